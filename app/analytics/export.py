@@ -0,0 +1,184 @@
+# Location: app/analytics/export.py
+
+import json
+import logging
+import queue
+from abc import ABC, abstractmethod
+from functools import lru_cache
+from typing import Any, Dict, List, Optional
+
+from app.analytics.schema import SCHEMA_VERSION
+from app.core.config import get_settings
+from app.core.metrics import ANALYTICS_ROWS_TOTAL
+from app.core.pubsub import get_publisher, topic_path
+from app.core.worker import PollingWorker
+
+Row = Dict[str, Any]
+
+
+class AnalyticsSinkError(Exception):
+    """The sink did not accept a batch of rows; the batch is tried again."""
+
+
+class AnalyticsSink(ABC):
+    """Where analytics rows end up: the BigQuery table, directly or through Pub/Sub."""
+
+    @abstractmethod
+    def write(self, rows: List[Row]) -> None:
+        """Writes a batch of rows. Raises AnalyticsSinkError if any was refused."""
+
+
+class BigQuerySink(AnalyticsSink):
+    """
+    Streams rows into the table with insertAll. The event ID is sent as
+    each row's insert ID, so BigQuery drops a row sent again shortly after.
+    """
+
+    def __init__(self, table: str):
+        self.table = table
+
+    @staticmethod
+    @lru_cache
+    def _client():
+        from google.cloud import bigquery
+        return bigquery.Client()
+
+    def write(self, rows: List[Row]) -> None:
+        try:
+            errors = self._client().insert_rows_json(self.table, rows, row_ids=[row["event_id"] for row in rows])
+        except Exception as e:
+            raise AnalyticsSinkError(f"{type(e).__name__}: {e}") from e
+        if errors:
+            raise AnalyticsSinkError(f"{len(errors)} rows refused, first: {errors[0]}")
+
+
+class PubSubSink(AnalyticsSink):
+    """
+    Publishes each row as a JSON message to a topic whose BigQuery
+    subscription writes it to the table by column name. The schema version
+    is also set as a message attribute.
+    """
+
+    def __init__(self, topic: str, timeout_seconds: float):
+        self.topic = topic
+        self.timeout_seconds = timeout_seconds
+
+    def write(self, rows: List[Row]) -> None:
+        path = topic_path(self.topic)
+        publisher = get_publisher()
+        futures = [
+            publisher.publish(path, json.dumps(row, separators=(",", ":")).encode("utf-8"), schemaVersion=str(SCHEMA_VERSION))
+            for row in rows
+        ]
+        failed = 0
+        for future in futures:
+            try:
+                future.result(timeout=self.timeout_seconds)
+            except Exception:
+                failed += 1
+        if failed:
+            # Publishing the whole batch again duplicates the rows that got
+            # through; analytics queries deduplicate on event_id.
+            raise AnalyticsSinkError(f"{failed} of {len(rows)} rows not published")
+
+
+@lru_cache
+def get_analytics_sink() -> Optional[AnalyticsSink]:
+    """Returns the configured sink, or None with ANALYTICS_SINK=none."""
+    settings = get_settings()
+    if settings.analytics_sink == "bigquery":
+        return BigQuerySink(settings.analytics_table)
+    if settings.analytics_sink == "pubsub":
+        return PubSubSink(settings.analytics_topic, settings.pubsub_publish_timeout_seconds)
+    return None
+
+
+class AnalyticsRecorder:
+    """
+    The rows a worker process has yet to export. Recording never blocks or
+    fails a request: once `max_rows` are waiting, further rows are dropped
+    and counted, so an unreachable sink cannot exhaust the worker's memory.
+    """
+
+    def __init__(self, max_rows: int):
+        self._rows: "queue.Queue[Row]" = queue.Queue(maxsize=max_rows)
+
+    def record(self, row: Row) -> None:
+        try:
+            self._rows.put_nowait(row)
+        except queue.Full:
+            ANALYTICS_ROWS_TOTAL.labels(category=row["category"], result="dropped").inc()
+
+    def take(self, limit: int) -> List[Row]:
+        rows = []
+        while len(rows) < limit:
+            try:
+                rows.append(self._rows.get_nowait())
+            except queue.Empty:
+                break
+        return rows
+
+
+@lru_cache
+def get_analytics_recorder() -> Optional[AnalyticsRecorder]:
+    """Returns this worker's recorder, or None with ANALYTICS_SINK=none."""
+    settings = get_settings()
+    if settings.analytics_sink == "none":
+        return None
+    return AnalyticsRecorder(settings.analytics_queue_size)
+
+
+class AnalyticsExporter(PollingWorker):
+    """
+    Writes the recorded rows to the sink in batches. A batch the sink
+    refuses is tried again on the next passes and dropped after
+    `max_attempts`, so export is best-effort: rows are lost with a worker
+    that stops before exporting them, or when the sink stays down.
+    """
+    name = "analytics-exporter"
+
+    def __init__(self, recorder: AnalyticsRecorder, sink: AnalyticsSink, batch_size: int = 500, max_attempts: int = 3):
+        super().__init__()
+        self.recorder = recorder
+        self.sink = sink
+        self.batch_size = batch_size
+        self.max_attempts = max_attempts
+        self._pending: List[Row] = []
+        self._attempts = 0
+
+    def drain(self) -> int:
+        """Exports one batch. Returns how many rows were written."""
+        if not self._pending:
+            self._pending, self._attempts = self.recorder.take(self.batch_size), 0
+        if not self._pending:
+            return 0
+        rows = self._pending
+        self._attempts += 1
+        try:
+            self.sink.write(rows)
+        except AnalyticsSinkError as e:
+            if self._attempts < self.max_attempts:
+                logging.warning(f"Analytics export of {len(rows)} rows failed (attempt {self._attempts}): {e}")
+                return 0
+            logging.error(f"Dropped {len(rows)} analytics rows after {self._attempts} attempts: {e}", extra={"report_error": True})
+            self._count(rows, "failed")
+            self._pending = []
+            return 0
+        self._count(rows, "exported")
+        self._pending = []
+        return len(rows)
+
+    def flush(self) -> None:
+        """Exports what is left when the worker shuts down, one attempt per batch."""
+        while True:
+            if not self._pending:
+                self._pending = self.recorder.take(self.batch_size)
+            if not self._pending:
+                return
+            self._attempts = self.max_attempts - 1
+            self.drain()
+
+    @staticmethod
+    def _count(rows: List[Row], result: str) -> None:
+        for row in rows:
+            ANALYTICS_ROWS_TOTAL.labels(category=row["category"], result=result).inc()
@@ -0,0 +1,29 @@
+# Location: app/analytics/publisher.py
+
+from app.analytics.export import AnalyticsRecorder
+from app.analytics.rows import event_row
+from app.events.envelope import Event
+from app.events.publisher import EventPublisher
+from app.repositories.transactions import after_commit
+
+
+class AnalyticsEventPublisher(EventPublisher):
+    """
+    Queues the event's de-identified analytics row for export once the
+    change it describes has committed, so changes rolled back are never
+    counted, then hands the event on to `inner`, failing as it does.
+    """
+
+    def __init__(self, inner: EventPublisher, recorder: AnalyticsRecorder, pseudonym_key: str):
+        self.inner = inner
+        self.recorder = recorder
+        self.pseudonym_key = pseudonym_key
+
+    @property
+    def transactional(self) -> bool:
+        return self.inner.transactional
+
+    def publish(self, event: Event) -> None:
+        row = event_row(self.pseudonym_key, event)
+        after_commit(lambda: self.recorder.record(row))
+        self.inner.publish(event)
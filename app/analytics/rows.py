@@ -0,0 +1,146 @@
+# Location: app/analytics/rows.py
+
+"""
+Turns domain events and API requests into analytics rows (see
+app/analytics/schema.py) that carry nothing that identifies a patient or a
+user: IDs are replaced by keyed pseudonyms, the same for the same ID in
+every row, so the data team can count and join without learning whose
+records they are; of a record only the ATTRIBUTES listed for its kind are
+kept, e.g. an appointment's type and status but never its reason. Record
+dates are reduced to durations. Free text, names, contact details, dates
+of birth and codes of a patient's own (an MRN, an SSN) are never exported.
+"""
+
+import hashlib
+import hmac
+import json
+import uuid
+from datetime import datetime
+from typing import Any, Dict, Iterable, List, Optional
+
+from app.analytics.schema import SCHEMA_VERSION
+from app.events.envelope import Event
+
+USAGE = "usage"
+CLINICAL_OPERATIONS = "clinical_operations"
+REQUEST_EVENT_TYPE = "api.request"
+
+# The fields of each kind of record that are exported, by their API
+# (camelCase) name; a dotted name reads into a nested object. Only string,
+# number and boolean values are kept. Kinds are named as in event types.
+ATTRIBUTES: Dict[str, List[str]] = {
+    "patient": ["sex"],
+    "practitioner": ["specialty", "active"],
+    "care_team": ["status"],
+    "appointment": ["appointmentType", "status"],
+    "encounter": ["visitType", "status"],
+    "observation": ["code.system", "code.code", "status", "unit"],
+    "lab_result": ["panelCode", "status", "abnormal"],
+    "care_plan": ["category", "status"],
+    "medication": ["code.system", "code.code", "status", "route", "refillsAllowed", "refillsUsed"],
+    "refill_request": ["status"],
+    "allergy": ["type", "category", "criticality", "clinicalStatus", "verificationStatus"],
+    "immunization": ["vaccineCode.code", "status", "doseNumber", "seriesDoses"],
+    "document": ["type", "contentType", "size", "status"],
+    "wound_image": ["contentType", "size"],
+    "consent": ["scope", "status", "documentVersion"],
+    "consent_document": ["scope", "language", "version", "materialChange"],
+    "telehealth_session": ["provider", "status", "durationSeconds"],
+}
+
+# Spans exported as a duration instead of the two dates: (start, end, name).
+DURATIONS: Dict[str, List[tuple]] = {
+    "appointment": [("start", "end", "durationMinutes")],
+    "encounter": [("start", "end", "durationMinutes")],
+}
+
+
+def pseudonym(key: str, value: Optional[str]) -> Optional[str]:
+    """The stable keyed pseudonym of an ID; without the key it cannot be reversed or recomputed."""
+    if not value:
+        return None
+    return hmac.new(key.encode(), str(value).encode(), hashlib.sha256).hexdigest()[:32]
+
+
+def _read(data: Dict[str, Any], dotted: str) -> Any:
+    value: Any = data
+    for part in dotted.split("."):
+        if not isinstance(value, dict):
+            return None
+        value = value.get(part)
+    return value
+
+
+def _minutes_between(start: Any, end: Any) -> Optional[int]:
+    try:
+        return int((datetime.fromisoformat(end) - datetime.fromisoformat(start)).total_seconds() // 60)
+    except (TypeError, ValueError):
+        return None
+
+
+def attributes(kind: str, data: Dict[str, Any]) -> Dict[str, Any]:
+    """The exported details of a record of this kind, from its API form."""
+    kept = {}
+    for name in ATTRIBUTES.get(kind, []):
+        value = _read(data, name)
+        if isinstance(value, (str, int, float, bool)):
+            kept[name] = value
+    for start, end, name in DURATIONS.get(kind, []):
+        minutes = _minutes_between(data.get(start), data.get(end))
+        if minutes is not None:
+            kept[name] = minutes
+    return kept
+
+
+def _row(category: str, event_type: str, occurred_at: datetime, organization_id: Optional[str], **columns) -> Dict[str, Any]:
+    row = {
+        "event_id": columns.pop("event_id", None) or uuid.uuid4().hex,
+        "schema_version": SCHEMA_VERSION,
+        "category": category,
+        "event_type": event_type,
+        "occurred_at": occurred_at.isoformat(),
+        "organization_id": organization_id,
+        "actor_roles": columns.pop("actor_roles", None) or [],
+    }
+    if "attributes" in columns:
+        # BigQuery takes JSON columns as JSON text, from insertAll and subscriptions alike.
+        columns["attributes"] = json.dumps(columns["attributes"], separators=(",", ":"))
+    row.update(columns)
+    return row
+
+
+def event_row(key: str, event: Event) -> Dict[str, Any]:
+    """The analytics row of a domain event. Its ID is the event's, so a row sent twice is one event."""
+    kind = event.type.split(".", 1)[0]
+    record_id = event.subject.rstrip("/").rsplit("/", 1)[-1]
+    return _row(
+        CLINICAL_OPERATIONS, event.type, event.occurred_at, event.tenant_id,
+        event_id=event.id,
+        resource=kind,
+        resource_key=pseudonym(key, record_id),
+        patient_key=pseudonym(key, event.data.get("patientId")),
+        attributes=attributes(kind, event.data),
+    )
+
+
+def request_row(
+    key: str,
+    occurred_at: datetime,
+    route: str,
+    method: str,
+    status_code: int,
+    duration_ms: float,
+    actor_uid: Optional[str],
+    actor_roles: Iterable[str],
+    organization_id: Optional[str],
+) -> Dict[str, Any]:
+    """The analytics row of one API request, by route template rather than path so no ID is exported."""
+    return _row(
+        USAGE, REQUEST_EVENT_TYPE, occurred_at, organization_id,
+        actor_key=pseudonym(key, actor_uid),
+        actor_roles=sorted(actor_roles),
+        route=route,
+        method=method,
+        status_code=status_code,
+        duration_ms=round(duration_ms, 3),
+    )
@@ -0,0 +1,51 @@
+# Location: app/analytics/schema.py
+
+"""
+The layout of the analytics table, one row per usage or clinical-operations
+event. Every row carries the SCHEMA_VERSION it was written with, so queries
+can tell rows apart across changes. Changes are additive only: a new
+version may add NULLABLE columns and new `attributes` keys, never rename,
+retype or drop one, so a table created for an older version keeps
+accepting rows. Bump SCHEMA_VERSION with every change, add the change to
+CHANGES, then update the table before deploying:
+
+    python -m app.analytics.schema > analytics.json
+    bq update <project>:<dataset>.<table> analytics.json
+
+(`bq mk --table --time_partitioning_field=occurred_at ...` with the same
+file creates it.) A BigQuery subscription writing the topic to the table
+(ANALYTICS_SINK=pubsub) must be created with --use-table-schema.
+"""
+
+import json
+from typing import Any, Dict, List
+
+SCHEMA_VERSION = 1
+
+# What each version changed, for the data team.
+CHANGES = {
+    1: "Initial layout.",
+}
+
+TABLE_SCHEMA: List[Dict[str, Any]] = [
+    {"name": "event_id", "type": "STRING", "mode": "REQUIRED", "description": "Unique per event; rows sent again carry the same ID."},
+    {"name": "schema_version", "type": "INTEGER", "mode": "REQUIRED"},
+    {"name": "category", "type": "STRING", "mode": "REQUIRED", "description": "'usage' (an API request) or 'clinical_operations' (a domain event)."},
+    {"name": "event_type", "type": "STRING", "mode": "REQUIRED", "description": "'api.request', or the domain event type, e.g. 'appointment.booked'."},
+    {"name": "occurred_at", "type": "TIMESTAMP", "mode": "REQUIRED"},
+    {"name": "organization_id", "type": "STRING", "mode": "NULLABLE"},
+    {"name": "resource", "type": "STRING", "mode": "NULLABLE", "description": "The kind of record the event is about, e.g. 'appointment'."},
+    {"name": "resource_key", "type": "STRING", "mode": "NULLABLE", "description": "Pseudonym of the record's ID."},
+    {"name": "patient_key", "type": "STRING", "mode": "NULLABLE", "description": "Pseudonym of the patient's ID."},
+    {"name": "actor_key", "type": "STRING", "mode": "NULLABLE", "description": "Pseudonym of the caller's user ID."},
+    {"name": "actor_roles", "type": "STRING", "mode": "REPEATED"},
+    {"name": "route", "type": "STRING", "mode": "NULLABLE", "description": "Route template of an API request, e.g. '/api/v1/patients/{patientId}'."},
+    {"name": "method", "type": "STRING", "mode": "NULLABLE"},
+    {"name": "status_code", "type": "INTEGER", "mode": "NULLABLE"},
+    {"name": "duration_ms", "type": "FLOAT", "mode": "NULLABLE"},
+    {"name": "attributes", "type": "JSON", "mode": "NULLABLE", "description": "Non-identifying details of the record, e.g. an appointment's type and status."},
+]
+
+
+if __name__ == "__main__":
+    print(json.dumps(TABLE_SCHEMA, indent=2))
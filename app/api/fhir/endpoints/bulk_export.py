@@ -0,0 +1,173 @@
+from fastapi import APIRouter, Body, Depends, Header, Request, Response, status
+from fastapi.responses import JSONResponse
+from datetime import datetime, timedelta, timezone
+from email.utils import format_datetime
+from typing import Any, Dict, List, Optional
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_export_job_repository, get_task_queue
+from app.authz.policy import require_step_up
+from app.core.config import get_settings
+from app.core.storage import signed_download_url
+from app.dependencies.auth import get_current_user
+from app.fhir.bulk_export import NDJSON_FORMATS, SUPPORTED_TYPES
+from app.fhir.responses import fhir_base_url, operation_outcome
+from app.repositories.export_jobs import ExportJobRepository
+from app.tasks.jobs import BULK_EXPORT_TASK
+from app.tasks.queue import TaskQueue
+
+router = APIRouter()
+
+# --- Configuration ---
+settings = get_settings()
+FHIR_EXPORT_BUCKET = settings.fhir_export_bucket
+FHIR_EXPORT_URL_TTL_SECONDS = settings.fhir_export_url_ttl_seconds
+
+# Suggested polling interval for clients while a job is running.
+RETRY_AFTER_SECONDS = 10
+
+
+def _kickoff_parameters(request: Request, body: Optional[Dict[str, Any]]) -> Dict[str, str]:
+    """
+    Collects kick-off parameters from the query string and, for POST, from a
+    FHIR `Parameters` resource body. Body values win.
+    """
+    params = {k: v for k, v in request.query_params.items() if k in ("_type", "_since", "_outputFormat")}
+    if body and body.get("resourceType") == "Parameters":
+        for parameter in body.get("parameter", []):
+            value = next((v for k, v in parameter.items() if k.startswith("value")), None)
+            if parameter.get("name") in ("_type", "_since", "_outputFormat") and value is not None:
+                params[parameter["name"]] = str(value)
+    return params
+
+
+def _status_url(request: Request, job_id: str) -> str:
+    return f"{fhir_base_url(request)}/bulkstatus/{job_id}"
+
+
+def _get_owned_job(job_id: str, jobs: ExportJobRepository, current_user: Dict) -> Optional[schemas.ExportJob]:
+    """Returns the job only to the user who started it; other users get the same response as a missing job."""
+    job = jobs.get(job_id)
+    if not job or job.requested_by != current_user["uid"]:
+        return None
+    return job
+
+
+@router.api_route(
+    "/$export",
+    methods=["GET", "POST"],
+    status_code=status.HTTP_202_ACCEPTED,
+    dependencies=[Depends(require_step_up("start a bulk export"))],
+)
+def export_kickoff(
+    request: Request,
+    body: Optional[Dict[str, Any]] = Body(None),
+    prefer: Optional[str] = Header(None),
+    jobs: ExportJobRepository = Depends(get_export_job_repository),
+    tasks: TaskQueue = Depends(get_task_queue),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Bulk Data system-level export kick-off. Requires `Prefer: respond-async`.
+    Supports `_type` (comma-separated, default all supported types), `_since`
+    and `_outputFormat` (NDJSON only). Returns 202 with a `Content-Location`
+    status URL to poll. Staff must have verified a second factor within
+    MFA_STEP_UP_MAX_AGE_SECONDS.
+    """
+    if not FHIR_EXPORT_BUCKET:
+        return operation_outcome(status.HTTP_503_SERVICE_UNAVAILABLE, "not-supported", "Bulk export is not configured on this server")
+    if not prefer or "respond-async" not in prefer:
+        return operation_outcome(status.HTTP_400_BAD_REQUEST, "invalid", "The 'Prefer: respond-async' header is required")
+
+    params = _kickoff_parameters(request, body)
+    output_format = params.get("_outputFormat")
+    if output_format and output_format not in NDJSON_FORMATS:
+        return operation_outcome(status.HTTP_400_BAD_REQUEST, "not-supported", f"Unsupported _outputFormat '{output_format}'")
+
+    types: List[str] = [t.strip() for t in params.get("_type", "").split(",") if t.strip()] or list(SUPPORTED_TYPES)
+    unsupported = [t for t in types if t not in SUPPORTED_TYPES]
+    if unsupported:
+        return operation_outcome(status.HTTP_400_BAD_REQUEST, "not-supported", f"Unsupported _type: {', '.join(unsupported)}")
+
+    since = None
+    if params.get("_since"):
+        try:
+            since = datetime.fromisoformat(params["_since"])
+        except ValueError:
+            return operation_outcome(status.HTTP_400_BAD_REQUEST, "invalid", f"Invalid _since instant '{params['_since']}'")
+        if since.tzinfo is None:
+            return operation_outcome(status.HTTP_400_BAD_REQUEST, "invalid", "_since must include a timezone")
+
+    job = jobs.create(schemas.ExportJobCreate(
+        types=list(dict.fromkeys(types)),
+        since=since,
+        request_url=str(request.url),
+        requested_by=current_user["uid"],
+        transaction_time=datetime.now(timezone.utc),
+    ))
+    # Runs on Cloud Tasks, or in this worker after the 202 has been sent when no queue is configured.
+    tasks.enqueue(BULK_EXPORT_TASK, {"jobId": job.job_id}, task_id=f"bulk-export-{job.job_id}")
+
+    logging.info(f"User {current_user['uid']} started bulk export {job.job_id} for {', '.join(job.types)}")
+    return Response(status_code=status.HTTP_202_ACCEPTED, headers={"Content-Location": _status_url(request, job.job_id)})
+
+
+@router.get("/bulkstatus/{jobId}")
+def export_status(
+    jobId: str,
+    jobs: ExportJobRepository = Depends(get_export_job_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Bulk Data status request. Returns 202 with `X-Progress` while the job runs,
+    500 with an OperationOutcome if it failed, and 200 with the completion
+    manifest once the NDJSON files are ready. Download URLs are signed per
+    request and expire after FHIR_EXPORT_URL_TTL_SECONDS.
+    """
+    job = _get_owned_job(jobId, jobs, current_user)
+    if not job or job.status == "cancelled":
+        return operation_outcome(status.HTTP_404_NOT_FOUND, "not-found", f"Export job '{jobId}' not found")
+
+    if job.status in ("accepted", "in-progress"):
+        return Response(
+            status_code=status.HTTP_202_ACCEPTED,
+            headers={"X-Progress": job.progress or job.status, "Retry-After": str(RETRY_AFTER_SECONDS)},
+        )
+    if job.status == "error":
+        return operation_outcome(status.HTTP_500_INTERNAL_SERVER_ERROR, "exception", job.error or "Export failed")
+
+    expires = datetime.now(timezone.utc) + timedelta(seconds=FHIR_EXPORT_URL_TTL_SECONDS)
+    manifest = {
+        "transactionTime": job.transaction_time.isoformat(),
+        "request": job.request_url,
+        "requiresAccessToken": False,
+        "output": [
+            {
+                "type": f.type,
+                "url": signed_download_url(FHIR_EXPORT_BUCKET, f.blob_name, FHIR_EXPORT_URL_TTL_SECONDS),
+                "count": f.count,
+            }
+            for f in job.output
+        ],
+        "error": [],
+    }
+    return JSONResponse(content=manifest, headers={"Expires": format_datetime(expires, usegmt=True)})
+
+
+@router.delete("/bulkstatus/{jobId}", status_code=status.HTTP_202_ACCEPTED)
+def cancel_export(
+    jobId: str,
+    jobs: ExportJobRepository = Depends(get_export_job_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Bulk Data delete request: cancels a running export. The exporter stops
+    before its next resource type.
+    """
+    job = _get_owned_job(jobId, jobs, current_user)
+    if not job or job.status == "cancelled":
+        return operation_outcome(status.HTTP_404_NOT_FOUND, "not-found", f"Export job '{jobId}' not found")
+    jobs.update(jobId, {"status": "cancelled", "progress": None})
+    logging.info(f"User {current_user['uid']} cancelled bulk export {jobId}")
+    return Response(status_code=status.HTTP_202_ACCEPTED)
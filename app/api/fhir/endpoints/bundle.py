@@ -0,0 +1,39 @@
+from fastapi import Body, Depends, Request
+from typing import Any, Dict
+
+from app.api.v1.deps import get_event_publisher, get_observation_repository, get_patient_repository
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.fhir.bundles import BundleError, EntryFailed, process_bundle
+from app.fhir.responses import FHIRResponse, fhir_base_url, operation_outcome
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+
+
+async def post_bundle(
+    request: Request,
+    bundle: Dict[str, Any] = Body(...),
+    patients: PatientRepository = Depends(get_patient_repository),
+    observations: ObservationRepository = Depends(get_observation_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    FHIR batch and transaction: runs the requests in the entries of a Bundle
+    (create, read or search of Patient and Observation) and returns a
+    `batch-response` or `transaction-response` Bundle with each entry's
+    status, location and resource, in the order of the request. Each entry
+    needs the access it would need on its own. A refused or failed batch
+    entry is answered with its OperationOutcome and does not affect the
+    others. A transaction is stored whole or not at all: if any entry
+    fails, the request fails with that entry's status, and entries may
+    reference patients created earlier in the Bundle by their `urn:uuid:`
+    fullUrl.
+    """
+    try:
+        response = await process_bundle(
+            bundle, current_user, fhir_base_url(request), patients, observations, events,
+        )
+    except (BundleError, EntryFailed) as e:
+        return operation_outcome(e.status_code, e.code, str(e))
+    return FHIRResponse(content=response)
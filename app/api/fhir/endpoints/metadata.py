@@ -0,0 +1,95 @@
+from datetime import datetime, timezone
+from typing import Any, Dict, Optional
+import logging
+
+from fastapi import APIRouter, status
+from fastapi.responses import JSONResponse
+
+from app.fhir.responses import FHIR_JSON, FHIRResponse, operation_outcome
+from app.fhir.smart import get_smart_configuration
+
+router = APIRouter()
+
+# Advertised in the CapabilityStatement; keep in sync with the FHIR routers.
+SUPPORTED_RESOURCES = {
+    "Patient": {"interactions": ["read", "search-type", "create"], "search": [("identifier", "token")]},
+    "Observation": {"interactions": ["read", "search-type", "create"], "search": [("identifier", "token"), ("patient", "reference")]},
+}
+
+_STARTED_AT = datetime.now(timezone.utc).isoformat()
+
+SMART_SECURITY_SERVICE = {"system": "http://terminology.hl7.org/CodeSystem/restful-security-service", "code": "SMART-on-FHIR"}
+OAUTH_URIS_EXTENSION = "http://fhir-registry.smarthealthit.org/StructureDefinition/oauth-uris"
+
+
+def _security() -> Optional[Dict[str, Any]]:
+    """The CapabilityStatement's SMART security section, or None if SMART is not configured or unavailable."""
+    try:
+        configuration = get_smart_configuration()
+    except Exception as e:
+        logging.warning(f"Could not resolve the SMART configuration for the CapabilityStatement: {e}")
+        return None
+    if not configuration:
+        return None
+    return {
+        "service": [{"coding": [SMART_SECURITY_SERVICE]}],
+        "extension": [{
+            "url": OAUTH_URIS_EXTENSION,
+            "extension": [
+                {"url": "authorize", "valueUri": configuration["authorization_endpoint"]},
+                {"url": "token", "valueUri": configuration["token_endpoint"]},
+            ],
+        }],
+    }
+
+
+@router.get("/metadata", response_class=FHIRResponse)
+def capability_statement():
+    """
+    FHIR capabilities interaction. Unauthenticated, as the spec expects
+    clients to discover the server before obtaining a token.
+    """
+    rest = {
+        "mode": "server",
+        "interaction": [{"code": "batch"}, {"code": "transaction"}],
+        "resource": [
+            {
+                "type": resource_type,
+                "interaction": [{"code": code} for code in spec["interactions"]],
+                "searchParam": [{"name": name, "type": param_type} for name, param_type in spec["search"]],
+            }
+            for resource_type, spec in SUPPORTED_RESOURCES.items()
+        ],
+        "operation": [
+            {"name": "export", "definition": "http://hl7.org/fhir/uv/bulkdata/OperationDefinition/export"},
+        ],
+    }
+    security = _security()
+    if security:
+        rest["security"] = security
+    return FHIRResponse(content={
+        "resourceType": "CapabilityStatement",
+        "status": "active",
+        "date": _STARTED_AT,
+        "kind": "instance",
+        "fhirVersion": "4.0.1",
+        "format": [FHIR_JSON, "json"],
+        "rest": [rest],
+    })
+
+
+@router.get("/.well-known/smart-configuration")
+def smart_configuration_document():
+    """
+    SMART App Launch discovery: where apps obtain authorization, and the
+    scopes and launch flows this server supports. Unauthenticated, like
+    /metadata.
+    """
+    try:
+        configuration = get_smart_configuration()
+    except Exception as e:
+        logging.error(f"Could not resolve the SMART configuration: {e}")
+        return operation_outcome(status.HTTP_503_SERVICE_UNAVAILABLE, "transient", "The authorization server could not be reached")
+    if not configuration:
+        return operation_outcome(status.HTTP_404_NOT_FOUND, "not-found", "SMART on FHIR authorization is not configured")
+    return JSONResponse(content=configuration)
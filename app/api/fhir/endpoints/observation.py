@@ -0,0 +1,86 @@
+from fastapi import APIRouter, Body, Depends, Query, Request, status
+from typing import Any, Dict, Optional
+import logging
+
+from app.api.v1.deps import get_event_publisher, get_observation_repository, get_patient_repository
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.fhir.mappers import FHIRMappingError, observation_from_fhir, observation_to_fhir, parse_reference, parse_token
+from app.fhir.responses import FHIRResponse, fhir_base_url, operation_outcome, search_bundle
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.transactions import transactional
+
+router = APIRouter()
+
+@router.get("/{observationId}", response_class=FHIRResponse)
+def read_observation(
+    observationId: str,
+    repo: ObservationRepository = Depends(get_observation_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    FHIR read: returns the observation as a FHIR R4 Observation resource.
+    """
+    observation = repo.get(observationId)
+    if not observation:
+        return operation_outcome(status.HTTP_404_NOT_FOUND, "not-found", f"Observation/{observationId} not found")
+    return FHIRResponse(content=observation_to_fhir(observation))
+
+
+@router.get("", response_class=FHIRResponse)
+def search_observations(
+    request: Request,
+    identifier: Optional[str] = Query(None, description="Token search on the sender-assigned identifier."),
+    patient: Optional[str] = Query(None, description="Patient reference, e.g. 'Patient/123' or '123'."),
+    count: int = Query(30, alias="_count", ge=1, le=100),
+    repo: ObservationRepository = Depends(get_observation_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    FHIR search by identifier, or by patient (most recent first).
+    """
+    if identifier:
+        system, value = parse_token(identifier)
+        observations = repo.find_by_identifier(system, value)
+        if patient:
+            patient_id = parse_reference(patient, "Patient") or patient
+            observations = [o for o in observations if o.patient_id == patient_id]
+        observations = observations[:count]
+    elif patient:
+        observations = repo.list(parse_reference(patient, "Patient") or patient, limit=count)
+    else:
+        return operation_outcome(status.HTTP_400_BAD_REQUEST, "not-supported", "Observation search requires the 'identifier' or 'patient' parameter")
+    resources = [observation_to_fhir(o) for o in observations]
+    return FHIRResponse(content=search_bundle(fhir_base_url(request), str(request.url), resources))
+
+
+@router.post("", response_class=FHIRResponse, status_code=status.HTTP_201_CREATED)
+@transactional
+def create_observation(
+    request: Request,
+    resource: Dict[str, Any] = Body(...),
+    repo: ObservationRepository = Depends(get_observation_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    FHIR create: stores an Observation with a valueQuantity. The referenced
+    Patient must already exist.
+    """
+    try:
+        observation_in = observation_from_fhir(resource)
+    except FHIRMappingError as e:
+        return operation_outcome(status.HTTP_400_BAD_REQUEST, "invalid", str(e))
+    if not patients.get(observation_in.patient_id):
+        return operation_outcome(status.HTTP_422_UNPROCESSABLE_ENTITY, "processing", f"Patient/{observation_in.patient_id} not found")
+
+    observation = repo.create(observation_in)
+    events.emit("observation.recorded", f"patients/{observation.patient_id}/observations/{observation.observation_id}", observation)
+    logging.info(f"User {current_user['uid']} created observation {observation.observation_id} for patient {observation.patient_id} via FHIR")
+    return FHIRResponse(
+        status_code=status.HTTP_201_CREATED,
+        content=observation_to_fhir(observation),
+        headers={"Location": f"{fhir_base_url(request)}/Observation/{observation.observation_id}"},
+    )
@@ -0,0 +1,82 @@
+from fastapi import APIRouter, Body, Depends, Query, Request, status
+from typing import Any, Dict, Optional
+import logging
+
+from app.api.v1.deps import get_event_publisher, get_patient_repository
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.fhir.mappers import MRN_SYSTEM, FHIRMappingError, parse_token, patient_from_fhir, patient_to_fhir
+from app.fhir.responses import FHIRResponse, fhir_base_url, operation_outcome, search_bundle
+from app.repositories.base import ConflictError
+from app.repositories.patients import PatientRepository
+from app.repositories.transactions import transactional
+
+router = APIRouter()
+
+@router.get("/{patientId}", response_class=FHIRResponse)
+def read_patient(
+    patientId: str,
+    repo: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    FHIR read: returns the patient as a FHIR R4 Patient resource.
+    """
+    patient = repo.get(patientId)
+    if not patient:
+        return operation_outcome(status.HTTP_404_NOT_FOUND, "not-found", f"Patient/{patientId} not found")
+    return FHIRResponse(content=patient_to_fhir(patient))
+
+
+@router.get("", response_class=FHIRResponse)
+def search_patients(
+    request: Request,
+    identifier: Optional[str] = Query(None, description="Token search, e.g. 'urn:megacare:mrn|MRN-001'."),
+    repo: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    FHIR search by identifier. Only MegaCare MRNs are searchable, so a token
+    with any other system returns an empty Bundle.
+    """
+    if not identifier:
+        return operation_outcome(status.HTTP_400_BAD_REQUEST, "not-supported", "Patient search requires the 'identifier' parameter")
+    system, value = parse_token(identifier)
+    patients = []
+    if system in (None, MRN_SYSTEM):
+        patient = repo.get_by_mrn(value)
+        if patient:
+            patients.append(patient)
+    resources = [patient_to_fhir(p) for p in patients]
+    return FHIRResponse(content=search_bundle(fhir_base_url(request), str(request.url), resources))
+
+
+@router.post("", response_class=FHIRResponse, status_code=status.HTTP_201_CREATED)
+@transactional
+def create_patient(
+    request: Request,
+    resource: Dict[str, Any] = Body(...),
+    repo: PatientRepository = Depends(get_patient_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    FHIR create: registers a patient from a FHIR Patient resource. The
+    resource must carry an identifier with the MegaCare MRN system.
+    """
+    try:
+        patient_in = patient_from_fhir(resource)
+    except FHIRMappingError as e:
+        return operation_outcome(status.HTTP_400_BAD_REQUEST, "invalid", str(e))
+    try:
+        patient = repo.create(patient_in)
+    except ConflictError as e:
+        return operation_outcome(status.HTTP_409_CONFLICT, "duplicate", str(e))
+
+    events.emit("patient.created", f"patients/{patient.patient_id}", patient)
+    logging.info(f"User {current_user['uid']} created patient {patient.patient_id} via FHIR")
+    return FHIRResponse(
+        status_code=status.HTTP_201_CREATED,
+        content=patient_to_fhir(patient),
+        headers={"Location": f"{fhir_base_url(request)}/Patient/{patient.patient_id}"},
+    )
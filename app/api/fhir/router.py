@@ -0,0 +1,27 @@
+# Location: app/api/fhir/router.py
+
+from fastapi import APIRouter, Depends
+
+from app.api.fhir.endpoints import bulk_export, bundle, metadata, observation, patient
+from app.authz.smart import authorize_fhir
+from app.fhir.responses import FHIRResponse
+
+# --- FHIR R4 Router ---
+# Serves internal models as FHIR R4 JSON (application/fhir+json) for EHR
+# integrations. Mounted in `app.main` at FHIR_BASE_PATH. Errors are returned
+# as OperationOutcome resources rather than the problem details used by
+# /api/v1 (see app/errors/handlers.py).
+# Access is checked against the token's SMART scopes, or for tokens without
+# them as for /api/v1/patients (see app/authz/smart.py). Bulk exports span
+# all patients and resource types, and the entries of batch and transaction
+# Bundles are checked one by one (see app/fhir/bundles.py).
+fhir_router = APIRouter()
+
+fhir_router.include_router(metadata.router)
+fhir_router.include_router(bulk_export.router, dependencies=[Depends(authorize_fhir("*", action="r"))])
+fhir_router.include_router(patient.router, prefix="/Patient", dependencies=[Depends(authorize_fhir("Patient"))])
+fhir_router.include_router(observation.router, prefix="/Observation", dependencies=[Depends(authorize_fhir("Observation"))])
+
+# Bundles are posted to the base URL itself, which an included router
+# cannot route.
+fhir_router.add_api_route("", bundle.post_bundle, methods=["POST"], response_class=FHIRResponse)
@@ -0,0 +1,63 @@
+# Location: app/api/graphql/loaders.py
+
+from datetime import datetime
+from typing import Any, Callable, Dict, List, Optional, Tuple
+
+from starlette.concurrency import run_in_threadpool
+from strawberry.dataloader import DataLoader
+
+from app.api.v1 import schemas
+from app.repositories.appointments import AppointmentRepository
+from app.repositories.medications import MedicationRepository
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.practitioners import PractitionerRepository
+
+# Keys of the per-patient list loaders: the patient and the field's arguments.
+AppointmentsKey = Tuple[str, Optional[datetime], int]
+MedicationsKey = Tuple[str, Optional[str]]
+ObservationsKey = Tuple[str, Optional[str], int]
+
+
+def _by_id(get_many: Callable[[List[str]], Dict[str, Any]]) -> Callable:
+    async def load(ids: List[str]) -> List[Optional[Any]]:
+        found = await run_in_threadpool(get_many, list(ids))
+        return [found.get(record_id) for record_id in ids]
+    return load
+
+
+def _each(fetch: Callable[[Any], List[Any]]) -> Callable:
+    # Lists are limited per patient, which a single query across patients
+    # cannot do, so each key is its own query, run together in one thread.
+    async def load(keys: List[Any]) -> List[List[Any]]:
+        return await run_in_threadpool(lambda: [fetch(key) for key in keys])
+    return load
+
+
+class Loaders:
+    """
+    The DataLoaders of one GraphQL request. Every record is read at most
+    once per request, and records fetched by ID (patients, and the
+    practitioners behind appointments and prescriptions) are read in one
+    batched call per resolver level rather than one call per parent.
+    """
+
+    def __init__(
+        self,
+        patients: PatientRepository,
+        practitioners: PractitionerRepository,
+        appointments: AppointmentRepository,
+        medications: MedicationRepository,
+        observations: ObservationRepository,
+    ):
+        self.patient: DataLoader[str, Optional[schemas.Patient]] = DataLoader(_by_id(patients.get_many))
+        self.practitioner: DataLoader[str, Optional[schemas.Practitioner]] = DataLoader(_by_id(practitioners.get_many))
+        self.appointments: DataLoader[AppointmentsKey, List[schemas.Appointment]] = DataLoader(_each(
+            lambda key: appointments.list(patient_id=key[0], start_from=key[1], limit=key[2])
+        ))
+        self.medications: DataLoader[MedicationsKey, List[schemas.Medication]] = DataLoader(_each(
+            lambda key: medications.list(key[0], status=key[1])
+        ))
+        self.observations: DataLoader[ObservationsKey, List[schemas.Observation]] = DataLoader(_each(
+            lambda key: observations.list(key[0], code=key[1], limit=key[2])
+        ))
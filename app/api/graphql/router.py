@@ -0,0 +1,47 @@
+# Location: app/api/graphql/router.py
+
+from typing import Dict
+
+from fastapi import Depends
+from strawberry.fastapi import GraphQLRouter
+
+from app.api.graphql.loaders import Loaders
+from app.api.graphql.schema import GraphQLContext, schema
+from app.api.v1.deps import (
+    get_appointment_repository,
+    get_medication_repository,
+    get_observation_repository,
+    get_patient_repository,
+    get_practitioner_repository,
+)
+from app.core.config import get_settings
+from app.authz.policy import require_mfa
+from app.repositories.appointments import AppointmentRepository
+from app.repositories.medications import MedicationRepository
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.practitioners import PractitionerRepository
+
+
+def get_context(
+    current_user: Dict = Depends(require_mfa),
+    patients: PatientRepository = Depends(get_patient_repository),
+    practitioners: PractitionerRepository = Depends(get_practitioner_repository),
+    appointments: AppointmentRepository = Depends(get_appointment_repository),
+    medications: MedicationRepository = Depends(get_medication_repository),
+    observations: ObservationRepository = Depends(get_observation_repository),
+) -> GraphQLContext:
+    """Authenticates the request, holding it to MFA_REQUIRED_ROLES, and gives it fresh loaders, so nothing is cached across requests."""
+    return GraphQLContext(current_user, Loaders(patients, practitioners, appointments, medications, observations))
+
+
+# --- GraphQL Router ---
+# POST /graphql for the patient portal, which fetches its dashboard in one
+# round trip. Callers authenticate with a bearer token as for /api/v1, and
+# each field applies the same role permissions as the REST routes.
+# GraphiQL is served on GET /graphql with the other API docs.
+graphql_router = GraphQLRouter(
+    schema,
+    context_getter=get_context,
+    graphql_ide="graphiql" if get_settings().api_docs_enabled else None,
+)
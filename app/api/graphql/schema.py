@@ -0,0 +1,253 @@
+# Location: app/api/graphql/schema.py
+
+import asyncio
+import logging
+from datetime import date, datetime, timezone
+from typing import Dict, List, Optional
+
+import strawberry
+from strawberry.extensions import QueryDepthLimiter
+from strawberry.fastapi import BaseContext
+from strawberry.types import Info
+
+from app.api.graphql.loaders import Loaders
+from app.api.v1 import schemas
+from app.audit.context import annotate
+from app.authz.roles import PATIENT_ID_CLAIM, grants, has_permission
+
+# Deep enough for patient -> appointments -> practitioner, with room to
+# spare; stops clients from nesting queries without bound.
+MAX_QUERY_DEPTH = 6
+
+
+class GraphQLContext(BaseContext):
+    def __init__(self, principal: Dict, loaders: Loaders):
+        super().__init__()
+        self.principal = principal
+        self.loaders = loaders
+
+
+class AccessDenied(Exception):
+    """Reported as the error of the field the caller may not read."""
+
+
+def _check_access(info: Info, resource: str, patient_id: str) -> None:
+    """
+    Applies the role permissions of the REST routes (see app/authz) to a
+    read of `resource` for `patient_id`: the caller needs `<resource>:read`,
+    or the own-record permission and this patient in their `patientId` claim.
+    Kinds of record under a patient are checked as `patients.<kind>`, which
+    `patients:read` covers unless a role denies the kind.
+    """
+    principal = info.context.principal
+    needed = f"{resource}:read"
+    full, own = grants(principal)
+    if has_permission(full, needed):
+        return
+    owner = has_permission(own, needed)
+    if owner and patient_id == principal.get(PATIENT_ID_CLAIM):
+        return
+    logging.info(f"User {principal.get('uid')} denied GraphQL read of {resource} for patient {patient_id}")
+    raise AccessDenied("You may only access your own records" if owner else "You do not have permission to access this resource")
+
+
+@strawberry.type
+class Coding:
+    system: str
+    code: str
+    display: Optional[str]
+
+    @staticmethod
+    def from_model(coding: schemas.Coding) -> "Coding":
+        return Coding(system=coding.system, code=coding.code, display=coding.display)
+
+
+@strawberry.type
+class Practitioner:
+    id: strawberry.ID
+    given_name: str
+    family_name: str
+    specialty: Optional[str]
+
+    @staticmethod
+    def from_model(practitioner: schemas.Practitioner) -> "Practitioner":
+        return Practitioner(
+            id=strawberry.ID(practitioner.practitioner_id),
+            given_name=practitioner.given_name,
+            family_name=practitioner.family_name,
+            specialty=practitioner.specialty,
+        )
+
+
+async def _practitioner(info: Info, practitioner_id: Optional[str]) -> Optional[Practitioner]:
+    if not practitioner_id:
+        return None
+    practitioner = await info.context.loaders.practitioner.load(practitioner_id)
+    return Practitioner.from_model(practitioner) if practitioner else None
+
+
+@strawberry.type
+class Appointment:
+    id: strawberry.ID
+    start: datetime
+    end: datetime
+    status: str
+    appointment_type: Optional[str]
+    reason: Optional[str]
+    location: Optional[str]
+    practitioner_id: strawberry.Private[str]
+
+    @strawberry.field
+    async def practitioner(self, info: Info) -> Optional[Practitioner]:
+        return await _practitioner(info, self.practitioner_id)
+
+    @staticmethod
+    def from_model(appointment: schemas.Appointment) -> "Appointment":
+        return Appointment(
+            id=strawberry.ID(appointment.appointment_id),
+            start=appointment.start,
+            end=appointment.end,
+            status=appointment.status,
+            appointment_type=appointment.appointment_type,
+            reason=appointment.reason,
+            location=appointment.location,
+            practitioner_id=appointment.practitioner_id,
+        )
+
+
+@strawberry.type
+class Medication:
+    id: strawberry.ID
+    name: str
+    code: Optional[Coding]
+    dosage: Optional[str]
+    route: Optional[str]
+    status: str
+    refills_allowed: int
+    refills_used: int
+    start_date: Optional[date]
+    end_date: Optional[date]
+    prescriber_id: strawberry.Private[Optional[str]]
+
+    @strawberry.field
+    async def prescriber(self, info: Info) -> Optional[Practitioner]:
+        return await _practitioner(info, self.prescriber_id)
+
+    @staticmethod
+    def from_model(medication: schemas.Medication) -> "Medication":
+        return Medication(
+            id=strawberry.ID(medication.medication_id),
+            name=medication.name,
+            code=Coding.from_model(medication.code) if medication.code else None,
+            dosage=medication.dosage,
+            route=medication.route,
+            status=medication.status,
+            refills_allowed=medication.refills_allowed,
+            refills_used=medication.refills_used,
+            start_date=medication.start_date,
+            end_date=medication.end_date,
+            prescriber_id=medication.prescriber_id,
+        )
+
+
+@strawberry.type
+class ObservationComponent:
+    code: Coding
+    value: Optional[float]
+    unit: Optional[str]
+
+
+@strawberry.type
+class Observation:
+    id: strawberry.ID
+    code: Coding
+    status: str
+    value: Optional[float]
+    unit: Optional[str]
+    components: List[ObservationComponent]
+    effective_at: datetime
+
+    @staticmethod
+    def from_model(observation: schemas.Observation) -> "Observation":
+        return Observation(
+            id=strawberry.ID(observation.observation_id),
+            code=Coding.from_model(observation.code),
+            status=observation.status,
+            value=observation.value,
+            unit=observation.unit,
+            components=[
+                ObservationComponent(code=Coding.from_model(component.code), value=component.value, unit=component.unit)
+                for component in observation.components
+            ],
+            effective_at=observation.effective_at,
+        )
+
+
+@strawberry.type
+class Patient:
+    id: strawberry.ID
+    mrn: str
+    given_name: str
+    family_name: str
+    dob: date
+    sex: str
+
+    @strawberry.field(description="Appointments ordered by start time; by default those that have not started yet.")
+    async def appointments(self, info: Info, start_from: Optional[datetime] = None, first: int = 10) -> List[Appointment]:
+        _check_access(info, "appointments", self.id)
+        start_from = start_from or datetime.now(timezone.utc)
+        appointments = await info.context.loaders.appointments.load((self.id, start_from, min(first, 100)))
+        return [Appointment.from_model(a) for a in appointments]
+
+    @strawberry.field(description="The medication list, optionally only medications with a given status (e.g. active).")
+    async def medications(self, info: Info, status: Optional[str] = None) -> List[Medication]:
+        _check_access(info, "patients.medications", self.id)
+        medications = await info.context.loaders.medications.load((self.id, status))
+        return [Medication.from_model(m) for m in medications]
+
+    @strawberry.field(description="Observations newest first, optionally only those with a given code (e.g. LOINC 59408-5).")
+    async def observations(self, info: Info, code: Optional[str] = None, first: int = 20) -> List[Observation]:
+        _check_access(info, "patients.observations", self.id)
+        observations = await info.context.loaders.observations.load((self.id, code, min(first, 100)))
+        return [Observation.from_model(o) for o in observations]
+
+    @staticmethod
+    def from_model(patient: schemas.Patient) -> "Patient":
+        return Patient(
+            id=strawberry.ID(patient.patient_id),
+            mrn=patient.mrn,
+            given_name=patient.given_name,
+            family_name=patient.family_name,
+            dob=patient.dob,
+            sex=patient.sex,
+        )
+
+
+async def _patient(info: Info, patient_id: str) -> Optional[Patient]:
+    _check_access(info, "patients", patient_id)
+    patient = await info.context.loaders.patient.load(patient_id)
+    if not patient:
+        return None
+    annotate(patient_id=patient.patient_id)
+    return Patient.from_model(patient)
+
+
+@strawberry.type
+class Query:
+    @strawberry.field(description="The caller's own patient record, for patient portal users.")
+    async def me(self, info: Info) -> Optional[Patient]:
+        patient_id = info.context.principal.get(PATIENT_ID_CLAIM)
+        return await _patient(info, patient_id) if patient_id else None
+
+    @strawberry.field
+    async def patient(self, info: Info, id: strawberry.ID) -> Optional[Patient]:
+        return await _patient(info, id)
+
+    @strawberry.field(description="Several patients at once, e.g. for a clinician's list; unknown IDs are left out.")
+    async def patients(self, info: Info, ids: List[strawberry.ID]) -> List[Patient]:
+        # Gathered, so the patient loader reads them in one batch.
+        patients = await asyncio.gather(*(_patient(info, patient_id) for patient_id in ids))
+        return [p for p in patients if p]
+
+
+schema = strawberry.Schema(query=Query, extensions=[QueryDepthLimiter(max_depth=MAX_QUERY_DEPTH)])
@@ -0,0 +1,35 @@
+# Location: app/api/health.py
+
+from fastapi import APIRouter, status
+from fastapi.responses import JSONResponse
+
+from app.core import health
+from app.resilience.dependencies import breaker_states
+
+router = APIRouter()
+
+
+@router.get("/healthz")
+def liveness():
+    """
+    Liveness probe. Only confirms the process is serving requests; it does not
+    touch any dependency so a flaky downstream never causes a restart loop.
+    """
+    return {"status": "ok"}
+
+
+@router.get("/readyz")
+async def readiness():
+    """
+    Readiness/startup probe. Runs every registered dependency check and returns
+    their individual status. Responds with 503 if any check fails so Cloud Run
+    does not route traffic to a broken instance; the response names the
+    failing checks, and why they failed is only logged. The state of this worker's
+    circuit breakers is reported too, but does not fail the probe: another
+    instance would find the same dependency down.
+    """
+    all_ok, checks = await health.run_checks()
+    return JSONResponse(
+        status_code=status.HTTP_200_OK if all_ok else status.HTTP_503_SERVICE_UNAVAILABLE,
+        content={"status": "ok" if all_ok else "unavailable", "checks": checks, "circuitBreakers": breaker_states()},
+    )
@@ -0,0 +1,57 @@
+from fastapi import APIRouter, Depends, HTTPException, Request, Response, status
+from typing import Any, Dict, List
+import json
+import logging
+
+from app.api.v1.deps import get_notification_repository
+from app.core.config import get_settings
+from app.notifications.sendgrid_events import SIGNATURE_HEADER, TIMESTAMP_HEADER, delivery_events, verify_signature
+from app.repositories.base import NotFoundError
+from app.repositories.notifications import NotificationRepository
+
+router = APIRouter()
+
+# --- Configuration ---
+SENDGRID_WEBHOOK_PUBLIC_KEY = get_settings().sendgrid_webhook_public_key
+
+
+async def _signed_events(request: Request) -> List[Dict[str, Any]]:
+    """The events of a webhook request, once its signature is verified; the raw body is what was signed."""
+    if not SENDGRID_WEBHOOK_PUBLIC_KEY:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="SENDGRID_WEBHOOK_PUBLIC_KEY is not set")
+    payload = await request.body()
+    signature, timestamp = request.headers.get(SIGNATURE_HEADER), request.headers.get(TIMESTAMP_HEADER)
+    if not signature or not timestamp or not verify_signature(SENDGRID_WEBHOOK_PUBLIC_KEY, payload, signature, timestamp):
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="Invalid webhook signature")
+    try:
+        events = json.loads(payload)
+    except ValueError:
+        events = None
+    if not isinstance(events, list):
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="Expected a JSON array of events")
+    return [event for event in events if isinstance(event, dict)]
+
+
+@router.post("/email/sendgrid/events", status_code=status.HTTP_204_NO_CONTENT)
+def receive_sendgrid_events(
+    events: List[Dict[str, Any]] = Depends(_signed_events),
+    notifications: NotificationRepository = Depends(get_notification_repository)
+):
+    """
+    Receives SendGrid's signed Event Webhook and records the delivery,
+    deferral, bounce, drop and spam-report events of our notifications
+    against them. SendGrid calls it without a token; the signature
+    (verified with SENDGRID_WEBHOOK_PUBLIC_KEY) is the authentication.
+    Events are recorded once however often SendGrid sends them.
+    """
+    recorded = 0
+    for notification_id, event in delivery_events(events):
+        try:
+            notifications.record_delivery(notification_id, event)
+        except NotFoundError:
+            # E.g. purged since, or sent by another deployment sharing the SendGrid account.
+            logging.warning(f"Ignored SendGrid {event.status} event of unknown notification {notification_id}")
+            continue
+        recorded += 1
+    logging.info(f"Recorded {recorded} of {len(events)} SendGrid event(s)")
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
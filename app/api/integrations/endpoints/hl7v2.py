@@ -0,0 +1,68 @@
+from fastapi import APIRouter, Body, Depends, Response
+from typing import Dict
+from zoneinfo import ZoneInfo
+import logging
+
+from app.api.v1.deps import get_event_publisher, get_observation_repository, get_patient_repository
+from app.core.config import get_settings
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.hl7v2.ack import ERR_APPLICATION, ERR_DATA_TYPE, build_ack
+from app.hl7v2.parser import HL7ParseError, parse_message
+from app.hl7v2.processing import HL7ProcessingError, process_message
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.transactions import transactional
+
+router = APIRouter()
+
+# --- Configuration ---
+# HL7 timestamps without an offset are read in the sending hospital's timezone.
+HL7V2_DEFAULT_TIMEZONE = ZoneInfo(get_settings().hl7v2_default_timezone)
+
+HL7_ER7 = "x-application/hl7-v2+er7"
+
+
+def _ack_response(ack: str) -> Response:
+    return Response(content=ack, media_type=HL7_ER7)
+
+
+@router.post("/hl7v2", response_class=Response)
+@transactional
+def ingest_hl7v2(
+    message: bytes = Body(..., media_type=HL7_ER7),
+    patients: PatientRepository = Depends(get_patient_repository),
+    observations: ObservationRepository = Depends(get_observation_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Accepts one ER7-encoded HL7v2 message (ADT^A01/A04/A05/A08/A28/A31 or
+    ORU^R01) and replies with an ACK. The HTTP status is always 200 once the
+    request is authenticated; the outcome is in MSA-1: AA (applied), AE
+    (application error, may be resent after fixing) or AR (rejected).
+    """
+    try:
+        text = message.decode("utf-8")
+    except UnicodeDecodeError:
+        # Many interface engines still send ISO-8859-1.
+        text = message.decode("latin-1")
+
+    try:
+        parsed = parse_message(text)
+    except HL7ParseError as e:
+        logging.warning(f"Rejected unparseable HL7v2 message from user {current_user['uid']}: {e}")
+        return _ack_response(build_ack(None, "AR", str(e), ERR_DATA_TYPE))
+
+    message_code, trigger = parsed.message_type
+    try:
+        result = process_message(parsed, patients, observations, HL7V2_DEFAULT_TIMEZONE, events)
+    except HL7ProcessingError as e:
+        logging.warning(f"HL7v2 {message_code}^{trigger} {parsed.control_id} not applied ({e.code}): {e.text}")
+        return _ack_response(build_ack(parsed, e.code, e.text, e.error))
+    except Exception as e:
+        logging.exception(f"HL7v2 {message_code}^{trigger} {parsed.control_id} failed: {e}", extra={"report_error": True})
+        return _ack_response(build_ack(parsed, "AE", "Internal error while processing message", ERR_APPLICATION))
+
+    logging.info(f"User {current_user['uid']} sent HL7v2 {message_code}^{trigger} {parsed.control_id}: {result}")
+    return _ack_response(build_ack(parsed, "AA", result))
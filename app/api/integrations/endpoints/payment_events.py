@@ -0,0 +1,78 @@
+from fastapi import APIRouter, Depends, HTTPException, Request, Response, status
+from typing import Any, Dict
+import json
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_event_publisher, get_invoice_repository
+from app.core.config import get_settings
+from app.events.publisher import EventPublisher
+from app.invoices.payments import STRIPE_SIGNATURE_HEADER, stripe_payment, verify_stripe_signature
+from app.repositories.base import NotFoundError
+from app.repositories.invoices import InvoiceRepository
+from app.repositories.transactions import unit_of_work
+from app.services.invoices import InvalidInvoiceError
+from app.tenancy.context import acting_for
+
+router = APIRouter()
+
+# --- Configuration ---
+settings = get_settings()
+STRIPE_WEBHOOK_SECRET = settings.stripe_webhook_secret
+
+# Recorded as `recordedBy` on the payments Stripe reports.
+STRIPE_ACTOR = "stripe"
+
+
+async def _stripe_event(request: Request) -> Dict[str, Any]:
+    """The event of a Stripe webhook, once its signature is verified with STRIPE_WEBHOOK_SECRET."""
+    if not STRIPE_WEBHOOK_SECRET:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="STRIPE_WEBHOOK_SECRET is not set")
+    payload = await request.body()
+    signature = request.headers.get(STRIPE_SIGNATURE_HEADER)
+    if not signature or not verify_stripe_signature(STRIPE_WEBHOOK_SECRET, payload, signature):
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="Invalid webhook signature")
+    try:
+        event = json.loads(payload)
+    except ValueError:
+        event = None
+    if not isinstance(event, dict):
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="Expected a JSON object")
+    return event
+
+
+@router.post("/payments/stripe/events")
+def receive_stripe_event(
+    event: Dict[str, Any] = Depends(_stripe_event),
+    repo: InvoiceRepository = Depends(get_invoice_repository),
+    events: EventPublisher = Depends(get_event_publisher)
+):
+    """
+    Receives Stripe's webhook and records the payments of completed
+    checkouts on their invoices, as the invoice's organization. Stripe
+    sends an event again until it is answered 200, and a payment already
+    recorded is not recorded twice.
+    """
+    payment = stripe_payment(event)
+    if payment is None:
+        return Response(status_code=status.HTTP_200_OK)
+    payment_in = schemas.InvoicePaymentCreate(amount_cents=payment.amount_cents, method="card", reference=payment.reference)
+    with acting_for(payment.tenant_id), unit_of_work():
+        invoice = repo.get(payment.invoice_id)
+        if not invoice:
+            logging.warning(f"Ignored Stripe payment {payment.reference} of unknown invoice {payment.invoice_id}")
+            return Response(status_code=status.HTTP_200_OK)
+        if any(recorded.reference == payment.reference for recorded in invoice.payments):
+            return Response(status_code=status.HTTP_200_OK)
+        try:
+            updated = repo.record_payment(payment.invoice_id, payment_in, STRIPE_ACTOR, source="stripe")
+        except (InvalidInvoiceError, NotFoundError) as e:
+            # E.g. paid at the desk while the checkout was open; the payment is refunded at Stripe.
+            logging.error(f"Stripe payment {payment.reference} of invoice {payment.invoice_id} was not recorded: {e}", extra={"report_error": True})
+            return Response(status_code=status.HTTP_200_OK)
+        subject = f"invoices/{updated.invoice_id}"
+        events.emit("invoice.payment_recorded", subject, updated)
+        if updated.status != invoice.status:
+            events.emit("invoice.status_changed", subject, updated)
+    logging.info(f"Recorded Stripe payment {payment.reference} of {payment.amount_cents} cents on invoice {payment.invoice_id}")
+    return Response(status_code=status.HTTP_200_OK)
@@ -0,0 +1,77 @@
+from fastapi import APIRouter, Depends, HTTPException, Query, Request, Response, status
+from typing import Dict
+from urllib.parse import parse_qsl
+import logging
+
+from app.api.v1.deps import get_notification_repository, get_patient_repository
+from app.core.config import get_settings
+from app.notifications.twilio_events import SIGNATURE_HEADER, delivery_event, opt_out_keyword, verify_signature
+from app.repositories.base import NotFoundError
+from app.repositories.notifications import NotificationRepository
+from app.repositories.patients import PatientRepository
+
+router = APIRouter()
+
+# --- Configuration ---
+settings = get_settings()
+TWILIO_AUTH_TOKEN = settings.twilio_auth_token
+TWILIO_WEBHOOK_BASE_URL = settings.twilio_webhook_base_url
+
+# Twilio's Advanced Opt-Out (or the carrier) answers STOP and START itself,
+# so the reply to incoming messages is an empty TwiML response.
+EMPTY_TWIML = '<?xml version="1.0" encoding="UTF-8"?><Response></Response>'
+
+
+async def _signed_params(request: Request) -> Dict[str, str]:
+    """
+    The form parameters of a webhook request, once its signature is
+    verified. Twilio signs the public URL it called, which only
+    TWILIO_WEBHOOK_BASE_URL gives behind Cloud Run's proxy.
+    """
+    if not TWILIO_AUTH_TOKEN or not TWILIO_WEBHOOK_BASE_URL:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="TWILIO_AUTH_TOKEN and TWILIO_WEBHOOK_BASE_URL must be set")
+    params = dict(parse_qsl((await request.body()).decode("utf-8", "replace"), keep_blank_values=True))
+    url = TWILIO_WEBHOOK_BASE_URL.rstrip("/") + request.url.path + (f"?{request.url.query}" if request.url.query else "")
+    signature = request.headers.get(SIGNATURE_HEADER)
+    if not signature or not verify_signature(TWILIO_AUTH_TOKEN, url, params, signature):
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="Invalid webhook signature")
+    return params
+
+
+@router.post("/sms/twilio/status", status_code=status.HTTP_204_NO_CONTENT)
+def receive_twilio_status(
+    notificationId: str = Query(...),
+    params: Dict[str, str] = Depends(_signed_params),
+    notifications: NotificationRepository = Depends(get_notification_repository)
+):
+    """
+    Receives Twilio's status callback for a text message and records it
+    against the notification named in the callback URL. Of the statuses,
+    delivered, undelivered and failed are recorded; each once.
+    """
+    event = delivery_event(params)
+    if event:
+        try:
+            notifications.record_delivery(notificationId, event)
+        except NotFoundError:
+            logging.warning(f"Ignored Twilio {event.status} status of unknown notification {notificationId}")
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+@router.post("/sms/twilio/inbound")
+def receive_twilio_message(
+    params: Dict[str, str] = Depends(_signed_params),
+    patients: PatientRepository = Depends(get_patient_repository)
+):
+    """
+    Receives text messages sent to our number. STOP (and the other opt-out
+    keywords) records on every patient with the sender's number, in any
+    organization, that they opted out of texts; START opts them back in.
+    Other messages are not read and are not answered.
+    """
+    opted_out = opt_out_keyword(params.get("Body", ""))
+    phone_number = params.get("From")
+    if opted_out is not None and phone_number:
+        updated = patients.set_sms_opt_out(phone_number, opted_out)
+        logging.info(f"Recorded SMS {'opt-out' if opted_out else 'opt-in'} for patient(s) {', '.join(p.patient_id for p in updated) or '-'}")
+    return Response(content=EMPTY_TWIML, media_type="application/xml")
@@ -0,0 +1,151 @@
+from fastapi import APIRouter, Depends, HTTPException, Request, Response, status
+from typing import Any, Dict, Optional
+from urllib.parse import parse_qsl
+import json
+import logging
+
+from app.api.v1.deps import get_event_publisher, get_telehealth_session_repository
+from app.core.config import get_settings
+from app.events.publisher import EventPublisher
+from app.notifications.twilio_events import SIGNATURE_HEADER, verify_signature
+from app.repositories.telehealth_sessions import TelehealthSessionRepository
+from app.services.telehealth import session_id_of_room
+from app.telehealth.webhooks import (
+    DAILY_SIGNATURE_HEADER,
+    DAILY_TIMESTAMP_HEADER,
+    daily_event,
+    livekit_event,
+    twilio_event,
+    verify_daily_signature,
+    verify_livekit_token,
+)
+from app.tenancy.context import acting_for
+
+router = APIRouter()
+
+# --- Configuration ---
+settings = get_settings()
+TWILIO_AUTH_TOKEN = settings.twilio_auth_token
+TWILIO_WEBHOOK_BASE_URL = settings.twilio_webhook_base_url
+DAILY_WEBHOOK_SECRET = settings.daily_webhook_secret
+LIVEKIT_API_KEY = settings.livekit_api_key
+LIVEKIT_API_SECRET = settings.livekit_api_secret
+
+
+def _json_object(payload: bytes) -> Dict[str, Any]:
+    try:
+        body = json.loads(payload)
+    except ValueError:
+        body = None
+    if not isinstance(body, dict):
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="Expected a JSON object")
+    return body
+
+
+def _record(room_event, repo: TelehealthSessionRepository, events: EventPublisher) -> None:
+    """
+    Records a provider's event on the session of its room, as the session's
+    organization, and announces the visit starting and ending.
+    """
+    if room_event is None:
+        return
+    room, event = room_event
+    session_id = session_id_of_room(room)
+    session = repo.get(session_id) if session_id else None
+    if not session:
+        # E.g. a room of another deployment sharing the provider account.
+        logging.warning(f"Ignored {event.type} event of unknown telehealth room {room}")
+        return
+    with acting_for(session.tenant_id):
+        updated = repo.record_event(session_id, event, ended_by="provider" if event.type == "ended" else None)
+        subject = f"telehealth/sessions/{session_id}"
+        if updated.started_at and not session.started_at:
+            events.emit("telehealth_session.started", subject, updated)
+        if updated.status == "ended" and session.status != "ended":
+            events.emit("telehealth_session.ended", subject, updated)
+
+
+async def _twilio_params(request: Request) -> Dict[str, str]:
+    """The form parameters of a Twilio status callback, once its signature is verified (see sms_events)."""
+    if not TWILIO_AUTH_TOKEN or not TWILIO_WEBHOOK_BASE_URL:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="TWILIO_AUTH_TOKEN and TWILIO_WEBHOOK_BASE_URL must be set")
+    params = dict(parse_qsl((await request.body()).decode("utf-8", "replace"), keep_blank_values=True))
+    url = TWILIO_WEBHOOK_BASE_URL.rstrip("/") + request.url.path
+    signature = request.headers.get(SIGNATURE_HEADER)
+    if not signature or not verify_signature(TWILIO_AUTH_TOKEN, url, params, signature):
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="Invalid webhook signature")
+    return params
+
+
+async def _daily_body(request: Request) -> Optional[Dict[str, Any]]:
+    """
+    The event of a Daily webhook, once its signature is verified. Daily
+    checks the URL with an unsigned request when the webhook is registered,
+    for which this is None.
+    """
+    payload = await request.body()
+    signature, timestamp = request.headers.get(DAILY_SIGNATURE_HEADER), request.headers.get(DAILY_TIMESTAMP_HEADER)
+    if not signature and not timestamp:
+        return None
+    if not DAILY_WEBHOOK_SECRET:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="DAILY_WEBHOOK_SECRET is not set")
+    if not signature or not timestamp or not verify_daily_signature(DAILY_WEBHOOK_SECRET, payload, signature, timestamp):
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="Invalid webhook signature")
+    return _json_object(payload)
+
+
+async def _livekit_body(request: Request) -> Dict[str, Any]:
+    """The event of a LiveKit webhook, once the token sent with it is verified."""
+    if not LIVEKIT_API_KEY or not LIVEKIT_API_SECRET:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="LIVEKIT_API_KEY and LIVEKIT_API_SECRET must be set")
+    payload = await request.body()
+    token = request.headers.get("Authorization")
+    if not token or not verify_livekit_token(LIVEKIT_API_KEY, LIVEKIT_API_SECRET, payload, token):
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="Invalid webhook signature")
+    return _json_object(payload)
+
+
+@router.post("/telehealth/twilio/status", status_code=status.HTTP_204_NO_CONTENT)
+def receive_twilio_room_status(
+    params: Dict[str, str] = Depends(_twilio_params),
+    repo: TelehealthSessionRepository = Depends(get_telehealth_session_repository),
+    events: EventPublisher = Depends(get_event_publisher)
+):
+    """
+    Receives Twilio Video's status callbacks for our rooms (set when each
+    room is created) and records participants connecting and
+    disconnecting, and the room ending, on its session.
+    """
+    _record(twilio_event(params), repo, events)
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+@router.post("/telehealth/daily/events")
+def receive_daily_event(
+    body: Optional[Dict[str, Any]] = Depends(_daily_body),
+    repo: TelehealthSessionRepository = Depends(get_telehealth_session_repository),
+    events: EventPublisher = Depends(get_event_publisher)
+):
+    """
+    Receives Daily's webhook, signed with DAILY_WEBHOOK_SECRET, and records
+    participants joining and leaving, and meetings ending, on their
+    session. Daily expects a 200 answer.
+    """
+    if body is not None:
+        _record(daily_event(body), repo, events)
+    return Response(status_code=status.HTTP_200_OK)
+
+
+@router.post("/telehealth/livekit/events", status_code=status.HTTP_204_NO_CONTENT)
+def receive_livekit_event(
+    body: Dict[str, Any] = Depends(_livekit_body),
+    repo: TelehealthSessionRepository = Depends(get_telehealth_session_repository),
+    events: EventPublisher = Depends(get_event_publisher)
+):
+    """
+    Receives the LiveKit server's webhook, authenticated with a token
+    signed with LIVEKIT_API_SECRET, and records participants joining and
+    leaving, and rooms finishing, on their session.
+    """
+    _record(livekit_event(body), repo, events)
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
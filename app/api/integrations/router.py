@@ -0,0 +1,18 @@
+# Location: app/api/integrations/router.py
+
+from fastapi import APIRouter
+
+from app.api.integrations.endpoints import email_events, hl7v2, payment_events, sms_events, telehealth_events
+
+# --- Integrations Router ---
+# Inbound interfaces for hospital systems that speak their own wire formats
+# rather than our JSON API, and for providers' callbacks, which
+# authenticate with their own signatures. Mounted in `app.main` under
+# `/integrations`.
+integrations_router = APIRouter()
+
+integrations_router.include_router(hl7v2.router)
+integrations_router.include_router(email_events.router)
+integrations_router.include_router(sms_events.router)
+integrations_router.include_router(telehealth_events.router)
+integrations_router.include_router(payment_events.router)
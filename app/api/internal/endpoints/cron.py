@@ -0,0 +1,41 @@
+from fastapi import APIRouter, Depends, HTTPException, status
+from typing import Dict
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_job_lock_repository, get_job_run_repository
+from app.core.config import get_settings
+from app.dependencies.auth import require_service_account
+from app.repositories.scheduler import JobLockRepository, JobRunRepository
+from app.scheduler import jobs  # Registers the scheduled jobs.
+from app.scheduler.registry import get_job
+from app.scheduler.runner import JobRunner
+
+router = APIRouter()
+
+# --- Configuration ---
+settings = get_settings()
+require_cloud_scheduler = require_service_account(settings.scheduler_service_account, settings.scheduler_audience)
+
+
+@router.post("/cron/{jobName}", response_model=schemas.JobRun, response_model_by_alias=False)
+def run_scheduled_job(
+    jobName: str,
+    locks: JobLockRepository = Depends(get_job_lock_repository),
+    runs: JobRunRepository = Depends(get_job_run_repository),
+    caller: Dict = Depends(require_cloud_scheduler)
+):
+    """
+    Runs a scheduled job on behalf of Cloud Scheduler, e.g. a job with
+    schedule `*/15 * * * *` that POSTs to /internal/cron/appointment-reminders
+    with an OIDC token of SCHEDULER_SERVICE_ACCOUNT. Returns the run record.
+    A run skipped because the job is still running elsewhere is a 200, so
+    Cloud Scheduler does not retry it; a failed run is a 500.
+    """
+    if get_job(jobName) is None:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail=f"Unknown scheduled job '{jobName}'")
+    logging.info(f"Running scheduled job {jobName} for {caller.get('email')}")
+    run = JobRunner(locks, runs).run(jobName, "cron")
+    if run.status == "failed":
+        raise HTTPException(status_code=status.HTTP_500_INTERNAL_SERVER_ERROR, detail=f"Scheduled job '{jobName}' failed: {run.error}")
+    return run
@@ -0,0 +1,57 @@
+from fastapi import APIRouter, Depends, HTTPException, Query, status
+from fastapi.responses import PlainTextResponse
+from starlette.concurrency import run_in_threadpool
+from typing import Any, Dict
+import logging
+
+from app.core.diagnostics import PROFILE_MAX_SECONDS, heap_top, runtime_vars, sample_profile, stack_dump
+from app.dependencies.auth import get_current_user
+
+router = APIRouter()
+
+
+@router.get("/stacks", response_class=PlainTextResponse)
+async def get_stacks(current_user: Dict = Depends(get_current_user)):
+    """
+    The stack of every thread and asyncio task of the worker that answers,
+    e.g. to see what a worker stuck on a hung downstream call is waiting on.
+    """
+    logging.info(f"User {current_user['uid']} dumped the stacks of a worker")
+    return PlainTextResponse(stack_dump())
+
+
+@router.get("/vars")
+async def get_vars(current_user: Dict = Depends(get_current_user)) -> Dict[str, Any]:
+    """Process and interpreter counters of the worker that answers: threads, tasks, memory, GC."""
+    return runtime_vars()
+
+
+@router.get("/profile", response_class=PlainTextResponse)
+async def get_profile(
+    seconds: float = Query(10, gt=0, le=PROFILE_MAX_SECONDS, description="How long to sample for."),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    A CPU profile of the worker that answers, sampled for `seconds` while it
+    goes on serving requests, as folded stacks for a flame graph, e.g.
+    `curl ... /internal/debug/profile?seconds=30 > profile.txt` and open it
+    in speedscope.
+    """
+    logging.info(f"User {current_user['uid']} started a {seconds}s CPU profile")
+    return PlainTextResponse(await run_in_threadpool(sample_profile, seconds))
+
+
+@router.get("/heap")
+async def get_heap(
+    limit: int = Query(50, ge=1, le=1000),
+    current_user: Dict = Depends(get_current_user)
+) -> Dict[str, Any]:
+    """
+    The source lines holding the most memory in the worker that answers.
+    Needs the server to run with PYTHONTRACEMALLOC, which slows it down;
+    without it the answer is 409.
+    """
+    try:
+        return await run_in_threadpool(heap_top, limit)
+    except RuntimeError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
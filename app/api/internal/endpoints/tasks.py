@@ -0,0 +1,39 @@
+from fastapi import APIRouter, Body, Depends, Header, HTTPException, Response, status
+from typing import Any, Dict, Optional
+import logging
+
+from app.core.config import get_settings
+from app.dependencies.auth import require_service_account
+from app.tasks import jobs  # Registers the job handlers.
+from app.tasks.registry import get_handler, run_task
+from app.tenancy.context import ORGANIZATION_HEADER, acting_for
+
+router = APIRouter()
+
+# --- Configuration ---
+settings = get_settings()
+# Cloud Tasks tokens carry the handler base URL as their audience (see app/tasks/queue.py).
+require_cloud_tasks = require_service_account(settings.tasks_service_account, settings.tasks_handler_url)
+
+
+@router.post("/tasks/{taskName}", status_code=status.HTTP_204_NO_CONTENT)
+def run_queued_task(
+    taskName: str,
+    payload: Dict[str, Any] = Body(...),
+    cloud_task_name: Optional[str] = Header(None, alias="X-CloudTasks-TaskName"),
+    retry_count: int = Header(0, alias="X-CloudTasks-TaskRetryCount"),
+    organization_id: Optional[str] = Header(None, alias=ORGANIZATION_HEADER),
+    caller: Dict = Depends(require_cloud_tasks)
+):
+    """
+    Runs a deferred job on behalf of Cloud Tasks. Only requests with an
+    OIDC token of TASKS_SERVICE_ACCOUNT are accepted. A 5xx response makes
+    Cloud Tasks retry; jobs that fail permanently are acknowledged with
+    204 so they are not retried. Jobs queued for an organization run scoped to it.
+    """
+    if get_handler(taskName) is None:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail=f"Unknown task '{taskName}'")
+    logging.info(f"Running task {taskName} ({cloud_task_name}, retry {retry_count})")
+    with acting_for(organization_id):
+        run_task(taskName, payload)
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
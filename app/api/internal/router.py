@@ -0,0 +1,27 @@
+# Location: app/api/internal/router.py
+
+from fastapi import APIRouter, Depends
+
+from app.api.internal.endpoints import cron, debug, tasks
+from app.core.config import get_settings
+from app.dependencies.auth import require_roles
+from app.rpc.gateway import gateway_router
+from app.tenancy.context import PLATFORM_ADMIN
+
+# --- Internal Router ---
+# Endpoints called by Google Cloud services on the service's behalf (Cloud
+# Tasks, Cloud Scheduler) rather than by users. Each handler checks the
+# caller's service account token. Routes for other MegaCare services go
+# under `/services`, where `ServiceAuthenticationMiddleware` checks the
+# caller instead. The runtime diagnostics under `/debug` are only served
+# with DIAGNOSTICS_ENABLED, to platform administrators. Mounted in
+# `app.main` under `/internal`.
+internal_router = APIRouter()
+
+internal_router.include_router(tasks.router)
+internal_router.include_router(cron.router)
+# The gRPC services transcoded to JSON, e.g. GET /internal/services/v1/patients/{patient_id}.
+internal_router.include_router(gateway_router(), prefix="/services")
+# Thread stacks, runtime counters and profiles of the answering worker (see app/core/diagnostics.py).
+if get_settings().diagnostics_enabled:
+    internal_router.include_router(debug.router, prefix="/debug", dependencies=[Depends(require_roles(PLATFORM_ADMIN))])
@@ -0,0 +1,14 @@
+# Location: app/api/metrics.py
+
+from fastapi import APIRouter, Response
+
+from app.core.metrics import render_latest
+
+router = APIRouter()
+
+
+@router.get("/metrics", include_in_schema=False)
+def metrics():
+    """Exposes Prometheus/OpenMetrics samples for Managed Prometheus to scrape."""
+    payload, content_type = render_latest()
+    return Response(content=payload, media_type=content_type)
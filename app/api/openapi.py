@@ -0,0 +1,162 @@
+# Location: app/api/openapi.py
+
+from typing import Any, Dict, List
+
+from fastapi import FastAPI
+from fastapi.openapi.utils import get_openapi
+from fastapi.routing import APIRoute
+
+from app.api.versioning import API_VERSIONS, path_version, route_deprecation, version_prefixes
+from app.errors.problems import PROBLEM_JSON, Problem, ValidationProblem
+from app.fhir.responses import FHIR_BASE_PATH
+
+DESCRIPTION = """
+Backend API for the MegaCare Connect application.
+
+Calls to `/api/v1` need a user's ID token as `Authorization: Bearer <token>`,
+or a partner `X-Api-Key` with a scope covering the route. Errors are sent as
+`application/problem+json` (RFC 9457) and, under `/fhir`, as OperationOutcome
+resources. List endpoints return pages; follow `next_cursor` to read on.
+Patients and observations are also served as FHIR with `Accept:
+application/fhir+json`, and lists stream as NDJSON with `Accept:
+application/x-ndjson`, or as CSV with `?format=csv` (choose the columns with
+`?fields=`).
+Updates name the `version` of the resource they are based on, as `If-Match:
+W/"<version>"` or in the body; stale ones are refused with `409`.
+Breaking changes go into a new major version beside `/api/v1`; deprecated
+operations are marked, and their responses carry `Deprecation` and `Sunset`
+headers.
+"""
+
+# Groups of operations in /docs, in this order. Every tag a router uses
+# should be listed here.
+TAGS_METADATA: List[Dict[str, str]] = [
+    {"name": "Authentication", "description": "Sign in with LINE; the only /api/v1 routes callable without a token."},
+    {"name": "Customers", "description": "The signed-in patient's own profile, equipment, prescription and daily therapy reports."},
+    {"name": "Sessions", "description": "Patient portal sign-ins, with an ID token or an emailed link: short-lived access tokens, rotating refresh tokens and the user's signed-in devices."},
+    {"name": "Multi-Factor Authentication", "description": "The caller's second factors (authenticator app, text message codes, recovery codes) and verifying one for their session."},
+    {"name": "Clinicians", "description": "Patients and daily therapy reports as seen by the signed-in clinician."},
+    {"name": "Patients", "description": "Patient registration and demographics, identified by MRN."},
+    {"name": "Observations", "description": "Vital signs and therapy measurements of a patient."},
+    {"name": "Lab Results", "description": "Laboratory results of a patient, coded with LOINC."},
+    {"name": "Medications", "description": "A patient's medication list and refill requests."},
+    {"name": "Allergies", "description": "Allergies and intolerances of a patient."},
+    {"name": "Immunizations", "description": "Vaccine doses given to a patient and the forecast of doses due."},
+    {"name": "Documents", "description": "Files attached to a patient's chart, uploaded and downloaded with signed Cloud Storage URLs."},
+    {"name": "Wound Images", "description": "Wound-care photos, stripped of their metadata and stored encrypted, and each wound's series of photos over time."},
+    {"name": "Notifications", "description": "Emails, text messages and push notifications sent to patients, such as appointment confirmations, and their delivery as reported by the provider."},
+    {"name": "Push Notifications", "description": "The patient app's device registrations for push notifications, and the categories of notification the patient receives."},
+    {"name": "Consents", "description": "Consent documents and the consents patients have given to them."},
+    {"name": "Coverage", "description": "A patient's health plans, and eligibility checks that ask the payer what the plan pays for a visit."},
+    {"name": "Practitioners", "description": "Clinicians and other providers, identified by NPI."},
+    {"name": "Care Teams", "description": "The practitioners caring for a patient and their roles."},
+    {"name": "Care Plans", "description": "Treatment plans with goals and scheduled activities."},
+    {"name": "Appointments", "description": "Booking, rescheduling and cancelling visits."},
+    {"name": "Encounters", "description": "Visits that took place, their participants and documents."},
+    {"name": "Claims", "description": "The services of a visit coded for billing, and the claims billing them to the patient's health plan through the clearinghouse, with what the payer paid."},
+    {"name": "Invoices", "description": "Statements billing patients what their health plan left them to pay, and the payments taken at the desk or online through Stripe."},
+    {"name": "Telehealth", "description": "Video visits for appointments at Twilio Video, Daily or LiveKit, the short-lived tokens to join them, and how long they lasted."},
+    {"name": "Devices", "description": "Telemetry sent by CPAP devices."},
+    {"name": "Audit", "description": "Who accessed which patient data; for compliance officers."},
+    {"name": "Webhooks", "description": "Subscriptions that receive domain events by HTTPS POST, and their deliveries."},
+    {"name": "API Keys", "description": "Keys for partner backends; for integration administrators."},
+    {"name": "Organizations", "description": "The clinics sharing the deployment, registered by platform administrators, and their data retention policies."},
+    {"name": "Lockouts", "description": "Accounts and addresses delayed or locked out after repeated failed sign-ins, and lifting those lockouts; for administrators."},
+    {"name": "Users", "description": "Inviting staff, assigning their roles, deactivating their accounts and resetting their credentials; for administrators."},
+    {"name": "Roles", "description": "Roles an organization defines from granular permissions, such as reading lab results but not documents; for administrators."},
+    {"name": "Feature Flags", "description": "Flags that switch features on per organization or for a share of callers; for platform administrators."},
+    {"name": "Reports", "description": "Operational aggregates per clinic (appointment volume, no-shows, telemetry adherence, open refill requests) as JSON or CSV."},
+    {"name": "Terminology", "description": "Looking up and validating LOINC, SNOMED CT and ICD-10 codes, and the value sets coded fields draw from."},
+    {"name": "Events", "description": "A server-sent event stream of changes to the resources the caller may read, for clients that update live."},
+    {"name": "GraphQL", "description": "One query for a patient's dashboard: demographics, appointments, medications and observations."},
+    {"name": "FHIR R4", "description": "A FHIR R4 view of patients and observations, including bulk `$export`."},
+    {"name": "Integrations", "description": "Inbound HL7 v2 messages from hospital systems, email delivery events from SendGrid, text message statuses and replies from Twilio, and video visit participant events."},
+    {"name": "Health Check", "description": "Liveness and readiness probes."},
+]
+
+
+def operation_id(route: APIRoute) -> str:
+    """
+    The handler's name, e.g. `list_patients`, which client generators turn
+    into method names. FHIR handlers get a `fhir_` prefix because some share
+    their name with the /api/v1 handler for the same resource.
+    """
+    return f"fhir_{route.name}" if route.path.startswith(FHIR_BASE_PATH) else route.name
+
+
+def _schema_ref(name: str) -> Dict[str, str]:
+    return {"$ref": f"#/components/schemas/{name}"}
+
+
+def _document_errors(path: str, operation: Dict[str, Any]) -> None:
+    """Describes error responses as they are sent, instead of FastAPI's default 422 body."""
+    responses = operation.setdefault("responses", {})
+    if path == FHIR_BASE_PATH or path.startswith(FHIR_BASE_PATH + "/"):
+        outcome = {"application/fhir+json": {"schema": {"type": "object", "description": "An OperationOutcome resource."}}}
+        if "422" in responses:
+            responses["422"] = {"description": "Invalid request", "content": outcome}
+        responses.setdefault("default", {"description": "Error", "content": outcome})
+        return
+    if "422" in responses:
+        responses["422"] = {"description": "Invalid request fields", "content": {PROBLEM_JSON: {"schema": _schema_ref("ValidationProblem")}}}
+    responses.setdefault("default", {"description": "Error", "content": {PROBLEM_JSON: {"schema": _schema_ref("Problem")}}})
+
+
+IDEMPOTENCY_KEY_PARAMETER = {
+    "name": "Idempotency-Key",
+    "in": "header",
+    "required": False,
+    "description": "Makes the request safe to retry: retries with the same key get the first response back (see app/middleware/idempotency.py).",
+    "schema": {"type": "string", "maxLength": 255},
+}
+
+
+def _document_idempotency(method: str, path: str, operation: Dict[str, Any]) -> None:
+    """Lists the optional Idempotency-Key header on the POSTs that honour it."""
+    if method == "post" and any(path.startswith(prefix + "/") for prefix in version_prefixes()):
+        operation.setdefault("parameters", []).append(IDEMPOTENCY_KEY_PARAMETER)
+
+
+def _deprecated(method: str, path: str) -> bool:
+    """Whether the operation's version or route is deprecated (see app/api/versioning.py)."""
+    version = API_VERSIONS.get(path_version(path) or "")
+    if version and (version.deprecated_on or version.sunset_on):
+        return True
+    return route_deprecation(method.upper(), path) is not None
+
+
+def install_openapi(app: FastAPI) -> None:
+    """
+    Replaces `app.openapi`, which serves /openapi.json and feeds /docs, with
+    one that documents the problem details and OperationOutcome errors of
+    each operation, and the Idempotency-Key header of /api POSTs. The document is built once, on first request.
+    """
+    def openapi() -> Dict[str, Any]:
+        if app.openapi_schema:
+            return app.openapi_schema
+        schema = get_openapi(
+            title=app.title,
+            version=app.version,
+            description=app.description,
+            routes=app.routes,
+            tags=app.openapi_tags,
+            servers=app.servers,
+        )
+        component_schemas = schema.setdefault("components", {}).setdefault("schemas", {})
+        for model in (Problem, ValidationProblem):
+            model_schema = model.model_json_schema(ref_template="#/components/schemas/{model}")
+            component_schemas.update(model_schema.pop("$defs", {}))
+            component_schemas[model.__name__] = model_schema
+        for path, operations in schema.get("paths", {}).items():
+            for method, operation in operations.items():
+                _document_errors(path, operation)
+                _document_idempotency(method, path, operation)
+                if _deprecated(method, path):
+                    operation["deprecated"] = True
+        # FastAPI's own validation error body is never sent (see app/errors/handlers.py).
+        for name in ("HTTPValidationError", "ValidationError"):
+            component_schemas.pop(name, None)
+        app.openapi_schema = schema
+        return schema
+
+    app.openapi = openapi
@@ -0,0 +1,228 @@
+# Location: app/api/representations.py
+
+import csv
+import io
+import json
+import types
+import typing
+from dataclasses import dataclass
+from typing import Any, Callable, Dict, List, Optional, Sequence, Tuple, Type
+
+from pydantic import BaseModel
+
+from app.api.v1 import schemas
+from app.fhir.bulk_export import NDJSON as FHIR_NDJSON
+from app.fhir.mappers import observation_to_fhir, patient_to_fhir
+from app.fhir.responses import FHIR_JSON
+from app.pagination.pages import Page
+
+# --- Representations ---
+# The /api routes return plain JSON models. The same responses can also be
+# rendered, by the representation middleware, as FHIR resources for the
+# models with a FHIR mapping below, and lists as NDJSON: one item per line,
+# with paged lists streamed across every page. Handlers only ever return
+# their model; the client picks the media type with Accept. Lists can also
+# be downloaded as CSV, with `?format=csv` or Accept, for spreadsheets.
+
+JSON = "application/json"
+NDJSON = "application/x-ndjson"
+# Lists are rendered as CSV by the middleware; the report endpoints render it themselves.
+CSV = "text/csv"
+# Query parameters of CSV downloads: `format=csv` in place of Accept, and
+# `fields`, the comma-separated columns to include, in order.
+FORMAT_PARAM = "format"
+FIELDS_PARAM = "fields"
+# Also accepted in Accept for NDJSON; responses use NDJSON.
+NDJSON_ALIASES = ("application/ndjson",)
+
+FHIR_MAPPERS: Dict[Type[BaseModel], Callable[[Any], Dict[str, Any]]] = {
+    schemas.Patient: patient_to_fhir,
+    schemas.Observation: observation_to_fhir,
+}
+
+
+@dataclass(frozen=True)
+class Representable:
+    """What a route responds with: a model, a list of them or a page of them."""
+    model: Type[BaseModel]
+    collection: Optional[str] = None  # "list", "page" or None for a single item.
+
+    @property
+    def fhir(self) -> bool:
+        return self.model in FHIR_MAPPERS
+
+    def media_types(self) -> List[str]:
+        """The media types the response can be rendered as, JSON first."""
+        offered = [JSON]
+        if self.fhir:
+            offered.append(FHIR_JSON)
+        if self.collection:
+            offered.append(NDJSON)
+            if self.fhir:
+                offered.append(FHIR_NDJSON)
+            offered.append(CSV)
+        return offered
+
+
+def representable(response_model: Any) -> Optional[Representable]:
+    """Reads a route's response model, e.g. Page[schemas.Patient] -> (Patient, "page")."""
+    if response_model is None:
+        return None
+    metadata = getattr(response_model, "__pydantic_generic_metadata__", None) or {}
+    if metadata.get("origin") is Page and metadata.get("args"):
+        return Representable(metadata["args"][0], "page")
+    if getattr(response_model, "__origin__", None) in (list, List):
+        args = getattr(response_model, "__args__", ())
+        if args and isinstance(args[0], type) and issubclass(args[0], BaseModel):
+            return Representable(args[0], "list")
+        return None
+    if isinstance(response_model, type) and issubclass(response_model, BaseModel):
+        return Representable(response_model)
+    return None
+
+
+def parse_accept(header: Optional[str]) -> List[Tuple[str, float]]:
+    """The media ranges of an Accept header with their quality, e.g. [("application/*", 0.5)]."""
+    ranges = []
+    for part in (header or "").split(","):
+        media_range, *params = part.split(";")
+        media_range = media_range.strip().lower()
+        if not media_range:
+            continue
+        quality = 1.0
+        for param in params:
+            name, _, value = param.strip().partition("=")
+            if name.strip().lower() == "q":
+                try:
+                    quality = float(value)
+                except ValueError:
+                    quality = 0.0
+        ranges.append((media_range, quality))
+    return ranges
+
+
+def _specificity(media_range: str, media_type: str) -> int:
+    """How closely a range matches a type: 3 exactly, 2 for type/*, 1 for */*, 0 not at all."""
+    if media_range == media_type:
+        return 3
+    kind, _, subtype = media_range.partition("/")
+    if subtype == "*" and media_type.startswith(kind + "/"):
+        return 2
+    return 1 if media_range == "*/*" else 0
+
+
+def negotiate(accept: Optional[str], offered: Sequence[str]) -> Optional[str]:
+    """
+    Picks the media type of `offered` that the Accept header rates highest,
+    judged by the most specific range matching it (RFC 9110, section
+    12.5.1); ties go to the earlier type. No Accept header accepts the
+    first one. None if the client accepts none of them.
+    """
+    ranges = parse_accept(accept)
+    if not ranges:
+        return offered[0] if offered else None
+    aliases = {alias: NDJSON for alias in NDJSON_ALIASES}
+    ranges = [(aliases.get(media_range, media_range), quality) for media_range, quality in ranges]
+    best, best_quality = None, 0.0
+    for media_type in offered:
+        matches = [(_specificity(media_range, media_type), quality) for media_range, quality in ranges]
+        specificity, quality = max(matches, default=(0, 0.0))
+        if specificity and quality > best_quality:
+            best, best_quality = media_type, quality
+    return best
+
+
+def to_fhir(model: Type[BaseModel], item: Dict[str, Any]) -> Dict[str, Any]:
+    """The FHIR resource of one item of a JSON response."""
+    return FHIR_MAPPERS[model](model.model_validate(item))
+
+
+# --- CSV ---
+# A CSV column per JSON field of the list's model, named as in the JSON.
+# Nested models are flattened into `parent.child` columns; lists and maps
+# are written as JSON in a single column.
+
+# Spreadsheets run cells starting with these as formulas; such text is
+# prefixed with an apostrophe so it is shown as typed (OWASP CSV injection).
+_FORMULA_PREFIXES = ("=", "+", "-", "@", "\t", "\r")
+
+
+def escape_formula(text: str) -> str:
+    """Returns `text` with an apostrophe in front if a spreadsheet would run it as a formula."""
+    return "'" + text if text.startswith(_FORMULA_PREFIXES) else text
+
+
+def _nested_model(annotation: Any) -> Optional[Type[BaseModel]]:
+    """The model a field holds directly, unwrapping Optional; None for lists, maps and scalars."""
+    if typing.get_origin(annotation) in (typing.Union, types.UnionType):
+        models = [a for a in typing.get_args(annotation) if a is not type(None)]
+        return _nested_model(models[0]) if len(models) == 1 else None
+    if isinstance(annotation, type) and issubclass(annotation, BaseModel):
+        return annotation
+    return None
+
+
+def csv_columns(model: Type[BaseModel], prefix: str = "") -> List[str]:
+    """Every CSV column of the model, in field order, e.g. ["patient_id", ..., "contact.email", ...]."""
+    columns = []
+    for name, field in model.model_fields.items():
+        nested = _nested_model(field.annotation)
+        columns.extend(csv_columns(nested, f"{prefix}{name}.") if nested else [prefix + name])
+    return columns
+
+
+def select_columns(model: Type[BaseModel], fields: Optional[str]) -> List[str]:
+    """
+    The columns named in a `fields` parameter, in its order, or all of them
+    when it is empty. A nested model's name selects all of its columns.
+    Raises ValueError naming the unknown ones.
+    """
+    available = csv_columns(model)
+    if not fields or not fields.strip():
+        return available
+    selected, unknown = [], []
+    for name in (f.strip() for f in fields.split(",")):
+        if not name:
+            continue
+        matching = [c for c in available if c == name or c.startswith(name + ".")]
+        if not matching:
+            unknown.append(name)
+        selected.extend(c for c in matching if c not in selected)
+    if unknown:
+        raise ValueError(f"Unknown {FIELDS_PARAM}: {', '.join(unknown)}")
+    return selected
+
+
+def _lookup(model: Type[BaseModel], item: Dict[str, Any], column: str) -> Any:
+    value: Any = item
+    for name in column.split("."):
+        if not isinstance(value, dict):
+            return None
+        field = model.model_fields.get(name)
+        # Routes respond with field names; aliases are accepted for any that respond by alias.
+        value = value[name] if name in value else value.get(field.alias) if field and field.alias else None
+        model = (_nested_model(field.annotation) if field else None) or model
+    return value
+
+
+def _cell(value: Any) -> str:
+    if value is None:
+        return ""
+    if isinstance(value, bool):
+        return "true" if value else "false"
+    if isinstance(value, (dict, list)):
+        value = json.dumps(value, ensure_ascii=False, separators=(",", ":"))
+    elif not isinstance(value, str):
+        return str(value)
+    return escape_formula(value)
+
+
+def csv_lines(model: Type[BaseModel], columns: Sequence[str], items: Sequence[Dict[str, Any]], header: bool = False) -> bytes:
+    """CSV rows of JSON items, optionally preceded by the header row."""
+    out = io.StringIO()
+    writer = csv.writer(out, lineterminator="\r\n")
+    if header:
+        writer.writerow(columns)
+    for item in items:
+        writer.writerow([_cell(_lookup(model, item, column)) for column in columns])
+    return out.getvalue().encode()
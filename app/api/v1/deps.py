@@ -0,0 +1,484 @@
+# Location: app/api/v1/deps.py
+
+from typing import Any, Dict
+
+from fastapi import BackgroundTasks
+from firebase_admin import firestore
+
+from app.analytics.export import get_analytics_recorder
+from app.analytics.publisher import AnalyticsEventPublisher
+from app.cache.patients import CachedPatientRepository
+from app.core.cache import CACHE_TTL_SECONDS, REDIS_URL, get_cache
+from app.core.config import get_settings
+from app.core.postgres import get_pool
+from app.events.publisher import EventPublisher, NullEventPublisher, OutboxEventPublisher, PubSubEventPublisher
+from app.events.stream import StreamEventPublisher
+from app.repositories.allergies import AllergyRepository, FirestoreAllergyRepository
+from app.repositories.api_keys import ApiKeyRepository, FirestoreApiKeyRepository
+from app.repositories.appointment_reminders import AppointmentReminderRepository, FirestoreAppointmentReminderRepository
+from app.repositories.appointments import AppointmentRepository, FirestoreAppointmentRepository
+from app.repositories.audit_events import AuditEventRepository, FirestoreAuditEventRepository
+from app.repositories.audit_exports import AuditExportRepository, FirestoreAuditExportRepository
+from app.repositories.care_plans import CarePlanRepository, FirestoreCarePlanRepository
+from app.repositories.care_teams import CareTeamRepository, FirestoreCareTeamRepository
+from app.repositories.claims import ChargeItemRepository, ClaimRepository, FirestoreChargeItemRepository, FirestoreClaimRepository
+from app.repositories.consents import (
+    ConsentDocumentRepository,
+    ConsentRepository,
+    FirestoreConsentDocumentRepository,
+    FirestoreConsentRepository,
+)
+from app.repositories.coverage import (
+    CoverageRepository,
+    EligibilityCheckRepository,
+    FirestoreCoverageRepository,
+    FirestoreEligibilityCheckRepository,
+)
+from app.repositories.custom_roles import CustomRoleRepository, FirestoreCustomRoleRepository
+from app.repositories.devices import DeviceRepository, FirestoreDeviceRepository
+from app.repositories.encounters import EncounterRepository, FirestoreEncounterRepository
+from app.repositories.export_jobs import ExportJobRepository, FirestoreExportJobRepository
+from app.repositories.feature_flags import FeatureFlagRepository, FirestoreFeatureFlagRepository
+from app.repositories.idempotency import FirestoreIdempotencyRepository, IdempotencyRepository
+from app.repositories.immunizations import FirestoreImmunizationRepository, ImmunizationRepository
+from app.repositories.import_jobs import FirestoreImportJobRepository, ImportJobRepository
+from app.repositories.invoices import FirestoreInvoiceRepository, InvoiceRepository
+from app.repositories.lab_results import FirestoreLabResultRepository, LabResultRepository
+from app.repositories.lockouts import FirestoreLockoutRepository, LockoutRepository
+from app.repositories.magic_links import FirestoreMagicLinkRepository, MagicLinkRepository
+from app.repositories.medications import (
+    FirestoreMedicationRepository,
+    FirestoreRefillRequestRepository,
+    MedicationRepository,
+    RefillRequestRepository,
+)
+from app.repositories.memory import (
+    MemoryAllergyRepository,
+    MemoryApiKeyRepository,
+    MemoryAppointmentReminderRepository,
+    MemoryAppointmentRepository,
+    MemoryAuditEventRepository,
+    MemoryAuditExportRepository,
+    MemoryCarePlanRepository,
+    MemoryCareTeamRepository,
+    MemoryChargeItemRepository,
+    MemoryClaimRepository,
+    MemoryConsentDocumentRepository,
+    MemoryConsentRepository,
+    MemoryCoverageRepository,
+    MemoryCustomRoleRepository,
+    MemoryDeviceRepository,
+    MemoryEligibilityCheckRepository,
+    MemoryEncounterRepository,
+    MemoryExportJobRepository,
+    MemoryFeatureFlagRepository,
+    MemoryIdempotencyRepository,
+    MemoryImmunizationRepository,
+    MemoryImportJobRepository,
+    MemoryInvoiceRepository,
+    MemoryJobLockRepository,
+    MemoryJobRunRepository,
+    MemoryLabResultRepository,
+    MemoryLockoutRepository,
+    MemoryMagicLinkRepository,
+    MemoryMedicationRepository,
+    MemoryMfaEnrollmentRepository,
+    MemoryNotificationRepository,
+    MemoryObservationRepository,
+    MemoryOrganizationRepository,
+    MemoryOutboxRepository,
+    MemoryPatientDocumentRepository,
+    MemoryPatientRepository,
+    MemoryPractitionerRepository,
+    MemoryPurgeManifestRepository,
+    MemoryPushTokenRepository,
+    MemoryRefillRequestRepository,
+    MemoryRetentionPolicyRepository,
+    MemorySessionRepository,
+    MemoryStreamEventRepository,
+    MemoryTelehealthSessionRepository,
+    MemoryWebhookDeliveryRepository,
+    MemoryWebhookSubscriptionRepository,
+    MemoryWoundImageRepository,
+    get_memory_store,
+)
+from app.repositories.mfa import FirestoreMfaEnrollmentRepository, MfaEnrollmentRepository
+from app.repositories.notifications import FirestoreNotificationRepository, NotificationRepository
+from app.repositories.observations import FirestoreObservationRepository, ObservationRepository
+from app.repositories.organizations import FirestoreOrganizationRepository, OrganizationRepository
+from app.repositories.outbox import FirestoreOutboxRepository, OutboxRepository
+from app.repositories.patient_documents import FirestorePatientDocumentRepository, PatientDocumentRepository
+from app.repositories.patients import FirestorePatientRepository, PatientRepository
+from app.repositories.postgres.allergies import PostgresAllergyRepository
+from app.repositories.postgres.api_keys import PostgresApiKeyRepository
+from app.repositories.postgres.appointment_reminders import PostgresAppointmentReminderRepository
+from app.repositories.postgres.appointments import PostgresAppointmentRepository
+from app.repositories.postgres.audit_events import PostgresAuditEventRepository
+from app.repositories.postgres.audit_exports import PostgresAuditExportRepository
+from app.repositories.postgres.care_plans import PostgresCarePlanRepository
+from app.repositories.postgres.care_teams import PostgresCareTeamRepository
+from app.repositories.postgres.claims import PostgresChargeItemRepository, PostgresClaimRepository
+from app.repositories.postgres.consents import PostgresConsentDocumentRepository, PostgresConsentRepository
+from app.repositories.postgres.coverage import PostgresCoverageRepository, PostgresEligibilityCheckRepository
+from app.repositories.postgres.custom_roles import PostgresCustomRoleRepository
+from app.repositories.postgres.encounters import PostgresEncounterRepository
+from app.repositories.postgres.export_jobs import PostgresExportJobRepository
+from app.repositories.postgres.feature_flags import PostgresFeatureFlagRepository
+from app.repositories.postgres.idempotency import PostgresIdempotencyRepository
+from app.repositories.postgres.immunizations import PostgresImmunizationRepository
+from app.repositories.postgres.import_jobs import PostgresImportJobRepository
+from app.repositories.postgres.invoices import PostgresInvoiceRepository
+from app.repositories.postgres.lab_results import PostgresLabResultRepository
+from app.repositories.postgres.lockouts import PostgresLockoutRepository
+from app.repositories.postgres.magic_links import PostgresMagicLinkRepository
+from app.repositories.postgres.medications import PostgresMedicationRepository, PostgresRefillRequestRepository
+from app.repositories.postgres.mfa import PostgresMfaEnrollmentRepository
+from app.repositories.postgres.notifications import PostgresNotificationRepository
+from app.repositories.postgres.observations import PostgresObservationRepository
+from app.repositories.postgres.organizations import PostgresOrganizationRepository
+from app.repositories.postgres.outbox import PostgresOutboxRepository
+from app.repositories.postgres.patient_documents import PostgresPatientDocumentRepository
+from app.repositories.postgres.patients import PostgresPatientRepository
+from app.repositories.postgres.practitioners import PostgresPractitionerRepository
+from app.repositories.postgres.push_tokens import PostgresPushTokenRepository
+from app.repositories.postgres.retention import PostgresPurgeManifestRepository, PostgresRetentionPolicyRepository
+from app.repositories.postgres.scheduler import PostgresJobLockRepository, PostgresJobRunRepository
+from app.repositories.postgres.sessions import PostgresSessionRepository
+from app.repositories.postgres.stream_events import PostgresStreamEventRepository
+from app.repositories.postgres.telehealth_sessions import PostgresTelehealthSessionRepository
+from app.repositories.postgres.webhooks import PostgresWebhookDeliveryRepository, PostgresWebhookSubscriptionRepository
+from app.repositories.postgres.wound_images import PostgresWoundImageRepository
+from app.repositories.practitioners import FirestorePractitionerRepository, PractitionerRepository
+from app.repositories.push_tokens import FirestorePushTokenRepository, PushTokenRepository
+from app.repositories.retention import (
+    FirestorePurgeManifestRepository,
+    FirestoreRetentionPolicyRepository,
+    PurgeManifestRepository,
+    RetentionPolicyRepository,
+)
+from app.repositories.scheduler import FirestoreJobLockRepository, FirestoreJobRunRepository, JobLockRepository, JobRunRepository
+from app.repositories.sessions import FirestoreSessionRepository, SessionRepository
+from app.repositories.stream_events import FirestoreStreamEventRepository, StreamEventRepository
+from app.repositories.telehealth_sessions import FirestoreTelehealthSessionRepository, TelehealthSessionRepository
+from app.repositories.webhooks import (
+    FirestoreWebhookDeliveryRepository,
+    FirestoreWebhookSubscriptionRepository,
+    WebhookDeliveryRepository,
+    WebhookSubscriptionRepository,
+)
+from app.repositories.wound_images import FirestoreWoundImageRepository, WoundImageRepository
+from app.resilience.dependencies import FIRESTORE, GuardedRepository
+from app.search.index import get_search_index
+from app.search.publisher import SearchIndexEventPublisher
+from app.tasks.queue import BackgroundTaskQueue, CloudTasksQueue, InlineTaskQueue, TaskQueue, queue_path
+from app.webhooks.publisher import WebhookEventPublisher
+
+# --- Repository Dependencies ---
+# Handlers receive repositories through `Depends(...)` so tests can swap in a
+# fake with `app.dependency_overrides` instead of patching Firestore.
+# STORE selects the backend for the clinical records. With `postgres`,
+# devices are still read from the Firestore customers collection; `memory`
+# keeps everything in process for local development and tests.
+STORE = get_settings().store
+DOMAIN_EVENTS_TOPIC = get_settings().domain_events_topic
+DOMAIN_EVENTS_DELIVERY = get_settings().domain_events_delivery
+WEBHOOKS_ENABLED = get_settings().webhooks_enabled
+EVENT_STREAM_ENABLED = get_settings().event_stream_enabled
+ANALYTICS_PSEUDONYM_KEY = get_settings().analytics_pseudonym_key
+TASKS_QUEUE = get_settings().tasks_queue
+
+
+def _repository(firestore_cls, postgres_cls, memory_cls):
+    if STORE == "postgres":
+        return postgres_cls(get_pool())
+    if STORE == "memory":
+        return memory_cls(get_memory_store())
+    return GuardedRepository(firestore_cls(firestore.client()), FIRESTORE)
+
+
+def get_patient_repository() -> PatientRepository:
+    repo = _repository(FirestorePatientRepository, PostgresPatientRepository, MemoryPatientRepository)
+    if REDIS_URL:
+        return CachedPatientRepository(repo, get_cache(), CACHE_TTL_SECONDS)
+    return repo
+
+
+def get_practitioner_repository() -> PractitionerRepository:
+    return _repository(FirestorePractitionerRepository, PostgresPractitionerRepository, MemoryPractitionerRepository)
+
+
+def get_care_team_repository() -> CareTeamRepository:
+    return _repository(FirestoreCareTeamRepository, PostgresCareTeamRepository, MemoryCareTeamRepository)
+
+
+def get_appointment_repository() -> AppointmentRepository:
+    return _repository(FirestoreAppointmentRepository, PostgresAppointmentRepository, MemoryAppointmentRepository)
+
+
+def get_appointment_reminder_repository() -> AppointmentReminderRepository:
+    return _repository(FirestoreAppointmentReminderRepository, PostgresAppointmentReminderRepository, MemoryAppointmentReminderRepository)
+
+
+def get_observation_repository() -> ObservationRepository:
+    return _repository(FirestoreObservationRepository, PostgresObservationRepository, MemoryObservationRepository)
+
+
+def get_export_job_repository() -> ExportJobRepository:
+    return _repository(FirestoreExportJobRepository, PostgresExportJobRepository, MemoryExportJobRepository)
+
+
+def get_import_job_repository() -> ImportJobRepository:
+    return _repository(FirestoreImportJobRepository, PostgresImportJobRepository, MemoryImportJobRepository)
+
+
+def get_device_repository() -> DeviceRepository:
+    if STORE == "memory":
+        return MemoryDeviceRepository(get_memory_store())
+    return GuardedRepository(FirestoreDeviceRepository(firestore.client()), FIRESTORE)
+
+
+def get_care_plan_repository() -> CarePlanRepository:
+    return _repository(FirestoreCarePlanRepository, PostgresCarePlanRepository, MemoryCarePlanRepository)
+
+
+def get_medication_repository() -> MedicationRepository:
+    return _repository(FirestoreMedicationRepository, PostgresMedicationRepository, MemoryMedicationRepository)
+
+
+def get_refill_request_repository() -> RefillRequestRepository:
+    return _repository(FirestoreRefillRequestRepository, PostgresRefillRequestRepository, MemoryRefillRequestRepository)
+
+
+def get_allergy_repository() -> AllergyRepository:
+    return _repository(FirestoreAllergyRepository, PostgresAllergyRepository, MemoryAllergyRepository)
+
+
+def get_immunization_repository() -> ImmunizationRepository:
+    return _repository(FirestoreImmunizationRepository, PostgresImmunizationRepository, MemoryImmunizationRepository)
+
+
+def get_patient_document_repository() -> PatientDocumentRepository:
+    return _repository(FirestorePatientDocumentRepository, PostgresPatientDocumentRepository, MemoryPatientDocumentRepository)
+
+
+def get_wound_image_repository() -> WoundImageRepository:
+    return _repository(FirestoreWoundImageRepository, PostgresWoundImageRepository, MemoryWoundImageRepository)
+
+
+def get_notification_repository() -> NotificationRepository:
+    return _repository(FirestoreNotificationRepository, PostgresNotificationRepository, MemoryNotificationRepository)
+
+
+def get_push_token_repository() -> PushTokenRepository:
+    return _repository(FirestorePushTokenRepository, PostgresPushTokenRepository, MemoryPushTokenRepository)
+
+
+def get_telehealth_session_repository() -> TelehealthSessionRepository:
+    return _repository(FirestoreTelehealthSessionRepository, PostgresTelehealthSessionRepository, MemoryTelehealthSessionRepository)
+
+
+def get_encounter_repository() -> EncounterRepository:
+    return _repository(FirestoreEncounterRepository, PostgresEncounterRepository, MemoryEncounterRepository)
+
+
+def get_lab_result_repository() -> LabResultRepository:
+    return _repository(FirestoreLabResultRepository, PostgresLabResultRepository, MemoryLabResultRepository)
+
+
+def get_consent_repository() -> ConsentRepository:
+    return _repository(FirestoreConsentRepository, PostgresConsentRepository, MemoryConsentRepository)
+
+
+def get_consent_document_repository() -> ConsentDocumentRepository:
+    return _repository(FirestoreConsentDocumentRepository, PostgresConsentDocumentRepository, MemoryConsentDocumentRepository)
+
+
+def get_coverage_repository() -> CoverageRepository:
+    return _repository(FirestoreCoverageRepository, PostgresCoverageRepository, MemoryCoverageRepository)
+
+
+def get_eligibility_check_repository() -> EligibilityCheckRepository:
+    return _repository(FirestoreEligibilityCheckRepository, PostgresEligibilityCheckRepository, MemoryEligibilityCheckRepository)
+
+
+def get_charge_item_repository() -> ChargeItemRepository:
+    return _repository(FirestoreChargeItemRepository, PostgresChargeItemRepository, MemoryChargeItemRepository)
+
+
+def get_claim_repository() -> ClaimRepository:
+    return _repository(FirestoreClaimRepository, PostgresClaimRepository, MemoryClaimRepository)
+
+
+def get_invoice_repository() -> InvoiceRepository:
+    return _repository(FirestoreInvoiceRepository, PostgresInvoiceRepository, MemoryInvoiceRepository)
+
+
+def get_audit_event_repository() -> AuditEventRepository:
+    return _repository(FirestoreAuditEventRepository, PostgresAuditEventRepository, MemoryAuditEventRepository)
+
+
+def get_audit_export_repository() -> AuditExportRepository:
+    return _repository(FirestoreAuditExportRepository, PostgresAuditExportRepository, MemoryAuditExportRepository)
+
+
+def get_patient_record_repositories() -> Dict[str, Any]:
+    """The repositories of the records a patient merge moves, by resource (see app/services/patient_matching.py)."""
+    return {
+        "appointments": get_appointment_repository(),
+        "appointmentReminders": get_appointment_reminder_repository(),
+        "encounters": get_encounter_repository(),
+        "observations": get_observation_repository(),
+        "labResults": get_lab_result_repository(),
+        "medications": get_medication_repository(),
+        "refillRequests": get_refill_request_repository(),
+        "allergies": get_allergy_repository(),
+        "immunizations": get_immunization_repository(),
+        "carePlans": get_care_plan_repository(),
+        "careTeams": get_care_team_repository(),
+        "consents": get_consent_repository(),
+        "patientDocuments": get_patient_document_repository(),
+        "woundImages": get_wound_image_repository(),
+        "notifications": get_notification_repository(),
+        "pushTokens": get_push_token_repository(),
+        "telehealthSessions": get_telehealth_session_repository(),
+        "coverage": get_coverage_repository(),
+        "eligibilityChecks": get_eligibility_check_repository(),
+        "chargeItems": get_charge_item_repository(),
+        "claims": get_claim_repository(),
+        "invoices": get_invoice_repository(),
+    }
+
+
+# --- Event Dependencies ---
+
+def get_outbox_repository() -> OutboxRepository:
+    return _repository(FirestoreOutboxRepository, PostgresOutboxRepository, MemoryOutboxRepository)
+
+
+def _domain_event_publisher() -> EventPublisher:
+    if not DOMAIN_EVENTS_TOPIC:
+        return NullEventPublisher()
+    if DOMAIN_EVENTS_DELIVERY == "outbox":
+        return OutboxEventPublisher(get_outbox_repository())
+    return PubSubEventPublisher(DOMAIN_EVENTS_TOPIC)
+
+
+def get_stream_event_repository() -> StreamEventRepository:
+    return _repository(FirestoreStreamEventRepository, PostgresStreamEventRepository, MemoryStreamEventRepository)
+
+
+def get_event_publisher() -> EventPublisher:
+    publisher = _domain_event_publisher()
+    if WEBHOOKS_ENABLED:
+        publisher = WebhookEventPublisher(publisher, get_webhook_subscription_repository(), get_webhook_delivery_repository())
+    if EVENT_STREAM_ENABLED:
+        publisher = StreamEventPublisher(publisher, get_stream_event_repository())
+    index = get_search_index()
+    if index:
+        publisher = SearchIndexEventPublisher(publisher, index)
+    recorder = get_analytics_recorder()
+    if recorder:
+        publisher = AnalyticsEventPublisher(publisher, recorder, ANALYTICS_PSEUDONYM_KEY)
+    return publisher
+
+
+# --- Webhook Dependencies ---
+
+def get_webhook_subscription_repository() -> WebhookSubscriptionRepository:
+    return _repository(FirestoreWebhookSubscriptionRepository, PostgresWebhookSubscriptionRepository, MemoryWebhookSubscriptionRepository)
+
+
+def get_webhook_delivery_repository() -> WebhookDeliveryRepository:
+    return _repository(FirestoreWebhookDeliveryRepository, PostgresWebhookDeliveryRepository, MemoryWebhookDeliveryRepository)
+
+
+# --- API Key Dependencies ---
+
+def get_api_key_repository() -> ApiKeyRepository:
+    return _repository(FirestoreApiKeyRepository, PostgresApiKeyRepository, MemoryApiKeyRepository)
+
+
+# --- Session Dependencies ---
+
+def get_session_repository() -> SessionRepository:
+    return _repository(FirestoreSessionRepository, PostgresSessionRepository, MemorySessionRepository)
+
+
+def get_magic_link_repository() -> MagicLinkRepository:
+    return _repository(FirestoreMagicLinkRepository, PostgresMagicLinkRepository, MemoryMagicLinkRepository)
+
+
+def get_mfa_enrollment_repository() -> MfaEnrollmentRepository:
+    return _repository(FirestoreMfaEnrollmentRepository, PostgresMfaEnrollmentRepository, MemoryMfaEnrollmentRepository)
+
+
+def get_lockout_repository() -> LockoutRepository:
+    return _repository(FirestoreLockoutRepository, PostgresLockoutRepository, MemoryLockoutRepository)
+
+
+# --- Scheduler Dependencies ---
+
+def get_job_lock_repository() -> JobLockRepository:
+    return _repository(FirestoreJobLockRepository, PostgresJobLockRepository, MemoryJobLockRepository)
+
+
+def get_job_run_repository() -> JobRunRepository:
+    return _repository(FirestoreJobRunRepository, PostgresJobRunRepository, MemoryJobRunRepository)
+
+
+# --- Idempotency Dependencies ---
+
+def get_idempotency_repository() -> IdempotencyRepository:
+    return _repository(FirestoreIdempotencyRepository, PostgresIdempotencyRepository, MemoryIdempotencyRepository)
+
+
+# --- Organization Dependencies ---
+
+def get_organization_repository() -> OrganizationRepository:
+    return _repository(FirestoreOrganizationRepository, PostgresOrganizationRepository, MemoryOrganizationRepository)
+
+
+# --- Feature Flag Dependencies ---
+
+def get_feature_flag_repository() -> FeatureFlagRepository:
+    return _repository(FirestoreFeatureFlagRepository, PostgresFeatureFlagRepository, MemoryFeatureFlagRepository)
+
+
+# --- Custom Role Dependencies ---
+
+def get_custom_role_repository() -> CustomRoleRepository:
+    return _repository(FirestoreCustomRoleRepository, PostgresCustomRoleRepository, MemoryCustomRoleRepository)
+
+
+# --- Data Retention Dependencies ---
+
+def get_retention_policy_repository() -> RetentionPolicyRepository:
+    return _repository(FirestoreRetentionPolicyRepository, PostgresRetentionPolicyRepository, MemoryRetentionPolicyRepository)
+
+
+def get_purge_manifest_repository() -> PurgeManifestRepository:
+    return _repository(FirestorePurgeManifestRepository, PostgresPurgeManifestRepository, MemoryPurgeManifestRepository)
+
+
+# --- Task Dependencies ---
+
+def _cloud_tasks_queue() -> TaskQueue:
+    settings = get_settings()
+    return CloudTasksQueue(
+        queue_path(TASKS_QUEUE, settings.tasks_location),
+        settings.tasks_handler_url,
+        settings.tasks_service_account,
+    )
+
+
+def get_task_queue(background_tasks: BackgroundTasks) -> TaskQueue:
+    if TASKS_QUEUE:
+        return _cloud_tasks_queue()
+    return BackgroundTaskQueue(background_tasks)
+
+
+def get_job_task_queue() -> TaskQueue:
+    """The queue for jobs deferred outside a request, by scheduled jobs; without TASKS_QUEUE they run inline."""
+    if TASKS_QUEUE:
+        return _cloud_tasks_queue()
+    return InlineTaskQueue()
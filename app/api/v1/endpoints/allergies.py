@@ -0,0 +1,175 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, status, Response
+from typing import List, Dict, Optional
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_allergy_repository, get_event_publisher, get_patient_repository
+from app.authz.roles import ADMIN
+from app.dependencies.auth import get_current_user, include_deleted, require_roles
+from app.events.publisher import EventPublisher
+from app.repositories.allergies import AllergyRepository
+from app.repositories.base import NotFoundError, including_deleted
+from app.repositories.patients import PatientRepository
+from app.repositories.transactions import transactional
+from app.terminology.service import CodeValidationError, Terminology, get_terminology
+
+router = APIRouter()
+
+def _ensure_patient_exists(patient_id: str, patients: PatientRepository):
+    if not patients.get(patient_id):
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+
+
+def _get_or_404(patient_id: str, allergy_id: str, repo: AllergyRepository) -> schemas.Allergy:
+    allergy = repo.get(allergy_id)
+    if not allergy or allergy.patient_id != patient_id:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Allergy not found")
+    return allergy
+
+
+def _check_codes(substance: Optional[schemas.Coding], reactions: Optional[List[schemas.AllergyReaction]], terminology: Terminology):
+    try:
+        terminology.check_codings([substance], "substance")
+        terminology.check_codings([m for reaction in reactions or [] for m in reaction.manifestations], "manifestations")
+    except CodeValidationError as e:
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail=str(e))
+
+
+@router.post("/{patientId}/allergies", response_model=schemas.Allergy, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_allergy(
+    patientId: str,
+    allergy_in: schemas.AllergyCreate,
+    repo: AllergyRepository = Depends(get_allergy_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    terminology: Terminology = Depends(get_terminology),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Record an allergy or intolerance for a patient. A substance can only be
+    recorded once per patient; update the existing record instead. Codes
+    are checked as `/terminology/validate-code` checks them.
+    """
+    _ensure_patient_exists(patientId, patients)
+    _check_codes(allergy_in.substance, allergy_in.reactions, terminology)
+    if repo.list(patientId, substance_code=allergy_in.substance.code, substance_system=allergy_in.substance.system, limit=1):
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="An allergy to this substance is already recorded for the patient")
+
+    allergy = repo.create(patientId, allergy_in, recorded_by=current_user["uid"])
+    events.emit("allergy.recorded", f"patients/{patientId}/allergies/{allergy.allergy_id}", allergy)
+    logging.info(f"User {current_user['uid']} recorded allergy {allergy.allergy_id} ({allergy.substance.code}) for patient {patientId}")
+    return allergy
+
+
+@router.get("/{patientId}/allergies", response_model=List[schemas.Allergy], response_model_by_alias=False)
+def list_allergies(
+    patientId: str,
+    substance: Optional[str] = Query(None, description="Only records for this substance code."),
+    system: Optional[str] = Query(None, description="Code system of `substance`; any system if omitted."),
+    clinicalStatus: Optional[schemas.AllergyClinicalStatus] = None,
+    include: bool = Depends(include_deleted),
+    repo: AllergyRepository = Depends(get_allergy_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a patient's allergies and intolerances, optionally only those for
+    one substance code or with a given clinical status.
+    """
+    if system and not substance:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="'system' requires a 'substance' code")
+    _ensure_patient_exists(patientId, patients)
+    with including_deleted(include):
+        return repo.list(patientId, substance_code=substance, substance_system=system, clinical_status=clinicalStatus)
+
+
+@router.get("/{patientId}/allergies/{allergyId}", response_model=schemas.Allergy, response_model_by_alias=False)
+def get_allergy(
+    patientId: str,
+    allergyId: str,
+    include: bool = Depends(include_deleted),
+    repo: AllergyRepository = Depends(get_allergy_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a single allergy record of a patient by ID.
+    """
+    with including_deleted(include):
+        return _get_or_404(patientId, allergyId, repo)
+
+
+@router.patch("/{patientId}/allergies/{allergyId}", response_model=schemas.Allergy, response_model_by_alias=False)
+@transactional
+def update_allergy(
+    patientId: str,
+    allergyId: str,
+    allergy_in: schemas.AllergyUpdate,
+    repo: AllergyRepository = Depends(get_allergy_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    terminology: Terminology = Depends(get_terminology),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Update an allergy record, e.g. to confirm it, add a reaction or mark it
+    resolved. The substance cannot be changed; record a new allergy instead.
+    """
+    _get_or_404(patientId, allergyId, repo)
+    _check_codes(None, allergy_in.reactions, terminology)
+    try:
+        allergy = repo.update(allergyId, allergy_in)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Allergy not found")
+    events.emit("allergy.updated", f"patients/{patientId}/allergies/{allergyId}", allergy)
+    logging.info(f"User {current_user['uid']} updated allergy {allergyId} for patient {patientId}")
+    return allergy
+
+
+@router.delete("/{patientId}/allergies/{allergyId}", status_code=status.HTTP_204_NO_CONTENT)
+@transactional
+def delete_allergy(
+    patientId: str,
+    allergyId: str,
+    repo: AllergyRepository = Depends(get_allergy_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Delete an allergy record; it can be restored. Records that were clinically
+    reviewed should usually be marked 'entered-in-error' or 'refuted' instead.
+    """
+    _get_or_404(patientId, allergyId, repo)
+    try:
+        repo.delete(allergyId, deleted_by=current_user["uid"])
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Allergy not found")
+    events.emit("allergy.deleted", f"patients/{patientId}/allergies/{allergyId}", patientId=patientId, allergyId=allergyId)
+    logging.info(f"User {current_user['uid']} deleted allergy {allergyId} for patient {patientId}")
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+@router.post("/{patientId}/allergies/{allergyId}/restore", response_model=schemas.Allergy, response_model_by_alias=False)
+@transactional
+def restore_allergy(
+    patientId: str,
+    allergyId: str,
+    repo: AllergyRepository = Depends(get_allergy_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(require_roles(ADMIN))
+):
+    """
+    Restore a deleted allergy record. Administrators only. Returns 409
+    Conflict if the substance has been recorded again since.
+    """
+    with including_deleted():
+        deleted = _get_or_404(patientId, allergyId, repo)
+    recorded = repo.list(patientId, substance_code=deleted.substance.code, substance_system=deleted.substance.system, limit=1)
+    if recorded and recorded[0].allergy_id != allergyId:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="An allergy to this substance is already recorded for the patient")
+    try:
+        allergy = repo.restore(allergyId)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Allergy not found")
+    events.emit("allergy.restored", f"patients/{patientId}/allergies/{allergyId}", allergy)
+    logging.info(f"User {current_user['uid']} restored allergy {allergyId} for patient {patientId}")
+    return allergy
@@ -0,0 +1,107 @@
+from fastapi import APIRouter, Depends, HTTPException, status
+from typing import Dict
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_api_key_repository
+from app.auth.api_keys import format_key, hash_secret, new_key_id, new_secret
+from app.dependencies.auth import require_roles
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.api_keys import ApiKeyRepository
+
+router = APIRouter()
+
+# Keys reach patient data, so only integration administrators manage them.
+API_KEY_ADMIN_ROLES = ("integration-admin",)
+
+
+def _with_secret(record: schemas.ApiKeyRecord, secret: str) -> schemas.ApiKeyWithSecret:
+    return schemas.ApiKeyWithSecret(
+        **record.model_dump(by_alias=True, exclude={"secret_hash"}),
+        key=format_key(record.key_id, secret),
+    )
+
+
+@router.post("", response_model=schemas.ApiKeyWithSecret, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+def create_api_key(
+    *,
+    key_in: schemas.ApiKeyCreate,
+    repo: ApiKeyRepository = Depends(get_api_key_repository),
+    current_user: Dict = Depends(require_roles(*API_KEY_ADMIN_ROLES))
+):
+    """
+    Issue an API key for a partner backend. The response includes the key,
+    which is not returned again; only a hash of it is stored.
+    """
+    secret = new_secret()
+    record = repo.create(new_key_id(), key_in, hash_secret(secret), current_user["uid"])
+    logging.info(f"User {current_user['uid']} created API key {record.key_id} with scopes {', '.join(record.scopes)}")
+    return _with_secret(record, secret)
+
+
+@router.get("", response_model=Page[schemas.ApiKey], response_model_by_alias=False)
+def list_api_keys(
+    page: PageRequest = Depends(pagination(default_limit=100, max_limit=500)),
+    repo: ApiKeyRepository = Depends(get_api_key_repository),
+    current_user: Dict = Depends(require_roles(*API_KEY_ADMIN_ROLES))
+):
+    """
+    Retrieve API keys, oldest first, including revoked ones.
+    """
+    return paginate(page, lambda after, limit: repo.list(limit=limit, after=after), lambda key: key.key_id)
+
+
+@router.get("/{keyId}", response_model=schemas.ApiKey, response_model_by_alias=False)
+def get_api_key(
+    keyId: str,
+    repo: ApiKeyRepository = Depends(get_api_key_repository),
+    current_user: Dict = Depends(require_roles(*API_KEY_ADMIN_ROLES))
+):
+    """
+    Retrieve a single API key by ID.
+    """
+    record = repo.get(keyId)
+    if not record:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="API key not found")
+    return record
+
+
+@router.post("/{keyId}/rotate", response_model=schemas.ApiKeyWithSecret, response_model_by_alias=False)
+def rotate_api_key(
+    keyId: str,
+    repo: ApiKeyRepository = Depends(get_api_key_repository),
+    current_user: Dict = Depends(require_roles(*API_KEY_ADMIN_ROLES))
+):
+    """
+    Replace the key's secret, keeping its ID, scopes and limit. The new key
+    is returned once; the old one stops working within API_KEY_CACHE_SECONDS.
+    """
+    record = repo.get(keyId)
+    if not record:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="API key not found")
+    if record.status == "revoked":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="Revoked API keys cannot be rotated")
+    secret = new_secret()
+    record = repo.set_secret_hash(keyId, hash_secret(secret))
+    logging.info(f"User {current_user['uid']} rotated API key {keyId}")
+    return _with_secret(record, secret)
+
+
+@router.post("/{keyId}/revoke", response_model=schemas.ApiKey, response_model_by_alias=False)
+def revoke_api_key(
+    keyId: str,
+    repo: ApiKeyRepository = Depends(get_api_key_repository),
+    current_user: Dict = Depends(require_roles(*API_KEY_ADMIN_ROLES))
+):
+    """
+    Revoke the key for good. Requests with it are refused within
+    API_KEY_CACHE_SECONDS.
+    """
+    record = repo.get(keyId)
+    if not record:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="API key not found")
+    if record.status == "revoked":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="API key is already revoked")
+    record = repo.revoke(keyId, current_user["uid"])
+    logging.info(f"User {current_user['uid']} revoked API key {keyId}")
+    return record
@@ -0,0 +1,226 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, status
+from typing import Dict, List, Optional
+from datetime import datetime
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_appointment_reminder_repository, get_appointment_repository, get_event_publisher, get_patient_repository, get_practitioner_repository
+from app.audit.context import annotate
+from app.dependencies.auth import get_current_user
+from app.dependencies.notifications import get_notifier
+from app.dependencies.reminders import get_reminder_scheduler
+from app.events.publisher import EventPublisher
+from app.notifications.notifier import Notifier
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.reminders.scheduling import ReminderScheduler
+from app.repositories.appointment_reminders import AppointmentReminderRepository
+from app.repositories.appointments import APPOINTMENT_SEARCH, AppointmentRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.practitioners import PractitionerRepository
+from app.repositories.transactions import transactional
+from app.search.filters import Search, search_query
+from app.services.appointments import InvalidTransitionError, can_reschedule, ensure_transition
+
+router = APIRouter()
+
+def _get_or_404(appointment_id: str, repo: AppointmentRepository) -> schemas.Appointment:
+    appointment = repo.get(appointment_id)
+    if not appointment:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Appointment not found")
+    annotate(patient_id=appointment.patient_id)
+    return appointment
+
+
+def _ensure_slot_free(repo: AppointmentRepository, practitioner_id: str, start: datetime, end: datetime, exclude_id: Optional[str] = None):
+    if repo.find_overlapping(practitioner_id, start, end, exclude_id=exclude_id):
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail="The practitioner already has an appointment in this time slot"
+        )
+
+
+def _transition(appointment: schemas.Appointment, target: str, repo: AppointmentRepository, changes: Optional[Dict] = None) -> schemas.Appointment:
+    try:
+        ensure_transition(appointment.status, target)
+    except InvalidTransitionError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    return repo.update(appointment.appointment_id, {"status": target, **(changes or {})})
+
+
+@router.post("", response_model=schemas.Appointment, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_appointment(
+    *,
+    appointment_in: schemas.AppointmentCreate,
+    repo: AppointmentRepository = Depends(get_appointment_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    practitioners: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    notifier: Optional[Notifier] = Depends(get_notifier),
+    reminders: Optional[ReminderScheduler] = Depends(get_reminder_scheduler),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Book an appointment. The patient and practitioner must exist and the
+    practitioner must be free for the requested period. Patients are sent
+    a confirmation by email, text and push when those are configured, and
+    reminders APPOINTMENT_REMINDER_HOURS before the appointment.
+    """
+    patient = patients.get(appointment_in.patient_id)
+    if not patient:
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown patient")
+    practitioner = practitioners.get(appointment_in.practitioner_id)
+    if not practitioner:
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown practitioner")
+    _ensure_slot_free(repo, appointment_in.practitioner_id, appointment_in.start, appointment_in.end)
+
+    appointment = repo.create(appointment_in)
+    events.emit("appointment.booked", f"appointments/{appointment.appointment_id}", appointment)
+    if notifier:
+        notifier.notify("appointment-confirmation", patient, {
+            "start": appointment.start.isoformat(),
+            "appointmentType": appointment.appointment_type,
+            "practitionerName": f"{practitioner.given_name} {practitioner.family_name}",
+            "location": appointment.location,
+        }, about=f"appointments/{appointment.appointment_id}")
+    if reminders:
+        reminders.schedule(appointment, patient)
+    logging.info(f"User {current_user['uid']} booked appointment {appointment.appointment_id} for patient {appointment.patient_id}")
+    return appointment
+
+
+@router.get("", response_model=Page[schemas.Appointment], response_model_by_alias=False)
+def list_appointments(
+    patientId: Optional[str] = None,
+    practitionerId: Optional[str] = None,
+    start_from: Optional[datetime] = Query(None, alias="from", description="Only appointments starting at or after this time."),
+    start_to: Optional[datetime] = Query(None, alias="to", description="Only appointments starting before this time."),
+    page: PageRequest = Depends(pagination(default_limit=30, max_limit=100)),
+    search: Search = Depends(search_query(APPOINTMENT_SEARCH)),
+    repo: AppointmentRepository = Depends(get_appointment_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve appointments for a patient and/or practitioner, ordered by start
+    time unless `sort` says otherwise. At least one of patientId or
+    practitionerId is required. Also filter by `status` (e.g.
+    `status=ne:cancelled`), `appointmentType`, `start`, `end`, `createdAt`
+    or `updatedAt`.
+    """
+    if not patientId and not practitionerId:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="Provide a patientId or practitionerId filter")
+    return paginate(
+        page,
+        lambda after, limit: repo.list(
+            patient_id=patientId, practitioner_id=practitionerId, start_from=start_from, start_to=start_to, limit=limit, after=after, search=search,
+        ),
+        lambda appointment: appointment.appointment_id,
+    )
+
+
+@router.get("/{appointmentId}", response_model=schemas.Appointment, response_model_by_alias=False)
+def get_appointment(
+    appointmentId: str,
+    repo: AppointmentRepository = Depends(get_appointment_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a single appointment by ID.
+    """
+    return _get_or_404(appointmentId, repo)
+
+
+@router.get("/{appointmentId}/reminders", response_model=List[schemas.AppointmentReminder], response_model_by_alias=False)
+def list_appointment_reminders(
+    appointmentId: str,
+    repo: AppointmentRepository = Depends(get_appointment_repository),
+    reminders: AppointmentReminderRepository = Depends(get_appointment_reminder_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve the reminders scheduled for the appointment, earliest due first,
+    including those sent, cancelled or skipped, with the reason.
+    """
+    _get_or_404(appointmentId, repo)
+    return sorted(reminders.list(appointmentId), key=lambda r: r.due_at)
+
+
+@router.post("/{appointmentId}/reschedule", response_model=schemas.Appointment, response_model_by_alias=False)
+@transactional
+def reschedule_appointment(
+    appointmentId: str,
+    reschedule_in: schemas.AppointmentReschedule,
+    repo: AppointmentRepository = Depends(get_appointment_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    reminders: Optional[ReminderScheduler] = Depends(get_reminder_scheduler),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Move a booked appointment to a new period. Appointments the patient has
+    already arrived for, or that are fulfilled or cancelled, cannot be moved.
+    Reminders not yet sent are cancelled and scheduled for the new time.
+    """
+    appointment = _get_or_404(appointmentId, repo)
+    if not can_reschedule(appointment.status):
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail=f"Cannot reschedule an appointment with status '{appointment.status}'."
+        )
+    _ensure_slot_free(repo, appointment.practitioner_id, reschedule_in.start, reschedule_in.end, exclude_id=appointmentId)
+
+    # The reminder announced the old time; the new one gets its own.
+    updated = repo.update(appointmentId, {**reschedule_in.model_dump(by_alias=True), "reminderSentAt": None})
+    events.emit("appointment.rescheduled", f"appointments/{appointmentId}", updated)
+    if reminders:
+        reminders.reschedule(updated, patients.get(updated.patient_id))
+    logging.info(f"User {current_user['uid']} rescheduled appointment {appointmentId} to {reschedule_in.start.isoformat()}")
+    return updated
+
+
+@router.post("/{appointmentId}/cancel", response_model=schemas.Appointment, response_model_by_alias=False)
+@transactional
+def cancel_appointment(
+    appointmentId: str,
+    cancel_in: schemas.AppointmentCancel,
+    repo: AppointmentRepository = Depends(get_appointment_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    reminders: Optional[ReminderScheduler] = Depends(get_reminder_scheduler),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Cancel a booked or arrived appointment, optionally recording a reason.
+    Its reminders not yet sent are cancelled.
+    """
+    appointment = _get_or_404(appointmentId, repo)
+    updated = _transition(appointment, "cancelled", repo, cancel_in.model_dump(by_alias=True, exclude_none=True))
+    events.emit("appointment.cancelled", f"appointments/{appointmentId}", updated)
+    if reminders:
+        reminders.cancel(appointmentId, "appointment cancelled")
+    logging.info(f"User {current_user['uid']} cancelled appointment {appointmentId}")
+    return updated
+
+
+@router.post("/{appointmentId}/status", response_model=schemas.Appointment, response_model_by_alias=False)
+@transactional
+def update_appointment_status(
+    appointmentId: str,
+    status_in: schemas.AppointmentStatusUpdate,
+    repo: AppointmentRepository = Depends(get_appointment_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    reminders: Optional[ReminderScheduler] = Depends(get_reminder_scheduler),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Move an appointment through its lifecycle: booked -> arrived -> fulfilled,
+    or to cancelled from either open state. Illegal transitions return 409.
+    Reminders not yet sent are cancelled once the appointment leaves booked.
+    """
+    appointment = _get_or_404(appointmentId, repo)
+    updated = _transition(appointment, status_in.status, repo)
+    event_type = "appointment.cancelled" if status_in.status == "cancelled" else "appointment.status_changed"
+    events.emit(event_type, f"appointments/{appointmentId}", updated)
+    if reminders:
+        reminders.cancel(appointmentId, f"appointment {status_in.status}")
+    logging.info(f"User {current_user['uid']} changed appointment {appointmentId} status from {appointment.status} to {status_in.status}")
+    return updated
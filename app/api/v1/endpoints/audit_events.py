@@ -0,0 +1,127 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, Request, Response, status
+from typing import Dict, Optional
+from datetime import datetime
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_audit_event_repository, get_audit_export_repository, get_task_queue
+from app.audit.context import annotate
+from app.authz.policy import require_step_up
+from app.core.config import get_settings
+from app.core.storage import signed_download_url
+from app.dependencies.auth import require_roles
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.audit_events import AuditEventRepository
+from app.repositories.audit_exports import AuditExportRepository
+from app.tasks.jobs import AUDIT_EXPORT_TASK
+from app.tasks.queue import TaskQueue
+
+router = APIRouter()
+
+# Only compliance staff may review the audit trail. Reads of it are
+# themselves audited by AuditMiddleware like any other request.
+AUDIT_READER_ROLES = ("compliance-officer", "privacy-officer")
+
+
+@router.get("", response_model=Page[schemas.AuditEvent], response_model_by_alias=False)
+def list_audit_events(
+    actor_uid: Optional[str] = Query(None, alias="actorUid", description="Only events by this user."),
+    patient_id: Optional[str] = Query(None, alias="patientId", description="Only events concerning this patient's records."),
+    resource_type: Optional[str] = Query(None, alias="resourceType", description="e.g. 'patients', 'encounters' or 'Observation'."),
+    occurred_from: Optional[datetime] = Query(None, alias="from", description="Only events at or after this time."),
+    occurred_to: Optional[datetime] = Query(None, alias="to", description="Only events at or before this time."),
+    page: PageRequest = Depends(pagination(default_limit=100, max_limit=1000)),
+    repo: AuditEventRepository = Depends(get_audit_event_repository),
+    current_user: Dict = Depends(require_roles(*AUDIT_READER_ROLES))
+):
+    """
+    Search the PHI access audit trail, most recent first.
+    Restricted to users with a compliance role.
+    """
+    if occurred_from and occurred_to and occurred_from > occurred_to:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="'from' must not be after 'to'")
+    logging.info(f"User {current_user['uid']} searched audit events (actor={actor_uid}, patient={patient_id}, resourceType={resource_type})")
+    return paginate(
+        page,
+        lambda after, limit: repo.list(
+            actor_uid=actor_uid,
+            patient_id=patient_id,
+            resource_type=resource_type,
+            occurred_from=occurred_from,
+            occurred_to=occurred_to,
+            limit=limit,
+            after=after,
+        ),
+        lambda event: event.event_id,
+    )
+
+
+@router.post(
+    "/exports",
+    response_model=schemas.AuditExport,
+    status_code=status.HTTP_202_ACCEPTED,
+    response_model_by_alias=False,
+    dependencies=[Depends(require_step_up("export the audit trail"))],
+)
+def start_audit_export(
+    export_in: schemas.AuditExportCreate,
+    request: Request,
+    response: Response,
+    exports: AuditExportRepository = Depends(get_audit_export_repository),
+    tasks: TaskQueue = Depends(get_task_queue),
+    current_user: Dict = Depends(require_roles(*AUDIT_READER_ROLES))
+):
+    """
+    Export the audit events matching the filters, which are those of the
+    search, to a CSV file, e.g. every access to one patient's chart over a
+    year. The file is written in the background: poll the export at
+    `Content-Location` until it is completed, then download it from its
+    `download_url`. Restricted to users with a compliance role.
+    """
+    bucket_name = get_settings().audit_export_bucket
+    if not bucket_name:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="Audit export is not configured (AUDIT_EXPORT_BUCKET)")
+    export = exports.create(export_in, current_user["uid"])
+    # Runs on Cloud Tasks, or in this worker after the 202 has been sent when no queue is configured.
+    tasks.enqueue(AUDIT_EXPORT_TASK, {"exportId": export.export_id}, task_id=f"audit-export-{export.export_id}")
+    annotate(patient_id=export_in.patient_id, resource_type="auditExports", resource_id=export.export_id)
+    logging.info(f"User {current_user['uid']} started audit export {export.export_id} (actor={export_in.actor_uid}, patient={export_in.patient_id}, resourceType={export_in.resource_type})")
+    response.headers["Content-Location"] = f"{request.url.path.rstrip('/')}/{export.export_id}"
+    return export
+
+
+@router.get("/exports/{exportId}", response_model=schemas.AuditExport, response_model_by_alias=False)
+def get_audit_export(
+    exportId: str,
+    exports: AuditExportRepository = Depends(get_audit_export_repository),
+    current_user: Dict = Depends(require_roles(*AUDIT_READER_ROLES))
+):
+    """
+    Retrieve an audit export. Once completed, `download_url` links to the
+    CSV, signed per request and valid for AUDIT_EXPORT_URL_TTL_SECONDS.
+    Restricted to users with a compliance role.
+    """
+    export = exports.get(exportId)
+    if not export:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Audit export not found")
+    annotate(patient_id=export.patient_id, resource_type="auditExports", resource_id=exportId)
+    if export.status == "completed" and export.blob_name:
+        settings = get_settings()
+        url = signed_download_url(settings.audit_export_bucket, export.blob_name, settings.audit_export_url_ttl_seconds)
+        export = export.model_copy(update={"download_url": url})
+    return export
+
+
+@router.get("/{eventId}", response_model=schemas.AuditEvent, response_model_by_alias=False)
+def get_audit_event(
+    eventId: str,
+    repo: AuditEventRepository = Depends(get_audit_event_repository),
+    current_user: Dict = Depends(require_roles(*AUDIT_READER_ROLES))
+):
+    """
+    Retrieve a single audit event by ID. Restricted to users with a compliance role.
+    """
+    event = repo.get(eventId)
+    if not event:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Audit event not found")
+    return event
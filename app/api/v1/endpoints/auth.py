@@ -1,4 +1,3 @@
-import os
 import httpx
 import jwt
 import logging
@@ -7,6 +6,9 @@ from pydantic import BaseModel, Field
 from google.cloud.firestore_v1.base_query import FieldFilter
 from firebase_admin import auth, firestore
 
+from app.core.config import get_settings
+from app.core.tracing import start_span
+
 router = APIRouter()
 
 # --- Schemas ---
@@ -26,11 +28,12 @@ class LineLoginResponse(BaseModel):
     firebase_token: str | None = Field(default=None, description="The Firebase custom token, present on successful login.")
     line_profile: LineProfileResponse | None = Field(default=None, description="The user's LINE profile, present if registration is required.")
 
-# --- Environment Variables ---
-# These should be set in your deployment environment (e.g., Cloud Run environment variables)
+# --- Configuration ---
+# These should be set in your deployment environment (e.g., Cloud Run environment
+# variables or `sm://` Secret Manager references, see app/core/config.py)
 LINE_TOKEN_URL = "https://api.line.me/oauth2/v2.1/token"
-LINE_CHANNEL_ID = os.getenv("LINE_CHANNEL_ID")
-LINE_CHANNEL_SECRET = os.getenv("LINE_CHANNEL_SECRET")
+LINE_CHANNEL_ID = get_settings().line_channel_id
+LINE_CHANNEL_SECRET = get_settings().line_channel_secret
 
 @router.post("/line", response_model=LineLoginResponse)
 async def line_login(payload: LineLoginRequest):
@@ -108,7 +111,8 @@ async def line_login(payload: LineLoginRequest):
     query = customers_ref.where(filter=FieldFilter("lineId", "==", line_user_id)).limit(1)
     
     try:
-        docs = list(query.stream())
+        with start_span("firestore.customers.find_by_line_id"):
+            docs = list(query.stream())
         if docs:
             # 5. If user exists (Login Flow)
             customer_doc = docs[0]
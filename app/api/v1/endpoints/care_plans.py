@@ -0,0 +1,354 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, status, Response
+from typing import List, Dict, Optional
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_care_plan_repository, get_care_team_repository, get_event_publisher, get_patient_repository, get_practitioner_repository
+from app.audit.context import annotate
+from app.authz.roles import ADMIN
+from app.dependencies.auth import get_current_user, include_deleted, require_roles
+from app.events.publisher import EventPublisher
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.base import NotFoundError, including_deleted
+from app.repositories.care_plans import CarePlanRepository
+from app.repositories.care_teams import CareTeamRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.practitioners import PractitionerRepository
+from app.repositories.transactions import transactional
+from app.services.care_plans import CARE_PLAN_STATUSES
+from app.services.state_machine import InvalidTransitionError
+
+router = APIRouter()
+
+def _get_or_404(care_plan_id: str, repo: CarePlanRepository) -> schemas.CarePlan:
+    care_plan = repo.get(care_plan_id)
+    if not care_plan:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Care plan not found")
+    annotate(patient_id=care_plan.patient_id)
+    return care_plan
+
+
+def _get_editable_or_404(care_plan_id: str, repo: CarePlanRepository) -> schemas.CarePlan:
+    """Completed and revoked plans are kept as a record and can no longer be edited."""
+    care_plan = _get_or_404(care_plan_id, repo)
+    if CARE_PLAN_STATUSES.is_terminal(care_plan.status):
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail=f"Cannot modify a care plan with status '{care_plan.status}'."
+        )
+    return care_plan
+
+
+def _ensure_care_team_for_patient(care_team_id: Optional[str], patient_id: str, care_teams: CareTeamRepository):
+    if not care_team_id:
+        return
+    care_team = care_teams.get(care_team_id)
+    if not care_team or care_team.patient_id != patient_id:
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown care team for this patient")
+
+
+def _ensure_practitioners_exist(activities: List[schemas.CarePlanActivityCreate], practitioners: PractitionerRepository):
+    missing = sorted({a.practitioner_id for a in activities if a.practitioner_id and not practitioners.get(a.practitioner_id)})
+    if missing:
+        raise HTTPException(
+            status_code=status.HTTP_422_UNPROCESSABLE_ENTITY,
+            detail=f"Unknown practitioner(s): {', '.join(missing)}"
+        )
+
+
+@router.post("", response_model=schemas.CarePlan, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_care_plan(
+    *,
+    care_plan_in: schemas.CarePlanCreate,
+    repo: CarePlanRepository = Depends(get_care_plan_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    care_teams: CareTeamRepository = Depends(get_care_team_repository),
+    practitioners: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Create a care plan for a patient, optionally with its initial goals and
+    activities. New plans start in 'draft' and are put into effect through the
+    `/status` endpoint.
+    """
+    if not patients.get(care_plan_in.patient_id):
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown patient")
+    _ensure_care_team_for_patient(care_plan_in.care_team_id, care_plan_in.patient_id, care_teams)
+    _ensure_practitioners_exist(care_plan_in.activities, practitioners)
+
+    care_plan = repo.create(care_plan_in, created_by=current_user["uid"])
+    events.emit("care_plan.created", f"care-plans/{care_plan.care_plan_id}", care_plan)
+    logging.info(f"User {current_user['uid']} created care plan {care_plan.care_plan_id} for patient {care_plan.patient_id}")
+    return care_plan
+
+
+@router.get("", response_model=Page[schemas.CarePlan], response_model_by_alias=False)
+def list_care_plans(
+    patientId: Optional[str] = None,
+    status_filter: Optional[schemas.CarePlanStatus] = Query(None, alias="status"),
+    page: PageRequest = Depends(pagination(default_limit=30, max_limit=100)),
+    include: bool = Depends(include_deleted),
+    repo: CarePlanRepository = Depends(get_care_plan_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a patient's care plans, optionally only those with a given status.
+    """
+    if not patientId:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="Provide a patientId filter")
+    with including_deleted(include):
+        return paginate(
+            page,
+            lambda after, limit: repo.list(patient_id=patientId, status=status_filter, limit=limit, after=after),
+            lambda care_plan: care_plan.care_plan_id,
+        )
+
+
+@router.get("/{carePlanId}", response_model=schemas.CarePlan, response_model_by_alias=False)
+def get_care_plan(
+    carePlanId: str,
+    include: bool = Depends(include_deleted),
+    repo: CarePlanRepository = Depends(get_care_plan_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a single care plan by ID, including its goals, activities and
+    status history.
+    """
+    with including_deleted(include):
+        return _get_or_404(carePlanId, repo)
+
+
+@router.patch("/{carePlanId}", response_model=schemas.CarePlan, response_model_by_alias=False)
+@transactional
+def update_care_plan(
+    carePlanId: str,
+    care_plan_in: schemas.CarePlanUpdate,
+    repo: CarePlanRepository = Depends(get_care_plan_repository),
+    care_teams: CareTeamRepository = Depends(get_care_team_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Update a care plan's details. Goals and activities are managed through
+    their sub-resources and status through the `/status` endpoint.
+    """
+    care_plan = _get_editable_or_404(carePlanId, repo)
+    period_start = care_plan_in.period_start or care_plan.period_start
+    period_end = care_plan_in.period_end or care_plan.period_end
+    if period_end and period_end < period_start:
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="periodEnd must not be before periodStart")
+    _ensure_care_team_for_patient(care_plan_in.care_team_id, care_plan.patient_id, care_teams)
+
+    try:
+        updated = repo.update(carePlanId, care_plan_in)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Care plan not found")
+    events.emit("care_plan.updated", f"care-plans/{carePlanId}", updated)
+    return updated
+
+
+@router.post("/{carePlanId}/status", response_model=schemas.CarePlan, response_model_by_alias=False)
+@transactional
+def update_care_plan_status(
+    carePlanId: str,
+    status_in: schemas.CarePlanStatusUpdate,
+    repo: CarePlanRepository = Depends(get_care_plan_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Move a care plan through its lifecycle: draft -> active, active <-> on-hold,
+    and finally completed or revoked. Illegal transitions return 409. Every
+    change is recorded in the plan's status history.
+    """
+    care_plan = _get_or_404(carePlanId, repo)
+    try:
+        CARE_PLAN_STATUSES.ensure(care_plan.status, status_in.status)
+    except InvalidTransitionError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+
+    updated = repo.set_status(carePlanId, status_in.status, changed_by=current_user["uid"], reason=status_in.reason)
+    events.emit("care_plan.status_changed", f"care-plans/{carePlanId}", updated)
+    logging.info(f"User {current_user['uid']} changed care plan {carePlanId} status from {care_plan.status} to {status_in.status}")
+    return updated
+
+
+@router.delete("/{carePlanId}", status_code=status.HTTP_204_NO_CONTENT)
+@transactional
+def delete_care_plan(
+    carePlanId: str,
+    repo: CarePlanRepository = Depends(get_care_plan_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Delete a draft care plan; it can be restored. Plans that have been put
+    into effect cannot be deleted and should be revoked instead.
+    """
+    care_plan = _get_or_404(carePlanId, repo)
+    if care_plan.status != "draft":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="Only draft care plans can be deleted; revoke it instead")
+    try:
+        repo.delete(carePlanId, deleted_by=current_user["uid"])
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Care plan not found")
+    events.emit("care_plan.deleted", f"care-plans/{carePlanId}", carePlanId=carePlanId)
+    logging.info(f"User {current_user['uid']} deleted draft care plan {carePlanId}")
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+@router.post("/{carePlanId}/restore", response_model=schemas.CarePlan, response_model_by_alias=False)
+@transactional
+def restore_care_plan(
+    carePlanId: str,
+    repo: CarePlanRepository = Depends(get_care_plan_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(require_roles(ADMIN))
+):
+    """
+    Restore a deleted care plan, as the draft it was. Administrators only.
+    """
+    try:
+        care_plan = repo.restore(carePlanId)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Care plan not found")
+    events.emit("care_plan.restored", f"care-plans/{carePlanId}", care_plan)
+    logging.info(f"User {current_user['uid']} restored care plan {carePlanId}")
+    return care_plan
+
+
+# --- Goals ---
+
+@router.post("/{carePlanId}/goals", response_model=schemas.CarePlan, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def add_care_plan_goal(
+    carePlanId: str,
+    goal_in: schemas.CarePlanGoalCreate,
+    repo: CarePlanRepository = Depends(get_care_plan_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Add a goal to a care plan.
+    """
+    _get_editable_or_404(carePlanId, repo)
+    care_plan = repo.add_goal(carePlanId, goal_in)
+    events.emit("care_plan.updated", f"care-plans/{carePlanId}", care_plan)
+    logging.info(f"User {current_user['uid']} added a goal to care plan {carePlanId}")
+    return care_plan
+
+
+@router.patch("/{carePlanId}/goals/{goalId}", response_model=schemas.CarePlan, response_model_by_alias=False)
+@transactional
+def update_care_plan_goal(
+    carePlanId: str,
+    goalId: str,
+    goal_in: schemas.CarePlanGoalUpdate,
+    repo: CarePlanRepository = Depends(get_care_plan_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Update a goal, e.g. to record that it has been achieved.
+    """
+    _get_editable_or_404(carePlanId, repo)
+    try:
+        care_plan = repo.update_goal(carePlanId, goalId, goal_in)
+    except NotFoundError as e:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail=str(e))
+    events.emit("care_plan.updated", f"care-plans/{carePlanId}", care_plan)
+    return care_plan
+
+
+@router.delete("/{carePlanId}/goals/{goalId}", response_model=schemas.CarePlan, response_model_by_alias=False)
+@transactional
+def remove_care_plan_goal(
+    carePlanId: str,
+    goalId: str,
+    repo: CarePlanRepository = Depends(get_care_plan_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Remove a goal from a care plan.
+    """
+    _get_editable_or_404(carePlanId, repo)
+    try:
+        care_plan = repo.remove_goal(carePlanId, goalId)
+    except NotFoundError as e:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail=str(e))
+    events.emit("care_plan.updated", f"care-plans/{carePlanId}", care_plan)
+    logging.info(f"User {current_user['uid']} removed goal {goalId} from care plan {carePlanId}")
+    return care_plan
+
+
+# --- Activities ---
+
+@router.post("/{carePlanId}/activities", response_model=schemas.CarePlan, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def add_care_plan_activity(
+    carePlanId: str,
+    activity_in: schemas.CarePlanActivityCreate,
+    repo: CarePlanRepository = Depends(get_care_plan_repository),
+    practitioners: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Add a scheduled activity to a care plan.
+    """
+    _get_editable_or_404(carePlanId, repo)
+    _ensure_practitioners_exist([activity_in], practitioners)
+    care_plan = repo.add_activity(carePlanId, activity_in)
+    events.emit("care_plan.updated", f"care-plans/{carePlanId}", care_plan)
+    logging.info(f"User {current_user['uid']} added an activity to care plan {carePlanId}")
+    return care_plan
+
+
+@router.patch("/{carePlanId}/activities/{activityId}", response_model=schemas.CarePlan, response_model_by_alias=False)
+@transactional
+def update_care_plan_activity(
+    carePlanId: str,
+    activityId: str,
+    activity_in: schemas.CarePlanActivityUpdate,
+    repo: CarePlanRepository = Depends(get_care_plan_repository),
+    practitioners: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Update an activity's schedule, assignee or progress.
+    """
+    _get_editable_or_404(carePlanId, repo)
+    _ensure_practitioners_exist([activity_in], practitioners)
+    try:
+        care_plan = repo.update_activity(carePlanId, activityId, activity_in)
+    except NotFoundError as e:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail=str(e))
+    events.emit("care_plan.updated", f"care-plans/{carePlanId}", care_plan)
+    return care_plan
+
+
+@router.delete("/{carePlanId}/activities/{activityId}", response_model=schemas.CarePlan, response_model_by_alias=False)
+@transactional
+def remove_care_plan_activity(
+    carePlanId: str,
+    activityId: str,
+    repo: CarePlanRepository = Depends(get_care_plan_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Remove an activity from a care plan.
+    """
+    _get_editable_or_404(carePlanId, repo)
+    try:
+        care_plan = repo.remove_activity(carePlanId, activityId)
+    except NotFoundError as e:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail=str(e))
+    events.emit("care_plan.updated", f"care-plans/{carePlanId}", care_plan)
+    logging.info(f"User {current_user['uid']} removed activity {activityId} from care plan {carePlanId}")
+    return care_plan
@@ -0,0 +1,198 @@
+from fastapi import APIRouter, Depends, HTTPException, status, Response
+from typing import List, Dict, Optional
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_care_team_repository, get_event_publisher, get_patient_repository, get_practitioner_repository
+from app.authz.roles import ADMIN
+from app.dependencies.auth import get_current_user, include_deleted, require_roles
+from app.events.publisher import EventPublisher
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.base import ConflictError, NotFoundError, including_deleted
+from app.repositories.care_teams import CareTeamRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.practitioners import PractitionerRepository
+from app.repositories.transactions import transactional
+
+router = APIRouter()
+
+def _ensure_practitioners_exist(practitioner_ids: List[str], practitioners: PractitionerRepository):
+    missing = [pid for pid in practitioner_ids if not practitioners.get(pid)]
+    if missing:
+        raise HTTPException(
+            status_code=status.HTTP_422_UNPROCESSABLE_ENTITY,
+            detail=f"Unknown practitioner(s): {', '.join(missing)}"
+        )
+
+
+@router.post("", response_model=schemas.CareTeam, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_care_team(
+    *,
+    care_team_in: schemas.CareTeamCreate,
+    repo: CareTeamRepository = Depends(get_care_team_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    practitioners: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Create a care team for a patient. The patient and every member must exist.
+    """
+    if not patients.get(care_team_in.patient_id):
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown patient")
+    member_ids = [m.practitioner_id for m in care_team_in.members]
+    if len(set(member_ids)) != len(member_ids):
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="A practitioner can only appear once in a care team")
+    _ensure_practitioners_exist(member_ids, practitioners)
+
+    care_team = repo.create(care_team_in)
+    events.emit("care_team.created", f"care-teams/{care_team.care_team_id}", care_team)
+    logging.info(f"User {current_user['uid']} created care team {care_team.care_team_id} for patient {care_team.patient_id}")
+    return care_team
+
+
+@router.get("", response_model=Page[schemas.CareTeam], response_model_by_alias=False)
+def list_care_teams(
+    patientId: Optional[str] = None,
+    practitionerId: Optional[str] = None,
+    page: PageRequest = Depends(pagination(default_limit=30, max_limit=100)),
+    include: bool = Depends(include_deleted),
+    repo: CareTeamRepository = Depends(get_care_team_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve care teams for a patient and/or those a practitioner belongs to.
+    At least one filter is required.
+    """
+    if not patientId and not practitionerId:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="Provide a patientId or practitionerId filter")
+    with including_deleted(include):
+        return paginate(
+            page,
+            lambda after, limit: repo.list(patient_id=patientId, practitioner_id=practitionerId, limit=limit, after=after),
+            lambda care_team: care_team.care_team_id,
+        )
+
+
+@router.get("/{careTeamId}", response_model=schemas.CareTeam, response_model_by_alias=False)
+def get_care_team(
+    careTeamId: str,
+    include: bool = Depends(include_deleted),
+    repo: CareTeamRepository = Depends(get_care_team_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a single care team by ID.
+    """
+    with including_deleted(include):
+        care_team = repo.get(careTeamId)
+    if not care_team:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Care team not found")
+    return care_team
+
+
+@router.patch("/{careTeamId}", response_model=schemas.CareTeam, response_model_by_alias=False)
+@transactional
+def update_care_team(
+    careTeamId: str,
+    care_team_in: schemas.CareTeamUpdate,
+    repo: CareTeamRepository = Depends(get_care_team_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Rename a care team or change its status. Membership is managed through the
+    `/members` sub-resource.
+    """
+    try:
+        care_team = repo.update(careTeamId, care_team_in)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Care team not found")
+    events.emit("care_team.updated", f"care-teams/{careTeamId}", care_team)
+    return care_team
+
+
+@router.post("/{careTeamId}/members", response_model=schemas.CareTeam, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def add_care_team_member(
+    careTeamId: str,
+    member: schemas.CareTeamMember,
+    repo: CareTeamRepository = Depends(get_care_team_repository),
+    practitioners: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Add a practitioner to a care team.
+    """
+    _ensure_practitioners_exist([member.practitioner_id], practitioners)
+    try:
+        care_team = repo.add_member(careTeamId, member)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Care team not found")
+    except ConflictError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    events.emit("care_team.updated", f"care-teams/{careTeamId}", care_team)
+    logging.info(f"User {current_user['uid']} added practitioner {member.practitioner_id} to care team {careTeamId}")
+    return care_team
+
+
+@router.delete("/{careTeamId}/members/{practitionerId}", response_model=schemas.CareTeam, response_model_by_alias=False)
+@transactional
+def remove_care_team_member(
+    careTeamId: str,
+    practitionerId: str,
+    repo: CareTeamRepository = Depends(get_care_team_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Remove a practitioner from a care team.
+    """
+    try:
+        care_team = repo.remove_member(careTeamId, practitionerId)
+    except NotFoundError as e:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail=str(e))
+    events.emit("care_team.updated", f"care-teams/{careTeamId}", care_team)
+    logging.info(f"User {current_user['uid']} removed practitioner {practitionerId} from care team {careTeamId}")
+    return care_team
+
+
+@router.delete("/{careTeamId}", status_code=status.HTTP_204_NO_CONTENT)
+@transactional
+def delete_care_team(
+    careTeamId: str,
+    repo: CareTeamRepository = Depends(get_care_team_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Delete a care team. The record is kept, marked deleted, and can be restored.
+    """
+    try:
+        repo.delete(careTeamId, deleted_by=current_user["uid"])
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Care team not found")
+    events.emit("care_team.deleted", f"care-teams/{careTeamId}", careTeamId=careTeamId)
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+@router.post("/{careTeamId}/restore", response_model=schemas.CareTeam, response_model_by_alias=False)
+@transactional
+def restore_care_team(
+    careTeamId: str,
+    repo: CareTeamRepository = Depends(get_care_team_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(require_roles(ADMIN))
+):
+    """
+    Restore a deleted care team. Administrators only.
+    """
+    try:
+        care_team = repo.restore(careTeamId)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Care team not found")
+    events.emit("care_team.restored", f"care-teams/{careTeamId}", care_team)
+    logging.info(f"User {current_user['uid']} restored care team {careTeamId}")
+    return care_team
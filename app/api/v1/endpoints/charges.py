@@ -0,0 +1,93 @@
+from fastapi import APIRouter, Depends, HTTPException, Query, Response, status
+from typing import Dict, List, Optional
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_charge_item_repository, get_encounter_repository, get_event_publisher, get_practitioner_repository
+from app.audit.context import annotate
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.repositories.claims import ChargeItemRepository
+from app.repositories.encounters import EncounterRepository
+from app.repositories.practitioners import PractitionerRepository
+from app.repositories.transactions import transactional
+
+router = APIRouter()
+
+
+def _encounter_or_404(encounter_id: str, encounters: EncounterRepository) -> schemas.Encounter:
+    encounter = encounters.get(encounter_id)
+    if not encounter:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Encounter not found")
+    annotate(patient_id=encounter.patient_id)
+    return encounter
+
+
+@router.post("/{encounterId}/charges", response_model=schemas.ChargeItem, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def record_charge_item(
+    encounterId: str,
+    charge_in: schemas.ChargeItemCreate,
+    repo: ChargeItemRepository = Depends(get_charge_item_repository),
+    encounters: EncounterRepository = Depends(get_encounter_repository),
+    practitioners: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Record a service provided at the visit, coded for billing: its CPT or
+    HCPCS code and modifiers, the diagnoses it was for, units and charge.
+    Charge items are billable until a claim bills them.
+    """
+    encounter = _encounter_or_404(encounterId, encounters)
+    if encounter.status == "cancelled":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="Cannot charge for a cancelled encounter")
+    if charge_in.practitioner_id and not practitioners.get(charge_in.practitioner_id):
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown practitioner")
+    charge = repo.create(encounter, charge_in, charge_in.service_date or encounter.start.date(), recorded_by=current_user["uid"])
+    events.emit("charge_item.recorded", f"encounters/{encounterId}/charges/{charge.charge_item_id}", charge)
+    logging.info(f"User {current_user['uid']} recorded charge item {charge.charge_item_id} ({charge.procedure_code}) on encounter {encounterId}")
+    return charge
+
+
+@router.get("/{encounterId}/charges", response_model=List[schemas.ChargeItem], response_model_by_alias=False)
+def list_charge_items(
+    encounterId: str,
+    charge_status: Optional[schemas.ChargeItemStatus] = Query(None, alias="status"),
+    repo: ChargeItemRepository = Depends(get_charge_item_repository),
+    encounters: EncounterRepository = Depends(get_encounter_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve the encounter's charge items by date of service, optionally
+    only those with a status.
+    """
+    _encounter_or_404(encounterId, encounters)
+    return repo.list(encounterId, status=charge_status)
+
+
+@router.delete("/{encounterId}/charges/{chargeItemId}", status_code=status.HTTP_204_NO_CONTENT)
+@transactional
+def void_charge_item(
+    encounterId: str,
+    chargeItemId: str,
+    repo: ChargeItemRepository = Depends(get_charge_item_repository),
+    encounters: EncounterRepository = Depends(get_encounter_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Void a charge item recorded in error. It is kept, voided, and cannot be
+    billed. A billed charge item is voided by voiding its claim first.
+    """
+    _encounter_or_404(encounterId, encounters)
+    charge = repo.get(chargeItemId)
+    if not charge or charge.encounter_id != encounterId:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Charge item not found")
+    if charge.status == "billed":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=f"The charge item is billed on claim {charge.claim_id}")
+    if charge.status == "billable":
+        charge = repo.update(chargeItemId, {"status": "voided"})
+        events.emit("charge_item.voided", f"encounters/{encounterId}/charges/{chargeItemId}", charge)
+        logging.info(f"User {current_user['uid']} voided charge item {chargeItemId} on encounter {encounterId}")
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
@@ -0,0 +1,210 @@
+from fastapi import APIRouter, Depends, HTTPException, Query, status
+from datetime import datetime, timezone
+from typing import Dict, Optional
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import (
+    get_charge_item_repository,
+    get_claim_repository,
+    get_coverage_repository,
+    get_encounter_repository,
+    get_event_publisher,
+    get_task_queue,
+)
+from app.audit.context import annotate
+from app.claims.clearinghouse import get_claims_clearinghouse
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.base import NotFoundError
+from app.repositories.claims import ChargeItemRepository, ClaimRepository
+from app.repositories.coverage import CoverageRepository
+from app.repositories.encounters import EncounterRepository
+from app.repositories.transactions import transactional
+from app.services.claims import InvalidClaimError, adjudicated_lines, build_claim, patient_responsibility
+from app.services.state_machine import InvalidTransitionError
+from app.tasks.jobs import CLAIM_SUBMISSION_TASK
+from app.tasks.queue import TaskQueue
+
+router = APIRouter()
+
+
+def _get_or_404(claim_id: str, repo: ClaimRepository) -> schemas.Claim:
+    claim = repo.get(claim_id)
+    if not claim:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Claim not found")
+    annotate(patient_id=claim.patient_id)
+    return claim
+
+
+def _coverage_or_422(claim_in: schemas.ClaimCreate, encounter: schemas.Encounter, coverages: CoverageRepository) -> schemas.Coverage:
+    if claim_in.coverage_id:
+        coverage = coverages.get(claim_in.coverage_id)
+        if not coverage or coverage.patient_id != encounter.patient_id:
+            raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown coverage for this patient")
+    else:
+        coverage = next(iter(coverages.list(encounter.patient_id, status="active")), None)
+        if not coverage:
+            raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="The patient has no active coverage to bill")
+    if coverage.status != "active":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="The coverage is inactive")
+    # A claim to a secondary payer carries what the primary paid, which is not recorded yet.
+    if coverage.priority != "primary":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="Only primary coverage can be billed")
+    return coverage
+
+
+@router.post("", response_model=schemas.Claim, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_claim(
+    claim_in: schemas.ClaimCreate,
+    repo: ClaimRepository = Depends(get_claim_repository),
+    charges: ChargeItemRepository = Depends(get_charge_item_repository),
+    encounters: EncounterRepository = Depends(get_encounter_repository),
+    coverages: CoverageRepository = Depends(get_coverage_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Draft a claim billing a finished encounter's charge items to the
+    patient's primary coverage. The charge items are billed by the claim
+    until it is voided. Review the draft, then submit it.
+    """
+    encounter = encounters.get(claim_in.encounter_id)
+    if not encounter:
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown encounter")
+    annotate(patient_id=encounter.patient_id)
+    if encounter.status != "finished":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="Only finished encounters can be billed")
+    coverage = _coverage_or_422(claim_in, encounter, coverages)
+
+    billable = charges.list(encounter.encounter_id, status="billable")
+    if claim_in.charge_item_ids is not None:
+        unknown = set(claim_in.charge_item_ids) - {charge.charge_item_id for charge in billable}
+        if unknown:
+            raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=f"Not billable charge items of this encounter: {', '.join(sorted(unknown))}")
+        billable = [charge for charge in billable if charge.charge_item_id in claim_in.charge_item_ids]
+    try:
+        fields = build_claim(encounter, coverage, billable, claim_in.filing_code)
+    except InvalidClaimError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+
+    claim = repo.create(fields, created_by=current_user["uid"])
+    for charge in billable:
+        charges.update(charge.charge_item_id, {"status": "billed", "claimId": claim.claim_id})
+    events.emit("claim.created", f"claims/{claim.claim_id}", claim)
+    logging.info(f"User {current_user['uid']} drafted claim {claim.claim_id} of {len(billable)} charge items for encounter {encounter.encounter_id}")
+    return claim
+
+
+@router.get("", response_model=Page[schemas.Claim], response_model_by_alias=False)
+def list_claims(
+    patientId: Optional[str] = None,
+    encounterId: Optional[str] = None,
+    claim_status: Optional[schemas.ClaimStatus] = Query(None, alias="status"),
+    page: PageRequest = Depends(pagination(default_limit=30, max_limit=100)),
+    repo: ClaimRepository = Depends(get_claim_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve claims newest first, optionally of a patient or encounter or
+    with a status, e.g. `denied` to work the denials.
+    """
+    return paginate(
+        page,
+        lambda after, limit: repo.list(
+            patient_id=patientId, encounter_id=encounterId,
+            statuses=[claim_status] if claim_status else None, limit=limit, after=after,
+        ),
+        lambda claim: claim.claim_id,
+    )
+
+
+@router.get("/{claimId}", response_model=schemas.Claim, response_model_by_alias=False)
+def get_claim(
+    claimId: str,
+    repo: ClaimRepository = Depends(get_claim_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a claim with its lines, status history and, once rejected or
+    denied, the reasons given.
+    """
+    return _get_or_404(claimId, repo)
+
+
+@router.post("/{claimId}/submit", response_model=schemas.Claim, status_code=status.HTTP_202_ACCEPTED, response_model_by_alias=False)
+@transactional
+def submit_claim(
+    claimId: str,
+    repo: ClaimRepository = Depends(get_claim_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    tasks: TaskQueue = Depends(get_task_queue),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Queue a draft claim, or a rejected one that has been corrected, to be
+    sent to the payer through the clearinghouse. It is `submitted` once the
+    clearinghouse has taken it, or `rejected` with the edits it failed.
+    """
+    if get_claims_clearinghouse() is None:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="Claims submission is not configured (CLAIMS_PROVIDER)")
+    claim = _get_or_404(claimId, repo)
+    try:
+        claim = repo.change_status(claimId, "queued", current_user["uid"], {"denialReasons": [], "error": None})
+    except InvalidTransitionError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    events.emit("claim.status_changed", f"claims/{claimId}", claim)
+    # Each submission is its own task, so a resubmission is not taken for a repeat of the last.
+    tasks.enqueue(CLAIM_SUBMISSION_TASK, {"claimId": claimId}, task_id=f"claim-submission-{claimId}-{len(claim.history)}")
+    logging.info(f"User {current_user['uid']} queued claim {claimId} for submission to payer {claim.payer_id}")
+    return claim
+
+
+@router.post("/{claimId}/status", response_model=schemas.Claim, response_model_by_alias=False)
+@transactional
+def record_claim_status(
+    claimId: str,
+    status_in: schemas.ClaimStatusUpdate,
+    repo: ClaimRepository = Depends(get_claim_repository),
+    charges: ChargeItemRepository = Depends(get_charge_item_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Record where a claim stands when the payer said so outside the
+    clearinghouse, e.g. a paper remittance advice with what was paid and
+    adjusted per line, or void a draft or rejected claim, which frees its
+    charge items to be billed again.
+    """
+    claim = _get_or_404(claimId, repo)
+    changes = {}
+    if status_in.payer_claim_number:
+        changes["payerClaimNumber"] = status_in.payer_claim_number
+    if status_in.denial_reasons:
+        changes["denialReasons"] = [reason.model_dump(by_alias=True) for reason in status_in.denial_reasons]
+    if status_in.status in ("paid", "denied"):
+        try:
+            lines = adjudicated_lines(claim, status_in.lines)
+        except InvalidClaimError as e:
+            raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail=str(e))
+        responsibility = status_in.patient_responsibility_cents
+        changes.update({
+            "lines": [line.model_dump(by_alias=True) for line in lines],
+            "paidCents": status_in.paid_cents if status_in.paid_cents is not None else sum(line.paid_cents or 0 for line in lines),
+            "patientResponsibilityCents": responsibility if responsibility is not None else patient_responsibility(lines),
+            "adjudicatedAt": datetime.now(timezone.utc),
+        })
+    try:
+        updated = repo.change_status(claimId, status_in.status, current_user["uid"], changes, note=status_in.note)
+    except InvalidTransitionError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Claim not found")
+    if updated.status == "void":
+        for line in claim.lines:
+            charges.update(line.charge_item_id, {"status": "billable", "claimId": None})
+    events.emit("claim.status_changed", f"claims/{claimId}", updated)
+    logging.info(f"User {current_user['uid']} recorded claim {claimId} as {updated.status}")
+    return updated
@@ -0,0 +1,59 @@
+from fastapi import APIRouter, Depends, HTTPException, status
+from typing import List, Dict
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_consent_document_repository, get_event_publisher
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.repositories.consents import ConsentDocumentRepository
+from app.repositories.transactions import transactional
+
+router = APIRouter()
+
+@router.post("", response_model=schemas.ConsentDocument, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def publish_consent_document(
+    *,
+    document_in: schemas.ConsentDocumentCreate,
+    repo: ConsentDocumentRepository = Depends(get_consent_document_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Publish a new version of the consent text for a scope. Versions are
+    numbered sequentially per scope; published versions are never edited.
+    Mark wording changes that patients must agree to again as `materialChange`.
+    """
+    document = repo.create(document_in, published_by=current_user["uid"])
+    events.emit("consent_document.published", f"consent-documents/{document.document_id}", document)
+    logging.info(f"User {current_user['uid']} published {document.scope} consent document version {document.version}")
+    return document
+
+
+@router.get("", response_model=List[schemas.ConsentDocument], response_model_by_alias=False)
+def list_consent_documents(
+    scope: schemas.ConsentScope,
+    repo: ConsentDocumentRepository = Depends(get_consent_document_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve every published version of a scope's consent text, newest first.
+    The first entry is the version new consents must be given to.
+    """
+    return repo.list(scope)
+
+
+@router.get("/{documentId}", response_model=schemas.ConsentDocument, response_model_by_alias=False)
+def get_consent_document(
+    documentId: str,
+    repo: ConsentDocumentRepository = Depends(get_consent_document_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a single consent document version by ID.
+    """
+    document = repo.get(documentId)
+    if not document:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Consent document not found")
+    return document
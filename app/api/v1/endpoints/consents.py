@@ -0,0 +1,145 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, status
+from typing import List, Dict, Optional
+from datetime import datetime, timezone
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_consent_document_repository, get_consent_repository, get_event_publisher, get_patient_repository
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.repositories.base import NotFoundError
+from app.repositories.consents import ConsentDocumentRepository, ConsentRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.transactions import transactional
+from app.services.consents import check_consent
+
+router = APIRouter()
+
+def _ensure_patient_exists(patient_id: str, patients: PatientRepository):
+    if not patients.get(patient_id):
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+
+
+def _get_or_404(patient_id: str, consent_id: str, repo: ConsentRepository) -> schemas.Consent:
+    consent = repo.get(consent_id)
+    if not consent or consent.patient_id != patient_id:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Consent not found")
+    return consent
+
+
+@router.post("/{patientId}/consents", response_model=schemas.Consent, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def grant_consent(
+    patientId: str,
+    grant: schemas.ConsentGrant,
+    repo: ConsentRepository = Depends(get_consent_repository),
+    documents: ConsentDocumentRepository = Depends(get_consent_document_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Record a patient's consent to the latest consent document of a scope.
+    An active consent to an earlier version of the same scope (and
+    organisation) is superseded by the new one.
+    """
+    _ensure_patient_exists(patientId, patients)
+    document = documents.get(grant.document_id)
+    if not document or document.scope != grant.scope:
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail=f"Unknown consent document for scope '{grant.scope}'")
+    latest = documents.list(grant.scope)[0]
+    if document.version != latest.version:
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail=f"Consent must be given to the latest document version ({latest.version}), not version {document.version}"
+        )
+
+    active = repo.list(patientId, scope=grant.scope, organization_id=grant.organization_id, status="active")
+    if any(c.document_version == document.version for c in active):
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="The patient has already consented to this document")
+    for previous in active:
+        repo.update(previous.consent_id, {"status": "superseded"})
+
+    consent = repo.create(patientId, grant, document, granted_by=current_user["uid"])
+    events.emit("consent.granted", f"patients/{patientId}/consents/{consent.consent_id}", consent)
+    logging.info(f"User {current_user['uid']} recorded {grant.scope} consent {consent.consent_id} (v{document.version}) for patient {patientId}")
+    return consent
+
+
+@router.get("/{patientId}/consents", response_model=List[schemas.Consent], response_model_by_alias=False)
+def list_consents(
+    patientId: str,
+    scope: Optional[schemas.ConsentScope] = None,
+    status_filter: Optional[schemas.ConsentStatus] = Query(None, alias="status"),
+    repo: ConsentRepository = Depends(get_consent_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a patient's consent history, newest first.
+    """
+    _ensure_patient_exists(patientId, patients)
+    return repo.list(patientId, scope=scope, status=status_filter)
+
+
+@router.get("/{patientId}/consents/check", response_model=schemas.ConsentDecision, response_model_by_alias=False)
+def check_patient_consent(
+    patientId: str,
+    scope: schemas.ConsentScope,
+    organizationId: Optional[str] = None,
+    repo: ConsentRepository = Depends(get_consent_repository),
+    documents: ConsentDocumentRepository = Depends(get_consent_document_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Check whether the patient currently consents to a use of their data.
+    Data-sharing checks must name the receiving organisation.
+    """
+    if scope == "data-sharing" and not organizationId:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="organizationId is required for data-sharing checks")
+    _ensure_patient_exists(patientId, patients)
+    return check_consent(repo, documents, patientId, scope, organization_id=organizationId)
+
+
+@router.get("/{patientId}/consents/{consentId}", response_model=schemas.Consent, response_model_by_alias=False)
+def get_consent(
+    patientId: str,
+    consentId: str,
+    repo: ConsentRepository = Depends(get_consent_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a single consent of a patient by ID.
+    """
+    return _get_or_404(patientId, consentId, repo)
+
+
+@router.post("/{patientId}/consents/{consentId}/revoke", response_model=schemas.Consent, response_model_by_alias=False)
+@transactional
+def revoke_consent(
+    patientId: str,
+    consentId: str,
+    revoke_in: schemas.ConsentRevoke,
+    repo: ConsentRepository = Depends(get_consent_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Revoke an active consent. The record is kept for the audit trail.
+    """
+    consent = _get_or_404(patientId, consentId, repo)
+    if consent.status != "active":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=f"Cannot revoke a consent with status '{consent.status}'.")
+    try:
+        updated = repo.update(consentId, {
+            "status": "revoked",
+            "revokedAt": datetime.now(timezone.utc),
+            "revokedBy": current_user["uid"],
+            "revocationReason": revoke_in.reason,
+        })
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Consent not found")
+    events.emit("consent.revoked", f"patients/{patientId}/consents/{consentId}", updated)
+    logging.info(f"User {current_user['uid']} revoked {consent.scope} consent {consentId} for patient {patientId}")
+    return updated
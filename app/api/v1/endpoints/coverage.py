@@ -0,0 +1,204 @@
+from fastapi import APIRouter, Depends, HTTPException, Query, Request, Response, status
+from datetime import date
+from typing import Dict, List, Optional
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import (
+    get_coverage_repository,
+    get_eligibility_check_repository,
+    get_event_publisher,
+    get_patient_repository,
+    get_task_queue,
+)
+from app.core.config import get_settings
+from app.dependencies.auth import get_current_user
+from app.eligibility.checks import reusable_check
+from app.eligibility.clearinghouse import get_clearinghouse
+from app.events.publisher import EventPublisher
+from app.repositories.base import NotFoundError
+from app.repositories.coverage import CoverageRepository, EligibilityCheckRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.transactions import transactional
+from app.tasks.jobs import ELIGIBILITY_CHECK_TASK
+from app.tasks.queue import TaskQueue
+
+router = APIRouter()
+
+
+def _patient_or_404(patient_id: str, patients: PatientRepository) -> schemas.Patient:
+    patient = patients.get(patient_id)
+    if not patient:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+    return patient
+
+
+def _get_or_404(patient_id: str, coverage_id: str, repo: CoverageRepository) -> schemas.Coverage:
+    coverage = repo.get(coverage_id)
+    if not coverage or coverage.patient_id != patient_id:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Coverage not found")
+    return coverage
+
+
+@router.post("/{patientId}/coverage", response_model=schemas.Coverage, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_coverage(
+    patientId: str,
+    coverage_in: schemas.CoverageCreate,
+    repo: CoverageRepository = Depends(get_coverage_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Record a health plan covering the patient, as on their insurance card.
+    `payerId` is the payer's ID at the clearinghouse, which eligibility
+    checks are sent to.
+    """
+    _patient_or_404(patientId, patients)
+    coverage = repo.create(patientId, coverage_in, recorded_by=current_user["uid"])
+    events.emit("coverage.recorded", f"patients/{patientId}/coverage/{coverage.coverage_id}", coverage)
+    logging.info(f"User {current_user['uid']} recorded coverage {coverage.coverage_id} ({coverage.payer_id}) for patient {patientId}")
+    return coverage
+
+
+@router.get("/{patientId}/coverage", response_model=List[schemas.Coverage], response_model_by_alias=False)
+def list_coverage(
+    patientId: str,
+    coverage_status: Optional[schemas.CoverageStatus] = Query(None, alias="status"),
+    repo: CoverageRepository = Depends(get_coverage_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve the patient's health plans, primary first, optionally only
+    those with a status.
+    """
+    _patient_or_404(patientId, patients)
+    return repo.list(patientId, status=coverage_status)
+
+
+@router.get("/{patientId}/coverage/{coverageId}", response_model=schemas.Coverage, response_model_by_alias=False)
+def get_coverage(
+    patientId: str,
+    coverageId: str,
+    repo: CoverageRepository = Depends(get_coverage_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a single health plan of a patient by ID.
+    """
+    return _get_or_404(patientId, coverageId, repo)
+
+
+@router.patch("/{patientId}/coverage/{coverageId}", response_model=schemas.Coverage, response_model_by_alias=False)
+@transactional
+def update_coverage(
+    patientId: str,
+    coverageId: str,
+    coverage_in: schemas.CoverageUpdate,
+    repo: CoverageRepository = Depends(get_coverage_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Update a health plan, e.g. to correct the member ID the payer did not
+    recognize, or mark it inactive when it ends. Earlier eligibility
+    answers are not reused after a change.
+    """
+    coverage = _get_or_404(patientId, coverageId, repo)
+    relationship = coverage_in.relationship or coverage.relationship
+    if relationship != "self" and not (coverage_in.subscriber or coverage.subscriber):
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="'subscriber' is required unless the patient is the policyholder")
+    try:
+        coverage = repo.update(coverageId, coverage_in)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Coverage not found")
+    events.emit("coverage.updated", f"patients/{patientId}/coverage/{coverageId}", coverage)
+    logging.info(f"User {current_user['uid']} updated coverage {coverageId} for patient {patientId}")
+    return coverage
+
+
+@router.post(
+    "/{patientId}/coverage/{coverageId}/eligibility",
+    response_model=schemas.EligibilityCheck,
+    status_code=status.HTTP_202_ACCEPTED,
+    response_model_by_alias=False,
+    responses={200: {"description": "An earlier answer was reused.", "model": schemas.EligibilityCheck}},
+)
+def check_eligibility(
+    patientId: str,
+    coverageId: str,
+    check_in: schemas.EligibilityCheckCreate,
+    request: Request,
+    response: Response,
+    repo: CoverageRepository = Depends(get_coverage_repository),
+    checks: EligibilityCheckRepository = Depends(get_eligibility_check_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    tasks: TaskQueue = Depends(get_task_queue),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Verify with the payer that the plan covers the patient for a visit, and
+    what they will owe: copays, coinsurance and the deductible and
+    out-of-pocket amounts left, per service type. Payers can take a while to
+    answer, so the check runs in the background: poll it at
+    `Content-Location` until it is completed. An answer to the same
+    question within ELIGIBILITY_CACHE_SECONDS is returned at once (200,
+    `cached`) unless `refresh` is set.
+    """
+    if get_clearinghouse() is None:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="Eligibility checks are not configured (ELIGIBILITY_PROVIDER)")
+    patient = _patient_or_404(patientId, patients)
+    coverage = _get_or_404(patientId, coverageId, repo)
+    if coverage.status != "active":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="The coverage is inactive")
+    service_date = check_in.service_date or date.today()
+    if not check_in.refresh:
+        cached = reusable_check(checks, coverage, patient, service_date, check_in.service_types, get_settings().eligibility_cache_seconds)
+        if cached:
+            response.status_code = status.HTTP_200_OK
+            response.headers["Content-Location"] = f"{request.url.path.rstrip('/')}/{cached.check_id}"
+            return cached.model_copy(update={"cached": True})
+
+    check = checks.create(coverage, check_in, service_date, requested_by=current_user["uid"])
+    # Runs on Cloud Tasks, or in this worker after the 202 has been sent when no queue is configured.
+    tasks.enqueue(ELIGIBILITY_CHECK_TASK, {"checkId": check.check_id}, task_id=f"eligibility-check-{check.check_id}")
+    logging.info(f"User {current_user['uid']} started eligibility check {check.check_id} of coverage {coverageId} for patient {patientId}")
+    response.headers["Content-Location"] = f"{request.url.path.rstrip('/')}/{check.check_id}"
+    return check
+
+
+@router.get("/{patientId}/coverage/{coverageId}/eligibility", response_model=List[schemas.EligibilityCheck], response_model_by_alias=False)
+def list_eligibility_checks(
+    patientId: str,
+    coverageId: str,
+    limit: int = Query(20, ge=1, le=100),
+    repo: CoverageRepository = Depends(get_coverage_repository),
+    checks: EligibilityCheckRepository = Depends(get_eligibility_check_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve the coverage's eligibility checks, most recent first.
+    """
+    _get_or_404(patientId, coverageId, repo)
+    return checks.list(coverageId, limit=limit)
+
+
+@router.get("/{patientId}/coverage/{coverageId}/eligibility/{checkId}", response_model=schemas.EligibilityCheck, response_model_by_alias=False)
+def get_eligibility_check(
+    patientId: str,
+    coverageId: str,
+    checkId: str,
+    checks: EligibilityCheckRepository = Depends(get_eligibility_check_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve an eligibility check. Once completed it carries the payer's
+    answer: whether the patient is covered and the benefit summaries, or
+    the payer's `rejections`, e.g. a member ID it does not know.
+    """
+    check = checks.get(checkId)
+    if not check or check.patient_id != patientId or check.coverage_id != coverageId:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Eligibility check not found")
+    return check
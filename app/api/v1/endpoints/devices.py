@@ -0,0 +1,84 @@
+from fastapi import APIRouter, Depends, HTTPException, status
+from datetime import datetime, timezone
+from typing import Dict
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_device_repository
+from app.core.config import get_settings
+from app.core.pubsub import publish_json
+from app.dependencies.auth import get_current_user
+from app.repositories.devices import DeviceRepository
+
+router = APIRouter()
+
+# --- Configuration ---
+TELEMETRY_TOPIC = get_settings().telemetry_topic
+
+# Version of the published message body; bump when its shape changes.
+TELEMETRY_SCHEMA_VERSION = "1"
+
+
+@router.post("/{deviceId}/telemetry", response_model=schemas.TelemetryBatchResult, status_code=status.HTTP_202_ACCEPTED, response_model_by_alias=False)
+def ingest_telemetry(
+    deviceId: str,
+    batch: schemas.TelemetryBatch,
+    repo: DeviceRepository = Depends(get_device_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Accept a batch of readings from a home-care device registered to the
+    authenticated user and publish it to Pub/Sub for downstream processing.
+
+    Readings whose sequence number is not above the highest one already
+    accepted for the device are treated as resends and dropped, so a device
+    can safely retry a whole batch. Delivery downstream is at-least-once:
+    the high-water mark only moves after Pub/Sub has accepted the batch.
+    """
+    if not TELEMETRY_TOPIC:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="Telemetry ingestion is not configured")
+
+    user_uid = current_user["uid"]
+    device = repo.get_for_owner(user_uid, deviceId)
+    if not device:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Device not registered to this user")
+    if device.status != "Active":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=f"Device is '{device.status}' and cannot send telemetry")
+
+    last_sequence = repo.get_telemetry_sequence(user_uid, deviceId)
+    fresh: Dict[int, schemas.TelemetryReading] = {}
+    for reading in sorted(batch.readings, key=lambda r: r.sequence):
+        if (last_sequence is None or reading.sequence > last_sequence) and reading.sequence not in fresh:
+            fresh[reading.sequence] = reading
+    duplicates = len(batch.readings) - len(fresh)
+
+    if not fresh:
+        return schemas.TelemetryBatchResult(accepted=0, duplicates=duplicates, last_sequence=last_sequence)
+
+    readings = list(fresh.values())
+    payload = {
+        "schemaVersion": TELEMETRY_SCHEMA_VERSION,
+        "deviceId": deviceId,
+        "ownerUid": user_uid,
+        "deviceName": device.device_name,
+        "serialNumber": device.serial_number,
+        "receivedAt": datetime.now(timezone.utc).isoformat(),
+        "readings": [r.model_dump(by_alias=True, mode="json") for r in readings],
+    }
+    try:
+        message_id = publish_json(
+            TELEMETRY_TOPIC, payload,
+            deviceId=deviceId, schemaVersion=TELEMETRY_SCHEMA_VERSION,
+        )
+    except Exception as e:
+        logging.error(f"Failed to publish telemetry for device {deviceId}: {e}")
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="Telemetry could not be queued; retry the batch")
+
+    new_last_sequence = repo.advance_telemetry_sequence(user_uid, deviceId, readings[-1].sequence)
+    logging.info(f"Published {len(readings)} telemetry readings for device {deviceId} as message {message_id} ({duplicates} duplicates dropped)")
+    return schemas.TelemetryBatchResult(
+        accepted=len(readings),
+        duplicates=duplicates,
+        last_sequence=new_last_sequence,
+        message_id=message_id,
+    )
@@ -0,0 +1,289 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, status
+from typing import List, Dict, Optional
+from datetime import datetime, timezone
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import (
+    get_appointment_repository,
+    get_encounter_repository,
+    get_event_publisher,
+    get_observation_repository,
+    get_patient_repository,
+    get_practitioner_repository,
+    get_task_queue,
+)
+from app.audit.context import annotate
+from app.core.config import get_settings
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.appointments import AppointmentRepository
+from app.repositories.base import NotFoundError
+from app.repositories.encounters import EncounterRepository
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.practitioners import PractitionerRepository
+from app.repositories.transactions import transactional
+from app.services.encounters import ENCOUNTER_STATUSES
+from app.services.state_machine import InvalidTransitionError
+from app.tasks.jobs import DISCHARGE_SUMMARY_TASK
+from app.tasks.queue import TaskQueue
+
+router = APIRouter()
+
+# A discharge summary is generated for finished encounters when a bucket is configured.
+ENCOUNTER_DOCUMENTS_BUCKET = get_settings().encounter_documents_bucket
+
+def _get_or_404(encounter_id: str, repo: EncounterRepository) -> schemas.Encounter:
+    encounter = repo.get(encounter_id)
+    if not encounter:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Encounter not found")
+    annotate(patient_id=encounter.patient_id)
+    return encounter
+
+
+def _get_linkable_or_404(encounter_id: str, repo: EncounterRepository) -> schemas.Encounter:
+    encounter = _get_or_404(encounter_id, repo)
+    if encounter.status == "cancelled":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="Cannot link records to a cancelled encounter")
+    return encounter
+
+
+def _ensure_participants_exist(participants: List[schemas.EncounterParticipant], practitioners: PractitionerRepository):
+    ids = [p.practitioner_id for p in participants]
+    if len(set(ids)) != len(ids):
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="A practitioner can only participate once in an encounter")
+    missing = [pid for pid in ids if not practitioners.get(pid)]
+    if missing:
+        raise HTTPException(
+            status_code=status.HTTP_422_UNPROCESSABLE_ENTITY,
+            detail=f"Unknown practitioner(s): {', '.join(missing)}"
+        )
+
+
+@router.post("", response_model=schemas.Encounter, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_encounter(
+    *,
+    encounter_in: schemas.EncounterCreate,
+    repo: EncounterRepository = Depends(get_encounter_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    practitioners: PractitionerRepository = Depends(get_practitioner_repository),
+    appointments: AppointmentRepository = Depends(get_appointment_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Open an encounter for a patient visit. The patient and participating
+    practitioners must exist; a referenced appointment must be the patient's.
+    """
+    if not patients.get(encounter_in.patient_id):
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown patient")
+    _ensure_participants_exist(encounter_in.participants, practitioners)
+    if encounter_in.appointment_id:
+        appointment = appointments.get(encounter_in.appointment_id)
+        if not appointment or appointment.patient_id != encounter_in.patient_id:
+            raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown appointment for this patient")
+
+    encounter = repo.create(encounter_in)
+    events.emit("encounter.opened", f"encounters/{encounter.encounter_id}", encounter)
+    logging.info(f"User {current_user['uid']} opened encounter {encounter.encounter_id} for patient {encounter.patient_id}")
+    return encounter
+
+
+@router.get("", response_model=Page[schemas.Encounter], response_model_by_alias=False)
+def list_encounters(
+    patientId: Optional[str] = None,
+    practitionerId: Optional[str] = None,
+    start_from: Optional[datetime] = Query(None, alias="from", description="Only encounters starting at or after this time."),
+    start_to: Optional[datetime] = Query(None, alias="to", description="Only encounters starting before this time."),
+    page: PageRequest = Depends(pagination(default_limit=30, max_limit=100)),
+    repo: EncounterRepository = Depends(get_encounter_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve encounters for a patient and/or those a practitioner took part
+    in, newest first. At least one of patientId or practitionerId is required.
+    """
+    if not patientId and not practitionerId:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="Provide a patientId or practitionerId filter")
+    if start_from and start_to and start_from >= start_to:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="'from' must be before 'to'")
+    return paginate(
+        page,
+        lambda after, limit: repo.list(patient_id=patientId, practitioner_id=practitionerId, start_from=start_from, start_to=start_to, limit=limit, after=after),
+        lambda encounter: encounter.encounter_id,
+    )
+
+
+@router.get("/{encounterId}", response_model=schemas.Encounter, response_model_by_alias=False)
+def get_encounter(
+    encounterId: str,
+    repo: EncounterRepository = Depends(get_encounter_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a single encounter by ID, including its linked observation IDs
+    and documents.
+    """
+    return _get_or_404(encounterId, repo)
+
+
+@router.patch("/{encounterId}", response_model=schemas.Encounter, response_model_by_alias=False)
+@transactional
+def update_encounter(
+    encounterId: str,
+    encounter_in: schemas.EncounterUpdate,
+    repo: EncounterRepository = Depends(get_encounter_repository),
+    practitioners: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Update an encounter's details or replace its participants.
+    """
+    encounter = _get_or_404(encounterId, repo)
+    start = encounter_in.start or encounter.start
+    end = encounter_in.end or encounter.end
+    if end and end <= start:
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="end must be after start")
+    if encounter_in.participants is not None:
+        _ensure_participants_exist(encounter_in.participants, practitioners)
+
+    try:
+        updated = repo.update(encounterId, encounter_in.model_dump(by_alias=True, exclude_unset=True))
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Encounter not found")
+    events.emit("encounter.updated", f"encounters/{encounterId}", updated)
+    return updated
+
+
+@router.post("/{encounterId}/status", response_model=schemas.Encounter, response_model_by_alias=False)
+@transactional
+def update_encounter_status(
+    encounterId: str,
+    status_in: schemas.EncounterStatusUpdate,
+    repo: EncounterRepository = Depends(get_encounter_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    tasks: TaskQueue = Depends(get_task_queue),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Move an encounter through its lifecycle: planned -> in-progress ->
+    finished, or to cancelled from either open state. Finishing an encounter
+    without an end time closes its period now, and queues its discharge summary.
+    """
+    encounter = _get_or_404(encounterId, repo)
+    try:
+        ENCOUNTER_STATUSES.ensure(encounter.status, status_in.status)
+    except InvalidTransitionError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+
+    changes = {"status": status_in.status}
+    if status_in.status == "finished" and not encounter.end:
+        changes["end"] = datetime.now(timezone.utc)
+    updated = repo.update(encounterId, changes)
+    events.emit("encounter.status_changed", f"encounters/{encounterId}", updated)
+    if status_in.status == "finished" and ENCOUNTER_DOCUMENTS_BUCKET:
+        tasks.enqueue(DISCHARGE_SUMMARY_TASK, {"encounterId": encounterId}, task_id=f"discharge-summary-{encounterId}")
+    logging.info(f"User {current_user['uid']} changed encounter {encounterId} status from {encounter.status} to {status_in.status}")
+    return updated
+
+
+# --- Linked Observations ---
+
+@router.get("/{encounterId}/observations", response_model=List[schemas.Observation], response_model_by_alias=False)
+def list_encounter_observations(
+    encounterId: str,
+    repo: EncounterRepository = Depends(get_encounter_repository),
+    observations: ObservationRepository = Depends(get_observation_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve the observations recorded during an encounter. Observations that
+    have since been deleted are skipped.
+    """
+    encounter = _get_or_404(encounterId, repo)
+    linked = (observations.get(observation_id) for observation_id in encounter.observation_ids)
+    return [observation for observation in linked if observation]
+
+
+@router.post("/{encounterId}/observations", response_model=schemas.Encounter, response_model_by_alias=False)
+def link_encounter_observation(
+    encounterId: str,
+    link_in: schemas.EncounterObservationLink,
+    repo: EncounterRepository = Depends(get_encounter_repository),
+    observations: ObservationRepository = Depends(get_observation_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Link an observation of the same patient to an encounter. Linking an
+    observation that is already linked has no effect.
+    """
+    encounter = _get_linkable_or_404(encounterId, repo)
+    observation = observations.get(link_in.observation_id)
+    if not observation or observation.patient_id != encounter.patient_id:
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown observation for this patient")
+
+    updated = repo.link_observation(encounterId, link_in.observation_id)
+    logging.info(f"User {current_user['uid']} linked observation {link_in.observation_id} to encounter {encounterId}")
+    return updated
+
+
+@router.delete("/{encounterId}/observations/{observationId}", response_model=schemas.Encounter, response_model_by_alias=False)
+def unlink_encounter_observation(
+    encounterId: str,
+    observationId: str,
+    repo: EncounterRepository = Depends(get_encounter_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Unlink an observation from an encounter. The observation itself is kept.
+    """
+    try:
+        encounter = repo.unlink_observation(encounterId, observationId)
+    except NotFoundError as e:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail=str(e))
+    logging.info(f"User {current_user['uid']} unlinked observation {observationId} from encounter {encounterId}")
+    return encounter
+
+
+# --- Linked Documents ---
+
+@router.post("/{encounterId}/documents", response_model=schemas.Encounter, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+def add_encounter_document(
+    encounterId: str,
+    document_in: schemas.EncounterDocument,
+    repo: EncounterRepository = Depends(get_encounter_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Attach a reference to a document produced during the visit.
+    """
+    _get_linkable_or_404(encounterId, repo)
+    encounter = repo.add_document(encounterId, document_in, added_by=current_user["uid"])
+    events.emit("encounter.updated", f"encounters/{encounterId}", encounter)
+    logging.info(f"User {current_user['uid']} attached a document to encounter {encounterId}")
+    return encounter
+
+
+@router.delete("/{encounterId}/documents/{documentId}", response_model=schemas.Encounter, response_model_by_alias=False)
+def remove_encounter_document(
+    encounterId: str,
+    documentId: str,
+    repo: EncounterRepository = Depends(get_encounter_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Detach a document reference from an encounter.
+    """
+    try:
+        encounter = repo.remove_document(encounterId, documentId)
+    except NotFoundError as e:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail=str(e))
+    events.emit("encounter.updated", f"encounters/{encounterId}", encounter)
+    logging.info(f"User {current_user['uid']} detached document {documentId} from encounter {encounterId}")
+    return encounter
@@ -0,0 +1,71 @@
+from fastapi import APIRouter, Depends, Header, HTTPException, Query, Request, status
+from fastapi.responses import StreamingResponse
+from typing import Dict, Optional
+from datetime import datetime, timedelta, timezone
+import logging
+
+from app.api.v1.deps import get_stream_event_repository
+from app.authz.roles import PATIENT_ID_CLAIM, grants
+from app.core.config import get_settings
+from app.dependencies.auth import get_current_user
+from app.events.stream import EventStream, parse_event_id, sse_messages
+from app.repositories.stream_events import StreamEventRepository
+from app.tenancy.context import current_tenant
+
+router = APIRouter()
+
+# --- Configuration ---
+settings = get_settings()
+
+
+@router.get(
+    "/stream",
+    response_class=StreamingResponse,
+    responses={200: {"content": {"text/event-stream": {}}, "description": "The event stream."}},
+)
+async def stream_events(
+    request: Request,
+    types: Optional[str] = Query(None, description="Comma-separated event types to receive, e.g. appointment.rescheduled,lab_result.recorded; all by default."),
+    last_event_id: Optional[str] = Header(None, alias="Last-Event-ID"),
+    repo: StreamEventRepository = Depends(get_stream_event_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Stream changes to the resources the caller may read, as server-sent
+    events, so a client can update what it shows without polling. Each
+    event is named by its type (`appointment.rescheduled`,
+    `lab_result.recorded`, ...) and its data gives the event's `id`,
+    `type`, `subject` (the resource path under /api/v1, to read it again),
+    `patientId` and `occurredAt`; the resource itself is not sent. Patients
+    receive the events of their own records. The response ends after
+    EVENT_STREAM_MAX_SECONDS; clients reconnect with the Last-Event-ID
+    they last received (EventSource does so itself) and carry on from
+    there, back to EVENT_STREAM_RETENTION_HOURS. Events may repeat across a
+    reconnect, so deduplicate on `id`.
+    """
+    if not settings.event_stream_enabled:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="The event stream is not enabled (EVENT_STREAM_ENABLED)")
+    full, own = grants(current_user)
+    if not full and not (own and current_user.get(PATIENT_ID_CLAIM)):
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="You do not have permission to access this resource")
+    after = None
+    if last_event_id:
+        try:
+            after = parse_event_id(last_event_id)
+        except ValueError as e:
+            raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail=str(e))
+    expired = after is not None and after[0] < datetime.now(timezone.utc) - timedelta(hours=settings.event_stream_retention_hours)
+
+    wanted = frozenset(t.strip() for t in types.split(",") if t.strip()) if types else None
+    stream = EventStream(repo, current_user, current_tenant(), types=wanted, after=None if expired else after)
+    logging.info(f"User {current_user['uid']} opened the event stream{f' after {last_event_id}' if last_event_id else ''}")
+    messages = sse_messages(
+        stream,
+        request.is_disconnected,
+        poll_seconds=settings.event_stream_poll_seconds,
+        heartbeat_seconds=settings.event_stream_heartbeat_seconds,
+        max_seconds=settings.event_stream_max_seconds,
+        reset=expired,
+    )
+    # X-Accel-Buffering stops nginx-style proxies holding events back.
+    return StreamingResponse(messages, media_type="text/event-stream", headers={"Cache-Control": "no-cache", "X-Accel-Buffering": "no"})
@@ -0,0 +1,93 @@
+from fastapi import APIRouter, Depends, HTTPException, Response, status
+from typing import Dict, List
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_feature_flag_repository
+from app.dependencies.auth import require_roles
+from app.flags.flags import get_feature_flags
+from app.repositories.base import ConflictError, NotFoundError
+from app.repositories.feature_flags import FeatureFlagRepository
+from app.tenancy.context import PLATFORM_ADMIN
+
+router = APIRouter()
+
+# Flags apply to every organization, so only platform administrators manage them.
+FLAG_ADMIN_ROLES = (PLATFORM_ADMIN,)
+
+
+@router.post("", response_model=schemas.FeatureFlag, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+def create_feature_flag(
+    *,
+    flag_in: schemas.FeatureFlagCreate,
+    repo: FeatureFlagRepository = Depends(get_feature_flag_repository),
+    current_user: Dict = Depends(require_roles(*FLAG_ADMIN_ROLES))
+):
+    """
+    Store a flag. A flag with the key of one set in FEATURE_FLAGS overrides it.
+    Other workers pick it up within FEATURE_FLAG_CACHE_SECONDS.
+    """
+    try:
+        flag = repo.create(flag_in, current_user["uid"])
+    except ConflictError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    get_feature_flags().invalidate()
+    logging.info(f"User {current_user['uid']} created feature flag {flag.key} (enabled={flag.enabled}, percentage={flag.percentage})")
+    return flag
+
+
+@router.get("", response_model=List[schemas.FeatureFlag], response_model_by_alias=False)
+def list_feature_flags(current_user: Dict = Depends(require_roles(*FLAG_ADMIN_ROLES))):
+    """
+    Retrieve every flag in effect, by key, whether stored or set in FEATURE_FLAGS.
+    """
+    return get_feature_flags().all()
+
+
+@router.get("/{key}", response_model=schemas.FeatureFlag, response_model_by_alias=False)
+def get_feature_flag(key: str, current_user: Dict = Depends(require_roles(*FLAG_ADMIN_ROLES))):
+    """
+    Retrieve a flag by key.
+    """
+    flag = get_feature_flags().get(key)
+    if not flag:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Feature flag not found")
+    return flag
+
+
+@router.patch("/{key}", response_model=schemas.FeatureFlag, response_model_by_alias=False)
+def update_feature_flag(
+    key: str,
+    flag_in: schemas.FeatureFlagUpdate,
+    repo: FeatureFlagRepository = Depends(get_feature_flag_repository),
+    current_user: Dict = Depends(require_roles(*FLAG_ADMIN_ROLES))
+):
+    """
+    Change a stored flag, e.g. to raise its percentage or switch it off.
+    Flags set only in FEATURE_FLAGS are overridden by creating them here.
+    """
+    try:
+        flag = repo.update(key, flag_in, current_user["uid"])
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Feature flag not found")
+    get_feature_flags().invalidate()
+    logging.info(f"User {current_user['uid']} updated feature flag {key} (enabled={flag.enabled}, percentage={flag.percentage})")
+    return flag
+
+
+@router.delete("/{key}", status_code=status.HTTP_204_NO_CONTENT)
+def delete_feature_flag(
+    key: str,
+    repo: FeatureFlagRepository = Depends(get_feature_flag_repository),
+    current_user: Dict = Depends(require_roles(*FLAG_ADMIN_ROLES))
+):
+    """
+    Remove a stored flag. A flag also set in FEATURE_FLAGS reverts to that setting.
+    """
+    try:
+        repo.delete(key)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Feature flag not found")
+    get_feature_flags().invalidate()
+    logging.info(f"User {current_user['uid']} deleted feature flag {key}")
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
@@ -0,0 +1,141 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, status
+from typing import Dict, Optional
+from datetime import date, datetime, timezone
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_event_publisher, get_immunization_repository, get_patient_repository, get_practitioner_repository
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.base import NotFoundError
+from app.repositories.immunizations import ImmunizationRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.practitioners import PractitionerRepository
+from app.repositories.transactions import transactional
+from app.services.immunizations import forecast, get_schedule
+
+router = APIRouter()
+
+# Upper bound on the doses read to compute a forecast; far above any real history.
+FORECAST_HISTORY_LIMIT = 500
+
+def _get_patient_or_404(patient_id: str, patients: PatientRepository) -> schemas.Patient:
+    patient = patients.get(patient_id)
+    if not patient:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+    return patient
+
+
+def _get_or_404(patient_id: str, immunization_id: str, repo: ImmunizationRepository) -> schemas.Immunization:
+    immunization = repo.get(immunization_id)
+    if not immunization or immunization.patient_id != patient_id:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Immunization not found")
+    return immunization
+
+
+@router.post("/{patientId}/immunizations", response_model=schemas.Immunization, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_immunization(
+    patientId: str,
+    immunization_in: schemas.ImmunizationCreate,
+    repo: ImmunizationRepository = Depends(get_immunization_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    practitioners: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Record a vaccine dose given to (or declined by) a patient. The
+    administration date must fall between the patient's birth and today.
+    """
+    patient = _get_patient_or_404(patientId, patients)
+    today = datetime.now(timezone.utc).date()
+    if not patient.dob <= immunization_in.occurrence_date <= today:
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="occurrenceDate must be between the patient's date of birth and today")
+    if immunization_in.performer_id and not practitioners.get(immunization_in.performer_id):
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown performer")
+
+    immunization = repo.create(patientId, immunization_in, recorded_by=current_user["uid"])
+    events.emit("immunization.recorded", f"patients/{patientId}/immunizations/{immunization.immunization_id}", immunization)
+    logging.info(f"User {current_user['uid']} recorded immunization {immunization.immunization_id} (CVX {immunization.vaccine_code.code}) for patient {patientId}")
+    return immunization
+
+
+@router.get("/{patientId}/immunizations", response_model=Page[schemas.Immunization], response_model_by_alias=False)
+def list_immunizations(
+    patientId: str,
+    vaccineCode: Optional[str] = Query(None, description="Only doses with this CVX code."),
+    page: PageRequest = Depends(pagination(default_limit=100, max_limit=500)),
+    repo: ImmunizationRepository = Depends(get_immunization_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a patient's immunization history, most recent dose first.
+    """
+    _get_patient_or_404(patientId, patients)
+    return paginate(
+        page,
+        lambda after, limit: repo.list(patientId, vaccine_code=vaccineCode, limit=limit, after=after),
+        lambda immunization: immunization.immunization_id,
+    )
+
+
+@router.get("/{patientId}/immunizations/forecast", response_model=schemas.ImmunizationForecast, response_model_by_alias=False)
+def get_immunization_forecast(
+    patientId: str,
+    asOf: Optional[date] = Query(None, description="Date to forecast for; today if omitted."),
+    repo: ImmunizationRepository = Depends(get_immunization_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Compute which doses of the configured immunization schedule are upcoming,
+    due or overdue for a patient, based on their age and recorded doses.
+    """
+    patient = _get_patient_or_404(patientId, patients)
+    as_of = asOf or datetime.now(timezone.utc).date()
+    history = repo.list(patientId, limit=FORECAST_HISTORY_LIMIT)
+    return schemas.ImmunizationForecast(
+        patient_id=patientId,
+        as_of=as_of,
+        recommendations=forecast(get_schedule(), patient.dob, history, as_of),
+    )
+
+
+@router.get("/{patientId}/immunizations/{immunizationId}", response_model=schemas.Immunization, response_model_by_alias=False)
+def get_immunization(
+    patientId: str,
+    immunizationId: str,
+    repo: ImmunizationRepository = Depends(get_immunization_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a single immunization record of a patient by ID.
+    """
+    return _get_or_404(patientId, immunizationId, repo)
+
+
+@router.patch("/{patientId}/immunizations/{immunizationId}", response_model=schemas.Immunization, response_model_by_alias=False)
+@transactional
+def update_immunization(
+    patientId: str,
+    immunizationId: str,
+    immunization_in: schemas.ImmunizationUpdate,
+    repo: ImmunizationRepository = Depends(get_immunization_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Correct an immunization record, e.g. its lot number or series position.
+    Doses recorded by mistake are marked 'entered-in-error' rather than deleted.
+    """
+    _get_or_404(patientId, immunizationId, repo)
+    try:
+        immunization = repo.update(immunizationId, immunization_in)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Immunization not found")
+    events.emit("immunization.updated", f"patients/{patientId}/immunizations/{immunizationId}", immunization)
+    logging.info(f"User {current_user['uid']} updated immunization {immunizationId} for patient {patientId}")
+    return immunization
@@ -0,0 +1,165 @@
+from fastapi import APIRouter, Depends, Header, HTTPException, Request, Response, status
+from fastapi.responses import StreamingResponse
+from starlette.concurrency import run_in_threadpool
+from typing import Dict, Iterator, Optional
+import logging
+import uuid
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_import_job_repository, get_task_queue
+from app.core.config import get_settings
+from app.core.storage import delete_blob, get_storage_client
+from app.dependencies.auth import get_current_user
+from app.repositories.import_jobs import ImportJobRepository
+from app.services.bulk_import import NDJSON_CONTENT_TYPES, error_blob_prefix, input_blob_name
+from app.tasks.jobs import BULK_IMPORT_TASK, bulk_import_task_id
+from app.tasks.queue import TaskQueue
+
+router = APIRouter()
+
+# --- Configuration ---
+settings = get_settings()
+IMPORT_BUCKET = settings.import_bucket
+IMPORT_MAX_BYTES = settings.import_max_bytes
+
+
+def _require_bucket() -> str:
+    if not IMPORT_BUCKET:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="Bulk import is not configured (IMPORT_BUCKET)")
+    return IMPORT_BUCKET
+
+
+def _get_job_or_404(job_id: str, jobs: ImportJobRepository) -> schemas.ImportJob:
+    job = jobs.get(job_id)
+    if not job:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Import job not found")
+    return job
+
+
+def _too_large() -> HTTPException:
+    return HTTPException(status_code=status.HTTP_413_REQUEST_ENTITY_TOO_LARGE, detail=f"Import files can be at most {IMPORT_MAX_BYTES} bytes")
+
+
+async def _store_upload(request: Request, bucket_name: str, blob_name: str) -> int:
+    """Streams the request body to the bucket without holding it in memory. Returns its size."""
+    blob = get_storage_client().bucket(bucket_name).blob(blob_name)
+    writer = await run_in_threadpool(blob.open, "wb", content_type="application/x-ndjson")
+    size = 0
+    try:
+        async for chunk in request.stream():
+            size += len(chunk)
+            if size > IMPORT_MAX_BYTES:
+                raise _too_large()
+            if chunk:
+                await run_in_threadpool(writer.write, chunk)
+    except BaseException:
+        await run_in_threadpool(delete_blob, bucket_name, blob_name)
+        raise
+    await run_in_threadpool(writer.close)
+    return size
+
+
+@router.post(
+    "",
+    response_model=schemas.ImportJob,
+    status_code=status.HTTP_202_ACCEPTED,
+    response_model_by_alias=False,
+    openapi_extra={"requestBody": {"required": True, "content": {t: {"schema": {"type": "string"}} for t in sorted(NDJSON_CONTENT_TYPES)}}},
+)
+async def start_import(
+    request: Request,
+    response: Response,
+    content_type: Optional[str] = Header(None),
+    content_length: Optional[int] = Header(None),
+    jobs: ImportJobRepository = Depends(get_import_job_repository),
+    tasks: TaskQueue = Depends(get_task_queue),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Import patients and vital-sign observations from an NDJSON file sent as
+    the request body (`Content-Type: application/x-ndjson`). Each line is a
+    JSON object with a `resourceType` of `Patient` (the fields of a patient
+    registration) or `Observation` (the fields of a vital sign, plus the
+    patient's `patientId` or `patientMrn`, and optionally the source
+    system's `identifier`, which stops the same reading being imported
+    twice). Patients can be referenced by MRN from later lines of the same
+    file. The file is imported in the background: poll the job at
+    `Content-Location` for progress, and read its error report for the lines
+    that were not imported; the rest of the file is imported regardless.
+    """
+    bucket_name = _require_bucket()
+    if (content_type or "").split(";")[0].strip().lower() not in NDJSON_CONTENT_TYPES:
+        raise HTTPException(status_code=status.HTTP_415_UNSUPPORTED_MEDIA_TYPE, detail=f"Content-Type must be one of {', '.join(sorted(NDJSON_CONTENT_TYPES))}")
+    if content_length is not None and content_length > IMPORT_MAX_BYTES:
+        raise _too_large()
+
+    job_id = uuid.uuid4().hex
+    blob_name = input_blob_name(job_id)
+    size = await _store_upload(request, bucket_name, blob_name)
+    job = await run_in_threadpool(jobs.create, job_id, schemas.ImportJobCreate(blob_name=blob_name, size=size, requested_by=current_user["uid"]))
+    # Runs on Cloud Tasks, or in this worker after the 202 has been sent when no queue is configured.
+    tasks.enqueue(BULK_IMPORT_TASK, {"jobId": job_id, "offset": 0}, task_id=bulk_import_task_id(job_id, 0))
+
+    logging.info(f"User {current_user['uid']} started bulk import {job_id} ({size} bytes)")
+    response.headers["Content-Location"] = f"{request.url.path.rstrip('/')}/{job_id}"
+    return job
+
+
+@router.get("/{jobId}", response_model=schemas.ImportJob, response_model_by_alias=False)
+def get_import(
+    jobId: str,
+    jobs: ImportJobRepository = Depends(get_import_job_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve an import job: its status, how many lines have been read, and
+    how many records were imported, skipped as already imported, or failed.
+    """
+    return _get_job_or_404(jobId, jobs)
+
+
+@router.get(
+    "/{jobId}/errors",
+    response_class=StreamingResponse,
+    responses={200: {"content": {"application/x-ndjson": {}}, "description": "One ImportLineError per line."}},
+)
+def get_import_errors(
+    jobId: str,
+    jobs: ImportJobRepository = Depends(get_import_job_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Download the error report of an import: one JSON object per line of the
+    file that was not imported, with its `line` number, `resourceType` and
+    the `error`. The values of the line are not repeated. The report grows
+    while the import runs, and is empty if every line was imported.
+    """
+    bucket_name = _require_bucket()
+    _get_job_or_404(jobId, jobs)
+    bucket = get_storage_client().bucket(bucket_name)
+
+    def report() -> Iterator[bytes]:
+        for blob in bucket.list_blobs(prefix=error_blob_prefix(jobId)):
+            yield blob.download_as_bytes()
+
+    return StreamingResponse(report(), media_type="application/x-ndjson")
+
+
+@router.delete("/{jobId}", response_model=schemas.ImportJob, response_model_by_alias=False)
+def cancel_import(
+    jobId: str,
+    jobs: ImportJobRepository = Depends(get_import_job_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Cancel an import that has not finished. It stops after the chunk of
+    lines being imported, and its uploaded file is then deleted; records
+    already imported are kept. Returns 409 if the import has already
+    finished.
+    """
+    job = _get_job_or_404(jobId, jobs)
+    if job.status not in ("accepted", "in-progress"):
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=f"Import job is already {job.status}")
+    cancelled = jobs.update(jobId, {"status": "cancelled"})
+    logging.info(f"User {current_user['uid']} cancelled bulk import {jobId}")
+    return cancelled
@@ -0,0 +1,224 @@
+from fastapi import APIRouter, Depends, HTTPException, Query, status
+from datetime import datetime, timedelta, timezone
+from typing import Dict, Optional
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import (
+    get_charge_item_repository,
+    get_claim_repository,
+    get_event_publisher,
+    get_invoice_repository,
+    get_task_queue,
+)
+from app.audit.context import annotate
+from app.core.config import get_settings
+from app.core.storage import signed_download_url
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.invoices.payments import PaymentProviderError, get_payment_provider
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.base import NotFoundError
+from app.repositories.claims import ChargeItemRepository, ClaimRepository
+from app.repositories.invoices import InvoiceRepository
+from app.repositories.transactions import transactional
+from app.services.invoices import PAYABLE, InvalidInvoiceError, build_invoice
+from app.services.state_machine import InvalidTransitionError
+from app.tasks.jobs import INVOICE_STATEMENT_TASK
+from app.tasks.queue import TaskQueue
+from app.tenancy.context import current_tenant
+
+router = APIRouter()
+
+# --- Configuration ---
+settings = get_settings()
+STATEMENT_URL_TTL_SECONDS = settings.invoice_url_ttl_seconds
+
+
+def _get_or_404(invoice_id: str, repo: InvoiceRepository) -> schemas.Invoice:
+    invoice = repo.get(invoice_id)
+    if not invoice:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Invoice not found")
+    annotate(patient_id=invoice.patient_id)
+    return invoice
+
+
+def _emit_payment(events: EventPublisher, before: schemas.Invoice, after: schemas.Invoice) -> None:
+    subject = f"invoices/{after.invoice_id}"
+    events.emit("invoice.payment_recorded", subject, after)
+    if after.status != before.status:
+        events.emit("invoice.status_changed", subject, after)
+
+
+@router.post("", response_model=schemas.Invoice, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_invoice(
+    invoice_in: schemas.InvoiceCreate,
+    repo: InvoiceRepository = Depends(get_invoice_repository),
+    claims: ClaimRepository = Depends(get_claim_repository),
+    charges: ChargeItemRepository = Depends(get_charge_item_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Draft the patient's invoice for what their payer left them to pay on a
+    paid or denied claim, itemized by the claim's lines. Review the draft,
+    then issue it.
+    """
+    claim = claims.get(invoice_in.claim_id)
+    if not claim:
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown claim")
+    annotate(patient_id=claim.patient_id)
+    if any(invoice.status != "void" for invoice in repo.list(claim_id=claim.claim_id, limit=100)):
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="The claim is already invoiced; void that invoice first")
+    try:
+        fields = build_invoice(claim, {line.charge_item_id: charges.get(line.charge_item_id) for line in claim.lines}, invoice_in.message)
+    except InvalidInvoiceError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    invoice = repo.create({**fields, "dueDate": invoice_in.due_date}, created_by=current_user["uid"])
+    events.emit("invoice.created", f"invoices/{invoice.invoice_id}", invoice)
+    logging.info(f"User {current_user['uid']} drafted invoice {invoice.invoice_id} of {invoice.amount_due_cents} cents for claim {claim.claim_id}")
+    return invoice
+
+
+@router.get("", response_model=Page[schemas.Invoice], response_model_by_alias=False)
+def list_invoices(
+    patientId: Optional[str] = None,
+    claimId: Optional[str] = None,
+    invoice_status: Optional[schemas.InvoiceStatus] = Query(None, alias="status"),
+    page: PageRequest = Depends(pagination(default_limit=30, max_limit=100)),
+    repo: InvoiceRepository = Depends(get_invoice_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve invoices newest first, optionally of a patient or claim or with
+    a status, e.g. `issued` for those awaiting payment.
+    """
+    return paginate(
+        page,
+        lambda after, limit: repo.list(
+            patient_id=patientId, claim_id=claimId,
+            statuses=[invoice_status] if invoice_status else None, limit=limit, after=after,
+        ),
+        lambda invoice: invoice.invoice_id,
+    )
+
+
+@router.get("/{invoiceId}", response_model=schemas.Invoice, response_model_by_alias=False)
+def get_invoice(
+    invoiceId: str,
+    repo: InvoiceRepository = Depends(get_invoice_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve an invoice with its lines, the payments taken and its balance.
+    """
+    return _get_or_404(invoiceId, repo)
+
+
+@router.post("/{invoiceId}/status", response_model=schemas.Invoice, response_model_by_alias=False)
+@transactional
+def change_invoice_status(
+    invoiceId: str,
+    status_in: schemas.InvoiceStatusUpdate,
+    repo: InvoiceRepository = Depends(get_invoice_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    tasks: TaskQueue = Depends(get_task_queue),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Issue a draft invoice to the patient, due INVOICE_DUE_DAYS later unless
+    it names a due date, which renders its PDF statement; or void an
+    invoice nothing was paid on, so the claim can be invoiced again.
+    """
+    invoice = _get_or_404(invoiceId, repo)
+    now = datetime.now(timezone.utc)
+    if status_in.status == "issued":
+        changes = {"issuedAt": now, "dueDate": invoice.due_date or (now + timedelta(days=settings.invoice_due_days)).date()}
+    else:
+        changes = {"voidedAt": now, "voidNote": status_in.note}
+    try:
+        updated = repo.change_status(invoiceId, status_in.status, changes)
+    except InvalidTransitionError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Invoice not found")
+    events.emit("invoice.status_changed", f"invoices/{invoiceId}", updated)
+    if updated.status == "issued" and settings.invoices_bucket:
+        tasks.enqueue(INVOICE_STATEMENT_TASK, {"invoiceId": invoiceId}, task_id=f"invoice-statement-{invoiceId}")
+    logging.info(f"User {current_user['uid']} marked invoice {invoiceId} {updated.status}")
+    return updated
+
+
+@router.post("/{invoiceId}/payments", response_model=schemas.Invoice, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def record_invoice_payment(
+    invoiceId: str,
+    payment_in: schemas.InvoicePaymentCreate,
+    repo: InvoiceRepository = Depends(get_invoice_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Record a payment taken outside the payment provider, e.g. at the front
+    desk or by check, and take it off the balance. The invoice is `paid`
+    once the balance is settled. Payments over the balance are refused.
+    """
+    invoice = _get_or_404(invoiceId, repo)
+    try:
+        updated = repo.record_payment(invoiceId, payment_in, current_user["uid"])
+    except (InvalidInvoiceError, InvalidTransitionError) as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Invoice not found")
+    _emit_payment(events, invoice, updated)
+    logging.info(f"User {current_user['uid']} recorded a {payment_in.method} payment of {payment_in.amount_cents} cents on invoice {invoiceId}")
+    return updated
+
+
+@router.get("/{invoiceId}/statement", response_model=schemas.InvoiceDownload, response_model_by_alias=False)
+def download_invoice_statement(
+    invoiceId: str,
+    repo: InvoiceRepository = Depends(get_invoice_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Get a short-lived signed URL to download the invoice's PDF statement
+    from. Returns 409 Conflict until the statement has been rendered, which
+    happens shortly after the invoice is issued.
+    """
+    bucket = settings.invoices_bucket
+    if not bucket:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="Statements are not configured (INVOICES_BUCKET)")
+    invoice = _get_or_404(invoiceId, repo)
+    if not invoice.statement:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="The statement has not been rendered yet")
+    url = signed_download_url(bucket, invoice.statement.blob_name, STATEMENT_URL_TTL_SECONDS)
+    logging.info(f"User {current_user['uid']} downloaded the statement of invoice {invoiceId}")
+    return schemas.InvoiceDownload(url=url, expires_at=datetime.now(timezone.utc) + timedelta(seconds=STATEMENT_URL_TTL_SECONDS))
+
+
+@router.post("/{invoiceId}/checkout", response_model=schemas.InvoiceCheckout, response_model_by_alias=False)
+def start_invoice_checkout(
+    invoiceId: str,
+    repo: InvoiceRepository = Depends(get_invoice_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Open a page at the payment provider where the invoice's balance is paid
+    by card; patients may open one for their own invoices. The payment is
+    recorded when the provider reports it.
+    """
+    provider = get_payment_provider()
+    if provider is None:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="Online payments are not configured (PAYMENTS_PROVIDER)")
+    invoice = _get_or_404(invoiceId, repo)
+    if invoice.status not in PAYABLE:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=f"The invoice is {invoice.status} and takes no payments")
+    try:
+        checkout = provider.checkout(invoice, current_tenant(), settings.payment_return_url)
+    except PaymentProviderError as e:
+        logging.warning(f"Payment provider request failed: {e}")
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="The payment provider is unavailable; try again")
+    logging.info(f"User {current_user['uid']} opened checkout {checkout.reference} for invoice {invoiceId}")
+    return schemas.InvoiceCheckout(url=checkout.url, expires_at=checkout.expires_at)
@@ -0,0 +1,120 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, status
+from typing import Dict, Optional
+from datetime import datetime
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_event_publisher, get_lab_result_repository, get_patient_repository
+from app.dependencies.auth import get_current_user
+from app.dependencies.notifications import get_notifier
+from app.events.publisher import EventPublisher
+from app.notifications.notifier import Notifier
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.lab_results import LabResultRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.transactions import transactional
+from app.services.lab_results import LabResultValidationError, prepare_lab_result, trend_points
+
+router = APIRouter()
+
+def _ensure_patient_exists(patient_id: str, patients: PatientRepository) -> schemas.Patient:
+    patient = patients.get(patient_id)
+    if not patient:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+    return patient
+
+
+def _ensure_range(start_from: Optional[datetime], start_to: Optional[datetime]):
+    if start_from and start_to and start_from >= start_to:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="'from' must be before 'to'")
+
+
+@router.post("/{patientId}/lab-results", response_model=schemas.LabResult, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_lab_result(
+    patientId: str,
+    lab_in: schemas.LabResultCreate,
+    repo: LabResultRepository = Depends(get_lab_result_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    notifier: Optional[Notifier] = Depends(get_notifier),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Record a lab result for a patient: a single test, or a panel of analytes.
+    Analytes without an abnormal flag are flagged from their reference range.
+    When email, SMS or push is configured, patients are told that a final
+    result is ready to view (without its details).
+    """
+    patient = _ensure_patient_exists(patientId, patients)
+    try:
+        lab_in = prepare_lab_result(lab_in)
+    except LabResultValidationError as e:
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail=str(e))
+
+    lab_result = repo.create(patientId, lab_in, recorded_by=current_user["uid"])
+    events.emit("lab_result.recorded", f"patients/{patientId}/lab-results/{lab_result.lab_result_id}", lab_result)
+    if notifier and lab_result.status == "final":
+        notifier.notify("result-ready", patient, {}, about=f"patients/{patientId}/lab-results/{lab_result.lab_result_id}")
+    logging.info(f"User {current_user['uid']} recorded lab result {lab_result.lab_result_id} with {len(lab_result.analytes)} analyte(s) for patient {patientId}")
+    return lab_result
+
+
+@router.get("/{patientId}/lab-results", response_model=Page[schemas.LabResult], response_model_by_alias=False)
+def list_lab_results(
+    patientId: str,
+    code: Optional[str] = Query(None, description="Only results whose panel or any analyte has this LOINC code."),
+    start_from: Optional[datetime] = Query(None, alias="from", description="Only results collected at or after this time."),
+    start_to: Optional[datetime] = Query(None, alias="to", description="Only results collected before this time."),
+    page: PageRequest = Depends(pagination(default_limit=30, max_limit=100)),
+    repo: LabResultRepository = Depends(get_lab_result_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a patient's lab results, newest first.
+    """
+    _ensure_range(start_from, start_to)
+    _ensure_patient_exists(patientId, patients)
+    return paginate(
+        page,
+        lambda after, limit: repo.list(patientId, code=code, start_from=start_from, start_to=start_to, limit=limit, after=after),
+        lambda lab_result: lab_result.lab_result_id,
+    )
+
+
+@router.get("/{patientId}/lab-results/trend", response_model=schemas.LabTrend, response_model_by_alias=False)
+def get_lab_trend(
+    patientId: str,
+    code: str = Query(..., description="LOINC code of the analyte to trend."),
+    start_from: Optional[datetime] = Query(None, alias="from"),
+    start_to: Optional[datetime] = Query(None, alias="to"),
+    limit: int = Query(100, ge=1, le=500, description="Maximum number of results to read."),
+    repo: LabResultRepository = Depends(get_lab_result_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve the values of one analyte over time, oldest first, with the
+    reference range and flag of each measurement.
+    """
+    _ensure_range(start_from, start_to)
+    _ensure_patient_exists(patientId, patients)
+    results = repo.list(patientId, code=code, start_from=start_from, start_to=start_to, limit=limit)
+    return schemas.LabTrend(code=code, points=trend_points(results, code))
+
+
+@router.get("/{patientId}/lab-results/{labResultId}", response_model=schemas.LabResult, response_model_by_alias=False)
+def get_lab_result(
+    patientId: str,
+    labResultId: str,
+    repo: LabResultRepository = Depends(get_lab_result_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a single lab result of a patient by ID.
+    """
+    lab_result = repo.get(labResultId)
+    if not lab_result or lab_result.patient_id != patientId:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Lab result not found")
+    return lab_result
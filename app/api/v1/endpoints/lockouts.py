@@ -0,0 +1,69 @@
+from fastapi import APIRouter, Depends, HTTPException, Query, Response, status
+from datetime import datetime, timezone
+from typing import Dict, List
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_lockout_repository
+from app.api.v1.endpoints.sessions import login_guard
+from app.auth.lockout import LoginGuard
+from app.authz.roles import ADMIN
+from app.dependencies.auth import require_roles
+from app.repositories.base import NotFoundError
+from app.repositories.lockouts import LockoutRepository
+from app.tenancy.context import PLATFORM_ADMIN
+
+router = APIRouter()
+
+# Lockouts are kept per account and address rather than per organization,
+# so administrators of any organization can lift them for their users.
+LOCKOUT_ADMIN_ROLES = (ADMIN, PLATFORM_ADMIN)
+
+
+@router.get("", response_model=List[schemas.Lockout], response_model_by_alias=False)
+def list_lockouts(
+    limit: int = Query(100, ge=1, le=500),
+    repo: LockoutRepository = Depends(get_lockout_repository),
+    current_user: Dict = Depends(require_roles(*LOCKOUT_ADMIN_ROLES))
+):
+    """
+    Retrieve the accounts and addresses that are locked out now, the
+    longest lockout first. Accounts and addresses that are only delayed
+    are not listed, but can be read by ID.
+    """
+    return repo.list_locked(datetime.now(timezone.utc), limit=limit)
+
+
+@router.get("/{lockoutId}", response_model=schemas.Lockout, response_model_by_alias=False)
+def get_lockout(
+    lockoutId: str,
+    repo: LockoutRepository = Depends(get_lockout_repository),
+    current_user: Dict = Depends(require_roles(*LOCKOUT_ADMIN_ROLES))
+):
+    """
+    Retrieve the recent failed sign-in attempts of an account
+    ('account:<uid>') or an address ('address:<ip>').
+    """
+    record = repo.get(lockoutId)
+    if not record:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="No recent failed sign-in attempts")
+    return record
+
+
+@router.delete("/{lockoutId}", status_code=status.HTTP_204_NO_CONTENT)
+def unlock(
+    lockoutId: str,
+    guard: LoginGuard = Depends(login_guard),
+    current_user: Dict = Depends(require_roles(*LOCKOUT_ADMIN_ROLES))
+):
+    """
+    Lift the lockout or delay of an account or address, e.g. once a user
+    who forgot their authenticator has been verified by phone, and forget
+    its failed attempts.
+    """
+    try:
+        guard.unlock(lockoutId)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="No recent failed sign-in attempts")
+    logging.warning(f"User {current_user['uid']} unlocked {lockoutId}")
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
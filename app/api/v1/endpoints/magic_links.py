@@ -0,0 +1,87 @@
+from fastapi import APIRouter, Depends, Header, HTTPException, Request, Response, status
+from typing import Optional
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_task_queue
+from app.api.v1.endpoints.sessions import locked_out_error, login_guard, session_manager
+from app.audit.context import annotate, set_actor
+from app.auth.lockout import LockedOut, LoginGuard, address
+from app.auth.magic_links import MagicLinkError, MagicLinks, MagicLinkThrottled, get_magic_links
+from app.auth.sessions import SessionManager
+from app.authz.networks import client_address
+from app.core.config import get_settings
+from app.tasks.jobs import MAGIC_LINK_EMAIL_TASK
+from app.tasks.queue import TaskQueue
+
+router = APIRouter()
+
+# Like /auth/sessions, these routes are open: they are how a user without a
+# password gets a token.
+
+
+def magic_links() -> MagicLinks:
+    links = get_magic_links()
+    if links is None:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail="Magic links are not configured (SESSION_SIGNING_KEY and AUTH_PROVIDER=firebase)",
+        )
+    return links
+
+
+@router.post("", status_code=status.HTTP_202_ACCEPTED)
+def request_magic_link(
+    link_in: schemas.MagicLinkRequest,
+    request: Request,
+    links: MagicLinks = Depends(magic_links),
+    tasks: TaskQueue = Depends(get_task_queue),
+):
+    """
+    Email a single-use sign-in link, valid for MAGIC_LINK_TTL_SECONDS, to
+    the address if it belongs to an account. The answer is the same either
+    way. An address gets MAGIC_LINK_MAX_PER_ADDRESS_PER_HOUR links an hour;
+    past that, 429 with Retry-After.
+    """
+    try:
+        record = links.request(link_in.email, source_ip=client_address(request.scope, get_settings().trusted_proxy_hops))
+    except MagicLinkThrottled as e:
+        raise HTTPException(status_code=status.HTTP_429_TOO_MANY_REQUESTS, detail=str(e), headers={"Retry-After": str(e.retry_after)})
+    if record:
+        annotate(resource_type="magicLinks", resource_id=record.link_id)
+        tasks.enqueue(MAGIC_LINK_EMAIL_TASK, {"linkId": record.link_id}, task_id=f"magic-link-{record.link_id}")
+    return Response(status_code=status.HTTP_202_ACCEPTED)
+
+
+@router.post("/exchange", response_model=schemas.SessionTokens, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+def exchange_magic_link(
+    exchange_in: schemas.MagicLinkExchange,
+    request: Request,
+    user_agent: Optional[str] = Header(None),
+    links: MagicLinks = Depends(magic_links),
+    manager: SessionManager = Depends(session_manager),
+    guard: LoginGuard = Depends(login_guard),
+):
+    """
+    Sign in with the token of an emailed link. Starts a session as
+    POST /auth/sessions does; the link cannot be used again. Failures count
+    towards the address's lockout like failed sign-ins.
+    """
+    source_ip = client_address(request.scope, get_settings().trusted_proxy_hops)
+    try:
+        guard.check(address(source_ip))
+    except LockedOut as e:
+        raise locked_out_error(e)
+    try:
+        identity = links.redeem(exchange_in.token)
+    except MagicLinkError as e:
+        guard.failed(address(source_ip))
+        raise HTTPException(status_code=status.HTTP_401_UNAUTHORIZED, detail=str(e))
+    tokens = manager.start_for(
+        identity,
+        device_label=exchange_in.device_label,
+        user_agent=user_agent,
+        source_ip=source_ip,
+    )
+    set_actor(identity)
+    annotate(resource_type="sessions", resource_id=tokens.session.session_id)
+    return tokens
@@ -0,0 +1,259 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, status
+from typing import List, Dict, Optional
+from datetime import datetime, timezone
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_event_publisher, get_medication_repository, get_patient_repository, get_practitioner_repository, get_refill_request_repository
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.base import NotFoundError
+from app.repositories.medications import MedicationRepository, RefillRequestRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.practitioners import PractitionerRepository
+from app.repositories.transactions import transactional
+from app.services.medications import OPEN_REFILL_STATUSES, REFILL_REQUEST_STATUSES, refills_remaining
+from app.services.state_machine import InvalidTransitionError
+
+router = APIRouter()
+
+def _ensure_patient_exists(patient_id: str, patients: PatientRepository):
+    if not patients.get(patient_id):
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+
+
+def _get_medication_or_404(patient_id: str, medication_id: str, repo: MedicationRepository) -> schemas.Medication:
+    medication = repo.get(medication_id)
+    if not medication or medication.patient_id != patient_id:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Medication not found")
+    return medication
+
+
+def _get_refill_request_or_404(medication_id: str, refill_request_id: str, repo: RefillRequestRepository) -> schemas.RefillRequest:
+    refill_request = repo.get(refill_request_id)
+    if not refill_request or refill_request.medication_id != medication_id:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Refill request not found")
+    return refill_request
+
+
+def _ensure_prescriber_exists(prescriber_id: Optional[str], practitioners: PractitionerRepository):
+    if prescriber_id and not practitioners.get(prescriber_id):
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown prescriber")
+
+
+# --- Medications ---
+
+@router.post("/{patientId}/medications", response_model=schemas.Medication, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_medication(
+    patientId: str,
+    medication_in: schemas.MedicationCreate,
+    repo: MedicationRepository = Depends(get_medication_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    practitioners: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Add a medication to a patient's medication list.
+    """
+    _ensure_patient_exists(patientId, patients)
+    _ensure_prescriber_exists(medication_in.prescriber_id, practitioners)
+
+    medication = repo.create(patientId, medication_in)
+    events.emit("medication.prescribed", f"patients/{patientId}/medications/{medication.medication_id}", medication)
+    logging.info(f"User {current_user['uid']} added medication {medication.medication_id} for patient {patientId}")
+    return medication
+
+
+@router.get("/{patientId}/medications", response_model=List[schemas.Medication], response_model_by_alias=False)
+def list_medications(
+    patientId: str,
+    status_filter: Optional[schemas.MedicationStatus] = Query(None, alias="status"),
+    repo: MedicationRepository = Depends(get_medication_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a patient's medications, optionally only those with a given status.
+    """
+    _ensure_patient_exists(patientId, patients)
+    return repo.list(patientId, status=status_filter)
+
+
+@router.get("/{patientId}/medications/{medicationId}", response_model=schemas.Medication, response_model_by_alias=False)
+def get_medication(
+    patientId: str,
+    medicationId: str,
+    repo: MedicationRepository = Depends(get_medication_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a single medication of a patient by ID.
+    """
+    return _get_medication_or_404(patientId, medicationId, repo)
+
+
+@router.patch("/{patientId}/medications/{medicationId}", response_model=schemas.Medication, response_model_by_alias=False)
+@transactional
+def update_medication(
+    patientId: str,
+    medicationId: str,
+    medication_in: schemas.MedicationUpdate,
+    repo: MedicationRepository = Depends(get_medication_repository),
+    practitioners: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Update a medication's dosage, refill allowance or status.
+    """
+    _get_medication_or_404(patientId, medicationId, repo)
+    _ensure_prescriber_exists(medication_in.prescriber_id, practitioners)
+    try:
+        medication = repo.update(medicationId, medication_in)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Medication not found")
+    events.emit("medication.updated", f"patients/{patientId}/medications/{medicationId}", medication)
+    return medication
+
+
+# --- Refill Requests ---
+
+@router.post("/{patientId}/medications/{medicationId}/refill-requests", response_model=schemas.RefillRequest, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_refill_request(
+    patientId: str,
+    medicationId: str,
+    request_in: schemas.RefillRequestCreate,
+    repo: RefillRequestRepository = Depends(get_refill_request_repository),
+    medications: MedicationRepository = Depends(get_medication_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Request a refill of an active medication. Only one request per medication
+    can be open at a time, and the medication must have refills remaining.
+    """
+    medication = _get_medication_or_404(patientId, medicationId, medications)
+    if medication.status != "active":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=f"Cannot request a refill of a medication with status '{medication.status}'.")
+    if refills_remaining(medication) == 0:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="No refills remaining for this medication")
+    if repo.list(medicationId, statuses=sorted(OPEN_REFILL_STATUSES), limit=1):
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="A refill request for this medication is already open")
+
+    refill_request = repo.create(medication, request_in, requested_by=current_user["uid"])
+    events.emit("refill_request.created", f"patients/{patientId}/medications/{medicationId}/refill-requests/{refill_request.refill_request_id}", refill_request)
+    logging.info(f"User {current_user['uid']} requested refill {refill_request.refill_request_id} of medication {medicationId}")
+    return refill_request
+
+
+@router.get("/{patientId}/medications/{medicationId}/refill-requests", response_model=Page[schemas.RefillRequest], response_model_by_alias=False)
+def list_refill_requests(
+    patientId: str,
+    medicationId: str,
+    page: PageRequest = Depends(pagination(default_limit=30, max_limit=100)),
+    repo: RefillRequestRepository = Depends(get_refill_request_repository),
+    medications: MedicationRepository = Depends(get_medication_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve the refill requests raised for a medication, newest first.
+    """
+    _get_medication_or_404(patientId, medicationId, medications)
+    return paginate(
+        page,
+        lambda after, limit: repo.list(medicationId, limit=limit, after=after),
+        lambda refill_request: refill_request.refill_request_id,
+    )
+
+
+@router.get("/{patientId}/medications/{medicationId}/refill-requests/{refillRequestId}", response_model=schemas.RefillRequest, response_model_by_alias=False)
+def get_refill_request(
+    patientId: str,
+    medicationId: str,
+    refillRequestId: str,
+    repo: RefillRequestRepository = Depends(get_refill_request_repository),
+    medications: MedicationRepository = Depends(get_medication_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a single refill request by ID.
+    """
+    _get_medication_or_404(patientId, medicationId, medications)
+    return _get_refill_request_or_404(medicationId, refillRequestId, repo)
+
+
+@router.post("/{patientId}/medications/{medicationId}/refill-requests/{refillRequestId}/assign", response_model=schemas.RefillRequest, response_model_by_alias=False)
+@transactional
+def assign_refill_request(
+    patientId: str,
+    medicationId: str,
+    refillRequestId: str,
+    assign_in: schemas.RefillRequestAssign,
+    repo: RefillRequestRepository = Depends(get_refill_request_repository),
+    medications: MedicationRepository = Depends(get_medication_repository),
+    practitioners: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Assign an open refill request to the practitioner who will review it.
+    """
+    _get_medication_or_404(patientId, medicationId, medications)
+    refill_request = _get_refill_request_or_404(medicationId, refillRequestId, repo)
+    if refill_request.status not in OPEN_REFILL_STATUSES:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=f"Cannot reassign a refill request with status '{refill_request.status}'.")
+    if not practitioners.get(assign_in.practitioner_id):
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown practitioner")
+
+    updated = repo.update(refillRequestId, {"assignedPractitionerId": assign_in.practitioner_id})
+    events.emit("refill_request.status_changed", f"patients/{patientId}/medications/{medicationId}/refill-requests/{refillRequestId}", updated)
+    logging.info(f"User {current_user['uid']} assigned refill request {refillRequestId} to practitioner {assign_in.practitioner_id}")
+    return updated
+
+
+@router.post("/{patientId}/medications/{medicationId}/refill-requests/{refillRequestId}/status", response_model=schemas.RefillRequest, response_model_by_alias=False)
+@transactional
+def update_refill_request_status(
+    patientId: str,
+    medicationId: str,
+    refillRequestId: str,
+    status_in: schemas.RefillRequestStatusUpdate,
+    repo: RefillRequestRepository = Depends(get_refill_request_repository),
+    medications: MedicationRepository = Depends(get_medication_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Move a refill request through review: requested -> under-review ->
+    approved or denied. A practitioner must be assigned before review starts.
+    Approving a request counts one refill against the medication.
+    """
+    medication = _get_medication_or_404(patientId, medicationId, medications)
+    refill_request = _get_refill_request_or_404(medicationId, refillRequestId, repo)
+    try:
+        REFILL_REQUEST_STATUSES.ensure(refill_request.status, status_in.status)
+    except InvalidTransitionError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    if not refill_request.assigned_practitioner_id:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="Assign a practitioner before reviewing this refill request")
+
+    changes = {"status": status_in.status}
+    if REFILL_REQUEST_STATUSES.is_terminal(status_in.status):
+        if status_in.status == "approved" and refills_remaining(medication) == 0:
+            raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="No refills remaining for this medication")
+        changes.update({
+            "decisionNote": status_in.decision_note,
+            "decidedBy": current_user["uid"],
+            "decidedAt": datetime.now(timezone.utc),
+        })
+
+    updated = repo.update(refillRequestId, changes)
+    if status_in.status == "approved":
+        medications.record_refill(medicationId)
+    events.emit("refill_request.status_changed", f"patients/{patientId}/medications/{medicationId}/refill-requests/{refillRequestId}", updated)
+    logging.info(f"User {current_user['uid']} changed refill request {refillRequestId} status from {refill_request.status} to {status_in.status}")
+    return updated
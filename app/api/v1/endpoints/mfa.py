@@ -0,0 +1,247 @@
+from fastapi import APIRouter, Depends, HTTPException, Request, Response, status
+from datetime import datetime, timezone
+from typing import Any, Callable, Dict, List, Optional
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_session_repository
+from app.api.v1.endpoints.sessions import locked_out_error, login_guard, session_manager
+from app.audit.context import annotate
+from app.auth.lockout import LockedOut, LoginGuard, account, address
+from app.auth.mfa import MfaError, MfaManager, MfaStateError, MfaThrottled, MfaUnavailable, get_mfa_manager
+from app.auth.sessions import SessionManager
+from app.authz.mfa import check_step_up, mfa_required, mfa_verified_at
+from app.authz.networks import client_address
+from app.core.config import get_settings
+from app.dependencies.auth import get_current_user
+from app.repositories.base import ConflictError, NotFoundError
+from app.repositories.sessions import SessionRepository
+
+router = APIRouter()
+
+# Under /auth, so AuthenticationMiddleware leaves these open and each
+# handler checks the caller's token through `get_current_user`. They stay
+# reachable to staff who have not proved a second factor yet, since this is
+# where they do.
+
+
+def mfa_manager() -> MfaManager:
+    return get_mfa_manager()
+
+
+def _mfa_error(e: Exception) -> HTTPException:
+    if isinstance(e, MfaThrottled):
+        return HTTPException(status_code=status.HTTP_429_TOO_MANY_REQUESTS, detail=str(e), headers={"Retry-After": str(e.retry_after)})
+    if isinstance(e, MfaUnavailable):
+        return HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail=str(e))
+    if isinstance(e, MfaStateError):
+        return HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    return HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail=str(e))
+
+
+def _guarded(guard: LoginGuard, request: Request, uid: str, attempt: Callable[[], Any]) -> Any:
+    """
+    Runs a code check under the caller's account and address lockouts:
+    wrong codes count against both, and a right one clears the account's.
+    """
+    subjects = (account(uid), address(client_address(request.scope, get_settings().trusted_proxy_hops)))
+    try:
+        guard.check(*subjects)
+    except LockedOut as e:
+        raise locked_out_error(e)
+    try:
+        result = attempt()
+    except MfaError as e:
+        guard.failed(*subjects)
+        raise _mfa_error(e)
+    except (MfaStateError, MfaThrottled) as e:
+        raise _mfa_error(e)
+    guard.succeeded(subjects[0])
+    return result
+
+
+def _status(record: Optional[schemas.MfaEnrollmentRecord], current_user: Dict, recovery_codes: Optional[List[str]] = None) -> schemas.MfaStatus:
+    verified_at = mfa_verified_at(current_user)
+    return schemas.MfaStatus(
+        totp=bool(record and record.totp_confirmed_at),
+        sms=bool(record and record.phone_confirmed_at),
+        phone_number_hint=f"•••• {record.phone_number[-4:]}" if record and record.phone_confirmed_at and record.phone_number else None,
+        recovery_codes_remaining=len(record.recovery_code_hashes) if record else 0,
+        required=mfa_required(current_user),
+        verified_at=datetime.fromtimestamp(verified_at, tz=timezone.utc) if verified_at else None,
+        recovery_codes=recovery_codes,
+    )
+
+
+def _check_changes_allowed(manager: MfaManager, current_user: Dict, what: str) -> None:
+    """Once a user has a factor, changing their factors takes a recently verified one, so a stolen token cannot."""
+    record = manager.repository_factory().get(current_user["uid"])
+    if record and (record.totp_confirmed_at or record.phone_confirmed_at):
+        check_step_up(current_user, what, everyone=True)
+
+
+@router.get("", response_model=schemas.MfaStatus, response_model_by_alias=False)
+def get_mfa_status(
+    manager: MfaManager = Depends(mfa_manager),
+    current_user: Dict = Depends(get_current_user),
+):
+    """
+    Retrieve the caller's enrolled second factors, whether their roles
+    require one, and when their credentials last proved one.
+    """
+    return _status(manager.repository_factory().get(current_user["uid"]), current_user)
+
+
+@router.post("/totp", response_model=schemas.MfaTotpEnrollment, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+def enroll_totp(
+    manager: MfaManager = Depends(mfa_manager),
+    current_user: Dict = Depends(get_current_user),
+):
+    """
+    Start enrolling an authenticator app. Show `otpauthUri` as a QR code,
+    then confirm with one of the app's codes at /auth/mfa/totp/confirm.
+    """
+    _check_changes_allowed(manager, current_user, "add an authenticator app")
+    try:
+        enrollment = manager.begin_totp(current_user["uid"], current_user.get("email") or current_user["uid"])
+    except MfaStateError as e:
+        raise _mfa_error(e)
+    annotate(resource_type="mfa", resource_id=current_user["uid"])
+    return enrollment
+
+
+@router.post("/totp/confirm", response_model=schemas.MfaStatus, response_model_by_alias=False)
+def confirm_totp(
+    code_in: schemas.MfaCode,
+    request: Request,
+    manager: MfaManager = Depends(mfa_manager),
+    guard: LoginGuard = Depends(login_guard),
+    current_user: Dict = Depends(get_current_user),
+):
+    """
+    Confirm the authenticator app being enrolled. Confirming the first
+    factor also returns recovery codes, which are not shown again.
+    """
+    recovery_codes = _guarded(guard, request, current_user["uid"], lambda: manager.confirm_totp(current_user["uid"], code_in.code))
+    annotate(resource_type="mfa", resource_id=current_user["uid"])
+    return _status(manager.repository_factory().get(current_user["uid"]), current_user, recovery_codes)
+
+
+@router.post("/sms", status_code=status.HTTP_202_ACCEPTED)
+def enroll_sms(
+    phone_in: schemas.MfaPhoneEnrollment,
+    manager: MfaManager = Depends(mfa_manager),
+    current_user: Dict = Depends(get_current_user),
+):
+    """
+    Start enrolling a phone number for text message codes. A code is
+    texted to it; confirm with it at /auth/mfa/sms/confirm.
+    """
+    _check_changes_allowed(manager, current_user, "add a phone number")
+    try:
+        manager.begin_sms(current_user["uid"], phone_in.phone_number)
+    except (MfaStateError, MfaThrottled, MfaUnavailable) as e:
+        raise _mfa_error(e)
+    annotate(resource_type="mfa", resource_id=current_user["uid"])
+    return Response(status_code=status.HTTP_202_ACCEPTED)
+
+
+@router.post("/sms/confirm", response_model=schemas.MfaStatus, response_model_by_alias=False)
+def confirm_sms(
+    code_in: schemas.MfaCode,
+    request: Request,
+    manager: MfaManager = Depends(mfa_manager),
+    guard: LoginGuard = Depends(login_guard),
+    current_user: Dict = Depends(get_current_user),
+):
+    """
+    Confirm the phone number being enrolled with the code texted to it.
+    Confirming the first factor also returns recovery codes.
+    """
+    recovery_codes = _guarded(guard, request, current_user["uid"], lambda: manager.confirm_sms(current_user["uid"], code_in.code))
+    annotate(resource_type="mfa", resource_id=current_user["uid"])
+    return _status(manager.repository_factory().get(current_user["uid"]), current_user, recovery_codes)
+
+
+@router.post("/challenge", status_code=status.HTTP_202_ACCEPTED)
+def send_mfa_challenge(
+    challenge_in: schemas.MfaChallengeRequest,
+    manager: MfaManager = Depends(mfa_manager),
+    current_user: Dict = Depends(get_current_user),
+):
+    """
+    Text a code to the caller's enrolled phone number, to verify at
+    /auth/mfa/verify. Codes expire after MFA_SMS_CODE_TTL_SECONDS.
+    """
+    try:
+        manager.challenge(current_user["uid"])
+    except (MfaStateError, MfaThrottled, MfaUnavailable) as e:
+        raise _mfa_error(e)
+    return Response(status_code=status.HTTP_202_ACCEPTED)
+
+
+@router.post("/verify", response_model=schemas.MfaVerification, response_model_by_alias=False)
+def verify_mfa(
+    verify_in: schemas.MfaVerify,
+    request: Request,
+    manager: MfaManager = Depends(mfa_manager),
+    guard: LoginGuard = Depends(login_guard),
+    sessions: SessionManager = Depends(session_manager),
+    session_repo: SessionRepository = Depends(get_session_repository),
+    current_user: Dict = Depends(get_current_user),
+):
+    """
+    Prove a second factor: an authenticator app code, a texted code, or a
+    recovery code. The session is marked verified and a new access token
+    returned, which carries the verification; later refreshes keep it.
+    Step-up operations need a verification within
+    MFA_STEP_UP_MAX_AGE_SECONDS, so they may send the caller back here.
+    Wrong codes count towards the account's and address's lockouts.
+    """
+    session_id = current_user.get("sid")
+    if not session_id:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="Second factors are verified within a session; start one at /auth/sessions")
+    _guarded(guard, request, current_user["uid"], lambda: manager.verify(current_user["uid"], verify_in.method, verify_in.code))
+    now = datetime.now(timezone.utc)
+    try:
+        record = session_repo.mark_mfa_verified(session_id, now)
+    except (ConflictError, NotFoundError):
+        raise HTTPException(status_code=status.HTTP_401_UNAUTHORIZED, detail="Session has ended", headers={"WWW-Authenticate": "Bearer"})
+    annotate(resource_type="sessions", resource_id=session_id)
+    return schemas.MfaVerification(
+        access_token=sessions.access_token(record, now),
+        expires_in=sessions.access_token_ttl_seconds,
+        verified_at=now,
+    )
+
+
+@router.post("/recovery-codes", response_model=schemas.MfaRecoveryCodes, response_model_by_alias=False)
+def regenerate_recovery_codes(
+    manager: MfaManager = Depends(mfa_manager),
+    current_user: Dict = Depends(get_current_user),
+):
+    """
+    Replace the caller's recovery codes; the old ones stop working. Needs a
+    second factor verified within MFA_STEP_UP_MAX_AGE_SECONDS.
+    """
+    check_step_up(current_user, "replace your recovery codes", everyone=True)
+    record = manager.repository_factory().get(current_user["uid"])
+    if not record or not (record.totp_confirmed_at or record.phone_confirmed_at):
+        raise _mfa_error(MfaStateError("No second factor is enrolled"))
+    annotate(resource_type="mfa", resource_id=current_user["uid"])
+    return schemas.MfaRecoveryCodes(recovery_codes=manager.regenerate_recovery_codes(current_user["uid"]))
+
+
+@router.delete("", status_code=status.HTTP_204_NO_CONTENT)
+def remove_mfa(
+    manager: MfaManager = Depends(mfa_manager),
+    current_user: Dict = Depends(get_current_user),
+):
+    """
+    Remove all of the caller's second factors, e.g. to replace a lost
+    phone: verify with a recovery code first, then enroll again. Needs a
+    second factor verified within MFA_STEP_UP_MAX_AGE_SECONDS.
+    """
+    check_step_up(current_user, "remove your second factors", everyone=True)
+    manager.reset(current_user["uid"])
+    annotate(resource_type="mfa", resource_id=current_user["uid"])
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
@@ -0,0 +1,48 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, status
+from typing import List, Dict, Optional
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_notification_repository, get_patient_repository
+from app.dependencies.auth import get_current_user
+from app.repositories.notifications import NotificationRepository
+from app.repositories.patients import PatientRepository
+
+router = APIRouter()
+
+
+def _ensure_patient_exists(patient_id: str, patients: PatientRepository):
+    if not patients.get(patient_id):
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+
+
+@router.get("/{patientId}/notifications", response_model=List[schemas.Notification], response_model_by_alias=False)
+def list_notifications(
+    patientId: str,
+    status_filter: Optional[schemas.NotificationStatus] = Query(None, alias="status"),
+    repo: NotificationRepository = Depends(get_notification_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve the messages sent to a patient, newest first, with their
+    delivery as reported by the provider, e.g. to see why a patient
+    did not receive a confirmation (`status=bounced`).
+    """
+    _ensure_patient_exists(patientId, patients)
+    return sorted(repo.list(patientId, status=status_filter), key=lambda n: n.created_at, reverse=True)
+
+
+@router.get("/{patientId}/notifications/{notificationId}", response_model=schemas.Notification, response_model_by_alias=False)
+def get_notification(
+    patientId: str,
+    notificationId: str,
+    repo: NotificationRepository = Depends(get_notification_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a message sent to a patient by ID, with its delivery events.
+    """
+    notification = repo.get(notificationId)
+    if not notification or notification.patient_id != patientId:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Notification not found")
+    return notification
@@ -0,0 +1,99 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, status
+from typing import Dict, Optional
+from datetime import datetime
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_event_publisher, get_observation_repository, get_patient_repository
+from app.dependencies.auth import get_current_user
+from app.dependencies.notifications import get_notifier
+from app.events.publisher import EventPublisher
+from app.notifications.notifier import Notifier
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.observations import OBSERVATION_SEARCH, ObservationRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.transactions import transactional
+from app.search.filters import Search, search_query
+from app.services.vitals import VitalValidationError, build_vital_observation, is_abnormal_reading
+
+router = APIRouter()
+
+def _ensure_patient_exists(patient_id: str, patients: PatientRepository):
+    if not patients.get(patient_id):
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+
+
+@router.post("/{patientId}/observations", response_model=schemas.Observation, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_observation(
+    patientId: str,
+    vital_in: schemas.VitalSignCreate,
+    repo: ObservationRepository = Depends(get_observation_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    notifier: Optional[Notifier] = Depends(get_notifier),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Record a vital sign for a patient. The LOINC code determines which units
+    are accepted and the plausible value range; blood pressure is sent as
+    systolic and diastolic components. When push is configured, the patient
+    app is alerted to readings outside the usual range.
+    """
+    patient = patients.get(patientId)
+    if not patient:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+    try:
+        observation_in = build_vital_observation(patientId, vital_in)
+    except VitalValidationError as e:
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail=str(e))
+
+    observation = repo.create(observation_in)
+    events.emit("observation.recorded", f"patients/{patientId}/observations/{observation.observation_id}", observation)
+    if notifier and is_abnormal_reading(observation):
+        notifier.notify("abnormal-reading", patient, {}, about=f"patients/{patientId}/observations/{observation.observation_id}")
+    logging.info(f"User {current_user['uid']} recorded observation {observation.observation_id} ({observation.code.code}) for patient {patientId}")
+    return observation
+
+
+@router.get("/{patientId}/observations", response_model=Page[schemas.Observation], response_model_by_alias=False)
+def list_observations(
+    patientId: str,
+    start_from: Optional[datetime] = Query(None, alias="from", description="Only observations taken at or after this time."),
+    start_to: Optional[datetime] = Query(None, alias="to", description="Only observations taken before this time."),
+    page: PageRequest = Depends(pagination(default_limit=30, max_limit=100)),
+    search: Search = Depends(search_query(OBSERVATION_SEARCH)),
+    repo: ObservationRepository = Depends(get_observation_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a patient's observations, newest first unless `sort` says
+    otherwise, optionally restricted to an effective time range. Filter by
+    `code` (a LOINC code, or several as `code=8867-4,59408-5`), `status`,
+    `value` (e.g. `value=lt90`), `effectiveAt` or `createdAt`.
+    """
+    if start_from and start_to and start_from >= start_to:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="'from' must be before 'to'")
+    _ensure_patient_exists(patientId, patients)
+    return paginate(
+        page,
+        lambda after, limit: repo.list(patientId, start_from=start_from, start_to=start_to, limit=limit, after=after, search=search),
+        lambda observation: observation.observation_id,
+    )
+
+
+@router.get("/{patientId}/observations/{observationId}", response_model=schemas.Observation, response_model_by_alias=False)
+def get_observation(
+    patientId: str,
+    observationId: str,
+    repo: ObservationRepository = Depends(get_observation_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a single observation of a patient by ID.
+    """
+    observation = repo.get(observationId)
+    if not observation or observation.patient_id != patientId:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Observation not found")
+    return observation
@@ -0,0 +1,157 @@
+from fastapi import APIRouter, Depends, HTTPException, Query, Request, status
+from typing import Dict, Optional
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_organization_repository, get_purge_manifest_repository, get_retention_policy_repository
+from app.authz.networks import address_allowed, client_address, parse_ranges
+from app.authz.roles import ADMIN
+from app.core.config import get_settings
+from app.dependencies.auth import get_current_user, require_roles
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.base import ConflictError, NotFoundError
+from app.repositories.organizations import OrganizationRepository
+from app.repositories.retention import PurgeManifestRepository, RetentionPolicyRepository
+from app.retention.policies import effective_policy
+from app.tenancy.context import PLATFORM_ADMIN, acting_for, claimed_organization, is_platform_admin
+
+router = APIRouter()
+
+
+def _check_member(organization_id: str, current_user: Dict) -> None:
+    """Platform administrators reach every organization; anyone else only their own."""
+    if not is_platform_admin(current_user) and claimed_organization(current_user) != organization_id:
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="You do not have permission to access this organization")
+
+
+@router.post("", response_model=schemas.Organization, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+def create_organization(
+    *,
+    organization_in: schemas.OrganizationCreate,
+    repo: OrganizationRepository = Depends(get_organization_repository),
+    current_user: Dict = Depends(require_roles(PLATFORM_ADMIN))
+):
+    """
+    Register a clinic. Its users' tokens must then carry its ID as the
+    `organizationId` claim.
+    """
+    try:
+        organization = repo.create(organization_in)
+    except ConflictError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    logging.info(f"User {current_user['uid']} registered organization {organization.organization_id}")
+    return organization
+
+
+@router.get("", response_model=Page[schemas.Organization], response_model_by_alias=False)
+def list_organizations(
+    page: PageRequest = Depends(pagination(default_limit=100, max_limit=500)),
+    repo: OrganizationRepository = Depends(get_organization_repository),
+    current_user: Dict = Depends(require_roles(PLATFORM_ADMIN))
+):
+    """
+    Retrieve every organization, by name.
+    """
+    return paginate(page, lambda after, limit: repo.list(limit=limit, after=after), lambda organization: organization.organization_id)
+
+
+@router.get("/{organizationId}", response_model=schemas.Organization, response_model_by_alias=False)
+def get_organization(
+    organizationId: str,
+    repo: OrganizationRepository = Depends(get_organization_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve an organization by ID. Members may read their own.
+    """
+    _check_member(organizationId, current_user)
+    organization = repo.get(organizationId)
+    if not organization:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Organization not found")
+    return organization
+
+
+@router.patch("/{organizationId}", response_model=schemas.Organization, response_model_by_alias=False)
+def update_organization(
+    organizationId: str,
+    organization_in: schemas.OrganizationUpdate,
+    request: Request,
+    repo: OrganizationRepository = Depends(get_organization_repository),
+    current_user: Dict = Depends(require_roles(PLATFORM_ADMIN, ADMIN))
+):
+    """
+    Update an organization's details. Its administrators may update their own.
+    `allowedSourceRanges` restricts where its admin and integration requests
+    may come from; ranges that exclude the caller's own address are refused,
+    so an administrator cannot lock themselves out.
+    """
+    _check_member(organizationId, current_user)
+    ranges = organization_in.allowed_source_ranges
+    if ranges and not address_allowed(client_address(request.scope, get_settings().trusted_proxy_hops), parse_ranges(ranges)):
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="allowedSourceRanges must include the address you are calling from")
+    try:
+        return repo.update(organizationId, organization_in)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Organization not found")
+
+
+def _check_exists(organization_id: str, repo: OrganizationRepository) -> None:
+    if not repo.get(organization_id):
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Organization not found")
+
+
+@router.get("/{organizationId}/retention", response_model=schemas.RetentionPolicy, response_model_by_alias=False)
+def get_retention_policy(
+    organizationId: str,
+    repo: OrganizationRepository = Depends(get_organization_repository),
+    policies: RetentionPolicyRepository = Depends(get_retention_policy_repository),
+    current_user: Dict = Depends(require_roles(PLATFORM_ADMIN, ADMIN))
+):
+    """
+    Retrieve how long the organization keeps each category of data, and
+    whether each rule is its own or the deployment default.
+    """
+    _check_member(organizationId, current_user)
+    _check_exists(organizationId, repo)
+    return effective_policy(organizationId, policies.get(organizationId))
+
+
+@router.patch("/{organizationId}/retention", response_model=schemas.RetentionPolicy, response_model_by_alias=False)
+def update_retention_policy(
+    organizationId: str,
+    policy_in: schemas.RetentionPolicyUpdate,
+    repo: OrganizationRepository = Depends(get_organization_repository),
+    policies: RetentionPolicyRepository = Depends(get_retention_policy_repository),
+    current_user: Dict = Depends(require_roles(PLATFORM_ADMIN, ADMIN))
+):
+    """
+    Set the organization's own rules for some categories; set a category to
+    null to follow the deployment default again. Shortening a period deletes
+    or anonymizes the data now past it on the next daily run.
+    """
+    _check_member(organizationId, current_user)
+    _check_exists(organizationId, repo)
+    stored = policies.update(organizationId, policy_in, current_user["uid"])
+    changed = ", ".join(sorted(policy_in.model_fields_set)) or "nothing"
+    logging.info(f"User {current_user['uid']} updated the retention policy of organization {organizationId} ({changed})")
+    return effective_policy(organizationId, stored)
+
+
+@router.get("/{organizationId}/retention/purges", response_model=Page[schemas.PurgeManifest], response_model_by_alias=False)
+def list_purge_manifests(
+    organizationId: str,
+    category: Optional[schemas.RetentionCategory] = Query(None),
+    page: PageRequest = Depends(pagination(default_limit=50, max_limit=200)),
+    repo: OrganizationRepository = Depends(get_organization_repository),
+    manifests: PurgeManifestRepository = Depends(get_purge_manifest_repository),
+    current_user: Dict = Depends(require_roles(PLATFORM_ADMIN, ADMIN))
+):
+    """
+    Retrieve the manifests of the retention job's runs for the organization,
+    most recent first: per category, the cutoff, whether data was deleted or
+    anonymized, and how many records of each collection.
+    """
+    _check_member(organizationId, current_user)
+    _check_exists(organizationId, repo)
+    with acting_for(organizationId):
+        return paginate(page, lambda after, limit: manifests.list(category=category, limit=limit, after=after), lambda manifest: manifest.manifest_id)
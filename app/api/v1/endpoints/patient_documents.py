@@ -0,0 +1,214 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, status, Response
+from datetime import datetime, timedelta, timezone
+from typing import List, Dict, Optional
+import logging
+import uuid
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_event_publisher, get_patient_document_repository, get_patient_repository, get_task_queue
+from app.core.config import get_settings
+from app.core.storage import delete_blob, get_blob, signed_download_url, signed_upload_url
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.repositories.base import NotFoundError
+from app.repositories.patient_documents import PatientDocumentRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.transactions import transactional
+from app.scanning.scanner import get_scanner
+from app.tasks.jobs import DOCUMENT_SCAN_TASK
+from app.tasks.queue import TaskQueue
+
+router = APIRouter()
+settings = get_settings()
+
+PATIENT_DOCUMENTS_BUCKET = settings.patient_documents_bucket
+MAX_BYTES = settings.patient_document_max_bytes
+UPLOAD_URL_TTL_SECONDS = settings.patient_document_upload_url_ttl_seconds
+DOWNLOAD_URL_TTL_SECONDS = settings.patient_document_download_url_ttl_seconds
+
+
+def _require_bucket() -> str:
+    if not PATIENT_DOCUMENTS_BUCKET:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="Document storage is not configured (PATIENT_DOCUMENTS_BUCKET)")
+    return PATIENT_DOCUMENTS_BUCKET
+
+
+def _require_scanner() -> None:
+    # Unscanned files are never made available, so none are accepted.
+    if get_scanner() is None:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="Document scanning is not configured (DOCUMENT_SCANNER)")
+
+
+def _ensure_patient_exists(patient_id: str, patients: PatientRepository):
+    if not patients.get(patient_id):
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+
+
+def _get_or_404(patient_id: str, document_id: str, repo: PatientDocumentRepository) -> schemas.PatientDocument:
+    document = repo.get(document_id)
+    if not document or document.patient_id != patient_id:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Document not found")
+    return document
+
+
+def _upload_mismatch(document: schemas.PatientDocument, blob) -> Optional[str]:
+    """Why the stored object is not the file that was announced, if it is not."""
+    if blob.size != document.size:
+        return f"Uploaded file is {blob.size} bytes, not the {document.size} announced"
+    if blob.md5_hash != document.checksum:
+        return "Uploaded file does not match the announced checksum"
+    if blob.content_type != document.content_type:
+        return f"Uploaded file has content type '{blob.content_type}', not '{document.content_type}'"
+    return None
+
+
+@router.post("/{patientId}/documents", response_model=schemas.PatientDocumentUpload, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_document(
+    patientId: str,
+    document_in: schemas.PatientDocumentCreate,
+    repo: PatientDocumentRepository = Depends(get_patient_document_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Attach a document, such as a discharge summary or referral letter, to a
+    patient's chart. Returns a signed URL to PUT the file to, with the
+    Content-Type and Content-MD5 headers it must be sent with; then confirm
+    the upload with `POST .../documents/{documentId}/complete`. Refused
+    with 503 until a malware scanner is configured.
+    """
+    bucket = _require_bucket()
+    _require_scanner()
+    _ensure_patient_exists(patientId, patients)
+    if document_in.size > MAX_BYTES:
+        raise HTTPException(status_code=status.HTTP_413_REQUEST_ENTITY_TOO_LARGE, detail=f"Documents can be at most {MAX_BYTES} bytes")
+
+    document_id = uuid.uuid4().hex
+    blob_name = f"patients/{patientId}/documents/{document_id}"
+    document = repo.create(patientId, document_id, document_in, blob_name, uploaded_by=current_user["uid"])
+    upload_url = signed_upload_url(bucket, blob_name, UPLOAD_URL_TTL_SECONDS, document.content_type, document.checksum)
+    logging.info(f"User {current_user['uid']} started uploading document {document_id} ({document.type}) for patient {patientId}")
+    return schemas.PatientDocumentUpload(
+        document=document,
+        upload_url=upload_url,
+        upload_headers={"Content-Type": document.content_type, "Content-MD5": document.checksum},
+        expires_at=datetime.now(timezone.utc) + timedelta(seconds=UPLOAD_URL_TTL_SECONDS),
+    )
+
+
+@router.post("/{patientId}/documents/{documentId}/complete", response_model=schemas.PatientDocument, response_model_by_alias=False)
+@transactional
+def complete_document_upload(
+    patientId: str,
+    documentId: str,
+    repo: PatientDocumentRepository = Depends(get_patient_document_repository),
+    tasks: TaskQueue = Depends(get_task_queue),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Confirm that the file was uploaded. The stored file must have the
+    announced size, checksum and content type; otherwise it is discarded and
+    422 returned, and the upload can be retried while its URL is valid.
+    Returns 409 Conflict if nothing was uploaded yet. The document is then
+    `pending-scan` until the malware scan makes it `available` or
+    `quarantined`; without a scanner it stays pending.
+    """
+    bucket = _require_bucket()
+    document = _get_or_404(patientId, documentId, repo)
+    if document.status != "pending":
+        return document
+
+    blob = get_blob(bucket, document.blob_name)
+    if blob is None:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="The file has not been uploaded yet")
+    mismatch = _upload_mismatch(document, blob)
+    if mismatch:
+        delete_blob(bucket, document.blob_name)
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail=mismatch)
+    try:
+        document = repo.mark_uploaded(documentId, status="pending-scan")
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Document not found")
+    if get_scanner() is not None:
+        tasks.enqueue(DOCUMENT_SCAN_TASK, {"documentId": documentId}, task_id=f"document-scan-{documentId}")
+    else:
+        logging.error(f"Document {documentId} awaits a malware scan but DOCUMENT_SCANNER is not set", extra={"report_error": True})
+    logging.info(f"User {current_user['uid']} uploaded document {documentId} for patient {patientId}")
+    return document
+
+
+@router.get("/{patientId}/documents", response_model=List[schemas.PatientDocument], response_model_by_alias=False)
+def list_documents(
+    patientId: str,
+    type: Optional[schemas.PatientDocumentType] = None,
+    status_filter: Optional[schemas.PatientDocumentStatus] = Query(None, alias="status"),
+    repo: PatientDocumentRepository = Depends(get_patient_document_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve the documents attached to a patient's chart, optionally only
+    those of one type or status.
+    """
+    _ensure_patient_exists(patientId, patients)
+    return repo.list(patientId, type=type, status=status_filter)
+
+
+@router.get("/{patientId}/documents/{documentId}", response_model=schemas.PatientDocument, response_model_by_alias=False)
+def get_document(
+    patientId: str,
+    documentId: str,
+    repo: PatientDocumentRepository = Depends(get_patient_document_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a document's metadata by ID.
+    """
+    return _get_or_404(patientId, documentId, repo)
+
+
+@router.get("/{patientId}/documents/{documentId}/download", response_model=schemas.PatientDocumentDownload, response_model_by_alias=False)
+def download_document(
+    patientId: str,
+    documentId: str,
+    repo: PatientDocumentRepository = Depends(get_patient_document_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Get a short-lived signed URL to download the document from. Returns 409
+    Conflict unless the document is available: while the upload has not been
+    confirmed or scanned, and when the file was quarantined.
+    """
+    bucket = _require_bucket()
+    document = _get_or_404(patientId, documentId, repo)
+    if document.status != "available":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=f"The document is {document.status} and cannot be downloaded")
+    url = signed_download_url(bucket, document.blob_name, DOWNLOAD_URL_TTL_SECONDS)
+    logging.info(f"User {current_user['uid']} downloaded document {documentId} of patient {patientId}")
+    return schemas.PatientDocumentDownload(url=url, expires_at=datetime.now(timezone.utc) + timedelta(seconds=DOWNLOAD_URL_TTL_SECONDS))
+
+
+@router.delete("/{patientId}/documents/{documentId}", status_code=status.HTTP_204_NO_CONTENT)
+@transactional
+def delete_document(
+    patientId: str,
+    documentId: str,
+    repo: PatientDocumentRepository = Depends(get_patient_document_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Remove a document from the chart, deleting the file too. This cannot be
+    undone.
+    """
+    bucket = _require_bucket()
+    document = _get_or_404(patientId, documentId, repo)
+    try:
+        repo.delete(documentId)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Document not found")
+    delete_blob(bucket, document.blob_name)
+    events.emit("document.deleted", f"patients/{patientId}/documents/{documentId}", patientId=patientId, documentId=documentId)
+    logging.info(f"User {current_user['uid']} deleted document {documentId} of patient {patientId}")
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
@@ -0,0 +1,195 @@
+from fastapi import APIRouter, Depends, HTTPException, status, Response
+from typing import Any, Dict, List
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_event_publisher, get_patient_record_repositories, get_patient_repository
+from app.authz.roles import ADMIN, CLINICIAN, COORDINATOR
+from app.dependencies.auth import get_current_user, include_deleted, require_roles
+from app.events.publisher import EventPublisher
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.base import ConflictError, NotFoundError, including_deleted
+from app.repositories.patients import PATIENT_SEARCH, PatientRepository
+from app.repositories.transactions import transactional
+from app.search.filters import Search, search_query
+from app.services.patient_matching import merge_records, potential_duplicates
+
+router = APIRouter()
+
+@router.post("", response_model=schemas.Patient, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_patient(
+    *,
+    patient_in: schemas.PatientCreate,
+    repo: PatientRepository = Depends(get_patient_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Register a new patient. Returns 409 Conflict if the MRN is already in use.
+    """
+    try:
+        patient = repo.create(patient_in)
+    except ConflictError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    events.emit("patient.created", f"patients/{patient.patient_id}", patient)
+    logging.info(f"User {current_user['uid']} created patient {patient.patient_id}")
+    return patient
+
+
+@router.get("", response_model=Page[schemas.Patient], response_model_by_alias=False)
+def list_patients(
+    page: PageRequest = Depends(pagination(default_limit=30, max_limit=100)),
+    search: Search = Depends(search_query(PATIENT_SEARCH)),
+    include: bool = Depends(include_deleted),
+    repo: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a list of patients, ordered by family name unless `sort` says
+    otherwise. Filter by `name`, `givenName` or `familyName` (e.g.
+    `name=contains:smith`), `dob` (e.g. `dob=ge1980-01-01`), `sex`, `mrn`,
+    `createdAt` or `updatedAt`. Deleted patients are left out unless an
+    administrator asks for `includeDeleted=true`.
+    """
+    with including_deleted(include):
+        return paginate(page, lambda after, limit: repo.list(limit=limit, after=after, search=search), lambda patient: patient.patient_id)
+
+
+@router.get("/{patientId}", response_model=schemas.Patient, response_model_by_alias=False)
+def get_patient(
+    patientId: str,
+    include: bool = Depends(include_deleted),
+    repo: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a single patient by ID.
+    """
+    with including_deleted(include):
+        patient = repo.get(patientId)
+    if not patient:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+    return patient
+
+
+@router.patch("/{patientId}", response_model=schemas.Patient, response_model_by_alias=False)
+@transactional
+def update_patient(
+    patientId: str,
+    patient_in: schemas.PatientUpdate,
+    repo: PatientRepository = Depends(get_patient_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Update a patient. Only the fields present in the request body are changed.
+    """
+    try:
+        patient = repo.update(patientId, patient_in)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+    except ConflictError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    events.emit("patient.updated", f"patients/{patientId}", patient)
+    logging.info(f"User {current_user['uid']} updated patient {patientId}")
+    return patient
+
+
+@router.delete("/{patientId}", status_code=status.HTTP_204_NO_CONTENT)
+@transactional
+def delete_patient(
+    patientId: str,
+    repo: PatientRepository = Depends(get_patient_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Delete a patient. The record is kept, marked deleted, and can be restored;
+    its MRN stays reserved meanwhile.
+    """
+    try:
+        repo.delete(patientId, deleted_by=current_user["uid"])
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+    events.emit("patient.deleted", f"patients/{patientId}", patientId=patientId)
+    logging.info(f"User {current_user['uid']} deleted patient {patientId}")
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+@router.post("/{patientId}/restore", response_model=schemas.Patient, response_model_by_alias=False)
+@transactional
+def restore_patient(
+    patientId: str,
+    repo: PatientRepository = Depends(get_patient_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(require_roles(ADMIN))
+):
+    """
+    Restore a deleted patient. Administrators only. A duplicate merged into
+    another patient cannot be restored, as its records have moved.
+    """
+    with including_deleted():
+        deleted = repo.get(patientId)
+    if deleted and deleted.merged_into:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=f"Patient was merged into {deleted.merged_into}")
+    try:
+        patient = repo.restore(patientId)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+    events.emit("patient.restored", f"patients/{patientId}", patient)
+    logging.info(f"User {current_user['uid']} restored patient {patientId}")
+    return patient
+
+
+@router.get("/{patientId}/potential-duplicates", response_model=List[schemas.PotentialDuplicate], response_model_by_alias=False)
+def list_potential_duplicates(
+    patientId: str,
+    repo: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(require_roles(CLINICIAN, COORDINATOR, ADMIN))
+):
+    """
+    Other patients who may be the same person, certain matches first, then
+    by score. Candidates share the patient's date of birth, family name or
+    phone number, and are compared on names, date of birth, sex, phone
+    number and address; see `grade` and the per-field `fields` for why.
+    Staff only, as it returns other patients' records.
+    """
+    patient = repo.get(patientId)
+    if not patient:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+    return potential_duplicates(patient, repo.find_candidates(patient))
+
+
+@router.post("/{patientId}/merge", response_model=schemas.PatientMergeResult, response_model_by_alias=False)
+@transactional
+def merge_patient(
+    patientId: str,
+    merge_in: schemas.PatientMerge,
+    repo: PatientRepository = Depends(get_patient_repository),
+    records: Dict[str, Any] = Depends(get_patient_record_repositories),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(require_roles(ADMIN))
+):
+    """
+    Merge a duplicate into this patient. Administrators only. Every
+    clinical record of `sourcePatientId` (appointments, encounters,
+    observations, medications, documents, ...) moves to this patient, and
+    the duplicate is deleted with `mergedInto` set; its MRN stays reserved.
+    This patient's own details are not changed, so copy anything to keep
+    from the duplicate with PATCH first. A merge that failed part way can
+    be sent again.
+    """
+    source_patient_id = merge_in.source_patient_id
+    if source_patient_id == patientId:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="A patient cannot be merged into itself")
+    patient = repo.get(patientId)
+    if not patient:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+    if not repo.get(source_patient_id):
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Source patient not found")
+    moved = merge_records(records, source_patient_id, patientId)
+    repo.mark_merged(source_patient_id, merged_into=patientId, merged_by=current_user["uid"])
+    events.emit("patient.merged", f"patients/{patientId}", patientId=patientId, sourcePatientId=source_patient_id, moved=moved)
+    logging.info(f"User {current_user['uid']} merged patient {source_patient_id} into {patientId} ({sum(moved.values())} records moved)")
+    return schemas.PatientMergeResult(patient=patient, source_patient_id=source_patient_id, moved=moved)
@@ -0,0 +1,132 @@
+from fastapi import APIRouter, Depends, HTTPException, status, Response
+from typing import Dict, Optional
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_event_publisher, get_practitioner_repository
+from app.authz.roles import ADMIN
+from app.dependencies.auth import get_current_user, include_deleted, require_roles
+from app.events.publisher import EventPublisher
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.base import ConflictError, NotFoundError, including_deleted
+from app.repositories.practitioners import PractitionerRepository
+from app.repositories.transactions import transactional
+
+router = APIRouter()
+
+@router.post("", response_model=schemas.Practitioner, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def create_practitioner(
+    *,
+    practitioner_in: schemas.PractitionerCreate,
+    repo: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Register a practitioner. Returns 409 Conflict if the NPI is already registered.
+    """
+    try:
+        practitioner = repo.create(practitioner_in)
+    except ConflictError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    events.emit("practitioner.created", f"practitioners/{practitioner.practitioner_id}", practitioner)
+    logging.info(f"User {current_user['uid']} created practitioner {practitioner.practitioner_id}")
+    return practitioner
+
+
+@router.get("", response_model=Page[schemas.Practitioner], response_model_by_alias=False)
+def list_practitioners(
+    specialty: Optional[str] = None,
+    page: PageRequest = Depends(pagination(default_limit=30, max_limit=100)),
+    include: bool = Depends(include_deleted),
+    repo: PractitionerRepository = Depends(get_practitioner_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve practitioners, optionally filtered by specialty.
+    """
+    with including_deleted(include):
+        return paginate(
+            page,
+            lambda after, limit: repo.list(limit=limit, after=after, specialty=specialty),
+            lambda practitioner: practitioner.practitioner_id,
+        )
+
+
+@router.get("/{practitionerId}", response_model=schemas.Practitioner, response_model_by_alias=False)
+def get_practitioner(
+    practitionerId: str,
+    include: bool = Depends(include_deleted),
+    repo: PractitionerRepository = Depends(get_practitioner_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a single practitioner by ID.
+    """
+    with including_deleted(include):
+        practitioner = repo.get(practitionerId)
+    if not practitioner:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Practitioner not found")
+    return practitioner
+
+
+@router.patch("/{practitionerId}", response_model=schemas.Practitioner, response_model_by_alias=False)
+@transactional
+def update_practitioner(
+    practitionerId: str,
+    practitioner_in: schemas.PractitionerUpdate,
+    repo: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Update a practitioner. The NPI cannot be changed once registered.
+    """
+    try:
+        practitioner = repo.update(practitionerId, practitioner_in)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Practitioner not found")
+    events.emit("practitioner.updated", f"practitioners/{practitionerId}", practitioner)
+    return practitioner
+
+
+@router.delete("/{practitionerId}", status_code=status.HTTP_204_NO_CONTENT)
+@transactional
+def delete_practitioner(
+    practitionerId: str,
+    repo: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Delete a practitioner. The record is kept, marked deleted, and can be
+    restored; its NPI stays registered meanwhile.
+    """
+    try:
+        repo.delete(practitionerId, deleted_by=current_user["uid"])
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Practitioner not found")
+    events.emit("practitioner.deleted", f"practitioners/{practitionerId}", practitionerId=practitionerId)
+    logging.info(f"User {current_user['uid']} deleted practitioner {practitionerId}")
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+@router.post("/{practitionerId}/restore", response_model=schemas.Practitioner, response_model_by_alias=False)
+@transactional
+def restore_practitioner(
+    practitionerId: str,
+    repo: PractitionerRepository = Depends(get_practitioner_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(require_roles(ADMIN))
+):
+    """
+    Restore a deleted practitioner. Administrators only.
+    """
+    try:
+        practitioner = repo.restore(practitionerId)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Practitioner not found")
+    events.emit("practitioner.restored", f"practitioners/{practitionerId}", practitioner)
+    logging.info(f"User {current_user['uid']} restored practitioner {practitionerId}")
+    return practitioner
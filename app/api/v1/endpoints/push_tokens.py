@@ -0,0 +1,116 @@
+from fastapi import APIRouter, Depends, HTTPException, status, Response
+from typing import List, Dict
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_patient_repository, get_push_token_repository
+from app.dependencies.auth import get_current_user
+from app.repositories.base import ConflictError, NotFoundError
+from app.repositories.patients import PatientRepository
+from app.repositories.push_tokens import PushTokenRepository
+from app.repositories.transactions import transactional
+
+router = APIRouter()
+
+
+def _get_patient_or_404(patient_id: str, patients: PatientRepository) -> schemas.Patient:
+    patient = patients.get(patient_id)
+    if not patient:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+    return patient
+
+
+@router.post("/{patientId}/push-tokens", response_model=schemas.PushToken, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def register_push_token(
+    patientId: str,
+    token_in: schemas.PushTokenCreate,
+    repo: PushTokenRepository = Depends(get_push_token_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Register the patient app's FCM token, so the device receives the
+    patient's push notifications. The app registers whenever it starts or
+    FCM issues it a new token; a token registered before, by this or another
+    patient, now belongs to this patient. Tokens FCM reports as no longer
+    valid are removed when a notification is next sent.
+    """
+    _get_patient_or_404(patientId, patients)
+    try:
+        push_token = repo.register(patientId, token_in)
+    except ConflictError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    logging.info(f"User {current_user['uid']} registered {push_token.platform} push token {push_token.push_token_id} for patient {patientId}")
+    return push_token
+
+
+@router.get("/{patientId}/push-tokens", response_model=List[schemas.PushToken], response_model_by_alias=False)
+def list_push_tokens(
+    patientId: str,
+    repo: PushTokenRepository = Depends(get_push_token_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve the devices registered for the patient's push notifications,
+    most recently registered first. The tokens themselves are not returned.
+    """
+    _get_patient_or_404(patientId, patients)
+    return sorted(repo.list(patientId), key=lambda t: t.registered_at, reverse=True)
+
+
+@router.delete("/{patientId}/push-tokens/{pushTokenId}", status_code=status.HTTP_204_NO_CONTENT)
+@transactional
+def delete_push_token(
+    patientId: str,
+    pushTokenId: str,
+    repo: PushTokenRepository = Depends(get_push_token_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Unregister a device, e.g. when the patient signs out of the app on it.
+    """
+    push_token = repo.get(pushTokenId)
+    if not push_token or push_token.patient_id != patientId:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Push token not found")
+    try:
+        repo.delete(pushTokenId)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Push token not found")
+    logging.info(f"User {current_user['uid']} deleted push token {pushTokenId} of patient {patientId}")
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+@router.get("/{patientId}/push-preferences", response_model=schemas.PushPreferences, response_model_by_alias=False)
+def get_push_preferences(
+    patientId: str,
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve which categories of push notification the patient receives.
+    """
+    return _get_patient_or_404(patientId, patients).contact_preferences.push
+
+
+@router.patch("/{patientId}/push-preferences", response_model=schemas.PushPreferences, response_model_by_alias=False)
+@transactional
+def update_push_preferences(
+    patientId: str,
+    preferences_in: schemas.PushPreferencesUpdate,
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Turn categories of push notification on or off: `messages` (such as a
+    result being ready), `appointments` (confirmations and reminders) and
+    `abnormalReadings`. Categories left out are unchanged. Notifications
+    already queued in a category turned off are not sent.
+    """
+    try:
+        patient = patients.update_push_preferences(patientId, preferences_in)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+    logging.info(f"User {current_user['uid']} updated the push preferences of patient {patientId}")
+    return patient.contact_preferences.push
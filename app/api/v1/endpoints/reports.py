@@ -0,0 +1,202 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, Request, Response, status
+from typing import Callable, Dict, Iterator, List, Literal, Optional
+from datetime import date, datetime, timedelta, timezone
+from zoneinfo import ZoneInfo, ZoneInfoNotFoundError
+import logging
+
+from pydantic import BaseModel
+
+from app.api.representations import CSV, JSON, negotiate
+from app.api.v1 import schemas
+from app.api.v1.deps import (
+    get_appointment_repository,
+    get_observation_repository,
+    get_organization_repository,
+    get_refill_request_repository,
+)
+from app.core.config import get_settings
+from app.dependencies.auth import get_current_user
+from app.repositories.appointments import AppointmentRepository
+from app.repositories.medications import RefillRequestRepository
+from app.repositories.observations import ObservationRepository
+from app.repositories.organizations import OrganizationRepository
+from app.services.medications import OPEN_REFILL_STATUSES
+from app.services.reports import (
+    DEFAULT_ADHERENCE_THRESHOLD,
+    appointment_volume,
+    no_show_rates,
+    open_refill_requests,
+    period_bounds,
+    telemetry_adherence,
+    to_csv,
+)
+from app.tenancy.context import acting_for, claimed_organization, current_tenant, is_platform_admin
+
+router = APIRouter()
+
+# --- Configuration ---
+settings = get_settings()
+TENANCY_ENABLED = settings.tenancy_enabled
+REPORTS_TIMEZONE = settings.reports_timezone
+REPORTS_MAX_DAYS = settings.reports_max_days
+DEFAULT_PERIOD_DAYS = 30
+
+ReportFormat = Literal["json", "csv"]
+
+
+class ReportScope:
+    """
+    The period, timezone and organizations of a report request. Reports
+    cover the caller's organization. Platform administrators name one with
+    `organizationId` (or X-Organization-Id), and without one get a row set
+    per organization; with tenancy off there is a single unscoped one.
+    """
+
+    def __init__(
+        self,
+        request: Request,
+        from_date: Optional[date] = Query(None, alias="from", description="First day of the period; 30 days before `to` by default."),
+        to_date: Optional[date] = Query(None, alias="to", description="Last day of the period, inclusive; today by default."),
+        timezone_name: Optional[str] = Query(None, alias="timezone", description="IANA timezone days are counted in; REPORTS_TIMEZONE by default."),
+        organizationId: Optional[str] = None,
+        format: Optional[ReportFormat] = Query(None, description="`csv` for a spreadsheet; `Accept: text/csv` does the same."),
+        organizations: OrganizationRepository = Depends(get_organization_repository),
+        current_user: Dict = Depends(get_current_user),
+    ):
+        try:
+            self.zone = ZoneInfo(timezone_name or REPORTS_TIMEZONE)
+        except (ZoneInfoNotFoundError, ValueError):
+            raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail=f"Unknown timezone '{timezone_name}'")
+        self.now = datetime.now(timezone.utc)
+        self.end = to_date or self.now.astimezone(self.zone).date()
+        self.start = from_date or self.end - timedelta(days=DEFAULT_PERIOD_DAYS - 1)
+        if self.start > self.end:
+            raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="`from` must not be after `to`")
+        if (self.end - self.start).days + 1 > REPORTS_MAX_DAYS:
+            raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail=f"Reports cover at most {REPORTS_MAX_DAYS} days")
+        self.period_from, self.period_to = period_bounds(self.start, self.end, self.zone)
+        self.csv = format == "csv" or (format is None and negotiate(request.headers.get("accept"), (JSON, CSV)) == CSV)
+        self.organization_ids = self._organizations(organizationId, organizations, current_user)
+        self.current_user = current_user
+
+    @staticmethod
+    def _organizations(organization_id: Optional[str], organizations: OrganizationRepository, current_user: Dict) -> List[Optional[str]]:
+        if organization_id:
+            if not is_platform_admin(current_user) and claimed_organization(current_user) != organization_id:
+                raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="You do not have permission to access this organization")
+            return [organization_id]
+        tenant = current_tenant()
+        if tenant or not TENANCY_ENABLED:
+            return [tenant]
+        ids, after = [], None
+        while True:
+            page = organizations.list(limit=100, after=after)
+            ids.extend(organization.organization_id for organization in page)
+            if len(page) < 100:
+                return ids
+            after = page[-1].organization_id
+
+    def each_organization(self) -> Iterator[Optional[str]]:
+        """Yields each organization of the report, with repository access scoped to it."""
+        for organization_id in self.organization_ids:
+            with acting_for(organization_id):
+                yield organization_id
+
+    def respond(self, name: str, report_model, row_model, rows: List[BaseModel]):
+        logging.info(f"User {self.current_user['uid']} ran the {name} report for {self.start} to {self.end} ({len(rows)} rows)")
+        if self.csv:
+            filename = f"{name}_{self.start.isoformat()}_{self.end.isoformat()}.csv"
+            return Response(
+                content=to_csv(row_model, rows), media_type=f"{CSV}; charset=utf-8",
+                headers={"Content-Disposition": f'attachment; filename="{filename}"'},
+            )
+        return report_model(
+            period_start=self.start, period_end=self.end, timezone=self.zone.key, generated_at=self.now, rows=rows,
+        )
+
+
+def _rows(scope: ReportScope, build: Callable[[Optional[str]], List[BaseModel]]) -> List[BaseModel]:
+    rows = []
+    for organization_id in scope.each_organization():
+        rows.extend(build(organization_id))
+    return rows
+
+
+@router.get("/appointment-volume", response_model=schemas.AppointmentVolumeReport, response_model_by_alias=False)
+def appointment_volume_report(
+    scope: ReportScope = Depends(),
+    appointments: AppointmentRepository = Depends(get_appointment_repository)
+):
+    """
+    Appointments per clinic per day, by the day they start on, with how
+    many of them are still booked, arrived, fulfilled or cancelled. Days
+    without appointments are left out.
+    """
+    rows = _rows(scope, lambda organization_id: appointment_volume(
+        organization_id, appointments.stream_starting_between(scope.period_from, scope.period_to), scope.zone,
+    ))
+    return scope.respond("appointment-volume", schemas.AppointmentVolumeReport, schemas.AppointmentVolumeRow, rows)
+
+
+@router.get("/no-show-rates", response_model=schemas.NoShowRateReport, response_model_by_alias=False)
+def no_show_rate_report(
+    scope: ReportScope = Depends(),
+    groupBy: Literal["organization", "practitioner"] = "organization",
+    appointments: AppointmentRepository = Depends(get_appointment_repository)
+):
+    """
+    The share of the period's appointments, once they have ended, whose
+    patient never came: those still booked, never marked arrived or
+    cancelled. Cancelled appointments and those not over yet are left out.
+    `groupBy=practitioner` gives a row per practitioner.
+    """
+    rows = _rows(scope, lambda organization_id: no_show_rates(
+        organization_id, appointments.stream_starting_between(scope.period_from, scope.period_to), scope.now,
+        by_practitioner=groupBy == "practitioner",
+    ))
+    return scope.respond("no-show-rates", schemas.NoShowRateReport, schemas.NoShowRateRow, rows)
+
+
+@router.get("/telemetry-adherence", response_model=schemas.TelemetryAdherenceReport, response_model_by_alias=False)
+def telemetry_adherence_report(
+    scope: ReportScope = Depends(),
+    code: Optional[str] = Query(None, description="Only readings of this LOINC code, e.g. '59408-5' (SpO2)."),
+    threshold: float = Query(DEFAULT_ADHERENCE_THRESHOLD, gt=0, le=1, description="Share of days with readings at which a patient counts as adherent."),
+    observations: ObservationRepository = Depends(get_observation_repository)
+):
+    """
+    How regularly patients' devices send readings: the average share of
+    the period's days on which each patient with any reading in it sent
+    one, and how many reached `threshold`. Patients who sent nothing in the
+    period are not counted.
+    """
+    rows = _rows(scope, lambda organization_id: [telemetry_adherence(
+        organization_id, observations.stream_taken_between(scope.period_from, scope.period_to),
+        scope.start, scope.end, scope.zone, code=code, threshold=threshold,
+    )])
+    return scope.respond("telemetry-adherence", schemas.TelemetryAdherenceReport, schemas.TelemetryAdherenceRow, rows)
+
+
+@router.get("/open-refill-requests", response_model=schemas.OpenRefillRequestReport, response_model_by_alias=False)
+def open_refill_request_report(
+    scope: ReportScope = Depends(),
+    refill_requests: RefillRequestRepository = Depends(get_refill_request_repository)
+):
+    """
+    Refill requests made in the period that are still waiting for a
+    decision, by status, with the oldest and their average age now. Widen
+    the period to include older requests.
+    """
+    def build(organization_id: Optional[str]) -> List[BaseModel]:
+        made_in_period = (
+            request for request in refill_requests.stream_by_status(sorted(OPEN_REFILL_STATUSES))
+            if scope.period_from <= _utc(request.created_at) < scope.period_to
+        )
+        return open_refill_requests(organization_id, made_in_period, scope.now, OPEN_REFILL_STATUSES)
+
+    rows = _rows(scope, build)
+    return scope.respond("open-refill-requests", schemas.OpenRefillRequestReport, schemas.OpenRefillRequestRow, rows)
+
+
+def _utc(at: datetime) -> datetime:
+    return at if at.tzinfo else at.replace(tzinfo=timezone.utc)
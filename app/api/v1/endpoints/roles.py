@@ -0,0 +1,115 @@
+from fastapi import APIRouter, Depends, HTTPException, Response, status
+from typing import Dict, List
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_custom_role_repository
+from app.audit.context import annotate
+from app.authz.custom_roles import get_custom_role_cache
+from app.authz.roles import ADMIN, GATE_ROLES, ROLE_PERMISSIONS
+from app.dependencies.auth import require_roles
+from app.repositories.base import ConflictError, NotFoundError
+from app.repositories.custom_roles import CustomRoleRepository
+from app.tenancy.context import PLATFORM_ADMIN, current_tenant
+
+router = APIRouter()
+
+# Administrators define the roles of their own organization; platform
+# administrators those of the organization they act for.
+ROLE_ADMIN_ROLES = (ADMIN, PLATFORM_ADMIN)
+
+
+def _changed(name: str) -> None:
+    """Applies a change to this worker's requests at once; other workers pick it up within CUSTOM_ROLE_CACHE_SECONDS."""
+    get_custom_role_cache().invalidate(current_tenant())
+    annotate(resource_type="roles", resource_id=name)
+
+
+@router.post("", response_model=schemas.CustomRole, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+def create_role(
+    *,
+    role_in: schemas.CustomRoleCreate,
+    repo: CustomRoleRepository = Depends(get_custom_role_repository),
+    current_user: Dict = Depends(require_roles(*ROLE_ADMIN_ROLES))
+):
+    """
+    Define a role from granular permissions, e.g. a `lab-technician` with
+    `patients:read` and `patients.lab-results:write` who is denied
+    `patients.documents:read`. Assign it to staff at /admin/users. The
+    names of the built-in roles cannot be used.
+    """
+    if role_in.name in ROLE_PERMISSIONS or role_in.name in GATE_ROLES:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=f"'{role_in.name}' is a built-in role")
+    try:
+        role = repo.create(role_in, current_user["uid"])
+    except ConflictError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    _changed(role.name)
+    logging.warning(f"User {current_user['uid']} defined role {role.name}: {', '.join(role.permissions)}; denied {', '.join(role.denied) or 'nothing'}")
+    return role
+
+
+@router.get("", response_model=List[schemas.CustomRole], response_model_by_alias=False)
+def list_roles(
+    repo: CustomRoleRepository = Depends(get_custom_role_repository),
+    current_user: Dict = Depends(require_roles(*ROLE_ADMIN_ROLES))
+):
+    """
+    Retrieve the organization's custom roles, by name.
+    """
+    return repo.list()
+
+
+@router.get("/{name}", response_model=schemas.CustomRole, response_model_by_alias=False)
+def get_role(
+    name: str,
+    repo: CustomRoleRepository = Depends(get_custom_role_repository),
+    current_user: Dict = Depends(require_roles(*ROLE_ADMIN_ROLES))
+):
+    """
+    Retrieve a custom role by name.
+    """
+    role = repo.get(name)
+    if not role:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Role not found")
+    return role
+
+
+@router.patch("/{name}", response_model=schemas.CustomRole, response_model_by_alias=False)
+def update_role(
+    name: str,
+    role_in: schemas.CustomRoleUpdate,
+    repo: CustomRoleRepository = Depends(get_custom_role_repository),
+    current_user: Dict = Depends(require_roles(*ROLE_ADMIN_ROLES))
+):
+    """
+    Change a custom role's description, permissions or denials. The lists
+    given replace the stored ones. Holders of the role are affected from
+    their next request.
+    """
+    try:
+        role = repo.update(name, role_in, current_user["uid"])
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Role not found")
+    _changed(name)
+    logging.warning(f"User {current_user['uid']} changed role {name}: {', '.join(role.permissions)}; denied {', '.join(role.denied) or 'nothing'}")
+    return role
+
+
+@router.delete("/{name}", status_code=status.HTTP_204_NO_CONTENT)
+def delete_role(
+    name: str,
+    repo: CustomRoleRepository = Depends(get_custom_role_repository),
+    current_user: Dict = Depends(require_roles(*ROLE_ADMIN_ROLES))
+):
+    """
+    Remove a custom role. Users who still hold it keep its name among their
+    roles, but it grants them nothing.
+    """
+    try:
+        repo.delete(name)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Role not found")
+    _changed(name)
+    logging.warning(f"User {current_user['uid']} deleted role {name}")
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
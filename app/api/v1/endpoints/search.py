@@ -0,0 +1,54 @@
+from fastapi import APIRouter, Depends, HTTPException, Query, status
+from typing import Dict, List, Optional
+import logging
+
+from app.api.v1 import schemas
+from app.audit.context import annotate
+from app.dependencies.auth import get_current_user
+from app.search.documents import visibility
+from app.search.index import SearchIndex, SearchIndexError, SearchQuery, get_search_index
+from app.tenancy.context import current_tenant
+
+router = APIRouter()
+
+MAX_SEARCH_LIMIT = 100
+# Relevance-ranked pages are read from the top, so deep pages get costly.
+MAX_SEARCH_OFFSET = 1000
+
+
+@router.get("", response_model=schemas.SearchResults, response_model_by_alias=False)
+def search_records(
+    q: str = Query(..., min_length=1, max_length=200, description="Words to find; each must match, the last one as a prefix."),
+    kind: Optional[List[schemas.SearchKind]] = Query(None, description="Only these kinds of record; repeat for several."),
+    patientId: Optional[str] = Query(None, description="Only this patient's records."),
+    limit: int = Query(20, ge=1, le=MAX_SEARCH_LIMIT),
+    offset: int = Query(0, ge=0, le=MAX_SEARCH_OFFSET),
+    index: Optional[SearchIndex] = Depends(get_search_index),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Search the text of encounters (reason, location, attached document
+    titles), care plans (title, description, goals and activities),
+    allergies (substance, reactions and notes) and the metadata of uploaded
+    patient documents, best match first, with the matched words marked in
+    `highlights`. Only records the caller may read are searched: those of
+    the resources their roles or API key scopes let them read, and a
+    patient's own records. Psychotherapy notes and file contents are not
+    searchable.
+    """
+    if index is None:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="Full-text search is not enabled (SEARCH_INDEX)")
+    seen = visibility(current_user, current_tenant())
+    if seen.empty:
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="You do not have permission to access this resource")
+    query = SearchQuery(q, seen, kinds=frozenset(kind) if kind else None, patient_id=patientId, limit=limit, offset=offset)
+    if not query.words:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="q must contain a word")
+    if patientId:
+        annotate(patient_id=patientId)
+    try:
+        results = index.search(query)
+    except SearchIndexError as e:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail=str(e))
+    logging.info(f"User {current_user['uid']} searched clinical text ({results.total} matches)")
+    return results
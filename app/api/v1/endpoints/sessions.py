@@ -0,0 +1,164 @@
+from fastapi import APIRouter, Depends, Header, HTTPException, Request, Response, status
+from typing import Dict, List, Optional
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_session_repository
+from app.audit.context import annotate, set_actor
+from app.auth.lockout import LockedOut, LoginGuard, address, get_login_guard
+from app.auth.sessions import SessionError, SessionManager, get_session_manager
+from app.auth.verifier import InvalidTokenError
+from app.authz.networks import client_address
+from app.core.config import get_settings
+from app.dependencies.auth import get_current_user
+from app.repositories.sessions import SessionRepository
+
+router = APIRouter()
+
+# The routes are under /auth, which AuthenticationMiddleware leaves open:
+# starting and refreshing a session are how a client gets a token. The
+# others check the caller's token themselves through `get_current_user`.
+
+
+def session_manager() -> SessionManager:
+    manager = get_session_manager()
+    if manager is None:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="Sessions are not configured (SESSION_SIGNING_KEY)")
+    return manager
+
+
+def login_guard() -> LoginGuard:
+    return get_login_guard()
+
+
+def _session_error(e: Exception) -> HTTPException:
+    return HTTPException(status_code=status.HTTP_401_UNAUTHORIZED, detail=str(e), headers={"WWW-Authenticate": "Bearer"})
+
+
+def locked_out_error(e: LockedOut) -> HTTPException:
+    return HTTPException(status_code=status.HTTP_429_TOO_MANY_REQUESTS, detail=str(e), headers={"Retry-After": str(e.retry_after)})
+
+
+@router.post("", response_model=schemas.SessionTokens, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+def start_portal_session(
+    *,
+    session_in: schemas.SessionCreate,
+    request: Request,
+    user_agent: Optional[str] = Header(None),
+    manager: SessionManager = Depends(session_manager),
+    guard: LoginGuard = Depends(login_guard),
+):
+    """
+    Sign in to the patient portal with a Firebase or OIDC ID token. Returns
+    an access token, valid for SESSION_ACCESS_TOKEN_TTL_SECONDS, and a
+    refresh token that exchanges for new tokens at /auth/sessions/refresh.
+    Repeated failures from an address are slowed down and then locked out,
+    with 429 and Retry-After.
+    """
+    source_ip = client_address(request.scope, get_settings().trusted_proxy_hops)
+    try:
+        guard.check(address(source_ip))
+    except LockedOut as e:
+        raise locked_out_error(e)
+    try:
+        claims, tokens = manager.start(
+            session_in.id_token,
+            device_label=session_in.device_label,
+            user_agent=user_agent,
+            source_ip=source_ip,
+        )
+    except InvalidTokenError as e:
+        guard.failed(address(source_ip))
+        raise _session_error(e)
+    set_actor(claims)
+    annotate(resource_type="sessions", resource_id=tokens.session.session_id)
+    return tokens
+
+
+@router.post("/refresh", response_model=schemas.SessionTokens, response_model_by_alias=False)
+def refresh_portal_session(
+    refresh_in: schemas.SessionRefresh,
+    request: Request,
+    manager: SessionManager = Depends(session_manager),
+    guard: LoginGuard = Depends(login_guard),
+):
+    """
+    Exchange a refresh token for a new access token and refresh token. Each
+    refresh token works once; using one again signs the session out.
+    Failures count towards the address's lockout like failed sign-ins.
+    """
+    source = address(client_address(request.scope, get_settings().trusted_proxy_hops))
+    try:
+        guard.check(source)
+    except LockedOut as e:
+        raise locked_out_error(e)
+    try:
+        tokens = manager.refresh(refresh_in.refresh_token)
+    except SessionError as e:
+        guard.failed(source)
+        raise _session_error(e)
+    annotate(resource_type="sessions", resource_id=tokens.session.session_id)
+    return tokens
+
+
+@router.post("/logout", status_code=status.HTTP_204_NO_CONTENT)
+def end_portal_session(
+    refresh_in: schemas.SessionRefresh,
+    manager: SessionManager = Depends(session_manager),
+):
+    """
+    Sign out the session of a refresh token. Its access tokens stop working
+    within SESSION_CACHE_SECONDS.
+    """
+    try:
+        record = manager.end(refresh_in.refresh_token)
+    except SessionError as e:
+        raise _session_error(e)
+    annotate(resource_type="sessions", resource_id=record.session_id)
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+@router.get("", response_model=List[schemas.Session], response_model_by_alias=False)
+def list_portal_sessions(
+    repo: SessionRepository = Depends(get_session_repository),
+    current_user: Dict = Depends(get_current_user),
+):
+    """
+    Retrieve the caller's signed-in devices. `current` marks the session of
+    the request's access token.
+    """
+    return [
+        schemas.Session.model_validate({**record.model_dump(by_alias=True), "current": record.session_id == current_user.get("sid")})
+        for record in repo.list_active(current_user["uid"])
+    ]
+
+
+@router.delete("/{sessionId}", status_code=status.HTTP_204_NO_CONTENT)
+def revoke_portal_session(
+    sessionId: str,
+    repo: SessionRepository = Depends(get_session_repository),
+    current_user: Dict = Depends(get_current_user),
+):
+    """
+    Sign out one of the caller's devices.
+    """
+    record = repo.get(sessionId)
+    if not record or record.uid != current_user["uid"]:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Session not found")
+    repo.revoke(sessionId, "logout")
+    logging.info(f"User {current_user['uid']} signed out session {sessionId}")
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+@router.post("/revoke-others", response_model=schemas.SessionRevocation, response_model_by_alias=False)
+def revoke_other_portal_sessions(
+    manager: SessionManager = Depends(session_manager),
+    current_user: Dict = Depends(get_current_user),
+):
+    """
+    Log out other devices: sign out every session of the caller but the one
+    making the request. Called with an ID token rather than a session's
+    access token, it signs out all of them.
+    """
+    revoked = manager.revoke_all(current_user["uid"], "signed-out-elsewhere", keep=current_user.get("sid"))
+    return schemas.SessionRevocation(revoked=revoked)
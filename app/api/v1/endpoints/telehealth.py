@@ -0,0 +1,203 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, status
+from typing import Dict, List, Optional
+from datetime import datetime, timedelta, timezone
+import logging
+import uuid
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_appointment_repository, get_event_publisher, get_telehealth_session_repository
+from app.audit.context import annotate
+from app.authz.roles import PATIENT_ID_CLAIM, grants, has_permission, permission
+from app.core.config import get_settings
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.repositories.appointments import AppointmentRepository
+from app.repositories.telehealth_sessions import TelehealthSessionRepository
+from app.services.telehealth import participant_identity, room_name
+from app.telehealth.providers import VideoProvider, VideoProviderError, get_video_provider
+
+router = APIRouter()
+
+# --- Configuration ---
+settings = get_settings()
+TOKEN_TTL = timedelta(seconds=settings.telehealth_token_ttl_seconds)
+JOIN_EARLY = timedelta(minutes=settings.telehealth_join_early_minutes)
+ROOM_GRACE = timedelta(minutes=settings.telehealth_room_grace_minutes)
+
+# Appointments a visit can be held for.
+OPEN_APPOINTMENT_STATUSES = ("booked", "arrived")
+
+
+def _provider() -> VideoProvider:
+    provider = get_video_provider()
+    if not provider:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="Telehealth is not configured (TELEHEALTH_PROVIDER)")
+    return provider
+
+
+def _get_or_404(session_id: str, repo: TelehealthSessionRepository) -> schemas.TelehealthSession:
+    session = repo.get(session_id)
+    if not session:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Telehealth session not found")
+    annotate(patient_id=session.patient_id)
+    return session
+
+
+def _participant_role(principal: Dict, session: schemas.TelehealthSession) -> Optional[str]:
+    """
+    'staff' for callers whose roles let them run any visit, 'patient' for
+    the session's own patient; None for anyone else.
+    """
+    full, _own = grants(principal)
+    if has_permission(full, permission("telehealth", "POST")):
+        return "staff"
+    if principal.get(PATIENT_ID_CLAIM) == session.patient_id:
+        return "patient"
+    return None
+
+
+def _unavailable(e: VideoProviderError) -> HTTPException:
+    logging.warning(f"Video provider request failed: {e}")
+    return HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="The video provider is unavailable; try again")
+
+
+@router.post("/sessions", response_model=schemas.TelehealthSession, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+def create_session(
+    *,
+    session_in: schemas.TelehealthSessionCreate,
+    repo: TelehealthSessionRepository = Depends(get_telehealth_session_repository),
+    appointments: AppointmentRepository = Depends(get_appointment_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Open the video visit of an appointment: a room at TELEHEALTH_PROVIDER
+    that stays open until TELEHEALTH_ROOM_GRACE_MINUTES after the
+    appointment's end. An appointment has one visit at a time; end it to
+    open another.
+    """
+    provider = _provider()
+    appointment = appointments.get(session_in.appointment_id)
+    if not appointment:
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail="Unknown appointment")
+    annotate(patient_id=appointment.patient_id)
+    if appointment.status not in OPEN_APPOINTMENT_STATUSES:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=f"A visit cannot be held for a {appointment.status} appointment")
+    if any(s.status != "ended" for s in repo.list(appointment_id=appointment.appointment_id)):
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="The appointment already has an open visit")
+
+    session_id = uuid.uuid4().hex
+    expires_at = max(appointment.end, datetime.now(timezone.utc)) + ROOM_GRACE
+    try:
+        room_url = provider.create_room(room_name(session_id), expires_at)
+    except VideoProviderError as e:
+        raise _unavailable(e)
+    session = repo.create(session_id, appointment, provider.name, room_url)
+    events.emit("telehealth_session.created", f"telehealth/sessions/{session.telehealth_session_id}", session)
+    logging.info(f"User {current_user['uid']} opened telehealth session {session.telehealth_session_id} for appointment {appointment.appointment_id}")
+    return session
+
+
+@router.get("/sessions", response_model=List[schemas.TelehealthSession], response_model_by_alias=False)
+def list_sessions(
+    patientId: Optional[str] = None,
+    appointmentId: Optional[str] = None,
+    status_filter: Optional[schemas.TelehealthSessionStatus] = Query(None, alias="status"),
+    repo: TelehealthSessionRepository = Depends(get_telehealth_session_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve the visits of a patient or an appointment, newest first, e.g.
+    the ended visits of a patient with their durations for billing. At
+    least one of patientId or appointmentId is required.
+    """
+    if not patientId and not appointmentId:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="Provide a patientId or appointmentId filter")
+    sessions = repo.list(patient_id=patientId, appointment_id=appointmentId, status=status_filter)
+    return sorted(sessions, key=lambda s: s.created_at, reverse=True)
+
+
+@router.get("/sessions/{telehealthSessionId}", response_model=schemas.TelehealthSession, response_model_by_alias=False)
+def get_session(
+    telehealthSessionId: str,
+    repo: TelehealthSessionRepository = Depends(get_telehealth_session_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a visit by ID, with who joined and left it and when.
+    """
+    return _get_or_404(telehealthSessionId, repo)
+
+
+@router.post("/sessions/{telehealthSessionId}/tokens", response_model=schemas.TelehealthJoinToken, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+def issue_join_token(
+    telehealthSessionId: str,
+    repo: TelehealthSessionRepository = Depends(get_telehealth_session_repository),
+    appointments: AppointmentRepository = Depends(get_appointment_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Mint a token for the caller to join the visit with the provider's
+    client SDK, valid for TELEHEALTH_TOKEN_TTL_SECONDS: as the patient for
+    the appointment's own patient, as staff for clinicians. Tokens are
+    issued from TELEHEALTH_JOIN_EARLY_MINUTES before the appointment until
+    the room closes, and not once the visit has ended; ask for a new one
+    to rejoin. Participants are named to the provider only by role and ID.
+    """
+    provider = _provider()
+    session = _get_or_404(telehealthSessionId, repo)
+    role = _participant_role(current_user, session)
+    if role is None:
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="Only the patient and clinicians may join the visit")
+    if session.status == "ended":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="The visit has ended")
+    appointment = appointments.get(session.appointment_id)
+    now = datetime.now(timezone.utc)
+    if appointment and now < appointment.start - JOIN_EARLY:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=f"The visit can be joined from {(appointment.start - JOIN_EARLY).isoformat()}")
+    closes_at = max(appointment.end, session.created_at) + ROOM_GRACE if appointment else now + TOKEN_TTL
+    if now >= closes_at:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="The visit's room has closed")
+
+    identity = participant_identity(role, session.patient_id if role == "patient" else current_user["uid"])
+    expires_at = min(now + TOKEN_TTL, closes_at)
+    try:
+        token = provider.join_token(session.room_name, identity, expires_at, moderator=role == "staff")
+    except VideoProviderError as e:
+        raise _unavailable(e)
+    repo.record_event(session.telehealth_session_id, schemas.TelehealthSessionEvent(type="token_issued", at=now, identity=identity, role=role))
+    logging.info(f"User {current_user['uid']} was issued a join token for telehealth session {session.telehealth_session_id} as {identity}")
+    return schemas.TelehealthJoinToken(
+        token=token, provider=session.provider, room_name=session.room_name, room_url=session.room_url,
+        identity=identity, role=role, expires_at=expires_at,
+    )
+
+
+@router.post("/sessions/{telehealthSessionId}/end", response_model=schemas.TelehealthSession, response_model_by_alias=False)
+def end_session(
+    telehealthSessionId: str,
+    repo: TelehealthSessionRepository = Depends(get_telehealth_session_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    End the visit: the room is closed, disconnecting everyone in it, and
+    the visit's duration is fixed. Only clinicians may end a visit; rooms
+    the participants leave are closed by the provider, which ends the
+    visit too. Ending a visit that has ended changes nothing.
+    """
+    session = _get_or_404(telehealthSessionId, repo)
+    if _participant_role(current_user, session) != "staff":
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="Only clinicians may end a visit")
+    if session.status == "ended":
+        return session
+    try:
+        _provider().end_room(session.room_name)
+    except VideoProviderError as e:
+        raise _unavailable(e)
+    session = repo.record_event(
+        session.telehealth_session_id, schemas.TelehealthSessionEvent(type="ended", at=datetime.now(timezone.utc)), ended_by=current_user["uid"],
+    )
+    events.emit("telehealth_session.ended", f"telehealth/sessions/{session.telehealth_session_id}", session)
+    logging.info(f"User {current_user['uid']} ended telehealth session {session.telehealth_session_id} after {session.duration_seconds or 0}s")
+    return session
@@ -0,0 +1,88 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, status
+from typing import Dict, List, Optional
+
+from app.api.v1 import schemas
+from app.dependencies.auth import get_current_user
+from app.terminology.remote import TerminologyServerError
+from app.terminology.service import Terminology, get_terminology
+
+router = APIRouter()
+
+MAX_EXPANSION_COUNT = 1000
+
+
+def _value_set_or_404(value_set_id: str, terminology: Terminology):
+    value_set = terminology.get_value_set(value_set_id)
+    if not value_set:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Value set not found")
+    return value_set
+
+
+@router.get("/lookup", response_model=schemas.CodeLookup, response_model_by_alias=False)
+def lookup_code(
+    system: str = Query(..., description="Code system URI, e.g. 'http://loinc.org'."),
+    code: str = Query(...),
+    terminology: Terminology = Depends(get_terminology),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Look a code up: its display, whether it is inactive and the codes it is
+    a kind of. Codes the loaded content lacks are looked up at the
+    terminology server, when one is configured.
+    """
+    try:
+        found = terminology.lookup(system, code)
+    except TerminologyServerError as e:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail=str(e))
+    if not found:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail=f"Code '{code}' not found in {system}")
+    return found
+
+
+@router.get("/validate-code", response_model=schemas.CodeValidation, response_model_by_alias=False)
+def validate_code(
+    system: str = Query(..., description="Code system URI, e.g. 'http://snomed.info/sct'."),
+    code: str = Query(...),
+    display: Optional[str] = Query(None, description="Also check that this is a display of the code."),
+    valueSet: Optional[str] = Query(None, description="Also check that the code is in this value set (ID or canonical URL)."),
+    terminology: Terminology = Depends(get_terminology),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Check a code the way coded fields are checked when records are written.
+    `result` is false only when the code is known not to be valid;
+    `verification` says how far it could be checked, as the bundled code
+    systems hold only the codes this API uses.
+    """
+    value_set = _value_set_or_404(valueSet, terminology) if valueSet else None
+    return terminology.validate(system, code, display=display, value_set=value_set)
+
+
+@router.get("/value-sets", response_model=List[schemas.ValueSetSummary], response_model_by_alias=False)
+def list_value_sets(
+    terminology: Terminology = Depends(get_terminology),
+    current_user: Dict = Depends(get_current_user)
+):
+    """The loaded value sets."""
+    return [
+        schemas.ValueSetSummary(
+            id=v.id, url=v.url, name=v.name, title=v.title, version=v.version, description=v.description,
+        )
+        for v in sorted(terminology.value_sets.values(), key=lambda v: v.id)
+    ]
+
+
+@router.get("/value-sets/{valueSetId}/expand", response_model=schemas.ValueSetExpansion, response_model_by_alias=False)
+def expand_value_set(
+    valueSetId: str,
+    filter: Optional[str] = Query(None, min_length=1, description="Only codes starting with, or displays containing, this text."),
+    offset: int = Query(0, ge=0),
+    count: int = Query(100, ge=1, le=MAX_EXPANSION_COUNT),
+    terminology: Terminology = Depends(get_terminology),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    The active codes of a value set, a page at a time, e.g. to fill a
+    picker as the user types with `filter`.
+    """
+    return terminology.expand(_value_set_or_404(valueSetId, terminology), text=filter, offset=offset, count=count)
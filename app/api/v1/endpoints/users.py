@@ -0,0 +1,210 @@
+from fastapi import APIRouter, Depends, HTTPException, Response, status
+from typing import Dict, List, Optional
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_custom_role_repository, get_task_queue
+from app.api.v1.endpoints.mfa import mfa_manager
+from app.audit.context import annotate, security_event
+from app.auth.accounts import AccountExistsError, UserAccounts, get_user_accounts, is_staff
+from app.auth.mfa import MfaManager
+from app.auth.sessions import SessionManager, get_session_manager
+from app.authz.roles import ADMIN, GATE_ROLES, PATIENT, ROLE_PERMISSIONS
+from app.dependencies.auth import require_roles
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.custom_roles import CustomRoleRepository
+from app.tasks.jobs import STAFF_ACCOUNT_EMAIL_TASK
+from app.tasks.queue import TaskQueue
+from app.tenancy.context import PLATFORM_ADMIN, current_tenant, is_platform_admin
+
+router = APIRouter()
+
+# Administrators manage the staff of their own organization; platform
+# administrators those of the organization they act for, or everyone's.
+USER_ADMIN_ROLES = (ADMIN, PLATFORM_ADMIN)
+
+
+def user_accounts() -> UserAccounts:
+    accounts = get_user_accounts()
+    if accounts is None:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="User management needs AUTH_PROVIDER=firebase")
+    return accounts
+
+
+def portal_sessions() -> Optional[SessionManager]:
+    """The session manager, or None when sessions are off and there are none to end."""
+    return get_session_manager()
+
+
+def _staff_user(accounts: UserAccounts, uid: str) -> schemas.StaffUser:
+    """The staff member, if the caller's organization is theirs; 404 otherwise, as if they did not exist."""
+    user = accounts.get(uid)
+    tenant = current_tenant()
+    if not user or not is_staff(user.roles) or (tenant is not None and user.organization_id != tenant):
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="User not found")
+    annotate(resource_type="users", resource_id=uid)
+    return user
+
+
+def _check_roles(roles: List[str], current_user: Dict, custom_roles: CustomRoleRepository) -> List[str]:
+    """The roles to assign; each must be built in or a custom role of the organization (see /admin/roles)."""
+    if PATIENT in roles:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="Patients sign up through the patient portal")
+    if PLATFORM_ADMIN in roles and not is_platform_admin(current_user):
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail=f"Only platform administrators grant '{PLATFORM_ADMIN}'")
+    unknown = [role for role in roles if role not in ROLE_PERMISSIONS and role not in GATE_ROLES and not custom_roles.get(role)]
+    if unknown:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail=f"Unknown roles: {', '.join(unknown)}")
+    return sorted(set(roles))
+
+
+def _end_sign_ins(accounts: UserAccounts, sessions: Optional[SessionManager], uid: str, reason: str) -> None:
+    accounts.revoke_sign_ins(uid)
+    if sessions is not None:
+        sessions.revoke_all(uid, reason)
+
+
+@router.get("", response_model=Page[schemas.StaffUser], response_model_by_alias=False)
+def list_users(
+    page: PageRequest = Depends(pagination(default_limit=100, max_limit=500)),
+    accounts: UserAccounts = Depends(user_accounts),
+    current_user: Dict = Depends(require_roles(*USER_ADMIN_ROLES))
+):
+    """
+    Retrieve the staff of the caller's organization by uid, including
+    invited and deactivated accounts. Accounts holding only the `patient`
+    role are not listed.
+    """
+    users = accounts.list(current_tenant())
+
+    def fetch(after: Optional[str], limit: int) -> List[schemas.StaffUser]:
+        return [user for user in users if after is None or user.uid > after][:limit]
+
+    return paginate(page, fetch, lambda user: user.uid)
+
+
+@router.post("", response_model=schemas.StaffUser, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+def invite_user(
+    *,
+    invite: schemas.StaffUserInvite,
+    accounts: UserAccounts = Depends(user_accounts),
+    custom_roles: CustomRoleRepository = Depends(get_custom_role_repository),
+    tasks: TaskQueue = Depends(get_task_queue),
+    current_user: Dict = Depends(require_roles(*USER_ADMIN_ROLES))
+):
+    """
+    Invite a staff member: creates their account in the caller's
+    organization with the roles given and emails them a link to choose a
+    password. 409 if the address already has an account.
+    """
+    roles = _check_roles(invite.roles, current_user, custom_roles)
+    try:
+        user = accounts.create(invite.email, invite.display_name, roles, current_tenant())
+    except AccountExistsError as e:
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail=str(e))
+    annotate(resource_type="users", resource_id=user.uid)
+    security_event("user-invited")
+    tasks.enqueue(STAFF_ACCOUNT_EMAIL_TASK, {"uid": user.uid, "invited": True}, task_id=f"staff-invitation-{user.uid}")
+    logging.warning(f"User {current_user['uid']} invited user {user.uid} with roles {', '.join(roles)}")
+    return user
+
+
+@router.get("/{uid}", response_model=schemas.StaffUser, response_model_by_alias=False)
+def get_user(
+    uid: str,
+    accounts: UserAccounts = Depends(user_accounts),
+    current_user: Dict = Depends(require_roles(*USER_ADMIN_ROLES))
+):
+    """
+    Retrieve a staff member of the caller's organization.
+    """
+    return _staff_user(accounts, uid)
+
+
+@router.put("/{uid}/roles", response_model=schemas.StaffUser, response_model_by_alias=False)
+def assign_roles(
+    uid: str,
+    roles_in: schemas.StaffRolesUpdate,
+    accounts: UserAccounts = Depends(user_accounts),
+    custom_roles: CustomRoleRepository = Depends(get_custom_role_repository),
+    sessions: Optional[SessionManager] = Depends(portal_sessions),
+    current_user: Dict = Depends(require_roles(*USER_ADMIN_ROLES))
+):
+    """
+    Replace a staff member's roles. Their sign-ins are ended, so the roles
+    they held do not outlive the tokens already issued; they take effect
+    when the user signs in again.
+    """
+    _staff_user(accounts, uid)
+    roles = _check_roles(roles_in.roles, current_user, custom_roles)
+    user = accounts.set_roles(uid, roles)
+    _end_sign_ins(accounts, sessions, uid, "roles-changed")
+    security_event("roles-changed")
+    logging.warning(f"User {current_user['uid']} set the roles of user {uid} to {', '.join(roles)}")
+    return user
+
+
+@router.post("/{uid}/deactivate", response_model=schemas.StaffUser, response_model_by_alias=False)
+def deactivate_user(
+    uid: str,
+    accounts: UserAccounts = Depends(user_accounts),
+    sessions: Optional[SessionManager] = Depends(portal_sessions),
+    current_user: Dict = Depends(require_roles(*USER_ADMIN_ROLES))
+):
+    """
+    Deactivate a staff member's account, e.g. when they leave: they are
+    signed out everywhere and cannot sign in until reactivated. Their
+    records and audit trail are kept.
+    """
+    if uid == current_user["uid"]:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="You cannot deactivate your own account")
+    _staff_user(accounts, uid)
+    user = accounts.set_disabled(uid, True)
+    _end_sign_ins(accounts, sessions, uid, "deactivated")
+    security_event("user-deactivated")
+    logging.warning(f"User {current_user['uid']} deactivated user {uid}")
+    return user
+
+
+@router.post("/{uid}/reactivate", response_model=schemas.StaffUser, response_model_by_alias=False)
+def reactivate_user(
+    uid: str,
+    accounts: UserAccounts = Depends(user_accounts),
+    current_user: Dict = Depends(require_roles(*USER_ADMIN_ROLES))
+):
+    """
+    Reactivate a deactivated account, with the roles it had.
+    """
+    _staff_user(accounts, uid)
+    user = accounts.set_disabled(uid, False)
+    security_event("user-reactivated")
+    logging.warning(f"User {current_user['uid']} reactivated user {uid}")
+    return user
+
+
+@router.post("/{uid}/credential-reset", status_code=status.HTTP_202_ACCEPTED)
+def reset_credentials(
+    uid: str,
+    reset_in: schemas.CredentialReset,
+    accounts: UserAccounts = Depends(user_accounts),
+    sessions: Optional[SessionManager] = Depends(portal_sessions),
+    mfa: MfaManager = Depends(mfa_manager),
+    tasks: TaskQueue = Depends(get_task_queue),
+    current_user: Dict = Depends(require_roles(*USER_ADMIN_ROLES))
+):
+    """
+    Force a staff member to choose a new password, e.g. when theirs may
+    have leaked: they are signed out everywhere and emailed a link to set
+    one. With `resetMfa` their second factors are removed as well, and
+    they enroll again at their next sign-in.
+    """
+    user = _staff_user(accounts, uid)
+    if user.status == "deactivated":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="The account is deactivated")
+    _end_sign_ins(accounts, sessions, uid, "credentials-reset")
+    if reset_in.reset_mfa:
+        mfa.reset(uid)
+    security_event("credentials-reset")
+    tasks.enqueue(STAFF_ACCOUNT_EMAIL_TASK, {"uid": uid, "invited": False})
+    logging.warning(f"User {current_user['uid']} reset the credentials of user {uid}{' and their second factors' if reset_in.reset_mfa else ''}")
+    return Response(status_code=status.HTTP_202_ACCEPTED)
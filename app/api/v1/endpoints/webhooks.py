@@ -0,0 +1,157 @@
+from fastapi import APIRouter, Depends, Query, HTTPException, status, Response
+from typing import Dict, Optional
+import logging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_webhook_delivery_repository, get_webhook_subscription_repository
+from app.dependencies.auth import require_roles
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.base import NotFoundError
+from app.repositories.webhooks import WebhookDeliveryRepository, WebhookSubscriptionRepository
+from app.webhooks.signing import generate_secret
+
+router = APIRouter()
+
+# Subscriptions receive PHI, so only integration administrators manage them.
+WEBHOOK_ADMIN_ROLES = ("integration-admin",)
+
+
+@router.post("", response_model=schemas.WebhookSubscriptionWithSecret, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+def create_webhook_subscription(
+    *,
+    subscription_in: schemas.WebhookSubscriptionCreate,
+    repo: WebhookSubscriptionRepository = Depends(get_webhook_subscription_repository),
+    current_user: Dict = Depends(require_roles(*WEBHOOK_ADMIN_ROLES))
+):
+    """
+    Register an HTTPS callback for one or more event types. The response
+    includes the signing secret, which is not returned again.
+    """
+    subscription = repo.create(subscription_in, generate_secret(), current_user["uid"])
+    logging.info(f"User {current_user['uid']} created webhook subscription {subscription.subscription_id} for {subscription.url}")
+    return subscription
+
+
+@router.get("", response_model=Page[schemas.WebhookSubscription], response_model_by_alias=False)
+def list_webhook_subscriptions(
+    page: PageRequest = Depends(pagination(default_limit=100, max_limit=500)),
+    repo: WebhookSubscriptionRepository = Depends(get_webhook_subscription_repository),
+    current_user: Dict = Depends(require_roles(*WEBHOOK_ADMIN_ROLES))
+):
+    """
+    Retrieve webhook subscriptions, oldest first.
+    """
+    return paginate(
+        page,
+        lambda after, limit: repo.list(limit=limit, after=after),
+        lambda subscription: subscription.subscription_id,
+    )
+
+
+@router.get("/{subscriptionId}", response_model=schemas.WebhookSubscription, response_model_by_alias=False)
+def get_webhook_subscription(
+    subscriptionId: str,
+    repo: WebhookSubscriptionRepository = Depends(get_webhook_subscription_repository),
+    current_user: Dict = Depends(require_roles(*WEBHOOK_ADMIN_ROLES))
+):
+    """
+    Retrieve a single webhook subscription by ID.
+    """
+    subscription = repo.get(subscriptionId)
+    if not subscription:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Webhook subscription not found")
+    return subscription
+
+
+@router.patch("/{subscriptionId}", response_model=schemas.WebhookSubscription, response_model_by_alias=False)
+def update_webhook_subscription(
+    subscriptionId: str,
+    subscription_in: schemas.WebhookSubscriptionUpdate,
+    repo: WebhookSubscriptionRepository = Depends(get_webhook_subscription_repository),
+    current_user: Dict = Depends(require_roles(*WEBHOOK_ADMIN_ROLES))
+):
+    """
+    Change a subscription's URL or event types, or pause it with `active: false`.
+    Pending deliveries to a paused subscription are marked failed.
+    """
+    try:
+        subscription = repo.update(subscriptionId, subscription_in)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Webhook subscription not found")
+    logging.info(f"User {current_user['uid']} updated webhook subscription {subscriptionId}")
+    return subscription
+
+
+@router.delete("/{subscriptionId}", status_code=status.HTTP_204_NO_CONTENT)
+def delete_webhook_subscription(
+    subscriptionId: str,
+    repo: WebhookSubscriptionRepository = Depends(get_webhook_subscription_repository),
+    current_user: Dict = Depends(require_roles(*WEBHOOK_ADMIN_ROLES))
+):
+    """
+    Delete a subscription. Its delivery log is kept.
+    """
+    try:
+        repo.delete(subscriptionId)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Webhook subscription not found")
+    logging.info(f"User {current_user['uid']} deleted webhook subscription {subscriptionId}")
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+@router.post("/{subscriptionId}/rotate-secret", response_model=schemas.WebhookSubscriptionWithSecret, response_model_by_alias=False)
+def rotate_webhook_secret(
+    subscriptionId: str,
+    repo: WebhookSubscriptionRepository = Depends(get_webhook_subscription_repository),
+    current_user: Dict = Depends(require_roles(*WEBHOOK_ADMIN_ROLES))
+):
+    """
+    Replace the subscription's signing secret. Deliveries from now on are
+    signed with the new secret, which is returned once.
+    """
+    try:
+        subscription = repo.set_secret(subscriptionId, generate_secret())
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Webhook subscription not found")
+    logging.info(f"User {current_user['uid']} rotated the secret of webhook subscription {subscriptionId}")
+    return subscription
+
+
+@router.get("/{subscriptionId}/deliveries", response_model=Page[schemas.WebhookDelivery], response_model_by_alias=False)
+def list_webhook_deliveries(
+    subscriptionId: str,
+    status_filter: Optional[schemas.WebhookDeliveryStatus] = Query(None, alias="status"),
+    page: PageRequest = Depends(pagination(default_limit=50, max_limit=500)),
+    repo: WebhookDeliveryRepository = Depends(get_webhook_delivery_repository),
+    current_user: Dict = Depends(require_roles(*WEBHOOK_ADMIN_ROLES))
+):
+    """
+    Retrieve the subscription's deliveries, most recent first, with the
+    number of attempts, the last response status and error of each.
+    """
+    return paginate(
+        page,
+        lambda after, limit: repo.list(subscriptionId, status=status_filter, limit=limit, after=after),
+        lambda delivery: delivery.delivery_id,
+    )
+
+
+@router.post("/{subscriptionId}/deliveries/{deliveryId}/retry", response_model=schemas.WebhookDelivery, response_model_by_alias=False)
+def retry_webhook_delivery(
+    subscriptionId: str,
+    deliveryId: str,
+    repo: WebhookDeliveryRepository = Depends(get_webhook_delivery_repository),
+    current_user: Dict = Depends(require_roles(*WEBHOOK_ADMIN_ROLES))
+):
+    """
+    Send a delivery again, e.g. after fixing the receiving endpoint. The
+    delivery is queued with a fresh set of attempts.
+    """
+    delivery = repo.get(deliveryId)
+    if not delivery or delivery.subscription_id != subscriptionId:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Webhook delivery not found")
+    if delivery.status == "pending":
+        raise HTTPException(status_code=status.HTTP_409_CONFLICT, detail="Delivery is already pending")
+    delivery = repo.requeue(deliveryId)
+    logging.info(f"User {current_user['uid']} requeued webhook delivery {deliveryId}")
+    return delivery
@@ -0,0 +1,226 @@
+from fastapi import APIRouter, Body, Depends, Query, HTTPException, status, Response
+from datetime import datetime, timedelta, timezone
+from typing import List, Dict, Optional
+import logging
+import uuid
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_event_publisher, get_patient_repository, get_wound_image_repository
+from app.core.config import get_settings
+from app.core.storage import delete_blob, download_blob, signed_download_url, upload_blob
+from app.crypto.envelope import get_field_cipher
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.images.processing import ImageRejected, process_photo
+from app.repositories.base import NotFoundError
+from app.repositories.patients import PatientRepository
+from app.repositories.transactions import transactional
+from app.repositories.wound_images import WoundImageRepository
+
+router = APIRouter()
+settings = get_settings()
+
+WOUND_IMAGES_BUCKET = settings.wound_images_bucket
+MAX_BYTES = settings.wound_image_max_bytes
+MAX_PIXELS = settings.wound_image_max_pixels
+THUMBNAIL_PIXELS = settings.wound_image_thumbnail_pixels
+THUMBNAIL_URL_TTL_SECONDS = settings.wound_image_thumbnail_url_ttl_seconds
+
+# Photos are sent as the request body, with their details in the query.
+PHOTO_MEDIA_TYPE = "image/jpeg"
+
+
+def _require_bucket() -> str:
+    if not WOUND_IMAGES_BUCKET:
+        raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="Wound image storage is not configured (WOUND_IMAGES_BUCKET)")
+    return WOUND_IMAGES_BUCKET
+
+
+def _ensure_patient_exists(patient_id: str, patients: PatientRepository):
+    if not patients.get(patient_id):
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Patient not found")
+
+
+def _get_or_404(patient_id: str, image_id: str, repo: WoundImageRepository) -> schemas.WoundImage:
+    image = repo.get(image_id)
+    if not image or image.patient_id != patient_id:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Wound image not found")
+    return image
+
+
+def _encryption_context(image_id: str) -> str:
+    return f"woundImages/{image_id}"
+
+
+def _area_change_percent(images: List[schemas.WoundImage]) -> Optional[float]:
+    measured = [image.length_mm * image.width_mm for image in images if image.length_mm and image.width_mm]
+    if len(measured) < 2:
+        return None
+    return round((measured[-1] - measured[0]) / measured[0] * 100, 1)
+
+
+@router.post("/{patientId}/wound-images", response_model=schemas.WoundImage, status_code=status.HTTP_201_CREATED, response_model_by_alias=False)
+@transactional
+def upload_wound_image(
+    patientId: str,
+    photo: bytes = Body(..., media_type=PHOTO_MEDIA_TYPE, description="The JPEG or PNG photo."),
+    woundId: str = Query(..., pattern=schemas.WOUND_ID_PATTERN, description="Identifies the wound across photos, e.g. 'left-heel-ulcer'."),
+    takenAt: Optional[datetime] = Query(None, description="When the photo was taken; now if omitted."),
+    bodySite: Optional[str] = Query(None, max_length=200),
+    lengthMm: Optional[float] = Query(None, gt=0),
+    widthMm: Optional[float] = Query(None, gt=0),
+    note: Optional[str] = Query(None, max_length=2000),
+    repo: WoundImageRepository = Depends(get_wound_image_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Upload a wound-care photo, sent as the request body (`Content-Type:
+    image/jpeg` or `image/png`). Its EXIF metadata, including any GPS
+    position, is removed and a thumbnail made before anything is stored; the
+    photo itself is stored encrypted when field encryption is configured.
+    Photos with the same `woundId` form that wound's series.
+    """
+    bucket = _require_bucket()
+    _ensure_patient_exists(patientId, patients)
+    if len(photo) > MAX_BYTES:
+        raise HTTPException(status_code=status.HTTP_413_REQUEST_ENTITY_TOO_LARGE, detail=f"Photos can be at most {MAX_BYTES} bytes")
+    try:
+        processed = process_photo(photo, MAX_PIXELS, THUMBNAIL_PIXELS)
+    except ImageRejected as e:
+        raise HTTPException(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail=str(e))
+
+    image_id = uuid.uuid4().hex
+    prefix = f"patients/{patientId}/wound-images/{image_id}"
+    cipher = get_field_cipher()
+    original = cipher.seal_bytes(processed.original, _encryption_context(image_id)) if cipher else processed.original
+    files = schemas.WoundImageFiles(
+        content_type=processed.content_type,
+        width=processed.width,
+        height=processed.height,
+        size=len(processed.original),
+        original_blob_name=f"{prefix}/original",
+        thumbnail_blob_name=f"{prefix}/thumbnail.jpg",
+        encrypted=cipher is not None,
+    )
+    upload_blob(bucket, files.original_blob_name, original, "application/octet-stream" if cipher else processed.content_type)
+    upload_blob(bucket, files.thumbnail_blob_name, processed.thumbnail, "image/jpeg")
+
+    image_in = schemas.WoundImageCreate(
+        wound_id=woundId, body_site=bodySite, taken_at=takenAt or datetime.now(timezone.utc),
+        length_mm=lengthMm, width_mm=widthMm, note=note,
+    )
+    image = repo.create(patientId, image_id, image_in, files, uploaded_by=current_user["uid"])
+    events.emit("wound_image.recorded", f"patients/{patientId}/wound-images/{image_id}", image)
+    logging.info(f"User {current_user['uid']} uploaded wound image {image_id} ({woundId}) for patient {patientId}")
+    return image
+
+
+@router.get("/{patientId}/wound-images", response_model=List[schemas.WoundImage], response_model_by_alias=False)
+def list_wound_images(
+    patientId: str,
+    woundId: Optional[str] = Query(None, description="Only photos of this wound."),
+    repo: WoundImageRepository = Depends(get_wound_image_repository),
+    patients: PatientRepository = Depends(get_patient_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a patient's wound photos, oldest first, optionally only those of
+    one wound.
+    """
+    _ensure_patient_exists(patientId, patients)
+    return sorted(repo.list(patientId, wound_id=woundId), key=lambda image: image.taken_at)
+
+
+@router.get("/{patientId}/wounds/{woundId}/series", response_model=schemas.WoundImageSeries, response_model_by_alias=False)
+def get_wound_series(
+    patientId: str,
+    woundId: str,
+    repo: WoundImageRepository = Depends(get_wound_image_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Compare a wound's healing over time: its photos, oldest first, each with
+    a short-lived signed thumbnail URL, and how much its measured area has
+    changed.
+    """
+    bucket = _require_bucket()
+    images = sorted(repo.list(patientId, wound_id=woundId), key=lambda image: image.taken_at)
+    if not images:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="No photos of this wound")
+    views = [
+        schemas.WoundImageView(**image.model_dump(), thumbnail_url=signed_download_url(bucket, image.thumbnail_blob_name, THUMBNAIL_URL_TTL_SECONDS))
+        for image in images
+    ]
+    return schemas.WoundImageSeries(
+        patient_id=patientId,
+        wound_id=woundId,
+        body_site=next((image.body_site for image in reversed(images) if image.body_site), None),
+        images=views,
+        first_taken_at=images[0].taken_at,
+        last_taken_at=images[-1].taken_at,
+        area_change_percent=_area_change_percent(images),
+        thumbnail_urls_expire_at=datetime.now(timezone.utc) + timedelta(seconds=THUMBNAIL_URL_TTL_SECONDS),
+    )
+
+
+@router.get("/{patientId}/wound-images/{imageId}", response_model=schemas.WoundImage, response_model_by_alias=False)
+def get_wound_image(
+    patientId: str,
+    imageId: str,
+    repo: WoundImageRepository = Depends(get_wound_image_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Retrieve a wound photo's details by ID.
+    """
+    return _get_or_404(patientId, imageId, repo)
+
+
+@router.get("/{patientId}/wound-images/{imageId}/original", response_class=Response)
+def download_wound_image(
+    patientId: str,
+    imageId: str,
+    repo: WoundImageRepository = Depends(get_wound_image_repository),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Download the full-size photo, decrypted. It is not cached by the browser.
+    """
+    bucket = _require_bucket()
+    image = _get_or_404(patientId, imageId, repo)
+    stored = download_blob(bucket, image.original_blob_name)
+    if image.encrypted:
+        cipher = get_field_cipher()
+        if cipher is None:
+            raise HTTPException(status_code=status.HTTP_503_SERVICE_UNAVAILABLE, detail="The photo is encrypted but FIELD_ENCRYPTION_KEY is not set")
+        stored = cipher.open_bytes(stored, _encryption_context(imageId))
+    logging.info(f"User {current_user['uid']} downloaded wound image {imageId} of patient {patientId}")
+    return Response(content=stored, media_type=image.content_type, headers={"Cache-Control": "no-store"})
+
+
+@router.delete("/{patientId}/wound-images/{imageId}", status_code=status.HTTP_204_NO_CONTENT)
+@transactional
+def delete_wound_image(
+    patientId: str,
+    imageId: str,
+    repo: WoundImageRepository = Depends(get_wound_image_repository),
+    events: EventPublisher = Depends(get_event_publisher),
+    current_user: Dict = Depends(get_current_user)
+):
+    """
+    Delete a wound photo taken in error, with its thumbnail. This cannot be
+    undone.
+    """
+    bucket = _require_bucket()
+    image = _get_or_404(patientId, imageId, repo)
+    try:
+        repo.delete(imageId)
+    except NotFoundError:
+        raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Wound image not found")
+    delete_blob(bucket, image.original_blob_name)
+    delete_blob(bucket, image.thumbnail_blob_name)
+    events.emit("wound_image.deleted", f"patients/{patientId}/wound-images/{imageId}", patientId=patientId, imageId=imageId)
+    logging.info(f"User {current_user['uid']} deleted wound image {imageId} of patient {patientId}")
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
@@ -0,0 +1,112 @@
+# Location: app/api/v1/router.py
+
+from fastapi import APIRouter, Depends
+
+from app.api.v1.endpoints import (
+    auth,
+    sessions,
+    magic_links,
+    mfa,
+    customers,
+    clinicians,
+    patients,
+    practitioners,
+    care_teams,
+    care_plans,
+    appointments,
+    encounters,
+    telehealth,
+    observations,
+    lab_results,
+    medications,
+    allergies,
+    immunizations,
+    patient_documents,
+    wound_images,
+    notifications,
+    push_tokens,
+    consents,
+    coverage,
+    charges,
+    claims,
+    invoices,
+    consent_documents,
+    devices,
+    audit_events,
+    webhooks,
+    api_keys,
+    lockouts,
+    users,
+    roles,
+    organizations,
+    feature_flags,
+    events,
+    reports,
+    terminology,
+    search,
+    imports,
+)
+from app.authz.policy import authorize, require_mfa
+
+# --- API v1 Router ---
+# All v1 endpoint routers are registered here and mounted once in `app.main`
+# under the `/api/v1` prefix. New resources should add their router below
+# rather than being attached to the application directly.
+#
+# Path parameters and HTTP method routing are handled by each endpoint
+# router. Cross-cutting behaviour for a group of routes (e.g. authentication
+# or rate limits) can be attached per group with `dependencies=[Depends(...)]`
+# on `include_router`, instead of being repeated in every handler.
+#
+# Access to clinical resources is checked that way with `authorize`, which
+# applies the role permissions and own-record rules of `app.authz`. The
+# customer and clinician self-service routes are keyed by the caller's UID,
+# administrative routes check their roles per handler, and the event stream
+# and full-text search filter what they return by the caller's permissions.
+# Both `authorize` and role checks turn away callers whose roles require a
+# second factor they have not proved; the clinician routes, the event stream
+# and search do so with `require_mfa`.
+api_router = APIRouter()
+
+api_router.include_router(customers.router, prefix="/customers", tags=["Customers"])
+api_router.include_router(auth.router, prefix="/auth", tags=["Authentication"])
+api_router.include_router(sessions.router, prefix="/auth/sessions", tags=["Sessions"])
+api_router.include_router(magic_links.router, prefix="/auth/magic-link", tags=["Sessions"])
+api_router.include_router(mfa.router, prefix="/auth/mfa", tags=["Multi-Factor Authentication"])
+api_router.include_router(clinicians.router, prefix="/clinician", tags=["Clinicians"], dependencies=[Depends(require_mfa)])
+api_router.include_router(patients.router, prefix="/patients", tags=["Patients"], dependencies=[Depends(authorize("patients"))])
+api_router.include_router(observations.router, prefix="/patients", tags=["Observations"], dependencies=[Depends(authorize("patients"))])
+api_router.include_router(lab_results.router, prefix="/patients", tags=["Lab Results"], dependencies=[Depends(authorize("patients"))])
+api_router.include_router(medications.router, prefix="/patients", tags=["Medications"], dependencies=[Depends(authorize("patients"))])
+api_router.include_router(allergies.router, prefix="/patients", tags=["Allergies"], dependencies=[Depends(authorize("patients"))])
+api_router.include_router(immunizations.router, prefix="/patients", tags=["Immunizations"], dependencies=[Depends(authorize("patients"))])
+api_router.include_router(patient_documents.router, prefix="/patients", tags=["Documents"], dependencies=[Depends(authorize("patients"))])
+api_router.include_router(wound_images.router, prefix="/patients", tags=["Wound Images"], dependencies=[Depends(authorize("patients"))])
+api_router.include_router(notifications.router, prefix="/patients", tags=["Notifications"], dependencies=[Depends(authorize("patients"))])
+api_router.include_router(push_tokens.router, prefix="/patients", tags=["Push Notifications"], dependencies=[Depends(authorize("patients"))])
+api_router.include_router(consents.router, prefix="/patients", tags=["Consents"], dependencies=[Depends(authorize("patients"))])
+api_router.include_router(coverage.router, prefix="/patients", tags=["Coverage"], dependencies=[Depends(authorize("patients"))])
+api_router.include_router(consent_documents.router, prefix="/consent-documents", tags=["Consents"], dependencies=[Depends(authorize("consent-documents"))])
+api_router.include_router(practitioners.router, prefix="/practitioners", tags=["Practitioners"], dependencies=[Depends(authorize("practitioners"))])
+api_router.include_router(care_teams.router, prefix="/care-teams", tags=["Care Teams"], dependencies=[Depends(authorize("care-teams"))])
+api_router.include_router(care_plans.router, prefix="/care-plans", tags=["Care Plans"], dependencies=[Depends(authorize("care-plans"))])
+api_router.include_router(appointments.router, prefix="/appointments", tags=["Appointments"], dependencies=[Depends(authorize("appointments"))])
+api_router.include_router(encounters.router, prefix="/encounters", tags=["Encounters"], dependencies=[Depends(authorize("encounters"))])
+api_router.include_router(charges.router, prefix="/encounters", tags=["Claims"], dependencies=[Depends(authorize("encounters"))])
+api_router.include_router(claims.router, prefix="/claims", tags=["Claims"], dependencies=[Depends(authorize("claims"))])
+api_router.include_router(invoices.router, prefix="/invoices", tags=["Invoices"], dependencies=[Depends(authorize("invoices"))])
+api_router.include_router(telehealth.router, prefix="/telehealth", tags=["Telehealth"], dependencies=[Depends(authorize("telehealth"))])
+api_router.include_router(devices.router, prefix="/devices", tags=["Devices"], dependencies=[Depends(authorize("devices"))])
+api_router.include_router(audit_events.router, prefix="/audit-events", tags=["Audit"])
+api_router.include_router(webhooks.router, prefix="/webhooks", tags=["Webhooks"])
+api_router.include_router(api_keys.router, prefix="/admin/api-keys", tags=["API Keys"])
+api_router.include_router(lockouts.router, prefix="/admin/lockouts", tags=["Lockouts"])
+api_router.include_router(users.router, prefix="/admin/users", tags=["Users"])
+api_router.include_router(roles.router, prefix="/admin/roles", tags=["Roles"])
+api_router.include_router(organizations.router, prefix="/organizations", tags=["Organizations"])
+api_router.include_router(feature_flags.router, prefix="/admin/flags", tags=["Feature Flags"])
+api_router.include_router(events.router, prefix="/events", tags=["Events"], dependencies=[Depends(require_mfa)])
+api_router.include_router(reports.router, prefix="/reports", tags=["Reports"], dependencies=[Depends(authorize("reports"))])
+api_router.include_router(terminology.router, prefix="/terminology", tags=["Terminology"])
+api_router.include_router(search.router, prefix="/search", tags=["Search"], dependencies=[Depends(require_mfa)])
+api_router.include_router(imports.router, prefix="/import", tags=["Import"], dependencies=[Depends(authorize("import"))])
@@ -1,8 +1,31 @@
 # Location: app/api/v1/schemas.py
 
-from pydantic import BaseModel, Field, ConfigDict
+from pydantic import BaseModel, Field, ConfigDict, field_validator, model_validator
 from datetime import datetime, date
-from typing import Optional, Dict, List
+from typing import Annotated, Any, Optional, Dict, List, Literal
+import ipaddress
+import re
+from urllib.parse import urlsplit
+
+from app.crypto.fields import ENCRYPTED
+from app.events.envelope import EventType
+from app.validation.fields import (
+    BirthDate,
+    EmailAddress,
+    FutureInstant,
+    IanaTimezone,
+    MedicalRecordNumber,
+    NationalProviderIdentifier,
+    NhsNumber,
+    PhoneNumber,
+    SocialSecurityNumber,
+)
+
+# --- Record Versions ---
+# Incremented by every change to a resource (see app/repositories/base.py)
+# and sent as its ETag, W/"<version>"; updates must send back the version they
+# were based on, as If-Match or a `version` field in the body.
+RecordVersion = Annotated[int, Field(ge=0, description="Incremented by every change; updates send back the version they are based on.")]
 
 # --- Base Schemas for Maps ---
 class ComplianceMap(BaseModel):
@@ -219,4 +242,2311 @@ class DailyReportCreate(DailyReportBase):
 
 class DailyReport(DailyReportBase):
     report_id: str = Field(..., alias="reportId") # Will be the YYYY-MM-DD date string
-    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
\ No newline at end of file
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Patient Schemas ---
+class ContactInfo(BaseModel):
+    phone_number: Optional[str] = Field(None, alias="phoneNumber")
+    email: Optional[str] = None
+    address_line: Optional[str] = Field(None, alias="addressLine")
+    city: Optional[str] = None
+    postal_code: Optional[str] = Field(None, alias="postalCode")
+    country: Optional[str] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class ContactInfoCreate(ContactInfo):
+    """Contact details as sent by clients; the phone number is normalised to E.164."""
+    phone_number: Optional[PhoneNumber] = Field(None, alias="phoneNumber")
+
+class InsuranceCoverage(BaseModel):
+    """The health plan that covers the patient."""
+    payer: str = Field(..., description="The insurer or plan, e.g. 'Blue Cross'.")
+    member_id: str = Field(..., alias="memberId", description="The patient's ID with the payer. Encrypted at rest.", json_schema_extra=ENCRYPTED)
+    group_number: Optional[str] = Field(None, alias="groupNumber")
+    model_config = ConfigDict(populate_by_name=True)
+
+class PatientBase(BaseModel):
+    given_name: str = Field(..., alias="givenName")
+    family_name: str = Field(..., alias="familyName")
+    dob: date
+    sex: Literal["male", "female", "other", "unknown"] = "unknown"
+    mrn: str = Field(..., description="Medical record number, unique per patient.")
+    nhs_number: Optional[str] = Field(None, alias="nhsNumber", description="10-digit NHS number, for patients registered with the NHS.")
+    ssn: Optional[str] = Field(None, description="US Social Security number. Encrypted at rest.", json_schema_extra=ENCRYPTED)
+    contact: Optional[ContactInfo] = None
+    insurance: Optional[InsuranceCoverage] = None
+    timezone: Optional[str] = Field(None, description="IANA timezone the patient lives in, for reminder times and the times in messages; EMAIL_TIMEZONE if unset.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class PatientCreate(PatientBase):
+    # Stricter than PatientBase so that records stored before a rule was
+    # introduced can still be read.
+    dob: BirthDate
+    mrn: MedicalRecordNumber = Field(..., description="Medical record number, unique per patient.")
+    nhs_number: Optional[NhsNumber] = Field(None, alias="nhsNumber", description="10-digit NHS number, for patients registered with the NHS.")
+    ssn: Optional[SocialSecurityNumber] = Field(None, description="US Social Security number. Encrypted at rest.", json_schema_extra=ENCRYPTED)
+    contact: Optional[ContactInfoCreate] = None
+    timezone: Optional[IanaTimezone] = Field(None, description="IANA timezone the patient lives in, e.g. Asia/Bangkok.")
+
+class PatientUpdate(BaseModel):
+    """Payload for partially updating a patient; only fields that are sent are changed."""
+    given_name: Optional[str] = Field(None, alias="givenName")
+    family_name: Optional[str] = Field(None, alias="familyName")
+    dob: Optional[BirthDate] = None
+    sex: Optional[Literal["male", "female", "other", "unknown"]] = None
+    mrn: Optional[MedicalRecordNumber] = None
+    nhs_number: Optional[NhsNumber] = Field(None, alias="nhsNumber")
+    ssn: Optional[SocialSecurityNumber] = Field(None, json_schema_extra=ENCRYPTED)
+    contact: Optional[ContactInfoCreate] = None
+    insurance: Optional[InsuranceCoverage] = None
+    timezone: Optional[IanaTimezone] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+    @field_validator("given_name", "family_name", "dob", "sex", "mrn")
+    @classmethod
+    def _not_null(cls, value):
+        # Optional so they may be left out, but every patient has them.
+        if value is None:
+            raise ValueError("may be omitted but not null")
+        return value
+
+class PushPreferences(BaseModel):
+    """The push notification categories the patient app receives; all are on until turned off."""
+    messages: bool = Field(True, description="New messages, such as a result being ready to view.")
+    appointments: bool = Field(True, description="Appointment confirmations and reminders.")
+    abnormal_readings: bool = Field(True, alias="abnormalReadings", description="Vital signs recorded outside their usual range.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class PushPreferencesUpdate(BaseModel):
+    messages: Optional[bool] = None
+    appointments: Optional[bool] = None
+    abnormal_readings: Optional[bool] = Field(None, alias="abnormalReadings")
+    model_config = ConfigDict(populate_by_name=True)
+
+class ContactPreferences(BaseModel):
+    """
+    How the patient agreed to be contacted: the SMS opt-out is set by the
+    patient's own STOP and START replies, push categories from the patient
+    app (see /patients/{patientId}/push-preferences).
+    """
+    sms_opt_out: bool = Field(False, alias="smsOptOut", description="The patient texted STOP; no text messages are sent until they text START.")
+    sms_opt_out_changed_at: Optional[datetime] = Field(None, alias="smsOptOutChangedAt")
+    push: PushPreferences = Field(default_factory=PushPreferences)
+    model_config = ConfigDict(populate_by_name=True)
+
+class Patient(PatientBase):
+    patient_id: str = Field(..., alias="patientId")
+    contact_preferences: ContactPreferences = Field(default_factory=ContactPreferences, alias="contactPreferences")
+    deleted_at: Optional[datetime] = Field(None, alias="deletedAt")
+    deleted_by: Optional[str] = Field(None, alias="deletedBy")
+    merged_into: Optional[str] = Field(None, alias="mergedInto", description="Set on a duplicate merged into this patient, which is then deleted.")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Patient Matching Schemas ---
+# Duplicate detection (see app/services/patient_matching.py) compares a
+# patient with the records sharing their date of birth, family name or
+# phone number, field by field; a merge moves the duplicate's records to
+# the patient that is kept.
+MatchGrade = Literal["certain", "probable", "possible"]
+FieldComparison = Literal["agree", "partial", "disagree", "missing"]
+
+class MatchFieldScore(BaseModel):
+    field: Literal["givenName", "familyName", "dob", "sex", "phoneNumber", "address"]
+    comparison: FieldComparison
+    weight: float = Field(..., description="What the comparison adds to the score; negative when the values differ.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class PotentialDuplicate(BaseModel):
+    patient: Patient
+    grade: MatchGrade = Field(..., description="`certain` when a deterministic rule matched, else by score.")
+    score: float = Field(..., description="Sum of the field weights; higher is likelier the same person.")
+    rule: Optional[Literal["nhs-number", "ssn", "name-dob-phone"]] = Field(None, description="The deterministic rule that matched, if any.")
+    fields: List[MatchFieldScore] = []
+    model_config = ConfigDict(populate_by_name=True)
+
+class PatientMerge(BaseModel):
+    source_patient_id: str = Field(..., alias="sourcePatientId", description="The duplicate whose records move to this patient; it is deleted.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class PatientMergeResult(BaseModel):
+    patient: Patient
+    source_patient_id: str = Field(..., alias="sourcePatientId")
+    moved: Dict[str, int] = Field(..., description="How many records of each resource were moved, e.g. {'observations': 212}.")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Coverage Schemas ---
+# A patient's health plans, verified with the payer through a clearinghouse
+# (see app/eligibility/). Amounts are in cents.
+CoveragePriority = Literal["primary", "secondary", "tertiary"]
+CoverageStatus = Literal["active", "inactive"]
+# The patient's relationship to the policyholder (X12 individual relationship codes 18, 01, 19, G8).
+SubscriberRelationship = Literal["self", "spouse", "child", "other"]
+# X12 service type codes, e.g. 30 (health benefit plan coverage), 98 (office visit), 47 (hospital).
+ServiceTypeCode = Annotated[str, Field(pattern=r"^[A-Z0-9]{1,2}$")]
+
+class CoverageSubscriber(BaseModel):
+    """The policyholder, when the patient is their dependant."""
+    given_name: str = Field(..., alias="givenName")
+    family_name: str = Field(..., alias="familyName")
+    dob: BirthDate
+    sex: Literal["male", "female", "other", "unknown"] = "unknown"
+    model_config = ConfigDict(populate_by_name=True)
+
+class CoverageCreate(BaseModel):
+    payer_id: str = Field(..., alias="payerId", min_length=1, max_length=80, description="The payer's ID at the clearinghouse, e.g. '60054' (Aetna).")
+    payer_name: str = Field(..., alias="payerName", min_length=1, max_length=200)
+    member_id: str = Field(..., alias="memberId", min_length=1, max_length=80, description="The subscriber's ID with the payer. Encrypted at rest.", json_schema_extra=ENCRYPTED)
+    group_number: Optional[str] = Field(None, alias="groupNumber", max_length=50)
+    plan_name: Optional[str] = Field(None, alias="planName", max_length=200)
+    priority: CoveragePriority = "primary"
+    relationship: SubscriberRelationship = Field("self", description="The patient's relationship to the policyholder.")
+    subscriber: Optional[CoverageSubscriber] = Field(None, description="The policyholder; required unless `relationship` is self.")
+    period_start: Optional[date] = Field(None, alias="periodStart")
+    period_end: Optional[date] = Field(None, alias="periodEnd")
+    model_config = ConfigDict(populate_by_name=True)
+
+    @model_validator(mode="after")
+    def _check_subscriber(self):
+        if self.relationship != "self" and self.subscriber is None:
+            raise ValueError("'subscriber' is required unless the patient is the policyholder")
+        if self.period_start and self.period_end and self.period_start > self.period_end:
+            raise ValueError("'periodStart' must not be after 'periodEnd'")
+        return self
+
+class CoverageUpdate(BaseModel):
+    """Payload for partially updating a coverage; only fields that are sent are changed."""
+    payer_id: Optional[str] = Field(None, alias="payerId", min_length=1, max_length=80)
+    payer_name: Optional[str] = Field(None, alias="payerName", min_length=1, max_length=200)
+    member_id: Optional[str] = Field(None, alias="memberId", min_length=1, max_length=80, json_schema_extra=ENCRYPTED)
+    group_number: Optional[str] = Field(None, alias="groupNumber", max_length=50)
+    plan_name: Optional[str] = Field(None, alias="planName", max_length=200)
+    priority: Optional[CoveragePriority] = None
+    relationship: Optional[SubscriberRelationship] = None
+    subscriber: Optional[CoverageSubscriber] = None
+    period_start: Optional[date] = Field(None, alias="periodStart")
+    period_end: Optional[date] = Field(None, alias="periodEnd")
+    status: Optional[CoverageStatus] = Field(None, description="'inactive' once the plan no longer covers the patient.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class Coverage(CoverageCreate):
+    coverage_id: str = Field(..., alias="coverageId")
+    patient_id: str = Field(..., alias="patientId")
+    status: CoverageStatus = "active"
+    recorded_by: str = Field(..., alias="recordedBy")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+EligibilityCheckStatus = Literal["accepted", "in-progress", "completed", "error"]
+BenefitNetwork = Literal["in-network", "out-of-network", "any"]
+BenefitCoverageLevel = Literal["individual", "family", "employee", "other"]
+
+class EligibilityCheckCreate(BaseModel):
+    service_date: Optional[date] = Field(None, alias="serviceDate", description="The visit's date; today if omitted.")
+    service_types: List[ServiceTypeCode] = Field(default_factory=lambda: ["30"], alias="serviceTypes", min_length=1, max_length=10, description="X12 service type codes to ask about; 30 (health benefit plan coverage) by default.")
+    refresh: bool = Field(False, description="Ask the payer even if an answer within ELIGIBILITY_CACHE_SECONDS can be reused.")
+    model_config = ConfigDict(populate_by_name=True)
+
+    @field_validator("service_types")
+    @classmethod
+    def _sorted(cls, value: List[str]) -> List[str]:
+        return sorted(set(value))
+
+class BenefitSummary(BaseModel):
+    """What the plan pays for one service type, network and coverage level, read from the payer's answer."""
+    service_type: str = Field(..., alias="serviceType")
+    service_type_name: Optional[str] = Field(None, alias="serviceTypeName")
+    network: BenefitNetwork = "any"
+    coverage_level: Optional[BenefitCoverageLevel] = Field(None, alias="coverageLevel")
+    active: Optional[bool] = Field(None, description="Whether the service is covered; None if the payer did not say.")
+    copay_cents: Optional[int] = Field(None, alias="copayCents")
+    coinsurance_percent: Optional[float] = Field(None, alias="coinsurancePercent", description="The patient's share after the deductible, e.g. 20.")
+    deductible_cents: Optional[int] = Field(None, alias="deductibleCents")
+    deductible_remaining_cents: Optional[int] = Field(None, alias="deductibleRemainingCents")
+    out_of_pocket_max_cents: Optional[int] = Field(None, alias="outOfPocketMaxCents")
+    out_of_pocket_remaining_cents: Optional[int] = Field(None, alias="outOfPocketRemainingCents")
+    authorization_required: Optional[bool] = Field(None, alias="authorizationRequired")
+    notes: List[str] = Field(default_factory=list, description="The payer's free-text messages.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class EligibilityRejection(BaseModel):
+    """Why the payer or clearinghouse could not answer, e.g. X12 AAA code 72 (invalid member ID)."""
+    code: Optional[str] = None
+    description: str
+    follow_up: Optional[str] = Field(None, alias="followUp", description="What to do about it, e.g. 'Correct and resubmit'.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class EligibilityCheck(BaseModel):
+    check_id: str = Field(..., alias="checkId")
+    coverage_id: str = Field(..., alias="coverageId")
+    patient_id: str = Field(..., alias="patientId")
+    status: EligibilityCheckStatus = "accepted"
+    service_date: date = Field(..., alias="serviceDate")
+    service_types: List[str] = Field(..., alias="serviceTypes")
+    requested_by: str = Field(..., alias="requestedBy")
+    eligible: Optional[bool] = Field(None, description="Whether the plan covers the patient on the date of service, once completed.")
+    plan_status: Optional[str] = Field(None, alias="planStatus", description="As the payer put it, e.g. 'Active Coverage'.")
+    plan_name: Optional[str] = Field(None, alias="planName")
+    benefits: List[BenefitSummary] = Field(default_factory=list)
+    rejections: List[EligibilityRejection] = Field(default_factory=list)
+    error: Optional[str] = Field(None, description="Why the check could not be completed, e.g. the clearinghouse being unreachable.")
+    trace_number: Optional[str] = Field(None, alias="traceNumber", description="The check's reference with the clearinghouse.")
+    completed_at: Optional[datetime] = Field(None, alias="completedAt")
+    cached: bool = Field(False, description="The answer is an earlier check's, reused; not stored.")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Claim Schemas ---
+# What was done at a visit is recorded as charge items on its encounter and
+# billed to the patient's payer as a professional claim (X12 837P) through
+# a clearinghouse (see app/claims/). Amounts are in cents.
+# CPT codes (five digits, or four and F, T or U) and HCPCS Level II codes, e.g. 95810 or E0601.
+ProcedureCode = Annotated[str, Field(pattern=r"^([0-9]{4}[0-9FTU]|[A-V][0-9]{4})$")]
+ProcedureModifier = Annotated[str, Field(pattern=r"^[A-Z0-9]{2}$")]
+ChargeItemStatus = Literal["billable", "billed", "voided"]
+ClaimStatus = Literal["draft", "queued", "submitted", "accepted", "rejected", "paid", "denied", "void"]
+# X12 claim filing indicator codes: commercial, Blue Cross/Blue Shield, HMO,
+# Medicare Part B, Medicaid, TRICARE, VA and workers' compensation plans.
+ClaimFilingCode = Literal["CI", "BL", "HM", "MB", "MC", "CH", "VA", "WC"]
+# X12 claim adjustment group codes: contractual obligation, patient
+# responsibility, other adjustments, payer initiated and corrections.
+AdjustmentGroup = Literal["CO", "PR", "OA", "PI", "CR"]
+
+def _icd10(code: str) -> str:
+    """G4733 and g47.33 -> G47.33."""
+    code = code.strip().upper().replace(".", "")
+    if not re.fullmatch(r"[A-TV-Z][0-9][0-9A-Z][0-9A-Z]{0,4}", code):
+        raise ValueError(f"'{code}' is not an ICD-10-CM code such as G47.33")
+    return f"{code[:3]}.{code[3:]}" if len(code) > 3 else code
+
+class ChargeItemCreate(BaseModel):
+    procedure_code: ProcedureCode = Field(..., alias="procedureCode", description="CPT or HCPCS code of the service, e.g. 95810 (polysomnography).")
+    modifiers: List[ProcedureModifier] = Field(default_factory=list, max_length=4, description="e.g. 95 (synchronous telemedicine).")
+    diagnosis_codes: List[str] = Field(..., alias="diagnosisCodes", min_length=1, max_length=4, description="ICD-10-CM codes the service was for, most relevant first.")
+    units: int = Field(1, ge=1, le=999)
+    charge_cents: int = Field(..., alias="chargeCents", gt=0, description="The charge for all units.")
+    service_date: Optional[date] = Field(None, alias="serviceDate", description="The encounter's start date if omitted.")
+    practitioner_id: Optional[str] = Field(None, alias="practitionerId", description="Who rendered the service, billed as its rendering provider.")
+    description: Optional[str] = Field(None, max_length=200)
+    model_config = ConfigDict(populate_by_name=True)
+
+    @field_validator("diagnosis_codes")
+    @classmethod
+    def _normalized(cls, value: List[str]) -> List[str]:
+        codes = [_icd10(code) for code in value]
+        if len(set(codes)) != len(codes):
+            raise ValueError("'diagnosisCodes' must not repeat a code")
+        return codes
+
+class ChargeItem(ChargeItemCreate):
+    charge_item_id: str = Field(..., alias="chargeItemId")
+    encounter_id: str = Field(..., alias="encounterId")
+    patient_id: str = Field(..., alias="patientId")
+    service_date: date = Field(..., alias="serviceDate")
+    status: ChargeItemStatus = Field("billable", description="'billed' once on a claim, until the claim is voided.")
+    claim_id: Optional[str] = Field(None, alias="claimId")
+    recorded_by: str = Field(..., alias="recordedBy")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+class ClaimCreate(BaseModel):
+    encounter_id: str = Field(..., alias="encounterId", description="A finished encounter.")
+    coverage_id: Optional[str] = Field(None, alias="coverageId", description="The patient's active primary coverage if omitted.")
+    charge_item_ids: Optional[List[str]] = Field(None, alias="chargeItemIds", min_length=1, max_length=50, description="The encounter's billable charge items to bill; all of them if omitted.")
+    filing_code: ClaimFilingCode = Field("CI", alias="filingCode", description="The kind of plan billed; CI (commercial insurance) by default.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class ClaimAdjustment(BaseModel):
+    """An amount the payer did not pay, and why (an X12 CAS adjustment with its CARC)."""
+    group: AdjustmentGroup = Field(..., description="PR for amounts the patient owes, e.g. a deductible; CO for contractual write-offs.")
+    reason_code: str = Field(..., alias="reasonCode", pattern=r"^[A-Z0-9]{1,5}$", description="Claim adjustment reason code, e.g. 1 (deductible), 2 (coinsurance), 3 (copay), 45 (exceeds the fee schedule).")
+    amount_cents: int = Field(..., alias="amountCents")
+    model_config = ConfigDict(populate_by_name=True)
+
+class ClaimLine(BaseModel):
+    """A service line of the claim, from one charge item."""
+    charge_item_id: str = Field(..., alias="chargeItemId")
+    procedure_code: str = Field(..., alias="procedureCode")
+    modifiers: List[str] = Field(default_factory=list)
+    diagnosis_pointers: List[int] = Field(..., alias="diagnosisPointers", description="1-based positions in the claim's `diagnosisCodes`.")
+    units: int
+    charge_cents: int = Field(..., alias="chargeCents")
+    service_date: date = Field(..., alias="serviceDate")
+    practitioner_id: Optional[str] = Field(None, alias="practitionerId")
+    paid_cents: Optional[int] = Field(None, alias="paidCents")
+    adjustments: List[ClaimAdjustment] = Field(default_factory=list)
+    model_config = ConfigDict(populate_by_name=True)
+
+class ClaimDenialReason(BaseModel):
+    """
+    Why the clearinghouse or payer rejected or denied the claim: an edit the
+    claim failed, a claim status code, or a claim adjustment reason code.
+    """
+    code: Optional[str] = None
+    description: str
+    follow_up: Optional[str] = Field(None, alias="followUp", description="What to do about it, e.g. 'Correct and resubmit'.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class ClaimStatusChange(BaseModel):
+    status: ClaimStatus
+    at: datetime
+    by: str = Field(..., description="The user; 'clearinghouse' for statuses the clearinghouse or payer reported, 'system:claims' for claims unfit to send.")
+    note: Optional[str] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class ClaimLineAdjudication(BaseModel):
+    charge_item_id: str = Field(..., alias="chargeItemId")
+    paid_cents: int = Field(..., alias="paidCents", ge=0)
+    adjustments: List[ClaimAdjustment] = Field(default_factory=list)
+    model_config = ConfigDict(populate_by_name=True)
+
+class ClaimStatusUpdate(BaseModel):
+    """A status recorded by hand, e.g. from a remittance advice that came on paper, or voiding a claim."""
+    status: Literal["accepted", "rejected", "paid", "denied", "void"]
+    paid_cents: Optional[int] = Field(None, alias="paidCents", ge=0)
+    patient_responsibility_cents: Optional[int] = Field(None, alias="patientResponsibilityCents", ge=0, description="The lines' PR adjustments if omitted.")
+    payer_claim_number: Optional[str] = Field(None, alias="payerClaimNumber", max_length=50)
+    denial_reasons: List[ClaimDenialReason] = Field(default_factory=list, alias="denialReasons", max_length=20)
+    lines: List[ClaimLineAdjudication] = Field(default_factory=list, max_length=50, description="What the payer paid and adjusted per line.")
+    note: Optional[str] = Field(None, max_length=500)
+    model_config = ConfigDict(populate_by_name=True)
+
+class Claim(BaseModel):
+    claim_id: str = Field(..., alias="claimId")
+    encounter_id: str = Field(..., alias="encounterId")
+    patient_id: str = Field(..., alias="patientId")
+    coverage_id: str = Field(..., alias="coverageId")
+    payer_id: str = Field(..., alias="payerId")
+    payer_name: str = Field(..., alias="payerName")
+    filing_code: ClaimFilingCode = Field("CI", alias="filingCode")
+    status: ClaimStatus = "draft"
+    place_of_service: str = Field(..., alias="placeOfService", description="CMS place of service code, from the encounter's visit type, e.g. 11 (office).")
+    diagnosis_codes: List[str] = Field(..., alias="diagnosisCodes")
+    lines: List[ClaimLine]
+    total_charge_cents: int = Field(..., alias="totalChargeCents")
+    paid_cents: Optional[int] = Field(None, alias="paidCents")
+    patient_responsibility_cents: Optional[int] = Field(None, alias="patientResponsibilityCents")
+    patient_control_number: Optional[str] = Field(None, alias="patientControlNumber", description="The claim's reference with the payer (CLM01), set when first submitted.")
+    payer_claim_number: Optional[str] = Field(None, alias="payerClaimNumber")
+    clearinghouse_reference: Optional[str] = Field(None, alias="clearinghouseReference")
+    denial_reasons: List[ClaimDenialReason] = Field(default_factory=list, alias="denialReasons")
+    error: Optional[str] = Field(None, description="Why the claim could not be sent, e.g. the clearinghouse being unreachable.")
+    submitted_at: Optional[datetime] = Field(None, alias="submittedAt")
+    adjudicated_at: Optional[datetime] = Field(None, alias="adjudicatedAt")
+    status_checked_at: Optional[datetime] = Field(None, alias="statusCheckedAt", description="When the payer was last asked for the claim's status.")
+    history: List[ClaimStatusChange] = Field(default_factory=list)
+    created_by: str = Field(..., alias="createdBy")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Invoice Schemas ---
+# What the patient owes once their payer has adjudicated a claim, itemized
+# by the claim's lines with what the plan paid and adjusted, and the
+# payments taken against it (see app/invoices/). Amounts are in cents.
+InvoiceStatus = Literal["draft", "issued", "partially-paid", "paid", "void"]
+PaymentMethod = Literal["card", "cash", "check", "bank-transfer", "other"]
+
+class InvoiceCreate(BaseModel):
+    claim_id: str = Field(..., alias="claimId", description="A paid or denied claim whose lines leave the patient something to pay.")
+    due_date: Optional[date] = Field(None, alias="dueDate", description="INVOICE_DUE_DAYS after the invoice is issued if omitted.")
+    message: Optional[str] = Field(None, max_length=500, description="Printed on the statement, e.g. payment plan terms.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class InvoiceLine(BaseModel):
+    """A service on the statement: its charge, what the plan paid and adjusted, and the patient's share."""
+    charge_item_id: str = Field(..., alias="chargeItemId")
+    service_date: date = Field(..., alias="serviceDate")
+    procedure_code: str = Field(..., alias="procedureCode")
+    modifiers: List[str] = Field(default_factory=list)
+    description: Optional[str] = None
+    units: int = 1
+    charge_cents: int = Field(..., alias="chargeCents")
+    insurance_paid_cents: int = Field(0, alias="insurancePaidCents")
+    adjustments: List[ClaimAdjustment] = Field(default_factory=list)
+    patient_cents: int = Field(..., alias="patientCents", description="The line's patient responsibility (PR) adjustments.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class InvoicePaymentCreate(BaseModel):
+    amount_cents: int = Field(..., alias="amountCents", gt=0)
+    method: PaymentMethod
+    reference: Optional[str] = Field(None, max_length=100, description="E.g. the check number or the card terminal's transaction ID; a payment with a reference already recorded is refused.")
+    received_at: Optional[datetime] = Field(None, alias="receivedAt", description="Now if omitted.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class InvoicePayment(InvoicePaymentCreate):
+    payment_id: str = Field(..., alias="paymentId")
+    received_at: datetime = Field(..., alias="receivedAt")
+    source: Literal["manual", "stripe"] = Field("manual", description="Recorded by staff, or reported by the payment provider.")
+    recorded_by: str = Field(..., alias="recordedBy")
+
+class InvoiceStatusUpdate(BaseModel):
+    """Issue a draft to the patient, or void an invoice nothing was paid on."""
+    status: Literal["issued", "void"]
+    note: Optional[str] = Field(None, max_length=500)
+    model_config = ConfigDict(populate_by_name=True)
+
+class InvoiceStatement(BaseModel):
+    """The PDF statement rendered when the invoice was issued."""
+    blob_name: str = Field(..., alias="blobName")
+    rendered_at: datetime = Field(..., alias="renderedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+class InvoiceDownload(BaseModel):
+    url: str
+    expires_at: datetime = Field(..., alias="expiresAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+class Invoice(BaseModel):
+    invoice_id: str = Field(..., alias="invoiceId")
+    statement_number: str = Field(..., alias="statementNumber", description="What the patient quotes when paying.")
+    patient_id: str = Field(..., alias="patientId")
+    claim_id: str = Field(..., alias="claimId")
+    encounter_id: str = Field(..., alias="encounterId")
+    payer_name: str = Field(..., alias="payerName")
+    status: InvoiceStatus = "draft"
+    lines: List[InvoiceLine]
+    total_charge_cents: int = Field(..., alias="totalChargeCents")
+    insurance_paid_cents: int = Field(..., alias="insurancePaidCents")
+    adjustment_cents: int = Field(..., alias="adjustmentCents", description="What the plan's other adjustments, e.g. contractual (CO), took off the charges.")
+    amount_due_cents: int = Field(..., alias="amountDueCents")
+    amount_paid_cents: int = Field(0, alias="amountPaidCents")
+    balance_cents: int = Field(..., alias="balanceCents")
+    due_date: Optional[date] = Field(None, alias="dueDate")
+    message: Optional[str] = None
+    payments: List[InvoicePayment] = Field(default_factory=list)
+    statement: Optional[InvoiceStatement] = None
+    issued_at: Optional[datetime] = Field(None, alias="issuedAt")
+    paid_at: Optional[datetime] = Field(None, alias="paidAt")
+    voided_at: Optional[datetime] = Field(None, alias="voidedAt")
+    void_note: Optional[str] = Field(None, alias="voidNote")
+    created_by: str = Field(..., alias="createdBy")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+class InvoiceCheckout(BaseModel):
+    """A page at the payment provider where the patient pays the invoice's balance."""
+    url: str
+    expires_at: datetime = Field(..., alias="expiresAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Practitioner Schemas ---
+class PractitionerBase(BaseModel):
+    given_name: str = Field(..., alias="givenName")
+    family_name: str = Field(..., alias="familyName")
+    npi: NationalProviderIdentifier = Field(..., description="10-digit National Provider Identifier.")
+    specialty: Optional[str] = None
+    contact: Optional[ContactInfo] = None
+    active: bool = True
+    model_config = ConfigDict(populate_by_name=True)
+
+class PractitionerCreate(PractitionerBase):
+    contact: Optional[ContactInfoCreate] = None
+
+class PractitionerUpdate(BaseModel):
+    given_name: Optional[str] = Field(None, alias="givenName")
+    family_name: Optional[str] = Field(None, alias="familyName")
+    specialty: Optional[str] = None
+    contact: Optional[ContactInfoCreate] = None
+    active: Optional[bool] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class Practitioner(PractitionerBase):
+    practitioner_id: str = Field(..., alias="practitionerId")
+    deleted_at: Optional[datetime] = Field(None, alias="deletedAt")
+    deleted_by: Optional[str] = Field(None, alias="deletedBy")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Care Team Schemas ---
+class CareTeamMember(BaseModel):
+    practitioner_id: str = Field(..., alias="practitionerId")
+    role: str = Field(..., description="The member's role on the team, e.g. 'primary-physician' or 'respiratory-therapist'.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class CareTeamBase(BaseModel):
+    patient_id: str = Field(..., alias="patientId")
+    name: str
+    status: Literal["active", "inactive"] = "active"
+    members: List[CareTeamMember] = []
+    model_config = ConfigDict(populate_by_name=True)
+
+class CareTeamCreate(CareTeamBase):
+    pass
+
+class CareTeamUpdate(BaseModel):
+    name: Optional[str] = None
+    status: Optional[Literal["active", "inactive"]] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class CareTeam(CareTeamBase):
+    care_team_id: str = Field(..., alias="careTeamId")
+    deleted_at: Optional[datetime] = Field(None, alias="deletedAt")
+    deleted_by: Optional[str] = Field(None, alias="deletedBy")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Appointment Schemas ---
+AppointmentStatus = Literal["booked", "arrived", "fulfilled", "cancelled"]
+
+class AppointmentBase(BaseModel):
+    patient_id: str = Field(..., alias="patientId")
+    practitioner_id: str = Field(..., alias="practitionerId")
+    start: datetime
+    end: datetime
+    appointment_type: Optional[str] = Field(None, alias="appointmentType", description="e.g. 'cpap-setup', 'follow-up', 'mask-fitting'.")
+    reason: Optional[str] = None
+    location: Optional[str] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+    @model_validator(mode="after")
+    def validate_period(self):
+        if self.end <= self.start:
+            raise ValueError("end must be after start")
+        return self
+
+class AppointmentCreate(AppointmentBase):
+    pass
+
+class AppointmentReschedule(BaseModel):
+    start: datetime
+    end: datetime
+    model_config = ConfigDict(populate_by_name=True)
+
+    @model_validator(mode="after")
+    def validate_period(self):
+        if self.end <= self.start:
+            raise ValueError("end must be after start")
+        return self
+
+class AppointmentCancel(BaseModel):
+    cancellation_reason: Optional[str] = Field(None, alias="cancellationReason")
+    model_config = ConfigDict(populate_by_name=True)
+
+class AppointmentStatusUpdate(BaseModel):
+    status: AppointmentStatus
+    model_config = ConfigDict(populate_by_name=True)
+
+class Appointment(AppointmentBase):
+    appointment_id: str = Field(..., alias="appointmentId")
+    status: AppointmentStatus = "booked"
+    cancellation_reason: Optional[str] = Field(None, alias="cancellationReason")
+    reminder_sent_at: Optional[datetime] = Field(None, alias="reminderSentAt", description="When the reminder job announced the upcoming appointment.")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Appointment Reminder Schemas ---
+# The reminders a booked appointment's patient is sent (see app/reminders),
+# one per APPOINTMENT_REMINDER_HOURS offset. A reminder is scheduled when the
+# appointment is booked or moved and cancelled when it is moved or leaves
+# `booked`; it is sent at most once.
+ReminderStatus = Literal["scheduled", "sent", "cancelled", "skipped"]
+
+class AppointmentReminder(BaseModel):
+    reminder_id: str = Field(..., alias="reminderId")
+    appointment_id: str = Field(..., alias="appointmentId")
+    patient_id: str = Field(..., alias="patientId")
+    offset_hours: int = Field(..., alias="offsetHours", description="How long before the appointment the reminder is for.")
+    due_at: datetime = Field(..., alias="dueAt", description="offsetHours before the start, moved earlier out of the patient's quiet hours (in their timezone).")
+    appointment_start: datetime = Field(..., alias="appointmentStart", description="The start the reminder announces.")
+    status: ReminderStatus = "scheduled"
+    reason: Optional[str] = Field(None, description="Why the reminder was cancelled or skipped.")
+    finished_at: Optional[datetime] = Field(None, alias="finishedAt", description="When it was sent, cancelled or skipped.")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Observation Schemas ---
+class Coding(BaseModel):
+    system: str = Field(..., description="Code system URI, e.g. 'http://loinc.org'.")
+    code: str
+    display: Optional[str] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class Identifier(BaseModel):
+    system: str
+    value: str
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Terminology Schemas ---
+# Codes are looked up in the loaded code systems (see app/terminology), or
+# at TERMINOLOGY_SERVER_URL for codes those do not hold.
+CodeVerification = Literal["concept", "syntax", "none"]
+
+class CodeLookup(BaseModel):
+    system: str
+    code: str
+    display: Optional[str] = None
+    version: Optional[str] = None
+    inactive: bool = False
+    parents: List[str] = Field([], description="Codes the concept is a kind of, as far as the loaded content knows.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class CodeValidation(BaseModel):
+    result: bool = Field(..., description="False when the code is known not to be valid here.")
+    verification: CodeVerification = Field(
+        ..., description="`concept` if the code was found, `syntax` if only its form could be checked, `none` for a code system nothing is known about.",
+    )
+    system: str
+    code: str
+    display: Optional[str] = Field(None, description="The code's preferred display, when it was found.")
+    inactive: bool = False
+    message: Optional[str] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class ValueSetSummary(BaseModel):
+    id: str
+    url: str
+    name: Optional[str] = None
+    title: Optional[str] = None
+    version: Optional[str] = None
+    description: Optional[str] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class ValueSetExpansion(BaseModel):
+    id: str
+    url: str
+    total: int = Field(..., description="Codes matching `filter`, across every page.")
+    offset: int
+    contains: List[Coding]
+    model_config = ConfigDict(populate_by_name=True)
+
+class ObservationComponent(BaseModel):
+    """One part of a multi-part observation, e.g. the systolic value of a blood pressure."""
+    code: Coding
+    value: Optional[float] = None
+    unit: Optional[str] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class ObservationBase(BaseModel):
+    patient_id: str = Field(..., alias="patientId")
+    code: Coding
+    status: Literal["registered", "preliminary", "final", "amended"] = "final"
+    value: Optional[float] = None
+    unit: Optional[str] = Field(None, description="UCUM unit of `value`, e.g. '%' or 'kg'.")
+    components: List[ObservationComponent] = []
+    effective_at: datetime = Field(..., alias="effectiveAt")
+    identifier: Optional[Identifier] = Field(None, description="Identifier assigned by the sending system, if any.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class ObservationCreate(ObservationBase):
+    pass
+
+class VitalSignComponentCreate(BaseModel):
+    code: str = Field(..., description="LOINC code of the component, e.g. '8480-6' for systolic pressure.")
+    value: float
+    unit: str
+    model_config = ConfigDict(populate_by_name=True)
+
+class VitalSignCreate(BaseModel):
+    """Payload for recording a vital sign. `code` must be one of the supported LOINC vital-sign codes."""
+    code: str = Field(..., description="LOINC code, e.g. '59408-5' (SpO2), '8867-4' (heart rate), '85354-9' (blood pressure), '29463-7' (weight).")
+    value: Optional[float] = None
+    unit: Optional[str] = Field(None, description="UCUM unit; must be valid for the code.")
+    components: List[VitalSignComponentCreate] = []
+    effective_at: datetime = Field(..., alias="effectiveAt", description="When the measurement was taken; must include a timezone.")
+    status: Literal["preliminary", "final", "amended"] = "final"
+    model_config = ConfigDict(populate_by_name=True)
+
+class Observation(ObservationBase):
+    observation_id: str = Field(..., alias="observationId")
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Bulk Export Schemas ---
+ExportJobStatus = Literal["accepted", "in-progress", "completed", "error", "cancelled"]
+
+class ExportOutputFile(BaseModel):
+    type: str = Field(..., description="FHIR resource type contained in the file.")
+    blob_name: str = Field(..., alias="blobName")
+    count: int
+    model_config = ConfigDict(populate_by_name=True)
+
+class ExportJobCreate(BaseModel):
+    types: List[str]
+    since: Optional[datetime] = None
+    request_url: str = Field(..., alias="requestUrl")
+    requested_by: str = Field(..., alias="requestedBy", description="UID of the user who kicked off the export.")
+    transaction_time: datetime = Field(..., alias="transactionTime")
+    model_config = ConfigDict(populate_by_name=True)
+
+class ExportJob(ExportJobCreate):
+    job_id: str = Field(..., alias="jobId")
+    status: ExportJobStatus = "accepted"
+    progress: Optional[str] = None
+    output: List[ExportOutputFile] = []
+    error: Optional[str] = None
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Bulk Import Schemas ---
+# An import works through an uploaded NDJSON file one chunk of lines per
+# task run (see app/services/bulk_import.py); `offset` and `linesRead` are
+# where the next chunk starts.
+ImportJobStatus = Literal["accepted", "in-progress", "completed", "error", "cancelled"]
+ImportResourceType = Literal["Patient", "Observation"]
+
+class ImportJobCreate(BaseModel):
+    blob_name: str = Field(..., alias="blobName")
+    size: int = Field(..., description="Size of the uploaded file in bytes.")
+    requested_by: str = Field(..., alias="requestedBy", description="UID of the user or API key that uploaded the file.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class ImportJob(ImportJobCreate):
+    job_id: str = Field(..., alias="jobId")
+    status: ImportJobStatus = "accepted"
+    offset: int = Field(0, description="Bytes of the file read so far.")
+    lines_read: int = Field(0, alias="linesRead")
+    imported: Dict[str, int] = Field({}, description="Records created, by resource type.")
+    skipped: int = Field(0, description="Observations already stored with the same identifier by an earlier import.")
+    failed: int = Field(0, description="Lines refused; the error report says why.")
+    chunk_started_at: Optional[datetime] = Field(None, alias="chunkStartedAt", description="When the chunk being imported was first started.")
+    error: Optional[str] = Field(None, description="Why the import stopped, for status 'error'.")
+    completed_at: Optional[datetime] = Field(None, alias="completedAt")
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class ImportLineError(BaseModel):
+    """A line of the error report: a line of the file that was not imported."""
+    line: int = Field(..., description="Line number in the file, from 1.")
+    resource_type: Optional[str] = Field(None, alias="resourceType")
+    error: str
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Telemetry Schemas ---
+MAX_TELEMETRY_BATCH_SIZE = 500
+
+class TelemetryReading(BaseModel):
+    sequence: int = Field(..., ge=0, description="Per-device counter that increases with every reading; used to drop resent readings.")
+    recorded_at: datetime = Field(..., alias="recordedAt")
+    metrics: Dict[str, float] = Field(..., min_length=1, description="Measured values keyed by name, e.g. {'spo2': 96, 'pulseRate': 72} or {'pressure': 10.4, 'leak': 12}.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class TelemetryBatch(BaseModel):
+    readings: List[TelemetryReading] = Field(..., min_length=1, max_length=MAX_TELEMETRY_BATCH_SIZE)
+    model_config = ConfigDict(populate_by_name=True)
+
+class TelemetryBatchResult(BaseModel):
+    accepted: int
+    duplicates: int
+    last_sequence: Optional[int] = Field(None, alias="lastSequence", description="Highest sequence number accepted so far for this device.")
+    message_id: Optional[str] = Field(None, alias="messageId", description="Pub/Sub message ID of the published batch, if anything was published.")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Care Plan Schemas ---
+CarePlanStatus = Literal["draft", "active", "on-hold", "completed", "revoked"]
+GoalStatus = Literal["proposed", "in-progress", "achieved", "not-achieved", "cancelled"]
+ActivityStatus = Literal["not-started", "scheduled", "in-progress", "on-hold", "completed", "cancelled"]
+
+class CarePlanGoalCreate(BaseModel):
+    description: str
+    measure: Optional[Coding] = Field(None, description="What is measured to track the goal, e.g. the LOINC code for AHI.")
+    target_value: Optional[float] = Field(None, alias="targetValue")
+    target_unit: Optional[str] = Field(None, alias="targetUnit")
+    due_date: Optional[date] = Field(None, alias="dueDate")
+    status: GoalStatus = "proposed"
+    model_config = ConfigDict(populate_by_name=True)
+
+class CarePlanGoalUpdate(BaseModel):
+    description: Optional[str] = None
+    measure: Optional[Coding] = None
+    target_value: Optional[float] = Field(None, alias="targetValue")
+    target_unit: Optional[str] = Field(None, alias="targetUnit")
+    due_date: Optional[date] = Field(None, alias="dueDate")
+    status: Optional[GoalStatus] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class CarePlanGoal(CarePlanGoalCreate):
+    goal_id: str = Field(..., alias="goalId")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class ActivitySchedule(BaseModel):
+    """A repeating schedule, e.g. 3 times per week from start_date until end_date."""
+    frequency: int = Field(..., ge=1)
+    period: Literal["day", "week", "month"]
+    start_date: date = Field(..., alias="startDate")
+    end_date: Optional[date] = Field(None, alias="endDate")
+    model_config = ConfigDict(populate_by_name=True)
+
+    @model_validator(mode="after")
+    def validate_dates(self):
+        if self.end_date and self.end_date < self.start_date:
+            raise ValueError("endDate must not be before startDate")
+        return self
+
+class CarePlanActivityCreate(BaseModel):
+    description: str
+    kind: Optional[str] = Field(None, description="e.g. 'appointment', 'education', 'measurement', 'device-check'.")
+    schedule: Optional[ActivitySchedule] = None
+    practitioner_id: Optional[str] = Field(None, alias="practitionerId", description="Practitioner responsible for the activity.")
+    status: ActivityStatus = "not-started"
+    model_config = ConfigDict(populate_by_name=True)
+
+class CarePlanActivityUpdate(BaseModel):
+    description: Optional[str] = None
+    kind: Optional[str] = None
+    schedule: Optional[ActivitySchedule] = None
+    practitioner_id: Optional[str] = Field(None, alias="practitionerId")
+    status: Optional[ActivityStatus] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class CarePlanActivity(CarePlanActivityCreate):
+    activity_id: str = Field(..., alias="activityId")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class CarePlanBase(BaseModel):
+    patient_id: str = Field(..., alias="patientId")
+    title: str
+    description: Optional[str] = None
+    category: Optional[str] = Field(None, description="The program the plan belongs to, e.g. 'sleep-apnea' or 'copd'.")
+    care_team_id: Optional[str] = Field(None, alias="careTeamId")
+    period_start: date = Field(..., alias="periodStart")
+    period_end: Optional[date] = Field(None, alias="periodEnd")
+    model_config = ConfigDict(populate_by_name=True)
+
+class CarePlanCreate(CarePlanBase):
+    goals: List[CarePlanGoalCreate] = []
+    activities: List[CarePlanActivityCreate] = []
+
+    @model_validator(mode="after")
+    def validate_period(self):
+        if self.period_end and self.period_end < self.period_start:
+            raise ValueError("periodEnd must not be before periodStart")
+        return self
+
+class CarePlanUpdate(BaseModel):
+    """Changes to a plan's details. Status is changed through the `/status` endpoint."""
+    title: Optional[str] = None
+    description: Optional[str] = None
+    category: Optional[str] = None
+    care_team_id: Optional[str] = Field(None, alias="careTeamId")
+    period_start: Optional[date] = Field(None, alias="periodStart")
+    period_end: Optional[date] = Field(None, alias="periodEnd")
+    model_config = ConfigDict(populate_by_name=True)
+
+class CarePlanStatusUpdate(BaseModel):
+    status: CarePlanStatus
+    reason: Optional[str] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class CarePlanStatusChange(BaseModel):
+    status: CarePlanStatus
+    changed_at: datetime = Field(..., alias="changedAt")
+    changed_by: str = Field(..., alias="changedBy")
+    reason: Optional[str] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class CarePlan(CarePlanBase):
+    care_plan_id: str = Field(..., alias="carePlanId")
+    status: CarePlanStatus = "draft"
+    goals: List[CarePlanGoal] = []
+    activities: List[CarePlanActivity] = []
+    status_history: List[CarePlanStatusChange] = Field([], alias="statusHistory")
+    deleted_at: Optional[datetime] = Field(None, alias="deletedAt")
+    deleted_by: Optional[str] = Field(None, alias="deletedBy")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Medication Schemas ---
+MedicationStatus = Literal["active", "on-hold", "stopped", "completed"]
+RefillRequestStatus = Literal["requested", "under-review", "approved", "denied"]
+
+class MedicationBase(BaseModel):
+    code: Optional[Coding] = Field(None, description="Coded medication, e.g. an RxNorm concept.")
+    name: str
+    dosage: Optional[str] = Field(None, description="Free-text dosage instructions, e.g. '1 tablet twice daily'.")
+    route: Optional[str] = None
+    quantity: Optional[int] = Field(None, ge=1, description="Units dispensed per fill.")
+    refills_allowed: int = Field(0, ge=0, alias="refillsAllowed")
+    prescriber_id: Optional[str] = Field(None, alias="prescriberId")
+    start_date: Optional[date] = Field(None, alias="startDate")
+    end_date: Optional[date] = Field(None, alias="endDate")
+    model_config = ConfigDict(populate_by_name=True)
+
+class MedicationCreate(MedicationBase):
+    status: MedicationStatus = "active"
+
+class MedicationUpdate(BaseModel):
+    name: Optional[str] = None
+    dosage: Optional[str] = None
+    route: Optional[str] = None
+    quantity: Optional[int] = Field(None, ge=1)
+    refills_allowed: Optional[int] = Field(None, ge=0, alias="refillsAllowed")
+    prescriber_id: Optional[str] = Field(None, alias="prescriberId")
+    end_date: Optional[date] = Field(None, alias="endDate")
+    status: Optional[MedicationStatus] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class Medication(MedicationBase):
+    medication_id: str = Field(..., alias="medicationId")
+    patient_id: str = Field(..., alias="patientId")
+    status: MedicationStatus
+    refills_used: int = Field(0, alias="refillsUsed")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class RefillRequestCreate(BaseModel):
+    note: Optional[str] = Field(None, description="Message from the patient to the reviewing practitioner.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class RefillRequestAssign(BaseModel):
+    practitioner_id: str = Field(..., alias="practitionerId")
+    model_config = ConfigDict(populate_by_name=True)
+
+class RefillRequestStatusUpdate(BaseModel):
+    status: RefillRequestStatus
+    decision_note: Optional[str] = Field(None, alias="decisionNote", description="Shown to the patient, e.g. why a refill was denied.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class RefillRequest(BaseModel):
+    refill_request_id: str = Field(..., alias="refillRequestId")
+    patient_id: str = Field(..., alias="patientId")
+    medication_id: str = Field(..., alias="medicationId")
+    status: RefillRequestStatus
+    note: Optional[str] = None
+    requested_by: str = Field(..., alias="requestedBy")
+    assigned_practitioner_id: Optional[str] = Field(None, alias="assignedPractitionerId")
+    decision_note: Optional[str] = Field(None, alias="decisionNote")
+    decided_by: Optional[str] = Field(None, alias="decidedBy")
+    decided_at: Optional[datetime] = Field(None, alias="decidedAt")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Allergy Schemas ---
+AllergyClinicalStatus = Literal["active", "inactive", "resolved"]
+AllergyVerificationStatus = Literal["unconfirmed", "confirmed", "refuted", "entered-in-error"]
+AllergyCriticality = Literal["low", "high", "unable-to-assess"]
+
+class AllergyReaction(BaseModel):
+    manifestations: List[Coding] = Field(..., min_length=1, description="Clinical signs, e.g. a SNOMED CT code for urticaria.")
+    severity: Optional[Literal["mild", "moderate", "severe"]] = None
+    onset: Optional[datetime] = None
+    note: Optional[str] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class AllergyBase(BaseModel):
+    substance: Coding = Field(..., description="The substance the patient reacts to, e.g. an RxNorm or SNOMED CT code.")
+    type: Literal["allergy", "intolerance"] = "allergy"
+    category: Optional[Literal["food", "medication", "environment", "biologic"]] = None
+    criticality: Optional[AllergyCriticality] = None
+    clinical_status: AllergyClinicalStatus = Field("active", alias="clinicalStatus")
+    verification_status: AllergyVerificationStatus = Field("unconfirmed", alias="verificationStatus")
+    reactions: List[AllergyReaction] = []
+    onset_date: Optional[date] = Field(None, alias="onsetDate")
+    note: Optional[str] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class AllergyCreate(AllergyBase):
+    pass
+
+class AllergyUpdate(BaseModel):
+    category: Optional[Literal["food", "medication", "environment", "biologic"]] = None
+    criticality: Optional[AllergyCriticality] = None
+    clinical_status: Optional[AllergyClinicalStatus] = Field(None, alias="clinicalStatus")
+    verification_status: Optional[AllergyVerificationStatus] = Field(None, alias="verificationStatus")
+    reactions: Optional[List[AllergyReaction]] = None
+    onset_date: Optional[date] = Field(None, alias="onsetDate")
+    note: Optional[str] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class Allergy(AllergyBase):
+    allergy_id: str = Field(..., alias="allergyId")
+    patient_id: str = Field(..., alias="patientId")
+    recorded_by: str = Field(..., alias="recordedBy")
+    deleted_at: Optional[datetime] = Field(None, alias="deletedAt")
+    deleted_by: Optional[str] = Field(None, alias="deletedBy")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Immunization Schemas ---
+CVX_SYSTEM = "http://hl7.org/fhir/sid/cvx"
+ImmunizationStatus = Literal["completed", "not-done", "entered-in-error"]
+
+class ImmunizationBase(BaseModel):
+    vaccine_code: Coding = Field(..., alias="vaccineCode", description=f"CVX vaccine code (system '{CVX_SYSTEM}').")
+    status: ImmunizationStatus = "completed"
+    status_reason: Optional[str] = Field(None, alias="statusReason", description="Why a dose was not given, for 'not-done'.")
+    occurrence_date: date = Field(..., alias="occurrenceDate", description="Date the vaccine was administered.")
+    lot_number: Optional[str] = Field(None, alias="lotNumber")
+    expiration_date: Optional[date] = Field(None, alias="expirationDate", description="Expiry of the lot.")
+    manufacturer: Optional[str] = None
+    site: Optional[str] = Field(None, description="Body site, e.g. 'left deltoid'.")
+    route: Optional[str] = None
+    performer_id: Optional[str] = Field(None, alias="performerId", description="Practitioner who administered the dose.")
+    series: Optional[str] = Field(None, description="Name of the series the dose belongs to, e.g. 'Hepatitis B 3-dose'.")
+    dose_number: Optional[int] = Field(None, ge=1, alias="doseNumber")
+    series_doses: Optional[int] = Field(None, ge=1, alias="seriesDoses")
+    model_config = ConfigDict(populate_by_name=True)
+
+class ImmunizationCreate(ImmunizationBase):
+    @field_validator("vaccine_code")
+    @classmethod
+    def validate_cvx(cls, code: Coding) -> Coding:
+        if code.system != CVX_SYSTEM:
+            raise ValueError(f"vaccineCode must use the CVX system '{CVX_SYSTEM}'")
+        if not (code.code.isdigit() and len(code.code) <= 3):
+            raise ValueError("CVX codes are 1-3 digits")
+        return code
+
+    @model_validator(mode="after")
+    def validate_series(self):
+        if self.dose_number and self.series_doses and self.dose_number > self.series_doses:
+            raise ValueError("doseNumber must not exceed seriesDoses")
+        return self
+
+class ImmunizationUpdate(BaseModel):
+    """Corrections to a recorded dose; the vaccine and date cannot be changed."""
+    status: Optional[ImmunizationStatus] = None
+    status_reason: Optional[str] = Field(None, alias="statusReason")
+    lot_number: Optional[str] = Field(None, alias="lotNumber")
+    expiration_date: Optional[date] = Field(None, alias="expirationDate")
+    manufacturer: Optional[str] = None
+    site: Optional[str] = None
+    route: Optional[str] = None
+    series: Optional[str] = None
+    dose_number: Optional[int] = Field(None, ge=1, alias="doseNumber")
+    series_doses: Optional[int] = Field(None, ge=1, alias="seriesDoses")
+    model_config = ConfigDict(populate_by_name=True)
+
+class Immunization(ImmunizationBase):
+    immunization_id: str = Field(..., alias="immunizationId")
+    patient_id: str = Field(..., alias="patientId")
+    recorded_by: str = Field(..., alias="recordedBy")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class ImmunizationRecommendation(BaseModel):
+    series_id: str = Field(..., alias="seriesId")
+    series_name: str = Field(..., alias="seriesName")
+    status: Literal["upcoming", "due", "overdue", "complete"]
+    doses_given: int = Field(..., alias="dosesGiven")
+    dose_number: Optional[int] = Field(None, alias="doseNumber", description="The next dose; None once the series is complete.")
+    due_date: Optional[date] = Field(None, alias="dueDate")
+    overdue_date: Optional[date] = Field(None, alias="overdueDate")
+    model_config = ConfigDict(populate_by_name=True)
+
+class ImmunizationForecast(BaseModel):
+    patient_id: str = Field(..., alias="patientId")
+    as_of: date = Field(..., alias="asOf")
+    recommendations: List[ImmunizationRecommendation]
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Encounter Schemas ---
+EncounterStatus = Literal["planned", "in-progress", "finished", "cancelled"]
+EncounterVisitType = Literal["ambulatory", "virtual", "home", "inpatient", "emergency"]
+
+class EncounterParticipant(BaseModel):
+    practitioner_id: str = Field(..., alias="practitionerId")
+    role: Optional[str] = Field(None, description="e.g. 'attender', 'consultant', 'nurse'.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class EncounterDocument(BaseModel):
+    """A document produced during the visit (visit note, sleep study report, ...), stored elsewhere."""
+    title: str
+    content_type: Optional[str] = Field(None, alias="contentType")
+    url: str = Field(..., description="Location of the document, e.g. a gs:// URI or document service URL.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class EncounterDocumentLink(EncounterDocument):
+    document_id: str = Field(..., alias="documentId")
+    added_at: datetime = Field(..., alias="addedAt")
+    added_by: str = Field(..., alias="addedBy")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class EncounterObservationLink(BaseModel):
+    observation_id: str = Field(..., alias="observationId")
+    model_config = ConfigDict(populate_by_name=True)
+
+class EncounterBase(BaseModel):
+    patient_id: str = Field(..., alias="patientId")
+    visit_type: EncounterVisitType = Field(..., alias="visitType")
+    reason: Optional[str] = None
+    location: Optional[str] = None
+    start: datetime
+    end: Optional[datetime] = None
+    participants: List[EncounterParticipant] = []
+    appointment_id: Optional[str] = Field(None, alias="appointmentId", description="The appointment this visit fulfils, if any.")
+    psychotherapy_notes: Optional[str] = Field(
+        None, alias="psychotherapyNotes",
+        description="The therapist's session notes, kept apart from the rest of the record. Encrypted at rest.",
+        json_schema_extra=ENCRYPTED,
+    )
+    model_config = ConfigDict(populate_by_name=True)
+
+    @model_validator(mode="after")
+    def validate_period(self):
+        if self.end and self.end <= self.start:
+            raise ValueError("end must be after start")
+        return self
+
+class EncounterCreate(EncounterBase):
+    status: Literal["planned", "in-progress"] = "in-progress"
+
+class EncounterUpdate(BaseModel):
+    """Changes to a visit's details. Status is changed through the `/status` endpoint."""
+    visit_type: Optional[EncounterVisitType] = Field(None, alias="visitType")
+    reason: Optional[str] = None
+    location: Optional[str] = None
+    start: Optional[datetime] = None
+    end: Optional[datetime] = None
+    participants: Optional[List[EncounterParticipant]] = None
+    psychotherapy_notes: Optional[str] = Field(None, alias="psychotherapyNotes", json_schema_extra=ENCRYPTED)
+    model_config = ConfigDict(populate_by_name=True)
+
+class EncounterStatusUpdate(BaseModel):
+    status: EncounterStatus
+    model_config = ConfigDict(populate_by_name=True)
+
+class Encounter(EncounterBase):
+    encounter_id: str = Field(..., alias="encounterId")
+    status: EncounterStatus
+    observation_ids: List[str] = Field([], alias="observationIds")
+    documents: List[EncounterDocumentLink] = []
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Patient Document Schemas ---
+PatientDocumentType = Literal["discharge-summary", "referral-letter", "lab-report", "imaging-report", "consent-form", "other"]
+PatientDocumentContentType = Literal["application/pdf", "image/jpeg", "image/png", "image/tiff", "text/plain"]
+# pending: awaiting the upload; pending-scan: uploaded, awaiting the malware
+# scan; quarantined: the scan found malware. Only available documents can be
+# downloaded.
+PatientDocumentStatus = Literal["pending", "pending-scan", "available", "quarantined"]
+MD5_BASE64_PATTERN = r"^[A-Za-z0-9+/]{22}==$"
+
+class PatientDocumentCreate(BaseModel):
+    type: PatientDocumentType
+    title: str = Field(..., min_length=1, max_length=200)
+    content_type: PatientDocumentContentType = Field(..., alias="contentType")
+    size: int = Field(..., gt=0, description="Size of the file in bytes.")
+    checksum: str = Field(..., pattern=MD5_BASE64_PATTERN, description="Base64 MD5 of the file, sent again as the upload's Content-MD5 header.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class PatientDocument(PatientDocumentCreate):
+    document_id: str = Field(..., alias="documentId")
+    patient_id: str = Field(..., alias="patientId")
+    blob_name: str = Field(..., alias="blobName")
+    status: PatientDocumentStatus = "pending"
+    uploaded_by: str = Field(..., alias="uploadedBy")
+    uploaded_at: Optional[datetime] = Field(None, alias="uploadedAt", description="When the upload was confirmed.")
+    scanned_at: Optional[datetime] = Field(None, alias="scannedAt", description="When the file was scanned for malware, if it was.")
+    threat: Optional[str] = Field(None, description="What the malware scan found in a quarantined file.")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class PatientDocumentUpload(BaseModel):
+    """Where to PUT the file, with the headers the signed URL was issued for."""
+    document: PatientDocument
+    upload_url: str = Field(..., alias="uploadUrl")
+    upload_headers: Dict[str, str] = Field(..., alias="uploadHeaders")
+    expires_at: datetime = Field(..., alias="expiresAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+class PatientDocumentDownload(BaseModel):
+    url: str
+    expires_at: datetime = Field(..., alias="expiresAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Wound Image Schemas ---
+WOUND_ID_PATTERN = r"^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$"
+
+class WoundImageCreate(BaseModel):
+    wound_id: str = Field(..., alias="woundId", pattern=WOUND_ID_PATTERN, description="Identifies the wound across photos, e.g. 'left-heel-ulcer'; its photos form a series.")
+    body_site: Optional[str] = Field(None, alias="bodySite", max_length=200, description="Where the wound is, e.g. 'left heel'.")
+    taken_at: datetime = Field(..., alias="takenAt")
+    length_mm: Optional[float] = Field(None, alias="lengthMm", gt=0, description="The wound's measured length, if it was measured.")
+    width_mm: Optional[float] = Field(None, alias="widthMm", gt=0)
+    note: Optional[str] = Field(None, max_length=2000)
+    model_config = ConfigDict(populate_by_name=True)
+
+class WoundImageFiles(BaseModel):
+    """The stored photo (without its metadata) and thumbnail."""
+    content_type: Literal["image/jpeg", "image/png"] = Field(..., alias="contentType")
+    width: int
+    height: int
+    size: int = Field(..., description="Size of the stored photo in bytes.")
+    original_blob_name: str = Field(..., alias="originalBlobName")
+    thumbnail_blob_name: str = Field(..., alias="thumbnailBlobName")
+    encrypted: bool = Field(False, description="Whether the stored photo is encrypted with FIELD_ENCRYPTION_KEY.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class WoundImage(WoundImageCreate, WoundImageFiles):
+    image_id: str = Field(..., alias="imageId")
+    patient_id: str = Field(..., alias="patientId")
+    uploaded_by: str = Field(..., alias="uploadedBy")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class WoundImageView(WoundImage):
+    thumbnail_url: str = Field(..., alias="thumbnailUrl")
+
+class WoundImageSeries(BaseModel):
+    """A wound's photos, oldest first, to compare its healing over time."""
+    patient_id: str = Field(..., alias="patientId")
+    wound_id: str = Field(..., alias="woundId")
+    body_site: Optional[str] = Field(None, alias="bodySite", description="As given with the latest photo.")
+    images: List[WoundImageView]
+    first_taken_at: datetime = Field(..., alias="firstTakenAt")
+    last_taken_at: datetime = Field(..., alias="lastTakenAt")
+    area_change_percent: Optional[float] = Field(
+        None, alias="areaChangePercent",
+        description="Change of length x width from the first to the latest measured photo; negative while the wound shrinks.",
+    )
+    thumbnail_urls_expire_at: datetime = Field(..., alias="thumbnailUrlsExpireAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Lab Result Schemas ---
+LabResultStatus = Literal["preliminary", "final", "corrected", "cancelled"]
+# HL7 table 0078 interpretation codes: normal, low, high, critically low/high, abnormal (non-numeric).
+AbnormalFlag = Literal["N", "L", "H", "LL", "HH", "A"]
+LOINC_CODE_PATTERN = r"^\d{1,7}-\d$"
+
+class ReferenceRange(BaseModel):
+    low: Optional[float] = None
+    high: Optional[float] = None
+    critical_low: Optional[float] = Field(None, alias="criticalLow")
+    critical_high: Optional[float] = Field(None, alias="criticalHigh")
+    text: Optional[str] = Field(None, description="Range as printed by the lab, e.g. '<200 mg/dL' or 'negative'.")
+    model_config = ConfigDict(populate_by_name=True)
+
+    @model_validator(mode="after")
+    def validate_bounds(self):
+        if self.low is not None and self.high is not None and self.low > self.high:
+            raise ValueError("low must not exceed high")
+        if self.critical_low is not None and self.low is not None and self.critical_low > self.low:
+            raise ValueError("criticalLow must not exceed low")
+        if self.critical_high is not None and self.high is not None and self.critical_high < self.high:
+            raise ValueError("criticalHigh must not be below high")
+        return self
+
+class LabAnalyte(BaseModel):
+    code: str = Field(..., pattern=LOINC_CODE_PATTERN, description="LOINC code of the analyte, e.g. '2345-7' (glucose).")
+    display: Optional[str] = None
+    value: Optional[float] = None
+    value_string: Optional[str] = Field(None, alias="valueString", description="Result for non-numeric tests, e.g. 'positive'.")
+    unit: Optional[str] = Field(None, description="UCUM unit of `value`.")
+    reference_range: Optional[ReferenceRange] = Field(None, alias="referenceRange")
+    flag: Optional[AbnormalFlag] = Field(None, description="Computed from the reference range when not sent by the lab.")
+    note: Optional[str] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+    @model_validator(mode="after")
+    def validate_value(self):
+        if self.value is None and self.value_string is None:
+            raise ValueError("an analyte requires a value or valueString")
+        if self.value is not None and self.value_string is not None:
+            raise ValueError("send either value or valueString, not both")
+        return self
+
+class LabResultCreate(BaseModel):
+    panel_code: Optional[str] = Field(None, alias="panelCode", pattern=LOINC_CODE_PATTERN, description="LOINC panel code, e.g. '24323-8' (metabolic panel); omit for a single test.")
+    panel_display: Optional[str] = Field(None, alias="panelDisplay")
+    status: LabResultStatus = "final"
+    effective_at: datetime = Field(..., alias="effectiveAt", description="Specimen collection time; must include a timezone.")
+    issued_at: Optional[datetime] = Field(None, alias="issuedAt", description="When the lab released the result.")
+    performer: Optional[str] = Field(None, description="Name of the performing laboratory.")
+    ordered_by: Optional[str] = Field(None, alias="orderedBy", description="Ordering practitioner ID.")
+    encounter_id: Optional[str] = Field(None, alias="encounterId")
+    analytes: List[LabAnalyte] = Field(..., min_length=1)
+    model_config = ConfigDict(populate_by_name=True)
+
+    @model_validator(mode="after")
+    def validate_panel(self):
+        if self.panel_code is None and len(self.analytes) > 1:
+            raise ValueError("results with several analytes require a panelCode")
+        codes = [a.code for a in self.analytes]
+        if len(set(codes)) != len(codes):
+            raise ValueError("each analyte code may only appear once per result")
+        return self
+
+class LabResult(LabResultCreate):
+    lab_result_id: str = Field(..., alias="labResultId")
+    patient_id: str = Field(..., alias="patientId")
+    abnormal: bool = Field(False, description="True if any analyte is flagged other than 'N'.")
+    recorded_by: str = Field(..., alias="recordedBy")
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class LabTrendPoint(BaseModel):
+    lab_result_id: str = Field(..., alias="labResultId")
+    effective_at: datetime = Field(..., alias="effectiveAt")
+    value: Optional[float] = None
+    value_string: Optional[str] = Field(None, alias="valueString")
+    unit: Optional[str] = None
+    flag: Optional[AbnormalFlag] = None
+    reference_range: Optional[ReferenceRange] = Field(None, alias="referenceRange")
+    model_config = ConfigDict(populate_by_name=True)
+
+class LabTrend(BaseModel):
+    code: str
+    points: List[LabTrendPoint] = Field(..., description="Oldest first.")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Consent Schemas ---
+ConsentScope = Literal["data-sharing", "research", "marketing"]
+ConsentStatus = Literal["active", "revoked", "superseded"]
+
+class ConsentDocumentCreate(BaseModel):
+    scope: ConsentScope
+    title: str
+    body: str = Field(..., description="The consent text shown to the patient.")
+    language: str = Field("en", description="BCP 47 language tag, e.g. 'th' or 'en'.")
+    material_change: bool = Field(False, alias="materialChange", description="If true, consents given to earlier versions are no longer valid.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class ConsentDocument(ConsentDocumentCreate):
+    document_id: str = Field(..., alias="documentId")
+    version: int = Field(..., ge=1, description="Assigned sequentially per scope.")
+    published_by: str = Field(..., alias="publishedBy")
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class ConsentGrant(BaseModel):
+    scope: ConsentScope
+    document_id: str = Field(..., alias="documentId", description="The consent document the patient agreed to; must be the latest version for the scope.")
+    organization_id: Optional[str] = Field(None, alias="organizationId", description="Recipient organisation; required for 'data-sharing'.")
+    expires_at: Optional[FutureInstant] = Field(None, alias="expiresAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+    @model_validator(mode="after")
+    def validate_organization(self):
+        if self.scope == "data-sharing" and not self.organization_id:
+            raise ValueError("organizationId is required for data-sharing consent")
+        if self.scope != "data-sharing" and self.organization_id:
+            raise ValueError("organizationId only applies to data-sharing consent")
+        return self
+
+class ConsentRevoke(BaseModel):
+    reason: Optional[str] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class Consent(BaseModel):
+    consent_id: str = Field(..., alias="consentId")
+    patient_id: str = Field(..., alias="patientId")
+    scope: ConsentScope
+    organization_id: Optional[str] = Field(None, alias="organizationId")
+    document_id: str = Field(..., alias="documentId")
+    document_version: int = Field(..., alias="documentVersion")
+    status: ConsentStatus
+    granted_at: datetime = Field(..., alias="grantedAt")
+    granted_by: str = Field(..., alias="grantedBy")
+    expires_at: Optional[datetime] = Field(None, alias="expiresAt")
+    revoked_at: Optional[datetime] = Field(None, alias="revokedAt")
+    revoked_by: Optional[str] = Field(None, alias="revokedBy")
+    revocation_reason: Optional[str] = Field(None, alias="revocationReason")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class ConsentDecision(BaseModel):
+    allowed: bool
+    reason: str = Field(..., description="Why access is allowed or denied, e.g. 'no-consent' or 'reconsent-required'.")
+    consent_id: Optional[str] = Field(None, alias="consentId")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Audit Event Schemas ---
+AuditAction = Literal["read", "create", "update", "delete"]
+AuditOutcome = Literal["success", "failure"]
+# Sign-in attempts and account changes worth a security team's attention
+# (see app/auth/lockout.py and app/api/v1/endpoints/users.py).
+SecurityEvent = Literal[
+    "sign-in-failed", "sign-in-delayed", "locked-out", "lockout-refused", "unlocked",
+    "user-invited", "roles-changed", "user-deactivated", "user-reactivated", "credentials-reset",
+    "cross-tenant-access",
+]
+
+class AuditEventCreate(BaseModel):
+    occurred_at: datetime = Field(..., alias="occurredAt")
+    action: AuditAction
+    outcome: AuditOutcome
+    status_code: int = Field(..., alias="statusCode")
+    method: str
+    route: str = Field(..., description="Route template, e.g. '/api/v1/patients/{patientId}'.")
+    path: str = Field(..., description="Request path without the query string.")
+    actor_uid: Optional[str] = Field(None, alias="actorUid", description="Unset when the request was not authenticated.")
+    actor_roles: List[str] = Field(default_factory=list, alias="actorRoles")
+    resource_type: Optional[str] = Field(None, alias="resourceType")
+    resource_id: Optional[str] = Field(None, alias="resourceId")
+    patient_id: Optional[str] = Field(None, alias="patientId")
+    purpose_of_use: str = Field(..., alias="purposeOfUse", description="HL7 PurposeOfUse code, e.g. 'TREAT', or 'UNSPECIFIED'.")
+    source_ip: Optional[str] = Field(None, alias="sourceIp")
+    user_agent: Optional[str] = Field(None, alias="userAgent")
+    request_id: Optional[str] = Field(None, alias="requestId")
+    trace_id: Optional[str] = Field(None, alias="traceId")
+    security_event: Optional[SecurityEvent] = Field(None, alias="securityEvent", description="Set on failed and refused sign-in attempts, lockouts and unlocks, changes to staff accounts, and platform administrators' requests acting for another organization.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class AuditEvent(AuditEventCreate):
+    event_id: str = Field(..., alias="eventId")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# An export writes the events matching its filters to a CSV file in
+# AUDIT_EXPORT_BUCKET (see app/audit/export.py).
+AuditExportStatus = Literal["accepted", "in-progress", "completed", "error"]
+
+class AuditExportCreate(BaseModel):
+    actor_uid: Optional[str] = Field(None, alias="actorUid", description="Only events by this user.")
+    patient_id: Optional[str] = Field(None, alias="patientId", description="Only events concerning this patient's records, e.g. to answer who viewed a chart.")
+    resource_type: Optional[str] = Field(None, alias="resourceType")
+    occurred_from: Optional[datetime] = Field(None, alias="from", description="Only events at or after this time.")
+    occurred_to: Optional[datetime] = Field(None, alias="to", description="Only events at or before this time.")
+    model_config = ConfigDict(populate_by_name=True)
+
+    @model_validator(mode="after")
+    def _check_range(self):
+        if self.occurred_from and self.occurred_to and self.occurred_from > self.occurred_to:
+            raise ValueError("'from' must not be after 'to'")
+        return self
+
+class AuditExport(AuditExportCreate):
+    export_id: str = Field(..., alias="exportId")
+    status: AuditExportStatus = "accepted"
+    requested_by: str = Field(..., alias="requestedBy")
+    blob_name: Optional[str] = Field(None, alias="blobName")
+    row_count: Optional[int] = Field(None, alias="rowCount", description="Events written, once completed.")
+    error: Optional[str] = None
+    completed_at: Optional[datetime] = Field(None, alias="completedAt")
+    download_url: Optional[str] = Field(None, alias="downloadUrl", description="Signed link to the CSV once completed, valid for AUDIT_EXPORT_URL_TTL_SECONDS; not stored.")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Event Outbox Schemas ---
+OutboxStatus = Literal["pending", "published", "failed"]
+
+class OutboxEntry(BaseModel):
+    """A domain event waiting in the outbox, or already relayed to Pub/Sub."""
+    event_id: str = Field(..., alias="eventId")
+    event: Dict[str, Any] = Field(..., description="The event envelope as published.")
+    status: OutboxStatus = "pending"
+    attempts: int = 0
+    next_attempt_at: datetime = Field(..., alias="nextAttemptAt", description="When the relay may next try, or hold a lease on, this entry.")
+    last_error: Optional[str] = Field(None, alias="lastError")
+    published_at: Optional[datetime] = Field(None, alias="publishedAt")
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Event Stream Schemas ---
+class StreamEvent(BaseModel):
+    """
+    A domain event as sent on the event stream: what happened to which
+    resource, without the resource itself, which clients read if they need
+    it. `resource` and `patientId` decide who receives it.
+    """
+    event_id: str = Field(..., alias="eventId")
+    type: str
+    subject: str = Field(..., description="The resource path relative to /api/v1, e.g. appointments/{appointmentId}.")
+    resource: str = Field(..., description="The first segment of `subject`, whose read permission the event needs.")
+    patient_id: Optional[str] = Field(None, alias="patientId", description="The patient the resource belongs to, if any.")
+    occurred_at: datetime = Field(..., alias="occurredAt")
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Webhook Schemas ---
+WebhookDeliveryStatus = Literal["pending", "succeeded", "failed"]
+
+def _webhook_url_error(url: str) -> Optional[str]:
+    """
+    Callbacks must be public HTTPS endpoints. Loopback, private and
+    link-local addresses (e.g. the metadata server) are refused so a
+    subscription cannot make the API call into its own network.
+    """
+    parts = urlsplit(url)
+    if parts.scheme != "https" or not parts.hostname:
+        return "url must be an absolute https:// URL"
+    host = parts.hostname.lower()
+    if host == "localhost" or host.endswith((".localhost", ".internal", ".local")):
+        return "url must not point at an internal host"
+    try:
+        address = ipaddress.ip_address(host)
+    except ValueError:
+        return None
+    if not address.is_global:
+        return "url must not point at a private or reserved address"
+    return None
+
+class WebhookSubscriptionCreate(BaseModel):
+    url: str = Field(..., description="HTTPS endpoint that receives a POST per event.")
+    event_types: List[EventType] = Field(..., alias="eventTypes", min_length=1)
+    description: Optional[str] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+    @field_validator("url")
+    @classmethod
+    def validate_url(cls, value: str) -> str:
+        error = _webhook_url_error(value)
+        if error:
+            raise ValueError(error)
+        return value
+
+class WebhookSubscriptionUpdate(BaseModel):
+    url: Optional[str] = None
+    event_types: Optional[List[EventType]] = Field(None, alias="eventTypes", min_length=1)
+    description: Optional[str] = None
+    active: Optional[bool] = Field(None, description="Paused subscriptions receive no new deliveries.")
+    model_config = ConfigDict(populate_by_name=True)
+
+    @field_validator("url")
+    @classmethod
+    def validate_url(cls, value: Optional[str]) -> Optional[str]:
+        error = _webhook_url_error(value) if value is not None else None
+        if error:
+            raise ValueError(error)
+        return value
+
+class WebhookSubscription(BaseModel):
+    subscription_id: str = Field(..., alias="subscriptionId")
+    url: str
+    event_types: List[EventType] = Field(..., alias="eventTypes")
+    description: Optional[str] = None
+    active: bool = True
+    created_by: str = Field(..., alias="createdBy")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class WebhookSubscriptionWithSecret(WebhookSubscription):
+    """Returned only when a subscription is created or its secret rotated."""
+    secret: str = Field(..., description="Key for verifying the X-MegaCare-Signature header. Store it securely; it is not shown again.")
+
+class WebhookDelivery(BaseModel):
+    delivery_id: str = Field(..., alias="deliveryId")
+    subscription_id: str = Field(..., alias="subscriptionId")
+    event_id: str = Field(..., alias="eventId")
+    event_type: EventType = Field(..., alias="eventType")
+    event: Dict[str, Any] = Field(..., description="The event envelope, sent as the request body.")
+    status: WebhookDeliveryStatus = "pending"
+    attempts: int = 0
+    next_attempt_at: datetime = Field(..., alias="nextAttemptAt")
+    response_status: Optional[int] = Field(None, alias="responseStatus", description="HTTP status of the last attempt, if a response was received.")
+    last_error: Optional[str] = Field(None, alias="lastError")
+    delivered_at: Optional[datetime] = Field(None, alias="deliveredAt")
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Scheduled Job Schemas ---
+JobTrigger = Literal["cron", "ticker"]
+JobRunStatus = Literal["running", "succeeded", "failed", "skipped"]
+
+class JobLock(BaseModel):
+    """Guards a scheduled job so that only one worker or instance runs it at a time."""
+    job: str
+    locked_by: Optional[str] = Field(None, alias="lockedBy")
+    locked_until: Optional[datetime] = Field(None, alias="lockedUntil", description="The lease expires here even if the holder never releases it.")
+    last_started_at: Optional[datetime] = Field(None, alias="lastStartedAt")
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+class JobRun(BaseModel):
+    """One run of a scheduled job, kept as its run history."""
+    run_id: str = Field(..., alias="runId")
+    job: str
+    trigger: JobTrigger
+    status: JobRunStatus = "running"
+    owner: str = Field(..., description="Instance and process that ran the job.")
+    started_at: datetime = Field(..., alias="startedAt")
+    finished_at: Optional[datetime] = Field(None, alias="finishedAt")
+    result: Dict[str, Any] = Field(default_factory=dict, description="Counts reported by the job, e.g. reminders sent.")
+    error: Optional[str] = None
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Notification Schemas ---
+# Transactional messages to patients (see app/notifications). The message is
+# rendered from `template` and `context` when it is sent, and its delivery
+# is then tracked from the provider's event webhook.
+NotificationTemplate = Literal["appointment-confirmation", "appointment-reminder", "result-ready", "abnormal-reading"]
+NotificationChannel = Literal["email", "sms", "push"]
+NotificationStatus = Literal["queued", "throttled", "sent", "deferred", "delivered", "bounced", "dropped", "complained", "failed"]
+
+class EmailSenderIdentity(BaseModel):
+    """Who an organization's emails come from. With SendGrid the address must be a verified sender or on an authenticated domain."""
+    address: EmailAddress
+    name: Optional[str] = Field(None, max_length=100)
+    reply_to: Optional[EmailAddress] = Field(None, alias="replyTo")
+    model_config = ConfigDict(populate_by_name=True)
+
+class NotificationCreate(BaseModel):
+    channel: NotificationChannel = "email"
+    template: NotificationTemplate
+    recipient: str = Field(..., description="Email address or E.164 phone number the message is sent to; for push, the patient's registered devices.")
+    patient_id: Optional[str] = Field(None, alias="patientId")
+    about: Optional[str] = Field(None, description="The record the message is about, e.g. 'appointments/<id>'.")
+    context: Dict[str, Any] = Field(default_factory=dict, description="Values the template is filled with.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class DeliveryEvent(BaseModel):
+    status: NotificationStatus
+    at: datetime
+    reason: Optional[str] = Field(None, description="The provider's explanation, e.g. the bounce reply of the receiving server.")
+    provider_event_id: Optional[str] = Field(None, alias="providerEventId")
+    model_config = ConfigDict(populate_by_name=True)
+
+class Notification(NotificationCreate):
+    notification_id: str = Field(..., alias="notificationId")
+    status: NotificationStatus = "queued"
+    provider: Optional[str] = Field(None, description="'sendgrid', 'smtp', 'twilio' or 'fcm', once sent.")
+    sender: Optional[str] = Field(None, description="Address, number or messaging service the message was sent from.")
+    subject: Optional[str] = Field(None, description="Of emails, or the title of push notifications; texts have none.")
+    provider_message_id: Optional[str] = Field(None, alias="providerMessageId")
+    sent_at: Optional[datetime] = Field(None, alias="sentAt")
+    error: Optional[str] = Field(None, description="Why the provider refused the message, if it did.")
+    delivery_events: List[DeliveryEvent] = Field(default_factory=list, alias="deliveryEvents", description="As reported by the provider, in the order received.")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Push Token Schemas ---
+# Firebase Cloud Messaging registration tokens of the patient app, one per
+# installation. A token is registered again whenever the app starts, and to
+# the patient now signed in on the device if it changed hands.
+PushPlatform = Literal["ios", "android", "web"]
+
+class PushTokenCreate(BaseModel):
+    token: str = Field(..., min_length=20, max_length=4096, description="The FCM registration token of the app installation.")
+    platform: PushPlatform
+    app_version: Optional[str] = Field(None, alias="appVersion", max_length=50)
+    model_config = ConfigDict(populate_by_name=True)
+
+class PushToken(BaseModel):
+    push_token_id: str = Field(..., alias="pushTokenId", description="Derived from the token, so registering it again updates this record.")
+    patient_id: str = Field(..., alias="patientId")
+    # Anyone holding a token and the project's credentials can push to the
+    # device, so it is never returned once registered.
+    token: str = Field(..., exclude=True)
+    platform: PushPlatform
+    app_version: Optional[str] = Field(None, alias="appVersion")
+    registered_at: datetime = Field(..., alias="registeredAt", description="When the app last registered the token.")
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Telehealth Schemas ---
+# Video visits held for appointments (see app/telehealth). A session is a
+# room at TELEHEALTH_PROVIDER that the patient and clinician join with
+# short-lived tokens from the API. The provider reports who joined and left,
+# and those reports are the session's events: it is `active` from when the
+# patient and a clinician were first both in the room, and its duration,
+# which is billed, runs from then until the session ended.
+TelehealthProvider = Literal["twilio", "daily", "livekit"]
+TelehealthSessionStatus = Literal["waiting", "active", "ended"]
+TelehealthEventType = Literal["token_issued", "participant_joined", "participant_left", "ended"]
+ParticipantRole = Literal["patient", "staff"]
+
+class TelehealthSessionCreate(BaseModel):
+    appointment_id: str = Field(..., alias="appointmentId", description="A booked appointment, or one the patient has arrived for.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class TelehealthSessionEvent(BaseModel):
+    type: TelehealthEventType
+    at: datetime
+    identity: Optional[str] = Field(None, description="The participant, as named in their join token: 'patient-<patientId>' or 'staff-<uid>'.")
+    role: Optional[ParticipantRole] = None
+    provider_event_id: Optional[str] = Field(None, alias="providerEventId")
+    model_config = ConfigDict(populate_by_name=True)
+
+class TelehealthSession(BaseModel):
+    telehealth_session_id: str = Field(..., alias="telehealthSessionId")
+    appointment_id: str = Field(..., alias="appointmentId")
+    patient_id: str = Field(..., alias="patientId")
+    practitioner_id: str = Field(..., alias="practitionerId")
+    provider: TelehealthProvider
+    room_name: str = Field(..., alias="roomName")
+    room_url: Optional[str] = Field(None, alias="roomUrl", description="Where clients join the room; Daily rooms have their own, LiveKit's is the server.")
+    status: TelehealthSessionStatus = "waiting"
+    started_at: Optional[datetime] = Field(None, alias="startedAt", description="When the patient and a clinician were first both in the room.")
+    ended_at: Optional[datetime] = Field(None, alias="endedAt")
+    duration_seconds: Optional[int] = Field(None, alias="durationSeconds", description="From startedAt to endedAt; none if the visit never started.")
+    ended_by: Optional[str] = Field(None, alias="endedBy", description="The user who ended it, or 'provider' when the room closed.")
+    events: List[TelehealthSessionEvent] = Field(default_factory=list, description="In the order they happened.")
+    tenant_id: Optional[str] = Field(None, alias="tenantId")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class TelehealthJoinToken(BaseModel):
+    token: str = Field(..., description="Give it to the provider's client SDK to join the room.")
+    provider: TelehealthProvider
+    room_name: str = Field(..., alias="roomName")
+    room_url: Optional[str] = Field(None, alias="roomUrl")
+    identity: str
+    role: ParticipantRole
+    expires_at: datetime = Field(..., alias="expiresAt", description="The token can no longer be used to join after this; a participant already in the room stays.")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Operational Report Schemas ---
+# Aggregates for running a clinic (see app/services/reports.py), over the
+# days from `period_start` to `period_end` inclusive, counted in `timezone`.
+# Rows are flat, so each report reads the same as CSV; each is for one
+# organization (None with tenancy off).
+class ReportBase(BaseModel):
+    period_start: date = Field(..., alias="periodStart")
+    period_end: date = Field(..., alias="periodEnd")
+    timezone: str
+    generated_at: datetime = Field(..., alias="generatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+class AppointmentVolumeRow(BaseModel):
+    organization_id: Optional[str] = Field(None, alias="organizationId")
+    day: date
+    total: int = 0
+    booked: int = 0
+    arrived: int = 0
+    fulfilled: int = 0
+    cancelled: int = 0
+    model_config = ConfigDict(populate_by_name=True)
+
+class AppointmentVolumeReport(ReportBase):
+    rows: List[AppointmentVolumeRow]
+
+class NoShowRateRow(BaseModel):
+    organization_id: Optional[str] = Field(None, alias="organizationId")
+    practitioner_id: Optional[str] = Field(None, alias="practitionerId", description="Set when grouped by practitioner.")
+    appointments: int = Field(..., description="Appointments that have ended and were not cancelled.")
+    no_shows: int = Field(..., alias="noShows", description="Of those, the ones still booked: the patient never arrived.")
+    no_show_rate: Optional[float] = Field(None, alias="noShowRate", description="no_shows / appointments; None without appointments.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class NoShowRateReport(ReportBase):
+    rows: List[NoShowRateRow]
+
+class TelemetryAdherenceRow(BaseModel):
+    organization_id: Optional[str] = Field(None, alias="organizationId")
+    code: Optional[str] = Field(None, description="The LOINC code the report was limited to, if any.")
+    patients: int = Field(..., description="Patients with at least one reading in the period.")
+    adherent_patients: int = Field(..., alias="adherentPatients", description="Of those, the ones with readings on at least `threshold` of the days.")
+    average_adherence: Optional[float] = Field(None, alias="averageAdherence", description="Mean share of the period's days on which a patient sent a reading.")
+    threshold: float
+    model_config = ConfigDict(populate_by_name=True)
+
+class TelemetryAdherenceReport(ReportBase):
+    rows: List[TelemetryAdherenceRow]
+
+class OpenRefillRequestRow(BaseModel):
+    organization_id: Optional[str] = Field(None, alias="organizationId")
+    status: RefillRequestStatus
+    count: int
+    oldest_requested_at: Optional[datetime] = Field(None, alias="oldestRequestedAt")
+    average_age_hours: Optional[float] = Field(None, alias="averageAgeHours")
+    model_config = ConfigDict(populate_by_name=True)
+
+class OpenRefillRequestReport(ReportBase):
+    rows: List[OpenRefillRequestRow]
+
+# --- Full-Text Search Schemas ---
+# Matches of /search (see app/search/index.py). Highlights are HTML-escaped
+# fragments of the matched fields with the matched words in <mark> tags.
+SearchKind = Literal["encounter", "care-plan", "allergy", "patient-document"]
+
+class SearchHit(BaseModel):
+    kind: SearchKind
+    id: str = Field(..., description="The ID of the record matched.")
+    patient_id: str = Field(..., alias="patientId")
+    subject: str = Field(..., description="The record's path relative to /api/v1, e.g. 'encounters/4f2a...'.")
+    title: str
+    recorded_at: datetime = Field(..., alias="recordedAt", description="When the visit started or the record was created.")
+    score: float = Field(..., description="Relevance; higher is better. Comparable only within one response.")
+    highlights: Dict[str, List[str]] = Field({}, description="Fragments of the matched fields ('title', 'text'), by field.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class SearchResults(BaseModel):
+    total: int = Field(..., description="Records matching, across every page.")
+    offset: int
+    hits: List[SearchHit]
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Organization Schemas ---
+# Organizations are the clinics sharing the deployment. Their ID is chosen
+# when one is registered and is what users' tokens carry as `organizationId`.
+ORGANIZATION_ID_PATTERN = r"^[a-z0-9][a-z0-9-]{1,62}$"
+
+def _source_ranges(values: Optional[List[str]]) -> Optional[List[str]]:
+    """Normalizes CIDR ranges, e.g. 203.0.113.7 -> 203.0.113.7/32."""
+    if values is None:
+        return None
+    ranges = []
+    for value in values:
+        try:
+            ranges.append(str(ipaddress.ip_network(value.strip())))
+        except ValueError:
+            raise ValueError(f"'{value}' is not a CIDR range such as 203.0.113.0/24")
+    return ranges
+
+class BillingAddress(BaseModel):
+    line: str = Field(..., min_length=1, max_length=55, description="A street address; payers refuse P.O. boxes for billing providers.")
+    city: str = Field(..., min_length=1, max_length=30)
+    state: str = Field(..., pattern=r"^[A-Z]{2}$", description="Two-letter state code.")
+    postal_code: str = Field(..., alias="postalCode", pattern=r"^[0-9]{9}$", description="ZIP+4 without the hyphen; claims need all nine digits.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class BillingProvider(BaseModel):
+    """Who the organization checks coverage and bills as, with payers."""
+    npi: NationalProviderIdentifier = Field(..., description="The organization's (type 2) NPI.")
+    name: str = Field(..., min_length=1, max_length=60, description="As registered with payers.")
+    tax_id: Optional[str] = Field(None, alias="taxId", pattern=r"^[0-9]{9}$", description="Employer identification number, nine digits. Required for claims.")
+    address: Optional[BillingAddress] = Field(None, description="Required for claims.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class OrganizationBase(BaseModel):
+    name: str = Field(..., min_length=1, max_length=200)
+    contact: Optional[ContactInfo] = None
+    billing_provider: Optional[BillingProvider] = Field(None, alias="billingProvider", description="BILLING_PROVIDER_NPI and BILLING_PROVIDER_NAME if unset.")
+    email_sender: Optional[EmailSenderIdentity] = Field(None, alias="emailSender", description="Sender of the organization's emails; EMAIL_FROM_ADDRESS if unset.")
+    allowed_source_ranges: List[str] = Field(default_factory=list, alias="allowedSourceRanges", max_length=50, description="CIDR ranges the organization's admin and integration requests must come from; IP_ALLOWLIST_DEFAULT_RANGES if empty.")
+    model_config = ConfigDict(populate_by_name=True)
+
+    @field_validator("allowed_source_ranges")
+    @classmethod
+    def validate_source_ranges(cls, value: List[str]) -> List[str]:
+        return _source_ranges(value)
+
+class OrganizationCreate(OrganizationBase):
+    organization_id: str = Field(..., alias="organizationId", pattern=ORGANIZATION_ID_PATTERN, description="Lowercase letters, digits and hyphens, e.g. 'chiang-mai-sleep-clinic'.")
+    contact: Optional[ContactInfoCreate] = None
+
+class OrganizationUpdate(BaseModel):
+    name: Optional[str] = Field(None, min_length=1, max_length=200)
+    contact: Optional[ContactInfoCreate] = None
+    email_sender: Optional[EmailSenderIdentity] = Field(None, alias="emailSender")
+    billing_provider: Optional[BillingProvider] = Field(None, alias="billingProvider")
+    allowed_source_ranges: Optional[List[str]] = Field(None, alias="allowedSourceRanges", max_length=50)
+    model_config = ConfigDict(populate_by_name=True)
+
+    @field_validator("allowed_source_ranges")
+    @classmethod
+    def validate_source_ranges(cls, value: Optional[List[str]]) -> Optional[List[str]]:
+        return _source_ranges(value)
+
+class Organization(OrganizationBase):
+    organization_id: str = Field(..., alias="organizationId")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Feature Flag Schemas ---
+# Flags switch code paths on at runtime (see app/flags/flags.py): off for
+# everyone while disabled, otherwise on for the listed organizations and
+# for `percentage` percent of the rest.
+FLAG_KEY_PATTERN = r"^[a-z0-9][a-z0-9-]{1,62}$"
+
+class FeatureFlagBase(BaseModel):
+    description: Optional[str] = Field(None, max_length=500)
+    enabled: bool = Field(False, description="When false the flag is off for everyone.")
+    percentage: int = Field(100, ge=0, le=100, description="Share of callers the flag is on for, by organization or, without one, by user.")
+    organizations: List[str] = Field(default_factory=list, description="Organizations the flag is on for whatever the percentage.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class FeatureFlagCreate(FeatureFlagBase):
+    key: str = Field(..., pattern=FLAG_KEY_PATTERN, description="Lowercase letters, digits and hyphens, e.g. 'telehealth-visits'.")
+
+class FeatureFlagUpdate(BaseModel):
+    description: Optional[str] = Field(None, max_length=500)
+    enabled: Optional[bool] = None
+    percentage: Optional[int] = Field(None, ge=0, le=100)
+    organizations: Optional[List[str]] = None
+    model_config = ConfigDict(populate_by_name=True)
+
+class FeatureFlag(FeatureFlagBase):
+    key: str
+    source: Literal["config", "store"] = Field("store", description="`config` for flags set with FEATURE_FLAGS and not overridden here.")
+    updated_by: Optional[str] = Field(None, alias="updatedBy")
+    version: RecordVersion = 0
+    created_at: Optional[datetime] = Field(None, alias="createdAt")
+    updated_at: Optional[datetime] = Field(None, alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- Data Retention Schemas ---
+# How long an organization keeps each category of data before the retention
+# job (app/retention) deletes it, or keeps it without what identifies the
+# patient and the people involved. Categories an organization does not set
+# follow RETENTION_DEFAULTS; without either, the data is kept.
+RetentionCategory = Literal["telemetry", "audit", "messages"]
+RetentionAction = Literal["delete", "anonymize"]
+PurgeStatus = Literal["completed", "failed"]
+
+class RetentionRule(BaseModel):
+    days: int = Field(..., ge=1, le=36500, description="Age after which data of the category expires.")
+    action: RetentionAction = Field("delete", description="`anonymize` keeps the data without patient, user and request identifiers.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class RetentionPolicyUpdate(BaseModel):
+    """The organization's own rules. A category set to null follows the deployment default again."""
+    telemetry: Optional[RetentionRule] = Field(None, description="Observations, by when they were taken.")
+    audit: Optional[RetentionRule] = Field(None, description="PHI access audit events, by when they occurred.")
+    messages: Optional[RetentionRule] = Field(None, description="Relayed domain events and finished webhook deliveries, by when they finished.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class RetentionPolicyRecord(RetentionPolicyUpdate):
+    """An organization's stored overrides, keyed by its ID."""
+    organization_id: str = Field(..., alias="organizationId")
+    updated_by: Optional[str] = Field(None, alias="updatedBy")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class EffectiveRetentionRule(RetentionRule):
+    source: Literal["config", "organization"] = Field(..., description="`config` for a rule from RETENTION_DEFAULTS.")
+
+class RetentionPolicy(BaseModel):
+    """The rules in effect for an organization; a null category is kept indefinitely."""
+    organization_id: Optional[str] = Field(None, alias="organizationId")
+    telemetry: Optional[EffectiveRetentionRule] = None
+    audit: Optional[EffectiveRetentionRule] = None
+    messages: Optional[EffectiveRetentionRule] = None
+    updated_by: Optional[str] = Field(None, alias="updatedBy")
+    version: RecordVersion = 0
+    updated_at: Optional[datetime] = Field(None, alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+class PurgeManifestCreate(BaseModel):
+    category: RetentionCategory
+    action: RetentionAction
+    retention_days: int = Field(..., alias="retentionDays")
+    cutoff: datetime = Field(..., description="Data older than this expired.")
+    since: Optional[datetime] = Field(None, description="For `anonymize`, the previous run's cutoff: older data was anonymized then.")
+    counts: Dict[str, int] = Field(default_factory=dict, description="Records deleted or anonymized, by collection.")
+    status: PurgeStatus = "completed"
+    error: Optional[str] = None
+    started_at: datetime = Field(..., alias="startedAt")
+    finished_at: datetime = Field(..., alias="finishedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+class PurgeManifest(PurgeManifestCreate):
+    """What one run of the retention job did to one category of an organization's data."""
+    manifest_id: str = Field(..., alias="manifestId")
+    tenant_id: Optional[str] = Field(None, alias="tenantId")
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+# --- API Key Schemas ---
+ApiKeyStatus = Literal["active", "revoked"]
+
+# `<resource>:read` allows GET requests under /api/v1/<resource>, and
+# `<resource>:write` every other method, e.g. "patients:read".
+API_KEY_SCOPE_PATTERN = r"^[a-z][a-z0-9-]*:(read|write)$"
+
+class ApiKeyCreate(BaseModel):
+    name: str = Field(..., min_length=1, max_length=100, description="Identifies the integrator, e.g. 'Acme Sleep Lab backend'.")
+    scopes: List[str] = Field(..., min_length=1)
+    rate_limit_per_minute: Optional[int] = Field(None, alias="rateLimitPerMinute", ge=1, le=100_000, description="Requests per minute; the default limit when unset.")
+    model_config = ConfigDict(populate_by_name=True)
+
+    @field_validator("scopes")
+    @classmethod
+    def validate_scopes(cls, value: List[str]) -> List[str]:
+        invalid = [scope for scope in value if not re.match(API_KEY_SCOPE_PATTERN, scope)]
+        if invalid:
+            raise ValueError(f"scopes must look like 'patients:read' or 'appointments:write': {', '.join(invalid)}")
+        return sorted(set(value))
+
+class ApiKey(BaseModel):
+    key_id: str = Field(..., alias="keyId")
+    name: str
+    scopes: List[str]
+    rate_limit_per_minute: Optional[int] = Field(None, alias="rateLimitPerMinute")
+    tenant_id: Optional[str] = Field(None, alias="tenantId", description="The organization the key acts for: its creator's.")
+    status: ApiKeyStatus = "active"
+    created_by: str = Field(..., alias="createdBy")
+    rotated_at: Optional[datetime] = Field(None, alias="rotatedAt")
+    revoked_at: Optional[datetime] = Field(None, alias="revokedAt")
+    revoked_by: Optional[str] = Field(None, alias="revokedBy")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class ApiKeyRecord(ApiKey):
+    """The stored form: only a hash of the key's secret part is kept."""
+    secret_hash: str = Field(..., alias="secretHash")
+
+class ApiKeyWithSecret(ApiKey):
+    """Returned only when a key is created or rotated."""
+    key: str = Field(..., description="Send as the X-Api-Key header. Store it securely; it is not shown again.")
+
+# --- Session Schemas ---
+SessionStatus = Literal["active", "revoked"]
+
+class SessionCreate(BaseModel):
+    id_token: str = Field(..., alias="idToken", description="A Firebase or OIDC ID token of the user signing in.")
+    device_label: Optional[str] = Field(None, alias="deviceLabel", max_length=100, description="Shown in the user's list of signed-in devices, e.g. 'Kitchen iPad'.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class SessionRefresh(BaseModel):
+    refresh_token: str = Field(..., alias="refreshToken")
+    model_config = ConfigDict(populate_by_name=True)
+
+class Session(BaseModel):
+    session_id: str = Field(..., alias="sessionId")
+    uid: str
+    device_label: Optional[str] = Field(None, alias="deviceLabel")
+    user_agent: Optional[str] = Field(None, alias="userAgent")
+    source_ip: Optional[str] = Field(None, alias="sourceIp", description="Where the session was started from.")
+    status: SessionStatus = "active"
+    authenticated_at: datetime = Field(..., alias="authenticatedAt", description="When the user signed in; refreshing keeps it.")
+    last_used_at: datetime = Field(..., alias="lastUsedAt", description="When the refresh token was last used.")
+    expires_at: datetime = Field(..., alias="expiresAt", description="When the refresh token stops working unless used before.")
+    revoked_at: Optional[datetime] = Field(None, alias="revokedAt")
+    revoked_reason: Optional[str] = Field(None, alias="revokedReason", description="e.g. 'logout', 'signed-out-elsewhere', 'credentials-changed', 'refresh-token-reused'.")
+    mfa_verified_at: Optional[datetime] = Field(None, alias="mfaVerifiedAt", description="When a second factor was last verified in this session.")
+    current: bool = Field(False, description="Whether this is the session of the request's access token.")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True, from_attributes=True)
+
+class SessionRecord(Session):
+    """The stored form: hashes of the current refresh token and the one it replaced, and the claims access tokens carry."""
+    refresh_hash: str = Field(..., alias="refreshHash")
+    previous_refresh_hash: Optional[str] = Field(None, alias="previousRefreshHash")
+    claims: Dict[str, Any] = Field(default_factory=dict)
+
+class SessionTokens(BaseModel):
+    access_token: str = Field(..., alias="accessToken", description="Send as `Authorization: Bearer <token>`.")
+    token_type: str = Field("Bearer", alias="tokenType")
+    expires_in: int = Field(..., alias="expiresIn", description="Seconds until the access token expires.")
+    refresh_token: str = Field(..., alias="refreshToken", description="Exchanges for new tokens once; each refresh returns a new one.")
+    session: Session
+    model_config = ConfigDict(populate_by_name=True)
+
+class SessionRevocation(BaseModel):
+    revoked: int = Field(..., description="How many sessions were signed out.")
+
+# --- Magic Link Schemas ---
+MagicLinkStatus = Literal["pending", "used"]
+
+class MagicLinkRequest(BaseModel):
+    email: EmailAddress
+
+class MagicLinkExchange(BaseModel):
+    token: str = Field(..., description="The `token` of the link's fragment.")
+    device_label: Optional[str] = Field(None, alias="deviceLabel", max_length=100, description="Shown in the user's list of signed-in devices.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class MagicLinkRecord(BaseModel):
+    """A sign-in link sent by email; the link carries a token signed for this record."""
+    link_id: str = Field(..., alias="linkId")
+    uid: str
+    email: str = Field(..., json_schema_extra=ENCRYPTED)
+    status: MagicLinkStatus = "pending"
+    expires_at: datetime = Field(..., alias="expiresAt")
+    requested_from: Optional[str] = Field(None, alias="requestedFrom", description="The address the link was requested from.")
+    used_at: Optional[datetime] = Field(None, alias="usedAt")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- MFA Schemas ---
+MfaMethod = Literal["totp", "sms", "recovery"]
+
+class MfaStatus(BaseModel):
+    totp: bool = Field(False, description="Whether an authenticator app is enrolled.")
+    sms: bool = Field(False, description="Whether a phone number is enrolled for text message codes.")
+    phone_number_hint: Optional[str] = Field(None, alias="phoneNumberHint", description="The last digits of the enrolled number, e.g. '•••• 1234'.")
+    recovery_codes_remaining: int = Field(0, alias="recoveryCodesRemaining")
+    required: bool = Field(False, description="Whether the caller's roles require a second factor.")
+    verified_at: Optional[datetime] = Field(None, alias="verifiedAt", description="When the caller's credentials last proved a second factor.")
+    recovery_codes: Optional[List[str]] = Field(None, alias="recoveryCodes", description="Only in the response confirming the first factor; shown once.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class MfaTotpEnrollment(BaseModel):
+    secret: str = Field(..., description="Base32 secret, for entering by hand.")
+    otpauth_uri: str = Field(..., alias="otpauthUri", description="`otpauth://` URI to show as a QR code.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class MfaPhoneEnrollment(BaseModel):
+    phone_number: PhoneNumber = Field(..., alias="phoneNumber")
+    model_config = ConfigDict(populate_by_name=True)
+
+class MfaCode(BaseModel):
+    code: str = Field(..., min_length=6, max_length=20)
+
+class MfaChallengeRequest(BaseModel):
+    method: Literal["sms"] = Field("sms", description="Authenticator app codes need no challenge.")
+
+class MfaVerify(BaseModel):
+    method: MfaMethod
+    code: str = Field(..., min_length=6, max_length=20, description="A 6-digit code, or a recovery code.")
+
+class MfaRecoveryCodes(BaseModel):
+    recovery_codes: List[str] = Field(..., alias="recoveryCodes", description="Shown once; each works once in place of a code.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class MfaVerification(BaseModel):
+    access_token: str = Field(..., alias="accessToken", description="Replaces the session's access token; it carries the verification.")
+    token_type: str = Field("Bearer", alias="tokenType")
+    expires_in: int = Field(..., alias="expiresIn")
+    verified_at: datetime = Field(..., alias="verifiedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+class MfaEnrollmentRecord(BaseModel):
+    """A user's second factors, keyed by uid. Codes are kept as hashes; the TOTP secret and phone number are sealed."""
+    uid: str
+    totp_secret: Optional[str] = Field(None, alias="totpSecret", json_schema_extra=ENCRYPTED)
+    totp_confirmed_at: Optional[datetime] = Field(None, alias="totpConfirmedAt")
+    last_totp_step: Optional[int] = Field(None, alias="lastTotpStep", description="The time step of the last accepted code; codes are accepted once.")
+    phone_number: Optional[str] = Field(None, alias="phoneNumber", json_schema_extra=ENCRYPTED)
+    phone_confirmed_at: Optional[datetime] = Field(None, alias="phoneConfirmedAt")
+    sms_code_hash: Optional[str] = Field(None, alias="smsCodeHash")
+    sms_code_expires_at: Optional[datetime] = Field(None, alias="smsCodeExpiresAt")
+    recovery_code_hashes: List[str] = Field(default_factory=list, alias="recoveryCodeHashes")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Lockout Schemas ---
+LockoutSubjectType = Literal["account", "address"]
+
+class Lockout(BaseModel):
+    """Failed sign-in attempts of an account or from an address, and the wait they earned."""
+    lockout_id: str = Field(..., alias="lockoutId", description="'<subjectType>:<subject>', e.g. 'account:u1' or 'address:203.0.113.7'.")
+    subject_type: LockoutSubjectType = Field(..., alias="subjectType")
+    subject: str = Field(..., description="The uid or source address.")
+    failures: int = Field(0, description="Failed attempts since `windowStartedAt`.")
+    window_started_at: Optional[datetime] = Field(None, alias="windowStartedAt")
+    last_failure_at: Optional[datetime] = Field(None, alias="lastFailureAt")
+    retry_at: Optional[datetime] = Field(None, alias="retryAt", description="No attempt is accepted before this time.")
+    locked_until: Optional[datetime] = Field(None, alias="lockedUntil", description="Set while locked out, rather than only delayed.")
+    lockouts: int = Field(0, description="How many times the subject has been locked out.")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Staff User Schemas ---
+# Roles are role names as they appear in the `roles` claim.
+RoleName = Annotated[str, Field(pattern=r"^[a-z][a-z0-9-]{0,63}$")]
+StaffUserStatus = Literal["invited", "active", "deactivated"]
+
+class StaffUserInvite(BaseModel):
+    email: EmailAddress
+    display_name: Optional[str] = Field(None, alias="displayName", max_length=200)
+    roles: List[RoleName] = Field(..., min_length=1, max_length=20)
+    model_config = ConfigDict(populate_by_name=True)
+
+class StaffRolesUpdate(BaseModel):
+    roles: List[RoleName] = Field(..., min_length=1, max_length=20, description="Replaces the user's roles.")
+
+class CredentialReset(BaseModel):
+    reset_mfa: bool = Field(False, alias="resetMfa", description="Also remove the user's second factors, e.g. when their phone was lost.")
+    model_config = ConfigDict(populate_by_name=True)
+
+class StaffUser(BaseModel):
+    """A staff member's account at the identity provider."""
+    uid: str
+    email: Optional[str] = None
+    display_name: Optional[str] = Field(None, alias="displayName")
+    roles: List[str] = Field(default_factory=list)
+    organization_id: Optional[str] = Field(None, alias="organizationId")
+    status: StaffUserStatus = Field(..., description="'invited' until the user first signs in.")
+    last_sign_in_at: Optional[datetime] = Field(None, alias="lastSignInAt")
+    created_at: Optional[datetime] = Field(None, alias="createdAt")
+    model_config = ConfigDict(populate_by_name=True)
+
+# --- Custom Role Schemas ---
+# '<resource>:<read|write>' as in API key scopes, or '<resource>.<subresource>:<read|write>'
+# for a kind of record under a resource, e.g. 'patients.lab-results:read'.
+PERMISSION_PATTERN = r"^[a-z][a-z0-9-]*(\.[a-z][a-z0-9-]*)?:(read|write)$"
+PermissionName = Annotated[str, Field(pattern=PERMISSION_PATTERN)]
+
+class CustomRoleCreate(BaseModel):
+    name: RoleName = Field(..., description="The role name to assign to users, e.g. 'lab-technician'.")
+    description: Optional[str] = Field(None, max_length=500)
+    permissions: List[PermissionName] = Field(..., max_length=200, description="What the role grants; 'patients:read' also covers 'patients.lab-results:read'.")
+    denied: List[PermissionName] = Field(default_factory=list, max_length=200, description="Taken away from holders of the role, whatever their other roles grant, e.g. 'patients.encounters:read'.")
+    model_config = ConfigDict(populate_by_name=True)
+
+    @field_validator("permissions", "denied")
+    @classmethod
+    def dedupe(cls, value: List[str]) -> List[str]:
+        return sorted(set(value))
+
+class CustomRoleUpdate(BaseModel):
+    description: Optional[str] = Field(None, max_length=500)
+    permissions: Optional[List[PermissionName]] = Field(None, max_length=200)
+    denied: Optional[List[PermissionName]] = Field(None, max_length=200)
+
+    @field_validator("permissions", "denied")
+    @classmethod
+    def dedupe(cls, value: Optional[List[str]]) -> Optional[List[str]]:
+        return sorted(set(value)) if value is not None else None
+
+class CustomRole(CustomRoleCreate):
+    """A role an organization defined, stored per organization under '<organizationId>:<name>'."""
+    role_id: str = Field(..., alias="roleId")
+    updated_by: str = Field(..., alias="updatedBy")
+    version: RecordVersion = 0
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+
+# --- Idempotency Schemas ---
+IdempotencyStatus = Literal["processing", "completed"]
+
+class IdempotencyRecord(BaseModel):
+    """The outcome of a request sent with an Idempotency-Key, replayed to its retries."""
+    record_id: str = Field(..., alias="recordId", description="Hash of the caller, route and key.")
+    principal: str = Field(..., description="Who sent the request, e.g. 'user:<uid>' or 'key:<key ID>'.")
+    route: str = Field(..., description="Method and route template, e.g. 'POST /api/v1/appointments'.")
+    key: str
+    fingerprint: str = Field(..., description="Hash of the request body; a retry must send the same body.")
+    status: IdempotencyStatus = "processing"
+    expires_at: datetime = Field(..., alias="expiresAt", description="While processing, when a retry may take over; once completed, when the response is forgotten.")
+    response_status: Optional[int] = Field(None, alias="responseStatus")
+    response_headers: Dict[str, str] = Field(default_factory=dict, alias="responseHeaders")
+    response_body: Optional[str] = Field(None, alias="responseBody", description="Base64 of the response body, which may hold PHI.", json_schema_extra=ENCRYPTED)
+    created_at: datetime = Field(..., alias="createdAt")
+    updated_at: datetime = Field(..., alias="updatedAt")
+    model_config = ConfigDict(populate_by_name=True)
@@ -0,0 +1,129 @@
+# Location: app/api/versioning.py
+
+import re
+from dataclasses import dataclass
+from datetime import date, datetime, time, timezone
+from email.utils import format_datetime
+from typing import Dict, List, Optional, Tuple
+
+from starlette.datastructures import Headers
+
+# --- API Versions ---
+# Each major version of the REST API is its own router, mounted in `app.main`
+# under /api/<version>. A payload change that would break integrators goes
+# into a new version (with its own router next to app/api/v1) instead of
+# changing the current one; the older version keeps being served,
+# deprecated, until its sunset date. Routes retired within a version are
+# listed in DEPRECATED_ROUTES.
+
+API_ROOT = "/api"
+VERSION_HEADER = "Api-Version"
+
+_VERSION_RE = re.compile(r"^v?(\d+)$")
+_VERSIONED_PATH_RE = re.compile(r"^/api/(v\d+)(?=/|$)")
+
+
+@dataclass(frozen=True)
+class ApiVersion:
+    name: str
+    deprecated_on: Optional[date] = None  # When the deprecation was announced.
+    sunset_on: Optional[date] = None  # When the version stops being served.
+
+    @property
+    def prefix(self) -> str:
+        return f"{API_ROOT}/{self.name}"
+
+
+@dataclass(frozen=True)
+class RouteDeprecation:
+    """A route that is retired within its version, e.g. replaced by another."""
+    method: str
+    route: str  # Route template, e.g. "/api/v1/patients/{patientId}/refills".
+    deprecated_on: date
+    sunset_on: Optional[date] = None
+    successor: Optional[str] = None  # Path of the route that replaces it, sent as a successor-version link.
+
+
+# Served versions, oldest first.
+API_VERSIONS: Dict[str, ApiVersion] = {
+    "v1": ApiVersion("v1"),
+}
+
+DEPRECATED_ROUTES: Tuple[RouteDeprecation, ...] = ()
+
+
+def version_prefixes() -> Tuple[str, ...]:
+    """The mount points of the served versions, e.g. ("/api/v1",)."""
+    return tuple(version.prefix for version in API_VERSIONS.values())
+
+
+def normalize_version(value: Optional[str]) -> Optional[str]:
+    """Reads a requested version such as "2" or "v2" as "v2"; None if it is not a version."""
+    match = _VERSION_RE.match((value or "").strip().lower())
+    return f"v{int(match.group(1))}" if match else None
+
+
+def path_version(path: str) -> Optional[str]:
+    """The version in a path, e.g. "v1" for /api/v1/patients; None for an unversioned path."""
+    match = _VERSIONED_PATH_RE.match(path)
+    return match.group(1) if match else None
+
+
+def strip_version(path: str) -> str:
+    """The path relative to its version's mount point, e.g. /patients for /api/v1/patients."""
+    version = path_version(path)
+    return path[len(API_ROOT) + 1 + len(version):] if version else path
+
+
+def requested_version(headers: Headers) -> Optional[str]:
+    """
+    The version a client asks for on an unversioned /api path: the
+    Api-Version header, or else a `version` parameter of the Accept media
+    type (e.g. `application/json; version=2`). Returns the raw value, or
+    None if the client does not say.
+    """
+    explicit = headers.get(VERSION_HEADER)
+    if explicit:
+        return explicit.strip()
+    for media_range in (headers.get("accept") or "").split(","):
+        for param in media_range.split(";")[1:]:
+            name, _, value = param.strip().partition("=")
+            if name.strip().lower() == "version" and value.strip():
+                return value.strip().strip('"')
+    return None
+
+
+def _http_date(day: date) -> str:
+    return format_datetime(datetime.combine(day, time.min, tzinfo=timezone.utc), usegmt=True)
+
+
+def deprecation_headers(
+    deprecated_on: Optional[date],
+    sunset_on: Optional[date],
+    successor: Optional[str] = None,
+) -> List[Tuple[str, str]]:
+    """
+    The headers announcing a deprecation: `Deprecation` (RFC 9745, as
+    `@<unix time>`), `Sunset` (RFC 8594) and a `successor-version` link.
+    """
+    headers: List[Tuple[str, str]] = []
+    if deprecated_on:
+        announced = datetime.combine(deprecated_on, time.min, tzinfo=timezone.utc)
+        headers.append(("Deprecation", f"@{int(announced.timestamp())}"))
+    if sunset_on:
+        headers.append(("Sunset", _http_date(sunset_on)))
+    if successor:
+        headers.append(("Link", f'<{successor}>; rel="successor-version"'))
+    return headers
+
+
+def deprecates_routes_in(version: ApiVersion) -> bool:
+    """Whether any route of the version is retired, before matching a request's route."""
+    return any(deprecation.route.startswith(version.prefix + "/") for deprecation in DEPRECATED_ROUTES)
+
+
+def route_deprecation(method: str, route: str) -> Optional[RouteDeprecation]:
+    for deprecation in DEPRECATED_ROUTES:
+        if deprecation.route == route and deprecation.method == method:
+            return deprecation
+    return None
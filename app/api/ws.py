@@ -0,0 +1,41 @@
+# Location: app/api/ws.py
+
+import logging
+import re
+
+from fastapi import APIRouter, WebSocket
+
+from app.core.config import get_settings
+from app.realtime.hub import get_hub
+from app.realtime.session import CLOSE_FORBIDDEN, RealtimeSession, get_connection_limits
+
+router = APIRouter()
+
+
+def origin_allowed(origin: str) -> bool:
+    """
+    Whether a browser page from `origin` may connect: one of CORS_ALLOW_ORIGINS
+    or matching CORS_ALLOW_ORIGIN_REGEX. CORS does not cover WebSockets, so
+    the same origins are checked here. Native apps send no Origin.
+    """
+    settings = get_settings()
+    if "*" in settings.cors_origins or origin in settings.cors_origins:
+        return True
+    return bool(settings.cors_allow_origin_regex and re.fullmatch(settings.cors_allow_origin_regex, origin))
+
+
+@router.websocket("/ws")
+async def websocket_session(websocket: WebSocket):
+    """
+    Presence, typing indicators and WebRTC signaling for telehealth visits;
+    see app/realtime/session.py for the protocol. Closed without accepting
+    (an HTTP 403 to the upgrade request) when WEBSOCKETS_ENABLED is off or
+    the page's origin is not allowed.
+    """
+    origin = websocket.headers.get("origin")
+    if not get_settings().websockets_enabled or (origin and not origin_allowed(origin)):
+        logging.info(f"Refused WebSocket connection from origin {origin or '-'}")
+        await websocket.close(code=CLOSE_FORBIDDEN)
+        return
+    await websocket.accept()
+    await RealtimeSession(websocket, get_hub(), get_connection_limits()).run()
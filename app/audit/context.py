@@ -0,0 +1,79 @@
+# Location: app/audit/context.py
+
+from contextvars import ContextVar
+from dataclasses import dataclass, field
+from typing import Dict, List, Optional
+
+
+@dataclass
+class AuditContext:
+    """
+    What the audit middleware cannot work out from the request line alone:
+    the authenticated actor (set by `get_current_user`), their organization
+    (set by TenancyMiddleware) and, for routes that are not nested under a
+    patient, whose record was touched.
+    """
+    actor_uid: Optional[str] = None
+    actor_roles: List[str] = field(default_factory=list)
+    patient_id: Optional[str] = None
+    resource_type: Optional[str] = None
+    resource_id: Optional[str] = None
+    organization_id: Optional[str] = None
+    security_event: Optional[str] = None
+
+
+# Set by AuditMiddleware for the duration of each audited request. Sync
+# handlers run in a threadpool with a *copy* of the context, so the variable
+# holds a mutable object: assignments to its fields from a handler are seen
+# by the middleware, whereas `audit_context_var.set(...)` there would not be.
+audit_context_var: ContextVar[Optional[AuditContext]] = ContextVar("audit_context", default=None)
+
+
+def set_actor(claims: Dict) -> None:
+    """Records the authenticated caller from their verified token claims."""
+    context = audit_context_var.get()
+    if context is None:
+        return
+    context.actor_uid = claims.get("uid")
+    roles = claims.get("roles")
+    context.actor_roles = [str(role) for role in roles] if isinstance(roles, list) else []
+
+
+def set_organization(organization_id: Optional[str]) -> None:
+    """Records the organization the request acted for, so its audit event is stored with that tenant's."""
+    context = audit_context_var.get()
+    if context is not None:
+        context.organization_id = organization_id
+
+
+def annotate(
+    patient_id: Optional[str] = None,
+    resource_type: Optional[str] = None,
+    resource_id: Optional[str] = None,
+) -> None:
+    """
+    Attaches details to the current request's audit event, e.g. the patient
+    an encounter belongs to when it is read via `/encounters/{encounterId}`.
+    Values derived from the route are used for anything left unset.
+    Does nothing outside an audited request.
+    """
+    context = audit_context_var.get()
+    if context is None:
+        return
+    if patient_id:
+        context.patient_id = patient_id
+    if resource_type:
+        context.resource_type = resource_type
+    if resource_id:
+        context.resource_id = resource_id
+
+
+def security_event(event: str) -> None:
+    """
+    Marks the current request's audit event as a security event, e.g.
+    "locked-out" (see `schemas.SecurityEvent`); a later mark replaces an
+    earlier one. Does nothing outside an audited request.
+    """
+    context = audit_context_var.get()
+    if context is not None:
+        context.security_event = event
@@ -0,0 +1,103 @@
+# Location: app/audit/events.py
+
+import re
+from datetime import datetime
+from typing import Dict, Optional, Tuple
+
+from starlette.datastructures import Headers
+from starlette.types import Scope
+
+from app.api.v1 import schemas
+from app.api.versioning import version_prefixes
+from app.audit.context import AuditContext
+from app.authz.networks import client_address
+from app.core.config import get_settings
+
+ACTIONS = {"GET": "read", "HEAD": "read", "POST": "create", "PUT": "update", "PATCH": "update", "DELETE": "delete"}
+
+# HL7 v3 PurposeOfUse codes a caller may declare in the X-Purpose-Of-Use header.
+# See https://terminology.hl7.org/ValueSet-v3-PurposeOfUse.html
+PURPOSE_OF_USE_HEADER = "X-Purpose-Of-Use"
+PURPOSES_OF_USE = {"TREAT", "ETREAT", "HPAYMT", "HOPERAT", "PATRQT", "PUBHLTH", "HRESCH", "HLEGAL", "COC"}
+UNSPECIFIED_PURPOSE = "UNSPECIFIED"
+
+# Mount points stripped before the resource is read off the route template.
+ROUTE_PREFIXES = version_prefixes() + ("/fhir", "/integrations")
+
+# Trailing route segments that name an operation on a resource rather than
+# a resource of their own, e.g. POST /encounters/{encounterId}/status.
+OPERATION_SEGMENTS = {"status", "assign", "revoke", "check", "trend", "forecast", "reschedule", "cancel", "complete", "download", "original", "series"}
+
+_PARAM_RE = re.compile(r"^\{(\w+)(?::\w+)?\}$")
+
+
+def action_for(method: str) -> str:
+    return ACTIONS.get(method.upper(), "read")
+
+
+def purpose_of_use(headers: Headers) -> str:
+    """Returns the declared purpose of use, or UNSPECIFIED if absent or not a known code."""
+    value = (headers.get(PURPOSE_OF_USE_HEADER) or "").strip().upper()
+    return value if value in PURPOSES_OF_USE else UNSPECIFIED_PURPOSE
+
+
+def resource_for(route: str, path_params: Dict[str, str]) -> Tuple[Optional[str], Optional[str]]:
+    """
+    Reads (resourceType, resourceId) off a route template: the last collection
+    segment and the ID that follows it, if any.
+
+        /api/v1/patients/{patientId}/allergies/{allergyId} -> ("allergies", <allergyId>)
+        /api/v1/patients/{patientId}/allergies             -> ("allergies", None)
+        /api/v1/encounters/{encounterId}/status            -> ("encounters", <encounterId>)
+    """
+    for prefix in ROUTE_PREFIXES:
+        if route.startswith(prefix + "/"):
+            route = route[len(prefix):]
+            break
+    segments = [segment for segment in route.split("/") if segment]
+
+    resource_type, resource_id = None, None
+    for index, segment in enumerate(segments):
+        if _PARAM_RE.match(segment) or segment in OPERATION_SEGMENTS:
+            continue
+        resource_type, resource_id = segment, None
+        if index + 1 < len(segments):
+            param = _PARAM_RE.match(segments[index + 1])
+            if param:
+                resource_id = path_params.get(param.group(1))
+    return resource_type, resource_id
+
+
+def build_event(
+    scope: Scope,
+    route: str,
+    status_code: int,
+    context: AuditContext,
+    occurred_at: datetime,
+    request_id: Optional[str] = None,
+    trace_id: Optional[str] = None,
+) -> schemas.AuditEventCreate:
+    """Assembles the audit record for a completed request."""
+    headers = Headers(scope=scope)
+    path_params = {k: str(v) for k, v in (scope.get("path_params") or {}).items()}
+    resource_type, resource_id = resource_for(route, path_params)
+    return schemas.AuditEventCreate(
+        occurred_at=occurred_at,
+        action=action_for(scope["method"]),
+        outcome="success" if status_code < 400 else "failure",
+        status_code=status_code,
+        method=scope["method"],
+        route=route,
+        path=scope["path"],
+        actor_uid=context.actor_uid,
+        actor_roles=context.actor_roles,
+        resource_type=context.resource_type or resource_type,
+        resource_id=context.resource_id or resource_id,
+        patient_id=context.patient_id or path_params.get("patientId") or path_params.get("patient_id"),
+        purpose_of_use=purpose_of_use(headers),
+        source_ip=client_address(scope, get_settings().trusted_proxy_hops),
+        user_agent=headers.get("user-agent"),
+        request_id=request_id,
+        trace_id=trace_id,
+        security_event=context.security_event,
+    )
@@ -0,0 +1,88 @@
+# Location: app/audit/export.py
+
+import csv
+import logging
+from datetime import datetime, timezone
+from typing import Any, Dict
+
+from app.api.representations import escape_formula
+from app.api.v1 import schemas
+from app.core.storage import get_storage_client
+from app.repositories.audit_events import AuditEventRepository
+from app.repositories.audit_exports import AuditExportRepository
+
+CSV = "text/csv"
+
+# Columns of the export, named as the event's JSON fields, in the order a
+# reader scans them: when, who, what, whose record, from where.
+COLUMNS = (
+    "eventId", "occurredAt", "actorUid", "actorRoles", "action", "outcome", "statusCode",
+    "method", "route", "path", "resourceType", "resourceId", "patientId", "purposeOfUse",
+    "sourceIp", "userAgent", "securityEvent", "requestId", "traceId",
+)
+
+# Events are read this many at a time; each batch continues after the last.
+BATCH_SIZE = 1000
+
+
+def output_blob_name(export_id: str) -> str:
+    return f"audit-exports/{export_id}.csv"
+
+
+def _row(event: schemas.AuditEvent) -> Dict[str, Any]:
+    data = event.model_dump(mode="json", by_alias=True)
+    data["actorRoles"] = " ".join(data.get("actorRoles") or [])
+    return {
+        column: "" if data.get(column) is None else escape_formula(data[column]) if isinstance(data[column], str) else data[column]
+        for column in COLUMNS
+    }
+
+
+def run_audit_export(
+    export_id: str,
+    exports: AuditExportRepository,
+    events: AuditEventRepository,
+    bucket_name: str,
+    batch_size: int = BATCH_SIZE,
+) -> None:
+    """
+    Writes the events matching the export's filters, most recent first as
+    the audit trail is listed, to `gs://{bucket_name}/audit-exports/{export_id}.csv`
+    and records the file and row count on the export. Exports that are no
+    longer `accepted` are skipped, so a repeated run writes no second file.
+    """
+    export = exports.get(export_id)
+    if not export or export.status != "accepted":
+        return
+    exports.update(export_id, {"status": "in-progress"})
+    try:
+        blob = get_storage_client().bucket(bucket_name).blob(output_blob_name(export_id))
+        count, after = 0, None
+        with blob.open("w", content_type=CSV) as f:
+            writer = csv.DictWriter(f, fieldnames=COLUMNS, lineterminator="\r\n")
+            writer.writeheader()
+            while True:
+                batch = events.list(
+                    actor_uid=export.actor_uid,
+                    patient_id=export.patient_id,
+                    resource_type=export.resource_type,
+                    occurred_from=export.occurred_from,
+                    occurred_to=export.occurred_to,
+                    limit=batch_size,
+                    after=after,
+                )
+                writer.writerows(_row(event) for event in batch)
+                count += len(batch)
+                if len(batch) < batch_size:
+                    break
+                after = batch[-1].event_id
+        exports.update(export_id, {
+            "status": "completed",
+            "blobName": blob.name,
+            "rowCount": count,
+            "completedAt": datetime.now(timezone.utc),
+        })
+        logging.info(f"Audit export {export_id} completed with {count} events.")
+    except Exception as e:
+        logging.exception(f"Audit export {export_id} failed: {e}", extra={"report_error": True})
+        exports.update(export_id, {"status": "error", "error": str(e)})
@@ -0,0 +1,146 @@
+# Location: app/auth/accounts.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime, timezone
+from functools import lru_cache
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.auth.verifier import _roles
+from app.authz.roles import PATIENT
+from app.core.config import get_settings
+from app.tenancy.context import ORGANIZATION_ID_CLAIM
+
+
+class AccountExistsError(Exception):
+    """An account with the email address already exists."""
+
+
+class UserAccounts(ABC):
+    """
+    Staff accounts at the identity provider, for administrators: inviting,
+    changing roles, deactivating and resetting credentials. Roles and the
+    organization are kept as custom claims, so they reach the user's next
+    ID token.
+    """
+
+    @abstractmethod
+    def list(self, organization_id: Optional[str]) -> List[schemas.StaffUser]:
+        """Returns the staff of the organization (everyone's if None), by uid."""
+
+    @abstractmethod
+    def get(self, uid: str) -> Optional[schemas.StaffUser]:
+        """Returns the user, deactivated or not, or None if there is no such account."""
+
+    @abstractmethod
+    def create(self, email: str, display_name: Optional[str], roles: List[str], organization_id: Optional[str]) -> schemas.StaffUser:
+        """Creates an account without a password. Raises AccountExistsError."""
+
+    @abstractmethod
+    def set_roles(self, uid: str, roles: List[str]) -> schemas.StaffUser:
+        """Replaces the user's roles, keeping their other claims."""
+
+    @abstractmethod
+    def set_disabled(self, uid: str, disabled: bool) -> schemas.StaffUser:
+        """Deactivates or reactivates the account."""
+
+    @abstractmethod
+    def revoke_sign_ins(self, uid: str) -> None:
+        """Ends the user's sign-ins at the identity provider; their ID tokens stop being accepted."""
+
+    @abstractmethod
+    def password_link(self, email: str) -> str:
+        """Returns a link that sets a new password for the account, for inviting or resetting."""
+
+
+def is_staff(roles: List[str]) -> bool:
+    """Accounts holding a role other than `patient` are staff."""
+    return any(role != PATIENT for role in roles)
+
+
+class FirebaseUserAccounts(UserAccounts):
+    """Firebase Auth users, managed with the Admin SDK."""
+
+    @staticmethod
+    def _timestamp(millis: Optional[int]) -> Optional[datetime]:
+        return datetime.fromtimestamp(millis / 1000, tz=timezone.utc) if millis else None
+
+    def _user(self, user) -> schemas.StaffUser:
+        custom = user.custom_claims or {}
+        last_sign_in_at = self._timestamp(user.user_metadata.last_sign_in_timestamp)
+        return schemas.StaffUser(
+            uid=user.uid,
+            email=user.email,
+            display_name=user.display_name,
+            roles=_roles(custom.get("roles")),
+            organization_id=custom.get(ORGANIZATION_ID_CLAIM),
+            status="deactivated" if user.disabled else ("active" if last_sign_in_at else "invited"),
+            last_sign_in_at=last_sign_in_at,
+            created_at=self._timestamp(user.user_metadata.creation_timestamp),
+        )
+
+    def list(self, organization_id: Optional[str]) -> List[schemas.StaffUser]:
+        from firebase_admin import auth
+
+        # Firebase cannot query by claim, so every account is read; patients
+        # are the bulk of them and are skipped.
+        users = []
+        for user in auth.list_users().iterate_all():
+            custom = user.custom_claims or {}
+            if not is_staff(_roles(custom.get("roles"))):
+                continue
+            if organization_id is not None and custom.get(ORGANIZATION_ID_CLAIM) != organization_id:
+                continue
+            users.append(self._user(user))
+        return sorted(users, key=lambda user: user.uid)
+
+    def get(self, uid: str) -> Optional[schemas.StaffUser]:
+        from firebase_admin import auth
+
+        try:
+            return self._user(auth.get_user(uid))
+        except auth.UserNotFoundError:
+            return None
+
+    def create(self, email: str, display_name: Optional[str], roles: List[str], organization_id: Optional[str]) -> schemas.StaffUser:
+        from firebase_admin import auth
+
+        try:
+            user = auth.create_user(email=email, display_name=display_name, email_verified=False)
+        except auth.EmailAlreadyExistsError:
+            raise AccountExistsError("An account with the address already exists")
+        claims = {"roles": roles}
+        if organization_id:
+            claims[ORGANIZATION_ID_CLAIM] = organization_id
+        auth.set_custom_user_claims(user.uid, claims)
+        return self._user(auth.get_user(user.uid))
+
+    def set_roles(self, uid: str, roles: List[str]) -> schemas.StaffUser:
+        from firebase_admin import auth
+
+        user = auth.get_user(uid)
+        auth.set_custom_user_claims(uid, {**(user.custom_claims or {}), "roles": roles})
+        return self._user(auth.get_user(uid))
+
+    def set_disabled(self, uid: str, disabled: bool) -> schemas.StaffUser:
+        from firebase_admin import auth
+
+        return self._user(auth.update_user(uid, disabled=disabled))
+
+    def revoke_sign_ins(self, uid: str) -> None:
+        from firebase_admin import auth
+
+        auth.revoke_refresh_tokens(uid)
+
+    def password_link(self, email: str) -> str:
+        from firebase_admin import auth
+
+        return auth.generate_password_reset_link(email)
+
+
+@lru_cache
+def get_user_accounts() -> Optional[UserAccounts]:
+    """The accounts of AUTH_PROVIDER's users, or None where they cannot be managed (OIDC issuers)."""
+    if get_settings().auth_provider == "firebase":
+        return FirebaseUserAccounts()
+    return None
@@ -0,0 +1,161 @@
+# Location: app/auth/api_keys.py
+
+import hashlib
+import hmac
+import re
+import secrets
+import threading
+import time
+from functools import lru_cache
+from typing import Any, Callable, Dict, Optional, Tuple
+
+from app.api.v1 import schemas
+from app.api.versioning import strip_version
+from app.auth.verifier import InvalidTokenError
+from app.core.config import get_settings
+from app.repositories.api_keys import ApiKeyRepository
+from app.tenancy.context import ORGANIZATION_ID_CLAIM
+
+API_KEY_HEADER = "X-Api-Key"
+
+# Keys look like `mck_<16 hex key ID>_<secret>`. The ID locates the record;
+# only a SHA-256 hash of the secret is stored. The secret carries 256 bits of
+# randomness, so a fast hash is enough; there is nothing to brute-force.
+KEY_PREFIX = "mck_"
+_KEY_RE = re.compile(r"^mck_([0-9a-f]{16})_([A-Za-z0-9_\-]{43})$")
+
+
+def hash_secret(secret: str) -> str:
+    return hashlib.sha256(secret.encode("utf-8")).hexdigest()
+
+
+def new_key_id() -> str:
+    return secrets.token_hex(8)
+
+
+def new_secret() -> str:
+    return secrets.token_urlsafe(32)
+
+
+def format_key(key_id: str, secret: str) -> str:
+    return f"{KEY_PREFIX}{key_id}_{secret}"
+
+
+def parse_key(raw: str) -> Optional[Tuple[str, str]]:
+    """Splits a presented key into (key ID, secret), or None if it is not shaped like one."""
+    match = _KEY_RE.match(raw.strip())
+    return (match.group(1), match.group(2)) if match else None
+
+
+def principal_for(record: schemas.ApiKeyRecord) -> Dict[str, Any]:
+    """
+    The claims an API key authenticates as. Keys hold no roles, so
+    role-gated endpoints (administration, audit search) stay closed to them;
+    what they may call is governed by their scopes. A key acts for the
+    organization it was created in.
+    """
+    claims = {
+        "uid": f"api-key:{record.key_id}",
+        "roles": [],
+        "scopes": list(record.scopes),
+        "apiKeyId": record.key_id,
+        "authMethod": "api_key",
+    }
+    if record.tenant_id:
+        claims[ORGANIZATION_ID_CLAIM] = record.tenant_id
+    return claims
+
+
+def scope_for(method: str, path: str) -> Optional[str]:
+    """
+    The scope a request needs, e.g. GET /api/v1/patients/p-1/observations ->
+    "patients:read". Scopes are the same in every API version.
+    """
+    resource = strip_version(path).strip("/").split("/", 1)[0]
+    if not resource:
+        return None
+    return f"{resource}:{'read' if method in ('GET', 'HEAD') else 'write'}"
+
+
+def _default_repository() -> ApiKeyRepository:
+    from app.api.v1.deps import get_api_key_repository
+    return get_api_key_repository()
+
+
+class ApiKeyVerifier:
+    """
+    Checks presented API keys against their stored hash. Records are cached
+    for `cache_seconds` to keep a store read off every request, so a revoked
+    or rotated-out key keeps working for at most that long; a key that does
+    not match its cached record is looked up again, so a freshly rotated
+    key works at once.
+    """
+
+    def __init__(
+        self,
+        repository_factory: Callable[[], ApiKeyRepository] = _default_repository,
+        cache_seconds: float = 60.0,
+        clock: Callable[[], float] = time.monotonic,
+    ):
+        self.repository_factory = repository_factory
+        self.cache_seconds = cache_seconds
+        self.clock = clock
+        self._cache: Dict[str, Tuple[float, Optional[schemas.ApiKeyRecord]]] = {}
+        self._lock = threading.Lock()
+
+    def _record(self, key_id: str, refresh: bool = False) -> Optional[schemas.ApiKeyRecord]:
+        now = self.clock()
+        with self._lock:
+            cached = self._cache.get(key_id)
+        if cached and not refresh and now - cached[0] < self.cache_seconds:
+            return cached[1]
+        record = self.repository_factory().get(key_id)
+        with self._lock:
+            self._cache[key_id] = (now, record)
+        return record
+
+    def verify(self, raw: str) -> Tuple[schemas.ApiKeyRecord, Dict[str, Any]]:
+        """Returns the key's record and the claims it authenticates as. Raises InvalidTokenError."""
+        parsed = parse_key(raw)
+        if not parsed:
+            raise InvalidTokenError("Malformed API key")
+        key_id, secret = parsed
+        presented = hash_secret(secret)
+        record = self._record(key_id)
+        if record and not hmac.compare_digest(record.secret_hash, presented):
+            record = self._record(key_id, refresh=True)
+        if not record or not hmac.compare_digest(record.secret_hash, presented):
+            raise InvalidTokenError("Unknown API key")
+        if record.status != "active":
+            raise InvalidTokenError("API key has been revoked")
+        return record, principal_for(record)
+
+
+class KeyRateLimiter:
+    """
+    Fixed one-minute windows of requests per API key, counted in this
+    worker process. Each worker enforces the full limit, so the effective
+    limit of a deployment is the per-key limit times its worker count.
+    """
+
+    def __init__(self, clock: Callable[[], float] = time.monotonic):
+        self.clock = clock
+        self._windows: Dict[str, Tuple[int, int]] = {}
+        self._lock = threading.Lock()
+
+    def allow(self, key_id: str, limit: int) -> Tuple[bool, int]:
+        """Counts a request. Returns whether it is within the limit and the seconds until the window resets."""
+        now = self.clock()
+        window = int(now // 60)
+        with self._lock:
+            current, count = self._windows.get(key_id, (window, 0))
+            if current != window:
+                count = 0
+            count += 1
+            self._windows[key_id] = (window, count)
+        return count <= limit, 60 - int(now % 60)
+
+
+@lru_cache
+def get_api_key_verifier() -> ApiKeyVerifier:
+    return ApiKeyVerifier(cache_seconds=get_settings().api_key_cache_seconds)
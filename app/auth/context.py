@@ -0,0 +1,14 @@
+# Location: app/auth/context.py
+
+from contextvars import ContextVar
+from typing import Any, Dict, Optional
+
+# --- Authenticated Principal ---
+# The verified claims of the caller's bearer token, set by
+# AuthenticationMiddleware for the duration of the request. Always carries
+# `uid` (the token subject) and `roles`, whichever issuer signed the token.
+principal_var: ContextVar[Optional[Dict[str, Any]]] = ContextVar("principal", default=None)
+
+
+def get_principal() -> Optional[Dict[str, Any]]:
+    return principal_var.get()
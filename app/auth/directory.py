@@ -0,0 +1,66 @@
+# Location: app/auth/directory.py
+
+from abc import ABC, abstractmethod
+from functools import lru_cache
+from typing import Any, Dict, Optional
+
+from app.auth.verifier import _roles
+from app.core.config import get_settings
+
+
+class UserDirectory(ABC):
+    """
+    The identity provider's user accounts, for signing users in without an
+    ID token (e.g. by magic link). Users are returned as the claims their ID
+    token would carry, so they start sessions like any other sign-in.
+    """
+
+    @abstractmethod
+    def find_by_email(self, email: str) -> Optional[Dict[str, Any]]:
+        """Returns the user with the address, or None if no enabled account has it."""
+
+    @abstractmethod
+    def get(self, uid: str) -> Optional[Dict[str, Any]]:
+        """Returns the user, or None if their account is gone or disabled."""
+
+
+class FirebaseUserDirectory(UserDirectory):
+    """Firebase Auth users, read with the Admin SDK; custom claims such as `roles` are included."""
+
+    @staticmethod
+    def _claims(user) -> Optional[Dict[str, Any]]:
+        if user.disabled:
+            return None
+        custom = user.custom_claims or {}
+        return {
+            **custom,
+            "uid": user.uid,
+            "email": user.email,
+            "email_verified": user.email_verified,
+            "name": user.display_name,
+            "roles": _roles(custom.get("roles")),
+        }
+
+    def find_by_email(self, email: str) -> Optional[Dict[str, Any]]:
+        from firebase_admin import auth
+
+        try:
+            return self._claims(auth.get_user_by_email(email))
+        except auth.UserNotFoundError:
+            return None
+
+    def get(self, uid: str) -> Optional[Dict[str, Any]]:
+        from firebase_admin import auth
+
+        try:
+            return self._claims(auth.get_user(uid))
+        except auth.UserNotFoundError:
+            return None
+
+
+@lru_cache
+def get_user_directory() -> Optional[UserDirectory]:
+    """The directory of AUTH_PROVIDER's users, or None where it cannot be read (OIDC issuers)."""
+    if get_settings().auth_provider == "firebase":
+        return FirebaseUserDirectory()
+    return None
@@ -0,0 +1,125 @@
+# Location: app/auth/google_tokens.py
+
+import threading
+import time
+from functools import lru_cache
+from typing import Callable, Dict, Generator, Optional, Tuple
+
+import httpx
+import jwt
+
+from app.core.http_client import new_client
+
+# --- Inbound: Verifying Google ID Tokens ---
+# Google-signed OIDC tokens are what Cloud Run services, Cloud Tasks and
+# Cloud Scheduler attach to their calls. Anyone with a Google account can
+# mint one for any audience, so callers must also be checked by email.
+GOOGLE_ISSUERS = ("accounts.google.com", "https://accounts.google.com")
+
+
+@lru_cache
+def _google_request():
+    """A shared transport for fetching Google's token signing certificates."""
+    from google.auth.transport import requests as google_requests
+    return google_requests.Request()
+
+
+def verify_google_id_token(token: str, audience: str) -> Dict:
+    """
+    Verifies the signature, expiry, issuer and audience of a Google-signed ID
+    token and that its email is verified. Returns the claims; raises
+    ValueError otherwise.
+    """
+    from google.oauth2 import id_token
+
+    claims = id_token.verify_oauth2_token(token, _google_request(), audience=audience)
+    if claims.get("iss", GOOGLE_ISSUERS[0]) not in GOOGLE_ISSUERS:
+        raise ValueError(f"token was issued by {claims.get('iss')}, not Google")
+    if not claims.get("email") or not claims.get("email_verified"):
+        raise ValueError("token carries no verified service account email")
+    return claims
+
+
+# --- Outbound: Minting ID Tokens ---
+METADATA_IDENTITY_URL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/identity"
+METADATA_TIMEOUT_SECONDS = 5.0
+# Cached tokens are replaced this long before they expire, so a token never
+# runs out while a request is in flight.
+REFRESH_MARGIN_SECONDS = 300
+
+
+class IdTokenProvider:
+    """
+    Mints ID tokens of this service's own service account from the metadata
+    server, as available on Cloud Run, for calling other MegaCare services.
+    Tokens are cached per audience until shortly before they expire. Safe
+    to share between threads.
+    """
+
+    def __init__(self, client: Optional[httpx.Client] = None, clock: Callable[[], float] = time.time):
+        self.client = client or new_client("metadata-server", METADATA_TIMEOUT_SECONDS)
+        self.clock = clock
+        self._tokens: Dict[str, Tuple[str, float]] = {}
+        self._lock = threading.Lock()
+
+    def token(self, audience: str) -> str:
+        with self._lock:
+            cached = self._tokens.get(audience)
+        if cached and cached[1] - REFRESH_MARGIN_SECONDS > self.clock():
+            return cached[0]
+        response = self.client.get(
+            METADATA_IDENTITY_URL,
+            params={"audience": audience, "format": "full"},
+            headers={"Metadata-Flavor": "Google"},
+        )
+        response.raise_for_status()
+        token = response.text.strip()
+        # The token comes straight from the metadata server; its expiry is
+        # only read, not trusted for anything.
+        expires_at = float(jwt.decode(token, options={"verify_signature": False}).get("exp", self.clock() + 3600))
+        with self._lock:
+            self._tokens[audience] = (token, expires_at)
+        return token
+
+
+@lru_cache
+def get_id_token_provider() -> IdTokenProvider:
+    return IdTokenProvider()
+
+
+class GoogleIdTokenAuth(httpx.Auth):
+    """httpx authentication that sends an ID token for `audience` as the bearer token."""
+
+    def __init__(self, audience: str, provider: Optional[IdTokenProvider] = None):
+        self.audience = audience
+        self.provider = provider or get_id_token_provider()
+
+    def auth_flow(self, request: httpx.Request) -> Generator[httpx.Request, httpx.Response, None]:
+        request.headers["Authorization"] = f"Bearer {self.provider.token(self.audience)}"
+        yield request
+
+
+def service_client(
+    base_url: str,
+    audience: Optional[str] = None,
+    timeout: float = 10.0,
+    provider: Optional[IdTokenProvider] = None,
+    transport: Optional[httpx.BaseTransport] = None,
+) -> httpx.Client:
+    """
+    An httpx client for another MegaCare service on Cloud Run that
+    authenticates every request with this service's identity:
+
+        with service_client("https://billing-abc123-as.a.run.app") as billing:
+            billing.post("/internal/services/invoices", json=...)
+
+    The audience defaults to the base URL, which is what Cloud Run and
+    `ServiceAuthenticationMiddleware` expect.
+    """
+    return new_client(
+        "megacare-service",
+        timeout,
+        base_url=base_url,
+        auth=GoogleIdTokenAuth(audience or base_url.rstrip("/"), provider),
+        transport=transport,
+    )
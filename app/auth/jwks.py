@@ -0,0 +1,92 @@
+# Location: app/auth/jwks.py
+
+import threading
+import time
+from typing import Any, Callable, Dict, Optional
+
+import httpx
+import jwt
+
+from app.core.http_client import new_client
+
+# A token signed with an unknown key ID triggers an early refresh (issuers
+# publish new keys shortly before signing with them), but at most this often,
+# so tokens with made-up key IDs cannot make every request fetch the key set.
+MIN_REFRESH_SECONDS = 60.0
+FETCH_TIMEOUT_SECONDS = 5.0
+
+
+def fetch_openid_configuration(issuer: str, client: Optional[httpx.Client] = None) -> Dict[str, Any]:
+    """Returns the issuer's OpenID Connect discovery document."""
+    client = client or new_client("jwks", FETCH_TIMEOUT_SECONDS)
+    response = client.get(f"{issuer.rstrip('/')}/.well-known/openid-configuration")
+    response.raise_for_status()
+    return response.json()
+
+
+def discover_jwks_url(issuer: str, client: Optional[httpx.Client] = None) -> str:
+    """Returns the `jwks_uri` from the issuer's OpenID Connect discovery document."""
+    jwks_uri = fetch_openid_configuration(issuer, client).get("jwks_uri")
+    if not jwks_uri:
+        raise ValueError(f"The discovery document of {issuer} has no jwks_uri")
+    return jwks_uri
+
+
+class JwksCache:
+    """
+    The signing keys of an OIDC issuer, fetched from its JWKS endpoint and
+    kept for `ttl_seconds`. Safe to share between threads; concurrent misses
+    wait for one fetch. The URL is resolved lazily, so discovery failures
+    fail requests rather than start-ups: callers get 503 with Retry-After,
+    and the failure is not counted towards sign-in lockouts.
+    """
+
+    def __init__(
+        self,
+        resolve_url: Callable[[], str],
+        ttl_seconds: float = 3600.0,
+        client: Optional[httpx.Client] = None,
+        clock: Callable[[], float] = time.monotonic,
+    ):
+        self.resolve_url = resolve_url
+        self.ttl_seconds = ttl_seconds
+        self.client = client or new_client("jwks", FETCH_TIMEOUT_SECONDS)
+        self.clock = clock
+        self._url: Optional[str] = None
+        self._keys: Dict[str, Any] = {}
+        self._fetched_at: Optional[float] = None
+        self._lock = threading.Lock()
+
+    def get(self, kid: Optional[str]) -> Any:
+        """Returns the verification key with the given key ID. Raises LookupError if the issuer has none."""
+        with self._lock:
+            now = self.clock()
+            stale = self._fetched_at is None or now - self._fetched_at >= self.ttl_seconds
+            missing = kid not in self._keys and (self._fetched_at is None or now - self._fetched_at >= MIN_REFRESH_SECONDS)
+            if stale or missing:
+                self._refresh(now)
+            key = self._keys.get(kid)
+        if key is None:
+            raise LookupError(f"Unknown signing key '{kid}'")
+        return key
+
+    def prime(self) -> None:
+        """Fetches the keys now, so the first token verified does not wait for them."""
+        with self._lock:
+            self._refresh(self.clock())
+
+    def _refresh(self, now: float) -> None:
+        if self._url is None:
+            self._url = self.resolve_url()
+        response = self.client.get(self._url)
+        response.raise_for_status()
+        keys = {}
+        for jwk in response.json().get("keys", []):
+            if jwk.get("use", "sig") != "sig" or "kid" not in jwk:
+                continue
+            try:
+                keys[jwk["kid"]] = jwt.PyJWK(jwk).key
+            except jwt.PyJWTError:
+                continue  # An algorithm we do not support; tokens signed with it are rejected.
+        self._keys = keys
+        self._fetched_at = now
@@ -0,0 +1,173 @@
+# Location: app/auth/lockout.py
+
+import logging
+import math
+import time
+from dataclasses import dataclass
+from datetime import datetime, timedelta, timezone
+from functools import lru_cache
+from typing import Any, Callable, Dict, Optional
+
+from app.api.v1 import schemas
+from app.audit.context import security_event
+from app.core.config import get_settings
+from app.repositories.base import NotFoundError
+from app.repositories.lockouts import LockoutRepository
+
+
+class LockedOut(Exception):
+    """The account or address must wait `retry_after` seconds before another attempt."""
+
+    def __init__(self, retry_after: int, locked: bool):
+        super().__init__(f"Too many failed sign-in attempts; {'locked out' if locked else 'retry'} for {retry_after}s")
+        self.retry_after = retry_after
+        self.locked = locked
+
+
+@dataclass(frozen=True)
+class Subject:
+    """What failed attempts are counted against: an account (uid) or a source address."""
+    type: str
+    value: str
+
+    @property
+    def lockout_id(self) -> str:
+        return f"{self.type}:{self.value}"
+
+
+def account(uid: str) -> Subject:
+    return Subject("account", uid)
+
+
+def address(source_ip: Optional[str]) -> Optional[Subject]:
+    """The subject of a source address; None if it is unknown, and then nothing is counted."""
+    return Subject("address", source_ip) if source_ip else None
+
+
+def _default_repository() -> LockoutRepository:
+    from app.api.v1.deps import get_lockout_repository
+    return get_lockout_repository()
+
+
+class LoginGuard:
+    """
+    Slows down and then stops guessing of credentials. Each account and
+    source address gets `free_attempts` failed attempts; each further one
+    makes the next wait `base_delay_seconds`, doubling up to
+    `max_delay_seconds`. Reaching the account or address threshold within
+    `window_seconds` locks it out for `lockout_seconds`, unless an
+    administrator unlocks it first.
+
+    Failures, delays, lockouts and refusals mark the request's audit event
+    as a security event. Failures are counted in the store, so every
+    worker sees them.
+    """
+
+    def __init__(
+        self,
+        repository_factory: Callable[[], LockoutRepository] = _default_repository,
+        free_attempts: int = 3,
+        base_delay_seconds: int = 2,
+        max_delay_seconds: int = 60,
+        account_threshold: int = 10,
+        address_threshold: int = 50,
+        window_seconds: int = 900,
+        lockout_seconds: int = 900,
+        clock: Callable[[], float] = time.time,
+    ):
+        self.repository_factory = repository_factory
+        self.free_attempts = free_attempts
+        self.base_delay_seconds = base_delay_seconds
+        self.max_delay_seconds = max_delay_seconds
+        self.account_threshold = account_threshold
+        self.address_threshold = address_threshold
+        self.window_seconds = window_seconds
+        self.lockout_seconds = lockout_seconds
+        self.clock = clock
+
+    def _now(self) -> datetime:
+        return datetime.fromtimestamp(self.clock(), tz=timezone.utc)
+
+    def _threshold(self, subject: Subject) -> int:
+        return self.account_threshold if subject.type == "account" else self.address_threshold
+
+    def check(self, *subjects: Optional[Subject]) -> None:
+        """Raises LockedOut if any of the subjects must still wait; None subjects are skipped."""
+        now = self._now()
+        for subject in filter(None, subjects):
+            record = self.repository_factory().get(subject.lockout_id)
+            if record and record.retry_at and record.retry_at > now:
+                locked = bool(record.locked_until and record.locked_until > now)
+                security_event("lockout-refused")
+                logging.info(f"Refused a sign-in attempt of {subject.lockout_id} ({'locked out' if locked else 'delayed'})")
+                raise LockedOut(math.ceil((record.retry_at - now).total_seconds()), locked)
+
+    def failed(self, *subjects: Optional[Subject]) -> None:
+        """Counts a failed attempt against each of the subjects."""
+        now = self._now()
+        security_event("sign-in-failed")
+        for subject in filter(None, subjects):
+            record = self.repository_factory().record_failure(
+                subject.lockout_id, subject.type, subject.value, lambda stored: self._count(stored, subject, now)
+            )
+            if record.failures >= self._threshold(subject):
+                security_event("locked-out")
+                logging.warning(f"Locked out {subject.lockout_id} until {record.locked_until.isoformat()} after {record.failures} failed sign-in attempts")
+            elif record.retry_at and record.retry_at > now:
+                security_event("sign-in-delayed")
+
+    def _count(self, record: schemas.Lockout, subject: Subject, now: datetime) -> Dict[str, Any]:
+        """The changes one more failure makes; a window that has passed, or a served lockout, starts over."""
+        fresh = (
+            record.window_started_at is None
+            or record.window_started_at <= now - timedelta(seconds=self.window_seconds)
+            or (record.locked_until is not None and record.locked_until <= now)
+        )
+        failures = 1 if fresh else record.failures + 1
+        changes: Dict[str, Any] = {
+            "failures": failures,
+            "windowStartedAt": now if fresh else record.window_started_at,
+            "lastFailureAt": now,
+            "retryAt": None,
+            "lockedUntil": None if fresh else record.locked_until,
+        }
+        if failures >= self._threshold(subject):
+            until = now + timedelta(seconds=self.lockout_seconds)
+            changes.update({"retryAt": until, "lockedUntil": until, "lockouts": record.lockouts + 1})
+        elif failures > self.free_attempts:
+            delay = min(self.max_delay_seconds, self.base_delay_seconds * 2 ** (failures - self.free_attempts - 1))
+            changes["retryAt"] = now + timedelta(seconds=delay)
+        return changes
+
+    def succeeded(self, subject: Subject) -> None:
+        """
+        Forgets the failed attempts of an account that signed in. Addresses
+        keep theirs, or an attacker could reset theirs by signing in to an
+        account of their own between guesses.
+        """
+        if subject.type != "account":
+            return
+        try:
+            self.repository_factory().clear(subject.lockout_id)
+        except NotFoundError:
+            pass
+
+    def unlock(self, lockout_id: str) -> None:
+        """Lifts a lockout or delay and forgets the failures. Raises NotFoundError."""
+        self.repository_factory().clear(lockout_id)
+        security_event("unlocked")
+        logging.warning(f"Unlocked {lockout_id}")
+
+
+@lru_cache
+def get_login_guard() -> LoginGuard:
+    settings = get_settings()
+    return LoginGuard(
+        free_attempts=settings.lockout_free_attempts,
+        base_delay_seconds=settings.lockout_base_delay_seconds,
+        max_delay_seconds=settings.lockout_max_delay_seconds,
+        account_threshold=settings.lockout_account_threshold,
+        address_threshold=settings.lockout_address_threshold,
+        window_seconds=settings.lockout_window_seconds,
+        lockout_seconds=settings.lockout_duration_seconds,
+    )
@@ -0,0 +1,167 @@
+# Location: app/auth/magic_links.py
+
+import hashlib
+import logging
+import secrets
+from datetime import datetime, timedelta, timezone
+from functools import lru_cache
+from typing import Any, Callable, Dict, Optional
+
+import jwt
+
+from app.api.v1 import schemas
+from app.auth.directory import UserDirectory, get_user_directory
+from app.auth.sessions import SESSION_ALGORITHM, SESSION_ISSUER
+from app.auth.verifier import LEEWAY_SECONDS
+from app.core.config import get_settings
+from app.ratelimit.buckets import TokenBuckets, get_token_buckets
+from app.repositories.base import ConflictError, NotFoundError
+from app.repositories.magic_links import MagicLinkRepository
+
+# Link tokens are signed with the session key like session access tokens,
+# but for their own audience, so neither passes for the other. `jti` names
+# the link record; the token itself is never stored.
+MAGIC_LINK_AUDIENCE = "megacare-magic-link"
+
+
+class MagicLinkError(Exception):
+    """The link is malformed, expired, already used, or its user can no longer sign in."""
+
+
+class MagicLinkThrottled(Exception):
+    """Too many links were asked for the address; another may be asked for in `retry_after` seconds."""
+
+    def __init__(self, retry_after: int):
+        super().__init__(f"Too many sign-in links requested; retry in {retry_after}s")
+        self.retry_after = retry_after
+
+
+def address_hash(email: str) -> str:
+    """Keys rate limits and log lines by address without writing the address down."""
+    return hashlib.sha256(email.strip().lower().encode("utf-8")).hexdigest()
+
+
+def _default_repository() -> MagicLinkRepository:
+    from app.api.v1.deps import get_magic_link_repository
+    return get_magic_link_repository()
+
+
+class MagicLinks:
+    """
+    Passwordless sign-in by email. Asking for a link for an address with an
+    account records a pending link and returns it, to be emailed; asking
+    for an unknown address does nothing, and callers answer both alike so
+    the endpoint does not reveal who has an account. Each address gets
+    `per_address_per_hour` links an hour, counted before the lookup for the
+    same reason. A link's token is redeemed once, within `ttl_seconds`, for
+    the claims of its user as the directory has them at that moment.
+    """
+
+    def __init__(
+        self,
+        signing_key: str,
+        directory: UserDirectory,
+        buckets: TokenBuckets,
+        repository_factory: Callable[[], MagicLinkRepository] = _default_repository,
+        ttl_seconds: int = 900,
+        per_address_per_hour: int = 5,
+        link_url: Optional[str] = None,
+        clock: Callable[[], datetime] = lambda: datetime.now(timezone.utc),
+    ):
+        self.signing_key = signing_key
+        self.directory = directory
+        self.buckets = buckets
+        self.repository_factory = repository_factory
+        self.ttl_seconds = ttl_seconds
+        self.per_address_per_hour = per_address_per_hour
+        self.link_url = link_url
+        self.clock = clock
+
+    def request(self, email: str, source_ip: Optional[str] = None) -> Optional[schemas.MagicLinkRecord]:
+        """
+        Records a link for the account with the address. Returns None for
+        an unknown address. Raises MagicLinkThrottled.
+        """
+        key = address_hash(email)
+        allowed, retry_after = self.buckets.take(f"magic-link:{key}", self.per_address_per_hour, self.per_address_per_hour / 3600)
+        if not allowed:
+            logging.warning(f"Throttled sign-in links to address {key[:12]}")
+            raise MagicLinkThrottled(retry_after)
+        user = self.directory.find_by_email(email)
+        if not user:
+            logging.info(f"No sign-in link for address {key[:12]}: no enabled account")
+            return None
+        record = self.repository_factory().create(
+            secrets.token_hex(16), user["uid"], user.get("email") or email,
+            self.clock() + timedelta(seconds=self.ttl_seconds), requested_from=source_ip,
+        )
+        logging.info(f"Issued sign-in link {record.link_id} to user {record.uid}")
+        return record
+
+    def token(self, record: schemas.MagicLinkRecord) -> str:
+        """The link's token. It is derived from the record alone, so the job that sends the email signs the same token."""
+        claims = {
+            "iss": SESSION_ISSUER,
+            "aud": MAGIC_LINK_AUDIENCE,
+            "sub": record.uid,
+            "jti": record.link_id,
+            "iat": int(record.created_at.timestamp()),
+            "exp": int(record.expires_at.timestamp()),
+        }
+        return jwt.encode(claims, self.signing_key, algorithm=SESSION_ALGORITHM)
+
+    def url(self, record: schemas.MagicLinkRecord) -> str:
+        """
+        The link emailed for the record. The token is in the fragment,
+        which browsers do not send to servers or in Referer headers; the
+        page reads it and posts it to the exchange endpoint.
+        """
+        if not self.link_url:
+            raise ValueError("PATIENT_PORTAL_URL must be set to send sign-in links")
+        return f"{self.link_url}#token={self.token(record)}"
+
+    def redeem(self, token: str) -> Dict[str, Any]:
+        """Uses up a link's token and returns its user's claims. Raises MagicLinkError."""
+        try:
+            claims = jwt.decode(
+                token,
+                self.signing_key,
+                algorithms=[SESSION_ALGORITHM],
+                audience=MAGIC_LINK_AUDIENCE,
+                issuer=SESSION_ISSUER,
+                leeway=LEEWAY_SECONDS,
+                options={"require": ["exp", "iat", "sub", "jti"]},
+            )
+        except jwt.PyJWTError as e:
+            raise MagicLinkError(str(e))
+        try:
+            record = self.repository_factory().redeem(claims["jti"])
+        except NotFoundError:
+            raise MagicLinkError("Unknown sign-in link")
+        except ConflictError:
+            logging.warning(f"Sign-in link {claims['jti']} of user {claims['sub']} was used again")
+            raise MagicLinkError("Sign-in link was already used")
+        user = self.directory.get(record.uid)
+        if not user:
+            raise MagicLinkError("Account is disabled or gone")
+        return user
+
+
+@lru_cache
+def get_magic_links() -> Optional[MagicLinks]:
+    """
+    The process-wide magic links, or None without SESSION_SIGNING_KEY (links
+    exchange for sessions) or a user directory to look addresses up in.
+    """
+    settings = get_settings()
+    directory = get_user_directory()
+    if not settings.session_signing_key or directory is None:
+        return None
+    return MagicLinks(
+        settings.session_signing_key,
+        directory,
+        get_token_buckets(),
+        ttl_seconds=settings.magic_link_ttl_seconds,
+        per_address_per_hour=settings.magic_link_max_per_address_per_hour,
+        link_url=settings.patient_portal_url.rstrip("/") + settings.magic_link_path if settings.patient_portal_url else None,
+    )
@@ -0,0 +1,258 @@
+# Location: app/auth/mfa.py
+
+import base64
+import hashlib
+import hmac
+import logging
+import secrets
+import struct
+import time
+from datetime import datetime, timedelta, timezone
+from functools import lru_cache
+from typing import Callable, List, Optional
+from urllib.parse import quote, urlencode
+
+from app.api.v1 import schemas
+from app.core.config import get_settings
+from app.notifications.sms import OutgoingSms, SmsDeliveryError, SmsRejected, SmsSender, get_sms_sender
+from app.ratelimit.buckets import TokenBuckets, get_token_buckets
+from app.repositories.base import ConflictError, NotFoundError
+from app.repositories.mfa import MfaEnrollmentRepository
+
+# RFC 6238 with the parameters every authenticator app supports.
+TOTP_DIGITS = 6
+TOTP_PERIOD_SECONDS = 30
+# Codes one period either side of now are accepted, for clock drift.
+TOTP_DRIFT_STEPS = 1
+
+RECOVERY_CODE_COUNT = 10
+_RECOVERY_ALPHABET = "abcdefghjkmnpqrstuvwxyz23456789"
+
+
+class MfaError(Exception):
+    """A code did not match, or was used, expired or never sent."""
+
+
+class MfaStateError(Exception):
+    """The request does not fit what the user has enrolled, e.g. confirming a factor that was never started."""
+
+
+class MfaUnavailable(Exception):
+    """Text message codes cannot be sent: SMS_PROVIDER is unset or the provider failed."""
+
+
+class MfaThrottled(Exception):
+    """Too many codes were tried or sent; another may be in `retry_after` seconds."""
+
+    def __init__(self, retry_after: int):
+        super().__init__(f"Too many attempts; retry in {retry_after}s")
+        self.retry_after = retry_after
+
+
+def totp(secret: str, step: int) -> str:
+    """The code of a base32 secret for a time step."""
+    key = base64.b32decode(secret.upper() + "=" * (-len(secret) % 8))
+    digest = hmac.new(key, struct.pack(">Q", step), hashlib.sha1).digest()
+    offset = digest[-1] & 0x0F
+    value = struct.unpack(">I", digest[offset:offset + 4])[0] & 0x7FFFFFFF
+    return str(value % 10 ** TOTP_DIGITS).zfill(TOTP_DIGITS)
+
+
+def _hash_code(uid: str, code: str) -> str:
+    return hashlib.sha256(f"{uid}:{code.strip().lower()}".encode("utf-8")).hexdigest()
+
+
+def _default_repository() -> MfaEnrollmentRepository:
+    from app.api.v1.deps import get_mfa_enrollment_repository
+    return get_mfa_enrollment_repository()
+
+
+class MfaManager:
+    """
+    Second factors of users: an authenticator app (TOTP), a phone number
+    that receives text message codes, and single-use recovery codes handed
+    out when the first factor is confirmed. A factor counts once confirmed
+    with a code. Each user may try `max_attempts` codes per 15 minutes and
+    is sent `sms_max_per_hour` texts an hour.
+    """
+
+    def __init__(
+        self,
+        buckets: TokenBuckets,
+        sms_sender: Optional[SmsSender],
+        repository_factory: Callable[[], MfaEnrollmentRepository] = _default_repository,
+        issuer: str = "MegaCare",
+        sms_code_ttl_seconds: int = 300,
+        sms_max_per_hour: int = 5,
+        max_attempts: int = 5,
+        clock: Callable[[], float] = time.time,
+    ):
+        self.buckets = buckets
+        self.sms_sender = sms_sender
+        self.repository_factory = repository_factory
+        self.issuer = issuer
+        self.sms_code_ttl_seconds = sms_code_ttl_seconds
+        self.sms_max_per_hour = sms_max_per_hour
+        self.max_attempts = max_attempts
+        self.clock = clock
+
+    def _now(self) -> datetime:
+        return datetime.fromtimestamp(self.clock(), tz=timezone.utc)
+
+    def _attempt(self, uid: str) -> None:
+        allowed, retry_after = self.buckets.take(f"mfa:attempt:{uid}", self.max_attempts, self.max_attempts / 900)
+        if not allowed:
+            logging.warning(f"Throttled second factor attempts of user {uid}")
+            raise MfaThrottled(retry_after)
+
+    def _enrollment(self, uid: str) -> schemas.MfaEnrollmentRecord:
+        record = self.repository_factory().get(uid)
+        if not record:
+            raise MfaStateError("No second factor is enrolled")
+        return record
+
+    def _matching_step(self, secret: str, code: str) -> Optional[int]:
+        current = int(self.clock()) // TOTP_PERIOD_SECONDS
+        for step in range(current - TOTP_DRIFT_STEPS, current + TOTP_DRIFT_STEPS + 1):
+            if hmac.compare_digest(totp(secret, step), code.strip()):
+                return step
+        return None
+
+    def _use_totp(self, uid: str, secret: str, code: str) -> None:
+        step = self._matching_step(secret, code)
+        if step is None:
+            raise MfaError("Authenticator code does not match")
+        try:
+            self.repository_factory().use_totp_step(uid, step)
+        except ConflictError:
+            raise MfaError("Authenticator code was already used")
+
+    def _use_sms_code(self, uid: str, code: str) -> None:
+        try:
+            self.repository_factory().use_sms_code(uid, _hash_code(uid, code), self._now())
+        except (ConflictError, NotFoundError):
+            raise MfaError("Text message code does not match or has expired")
+
+    def _send_code(self, uid: str, phone_number: str) -> None:
+        if self.sms_sender is None:
+            raise MfaUnavailable("Text message codes are not available (SMS_PROVIDER)")
+        allowed, retry_after = self.buckets.take(f"mfa:sms:{uid}", self.sms_max_per_hour, self.sms_max_per_hour / 3600)
+        if not allowed:
+            raise MfaThrottled(retry_after)
+        code = str(secrets.randbelow(10 ** TOTP_DIGITS)).zfill(TOTP_DIGITS)
+        self.repository_factory().save(uid, {
+            "smsCodeHash": _hash_code(uid, code),
+            "smsCodeExpiresAt": self._now() + timedelta(seconds=self.sms_code_ttl_seconds),
+        })
+        minutes = self.sms_code_ttl_seconds // 60
+        message = OutgoingSms(
+            notification_id=f"mfa-{secrets.token_hex(8)}",
+            to=phone_number,
+            body=f"{self.issuer}: your verification code is {code}. It expires in {minutes} minutes. Never share it.",
+        )
+        try:
+            self.sms_sender.send(message)
+        except (SmsDeliveryError, SmsRejected) as e:
+            raise MfaUnavailable(f"The code could not be texted: {e}")
+        logging.info(f"Texted a verification code to user {uid}")
+
+    def _first_recovery_codes(self, record: schemas.MfaEnrollmentRecord) -> Optional[List[str]]:
+        """New recovery codes if the user has none, e.g. on confirming their first factor."""
+        if record.recovery_code_hashes:
+            return None
+        return self.regenerate_recovery_codes(record.uid)
+
+    def begin_totp(self, uid: str, account_name: str) -> schemas.MfaTotpEnrollment:
+        """Starts enrolling an authenticator app; it counts once `confirm_totp` is given one of its codes."""
+        record = self.repository_factory().get(uid)
+        if record and record.totp_confirmed_at:
+            raise MfaStateError("An authenticator app is already enrolled")
+        secret = base64.b32encode(secrets.token_bytes(20)).decode("ascii").rstrip("=")
+        self.repository_factory().save(uid, {"totpSecret": secret, "totpConfirmedAt": None, "lastTotpStep": None})
+        label = quote(f"{self.issuer}:{account_name}")
+        query = urlencode({"secret": secret, "issuer": self.issuer, "digits": TOTP_DIGITS, "period": TOTP_PERIOD_SECONDS})
+        return schemas.MfaTotpEnrollment(secret=secret, otpauth_uri=f"otpauth://totp/{label}?{query}")
+
+    def confirm_totp(self, uid: str, code: str) -> Optional[List[str]]:
+        """Confirms the authenticator app. Returns recovery codes if it is the user's first factor."""
+        self._attempt(uid)
+        record = self._enrollment(uid)
+        if not record.totp_secret or record.totp_confirmed_at:
+            raise MfaStateError("No authenticator app is being enrolled")
+        self._use_totp(uid, record.totp_secret, code)
+        record = self.repository_factory().save(uid, {"totpConfirmedAt": self._now()})
+        logging.info(f"User {uid} enrolled an authenticator app")
+        return self._first_recovery_codes(record)
+
+    def begin_sms(self, uid: str, phone_number: str) -> None:
+        """Starts enrolling a phone number and texts it a code for `confirm_sms`."""
+        record = self.repository_factory().get(uid)
+        if record and record.phone_confirmed_at:
+            raise MfaStateError("A phone number is already enrolled")
+        self.repository_factory().save(uid, {"phoneNumber": phone_number, "phoneConfirmedAt": None})
+        self._send_code(uid, phone_number)
+
+    def confirm_sms(self, uid: str, code: str) -> Optional[List[str]]:
+        """Confirms the phone number. Returns recovery codes if it is the user's first factor."""
+        self._attempt(uid)
+        record = self._enrollment(uid)
+        if not record.phone_number or record.phone_confirmed_at:
+            raise MfaStateError("No phone number is being enrolled")
+        self._use_sms_code(uid, code)
+        record = self.repository_factory().save(uid, {"phoneConfirmedAt": self._now()})
+        logging.info(f"User {uid} enrolled a phone number")
+        return self._first_recovery_codes(record)
+
+    def challenge(self, uid: str) -> None:
+        """Texts a code to the user's enrolled phone number, for `verify`."""
+        record = self._enrollment(uid)
+        if not record.phone_confirmed_at:
+            raise MfaStateError("No phone number is enrolled")
+        self._send_code(uid, record.phone_number)
+
+    def verify(self, uid: str, method: str, code: str) -> None:
+        """Checks a code of one of the user's confirmed factors. Raises MfaError, MfaStateError or MfaThrottled."""
+        self._attempt(uid)
+        record = self._enrollment(uid)
+        if method == "totp":
+            if not record.totp_confirmed_at:
+                raise MfaStateError("No authenticator app is enrolled")
+            self._use_totp(uid, record.totp_secret, code)
+        elif method == "sms":
+            if not record.phone_confirmed_at:
+                raise MfaStateError("No phone number is enrolled")
+            self._use_sms_code(uid, code)
+        else:
+            try:
+                record = self.repository_factory().use_recovery_code(uid, _hash_code(uid, code))
+            except ConflictError:
+                raise MfaError("Unknown or used recovery code")
+            logging.warning(f"User {uid} used a recovery code; {len(record.recovery_code_hashes)} left")
+        logging.info(f"User {uid} verified a second factor ({method})")
+
+    def regenerate_recovery_codes(self, uid: str) -> List[str]:
+        """Replaces the user's recovery codes with new ones; the old ones stop working."""
+        codes = [
+            "-".join("".join(secrets.choice(_RECOVERY_ALPHABET) for _ in range(4)) for _ in range(3))
+            for _ in range(RECOVERY_CODE_COUNT)
+        ]
+        self.repository_factory().save(uid, {"recoveryCodeHashes": [_hash_code(uid, code) for code in codes]})
+        return codes
+
+    def reset(self, uid: str) -> None:
+        """Removes every factor of the user, who then enrolls again."""
+        self.repository_factory().delete(uid)
+        logging.info(f"Removed the second factors of user {uid}")
+
+
+@lru_cache
+def get_mfa_manager() -> MfaManager:
+    settings = get_settings()
+    return MfaManager(
+        get_token_buckets(),
+        get_sms_sender(),
+        issuer=settings.mfa_totp_issuer,
+        sms_code_ttl_seconds=settings.mfa_sms_code_ttl_seconds,
+        sms_max_per_hour=settings.mfa_sms_max_per_hour,
+        max_attempts=settings.mfa_max_attempts,
+    )
@@ -0,0 +1,317 @@
+# Location: app/auth/sessions.py
+
+import hashlib
+import hmac
+import logging
+import re
+import secrets
+import threading
+import time
+from datetime import datetime, timedelta, timezone
+from functools import lru_cache
+from typing import Any, Callable, Dict, Optional, Tuple
+
+import jwt
+
+from app.api.v1 import schemas
+from app.auth.verifier import LEEWAY_SECONDS, InvalidTokenError, TokenVerifier, _roles
+from app.authz.mfa import MFA_CLAIM
+from app.authz.roles import PATIENT_ID_CLAIM
+from app.core.config import get_settings
+from app.repositories.base import ConflictError
+from app.repositories.sessions import SessionRepository
+from app.tenancy.context import ORGANIZATION_ID_CLAIM
+
+# Session access tokens are HS256 JWTs this API issues to itself: `iss` and
+# `aud` are both SESSION_ISSUER and `sid` names the session.
+SESSION_ISSUER = "megacare-api"
+SESSION_ALGORITHM = "HS256"
+
+# The identity claims copied from the ID token a session starts with into
+# each of its access tokens.
+SESSION_CLAIMS = ("roles", ORGANIZATION_ID_CLAIM, PATIENT_ID_CLAIM, "email", "email_verified", "name")
+
+# Refresh tokens look like `mcr_<32 hex session ID>_<secret>`, the same
+# scheme as API keys (see app/auth/api_keys.py): the ID locates the session,
+# which keeps only a SHA-256 hash of the secret.
+REFRESH_TOKEN_PREFIX = "mcr_"
+_REFRESH_TOKEN_RE = re.compile(r"^mcr_([0-9a-f]{32})_([A-Za-z0-9_\-]{43})$")
+
+
+class SessionError(Exception):
+    """The refresh token is malformed, unknown, expired or revoked."""
+
+
+def hash_secret(secret: str) -> str:
+    return hashlib.sha256(secret.encode("utf-8")).hexdigest()
+
+
+def new_session_id() -> str:
+    return secrets.token_hex(16)
+
+
+def format_refresh_token(session_id: str, secret: str) -> str:
+    return f"{REFRESH_TOKEN_PREFIX}{session_id}_{secret}"
+
+
+def parse_refresh_token(raw: str) -> Optional[Tuple[str, str]]:
+    """Splits a presented refresh token into (session ID, secret), or None if it is not shaped like one."""
+    match = _REFRESH_TOKEN_RE.match(raw.strip())
+    return (match.group(1), match.group(2)) if match else None
+
+
+def firebase_credentials_valid_after(uid: str) -> Optional[datetime]:
+    """
+    When Firebase last invalidated the user's sign-ins: it moves
+    tokensValidAfterTime forward when their password changes or their
+    refresh tokens are revoked. Disabled and deleted accounts are
+    invalidated now.
+    """
+    from firebase_admin import auth
+
+    try:
+        user = auth.get_user(uid)
+    except auth.UserNotFoundError:
+        return datetime.now(timezone.utc)
+    if user.disabled:
+        return datetime.now(timezone.utc)
+    if not user.tokens_valid_after_timestamp:
+        return None
+    return datetime.fromtimestamp(user.tokens_valid_after_timestamp / 1000, tz=timezone.utc)
+
+
+def _default_repository() -> SessionRepository:
+    from app.api.v1.deps import get_session_repository
+    return get_session_repository()
+
+
+class SessionManager:
+    """
+    Signs users of the patient portal in for longer than an ID token lasts.
+    A session starts from a verified ID token and hands out a short-lived
+    access token with a refresh token. Each refresh token works once: using
+    it returns a new pair, and presenting the one it replaced again means
+    it was copied, so the session is revoked. Sessions end when not
+    refreshed for `idle_seconds`, `max_age_seconds` after sign-in, when
+    revoked, or when the identity provider reports the user's credentials
+    changed since they signed in (`credentials_valid_after`).
+    """
+
+    def __init__(
+        self,
+        identity_verifier: TokenVerifier,
+        signing_key: str,
+        repository_factory: Callable[[], SessionRepository] = _default_repository,
+        access_token_ttl_seconds: int = 900,
+        idle_seconds: int = 14 * 86400,
+        max_age_seconds: int = 90 * 86400,
+        credentials_valid_after: Callable[[str], Optional[datetime]] = lambda uid: None,
+        clock: Callable[[], datetime] = lambda: datetime.now(timezone.utc),
+    ):
+        self.identity_verifier = identity_verifier
+        self.signing_key = signing_key
+        self.repository_factory = repository_factory
+        self.access_token_ttl_seconds = access_token_ttl_seconds
+        self.idle_seconds = idle_seconds
+        self.max_age_seconds = max_age_seconds
+        self.credentials_valid_after = credentials_valid_after
+        self.clock = clock
+
+    def _expiry(self, authenticated_at: datetime, now: datetime) -> datetime:
+        return min(now + timedelta(seconds=self.idle_seconds), authenticated_at + timedelta(seconds=self.max_age_seconds))
+
+    def access_token(self, record: schemas.SessionRecord, now: datetime) -> str:
+        """An access token of the session, valid for `access_token_ttl_seconds` from `now`."""
+        claims = {
+            **record.claims,
+            "iss": SESSION_ISSUER,
+            "aud": SESSION_ISSUER,
+            "sub": record.uid,
+            "sid": record.session_id,
+            "iat": int(now.timestamp()),
+            "exp": int(now.timestamp()) + self.access_token_ttl_seconds,
+        }
+        if record.mfa_verified_at:
+            claims[MFA_CLAIM] = int(record.mfa_verified_at.timestamp())
+        return jwt.encode(claims, self.signing_key, algorithm=SESSION_ALGORITHM)
+
+    def _tokens(self, record: schemas.SessionRecord, secret: str, now: datetime) -> schemas.SessionTokens:
+        return schemas.SessionTokens(
+            access_token=self.access_token(record, now),
+            expires_in=self.access_token_ttl_seconds,
+            refresh_token=format_refresh_token(record.session_id, secret),
+            session=schemas.Session.model_validate(record.model_dump(by_alias=True)),
+        )
+
+    def start(
+        self, id_token: str, device_label: Optional[str] = None, user_agent: Optional[str] = None, source_ip: Optional[str] = None,
+    ) -> Tuple[Dict[str, Any], schemas.SessionTokens]:
+        """
+        Starts a session for the user of `id_token`. Returns their verified
+        claims and the session's first tokens. Raises InvalidTokenError.
+        """
+        identity = self.identity_verifier.verify(id_token)
+        return identity, self.start_for(identity, device_label=device_label, user_agent=user_agent, source_ip=source_ip)
+
+    def start_for(
+        self, identity: Dict[str, Any], device_label: Optional[str] = None, user_agent: Optional[str] = None, source_ip: Optional[str] = None,
+    ) -> schemas.SessionTokens:
+        """
+        Starts a session for a user signed in some other way (e.g. a magic
+        link), given the claims their ID token would carry, `uid` included.
+        """
+        now = self.clock()
+        auth_time = identity.get("auth_time")
+        authenticated_at = datetime.fromtimestamp(auth_time, tz=timezone.utc) if isinstance(auth_time, (int, float)) else now
+        secret = secrets.token_urlsafe(32)
+        record = self.repository_factory().create(
+            new_session_id(),
+            identity["uid"],
+            {claim: identity[claim] for claim in SESSION_CLAIMS if claim in identity},
+            hash_secret(secret),
+            authenticated_at,
+            self._expiry(authenticated_at, now),
+            device_label=device_label,
+            user_agent=user_agent,
+            source_ip=source_ip,
+        )
+        logging.info(f"Started session {record.session_id} for user {record.uid}")
+        return self._tokens(record, secret, now)
+
+    def _presented(self, raw: str) -> Tuple[schemas.SessionRecord, str]:
+        parsed = parse_refresh_token(raw)
+        if not parsed:
+            raise SessionError("Malformed refresh token")
+        session_id, secret = parsed
+        record = self.repository_factory().get(session_id)
+        if not record:
+            raise SessionError("Unknown refresh token")
+        return record, hash_secret(secret)
+
+    def refresh(self, raw: str) -> schemas.SessionTokens:
+        """Exchanges a refresh token for new tokens. Raises SessionError."""
+        record, presented = self._presented(raw)
+        repo = self.repository_factory()
+        if record.status != "active":
+            raise SessionError("Session has ended")
+        if record.previous_refresh_hash and hmac.compare_digest(record.previous_refresh_hash, presented):
+            repo.revoke(record.session_id, "refresh-token-reused")
+            logging.warning(f"Revoked session {record.session_id} of user {record.uid}: a replaced refresh token was used again")
+            raise SessionError("Refresh token was already used")
+        if not hmac.compare_digest(record.refresh_hash, presented):
+            raise SessionError("Unknown refresh token")
+        now = self.clock()
+        if record.expires_at <= now:
+            repo.revoke(record.session_id, "expired")
+            raise SessionError("Session has expired")
+        valid_after = self.credentials_valid_after(record.uid)
+        if valid_after and valid_after > record.authenticated_at:
+            repo.revoke(record.session_id, "credentials-changed")
+            logging.info(f"Revoked session {record.session_id} of user {record.uid}: credentials changed since sign-in")
+            raise SessionError("Credentials have changed since sign-in")
+
+        secret = secrets.token_urlsafe(32)
+        try:
+            record = repo.rotate(record.session_id, record.refresh_hash, hash_secret(secret), self._expiry(record.authenticated_at, now))
+        except ConflictError:
+            # Refreshed concurrently with the same token; one of the two was a copy.
+            repo.revoke(record.session_id, "refresh-token-reused")
+            raise SessionError("Refresh token was already used")
+        return self._tokens(record, secret, now)
+
+    def end(self, raw: str) -> schemas.SessionRecord:
+        """Signs out the session of a refresh token. Raises SessionError."""
+        record, presented = self._presented(raw)
+        if not hmac.compare_digest(record.refresh_hash, presented):
+            raise SessionError("Unknown refresh token")
+        return self.repository_factory().revoke(record.session_id, "logout")
+
+    def revoke_all(self, uid: str, reason: str, keep: Optional[str] = None) -> int:
+        """Revokes the user's active sessions but `keep`. Returns how many were revoked."""
+        repo = self.repository_factory()
+        revoked = 0
+        for record in repo.list_active(uid, limit=1000):
+            if record.session_id != keep:
+                repo.revoke(record.session_id, reason)
+                revoked += 1
+        if revoked:
+            logging.info(f"Revoked {revoked} session(s) of user {uid}: {reason}")
+        return revoked
+
+
+class SessionTokenVerifier(TokenVerifier):
+    """
+    Verifies the access tokens of this API's sessions and hands any other
+    token to `fallback`, the AUTH_PROVIDER verifier. Each session's status
+    is reused for `cache_seconds`, so a revoked session's access tokens stop
+    working within that time rather than when they expire.
+    """
+
+    def __init__(
+        self,
+        fallback: TokenVerifier,
+        signing_key: str,
+        repository_factory: Callable[[], SessionRepository] = _default_repository,
+        cache_seconds: float = 30.0,
+        clock: Callable[[], float] = time.monotonic,
+    ):
+        self.fallback = fallback
+        self.signing_key = signing_key
+        self.repository_factory = repository_factory
+        self.cache_seconds = cache_seconds
+        self.clock = clock
+        self._active: Dict[str, Tuple[float, bool]] = {}
+        self._lock = threading.Lock()
+
+    def _session_active(self, session_id: str) -> bool:
+        now = self.clock()
+        with self._lock:
+            cached = self._active.get(session_id)
+        if cached and now - cached[0] < self.cache_seconds:
+            return cached[1]
+        record = self.repository_factory().get(session_id)
+        active = bool(record and record.status == "active")
+        with self._lock:
+            self._active[session_id] = (now, active)
+        return active
+
+    def verify(self, token: str) -> Dict[str, Any]:
+        try:
+            issuer = jwt.decode(token, options={"verify_signature": False}).get("iss")
+        except jwt.PyJWTError as e:
+            raise InvalidTokenError(str(e))
+        if issuer != SESSION_ISSUER:
+            return self.fallback.verify(token)
+        try:
+            claims = jwt.decode(
+                token,
+                self.signing_key,
+                algorithms=[SESSION_ALGORITHM],
+                audience=SESSION_ISSUER,
+                issuer=SESSION_ISSUER,
+                leeway=LEEWAY_SECONDS,
+                options={"require": ["exp", "iat", "sub", "sid"]},
+            )
+        except jwt.PyJWTError as e:
+            raise InvalidTokenError(str(e))
+        if not self._session_active(claims["sid"]):
+            raise InvalidTokenError("Session has ended")
+        return {**claims, "uid": claims["sub"], "roles": _roles(claims.get("roles")), "authMethod": "session"}
+
+
+@lru_cache
+def get_session_manager() -> Optional[SessionManager]:
+    """The process-wide session manager, or None when SESSION_SIGNING_KEY is unset."""
+    from app.auth.verifier import get_identity_verifier
+
+    settings = get_settings()
+    if not settings.session_signing_key:
+        return None
+    return SessionManager(
+        get_identity_verifier(),
+        settings.session_signing_key,
+        access_token_ttl_seconds=settings.session_access_token_ttl_seconds,
+        idle_seconds=settings.session_idle_timeout_seconds,
+        max_age_seconds=settings.session_max_age_seconds,
+        credentials_valid_after=firebase_credentials_valid_after if settings.auth_provider == "firebase" else (lambda uid: None),
+    )
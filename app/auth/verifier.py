@@ -0,0 +1,110 @@
+# Location: app/auth/verifier.py
+
+from abc import ABC, abstractmethod
+from functools import lru_cache
+from typing import Any, Dict, List, Sequence
+
+import jwt
+
+from app.auth.jwks import JwksCache, discover_jwks_url
+from app.core.config import get_settings
+
+# Allowed clock skew between the issuer and this service.
+LEEWAY_SECONDS = 30
+SIGNING_ALGORITHMS = ("RS256", "ES256")
+
+
+class InvalidTokenError(Exception):
+    """The bearer token is malformed, expired, or not issued for this API."""
+
+
+def _roles(value: Any) -> List[str]:
+    if isinstance(value, list):
+        return [str(role) for role in value]
+    if isinstance(value, str):
+        return value.split()
+    return []
+
+
+class TokenVerifier(ABC):
+    """
+    Verifies bearer tokens. Returned claims always include `uid` (the
+    token subject) and `roles`, so handlers do not depend on the issuer.
+    """
+
+    @abstractmethod
+    def verify(self, token: str) -> Dict[str, Any]:
+        """Returns the token's claims. Raises InvalidTokenError."""
+
+
+class FirebaseTokenVerifier(TokenVerifier):
+    """Firebase Auth ID tokens, verified by the Admin SDK; `roles` is a custom claim."""
+
+    def verify(self, token: str) -> Dict[str, Any]:
+        from firebase_admin import auth
+
+        try:
+            claims = auth.verify_id_token(token)
+        except auth.InvalidIdTokenError as e:
+            raise InvalidTokenError(f"Invalid Firebase ID token: {e}")
+        return {**claims, "roles": _roles(claims.get("roles"))}
+
+
+class OidcTokenVerifier(TokenVerifier):
+    """
+    JWTs of any OpenID Connect issuer, e.g. Identity Platform
+    (https://securetoken.google.com/<project>) or Auth0. The signature is
+    checked against the issuer's published keys, and `iss`, `aud` and
+    `exp` must match.
+    """
+
+    def __init__(self, issuer: str, audience: str, keys: JwksCache, roles_claim: str = "roles", algorithms: Sequence[str] = SIGNING_ALGORITHMS):
+        self.issuer = issuer
+        self.audience = audience
+        self.keys = keys
+        self.roles_claim = roles_claim
+        self.algorithms = list(algorithms)
+
+    def verify(self, token: str) -> Dict[str, Any]:
+        try:
+            header = jwt.get_unverified_header(token)
+            claims = jwt.decode(
+                token,
+                self.keys.get(header.get("kid")),
+                algorithms=self.algorithms,
+                audience=self.audience,
+                issuer=self.issuer,
+                leeway=LEEWAY_SECONDS,
+                options={"require": ["exp", "iat", "sub"]},
+            )
+        except (jwt.PyJWTError, LookupError) as e:
+            raise InvalidTokenError(str(e))
+        return {**claims, "uid": claims["sub"], "roles": _roles(claims.get(self.roles_claim))}
+
+
+@lru_cache
+def get_identity_verifier() -> TokenVerifier:
+    """The process-wide verifier of AUTH_PROVIDER's ID tokens; OIDC keys are cached across requests."""
+    settings = get_settings()
+    if settings.auth_provider == "oidc":
+        jwks_url = settings.oidc_jwks_url
+        keys = JwksCache(
+            (lambda: jwks_url) if jwks_url else (lambda: discover_jwks_url(settings.oidc_issuer)),
+            ttl_seconds=settings.oidc_jwks_cache_seconds,
+        )
+        return OidcTokenVerifier(settings.oidc_issuer, settings.oidc_audience, keys, roles_claim=settings.oidc_roles_claim)
+    return FirebaseTokenVerifier()
+
+
+@lru_cache
+def get_token_verifier() -> TokenVerifier:
+    """
+    The process-wide bearer token verifier: ID tokens of AUTH_PROVIDER and,
+    with SESSION_SIGNING_KEY set, access tokens of the patient portal's
+    sessions (see app/auth/sessions.py).
+    """
+    settings = get_settings()
+    if not settings.session_signing_key:
+        return get_identity_verifier()
+    from app.auth.sessions import SessionTokenVerifier
+    return SessionTokenVerifier(get_identity_verifier(), settings.session_signing_key, cache_seconds=settings.session_cache_seconds)
@@ -0,0 +1,90 @@
+# Location: app/authz/custom_roles.py
+
+import logging
+import threading
+import time
+from functools import lru_cache
+from typing import Callable, Dict, FrozenSet, Iterable, List, Optional, Tuple
+
+from app.api.v1 import schemas
+from app.authz.roles import DENIED
+from app.core.config import get_settings
+from app.repositories.custom_roles import CustomRoleRepository
+from app.tenancy.context import acting_for, current_tenant
+
+
+def _default_repository() -> CustomRoleRepository:
+    from app.api.v1.deps import get_custom_role_repository
+    return get_custom_role_repository()
+
+
+def role_permissions(role: schemas.CustomRole) -> FrozenSet[str]:
+    """What the role grants, with its denials prefixed as app/authz/roles.py reads them."""
+    return frozenset(role.permissions) | frozenset(DENIED + p for p in role.denied)
+
+
+class CustomRoleCache:
+    """
+    The permissions of each organization's custom roles, read in one query
+    and kept for `ttl_seconds`, so authorizing a request does not read the
+    store. Changes made through this worker apply at once (`invalidate`);
+    on other workers within `ttl_seconds`.
+    """
+
+    def __init__(
+        self,
+        repository_factory: Callable[[], CustomRoleRepository] = _default_repository,
+        ttl_seconds: float = 30.0,
+        clock: Callable[[], float] = time.monotonic,
+    ):
+        self.repository_factory = repository_factory
+        self.ttl_seconds = ttl_seconds
+        self.clock = clock
+        self._entries: Dict[Optional[str], Tuple[float, Dict[str, FrozenSet[str]]]] = {}
+        self._lock = threading.Lock()
+
+    def _load(self, organization_id: Optional[str]) -> Dict[str, FrozenSet[str]]:
+        with acting_for(organization_id):
+            roles: List[schemas.CustomRole] = self.repository_factory().list()
+        # Unscoped, the query also returns the roles of every organization;
+        # only those stored without one are meant.
+        prefix = f"{organization_id or '-'}:"
+        return {role.name: role_permissions(role) for role in roles if role.role_id.startswith(prefix)}
+
+    def roles(self, organization_id: Optional[str]) -> Dict[str, FrozenSet[str]]:
+        """The organization's roles by name. Empty, and not kept, if the store cannot be read."""
+        now = self.clock()
+        with self._lock:
+            entry = self._entries.get(organization_id)
+        if entry and now - entry[0] < self.ttl_seconds:
+            return entry[1]
+        try:
+            roles = self._load(organization_id)
+        except Exception as e:
+            logging.warning(f"Could not read the custom roles of organization {organization_id or '-'}; granting none: {e}")
+            return {}
+        with self._lock:
+            self._entries[organization_id] = (now, roles)
+        return roles
+
+    def invalidate(self, organization_id: Optional[str]) -> None:
+        with self._lock:
+            self._entries.pop(organization_id, None)
+
+    def permissions(self, organization_id: Optional[str], names: Iterable[str]) -> FrozenSet[str]:
+        roles = self.roles(organization_id)
+        return frozenset().union(*(roles.get(name, frozenset()) for name in names))
+
+
+@lru_cache
+def get_custom_role_cache() -> CustomRoleCache:
+    return CustomRoleCache(ttl_seconds=get_settings().custom_role_cache_seconds)
+
+
+def custom_role_permissions(names: Iterable[str]) -> FrozenSet[str]:
+    """
+    The permissions the named custom roles of the organization the request
+    acts for grant, denials included. Roles are defined per organization,
+    like the records they reach.
+    """
+    return get_custom_role_cache().permissions(current_tenant(), names)
@@ -0,0 +1,78 @@
+# Location: app/authz/mfa.py
+
+import logging
+import time
+from typing import Any, Dict, Optional
+
+from fastapi import HTTPException, status
+
+from app.core.config import get_settings
+
+# When the caller last proved a second factor, in epoch seconds. Session
+# access tokens carry it once the user verifies one at /auth/mfa/verify.
+MFA_CLAIM = "mfaAt"
+
+# The RFC 9470 error clients recognise as "authenticate again, harder".
+STEP_UP_ERROR = "insufficient_user_authentication"
+
+
+def mfa_verified_at(principal: Dict[str, Any]) -> Optional[int]:
+    """
+    When the caller's credentials last proved a second factor, or None.
+    Besides session tokens, Firebase ID tokens of users signed in with
+    Firebase MFA and OIDC tokens whose `amr` includes "mfa" count, as of
+    their `auth_time`.
+    """
+    value = principal.get(MFA_CLAIM)
+    if isinstance(value, (int, float)):
+        return int(value)
+    firebase = principal.get("firebase")
+    amr = principal.get("amr")
+    proved = (isinstance(firebase, dict) and bool(firebase.get("sign_in_second_factor"))) or (isinstance(amr, list) and "mfa" in amr)
+    auth_time = principal.get("auth_time")
+    return int(auth_time) if proved and isinstance(auth_time, (int, float)) else None
+
+
+def mfa_required(principal: Dict[str, Any]) -> bool:
+    """
+    Whether the caller holds one of MFA_REQUIRED_ROLES. Nobody is held to
+    it without SESSION_SIGNING_KEY, since sessions are what carry a
+    verified factor, and API keys never are.
+    """
+    settings = get_settings()
+    if principal.get("authMethod") == "api_key" or not settings.session_signing_key:
+        return False
+    roles = principal.get("roles")
+    roles = {str(role) for role in roles} if isinstance(roles, list) else set()
+    return bool(roles & set(settings.mfa_required_role_list))
+
+
+def _refusal(detail: str) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_403_FORBIDDEN,
+        detail=detail,
+        headers={"WWW-Authenticate": f'Bearer error="{STEP_UP_ERROR}", error_description="{detail}"'},
+    )
+
+
+def check_mfa(principal: Dict[str, Any]) -> None:
+    """Raises 403 if the caller's roles require a second factor they have not proved."""
+    if mfa_required(principal) and mfa_verified_at(principal) is None:
+        logging.info(f"User {principal.get('uid')} refused: no second factor proved")
+        raise _refusal("Multi-factor authentication is required for your role")
+
+
+def check_step_up(principal: Dict[str, Any], what: str, everyone: bool = False) -> None:
+    """
+    Raises 403 unless the caller proved a second factor within
+    MFA_STEP_UP_MAX_AGE_SECONDS, for operations that warrant asking again
+    however the caller signed in. Applies to the users MFA is required of,
+    or with `everyone` to any user, e.g. one changing their own factors.
+    """
+    if not everyone and not mfa_required(principal):
+        return
+    verified_at = mfa_verified_at(principal)
+    max_age = get_settings().mfa_step_up_max_age_seconds
+    if verified_at is None or time.time() - verified_at > max_age:
+        logging.info(f"User {principal.get('uid')} refused {what}: second factor not proved in the last {max_age}s")
+        raise _refusal(f"Verify a second factor again to {what}")
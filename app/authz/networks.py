@@ -0,0 +1,56 @@
+# Location: app/authz/networks.py
+
+import ipaddress
+from typing import Iterable, List, Optional, Sequence, Union
+
+from starlette.datastructures import Headers
+from starlette.types import Scope
+
+Network = Union[ipaddress.IPv4Network, ipaddress.IPv6Network]
+
+
+def parse_ranges(values: Iterable[str]) -> List[Network]:
+    """
+    Parses CIDR ranges such as "203.0.113.0/24" or "2001:db8::/32"; a bare
+    address is a range of one. Raises ValueError for anything else,
+    including ranges with host bits set, which are usually typos.
+    """
+    ranges = []
+    for value in values:
+        try:
+            ranges.append(ipaddress.ip_network(value.strip()))
+        except ValueError as e:
+            raise ValueError(f"'{value}' is not a CIDR range: {e}")
+    return ranges
+
+
+def client_address(scope: Scope, trusted_proxy_hops: int = 1) -> Optional[str]:
+    """
+    The address of the client that connected to the outermost trusted
+    proxy. Each proxy appends the address it was connected from to
+    X-Forwarded-For, and anything before those entries was sent by the
+    client and cannot be trusted. Cloud Run's front end is one hop; an
+    external HTTPS load balancer in front of it is a second.
+    """
+    forwarded = Headers(scope=scope).get("x-forwarded-for")
+    if forwarded and trusted_proxy_hops > 0:
+        hops = [hop.strip() for hop in forwarded.split(",") if hop.strip()]
+        if len(hops) >= trusted_proxy_hops:
+            return hops[-trusted_proxy_hops]
+        return None
+    client = scope.get("client")
+    return client[0] if client else None
+
+
+def address_allowed(address: Optional[str], ranges: Sequence[Network]) -> bool:
+    """True if `address` lies in one of `ranges`; unknown and malformed addresses are in none."""
+    if not address:
+        return False
+    try:
+        ip = ipaddress.ip_address(address)
+    except ValueError:
+        return False
+    # An IPv4 client reaching a dual-stack listener shows as ::ffff:a.b.c.d.
+    if isinstance(ip, ipaddress.IPv6Address) and ip.ipv4_mapped:
+        ip = ip.ipv4_mapped
+    return any(ip.version == network.version and ip in network for network in ranges)
@@ -0,0 +1,84 @@
+# Location: app/authz/ownership.py
+
+from typing import Callable, Dict, Optional
+
+from app.api.v1.deps import (
+    get_appointment_repository,
+    get_care_plan_repository,
+    get_care_team_repository,
+    get_claim_repository,
+    get_encounter_repository,
+    get_invoice_repository,
+    get_observation_repository,
+    get_telehealth_session_repository,
+)
+
+# Returns the patient a record belongs to, or None if there is no such record.
+OwnerLookup = Callable[[str], Optional[str]]
+
+_LOOKUPS: Dict[str, OwnerLookup] = {}
+
+
+def owner_lookup(path_param: str) -> Callable[[OwnerLookup], OwnerLookup]:
+    """
+    Registers how to find the patient of the record a path parameter names,
+    so routes such as `/appointments/{appointmentId}` can be limited to the
+    caller's own records without a check in each handler:
+
+        @owner_lookup("appointmentId")
+        def appointment_owner(appointment_id: str) -> Optional[str]: ...
+    """
+    def register(lookup: OwnerLookup) -> OwnerLookup:
+        if path_param in _LOOKUPS:
+            raise ValueError(f"An owner lookup for '{path_param}' is already registered.")
+        _LOOKUPS[path_param] = lookup
+        return lookup
+    return register
+
+
+def get_owner_lookup(path_param: str) -> Optional[OwnerLookup]:
+    return _LOOKUPS.get(path_param)
+
+
+def _patient_of(record) -> Optional[str]:
+    return record.patient_id if record else None
+
+
+@owner_lookup("appointmentId")
+def appointment_owner(appointment_id: str) -> Optional[str]:
+    return _patient_of(get_appointment_repository().get(appointment_id))
+
+
+@owner_lookup("encounterId")
+def encounter_owner(encounter_id: str) -> Optional[str]:
+    return _patient_of(get_encounter_repository().get(encounter_id))
+
+
+@owner_lookup("carePlanId")
+def care_plan_owner(care_plan_id: str) -> Optional[str]:
+    return _patient_of(get_care_plan_repository().get(care_plan_id))
+
+
+@owner_lookup("careTeamId")
+def care_team_owner(care_team_id: str) -> Optional[str]:
+    return _patient_of(get_care_team_repository().get(care_team_id))
+
+
+@owner_lookup("observationId")
+def observation_owner(observation_id: str) -> Optional[str]:
+    return _patient_of(get_observation_repository().get(observation_id))
+
+
+@owner_lookup("telehealthSessionId")
+def telehealth_session_owner(session_id: str) -> Optional[str]:
+    return _patient_of(get_telehealth_session_repository().get(session_id))
+
+
+@owner_lookup("claimId")
+def claim_owner(claim_id: str) -> Optional[str]:
+    return _patient_of(get_claim_repository().get(claim_id))
+
+
+@owner_lookup("invoiceId")
+def invoice_owner(invoice_id: str) -> Optional[str]:
+    return _patient_of(get_invoice_repository().get(invoice_id))
@@ -0,0 +1,172 @@
+# Location: app/authz/policy.py
+
+import logging
+from typing import Any, Awaitable, Callable, Dict, Optional
+
+from fastapi import Depends, HTTPException, Request, status
+from starlette.concurrency import run_in_threadpool
+
+from app.audit.events import OPERATION_SEGMENTS
+from app.authz.mfa import check_mfa, check_step_up
+from app.authz.ownership import get_owner_lookup
+from app.authz.roles import PATIENT_ID_CLAIM, grants, has_permission, permission
+from app.dependencies.auth import get_current_user
+from app.middleware.metrics import route_template
+
+# Returned by `target_patient` for a path naming a record that does not
+# exist; the handler answers 404 as it would for anyone.
+NO_SUCH_RECORD = object()
+
+
+async def json_body(request: Request) -> Any:
+    """
+    The parsed JSON body of a write request, or None. A body without a
+    content type is parsed too, as FastAPI parses it for the handler.
+    """
+    content_type = request.headers.get("content-type")
+    if request.method in ("GET", "HEAD") or (content_type and "json" not in content_type):
+        return None
+    try:
+        return await request.json()
+    except ValueError:
+        return None
+
+
+async def path_patient(request: Request) -> Any:
+    """
+    The patient named by a `patientId` path parameter, or the owner of the
+    first record named in the path (see `owner_lookup`). None if the path
+    names neither.
+    """
+    path_params = request.path_params
+    if "patientId" in path_params:
+        return path_params["patientId"]
+    for name, value in path_params.items():
+        lookup = get_owner_lookup(name)
+        if lookup:
+            owner = await run_in_threadpool(lookup, value)
+            return owner if owner is not None else NO_SUCH_RECORD
+    return None
+
+
+async def target_patient(request: Request) -> Any:
+    """
+    Works out whose record a request touches: from the path (see
+    `path_patient`), else a `patientId` JSON body field or query parameter.
+    The body field is read under its alias and its field name, `patient_id`,
+    as write schemas accept both. Returns None if the request names no
+    patient. A request naming different patients in these places is refused
+    with 400, as the handler would act on the body's.
+    """
+    patient_id = await path_patient(request)
+    if patient_id is not None:
+        return patient_id
+    body = await json_body(request)
+    named = [body.get(key) for key in ("patientId", "patient_id")] if isinstance(body, dict) else []
+    named.append(request.query_params.get("patientId"))
+    named = [value for value in named if value]
+    if len(set(map(str, named))) > 1:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="The request names different patients in its body and query")
+    return named[0] if named else None
+
+
+def subresource(route: str, resource: str) -> Optional[str]:
+    """
+    The kind of record under `resource` a route template reaches: the
+    segment after the resource's ID, e.g. "lab-results" for
+    /api/v1/patients/{patientId}/lab-results/{labResultId}. None for the
+    resource itself and for operations on it, such as .../{encounterId}/status.
+    """
+    segments = [segment for segment in route.split("/") if segment]
+    if resource not in segments:
+        return None
+    index = segments.index(resource)
+    if index + 2 >= len(segments) or not segments[index + 1].startswith("{"):
+        return None
+    segment = segments[index + 2]
+    return None if segment.startswith("{") or segment in OPERATION_SEGMENTS else segment
+
+
+async def check_permission(principal: Dict, needed: str, locate: Callable[[], Awaitable[Any]], what: str) -> None:
+    """
+    Raises 403 unless the caller's roles (or API key scopes) grant `needed`.
+    For a permission that extends only to the caller's own records, the
+    patient `locate` finds must be the one in the caller's `patientId`
+    claim. `what` names the access in the log, e.g. "GET /api/v1/patients/123".
+    Callers whose roles require a second factor must have proved one (see
+    app/authz/mfa.py).
+    """
+    check_mfa(principal)
+    full, own = grants(principal)
+    if has_permission(full, needed):
+        return
+    if has_permission(own, needed):
+        patient_id = await locate()
+        if patient_id is NO_SUCH_RECORD or (patient_id and patient_id == principal.get(PATIENT_ID_CLAIM)):
+            return
+        logging.info(f"User {principal.get('uid')} denied {what}: not their own record")
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="You may only access your own records")
+    raise HTTPException(
+        status_code=status.HTTP_403_FORBIDDEN,
+        detail="You do not have permission to access this resource",
+    )
+
+
+async def check_role_access(
+    resource: str,
+    request: Request,
+    principal: Dict,
+    locate: Callable[[Request], Awaitable[Any]] = target_patient,
+    needed: Optional[str] = None,
+) -> None:
+    """
+    Applies `check_permission` to the permission the request needs on
+    `resource`, finding whose record it touches with `locate`. Routes to a
+    kind of record under the resource need the narrower permission, e.g.
+    `patients.lab-results:read`, which `patients:read` covers. `needed`
+    overrides the permission derived from the route and request method.
+    """
+    kind = subresource(route_template(request.scope), resource)
+    await check_permission(
+        principal,
+        needed or permission(f"{resource}.{kind}" if kind else resource, request.method),
+        lambda: locate(request),
+        f"{request.method} {request.url.path}",
+    )
+
+
+def authorize(resource: str) -> Callable[..., Dict]:
+    """
+    Returns a dependency applying `check_role_access` to `resource`. Attach
+    it to a group of routes in the router rather than to each handler:
+
+        api_router.include_router(appointments.router, prefix="/appointments",
+                                  dependencies=[Depends(authorize("appointments"))])
+    """
+    async def dependency(request: Request, current_user: Dict = Depends(get_current_user)) -> Dict:
+        await check_role_access(resource, request, current_user)
+        return current_user
+    return dependency
+
+
+def require_mfa(current_user: Dict = Depends(get_current_user)) -> Dict:
+    """
+    Dependency applying `check_mfa` to routes that are not behind
+    `authorize`, such as the /clinician self-service routes.
+    """
+    check_mfa(current_user)
+    return current_user
+
+
+def require_step_up(what: str) -> Callable[..., Dict]:
+    """
+    Returns a dependency applying `check_step_up` to a sensitive operation,
+    after the route's own authorization. `what` completes "Verify a second
+    factor again to ...":
+
+        @router.post("/$export", dependencies=[Depends(require_step_up("start a bulk export"))])
+    """
+    def dependency(current_user: Dict = Depends(get_current_user)) -> Dict:
+        check_step_up(current_user, what)
+        return current_user
+    return dependency
@@ -0,0 +1,97 @@
+# Location: app/authz/roles.py
+
+from typing import Any, Dict, FrozenSet, Tuple
+
+# --- Roles ---
+# Granted through the `roles` claim of the caller's token (a Firebase custom
+# claim, or OIDC_ROLES_CLAIM). Other roles, such as `compliance-officer` or
+# `integration-admin`, gate individual endpoints with `require_roles` and
+# carry none of the permissions below. Any other role is looked up among the
+# custom roles of the caller's organization (see app/authz/custom_roles.py).
+PATIENT = "patient"
+CLINICIAN = "clinician"
+COORDINATOR = "coordinator"
+ADMIN = "admin"
+
+# Roles that gate endpoints by name; organizations cannot define roles by
+# these names, or by those of the roles above.
+GATE_ROLES = frozenset({"compliance-officer", "privacy-officer", "integration-admin", "platform-admin"})
+
+# The token claim linking a `patient` user to their patient record.
+PATIENT_ID_CLAIM = "patientId"
+
+# --- Permissions ---
+# `<resource>:read` covers GET and HEAD requests to a resource and its
+# subresources, `<resource>:write` every other method, where the resource is
+# the first path segment under /api/<version> (the same format as API key scopes).
+# `<resource>.<subresource>:<action>` narrows a permission to one kind of
+# record under a resource, e.g. `patients.lab-results:read`; `<resource>:read`
+# covers it. "*" grants everything. A permission prefixed with "!" is denied:
+# it and those it covers are refused whatever else grants them.
+ALL = "*"
+DENIED = "!"
+
+
+def permission(resource: str, method: str) -> str:
+    return f"{resource}:{'read' if method in ('GET', 'HEAD') else 'write'}"
+
+
+def _read_write(*resources: str) -> FrozenSet[str]:
+    return frozenset(f"{resource}:{action}" for resource in resources for action in ("read", "write"))
+
+
+def _read(*resources: str) -> FrozenSet[str]:
+    return frozenset(f"{resource}:read" for resource in resources)
+
+
+ROLE_PERMISSIONS: Dict[str, FrozenSet[str]] = {
+    ADMIN: frozenset({ALL}),
+    CLINICIAN: _read_write("patients", "care-plans", "appointments", "encounters", "telehealth")
+    | _read("care-teams", "practitioners", "consent-documents"),
+    # Coordinators staff the front desk, where coverage is checked before
+    # visits, and the billing office, where the visits are billed.
+    COORDINATOR: _read_write("appointments", "care-teams", "practitioners", "patients.coverage", "encounters.charges", "claims", "invoices")
+    | _read("patients", "care-plans", "encounters", "consent-documents", "telehealth", "reports"),
+    # Devices are stored per user, so a patient only ever reaches their own.
+    PATIENT: _read("practitioners", "consent-documents") | frozenset({"devices:write"}),
+}
+
+# Permissions that only extend to the records of the caller's own patient
+# (see app/authz/ownership.py). Patients pay their own invoices online.
+OWN_RECORD_PERMISSIONS: Dict[str, FrozenSet[str]] = {
+    PATIENT: _read_write("patients", "appointments", "telehealth") | _read("care-plans", "care-teams", "encounters", "invoices")
+    | frozenset({"invoices.checkout:write"}),
+}
+
+
+def grants(principal: Dict[str, Any]) -> Tuple[FrozenSet[str], FrozenSet[str]]:
+    """
+    Returns the caller's unrestricted permissions and those limited to their
+    own records. API keys hold no roles; their scopes are their permissions.
+    """
+    if principal.get("authMethod") == "api_key":
+        return frozenset(principal.get("scopes") or []), frozenset()
+    roles = principal.get("roles")
+    roles = [str(role) for role in roles] if isinstance(roles, list) else []
+    full = frozenset().union(*(ROLE_PERMISSIONS.get(role, frozenset()) for role in roles))
+    own = frozenset().union(*(OWN_RECORD_PERMISSIONS.get(role, frozenset()) for role in roles))
+    custom = [role for role in roles if role not in ROLE_PERMISSIONS and role not in GATE_ROLES]
+    if custom:
+        from app.authz.custom_roles import custom_role_permissions
+
+        full = full | custom_role_permissions(custom)
+    return full, own
+
+
+def _covering(needed: str) -> Tuple[str, ...]:
+    """The permission and the broader one covering it: `patients.lab-results:read` -> also `patients:read`."""
+    resource, _, action = needed.rpartition(":")
+    parent = resource.split(".", 1)[0]
+    return (needed,) if parent == resource else (needed, f"{parent}:{action}")
+
+
+def has_permission(permissions: FrozenSet[str], needed: str) -> bool:
+    covering = _covering(needed)
+    if any(DENIED + p in permissions for p in covering):
+        return False
+    return ALL in permissions or any(p in permissions for p in covering)
@@ -0,0 +1,112 @@
+# Location: app/authz/smart.py
+
+import logging
+from typing import Any, Awaitable, Callable, Dict, Optional
+
+from fastapi import Depends, HTTPException, Request, status
+
+from app.authz.policy import NO_SUCH_RECORD, check_permission, json_body, path_patient
+from app.dependencies.auth import get_current_user
+from app.fhir.mappers import parse_reference
+from app.fhir.smart import granted_scopes, launch_context
+
+# Write interactions by HTTP method; reads are `r` for a single resource
+# and `s` for a search.
+_WRITE_ACTIONS = {"POST": "c", "PUT": "u", "PATCH": "u", "DELETE": "d"}
+
+
+def interaction_action(request: Request) -> str:
+    if request.method in ("GET", "HEAD"):
+        return "r" if request.path_params else "s"
+    return _WRITE_ACTIONS.get(request.method, "c")
+
+
+async def fhir_target_patient(request: Request) -> Any:
+    """
+    Works out whose record a FHIR request touches: from the path, else the
+    `subject` reference of the posted resource, else the `patient` or
+    `subject` search parameter. A request whose resource and parameters
+    name different patients is refused with 400.
+    """
+    patient_id = await path_patient(request)
+    if patient_id is not None:
+        return patient_id
+    body = await json_body(request)
+    subject = body.get("subject") if isinstance(body, dict) else None
+    body_patient = parse_reference(subject.get("reference"), "Patient") if isinstance(subject, dict) else None
+    reference = request.query_params.get("patient") or request.query_params.get("subject")
+    query_patient = (parse_reference(reference, "Patient") or reference) if reference else None
+    if body_patient and query_patient and body_patient != query_patient:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="The search parameters and the resource's subject name different patients")
+    return body_patient or query_patient
+
+
+def _insufficient_scope(detail: str) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_403_FORBIDDEN,
+        detail=detail,
+        headers={"WWW-Authenticate": 'Bearer error="insufficient_scope"'},
+    )
+
+
+async def check_fhir_access(
+    principal: Dict,
+    resource_type: str,
+    needed: str,
+    locate: Callable[[], Awaitable[Any]],
+    what: str,
+) -> None:
+    """
+    Raises 403 unless the caller may perform SMART action `needed` on FHIR
+    `resource_type` ("*" for operations spanning all types, such as bulk
+    export). `locate` finds the patient whose record is touched, and `what`
+    names the access in the log.
+
+    Tokens carrying SMART scopes are limited by them: `system/` scopes are
+    granted as they stand, `patient/` scopes only reach the records of the
+    patient in the token's launch context, and `user/` scopes reach what the
+    user's roles allow. Other tokens are checked by role, as for
+    /api/v1/patients.
+    """
+    async def check_roles() -> None:
+        role_permission = f"patients:{'read' if needed in ('r', 's') else 'write'}"
+        await check_permission(principal, role_permission, locate, what)
+
+    scopes = granted_scopes(principal)
+    if not scopes:
+        await check_roles()
+        return
+
+    contexts = {scope.context for scope in scopes if scope.allows(resource_type, needed)}
+    if "system" in contexts:
+        return
+    if "patient" in contexts:
+        launch_patient = launch_context(principal)["patient"]
+        patient_id = await locate()
+        if patient_id is NO_SUCH_RECORD or (launch_patient and patient_id == launch_patient):
+            return
+        if "user" not in contexts:
+            logging.info(f"User {principal.get('uid')} denied {what}: outside the launch patient")
+            raise _insufficient_scope("Patient-level scopes only reach the launch patient's records")
+    if "user" in contexts:
+        await check_roles()
+        return
+    raise _insufficient_scope(f"The token's scopes do not allow '{needed}' on {resource_type}")
+
+
+def authorize_fhir(resource_type: str, action: Optional[str] = None) -> Callable[..., Dict]:
+    """
+    Returns a dependency applying `check_fhir_access` to `resource_type`,
+    for the patient the request touches (see `fhir_target_patient`).
+    `action` overrides the SMART action derived from the request.
+    """
+    async def dependency(request: Request, current_user: Dict = Depends(get_current_user)) -> Dict:
+        await check_fhir_access(
+            current_user,
+            resource_type,
+            action or interaction_action(request),
+            lambda: fhir_target_patient(request),
+            f"{request.method} {request.url.path}",
+        )
+        return current_user
+    return dependency
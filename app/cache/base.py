@@ -0,0 +1,116 @@
+# Location: app/cache/base.py
+
+import json
+import logging
+import threading
+import time
+from abc import ABC, abstractmethod
+from typing import Callable, Dict, Optional, Tuple, Type, TypeVar
+
+from pydantic import BaseModel
+
+from app.core.metrics import CACHE_REQUESTS_TOTAL
+from app.crypto.envelope import get_field_cipher
+from app.crypto.fields import encrypted_paths, open_fields, seal_fields
+
+M = TypeVar("M", bound=BaseModel)
+
+
+class Cache(ABC):
+    """
+    A string key/value cache with per-entry TTLs. Implementations should
+    raise on a broken connection; `read_through` and `invalidate` treat
+    cache errors as misses so an outage only costs latency.
+    """
+
+    @abstractmethod
+    def get(self, key: str) -> Optional[str]:
+        """Returns the cached value, or None if absent or expired."""
+
+    @abstractmethod
+    def set(self, key: str, value: str, ttl_seconds: int) -> None:
+        """Stores `value` under `key` for `ttl_seconds`."""
+
+    @abstractmethod
+    def delete(self, *keys: str) -> None:
+        """Removes the keys; missing keys are ignored."""
+
+
+class NullCache(Cache):
+    """Caches nothing. Used when no cache is configured."""
+
+    def get(self, key: str) -> Optional[str]:
+        return None
+
+    def set(self, key: str, value: str, ttl_seconds: int) -> None:
+        pass
+
+    def delete(self, *keys: str) -> None:
+        pass
+
+
+class MemoryCache(Cache):
+    """A per-process cache for local development and tests."""
+
+    def __init__(self, clock: Callable[[], float] = time.monotonic):
+        self.clock = clock
+        self._entries: Dict[str, Tuple[str, float]] = {}
+        self._lock = threading.Lock()
+
+    def get(self, key: str) -> Optional[str]:
+        with self._lock:
+            entry = self._entries.get(key)
+            if entry and entry[1] <= self.clock():
+                del self._entries[key]
+                entry = None
+        return entry[0] if entry else None
+
+    def set(self, key: str, value: str, ttl_seconds: int) -> None:
+        with self._lock:
+            self._entries[key] = (value, self.clock() + ttl_seconds)
+
+    def delete(self, *keys: str) -> None:
+        with self._lock:
+            for key in keys:
+                self._entries.pop(key, None)
+
+
+def read_through(cache: Cache, key: str, ttl_seconds: int, model: Type[M], load: Callable[[], Optional[M]]) -> Optional[M]:
+    """
+    Returns the model cached under `key`, or calls `load` and caches its
+    result. None results are not cached, so a record created right after a
+    failed lookup is found immediately. Encrypted fields stay sealed in the
+    cache, as they are in the store.
+    """
+    namespace = key.split(":", 1)[0]
+    paths = encrypted_paths(model)
+    try:
+        cached = cache.get(key)
+    except Exception as e:
+        logging.warning(f"Cache read for '{namespace}' failed, loading from the store: {e}")
+        cached = None
+    if cached is not None:
+        CACHE_REQUESTS_TOTAL.labels(namespace=namespace, result="hit").inc()
+        data, _stale = open_fields(json.loads(cached), paths, get_field_cipher())
+        return model.model_validate(data)
+
+    CACHE_REQUESTS_TOTAL.labels(namespace=namespace, result="miss").inc()
+    value = load()
+    if value is not None:
+        try:
+            data = seal_fields(value.model_dump(mode="json", by_alias=True), paths, get_field_cipher())
+            cache.set(key, json.dumps(data), ttl_seconds)
+        except Exception as e:
+            logging.warning(f"Cache write for '{namespace}' failed: {e}")
+    return value
+
+
+def invalidate(cache: Cache, *keys: str) -> None:
+    """
+    Removes the keys after a write. A failure is logged loudly: the stale
+    entries will then be served until their TTL runs out.
+    """
+    try:
+        cache.delete(*keys)
+    except Exception:
+        logging.exception(f"Cache invalidation failed for {len(keys)} key(s); stale entries expire with their TTL")
@@ -0,0 +1,105 @@
+# Location: app/cache/patients.py
+
+from datetime import datetime
+from typing import Dict, Iterator, List, Optional
+
+from app.api.v1 import schemas
+from app.cache.base import Cache, invalidate, read_through
+from app.repositories.base import showing_deleted
+from app.repositories.patients import PatientRepository
+from app.search.filters import Search
+from app.tenancy.context import current_tenant
+
+
+# Entries are kept per organization, as MRNs are only unique within one and
+# a patient must not be served to another organization from the cache.
+def _prefix() -> str:
+    return f"patient:{current_tenant() or '-'}"
+
+
+def patient_key(patient_id: str) -> str:
+    return f"{_prefix()}:id:{patient_id}"
+
+
+def mrn_key(mrn: str) -> str:
+    return f"{_prefix()}:mrn:{mrn}"
+
+
+class CachedPatientRepository(PatientRepository):
+    """
+    Read-through caching for patient lookups by ID and MRN, the hottest reads
+    (every HL7v2 message and FHIR identifier search resolves an MRN). Writes
+    go to the wrapped repository, then drop the affected keys of the current
+    organization; a change made across organizations by a platform
+    administrator reaches an organization's cached reads when they expire.
+    """
+
+    def __init__(self, repo: PatientRepository, cache: Cache, ttl_seconds: int):
+        self.repo = repo
+        self.cache = cache
+        self.ttl_seconds = ttl_seconds
+
+    def _invalidate(self, patient: Optional[schemas.Patient], *extra_keys: str) -> None:
+        keys = list(extra_keys)
+        if patient:
+            keys += [patient_key(patient.patient_id), mrn_key(patient.mrn)]
+        invalidate(self.cache, *keys)
+
+    def create(self, patient_in: schemas.PatientCreate) -> schemas.Patient:
+        # Misses are never cached, so there is nothing to invalidate.
+        return self.repo.create(patient_in)
+
+    def get(self, patient_id: str) -> Optional[schemas.Patient]:
+        # Only live patients are cached.
+        if showing_deleted():
+            return self.repo.get(patient_id)
+        return read_through(self.cache, patient_key(patient_id), self.ttl_seconds, schemas.Patient, lambda: self.repo.get(patient_id))
+
+    def get_many(self, patient_ids: List[str]) -> Dict[str, schemas.Patient]:
+        return self.repo.get_many(patient_ids)
+
+    def get_by_mrn(self, mrn: str) -> Optional[schemas.Patient]:
+        if showing_deleted():
+            return self.repo.get_by_mrn(mrn)
+        return read_through(self.cache, mrn_key(mrn), self.ttl_seconds, schemas.Patient, lambda: self.repo.get_by_mrn(mrn))
+
+    def list(self, limit: int = 30, after: Optional[str] = None, search: Optional[Search] = None) -> List[schemas.Patient]:
+        return self.repo.list(limit=limit, after=after, search=search)
+
+    def find_candidates(self, patient: schemas.Patient, limit: int = 50) -> List[schemas.Patient]:
+        return self.repo.find_candidates(patient, limit)
+
+    def stream(self, updated_since: Optional[datetime] = None, updated_before: Optional[datetime] = None) -> Iterator[schemas.Patient]:
+        return self.repo.stream(updated_since, updated_before)
+
+    def update(self, patient_id: str, patient_in: schemas.PatientUpdate) -> schemas.Patient:
+        # The MRN may change, so the entry under the old MRN has to go too.
+        before = self.repo.get(patient_id)
+        patient = self.repo.update(patient_id, patient_in)
+        self._invalidate(before, mrn_key(patient.mrn))
+        return patient
+
+    def set_sms_opt_out(self, phone_number: str, opted_out: bool) -> List[schemas.Patient]:
+        patients = self.repo.set_sms_opt_out(phone_number, opted_out)
+        for patient in patients:
+            self._invalidate(patient)
+        return patients
+
+    def update_push_preferences(self, patient_id: str, preferences_in: schemas.PushPreferencesUpdate) -> schemas.Patient:
+        patient = self.repo.update_push_preferences(patient_id, preferences_in)
+        self._invalidate(patient)
+        return patient
+
+    def delete(self, patient_id: str, deleted_by: str) -> None:
+        before = self.repo.get(patient_id)
+        self.repo.delete(patient_id, deleted_by)
+        self._invalidate(before, patient_key(patient_id))
+
+    def restore(self, patient_id: str) -> schemas.Patient:
+        # Deleted patients are never cached, so there is nothing to invalidate.
+        return self.repo.restore(patient_id)
+
+    def mark_merged(self, patient_id: str, merged_into: str, merged_by: str) -> schemas.Patient:
+        patient = self.repo.mark_merged(patient_id, merged_into, merged_by)
+        self._invalidate(patient)
+        return patient
@@ -0,0 +1,38 @@
+# Location: app/cache/redis_cache.py
+
+from typing import Optional
+
+from app.cache.base import Cache
+
+
+class RedisCache(Cache):
+    """
+    Redis (Memorystore) backed cache. Keys are prefixed so several services
+    or environments can share one instance. Timeouts are short: a slow cache
+    should fail over to the store instead of holding up the request.
+    """
+
+    def __init__(self, url: str, key_prefix: str = "megacare", timeout_seconds: float = 0.25, client=None):
+        if client is None:
+            import redis
+            client = redis.Redis.from_url(
+                url,
+                socket_timeout=timeout_seconds,
+                socket_connect_timeout=timeout_seconds,
+                decode_responses=True,
+            )
+        self.client = client
+        self.key_prefix = key_prefix
+
+    def _key(self, key: str) -> str:
+        return f"{self.key_prefix}:{key}"
+
+    def get(self, key: str) -> Optional[str]:
+        return self.client.get(self._key(key))
+
+    def set(self, key: str, value: str, ttl_seconds: int) -> None:
+        self.client.set(self._key(key), value, ex=ttl_seconds)
+
+    def delete(self, *keys: str) -> None:
+        if keys:
+            self.client.delete(*(self._key(key) for key in keys))
@@ -0,0 +1,255 @@
+# Location: app/claims/clearinghouse.py
+
+from abc import ABC, abstractmethod
+from dataclasses import dataclass, field
+from datetime import date
+from functools import lru_cache
+from typing import Any, Dict, List, Optional, Tuple
+
+from app.api.v1 import schemas
+from app.core.config import get_settings
+from app.eligibility.clearinghouse import RELATIONSHIP_CODES, ChangeHealthcareClearinghouse, ClearinghouseError, to_cents
+
+# Who pays the claim: the patient's primary, secondary or tertiary plan (SBR01).
+RESPONSIBILITY_CODES = {"primary": "P", "secondary": "S", "tertiary": "T"}
+
+# Claim status category codes (X12 507) that say where the claim is. A0 and
+# A1 (received), A4 (not found yet), D0 (no match) and the E codes (the
+# inquiry failed) say nothing new.
+ACCEPTED_CATEGORIES = frozenset({"A2", "P0", "P1", "P2", "P3", "P4", "P5"})
+REJECTED_CATEGORIES = frozenset({"A3", "A6", "A7", "A8"})
+DENIED_CATEGORIES = frozenset({"F2"})
+# Finalized otherwise: paid in whole or part, or adjudicated with nothing to
+# pay (F4), e.g. when all of it went to the deductible.
+PAID_CATEGORIES = frozenset({"F0", "F1", "F3", "F3F", "F3N", "F4"})
+
+
+@dataclass
+class ClaimSubmission:
+    """What an X12 837P carries: the claim, the patient and plan it is for, and who bills it."""
+    control_number: str
+    patient_control_number: str
+    provider: schemas.BillingProvider
+    claim: schemas.Claim
+    coverage: schemas.Coverage
+    patient: schemas.Patient
+    practitioners: Dict[str, schemas.Practitioner] = field(default_factory=dict)
+
+
+@dataclass
+class SubmissionReceipt:
+    """Whether the clearinghouse took the claim to send on to the payer; `rejections` say why not."""
+    accepted: bool
+    reference: Optional[str] = None
+    rejections: List[schemas.ClaimDenialReason] = field(default_factory=list)
+
+
+@dataclass
+class ClaimStatusReport:
+    """The payer's X12 277 about a claim, read. `status` is None when it says nothing new."""
+    status: Optional[str]
+    category: Optional[str] = None
+    description: Optional[str] = None
+    paid_cents: Optional[int] = None
+    payer_claim_number: Optional[str] = None
+    reasons: List[schemas.ClaimDenialReason] = field(default_factory=list)
+
+
+class ClaimsClearinghouse(ABC):
+    """Sends claims on to payers and asks the payers where they stand."""
+
+    name: str
+
+    @abstractmethod
+    def submit(self, submission: ClaimSubmission) -> SubmissionReceipt:
+        """Sends the claim. Raises ClearinghouseError."""
+
+    @abstractmethod
+    def status(self, submission: ClaimSubmission) -> ClaimStatusReport:
+        """Asks the payer about the claim sent as `submission` (an X12 276). Raises ClearinghouseError."""
+
+
+def status_of_category(category: Optional[str]) -> Optional[str]:
+    if category in ACCEPTED_CATEGORIES:
+        return "accepted"
+    if category in REJECTED_CATEGORIES:
+        return "rejected"
+    if category in DENIED_CATEGORIES:
+        return "denied"
+    if category in PAID_CATEGORIES:
+        return "paid"
+    return None
+
+
+def _amount(cents: int) -> str:
+    return f"{cents // 100}.{cents % 100:02d}"
+
+
+def _date(value: date) -> str:
+    return value.strftime("%Y%m%d")
+
+
+class ChangeHealthcareClaims(ChangeHealthcareClearinghouse, ClaimsClearinghouse):
+    """
+    Change Healthcare's Professional Claims API, which takes the 837P as
+    JSON, and its Claim Status API (276/277). Signs in as the Eligibility
+    API does.
+    """
+
+    SUBMISSION_PATH = "/medicalnetwork/professionalclaims/v3/submission"
+    STATUS_PATH = "/medicalnetwork/claimstatus/v2"
+
+    def _parties(self, submission: ClaimSubmission) -> Tuple[Dict[str, Any], Optional[Dict[str, Any]]]:
+        """The subscriber and, when the patient is their dependant, the patient."""
+        coverage, patient = submission.coverage, submission.patient
+        person = self._person(patient.given_name, patient.family_name, patient.dob, patient.sex)
+        if coverage.relationship == "self":
+            subscriber, dependent = person, None
+            if patient.contact and patient.contact.address_line and patient.contact.city:
+                subscriber["address"] = {"address1": patient.contact.address_line, "city": patient.contact.city, "postalCode": patient.contact.postal_code}
+        else:
+            holder = coverage.subscriber
+            subscriber = self._person(holder.given_name, holder.family_name, holder.dob, holder.sex)
+            dependent = {**person, "relationshipToSubscriberCode": RELATIONSHIP_CODES[coverage.relationship]}
+        subscriber["memberId"] = coverage.member_id
+        if coverage.group_number:
+            subscriber["groupNumber"] = coverage.group_number
+        return subscriber, dependent
+
+    def _service_line(self, line: schemas.ClaimLine, practitioners: Dict[str, schemas.Practitioner]) -> Dict[str, Any]:
+        service = {
+            "procedureIdentifier": "HC",
+            "procedureCode": line.procedure_code,
+            "lineItemChargeAmount": _amount(line.charge_cents),
+            "measurementUnit": "UN",
+            "serviceUnitCount": str(line.units),
+            "compositeDiagnosisCodePointers": {"diagnosisCodePointers": [str(pointer) for pointer in line.diagnosis_pointers]},
+        }
+        if line.modifiers:
+            service["procedureModifiers"] = line.modifiers
+        entry = {"serviceDate": _date(line.service_date), "professionalService": service}
+        practitioner = practitioners.get(line.practitioner_id) if line.practitioner_id else None
+        if practitioner:
+            entry["renderingProvider"] = {"npi": practitioner.npi, "firstName": practitioner.given_name, "lastName": practitioner.family_name}
+        return entry
+
+    def _claim_request(self, submission: ClaimSubmission) -> Dict[str, Any]:
+        claim, coverage, provider = submission.claim, submission.coverage, submission.provider
+        subscriber, dependent = self._parties(submission)
+        subscriber["paymentResponsibilityLevelCode"] = RESPONSIBILITY_CODES[coverage.priority]
+        address = provider.address
+        body = {
+            "controlNumber": submission.control_number,
+            "tradingPartnerServiceId": coverage.payer_id,
+            "submitter": {"organizationName": provider.name, "contactInformation": {"name": provider.name}},
+            "receiver": {"organizationName": coverage.payer_name},
+            "subscriber": subscriber,
+            "billing": {
+                "providerType": "BillingProvider",
+                "npi": provider.npi,
+                "employerId": provider.tax_id,
+                "organizationName": provider.name,
+                "address": {"address1": address.line, "city": address.city, "state": address.state, "postalCode": address.postal_code},
+            },
+            "claimInformation": {
+                "claimFilingCode": claim.filing_code,
+                "patientControlNumber": submission.patient_control_number,
+                "claimChargeAmount": _amount(claim.total_charge_cents),
+                "placeOfServiceCode": claim.place_of_service,
+                "claimFrequencyCode": "1",
+                "signatureIndicator": "Y",
+                "planParticipationCode": "A",
+                "benefitsAssignmentCertificationIndicator": "Y",
+                "releaseInformationCode": "Y",
+                # The first diagnosis is the principal one (ABK), the rest are other diagnoses (ABF).
+                "healthCareCodeInformation": [
+                    {"diagnosisTypeCode": "ABK" if index == 0 else "ABF", "diagnosisCode": code.replace(".", "")}
+                    for index, code in enumerate(claim.diagnosis_codes)
+                ],
+                "serviceLines": [self._service_line(line, submission.practitioners) for line in claim.lines],
+            },
+        }
+        if dependent:
+            body["dependent"] = dependent
+        return body
+
+    def _answer(self, response) -> Dict[str, Any]:
+        if response.status_code >= 500 or response.status_code in (401, 429):
+            raise ClearinghouseError(f"Change Healthcare answered {response.status_code}")
+        try:
+            return response.json()
+        except ValueError as e:
+            raise ClearinghouseError("Change Healthcare sent a malformed response") from e
+
+    def submit(self, submission: ClaimSubmission) -> SubmissionReceipt:
+        response = self._post(self.SUBMISSION_PATH, self._claim_request(submission))
+        body = self._answer(response)
+        # Claims failing the clearinghouse's edits come back with `errors`,
+        # naming the field at fault; they are not sent on to the payer.
+        errors = body.get("errors") or []
+        if response.status_code >= 300 and not errors:
+            raise ClearinghouseError(f"Change Healthcare refused the claim ({response.status_code}): {response.text}", retryable=False)
+        if errors:
+            return SubmissionReceipt(accepted=False, rejections=[
+                schemas.ClaimDenialReason(
+                    code=error.get("code"),
+                    description=": ".join(part for part in (error.get("field"), error.get("description") or "Rejected") if part),
+                    follow_up=error.get("followupAction"),
+                )
+                for error in errors
+            ])
+        reference = body.get("claimReference") or {}
+        return SubmissionReceipt(accepted=True, reference=reference.get("correlationId") or reference.get("rhclaimNumber"))
+
+    def status(self, submission: ClaimSubmission) -> ClaimStatusReport:
+        claim, provider = submission.claim, submission.provider
+        subscriber, dependent = self._parties(submission)
+        subscriber.pop("address", None)
+        service_dates = [line.service_date for line in claim.lines]
+        body = {
+            "controlNumber": submission.control_number,
+            "tradingPartnerServiceId": submission.coverage.payer_id,
+            "providers": [{"providerType": "BillingProvider", "organizationName": provider.name, "npi": provider.npi}],
+            "subscriber": subscriber,
+            "encounter": {"beginningDateOfService": _date(min(service_dates)), "endDateOfService": _date(max(service_dates))},
+        }
+        if dependent:
+            body["dependent"] = dependent
+        response = self._post(self.STATUS_PATH, body)
+        answer = self._answer(response)
+        errors = answer.get("errors") or []
+        if errors or response.status_code >= 300:
+            descriptions = "; ".join(error.get("description") or "" for error in errors) or response.text
+            raise ClearinghouseError(f"Change Healthcare could not ask about the claim ({response.status_code}): {descriptions}", retryable=False)
+
+        statuses = [entry["claimStatus"] for entry in answer.get("claims") or [] if entry.get("claimStatus")]
+        # A payer may answer about every claim of the patient on those dates; ours carries our control number.
+        ours = [status for status in statuses if status.get("patientAccountNumber") == submission.patient_control_number]
+        found = (ours or statuses or [None])[0]
+        if found is None:
+            return ClaimStatusReport(status=None)
+        category = found.get("statusCategoryCode")
+        status = status_of_category(category)
+        reasons = []
+        if status in ("rejected", "denied"):
+            reasons = [schemas.ClaimDenialReason(code=found.get("statusCode"), description=found.get("statusCodeValue") or found.get("statusCategoryCodeValue") or category)]
+        return ClaimStatusReport(
+            status=status,
+            category=category,
+            description=found.get("statusCategoryCodeValue"),
+            paid_cents=to_cents(found.get("amountPaid")),
+            payer_claim_number=found.get("tradingPartnerClaimNumber"),
+            reasons=reasons,
+        )
+
+
+@lru_cache
+def get_claims_clearinghouse() -> Optional[ClaimsClearinghouse]:
+    """Returns the configured clearinghouse, or None with CLAIMS_PROVIDER=none."""
+    settings = get_settings()
+    if settings.claims_provider == "change-healthcare":
+        return ChangeHealthcareClaims(
+            settings.change_healthcare_url, settings.change_healthcare_client_id, settings.change_healthcare_client_secret,
+            settings.eligibility_timeout_seconds,
+        )
+    return None
@@ -0,0 +1,143 @@
+# Location: app/claims/submission.py
+
+import logging
+from datetime import datetime, timezone
+from typing import Optional
+
+from app.api.v1 import schemas
+from app.claims.clearinghouse import ClaimsClearinghouse, ClaimSubmission
+from app.eligibility.checks import control_number
+from app.eligibility.clearinghouse import ClearinghouseError
+from app.repositories.claims import ClaimRepository
+from app.repositories.coverage import CoverageRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.practitioners import PractitionerRepository
+from app.services.claims import CLAIM_STATUSES, patient_control_number
+
+# Recorded as `by` on status changes: those the clearinghouse or payer
+# reported, and claims found unfit to send before they were sent.
+CLEARINGHOUSE_ACTOR = "clearinghouse"
+SYSTEM_ACTOR = "system:claims"
+
+
+def provider_problem(provider: Optional[schemas.BillingProvider]) -> Optional[str]:
+    """Why claims cannot be sent as `provider`, if they cannot."""
+    if provider is None:
+        return "No billing provider NPI is set for the organization (or BILLING_PROVIDER_NPI)"
+    if not provider.tax_id or not provider.address:
+        return "Claims need the billing provider's tax ID and address"
+    return None
+
+
+def _submission(
+    claim: schemas.Claim,
+    coverage: schemas.Coverage,
+    patient: schemas.Patient,
+    provider: schemas.BillingProvider,
+    reference: str,
+    practitioners: Optional[PractitionerRepository] = None,
+) -> ClaimSubmission:
+    rendering = {}
+    if practitioners:
+        for practitioner_id in {line.practitioner_id for line in claim.lines if line.practitioner_id}:
+            practitioner = practitioners.get(practitioner_id)
+            if practitioner:
+                rendering[practitioner_id] = practitioner
+    return ClaimSubmission(
+        control_number=control_number(reference),
+        patient_control_number=patient_control_number(claim.claim_id),
+        provider=provider,
+        claim=claim,
+        coverage=coverage,
+        patient=patient,
+        practitioners=rendering,
+    )
+
+
+def run_claim_submission(
+    claim_id: str,
+    claims: ClaimRepository,
+    coverages: CoverageRepository,
+    patients: PatientRepository,
+    practitioners: PractitionerRepository,
+    clearinghouse: ClaimsClearinghouse,
+    provider: Optional[schemas.BillingProvider],
+) -> Optional[schemas.Claim]:
+    """
+    Sends a queued claim and records whether the clearinghouse took it.
+    Returns the claim as recorded, or None if it was not queued, so a
+    repeated run sends nothing. Raises ClearinghouseError when sending again
+    may help; the claim stays queued until it does.
+    """
+    claim = claims.get(claim_id)
+    if not claim or claim.status != "queued":
+        return None
+    coverage = coverages.get(claim.coverage_id)
+    patient = patients.get(claim.patient_id)
+    problem = "The coverage or patient no longer exists" if not coverage or not patient else provider_problem(provider)
+    if problem:
+        return claims.change_status(claim_id, "rejected", SYSTEM_ACTOR, {"error": problem}, note=problem)
+
+    # Each submission of the claim is its own interchange; retries of one are not.
+    submission = _submission(claim, coverage, patient, provider, f"{claim_id}/{len(claim.history)}", practitioners)
+    try:
+        receipt = clearinghouse.submit(submission)
+    except ClearinghouseError as e:
+        if e.retryable:
+            claims.update(claim_id, {"error": str(e)})
+            raise
+        logging.error(f"Claim {claim_id} could not be submitted: {e}", extra={"report_error": True})
+        return claims.change_status(claim_id, "rejected", SYSTEM_ACTOR, {"error": str(e)}, note=str(e))
+
+    changes = {"patientControlNumber": submission.patient_control_number, "error": None}
+    if not receipt.accepted:
+        logging.info(f"Claim {claim_id} was rejected by the clearinghouse: {len(receipt.rejections)} errors.")
+        changes["denialReasons"] = [reason.model_dump(by_alias=True) for reason in receipt.rejections]
+        return claims.change_status(claim_id, "rejected", CLEARINGHOUSE_ACTOR, changes, note="Failed the clearinghouse's edits")
+    logging.info(f"Claim {claim_id} was submitted to payer {claim.payer_id} as {submission.patient_control_number}.")
+    changes.update({"clearinghouseReference": receipt.reference, "submittedAt": datetime.now(timezone.utc), "denialReasons": []})
+    return claims.change_status(claim_id, "submitted", CLEARINGHOUSE_ACTOR, changes)
+
+
+def refresh_claim_status(
+    claim: schemas.Claim,
+    claims: ClaimRepository,
+    coverages: CoverageRepository,
+    patients: PatientRepository,
+    clearinghouse: ClaimsClearinghouse,
+    provider: Optional[schemas.BillingProvider],
+    now: Optional[datetime] = None,
+) -> Optional[schemas.Claim]:
+    """
+    Asks the payer where a submitted claim stands and records the answer.
+    Returns the claim if its status moved, else None. Raises
+    ClearinghouseError; the claim is not asked about again until its next
+    turn either way.
+    """
+    now = now or datetime.now(timezone.utc)
+    coverage = coverages.get(claim.coverage_id)
+    patient = patients.get(claim.patient_id)
+    if not coverage or not patient or provider is None:
+        claims.update(claim.claim_id, {"statusCheckedAt": now})
+        return None
+    submission = _submission(claim, coverage, patient, provider, f"{claim.claim_id}/status/{now.isoformat()}")
+    try:
+        report = clearinghouse.status(submission)
+    except ClearinghouseError:
+        claims.update(claim.claim_id, {"statusCheckedAt": now})
+        raise
+
+    changes = {"statusCheckedAt": now}
+    if report.payer_claim_number:
+        changes["payerClaimNumber"] = report.payer_claim_number
+    if report.status is None or report.status not in CLAIM_STATUSES.allowed(claim.status):
+        claims.update(claim.claim_id, changes)
+        return None
+    if report.status in ("paid", "denied"):
+        changes["adjudicatedAt"] = now
+        if report.paid_cents is not None:
+            changes["paidCents"] = report.paid_cents
+    if report.reasons:
+        changes["denialReasons"] = [reason.model_dump(by_alias=True) for reason in report.reasons]
+    logging.info(f"Claim {claim.claim_id} is {report.status} ({report.category}) with payer {claim.payer_id}.")
+    return claims.change_status(claim.claim_id, report.status, CLEARINGHOUSE_ACTOR, changes, note=report.description)
@@ -0,0 +1,82 @@
+# Location: app/core/access_log.py
+
+import logging
+from dataclasses import dataclass
+from typing import Any, Dict, List, Optional
+
+# --- Access Log ---
+# One entry per HTTP request, written to its own logger rather than mixed
+# into application logs. In JSON format each entry carries a Cloud Logging
+# `httpRequest` and the label `log=access`, so the request log can be
+# queried on its own with `labels.log="access"` and excluded from the rest.
+ACCESS_LOGGER = "megacare.access"
+ACCESS_LOG_LABEL = {"log": "access"}
+
+
+@dataclass(frozen=True)
+class SampleRule:
+    """Log a `rate` share (0 to 1) of the successful requests to routes under `prefix`."""
+    prefix: str
+    rate: float
+
+
+def parse_sample_rules(spec: str) -> List[SampleRule]:
+    """
+    Parses ACCESS_LOG_SAMPLE_RULES, comma-separated `<prefix>=<rate>` entries
+    such as "/healthz=0,/api/v1/devices=0.05". Rules are returned longest
+    prefix first, the order they are matched in. Raises ValueError for a
+    malformed entry.
+    """
+    rules = []
+    for entry in (part.strip() for part in spec.split(",")):
+        if not entry:
+            continue
+        prefix, _, rate = entry.rpartition("=")
+        try:
+            value = float(rate)
+        except ValueError:
+            value = -1.0
+        if not prefix.startswith("/") or not 0 <= value <= 1:
+            raise ValueError(f"invalid access log sample rule '{entry}', expected <prefix>=<rate between 0 and 1>")
+        rules.append(SampleRule(prefix.rstrip("/") or "/", value))
+    return sorted(rules, key=lambda rule: len(rule.prefix), reverse=True)
+
+
+def sample_rate(rules: List[SampleRule], path: str) -> float:
+    """The share of successful requests to `path` that are logged: that of the longest matching prefix, else all."""
+    for rule in rules:
+        if rule.prefix == "/" or path == rule.prefix or path.startswith(rule.prefix + "/"):
+            return rule.rate
+    return 1.0
+
+
+def http_request_entry(
+    method: str,
+    path: str,
+    status: int,
+    latency_seconds: float,
+    request_size: int,
+    response_size: int,
+    protocol: Optional[str] = None,
+    user_agent: Optional[str] = None,
+    remote_ip: Optional[str] = None,
+) -> Dict[str, Any]:
+    """
+    A Cloud Logging HttpRequest. The URL is the path only: query strings
+    can carry search terms such as patient names.
+    """
+    entry: Dict[str, Any] = {
+        "requestMethod": method,
+        "requestUrl": path,
+        "status": status,
+        "requestSize": str(request_size),
+        "responseSize": str(response_size),
+        "latency": f"{latency_seconds:.6f}s",
+    }
+    optional = {"protocol": protocol, "userAgent": user_agent, "remoteIp": remote_ip}
+    entry.update({k: v for k, v in optional.items() if v})
+    return entry
+
+
+def get_access_logger() -> logging.Logger:
+    return logging.getLogger(ACCESS_LOGGER)
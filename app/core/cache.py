@@ -0,0 +1,31 @@
+# Location: app/core/cache.py
+
+import threading
+
+from app.cache.base import Cache, NullCache
+from app.core.config import get_settings
+
+# --- Configuration ---
+# Memorystore is only reachable from the VPC; give the Cloud Run service a
+# VPC connector or Direct VPC egress. Cached entries hold PHI, so use an
+# instance with in-transit encryption and AUTH enabled (rediss://:<auth>@host).
+settings = get_settings()
+REDIS_URL = settings.redis_url
+CACHE_TTL_SECONDS = settings.cache_ttl_seconds
+CACHE_KEY_PREFIX = settings.cache_key_prefix
+
+_cache = None
+_cache_lock = threading.Lock()
+
+
+def get_cache() -> Cache:
+    """Returns the process-wide cache: Redis when REDIS_URL is set, otherwise a no-op cache."""
+    global _cache
+    with _cache_lock:
+        if _cache is None:
+            if REDIS_URL:
+                from app.cache.redis_cache import RedisCache
+                _cache = RedisCache(REDIS_URL, key_prefix=CACHE_KEY_PREFIX)
+            else:
+                _cache = NullCache()
+        return _cache
@@ -0,0 +1,725 @@
+# Location: app/core/config.py
+
+import logging
+import os
+import re
+from functools import lru_cache
+from typing import List, Literal, Optional, Tuple
+from zoneinfo import ZoneInfo, ZoneInfoNotFoundError
+
+from pydantic import Field, field_validator, model_validator
+from pydantic_settings import BaseSettings, SettingsConfigDict
+
+# Values of the form `sm://<secret-id>` or
+# `sm://projects/<project>/secrets/<secret-id>/versions/<version>` are resolved
+# from Google Secret Manager once at start-up.
+SECRET_REFERENCE_PREFIX = "sm://"
+
+
+def _split_list(value: str) -> List[str]:
+    """Splits a comma-separated setting, dropping blanks."""
+    return [item.strip() for item in value.split(",") if item.strip()]
+
+
+class Settings(BaseSettings):
+    """
+    Typed application configuration, loaded from environment variables
+    (case-insensitive; e.g. `line_channel_id` <- LINE_CHANNEL_ID) and an
+    optional `.env` file for local development.
+    Invalid values fail fast at start-up instead of on first use.
+    """
+    # --- Google Cloud / Cloud Run ---
+    google_cloud_project: Optional[str] = None
+    k_service: Optional[str] = Field(None, description="Set by Cloud Run to the service name.")
+    k_revision: Optional[str] = Field(None, description="Set by Cloud Run to the revision name.")
+
+    # --- Logging ---
+    log_level: Literal["DEBUG", "INFO", "WARNING", "ERROR", "CRITICAL"] = "INFO"
+    log_format: Optional[Literal["json", "text"]] = Field(None, description="Defaults to json on Cloud Run, text elsewhere.")
+    log_redaction_enabled: bool = Field(True, description="Mask names, dates of birth, MRNs, contact details and notes in log entries.")
+    access_log_enabled: bool = Field(True, description="Write one access log entry per HTTP request, in place of Uvicorn's.")
+    access_log_sample_rules: str = Field("/healthz=0,/readyz=0,/metrics=0", description="Comma-separated `<route prefix>=<rate>`: the share of successful requests to those routes that is logged; errors are always logged.")
+
+    # --- Tracing ---
+    tracing_enabled: Optional[bool] = Field(None, description="Defaults to true on Cloud Run, false elsewhere.")
+    tracing_sample_ratio: float = Field(0.1, ge=0.0, le=1.0)
+
+    # --- Health Checks ---
+    health_check_timeout_seconds: float = Field(3.0, gt=0)
+    health_pubsub_topic: Optional[str] = None
+    health_secret_name: Optional[str] = None
+
+    # --- Warmup ---
+    warmup_enabled: bool = Field(True, description="Open connections and load caches when a worker starts, before it accepts requests.")
+    warmup_timeout_seconds: float = Field(20.0, gt=0, description="How long a worker waits for warmup before it starts accepting requests regardless.")
+
+    # --- Diagnostics ---
+    diagnostics_enabled: bool = Field(False, description="Serve thread stacks, runtime counters and CPU and heap profiles under /internal/debug to platform administrators.")
+
+    # --- API Documentation ---
+    api_docs_enabled: bool = Field(True, description="Serve the OpenAPI document at /openapi.json and Swagger UI at /docs.")
+
+    # --- API Versioning ---
+    api_default_version: str = Field("v1", description="Version serving /api requests that name none in the path or the Api-Version header, e.g. v1.")
+
+    # --- Storage ---
+    store: Literal["firestore", "postgres", "memory"] = Field("firestore", description="Backend for the repositories; 'memory' is for local development and tests.")
+    database_url: Optional[str] = Field(None, description="libpq connection string, required when STORE=postgres.")
+    database_pool_max_size: int = Field(10, ge=2, description="Connections per worker process; migrations need two.")
+    database_migrate_on_startup: bool = Field(False, description="Apply pending schema migrations when a worker starts.")
+
+    # --- Cache ---
+    redis_url: Optional[str] = Field(None, description="Redis/Memorystore URL, e.g. redis://10.0.0.3:6379/0. Caching is off when unset.")
+    cache_ttl_seconds: int = Field(300, ge=1)
+    cache_key_prefix: str = "megacare"
+
+    # --- Pub/Sub ---
+    pubsub_publish_timeout_seconds: float = Field(10.0, gt=0)
+    telemetry_topic: Optional[str] = Field(None, description="Topic ID or full path that receives device telemetry batches.")
+    domain_events_topic: Optional[str] = Field(None, description="Topic ID or full path that receives domain events. Events are dropped when unset.")
+    domain_events_delivery: Literal["outbox", "direct"] = Field("outbox", description="'outbox' stores events with the change and relays them; 'direct' publishes them after the change.")
+    outbox_relay_enabled: bool = Field(True, description="Run the outbox relay in each worker.")
+    outbox_relay_interval_seconds: float = Field(1.0, gt=0, description="Pause between relay passes while the outbox is empty.")
+    outbox_relay_batch_size: int = Field(100, ge=1, le=500)
+    outbox_max_attempts: int = Field(10, ge=1, description="Publish attempts before an event is marked failed.")
+
+    # --- Webhooks ---
+    webhooks_enabled: bool = Field(False, description="Queue deliveries for webhook subscriptions and run the dispatcher in each worker.")
+    webhook_timeout_seconds: float = Field(10.0, gt=0, le=60)
+    webhook_max_attempts: int = Field(8, ge=1, description="Delivery attempts before a delivery is marked failed.")
+    webhook_dispatch_interval_seconds: float = Field(1.0, gt=0, description="Pause between dispatcher passes while no deliveries are due.")
+    webhook_batch_size: int = Field(50, ge=1, le=500)
+
+    # --- Event Stream ---
+    event_stream_enabled: bool = Field(False, description="Record domain events for /api/v1/events/stream.")
+    event_stream_poll_seconds: float = Field(2.0, gt=0, description="How often an open stream checks for new events.")
+    event_stream_heartbeat_seconds: float = Field(15.0, gt=0, description="Idle time after which a comment is sent to keep proxies from closing the stream.")
+    event_stream_max_seconds: int = Field(240, ge=10, description="How long a stream stays open before the client is made to reconnect; keep below the Cloud Run request timeout.")
+    event_stream_retention_hours: int = Field(24, ge=1, description="How long events are kept for clients resuming with Last-Event-ID.")
+
+    # --- WebSockets ---
+    websockets_enabled: bool = Field(False, description="Serve /ws for telehealth presence and signaling.")
+    websocket_backend: Literal["memory", "redis"] = Field("memory", description="Keep rooms in each worker process, or in Redis (REDIS_URL) across all instances.")
+    websocket_max_connections: int = Field(1000, ge=1, description="Open connections per worker; more are closed with 1013.")
+    websocket_max_connections_per_user: int = Field(5, ge=1, description="Open connections per user and worker; more are closed with 4429.")
+    websocket_auth_timeout_seconds: float = Field(10.0, gt=0, description="Time a client has to authenticate after connecting.")
+    websocket_heartbeat_seconds: float = Field(25.0, gt=0, description="Interval between server pings, which also refresh presence.")
+    websocket_idle_timeout_seconds: float = Field(60.0, gt=0, description="Connections with no message from the client for this long are closed.")
+    websocket_max_session_seconds: int = Field(540, ge=60, description="How long a connection stays open before the client is made to reconnect; keep below the Cloud Run request timeout.")
+    websocket_max_message_bytes: int = Field(65536, ge=1024, description="Largest message a client may send.")
+
+    # --- Cloud Tasks ---
+    tasks_queue: Optional[str] = Field(None, description="Queue ID or full `projects/.../locations/.../queues/...` path for deferred jobs. Jobs run in the worker after the response when unset.")
+    tasks_location: Optional[str] = Field(None, description="Region of the queue when TASKS_QUEUE is a queue ID, e.g. asia-southeast1.")
+    tasks_handler_url: Optional[str] = Field(None, description="Public base URL of this service, which Cloud Tasks calls back at /internal/tasks/{task}.")
+    tasks_service_account: Optional[str] = Field(None, description="Service account Cloud Tasks signs its OIDC tokens as; the only caller /internal/tasks accepts.")
+
+    # --- Scheduler ---
+    scheduler_service_account: Optional[str] = Field(None, description="Service account Cloud Scheduler signs its OIDC tokens as; the only caller /internal/cron accepts.")
+    scheduler_audience: Optional[str] = Field(None, description="Audience of the Cloud Scheduler tokens, usually the service URL.")
+    scheduler_ticker_enabled: bool = Field(False, description="Run due jobs from a ticker in each worker. Only for instances with CPU always allocated.")
+    scheduler_tick_seconds: float = Field(30.0, gt=0, description="Pause between ticker checks for due jobs.")
+    appointment_reminder_lead_hours: int = Field(24, ge=1, le=7 * 24, description="How far ahead of an appointment its reminder is due.")
+    operational_retention_days: int = Field(30, ge=1, description="Days relayed outbox entries, finished webhook deliveries and job runs are kept.")
+
+    # --- Appointment Reminders ---
+    appointment_reminder_hours: str = Field("48,2", description="Comma-separated hours before an appointment its patient is reminded; empty sends no reminders.")
+    appointment_reminder_quiet_hours: str = Field("21-8", description="Local hours (start-end) of the patient's night; reminders due then are sent at its start instead. Empty to send at any hour.")
+
+    # --- Service-to-Service Auth ---
+    service_auth_audience: Optional[str] = Field(None, description="Audience other MegaCare services mint their ID tokens for, usually this service's URL.")
+    service_auth_allowed_callers: str = Field("", description="Comma-separated service account emails allowed to call /internal/services.")
+
+    # --- gRPC ---
+    grpc_enabled: bool = Field(False, description="Serve the gRPC services of app/rpc on GRPC_PORT next to HTTP, for internal services.")
+    grpc_port: int = Field(50051, ge=1, le=65535)
+    grpc_max_workers: int = Field(8, ge=1, description="Threads per worker process handling gRPC calls.")
+
+    # --- Encounter Documents ---
+    encounter_documents_bucket: Optional[str] = Field(None, description="GCS bucket for generated encounter documents such as discharge summaries. Not generated when unset.")
+
+    # --- Patient Documents ---
+    patient_documents_bucket: Optional[str] = Field(None, description="GCS bucket for documents attached to patients' charts. Uploads are refused when unset.")
+    patient_document_max_bytes: int = Field(25 * 1024 * 1024, gt=0, description="Largest document accepted for upload.")
+    patient_document_upload_url_ttl_seconds: int = Field(900, gt=0, le=7 * 24 * 3600, description="Lifetime of signed upload URLs.")
+    patient_document_download_url_ttl_seconds: int = Field(300, gt=0, le=7 * 24 * 3600, description="Lifetime of signed download URLs.")
+
+    # --- Document Scanning ---
+    document_scanner: Literal["none", "clamav"] = Field("none", description="Malware scanner uploaded documents must pass before they can be downloaded; document uploads are refused with 'none'.")
+    clamav_host: str = Field("localhost", description="Host of the clamd daemon, e.g. a sidecar container.")
+    clamav_port: int = Field(3310, gt=0, le=65535, description="TCP port of the clamd daemon.")
+    clamav_timeout_seconds: float = Field(60.0, gt=0, description="How long to wait for clamd to answer a scan.")
+
+    # --- Wound Images ---
+    wound_images_bucket: Optional[str] = Field(None, description="GCS bucket for wound-care photos and their thumbnails. Uploads are refused when unset.")
+    wound_image_max_bytes: int = Field(20 * 1024 * 1024, gt=0, description="Largest photo accepted for upload.")
+    wound_image_max_pixels: int = Field(50_000_000, gt=0, description="Largest photo, in pixels, that is decoded.")
+    wound_image_thumbnail_pixels: int = Field(320, ge=32, le=2048, description="Longer side of generated thumbnails.")
+    wound_image_thumbnail_url_ttl_seconds: int = Field(300, gt=0, le=7 * 24 * 3600, description="Lifetime of signed thumbnail URLs.")
+
+    # --- Email Notifications ---
+    email_provider: Literal["none", "sendgrid", "smtp"] = Field("none", description="How transactional emails are sent; 'none' sends none.")
+    email_from_address: Optional[str] = Field(None, description="Sender of emails for organizations without their own sender identity.")
+    email_from_name: str = Field("MegaCare", description="Display name of the default sender.")
+    sendgrid_api_key: Optional[str] = Field(None, description="SendGrid API key with the Mail Send permission.")
+    sendgrid_webhook_public_key: Optional[str] = Field(None, description="Base64 verification key of the signed SendGrid Event Webhook; delivery events are refused when unset.")
+    smtp_host: Optional[str] = None
+    smtp_port: int = Field(587, gt=0, le=65535)
+    smtp_username: Optional[str] = None
+    smtp_password: Optional[str] = None
+    smtp_starttls: bool = Field(True, description="Upgrade SMTP connections with STARTTLS before logging in.")
+    email_timeout_seconds: float = Field(10.0, gt=0, description="How long to wait for the email provider to accept a message.")
+    patient_portal_url: Optional[str] = Field(None, description="Where emails tell patients to sign in, e.g. https://care.example.com.")
+    email_timezone: str = Field("UTC", description="IANA timezone appointment times are given in.")
+
+    # --- SMS Notifications ---
+    sms_provider: Literal["none", "twilio"] = Field("none", description="How text messages are sent; 'none' sends none.")
+    twilio_account_sid: Optional[str] = None
+    twilio_auth_token: Optional[str] = Field(None, description="Authenticates API calls and verifies Twilio's webhook signatures.")
+    twilio_from_number: Optional[str] = Field(None, description="E.164 number texts are sent from, unless a messaging service is set.")
+    twilio_messaging_service_sid: Optional[str] = Field(None, description="Messaging Service to send through instead of a single number.")
+    twilio_webhook_base_url: Optional[str] = Field(None, description="Public base URL of this API, e.g. https://api.megacare.example; Twilio signs webhook URLs with it.")
+    sms_timeout_seconds: float = Field(10.0, gt=0, description="How long to wait for Twilio to accept a message.")
+    sms_max_per_hour: int = Field(500, ge=1, description="Texts an organization may queue per hour; more are recorded as throttled and not sent.")
+    sms_max_per_patient_per_day: int = Field(5, ge=1, description="Texts a patient may be queued per day.")
+
+    # --- Push Notifications ---
+    push_provider: Literal["none", "fcm"] = Field("none", description="How push notifications reach the patient app; 'fcm' sends through the Firebase project's Cloud Messaging.")
+    push_ttl_seconds: int = Field(86400, ge=0, le=28 * 86400, description="How long FCM keeps trying to deliver to a device that is offline.")
+
+    # --- Telehealth ---
+    telehealth_provider: Literal["none", "twilio", "daily", "livekit"] = Field("none", description="Where video visits are held; 'none' refuses to open them.")
+    twilio_api_key_sid: Optional[str] = Field(None, description="API key that signs Twilio Video access tokens and creates rooms.")
+    twilio_api_key_secret: Optional[str] = None
+    daily_api_key: Optional[str] = None
+    daily_webhook_secret: Optional[str] = Field(None, description="Base64 HMAC secret of the Daily webhook; its events are refused when unset.")
+    livekit_url: Optional[str] = Field(None, description="The LiveKit server clients connect to, e.g. wss://megacare.livekit.cloud.")
+    livekit_api_key: Optional[str] = None
+    livekit_api_secret: Optional[str] = Field(None, description="Signs join tokens and verifies LiveKit's webhooks.")
+    telehealth_token_ttl_seconds: int = Field(600, ge=60, le=6 * 3600, description="How long a join token can be used to enter the room.")
+    telehealth_join_early_minutes: int = Field(15, ge=0, le=24 * 60, description="How long before the appointment participants may join.")
+    telehealth_room_grace_minutes: int = Field(60, ge=0, le=24 * 60, description="How long after the appointment's end the room stays open.")
+    telehealth_timeout_seconds: float = Field(10.0, gt=0, description="How long to wait for the video provider.")
+
+    # --- Insurance Eligibility ---
+    eligibility_provider: Literal["none", "change-healthcare"] = Field("none", description="The clearinghouse that answers eligibility checks; 'none' refuses them.")
+    change_healthcare_url: str = Field("https://apigw.changehealthcare.com", description="API gateway of Change Healthcare (Optum); the sandbox is https://sandbox.apigw.changehealthcare.com.")
+    change_healthcare_client_id: Optional[str] = None
+    change_healthcare_client_secret: Optional[str] = None
+    eligibility_timeout_seconds: float = Field(30.0, gt=0, description="How long to wait for the clearinghouse, which waits for the payer.")
+    eligibility_cache_seconds: int = Field(24 * 3600, ge=0, le=30 * 24 * 3600, description="How long a payer's answer is reused for the same coverage, date of service and service types; 0 always asks.")
+    billing_provider_npi: Optional[str] = Field(None, description="NPI of the provider checks and claims are made for, where the organization sets none.")
+    billing_provider_name: Optional[str] = None
+    billing_provider_tax_id: Optional[str] = Field(None, pattern=r"^[0-9]{9}$", description="EIN of the billing provider, which claims need.")
+    billing_provider_address_line: Optional[str] = Field(None, description="Street address of the billing provider, which claims need, with the city, state and ZIP+4 below.")
+    billing_provider_city: Optional[str] = None
+    billing_provider_state: Optional[str] = None
+    billing_provider_postal_code: Optional[str] = None
+
+    # --- Claims ---
+    claims_provider: Literal["none", "change-healthcare"] = Field("none", description="The clearinghouse claims are submitted through; 'none' keeps them as drafts.")
+    claim_status_check_hours: int = Field(24, ge=1, description="How often the payer is asked about each submitted claim until it is paid or denied.")
+
+    # --- Invoices & Payments ---
+    invoices_bucket: Optional[str] = Field(None, description="GCS bucket for the PDF statements of issued invoices. Statements are not rendered when unset.")
+    invoice_url_ttl_seconds: int = Field(300, gt=0, le=7 * 24 * 3600, description="Lifetime of signed statement download URLs.")
+    invoice_due_days: int = Field(30, ge=0, le=365, description="Days after issue an invoice is due, unless it names a due date.")
+    payments_provider: Literal["none", "stripe"] = Field("none", description="Where patients pay their invoices online; 'none' only records payments taken otherwise.")
+    stripe_secret_key: Optional[str] = None
+    stripe_webhook_secret: Optional[str] = Field(None, description="Signing secret of the Stripe webhook endpoint that reports payments.")
+    payment_return_url: Optional[str] = Field(None, description="Where the payment page sends patients back to when they have paid or given up.")
+    payments_timeout_seconds: float = Field(10.0, gt=0, description="How long to wait for the payment provider.")
+
+    # --- Reports ---
+    reports_timezone: str = Field("UTC", description="IANA timezone report days are counted in, unless a request names one.")
+    reports_max_days: int = Field(366, ge=1, description="Longest period a report may cover.")
+
+    # --- FHIR Bulk Export ---
+    fhir_export_bucket: Optional[str] = Field(None, description="GCS bucket that receives $export NDJSON files.")
+    fhir_export_url_ttl_seconds: int = Field(3600, gt=0, le=7 * 24 * 3600, description="Lifetime of signed download URLs.")
+
+    # --- Audit Export ---
+    audit_export_bucket: Optional[str] = Field(None, description="GCS bucket that receives CSV exports of the audit trail. Exports are refused when unset.")
+    audit_export_url_ttl_seconds: int = Field(900, gt=0, le=24 * 3600, description="Lifetime of signed audit export download URLs.")
+
+    # --- Bulk Import ---
+    import_bucket: Optional[str] = Field(None, description="GCS bucket holding uploaded import files and their error reports. Imports are refused when unset.")
+    import_max_bytes: int = Field(512 * 1024 * 1024, gt=0, description="Largest NDJSON file accepted by /import.")
+    import_chunk_lines: int = Field(500, ge=1, le=10000, description="Lines imported per task run; progress is saved after each chunk.")
+
+    # --- HL7v2 Integration ---
+    hl7v2_default_timezone: str = Field("UTC", description="IANA timezone for HL7 timestamps sent without an offset.")
+
+    # --- Immunizations ---
+    immunization_schedule_path: Optional[str] = Field(None, description="JSON schedule table used for forecasts; the bundled schedule if unset.")
+
+    # --- Terminology ---
+    terminology_path: Optional[str] = Field(None, description="FHIR CodeSystem and ValueSet JSON file, or directory of them, loaded besides the bundled ones.")
+    terminology_server_url: Optional[str] = Field(None, description="FHIR terminology server asked about LOINC, SNOMED CT and ICD-10 codes the loaded content lacks.")
+    terminology_timeout_seconds: float = Field(5.0, gt=0, description="How long to wait for the terminology server.")
+    terminology_cache_seconds: int = Field(86400, ge=1, description="How long the terminology server's answers are cached.")
+
+    # --- Full-Text Search ---
+    search_index: Literal["none", "embedded", "elasticsearch"] = Field(
+        "none", description="Where note text and document metadata are indexed for /search: an SQLite FTS5 index in the process, or Elasticsearch; 'none' turns search off.",
+    )
+    search_index_path: str = Field(":memory:", description="SQLite file of the embedded index; ':memory:' keeps it in the process only.")
+    elasticsearch_url: Optional[str] = Field(None, description="Elasticsearch (or OpenSearch) endpoint, e.g. https://search.example:9200.")
+    elasticsearch_index: str = Field("megacare-clinical-text", description="Index holding the searchable records.")
+    elasticsearch_api_key: Optional[str] = Field(None, description="Encoded API key sent as `Authorization: ApiKey ...`.")
+    search_timeout_seconds: float = Field(5.0, gt=0, description="How long to wait for Elasticsearch.")
+
+    # --- Authentication ---
+    auth_provider: Literal["firebase", "oidc"] = Field("firebase", description="Verify bearer tokens as Firebase ID tokens, or as JWTs of OIDC_ISSUER.")
+    oidc_issuer: Optional[str] = Field(None, description="Expected `iss`, e.g. https://securetoken.google.com/<project> or https://<tenant>.auth0.com/. Required when AUTH_PROVIDER=oidc.")
+    oidc_audience: Optional[str] = Field(None, description="Expected `aud`: the project ID for Identity Platform, the API identifier for Auth0. Required when AUTH_PROVIDER=oidc.")
+    oidc_jwks_url: Optional[str] = Field(None, description="The issuer's signing keys; discovered from its openid-configuration when unset.")
+    oidc_roles_claim: str = Field("roles", description="Claim that carries the user's roles, e.g. a namespaced claim for Auth0.")
+    oidc_jwks_cache_seconds: int = Field(3600, ge=60, description="How long fetched signing keys are used before they are fetched again.")
+
+    # --- SMART on FHIR ---
+    smart_authorization_endpoint: Optional[str] = Field(None, description="OAuth authorize URL advertised to SMART apps; discovered from OIDC_ISSUER when unset.")
+    smart_token_endpoint: Optional[str] = Field(None, description="OAuth token URL advertised to SMART apps; discovered from OIDC_ISSUER when unset.")
+
+    # --- API Keys ---
+    api_key_cache_seconds: int = Field(60, ge=0, description="How long verified API key records are reused; revocation takes effect within this time.")
+    api_key_default_rate_limit_per_minute: int = Field(600, ge=1, description="Requests per minute per worker for keys without their own limit.")
+
+    # --- Sessions ---
+    session_signing_key: Optional[str] = Field(None, description="Key (32+ characters) that signs session access tokens; the patient portal session endpoints are off when unset.")
+    session_access_token_ttl_seconds: int = Field(900, ge=60, le=3600, description="Lifetime of session access tokens.")
+    session_idle_timeout_seconds: int = Field(14 * 86400, ge=3600, description="A session whose refresh token is not used for this long ends.")
+    session_max_age_seconds: int = Field(90 * 86400, ge=3600, description="Sessions end this long after sign-in however often they are refreshed.")
+    session_cache_seconds: float = Field(30.0, ge=0, description="How long a session's status is reused when verifying its access tokens; those of a revoked session stop working within this time.")
+    magic_link_ttl_seconds: int = Field(900, ge=60, le=3600, description="How long an emailed sign-in link works.")
+    magic_link_max_per_address_per_hour: int = Field(5, ge=1, description="Sign-in links sent to one address per hour, across workers sharing the rate limit backend.")
+    magic_link_path: str = Field("/sign-in/link", description="Page of PATIENT_PORTAL_URL that sign-in links open; it exchanges the token in their fragment at /v1/auth/magic-link/exchange.")
+
+    # --- Multi-Factor Authentication ---
+    mfa_required_roles: str = Field("clinician,admin", description="Comma-separated roles that must prove a second factor; enforced once SESSION_SIGNING_KEY is set.")
+    mfa_step_up_max_age_seconds: int = Field(300, ge=30, description="How recently a second factor must have been proved for step-up operations such as bulk export.")
+    mfa_totp_issuer: str = Field("MegaCare", description="Account issuer shown in authenticator apps.")
+    mfa_sms_code_ttl_seconds: int = Field(300, ge=60, le=900, description="How long a text message code works.")
+    mfa_sms_max_per_hour: int = Field(5, ge=1, description="Text message codes sent to a user per hour.")
+    mfa_max_attempts: int = Field(5, ge=1, description="Codes a user may try per 15 minutes before being made to wait.")
+
+    # --- Sign-In Lockouts ---
+    lockout_free_attempts: int = Field(3, ge=1, description="Failed sign-in attempts allowed before each further one delays the next.")
+    lockout_base_delay_seconds: int = Field(2, ge=1, description="The delay after the first attempt past LOCKOUT_FREE_ATTEMPTS; it doubles with each further failure.")
+    lockout_max_delay_seconds: int = Field(60, ge=1, description="The longest delay between failed attempts short of a lockout.")
+    lockout_account_threshold: int = Field(10, ge=2, description="Failed attempts on one account within LOCKOUT_WINDOW_SECONDS that lock it out.")
+    lockout_address_threshold: int = Field(50, ge=2, description="Failed attempts from one address within LOCKOUT_WINDOW_SECONDS that lock it out; higher, as clinics share addresses.")
+    lockout_window_seconds: int = Field(900, ge=60, description="How long failed attempts count towards a lockout.")
+    lockout_duration_seconds: int = Field(900, ge=60, description="How long a lockout lasts, unless an administrator lifts it at /v1/admin/lockouts.")
+
+    # --- Compression ---
+    compression_enabled: bool = Field(True, description="Compress JSON and text responses for clients that accept it.")
+    compression_minimum_size: int = Field(1024, ge=0, description="Smallest body, in bytes, that is compressed.")
+    compression_gzip_level: int = Field(6, ge=1, le=9)
+    compression_brotli_enabled: bool = Field(True, description="Prefer brotli over gzip for clients that accept both.")
+
+    # --- CORS ---
+    cors_allow_origins: str = Field("*", description="Comma-separated browser origins allowed to call the API, e.g. https://portal.megacare.example; `*` for any.")
+    cors_allow_origin_regex: Optional[str] = Field(None, description="Also allow origins matching this regex, e.g. for preview deployments.")
+    cors_allow_methods: str = Field("*", description="Comma-separated methods allowed in cross-origin requests, e.g. GET,POST,PATCH; `*` for any.")
+    cors_allow_headers: str = Field("*", description="Comma-separated request headers browsers may send, e.g. Authorization,Content-Type; `*` for any.")
+    cors_allow_credentials: bool = Field(False, description="Let browsers send cookies and read responses of credentialed requests. Needs explicit origins.")
+    cors_max_age_seconds: int = Field(600, ge=0, le=86400, description="How long browsers may cache a preflight response.")
+
+    # --- Security Headers ---
+    security_headers_enabled: bool = Field(True, description="Send Strict-Transport-Security, X-Content-Type-Options, Content-Security-Policy, Referrer-Policy and Cache-Control headers.")
+    security_hsts_max_age_seconds: int = Field(31536000, ge=0, description="max-age of Strict-Transport-Security; 0 leaves the header out.")
+    security_referrer_policy: str = Field("no-referrer", description="Referrer-Policy sent with every response.")
+    security_no_store_prefixes: str = Field("/api,/fhir,/graphql,/integrations,/internal", description="Comma-separated route prefixes whose responses may hold patient data and are sent with Cache-Control: no-store.")
+    security_docs_prefixes: str = Field("/docs,/redoc,/graphql", description="Comma-separated route prefixes serving the documentation UIs, whose Content-Security-Policy allows the scripts and styles they load.")
+
+    # --- Source Address Allowlists ---
+    ip_allowlist_prefixes: str = Field("{api}/admin,/integrations/hl7v2", description="Comma-separated route prefixes whose requests must come from the organization's allowedSourceRanges; {api} stands for every served API version, e.g. /api/v1.")
+    ip_allowlist_default_ranges: str = Field("", description="Comma-separated CIDR ranges for organizations without their own and requests acting for none; empty allows any address.")
+    ip_allowlist_cache_seconds: float = Field(60.0, ge=0, description="How long an organization's ranges are cached per worker.")
+    trusted_proxy_hops: int = Field(1, ge=0, description="Proxies that append to X-Forwarded-For in front of the API: 1 for Cloud Run, 2 behind an external HTTPS load balancer, 0 to use the peer address.")
+
+    # --- Pagination ---
+    pagination_cursor_secret: Optional[str] = Field(None, description="Key that signs page cursors; a random per-process key if unset, so cursors only work on the instance that issued them.")
+
+    # --- Validation ---
+    phone_default_country_code: str = Field("66", pattern=r"^[1-9]\d{0,2}$", description="Calling code given to phone numbers sent in national format, e.g. 0812345678 -> +66812345678.")
+
+    # --- Rate Limiting ---
+    rate_limit_enabled: bool = Field(False, description="Limit requests per caller and route group with token buckets.")
+    rate_limit_rules: str = Field("/api/v1/auth=20:10,/api/v1=600:120,/fhir=600:120", description="Comma-separated `<route prefix>=<requests per minute>[:<burst>]`; the longest matching prefix applies.")
+    rate_limit_backend: Literal["memory", "redis"] = Field("memory", description="Count in each worker process, or in Redis (REDIS_URL) across all instances.")
+
+    # --- Load Shedding ---
+    load_shedding_enabled: bool = Field(False, description="Refuse requests with 503 while a worker has too many in flight.")
+    load_shedding_max_concurrency: int = Field(100, ge=1, description="Requests a worker admits at once while latency is on target.")
+    load_shedding_min_concurrency: int = Field(10, ge=1, description="The lowest the limit is cut to while latency is over target.")
+    load_shedding_latency_target_ms: int = Field(2000, ge=1, description="p99 latency (to the start of the response) above which the limit is cut.")
+    load_shedding_retry_after_seconds: int = Field(1, ge=1, description="Retry-After sent with refused requests.")
+
+    # --- Circuit Breakers ---
+    circuit_breaker_failure_threshold: int = Field(5, ge=1, description="Consecutive failed calls to a dependency after which its circuit opens.")
+    circuit_breaker_reset_seconds: float = Field(30.0, gt=0, description="How long a circuit stays open before a trial call is let through.")
+    bulkhead_max_concurrent_calls: int = Field(10, ge=1, description="Calls a worker may have in flight to each of Pub/Sub and Twilio.")
+    bulkhead_max_wait_seconds: float = Field(0.5, ge=0, description="How long a call waits for a free bulkhead slot before it is refused.")
+
+    # --- Outbound HTTP ---
+    http_client_max_attempts: int = Field(3, ge=1, description="Attempts an outbound idempotent request gets when the connection fails or the server answers 429, 502, 503 or 504.")
+    http_client_backoff_base_seconds: float = Field(0.2, gt=0, description="Delay before the first retry; doubled for each further retry, with full jitter.")
+    http_client_backoff_max_seconds: float = Field(5.0, gt=0, description="Longest delay between retries, including one asked for with Retry-After.")
+    http_client_connect_timeout_seconds: float = Field(3.0, gt=0, description="How long to wait for a connection; the client's own timeout applies to the rest of the request.")
+    http_client_max_connections: int = Field(50, ge=1, description="Connections each outbound client may hold open at once.")
+    http_client_max_keepalive_connections: int = Field(10, ge=0, description="Idle connections each outbound client keeps for reuse.")
+    http_client_keepalive_expiry_seconds: float = Field(30.0, gt=0, description="How long an idle connection is kept; below the idle timeout of most providers' load balancers.")
+
+    # --- Idempotency ---
+    idempotency_ttl_seconds: int = Field(24 * 60 * 60, ge=60, description="How long the response to a POST with an Idempotency-Key is replayed to retries.")
+
+    # --- Tenancy ---
+    tenancy_enabled: bool = Field(False, description="Scope records and routes to the organization in the caller's `organizationId` claim.")
+    default_organization_id: Optional[str] = Field(None, description="Organization of callers whose token names none, e.g. while a single clinic migrates; others are refused.")
+
+    # --- Custom Roles ---
+    custom_role_cache_seconds: int = Field(30, ge=0, description="How long an organization's custom roles are reused; changes reach other workers within this time.")
+
+    # --- Feature Flags ---
+    feature_flags: str = Field("", description="Comma-separated `<flag>=<on|off|percentage>[:<organization>+...]`; flags stored through /admin/flags take precedence.")
+    feature_flag_cache_seconds: int = Field(30, ge=0, description="How long stored flags are reused; changes reach other workers within this time.")
+
+    # --- Field Encryption ---
+    field_encryption_key: Optional[str] = Field(None, description="Cloud KMS key sensitive fields are sealed under, projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>; stored in plaintext when unset.")
+    field_encryption_data_key_seconds: int = Field(3600, ge=60, description="How long a worker seals with one data key before making a new one.")
+
+    # --- Data Retention ---
+    retention_defaults: str = Field("", description="Comma-separated `<category>=<days>[:delete|anonymize]` for telemetry, audit and messages; organizations may override them. Unlisted categories are kept.")
+
+    # --- Analytics ---
+    analytics_sink: Literal["none", "bigquery", "pubsub"] = Field("none", description="Where de-identified usage and clinical-operations rows are exported: streamed into ANALYTICS_TABLE, or published to ANALYTICS_TOPIC for a BigQuery subscription.")
+    analytics_table: Optional[str] = Field(None, description="BigQuery table rows are streamed into, `<project>.<dataset>.<table>`.")
+    analytics_topic: Optional[str] = Field(None, description="Topic ID or full path whose BigQuery subscription writes the analytics table.")
+    analytics_pseudonym_key: Optional[str] = Field(None, description="Secret that IDs are pseudonymised with; keep it from the data team, and never change it, or pseudonyms stop matching.")
+    analytics_usage_sample_rate: float = Field(1.0, ge=0, le=1, description="Share of API requests exported as usage rows; domain events are always exported.")
+    analytics_flush_seconds: float = Field(5.0, gt=0, description="Pause between exports while fewer than a batch of rows are queued.")
+    analytics_batch_size: int = Field(500, ge=1, le=500)
+    analytics_queue_size: int = Field(10000, ge=1, description="Rows a worker holds for export; more are dropped and counted.")
+
+    # --- LINE Login ---
+    line_channel_id: Optional[str] = None
+    line_channel_secret: Optional[str] = None
+
+    model_config = SettingsConfigDict(env_file=".env", extra="ignore")
+
+    @model_validator(mode="before")
+    @classmethod
+    def _uppercase_log_level(cls, data):
+        if isinstance(data, dict):
+            for key in ("log_level", "LOG_LEVEL"):
+                if isinstance(data.get(key), str):
+                    data[key] = data[key].upper()
+        return data
+
+    @field_validator("hl7v2_default_timezone", "email_timezone", "reports_timezone")
+    @classmethod
+    def _validate_timezone(cls, value: str) -> str:
+        try:
+            ZoneInfo(value)
+        except (ZoneInfoNotFoundError, ValueError):
+            raise ValueError(f"unknown timezone '{value}'")
+        return value
+
+    @field_validator("appointment_reminder_hours")
+    @classmethod
+    def _validate_reminder_hours(cls, value: str) -> str:
+        for item in _split_list(value):
+            if not item.isdigit() or not 1 <= int(item) <= 30 * 24:
+                raise ValueError(f"reminder hours must be whole hours from 1 to 720, not '{item}'")
+        return value
+
+    @field_validator("appointment_reminder_quiet_hours")
+    @classmethod
+    def _validate_quiet_hours(cls, value: str) -> str:
+        if value.strip() and not re.fullmatch(r"\s*([01]?\d|2[0-3])\s*-\s*([01]?\d|2[0-3])\s*", value):
+            raise ValueError(f"quiet hours must be 'start-end' in hours 0-23, e.g. 21-8, not '{value}'")
+        return value
+
+    @field_validator("immunization_schedule_path")
+    @classmethod
+    def _validate_schedule_path(cls, value: Optional[str]) -> Optional[str]:
+        if value and not os.path.isfile(value):
+            raise ValueError(f"schedule file '{value}' does not exist")
+        return value
+
+    @field_validator("terminology_path")
+    @classmethod
+    def _validate_terminology_path(cls, value: Optional[str]) -> Optional[str]:
+        if value and not os.path.exists(value):
+            raise ValueError(f"terminology path '{value}' does not exist")
+        return value
+
+    @field_validator("api_default_version")
+    @classmethod
+    def _validate_api_version(cls, value: str) -> str:
+        from app.api.versioning import API_VERSIONS, normalize_version
+        version = normalize_version(value)
+        if version not in API_VERSIONS:
+            raise ValueError(f"'{value}' is not a served API version ({', '.join(API_VERSIONS)})")
+        return version
+
+    @field_validator("access_log_sample_rules")
+    @classmethod
+    def _validate_access_log_sample_rules(cls, value: str) -> str:
+        from app.core.access_log import parse_sample_rules
+        parse_sample_rules(value)
+        return value
+
+    @field_validator("ip_allowlist_default_ranges")
+    @classmethod
+    def _validate_ip_allowlist_default_ranges(cls, value: str) -> str:
+        from app.authz.networks import parse_ranges
+        parse_ranges(_split_list(value))
+        return value
+
+    @field_validator("feature_flags")
+    @classmethod
+    def _validate_feature_flags(cls, value: str) -> str:
+        from app.flags.flags import parse_flags
+        parse_flags(value)
+        return value
+
+    @field_validator("field_encryption_key")
+    @classmethod
+    def _validate_field_encryption_key(cls, value: Optional[str]) -> Optional[str]:
+        if value and not re.fullmatch(r"projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+", value):
+            raise ValueError("must be a CryptoKey resource name without a version, projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>")
+        return value
+
+    @field_validator("retention_defaults")
+    @classmethod
+    def _validate_retention_defaults(cls, value: str) -> str:
+        from app.retention.policies import parse_retention
+        parse_retention(value)
+        return value
+
+    @model_validator(mode="after")
+    def _require_database_url(self):
+        if self.store == "postgres" and not self.database_url:
+            raise ValueError("DATABASE_URL is required when STORE=postgres")
+        return self
+
+    @model_validator(mode="after")
+    def _require_oidc_issuer(self):
+        if self.auth_provider == "oidc" and not (self.oidc_issuer and self.oidc_audience):
+            raise ValueError("OIDC_ISSUER and OIDC_AUDIENCE are required when AUTH_PROVIDER=oidc")
+        return self
+
+    @model_validator(mode="after")
+    def _require_tasks_callback(self):
+        if self.tasks_queue and not (self.tasks_handler_url and self.tasks_service_account):
+            raise ValueError("TASKS_HANDLER_URL and TASKS_SERVICE_ACCOUNT are required when TASKS_QUEUE is set")
+        return self
+
+    @model_validator(mode="after")
+    def _require_scheduler_audience(self):
+        if self.scheduler_service_account and not self.scheduler_audience:
+            raise ValueError("SCHEDULER_AUDIENCE is required when SCHEDULER_SERVICE_ACCOUNT is set")
+        return self
+
+    @model_validator(mode="after")
+    def _require_explicit_cors_origins(self):
+        if self.cors_allow_credentials and "*" in self.cors_origins:
+            raise ValueError("CORS_ALLOW_ORIGINS must list the origins when CORS_ALLOW_CREDENTIALS=true")
+        return self
+
+    @model_validator(mode="after")
+    def _require_rate_limit_store(self):
+        if self.rate_limit_backend == "redis" and not self.redis_url:
+            raise ValueError("REDIS_URL is required when RATE_LIMIT_BACKEND=redis")
+        return self
+
+    @model_validator(mode="after")
+    def _require_websocket_settings(self):
+        if self.websocket_backend == "redis" and not self.redis_url:
+            raise ValueError("REDIS_URL is required when WEBSOCKET_BACKEND=redis")
+        if self.websocket_idle_timeout_seconds <= self.websocket_heartbeat_seconds:
+            raise ValueError("WEBSOCKET_IDLE_TIMEOUT_SECONDS must be longer than WEBSOCKET_HEARTBEAT_SECONDS")
+        return self
+
+    @model_validator(mode="after")
+    def _check_session_signing_key(self):
+        if self.session_signing_key is not None and len(self.session_signing_key) < 32:
+            raise ValueError("SESSION_SIGNING_KEY must be at least 32 characters")
+        return self
+
+    @model_validator(mode="after")
+    def _require_email_provider_settings(self):
+        if self.email_provider == "none":
+            return self
+        if not self.email_from_address:
+            raise ValueError("EMAIL_FROM_ADDRESS is required when EMAIL_PROVIDER is set")
+        if self.email_provider == "sendgrid" and not self.sendgrid_api_key:
+            raise ValueError("SENDGRID_API_KEY is required when EMAIL_PROVIDER=sendgrid")
+        if self.email_provider == "smtp" and not self.smtp_host:
+            raise ValueError("SMTP_HOST is required when EMAIL_PROVIDER=smtp")
+        return self
+
+    @model_validator(mode="after")
+    def _require_sms_provider_settings(self):
+        if self.sms_provider == "twilio":
+            if not self.twilio_account_sid or not self.twilio_auth_token:
+                raise ValueError("TWILIO_ACCOUNT_SID and TWILIO_AUTH_TOKEN are required when SMS_PROVIDER=twilio")
+            if not self.twilio_from_number and not self.twilio_messaging_service_sid:
+                raise ValueError("TWILIO_FROM_NUMBER or TWILIO_MESSAGING_SERVICE_SID is required when SMS_PROVIDER=twilio")
+        return self
+
+    @model_validator(mode="after")
+    def _require_telehealth_provider_settings(self):
+        if self.telehealth_provider == "twilio" and not (self.twilio_account_sid and self.twilio_api_key_sid and self.twilio_api_key_secret):
+            raise ValueError("TWILIO_ACCOUNT_SID, TWILIO_API_KEY_SID and TWILIO_API_KEY_SECRET are required when TELEHEALTH_PROVIDER=twilio")
+        if self.telehealth_provider == "daily" and not self.daily_api_key:
+            raise ValueError("DAILY_API_KEY is required when TELEHEALTH_PROVIDER=daily")
+        if self.telehealth_provider == "livekit" and not (self.livekit_url and self.livekit_api_key and self.livekit_api_secret):
+            raise ValueError("LIVEKIT_URL, LIVEKIT_API_KEY and LIVEKIT_API_SECRET are required when TELEHEALTH_PROVIDER=livekit")
+        return self
+
+    @model_validator(mode="after")
+    def _require_clearinghouse_settings(self):
+        for name, provider in (("ELIGIBILITY_PROVIDER", self.eligibility_provider), ("CLAIMS_PROVIDER", self.claims_provider)):
+            if provider == "change-healthcare" and not (self.change_healthcare_client_id and self.change_healthcare_client_secret):
+                raise ValueError(f"CHANGE_HEALTHCARE_CLIENT_ID and CHANGE_HEALTHCARE_CLIENT_SECRET are required when {name}=change-healthcare")
+        return self
+
+    @model_validator(mode="after")
+    def _require_payments_settings(self):
+        if self.payments_provider == "stripe" and not (self.stripe_secret_key and self.stripe_webhook_secret and self.payment_return_url):
+            raise ValueError("STRIPE_SECRET_KEY, STRIPE_WEBHOOK_SECRET and PAYMENT_RETURN_URL are required when PAYMENTS_PROVIDER=stripe")
+        return self
+
+    @model_validator(mode="after")
+    def _require_complete_billing_address(self):
+        parts = (self.billing_provider_address_line, self.billing_provider_city, self.billing_provider_state, self.billing_provider_postal_code)
+        if any(parts) and not all(parts):
+            raise ValueError("BILLING_PROVIDER_ADDRESS_LINE, BILLING_PROVIDER_CITY, BILLING_PROVIDER_STATE and BILLING_PROVIDER_POSTAL_CODE are set together")
+        return self
+
+    @model_validator(mode="after")
+    def _require_search_index_settings(self):
+        if self.search_index == "elasticsearch" and not self.elasticsearch_url:
+            raise ValueError("ELASTICSEARCH_URL is required when SEARCH_INDEX=elasticsearch")
+        return self
+
+    @model_validator(mode="after")
+    def _require_analytics_settings(self):
+        if self.analytics_sink == "none":
+            return self
+        if not self.analytics_pseudonym_key:
+            raise ValueError("ANALYTICS_PSEUDONYM_KEY is required when ANALYTICS_SINK is set")
+        if self.analytics_sink == "bigquery" and not self.analytics_table:
+            raise ValueError("ANALYTICS_TABLE is required when ANALYTICS_SINK=bigquery")
+        if self.analytics_sink == "pubsub" and not self.analytics_topic:
+            raise ValueError("ANALYTICS_TOPIC is required when ANALYTICS_SINK=pubsub")
+        return self
+
+    @model_validator(mode="after")
+    def _apply_cloud_run_defaults(self):
+        on_cloud_run = bool(self.k_service)
+        if self.log_format is None:
+            self.log_format = "json" if on_cloud_run else "text"
+        if self.tracing_enabled is None:
+            self.tracing_enabled = on_cloud_run
+        return self
+
+    @property
+    def service_auth_callers(self) -> List[str]:
+        return _split_list(self.service_auth_allowed_callers)
+
+    @property
+    def reminder_offsets_hours(self) -> List[int]:
+        return sorted({int(item) for item in _split_list(self.appointment_reminder_hours)}, reverse=True)
+
+    @property
+    def reminder_quiet_hours(self) -> Optional[Tuple[int, int]]:
+        if not self.appointment_reminder_quiet_hours.strip():
+            return None
+        start, end = (int(part) for part in self.appointment_reminder_quiet_hours.split("-"))
+        return (start, end) if start != end else None
+
+    @property
+    def no_store_prefixes(self) -> List[str]:
+        return _split_list(self.security_no_store_prefixes)
+
+    @property
+    def docs_prefixes(self) -> List[str]:
+        return _split_list(self.security_docs_prefixes)
+
+    @property
+    def mfa_required_role_list(self) -> List[str]:
+        return _split_list(self.mfa_required_roles)
+
+    @property
+    def allowlist_prefixes(self) -> List[str]:
+        return _split_list(self.ip_allowlist_prefixes)
+
+    @property
+    def allowlist_default_ranges(self) -> List[str]:
+        return _split_list(self.ip_allowlist_default_ranges)
+
+    @property
+    def cors_origins(self) -> List[str]:
+        return _split_list(self.cors_allow_origins)
+
+    @property
+    def cors_methods(self) -> List[str]:
+        return _split_list(self.cors_allow_methods)
+
+    @property
+    def cors_headers(self) -> List[str]:
+        return _split_list(self.cors_allow_headers)
+
+    @property
+    def service_name(self) -> str:
+        return self.k_service or "mega-care-api"
+
+    @property
+    def service_version(self) -> str:
+        return self.k_revision or "local"
+
+
+def _secret_version_name(reference: str, project: Optional[str]) -> str:
+    path = reference[len(SECRET_REFERENCE_PREFIX):]
+    if path.startswith("projects/"):
+        return path if "/versions/" in path else f"{path}/versions/latest"
+    if not project:
+        raise ValueError(f"Cannot resolve secret reference '{reference}' without GOOGLE_CLOUD_PROJECT.")
+    return f"projects/{project}/secrets/{path}/versions/latest"
+
+
+def resolve_secret_references(settings: Settings, client=None) -> Settings:
+    """
+    Replaces every `sm://...` string value with the payload of the referenced
+    Secret Manager secret version. Raises if a secret cannot be read, so a
+    misconfigured revision fails its startup probe rather than serving traffic.
+    """
+    references = {
+        name: value for name, value in settings.model_dump().items()
+        if isinstance(value, str) and value.startswith(SECRET_REFERENCE_PREFIX)
+    }
+    if not references:
+        return settings
+
+    if client is None:
+        from google.cloud import secretmanager
+        client = secretmanager.SecretManagerServiceClient()
+
+    resolved = {}
+    for field_name, reference in references.items():
+        version_name = _secret_version_name(reference, settings.google_cloud_project)
+        response = client.access_secret_version(request={"name": version_name})
+        resolved[field_name] = response.payload.data.decode("utf-8")
+        logging.info(f"Resolved setting '{field_name}' from Secret Manager.")
+    return settings.model_copy(update=resolved)
+
+
+@lru_cache
+def get_settings() -> Settings:
+    """Returns the process-wide settings, loading and resolving them on first use."""
+    return resolve_secret_references(Settings())
@@ -0,0 +1,148 @@
+# Location: app/core/diagnostics.py
+
+import asyncio
+import gc
+import io
+import os
+import sys
+import threading
+import time
+import traceback
+import tracemalloc
+from collections import Counter
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional
+
+from app.core.config import get_settings
+
+# --- Runtime Diagnostics ---
+# What a live worker is doing, for profiling latency spikes on a running
+# revision: the stack of every thread and asyncio task, runtime counters, a
+# sampled CPU profile and, when the server runs with PYTHONTRACEMALLOC, the
+# biggest allocations. Each call describes the worker process that answers
+# it; Cloud Run instances and Gunicorn workers are not aggregated.
+PROFILE_MAX_SECONDS = 60
+PROFILE_INTERVAL_SECONDS = 0.01
+
+_STARTED_AT = datetime.now(timezone.utc)
+
+
+def _thread_names() -> Dict[int, str]:
+    return {thread.ident: thread.name for thread in threading.enumerate() if thread.ident is not None}
+
+
+def stack_dump() -> str:
+    """
+    The current stack of every thread, then of every asyncio task of the
+    running loop (if called from it), as text - the counterpart of a
+    goroutine dump.
+    """
+    out = io.StringIO()
+    names = _thread_names()
+    frames = sys._current_frames()
+    out.write(f"{len(frames)} threads\n\n")
+    for ident, frame in frames.items():
+        out.write(f"Thread {names.get(ident, '?')} ({ident}):\n")
+        out.write("".join(traceback.format_stack(frame)))
+        out.write("\n")
+    try:
+        tasks = asyncio.all_tasks()
+    except RuntimeError:
+        tasks = set()
+    out.write(f"{len(tasks)} asyncio tasks\n\n")
+    for task in tasks:
+        out.write(f"Task {task.get_name()}:\n")
+        task.print_stack(file=out)
+        out.write("\n")
+    return out.getvalue()
+
+
+def _open_file_descriptors() -> Optional[int]:
+    try:
+        return len(os.listdir("/proc/self/fd"))
+    except OSError:
+        return None
+
+
+def _max_rss_bytes() -> Optional[int]:
+    try:
+        import resource
+    except ImportError:
+        return None
+    # ru_maxrss is in kilobytes on Linux.
+    return resource.getrusage(resource.RUSAGE_SELF).ru_maxrss * 1024
+
+
+def runtime_vars() -> Dict[str, Any]:
+    """Process and interpreter counters, the counterpart of expvar."""
+    settings = get_settings()
+    try:
+        asyncio_tasks = len(asyncio.all_tasks())
+    except RuntimeError:
+        asyncio_tasks = None
+    traced, peak = tracemalloc.get_traced_memory() if tracemalloc.is_tracing() else (None, None)
+    return {
+        "python": sys.version,
+        "pid": os.getpid(),
+        "cmdline": sys.argv,
+        "revision": settings.k_revision,
+        "startedAt": _STARTED_AT.isoformat(),
+        "uptimeSeconds": round((datetime.now(timezone.utc) - _STARTED_AT).total_seconds(), 3),
+        "threads": threading.active_count(),
+        "asyncioTasks": asyncio_tasks,
+        "maxRssBytes": _max_rss_bytes(),
+        "openFileDescriptors": _open_file_descriptors(),
+        "gc": {
+            "counts": list(gc.get_count()),
+            "thresholds": list(gc.get_threshold()),
+            "collections": [generation["collections"] for generation in gc.get_stats()],
+            "uncollectable": sum(generation["uncollectable"] for generation in gc.get_stats()),
+        },
+        "tracemalloc": {"tracing": tracemalloc.is_tracing(), "tracedBytes": traced, "peakBytes": peak},
+    }
+
+
+def _folded(frame) -> str:
+    names = []
+    while frame is not None:
+        code = frame.f_code
+        names.append(f"{code.co_name} ({os.path.basename(code.co_filename)})")
+        frame = frame.f_back
+    return ";".join(reversed(names))
+
+
+def sample_profile(seconds: float, interval: float = PROFILE_INTERVAL_SECONDS) -> str:
+    """
+    Samples the stack of every other thread each `interval` for `seconds`
+    and returns the samples in the folded format of flame graph tools
+    (speedscope, flamegraph.pl, Pyroscope): one `outer;...;inner count`
+    line per distinct stack, most frequent first. The event loop thread is
+    sampled too, so time spent in async handlers shows.
+    """
+    me = threading.get_ident()
+    names = _thread_names()
+    samples: Counter = Counter()
+    deadline = time.monotonic() + seconds
+    while time.monotonic() < deadline:
+        for ident, frame in sys._current_frames().items():
+            if ident != me:
+                samples[f"{names.get(ident, ident)};{_folded(frame)}"] += 1
+        time.sleep(interval)
+    return "".join(f"{stack} {count}\n" for stack, count in samples.most_common())
+
+
+def heap_top(limit: int) -> Dict[str, Any]:
+    """
+    The `limit` source lines holding the most memory allocated since tracing
+    started. Raises RuntimeError unless tracemalloc is tracing, which
+    PYTHONTRACEMALLOC=<frames> turns on at start-up.
+    """
+    if not tracemalloc.is_tracing():
+        raise RuntimeError("Allocations are not traced; start the server with PYTHONTRACEMALLOC=<frames>")
+    traced, peak = tracemalloc.get_traced_memory()
+    statistics = tracemalloc.take_snapshot().statistics("lineno")
+    allocations: List[Dict[str, Any]] = [
+        {"location": str(stat.traceback[0]), "sizeBytes": stat.size, "count": stat.count}
+        for stat in statistics[:limit]
+    ]
+    return {"tracedBytes": traced, "peakBytes": peak, "allocations": allocations}
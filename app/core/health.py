@@ -0,0 +1,96 @@
+# Location: app/core/health.py
+
+import time
+import asyncio
+import logging
+from typing import Callable, Dict, List, Tuple
+
+from starlette.concurrency import run_in_threadpool
+from firebase_admin import firestore
+
+from app.core.config import get_settings
+
+# --- Configuration ---
+# Optional dependencies are only checked when the corresponding setting is present.
+settings = get_settings()
+HEALTH_CHECK_TIMEOUT_SECONDS = settings.health_check_timeout_seconds
+HEALTH_PUBSUB_TOPIC = settings.health_pubsub_topic  # e.g. projects/p/topics/t
+HEALTH_SECRET_NAME = settings.health_secret_name  # e.g. projects/p/secrets/s
+STORE = settings.store
+
+# A checker is a blocking callable that raises if its dependency is unhealthy.
+HealthCheck = Callable[[], None]
+
+_checks: Dict[str, HealthCheck] = {}
+
+
+def register_check(name: str, check: HealthCheck) -> None:
+    """
+    Registers (or replaces) a readiness check under `name`.
+    Checks run concurrently on every /readyz call.
+    """
+    _checks[name] = check
+
+
+def registered_checks() -> List[Tuple[str, HealthCheck]]:
+    return list(_checks.items())
+
+
+async def _run_check(name: str, check: HealthCheck) -> Dict:
+    started = time.perf_counter()
+    # The probe is unauthenticated, so why a check failed (which may name
+    # hosts or carry driver errors) is only logged.
+    try:
+        await asyncio.wait_for(run_in_threadpool(check), timeout=HEALTH_CHECK_TIMEOUT_SECONDS)
+        result = {"status": "ok"}
+    except asyncio.TimeoutError:
+        logging.warning(f"Readiness check '{name}' timed out after {HEALTH_CHECK_TIMEOUT_SECONDS}s.")
+        result = {"status": "error"}
+    except Exception as e:
+        logging.warning(f"Readiness check '{name}' failed: {e}")
+        result = {"status": "error"}
+    result["latencyMs"] = round((time.perf_counter() - started) * 1000, 1)
+    return result
+
+
+async def run_checks() -> Tuple[bool, Dict[str, Dict]]:
+    """Runs all registered checks and returns (all_ok, per-check results)."""
+    checks = registered_checks()
+    results = await asyncio.gather(*(_run_check(name, check) for name, check in checks))
+    by_name = {name: result for (name, _), result in zip(checks, results)}
+    return all(r["status"] == "ok" for r in results), by_name
+
+
+# --- Built-in Checks ---
+
+def check_firestore() -> None:
+    """Performs a minimal read to confirm Firestore is reachable with our credentials."""
+    db = firestore.client()
+    list(db.collection("customers").limit(1).stream())
+
+
+def check_pubsub_topic(topic_path: str) -> HealthCheck:
+    def check() -> None:
+        from google.cloud import pubsub_v1
+        pubsub_v1.PublisherClient().get_topic(request={"topic": topic_path})
+    return check
+
+
+def check_secret_manager(secret_name: str) -> HealthCheck:
+    def check() -> None:
+        from google.cloud import secretmanager
+        secretmanager.SecretManagerServiceClient().get_secret(request={"name": secret_name})
+    return check
+
+
+def register_default_checks() -> None:
+    """Registers the checks for the dependencies this service is configured to use."""
+    if STORE != "memory":
+        register_check("firestore", check_firestore)
+    if STORE == "postgres":
+        from app.core.postgres import check_postgres
+        register_check("postgres", check_postgres)
+    if HEALTH_PUBSUB_TOPIC:
+        register_check("pubsub", check_pubsub_topic(HEALTH_PUBSUB_TOPIC))
+    if HEALTH_SECRET_NAME:
+        register_check("secretmanager", check_secret_manager(HEALTH_SECRET_NAME))
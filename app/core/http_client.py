@@ -0,0 +1,178 @@
+# Location: app/core/http_client.py
+
+import logging
+import random
+import time
+from datetime import datetime, timezone
+from email.utils import parsedate_to_datetime
+from typing import Callable, Dict, Optional
+
+import httpx
+from opentelemetry import propagate
+
+from app.core.config import get_settings
+from app.core.metrics import HTTP_CLIENT_REQUESTS_TOTAL, HTTP_CLIENT_RETRIES_TOTAL
+from app.core.request_context import REQUEST_ID_HEADER, get_request_id, get_span_id, get_trace_id
+
+# --- Configuration ---
+settings = get_settings()
+MAX_ATTEMPTS = settings.http_client_max_attempts
+BACKOFF_BASE_SECONDS = settings.http_client_backoff_base_seconds
+BACKOFF_MAX_SECONDS = settings.http_client_backoff_max_seconds
+CONNECT_TIMEOUT_SECONDS = settings.http_client_connect_timeout_seconds
+
+# Methods a server must be able to receive twice with the same effect, so
+# a request whose response was lost can be sent again.
+IDEMPOTENT_METHODS = frozenset({"GET", "HEAD", "OPTIONS", "PUT", "DELETE"})
+
+# Answers that say the server, or something in front of it, is busy or
+# restarting rather than that the request was wrong.
+RETRYABLE_STATUSES = frozenset({429, 502, 503, 504})
+
+# Failures before the request left this process; retrying these cannot
+# repeat its effect, whatever the method.
+UNSENT_ERRORS = (httpx.ConnectError, httpx.ConnectTimeout, httpx.PoolTimeout)
+
+# Failures after which the server may or may not have acted on the request.
+RETRYABLE_ERRORS = (httpx.TimeoutException, httpx.NetworkError, httpx.RemoteProtocolError)
+
+
+def retry_after_seconds(value: Optional[str], now: Callable[[], datetime] = lambda: datetime.now(timezone.utc)) -> Optional[float]:
+    """Parses a `Retry-After` header given as seconds or as an HTTP date."""
+    if not value:
+        return None
+    value = value.strip()
+    if value.isdigit():
+        return float(value)
+    try:
+        return max(0.0, (parsedate_to_datetime(value) - now()).total_seconds())
+    except (TypeError, ValueError):
+        return None
+
+
+class RetryTransport(httpx.BaseTransport):
+    """
+    Sends each request through `transport`, retrying with exponential
+    backoff and full jitter when an idempotent request fails to get an
+    answer or is answered 429, 502, 503 or 504. Any request is retried when
+    its connection could not be made, since nothing was sent. A
+    `Retry-After` on the answer is honoured up to `backoff_max_seconds`.
+    """
+
+    def __init__(
+        self,
+        name: str,
+        transport: httpx.BaseTransport,
+        max_attempts: int = MAX_ATTEMPTS,
+        backoff_base_seconds: float = BACKOFF_BASE_SECONDS,
+        backoff_max_seconds: float = BACKOFF_MAX_SECONDS,
+        sleep: Callable[[float], None] = time.sleep,
+        jitter: Callable[[], float] = random.random,
+    ):
+        self.name = name
+        self.transport = transport
+        self.max_attempts = max_attempts
+        self.backoff_base_seconds = backoff_base_seconds
+        self.backoff_max_seconds = backoff_max_seconds
+        self.sleep = sleep
+        self.jitter = jitter
+
+    def backoff(self, attempt: int) -> float:
+        """The delay before retrying after failed attempt number `attempt`."""
+        return self.jitter() * min(self.backoff_max_seconds, self.backoff_base_seconds * 2 ** (attempt - 1))
+
+    @staticmethod
+    def _replayable(request: httpx.Request) -> bool:
+        # A streamed body has been consumed by the first attempt.
+        return isinstance(request.stream, httpx.ByteStream)
+
+    def handle_request(self, request: httpx.Request) -> httpx.Response:
+        idempotent = request.method in IDEMPOTENT_METHODS
+        attempt = 1
+        while True:
+            try:
+                response = self.transport.handle_request(request)
+            except RETRYABLE_ERRORS as e:
+                HTTP_CLIENT_REQUESTS_TOTAL.labels(client=self.name, method=request.method, outcome="error").inc()
+                retryable = isinstance(e, UNSENT_ERRORS) or idempotent
+                if attempt >= self.max_attempts or not retryable or not self._replayable(request):
+                    raise
+                delay = self.backoff(attempt)
+                reason = type(e).__name__
+            else:
+                HTTP_CLIENT_REQUESTS_TOTAL.labels(client=self.name, method=request.method, outcome=str(response.status_code)).inc()
+                if (
+                    attempt >= self.max_attempts or not idempotent
+                    or response.status_code not in RETRYABLE_STATUSES or not self._replayable(request)
+                ):
+                    return response
+                asked = retry_after_seconds(response.headers.get("Retry-After"))
+                delay = min(asked, self.backoff_max_seconds) if asked is not None else self.backoff(attempt)
+                reason = str(response.status_code)
+                response.close()
+            HTTP_CLIENT_RETRIES_TOTAL.labels(client=self.name, method=request.method).inc()
+            logging.info(f"Retrying {request.method} to {self.name} in {delay:.2f}s after attempt {attempt} failed ({reason}).")
+            self.sleep(delay)
+            attempt += 1
+
+    def close(self) -> None:
+        self.transport.close()
+
+
+def propagate_context(request: httpx.Request) -> None:
+    """
+    Request hook that passes the current request's trace context and
+    request ID on, so the call shows up under this request in Cloud Trace
+    and in the other service's logs. With tracing on, OpenTelemetry's
+    propagators write the headers (and the httpx instrumentation replaces
+    them with those of its client span); otherwise the upstream trace, if
+    any, is passed on unsampled.
+    """
+    propagate.inject(request.headers)
+    trace_id, span_id = get_trace_id(), get_span_id()
+    if "traceparent" not in request.headers and trace_id and span_id:
+        request.headers["traceparent"] = f"00-{trace_id}-{span_id}-00"
+    request_id = get_request_id()
+    if request_id and REQUEST_ID_HEADER not in request.headers:
+        request.headers[REQUEST_ID_HEADER] = request_id
+
+
+def new_client(
+    name: str,
+    timeout: float,
+    *,
+    base_url: str = "",
+    headers: Optional[Dict[str, str]] = None,
+    auth: Optional[httpx.Auth] = None,
+    follow_redirects: bool = False,
+    max_attempts: int = MAX_ATTEMPTS,
+    transport: Optional[httpx.BaseTransport] = None,
+) -> httpx.Client:
+    """
+    The httpx client every integration uses to call out, instead of a bare
+    `httpx.Client()` or `httpx.get()`:
+
+        client = new_client("terminology", settings.terminology_timeout_seconds)
+
+    `name` labels its metrics and log entries. Requests get `timeout`
+    (connections at most CONNECT_TIMEOUT_SECONDS of it), retries as in
+    RetryTransport, the caller's trace context, and a pooled connection
+    within the HTTP_CLIENT_* limits. Redirects are not followed unless asked
+    for, so credentials are never sent on to another host. Tests pass a
+    `transport` such as httpx.MockTransport.
+    """
+    if transport is None:
+        transport = httpx.HTTPTransport(limits=httpx.Limits(
+            max_connections=settings.http_client_max_connections,
+            max_keepalive_connections=settings.http_client_max_keepalive_connections,
+            keepalive_expiry=settings.http_client_keepalive_expiry_seconds,
+        ))
+    return httpx.Client(
+        base_url=base_url,
+        headers=headers,
+        auth=auth,
+        timeout=httpx.Timeout(timeout, connect=min(timeout, CONNECT_TIMEOUT_SECONDS)),
+        follow_redirects=follow_redirects,
+        transport=RetryTransport(name, transport, max_attempts=max_attempts),
+        event_hooks={"request": [propagate_context]},
+    )
@@ -0,0 +1,155 @@
+# Location: app/core/logging_config.py
+
+import json
+import logging
+from datetime import datetime, timezone
+
+from app.core.access_log import ACCESS_LOGGER
+from app.core.config import get_settings
+from app.core.redaction import RedactionLogFilter
+from app.core.request_context import RequestContextLogFilter
+
+# --- Configuration ---
+# LOG_FORMAT is "json" on Cloud Run and "text" locally unless set explicitly.
+settings = get_settings()
+LOG_LEVEL = settings.log_level
+LOG_FORMAT = settings.log_format
+GOOGLE_CLOUD_PROJECT = settings.google_cloud_project
+
+# Special fields recognised by the Cloud Logging agent when parsing JSON payloads.
+# See https://cloud.google.com/logging/docs/structured-logging
+TRACE_KEY = "logging.googleapis.com/trace"
+SPAN_ID_KEY = "logging.googleapis.com/spanId"
+LABELS_KEY = "logging.googleapis.com/labels"
+SOURCE_LOCATION_KEY = "logging.googleapis.com/sourceLocation"
+# Setting this @type on an entry makes Error Reporting group it as an error
+# event even if the message does not look like a stack trace.
+# See https://cloud.google.com/error-reporting/docs/formatting-error-messages
+ERROR_REPORT_TYPE = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+
+def _default_labels() -> dict:
+    """Labels attached to every log entry to identify the emitting service revision."""
+    labels = {}
+    if settings.k_service:
+        labels["service"] = settings.k_service
+    if settings.k_revision:
+        labels["revision"] = settings.k_revision
+    return labels
+
+
+def format_trace(trace_id: str) -> str:
+    """Returns the fully-qualified trace resource name expected by Cloud Logging."""
+    if trace_id.startswith("projects/") or not GOOGLE_CLOUD_PROJECT:
+        return trace_id
+    return f"projects/{GOOGLE_CLOUD_PROJECT}/traces/{trace_id}"
+
+
+class CloudLoggingFormatter(logging.Formatter):
+    """
+    Formats log records as single-line JSON that Cloud Logging parses into
+    structured entries, so `severity` filtering and log-based metrics work.
+
+    Optional fields can be attached through `extra`:
+        logging.info("...", extra={"trace": "...", "span_id": "...",
+                                   "http_request": {...}, "labels": {...},
+                                   "json_fields": {...}})
+
+`json_fields` are added to the entry's payload as they are.
+
+    Pass `extra={"report_error": True}` together with `logging.exception` to
+    send the entry to Cloud Error Reporting.
+    """
+
+    def __init__(self):
+        super().__init__()
+        self.default_labels = _default_labels()
+
+    def format(self, record: logging.LogRecord) -> str:
+        message = record.getMessage()
+        if record.exc_info and not record.exc_text:
+            record.exc_text = self.formatException(record.exc_info)
+        if record.exc_text:
+            message = f"{message}\n{record.exc_text}"
+        if record.stack_info:
+            message = f"{message}\n{self.formatStack(record.stack_info)}"
+
+        entry = {
+            # Python level names (DEBUG, INFO, WARNING, ERROR, CRITICAL) are valid
+            # Cloud Logging severities as-is.
+            "severity": record.levelname,
+            "message": message,
+            "time": datetime.fromtimestamp(record.created, timezone.utc).isoformat(),
+            "logger": record.name,
+            SOURCE_LOCATION_KEY: {
+                "file": record.pathname,
+                "line": record.lineno,
+                "function": record.funcName,
+            },
+        }
+
+        trace = getattr(record, "trace", None)
+        if trace:
+            entry[TRACE_KEY] = format_trace(trace)
+        span_id = getattr(record, "span_id", None)
+        if span_id:
+            entry[SPAN_ID_KEY] = span_id
+        http_request = getattr(record, "http_request", None)
+        if http_request:
+            entry["httpRequest"] = http_request
+        for key, value in (getattr(record, "json_fields", None) or {}).items():
+            entry.setdefault(key, value)
+
+        if getattr(record, "report_error", False):
+            entry["@type"] = ERROR_REPORT_TYPE
+            entry["serviceContext"] = {
+                "service": settings.service_name,
+                "version": settings.service_version,
+            }
+            if http_request:
+                entry["context"] = {"httpRequest": http_request}
+
+        labels = {**self.default_labels, **(getattr(record, "labels", None) or {})}
+        if labels:
+            entry[LABELS_KEY] = {k: str(v) for k, v in labels.items()}
+
+        return json.dumps(entry, default=str, ensure_ascii=False)
+
+
+def _handler(text_format: str) -> logging.Handler:
+    handler = logging.StreamHandler()
+    handler.addFilter(RequestContextLogFilter())
+    if settings.log_redaction_enabled:
+        handler.addFilter(RedactionLogFilter())
+    if LOG_FORMAT == "json":
+        handler.setFormatter(CloudLoggingFormatter())
+    else:
+        handler.setFormatter(logging.Formatter(text_format))
+    return handler
+
+
+def setup_logging() -> None:
+    """
+    Configures the root logger once at application start-up.
+    Every record is stamped with the current request and trace IDs, and has
+    PHI masked unless LOG_REDACTION_ENABLED is false (see app/core/redaction.py).
+    Uvicorn's loggers are routed through the root handler so that server and
+    application logs share the same format. The access log (see
+    app/core/access_log.py) has a handler of its own and replaces Uvicorn's,
+    unless ACCESS_LOG_ENABLED is false.
+    """
+    root_logger = logging.getLogger()
+    root_logger.handlers = [_handler('%(asctime)s - %(name)s - %(levelname)s - [%(request_id)s] %(message)s')]
+    root_logger.setLevel(LOG_LEVEL)
+
+    for name in ("uvicorn", "uvicorn.error", "uvicorn.access"):
+        uvicorn_logger = logging.getLogger(name)
+        uvicorn_logger.handlers = []
+        uvicorn_logger.propagate = True
+
+    access_logger = logging.getLogger(ACCESS_LOGGER)
+    access_logger.handlers = [_handler('%(asctime)s - access - [%(request_id)s] %(message)s')]
+    access_logger.setLevel(logging.INFO)
+    access_logger.propagate = False
+    access_logger.disabled = not settings.access_log_enabled
+    logging.getLogger("uvicorn.access").disabled = settings.access_log_enabled
@@ -0,0 +1,153 @@
+# Location: app/core/metrics.py
+
+import os
+
+from prometheus_client import (
+    CONTENT_TYPE_LATEST,
+    CollectorRegistry,
+    Counter,
+    Gauge,
+    Histogram,
+    REGISTRY,
+    generate_latest,
+)
+from prometheus_client import multiprocess
+
+# --- Core HTTP Metrics ---
+# Labelled by route template (e.g. /api/v1/clinician/patients/{patientId}),
+# never the raw path, to keep label cardinality bounded.
+HTTP_REQUESTS_TOTAL = Counter(
+    "http_requests_total",
+    "Total HTTP requests processed.",
+    ["method", "route", "status"],
+)
+HTTP_REQUEST_DURATION_SECONDS = Histogram(
+    "http_request_duration_seconds",
+    "HTTP request latency in seconds.",
+    ["method", "route", "status"],
+    buckets=(0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30),
+)
+HTTP_RESPONSE_SIZE_BYTES = Histogram(
+    "http_response_size_bytes",
+    "HTTP response body size in bytes.",
+    ["method", "route", "status"],
+    buckets=(100, 1_000, 10_000, 100_000, 1_000_000, 10_000_000),
+)
+# "livesum" aggregates the gauge across Gunicorn workers in multiprocess mode.
+HTTP_REQUESTS_IN_FLIGHT = Gauge(
+    "http_requests_in_flight",
+    "HTTP requests currently being processed.",
+    multiprocess_mode="livesum",
+)
+
+# --- Load Shedding Metrics ---
+# The limit is adjusted in each worker; "liveall" keeps one series per
+# worker process.
+SHED_REQUESTS_TOTAL = Counter(
+    "shed_requests_total",
+    "Requests refused with 503 by the concurrency limiter.",
+    ["reason"],
+)
+CONCURRENCY_LIMIT = Gauge(
+    "concurrency_limit",
+    "Requests a worker currently admits at once.",
+    multiprocess_mode="liveall",
+)
+
+# --- Resilience Metrics ---
+# Labelled by dependency (e.g. "twilio"). State is 0 closed, 1 half-open,
+# 2 open, per worker; rejections are by reason "open" or "bulkhead".
+CIRCUIT_BREAKER_STATE = Gauge(
+    "circuit_breaker_state",
+    "State of each dependency's circuit breaker.",
+    ["dependency"],
+    multiprocess_mode="liveall",
+)
+DEPENDENCY_CALLS_REJECTED_TOTAL = Counter(
+    "dependency_calls_rejected_total",
+    "Calls to a dependency refused by its circuit breaker or bulkhead.",
+    ["dependency", "reason"],
+)
+
+# --- Outbound HTTP Metrics ---
+# Labelled by client (e.g. "twilio"), never the URL; outcome is the status
+# code or "error" for a request that got no response.
+HTTP_CLIENT_REQUESTS_TOTAL = Counter(
+    "http_client_requests_total",
+    "Outbound HTTP requests, counting each attempt.",
+    ["client", "method", "outcome"],
+)
+HTTP_CLIENT_RETRIES_TOTAL = Counter(
+    "http_client_retries_total",
+    "Outbound HTTP requests retried after a failed attempt.",
+    ["client", "method"],
+)
+
+# --- Cache Metrics ---
+# Labelled by key namespace (e.g. "patient"), never the key itself.
+CACHE_REQUESTS_TOTAL = Counter(
+    "cache_requests_total",
+    "Read-through cache lookups.",
+    ["namespace", "result"],
+)
+
+# --- Event Outbox Metrics ---
+# result is "published", "retried" or "failed" (given up after the last attempt).
+OUTBOX_EVENTS_TOTAL = Counter(
+    "outbox_events_total",
+    "Domain events relayed from the outbox to Pub/Sub.",
+    ["result"],
+)
+
+# --- Webhook Metrics ---
+# result is "succeeded", "retried" or "failed" (given up, or the subscription is gone).
+WEBHOOK_DELIVERIES_TOTAL = Counter(
+    "webhook_deliveries_total",
+    "Webhook delivery attempts, by outcome.",
+    ["result"],
+)
+
+# --- Task Metrics ---
+# result is "succeeded", "retried" (raised; the queue tries again) or "failed" (permanently).
+TASK_RUNS_TOTAL = Counter(
+    "task_runs_total",
+    "Deferred job runs.",
+    ["task", "result"],
+)
+
+# --- Scheduler Metrics ---
+# result is "succeeded", "failed" or "skipped" (another run held the job's lock).
+SCHEDULED_JOB_RUNS_TOTAL = Counter(
+    "scheduled_job_runs_total",
+    "Scheduled job runs, by outcome.",
+    ["job", "result"],
+)
+
+# --- Rate Limit Metrics ---
+# Labelled by the matched rule's route prefix, never the caller.
+RATE_LIMITED_REQUESTS_TOTAL = Counter(
+    "rate_limited_requests_total",
+    "Requests refused with 429 for exceeding a rate limit.",
+    ["route_group"],
+)
+
+# --- Analytics Metrics ---
+# result is "exported", "failed" (the sink refused the batch) or "dropped" (the queue was full).
+ANALYTICS_ROWS_TOTAL = Counter(
+    "analytics_rows_total",
+    "Analytics rows handed to the export sink, by outcome.",
+    ["category", "result"],
+)
+
+
+def render_latest() -> tuple[bytes, str]:
+    """
+    Renders all metrics in the Prometheus text format.
+    When PROMETHEUS_MULTIPROC_DIR is set (multiple Gunicorn workers), samples
+    from every worker process are aggregated instead of only the current one.
+    """
+    if os.getenv("PROMETHEUS_MULTIPROC_DIR"):
+        registry = CollectorRegistry()
+        multiprocess.MultiProcessCollector(registry)
+        return generate_latest(registry), CONTENT_TYPE_LATEST
+    return generate_latest(REGISTRY), CONTENT_TYPE_LATEST
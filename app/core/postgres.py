@@ -0,0 +1,90 @@
+# Location: app/core/postgres.py
+
+import threading
+from contextlib import contextmanager
+from contextvars import ContextVar
+from typing import Optional
+
+from app.core.config import get_settings
+
+# --- Configuration ---
+# Only used when STORE=postgres. On Cloud Run, connect to Cloud SQL through
+# its Unix socket, e.g. "host=/cloudsql/<project>:<region>:<instance> dbname=megacare user=api".
+settings = get_settings()
+DATABASE_URL = settings.database_url
+DATABASE_POOL_MAX_SIZE = settings.database_pool_max_size
+
+_pool = None
+_pool_lock = threading.Lock()
+
+# The connection of the transaction opened by `transaction()`, if any.
+_transaction_conn: ContextVar[Optional[object]] = ContextVar("postgres_transaction", default=None)
+
+
+def get_pool():
+    """
+    Returns the process-wide connection pool, opening it on first use.
+    Connections return rows as dicts. Each `with pool.connection() as conn:`
+    block is one transaction, committed when the block exits cleanly.
+    """
+    global _pool
+    with _pool_lock:
+        if _pool is None:
+            from psycopg.rows import dict_row
+            from psycopg_pool import ConnectionPool
+            _pool = ConnectionPool(
+                DATABASE_URL,
+                min_size=1,
+                max_size=DATABASE_POOL_MAX_SIZE,
+                kwargs={"row_factory": dict_row},
+                open=True,
+            )
+        return _pool
+
+
+def close_pool() -> None:
+    """Closes the pool, if one was opened. Called from the application lifespan on shutdown."""
+    global _pool
+    with _pool_lock:
+        if _pool is not None:
+            _pool.close()
+            _pool = None
+
+
+@contextmanager
+def transaction(pool):
+    """
+    Runs the enclosed block in one transaction: repositories called inside
+    it share its connection through `connection()`, and everything commits
+    together when the block exits cleanly or rolls back if it raises.
+    """
+    with pool.connection() as conn:
+        with conn.transaction():
+            token = _transaction_conn.set(conn)
+            try:
+                yield conn
+            finally:
+                _transaction_conn.reset(token)
+
+
+@contextmanager
+def connection(pool):
+    """
+    Yields a connection for one repository operation. Inside `transaction()`
+    this is the shared connection, wrapped in a savepoint so that an error
+    the caller handles (e.g. a unique violation) does not abort the rest of
+    the transaction; otherwise it is a pooled connection committed on exit.
+    """
+    conn = _transaction_conn.get()
+    if conn is None:
+        with pool.connection() as conn:
+            yield conn
+    else:
+        with conn.transaction():
+            yield conn
+
+
+def check_postgres() -> None:
+    """Readiness check: runs a trivial query on a pooled connection."""
+    with get_pool().connection() as conn:
+        conn.execute("SELECT 1")
@@ -0,0 +1,46 @@
+# Location: app/core/pubsub.py
+
+import json
+from functools import lru_cache
+from typing import Any, Optional
+
+from app.core.config import get_settings
+from app.core.tracing import start_span
+
+# --- Configuration ---
+settings = get_settings()
+GOOGLE_CLOUD_PROJECT = settings.google_cloud_project
+PUBSUB_PUBLISH_TIMEOUT_SECONDS = settings.pubsub_publish_timeout_seconds
+
+
+@lru_cache
+def get_publisher():
+    """Returns a process-wide Pub/Sub publisher. The client batches and retries internally."""
+    from google.cloud import pubsub_v1
+    return pubsub_v1.PublisherClient()
+
+
+def topic_path(topic: str, project: Optional[str] = None) -> str:
+    """Accepts a topic ID or a full `projects/{p}/topics/{t}` path and returns the full path."""
+    if topic.startswith("projects/"):
+        return topic
+    project = project or GOOGLE_CLOUD_PROJECT
+    if not project:
+        raise ValueError(f"Cannot resolve Pub/Sub topic '{topic}' without GOOGLE_CLOUD_PROJECT.")
+    return f"projects/{project}/topics/{topic}"
+
+
+def publish_json(topic: str, payload: Any, **attributes: str) -> str:
+    """
+    Publishes `payload` as UTF-8 JSON and blocks until Pub/Sub has accepted
+    it, so callers only acknowledge data that is durably queued. Returns the
+    message ID. Attribute values must be strings. Raises DependencyUnavailable
+    without publishing while Pub/Sub's circuit is open.
+    """
+    from app.resilience.dependencies import PUBSUB, protect
+
+    path = topic_path(topic)
+    data = json.dumps(payload, default=str, separators=(",", ":")).encode("utf-8")
+    with start_span("pubsub.publish", topic=path), protect(PUBSUB):
+        future = get_publisher().publish(path, data, **attributes)
+        return future.result(timeout=PUBSUB_PUBLISH_TIMEOUT_SECONDS)
@@ -0,0 +1,122 @@
+# Location: app/core/redaction.py
+
+import logging
+import re
+from typing import Any, Optional
+
+# --- PHI Redaction ---
+# Log entries leave the service for Cloud Logging and Error Reporting, where
+# patient data must not end up, so every record is scrubbed before it is
+# formatted (see RedactionLogFilter). Messages and tracebacks are free text:
+# values following a PHI field name, and anything shaped like a date of
+# birth, phone number or email address, are masked. Structured fields (the
+# `http_request` and `labels` extras) are masked unless their name is in
+# SAFE_FIELDS. IDs are not PHI and are kept, so entries can still be traced
+# back to the records they concern.
+MASK = "[REDACTED]"
+
+# Field names, as they appear in payloads, reprs and messages, whose values
+# are PHI: names, contact details, identifiers and free-text notes.
+PHI_FIELDS = (
+    "name", "givenName", "given_name", "familyName", "family_name", "fullName", "full_name", "displayName", "display_name",
+    "dob", "birthDate", "birth_date", "dateOfBirth", "date_of_birth",
+    "mrn", "nhsNumber", "nhs_number", "ssn", "nationalId", "national_id", "memberId", "member_id",
+    "phone", "phoneNumber", "phone_number", "mobile", "email",
+    "address", "addressLine", "address_line", "city", "postalCode", "postal_code",
+    "notes", "note", "comment", "comments", "reason", "instructions", "summary", "text",
+    "psychotherapyNotes", "psychotherapy_notes",
+    "input", "input_value",
+)
+
+# Structured fields whose values are kept as they are (strings are still
+# scrubbed like messages, e.g. the query of a URL). Names ending in `Id`/`_id`
+# are kept too, unless they are PHI (an insurance `memberId`).
+SAFE_FIELDS = frozenset({
+    "requestMethod", "requestUrl", "status", "latency", "protocol", "userAgent", "remoteIp", "serverIp", "referer",
+    "requestSize", "responseSize", "cacheHit", "cacheLookup",
+    "method", "path", "route", "code", "type", "severity", "resourceType", "component", "service", "revision",
+    "version", "count", "attempt", "limit", "cursor", "after", "status_code", "statusCode",
+})
+
+# A quoted value or a bare token; nested dicts and lists are left to the
+# patterns for their own fields.
+_QUOTED_OR_TOKEN = r"""'[^']*'|"[^"]*"|(?![{\[(])[^\s,;&)}\]]+"""
+
+_PATTERNS = (
+    # `givenName='Ann'`, `"familyName": "Lee"`, `mrn=MRN-1`; and for MRNs and
+    # dates of birth also `MRN 'MRN-1'` and `with MRN MRN-1`.
+    re.compile(rf"""(?P<key>["']?\b(?:{"|".join(PHI_FIELDS)})\b["']?\s*[:=]\s*)(?P<value>{_QUOTED_OR_TOKEN})""", re.IGNORECASE),
+    re.compile(rf"""(?P<key>\b(?:mrn|dob|nhs number)\s+)(?P<value>{_QUOTED_OR_TOKEN})""", re.IGNORECASE),
+)
+
+# Query parameters of URLs; the values of safe ones, such as `limit`, are kept.
+_QUERY_PARAMETER = re.compile(r"(?P<key>[?&](?P<name>[\w.\-\[\]]+)=)(?P<value>[^&\s#'\"]+)")
+
+_SHAPES = (
+    # Email addresses, except those of Google service accounts.
+    re.compile(r"\b[\w.+\-]+@(?![\w\-]+\.iam\.gserviceaccount\.com\b)(?:[\w\-]+\.)+[a-zA-Z]{2,}\b"),
+    # Calendar dates without a time, as dates of birth are written.
+    re.compile(r"\b(?:19|20)\d{2}-\d{2}-\d{2}\b(?![T:\d]|\s\d{2}:)"),
+    re.compile(r"\b\d{1,2}/\d{1,2}/(?:19|20)\d{2}\b"),
+    # Phone numbers: E.164, and national numbers such as "081 234 5678".
+    re.compile(r"(?<![\w+])\+\d{8,15}\b"),
+    re.compile(r"(?<![\w\-])0\d{1,2}[\s\-]?\d{3}[\s\-]?\d{4}\b"),
+)
+
+
+def redact_text(text: str) -> str:
+    """Masks PHI in free text, e.g. a log message or an exception's traceback."""
+    for pattern in _PATTERNS:
+        text = pattern.sub(lambda match: match.group("key") + MASK, text)
+    text = _QUERY_PARAMETER.sub(lambda match: match.group(0) if _safe_field(match.group("name")) else match.group("key") + MASK, text)
+    for shape in _SHAPES:
+        text = shape.sub(MASK, text)
+    return text
+
+
+def _safe_field(name: str) -> bool:
+    return name in SAFE_FIELDS or (name.endswith(("Id", "_id", "ID")) and name not in PHI_FIELDS)
+
+
+def redact_fields(value: Any, field: Optional[str] = None) -> Any:
+    """
+    Masks the values of structured data (dicts, lists and scalars) whose
+    field is not in SAFE_FIELDS; strings of safe fields are scrubbed like text.
+    """
+    if isinstance(value, dict):
+        return {key: redact_fields(item, str(key)) for key, item in value.items()}
+    if isinstance(value, (list, tuple)):
+        return [redact_fields(item, field) for item in value]
+    if field is not None and not _safe_field(field):
+        return MASK if value not in (None, "") else value
+    return redact_text(value) if isinstance(value, str) else value
+
+
+class RedactionLogFilter(logging.Filter):
+    """
+    Masks PHI in every record before it is formatted: the message (with its
+    arguments merged in), the traceback and stack, and the `http_request` and
+    `labels` extras. The record is changed in place, so every handler sees
+    the redacted version.
+    """
+
+    _formatter = logging.Formatter()
+
+    def filter(self, record: logging.LogRecord) -> bool:
+        try:
+            message = record.getMessage()
+        except Exception:
+            message = str(record.msg)
+        record.msg = redact_text(message)
+        record.args = None
+        if record.exc_info and not record.exc_text:
+            record.exc_text = self._formatter.formatException(record.exc_info)
+        if record.exc_text:
+            record.exc_text = redact_text(record.exc_text)
+        if record.stack_info:
+            record.stack_info = redact_text(record.stack_info)
+        for extra in ("http_request", "labels"):
+            value = getattr(record, extra, None)
+            if isinstance(value, dict):
+                setattr(record, extra, redact_fields(value))
+        return True
@@ -0,0 +1,92 @@
+# Location: app/core/request_context.py
+
+import re
+import uuid
+import logging
+from contextvars import ContextVar
+from typing import Optional, Tuple
+
+from app.core.tracing import current_span_ids
+
+# --- Request-Scoped Context ---
+# Populated by RequestContextMiddleware for the duration of each request.
+# ContextVars are copied into the threadpool used for sync endpoints, so these
+# are readable from any handler or helper without passing them around.
+request_id_var: ContextVar[Optional[str]] = ContextVar("request_id", default=None)
+trace_id_var: ContextVar[Optional[str]] = ContextVar("trace_id", default=None)
+span_id_var: ContextVar[Optional[str]] = ContextVar("span_id", default=None)
+
+REQUEST_ID_HEADER = "X-Request-ID"
+TRACE_ID_HEADER = "X-Trace-ID"
+
+_TRACEPARENT_RE = re.compile(r"^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$")
+_CLOUD_TRACE_RE = re.compile(r"^([0-9a-fA-F]{32})(?:/(\d+))?(?:;o=\d)?$")
+_REQUEST_ID_RE = re.compile(r"^[A-Za-z0-9._\-]{1,128}$")
+
+
+def get_request_id() -> Optional[str]:
+    return request_id_var.get()
+
+
+def get_trace_id() -> Optional[str]:
+    return trace_id_var.get()
+
+
+def get_span_id() -> Optional[str]:
+    return span_id_var.get()
+
+
+def parse_traceparent(value: Optional[str]) -> Tuple[Optional[str], Optional[str]]:
+    """Parses a W3C `traceparent` header into (trace_id, span_id)."""
+    if not value:
+        return None, None
+    match = _TRACEPARENT_RE.match(value.strip().lower())
+    if not match or set(match.group(1)) == {"0"}:
+        return None, None
+    return match.group(1), match.group(2)
+
+
+def parse_cloud_trace_context(value: Optional[str]) -> Tuple[Optional[str], Optional[str]]:
+    """
+    Parses Google's `X-Cloud-Trace-Context: TRACE_ID/SPAN_ID;o=OPTIONS` header.
+    The span ID is sent as a decimal integer; it is converted to the 16-character
+    hex form that Cloud Logging expects.
+    """
+    if not value:
+        return None, None
+    match = _CLOUD_TRACE_RE.match(value.strip())
+    if not match:
+        return None, None
+    trace_id = match.group(1).lower()
+    span_id = None
+    if match.group(2):
+        span_id = format(int(match.group(2)) & 0xFFFFFFFFFFFFFFFF, "016x")
+    return trace_id, span_id
+
+
+def resolve_request_id(value: Optional[str]) -> str:
+    """Accepts a well-formed client-supplied request ID, otherwise generates a new one."""
+    if value and _REQUEST_ID_RE.match(value):
+        return value
+    return uuid.uuid4().hex
+
+
+class RequestContextLogFilter(logging.Filter):
+    """
+    Copies the current request's IDs onto every log record so formatters can
+    emit them (see CloudLoggingFormatter). When tracing is enabled the active
+    OpenTelemetry span wins, so log entries nest under the matching Cloud Trace
+    span. Records logged outside a request get a "-" request ID for the text format.
+    """
+
+    def filter(self, record: logging.LogRecord) -> bool:
+        request_id = request_id_var.get()
+        record.request_id = request_id or "-"
+        otel_trace_id, otel_span_id = current_span_ids()
+        if not getattr(record, "trace", None):
+            record.trace = otel_trace_id or trace_id_var.get()
+        if not getattr(record, "span_id", None):
+            record.span_id = otel_span_id or span_id_var.get()
+        if request_id:
+            record.labels = {"requestId": request_id, **(getattr(record, "labels", None) or {})}
+        return True
@@ -0,0 +1,33 @@
+# Location: app/core/server.py
+
+import os
+from uvicorn.workers import UvicornWorker
+
+# --- Server Limits ---
+# These are read from the environment so they can be tuned per Cloud Run
+# service without rebuilding the image. Timeouts that belong to the Gunicorn
+# master (worker timeout, keep-alive) are set in gunicorn.conf.py.
+MAX_HEADER_BYTES = int(os.getenv("SERVER_MAX_HEADER_BYTES", "16384"))
+LIMIT_CONCURRENCY = os.getenv("SERVER_LIMIT_CONCURRENCY")
+SHUTDOWN_TIMEOUT_SECONDS = int(os.getenv("SHUTDOWN_TIMEOUT_SECONDS", "8"))
+
+
+class MegaCareUvicornWorker(UvicornWorker):
+    """
+    Gunicorn worker class that runs Uvicorn with explicit HTTP limits.
+
+    - The h11 protocol implementation is used so that the request line plus
+      headers are capped at `SERVER_MAX_HEADER_BYTES`; larger requests are
+      rejected with 400 before reaching the application.
+    - `SERVER_LIMIT_CONCURRENCY` optionally caps concurrent connections per
+      worker; excess connections receive a 503.
+    - In-flight requests are given `SHUTDOWN_TIMEOUT_SECONDS` to complete
+      after SIGTERM.
+    """
+    CONFIG_KWARGS = {
+        "loop": "auto",
+        "http": "h11",
+        "h11_max_incomplete_event_size": MAX_HEADER_BYTES,
+        "limit_concurrency": int(LIMIT_CONCURRENCY) if LIMIT_CONCURRENCY else None,
+        "timeout_graceful_shutdown": SHUTDOWN_TIMEOUT_SECONDS,
+    }
@@ -0,0 +1,94 @@
+# Location: app/core/storage.py
+
+from datetime import timedelta
+from functools import lru_cache
+from typing import Dict, Iterator, Optional
+
+from google.cloud import storage
+
+
+@lru_cache
+def get_storage_client() -> storage.Client:
+    """Returns a process-wide Cloud Storage client using Application Default Credentials."""
+    return storage.Client()
+
+
+def _signing_kwargs() -> Dict[str, str]:
+    """
+    Cloud Run's metadata-server credentials hold no private key, so V4 signing
+    has to go through the IAM signBlob API. That needs the service account
+    email and a fresh access token (and `roles/iam.serviceAccountTokenCreator`
+    on itself). Key-file credentials used locally can sign directly.
+    """
+    import google.auth
+    from google.auth.transport import requests as google_requests
+    from google.oauth2 import service_account
+
+    credentials, _project = google.auth.default(scopes=["https://www.googleapis.com/auth/cloud-platform"])
+    if isinstance(credentials, service_account.Credentials):
+        return {}
+    credentials.refresh(google_requests.Request())
+    return {"service_account_email": credentials.service_account_email, "access_token": credentials.token}
+
+
+def signed_download_url(bucket_name: str, blob_name: str, ttl_seconds: int) -> str:
+    """Returns a V4 signed GET URL for `gs://bucket_name/blob_name` valid for `ttl_seconds`."""
+    blob = get_storage_client().bucket(bucket_name).blob(blob_name)
+    return blob.generate_signed_url(
+        version="v4",
+        expiration=timedelta(seconds=ttl_seconds),
+        method="GET",
+        **_signing_kwargs(),
+    )
+
+
+def signed_upload_url(bucket_name: str, blob_name: str, ttl_seconds: int, content_type: str, content_md5: str) -> str:
+    """
+    Returns a V4 signed PUT URL for `gs://bucket_name/blob_name` valid for
+    `ttl_seconds`. Content-Type and Content-MD5 are part of the signature, so
+    the upload must send both headers with these values, and Cloud Storage
+    rejects a body whose MD5 differs.
+    """
+    blob = get_storage_client().bucket(bucket_name).blob(blob_name)
+    return blob.generate_signed_url(
+        version="v4",
+        expiration=timedelta(seconds=ttl_seconds),
+        method="PUT",
+        content_type=content_type,
+        content_md5=content_md5,
+        **_signing_kwargs(),
+    )
+
+
+def get_blob(bucket_name: str, blob_name: str) -> Optional["storage.Blob"]:
+    """Returns the object with its metadata (size, md5_hash, ...), or None if it does not exist."""
+    return get_storage_client().bucket(bucket_name).get_blob(blob_name)
+
+
+def upload_blob(bucket_name: str, blob_name: str, data: bytes, content_type: str) -> None:
+    """Writes `data` to `gs://bucket_name/blob_name`, replacing any object there."""
+    get_storage_client().bucket(bucket_name).blob(blob_name).upload_from_string(data, content_type=content_type)
+
+
+def download_blob(bucket_name: str, blob_name: str) -> bytes:
+    """Returns the object's content. Raises google.api_core.exceptions.NotFound if it does not exist."""
+    return get_storage_client().bucket(bucket_name).blob(blob_name).download_as_bytes()
+
+
+def read_blob(bucket_name: str, blob_name: str, chunk_size: int = 1024 * 1024, start: int = 0) -> Iterator[bytes]:
+    """Yields the object's content from byte `start` in chunks of up to `chunk_size` bytes, without holding it all in memory."""
+    with get_storage_client().bucket(bucket_name).blob(blob_name).open("rb", chunk_size=chunk_size) as f:
+        if start:
+            f.seek(start)
+        while chunk := f.read(chunk_size):
+            yield chunk
+
+
+def delete_blob(bucket_name: str, blob_name: str) -> None:
+    """Deletes the object; an object that does not exist is ignored."""
+    from google.api_core.exceptions import NotFound
+
+    try:
+        get_storage_client().bucket(bucket_name).blob(blob_name).delete()
+    except NotFound:
+        pass
@@ -0,0 +1,102 @@
+# Location: app/core/tracing.py
+
+import logging
+from contextlib import contextmanager
+from typing import Iterator, Optional
+
+from fastapi import FastAPI
+from opentelemetry import propagate, trace
+from opentelemetry.propagators.composite import CompositePropagator
+from opentelemetry.sdk.resources import Resource
+from opentelemetry.sdk.trace import TracerProvider
+from opentelemetry.sdk.trace.export import BatchSpanProcessor
+from opentelemetry.sdk.trace.sampling import ParentBased, TraceIdRatioBased
+from opentelemetry.trace.propagation.tracecontext import TraceContextTextMapPropagator
+
+from app.core.config import get_settings
+
+# --- Configuration ---
+# Tracing is on by default on Cloud Run and off locally.
+settings = get_settings()
+TRACING_ENABLED = settings.tracing_enabled
+TRACING_SAMPLE_RATIO = settings.tracing_sample_ratio
+GOOGLE_CLOUD_PROJECT = settings.google_cloud_project
+SERVICE_NAME = settings.service_name
+
+# Probes and scrapes are high-volume and uninteresting to trace.
+EXCLUDED_URLS = "healthz,readyz,metrics"
+
+tracer = trace.get_tracer("mega-care-api")
+
+_provider: Optional[TracerProvider] = None
+
+
+def setup_tracing(app: FastAPI) -> None:
+    """
+    Configures OpenTelemetry for this worker process:
+    - a server span for every request (FastAPI instrumentation),
+    - client spans for outbound httpx calls (e.g. the LINE token exchange),
+    - export to Cloud Trace, honouring both W3C `traceparent` and Google's
+      `X-Cloud-Trace-Context` from upstream services.
+    Does nothing unless TRACING_ENABLED is true.
+    """
+    global _provider
+    if not TRACING_ENABLED or _provider is not None:
+        return
+
+    from opentelemetry.exporter.cloud_trace import CloudTraceSpanExporter
+    from opentelemetry.instrumentation.fastapi import FastAPIInstrumentor
+    from opentelemetry.instrumentation.httpx import HTTPXClientInstrumentor
+    from opentelemetry.propagators.cloud_trace_propagator import CloudTraceFormatPropagator
+
+    resource = Resource.create({
+        "service.name": SERVICE_NAME,
+        "service.version": settings.service_version,
+    })
+    _provider = TracerProvider(
+        resource=resource,
+        sampler=ParentBased(TraceIdRatioBased(TRACING_SAMPLE_RATIO)),
+    )
+    _provider.add_span_processor(BatchSpanProcessor(CloudTraceSpanExporter(project_id=GOOGLE_CLOUD_PROJECT)))
+    trace.set_tracer_provider(_provider)
+
+    propagate.set_global_textmap(CompositePropagator([
+        TraceContextTextMapPropagator(),
+        CloudTraceFormatPropagator(),
+    ]))
+
+    FastAPIInstrumentor.instrument_app(app, tracer_provider=_provider, excluded_urls=EXCLUDED_URLS)
+    HTTPXClientInstrumentor().instrument(tracer_provider=_provider)
+    logging.info(f"OpenTelemetry tracing enabled with sample ratio {TRACING_SAMPLE_RATIO}.")
+
+
+def shutdown_tracing() -> None:
+    """Flushes any buffered spans. Called from the application lifespan on shutdown."""
+    if _provider is not None:
+        _provider.shutdown()
+
+
+@contextmanager
+def start_span(name: str, **attributes) -> Iterator[trace.Span]:
+    """
+    Creates a child span of the current request span, for wrapping outbound
+    calls that are not auto-instrumented (Firestore, Pub/Sub, ...):
+
+        with start_span("firestore.customers.get", uid=user_uid):
+            doc = customer_ref.get()
+
+    Exceptions are recorded on the span and re-raised.
+    """
+    with tracer.start_as_current_span(name) as span:
+        for key, value in attributes.items():
+            if value is not None:
+                span.set_attribute(key, value)
+        yield span
+
+
+def current_span_ids() -> tuple[Optional[str], Optional[str]]:
+    """Returns the (trace_id, span_id) of the active OpenTelemetry span as hex strings, if any."""
+    span_context = trace.get_current_span().get_span_context()
+    if not span_context.is_valid:
+        return None, None
+    return format(span_context.trace_id, "032x"), format(span_context.span_id, "016x")
@@ -0,0 +1,132 @@
+# Location: app/core/warmup.py
+
+import logging
+import time
+from concurrent.futures import ThreadPoolExecutor, wait
+from datetime import datetime, timezone
+from typing import Callable, Dict, List, Tuple
+from zoneinfo import ZoneInfo
+
+from app.core.config import get_settings
+
+# --- Configuration ---
+settings = get_settings()
+WARMUP_TIMEOUT_SECONDS = settings.warmup_timeout_seconds
+
+# A hook is a blocking callable that does, ahead of the first request,
+# work that request would otherwise wait for: opening connections, loading
+# files, fetching keys. Hooks must be safe to run again on first use.
+WarmupHook = Callable[[], None]
+
+_hooks: Dict[str, WarmupHook] = {}
+
+
+def register_hook(name: str, hook: WarmupHook) -> None:
+    """Registers (or replaces) a warmup hook under `name`."""
+    _hooks[name] = hook
+
+
+def registered_hooks() -> List[Tuple[str, WarmupHook]]:
+    return list(_hooks.items())
+
+
+def _timed(name: str, hook: WarmupHook) -> Dict:
+    started = time.perf_counter()
+    try:
+        hook()
+        result = {"status": "ok"}
+    except Exception as e:
+        logging.warning(f"Warmup hook '{name}' failed: {e}")
+        result = {"status": "error", "error": str(e)}
+    result["latencyMs"] = round((time.perf_counter() - started) * 1000, 1)
+    return result
+
+
+def run_warmup(timeout: float = WARMUP_TIMEOUT_SECONDS) -> Dict[str, Dict]:
+    """
+    Runs every registered hook concurrently and returns their results once
+    all have finished or `timeout` has passed. A failed or slow hook never
+    stops the worker from starting: whatever it left undone happens on
+    first use instead, and /readyz still decides whether traffic arrives.
+    """
+    hooks = registered_hooks()
+    if not hooks:
+        return {}
+    started = time.perf_counter()
+    executor = ThreadPoolExecutor(max_workers=len(hooks), thread_name_prefix="warmup")
+    futures = {name: executor.submit(_timed, name, hook) for name, hook in hooks}
+    wait(futures.values(), timeout=timeout)
+    # Hooks still running are left to finish in the background.
+    executor.shutdown(wait=False)
+    results = {}
+    for name, future in futures.items():
+        if future.done():
+            results[name] = future.result()
+        else:
+            logging.warning(f"Warmup hook '{name}' did not finish within {timeout}s.")
+            results[name] = {"status": "timeout"}
+    elapsed = round((time.perf_counter() - started) * 1000, 1)
+    summary = ", ".join(f"{name} {result['status']}" for name, result in results.items())
+    logging.info(f"Warmup finished in {elapsed}ms: {summary}.")
+    return results
+
+
+# --- Built-in Hooks ---
+
+def warm_firestore() -> None:
+    """Opens the Firestore client's gRPC channel with a minimal read."""
+    from app.core.health import check_firestore
+    check_firestore()
+
+
+def warm_postgres() -> None:
+    """Opens the pool and waits for its first connection."""
+    from app.core.postgres import get_pool
+    get_pool().wait(timeout=WARMUP_TIMEOUT_SECONDS)
+
+
+def warm_token_verifier() -> None:
+    """Fetches the OIDC issuer's signing keys; Firebase fetches its own certificates per process."""
+    from app.auth.verifier import OidcTokenVerifier, get_identity_verifier
+    verifier = get_identity_verifier()
+    if isinstance(verifier, OidcTokenVerifier):
+        verifier.keys.prime()
+
+
+def warm_terminology() -> None:
+    """Loads the bundled code systems and value sets."""
+    from app.terminology.service import get_terminology
+    get_terminology()
+
+
+def warm_templates() -> None:
+    """Renders each notification template once, loading the time zone data they format dates with."""
+    from app.notifications.templates import TEMPLATES, TemplateContext, render, render_push, render_sms
+
+    common = TemplateContext(organization_name=settings.email_from_name, portal_url=settings.patient_portal_url, timezone=ZoneInfo(settings.email_timezone))
+    context = {"start": datetime.now(timezone.utc).isoformat()}
+    for template in TEMPLATES:
+        render(template, context, common)
+        render_sms(template, context, common)
+        render_push(template, context, common)
+
+
+def warm_protos() -> None:
+    """Compiles the gRPC protos the server and HTTP gateway use."""
+    from app.rpc.stubs import load
+    for name in ("patients", "appointments", "observations"):
+        load(name)
+
+
+def register_default_hooks() -> None:
+    """Registers the hooks for the dependencies this service is configured to use."""
+    if settings.store != "memory":
+        register_hook("firestore", warm_firestore)
+    if settings.store == "postgres":
+        register_hook("postgres", warm_postgres)
+    if settings.auth_provider == "oidc":
+        register_hook("tokenVerifier", warm_token_verifier)
+    register_hook("terminology", warm_terminology)
+    register_hook("templates", warm_templates)
+    if settings.grpc_enabled:
+        register_hook("protos", warm_protos)
@@ -0,0 +1,54 @@
+# Location: app/core/worker.py
+
+import logging
+import threading
+from abc import ABC, abstractmethod
+from datetime import timedelta
+from typing import Optional
+
+# Retries back off exponentially from one second, capped at five minutes.
+BASE_RETRY_SECONDS = 1.0
+MAX_RETRY_SECONDS = 300.0
+
+
+def retry_delay(attempts: int) -> timedelta:
+    """The wait after the given number of failed attempts: 1s, 2s, 4s, ... up to 5 minutes."""
+    return timedelta(seconds=min(BASE_RETRY_SECONDS * 2 ** (attempts - 1), MAX_RETRY_SECONDS))
+
+
+class PollingWorker(ABC):
+    """
+    A background loop in a daemon thread of the worker process, for queues
+    kept in the store (the event outbox, webhook deliveries) and the
+    scheduler ticker. Subclasses implement `drain`, which processes one
+    batch of due items.
+    """
+    name: str
+    batch_size: int
+
+    def __init__(self):
+        self._stop = threading.Event()
+        self._thread: Optional[threading.Thread] = None
+
+    @abstractmethod
+    def drain(self) -> int:
+        """Processes one batch of due items. Returns how many were handled."""
+
+    def run(self, interval_seconds: float) -> None:
+        """Drains until `stop()` is called, pausing `interval_seconds` whenever a batch comes back short."""
+        while not self._stop.is_set():
+            try:
+                if self.drain() >= self.batch_size:
+                    continue
+            except Exception:
+                logging.exception(f"{self.name} pass failed", extra={"report_error": True})
+            self._stop.wait(interval_seconds)
+
+    def start(self, interval_seconds: float) -> None:
+        self._thread = threading.Thread(target=self.run, args=(interval_seconds,), name=self.name, daemon=True)
+        self._thread.start()
+
+    def stop(self, timeout: float = 10.0) -> None:
+        self._stop.set()
+        if self._thread:
+            self._thread.join(timeout)
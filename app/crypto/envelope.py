@@ -0,0 +1,160 @@
+# Location: app/crypto/envelope.py
+
+import base64
+import binascii
+import os
+import threading
+import time
+from collections import OrderedDict
+from dataclasses import dataclass
+from functools import lru_cache
+from typing import Callable, Optional
+
+from app.core.config import get_settings
+from app.crypto.kms import CloudKmsKeyManager, KeyManager
+
+# --- Envelope Encryption ---
+# Each value is encrypted with AES-256-GCM under a data key, and the data
+# key is stored with it wrapped by Cloud KMS, so nothing readable is
+# persisted and KMS is only called when a data key is made or first unwrapped:
+#   enc:v1:<KMS key version>:<wrapped data key>:<nonce + ciphertext>
+# A process seals with one data key for FIELD_ENCRYPTION_DATA_KEY_SECONDS,
+# then makes a new one; data keys it unwraps are kept in memory. The value's
+# field path is bound to it as associated data, so a sealed SSN cannot be
+# moved into another field and read from there.
+PREFIX = "enc:v1:"
+NONCE_BYTES = 12
+UNWRAPPED_KEY_CACHE_SIZE = 1024
+
+
+class FieldEncryptionError(RuntimeError):
+    """A sealed value cannot be opened: it is malformed, was tampered with, or no key is configured."""
+
+
+@dataclass
+class DataKey:
+    key: bytes
+    wrapped: bytes
+    key_version: str
+    expires: float
+
+
+def is_sealed(value) -> bool:
+    return isinstance(value, str) and value.startswith(PREFIX)
+
+
+def _encode(data: bytes) -> str:
+    return base64.urlsafe_b64encode(data).decode().rstrip("=")
+
+
+def _decode(text: str) -> bytes:
+    return base64.urlsafe_b64decode(text + "=" * (-len(text) % 4))
+
+
+class FieldCipher:
+    """
+    Seals and opens string values. `is_current` tells whether a sealed value
+    is under the KMS key version this process seals with; values that are
+    not get re-sealed by the repositories as they read them.
+    """
+
+    def __init__(self, keys: KeyManager, data_key_seconds: float = 3600, clock: Callable[[], float] = time.monotonic):
+        self.keys = keys
+        self.data_key_seconds = data_key_seconds
+        self.clock = clock
+        self._lock = threading.Lock()
+        self._active: Optional[DataKey] = None
+        self._unwrapped: "OrderedDict[bytes, bytes]" = OrderedDict()
+
+    def _active_key(self) -> DataKey:
+        with self._lock:
+            if self._active is None or self._active.expires <= self.clock():
+                key = os.urandom(32)
+                wrapped, key_version = self.keys.wrap(key)
+                self._active = DataKey(key, wrapped, key_version, self.clock() + self.data_key_seconds)
+                self._remember(wrapped, key)
+            return self._active
+
+    def _remember(self, wrapped: bytes, key: bytes) -> None:
+        self._unwrapped[wrapped] = key
+        self._unwrapped.move_to_end(wrapped)
+        while len(self._unwrapped) > UNWRAPPED_KEY_CACHE_SIZE:
+            self._unwrapped.popitem(last=False)
+
+    def _data_key(self, wrapped: bytes, key_version: str) -> bytes:
+        with self._lock:
+            key = self._unwrapped.get(wrapped)
+            if key is not None:
+                self._unwrapped.move_to_end(wrapped)
+                return key
+        key = self.keys.unwrap(wrapped, key_version)
+        with self._lock:
+            self._remember(wrapped, key)
+        return key
+
+    def seal(self, value: str, context: str) -> str:
+        """Encrypts `value` for the field `context` (its path, e.g. "insurance.memberId")."""
+        from cryptography.hazmat.primitives.ciphers.aead import AESGCM
+
+        data_key = self._active_key()
+        nonce = os.urandom(NONCE_BYTES)
+        ciphertext = AESGCM(data_key.key).encrypt(nonce, value.encode(), context.encode())
+        return f"{PREFIX}{data_key.key_version}:{_encode(data_key.wrapped)}:{_encode(nonce + ciphertext)}"
+
+    def open(self, token: str, context: str) -> str:
+        """Decrypts a value sealed by `seal` for the same field. Raises FieldEncryptionError."""
+        from cryptography.exceptions import InvalidTag
+        from cryptography.hazmat.primitives.ciphers.aead import AESGCM
+
+        try:
+            key_version, wrapped, sealed = token[len(PREFIX):].split(":")
+            wrapped, sealed = _decode(wrapped), _decode(sealed)
+        except (ValueError, binascii.Error):
+            raise FieldEncryptionError(f"Malformed encrypted value in '{context}'")
+        key = self._data_key(wrapped, key_version)
+        try:
+            return AESGCM(key).decrypt(sealed[:NONCE_BYTES], sealed[NONCE_BYTES:], context.encode()).decode()
+        except InvalidTag:
+            raise FieldEncryptionError(f"Encrypted value in '{context}' does not match its key or field")
+
+    def seal_bytes(self, data: bytes, context: str) -> bytes:
+        """Encrypts a file, such as a photo, in the format of `seal` with raw rather than base64 ciphertext."""
+        from cryptography.hazmat.primitives.ciphers.aead import AESGCM
+
+        data_key = self._active_key()
+        nonce = os.urandom(NONCE_BYTES)
+        ciphertext = AESGCM(data_key.key).encrypt(nonce, data, context.encode())
+        return f"{PREFIX}{data_key.key_version}:{_encode(data_key.wrapped)}:".encode() + nonce + ciphertext
+
+    def open_bytes(self, sealed: bytes, context: str) -> bytes:
+        """Decrypts a file sealed by `seal_bytes` for the same context. Raises FieldEncryptionError."""
+        from cryptography.exceptions import InvalidTag
+        from cryptography.hazmat.primitives.ciphers.aead import AESGCM
+
+        try:
+            if not sealed.startswith(PREFIX.encode()):
+                raise ValueError("missing prefix")
+            key_version, wrapped, ciphertext = sealed[len(PREFIX):].split(b":", 2)
+            key_version, wrapped = key_version.decode(), _decode(wrapped.decode())
+        except (ValueError, UnicodeDecodeError, binascii.Error):
+            raise FieldEncryptionError(f"Malformed encrypted file '{context}'")
+        key = self._data_key(wrapped, key_version)
+        try:
+            return AESGCM(key).decrypt(ciphertext[:NONCE_BYTES], ciphertext[NONCE_BYTES:], context.encode())
+        except InvalidTag:
+            raise FieldEncryptionError(f"Encrypted file '{context}' does not match its key")
+
+    def is_current(self, token: str) -> bool:
+        return token[len(PREFIX):].split(":", 1)[0] == self._active_key().key_version
+
+
+@lru_cache
+def get_field_cipher() -> Optional[FieldCipher]:
+    """
+    Returns the process-wide cipher for FIELD_ENCRYPTION_KEY, or None when it
+    is not set (local development), in which case fields are stored as sent.
+    """
+    settings = get_settings()
+    if not settings.field_encryption_key:
+        return None
+    return FieldCipher(CloudKmsKeyManager(settings.field_encryption_key), settings.field_encryption_data_key_seconds)
@@ -0,0 +1,102 @@
+# Location: app/crypto/fields.py
+
+import inspect
+import types
+import typing
+from functools import lru_cache
+from typing import Any, Callable, Dict, List, Optional, Tuple, Type
+
+from pydantic import BaseModel
+
+from app.crypto.envelope import FieldCipher, FieldEncryptionError, is_sealed
+
+# Schema fields are marked for encryption with
+#   ssn: Optional[str] = Field(None, json_schema_extra=ENCRYPTED)
+# which also shows in the OpenAPI document as `x-encrypted: true`. Marked
+# fields must be strings; fields of nested models (not of list items) are
+# found too, e.g. a patient's `insurance.memberId`.
+ENCRYPTED = {"x-encrypted": True}
+
+# A marked field's stored value that needs sealing again: what is stored
+# now (to write only if unchanged) and its replacement.
+Reseal = Tuple[Any, str]
+
+
+def _nested_models(annotation) -> List[Type[BaseModel]]:
+    if inspect.isclass(annotation) and issubclass(annotation, BaseModel):
+        return [annotation]
+    if typing.get_origin(annotation) in (typing.Union, types.UnionType):
+        return [model for arg in typing.get_args(annotation) for model in _nested_models(arg)]
+    return []
+
+
+@lru_cache(maxsize=None)
+def encrypted_paths(model: Type[BaseModel]) -> Tuple[str, ...]:
+    """The dotted alias paths of the fields of `model` marked ENCRYPTED, e.g. ("ssn", "insurance.memberId")."""
+    paths: List[str] = []
+    for name, field in model.model_fields.items():
+        key = field.alias or name
+        if isinstance(field.json_schema_extra, dict) and field.json_schema_extra.get("x-encrypted"):
+            paths.append(key)
+        for nested in _nested_models(field.annotation):
+            paths += [f"{key}.{path}" for path in encrypted_paths(nested)]
+    return tuple(paths)
+
+
+def _at(data: Any, path: str) -> Any:
+    for part in path.split("."):
+        if not isinstance(data, dict):
+            return None
+        data = data.get(part)
+    return data
+
+
+def _replace(data: Dict[str, Any], parts: List[str], change: Callable[[Any], Any]) -> Dict[str, Any]:
+    """A copy of `data` with the value at `parts` changed, if it is set."""
+    head, rest = parts[0], parts[1:]
+    if not isinstance(data, dict) or data.get(head) is None:
+        return data
+    return {**data, head: _replace(data[head], rest, change) if rest else change(data[head])}
+
+
+def seal_fields(data: Dict[str, Any], paths: Tuple[str, ...], cipher: Optional[FieldCipher]) -> Dict[str, Any]:
+    """Returns `data` (a record by alias) with the values at `paths` sealed; unchanged without a cipher."""
+    if cipher is None:
+        return data
+    for path in paths:
+        data = _replace(data, path.split("."), lambda value, path=path: value if is_sealed(value) else cipher.seal(value, path))
+    return data
+
+
+def open_fields(
+    data: Dict[str, Any], paths: Tuple[str, ...], cipher: Optional[FieldCipher],
+) -> Tuple[Dict[str, Any], Dict[str, Reseal]]:
+    """
+    Returns `data` with the values at `paths` opened, and those to seal again
+    by path: values under an older key version, and values stored before
+    encryption was turned on. Raises FieldEncryptionError for a sealed value
+    when no cipher is configured.
+    """
+    reseal: Dict[str, Reseal] = {}
+    for path in paths:
+        stored = _at(data, path)
+        if stored is None:
+            continue
+        if not is_sealed(stored):
+            if cipher is not None:
+                reseal[path] = (stored, cipher.seal(stored, path))
+            continue
+        if cipher is None:
+            raise FieldEncryptionError(f"'{path}' is encrypted but FIELD_ENCRYPTION_KEY is not set")
+        value = cipher.open(stored, path)
+        if not cipher.is_current(stored):
+            reseal[path] = (stored, cipher.seal(value, path))
+        data = _replace(data, path.split("."), lambda _stored, value=value: value)
+    return data, reseal
+
+
+def without_encrypted(data: Dict[str, Any], paths: Tuple[str, ...]) -> Dict[str, Any]:
+    """Returns `data` with the values at `paths` set to None, e.g. for a domain event leaving the service."""
+    for path in paths:
+        data = _replace(data, path.split("."), lambda _value: None)
+    return data
@@ -0,0 +1,58 @@
+# Location: app/crypto/kms.py
+
+from abc import ABC, abstractmethod
+from typing import Tuple
+
+# Cloud KMS names the version that encrypted a ciphertext
+#   projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>/cryptoKeyVersions/<n>
+# and decrypts with the key (everything before `/cryptoKeyVersions/`), which
+# finds the version from the ciphertext itself.
+_VERSION_SEPARATOR = "/cryptoKeyVersions/"
+
+
+def key_of(key_version: str) -> str:
+    """The CryptoKey resource name of a CryptoKeyVersion name."""
+    return key_version.split(_VERSION_SEPARATOR, 1)[0]
+
+
+class KeyManager(ABC):
+    """Wraps and unwraps the data keys fields are encrypted with (see app/crypto/envelope.py)."""
+
+    @abstractmethod
+    def wrap(self, data_key: bytes) -> Tuple[bytes, str]:
+        """Encrypts `data_key` under the current primary key version; returns it and that version's name."""
+
+    @abstractmethod
+    def unwrap(self, wrapped: bytes, key_version: str) -> bytes:
+        """Decrypts a data key wrapped by `wrap`, under `key_version` or any later key."""
+
+
+class CloudKmsKeyManager(KeyManager):
+    """
+    Wraps data keys with a Cloud KMS symmetric key. Rotating the key (on a
+    schedule, or `gcloud kms keys versions create`) makes the new version
+    primary; data keys wrapped before stay readable as long as their version
+    is enabled, so disable or destroy old versions only once nothing is
+    sealed under them any more. The service account needs
+    `roles/cloudkms.cryptoKeyEncrypterDecrypter` on the key.
+    """
+
+    def __init__(self, key_name: str, client=None):
+        self.key_name = key_name
+        self._client = client
+
+    @property
+    def client(self):
+        if self._client is None:
+            from google.cloud import kms
+            self._client = kms.KeyManagementServiceClient()
+        return self._client
+
+    def wrap(self, data_key: bytes) -> Tuple[bytes, str]:
+        response = self.client.encrypt(request={"name": self.key_name, "plaintext": data_key})
+        return response.ciphertext, response.name
+
+    def unwrap(self, wrapped: bytes, key_version: str) -> bytes:
+        # Values sealed under a key that has since been replaced (a new
+        # FIELD_ENCRYPTION_KEY) are still decrypted with the key they name.
+        return self.client.decrypt(request={"name": key_of(key_version), "ciphertext": wrapped}).plaintext
@@ -0,0 +1,195 @@
+# Location: app/db/migrate.py
+
+"""
+Schema migrations for the PostgreSQL store, in the style of golang-migrate:
+numbered `NNNNNN_<name>.up.sql` / `.down.sql` pairs in app/db/migrations,
+shipped with the application, and a single-row `schema_migrations` table
+holding the current version and a `dirty` flag.
+
+Each migration runs in its own transaction. If one fails, the version is
+left marked dirty and further runs refuse to start until an operator has
+repaired the schema and run `force`.
+
+    python -m app.db.migrate up            # apply all pending migrations
+    python -m app.db.migrate down 1        # roll back the latest migration
+    python -m app.db.migrate version       # print the current version
+    python -m app.db.migrate force 3       # set the version without running anything
+
+With DATABASE_MIGRATE_ON_STARTUP=true the API applies pending migrations
+itself when a worker starts (see app/main.py).
+"""
+
+import logging
+import re
+import sys
+from contextlib import contextmanager
+from dataclasses import dataclass
+from pathlib import Path
+from typing import List, Optional, Tuple
+
+MIGRATIONS_DIR = Path(__file__).parent / "migrations"
+
+# Held for the duration of a run so concurrently starting workers or
+# instances apply each migration exactly once.
+MIGRATION_LOCK_ID = 728_001
+
+_FILE_RE = re.compile(r"^(\d+)_(\w+)\.(up|down)\.sql$")
+
+
+class MigrationError(Exception):
+    """Raised when migrations cannot be applied, e.g. because the schema is dirty."""
+
+
+@dataclass
+class Migration:
+    version: int
+    name: str
+    up: str
+    down: Optional[str] = None
+
+
+def load_migrations(directory: Path = MIGRATIONS_DIR) -> List[Migration]:
+    """Reads the migration files in `directory`, ordered by version."""
+    migrations = {}
+    for path in directory.iterdir():
+        match = _FILE_RE.match(path.name)
+        if not match:
+            continue
+        version, name, direction = int(match.group(1)), match.group(2), match.group(3)
+        migration = migrations.setdefault(version, Migration(version, name, up=""))
+        if migration.name != name:
+            raise MigrationError(f"Migration {version} has files with different names: '{migration.name}' and '{name}'")
+        if direction == "up":
+            migration.up = path.read_text()
+        else:
+            migration.down = path.read_text()
+    for migration in migrations.values():
+        if not migration.up:
+            raise MigrationError(f"Migration {migration.version}_{migration.name} has no .up.sql file")
+    return [migrations[v] for v in sorted(migrations)]
+
+
+def _ensure_table(conn) -> None:
+    conn.execute("CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL, dirty BOOLEAN NOT NULL)")
+
+
+def current_version(conn) -> Tuple[Optional[int], bool]:
+    """Returns (version, dirty); version is None before the first migration."""
+    _ensure_table(conn)
+    row = conn.execute("SELECT version, dirty FROM schema_migrations").fetchone()
+    return (row["version"], row["dirty"]) if row else (None, False)
+
+
+def _set_version(conn, version: Optional[int], dirty: bool) -> None:
+    conn.execute("DELETE FROM schema_migrations")
+    if version is not None:
+        conn.execute("INSERT INTO schema_migrations (version, dirty) VALUES (%s, %s)", [version, dirty])
+
+
+def _run(pool, migration: Migration, sql: str, target: Optional[int]) -> None:
+    # Mark the version dirty first, in its own transaction, so that a failure
+    # halfway through leaves a record of which migration broke.
+    with pool.connection() as conn:
+        _set_version(conn, migration.version, True)
+    with pool.connection() as conn:
+        conn.execute(sql)
+        _set_version(conn, target, False)
+
+
+@contextmanager
+def _migration_lock(pool):
+    # Holds one pooled connection for the lock; the migrations run on others.
+    with pool.connection() as conn:
+        conn.autocommit = True
+        conn.execute("SELECT pg_advisory_lock(%s)", [MIGRATION_LOCK_ID])
+        try:
+            yield
+        finally:
+            conn.execute("SELECT pg_advisory_unlock(%s)", [MIGRATION_LOCK_ID])
+            conn.autocommit = False
+
+
+def migrate_up(pool, migrations: Optional[List[Migration]] = None) -> List[int]:
+    """Applies every migration newer than the current version. Returns the versions applied."""
+    migrations = load_migrations() if migrations is None else migrations
+    with _migration_lock(pool):
+        with pool.connection() as conn:
+            version, dirty = current_version(conn)
+        if dirty:
+            raise MigrationError(f"Schema is dirty at version {version}; repair it and run 'force' first")
+        applied = []
+        for migration in migrations:
+            if version is not None and migration.version <= version:
+                continue
+            logging.info(f"Applying migration {migration.version}_{migration.name}")
+            _run(pool, migration, migration.up, migration.version)
+            applied.append(migration.version)
+        return applied
+
+
+def migrate_down(pool, steps: int, migrations: Optional[List[Migration]] = None) -> List[int]:
+    """Rolls back the latest `steps` migrations. Returns the versions rolled back."""
+    migrations = load_migrations() if migrations is None else migrations
+    with _migration_lock(pool):
+        with pool.connection() as conn:
+            version, dirty = current_version(conn)
+        if dirty:
+            raise MigrationError(f"Schema is dirty at version {version}; repair it and run 'force' first")
+        applied = [m for m in migrations if version is not None and m.version <= version]
+        rolled_back = []
+        for index in range(len(applied) - 1, max(len(applied) - steps, 0) - 1, -1):
+            migration = applied[index]
+            if migration.down is None:
+                raise MigrationError(f"Migration {migration.version}_{migration.name} has no .down.sql file")
+            previous = applied[index - 1].version if index > 0 else None
+            logging.info(f"Rolling back migration {migration.version}_{migration.name}")
+            _run(pool, migration, migration.down, previous)
+            rolled_back.append(migration.version)
+        return rolled_back
+
+
+def force(pool, version: int) -> None:
+    """Sets the version and clears the dirty flag without running any migration."""
+    with pool.connection() as conn:
+        _ensure_table(conn)
+        _set_version(conn, version, False)
+
+
+def main(argv: List[str]) -> int:
+    from app.core.postgres import close_pool, get_pool
+
+    usage = "usage: python -m app.db.migrate up | down N | version | force V"
+    if not argv or argv[0] not in ("up", "down", "version", "force"):
+        print(usage, file=sys.stderr)
+        return 2
+    command, args = argv[0], argv[1:]
+    pool = get_pool()
+    try:
+        if command == "up":
+            applied = migrate_up(pool)
+            print(f"Applied {len(applied)} migration(s).")
+        elif command == "down":
+            if len(args) != 1 or not args[0].isdigit():
+                print(usage, file=sys.stderr)
+                return 2
+            rolled_back = migrate_down(pool, int(args[0]))
+            print(f"Rolled back {len(rolled_back)} migration(s).")
+        elif command == "force":
+            if len(args) != 1 or not args[0].isdigit():
+                print(usage, file=sys.stderr)
+                return 2
+            force(pool, int(args[0]))
+        with pool.connection() as conn:
+            version, dirty = current_version(conn)
+        print(f"Version: {version if version is not None else 'none'}{' (dirty)' if dirty else ''}")
+        return 0
+    except MigrationError as e:
+        print(f"error: {e}", file=sys.stderr)
+        return 1
+    finally:
+        close_pool()
+
+
+if __name__ == "__main__":
+    logging.basicConfig(level=logging.INFO)
+    sys.exit(main(sys.argv[1:]))
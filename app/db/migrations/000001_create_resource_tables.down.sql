@@ -0,0 +1,18 @@
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS export_jobs;
+DROP TABLE IF EXISTS consents;
+DROP TABLE IF EXISTS consent_documents;
+DROP TABLE IF EXISTS immunizations;
+DROP TABLE IF EXISTS allergies;
+DROP TABLE IF EXISTS refill_requests;
+DROP TABLE IF EXISTS medications;
+DROP TABLE IF EXISTS lab_results;
+DROP TABLE IF EXISTS observations;
+DROP TABLE IF EXISTS encounters;
+DROP TABLE IF EXISTS appointments;
+DROP TABLE IF EXISTS care_plans;
+DROP TABLE IF EXISTS care_teams;
+DROP TABLE IF EXISTS practitioners;
+DROP TABLE IF EXISTS patients;
@@ -0,0 +1,172 @@
+-- Every resource is stored as a JSONB document in the same shape as its
+-- Firestore counterpart (see app/repositories/postgres/base.py). The GIN
+-- index serves equality and array-contains filters (`data @> ...`); the
+-- expression indexes match `field_sql` and serve range filters and ordering.
+
+CREATE TABLE patients (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX patients_data_idx ON patients USING GIN (data jsonb_path_ops);
+CREATE INDEX patients_updated_at_idx ON patients (updated_at);
+CREATE INDEX patients_family_name_idx ON patients (((data->>'familyName') COLLATE "C"));
+CREATE UNIQUE INDEX patients_mrn_key ON patients ((data->>'mrn'));
+
+CREATE TABLE practitioners (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX practitioners_data_idx ON practitioners USING GIN (data jsonb_path_ops);
+CREATE INDEX practitioners_updated_at_idx ON practitioners (updated_at);
+CREATE INDEX practitioners_family_name_idx ON practitioners (((data->>'familyName') COLLATE "C"));
+CREATE UNIQUE INDEX practitioners_npi_key ON practitioners ((data->>'npi'));
+
+CREATE TABLE care_teams (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX care_teams_data_idx ON care_teams USING GIN (data jsonb_path_ops);
+CREATE INDEX care_teams_updated_at_idx ON care_teams (updated_at);
+
+CREATE TABLE care_plans (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX care_plans_data_idx ON care_plans USING GIN (data jsonb_path_ops);
+CREATE INDEX care_plans_updated_at_idx ON care_plans (updated_at);
+
+CREATE TABLE appointments (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX appointments_data_idx ON appointments USING GIN (data jsonb_path_ops);
+CREATE INDEX appointments_updated_at_idx ON appointments (updated_at);
+CREATE INDEX appointments_start_idx ON appointments (((data->>'start') COLLATE "C"));
+
+CREATE TABLE encounters (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX encounters_data_idx ON encounters USING GIN (data jsonb_path_ops);
+CREATE INDEX encounters_updated_at_idx ON encounters (updated_at);
+CREATE INDEX encounters_start_idx ON encounters (((data->>'start') COLLATE "C"));
+
+CREATE TABLE observations (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX observations_data_idx ON observations USING GIN (data jsonb_path_ops);
+CREATE INDEX observations_updated_at_idx ON observations (updated_at);
+CREATE INDEX observations_effective_at_idx ON observations (((data->>'effectiveAt') COLLATE "C"));
+
+CREATE TABLE lab_results (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX lab_results_data_idx ON lab_results USING GIN (data jsonb_path_ops);
+CREATE INDEX lab_results_updated_at_idx ON lab_results (updated_at);
+CREATE INDEX lab_results_effective_at_idx ON lab_results (((data->>'effectiveAt') COLLATE "C"));
+
+CREATE TABLE medications (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX medications_data_idx ON medications USING GIN (data jsonb_path_ops);
+CREATE INDEX medications_updated_at_idx ON medications (updated_at);
+
+CREATE TABLE refill_requests (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX refill_requests_data_idx ON refill_requests USING GIN (data jsonb_path_ops);
+CREATE INDEX refill_requests_updated_at_idx ON refill_requests (updated_at);
+CREATE INDEX refill_requests_created_at_idx ON refill_requests (created_at);
+
+CREATE TABLE allergies (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX allergies_data_idx ON allergies USING GIN (data jsonb_path_ops);
+CREATE INDEX allergies_updated_at_idx ON allergies (updated_at);
+
+CREATE TABLE immunizations (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX immunizations_data_idx ON immunizations USING GIN (data jsonb_path_ops);
+CREATE INDEX immunizations_updated_at_idx ON immunizations (updated_at);
+CREATE INDEX immunizations_occurrence_date_idx ON immunizations (((data->>'occurrenceDate') COLLATE "C"));
+
+CREATE TABLE consent_documents (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX consent_documents_data_idx ON consent_documents USING GIN (data jsonb_path_ops);
+CREATE INDEX consent_documents_updated_at_idx ON consent_documents (updated_at);
+CREATE UNIQUE INDEX consent_documents_scope_version_key ON consent_documents ((data->>'scope'), ((data->>'version')::int));
+
+CREATE TABLE consents (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX consents_data_idx ON consents USING GIN (data jsonb_path_ops);
+CREATE INDEX consents_updated_at_idx ON consents (updated_at);
+CREATE INDEX consents_granted_at_idx ON consents (((data->>'grantedAt') COLLATE "C"));
+
+CREATE TABLE export_jobs (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX export_jobs_data_idx ON export_jobs USING GIN (data jsonb_path_ops);
+CREATE INDEX export_jobs_updated_at_idx ON export_jobs (updated_at);
+
+CREATE TABLE audit_events (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX audit_events_data_idx ON audit_events USING GIN (data jsonb_path_ops);
+CREATE INDEX audit_events_updated_at_idx ON audit_events (updated_at);
+CREATE INDEX audit_events_occurred_at_idx ON audit_events (((data->>'occurredAt') COLLATE "C"));
+
+-- The audit trail is append-only: reject any change to an existing row.
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
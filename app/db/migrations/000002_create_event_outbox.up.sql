@@ -0,0 +1,14 @@
+-- The transactional outbox for domain events (see app/repositories/postgres/outbox.py).
+-- Entries are written in the same transaction as the change they describe
+-- and relayed to Pub/Sub afterwards. The partial index serves the relay's
+-- claim query, which only ever looks at pending entries.
+
+CREATE TABLE event_outbox (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX event_outbox_due_idx ON event_outbox (((data->>'nextAttemptAt') COLLATE "C"))
+    WHERE data @> '{"status": "pending"}';
+CREATE INDEX event_outbox_updated_at_idx ON event_outbox (updated_at);
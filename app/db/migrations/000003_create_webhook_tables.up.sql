@@ -0,0 +1,22 @@
+-- Partner webhook subscriptions and their delivery log
+-- (see app/repositories/postgres/webhooks.py). Deliveries double as the
+-- dispatcher's queue, served by the partial index on pending rows.
+
+CREATE TABLE webhook_subscriptions (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX webhook_subscriptions_data_idx ON webhook_subscriptions USING GIN (data jsonb_path_ops);
+
+CREATE TABLE webhook_deliveries (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX webhook_deliveries_data_idx ON webhook_deliveries USING GIN (data jsonb_path_ops);
+CREATE INDEX webhook_deliveries_created_at_idx ON webhook_deliveries (created_at);
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (((data->>'nextAttemptAt') COLLATE "C"))
+    WHERE data @> '{"status": "pending"}';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS webhook_deliveries_updated_at_idx;
+DROP TABLE IF EXISTS job_runs;
+DROP TABLE IF EXISTS job_locks;
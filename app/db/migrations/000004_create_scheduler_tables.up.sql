@@ -0,0 +1,21 @@
+-- Locks and run history of scheduled jobs (see app/repositories/postgres/scheduler.py),
+-- and the updated_at index the retention job deletes old webhook deliveries by.
+
+CREATE TABLE job_locks (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE job_runs (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX job_runs_data_idx ON job_runs USING GIN (data jsonb_path_ops);
+CREATE INDEX job_runs_started_at_idx ON job_runs (((data->>'startedAt') COLLATE "C"));
+CREATE INDEX job_runs_updated_at_idx ON job_runs (updated_at);
+
+CREATE INDEX webhook_deliveries_updated_at_idx ON webhook_deliveries (updated_at);
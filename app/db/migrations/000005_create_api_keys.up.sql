@@ -0,0 +1,9 @@
+-- Integrator API keys (see app/repositories/postgres/api_keys.py). Keys are
+-- looked up by ID only; rows hold a hash of the secret, never the secret.
+
+CREATE TABLE api_keys (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
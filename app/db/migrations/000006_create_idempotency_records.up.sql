@@ -0,0 +1,10 @@
+-- Responses kept for requests sent with an Idempotency-Key (see
+-- app/repositories/idempotency.py), purged by updated_at once expired.
+
+CREATE TABLE idempotency_records (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idempotency_records_updated_at_idx ON idempotency_records (updated_at);
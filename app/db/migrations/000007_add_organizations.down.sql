@@ -0,0 +1,8 @@
+DROP INDEX consent_documents_scope_version_key;
+CREATE UNIQUE INDEX consent_documents_scope_version_key ON consent_documents ((data->>'scope'), ((data->>'version')::int));
+DROP INDEX practitioners_npi_key;
+CREATE UNIQUE INDEX practitioners_npi_key ON practitioners ((data->>'npi'));
+DROP INDEX patients_mrn_key;
+CREATE UNIQUE INDEX patients_mrn_key ON patients ((data->>'mrn'));
+
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,23 @@
+-- Organizations (clinics) sharing the deployment, and unique keys that
+-- apply per organization: records carry the `tenantId` of the organization
+-- they were created for (see app/repositories/base.py). Records created
+-- before tenancy have none and count as one organization until
+-- `python -m app.tenancy.backfill` assigns them.
+
+CREATE TABLE organizations (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX organizations_data_idx ON organizations USING GIN (data jsonb_path_ops);
+CREATE INDEX organizations_updated_at_idx ON organizations (updated_at);
+CREATE INDEX organizations_name_idx ON organizations (((data->>'name') COLLATE "C"));
+
+DROP INDEX patients_mrn_key;
+CREATE UNIQUE INDEX patients_mrn_key ON patients ((COALESCE(data->>'tenantId', '')), (data->>'mrn'));
+DROP INDEX practitioners_npi_key;
+CREATE UNIQUE INDEX practitioners_npi_key ON practitioners ((COALESCE(data->>'tenantId', '')), (data->>'npi'));
+DROP INDEX consent_documents_scope_version_key;
+CREATE UNIQUE INDEX consent_documents_scope_version_key
+    ON consent_documents ((COALESCE(data->>'tenantId', '')), (data->>'scope'), ((data->>'version')::int));
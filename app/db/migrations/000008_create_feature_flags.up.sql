@@ -0,0 +1,8 @@
+-- Feature flags managed through /admin/flags (see app/flags/flags.py).
+
+CREATE TABLE feature_flags (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
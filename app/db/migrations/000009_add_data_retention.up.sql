@@ -0,0 +1,31 @@
+-- Per-organization retention overrides and the manifests of the retention
+-- job (see app/retention). The audit trail stays append-only, except for the
+-- retention statements, which set `megacare.retention_pass` in their
+-- transaction (see app/repositories/postgres/base.py).
+
+CREATE TABLE retention_policies (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE purge_manifests (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX purge_manifests_data_idx ON purge_manifests USING GIN (data jsonb_path_ops);
+CREATE INDEX purge_manifests_updated_at_idx ON purge_manifests (updated_at);
+CREATE INDEX purge_manifests_started_at_idx ON purge_manifests (((data->>'startedAt') COLLATE "C"));
+CREATE INDEX purge_manifests_cutoff_idx ON purge_manifests (((data->>'cutoff') COLLATE "C"));
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF current_setting('megacare.retention_pass', true) = 'on' THEN
+        RETURN COALESCE(NEW, OLD);
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,11 @@
+-- Metadata of the documents attached to patients' charts; the files are in
+-- the PATIENT_DOCUMENTS_BUCKET Cloud Storage bucket.
+
+CREATE TABLE patient_documents (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX patient_documents_data_idx ON patient_documents USING GIN (data jsonb_path_ops);
+CREATE INDEX patient_documents_updated_at_idx ON patient_documents (updated_at);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS wound_images;
@@ -0,0 +1,11 @@
+-- Metadata of wound-care photos; the photos and thumbnails are in the
+-- WOUND_IMAGES_BUCKET Cloud Storage bucket.
+
+CREATE TABLE wound_images (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX wound_images_data_idx ON wound_images USING GIN (data jsonb_path_ops);
+CREATE INDEX wound_images_updated_at_idx ON wound_images (updated_at);
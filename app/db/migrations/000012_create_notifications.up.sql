@@ -0,0 +1,11 @@
+-- Messages sent to patients (appointment confirmations, result-ready
+-- notices) and the delivery events reported for them.
+
+CREATE TABLE notifications (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX notifications_data_idx ON notifications USING GIN (data jsonb_path_ops);
+CREATE INDEX notifications_updated_at_idx ON notifications (updated_at);
@@ -0,0 +1,10 @@
+-- FCM registration tokens of the patient app, one row per token.
+
+CREATE TABLE push_tokens (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX push_tokens_data_idx ON push_tokens USING GIN (data jsonb_path_ops);
+CREATE INDEX push_tokens_updated_at_idx ON push_tokens (updated_at);
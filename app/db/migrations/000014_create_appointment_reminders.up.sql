@@ -0,0 +1,11 @@
+-- Reminders scheduled for appointments, one row per reminder.
+
+CREATE TABLE appointment_reminders (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX appointment_reminders_data_idx ON appointment_reminders USING GIN (data jsonb_path_ops);
+CREATE INDEX appointment_reminders_updated_at_idx ON appointment_reminders (updated_at);
+CREATE INDEX appointment_reminders_due_idx ON appointment_reminders (((data->>'dueAt') COLLATE "C"));
@@ -0,0 +1,11 @@
+-- Events sent on the event stream, kept for EVENT_STREAM_RETENTION_HOURS.
+
+CREATE TABLE stream_events (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX stream_events_data_idx ON stream_events USING GIN (data jsonb_path_ops);
+CREATE INDEX stream_events_updated_at_idx ON stream_events (updated_at);
+CREATE INDEX stream_events_occurred_idx ON stream_events (((data->>'occurredAt') COLLATE "C"));
@@ -0,0 +1,11 @@
+-- Video visits of telehealth appointments and the participant events
+-- their duration is billed from.
+
+CREATE TABLE telehealth_sessions (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX telehealth_sessions_data_idx ON telehealth_sessions USING GIN (data jsonb_path_ops);
+CREATE INDEX telehealth_sessions_updated_at_idx ON telehealth_sessions (updated_at);
@@ -0,0 +1,10 @@
+-- Bulk NDJSON import jobs and how far through their file they have got.
+
+CREATE TABLE import_jobs (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX import_jobs_data_idx ON import_jobs USING GIN (data jsonb_path_ops);
+CREATE INDEX import_jobs_updated_at_idx ON import_jobs (updated_at);
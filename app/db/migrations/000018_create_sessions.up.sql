@@ -0,0 +1,11 @@
+-- Patient portal sign-in sessions (see app/repositories/postgres/sessions.py).
+-- Rows hold hashes of the refresh tokens, never the tokens.
+
+CREATE TABLE sessions (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX sessions_data_idx ON sessions USING GIN (data jsonb_path_ops);
+CREATE INDEX sessions_updated_at_idx ON sessions (updated_at);
@@ -0,0 +1,11 @@
+-- Emailed sign-in links (see app/repositories/postgres/magic_links.py).
+-- Rows are what make a link single-use; the tokens are never stored.
+
+CREATE TABLE magic_links (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX magic_links_data_idx ON magic_links USING GIN (data jsonb_path_ops);
+CREATE INDEX magic_links_updated_at_idx ON magic_links (updated_at);
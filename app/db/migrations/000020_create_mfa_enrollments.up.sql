@@ -0,0 +1,10 @@
+-- Users' second factors (see app/repositories/postgres/mfa.py), keyed by
+-- uid. Rows hold hashes of codes; secrets and numbers are sealed.
+
+CREATE TABLE mfa_enrollments (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX mfa_enrollments_updated_at_idx ON mfa_enrollments (updated_at);
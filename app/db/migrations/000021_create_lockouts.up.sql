@@ -0,0 +1,12 @@
+-- Failed sign-in attempts by account and source address (see
+-- app/repositories/postgres/lockouts.py). Rows are forgotten once quiet
+-- for OPERATIONAL_RETENTION_DAYS.
+
+CREATE TABLE lockouts (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX lockouts_data_idx ON lockouts USING GIN (data jsonb_path_ops);
+CREATE INDEX lockouts_updated_at_idx ON lockouts (updated_at);
@@ -0,0 +1,12 @@
+-- CSV exports of the audit trail (see app/repositories/postgres/audit_exports.py).
+-- Rows record the filters and where the file was written; the events
+-- themselves stay in audit_events.
+
+CREATE TABLE audit_exports (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX audit_exports_data_idx ON audit_exports USING GIN (data jsonb_path_ops);
+CREATE INDEX audit_exports_updated_at_idx ON audit_exports (updated_at);
@@ -0,0 +1,11 @@
+-- Roles organizations define from granular permissions
+-- (see app/repositories/postgres/custom_roles.py). IDs are
+-- '<organizationId>:<name>'.
+
+CREATE TABLE custom_roles (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX custom_roles_data_idx ON custom_roles USING GIN (data jsonb_path_ops);
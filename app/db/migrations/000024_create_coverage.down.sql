@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS eligibility_checks;
+DROP TABLE IF EXISTS coverage;
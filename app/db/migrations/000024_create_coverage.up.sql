@@ -0,0 +1,20 @@
+-- Patients' health plans and the eligibility checks made against them
+-- (see app/repositories/postgres/coverage.py). A check keeps the payer's
+-- summarized answer, which later checks of the same coverage may reuse.
+
+CREATE TABLE coverage (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX coverage_data_idx ON coverage USING GIN (data jsonb_path_ops);
+
+CREATE TABLE eligibility_checks (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX eligibility_checks_data_idx ON eligibility_checks USING GIN (data jsonb_path_ops);
+CREATE INDEX eligibility_checks_created_at_idx ON eligibility_checks (created_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS claims;
+DROP TABLE IF EXISTS charge_items;
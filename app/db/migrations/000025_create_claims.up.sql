@@ -0,0 +1,21 @@
+-- Charge items recorded on encounters and the claims billing them (see
+-- app/repositories/postgres/claims.py). A claim keeps its lines, its status
+-- history and what the payer paid or why it denied it.
+
+CREATE TABLE charge_items (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX charge_items_data_idx ON charge_items USING GIN (data jsonb_path_ops);
+
+CREATE TABLE claims (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX claims_data_idx ON claims USING GIN (data jsonb_path_ops);
+CREATE INDEX claims_status_idx ON claims (((data->>'status') COLLATE "C"));
+CREATE INDEX claims_created_at_idx ON claims (created_at);
@@ -0,0 +1,12 @@
+-- Patient invoices drafted from adjudicated claims, with their lines and
+-- the payments taken against them (see app/repositories/postgres/invoices.py).
+
+CREATE TABLE invoices (
+    id TEXT PRIMARY KEY,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX invoices_data_idx ON invoices USING GIN (data jsonb_path_ops);
+CREATE INDEX invoices_status_idx ON invoices (((data->>'status') COLLATE "C"));
+CREATE INDEX invoices_created_at_idx ON invoices (created_at);
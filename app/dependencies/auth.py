@@ -1,36 +1,129 @@
-from typing import Dict
+from typing import Callable, Dict, Optional, Set
 
-from fastapi import Depends, HTTPException, status
-from fastapi.security import HTTPBearer, HTTPAuthorizationCredentials
-from firebase_admin import auth
+from fastapi import Depends, HTTPException, Query, status
+from fastapi.security import APIKeyHeader, HTTPBearer, HTTPAuthorizationCredentials
+
+from app.audit.context import set_actor
+from app.auth.api_keys import API_KEY_HEADER
+from app.auth.context import get_principal
+from app.auth.google_tokens import verify_google_id_token
+from app.auth.verifier import get_token_verifier
+from app.authz.mfa import check_mfa
+from app.authz.roles import ADMIN
 
 security = HTTPBearer()
+# User-facing endpoints also accept partner API keys, so neither scheme is
+# required on its own; `get_current_user` answers 401 when both are missing.
+optional_bearer = HTTPBearer(auto_error=False, bearerFormat="JWT", description="A user's Firebase or OIDC ID token.")
+api_key_header = APIKeyHeader(name=API_KEY_HEADER, auto_error=False, description="A partner API key; allowed on routes covered by its scopes.")
 
 
-def get_current_user(credentials: HTTPAuthorizationCredentials = Depends(security)) -> Dict:
+def get_current_user(
+    credentials: Optional[HTTPAuthorizationCredentials] = Depends(optional_bearer),
+    api_key: Optional[str] = Depends(api_key_header),
+) -> Dict:
     """
-    FastAPI dependency to get the current user's info from a Firebase Auth ID token.
-    Verifies the token and returns the decoded claims.
+    FastAPI dependency that returns the verified claims of the caller's
+    bearer token or API key. Behind AuthenticationMiddleware the credentials
+    have already been verified; otherwise a bearer token is verified here
+    with the AUTH_PROVIDER verifier. API keys are only honoured by the
+    middleware, which also enforces their scopes.
     """
-    token = credentials.credentials
-    if not token:
-        raise HTTPException(
-            status_code=status.HTTP_401_UNAUTHORIZED,
-            detail="Authentication token not provided",
-            headers={"WWW-Authenticate": "Bearer"},
-        )
-    try:
-        decoded_token = auth.verify_id_token(token)
-        return decoded_token
-    except auth.InvalidIdTokenError as e:
+    claims = get_principal()
+    if claims is None:
+        token = credentials.credentials if credentials else None
+        if not token:
+            raise HTTPException(
+                status_code=status.HTTP_401_UNAUTHORIZED,
+                detail="API keys are not accepted on this route" if api_key else "Authentication token not provided",
+                headers={"WWW-Authenticate": "Bearer"},
+            )
+        try:
+            claims = get_token_verifier().verify(token)
+        except Exception as e:
+            raise HTTPException(
+                status_code=status.HTTP_401_UNAUTHORIZED,
+                detail=f"Invalid authentication credentials: {e}",
+                headers={"WWW-Authenticate": "Bearer"},
+            )
+    set_actor(claims)
+    return claims
+
+
+def user_roles(user: Dict) -> Set[str]:
+    """Returns the roles granted to the user through the `roles` custom claim."""
+    roles = user.get("roles")
+    return {str(role) for role in roles} if isinstance(roles, list) else set()
+
+
+def require_roles(*roles: str) -> Callable[..., Dict]:
+    """
+    Returns a dependency that admits only users holding at least one of
+    `roles`, and otherwise responds 403. Users whose roles require a second
+    factor must have proved one:
+
+        current_user: Dict = Depends(require_roles("compliance"))
+    """
+    allowed = set(roles)
+
+    def dependency(current_user: Dict = Depends(get_current_user)) -> Dict:
+        if not user_roles(current_user) & allowed:
+            raise HTTPException(
+                status_code=status.HTTP_403_FORBIDDEN,
+                detail="You do not have permission to access this resource",
+            )
+        check_mfa(current_user)
+        return current_user
+    return dependency
+
+
+def include_deleted(
+    include: bool = Query(False, alias="includeDeleted", description="Also return deleted records. Administrators only."),
+    current_user: Dict = Depends(get_current_user),
+) -> bool:
+    """
+    FastAPI dependency for the `?includeDeleted=true` filter of soft-deleted
+    resources; run the reads under `including_deleted(include)`. Only
+    administrators may see deleted records, so anyone else asking gets 403.
+    """
+    if include and ADMIN not in user_roles(current_user):
         raise HTTPException(
-            status_code=status.HTTP_401_UNAUTHORIZED,
-            detail=f"Invalid Firebase ID token: {e}",
-            headers={"WWW-Authenticate": "Bearer"},
+            status_code=status.HTTP_403_FORBIDDEN,
+            detail="Only administrators may include deleted records",
         )
-    except Exception as e:
-        raise HTTPException(
-            status_code=status.HTTP_401_UNAUTHORIZED,
-            detail=f"Invalid authentication credentials: {e}",
-            headers={"WWW-Authenticate": "Bearer"},
-        )
\ No newline at end of file
+    return include
+
+
+def verify_service_account_token(token: str, audience: str, service_account: str) -> Dict:
+    """
+    Verifies a Google-signed OIDC token, as attached by Cloud Tasks and Cloud
+    Scheduler, and checks it was issued to `service_account` for `audience`.
+    Raises ValueError otherwise.
+    """
+    claims = verify_google_id_token(token, audience)
+    if claims.get("email") != service_account:
+        raise ValueError(f"token was not issued to {service_account}")
+    return claims
+
+
+def require_service_account(service_account: Optional[str], audience: Optional[str]) -> Callable[..., Dict]:
+    """
+    Returns a dependency that admits only requests carrying an OIDC token of
+    the given Google service account, for internal endpoints that are called
+    by Google Cloud services rather than users. Without a configured account
+    every request is refused.
+    """
+    def dependency(credentials: HTTPAuthorizationCredentials = Depends(security)) -> Dict:
+        if not service_account or not audience:
+            raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="No internal caller is configured")
+        try:
+            claims = verify_service_account_token(credentials.credentials, audience, service_account)
+        except Exception as e:
+            raise HTTPException(
+                status_code=status.HTTP_401_UNAUTHORIZED,
+                detail=f"Invalid service account token: {e}",
+                headers={"WWW-Authenticate": "Bearer"},
+            )
+        set_actor({"uid": claims["email"]})
+        return claims
+    return dependency
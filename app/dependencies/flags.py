@@ -0,0 +1,19 @@
+from typing import Callable
+
+from fastapi import HTTPException, status
+
+from app.flags.flags import get_feature_flags
+
+
+def require_flag(key: str) -> Callable[[], None]:
+    """
+    Returns a dependency that answers 404, as for a route that does not
+    exist, unless the feature flag `key` is on for the caller. Use it to
+    dark-launch a route or a whole router:
+
+        router = APIRouter(dependencies=[Depends(require_flag("telehealth-visits"))])
+    """
+    def dependency() -> None:
+        if not get_feature_flags().is_enabled(key):
+            raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="Not Found")
+    return dependency
@@ -0,0 +1,23 @@
+from typing import Optional
+
+from fastapi import Depends
+
+from app.api.v1.deps import get_notification_repository, get_push_token_repository, get_task_queue
+from app.core.config import get_settings
+from app.notifications.notifier import Notifier, SmsThrottle
+from app.ratelimit.buckets import get_token_buckets
+from app.tasks.queue import TaskQueue
+
+
+def get_notifier(tasks: TaskQueue = Depends(get_task_queue)) -> Optional[Notifier]:
+    """
+    The notifier of patients, or None with none of EMAIL_PROVIDER,
+    SMS_PROVIDER and PUSH_PROVIDER set, when no message is sent or recorded.
+    """
+    settings = get_settings()
+    email, sms, push = settings.email_provider != "none", settings.sms_provider != "none", settings.push_provider != "none"
+    if not email and not sms and not push:
+        return None
+    throttle = SmsThrottle(get_token_buckets(), settings.sms_max_per_hour, settings.sms_max_per_patient_per_day) if sms else None
+    push_tokens = get_push_token_repository() if push else None
+    return Notifier(get_notification_repository(), tasks, email=email, sms_throttle=throttle, push_tokens=push_tokens)
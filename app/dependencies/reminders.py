@@ -0,0 +1,24 @@
+from typing import Optional
+
+from fastapi import Depends
+
+from app.api.v1.deps import get_appointment_reminder_repository, get_task_queue
+from app.core.config import get_settings
+from app.dependencies.notifications import get_notifier
+from app.notifications.notifier import Notifier
+from app.reminders.scheduling import ReminderScheduler
+from app.tasks.queue import TaskQueue
+
+
+def get_reminder_scheduler(
+    tasks: TaskQueue = Depends(get_task_queue), notifier: Optional[Notifier] = Depends(get_notifier),
+) -> Optional[ReminderScheduler]:
+    """
+    The scheduler of appointment reminders, or None when no reminder would
+    be sent: APPOINTMENT_REMINDER_HOURS is empty, or no notification
+    provider is set.
+    """
+    settings = get_settings()
+    if notifier is None or not settings.reminder_offsets_hours:
+        return None
+    return ReminderScheduler(get_appointment_reminder_repository(), tasks, settings.reminder_offsets_hours, settings.reminder_quiet_hours)
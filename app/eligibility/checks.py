@@ -0,0 +1,124 @@
+# Location: app/eligibility/checks.py
+
+import hashlib
+import logging
+from datetime import date, datetime, timedelta, timezone
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.core.config import get_settings
+from app.eligibility.clearinghouse import Clearinghouse, ClearinghouseError, EligibilityInquiry
+from app.repositories.coverage import CoverageRepository, EligibilityCheckRepository
+from app.repositories.patients import PatientRepository
+
+# Checks of a coverage looked through for an answer to reuse.
+REUSE_LOOKBACK = 20
+
+
+def control_number(reference: str) -> str:
+    """The 9-digit number an inquiry or claim is sent with; the same for every attempt at sending it."""
+    return f"{int(hashlib.sha256(reference.encode()).hexdigest(), 16) % 10**9:09d}"
+
+
+def billing_provider(organization: Optional[schemas.Organization]) -> Optional[schemas.BillingProvider]:
+    """Who the organization asks payers as: its own billing provider, or BILLING_PROVIDER_NPI."""
+    if organization and organization.billing_provider:
+        return organization.billing_provider
+    settings = get_settings()
+    if not settings.billing_provider_npi:
+        return None
+    address = None
+    if settings.billing_provider_address_line:
+        address = schemas.BillingAddress(
+            line=settings.billing_provider_address_line, city=settings.billing_provider_city,
+            state=settings.billing_provider_state, postalCode=settings.billing_provider_postal_code,
+        )
+    return schemas.BillingProvider(
+        npi=settings.billing_provider_npi, name=settings.billing_provider_name or settings.email_from_name,
+        taxId=settings.billing_provider_tax_id, address=address,
+    )
+
+
+def reusable_check(
+    checks: EligibilityCheckRepository,
+    coverage: schemas.Coverage,
+    patient: schemas.Patient,
+    service_date: date,
+    service_types: List[str],
+    max_age_seconds: int,
+    now: Optional[datetime] = None,
+) -> Optional[schemas.EligibilityCheck]:
+    """
+    A completed check of the coverage for the same date of service and
+    service types, answered within `max_age_seconds` and made since the
+    coverage and patient were last changed, so it asked what a new check
+    would. Only answers saying whether the patient is covered are reused.
+    """
+    if max_age_seconds <= 0:
+        return None
+    now = now or datetime.now(timezone.utc)
+    for check in checks.list(coverage.coverage_id, limit=REUSE_LOOKBACK):
+        if (
+            check.status == "completed" and check.eligible is not None
+            and check.service_date == service_date and check.service_types == service_types
+            and check.completed_at and now - check.completed_at <= timedelta(seconds=max_age_seconds)
+            and check.created_at >= coverage.updated_at and check.created_at >= patient.updated_at
+        ):
+            return check
+    return None
+
+
+def run_eligibility_check(
+    check_id: str,
+    checks: EligibilityCheckRepository,
+    coverages: CoverageRepository,
+    patients: PatientRepository,
+    clearinghouse: Clearinghouse,
+    provider: Optional[schemas.BillingProvider],
+) -> Optional[schemas.EligibilityCheck]:
+    """
+    Asks the payer about the check's coverage and records the summarized
+    answer. Returns the check as recorded, or None if it was already done,
+    so a repeated run asks nothing. Raises ClearinghouseError when asking
+    again may help; the check stays in progress until it does.
+    """
+    check = checks.get(check_id)
+    if not check or check.status not in ("accepted", "in-progress"):
+        return None
+    coverage = coverages.get(check.coverage_id)
+    patient = patients.get(check.patient_id)
+    if not coverage or not patient:
+        return checks.update(check_id, {"status": "error", "error": "The coverage or patient no longer exists"})
+    if provider is None:
+        return checks.update(check_id, {"status": "error", "error": "No billing provider NPI is set for the organization (or BILLING_PROVIDER_NPI)"})
+    checks.update(check_id, {"status": "in-progress"})
+
+    inquiry = EligibilityInquiry(
+        control_number=control_number(check_id),
+        provider=provider,
+        coverage=coverage,
+        patient=patient,
+        service_date=check.service_date,
+        service_types=check.service_types,
+    )
+    try:
+        answer = clearinghouse.check(inquiry)
+    except ClearinghouseError as e:
+        if e.retryable:
+            checks.update(check_id, {"error": str(e)})
+            raise
+        logging.error(f"Eligibility check {check_id} failed: {e}", extra={"report_error": True})
+        return checks.update(check_id, {"status": "error", "error": str(e)})
+
+    logging.info(f"Eligibility check {check_id} completed: eligible={answer.eligible}, {len(answer.benefits)} benefits, {len(answer.rejections)} rejections.")
+    return checks.update(check_id, {
+        "status": "completed",
+        "eligible": answer.eligible,
+        "planStatus": answer.plan_status,
+        "planName": answer.plan_name,
+        "benefits": [benefit.model_dump(by_alias=True) for benefit in answer.benefits],
+        "rejections": [rejection.model_dump(by_alias=True) for rejection in answer.rejections],
+        "error": None,
+        "traceNumber": inquiry.control_number,
+        "completedAt": datetime.now(timezone.utc),
+    })
@@ -0,0 +1,272 @@
+# Location: app/eligibility/clearinghouse.py
+
+import threading
+import time
+from abc import ABC, abstractmethod
+from dataclasses import dataclass, field
+from datetime import date
+from decimal import Decimal, InvalidOperation
+from functools import lru_cache
+from typing import Any, Dict, List, Optional, Tuple
+
+import httpx
+
+from app.api.v1 import schemas
+from app.core.config import get_settings
+from app.core.http_client import new_client
+
+# X12 eligibility or benefit codes (EB01) meaning the patient is covered,
+# and those meaning they are not.
+ACTIVE_CODES = frozenset({"1", "2", "3", "4", "5"})
+INACTIVE_CODES = frozenset({"6", "7", "8", "I"})
+
+# EB01 codes of the amounts summarized.
+COINSURANCE, COPAY, DEDUCTIBLE, OUT_OF_POCKET = "A", "B", "C", "G"
+
+# The time qualifier (EB06) of an amount still to be met this period.
+REMAINING = "29"
+
+NETWORKS = {"Y": "in-network", "N": "out-of-network"}
+COVERAGE_LEVELS = {"IND": "individual", "FAM": "family", "EMP": "employee"}
+RELATIONSHIP_CODES = {"spouse": "01", "child": "19", "other": "G8"}
+GENDER_CODES = {"male": "M", "female": "F"}
+
+
+class ClearinghouseError(Exception):
+    """
+    Raised when the clearinghouse could not be reached or failed to answer.
+    `retryable` is False when asking again cannot help, e.g. a request it
+    refuses as malformed.
+    """
+
+    def __init__(self, message: str, retryable: bool = True):
+        super().__init__(message)
+        self.retryable = retryable
+
+
+@dataclass
+class EligibilityInquiry:
+    """What an X12 270 asks: does the coverage pay for these services on this date."""
+    control_number: str
+    provider: schemas.BillingProvider
+    coverage: schemas.Coverage
+    patient: schemas.Patient
+    service_date: date
+    service_types: List[str]
+
+
+@dataclass
+class EligibilityAnswer:
+    """The payer's X12 271, summarized. `rejections` are set instead when it could not answer."""
+    eligible: Optional[bool]
+    plan_status: Optional[str] = None
+    plan_name: Optional[str] = None
+    benefits: List[schemas.BenefitSummary] = field(default_factory=list)
+    rejections: List[schemas.EligibilityRejection] = field(default_factory=list)
+
+
+class Clearinghouse(ABC):
+    """Relays eligibility inquiries to payers and reads their answers."""
+
+    name: str
+
+    @abstractmethod
+    def check(self, inquiry: EligibilityInquiry) -> EligibilityAnswer:
+        """Asks the payer; may take as long as the payer does. Raises ClearinghouseError."""
+
+
+def to_cents(value: Any) -> Optional[int]:
+    """An amount as the clearinghouse writes it, e.g. "412.5", in cents; None if it is not one."""
+    try:
+        return int((Decimal(str(value)) * 100).to_integral_value())
+    except (InvalidOperation, TypeError, ValueError):
+        return None
+
+
+def _percent(value: Any) -> Optional[float]:
+    try:
+        return float(Decimal(str(value)) * 100)
+    except (InvalidOperation, TypeError, ValueError):
+        return None
+
+
+def summarize_benefits(entries: List[Dict[str, Any]], service_types: List[str]) -> List[schemas.BenefitSummary]:
+    """
+    One summary per service type, network and coverage level from the
+    benefit entries (the 271's EB segments, as Change Healthcare's JSON
+    gives them). Entries naming no service type apply to those asked about.
+    """
+    summaries: Dict[Tuple[str, str, Optional[str]], schemas.BenefitSummary] = {}
+    for entry in entries:
+        code = entry.get("code")
+        codes = entry.get("serviceTypeCodes") or service_types
+        names = entry.get("serviceTypes") or []
+        network = NETWORKS.get(entry.get("inPlanNetworkIndicatorCode"), "any")
+        level = entry.get("coverageLevelCode")
+        level = COVERAGE_LEVELS.get(level, "other") if level else None
+        for index, service_type in enumerate(codes):
+            key = (service_type, network, level)
+            summary = summaries.get(key)
+            if summary is None:
+                summary = summaries[key] = schemas.BenefitSummary(
+                    service_type=service_type,
+                    service_type_name=names[index] if index < len(names) else None,
+                    network=network,
+                    coverage_level=level,
+                )
+            if code in ACTIVE_CODES:
+                summary.active = True
+            elif code in INACTIVE_CODES:
+                summary.active = False
+            elif code == COPAY:
+                summary.copay_cents = to_cents(entry.get("benefitAmount"))
+            elif code == COINSURANCE:
+                summary.coinsurance_percent = _percent(entry.get("benefitPercent"))
+            elif code == DEDUCTIBLE:
+                if entry.get("timeQualifierCode") == REMAINING:
+                    summary.deductible_remaining_cents = to_cents(entry.get("benefitAmount"))
+                else:
+                    summary.deductible_cents = to_cents(entry.get("benefitAmount"))
+            elif code == OUT_OF_POCKET:
+                if entry.get("timeQualifierCode") == REMAINING:
+                    summary.out_of_pocket_remaining_cents = to_cents(entry.get("benefitAmount"))
+                else:
+                    summary.out_of_pocket_max_cents = to_cents(entry.get("benefitAmount"))
+            if entry.get("authOrCertIndicator") in ("Y", "N"):
+                summary.authorization_required = entry["authOrCertIndicator"] == "Y"
+            for info in entry.get("additionalInformation") or []:
+                note = info.get("description")
+                if note and note not in summary.notes:
+                    summary.notes.append(note)
+    return sorted(summaries.values(), key=lambda s: (s.service_type, s.network, s.coverage_level or ""))
+
+
+class ChangeHealthcareClearinghouse(Clearinghouse):
+    """
+    Change Healthcare's (Optum's) Eligibility API, which takes the 270 and
+    gives the 271 as JSON. Calls are authorized with OAuth client
+    credentials; the access token is reused until shortly before it expires.
+    """
+
+    name = "change-healthcare"
+    TOKEN_PATH = "/apip/auth/v2/token"
+    ELIGIBILITY_PATH = "/medicalnetwork/eligibility/v3"
+
+    # Tokens are renewed this long before they expire.
+    TOKEN_MARGIN_SECONDS = 60
+
+    def __init__(self, base_url: str, client_id: str, client_secret: str, timeout: float, client: Optional[httpx.Client] = None):
+        self.base_url = base_url.rstrip("/")
+        self.client_id = client_id
+        self.client_secret = client_secret
+        self.client = client or new_client("change-healthcare", timeout)
+        self._token: Optional[Tuple[str, float]] = None
+        self._lock = threading.Lock()
+
+    def _access_token(self) -> str:
+        with self._lock:
+            if self._token and self._token[1] > time.monotonic():
+                return self._token[0]
+            try:
+                response = self.client.post(f"{self.base_url}{self.TOKEN_PATH}", json={
+                    "client_id": self.client_id,
+                    "client_secret": self.client_secret,
+                    "grant_type": "client_credentials",
+                })
+            except httpx.HTTPError as e:
+                raise ClearinghouseError(f"Change Healthcare is unavailable: {e}") from e
+            if response.status_code >= 300:
+                raise ClearinghouseError(f"Change Healthcare refused the client credentials ({response.status_code})", retryable=response.status_code >= 500)
+            body = response.json()
+            expires_in = float(body.get("expires_in") or 3600)
+            self._token = (body["access_token"], time.monotonic() + max(0.0, expires_in - self.TOKEN_MARGIN_SECONDS))
+            return self._token[0]
+
+    @staticmethod
+    def _person(given_name: str, family_name: str, dob: date, sex: str) -> Dict[str, Any]:
+        person = {"firstName": given_name, "lastName": family_name, "dateOfBirth": dob.strftime("%Y%m%d")}
+        if sex in GENDER_CODES:
+            person["gender"] = GENDER_CODES[sex]
+        return person
+
+    def _request(self, inquiry: EligibilityInquiry) -> Dict[str, Any]:
+        coverage, patient = inquiry.coverage, inquiry.patient
+        if coverage.relationship == "self":
+            subscriber = self._person(patient.given_name, patient.family_name, patient.dob, patient.sex)
+            dependents = []
+        else:
+            holder = coverage.subscriber
+            subscriber = self._person(holder.given_name, holder.family_name, holder.dob, holder.sex)
+            dependent = self._person(patient.given_name, patient.family_name, patient.dob, patient.sex)
+            dependents = [{**dependent, "individualRelationshipCode": RELATIONSHIP_CODES[coverage.relationship]}]
+        subscriber["memberId"] = coverage.member_id
+        if coverage.group_number:
+            subscriber["groupNumber"] = coverage.group_number
+        body = {
+            "controlNumber": inquiry.control_number,
+            "tradingPartnerServiceId": coverage.payer_id,
+            "provider": {"organizationName": inquiry.provider.name, "npi": inquiry.provider.npi},
+            "subscriber": subscriber,
+            "encounter": {"dateOfService": inquiry.service_date.strftime("%Y%m%d"), "serviceTypeCodes": inquiry.service_types},
+        }
+        if dependents:
+            body["dependents"] = dependents
+        return body
+
+    def _post(self, path: str, body: Dict[str, Any]) -> httpx.Response:
+        for attempt in (1, 2):
+            try:
+                response = self.client.post(
+                    f"{self.base_url}{path}", json=body,
+                    headers={"Authorization": f"Bearer {self._access_token()}"},
+                )
+            except httpx.HTTPError as e:
+                raise ClearinghouseError(f"Change Healthcare is unavailable: {e}") from e
+            if response.status_code != 401 or attempt == 2:
+                return response
+            # The token was revoked or expired early; get another.
+            with self._lock:
+                self._token = None
+        return response
+
+    def check(self, inquiry: EligibilityInquiry) -> EligibilityAnswer:
+        response = self._post(self.ELIGIBILITY_PATH, self._request(inquiry))
+        if response.status_code >= 500 or response.status_code in (401, 429):
+            raise ClearinghouseError(f"Change Healthcare answered {response.status_code}")
+        try:
+            body = response.json()
+        except ValueError as e:
+            raise ClearinghouseError("Change Healthcare sent a malformed response") from e
+        # Requests the payer rejects (an AAA segment) and those the API finds
+        # invalid both come back with `errors`; there is no coverage to read.
+        errors = body.get("errors") or []
+        if response.status_code >= 300 and not errors:
+            raise ClearinghouseError(f"Change Healthcare refused the inquiry ({response.status_code}): {response.text}", retryable=False)
+        rejections = [
+            schemas.EligibilityRejection(code=error.get("code"), description=error.get("description") or "Rejected", follow_up=error.get("followupAction"))
+            for error in errors
+        ]
+        statuses = body.get("planStatus") or []
+        eligible = None
+        if statuses and not rejections:
+            eligible = any(s.get("statusCode") in ACTIVE_CODES for s in statuses)
+        plan_name = next((entry.get("planCoverage") for entry in body.get("benefitsInformation") or [] if entry.get("planCoverage")), None)
+        return EligibilityAnswer(
+            eligible=eligible,
+            plan_status=statuses[0].get("status") if statuses else None,
+            plan_name=plan_name,
+            benefits=summarize_benefits(body.get("benefitsInformation") or [], inquiry.service_types),
+            rejections=rejections,
+        )
+
+
+@lru_cache
+def get_clearinghouse() -> Optional[Clearinghouse]:
+    """Returns the configured clearinghouse, or None with ELIGIBILITY_PROVIDER=none."""
+    settings = get_settings()
+    if settings.eligibility_provider == "change-healthcare":
+        return ChangeHealthcareClearinghouse(
+            settings.change_healthcare_url, settings.change_healthcare_client_id, settings.change_healthcare_client_secret,
+            settings.eligibility_timeout_seconds,
+        )
+    return None
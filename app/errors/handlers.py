@@ -0,0 +1,119 @@
+# Location: app/errors/handlers.py
+
+import logging
+from typing import Dict, Optional, Tuple, Type
+
+from fastapi import FastAPI, Request
+from fastapi.exceptions import RequestValidationError
+from starlette.exceptions import HTTPException as StarletteHTTPException
+from starlette.responses import Response
+
+from app.errors.problems import problem_response, problem_type
+from app.fhir.responses import FHIR_BASE_PATH, operation_outcome
+from app.repositories.base import ConflictError, NotFoundError, StaleCursorError, VersionConflictError
+from app.resilience.breaker import DependencyUnavailable
+from app.services.state_machine import InvalidTransitionError
+
+# Domain errors that reach the framework without a handler turning them into
+# an HTTPException, as (status, problem type name, title). Subclasses are
+# reported as their nearest listed base class.
+DOMAIN_PROBLEMS: Dict[Type[Exception], Tuple[int, str, str]] = {
+    NotFoundError: (404, "not-found", "Resource Not Found"),
+    ConflictError: (409, "conflict", "Conflicting Resource"),
+    StaleCursorError: (400, "stale-cursor", "Stale Page Cursor"),
+    VersionConflictError: (409, "version-conflict", "Stale Version"),
+    InvalidTransitionError: (409, "invalid-transition", "Invalid Status Transition"),
+}
+
+# FHIR issue types for the error statuses /fhir can answer with.
+_FHIR_ISSUE_CODES = {400: "invalid", 401: "login", 403: "forbidden", 404: "not-found", 409: "conflict", 422: "invalid", 429: "throttled", 503: "transient"}
+
+
+def _is_fhir(request: Request) -> bool:
+    return request.url.path == FHIR_BASE_PATH or request.url.path.startswith(FHIR_BASE_PATH + "/")
+
+
+def _fhir_error(status_code: int, diagnostics: str, headers: Optional[Dict[str, str]] = None) -> Response:
+    """FHIR clients expect OperationOutcome resources rather than problem details."""
+    response = operation_outcome(status_code, _FHIR_ISSUE_CODES.get(status_code, "processing"), diagnostics)
+    response.headers.update(headers or {})
+    return response
+
+
+def _field_name(location: Tuple) -> str:
+    """`("body", "participants", 0, "practitionerId")` -> `participants[0].practitionerId`."""
+    name = ""
+    for part in location[1:] if location and location[0] == "body" else location:
+        name += f"[{part}]" if isinstance(part, int) else (f".{part}" if name else str(part))
+    return name
+
+
+def _field_error(error: Dict) -> Dict[str, str]:
+    """
+    One entry of a validation problem's `errors`. Rules that raise a plain
+    ValueError report it as "value_error"; their message is used without
+    pydantic's "Value error, " prefix.
+    """
+    cause = (error.get("ctx") or {}).get("error")
+    message = str(cause) if error.get("type") == "value_error" and cause else error.get("msg", "")
+    return {"field": _field_name(tuple(error.get("loc", ()))), "message": message, "code": error.get("type", "")}
+
+
+async def http_exception_handler(request: Request, exc: StarletteHTTPException) -> Response:
+    detail = exc.detail if isinstance(exc.detail, str) else None
+    if _is_fhir(request):
+        return _fhir_error(exc.status_code, detail or "", exc.headers)
+    return problem_response(exc.status_code, detail, headers=exc.headers, instance=request.url.path)
+
+
+async def validation_exception_handler(request: Request, exc: RequestValidationError) -> Response:
+    """
+    Logs the field errors of a 422 to help debug malformed client requests,
+    and lists each of them in the problem's `errors` with the code of the
+    failed rule (e.g. "missing", "phone_e164"; see app/validation/fields.py).
+    The rejected values themselves are not logged, as they may be PHI.
+    """
+    errors = [_field_error(error) for error in exc.errors()]
+    logging.error(f"422 Unprocessable Entity. Request: {request.method} {request.url}. Errors: {errors}")
+    detail = "One or more fields of the request are invalid"
+    if _is_fhir(request):
+        return _fhir_error(422, "; ".join(f"{e['field']}: {e['message']}" for e in errors) or detail)
+    return problem_response(
+        422,
+        detail,
+        type_=problem_type("validation-error"),
+        title="Invalid Request",
+        instance=request.url.path,
+        errors=errors,
+    )
+
+
+async def domain_error_handler(request: Request, exc: Exception) -> Response:
+    status_code, name, title = next(problem for cls, problem in DOMAIN_PROBLEMS.items() if isinstance(exc, cls))
+    logging.info(f"{request.method} {request.url.path} failed with {type(exc).__name__}: {exc}")
+    if _is_fhir(request):
+        return _fhir_error(status_code, str(exc))
+    return problem_response(status_code, str(exc), type_=problem_type(name), title=title, instance=request.url.path)
+
+
+async def dependency_unavailable_handler(request: Request, exc: DependencyUnavailable) -> Response:
+    """A dependency's circuit is open or its bulkhead full: the request can be retried shortly."""
+    logging.warning(f"{request.method} {request.url.path} failed fast: {exc}")
+    headers = {"Retry-After": str(exc.retry_after)}
+    detail = "A service this request depends on is unavailable; retry later"
+    if _is_fhir(request):
+        return _fhir_error(503, detail, headers)
+    return problem_response(503, detail, headers=headers, instance=request.url.path)
+
+
+def register_exception_handlers(app: FastAPI) -> None:
+    """
+    Reports every error raised while handling a request as
+    application/problem+json (RFC 9457), or as an OperationOutcome under
+    /fhir. Unhandled exceptions become a problem 500 in RecoveryMiddleware.
+    """
+    app.add_exception_handler(StarletteHTTPException, http_exception_handler)
+    app.add_exception_handler(RequestValidationError, validation_exception_handler)
+    app.add_exception_handler(DependencyUnavailable, dependency_unavailable_handler)
+    for error_class in DOMAIN_PROBLEMS:
+        app.add_exception_handler(error_class, domain_error_handler)
@@ -0,0 +1,97 @@
+# Location: app/errors/problems.py
+
+from http import HTTPStatus
+from typing import Any, Dict, List, Mapping, Optional
+
+from pydantic import BaseModel, ConfigDict, Field
+from starlette.responses import JSONResponse
+
+from app.core.request_context import get_request_id, get_trace_id
+
+PROBLEM_JSON = "application/problem+json"
+
+# Errors with meaning beyond their HTTP status get a `type` under this URN,
+# e.g. urn:megacare:problem:conflict. Plain HTTP errors use "about:blank"
+# with the status phrase as the title (RFC 9457, section 4.2.1).
+PROBLEM_TYPE_PREFIX = "urn:megacare:problem:"
+
+
+def problem_type(name: str) -> str:
+    return PROBLEM_TYPE_PREFIX + name
+
+
+def _status_phrase(status_code: int) -> str:
+    try:
+        return HTTPStatus(status_code).phrase
+    except ValueError:
+        return "Error"
+
+
+def problem_body(
+    status_code: int,
+    detail: Optional[str] = None,
+    title: Optional[str] = None,
+    type_: str = "about:blank",
+    instance: Optional[str] = None,
+    **extensions: Any,
+) -> Dict[str, Any]:
+    """
+    Builds an RFC 9457 problem details object. The request and trace IDs of
+    the current request are added so clients can quote them in support
+    requests and they lead straight to the logs.
+    """
+    body: Dict[str, Any] = {"type": type_, "title": title or _status_phrase(status_code), "status": status_code}
+    if detail is not None:
+        body["detail"] = detail
+    if instance:
+        body["instance"] = instance
+    body.update(extensions)
+    request_id = get_request_id()
+    if request_id:
+        body["requestId"] = request_id
+    trace_id = get_trace_id()
+    if trace_id:
+        body["traceId"] = trace_id
+    return body
+
+
+class ProblemResponse(JSONResponse):
+    """A JSON response served as application/problem+json."""
+    media_type = PROBLEM_JSON
+
+
+def problem_response(
+    status_code: int,
+    detail: Optional[str] = None,
+    headers: Optional[Mapping[str, str]] = None,
+    **fields: Any,
+) -> ProblemResponse:
+    """The error response for `status_code`; `fields` are passed to `problem_body`."""
+    return ProblemResponse(problem_body(status_code, detail, **fields), status_code=status_code, headers=headers)
+
+
+# --- Schemas ---
+# Describe the problem bodies in the OpenAPI document (see app/api/openapi.py);
+# responses are built as dicts by `problem_body`.
+
+class Problem(BaseModel):
+    """An error, as RFC 9457 problem details."""
+    type: str = Field("about:blank", description="`about:blank`, or a `urn:megacare:problem:` type clients can branch on.")
+    title: str
+    status: int
+    detail: Optional[str] = None
+    instance: Optional[str] = Field(None, description="The request path.")
+    request_id: Optional[str] = Field(None, alias="requestId", description="Quote it when reporting a problem.")
+    trace_id: Optional[str] = Field(None, alias="traceId")
+    model_config = ConfigDict(populate_by_name=True)
+
+
+class FieldError(BaseModel):
+    field: str = Field(..., description="Path of the field in the request, e.g. `contact.phoneNumber` or `participants[0].practitionerId`.")
+    message: str
+    code: str = Field(..., description="The failed rule, e.g. `missing` or `phone_e164`.")
+
+
+class ValidationProblem(Problem):
+    """A 422 listing every invalid field of the request."""
+    errors: List[FieldError]
@@ -0,0 +1,100 @@
+# Location: app/events/envelope.py
+
+import uuid
+from datetime import datetime, timezone
+from typing import Any, Dict, Literal, Optional
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.crypto.fields import encrypted_paths, without_encrypted
+from app.tenancy.context import current_tenant
+
+# Every event type the API publishes, as `<resource>.<what happened>`.
+# Subscribers filter on the `type` message attribute, e.g.
+#   gcloud pubsub subscriptions create billing --topic=domain-events \
+#     --message-filter='attributes.type = "appointment.booked"'
+EventType = Literal[
+    "patient.created",
+    "patient.updated",
+    "patient.deleted",
+    "patient.restored",
+    "patient.merged",
+    "practitioner.created",
+    "practitioner.updated",
+    "practitioner.deleted",
+    "practitioner.restored",
+    "care_team.created",
+    "care_team.updated",
+    "care_team.deleted",
+    "care_team.restored",
+    "appointment.booked",
+    "appointment.rescheduled",
+    "appointment.cancelled",
+    "appointment.status_changed",
+    "appointment.reminder_due",
+    "encounter.opened",
+    "encounter.updated",
+    "encounter.status_changed",
+    "observation.recorded",
+    "lab_result.recorded",
+    "care_plan.created",
+    "care_plan.updated",
+    "care_plan.status_changed",
+    "care_plan.deleted",
+    "care_plan.restored",
+    "medication.prescribed",
+    "medication.updated",
+    "refill_request.created",
+    "refill_request.status_changed",
+    "allergy.recorded",
+    "allergy.updated",
+    "allergy.deleted",
+    "allergy.restored",
+    "immunization.recorded",
+    "immunization.updated",
+    "document.uploaded",
+    "document.quarantined",
+    "document.deleted",
+    "wound_image.recorded",
+    "wound_image.deleted",
+    "consent.granted",
+    "consent.revoked",
+    "consent_document.published",
+    "telehealth_session.created",
+    "telehealth_session.started",
+    "telehealth_session.ended",
+    "coverage.recorded",
+    "coverage.updated",
+    "eligibility_check.completed",
+    "charge_item.recorded",
+    "charge_item.voided",
+    "claim.created",
+    "claim.status_changed",
+    "invoice.created",
+    "invoice.status_changed",
+    "invoice.payment_recorded",
+]
+
+
+class Event(BaseModel):
+    """
+    The envelope every domain event is published in. `subject` is the path
+    of the resource the event is about, relative to /api/v1 (for example
+    "patients/4f2a..."); `data` is that resource as the API returns it, or
+    just its ID for deletions. Encrypted fields (an SSN, psychotherapy notes)
+    are null in events; subscribers that need them read the resource. `tenantId` is the organization the change
+    was made for, when tenancy is enabled.
+    """
+    id: str = Field(default_factory=lambda: uuid.uuid4().hex)
+    type: EventType
+    occurred_at: datetime = Field(default_factory=lambda: datetime.now(timezone.utc), alias="occurredAt")
+    subject: str
+    data: Dict[str, Any]
+    tenant_id: Optional[str] = Field(default_factory=current_tenant, alias="tenantId")
+    model_config = ConfigDict(populate_by_name=True)
+
+
+def resource_event(event_type: EventType, subject: str, resource: BaseModel) -> Event:
+    """Builds the event for a change to `resource`, carrying it in its API (camelCase) form."""
+    data = resource.model_dump(mode="json", by_alias=True)
+    return Event(type=event_type, subject=subject, data=without_encrypted(data, encrypted_paths(type(resource))))
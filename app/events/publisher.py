@@ -0,0 +1,91 @@
+# Location: app/events/publisher.py
+
+import logging
+from abc import ABC, abstractmethod
+from typing import Any, Dict, Optional
+
+from pydantic import BaseModel
+
+from app.core.pubsub import publish_json
+from app.events.envelope import Event, EventType, resource_event
+from app.repositories.outbox import OutboxRepository
+from app.repositories.transactions import in_transaction
+
+
+def build_event(event_type: EventType, subject: str, resource: Optional[BaseModel], data: Dict[str, Any]) -> Event:
+    return resource_event(event_type, subject, resource) if resource is not None else Event(type=event_type, subject=subject, data=data)
+
+
+class EventPublisher(ABC):
+    """
+    Publishes domain events after the change they describe has been stored.
+    Publishers that store events with the change set `transactional`.
+    """
+    transactional = False
+
+    @abstractmethod
+    def publish(self, event: Event) -> None:
+        """Delivers the event. Raises if it could not be handed off."""
+
+    def emit(self, event_type: EventType, subject: str, resource: Optional[BaseModel] = None, **data) -> None:
+        """
+        Publishes an event for a change that has already been written, from
+        `resource` or, for deletions, from the keyword `data`. The change
+        cannot be undone at this point, so a failure is reported to Cloud
+        Error Reporting instead of failing the request. Transactional
+        publishers inside a `unit_of_work()` raise instead, rolling the
+        change back with the event.
+        """
+        if self.transactional and in_transaction():
+            self.publish(build_event(event_type, subject, resource, data))
+            return
+        try:
+            self.publish(build_event(event_type, subject, resource, data))
+        except Exception:
+            logging.exception(f"Failed to publish {event_type} event for {subject}", extra={"report_error": True})
+
+
+class PubSubEventPublisher(EventPublisher):
+    """
+    Publishes every event to one topic. The event type, subject and tenant
+    are also set as message attributes so subscriptions can filter without
+    decoding.
+    """
+
+    def __init__(self, topic: str):
+        self.topic = topic
+
+    def publish(self, event: Event) -> None:
+        tenant = {"tenantId": event.tenant_id} if event.tenant_id else {}
+        publish_json(
+            self.topic,
+            event.model_dump(mode="json", by_alias=True),
+            eventId=event.id,
+            type=event.type,
+            subject=event.subject,
+            **tenant,
+        )
+
+
+class OutboxEventPublisher(EventPublisher):
+    """
+    Adds events to the outbox instead of publishing them; `OutboxRelay`
+    delivers them to Pub/Sub. Inside a `transactional` endpoint the entry
+    is part of the same transaction as the change, so a failure to store it
+    is raised and rolls the change back rather than losing the event.
+    """
+
+    transactional = True
+
+    def __init__(self, outbox: OutboxRepository):
+        self.outbox = outbox
+
+    def publish(self, event: Event) -> None:
+        self.outbox.add(event)
+
+
+class NullEventPublisher(EventPublisher):
+    """Drops events. Used when DOMAIN_EVENTS_TOPIC is not set."""
+
+    def publish(self, event: Event) -> None:
+        logging.debug(f"Domain events are disabled; dropped {event.type} for {event.subject}")
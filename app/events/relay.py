@@ -0,0 +1,62 @@
+# Location: app/events/relay.py
+
+import logging
+from datetime import datetime, timezone
+from typing import Callable
+
+from app.core.metrics import OUTBOX_EVENTS_TOTAL
+from app.core.worker import PollingWorker, retry_delay
+from app.events.envelope import Event
+from app.events.publisher import EventPublisher
+from app.repositories.outbox import OutboxRepository
+
+
+class OutboxRelay(PollingWorker):
+    """
+    Drains the outbox to Pub/Sub. Delivery is at-least-once: an entry is
+    marked published only after Pub/Sub has accepted it, so a crash in
+    between publishes it again once its lease expires. The event ID is sent
+    as the `eventId` attribute for subscribers to deduplicate on.
+    """
+    name = "outbox-relay"
+
+    def __init__(
+        self,
+        outbox: OutboxRepository,
+        publisher: EventPublisher,
+        batch_size: int = 100,
+        max_attempts: int = 10,
+        lease_seconds: float = 60.0,
+        clock: Callable[[], datetime] = lambda: datetime.now(timezone.utc),
+    ):
+        super().__init__()
+        self.outbox = outbox
+        self.publisher = publisher
+        self.batch_size = batch_size
+        self.max_attempts = max_attempts
+        self.lease_seconds = lease_seconds
+        self.clock = clock
+
+    def drain(self) -> int:
+        """Relays one batch of due entries. Returns how many were published."""
+        published = 0
+        for entry in self.outbox.claim(self.batch_size, self.lease_seconds):
+            try:
+                self.publisher.publish(Event.model_validate(entry.event))
+            except Exception as e:
+                self._record_failure(entry.event_id, entry.attempts + 1, f"{type(e).__name__}: {e}")
+                continue
+            self.outbox.mark_published(entry.event_id)
+            OUTBOX_EVENTS_TOTAL.labels(result="published").inc()
+            published += 1
+        return published
+
+    def _record_failure(self, event_id: str, attempts: int, error: str) -> None:
+        if attempts >= self.max_attempts:
+            logging.error(f"Giving up on outbox event {event_id} after {attempts} attempts: {error}", extra={"report_error": True})
+            self.outbox.mark_failed(event_id, attempts, error)
+            OUTBOX_EVENTS_TOTAL.labels(result="failed").inc()
+            return
+        logging.warning(f"Outbox event {event_id} not published (attempt {attempts}): {error}")
+        self.outbox.reschedule(event_id, attempts, self.clock() + retry_delay(attempts), error)
+        OUTBOX_EVENTS_TOTAL.labels(result="retried").inc()
@@ -0,0 +1,182 @@
+# Location: app/events/stream.py
+
+"""
+Server-sent events for clients that update live, such as the web portal.
+With EVENT_STREAM_ENABLED, every domain event is also recorded, without
+its payload, in the `streamEvents` store, and each open stream polls the
+store for events its caller may see. Polling the store rather than
+listening in the worker means a stream receives the events of every
+instance. Event IDs are `<microseconds>-<event ID>`, so a client that
+reconnects with Last-Event-ID carries on after the last event it received.
+"""
+
+import asyncio
+import json
+import logging
+import time
+from datetime import datetime, timedelta, timezone
+from typing import AsyncIterator, Awaitable, Callable, Dict, FrozenSet, List, Optional, Tuple
+
+from starlette.concurrency import run_in_threadpool
+
+from app.api.v1 import schemas
+from app.authz.roles import PATIENT_ID_CLAIM, grants, has_permission, permission
+from app.events.envelope import Event
+from app.events.publisher import EventPublisher
+from app.repositories.stream_events import StreamEventRepository
+from app.tenancy.context import acting_for
+
+# Events are stamped before the transaction that stores them commits, so a
+# stream reads this far back each time to pick up those committed late.
+SETTLE_SECONDS = 5.0
+PAGE_SIZE = 100
+
+Cursor = Tuple[datetime, str]
+
+
+class StreamEventPublisher(EventPublisher):
+    """
+    Records the event for the event stream, then hands it on to `inner`,
+    failing like the webhook publisher does: with the change, inside a
+    transaction, but never because a direct Pub/Sub publish failed.
+    """
+
+    transactional = True
+
+    def __init__(self, inner: EventPublisher, events: StreamEventRepository):
+        self.inner = inner
+        self.events = events
+
+    def publish(self, event: Event) -> None:
+        self.events.append(event)
+        if self.inner.transactional:
+            self.inner.publish(event)
+            return
+        try:
+            self.inner.publish(event)
+        except Exception:
+            logging.exception(f"Failed to publish {event.type} event for {event.subject}", extra={"report_error": True})
+
+
+def event_cursor(event: schemas.StreamEvent) -> Cursor:
+    return event.occurred_at, event.event_id
+
+
+def format_event_id(event: schemas.StreamEvent) -> str:
+    return f"{int(event.occurred_at.timestamp() * 1_000_000)}-{event.event_id}"
+
+
+def parse_event_id(value: str) -> Cursor:
+    """The position a Last-Event-ID names. Raises ValueError for one this stream did not send."""
+    micros, _, event_id = value.strip().partition("-")
+    if not micros.isdigit() or not event_id:
+        raise ValueError(f"Invalid Last-Event-ID '{value}'")
+    return datetime.fromtimestamp(int(micros) / 1_000_000, tz=timezone.utc), event_id
+
+
+def own_patient(principal: Dict) -> Optional[str]:
+    """
+    The patient whose events alone the caller receives: that of their
+    `patientId` claim, unless their roles let them read all patients.
+    """
+    full, _own = grants(principal)
+    patient_id = principal.get(PATIENT_ID_CLAIM)
+    return patient_id if patient_id and not has_permission(full, permission("patients", "GET")) else None
+
+
+def visible_to(event: schemas.StreamEvent, principal: Dict) -> bool:
+    """
+    Whether the caller may read the resource the event is about: with the
+    read permission of its resource (see app/authz/roles.py), or, for
+    permissions limited to their own records, if it is their patient's.
+    """
+    needed = permission(event.resource, "GET")
+    full, own = grants(principal)
+    if has_permission(full, needed):
+        return True
+    return needed in own and event.patient_id is not None and event.patient_id == principal.get(PATIENT_ID_CLAIM)
+
+
+def sse_message(event: schemas.StreamEvent) -> str:
+    data = {
+        "id": event.event_id, "type": event.type, "subject": event.subject,
+        "patientId": event.patient_id, "occurredAt": event.occurred_at.isoformat(),
+    }
+    return f"id: {format_event_id(event)}\nevent: {event.type}\ndata: {json.dumps(data)}\n\n"
+
+
+class EventStream:
+    """
+    The events one caller receives, from `after` on (from now on without
+    one). `read` returns those stored since the last read; each is
+    returned once, however many reads see it in the settle window.
+    """
+
+    def __init__(
+        self,
+        events: StreamEventRepository,
+        principal: Dict,
+        tenant: Optional[str],
+        types: Optional[FrozenSet[str]] = None,
+        after: Optional[Cursor] = None,
+        clock: Callable[[], datetime] = lambda: datetime.now(timezone.utc),
+    ):
+        self.events = events
+        self.principal = principal
+        self.tenant = tenant
+        self.types = types
+        self.patient_id = own_patient(principal)
+        self.floor: Cursor = after or (clock(), "")
+        self.since = self.floor[0]
+        self.seen: Dict[str, datetime] = {}
+
+    def read(self) -> List[schemas.StreamEvent]:
+        fresh = []
+        since = self.since - timedelta(seconds=SETTLE_SECONDS)
+        with acting_for(self.tenant):
+            while True:
+                page = self.events.list_since(since, patient_id=self.patient_id, limit=PAGE_SIZE)
+                for event in page:
+                    if event.event_id in self.seen or event_cursor(event) <= self.floor:
+                        continue
+                    self.seen[event.event_id] = event.occurred_at
+                    self.since = max(self.since, event.occurred_at)
+                    if (self.types is None or event.type in self.types) and visible_to(event, self.principal):
+                        fresh.append(event)
+                if len(page) < PAGE_SIZE or page[-1].occurred_at == since:
+                    break
+                since = page[-1].occurred_at
+        settled = self.since - timedelta(seconds=SETTLE_SECONDS)
+        self.seen = {event_id: at for event_id, at in self.seen.items() if at >= settled}
+        return sorted(fresh, key=event_cursor)
+
+
+async def sse_messages(
+    stream: EventStream,
+    disconnected: Callable[[], Awaitable[bool]],
+    poll_seconds: float,
+    heartbeat_seconds: float,
+    max_seconds: float,
+    reset: bool = False,
+) -> AsyncIterator[str]:
+    """
+    The text of the event stream: the events, a comment after each quiet
+    `heartbeat_seconds`, and after `max_seconds` the end of the response,
+    on which clients reconnect with Last-Event-ID. `reset` first sends a
+    `reset` event, telling the client that events it missed are no longer
+    kept, so it should reload what it shows.
+    """
+    started = last_sent = time.monotonic()
+    yield f"retry: {int(poll_seconds * 1000) + 1000}\n\n"
+    if reset:
+        yield "event: reset\ndata: {}\n\n"
+    while time.monotonic() - started < max_seconds and not await disconnected():
+        events = await run_in_threadpool(stream.read)
+        for event in events:
+            yield sse_message(event)
+        if events:
+            last_sent = time.monotonic()
+        elif time.monotonic() - last_sent >= heartbeat_seconds:
+            yield ": keep-alive\n\n"
+            last_sent = time.monotonic()
+        await asyncio.sleep(poll_seconds)
@@ -0,0 +1,83 @@
+# Location: app/fhir/bulk_export.py
+
+import json
+import logging
+from typing import Callable, Dict, Iterable, Tuple
+
+from app.core.storage import get_storage_client
+from app.fhir.mappers import observation_to_fhir, patient_to_fhir
+from app.repositories.export_jobs import ExportJobRepository
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+
+# --- Bulk Data Export ---
+# Implements the work behind the FHIR Bulk Data `$export` operation:
+# https://hl7.org/fhir/uv/bulkdata/export.html
+NDJSON = "application/fhir+ndjson"
+# `_outputFormat` values the spec requires servers to accept as NDJSON.
+NDJSON_FORMATS = {NDJSON, "application/ndjson", "ndjson"}
+SUPPORTED_TYPES = ("Patient", "Observation")
+
+# A source yields internal records for a time window; the mapper turns each into a FHIR resource.
+Source = Tuple[Callable[..., Iterable], Callable[[object], Dict]]
+
+
+def output_blob_name(job_id: str, resource_type: str) -> str:
+    return f"bulk-export/{job_id}/{resource_type}.ndjson"
+
+
+def _is_cancelled(jobs: ExportJobRepository, job_id: str) -> bool:
+    job = jobs.get(job_id)
+    return job is None or job.status == "cancelled"
+
+
+def run_export(
+    job_id: str,
+    jobs: ExportJobRepository,
+    patients: PatientRepository,
+    observations: ObservationRepository,
+    bucket_name: str,
+) -> None:
+    """
+    Writes one NDJSON file per requested resource type to
+    `gs://{bucket_name}/bulk-export/{job_id}/` and records the output on the
+    job. Only resources last updated in [since, transactionTime) are exported,
+    so a follow-up export with `_since=transactionTime` neither skips nor
+    repeats records. Types that have no matching resources produce no file.
+    The job is re-read between types so a DELETE on the status URL stops it.
+    """
+    job = jobs.get(job_id)
+    if not job or job.status != "accepted":
+        return
+    jobs.update(job_id, {"status": "in-progress"})
+
+    sources: Dict[str, Source] = {
+        "Patient": (patients.stream, patient_to_fhir),
+        "Observation": (observations.stream, observation_to_fhir),
+    }
+    try:
+        bucket = get_storage_client().bucket(bucket_name)
+        output = []
+        for index, resource_type in enumerate(job.types, start=1):
+            if _is_cancelled(jobs, job_id):
+                logging.info(f"Bulk export {job_id} was cancelled; stopping before {resource_type}.")
+                return
+            jobs.update(job_id, {"progress": f"Exporting {resource_type} ({index}/{len(job.types)})"})
+
+            stream, to_fhir = sources[resource_type]
+            blob = bucket.blob(output_blob_name(job_id, resource_type))
+            count = 0
+            with blob.open("w", content_type=NDJSON) as f:
+                for record in stream(updated_since=job.since, updated_before=job.transaction_time):
+                    f.write(json.dumps(to_fhir(record), ensure_ascii=False, separators=(",", ":")) + "\n")
+                    count += 1
+            if count:
+                output.append({"type": resource_type, "blobName": blob.name, "count": count})
+            else:
+                blob.delete()
+
+        jobs.update(job_id, {"status": "completed", "progress": None, "output": output})
+        logging.info(f"Bulk export {job_id} completed with {sum(o['count'] for o in output)} resources.")
+    except Exception as e:
+        logging.exception(f"Bulk export {job_id} failed: {e}", extra={"report_error": True})
+        jobs.update(job_id, {"status": "error", "progress": None, "error": str(e)})
@@ -0,0 +1,400 @@
+# Location: app/fhir/bundles.py
+
+import logging
+from dataclasses import dataclass, field
+from http import HTTPStatus
+from typing import Any, Callable, Dict, List, Optional, Tuple
+from urllib.parse import parse_qsl, urlsplit
+
+from fastapi import HTTPException
+from starlette.concurrency import run_in_threadpool
+
+from app.authz.ownership import get_owner_lookup
+from app.authz.policy import NO_SUCH_RECORD
+from app.authz.smart import check_fhir_access
+from app.events.publisher import EventPublisher
+from app.fhir.mappers import (
+    MRN_SYSTEM, FHIRMappingError, observation_from_fhir, observation_to_fhir, parse_reference, parse_token,
+    patient_from_fhir, patient_to_fhir,
+)
+from app.fhir.responses import outcome_resource, search_bundle
+from app.repositories.base import ConflictError
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.transactions import unit_of_work
+
+# --- Batch and Transaction Bundles ---
+# Integration engines post a Bundle of type `batch` or `transaction` to the
+# FHIR base URL to make several requests in one round trip. Each entry names
+# its request (`POST Patient`, `GET Observation?patient=123`, ...) and is
+# answered by the entry at the same position of the response Bundle. Batch
+# entries succeed or fail on their own. A transaction's entries are all
+# stored or none is, and may reference a resource created earlier in the
+# same Bundle by its `urn:uuid:` fullUrl.
+SUPPORTED_TYPES = ("batch", "transaction")
+MAX_ENTRIES = 500
+SEARCH_MAX_COUNT = 100
+# Conditional requests are not supported; entries using them are refused
+# rather than run unconditionally.
+_CONDITIONAL_FIELDS = ("ifNoneExist", "ifMatch", "ifNoneMatch", "ifModifiedSince")
+
+
+class BundleError(ValueError):
+    """Raised when the posted resource is not a Bundle that can be processed."""
+
+    def __init__(self, status_code: int, code: str, diagnostics: str):
+        super().__init__(diagnostics)
+        self.status_code = status_code
+        self.code = code
+
+
+class EntryFailed(Exception):
+    """Raised when one entry cannot be processed; answered as an OperationOutcome."""
+
+    def __init__(self, status_code: int, code: str, diagnostics: str):
+        super().__init__(diagnostics)
+        self.status_code = status_code
+        self.code = code
+        self.diagnostics = diagnostics
+
+
+@dataclass
+class BundleEntry:
+    index: int
+    method: str
+    url: str
+    resource_type: str
+    resource_id: Optional[str] = None
+    params: Dict[str, str] = field(default_factory=dict)
+    resource: Optional[Dict[str, Any]] = None
+    full_url: Optional[str] = None
+
+    @property
+    def action(self) -> str:
+        """The SMART action the entry needs."""
+        if self.method == "POST":
+            return "c"
+        return "r" if self.resource_id else "s"
+
+
+def _status_line(status_code: int) -> str:
+    return f"{status_code} {HTTPStatus(status_code).phrase}"
+
+
+def _parse_entry(index: int, raw: Any, base_url: str) -> BundleEntry:
+    if not isinstance(raw, dict) or not isinstance(raw.get("request"), dict):
+        raise EntryFailed(400, "invalid", "Bundle entries must have a request")
+    request = raw["request"]
+    method, url = str(request.get("method") or "").upper(), str(request.get("url") or "")
+    if any(request.get(name) for name in _CONDITIONAL_FIELDS):
+        raise EntryFailed(400, "not-supported", "Conditional requests are not supported in Bundles")
+    if url.startswith(f"{base_url}/"):
+        url = url[len(base_url) + 1:]
+    parts = urlsplit(url)
+    if parts.scheme or parts.netloc:
+        raise EntryFailed(400, "not-supported", f"Entry URL must be relative to {base_url}")
+    segments = [s for s in parts.path.split("/") if s]
+    if not segments or segments[0] not in ("Patient", "Observation") or len(segments) > 2:
+        raise EntryFailed(400, "not-supported", f"Only Patient and Observation are supported, not '{parts.path}'")
+    entry = BundleEntry(
+        index=index,
+        method=method,
+        url=url,
+        resource_type=segments[0],
+        resource_id=segments[1] if len(segments) == 2 else None,
+        params=dict(parse_qsl(parts.query)),
+        resource=raw.get("resource"),
+        full_url=raw.get("fullUrl"),
+    )
+    if method == "POST" and entry.resource_id is None and not entry.params:
+        if not isinstance(entry.resource, dict):
+            raise EntryFailed(400, "invalid", "A POST entry must carry the resource to create")
+        return entry
+    if method == "GET":
+        return entry
+    raise EntryFailed(400, "not-supported", f"{method or 'An entry without a method'} {url} is not supported in Bundles")
+
+
+def parse_bundle(bundle: Any, base_url: str) -> Tuple[str, List[Any]]:
+    """
+    Checks the posted Bundle and parses its entries. Returns its type and,
+    for each entry, a BundleEntry or the EntryFailed it could not be parsed
+    with. Raises BundleError for anything but a batch or transaction.
+    """
+    if not isinstance(bundle, dict) or bundle.get("resourceType") != "Bundle":
+        raise BundleError(400, "invalid", "Expected a Bundle resource")
+    bundle_type = bundle.get("type")
+    if bundle_type not in SUPPORTED_TYPES:
+        raise BundleError(400, "not-supported", "Only batch and transaction Bundles can be posted")
+    raw_entries = bundle.get("entry") or []
+    if not isinstance(raw_entries, list):
+        raise BundleError(400, "invalid", "Bundle.entry must be a list")
+    if len(raw_entries) > MAX_ENTRIES:
+        raise BundleError(413, "too-costly", f"Bundles may have at most {MAX_ENTRIES} entries")
+
+    entries: List[Any] = []
+    for index, raw in enumerate(raw_entries):
+        try:
+            entries.append(_parse_entry(index, raw, base_url))
+        except EntryFailed as e:
+            entries.append(e)
+    return bundle_type, entries
+
+
+# --- Authorization ---
+
+def _reference_target(reference: Any) -> Optional[str]:
+    return parse_reference(reference, "Patient") if isinstance(reference, str) else None
+
+
+def _locator(entry: BundleEntry) -> Callable:
+    """Finds whose record an entry touches, as `fhir_target_patient` does for a single request."""
+    async def locate() -> Any:
+        if entry.resource_id and entry.resource_type == "Patient":
+            return entry.resource_id
+        if entry.resource_id:
+            owner = await run_in_threadpool(get_owner_lookup("observationId"), entry.resource_id)
+            return owner if owner is not None else NO_SUCH_RECORD
+        reference = entry.params.get("patient") or entry.params.get("subject")
+        if reference:
+            return parse_reference(reference, "Patient") or reference
+        subject = (entry.resource or {}).get("subject")
+        # A reference to a patient created in the same Bundle is nobody's own record yet.
+        return _reference_target(subject.get("reference")) if isinstance(subject, dict) else None
+    return locate
+
+
+async def authorize_entries(entries: List[Any], principal: Dict, bundle_type: str) -> List[Any]:
+    """Checks each entry's access as for the same request on its own, replacing refused ones with EntryFailed."""
+    checked = []
+    for entry in entries:
+        if isinstance(entry, BundleEntry):
+            try:
+                await check_fhir_access(
+                    principal, entry.resource_type, entry.action, _locator(entry),
+                    f"{entry.method} {entry.url} in a {bundle_type} Bundle",
+                )
+            except HTTPException as e:
+                entry = EntryFailed(e.status_code, "forbidden", str(e.detail))
+        checked.append(entry)
+    return checked
+
+
+# --- Processing ---
+
+class _Processor:
+    """Runs the entries of one Bundle against the repositories."""
+
+    def __init__(
+        self,
+        base_url: str,
+        patients: PatientRepository,
+        observations: ObservationRepository,
+        events: EventPublisher,
+        transaction: bool,
+    ):
+        self.base_url = base_url
+        self.transaction = transaction
+        self.patients = patients
+        self.observations = observations
+        self.events = events
+        # fullUrl of each resource a transaction has created so far -> its new
+        # relative reference. Batch entries are independent of each other.
+        self.created: Dict[str, str] = {}
+
+    def resolve(self, value: Any) -> Any:
+        """Replaces references to resources created earlier in the Bundle with their new `Type/id`."""
+        if isinstance(value, dict):
+            return {
+                k: self.created.get(v, v) if k == "reference" and isinstance(v, str) else self.resolve(v)
+                for k, v in value.items()
+            }
+        if isinstance(value, list):
+            return [self.resolve(v) for v in value]
+        return value
+
+    def _created(self, entry: BundleEntry, resource: Dict[str, Any], last_modified) -> Dict[str, Any]:
+        reference = f"{resource['resourceType']}/{resource['id']}"
+        if self.transaction and entry.full_url:
+            self.created[entry.full_url] = reference
+        return {
+            "fullUrl": f"{self.base_url}/{reference}",
+            "resource": resource,
+            "response": {"status": _status_line(201), "location": reference, "lastModified": last_modified.isoformat()},
+        }
+
+    def map_patient(self, entry: BundleEntry):
+        try:
+            return patient_from_fhir(self.resolve(entry.resource))
+        except FHIRMappingError as e:
+            raise EntryFailed(400, "invalid", str(e))
+
+    def map_observation(self, entry: BundleEntry, pending: Optional[Dict[str, str]] = None):
+        resource = self.resolve(entry.resource)
+        subject = resource.get("subject") if isinstance(resource, dict) else None
+        reference = subject.get("reference") if isinstance(subject, dict) else None
+        if pending and reference in pending:
+            # Checked before the patient it references has been created.
+            resource = {**resource, "subject": {**subject, "reference": pending[reference]}}
+        try:
+            observation_in = observation_from_fhir(resource)
+        except FHIRMappingError as e:
+            raise EntryFailed(400, "invalid", str(e))
+        if not (pending and reference in pending) and not self.patients.get(observation_in.patient_id):
+            raise EntryFailed(422, "processing", f"Patient/{observation_in.patient_id} not found")
+        return observation_in
+
+    def create_patient(self, entry: BundleEntry) -> Dict[str, Any]:
+        patient_in = self.map_patient(entry)
+        try:
+            patient = self.patients.create(patient_in)
+        except ConflictError as e:
+            raise EntryFailed(409, "duplicate", str(e))
+        self.events.emit("patient.created", f"patients/{patient.patient_id}", patient)
+        return self._created(entry, patient_to_fhir(patient), patient.updated_at)
+
+    def create_observation(self, entry: BundleEntry) -> Dict[str, Any]:
+        observation = self.observations.create(self.map_observation(entry))
+        self.events.emit("observation.recorded", f"patients/{observation.patient_id}/observations/{observation.observation_id}", observation)
+        return self._created(entry, observation_to_fhir(observation), observation.updated_at)
+
+    def read(self, entry: BundleEntry) -> Dict[str, Any]:
+        if entry.resource_type == "Patient":
+            record = self.patients.get(entry.resource_id)
+            resource = patient_to_fhir(record) if record else None
+        else:
+            record = self.observations.get(entry.resource_id)
+            resource = observation_to_fhir(record) if record else None
+        if resource is None:
+            raise EntryFailed(404, "not-found", f"{entry.resource_type}/{entry.resource_id} not found")
+        return {"fullUrl": f"{self.base_url}/{entry.resource_type}/{entry.resource_id}", "resource": resource, "response": {"status": _status_line(200)}}
+
+    def search(self, entry: BundleEntry) -> Dict[str, Any]:
+        params = entry.params
+        if entry.resource_type == "Patient":
+            identifier = params.get("identifier")
+            if not identifier:
+                raise EntryFailed(400, "not-supported", "Patient search requires the 'identifier' parameter")
+            system, value = parse_token(identifier)
+            patient = self.patients.get_by_mrn(value) if system in (None, MRN_SYSTEM) else None
+            resources = [patient_to_fhir(patient)] if patient else []
+        else:
+            try:
+                count = int(params.get("_count", 30))
+            except ValueError:
+                count = 0
+            if not 1 <= count <= SEARCH_MAX_COUNT:
+                raise EntryFailed(400, "invalid", f"_count must be between 1 and {SEARCH_MAX_COUNT}")
+            identifier, patient = params.get("identifier"), params.get("patient")
+            if identifier:
+                system, value = parse_token(identifier)
+                observations = self.observations.find_by_identifier(system, value)
+                if patient:
+                    patient_id = parse_reference(patient, "Patient") or patient
+                    observations = [o for o in observations if o.patient_id == patient_id]
+                observations = observations[:count]
+            elif patient:
+                observations = self.observations.list(parse_reference(patient, "Patient") or patient, limit=count)
+            else:
+                raise EntryFailed(400, "not-supported", "Observation search requires the 'identifier' or 'patient' parameter")
+            resources = [observation_to_fhir(o) for o in observations]
+        return {"resource": search_bundle(self.base_url, f"{self.base_url}/{entry.url}", resources), "response": {"status": _status_line(200)}}
+
+    def run(self, entry: BundleEntry) -> Dict[str, Any]:
+        if entry.method == "POST":
+            return self.create_patient(entry) if entry.resource_type == "Patient" else self.create_observation(entry)
+        return self.read(entry) if entry.resource_id else self.search(entry)
+
+    def check_transaction(self, entries: List[BundleEntry]) -> None:
+        """
+        Checks every write of a transaction before any is made: that its
+        resource maps, that new MRNs are free, and that referenced patients
+        exist or are created in the Bundle. With STORE=firestore the
+        writes are not atomic, so this keeps a failed transaction from
+        leaving part of it stored in all but a race.
+        """
+        pending = {
+            entry.full_url: "Patient/pending"
+            for entry in entries if entry.method == "POST" and entry.resource_type == "Patient" and entry.full_url
+        }
+        mrns = set()
+        for entry in entries:
+            try:
+                if entry.method != "POST":
+                    continue
+                if entry.resource_type == "Patient":
+                    patient_in = self.map_patient(entry)
+                    if patient_in.mrn in mrns or self.patients.get_by_mrn(patient_in.mrn):
+                        raise EntryFailed(409, "duplicate", f"A patient with MRN '{patient_in.mrn}' already exists")
+                    mrns.add(patient_in.mrn)
+                else:
+                    self.map_observation(entry, pending)
+            except EntryFailed as e:
+                raise _in_entry(entry.index, e)
+
+
+def _in_entry(index: int, failure: EntryFailed) -> EntryFailed:
+    return EntryFailed(failure.status_code, failure.code, f"Bundle.entry[{index}]: {failure.diagnostics}")
+
+
+def _error_entry(failure: EntryFailed) -> Dict[str, Any]:
+    return {"response": {"status": _status_line(failure.status_code), "outcome": outcome_resource(failure.code, failure.diagnostics)}}
+
+
+def run_batch(entries: List[Any], processor: _Processor) -> Dict[str, Any]:
+    """Runs each entry in its own unit of work; a failed entry does not affect the others."""
+    responses = []
+    for entry in entries:
+        if isinstance(entry, EntryFailed):
+            responses.append(_error_entry(entry))
+            continue
+        try:
+            with unit_of_work():
+                responses.append(processor.run(entry))
+        except EntryFailed as e:
+            responses.append(_error_entry(e))
+    return {"resourceType": "Bundle", "type": "batch-response", "entry": responses}
+
+
+def run_transaction(entries: List[Any], processor: _Processor) -> Dict[str, Any]:
+    """
+    Runs every entry in one unit of work: the writes before the reads, as
+    FHIR requires, and patients before the observations that may reference
+    them. Raises EntryFailed, naming the entry, if any entry fails; the
+    unit of work is then rolled back.
+    """
+    for index, entry in enumerate(entries):
+        if isinstance(entry, EntryFailed):
+            raise _in_entry(index, entry)
+    processor.check_transaction(entries)
+
+    responses: List[Optional[Dict[str, Any]]] = [None] * len(entries)
+    with unit_of_work():
+        for entry in sorted(entries, key=lambda e: (e.method != "POST", e.resource_type != "Patient")):
+            try:
+                responses[entry.index] = processor.run(entry)
+            except EntryFailed as e:
+                raise _in_entry(entry.index, e)
+    return {"resourceType": "Bundle", "type": "transaction-response", "entry": responses}
+
+
+async def process_bundle(
+    bundle: Any,
+    principal: Dict,
+    base_url: str,
+    patients: PatientRepository,
+    observations: ObservationRepository,
+    events: EventPublisher,
+) -> Dict[str, Any]:
+    """
+    Processes a posted batch or transaction Bundle and returns the response
+    Bundle. Raises BundleError if it is neither, and EntryFailed if a
+    transaction fails, in which case nothing of it has been stored.
+    """
+    bundle_type, entries = parse_bundle(bundle, base_url)
+    entries = await authorize_entries(entries, principal, bundle_type)
+    processor = _Processor(base_url, patients, observations, events, transaction=bundle_type == "transaction")
+    run = run_transaction if processor.transaction else run_batch
+    response = await run_in_threadpool(run, entries, processor)
+    logging.info(f"User {principal.get('uid')} processed a {bundle_type} Bundle of {len(entries)} entries via FHIR")
+    return response
@@ -0,0 +1,214 @@
+# Location: app/fhir/mappers.py
+
+from typing import Any, Dict, List, Optional, Tuple
+
+from pydantic import ValidationError
+
+from app.api.v1 import schemas
+
+# --- Identifier Systems ---
+# MegaCare-assigned identifiers are exposed under these system URIs so
+# integrators can tell them apart from identifiers issued by their own EHR.
+MRN_SYSTEM = "urn:megacare:mrn"
+NHS_NUMBER_SYSTEM = "https://fhir.nhs.uk/Id/nhs-number"
+UCUM_SYSTEM = "http://unitsofmeasure.org"
+
+
+class FHIRMappingError(ValueError):
+    """Raised when a FHIR resource cannot be converted into an internal model."""
+
+
+def _iso(value) -> Optional[str]:
+    return value.isoformat() if value else None
+
+
+def _meta(updated_at) -> Dict[str, Any]:
+    return {"lastUpdated": _iso(updated_at)}
+
+
+def _validation_message(e: ValidationError) -> str:
+    return "; ".join(f"{'.'.join(str(p) for p in err['loc'])}: {err['msg']}" for err in e.errors())
+
+
+def parse_token(token: str) -> Tuple[Optional[str], str]:
+    """
+    Splits a FHIR token search value into (system, code):
+    "sys|val" -> ("sys", "val"), "|val" and "val" -> (None, "val").
+    """
+    if "|" not in token:
+        return None, token
+    system, value = token.split("|", 1)
+    return system or None, value
+
+
+def parse_reference(reference: Optional[str], resource_type: str) -> Optional[str]:
+    """Returns the ID from a relative reference such as "Patient/123", or None if it is not of `resource_type`."""
+    if not reference:
+        return None
+    parts = reference.rstrip("/").split("/")
+    if len(parts) >= 2 and parts[-2] == resource_type and parts[-1]:
+        return parts[-1]
+    return None
+
+
+# --- Patient ---
+
+def patient_to_fhir(patient: schemas.Patient) -> Dict[str, Any]:
+    resource: Dict[str, Any] = {
+        "resourceType": "Patient",
+        "id": patient.patient_id,
+        "meta": _meta(patient.updated_at),
+        "identifier": [{"use": "usual", "system": MRN_SYSTEM, "value": patient.mrn}],
+        "name": [{"use": "official", "family": patient.family_name, "given": [patient.given_name]}],
+        "gender": patient.sex,
+        "birthDate": patient.dob.isoformat(),
+    }
+    if patient.nhs_number:
+        resource["identifier"].append({"use": "official", "system": NHS_NUMBER_SYSTEM, "value": patient.nhs_number})
+    contact = patient.contact
+    if contact:
+        telecom = []
+        if contact.phone_number:
+            telecom.append({"system": "phone", "value": contact.phone_number})
+        if contact.email:
+            telecom.append({"system": "email", "value": contact.email})
+        if telecom:
+            resource["telecom"] = telecom
+        address = {
+            "line": [contact.address_line] if contact.address_line else None,
+            "city": contact.city,
+            "postalCode": contact.postal_code,
+            "country": contact.country,
+        }
+        address = {k: v for k, v in address.items() if v}
+        if address:
+            resource["address"] = [address]
+    return resource
+
+
+def patient_from_fhir(resource: Dict[str, Any]) -> schemas.PatientCreate:
+    """
+    Converts a FHIR Patient into a PatientCreate. The first `official` (or
+    first) name is used, and an identifier with the MegaCare MRN system is
+    required because MRNs are unique per patient in our model.
+    """
+    if resource.get("resourceType") != "Patient":
+        raise FHIRMappingError("resourceType must be 'Patient'")
+
+    mrn = next((i.get("value") for i in resource.get("identifier", []) if i.get("system") == MRN_SYSTEM), None)
+    if not mrn:
+        raise FHIRMappingError(f"Patient.identifier with system '{MRN_SYSTEM}' is required")
+    nhs_number = next((i.get("value") for i in resource.get("identifier", []) if i.get("system") == NHS_NUMBER_SYSTEM), None)
+
+    names: List[Dict[str, Any]] = resource.get("name") or []
+    name = next((n for n in names if n.get("use") == "official"), names[0] if names else {})
+    given = name.get("given") or []
+
+    telecom = resource.get("telecom") or []
+    address = (resource.get("address") or [{}])[0]
+    contact = {
+        "phoneNumber": next((t.get("value") for t in telecom if t.get("system") == "phone"), None),
+        "email": next((t.get("value") for t in telecom if t.get("system") == "email"), None),
+        "addressLine": ", ".join(address.get("line") or []) or None,
+        "city": address.get("city"),
+        "postalCode": address.get("postalCode"),
+        "country": address.get("country"),
+    }
+
+    try:
+        return schemas.PatientCreate.model_validate({
+            "givenName": " ".join(given) or None,
+            "familyName": name.get("family"),
+            "dob": resource.get("birthDate"),
+            "sex": resource.get("gender", "unknown"),
+            "mrn": mrn,
+            "nhsNumber": nhs_number,
+            "contact": contact if any(contact.values()) else None,
+        })
+    except ValidationError as e:
+        raise FHIRMappingError(_validation_message(e))
+
+
+# --- Observation ---
+
+def observation_to_fhir(observation: schemas.Observation) -> Dict[str, Any]:
+    code = observation.code
+    resource: Dict[str, Any] = {
+        "resourceType": "Observation",
+        "id": observation.observation_id,
+        "meta": _meta(observation.updated_at),
+        "status": observation.status,
+        "code": {
+            "coding": [{k: v for k, v in code.model_dump().items() if v is not None}],
+            **({"text": code.display} if code.display else {}),
+        },
+        "subject": {"reference": f"Patient/{observation.patient_id}"},
+        "effectiveDateTime": _iso(observation.effective_at),
+    }
+    if observation.identifier:
+        resource["identifier"] = [observation.identifier.model_dump()]
+    if observation.value is not None:
+        resource["valueQuantity"] = _quantity(observation.value, observation.unit)
+    if observation.components:
+        resource["component"] = [
+            {
+                "code": {"coding": [{k: v for k, v in c.code.model_dump().items() if v is not None}]},
+                **({"valueQuantity": _quantity(c.value, c.unit)} if c.value is not None else {}),
+            }
+            for c in observation.components
+        ]
+    return resource
+
+
+def _quantity(value: float, unit: Optional[str]) -> Dict[str, Any]:
+    quantity: Dict[str, Any] = {"value": value}
+    if unit:
+        quantity.update({"unit": unit, "system": UCUM_SYSTEM, "code": unit})
+    return quantity
+
+
+def observation_from_fhir(resource: Dict[str, Any]) -> schemas.ObservationCreate:
+    """
+    Converts a FHIR Observation with a `valueQuantity` (or no value, e.g. a
+    blood pressure panel with components) into an ObservationCreate. The
+    subject must be a `Patient/{id}` reference.
+    """
+    if resource.get("resourceType") != "Observation":
+        raise FHIRMappingError("resourceType must be 'Observation'")
+
+    patient_id = parse_reference((resource.get("subject") or {}).get("reference"), "Patient")
+    if not patient_id:
+        raise FHIRMappingError("Observation.subject must reference a Patient")
+
+    codings = (resource.get("code") or {}).get("coding") or []
+    if not codings:
+        raise FHIRMappingError("Observation.code.coding is required")
+    if "valueQuantity" not in resource and any(k.startswith("value") for k in resource):
+        raise FHIRMappingError("Only valueQuantity observation values are supported")
+
+    quantity = resource.get("valueQuantity") or {}
+    identifiers = resource.get("identifier") or []
+    components = []
+    for component in resource.get("component") or []:
+        component_codings = (component.get("code") or {}).get("coding") or []
+        if not component_codings:
+            raise FHIRMappingError("Observation.component.code.coding is required")
+        component_quantity = component.get("valueQuantity") or {}
+        components.append({
+            "code": component_codings[0],
+            "value": component_quantity.get("value"),
+            "unit": component_quantity.get("code") or component_quantity.get("unit"),
+        })
+    try:
+        return schemas.ObservationCreate.model_validate({
+            "patientId": patient_id,
+            "code": codings[0],
+            "status": resource.get("status"),
+            "value": quantity.get("value"),
+            "unit": quantity.get("code") or quantity.get("unit"),
+            "components": components,
+            "effectiveAt": resource.get("effectiveDateTime"),
+            "identifier": identifiers[0] if identifiers else None,
+        })
+    except ValidationError as e:
+        raise FHIRMappingError(_validation_message(e))
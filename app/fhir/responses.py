@@ -0,0 +1,56 @@
+# Location: app/fhir/responses.py
+
+from typing import Any, Dict, List, Optional
+
+from fastapi.responses import JSONResponse
+
+FHIR_JSON = "application/fhir+json"
+
+
+class FHIRResponse(JSONResponse):
+    """A JSON response served with the FHIR media type."""
+    media_type = FHIR_JSON
+
+
+def outcome_resource(code: str, diagnostics: str, severity: str = "error") -> Dict[str, Any]:
+    """
+    An OperationOutcome with one issue. `code` is a value from the FHIR
+    issue-type value set, e.g. "not-found", "invalid", "conflict" or "processing".
+    """
+    return {
+        "resourceType": "OperationOutcome",
+        "issue": [{"severity": severity, "code": code, "diagnostics": diagnostics}],
+    }
+
+
+def operation_outcome(status_code: int, code: str, diagnostics: str, severity: str = "error") -> FHIRResponse:
+    """Builds an OperationOutcome error response (see `outcome_resource`)."""
+    return FHIRResponse(status_code=status_code, content=outcome_resource(code, diagnostics, severity))
+
+
+def search_bundle(base_url: str, self_url: str, resources: List[Dict[str, Any]], total: Optional[int] = None) -> Dict[str, Any]:
+    """Wraps search results in a `searchset` Bundle."""
+    return {
+        "resourceType": "Bundle",
+        "type": "searchset",
+        "total": len(resources) if total is None else total,
+        "link": [{"relation": "self", "url": self_url}],
+        "entry": [
+            {
+                "fullUrl": f"{base_url}/{resource['resourceType']}/{resource['id']}",
+                "resource": resource,
+                "search": {"mode": "match"},
+            }
+            for resource in resources
+        ],
+    }
+
+
+# --- Base URL ---
+# The FHIR API is mounted at this path in `app.main`; absolute resource URLs
+# (Bundle fullUrl, Location headers) are built from it.
+FHIR_BASE_PATH = "/fhir"
+
+
+def fhir_base_url(request) -> str:
+    return f"{str(request.base_url).rstrip('/')}{FHIR_BASE_PATH}"
@@ -0,0 +1,119 @@
+# Location: app/fhir/smart.py
+
+import re
+from dataclasses import dataclass
+from functools import lru_cache
+from typing import Any, Dict, FrozenSet, List, Optional
+
+from app.auth.jwks import fetch_openid_configuration
+from app.core.config import get_settings
+
+# --- SMART Scopes ---
+# SMART App Launch scopes name a context, a resource type (or "*") and the
+# permitted interactions: `patient/*.read`, `user/Observation.write`, or in
+# the SMART v2 syntax `patient/Observation.rs`. The actions are those of
+# SMART v2: create, read, update, delete and search; v1 `read` means read
+# and search, `write` create, update and delete, and `*` all of them.
+_SCOPE_RE = re.compile(r"^(patient|user|system)/(\*|[A-Z][A-Za-z]+)\.(read|write|\*|c?r?u?d?s?)$")
+_V1_ACTIONS = {"read": "rs", "write": "cud", "*": "cruds"}
+
+# Token claims carrying the launch context chosen at authorization time.
+LAUNCH_PATIENT_CLAIM = "patient"
+LAUNCH_ENCOUNTER_CLAIM = "encounter"
+FHIR_USER_CLAIM = "fhirUser"
+
+
+@dataclass(frozen=True)
+class SmartScope:
+    context: str
+    resource_type: str
+    actions: FrozenSet[str]
+
+    def allows(self, resource_type: str, action: str) -> bool:
+        return self.resource_type in ("*", resource_type) and action in self.actions
+
+
+def parse_scope(scope: str) -> Optional[SmartScope]:
+    """Parses one SMART resource scope; returns None for anything else (e.g. `openid`, `launch/patient`)."""
+    match = _SCOPE_RE.match(scope)
+    if not match or not match.group(3):
+        return None
+    context, resource_type, actions = match.groups()
+    return SmartScope(context, resource_type, frozenset(_V1_ACTIONS.get(actions, actions)))
+
+
+def granted_scopes(claims: Dict[str, Any]) -> List[SmartScope]:
+    """The SMART resource scopes of an access token, from `scope` (space-separated) or `scp` (a list)."""
+    value = claims.get("scope", claims.get("scp"))
+    scopes = value.split() if isinstance(value, str) else value if isinstance(value, list) else []
+    return [parsed for parsed in (parse_scope(str(scope)) for scope in scopes) if parsed]
+
+
+def launch_context(claims: Dict[str, Any]) -> Dict[str, Optional[str]]:
+    """The patient and encounter chosen at launch, and the signed-in user's FHIR resource."""
+    return {
+        "patient": claims.get(LAUNCH_PATIENT_CLAIM),
+        "encounter": claims.get(LAUNCH_ENCOUNTER_CLAIM),
+        "fhirUser": claims.get(FHIR_USER_CLAIM),
+    }
+
+
+# --- SMART Configuration ---
+# Advertised at /fhir/.well-known/smart-configuration. Tokens are issued by
+# the configured OpenID Connect issuer; this server only verifies them.
+SCOPES_SUPPORTED = [
+    "openid", "fhirUser", "launch", "launch/patient", "launch/encounter", "offline_access",
+    "patient/*.read", "patient/*.rs", "patient/Patient.read", "patient/Observation.read", "patient/Observation.write",
+    "user/*.read", "user/*.write", "user/Patient.read", "user/Patient.write", "user/Observation.read", "user/Observation.write",
+    "system/*.read",
+]
+CAPABILITIES = [
+    "launch-ehr", "launch-standalone", "client-public", "client-confidential-symmetric",
+    "client-confidential-asymmetric", "context-ehr-patient", "context-ehr-encounter",
+    "context-standalone-patient", "sso-openid-connect", "permission-patient", "permission-user",
+    "permission-offline", "permission-v1", "permission-v2",
+]
+
+
+def smart_configuration(
+    authorization_endpoint: str,
+    token_endpoint: str,
+    issuer: Optional[str] = None,
+    jwks_uri: Optional[str] = None,
+) -> Dict[str, Any]:
+    document = {
+        "authorization_endpoint": authorization_endpoint,
+        "token_endpoint": token_endpoint,
+        "grant_types_supported": ["authorization_code", "client_credentials"],
+        "response_types_supported": ["code"],
+        "code_challenge_methods_supported": ["S256"],
+        "token_endpoint_auth_methods_supported": ["client_secret_basic", "private_key_jwt"],
+        "scopes_supported": SCOPES_SUPPORTED,
+        "capabilities": CAPABILITIES,
+    }
+    if issuer:
+        document["issuer"] = issuer
+    if jwks_uri:
+        document["jwks_uri"] = jwks_uri
+    return document
+
+
+@lru_cache
+def get_smart_configuration() -> Optional[Dict[str, Any]]:
+    """
+    The SMART configuration of this server, from SMART_AUTHORIZATION_ENDPOINT
+    and SMART_TOKEN_ENDPOINT or else the OIDC issuer's discovery document.
+    None when neither is configured. Discovery failures raise and are not
+    cached, so the next request tries again.
+    """
+    settings = get_settings()
+    authorization_endpoint, token_endpoint = settings.smart_authorization_endpoint, settings.smart_token_endpoint
+    jwks_uri = settings.oidc_jwks_url
+    if settings.oidc_issuer and not (authorization_endpoint and token_endpoint):
+        discovered = fetch_openid_configuration(settings.oidc_issuer)
+        authorization_endpoint = authorization_endpoint or discovered.get("authorization_endpoint")
+        token_endpoint = token_endpoint or discovered.get("token_endpoint")
+        jwks_uri = jwks_uri or discovered.get("jwks_uri")
+    if not (authorization_endpoint and token_endpoint):
+        return None
+    return smart_configuration(authorization_endpoint, token_endpoint, issuer=settings.oidc_issuer, jwks_uri=jwks_uri)
@@ -0,0 +1,149 @@
+# Location: app/flags/flags.py
+
+"""
+Feature flags, for dark-launching endpoints and code paths. A flag is set
+in FEATURE_FLAGS or stored through /admin/flags; a stored flag overrides
+the configured one with the same key, and unknown flags are off:
+
+    if get_feature_flags().is_enabled("telehealth-visits"):
+        ...
+
+Routes that should not exist until their flag is on depend on
+`require_flag` (app/dependencies/flags.py) instead.
+
+An enabled flag is on for the organizations it lists and for `percentage`
+percent of everyone else. Callers are bucketed by organization, so a clinic
+sees one behaviour for all its users, or by user where there is no
+organization. Buckets are derived from the flag key, so raising the
+percentage only ever adds callers, and each flag rolls out to a different
+share of them.
+"""
+
+import hashlib
+import logging
+import threading
+import time
+from functools import lru_cache
+from typing import Callable, Dict, List, Optional, Tuple
+
+from app.api.v1 import schemas
+from app.auth.context import get_principal
+from app.core.config import get_settings
+from app.repositories.feature_flags import FeatureFlagRepository
+from app.tenancy.context import claimed_organization, current_tenant
+
+
+def parse_flags(spec: str) -> Dict[str, schemas.FeatureFlag]:
+    """
+    Parses FEATURE_FLAGS, comma-separated `<flag>=<on|off|percentage>[:<organization>+...]`
+    entries such as "telehealth-visits=off:clinic-a+clinic-b,new-reports=25".
+    `off` with organizations enables the flag for those organizations only.
+    Raises ValueError for a malformed entry.
+    """
+    flags = {}
+    for entry in (part.strip() for part in spec.split(",")):
+        if not entry:
+            continue
+        key, _, value = entry.partition("=")
+        state, _, organizations = value.partition(":")
+        percentage = {"on": 100, "off": 0}.get(state, int(state) if state.isdigit() else -1)
+        listed = [organization for organization in organizations.split("+") if organization]
+        try:
+            flag = schemas.FeatureFlagCreate(key=key.strip(), enabled=percentage > 0 or bool(listed), percentage=percentage, organizations=listed)
+        except ValueError:
+            raise ValueError(f"invalid feature flag '{entry}', expected <flag>=<on|off|percentage>[:<organization>+...]")
+        flags[flag.key] = schemas.FeatureFlag(**flag.model_dump(), source="config")
+    return flags
+
+
+def bucket(key: str, subject: str) -> int:
+    """The rollout bucket, 0-99, of `subject` for the flag `key`."""
+    digest = hashlib.sha256(f"{key}:{subject}".encode("utf-8")).hexdigest()
+    return int(digest[:8], 16) % 100
+
+
+def evaluate(flag: schemas.FeatureFlag, organization_id: Optional[str], user_id: Optional[str]) -> bool:
+    """Whether `flag` is on for a caller; callers with neither an organization nor a user only get fully rolled-out flags."""
+    if not flag.enabled:
+        return False
+    if organization_id and organization_id in flag.organizations:
+        return True
+    if flag.percentage >= 100:
+        return True
+    subject = organization_id or user_id
+    return subject is not None and bucket(flag.key, subject) < flag.percentage
+
+
+def _default_repository() -> FeatureFlagRepository:
+    from app.api.v1.deps import get_feature_flag_repository
+    return get_feature_flag_repository()
+
+
+class FeatureFlags:
+    """
+    Evaluates flags against the configured ones and those in the store.
+    Stored flags are read all at once and reused for `cache_seconds`, so a
+    change made through the admin API applies at once in the worker that
+    made it and within that time in the others. If the store cannot be
+    read, the flags last read (or only the configured ones) keep applying.
+    """
+
+    def __init__(
+        self,
+        configured: Optional[Dict[str, schemas.FeatureFlag]] = None,
+        repository_factory: Callable[[], FeatureFlagRepository] = _default_repository,
+        cache_seconds: float = 30.0,
+        clock: Callable[[], float] = time.monotonic,
+    ):
+        self.configured = configured or {}
+        self.repository_factory = repository_factory
+        self.cache_seconds = cache_seconds
+        self.clock = clock
+        self._cache: Optional[Tuple[float, Dict[str, schemas.FeatureFlag]]] = None
+        self._lock = threading.Lock()
+
+    def _stored(self) -> Dict[str, schemas.FeatureFlag]:
+        now = self.clock()
+        with self._lock:
+            cached = self._cache
+        if cached and now - cached[0] < self.cache_seconds:
+            return cached[1]
+        try:
+            stored = {flag.key: flag for flag in self.repository_factory().list()}
+        except Exception as e:
+            logging.warning(f"Could not read feature flags, keeping the last known ones: {e}")
+            stored = cached[1] if cached else {}
+        with self._lock:
+            self._cache = (now, stored)
+        return stored
+
+    def invalidate(self) -> None:
+        """Forgets the stored flags read so far, after a change through the admin API."""
+        with self._lock:
+            self._cache = None
+
+    def all(self) -> List[schemas.FeatureFlag]:
+        """Every flag in effect, by key: the stored ones and the configured ones not overridden."""
+        flags = {**self.configured, **self._stored()}
+        return [flags[key] for key in sorted(flags)]
+
+    def get(self, key: str) -> Optional[schemas.FeatureFlag]:
+        return self._stored().get(key) or self.configured.get(key)
+
+    def is_enabled(self, key: str, organization_id: Optional[str] = None, user_id: Optional[str] = None) -> bool:
+        """
+        Whether the flag `key` is on, by default for the current request's
+        organization and user.
+        """
+        flag = self.get(key)
+        if flag is None:
+            return False
+        principal = get_principal() or {}
+        organization_id = organization_id or current_tenant() or claimed_organization(principal)
+        return evaluate(flag, organization_id, user_id or principal.get("uid"))
+
+
+@lru_cache
+def get_feature_flags() -> FeatureFlags:
+    settings = get_settings()
+    return FeatureFlags(parse_flags(settings.feature_flags), cache_seconds=settings.feature_flag_cache_seconds)
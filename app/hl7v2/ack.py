@@ -0,0 +1,59 @@
+# Location: app/hl7v2/ack.py
+
+import uuid
+from datetime import datetime, timezone
+from typing import Literal, Optional
+
+from app.hl7v2.parser import Encoding, Message, escape, format_timestamp
+
+# MSA-1 acknowledgment codes (original acknowledgment mode):
+# AA = accepted, AE = application error (sender may fix and resend),
+# AR = rejected (the message itself is unacceptable, do not resend as-is).
+AckCode = Literal["AA", "AE", "AR"]
+
+# HL7 table 0357 error condition codes used in ERR-3.
+ERR_UNSUPPORTED_MESSAGE_TYPE = ("200", "Unsupported message type")
+ERR_UNSUPPORTED_EVENT = ("201", "Unsupported event code")
+ERR_REQUIRED_FIELD_MISSING = ("101", "Required field missing")
+ERR_DATA_TYPE = ("102", "Data type error")
+ERR_UNKNOWN_KEY = ("204", "Unknown key identifier")
+ERR_APPLICATION = ("207", "Application internal error")
+
+RECEIVING_APPLICATION = "MEGACARE"
+
+
+def build_ack(
+    message: Optional[Message],
+    code: AckCode,
+    text: str = "",
+    error: Optional[tuple] = None,
+) -> str:
+    """
+    Builds an ER7 ACK for `message`, swapping sender and receiver from its MSH.
+    `message` may be None when the input could not be parsed; the ACK then
+    carries no original control ID.
+    """
+    enc = message.encoding if message else Encoding()
+    f, c = enc.field, enc.component
+    msh = message.msh if message else None
+    trigger = message.message_type[1] if message else ""
+    now = format_timestamp(datetime.now(timezone.utc))
+
+    segments = [
+        f.join([
+            "MSH", enc.characters,
+            RECEIVING_APPLICATION, RECEIVING_APPLICATION,
+            msh.field(3) if msh else "", msh.field(4) if msh else "",
+            now, "",
+            c.join(["ACK", trigger, "ACK"]) if trigger else "ACK",
+            uuid.uuid4().hex[:20],
+            msh.field(11) if msh and msh.field(11) else "P",
+            message.version if message else "2.5",
+        ]),
+        f.join(["MSA", code, message.control_id if message else "", escape(text, enc)]),
+    ]
+    if error:
+        error_code, error_text = error
+        severity = "E" if code != "AA" else "W"
+        segments.append(f.join(["ERR", "", "", c.join([error_code, error_text, "HL70357"]), severity, "", "", "", escape(text, enc)]))
+    return "\r".join(segments) + "\r"
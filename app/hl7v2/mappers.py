@@ -0,0 +1,105 @@
+# Location: app/hl7v2/mappers.py
+
+from datetime import tzinfo
+from typing import Any, Dict, Optional
+
+from app.hl7v2.parser import Segment, parse_timestamp
+
+# HL7 table 0001 (administrative sex) -> our `sex` values.
+SEX_CODES = {"M": "male", "F": "female", "O": "other", "A": "other", "U": "unknown", "N": "unknown"}
+
+# HL7 table 0396 coding system names -> URIs used in our Coding model.
+CODING_SYSTEMS = {
+    "LN": "http://loinc.org",
+    "SCT": "http://snomed.info/sct",
+    "SNM": "http://snomed.info/sct",
+    "UCUM": "http://unitsofmeasure.org",
+}
+
+# OBX-11 result status -> Observation status.
+RESULT_STATUSES = {"F": "final", "P": "preliminary", "C": "amended", "R": "registered", "I": "registered"}
+
+
+def coding_system_uri(name: str) -> str:
+    return CODING_SYSTEMS.get(name, f"urn:hl7v2:codesystem:{name}" if name else "urn:hl7v2:codesystem:local")
+
+
+def patient_mrn(pid: Segment) -> Optional[str]:
+    """
+    Returns the medical record number from PID-3: the repetition whose
+    identifier type (CX.5) is "MR", otherwise the first identifier.
+    """
+    for index, _ in enumerate(pid.repetitions(3)):
+        if pid.component(3, 5, index) == "MR" and pid.component(3, 1, index):
+            return pid.component(3, 1, index)
+    return pid.component(3, 1) or None
+
+
+def pid_to_patient_fields(pid: Segment, default_tz: tzinfo) -> Dict[str, Any]:
+    """
+    Maps PID onto PatientCreate/PatientUpdate fields (by alias). Only values
+    present in the segment are returned, so the result can be used for
+    partial updates (ADT^A08) as well as creates.
+    """
+    fields: Dict[str, Any] = {}
+    mrn = patient_mrn(pid)
+    if mrn:
+        fields["mrn"] = mrn
+
+    family, given = pid.component(5, 1), pid.component(5, 2)
+    if family:
+        fields["familyName"] = family
+    if given:
+        middle = pid.component(5, 3)
+        fields["givenName"] = f"{given} {middle}".strip()
+
+    dob = parse_timestamp(pid.field(7), default_tz)
+    if dob:
+        fields["dob"] = dob.date()
+
+    sex = pid.component(8, 1).upper()
+    if sex:
+        fields["sex"] = SEX_CODES.get(sex, "unknown")
+
+    # XTN: the number is XTN.1 in older versions, XTN.12 (unformatted) in 2.5+; XTN.4 is email.
+    phone = pid.component(13, 1) or pid.component(13, 12)
+    email = pid.component(13, 4)
+    address = pid.components(11)
+    contact = {
+        "phoneNumber": phone or None,
+        "email": email or None,
+        "addressLine": ", ".join(v for v in address[:2] if v) or None,
+        "city": address[2] if len(address) > 2 and address[2] else None,
+        "postalCode": address[4] if len(address) > 4 and address[4] else None,
+        "country": address[5] if len(address) > 5 and address[5] else None,
+    }
+    if any(contact.values()):
+        fields["contact"] = contact
+    return fields
+
+
+def obx_to_observation_fields(obx: Segment, fallback_time: Optional[str], default_tz: tzinfo) -> Optional[Dict[str, Any]]:
+    """
+    Maps a numeric (NM) OBX onto ObservationCreate fields (by alias), except
+    patientId and identifier, which the caller sets. Returns None for value
+    types we do not store.
+    """
+    if obx.field(2) not in ("NM", "SN"):
+        return None
+    raw_value = obx.component(5, 1) if obx.field(2) == "NM" else obx.component(5, 2)
+    try:
+        value = float(raw_value)
+    except ValueError:
+        value = None
+
+    return {
+        "code": {
+            "system": coding_system_uri(obx.component(3, 3)),
+            "code": obx.component(3, 1),
+            "display": obx.component(3, 2) or None,
+        },
+        "value": value,
+        "unit": obx.component(6, 1) or None,
+        "status": RESULT_STATUSES.get(obx.field(11), "final"),
+        "effectiveAt": parse_timestamp(obx.field(14), default_tz) or parse_timestamp(fallback_time or "", default_tz),
+    }
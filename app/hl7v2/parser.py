@@ -0,0 +1,168 @@
+# Location: app/hl7v2/parser.py
+
+import re
+from dataclasses import dataclass
+from datetime import datetime, timedelta, timezone, tzinfo
+from typing import List, Optional, Tuple
+
+# Segments are separated by <CR>; <LF> and <CR><LF> are tolerated because
+# many interface engines normalise line endings when sending over HTTP.
+_SEGMENT_SEPARATOR_RE = re.compile(r"\r\n|\r|\n")
+_TIMESTAMP_RE = re.compile(r"^(\d{4})(\d{2})?(\d{2})?(\d{2})?(\d{2})?(\d{2})?(?:\.\d+)?([+-]\d{4})?$")
+
+
+class HL7ParseError(ValueError):
+    """Raised when a message is not valid ER7 (pipe-delimited) HL7v2."""
+
+
+@dataclass(frozen=True)
+class Encoding:
+    """Delimiters declared in MSH-1 and MSH-2."""
+    field: str = "|"
+    component: str = "^"
+    repetition: str = "~"
+    escape: str = "\\"
+    subcomponent: str = "&"
+
+    @property
+    def characters(self) -> str:
+        return f"{self.component}{self.repetition}{self.escape}{self.subcomponent}"
+
+
+def unescape(value: str, enc: Encoding) -> str:
+    """Decodes the standard delimiter escape sequences (\\F\\, \\S\\, \\T\\, \\R\\, \\E\\)."""
+    if enc.escape not in value:
+        return value
+    replacements = {"F": enc.field, "S": enc.component, "T": enc.subcomponent, "R": enc.repetition, "E": enc.escape}
+    pattern = re.compile(re.escape(enc.escape) + r"([FSTRE])" + re.escape(enc.escape))
+    return pattern.sub(lambda m: replacements[m.group(1)], value)
+
+
+def escape(value: str, enc: Encoding) -> str:
+    """Encodes delimiter characters in free text so it can be placed in a field."""
+    value = value.replace(enc.escape, f"{enc.escape}E{enc.escape}")
+    for char, code in ((enc.field, "F"), (enc.component, "S"), (enc.subcomponent, "T"), (enc.repetition, "R")):
+        value = value.replace(char, f"{enc.escape}{code}{enc.escape}")
+    return value
+
+
+class Segment:
+    """
+    One segment with HL7's 1-based field numbering, so `pid.field(3)` is PID-3.
+    For MSH, field 1 is the field separator itself and field 2 the encoding
+    characters, as in the standard.
+    """
+
+    def __init__(self, name: str, fields: List[str], encoding: Encoding):
+        self.name = name
+        self.fields = fields
+        self.encoding = encoding
+
+    def field(self, n: int) -> str:
+        """Returns the raw (still delimited and escaped) value of field `n`."""
+        return self.fields[n] if n < len(self.fields) else ""
+
+    def repetitions(self, n: int) -> List[str]:
+        raw = self.field(n)
+        return raw.split(self.encoding.repetition) if raw else []
+
+    def component(self, n: int, c: int = 1, repetition: int = 0) -> str:
+        """Returns component `c` of field `n` (HL7 `n.c` notation), unescaped. Missing values are ''."""
+        reps = self.repetitions(n)
+        if repetition >= len(reps):
+            return ""
+        components = reps[repetition].split(self.encoding.component)
+        value = components[c - 1] if c - 1 < len(components) else ""
+        return unescape(value, self.encoding)
+
+    def components(self, n: int, repetition: int = 0) -> List[str]:
+        reps = self.repetitions(n)
+        if repetition >= len(reps):
+            return []
+        return [unescape(v, self.encoding) for v in reps[repetition].split(self.encoding.component)]
+
+    def __repr__(self) -> str:
+        return f"Segment({self.name})"
+
+
+class Message:
+    """A parsed HL7v2 message. The first segment is always MSH."""
+
+    def __init__(self, segments: List[Segment], encoding: Encoding):
+        self.segments = segments
+        self.encoding = encoding
+
+    @property
+    def msh(self) -> Segment:
+        return self.segments[0]
+
+    def segment(self, name: str) -> Optional[Segment]:
+        return next((s for s in self.segments if s.name == name), None)
+
+    def all(self, name: str) -> List[Segment]:
+        return [s for s in self.segments if s.name == name]
+
+    @property
+    def message_type(self) -> Tuple[str, str]:
+        """(message code, trigger event) from MSH-9, e.g. ("ADT", "A01")."""
+        return self.msh.component(9, 1), self.msh.component(9, 2)
+
+    @property
+    def control_id(self) -> str:
+        return self.msh.field(10)
+
+    @property
+    def version(self) -> str:
+        return self.msh.component(12, 1) or "2.5"
+
+
+def parse_message(text: str) -> Message:
+    """Parses an ER7-encoded message. Raises HL7ParseError if it does not start with a valid MSH segment."""
+    lines = [line for line in _SEGMENT_SEPARATOR_RE.split(text.strip()) if line.strip()]
+    if not lines or not lines[0].startswith("MSH") or len(lines[0]) < 8:
+        raise HL7ParseError("Message must start with an MSH segment")
+
+    header = lines[0]
+    field_separator = header[3]
+    encoding_chars = header[4:].split(field_separator, 1)[0]
+    if len(encoding_chars) < 4:
+        raise HL7ParseError("MSH-2 must declare the component, repetition, escape and subcomponent characters")
+    enc = Encoding(field_separator, *encoding_chars[:4])
+
+    segments = []
+    for line in lines:
+        parts = line.split(enc.field)
+        name = parts[0]
+        if not re.fullmatch(r"[A-Z][A-Z0-9]{2}", name):
+            raise HL7ParseError(f"Invalid segment name '{name}'")
+        if name == "MSH":
+            parts = [name, enc.field] + parts[1:]
+        segments.append(Segment(name, parts, enc))
+
+    message = Message(segments, enc)
+    if not message.control_id:
+        raise HL7ParseError("MSH-10 (message control ID) is required")
+    return message
+
+
+def parse_timestamp(value: str, default_tz: tzinfo = timezone.utc) -> Optional[datetime]:
+    """
+    Parses an HL7 TS/DTM value (YYYY[MM[DD[HH[MM[SS[.S]]]]]][+/-ZZZZ]).
+    Values without an offset are interpreted in `default_tz`.
+    """
+    match = _TIMESTAMP_RE.match(value.strip()) if value else None
+    if not match:
+        return None
+    year, month, day, hour, minute, second, offset = match.groups()
+    tz = default_tz
+    if offset:
+        sign = 1 if offset[0] == "+" else -1
+        tz = timezone(sign * timedelta(hours=int(offset[1:3]), minutes=int(offset[3:5])))
+    return datetime(
+        int(year), int(month or 1), int(day or 1),
+        int(hour or 0), int(minute or 0), int(second or 0), tzinfo=tz,
+    )
+
+
+def format_timestamp(value: datetime) -> str:
+    return value.strftime("%Y%m%d%H%M%S%z")
@@ -0,0 +1,145 @@
+# Location: app/hl7v2/processing.py
+
+from datetime import tzinfo
+from typing import Optional
+
+from pydantic import ValidationError
+
+from app.api.v1 import schemas
+from app.events.publisher import EventPublisher, NullEventPublisher
+from app.hl7v2.ack import (
+    ERR_APPLICATION, ERR_REQUIRED_FIELD_MISSING, ERR_UNKNOWN_KEY,
+    ERR_UNSUPPORTED_EVENT, ERR_UNSUPPORTED_MESSAGE_TYPE, AckCode,
+)
+from app.hl7v2.mappers import obx_to_observation_fields, patient_mrn, pid_to_patient_fields
+from app.hl7v2.parser import Message
+from app.repositories.base import ConflictError
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+
+# ADT trigger events that carry patient demographics we keep in sync:
+# admit, register, pre-admit, update, add person, update person.
+ADT_PATIENT_EVENTS = {"A01", "A04", "A05", "A08", "A28", "A31"}
+
+
+class HL7ProcessingError(Exception):
+    """A message that parsed but cannot be applied; carries the ACK code and ERR condition to return."""
+
+    def __init__(self, code: AckCode, text: str, error: tuple):
+        self.code = code
+        self.text = text
+        self.error = error
+        super().__init__(text)
+
+
+def _validation_text(e: ValidationError) -> str:
+    return "; ".join(f"{'.'.join(str(p) for p in err['loc'])}: {err['msg']}" for err in e.errors())
+
+
+def _require_pid(message: Message):
+    pid = message.segment("PID")
+    if not pid:
+        raise HL7ProcessingError("AE", "PID segment is required", ERR_REQUIRED_FIELD_MISSING)
+    if not patient_mrn(pid):
+        raise HL7ProcessingError("AE", "PID-3 (patient identifier) is required", ERR_REQUIRED_FIELD_MISSING)
+    return pid
+
+
+def process_adt(message: Message, patients: PatientRepository, default_tz: tzinfo, events: Optional[EventPublisher] = None) -> str:
+    """Creates or updates the patient identified by PID-3. Returns the ACK text."""
+    events = events or NullEventPublisher()
+    fields = pid_to_patient_fields(_require_pid(message), default_tz)
+    existing = patients.get_by_mrn(fields["mrn"])
+    try:
+        if existing:
+            changes = schemas.PatientUpdate.model_validate({k: v for k, v in fields.items() if k != "mrn"})
+            patient = patients.update(existing.patient_id, changes)
+            events.emit("patient.updated", f"patients/{patient.patient_id}", patient)
+            return f"Updated patient {patient.patient_id}"
+        patient = patients.create(schemas.PatientCreate.model_validate(fields))
+        events.emit("patient.created", f"patients/{patient.patient_id}", patient)
+        return f"Created patient {patient.patient_id}"
+    except ValidationError as e:
+        raise HL7ProcessingError("AE", _validation_text(e), ERR_REQUIRED_FIELD_MISSING)
+    except ConflictError as e:
+        raise HL7ProcessingError("AE", str(e), ERR_APPLICATION)
+
+
+def _observation_identifier_system(message: Message) -> str:
+    sender = message.msh.component(4, 1) or message.msh.component(3, 1) or "unknown"
+    return f"urn:hl7v2:{sender}:obx"
+
+
+def process_oru(
+    message: Message,
+    patients: PatientRepository,
+    observations: ObservationRepository,
+    default_tz: tzinfo,
+    events: Optional[EventPublisher] = None,
+) -> str:
+    """
+    Stores each numeric OBX of an ORU^R01 as an observation of the patient in
+    PID-3, who must already be known. Every OBX gets an identifier derived
+    from the sender, MSH-10 and OBX-1, so a resent message is not stored
+    twice. All OBX segments are validated before anything is written.
+    """
+    events = events or NullEventPublisher()
+    pid = _require_pid(message)
+    mrn = patient_mrn(pid)
+    patient = patients.get_by_mrn(mrn)
+    if not patient:
+        raise HL7ProcessingError("AE", f"Unknown patient MRN '{mrn}'", ERR_UNKNOWN_KEY)
+
+    obr = message.segment("OBR")
+    fallback_time: Optional[str] = obr.field(7) if obr else None
+    system = _observation_identifier_system(message)
+
+    pending = []
+    skipped = 0
+    for index, obx in enumerate(message.all("OBX"), start=1):
+        fields = obx_to_observation_fields(obx, fallback_time, default_tz)
+        if fields is None:
+            skipped += 1
+            continue
+        if not fields["effectiveAt"]:
+            raise HL7ProcessingError("AE", f"OBX {index}: OBX-14 or OBR-7 (observation time) is required", ERR_REQUIRED_FIELD_MISSING)
+        identifier = {"system": system, "value": f"{message.control_id}-{obx.field(1) or index}"}
+        try:
+            pending.append(schemas.ObservationCreate.model_validate({
+                **fields, "patientId": patient.patient_id, "identifier": identifier,
+            }))
+        except ValidationError as e:
+            raise HL7ProcessingError("AE", f"OBX {index}: {_validation_text(e)}", ERR_REQUIRED_FIELD_MISSING)
+
+    stored = 0
+    for observation_in in pending:
+        if observations.find_by_identifier(observation_in.identifier.system, observation_in.identifier.value):
+            continue
+        observation = observations.create(observation_in)
+        events.emit("observation.recorded", f"patients/{patient.patient_id}/observations/{observation.observation_id}", observation)
+        stored += 1
+
+    text = f"Stored {stored} observation(s) for patient {patient.patient_id}"
+    if skipped:
+        text += f"; skipped {skipped} non-numeric OBX"
+    return text
+
+
+def process_message(
+    message: Message,
+    patients: PatientRepository,
+    observations: ObservationRepository,
+    default_tz: tzinfo,
+    events: Optional[EventPublisher] = None,
+) -> str:
+    """Dispatches on MSH-9. Returns the text for an AA acknowledgment or raises HL7ProcessingError."""
+    message_code, trigger = message.message_type
+    if message_code == "ADT":
+        if trigger not in ADT_PATIENT_EVENTS:
+            raise HL7ProcessingError("AR", f"ADT^{trigger} is not supported", ERR_UNSUPPORTED_EVENT)
+        return process_adt(message, patients, default_tz, events)
+    if message_code == "ORU":
+        if trigger != "R01":
+            raise HL7ProcessingError("AR", f"ORU^{trigger} is not supported", ERR_UNSUPPORTED_EVENT)
+        return process_oru(message, patients, observations, default_tz, events)
+    raise HL7ProcessingError("AR", f"Message type '{message_code}' is not supported", ERR_UNSUPPORTED_MESSAGE_TYPE)
@@ -0,0 +1,64 @@
+# Location: app/images/processing.py
+
+import io
+from dataclasses import dataclass
+
+# Photos are decoded and encoded again, so nothing but the pixels (and the
+# colour profile, which clinicians need to judge a wound's colour) is kept:
+# EXIF, including GPS position, camera serial numbers and timestamps, XMP
+# and comments are dropped. The EXIF orientation is applied to the pixels
+# first, so the photo still shows the right way up.
+FORMATS = {"JPEG": "image/jpeg", "PNG": "image/png"}
+JPEG_QUALITY = 92
+THUMBNAIL_QUALITY = 80
+
+
+class ImageRejected(ValueError):
+    """The upload is not an image this module accepts, or is too large to decode."""
+
+
+@dataclass(frozen=True)
+class ProcessedImage:
+    original: bytes  # The photo without its metadata, in its own format.
+    content_type: str
+    width: int
+    height: int
+    thumbnail: bytes  # JPEG, at most `thumbnail_pixels` on its longer side.
+
+
+def process_photo(data: bytes, max_pixels: int, thumbnail_pixels: int) -> ProcessedImage:
+    """Strips the metadata of a JPEG or PNG photo and makes its thumbnail. Raises ImageRejected."""
+    from PIL import Image, ImageOps, UnidentifiedImageError
+
+    try:
+        with Image.open(io.BytesIO(data)) as image:
+            if image.format not in FORMATS:
+                raise ImageRejected(f"Unsupported image format '{image.format}'; send JPEG or PNG")
+            if image.width * image.height > max_pixels:
+                raise ImageRejected(f"Image is {image.width}x{image.height}; at most {max_pixels} pixels are accepted")
+            image_format = image.format
+            # A CMYK profile would be wrong for the RGB pixels written below.
+            icc_profile = image.info.get("icc_profile") if image.mode != "CMYK" else None
+            upright = ImageOps.exif_transpose(image)
+    except (UnidentifiedImageError, Image.DecompressionBombError, OSError) as e:
+        raise ImageRejected(f"Not a readable image: {e}") from e
+
+    original = io.BytesIO()
+    save_options = {"icc_profile": icc_profile} if icc_profile else {}
+    if image_format == "JPEG":
+        upright.convert("RGB").save(original, "JPEG", quality=JPEG_QUALITY, **save_options)
+    else:
+        upright.save(original, "PNG", **save_options)
+
+    thumbnail = upright.convert("RGB")
+    thumbnail.thumbnail((thumbnail_pixels, thumbnail_pixels))
+    thumbnail_bytes = io.BytesIO()
+    thumbnail.save(thumbnail_bytes, "JPEG", quality=THUMBNAIL_QUALITY, **save_options)
+
+    return ProcessedImage(
+        original=original.getvalue(),
+        content_type=FORMATS[image_format],
+        width=upright.width,
+        height=upright.height,
+        thumbnail=thumbnail_bytes.getvalue(),
+    )
@@ -0,0 +1,136 @@
+# Location: app/invoices/payments.py
+
+import hashlib
+import hmac
+import time
+from abc import ABC, abstractmethod
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from functools import lru_cache
+from typing import Any, Dict, Optional
+
+import httpx
+
+from app.api.v1 import schemas
+from app.core.config import get_settings
+from app.core.http_client import new_client
+
+# The header Stripe signs its webhooks in, and how old a signature may be.
+STRIPE_SIGNATURE_HEADER = "Stripe-Signature"
+STRIPE_SIGNATURE_TOLERANCE_SECONDS = 300
+
+# Checkout events that mean the money was taken: at once for cards, later
+# for bank debits and the like.
+STRIPE_PAYMENT_EVENTS = frozenset({"checkout.session.completed", "checkout.session.async_payment_succeeded"})
+
+
+class PaymentProviderError(Exception):
+    """Raised when the payment provider could not be reached or refused the request."""
+
+
+@dataclass
+class Checkout:
+    """A payment page for the invoice's balance, open until `expires_at`."""
+    url: str
+    expires_at: datetime
+    reference: str
+
+
+@dataclass
+class ProviderPayment:
+    """A payment the provider reported taking for an invoice."""
+    invoice_id: str
+    tenant_id: Optional[str]
+    amount_cents: int
+    reference: str
+
+
+class PaymentProvider(ABC):
+    """Takes patients' payments of their invoices online."""
+
+    name: str
+
+    @abstractmethod
+    def checkout(self, invoice: schemas.Invoice, tenant_id: Optional[str], return_url: str) -> Checkout:
+        """Opens a page where the invoice's balance is paid; the patient is sent back to `return_url`. Raises PaymentProviderError."""
+
+
+class StripePayments(PaymentProvider):
+    """
+    Stripe Checkout: a hosted page per payment, whose outcome Stripe reports
+    to our webhook (see app/api/integrations/endpoints/payment_events.py).
+    The invoice and organization travel in the session's metadata.
+    """
+
+    name = "stripe"
+    API_URL = "https://api.stripe.com/v1"
+
+    def __init__(self, secret_key: str, timeout: float, client: Optional[httpx.Client] = None):
+        self.secret_key = secret_key
+        self.client = client or new_client("stripe", timeout)
+
+    def checkout(self, invoice: schemas.Invoice, tenant_id: Optional[str], return_url: str) -> Checkout:
+        data = {
+            "mode": "payment",
+            "client_reference_id": invoice.invoice_id,
+            "line_items[0][quantity]": "1",
+            "line_items[0][price_data][currency]": "usd",
+            "line_items[0][price_data][unit_amount]": str(invoice.balance_cents),
+            "line_items[0][price_data][product_data][name]": f"Statement {invoice.statement_number}",
+            "metadata[invoiceId]": invoice.invoice_id,
+            "payment_intent_data[metadata][invoiceId]": invoice.invoice_id,
+            "success_url": return_url,
+            "cancel_url": return_url,
+        }
+        if tenant_id:
+            data["metadata[tenantId]"] = tenant_id
+        try:
+            response = self.client.post(f"{self.API_URL}/checkout/sessions", data=data, headers={"Authorization": f"Bearer {self.secret_key}"})
+        except httpx.HTTPError as e:
+            raise PaymentProviderError(f"Stripe is unavailable: {e}") from e
+        if response.status_code >= 300:
+            raise PaymentProviderError(f"Stripe refused the checkout session ({response.status_code}): {response.text}")
+        session = response.json()
+        return Checkout(url=session["url"], expires_at=datetime.fromtimestamp(session["expires_at"], timezone.utc), reference=session["id"])
+
+
+def verify_stripe_signature(secret: str, payload: bytes, header: str, now: Optional[float] = None) -> bool:
+    """
+    Checks Stripe's signature of a webhook body: an HMAC of the timestamp and
+    body, one of the `v1` values of the header, made recently.
+    """
+    parts = [part.split("=", 1) for part in header.split(",") if "=" in part]
+    timestamps = [value for key, value in parts if key == "t"]
+    signatures = [value for key, value in parts if key == "v1"]
+    if not timestamps or not signatures or not timestamps[0].isdigit():
+        return False
+    if abs((now if now is not None else time.time()) - int(timestamps[0])) > STRIPE_SIGNATURE_TOLERANCE_SECONDS:
+        return False
+    expected = hmac.new(secret.encode(), timestamps[0].encode() + b"." + payload, hashlib.sha256).hexdigest()
+    return any(hmac.compare_digest(expected, signature) for signature in signatures)
+
+
+def stripe_payment(event: Dict[str, Any]) -> Optional[ProviderPayment]:
+    """The invoice payment a Stripe event reports, or None for the events not recorded."""
+    if event.get("type") not in STRIPE_PAYMENT_EVENTS:
+        return None
+    session = (event.get("data") or {}).get("object") or {}
+    metadata = session.get("metadata") or {}
+    invoice_id = metadata.get("invoiceId") or session.get("client_reference_id")
+    if session.get("payment_status") != "paid" or not invoice_id or not session.get("amount_total"):
+        return None
+    return ProviderPayment(
+        invoice_id=invoice_id,
+        tenant_id=metadata.get("tenantId"),
+        amount_cents=int(session["amount_total"]),
+        reference=session.get("payment_intent") or session["id"],
+    )
+
+
+@lru_cache
+def get_payment_provider() -> Optional[PaymentProvider]:
+    """Returns the configured payment provider, or None with PAYMENTS_PROVIDER=none."""
+    settings = get_settings()
+    if settings.payments_provider == "stripe":
+        return StripePayments(settings.stripe_secret_key, settings.payments_timeout_seconds)
+    return None
@@ -0,0 +1,114 @@
+# Location: app/invoices/statements.py
+
+from datetime import datetime, timezone
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.core.storage import upload_blob
+from app.invoices.pdf import render_text_pdf
+from app.repositories.invoices import InvoiceRepository
+from app.repositories.patients import PatientRepository
+
+# What the adjustment groups on a statement mean, printed under the lines.
+ADJUSTMENT_GROUPS = {
+    "CO": "contractual obligation, not billed to you",
+    "PR": "patient responsibility, e.g. deductible (1), coinsurance (2) or copay (3)",
+    "OA": "other adjustment",
+    "PI": "payer initiated reduction",
+    "CR": "correction",
+}
+
+COLUMNS = "{:<12}{:<16}{:>13}{:>14}{:>14}{:>13}"
+
+
+def statement_blob_name(invoice_id: str) -> str:
+    return f"invoices/{invoice_id}/statement.pdf"
+
+
+def _money(cents: int) -> str:
+    return f"{'-' if cents < 0 else ''}${abs(cents) // 100:,}.{abs(cents) % 100:02d}"
+
+
+def _remit_to(provider: Optional[schemas.BillingProvider]) -> List[str]:
+    if provider is None:
+        return []
+    lines = [provider.name]
+    if provider.address:
+        address = provider.address
+        postal_code = f"{address.postal_code[:5]}-{address.postal_code[5:]}" if len(address.postal_code) == 9 else address.postal_code
+        lines += [address.line, f"{address.city}, {address.state} {postal_code}"]
+    return lines
+
+
+def statement_lines(invoice: schemas.Invoice, patient: schemas.Patient, provider: Optional[schemas.BillingProvider], on: datetime) -> List[str]:
+    """The text of the patient's statement: who bills whom, each service with its adjustments, the totals and payments."""
+    lines = _remit_to(provider) + ["", "PATIENT STATEMENT", ""]
+    lines.append(f"Statement number: {invoice.statement_number:<20}Statement date: {on.date().isoformat()}")
+    name = f"{patient.given_name} {patient.family_name}"
+    lines.append(f"Patient: {name:<29}Due date: {invoice.due_date.isoformat() if invoice.due_date else 'on receipt'}")
+    contact = patient.contact
+    if contact and contact.address_line:
+        lines += [f"         {contact.address_line}", f"         {contact.city or ''} {contact.postal_code or ''}".rstrip()]
+    lines += [f"Insurance: {invoice.payer_name}", ""]
+
+    lines.append(COLUMNS.format("Date", "Service", "Charge", "Plan paid", "Adjusted", "You owe"))
+    lines.append("-" * 82)
+    for line in invoice.lines:
+        service = line.procedure_code + "".join(f"-{modifier}" for modifier in line.modifiers)
+        adjusted = sum(adjustment.amount_cents for adjustment in line.adjustments if adjustment.group != "PR")
+        lines.append(COLUMNS.format(
+            line.service_date.isoformat(), service, _money(line.charge_cents), _money(line.insurance_paid_cents), _money(adjusted), _money(line.patient_cents),
+        ))
+        if line.description:
+            lines.append(f"{'':12}{line.description}")
+        for adjustment in line.adjustments:
+            lines.append(f"{'':12}{adjustment.group}-{adjustment.reason_code}  {_money(adjustment.amount_cents)}")
+    lines.append("-" * 82)
+    lines += [
+        f"{'Total charges':<40}{_money(invoice.total_charge_cents):>42}",
+        f"{'Paid by your plan':<40}{_money(invoice.insurance_paid_cents):>42}",
+        f"{'Adjusted by your plan':<40}{_money(invoice.adjustment_cents):>42}",
+        f"{'Your responsibility':<40}{_money(invoice.amount_due_cents):>42}",
+    ]
+    for payment in invoice.payments:
+        lines.append(f"{'Payment received ' + payment.received_at.date().isoformat():<40}{_money(-payment.amount_cents):>42}")
+    lines += [f"{'BALANCE DUE':<40}{_money(invoice.balance_cents):>42}", ""]
+
+    if invoice.message:
+        lines += [invoice.message, ""]
+    groups = sorted({adjustment.group for line in invoice.lines for adjustment in line.adjustments})
+    if groups:
+        lines.append("Adjustment codes:")
+        lines += [f"  {group}  {ADJUSTMENT_GROUPS[group]}" for group in groups]
+        lines.append("")
+    lines.append(f"Please quote statement number {invoice.statement_number} with your payment.")
+    return lines
+
+
+def render_statement(invoice: schemas.Invoice, patient: schemas.Patient, provider: Optional[schemas.BillingProvider], on: datetime) -> bytes:
+    return render_text_pdf(statement_lines(invoice, patient, provider, on))
+
+
+def write_statement(
+    invoice_id: str,
+    invoices: InvoiceRepository,
+    patients: PatientRepository,
+    provider: Optional[schemas.BillingProvider],
+    bucket_name: str,
+) -> Optional[schemas.Invoice]:
+    """
+    Renders an issued invoice's statement to `bucket_name` and records it.
+    Returns the invoice, or None if it has no statement to render or has
+    one already, so a repeated run writes nothing.
+    """
+    invoice = invoices.get(invoice_id)
+    if not invoice or invoice.statement or invoice.status in ("draft", "void"):
+        return None
+    patient = patients.get(invoice.patient_id)
+    if not patient:
+        return None
+    now = datetime.now(timezone.utc)
+    blob_name = statement_blob_name(invoice_id)
+    upload_blob(bucket_name, blob_name, render_statement(invoice, patient, provider, now), "application/pdf")
+    statement = schemas.InvoiceStatement(blob_name=blob_name, rendered_at=now)
+    return invoices.update(invoice_id, {"statement": statement.model_dump(by_alias=True)})
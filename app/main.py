@@ -1,17 +1,64 @@
-import os
 import logging
+from contextlib import asynccontextmanager
 import firebase_admin
 from firebase_admin import credentials
-from fastapi import FastAPI, Request, status
-from fastapi.exceptions import RequestValidationError
-from fastapi.responses import JSONResponse
+from fastapi import FastAPI
 from fastapi.middleware.cors import CORSMiddleware
-from app.api.v1.endpoints import auth, customers, clinicians
+from app.analytics.export import AnalyticsExporter, get_analytics_recorder, get_analytics_sink
+from app.api import health, metrics, ws
+from app.api.fhir.router import fhir_router
+from app.api.graphql.router import graphql_router
+from app.api.openapi import DESCRIPTION, TAGS_METADATA, install_openapi, operation_id
+from app.api.integrations.router import integrations_router
+from app.api.internal.router import internal_router
+from app.api.v1.deps import (
+    get_job_lock_repository,
+    get_job_run_repository,
+    get_outbox_repository,
+    get_webhook_delivery_repository,
+    get_webhook_subscription_repository,
+)
+from app.api.v1.router import api_router
+from app.api.versioning import API_VERSIONS, VERSION_HEADER
+from app.core.config import get_settings
+from app.core.health import register_default_checks
+from app.core.logging_config import setup_logging
+from app.core.postgres import close_pool
+from app.core.request_context import REQUEST_ID_HEADER, TRACE_ID_HEADER
+from app.core.tracing import setup_tracing, shutdown_tracing
+from app.core.warmup import register_default_hooks, run_warmup
+from app.errors.handlers import register_exception_handlers
+from app.events.publisher import PubSubEventPublisher
+from app.events.relay import OutboxRelay
+from app.fhir.responses import FHIR_BASE_PATH
+from app.middleware.access_log import AccessLogMiddleware
+from app.middleware.analytics import UsageAnalyticsMiddleware
+from app.middleware.audit import AuditMiddleware
+from app.middleware.authentication import AuthenticationMiddleware
+from app.middleware.compression import CompressionMiddleware
+from app.middleware.conditional import ConditionalRequestMiddleware
+from app.middleware.idempotency import REPLAYED_HEADER, IdempotencyMiddleware
+from app.middleware.ip_allowlist import SourceAddressAllowlistMiddleware
+from app.middleware.load_shedding import LoadSheddingMiddleware
+from app.middleware.metrics import MetricsMiddleware
+from app.middleware.rate_limit import RateLimitMiddleware
+from app.middleware.recovery import RecoveryMiddleware
+from app.middleware.representation import RepresentationMiddleware
+from app.middleware.request_context import RequestContextMiddleware
+from app.middleware.security_headers import SecurityHeadersMiddleware
+from app.middleware.service_authentication import ServiceAuthenticationMiddleware
+from app.middleware.tenancy import TenancyMiddleware
+from app.middleware.versioning import ApiVersionMiddleware
+from app.rpc.server import RpcServer
+from app.scheduler.runner import JobRunner
+from app.scheduler.ticker import SchedulerTicker
+from app.webhooks.dispatcher import WebhookDispatcher
 
 # --- Logging Configuration ---
 # Configure logging at the application's entry point.
-# Cloud Run will automatically handle log output to Cloud Logging.
-logging.basicConfig(level=logging.INFO, format='%(asctime)s - %(name)s - %(levelname)s - %(message)s')
+# On Cloud Run, logs are emitted as structured JSON so Cloud Logging picks up
+# severity, trace and labels (see app/core/logging_config.py).
+setup_logging()
 
 # --- Firebase Admin SDK Initialization ---
 # It's crucial to initialize the app only once.
@@ -21,55 +68,260 @@ try:
     if not firebase_admin._apps:
         cred = credentials.ApplicationDefault()
         firebase_admin.initialize_app(cred, {
-            'projectId': os.getenv('GOOGLE_CLOUD_PROJECT'),
+            'projectId': get_settings().google_cloud_project,
         })
 except Exception as e:
     logging.error(f"Could not initialize Firebase Admin SDK: {e}")
     # Depending on the use case, you might want to exit the application
     # if Firebase connection is essential for all operations.
 
+# --- Application Lifespan ---
+@asynccontextmanager
+async def lifespan(app: FastAPI):
+    """
+    Runs once per worker process on startup and shutdown.
+    On SIGTERM the server stops accepting connections and waits for in-flight
+    requests to complete (see `graceful_timeout` in gunicorn.conf.py) before
+    the shutdown branch below runs.
+    """
+    settings = get_settings()
+    if settings.store == "postgres" and settings.database_migrate_on_startup:
+        # Workers starting together serialise on the migration lock, so each
+        # pending migration is applied once (see app/db/migrate.py).
+        from app.core.postgres import get_pool
+        from app.db.migrate import migrate_up
+        migrate_up(get_pool())
+    register_default_checks()
+    if settings.warmup_enabled:
+        # The worker accepts no connections until startup completes, so the
+        # first request to a new instance finds connections open and caches
+        # loaded (see app/core/warmup.py).
+        register_default_hooks()
+        run_warmup()
+    relay = None
+    if settings.domain_events_topic and settings.domain_events_delivery == "outbox" and settings.outbox_relay_enabled:
+        # Every worker relays; claimed entries are leased, so workers share
+        # the outbox without contending for the same entries (see app/events/relay.py).
+        relay = OutboxRelay(
+            get_outbox_repository(),
+            PubSubEventPublisher(settings.domain_events_topic),
+            batch_size=settings.outbox_relay_batch_size,
+            max_attempts=settings.outbox_max_attempts,
+        )
+        relay.start(settings.outbox_relay_interval_seconds)
+    dispatcher = None
+    if settings.webhooks_enabled:
+        dispatcher = WebhookDispatcher(
+            get_webhook_subscription_repository(),
+            get_webhook_delivery_repository(),
+            batch_size=settings.webhook_batch_size,
+            max_attempts=settings.webhook_max_attempts,
+            timeout_seconds=settings.webhook_timeout_seconds,
+        )
+        dispatcher.start(settings.webhook_dispatch_interval_seconds)
+    ticker = None
+    if settings.scheduler_ticker_enabled:
+        # Every worker ticks; the job locks make each due run happen once.
+        ticker = SchedulerTicker(JobRunner(get_job_lock_repository(), get_job_run_repository()))
+        ticker.start(settings.scheduler_tick_seconds)
+    exporter = None
+    if get_analytics_recorder():
+        exporter = AnalyticsExporter(get_analytics_recorder(), get_analytics_sink(), batch_size=settings.analytics_batch_size)
+        exporter.start(settings.analytics_flush_seconds)
+    rpc_server = None
+    if settings.grpc_enabled:
+        rpc_server = RpcServer(settings.grpc_port, settings.grpc_max_workers)
+        rpc_server.start()
+    logging.info("MegaCare Connect API worker started.")
+    yield
+    logging.info("MegaCare Connect API worker shutting down; in-flight requests have been drained.")
+    if relay:
+        relay.stop()
+    if dispatcher:
+        dispatcher.stop()
+    if ticker:
+        ticker.stop()
+    if exporter:
+        exporter.stop()
+        exporter.flush()
+    if rpc_server:
+        rpc_server.stop()
+    close_pool()
+    shutdown_tracing()
+
+api_docs_enabled = get_settings().api_docs_enabled
 app = FastAPI(
     title="MegaCare Connect API",
-    description="Backend API for the MegaCare Connect application.",
-    version="1.0.0"
+    description=DESCRIPTION,
+    version="1.0.0",
+    openapi_tags=TAGS_METADATA,
+    openapi_url="/openapi.json" if api_docs_enabled else None,
+    docs_url="/docs" if api_docs_enabled else None,
+    redoc_url="/redoc" if api_docs_enabled else None,
+    generate_unique_id_function=operation_id,
+    lifespan=lifespan
 )
 
-# --- Custom Exception Handler for Validation Errors ---
-@app.exception_handler(RequestValidationError)
-async def validation_exception_handler(request: Request, exc: RequestValidationError):
-    """
-    Custom handler to log detailed validation errors for 422 responses.
-    This helps in debugging malformed client-side requests.
-    The actual response is delegated back to FastAPI's default handler
-    to ensure it's processed correctly by middleware like CORS.
-    """
-    error_details = exc.errors()
-    logging.error(f"422 Unprocessable Entity. Request: {request.method} {request.url}. Errors: {error_details}")
+# --- OpenAPI ---
+# /openapi.json describes every public route for integrators and client
+# generators, with errors as they are sent (see app/api/openapi.py).
+install_openapi(app)
+
+# --- Tracing ---
+# Server spans per request and Cloud Trace export (see app/core/tracing.py).
+setup_tracing(app)
+
+# --- Exception Handlers ---
+# Errors are reported as application/problem+json, or as OperationOutcome
+# resources under /fhir (see app/errors/handlers.py).
+register_exception_handlers(app)
+
+# --- Recovery Middleware ---
+# Added first so it is the innermost middleware: unhandled exceptions are
+# turned into a problem+json 500 that still passes through CORS on the way out.
+app.add_middleware(RecoveryMiddleware)
+
+# --- Representation Middleware ---
+# Renders /api responses as FHIR, NDJSON or CSV when asked for them (see
+# app/middleware/representation.py). Inside conditional requests so ETags
+# and If-Match checks are of the representation the client reads.
+app.add_middleware(RepresentationMiddleware)
+
+# --- Conditional Request Middleware ---
+# ETags and If-None-Match / If-Match handling (see app/middleware/conditional.py).
+# Inside compression so tags are computed from the uncompressed body, and
+# inside authentication so the If-Match check reads as the same caller.
+app.add_middleware(ConditionalRequestMiddleware)
+
+# --- Idempotency Middleware ---
+# Replays the stored response to retried POSTs with an Idempotency-Key (see
+# app/middleware/idempotency.py). Inside authentication so keys belong to
+# the verified caller, and inside compression so stored bodies are plain.
+app.add_middleware(IdempotencyMiddleware)
+
+# --- Compression Middleware ---
+# Just outside recovery, so error bodies are compressed too and the metrics
+# middleware records the bytes actually sent.
+if get_settings().compression_enabled:
+    app.add_middleware(CompressionMiddleware)
 
-    # By calling the default handler, we ensure the response format is
-    # consistent and that it passes through the middleware chain correctly.
-    from fastapi.exception_handlers import request_validation_exception_handler
-    return await request_validation_exception_handler(request, exc)
+# --- Rate Limit Middleware ---
+# Inside authentication so requests count against the verified caller's
+# quota rather than a shared address; 429s are still counted and audited.
+if get_settings().rate_limit_enabled:
+    app.add_middleware(RateLimitMiddleware)
+
+# --- Source Address Allowlist Middleware ---
+# Admin and integration requests must come from the organization's allowed
+# CIDR ranges (see app/middleware/ip_allowlist.py). Inside tenancy, which
+# settles the organization a request acts for.
+app.add_middleware(SourceAddressAllowlistMiddleware)
+
+# --- Tenancy Middleware ---
+# Scopes each request to the caller's organization and refuses cross-tenant
+# access (see app/middleware/tenancy.py). Just inside authentication so the
+# organization is the verified caller's, and outside every middleware that
+# reads or stores records.
+if get_settings().tenancy_enabled:
+    app.add_middleware(TenancyMiddleware)
+
+# --- Authentication Middleware ---
+# Rejects /api/v1 requests without a valid bearer token before they reach a
+# route. Inside metrics so rejected requests are still counted, and inside
+# audit so the verified caller is recorded as the actor.
+app.add_middleware(AuthenticationMiddleware)
+
+# --- Service Authentication Middleware ---
+# Admits only allowed MegaCare service accounts to /internal/services (see
+# app/middleware/service_authentication.py), in the same position as user
+# authentication so rejected calls are counted and audited.
+app.add_middleware(ServiceAuthenticationMiddleware)
+
+# --- Metrics Middleware ---
+# Wraps the recovery middleware so requests that end in an unhandled
+# exception are still counted with their final 500 status.
+app.add_middleware(MetricsMiddleware)
+
+# --- Usage Analytics Middleware ---
+# Records de-identified usage rows for the analytics export (see
+# app/analytics/rows.py). Inside audit, whose context names the caller, and
+# outside metrics so the latency is that of the whole request.
+if get_settings().analytics_sink != "none":
+    app.add_middleware(
+        UsageAnalyticsMiddleware,
+        recorder_factory=get_analytics_recorder,
+        pseudonym_key=get_settings().analytics_pseudonym_key,
+        sample_rate=get_settings().analytics_usage_sample_rate,
+    )
+
+# --- Audit Middleware ---
+# Records who accessed which patient data (see app/middleware/audit.py).
+# Outside recovery so failed requests are audited with their final status,
+# and inside request context so events carry the request and trace IDs.
+app.add_middleware(AuditMiddleware)
+
+# --- Load Shedding Middleware ---
+# Refuses requests while the worker is at its concurrency limit (see
+# app/ratelimit/concurrency.py). Outside audit and authentication so a
+# refused request costs no token check or audit write, and inside the
+# access log so refusals are still logged.
+if get_settings().load_shedding_enabled:
+    app.add_middleware(LoadSheddingMiddleware)
+
+# --- Access Log Middleware ---
+# One request log entry per request (see app/core/access_log.py). Outside
+# audit and authentication so every response is logged with the caller they
+# verified, and inside API versioning so routes are logged by their
+# versioned template.
+if get_settings().access_log_enabled:
+    app.add_middleware(AccessLogMiddleware)
+
+# --- API Version Middleware ---
+# Serves unversioned /api paths by the negotiated version and labels
+# responses with their version and deprecation (see app/middleware/versioning.py).
+# Outside audit, authentication and metrics so they only see versioned
+# paths, and inside CORS so its refusals can be read by browsers.
+app.add_middleware(ApiVersionMiddleware)
+
+# --- Security Headers Middleware ---
+# HSTS, nosniff, Content-Security-Policy, Referrer-Policy, and no-store on
+# routes that return patient data (see app/middleware/security_headers.py).
+# Outside every middleware that can answer on its own, so refusals and
+# errors carry them too, and inside CORS, whose preflights need none.
+if get_settings().security_headers_enabled:
+    app.add_middleware(SecurityHeadersMiddleware)
 
 # --- CORS Middleware ---
-# To allow any origin to access your API, you can use a wildcard "*".
-# This is often used for public APIs or during development to avoid CORS issues.
-#
-# IMPORTANT: According to the CORS specification, when `allow_origins` is set to a
-# wildcard ("*"), `allow_credentials` must be set to `False`. Your application uses
-# a bearer token for authentication, which is passed in a header and is not
-# affected by this `allow_credentials` setting (which primarily relates to cookies).
+# Browser clients such as the patient web portal are allowed by origin
+# (CORS_ALLOW_ORIGINS, see app/core/config.py). Outside authentication so
+# preflights are answered without a token, and so error responses carry the
+# CORS headers the browser needs to read them.
+settings = get_settings()
 app.add_middleware(
     CORSMiddleware,
-    allow_origins=["*"],
-    allow_credentials=False,
-    allow_methods=["*"], # Allows all methods (GET, POST, etc.)
-    allow_headers=["*"], # Allows all headers, including Authorization
+    allow_origins=settings.cors_origins,
+    allow_origin_regex=settings.cors_allow_origin_regex,
+    allow_credentials=settings.cors_allow_credentials,
+    allow_methods=settings.cors_methods,
+    allow_headers=settings.cors_headers,
+    expose_headers=[REQUEST_ID_HEADER, TRACE_ID_HEADER, "Retry-After", "ETag", VERSION_HEADER, "Deprecation", "Sunset", "Link", REPLAYED_HEADER], # Lets browser clients report the request ID, back off, revalidate, notice deprecations and replays
+    max_age=settings.cors_max_age_seconds,
 )
 
-app.include_router(customers.router, prefix="/api/v1/customers", tags=["Customers"])
-app.include_router(auth.router, prefix="/api/v1/auth", tags=["Authentication"])
-app.include_router(clinicians.router, prefix="/api/v1/clinician", tags=["Clinicians"])
+# --- Request Context Middleware ---
+# Added last so it is the outermost middleware: every request, including CORS
+# preflights, gets a request ID and trace context before anything is logged.
+app.add_middleware(RequestContextMiddleware)
+
+app.include_router(health.router, tags=["Health Check"])
+app.include_router(metrics.router)
+app.include_router(api_router, prefix=API_VERSIONS["v1"].prefix)
+app.include_router(graphql_router, prefix="/graphql", tags=["GraphQL"])
+app.include_router(fhir_router, prefix=FHIR_BASE_PATH, tags=["FHIR R4"])
+app.include_router(integrations_router, prefix="/integrations", tags=["Integrations"])
+app.include_router(internal_router, prefix="/internal", tags=["Internal"], include_in_schema=False)
+# WebSocket sessions skip the HTTP middleware above and authenticate themselves (see app/realtime/session.py).
+app.include_router(ws.router)
 
 @app.get("/", tags=["Health Check"])
 def read_root():
@@ -0,0 +1,88 @@
+# Location: app/middleware/access_log.py
+
+import random
+import time
+from typing import List, Optional
+
+from starlette.datastructures import Headers
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from app.authz.networks import client_address
+from app.core.access_log import ACCESS_LOG_LABEL, SampleRule, get_access_logger, http_request_entry, parse_sample_rules, sample_rate
+from app.core.config import get_settings
+from app.middleware.metrics import route_template
+
+
+def _principal(scope: Scope) -> Optional[str]:
+    """The verified caller: the user or API key, or the calling service."""
+    state = scope.get("state") or {}
+    principal = state.get("principal") or {}
+    if principal.get("uid"):
+        return principal["uid"]
+    return (state.get("service_caller") or {}).get("email")
+
+
+class AccessLogMiddleware:
+    """
+    Writes one access log entry per HTTP request once its response has been
+    sent (see app/core/access_log.py): method, route template, status,
+    latency, bytes received and sent, the verified caller and, through the
+    logging filter, the request and trace IDs. Successful requests to the
+    routes in `rules` are logged at their sample rate; responses of 400 and
+    above are always logged.
+    """
+
+    def __init__(self, app: ASGIApp, rules: Optional[List[SampleRule]] = None):
+        self.app = app
+        self.rules = rules if rules is not None else parse_sample_rules(get_settings().access_log_sample_rules)
+        self.logger = get_access_logger()
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        if scope["type"] != "http":
+            await self.app(scope, receive, send)
+            return
+
+        status_code = 500
+        request_size = 0
+        response_size = 0
+
+        async def receive_wrapper() -> Message:
+            nonlocal request_size
+            message = await receive()
+            if message["type"] == "http.request":
+                request_size += len(message.get("body", b""))
+            return message
+
+        async def send_wrapper(message: Message):
+            nonlocal status_code, response_size
+            if message["type"] == "http.response.start":
+                status_code = message["status"]
+            elif message["type"] == "http.response.body":
+                response_size += len(message.get("body", b""))
+            await send(message)
+
+        started = time.perf_counter()
+        try:
+            await self.app(scope, receive_wrapper, send_wrapper)
+        finally:
+            latency = time.perf_counter() - started
+            if status_code >= 400 or random.random() < sample_rate(self.rules, scope.get("path", "")):
+                self._log(scope, status_code, latency, request_size, response_size)
+
+    def _log(self, scope: Scope, status_code: int, latency: float, request_size: int, response_size: int) -> None:
+        headers = Headers(scope=scope)
+        route = route_template(scope)
+        principal = _principal(scope)
+        self.logger.info(
+            f"{scope['method']} {route} {status_code} {latency * 1000:.0f}ms",
+            extra={
+                "http_request": http_request_entry(
+                    scope["method"], scope.get("path", ""), status_code, latency, request_size, response_size,
+                    protocol=f"HTTP/{scope.get('http_version', '1.1')}",
+                    user_agent=headers.get("user-agent"),
+                    remote_ip=client_address(scope, get_settings().trusted_proxy_hops),
+                ),
+                "json_fields": {"route": route, "principal": principal},
+                "labels": ACCESS_LOG_LABEL,
+            },
+        )
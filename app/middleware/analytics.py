@@ -0,0 +1,65 @@
+# Location: app/middleware/analytics.py
+
+import random
+import time
+from datetime import datetime, timezone
+from typing import Callable, Optional
+
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from app.analytics.export import AnalyticsRecorder
+from app.analytics.rows import request_row
+from app.audit.context import audit_context_var
+from app.middleware.audit import UNAUDITED_ROUTES
+from app.middleware.metrics import UNMATCHED_ROUTE, route_template
+
+
+class UsageAnalyticsMiddleware:
+    """
+    Records a usage row (see app/analytics/rows.py) for a `sample_rate`
+    share of API requests: route template, status, latency, and the caller
+    by pseudonym and roles, read from the audit context, so it must run
+    inside AuditMiddleware. Probe and metrics routes are not recorded.
+    """
+
+    def __init__(
+        self,
+        app: ASGIApp,
+        recorder_factory: Callable[[], Optional[AnalyticsRecorder]],
+        pseudonym_key: str,
+        sample_rate: float = 1.0,
+    ):
+        self.app = app
+        self.recorder_factory = recorder_factory
+        self.pseudonym_key = pseudonym_key
+        self.sample_rate = sample_rate
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        if scope["type"] != "http" or scope["method"] == "OPTIONS" or random.random() >= self.sample_rate:
+            await self.app(scope, receive, send)
+            return
+
+        status_code = 500
+
+        async def send_wrapper(message: Message):
+            nonlocal status_code
+            if message["type"] == "http.response.start":
+                status_code = message["status"]
+            await send(message)
+
+        occurred_at = datetime.now(timezone.utc)
+        started = time.perf_counter()
+        try:
+            await self.app(scope, receive, send_wrapper)
+        finally:
+            route = route_template(scope)
+            recorder = self.recorder_factory()
+            if recorder and route != UNMATCHED_ROUTE and route not in UNAUDITED_ROUTES:
+                context = audit_context_var.get()
+                recorder.record(request_row(
+                    self.pseudonym_key, occurred_at, route, scope["method"], status_code,
+                    (time.perf_counter() - started) * 1000,
+                    context.actor_uid if context else None,
+                    context.actor_roles if context else [],
+                    context.organization_id if context else None,
+                ))
@@ -0,0 +1,76 @@
+# Location: app/middleware/audit.py
+
+import logging
+from datetime import datetime, timezone
+from typing import Callable, Optional
+
+from starlette.concurrency import run_in_threadpool
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from app.audit.context import AuditContext, audit_context_var
+from app.audit.events import build_event
+from app.core.request_context import get_request_id, get_trace_id
+from app.middleware.metrics import UNMATCHED_ROUTE, route_template
+from app.repositories.audit_events import AuditEventRepository
+from app.tenancy.context import acting_for
+
+# Routes that never touch patient data.
+UNAUDITED_ROUTES = {"/", "/healthz", "/readyz", "/metrics"}
+
+
+def _default_repository() -> AuditEventRepository:
+    from app.api.v1.deps import get_audit_event_repository
+    return get_audit_event_repository()
+
+
+class AuditMiddleware:
+    """
+    Writes an audit event (who, what, when, from where, why) for every API
+    request, whether it succeeded or not. Requests that match no route and
+    the probe/metrics routes are not audited.
+
+    The actor is filled in by `get_current_user`, and handlers can add the
+    patient for routes that do not carry it via `app.audit.context.annotate`.
+    The event is stored after the response has been sent; a failed write
+    cannot change the response any more, so it is reported to Cloud Error
+    Reporting for follow-up instead.
+    """
+
+    def __init__(self, app: ASGIApp, repository_factory: Optional[Callable[[], AuditEventRepository]] = None):
+        self.app = app
+        self.repository_factory = repository_factory or _default_repository
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        if scope["type"] != "http" or scope["method"] == "OPTIONS":
+            await self.app(scope, receive, send)
+            return
+
+        status_code = 500
+
+        async def send_wrapper(message: Message):
+            nonlocal status_code
+            if message["type"] == "http.response.start":
+                status_code = message["status"]
+            await send(message)
+
+        context = AuditContext()
+        occurred_at = datetime.now(timezone.utc)
+        token = audit_context_var.set(context)
+        try:
+            await self.app(scope, receive, send_wrapper)
+        finally:
+            audit_context_var.reset(token)
+            route = route_template(scope)
+            if route != UNMATCHED_ROUTE and route not in UNAUDITED_ROUTES:
+                event = build_event(scope, route, status_code, context, occurred_at, get_request_id(), get_trace_id())
+                await self._store(event, context.organization_id)
+
+    async def _store(self, event, organization_id: Optional[str]) -> None:
+        try:
+            with acting_for(organization_id):
+                await run_in_threadpool(lambda: self.repository_factory().append(event))
+        except Exception:
+            logging.exception(
+                f"Failed to write audit event for {event.method} {event.route} by {event.actor_uid or 'anonymous'}",
+                extra={"report_error": True},
+            )
@@ -0,0 +1,159 @@
+# Location: app/middleware/authentication.py
+
+import logging
+from typing import Callable, Optional, Sequence
+
+from starlette.concurrency import run_in_threadpool
+from starlette.datastructures import Headers
+from starlette.types import ASGIApp, Receive, Scope, Send
+
+from app.api.v1 import schemas
+from app.api.versioning import version_prefixes
+from app.audit.context import set_actor
+from app.auth.api_keys import API_KEY_HEADER, ApiKeyVerifier, KeyRateLimiter, get_api_key_verifier, scope_for
+from app.auth.context import principal_var
+from app.auth.lockout import LockedOut, LoginGuard, address, get_login_guard
+from app.auth.verifier import InvalidTokenError, TokenVerifier, get_token_verifier
+from app.authz.networks import client_address
+from app.core.config import get_settings
+from app.errors.problems import ProblemResponse, problem_response
+
+# Every served API version is protected (see app/api/versioning.py).
+PROTECTED_PREFIXES = version_prefixes()
+# Login endpoints exchange third-party credentials for a token, so they are
+# called before the client has one.
+PUBLIC_PREFIXES = tuple(prefix + "/auth/" for prefix in PROTECTED_PREFIXES)
+
+
+def bearer_token(authorization: Optional[str]) -> Optional[str]:
+    """Extracts the token from an `Authorization: Bearer <token>` header."""
+    scheme, _, token = (authorization or "").partition(" ")
+    if scheme.lower() != "bearer" or not token.strip():
+        return None
+    return token.strip()
+
+
+class AuthenticationMiddleware:
+    """
+    Rejects requests to /api/<version> routes (other than login) that do not carry
+    a valid bearer token or `X-Api-Key`, before any handler runs. The
+    verified claims are put in the request context
+    (`app.auth.context.principal_var`) and in `request.state.principal`,
+    and `get_current_user` returns them without verifying again.
+
+    API keys are for partner backends that cannot obtain OAuth tokens. A key
+    request must be covered by one of the key's scopes (see `scope_for`)
+    and within its per-minute rate limit, or it is refused with 403 or 429.
+
+    Rejected credentials count towards the source address's lockout (see
+    `LoginGuard`); once it is delayed or locked out, further rejections are
+    answered with 429 and Retry-After. Credentials that could not be
+    checked, e.g. while the issuer's keys cannot be fetched, are answered
+    with 503 and not counted. Valid credentials are never turned
+    away by it, so clinics behind one address keep working while a device
+    there sends stale tokens.
+    """
+
+    def __init__(
+        self,
+        app: ASGIApp,
+        verifier_factory: Callable[[], TokenVerifier] = get_token_verifier,
+        api_key_verifier_factory: Callable[[], ApiKeyVerifier] = get_api_key_verifier,
+        rate_limiter: Optional[KeyRateLimiter] = None,
+        default_rate_limit: Optional[int] = None,
+        protected_prefixes: Sequence[str] = PROTECTED_PREFIXES,
+        public_prefixes: Sequence[str] = PUBLIC_PREFIXES,
+        login_guard_factory: Callable[[], LoginGuard] = get_login_guard,
+    ):
+        self.app = app
+        self.verifier_factory = verifier_factory
+        self.api_key_verifier_factory = api_key_verifier_factory
+        self.rate_limiter = rate_limiter or KeyRateLimiter()
+        self.default_rate_limit = default_rate_limit or get_settings().api_key_default_rate_limit_per_minute
+        self.protected_prefixes = tuple(protected_prefixes)
+        self.public_prefixes = tuple(public_prefixes)
+        self.login_guard_factory = login_guard_factory
+
+    def _requires_token(self, scope: Scope) -> bool:
+        path = scope.get("path", "")
+        if scope["method"] == "OPTIONS" or path.startswith(self.public_prefixes):
+            return False
+        return any(path == prefix or path.startswith(prefix + "/") for prefix in self.protected_prefixes)
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        if scope["type"] != "http" or not self._requires_token(scope):
+            await self.app(scope, receive, send)
+            return
+
+        headers = Headers(scope=scope)
+        token = bearer_token(headers.get("authorization"))
+        api_key = headers.get(API_KEY_HEADER)
+        if not token and not api_key:
+            await self._reject(scope, receive, send, "Authentication token not provided")
+            return
+        try:
+            if token:
+                claims = await run_in_threadpool(self.verifier_factory().verify, token)
+            else:
+                record, claims = await run_in_threadpool(self.api_key_verifier_factory().verify, api_key)
+        except InvalidTokenError as e:
+            logging.info(f"Rejected credentials for {scope['method']} {scope['path']}: {e}")
+            refusal = await run_in_threadpool(self._count_rejection, scope)
+            if refusal:
+                await refusal(scope, receive, send)
+                return
+            await self._reject(scope, receive, send, f"Invalid authentication credentials: {e}")
+            return
+        except Exception as e:
+            # E.g. the issuer's keys or the key store could not be reached:
+            # not the client's fault, so not counted towards its lockout.
+            logging.error(f"Could not verify credentials for {scope['method']} {scope['path']}: {e}", extra={"report_error": True})
+            response = problem_response(503, "Credentials cannot be verified right now; try again shortly", headers={"Retry-After": "5"})
+            await response(scope, receive, send)
+            return
+
+        if not token:
+            refusal = self._check_api_key(scope, record)
+            if refusal:
+                await refusal(scope, receive, send)
+                return
+
+        scope.setdefault("state", {})
+        scope["state"]["principal"] = claims
+        set_actor(claims)
+        context_token = principal_var.set(claims)
+        try:
+            await self.app(scope, receive, send)
+        finally:
+            principal_var.reset(context_token)
+
+    def _check_api_key(self, scope: Scope, record: schemas.ApiKeyRecord) -> Optional[ProblemResponse]:
+        """Returns the response refusing a key request outside its scopes or rate limit, if any."""
+        needed = scope_for(scope["method"], scope["path"])
+        if needed not in record.scopes:
+            return problem_response(403, f"API key lacks the '{needed}' scope")
+        allowed, retry_after = self.rate_limiter.allow(record.key_id, record.rate_limit_per_minute or self.default_rate_limit)
+        if not allowed:
+            return problem_response(429, "API key rate limit exceeded", headers={"Retry-After": str(retry_after)})
+        return None
+
+    def _count_rejection(self, scope: Scope) -> Optional[ProblemResponse]:
+        """
+        Counts rejected credentials against the source address. Returns the
+        429 refusing them if the address was already made to wait. A store
+        failure is logged and leaves the rejection as it is.
+        """
+        source = address(client_address(scope, get_settings().trusted_proxy_hops))
+        try:
+            guard = self.login_guard_factory()
+            guard.check(source)
+            guard.failed(source)
+        except LockedOut as e:
+            return problem_response(429, str(e), headers={"Retry-After": str(e.retry_after)})
+        except Exception as e:
+            logging.warning(f"Could not count rejected credentials from {source.value if source else 'unknown address'}: {e}")
+        return None
+
+    async def _reject(self, scope: Scope, receive: Receive, send: Send, detail: str) -> None:
+        response = problem_response(401, detail, headers={"WWW-Authenticate": "Bearer"})
+        await response(scope, receive, send)
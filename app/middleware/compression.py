@@ -0,0 +1,161 @@
+# Location: app/middleware/compression.py
+
+import zlib
+from typing import Optional, Tuple
+
+from starlette.datastructures import Headers, MutableHeaders
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from app.core.config import get_settings
+
+# Bodies that are worth compressing: JSON of every flavour (FHIR, problem
+# details, NDJSON exports) and text. Event streams are left alone so each
+# event reaches the client as soon as it is sent.
+_UNCOMPRESSED_TYPES = ("text/event-stream",)
+
+
+def compressible(content_type: Optional[str]) -> bool:
+    media_type = (content_type or "").split(";", 1)[0].strip().lower()
+    if not media_type or media_type in _UNCOMPRESSED_TYPES:
+        return False
+    return media_type.startswith("text/") or media_type.endswith(("/json", "+json", "ndjson"))
+
+
+def negotiate_encoding(accept_encoding: Optional[str], supported: Tuple[str, ...]) -> Optional[str]:
+    """
+    Picks the first of `supported` (in order of preference) that the
+    Accept-Encoding header accepts, or None for an uncompressed response.
+    """
+    accepted = {}
+    for part in (accept_encoding or "").split(","):
+        coding, _, params = part.strip().partition(";")
+        quality = 1.0
+        for param in params.split(";"):
+            name, _, value = param.strip().partition("=")
+            if name == "q":
+                try:
+                    quality = float(value)
+                except ValueError:
+                    quality = 0.0
+        if coding:
+            accepted[coding.strip().lower()] = quality
+    for coding in supported:
+        if accepted.get(coding, accepted.get("*", 0.0)) > 0:
+            return coding
+    return None
+
+
+class _Compressor:
+    """Incremental gzip or brotli compression of one response body."""
+
+    def __init__(self, encoding: str, gzip_level: int):
+        if encoding == "br":
+            import brotli
+            self._brotli = brotli.Compressor(quality=4)
+            self._zlib = None
+        else:
+            self._brotli = None
+            self._zlib = zlib.compressobj(gzip_level, zlib.DEFLATED, 16 + zlib.MAX_WBITS)
+
+    def compress(self, data: bytes) -> bytes:
+        """Compresses a chunk and flushes it, so streamed responses are not held back."""
+        if self._brotli:
+            return self._brotli.process(data) + self._brotli.flush()
+        return self._zlib.compress(data) + self._zlib.flush(zlib.Z_SYNC_FLUSH)
+
+    def finish(self, data: bytes = b"") -> bytes:
+        if self._brotli:
+            return self._brotli.process(data) + self._brotli.finish()
+        return self._zlib.compress(data) + self._zlib.flush()
+
+
+class CompressionMiddleware:
+    """
+    Compresses JSON and text responses of at least `minimum_size` bytes with
+    brotli or gzip, as negotiated from Accept-Encoding. Smaller bodies are
+    sent as they are: compressing them costs more CPU than it saves. Bodies
+    streamed in several parts are compressed as they are sent once their
+    first parts reach the minimum size.
+    """
+
+    def __init__(
+        self,
+        app: ASGIApp,
+        minimum_size: Optional[int] = None,
+        gzip_level: Optional[int] = None,
+        brotli: Optional[bool] = None,
+    ):
+        settings = get_settings()
+        self.app = app
+        self.minimum_size = minimum_size if minimum_size is not None else settings.compression_minimum_size
+        self.gzip_level = gzip_level if gzip_level is not None else settings.compression_gzip_level
+        use_brotli = brotli if brotli is not None else settings.compression_brotli_enabled
+        self.encodings = ("br", "gzip") if use_brotli else ("gzip",)
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        encoding = None
+        if scope["type"] == "http" and scope["method"] != "HEAD":
+            encoding = negotiate_encoding(Headers(scope=scope).get("accept-encoding"), self.encodings)
+        if not encoding:
+            await self.app(scope, receive, send)
+            return
+
+        start: Optional[Message] = None
+        buffered = b""
+        compressor: Optional[_Compressor] = None
+        passthrough = False
+
+        def compressed_start(content_length: Optional[int]) -> Message:
+            headers = MutableHeaders(scope=start)
+            headers["Content-Encoding"] = encoding
+            del headers["Content-Length"]
+            if content_length is not None:
+                headers["Content-Length"] = str(content_length)
+            return start
+
+        async def send_compressed(message: Message):
+            nonlocal start, buffered, compressor, passthrough
+            if message["type"] == "http.response.start":
+                headers = Headers(raw=message.get("headers", []))
+                passthrough = (
+                    message["status"] in (204, 304)
+                    or "content-encoding" in headers
+                    or not compressible(headers.get("content-type"))
+                )
+                if passthrough:
+                    await send(message)
+                    return
+                MutableHeaders(scope=message).add_vary_header("Accept-Encoding")
+                start = message
+                return
+            if message["type"] != "http.response.body" or passthrough:
+                await send(message)
+                return
+
+            body = message.get("body", b"")
+            more_body = message.get("more_body", False)
+            if compressor:
+                chunk = compressor.compress(body) if more_body else compressor.finish(body)
+                await send({"type": "http.response.body", "body": chunk, "more_body": more_body})
+                return
+
+            buffered += body
+            if len(buffered) < self.minimum_size:
+                if more_body:
+                    return
+                # The whole body turned out small; send it as it is.
+                await send(start)
+                await send({"type": "http.response.body", "body": buffered, "more_body": False})
+                return
+
+            compressor = _Compressor(encoding, self.gzip_level)
+            if more_body:
+                await send(compressed_start(None))
+                await send({"type": "http.response.body", "body": compressor.compress(buffered), "more_body": True})
+            else:
+                compressed = compressor.finish(buffered)
+                await send(compressed_start(len(compressed)))
+                await send({"type": "http.response.body", "body": compressed, "more_body": False})
+            buffered = b""
+
+        await self.app(scope, receive, send_compressed)
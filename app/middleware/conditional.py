@@ -0,0 +1,274 @@
+# Location: app/middleware/conditional.py
+
+import hashlib
+import json
+import logging
+import re
+from typing import Any, FrozenSet, List, Optional, Tuple
+
+from starlette.datastructures import Headers, MutableHeaders
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from app.errors.problems import ProblemResponse, problem_response, problem_type
+from app.middleware.metrics import matched_route
+from app.repositories.base import VERSION_FIELD, expected_version
+
+# Bodies larger than this are sent without an ETag rather than held in
+# memory to be hashed; in practice these are exports and files.
+MAX_ETAG_BODY_BYTES = 1024 * 1024
+
+# Responses that are never tagged: an event stream has no end to hash, and
+# each event must reach the client as soon as it is sent.
+UNTAGGED_TYPES = ("text/event-stream",)
+
+WRITE_METHODS = ("PUT", "PATCH", "DELETE")
+
+# Writes that must say which version they are based on, when the resource
+# has one.
+VERSIONED_METHODS = ("PUT", "PATCH")
+
+_VERSION_TAG = re.compile(r'^W/"(\d+)"$')
+
+
+def body_etag(body: bytes) -> str:
+    """The strong ETag of a response body."""
+    return f'"{hashlib.sha256(body).hexdigest()[:32]}"'
+
+
+def version_etag(version: int) -> str:
+    """The weak ETag of a record version, as FHIR servers send it."""
+    return f'W/"{version}"'
+
+
+def body_version(body: bytes) -> Optional[int]:
+    """The `version` of a JSON object body, if it has one."""
+    if not body.lstrip().startswith(b"{"):
+        return None
+    try:
+        version = json.loads(body).get(VERSION_FIELD)
+    except ValueError:
+        return None
+    return version if isinstance(version, int) and not isinstance(version, bool) else None
+
+
+def tag_version(tags: List[str]) -> Optional[int]:
+    """The version an If-Match names, if it is a single version ETag."""
+    versions = [int(match.group(1)) for match in map(_VERSION_TAG.match, tags) if match]
+    return versions[0] if len(tags) == 1 and versions else None
+
+
+def parse_etags(header: Optional[str]) -> List[str]:
+    """The entity tags of an If-Match or If-None-Match header; `*` stands for any."""
+    return [tag.strip() for tag in (header or "").split(",") if tag.strip()]
+
+
+def _opaque(tag: str) -> str:
+    return tag[2:] if tag.startswith("W/") else tag
+
+
+def none_match(tags: List[str], etag: str) -> bool:
+    """If-None-Match uses the weak comparison: W/"x" matches "x"."""
+    return "*" in tags or _opaque(etag) in (_opaque(tag) for tag in tags)
+
+
+def any_match(tags: List[str], etag: Optional[str]) -> bool:
+    """If-Match uses the strong comparison: weak tags never match."""
+    if etag is None:
+        return False
+    return "*" in tags or (not etag.startswith("W/") and etag in tags)
+
+
+class ConditionalRequestMiddleware:
+    """
+    Adds ETags to successful GET responses and answers a matching
+    If-None-Match with 304 Not Modified so clients can revalidate cached
+    reads cheaply. A resource with a `version` is tagged W/"<version>";
+    anything else gets a strong ETag hashed from the body. Responses that
+    set their own ETag keep it.
+
+    A PUT, PATCH or DELETE of a versioned resource names the version it is
+    based on, with If-Match: W/"<version>" or, for PUT and PATCH, a
+    `version` field in the body. The write then runs under
+    `expected_version` for the records in its path, so the repository
+    checks the version atomically with the write and a stale one is refused
+    with 409 (see app/repositories/base.py). A PUT or PATCH of a versioned
+    resource that names no version is refused with 428 Precondition
+    Required, so two coordinators cannot silently overwrite each other.
+
+    Any other If-Match is checked against the current representation, read
+    with a GET of the same path as the same caller; if the resource has
+    changed since the client read it (or is gone), the write is refused with
+    412 Precondition Failed. That check and the write are not atomic.
+    """
+
+    def __init__(self, app: ASGIApp, max_body_bytes: int = MAX_ETAG_BODY_BYTES):
+        self.app = app
+        self.max_body_bytes = max_body_bytes
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        if scope["type"] != "http":
+            await self.app(scope, receive, send)
+            return
+        headers = Headers(scope=scope)
+        if scope["method"] == "GET":
+            await self._tagged(scope, receive, send, parse_etags(headers.get("if-none-match")))
+            return
+        if scope["method"] not in WRITE_METHODS:
+            await self.app(scope, receive, send)
+            return
+
+        if_match = parse_etags(headers.get("if-match"))
+        version = tag_version(if_match)
+        if if_match and version is None:
+            refusal = await self._check_precondition(scope, if_match)
+            if refusal:
+                await refusal(scope, receive, send)
+                return
+            await self.app(scope, receive, send)
+            return
+
+        route = matched_route(scope)
+        if version is None and scope["method"] in VERSIONED_METHODS and _versioned(route):
+            body = await _read_body(receive)
+            version = body_version(body)
+            receive = _replay(body, receive)
+            if version is None:
+                logging.info(f"Refused {scope['method']} {scope['path']}: no version named")
+                response = problem_response(
+                    428,
+                    f'Send the version the update is based on, as If-Match: W/"<version>" or a `{VERSION_FIELD}` field',
+                    type_=problem_type("precondition-required"),
+                )
+                await response(scope, receive, send)
+                return
+        if version is None or route is None:
+            await self.app(scope, receive, send)
+            return
+        with expected_version(_record_ids(route, scope), version):
+            await self.app(scope, receive, send)
+
+    async def _tagged(self, scope: Scope, receive: Receive, send: Send, if_none_match: List[str]) -> None:
+        start: Optional[Message] = None
+        buffered: List[bytes] = []
+        size = 0
+        passthrough = False
+
+        async def flush_buffer():
+            await send(start)
+            for index, chunk in enumerate(buffered):
+                await send({"type": "http.response.body", "body": chunk, "more_body": index < len(buffered) - 1})
+
+        async def send_tagged(message: Message):
+            nonlocal start, size, passthrough
+            if message["type"] == "http.response.start":
+                headers = Headers(raw=message.get("headers", []))
+                media_type = (headers.get("content-type") or "").split(";", 1)[0].strip().lower()
+                passthrough = message["status"] != 200 or "etag" in headers or media_type in UNTAGGED_TYPES
+                if passthrough:
+                    await send(message)
+                else:
+                    start = message
+                return
+            if message["type"] != "http.response.body" or passthrough:
+                await send(message)
+                return
+
+            buffered.append(message.get("body", b""))
+            size += len(buffered[-1])
+            if size > self.max_body_bytes:
+                passthrough = True
+                await send(start)
+                for chunk in buffered[:-1]:
+                    await send({"type": "http.response.body", "body": chunk, "more_body": True})
+                await send(message)
+                return
+            if message.get("more_body"):
+                return
+
+            body = b"".join(buffered)
+            version = body_version(body)
+            etag = version_etag(version) if version is not None else body_etag(body)
+            response_headers = MutableHeaders(scope=start)
+            response_headers["ETag"] = etag
+            if if_none_match and none_match(if_none_match, etag):
+                kept = [(name, value) for name, value in start["headers"] if name in (b"etag", b"cache-control", b"vary")]
+                await send({"type": "http.response.start", "status": 304, "headers": kept})
+                await send({"type": "http.response.body", "body": b"", "more_body": False})
+                return
+            await flush_buffer()
+
+        await self.app(scope, receive, send_tagged)
+
+    async def _current_etag(self, scope: Scope) -> Tuple[int, Optional[str]]:
+        """Reads the resource with a GET as the same caller; returns its status and ETag."""
+        read_scope = {
+            **scope,
+            "method": "GET",
+            "headers": [(name, value) for name, value in scope["headers"] if name not in (b"if-match", b"if-none-match", b"content-length", b"content-type")],
+            "state": dict(scope.get("state", {})),
+        }
+        status_code = 500
+        etag: Optional[str] = None
+
+        async def receive() -> Message:
+            return {"type": "http.request", "body": b"", "more_body": False}
+
+        async def capture(message: Message):
+            nonlocal status_code, etag
+            if message["type"] == "http.response.start":
+                status_code = message["status"]
+                etag = Headers(raw=message.get("headers", [])).get("etag")
+
+        await self._tagged(read_scope, receive, capture, [])
+        return status_code, etag
+
+    async def _check_precondition(self, scope: Scope, if_match: List[str]) -> Optional[ProblemResponse]:
+        status_code, etag = await self._current_etag(scope)
+        if status_code == 200 and any_match(if_match, etag):
+            return None
+        if status_code not in (200, 404):
+            # Let the write itself answer, e.g. with 401, 403 or 405.
+            return None
+        logging.info(f"Refused {scope['method']} {scope['path']}: If-Match {if_match} does not match {etag}")
+        return problem_response(
+            412,
+            "The resource has changed since it was read; fetch it again before updating",
+            headers={"ETag": etag} if etag else None,
+        )
+
+
+def _versioned(route: Any) -> bool:
+    """Whether the route responds with a resource that has a version."""
+    return VERSION_FIELD in getattr(getattr(route, "response_model", None), "model_fields", {})
+
+
+def _record_ids(route: Any, scope: Scope) -> FrozenSet[str]:
+    """The IDs in the request's path; the write is to one of them, e.g. the care plan of a goal."""
+    _, child_scope = route.matches(scope)
+    return frozenset(str(value) for value in child_scope.get("path_params", {}).values())
+
+
+async def _read_body(receive: Receive) -> bytes:
+    chunks: List[bytes] = []
+    while True:
+        message = await receive()
+        if message["type"] != "http.request":
+            break
+        chunks.append(message.get("body", b""))
+        if not message.get("more_body"):
+            break
+    return b"".join(chunks)
+
+
+def _replay(body: bytes, receive: Receive) -> Receive:
+    """A receive that sends the body already read, then defers to the original."""
+    sent = False
+
+    async def replay() -> Message:
+        nonlocal sent
+        if sent:
+            return await receive()
+        sent = True
+        return {"type": "http.request", "body": body, "more_body": False}
+
+    return replay
@@ -0,0 +1,213 @@
+# Location: app/middleware/idempotency.py
+
+import base64
+import hashlib
+import logging
+from typing import Callable, Dict, List, Optional, Sequence
+
+from starlette.concurrency import run_in_threadpool
+from starlette.datastructures import Headers
+from starlette.responses import Response
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from app.api.versioning import version_prefixes
+from app.core.config import get_settings
+from app.crypto.envelope import get_field_cipher
+from app.errors.problems import problem_response, problem_type
+from app.middleware.metrics import UNMATCHED_ROUTE, route_template
+from app.middleware.rate_limit import caller_key
+from app.repositories.idempotency import IdempotencyRepository
+
+IDEMPOTENCY_HEADER = "Idempotency-Key"
+REPLAYED_HEADER = "Idempotent-Replayed"
+KEYED_METHODS = ("POST",)
+MAX_KEY_LENGTH = 255
+
+# How long a request holds its key before a retry may take over, assuming
+# the worker running it died.
+PROCESSING_LEASE_SECONDS = 60
+
+# Responses larger than this are not kept; retries of them run again.
+MAX_STORED_BODY_BYTES = 256 * 1024
+
+# Headers recomputed when a stored response is replayed.
+_UNSTORED_HEADERS = ("content-length",)
+
+# Routes, under a version prefix, whose responses hand out credentials:
+# session tokens, recovery codes, API keys, webhook signing secrets and
+# telehealth join tokens. They are never stored, so keys on them are
+# ignored and retries run again.
+CREDENTIAL_ROUTES = ("/auth", "/admin/api-keys", "/webhooks", "/telehealth/sessions/{telehealthSessionId}/tokens")
+
+
+def _default_repository() -> IdempotencyRepository:
+    from app.api.v1.deps import get_idempotency_repository
+    return get_idempotency_repository()
+
+
+def idempotency_record_id(principal: str, route: str, key: str) -> str:
+    """The record of a key: keys are scoped to the caller and the route, so they never collide across them."""
+    return hashlib.sha256(f"{principal}\n{route}\n{key}".encode()).hexdigest()
+
+
+def _problem(status_code: int, detail: str, name: str, headers: Optional[Dict[str, str]] = None):
+    return problem_response(status_code, detail, headers=headers, type_=problem_type(name))
+
+
+class IdempotencyMiddleware:
+    """
+    Makes /api POSTs sent with an Idempotency-Key safe to retry. The first
+    request with a key runs and its response is stored, keyed by the
+    caller (see `caller_key`), the route and the key, for
+    IDEMPOTENCY_TTL_SECONDS; retries get that response replayed, marked
+    `Idempotent-Replayed: true`, without running the request again, so a
+    booking retried after a dropped connection is made once.
+
+    A retry while the first request is still running gets 409 with
+    Retry-After; a key reused with a different body gets 422. Responses
+    with a 5xx status, or too large to keep, are not stored, so their
+    retries run again. If the store is unavailable the request runs
+    unprotected rather than failing.
+
+    Stored bodies may hold PHI, so they are sealed with field encryption
+    (see app/crypto) and not kept at all without FIELD_ENCRYPTION_KEY.
+    Keys on CREDENTIAL_ROUTES are ignored.
+
+    Inside authentication so keys are scoped to the verified caller, and
+    inside compression so stored bodies are uncompressed.
+    """
+
+    def __init__(
+        self,
+        app: ASGIApp,
+        repository_factory: Optional[Callable[[], IdempotencyRepository]] = None,
+        ttl_seconds: Optional[float] = None,
+        prefixes: Optional[Sequence[str]] = None,
+    ):
+        self.app = app
+        self.repository_factory = repository_factory or _default_repository
+        self.ttl_seconds = ttl_seconds or get_settings().idempotency_ttl_seconds
+        self.prefixes = tuple(prefixes or version_prefixes())
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        path = scope.get("path", "")
+        key = Headers(scope=scope).get(IDEMPOTENCY_HEADER) if scope["type"] == "http" else None
+        if (
+            key is None
+            or scope["method"] not in KEYED_METHODS
+            or not any(path == p or path.startswith(p + "/") for p in self.prefixes)
+        ):
+            await self.app(scope, receive, send)
+            return
+        if not key or len(key) > MAX_KEY_LENGTH:
+            response = _problem(400, f"{IDEMPOTENCY_HEADER} must be 1 to {MAX_KEY_LENGTH} characters", "invalid-idempotency-key")
+            await response(scope, receive, send)
+            return
+
+        template = route_template(scope)
+        route = f"{scope['method']} {template}"
+        if route.endswith(UNMATCHED_ROUTE) or self._issues_credentials(template):
+            await self.app(scope, receive, send)
+            return
+
+        body = await self._read_body(receive)
+        fingerprint = hashlib.sha256(body).hexdigest()
+        principal = caller_key(scope)
+        record_id = idempotency_record_id(principal, route, key)
+
+        body_sent = False
+
+        async def replay_receive() -> Message:
+            nonlocal body_sent
+            if body_sent:
+                return await receive()
+            body_sent = True
+            return {"type": "http.request", "body": body, "more_body": False}
+
+        try:
+            repository = self.repository_factory()
+            existing = await run_in_threadpool(
+                repository.claim, record_id, principal, route, key, fingerprint, PROCESSING_LEASE_SECONDS,
+            )
+        except Exception:
+            logging.exception(f"Idempotency store unavailable; running {route} without replay protection", extra={"report_error": True})
+            await self.app(scope, replay_receive, send)
+            return
+
+        if existing is not None:
+            if existing.fingerprint != fingerprint:
+                response = _problem(422, f"{IDEMPOTENCY_HEADER} '{key}' was already used with a different request body", "idempotency-key-reused")
+            elif existing.status == "processing":
+                response = _problem(409, f"A request with {IDEMPOTENCY_HEADER} '{key}' is still in progress", "request-in-progress", {"Retry-After": "1"})
+            else:
+                logging.info(f"Replaying {route} for {IDEMPOTENCY_HEADER} '{key}'")
+                response = Response(
+                    base64.b64decode(existing.response_body or ""),
+                    status_code=existing.response_status,
+                    headers={**existing.response_headers, REPLAYED_HEADER: "true"},
+                )
+            await response(scope, receive, send)
+            return
+
+        await self._run(scope, replay_receive, send, repository, record_id)
+
+    def _issues_credentials(self, template: str) -> bool:
+        for prefix in self.prefixes:
+            if template.startswith(prefix + "/"):
+                rest = template[len(prefix):]
+                return any(rest == route or rest.startswith(route + "/") for route in CREDENTIAL_ROUTES)
+        return False
+
+    @staticmethod
+    async def _read_body(receive: Receive) -> bytes:
+        chunks: List[bytes] = []
+        while True:
+            message = await receive()
+            if message["type"] != "http.request":
+                break
+            chunks.append(message.get("body", b""))
+            if not message.get("more_body"):
+                break
+        return b"".join(chunks)
+
+    async def _run(self, scope: Scope, receive: Receive, send: Send, repository: IdempotencyRepository, record_id: str):
+        status_code = 500
+        headers: Dict[str, str] = {}
+        chunks: List[bytes] = []
+        size = 0
+
+        async def send_wrapper(message: Message):
+            nonlocal status_code, headers, size
+            if message["type"] == "http.response.start":
+                status_code = message["status"]
+                headers = {
+                    name.decode("latin-1"): value.decode("latin-1")
+                    for name, value in message.get("headers", [])
+                    if name.decode("latin-1") not in _UNSTORED_HEADERS
+                }
+            elif message["type"] == "http.response.body" and size <= MAX_STORED_BODY_BYTES:
+                chunks.append(message.get("body", b""))
+                size += len(chunks[-1])
+            await send(message)
+
+        try:
+            await self.app(scope, receive, send_wrapper)
+        except BaseException:
+            await self._release(repository, record_id)
+            raise
+        if status_code >= 500 or size > MAX_STORED_BODY_BYTES or get_field_cipher() is None:
+            await self._release(repository, record_id)
+            return
+        try:
+            body = base64.b64encode(b"".join(chunks)).decode()
+            await run_in_threadpool(repository.complete, record_id, status_code, headers, body, self.ttl_seconds)
+        except Exception:
+            logging.exception(f"Failed to store the response of {scope['path']}; its retries will run again", extra={"report_error": True})
+            await self._release(repository, record_id)
+
+    @staticmethod
+    async def _release(repository: IdempotencyRepository, record_id: str):
+        try:
+            await run_in_threadpool(repository.release, record_id)
+        except Exception:
+            logging.exception(f"Failed to release idempotency record {record_id}", extra={"report_error": True})
@@ -0,0 +1,120 @@
+# Location: app/middleware/ip_allowlist.py
+
+import logging
+import threading
+import time
+from typing import Callable, Dict, List, Optional, Sequence, Tuple
+
+from starlette.concurrency import run_in_threadpool
+from starlette.types import ASGIApp, Receive, Scope, Send
+
+from app.api.versioning import version_prefixes
+from app.authz.networks import Network, address_allowed, client_address, parse_ranges
+from app.core.config import get_settings
+from app.errors.problems import problem_response, problem_type
+from app.tenancy.context import claimed_organization, current_tenant
+
+# Stands for the mount point of every served API version in a prefix, so
+# "{api}/admin" covers /api/v1/admin and the admin API of later versions.
+API_PLACEHOLDER = "{api}"
+
+# Returns an organization's own ranges, or None if it does not exist.
+RangesLookup = Callable[[str], Optional[List[str]]]
+
+
+def organization_ranges(organization_id: str) -> Optional[List[str]]:
+    from app.api.v1.deps import get_organization_repository
+    organization = get_organization_repository().get(organization_id)
+    return organization.allowed_source_ranges if organization else None
+
+
+class SourceRangeCache:
+    """Each organization's parsed ranges, kept for `ttl_seconds` so admin requests do not each read the organization."""
+
+    def __init__(self, lookup: RangesLookup, ttl_seconds: float, clock: Callable[[], float] = time.monotonic):
+        self.lookup = lookup
+        self.ttl_seconds = ttl_seconds
+        self.clock = clock
+        self._entries: Dict[str, Tuple[float, List[Network]]] = {}
+        self._lock = threading.Lock()
+
+    def get(self, organization_id: str) -> List[Network]:
+        now = self.clock()
+        with self._lock:
+            entry = self._entries.get(organization_id)
+        if entry and now - entry[0] < self.ttl_seconds:
+            return entry[1]
+        # Ranges were validated when stored.
+        ranges = parse_ranges(self.lookup(organization_id) or [])
+        with self._lock:
+            self._entries[organization_id] = (now, ranges)
+        return ranges
+
+
+class SourceAddressAllowlistMiddleware:
+    """
+    Refuses requests under `prefixes` (by default the admin API of every
+    version and the HL7 v2 interface partners send to) with 403 unless they come from an address in the
+    allowed ranges: those of the organization the request acts for (its
+    `allowedSourceRanges`), or `default_ranges` when it has none or the
+    request acts for no organization. With no ranges at all, any address
+    is allowed. The address is read from X-Forwarded-For as appended by the
+    `trusted_proxy_hops` proxies in front of the API (see client_address),
+    so a client cannot choose it. Provider callbacks under /integrations
+    are left out by default: they come from the providers' networks and
+    are authenticated by their signatures.
+    """
+
+    def __init__(
+        self,
+        app: ASGIApp,
+        prefixes: Optional[Sequence[str]] = None,
+        default_ranges: Optional[Sequence[str]] = None,
+        trusted_proxy_hops: Optional[int] = None,
+        cache: Optional[SourceRangeCache] = None,
+    ):
+        settings = get_settings()
+        self.app = app
+        self.prefixes = tuple(prefixes if prefixes is not None else settings.allowlist_prefixes)
+        self.default_ranges = parse_ranges(default_ranges if default_ranges is not None else settings.allowlist_default_ranges)
+        self.trusted_proxy_hops = trusted_proxy_hops if trusted_proxy_hops is not None else settings.trusted_proxy_hops
+        self.cache = cache or SourceRangeCache(organization_ranges, settings.ip_allowlist_cache_seconds)
+
+    def _expanded_prefixes(self) -> List[str]:
+        # Expanded per request, as the served versions are read at runtime.
+        prefixes = []
+        for prefix in self.prefixes:
+            if prefix.startswith(API_PLACEHOLDER):
+                prefixes += [mount + prefix[len(API_PLACEHOLDER):] for mount in version_prefixes()]
+            else:
+                prefixes.append(prefix)
+        return prefixes
+
+    def _restricted(self, path: str) -> bool:
+        return any(path == prefix or path.startswith(prefix + "/") for prefix in self._expanded_prefixes())
+
+    @staticmethod
+    def _organization(scope: Scope) -> Optional[str]:
+        """The tenant set by TenancyMiddleware, else the one the verified caller belongs to."""
+        principal = (scope.get("state") or {}).get("principal") or {}
+        return current_tenant() or claimed_organization(principal)
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        if scope["type"] != "http" or scope["method"] == "OPTIONS" or not self._restricted(scope["path"]):
+            await self.app(scope, receive, send)
+            return
+
+        organization = self._organization(scope)
+        ranges = await run_in_threadpool(self.cache.get, organization) if organization else []
+        ranges = ranges or self.default_ranges
+        if not ranges:
+            await self.app(scope, receive, send)
+            return
+
+        address = client_address(scope, self.trusted_proxy_hops)
+        if not address_allowed(address, ranges):
+            logging.warning(f"Refused {scope['method']} {scope['path']} from {address or 'an unknown address'} outside the allowed ranges of {organization or 'the deployment'}")
+            response = problem_response(403, "Requests to this endpoint are not allowed from your network", type_=problem_type("source-address-forbidden"))
+            await response(scope, receive, send)
+            return
+        await self.app(scope, receive, send)
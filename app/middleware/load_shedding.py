@@ -0,0 +1,76 @@
+# Location: app/middleware/load_shedding.py
+
+import logging
+import time
+from typing import Optional, Sequence
+
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from app.core.config import get_settings
+from app.core.metrics import CONCURRENCY_LIMIT, SHED_REQUESTS_TOTAL
+from app.errors.problems import problem_response
+from app.ratelimit.concurrency import AdaptiveConcurrencyLimiter
+
+# Probes must answer while the worker is busy, or Cloud Run restarts it;
+# long-lived streams would hold a slot and skew the latency for their
+# whole duration.
+DEFAULT_EXEMPT_PREFIXES = ("/healthz", "/readyz", "/metrics", "/api/v1/events/stream")
+
+
+class LoadSheddingMiddleware:
+    """
+    Refuses requests with 503 and Retry-After, before they are
+    authenticated or reach a handler, while the worker already has as many
+    in flight as the limiter admits (see AdaptiveConcurrencyLimiter). Latency
+    is measured to the start of the response, so streamed bodies do not
+    count against it. Routes under `exempt_prefixes` are never refused.
+    """
+
+    def __init__(
+        self,
+        app: ASGIApp,
+        limiter: Optional[AdaptiveConcurrencyLimiter] = None,
+        exempt_prefixes: Sequence[str] = DEFAULT_EXEMPT_PREFIXES,
+        retry_after_seconds: Optional[int] = None,
+    ):
+        settings = get_settings()
+        self.app = app
+        self.limiter = limiter or AdaptiveConcurrencyLimiter(
+            settings.load_shedding_min_concurrency,
+            settings.load_shedding_max_concurrency,
+            settings.load_shedding_latency_target_ms / 1000,
+        )
+        self.exempt_prefixes = tuple(exempt_prefixes)
+        self.retry_after_seconds = retry_after_seconds or settings.load_shedding_retry_after_seconds
+        CONCURRENCY_LIMIT.set(self.limiter.limit)
+
+    def _exempt(self, path: str) -> bool:
+        return any(path == prefix or path.startswith(prefix + "/") for prefix in self.exempt_prefixes)
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        if scope["type"] != "http" or scope["method"] == "OPTIONS" or self._exempt(scope.get("path", "")):
+            await self.app(scope, receive, send)
+            return
+
+        if not self.limiter.try_acquire():
+            logging.warning(f"Shed {scope['method']} {scope['path']}: {self.limiter.in_flight} requests in flight, limit {self.limiter.limit}")
+            SHED_REQUESTS_TOTAL.labels(reason="concurrency").inc()
+            CONCURRENCY_LIMIT.set(self.limiter.limit)
+            response = problem_response(503, "The server is overloaded; retry later", headers={"Retry-After": str(self.retry_after_seconds)})
+            await response(scope, receive, send)
+            return
+
+        started = time.perf_counter()
+        latency: Optional[float] = None
+
+        async def send_wrapper(message: Message):
+            nonlocal latency
+            if message["type"] == "http.response.start" and latency is None:
+                latency = time.perf_counter() - started
+            await send(message)
+
+        try:
+            await self.app(scope, receive, send_wrapper)
+        finally:
+            self.limiter.release(latency if latency is not None else time.perf_counter() - started)
+            CONCURRENCY_LIMIT.set(self.limiter.limit)
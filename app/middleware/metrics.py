@@ -0,0 +1,72 @@
+# Location: app/middleware/metrics.py
+
+import time
+from typing import Optional
+
+from starlette.routing import BaseRoute, Match
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from app.core.metrics import (
+    HTTP_REQUEST_DURATION_SECONDS,
+    HTTP_REQUESTS_IN_FLIGHT,
+    HTTP_REQUESTS_TOTAL,
+    HTTP_RESPONSE_SIZE_BYTES,
+)
+
+UNMATCHED_ROUTE = "<unmatched>"
+
+
+def matched_route(scope: Scope) -> Optional[BaseRoute]:
+    """The application route that handles this request, if any."""
+    app = scope.get("app")
+    for route in getattr(app, "routes", []):
+        match, _ = route.matches(scope)
+        if match == Match.FULL:
+            return route
+    return None
+
+
+def route_template(scope: Scope) -> str:
+    """
+    Returns the path template of the route that handles this request, so metrics
+    are partitioned by endpoint rather than by every distinct patient ID.
+    """
+    return getattr(matched_route(scope), "path", UNMATCHED_ROUTE)
+
+
+class MetricsMiddleware:
+    """Records request count, latency, in-flight requests and response size."""
+
+    def __init__(self, app: ASGIApp):
+        self.app = app
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        if scope["type"] != "http":
+            await self.app(scope, receive, send)
+            return
+
+        status_code = 500
+        response_size = 0
+
+        async def send_wrapper(message: Message):
+            nonlocal status_code, response_size
+            if message["type"] == "http.response.start":
+                status_code = message["status"]
+            elif message["type"] == "http.response.body":
+                response_size += len(message.get("body", b""))
+            await send(message)
+
+        HTTP_REQUESTS_IN_FLIGHT.inc()
+        started = time.perf_counter()
+        try:
+            await self.app(scope, receive, send_wrapper)
+        finally:
+            HTTP_REQUESTS_IN_FLIGHT.dec()
+            labels = {
+                "method": scope["method"],
+                "route": route_template(scope),
+                "status": str(status_code),
+            }
+            HTTP_REQUESTS_TOTAL.labels(**labels).inc()
+            HTTP_REQUEST_DURATION_SECONDS.labels(**labels).observe(time.perf_counter() - started)
+            HTTP_RESPONSE_SIZE_BYTES.labels(**labels).observe(response_size)
@@ -0,0 +1,69 @@
+# Location: app/middleware/rate_limit.py
+
+import logging
+from typing import Callable, Dict, List, Optional
+
+from starlette.concurrency import run_in_threadpool
+from starlette.types import ASGIApp, Receive, Scope, Send
+
+from app.authz.networks import client_address
+from app.core.config import get_settings
+from app.core.metrics import RATE_LIMITED_REQUESTS_TOTAL
+from app.errors.problems import problem_response
+from app.ratelimit.buckets import RateLimitRule, TokenBuckets, get_token_buckets, match_rule, parse_rules
+
+
+def caller_key(scope: Scope) -> str:
+    """
+    Whose quota a request counts against: the API key or user verified by
+    `AuthenticationMiddleware`, the calling service verified by
+    `ServiceAuthenticationMiddleware`, otherwise the client address as
+    seen by the outermost trusted proxy (TRUSTED_PROXY_HOPS), so a client
+    cannot pick its own quota with X-Forwarded-For.
+    """
+    state = scope.get("state", {})
+    principal: Optional[Dict] = state.get("principal")
+    if principal and principal.get("apiKeyId"):
+        return f"key:{principal['apiKeyId']}"
+    if principal and principal.get("uid"):
+        return f"user:{principal['uid']}"
+    if state.get("service_caller"):
+        return f"service:{state['service_caller']['email']}"
+    return f"ip:{client_address(scope, get_settings().trusted_proxy_hops) or 'unknown'}"
+
+
+class RateLimitMiddleware:
+    """
+    Limits each caller (see `caller_key`) to the rule of the route group a
+    request falls in (RATE_LIMIT_RULES), with a token bucket per caller and
+    group. Requests over the limit get 429 with Retry-After. Routes matched
+    by no rule, such as the health probes, are not limited.
+    """
+
+    def __init__(
+        self,
+        app: ASGIApp,
+        rules: Optional[List[RateLimitRule]] = None,
+        buckets_factory: Callable[[], TokenBuckets] = get_token_buckets,
+    ):
+        self.app = app
+        self.rules = rules if rules is not None else parse_rules(get_settings().rate_limit_rules)
+        self.buckets_factory = buckets_factory
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        rule = match_rule(self.rules, scope.get("path", "")) if scope["type"] == "http" and scope["method"] != "OPTIONS" else None
+        if not rule:
+            await self.app(scope, receive, send)
+            return
+
+        key = caller_key(scope)
+        allowed, retry_after = await run_in_threadpool(
+            self.buckets_factory().take, f"{rule.prefix}|{key}", rule.burst, rule.refill_per_second,
+        )
+        if not allowed:
+            logging.info(f"Rate limited {scope['method']} {scope['path']} for {key}")
+            RATE_LIMITED_REQUESTS_TOTAL.labels(route_group=rule.prefix).inc()
+            response = problem_response(429, "Rate limit exceeded", headers={"Retry-After": str(max(retry_after, 1))})
+            await response(scope, receive, send)
+            return
+        await self.app(scope, receive, send)
@@ -0,0 +1,66 @@
+# Location: app/middleware/recovery.py
+
+import json
+import logging
+
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from app.errors.problems import PROBLEM_JSON, problem_body
+
+logger = logging.getLogger(__name__)
+
+
+class RecoveryMiddleware:
+    """
+    Catches any exception that escapes a handler and turns it into a sanitized
+    `application/problem+json` 500 response.
+
+    The exception is logged with its full stack trace and flagged for Google
+    Cloud Error Reporting (see `report_error` in CloudLoggingFormatter); the
+    client only receives a generic message plus the request ID needed to find
+    that log entry.
+    """
+
+    def __init__(self, app: ASGIApp):
+        self.app = app
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        if scope["type"] != "http":
+            await self.app(scope, receive, send)
+            return
+
+        response_started = False
+
+        async def send_wrapper(message: Message):
+            nonlocal response_started
+            if message["type"] == "http.response.start":
+                response_started = True
+            await send(message)
+
+        try:
+            await self.app(scope, receive, send_wrapper)
+        except Exception as exc:
+            method = scope.get("method")
+            path = scope.get("path")
+            logger.exception(
+                f"Unhandled exception while processing {method} {path}: {exc!r}",
+                extra={
+                    "report_error": True,
+                    "http_request": {"requestMethod": method, "requestUrl": path},
+                },
+            )
+            if response_started:
+                # Headers are already on the wire; let the server abort the connection.
+                raise
+
+            body = problem_body(500, "An unexpected error occurred. Please try again later.", instance=path)
+            content = json.dumps(body).encode("utf-8")
+            await send({
+                "type": "http.response.start",
+                "status": 500,
+                "headers": [
+                    (b"content-type", PROBLEM_JSON.encode("latin-1")),
+                    (b"content-length", str(len(content)).encode("latin-1")),
+                ],
+            })
+            await send({"type": "http.response.body", "body": content})
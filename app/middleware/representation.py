@@ -0,0 +1,211 @@
+# Location: app/middleware/representation.py
+
+import json
+import logging
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+
+from starlette.datastructures import Headers, MutableHeaders, QueryParams
+from starlette.requests import Request
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from app.api.representations import (
+    CSV,
+    FIELDS_PARAM,
+    FORMAT_PARAM,
+    JSON,
+    NDJSON,
+    Representable,
+    csv_lines,
+    negotiate,
+    representable,
+    select_columns,
+    to_fhir,
+)
+from app.api.versioning import version_prefixes
+from app.errors.problems import problem_response
+from app.fhir.bulk_export import NDJSON as FHIR_NDJSON
+from app.fhir.responses import FHIR_JSON, fhir_base_url, search_bundle
+from app.middleware.metrics import matched_route
+
+# Response headers that describe the JSON body and are replaced with the
+# rendered body's own.
+_BODY_HEADERS = (b"content-length", b"content-type", b"etag")
+
+
+async def _no_body() -> Message:
+    return {"type": "http.request", "body": b"", "more_body": False}
+
+
+class RepresentationMiddleware:
+    """
+    Renders /api responses in the media type the client asks for with
+    Accept: the handler's JSON as is, FHIR resources (application/fhir+json)
+    for patients and observations, or NDJSON (application/x-ndjson, or
+    application/fhir+ndjson of FHIR resources) and CSV (text/csv, or
+    `?format=csv` for links opened in a browser) for list endpoints. A
+    single FHIR resource is sent as it stands, a list as a searchset
+    Bundle; a paged list as a Bundle of the page with a `next` link. CSV
+    has a column per field, or those named in `?fields=`, and is sent as an
+    attachment.
+
+    NDJSON and CSV of a paged list stream every page, so bulk readers need
+    no cursor handling: the route is called again with each `next_cursor`
+    and items are written as they arrive. A page that fails after the
+    stream has started aborts the response rather than ending it short.
+
+    Clients that accept none of a route's media types get its JSON, so an
+    unusual Accept header does not break existing integrations. Errors are
+    never converted. Inside the conditional request middleware, so ETags
+    are those of the rendered body.
+    """
+
+    def __init__(self, app: ASGIApp, prefixes: Optional[Sequence[str]] = None):
+        self.app = app
+        self.prefixes = tuple(prefixes or version_prefixes())
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        path = scope.get("path", "")
+        if scope["type"] != "http" or not any(path == p or path.startswith(p + "/") for p in self.prefixes):
+            await self.app(scope, receive, send)
+            return
+
+        accept = Headers(scope=scope).get("accept")
+        params = QueryParams(scope.get("query_string", b""))
+        target: Optional[Representable] = None
+        media_type = JSON
+        if params.get(FORMAT_PARAM) == "csv":
+            # Routes with a `format` parameter of their own, such as the reports, get it themselves.
+            target = representable(getattr(matched_route(scope), "response_model", None))
+            if target and target.collection:
+                media_type = CSV
+        elif accept and negotiate(accept, (JSON, FHIR_JSON, NDJSON, FHIR_NDJSON, CSV)) not in (JSON, None):
+            target = representable(getattr(matched_route(scope), "response_model", None))
+            if target:
+                media_type = negotiate(accept, target.media_types()) or JSON
+        if media_type == CSV and scope["method"] != "GET":
+            media_type = JSON
+
+        columns: List[str] = []
+        if media_type == CSV:
+            try:
+                columns = select_columns(target.model, params.get(FIELDS_PARAM))
+            except ValueError as e:
+                await problem_response(400, str(e))(scope, receive, send)
+                return
+
+        if media_type == JSON:
+            async def send_varied(message: Message):
+                if message["type"] == "http.response.start":
+                    MutableHeaders(scope=message).add_vary_header("Accept")
+                await send(message)
+
+            await self.app(scope, receive, send_varied)
+        elif media_type in (NDJSON, FHIR_NDJSON, CSV) and scope["method"] == "GET":
+            await self._stream(scope, receive, send, target, media_type, columns)
+        else:
+            await self._render(scope, receive, send, target, media_type)
+
+    async def _capture(self, scope: Scope, receive: Receive) -> Tuple[Message, bytes]:
+        """Runs the route and returns its response start message and body."""
+        start: Message = {"type": "http.response.start", "status": 500, "headers": []}
+        chunks: List[bytes] = []
+
+        async def capture(message: Message):
+            nonlocal start
+            if message["type"] == "http.response.start":
+                start = message
+            elif message["type"] == "http.response.body":
+                chunks.append(message.get("body", b""))
+
+        await self.app(scope, receive, capture)
+        return start, b"".join(chunks)
+
+    @staticmethod
+    def _is_json(start: Message) -> bool:
+        content_type = Headers(raw=start.get("headers", [])).get("content-type") or ""
+        return 200 <= start["status"] < 300 and content_type.split(";", 1)[0].strip() == JSON
+
+    @staticmethod
+    def _start(start: Message, media_type: str, length: Optional[int] = None, filename: Optional[str] = None) -> Message:
+        headers = [(name, value) for name, value in start.get("headers", []) if name not in _BODY_HEADERS]
+        message = {"type": "http.response.start", "status": start["status"], "headers": headers}
+        mutable = MutableHeaders(scope=message)
+        mutable["Content-Type"] = f"{media_type}; charset=utf-8" if media_type == CSV else media_type
+        if filename:
+            mutable["Content-Disposition"] = f'attachment; filename="{filename}"'
+        if length is not None:
+            mutable["Content-Length"] = str(length)
+        mutable.add_vary_header("Accept")
+        return message
+
+    @staticmethod
+    async def _forward(start: Message, body: bytes, send: Send):
+        MutableHeaders(scope=start).add_vary_header("Accept")
+        await send(start)
+        await send({"type": "http.response.body", "body": body, "more_body": False})
+
+    async def _render(self, scope: Scope, receive: Receive, send: Send, target: Representable, media_type: str):
+        start, body = await self._capture(scope, receive)
+        if not self._is_json(start):
+            await self._forward(start, body, send)
+            return
+        data = json.loads(body)
+        if target.collection is None:
+            rendered = to_fhir(target.model, data)
+        else:
+            request = Request(scope)
+            items = data["items"] if target.collection == "page" else data
+            resources = [to_fhir(target.model, item) for item in items]
+            total = data.get("total") if target.collection == "page" else len(items)
+            rendered = search_bundle(fhir_base_url(request), str(request.url), resources, total)
+            if total is None:
+                rendered.pop("total")
+            if target.collection == "page" and data.get("next_cursor"):
+                rendered["link"].append({"relation": "next", "url": str(request.url.include_query_params(cursor=data["next_cursor"]))})
+        payload = json.dumps(rendered, separators=(",", ":")).encode()
+        await send(self._start(start, media_type, len(payload)))
+        await send({"type": "http.response.body", "body": payload, "more_body": False})
+
+    async def _stream(self, scope: Scope, receive: Receive, send: Send, target: Representable, media_type: str, columns: List[str]):
+        page_scope, page_receive = scope, receive
+        started = False
+        while True:
+            start, body = await self._capture(page_scope, page_receive)
+            if not self._is_json(start):
+                if not started:
+                    await self._forward(start, body, send)
+                    return
+                logging.error(f"NDJSON stream of {scope['path']} aborted: a later page failed with {start['status']}")
+                raise RuntimeError(f"page of {scope['path']} failed with {start['status']}")
+            data = json.loads(body)
+            items = data["items"] if target.collection == "page" else data
+            cursor = data.get("next_cursor") if target.collection == "page" else None
+            if media_type == CSV:
+                lines = csv_lines(target.model, columns, items, header=not started)
+            else:
+                lines = self._lines(target, media_type, items)
+            if not started:
+                await send(self._start(start, media_type, filename=self._filename(scope) if media_type == CSV else None))
+                started = True
+            await send({"type": "http.response.body", "body": lines, "more_body": bool(cursor)})
+            if not cursor:
+                return
+            page_scope = self._with_cursor(scope, cursor)
+            page_receive = _no_body
+
+    @staticmethod
+    def _lines(target: Representable, media_type: str, items: List[Dict[str, Any]]) -> bytes:
+        if media_type == FHIR_NDJSON:
+            items = [to_fhir(target.model, item) for item in items]
+        return b"".join(json.dumps(item, separators=(",", ":")).encode() + b"\n" for item in items)
+
+    @staticmethod
+    def _filename(scope: Scope) -> str:
+        """Named after the listed resource, e.g. appointments.csv for /api/v1/appointments."""
+        name = scope["path"].rstrip("/").rsplit("/", 1)[-1]
+        return f"{''.join(c for c in name if c.isalnum() or c in '-_') or 'export'}.csv"
+
+    @staticmethod
+    def _with_cursor(scope: Scope, cursor: str) -> Scope:
+        url = Request(scope).url.include_query_params(cursor=cursor)
+        return dict(scope, query_string=url.query.encode(), state=dict(scope.get("state", {})))
@@ -0,0 +1,67 @@
+# Location: app/middleware/request_context.py
+
+from starlette.datastructures import Headers, MutableHeaders
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from app.core.request_context import (
+    REQUEST_ID_HEADER,
+    TRACE_ID_HEADER,
+    parse_cloud_trace_context,
+    parse_traceparent,
+    request_id_var,
+    resolve_request_id,
+    span_id_var,
+    trace_id_var,
+)
+
+
+class RequestContextMiddleware:
+    """
+    Establishes the request ID and trace context for every HTTP request.
+
+    - The trace is taken from a W3C `traceparent` header, falling back to
+      Cloud Run's `X-Cloud-Trace-Context`.
+    - The request ID is taken from `X-Request-ID` if the caller supplied a
+      well-formed one, otherwise a new one is generated.
+
+    Both are stored in context variables (read by the logging filter) and
+    echoed back as `X-Request-ID` / `X-Trace-ID` response headers so a user
+    reported failure can be matched to its log entries.
+    """
+
+    def __init__(self, app: ASGIApp):
+        self.app = app
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        if scope["type"] != "http":
+            await self.app(scope, receive, send)
+            return
+
+        headers = Headers(scope=scope)
+        trace_id, span_id = parse_traceparent(headers.get("traceparent"))
+        if not trace_id:
+            trace_id, span_id = parse_cloud_trace_context(headers.get("x-cloud-trace-context"))
+        request_id = resolve_request_id(headers.get(REQUEST_ID_HEADER))
+
+        # Make the values available to handlers that prefer request.state.
+        scope.setdefault("state", {})
+        scope["state"]["request_id"] = request_id
+        scope["state"]["trace_id"] = trace_id
+
+        async def send_with_context_headers(message: Message):
+            if message["type"] == "http.response.start":
+                response_headers = MutableHeaders(scope=message)
+                response_headers[REQUEST_ID_HEADER] = request_id
+                if trace_id:
+                    response_headers[TRACE_ID_HEADER] = trace_id
+            await send(message)
+
+        request_token = request_id_var.set(request_id)
+        trace_token = trace_id_var.set(trace_id)
+        span_token = span_id_var.set(span_id)
+        try:
+            await self.app(scope, receive, send_with_context_headers)
+        finally:
+            request_id_var.reset(request_token)
+            trace_id_var.reset(trace_token)
+            span_id_var.reset(span_token)
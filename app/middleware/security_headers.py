@@ -0,0 +1,84 @@
+# Location: app/middleware/security_headers.py
+
+from typing import Optional, Sequence
+
+from starlette.datastructures import MutableHeaders
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from app.core.config import get_settings
+
+# API responses are data, never pages: nothing in them may load, run or be
+# framed.
+API_CONTENT_SECURITY_POLICY = "default-src 'none'; frame-ancestors 'none'"
+
+# Swagger UI, ReDoc and GraphiQL are pages served by FastAPI and Strawberry
+# that load their scripts and styles from public CDNs and start with an
+# inline script. ReDoc renders in a blob: worker.
+DOCS_CONTENT_SECURITY_POLICY = "; ".join([
+    "default-src 'none'",
+    "script-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net https://unpkg.com",
+    "style-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net https://unpkg.com https://fonts.googleapis.com",
+    "font-src https://fonts.gstatic.com",
+    "img-src 'self' data: https://fastapi.tiangolo.com https://cdn.redoc.ly",
+    "connect-src 'self'",
+    "worker-src blob:",
+    "frame-ancestors 'none'",
+])
+
+
+def _matches(path: str, prefixes: Sequence[str]) -> bool:
+    return any(prefix == "/" or path == prefix or path.startswith(prefix + "/") for prefix in prefixes)
+
+
+class SecurityHeadersMiddleware:
+    """
+    Adds browser security headers to every HTTP response:
+    - Strict-Transport-Security, so browsers only ever reach the API over HTTPS,
+    - X-Content-Type-Options: nosniff, so a JSON body is never run as script,
+    - Content-Security-Policy, the docs UIs' own under `docs_prefixes` and
+      one that allows nothing elsewhere,
+    - Referrer-Policy, so URLs holding patient IDs are not passed on,
+    - Cache-Control: no-store under `no_store_prefixes`, whose responses may
+      hold patient data that browsers and shared caches must not keep.
+    Headers a route set itself are kept, except Cache-Control under
+    `no_store_prefixes`.
+    """
+
+    def __init__(
+        self,
+        app: ASGIApp,
+        no_store_prefixes: Optional[Sequence[str]] = None,
+        docs_prefixes: Optional[Sequence[str]] = None,
+        hsts_max_age_seconds: Optional[int] = None,
+        referrer_policy: Optional[str] = None,
+    ):
+        settings = get_settings()
+        self.app = app
+        self.no_store_prefixes = tuple(no_store_prefixes if no_store_prefixes is not None else settings.no_store_prefixes)
+        self.docs_prefixes = tuple(docs_prefixes if docs_prefixes is not None else settings.docs_prefixes)
+        max_age = hsts_max_age_seconds if hsts_max_age_seconds is not None else settings.security_hsts_max_age_seconds
+        self.hsts = f"max-age={max_age}; includeSubDomains" if max_age else None
+        self.referrer_policy = referrer_policy or settings.security_referrer_policy
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        if scope["type"] != "http":
+            await self.app(scope, receive, send)
+            return
+
+        path = scope.get("path", "")
+        no_store = _matches(path, self.no_store_prefixes)
+        content_security_policy = DOCS_CONTENT_SECURITY_POLICY if _matches(path, self.docs_prefixes) else API_CONTENT_SECURITY_POLICY
+
+        async def send_wrapper(message: Message):
+            if message["type"] == "http.response.start":
+                headers = MutableHeaders(scope=message)
+                if self.hsts:
+                    headers.setdefault("Strict-Transport-Security", self.hsts)
+                headers.setdefault("X-Content-Type-Options", "nosniff")
+                headers.setdefault("Content-Security-Policy", content_security_policy)
+                headers.setdefault("Referrer-Policy", self.referrer_policy)
+                if no_store:
+                    headers["Cache-Control"] = "no-store"
+            await send(message)
+
+        await self.app(scope, receive, send_wrapper)
@@ -0,0 +1,77 @@
+# Location: app/middleware/service_authentication.py
+
+import logging
+from typing import Callable, Dict, Iterable, Optional
+
+from starlette.concurrency import run_in_threadpool
+from starlette.datastructures import Headers
+from starlette.types import ASGIApp, Receive, Scope, Send
+
+from app.audit.context import set_actor
+from app.auth.google_tokens import verify_google_id_token
+from app.core.config import get_settings
+from app.errors.problems import problem_response
+from app.middleware.authentication import bearer_token
+
+# Routes other MegaCare services call on their own behalf.
+SERVICE_PREFIX = "/internal/services"
+
+
+class ServiceAuthenticationMiddleware:
+    """
+    Admits requests under /internal/services only with a Google-signed ID
+    token for `audience` (SERVICE_AUTH_AUDIENCE) whose email is one of
+    `allowed_callers` (SERVICE_AUTH_ALLOWED_CALLERS); see
+    `app.auth.google_tokens.service_client` for the calling side. The
+    verified claims are put in `request.state.service_caller`. Without an
+    audience or callers configured, every such request is refused.
+    """
+
+    def __init__(
+        self,
+        app: ASGIApp,
+        audience: Optional[str] = None,
+        allowed_callers: Optional[Iterable[str]] = None,
+        verify: Callable[[str, str], Dict] = verify_google_id_token,
+        prefix: str = SERVICE_PREFIX,
+    ):
+        settings = get_settings()
+        self.app = app
+        self.audience = audience or settings.service_auth_audience
+        self.allowed_callers = frozenset(allowed_callers if allowed_callers is not None else settings.service_auth_callers)
+        self.verify = verify
+        self.prefix = prefix
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        path = scope.get("path", "") if scope["type"] == "http" else ""
+        if not (path == self.prefix or path.startswith(self.prefix + "/")):
+            await self.app(scope, receive, send)
+            return
+
+        if not self.audience or not self.allowed_callers:
+            await self._respond(scope, receive, send, 403, "No internal service callers are configured")
+            return
+        token = bearer_token(Headers(scope=scope).get("authorization"))
+        if not token:
+            await self._respond(scope, receive, send, 401, "Service identity token not provided")
+            return
+        try:
+            claims = await run_in_threadpool(self.verify, token, self.audience)
+        except Exception as e:
+            logging.info(f"Rejected service token for {scope['method']} {path}: {e}")
+            await self._respond(scope, receive, send, 401, f"Invalid service identity token: {e}")
+            return
+        if claims["email"] not in self.allowed_callers:
+            logging.warning(f"Refused {scope['method']} {path} from service account {claims['email']}")
+            await self._respond(scope, receive, send, 403, "This service account may not call internal services")
+            return
+
+        scope.setdefault("state", {})
+        scope["state"]["service_caller"] = claims
+        set_actor({"uid": claims["email"]})
+        await self.app(scope, receive, send)
+
+    async def _respond(self, scope: Scope, receive: Receive, send: Send, status_code: int, detail: str) -> None:
+        headers = {"WWW-Authenticate": "Bearer"} if status_code == 401 else None
+        response = problem_response(status_code, detail, headers=headers)
+        await response(scope, receive, send)
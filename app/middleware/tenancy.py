@@ -0,0 +1,133 @@
+# Location: app/middleware/tenancy.py
+
+import logging
+import re
+from typing import Callable, Optional, Sequence
+
+from starlette.concurrency import run_in_threadpool
+from starlette.datastructures import Headers
+from starlette.types import ASGIApp, Receive, Scope, Send
+
+from app.api.versioning import strip_version
+from app.audit.context import security_event, set_organization
+from app.auth.context import principal_var
+from app.auth.verifier import TokenVerifier, get_token_verifier
+from app.core.config import get_settings
+from app.errors.problems import problem_response, problem_type
+from app.fhir.responses import FHIR_BASE_PATH, operation_outcome
+from app.middleware.authentication import bearer_token
+from app.tenancy.context import ORGANIZATION_HEADER, acting_for, claimed_organization, is_platform_admin
+
+# FHIR, GraphQL and the HL7v2 integration verify bearer tokens in their
+# route dependencies rather than behind AuthenticationMiddleware; their
+# tokens are verified here so their records are scoped too.
+TOKEN_PREFIXES = (FHIR_BASE_PATH, "/graphql", "/integrations")
+
+_ORGANIZATION_PATH_RE = re.compile(r"^/organizations/([^/]+)")
+
+
+class TenancyMiddleware:
+    """
+    Sets the organization each authenticated request acts for (see
+    app/tenancy/context.py), which scopes every repository read and write
+    in the request to that organization's records.
+
+    For most callers the organization is their `organizationId` claim, or
+    DEFAULT_ORGANIZATION_ID; callers with neither are refused with 403.
+    Naming another organization, with the X-Organization-Id header or an
+    /organizations/{organizationId} path, is refused with a 403 "cross-tenant"
+    problem.
+
+    Platform administrators (`platform-admin` role) are not bound to their
+    claim: the header selects the organization they act for, and without it
+    they act for their claimed organization, or across all of them if they
+    have none. A request acting for any organization but their own is
+    logged, and its audit event is stored with that organization's and
+    marked with the "cross-tenant-access" security event, naming the
+    administrator as the actor.
+
+    Requests without a verified caller pass through unscoped, for their
+    route to reject or serve as public.
+    """
+
+    def __init__(
+        self,
+        app: ASGIApp,
+        verifier_factory: Callable[[], TokenVerifier] = get_token_verifier,
+        default_organization: Optional[str] = None,
+        token_prefixes: Sequence[str] = TOKEN_PREFIXES,
+    ):
+        self.app = app
+        self.verifier_factory = verifier_factory
+        self.default_organization = default_organization or get_settings().default_organization_id
+        self.token_prefixes = tuple(token_prefixes)
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        if scope["type"] != "http":
+            await self.app(scope, receive, send)
+            return
+
+        claims = (scope.get("state") or {}).get("principal")
+        context_token = None
+        if claims is None and self._verifies_token(scope["path"]):
+            claims = await self._verify(scope)
+            if claims is not None:
+                scope.setdefault("state", {})
+                scope["state"]["principal"] = claims
+                context_token = principal_var.set(claims)
+        try:
+            if claims is None:
+                await self.app(scope, receive, send)
+                return
+            await self._scoped(scope, receive, send, claims)
+        finally:
+            if context_token is not None:
+                principal_var.reset(context_token)
+
+    async def _scoped(self, scope: Scope, receive: Receive, send: Send, claims) -> None:
+        headers = Headers(scope=scope)
+        requested = headers.get(ORGANIZATION_HEADER) or None
+        tenant = claimed_organization(claims) or self.default_organization
+        if is_platform_admin(claims):
+            tenant = requested or claimed_organization(claims)
+            if tenant != claimed_organization(claims) or tenant is None:
+                logging.info(f"Platform administrator {claims.get('uid')} acting for {tenant or 'all organizations'} on {scope['method']} {scope['path']}")
+                security_event("cross-tenant-access")
+        elif tenant is None:
+            await self._refuse(scope, receive, send, "Your account is not assigned to an organization", "no-organization")
+            return
+        else:
+            named = [requested, self._path_organization(scope["path"])]
+            if any(organization and organization != tenant for organization in named):
+                logging.warning(f"Refused cross-tenant {scope['method']} {scope['path']} by {claims.get('uid')} of organization {tenant}")
+                await self._refuse(scope, receive, send, "Access to another organization's records is forbidden", "cross-tenant")
+                return
+
+        set_organization(tenant)
+        with acting_for(tenant):
+            await self.app(scope, receive, send)
+
+    def _verifies_token(self, path: str) -> bool:
+        return any(path == prefix or path.startswith(prefix + "/") for prefix in self.token_prefixes)
+
+    async def _verify(self, scope: Scope):
+        """The claims of the request's bearer token, or None; the route rejects missing or invalid tokens itself."""
+        token = bearer_token(Headers(scope=scope).get("authorization"))
+        if not token:
+            return None
+        try:
+            return await run_in_threadpool(self.verifier_factory().verify, token)
+        except Exception:
+            return None
+
+    @staticmethod
+    def _path_organization(path: str) -> Optional[str]:
+        match = _ORGANIZATION_PATH_RE.match(strip_version(path))
+        return match.group(1) if match else None
+
+    async def _refuse(self, scope: Scope, receive: Receive, send: Send, detail: str, problem: str) -> None:
+        if scope["path"] == FHIR_BASE_PATH or scope["path"].startswith(FHIR_BASE_PATH + "/"):
+            response = operation_outcome(403, "forbidden", detail)
+        else:
+            response = problem_response(403, detail, type_=problem_type(problem))
+        await response(scope, receive, send)
@@ -0,0 +1,110 @@
+# Location: app/middleware/versioning.py
+
+from typing import List, Optional, Tuple
+
+from starlette.datastructures import Headers, MutableHeaders
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from app.api.versioning import (
+    API_ROOT,
+    API_VERSIONS,
+    VERSION_HEADER,
+    ApiVersion,
+    deprecates_routes_in,
+    deprecation_headers,
+    normalize_version,
+    path_version,
+    requested_version,
+    route_deprecation,
+)
+from app.core.config import get_settings
+from app.errors.problems import problem_response, problem_type
+from app.middleware.metrics import route_template
+
+
+def _unsupported(status_code: int, version: str):
+    served = ", ".join(API_VERSIONS)
+    return problem_response(
+        status_code,
+        f"API version '{version}' is not served; use one of: {served}.",
+        title="Unsupported API Version",
+        type_=problem_type("unsupported-version"),
+    )
+
+
+class ApiVersionMiddleware:
+    """
+    Routes /api requests to an API version and labels responses with it.
+
+    Versioned paths (/api/v1/patients) are served by that version. An
+    unversioned path (/api/patients) is served by the version the client
+    negotiates with the Api-Version header or a `version` parameter of its
+    Accept media type, or else by API_DEFAULT_VERSION; the path is rewritten
+    before the inner middleware see it, so authentication, audit and metrics
+    only ever see versioned routes. Versions that are not served are refused
+    as unsupported-version problems: 404 when named in the path, 400 when
+    negotiated.
+
+    Every /api response carries the Api-Version that served it. Responses of
+    a deprecated version, or of a route in DEPRECATED_ROUTES, also carry
+    Deprecation and Sunset headers and a successor-version Link, so
+    integrators can find calls to migrate before they stop working.
+    """
+
+    def __init__(self, app: ASGIApp, default_version: Optional[str] = None):
+        self.app = app
+        self.default_version = default_version or get_settings().api_default_version
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send):
+        path = scope.get("path", "")
+        if scope["type"] != "http" or not (path == API_ROOT or path.startswith(API_ROOT + "/")):
+            await self.app(scope, receive, send)
+            return
+
+        negotiated = False
+        name = path_version(path)
+        if name is None:
+            raw = requested_version(Headers(scope=scope))
+            name = normalize_version(raw) if raw else self.default_version
+            if name not in API_VERSIONS:
+                await _unsupported(400, raw or self.default_version)(scope, receive, send)
+                return
+            negotiated = True
+            scope = self._rewritten(scope, API_VERSIONS[name].prefix)
+        elif name not in API_VERSIONS:
+            await _unsupported(404, name)(scope, receive, send)
+            return
+
+        version = API_VERSIONS[name]
+
+        async def send_wrapper(message: Message):
+            if message["type"] == "http.response.start":
+                headers = MutableHeaders(scope=message)
+                headers[VERSION_HEADER] = version.name
+                if negotiated:
+                    headers.add_vary_header(VERSION_HEADER)
+                    headers.add_vary_header("Accept")
+                for header, value in self._deprecation(scope, version):
+                    headers.append(header, value)
+            await send(message)
+
+        await self.app(scope, receive, send_wrapper)
+
+    @staticmethod
+    def _rewritten(scope: Scope, prefix: str) -> Scope:
+        """The scope with the version's prefix in place of /api."""
+        path = scope["path"]
+        raw_path = scope.get("raw_path") or path.encode()
+        return dict(
+            scope,
+            path=prefix + path[len(API_ROOT):],
+            raw_path=prefix.encode() + raw_path[len(API_ROOT):],
+        )
+
+    @staticmethod
+    def _deprecation(scope: Scope, version: ApiVersion) -> List[Tuple[str, str]]:
+        if deprecates_routes_in(version):
+            deprecation = route_deprecation(scope["method"], route_template(scope))
+            if deprecation:
+                return deprecation_headers(deprecation.deprecated_on, deprecation.sunset_on, deprecation.successor)
+        return deprecation_headers(version.deprecated_on, version.sunset_on)
@@ -0,0 +1,112 @@
+# Location: app/notifications/notifier.py
+
+import logging
+from typing import Any, Dict, List, Optional
+
+from app.api.v1 import schemas
+from app.notifications.push import push_allowed
+from app.notifications.templates import PUSH_ONLY_TEMPLATES
+from app.ratelimit.buckets import TokenBuckets
+from app.repositories.notifications import NotificationRepository
+from app.repositories.push_tokens import PushTokenRepository
+from app.tasks.jobs import EMAIL_NOTIFICATION_TASK, PUSH_NOTIFICATION_TASK, SMS_NOTIFICATION_TASK
+from app.tasks.queue import TaskQueue
+from app.tenancy.context import current_tenant
+
+
+class SmsThrottle:
+    """
+    Caps the texts queued per organization per hour and per patient per
+    day, with token buckets (app/ratelimit), so a runaway batch job cannot
+    text thousands of patients, or one patient over and over. Texts over
+    either cap are recorded as throttled and never sent. Like rate limits,
+    the caps hold per process unless RATE_LIMIT_BACKEND=redis.
+    """
+
+    def __init__(self, buckets: TokenBuckets, per_hour: int, per_patient_per_day: int):
+        self.buckets = buckets
+        self.per_hour = per_hour
+        self.per_patient_per_day = per_patient_per_day
+
+    def allow(self, patient_id: str) -> bool:
+        """Takes a text from the patient's and the organization's allowance; False if either is spent."""
+        tenant = current_tenant() or "-"
+        allowed, _retry = self.buckets.take(f"sms:patient:{tenant}:{patient_id}", self.per_patient_per_day, self.per_patient_per_day / 86400)
+        if not allowed:
+            return False
+        allowed, _retry = self.buckets.take(f"sms:organization:{tenant}", self.per_hour, self.per_hour / 3600)
+        return allowed
+
+
+class Notifier:
+    """
+    Queues templated messages to patients, by email, text message and push
+    notification as configured. Each is recorded as a notification at once,
+    in the caller's transaction, and sent by a deferred job
+    (`notification-email`, `notification-sms`, `notification-push`), so a
+    slow or failing provider never holds up or fails the request that
+    caused the message.
+    """
+
+    def __init__(
+        self, notifications: NotificationRepository, tasks: TaskQueue, email: bool,
+        sms_throttle: Optional[SmsThrottle] = None, push_tokens: Optional[PushTokenRepository] = None,
+    ):
+        self.notifications = notifications
+        self.tasks = tasks
+        self.email = email
+        self.sms_throttle = sms_throttle
+        self.push_tokens = push_tokens
+
+    def notify(
+        self, template: str, patient: schemas.Patient, context: Dict[str, Any], about: Optional[str] = None,
+    ) -> List[schemas.Notification]:
+        """
+        Queues `template` to the patient's email address, phone number and
+        registered devices. Patients without one, or who opted out of texts
+        or the template's push category, are not sent that channel; push-only
+        templates (reading alerts) go by push alone. Times are rendered in
+        the patient's timezone, if recorded. Returns the notifications
+        recorded.
+        """
+        context = {"patientName": patient.given_name, **({"timezone": patient.timezone} if patient.timezone else {}), **context}
+        contact = patient.contact or schemas.ContactInfo()
+        recorded = []
+        push_only = template in PUSH_ONLY_TEMPLATES
+        if self.email and not push_only:
+            if contact.email:
+                recorded.append(self._queue(EMAIL_NOTIFICATION_TASK, "email", contact.email, template, patient, context, about))
+            else:
+                logging.info(f"Not emailing {template} to patient {patient.patient_id}, who has no email address")
+        if self.sms_throttle and not push_only:
+            if not contact.phone_number:
+                logging.info(f"Not texting {template} to patient {patient.patient_id}, who has no phone number")
+            elif patient.contact_preferences.sms_opt_out:
+                logging.info(f"Not texting {template} to patient {patient.patient_id}, who opted out of text messages")
+            elif not self.sms_throttle.allow(patient.patient_id):
+                logging.warning(f"Throttled {template} text to patient {patient.patient_id}: SMS_MAX_PER_HOUR or SMS_MAX_PER_PATIENT_PER_DAY reached")
+                recorded.append(self.notifications.create(
+                    self._notification("sms", contact.phone_number, template, patient, context, about), status="throttled",
+                ))
+            else:
+                recorded.append(self._queue(SMS_NOTIFICATION_TASK, "sms", contact.phone_number, template, patient, context, about))
+        if self.push_tokens:
+            if not push_allowed(patient, template):
+                logging.info(f"Not pushing {template} to patient {patient.patient_id}, who turned its category off")
+            elif not self.push_tokens.list(patient.patient_id, limit=1):
+                logging.info(f"Not pushing {template} to patient {patient.patient_id}, who has no registered devices")
+            else:
+                devices = f"patients/{patient.patient_id}/push-tokens"
+                recorded.append(self._queue(PUSH_NOTIFICATION_TASK, "push", devices, template, patient, context, about))
+        return recorded
+
+    @staticmethod
+    def _notification(channel, recipient, template, patient, context, about) -> schemas.NotificationCreate:
+        return schemas.NotificationCreate(
+            channel=channel, template=template, recipient=recipient, patient_id=patient.patient_id, about=about, context=context,
+        )
+
+    def _queue(self, task_name, channel, recipient, template, patient, context, about) -> schemas.Notification:
+        notification = self.notifications.create(self._notification(channel, recipient, template, patient, context, about))
+        self.tasks.enqueue(task_name, {"notificationId": notification.notification_id}, task_id=f"notification-{notification.notification_id}")
+        return notification
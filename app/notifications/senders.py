@@ -0,0 +1,147 @@
+# Location: app/notifications/senders.py
+
+import smtplib
+from abc import ABC, abstractmethod
+from dataclasses import dataclass
+from email.message import EmailMessage as MimeMessage
+from email.utils import formataddr, make_msgid
+from functools import lru_cache
+from typing import Optional
+
+import httpx
+
+from app.core.config import get_settings
+from app.core.http_client import new_client
+
+# Sent with every message so the provider's delivery events can be matched
+# to the notification: as a SendGrid custom arg, or an SMTP header.
+NOTIFICATION_ID_ARG = "notificationId"
+NOTIFICATION_ID_HEADER = "X-MegaCare-Notification-Id"
+
+
+class EmailDeliveryError(Exception):
+    """Raised when the provider could not be reached or asked to try later. Worth retrying."""
+
+
+class EmailRejected(Exception):
+    """Raised when the provider refused the message, e.g. an unverified sender. Retrying cannot help."""
+
+
+@dataclass(frozen=True)
+class OutgoingEmail:
+    notification_id: str
+    to: str
+    from_address: str
+    from_name: Optional[str]
+    reply_to: Optional[str]
+    subject: str
+    text: str
+    html: str
+
+
+class EmailSender(ABC):
+    """Hands transactional emails to a provider."""
+
+    name: str
+
+    @abstractmethod
+    def send(self, message: OutgoingEmail) -> Optional[str]:
+        """Sends the message and returns the provider's ID for it. Raises EmailDeliveryError or EmailRejected."""
+
+
+class SendGridSender(EmailSender):
+    """
+    Sends through the SendGrid v3 Mail Send API. Open and click tracking are
+    turned off: they would rewrite links and record when patients read
+    messages about their care.
+    """
+
+    name = "sendgrid"
+    API_URL = "https://api.sendgrid.com/v3/mail/send"
+
+    def __init__(self, api_key: str, timeout: float, client: Optional[httpx.Client] = None):
+        self.api_key = api_key
+        self.client = client or new_client("sendgrid", timeout)
+
+    def send(self, message: OutgoingEmail) -> Optional[str]:
+        body = {
+            "personalizations": [{"to": [{"email": message.to}], "custom_args": {NOTIFICATION_ID_ARG: message.notification_id}}],
+            "from": {"email": message.from_address, **({"name": message.from_name} if message.from_name else {})},
+            "subject": message.subject,
+            "content": [{"type": "text/plain", "value": message.text}, {"type": "text/html", "value": message.html}],
+            "tracking_settings": {"click_tracking": {"enable": False}, "open_tracking": {"enable": False}},
+        }
+        if message.reply_to:
+            body["reply_to"] = {"email": message.reply_to}
+        try:
+            response = self.client.post(self.API_URL, json=body, headers={"Authorization": f"Bearer {self.api_key}"})
+        except httpx.HTTPError as e:
+            raise EmailDeliveryError(f"SendGrid is unavailable: {e}") from e
+        if response.status_code == 429 or response.status_code >= 500:
+            raise EmailDeliveryError(f"SendGrid answered {response.status_code}")
+        if response.status_code >= 300:
+            raise EmailRejected(f"SendGrid refused the message ({response.status_code}): {self._errors(response)}")
+        return response.headers.get("X-Message-Id")
+
+    @staticmethod
+    def _errors(response: httpx.Response) -> str:
+        try:
+            return "; ".join(error.get("message", "") for error in response.json().get("errors", [])) or response.text
+        except ValueError:
+            return response.text
+
+
+class SmtpSender(EmailSender):
+    """
+    Sends through an SMTP relay, e.g. Google Workspace's smtp-relay.gmail.com.
+    SMTP reports no delivery events, so notifications sent this way stay
+    `sent` unless the relay refuses them.
+    """
+
+    name = "smtp"
+
+    def __init__(self, host: str, port: int, username: Optional[str], password: Optional[str], starttls: bool, timeout: float):
+        self.host = host
+        self.port = port
+        self.username = username
+        self.password = password
+        self.starttls = starttls
+        self.timeout = timeout
+
+    def send(self, message: OutgoingEmail) -> Optional[str]:
+        mime = MimeMessage()
+        mime["From"] = formataddr((message.from_name or "", message.from_address))
+        mime["To"] = message.to
+        if message.reply_to:
+            mime["Reply-To"] = message.reply_to
+        mime["Subject"] = message.subject
+        mime["Message-ID"] = message_id = make_msgid(domain=message.from_address.rsplit("@", 1)[-1])
+        mime[NOTIFICATION_ID_HEADER] = message.notification_id
+        mime.set_content(message.text)
+        mime.add_alternative(message.html, subtype="html")
+        try:
+            with smtplib.SMTP(self.host, self.port, timeout=self.timeout) as smtp:
+                if self.starttls:
+                    smtp.starttls()
+                if self.username:
+                    smtp.login(self.username, self.password or "")
+                smtp.send_message(mime)
+        except (smtplib.SMTPRecipientsRefused, smtplib.SMTPSenderRefused, smtplib.SMTPAuthenticationError) as e:
+            raise EmailRejected(f"{self.host} refused the message: {e}") from e
+        except (smtplib.SMTPException, OSError) as e:
+            raise EmailDeliveryError(f"{self.host}:{self.port} is unavailable: {e}") from e
+        return message_id
+
+
+@lru_cache
+def get_email_sender() -> Optional[EmailSender]:
+    """Returns the configured sender, or None with EMAIL_PROVIDER=none."""
+    settings = get_settings()
+    if settings.email_provider == "sendgrid":
+        return SendGridSender(settings.sendgrid_api_key, settings.email_timeout_seconds)
+    if settings.email_provider == "smtp":
+        return SmtpSender(
+            settings.smtp_host, settings.smtp_port, settings.smtp_username, settings.smtp_password,
+            settings.smtp_starttls, settings.email_timeout_seconds,
+        )
+    return None
@@ -0,0 +1,56 @@
+# Location: app/notifications/sendgrid_events.py
+
+import base64
+import binascii
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Tuple
+
+from cryptography.exceptions import InvalidSignature
+from cryptography.hazmat.primitives import hashes
+from cryptography.hazmat.primitives.asymmetric import ec
+from cryptography.hazmat.primitives.serialization import load_der_public_key
+
+from app.api.v1 import schemas
+from app.notifications.senders import NOTIFICATION_ID_ARG
+
+# The signed Event Webhook: SendGrid signs the timestamp followed by the raw
+# body with ECDSA (P-256, SHA-256) and publishes the verification key in its
+# Mail Settings.
+SIGNATURE_HEADER = "X-Twilio-Email-Event-Webhook-Signature"
+TIMESTAMP_HEADER = "X-Twilio-Email-Event-Webhook-Timestamp"
+
+# SendGrid event types that change a notification's status; others
+# (processed, open, click, unsubscribe...) are ignored.
+EVENT_STATUSES = {
+    "delivered": "delivered",
+    "deferred": "deferred",
+    "bounce": "bounced",
+    "dropped": "dropped",
+    "spamreport": "complained",
+}
+
+
+def verify_signature(public_key: str, payload: bytes, signature: str, timestamp: str) -> bool:
+    """Checks the Event Webhook signature of `payload` with the base64 verification key."""
+    try:
+        key = load_der_public_key(base64.b64decode(public_key))
+        key.verify(base64.b64decode(signature), timestamp.encode() + payload, ec.ECDSA(hashes.SHA256()))
+    except (InvalidSignature, binascii.Error, ValueError, TypeError):
+        return False
+    return True
+
+
+def delivery_events(events: List[Dict[str, Any]]) -> List[Tuple[str, schemas.DeliveryEvent]]:
+    """The delivery events of a webhook batch, with the notification each is about; events of other messages are left out."""
+    found = []
+    for event in events:
+        status = EVENT_STATUSES.get(event.get("event"))
+        notification_id = event.get(NOTIFICATION_ID_ARG)
+        if not status or not notification_id:
+            continue
+        at = datetime.fromtimestamp(event["timestamp"], timezone.utc) if isinstance(event.get("timestamp"), (int, float)) else datetime.now(timezone.utc)
+        reason = event.get("reason") or event.get("response")
+        found.append((str(notification_id), schemas.DeliveryEvent(
+            status=status, at=at, reason=str(reason)[:500] if reason else None, provider_event_id=event.get("sg_event_id"),
+        )))
+    return found
@@ -0,0 +1,132 @@
+# Location: app/notifications/sms.py
+
+from abc import ABC, abstractmethod
+from dataclasses import dataclass
+from functools import lru_cache
+from typing import Optional
+from urllib.parse import urlencode
+
+import httpx
+
+from app.core.config import get_settings
+from app.core.http_client import new_client
+from app.notifications.senders import NOTIFICATION_ID_ARG
+from app.resilience.breaker import DependencyUnavailable
+from app.resilience.dependencies import TWILIO, protect
+
+# Where Twilio reports the delivery of each message, relative to
+# TWILIO_WEBHOOK_BASE_URL; the notification ID is sent in the query.
+STATUS_CALLBACK_PATH = "/integrations/sms/twilio/status"
+
+# Twilio's error for a recipient who texted STOP to the sender.
+TWILIO_UNSUBSCRIBED_RECIPIENT = 21610
+
+
+class SmsDeliveryError(Exception):
+    """Raised when the provider could not be reached or asked to try later. Worth retrying."""
+
+
+class SmsRejected(Exception):
+    """Raised when the provider refused the message, e.g. an invalid number. Retrying cannot help."""
+
+
+class SmsRecipientOptedOut(SmsRejected):
+    """Raised when the recipient opted out of messages from the sender with the provider."""
+
+
+@dataclass(frozen=True)
+class OutgoingSms:
+    notification_id: str
+    to: str
+    body: str
+
+
+class SmsSender(ABC):
+    """Hands text messages to a provider."""
+
+    name: str
+
+    @property
+    @abstractmethod
+    def sender(self) -> str:
+        """The number or messaging service messages are sent from."""
+
+    @abstractmethod
+    def send(self, message: OutgoingSms) -> Optional[str]:
+        """Sends the message and returns the provider's ID for it. Raises SmsDeliveryError or SmsRejected."""
+
+
+class TwilioSender(SmsSender):
+    """
+    Sends through the Twilio Messages API, from a Messaging Service if one
+    is configured (Twilio then picks the number and handles STOP replies
+    with Advanced Opt-Out), otherwise from TWILIO_FROM_NUMBER.
+    """
+
+    name = "twilio"
+    API_URL = "https://api.twilio.com/2010-04-01/Accounts/{account_sid}/Messages.json"
+
+    def __init__(
+        self, account_sid: str, auth_token: str, from_number: Optional[str], messaging_service_sid: Optional[str],
+        webhook_base_url: Optional[str], timeout: float, client: Optional[httpx.Client] = None,
+    ):
+        self.account_sid = account_sid
+        self.auth_token = auth_token
+        self.from_number = from_number
+        self.messaging_service_sid = messaging_service_sid
+        self.webhook_base_url = webhook_base_url.rstrip("/") if webhook_base_url else None
+        self.client = client or new_client("twilio", timeout)
+
+    @property
+    def sender(self) -> str:
+        return self.messaging_service_sid or self.from_number
+
+    def send(self, message: OutgoingSms) -> Optional[str]:
+        data = {"To": message.to, "Body": message.body}
+        if self.messaging_service_sid:
+            data["MessagingServiceSid"] = self.messaging_service_sid
+        else:
+            data["From"] = self.from_number
+        if self.webhook_base_url:
+            data["StatusCallback"] = f"{self.webhook_base_url}{STATUS_CALLBACK_PATH}?{urlencode({NOTIFICATION_ID_ARG: message.notification_id})}"
+        try:
+            with protect(TWILIO, (SmsDeliveryError,)):
+                response = self._post(data)
+        except DependencyUnavailable as e:
+            raise SmsDeliveryError(str(e)) from e
+        if response.status_code >= 300:
+            code, detail = self._error(response)
+            if code == TWILIO_UNSUBSCRIBED_RECIPIENT:
+                raise SmsRecipientOptedOut(f"The recipient opted out with Twilio: {detail}")
+            raise SmsRejected(f"Twilio refused the message ({response.status_code}, error {code}): {detail}")
+        return response.json().get("sid")
+
+    def _post(self, data: dict) -> httpx.Response:
+        """Posts the message; failures that say Twilio itself is unwell raise SmsDeliveryError."""
+        try:
+            response = self.client.post(self.API_URL.format(account_sid=self.account_sid), data=data, auth=(self.account_sid, self.auth_token))
+        except httpx.HTTPError as e:
+            raise SmsDeliveryError(f"Twilio is unavailable: {e}") from e
+        if response.status_code == 429 or response.status_code >= 500:
+            raise SmsDeliveryError(f"Twilio answered {response.status_code}")
+        return response
+
+    @staticmethod
+    def _error(response: httpx.Response):
+        try:
+            error = response.json()
+            return error.get("code"), error.get("message", "")
+        except ValueError:
+            return None, response.text
+
+
+@lru_cache
+def get_sms_sender() -> Optional[SmsSender]:
+    """Returns the configured sender, or None with SMS_PROVIDER=none."""
+    settings = get_settings()
+    if settings.sms_provider == "twilio":
+        return TwilioSender(
+            settings.twilio_account_sid, settings.twilio_auth_token, settings.twilio_from_number,
+            settings.twilio_messaging_service_sid, settings.twilio_webhook_base_url, settings.sms_timeout_seconds,
+        )
+    return None
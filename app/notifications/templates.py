@@ -0,0 +1,200 @@
+# Location: app/notifications/templates.py
+
+import html
+from dataclasses import dataclass
+from datetime import datetime
+from typing import Any, Callable, Dict, List, Optional
+from zoneinfo import ZoneInfo
+
+# Messages say no more than a patient needs to act on them: a result-ready
+# notice names no test and no value, since email may be read by others and
+# travels unencrypted between some servers. The details are behind sign-in.
+# Push notifications show on a locked phone, so an abnormal-reading alert
+# names neither the vital sign nor the value either.
+
+
+@dataclass(frozen=True)
+class RenderedEmail:
+    subject: str
+    text: str
+    html: str
+
+
+@dataclass(frozen=True)
+class RenderedPush:
+    title: str
+    body: str
+    link: Optional[str]
+
+
+@dataclass(frozen=True)
+class TemplateContext:
+    """What every template may use besides the notification's own context."""
+    organization_name: str
+    portal_url: Optional[str]
+    timezone: ZoneInfo
+
+
+# A template returns its subject, its paragraphs of plain text, and an
+# optional call to action (label, URL). Emails open with a greeting; texts
+# and push notifications are the first paragraph alone (and the link, for
+# texts), so it has to stand on its own.
+Template = Callable[[Dict[str, Any], TemplateContext], tuple]
+
+
+def _greeting(context: Dict[str, Any]) -> str:
+    name = context.get("patientName")
+    return f"Dear {name}," if name else "Hello,"
+
+
+def _local_time(value: str, timezone: ZoneInfo) -> str:
+    local = datetime.fromisoformat(value).astimezone(timezone)
+    return f"{local:%A} {local.day} {local:%B %Y} at {local:%H:%M} ({timezone.key})"
+
+
+def appointment_confirmation(context: Dict[str, Any], common: TemplateContext):
+    start = _local_time(context["start"], common.timezone)
+    kind = (context.get("appointmentType") or "").replace("-", " ")
+    details = f"Your {kind} appointment" if kind else "Your appointment"
+    if context.get("practitionerName"):
+        details += f" with {context['practitionerName']}"
+    details += f" is booked for {start}"
+    details += f" at {context['location']}." if context.get("location") else "."
+    paragraphs = [
+        details,
+        f"If you cannot attend, please let {common.organization_name} know as soon as possible.",
+    ]
+    action = ("View your appointments", common.portal_url) if common.portal_url else None
+    return f"Your appointment with {common.organization_name} is confirmed", paragraphs, action
+
+
+def appointment_reminder(context: Dict[str, Any], common: TemplateContext):
+    start = _local_time(context["start"], common.timezone)
+    details = f"Your appointment with {context['practitionerName']}" if context.get("practitionerName") else "Your appointment"
+    details += f" is on {start}"
+    details += f" at {context['location']}." if context.get("location") else "."
+    paragraphs = [
+        details,
+        f"If you cannot attend, please let {common.organization_name} know as soon as possible.",
+    ]
+    action = ("View your appointments", common.portal_url) if common.portal_url else None
+    return "Appointment reminder", paragraphs, action
+
+
+def result_ready(context: Dict[str, Any], common: TemplateContext):
+    paragraphs = [
+        "A new result is ready for you to view.",
+        "Sign in to see it. If you have questions about it, your care team will be glad to help.",
+    ]
+    action = ("Sign in to view your result", common.portal_url) if common.portal_url else None
+    return "A new result is ready to view", paragraphs, action
+
+
+def abnormal_reading(context: Dict[str, Any], common: TemplateContext):
+    paragraphs = [
+        "One of your recent readings is outside its usual range.",
+        "Open the app to see it. If you feel unwell, contact your care team.",
+    ]
+    action = ("Sign in to view your readings", common.portal_url) if common.portal_url else None
+    return "Please check a recent reading", paragraphs, action
+
+
+TEMPLATES: Dict[str, Template] = {
+    "appointment-confirmation": appointment_confirmation,
+    "appointment-reminder": appointment_reminder,
+    "result-ready": result_ready,
+    "abnormal-reading": abnormal_reading,
+}
+
+# The push preference (see schemas.PushPreferences) each template falls
+# under. Reading alerts are sent by push alone; the patient app is where
+# they are useful, and they would otherwise add to the texts counted
+# against SMS_MAX_PER_PATIENT_PER_DAY.
+PUSH_CATEGORIES: Dict[str, str] = {
+    "appointment-confirmation": "appointments",
+    "appointment-reminder": "appointments",
+    "result-ready": "messages",
+    "abnormal-reading": "abnormalReadings",
+}
+PUSH_ONLY_TEMPLATES = {"abnormal-reading"}
+
+
+def _html(paragraphs: List[str], action: Optional[tuple], organization_name: str) -> str:
+    body = "".join(f"<p>{html.escape(paragraph)}</p>" for paragraph in paragraphs)
+    if action:
+        label, url = action
+        body += f'<p><a href="{html.escape(url, quote=True)}">{html.escape(label)}</a></p>'
+    return (
+        '<!DOCTYPE html><html><body style="font-family: sans-serif; line-height: 1.5">'
+        f"{body}<p>{html.escape(organization_name)}</p></body></html>"
+    )
+
+
+def render(template: str, context: Dict[str, Any], common: TemplateContext) -> RenderedEmail:
+    """Renders a notification template; values from `context` are escaped in the HTML part. Raises KeyError for unknown templates."""
+    subject, paragraphs, action = TEMPLATES[template](context, common)
+    paragraphs = [_greeting(context)] + paragraphs
+    text_paragraphs = paragraphs + ([f"{action[0]}: {action[1]}"] if action else []) + [common.organization_name]
+    return RenderedEmail(subject=subject, text="\n\n".join(text_paragraphs) + "\n", html=_html(paragraphs, action, common.organization_name))
+
+
+def render_sms(template: str, context: Dict[str, Any], common: TemplateContext) -> str:
+    """Renders a notification template as a text message, ending with how to opt out. Raises KeyError for unknown templates."""
+    _subject, paragraphs, action = TEMPLATES[template](context, common)
+    parts = [f"{common.organization_name}: {paragraphs[0]}"] + ([action[1]] if action else []) + ["Reply STOP to opt out."]
+    return " ".join(parts)
+
+
+def render_push(template: str, context: Dict[str, Any], common: TemplateContext) -> RenderedPush:
+    """Renders a notification template as a push notification: its subject over the first paragraph. Raises KeyError for unknown templates."""
+    subject, paragraphs, action = TEMPLATES[template](context, common)
+    return RenderedPush(title=subject, body=paragraphs[0], link=action[1] if action else None)
+
+
+def render_sign_in_link(url: str, minutes: int, common: TemplateContext) -> RenderedEmail:
+    """
+    Renders the email of a magic sign-in link. It is not a notification
+    template: the link is sent to whoever asked for it, patient or not, and
+    says nothing about their care.
+    """
+    paragraphs = [
+        "Hello,",
+        f"Use the link below to sign in to {common.organization_name}. It works once, for the next {minutes} minutes.",
+        "If you did not ask to sign in, you can ignore this email; nobody can sign in without the link.",
+    ]
+    action = ("Sign in", url)
+    text_paragraphs = paragraphs + [f"{action[0]}: {action[1]}", common.organization_name]
+    return RenderedEmail(
+        subject=f"Your sign-in link for {common.organization_name}",
+        text="\n\n".join(text_paragraphs) + "\n",
+        html=_html(paragraphs, action, common.organization_name),
+    )
+
+
+def render_staff_account_link(url: str, invited: bool, common: TemplateContext) -> RenderedEmail:
+    """
+    Renders the email that lets a staff member set their password: when an
+    administrator invites them, or resets their credentials.
+    """
+    if invited:
+        subject = f"You have been invited to {common.organization_name}"
+        paragraphs = [
+            "Hello,",
+            f"An administrator has created an account for you at {common.organization_name}. Use the link below to choose your password and sign in.",
+            "If you were not expecting this invitation, you can ignore this email.",
+        ]
+        action = ("Set your password", url)
+    else:
+        subject = f"Reset your password for {common.organization_name}"
+        paragraphs = [
+            "Hello,",
+            f"An administrator has reset your sign-in for {common.organization_name}, and you have been signed out everywhere. Use the link below to choose a new password.",
+            "If you did not expect this, contact your administrator.",
+        ]
+        action = ("Choose a new password", url)
+    text_paragraphs = paragraphs + [f"{action[0]}: {action[1]}", common.organization_name]
+    return RenderedEmail(
+        subject=subject,
+        text="\n\n".join(text_paragraphs) + "\n",
+        html=_html(paragraphs, action, common.organization_name),
+    )
@@ -0,0 +1,58 @@
+# Location: app/notifications/twilio_events.py
+
+import base64
+import hashlib
+import hmac
+from datetime import datetime, timezone
+from typing import Dict, Optional
+
+from app.api.v1 import schemas
+
+# Twilio signs each webhook request with HMAC-SHA1, keyed with the account's
+# auth token, over the full URL it called followed by every form parameter
+# (name then value) in name order.
+SIGNATURE_HEADER = "X-Twilio-Signature"
+
+# Message statuses that change a notification's status; the intermediate
+# ones (queued, sending, sent) are ignored.
+MESSAGE_STATUSES = {
+    "delivered": "delivered",
+    "undelivered": "bounced",
+    "failed": "failed",
+}
+
+# The keywords carriers and Twilio treat as opting out of, or back into,
+# messages from a number. Replies are matched whole, ignoring case and
+# surrounding whitespace, so "stop" opts out but "please stop by" does not.
+OPT_OUT_KEYWORDS = {"STOP", "STOPALL", "UNSUBSCRIBE", "CANCEL", "END", "QUIT", "REVOKE", "OPTOUT"}
+OPT_IN_KEYWORDS = {"START", "UNSTOP", "YES", "OPTIN"}
+
+
+def verify_signature(auth_token: str, url: str, params: Dict[str, str], signature: str) -> bool:
+    """Checks the signature Twilio sent for a request to `url` with the form `params`."""
+    signed = url + "".join(name + params[name] for name in sorted(params))
+    expected = base64.b64encode(hmac.new(auth_token.encode(), signed.encode(), hashlib.sha1).digest()).decode()
+    return hmac.compare_digest(expected, signature)
+
+
+def delivery_event(params: Dict[str, str]) -> Optional[schemas.DeliveryEvent]:
+    """The delivery event of a status callback, or None for an intermediate status."""
+    status = MESSAGE_STATUSES.get(params.get("MessageStatus", ""))
+    if not status:
+        return None
+    reason = f"Twilio error {params['ErrorCode']}" if params.get("ErrorCode") else None
+    return schemas.DeliveryEvent(
+        status=status, at=datetime.now(timezone.utc), reason=reason,
+        # Twilio sends one callback per status, and again when it is not acknowledged.
+        provider_event_id=f"{params.get('MessageSid')}:{params['MessageStatus']}",
+    )
+
+
+def opt_out_keyword(body: str) -> Optional[bool]:
+    """True if an incoming message opts out, False if it opts back in, None for anything else."""
+    keyword = body.strip().upper()
+    if keyword in OPT_OUT_KEYWORDS:
+        return True
+    if keyword in OPT_IN_KEYWORDS:
+        return False
+    return None
@@ -0,0 +1,89 @@
+# Location: app/pagination/pages.py
+
+import hashlib
+import logging
+from dataclasses import dataclass
+from typing import Callable, Generic, List, Optional, TypeVar
+
+from fastapi import HTTPException, Query, Request, status
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.pagination.cursors import InvalidCursorError, decode_cursor, encode_cursor
+
+T = TypeVar("T")
+
+# Query parameters that may change from one page to the next; any other
+# parameter change makes the cursor invalid.
+_PAGING_PARAMS = ("cursor", "limit")
+
+
+class Page(BaseModel, Generic[T]):
+    """
+    One page of a list endpoint. `next_cursor` is only set when more items
+    follow; pass it back as `cursor` to fetch them. `total` is only known,
+    and set, on the last page.
+    """
+
+    items: List[T]
+    next_cursor: Optional[str] = Field(None, alias="nextCursor")
+    total: Optional[int] = None
+
+    model_config = ConfigDict(populate_by_name=True)
+
+
+@dataclass
+class PageRequest:
+    """The `limit` and `cursor` of a list request and the query the cursor must belong to."""
+
+    limit: int
+    cursor: Optional[str]
+    query: str
+
+
+def query_fingerprint(request: Request) -> str:
+    """Hashes the path and filters of a list request, ignoring the paging parameters."""
+    params = sorted((name, value) for name, value in request.query_params.multi_items() if name not in _PAGING_PARAMS)
+    canonical = request.url.path + "?" + "&".join(f"{name}={value}" for name, value in params)
+    return hashlib.sha256(canonical.encode()).hexdigest()[:16]
+
+
+def pagination(default_limit: int = 30, max_limit: int = 100) -> Callable[..., PageRequest]:
+    """Dependency reading the `limit` and `cursor` query parameters of a list endpoint."""
+
+    def page_request(
+        request: Request,
+        limit: int = Query(default_limit, ge=1, le=max_limit),
+        cursor: Optional[str] = Query(None, description="The `next_cursor` of the previous page."),
+    ) -> PageRequest:
+        return PageRequest(limit=limit, cursor=cursor, query=query_fingerprint(request))
+
+    return page_request
+
+
+def paginate(page: PageRequest, fetch: Callable[[Optional[str], int], List[T]], id_of: Callable[[T], str]) -> Page[T]:
+    """
+    Fetches one page with `fetch(after, limit)`, which returns up to `limit`
+    items following the record ID `after` (from the start when None) in the
+    endpoint's order. The repository seeks to that record instead of
+    skipping rows, so deep pages cost the same as the first one.
+    """
+    after, seen = None, 0
+    if page.cursor:
+        try:
+            state = decode_cursor(page.cursor)
+        except InvalidCursorError as e:
+            logging.info(f"Rejected list cursor: {e}")
+            raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="Invalid cursor")
+        if state.get("q") != page.query:
+            raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail="The cursor belongs to a different query; keep the filters of the first page")
+        after, seen = state.get("a"), state.get("n", 0)
+
+    # One extra item tells whether another page follows. A StaleCursorError,
+    # raised when `after` has since been deleted, is reported as a 400
+    # stale-cursor problem (see app/errors/handlers.py).
+    items = fetch(after, page.limit + 1)
+    if len(items) <= page.limit:
+        return Page(items=items, total=seen + len(items))
+    items = items[:page.limit]
+    seen += len(items)
+    return Page(items=items, next_cursor=encode_cursor({"a": id_of(items[-1]), "q": page.query, "n": seen}))
@@ -0,0 +1,120 @@
+# Location: app/ratelimit/buckets.py
+
+import logging
+import math
+import threading
+import time
+from abc import ABC, abstractmethod
+from dataclasses import dataclass
+from functools import lru_cache
+from typing import Callable, Dict, List, Optional, Tuple
+
+from app.core.config import get_settings
+
+
+@dataclass(frozen=True)
+class RateLimitRule:
+    """
+    `per_minute` requests per minute for each caller on routes under
+    `prefix`, with bursts of up to `burst` requests.
+    """
+    prefix: str
+    per_minute: int
+    burst: int
+
+    @property
+    def refill_per_second(self) -> float:
+        return self.per_minute / 60
+
+
+def parse_rules(spec: str) -> List[RateLimitRule]:
+    """
+    Parses RATE_LIMIT_RULES, comma-separated `<prefix>=<per minute>[:<burst>]`
+    entries such as "/api/v1/auth=20:5,/fhir=300". The burst defaults to the
+    per-minute rate. Rules are returned longest prefix first, the order
+    they are matched in. Raises ValueError for a malformed entry.
+    """
+    rules = []
+    for entry in (part.strip() for part in spec.split(",")):
+        if not entry:
+            continue
+        prefix, _, limit = entry.rpartition("=")
+        per_minute, _, burst = limit.partition(":")
+        if not prefix.startswith("/") or not per_minute.isdigit() or (burst and not burst.isdigit()):
+            raise ValueError(f"invalid rate limit rule '{entry}', expected <prefix>=<per minute>[:<burst>]")
+        rules.append(RateLimitRule(prefix.rstrip("/") or "/", int(per_minute), int(burst or per_minute)))
+    return sorted(rules, key=lambda rule: len(rule.prefix), reverse=True)
+
+
+def match_rule(rules: List[RateLimitRule], path: str) -> Optional[RateLimitRule]:
+    """The rule for `path`: the one with the longest matching prefix, if any."""
+    for rule in rules:
+        if rule.prefix == "/" or path == rule.prefix or path.startswith(rule.prefix + "/"):
+            return rule
+    return None
+
+
+class TokenBuckets(ABC):
+    """
+    Token buckets by key. Each bucket holds up to `capacity` tokens and
+    gains `refill_per_second`; a request takes one.
+    """
+
+    @abstractmethod
+    def take(self, key: str, capacity: int, refill_per_second: float) -> Tuple[bool, int]:
+        """
+        Takes a token from the bucket. Returns whether one was available and,
+        if not, the whole seconds until one will be.
+        """
+
+
+class MemoryTokenBuckets(TokenBuckets):
+    """
+    Buckets held in this worker process. Each worker enforces the full limit,
+    so the effective limit of a deployment is the limit times its workers.
+    """
+
+    def __init__(self, clock: Callable[[], float] = time.monotonic):
+        self.clock = clock
+        self._buckets: Dict[str, Tuple[float, float]] = {}
+        self._lock = threading.Lock()
+
+    def take(self, key: str, capacity: int, refill_per_second: float) -> Tuple[bool, int]:
+        now = self.clock()
+        with self._lock:
+            tokens, updated_at = self._buckets.get(key, (float(capacity), now))
+            tokens = min(float(capacity), tokens + (now - updated_at) * refill_per_second)
+            allowed = tokens >= 1
+            if allowed:
+                tokens -= 1
+            self._buckets[key] = (tokens, now)
+        return allowed, 0 if allowed else math.ceil((1 - tokens) / refill_per_second)
+
+
+class FallbackTokenBuckets(TokenBuckets):
+    """
+    Uses `primary` (Redis) and falls back to per-process buckets while it
+    fails, so an outage loosens limits to per-instance ones rather than
+    failing requests.
+    """
+
+    def __init__(self, primary: TokenBuckets, fallback: Optional[TokenBuckets] = None):
+        self.primary = primary
+        self.fallback = fallback or MemoryTokenBuckets()
+
+    def take(self, key: str, capacity: int, refill_per_second: float) -> Tuple[bool, int]:
+        try:
+            return self.primary.take(key, capacity, refill_per_second)
+        except Exception as e:
+            logging.warning(f"Shared rate limit store failed, limiting in this process: {e}")
+            return self.fallback.take(key, capacity, refill_per_second)
+
+
+@lru_cache
+def get_token_buckets() -> TokenBuckets:
+    """Returns the process-wide buckets: in Redis when RATE_LIMIT_BACKEND=redis, otherwise in this process."""
+    settings = get_settings()
+    if settings.rate_limit_backend == "redis":
+        from app.ratelimit.redis_buckets import RedisTokenBuckets
+        return FallbackTokenBuckets(RedisTokenBuckets(settings.redis_url, key_prefix=settings.cache_key_prefix))
+    return MemoryTokenBuckets()
@@ -0,0 +1,74 @@
+# Location: app/ratelimit/concurrency.py
+
+import math
+import threading
+import time
+from typing import Callable, List
+
+
+class AdaptiveConcurrencyLimiter:
+    """
+    Admits up to `limit` requests at once in this worker. The limit starts
+    at `max_limit` and is adjusted every `window_seconds` from the p99
+    latency of the requests that finished in the window: above
+    `latency_target_seconds` it is cut by `backoff`, down to `min_limit`;
+    otherwise it grows by one back towards `max_limit` (additive increase,
+    multiplicative decrease). A slow downstream thus lowers the number of
+    requests waiting on it instead of letting them pile up. Windows with
+    fewer than `min_samples` requests only let the limit grow.
+    """
+
+    def __init__(
+        self,
+        min_limit: int,
+        max_limit: int,
+        latency_target_seconds: float,
+        window_seconds: float = 1.0,
+        backoff: float = 0.9,
+        min_samples: int = 20,
+        clock: Callable[[], float] = time.monotonic,
+    ):
+        self.min_limit = min(min_limit, max_limit)
+        self.max_limit = max_limit
+        self.latency_target_seconds = latency_target_seconds
+        self.window_seconds = window_seconds
+        self.backoff = backoff
+        self.min_samples = min_samples
+        self.clock = clock
+        self.limit = max_limit
+        self.in_flight = 0
+        self._samples: List[float] = []
+        self._window_ends = clock() + window_seconds
+        self._lock = threading.Lock()
+
+    def try_acquire(self) -> bool:
+        """Takes a slot for a request; False if the worker is at its limit."""
+        with self._lock:
+            self._adjust()
+            if self.in_flight >= self.limit:
+                return False
+            self.in_flight += 1
+            return True
+
+    def release(self, latency_seconds: float) -> None:
+        """Frees the slot of a finished request, recording how long it took."""
+        with self._lock:
+            self.in_flight -= 1
+            self._samples.append(latency_seconds)
+            self._adjust()
+
+    def _adjust(self) -> None:
+        now = self.clock()
+        if now < self._window_ends:
+            return
+        samples, self._samples = sorted(self._samples), []
+        self._window_ends = now + self.window_seconds
+        if len(samples) >= self.min_samples and p99(samples) > self.latency_target_seconds:
+            self.limit = max(self.min_limit, math.floor(self.limit * self.backoff))
+        else:
+            self.limit = min(self.max_limit, self.limit + 1)
+
+
+def p99(sorted_samples: List[float]) -> float:
+    """The 99th percentile of sorted, non-empty samples (nearest rank)."""
+    return sorted_samples[max(0, math.ceil(0.99 * len(sorted_samples)) - 1)]
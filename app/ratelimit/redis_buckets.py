@@ -0,0 +1,56 @@
+# Location: app/ratelimit/redis_buckets.py
+
+import math
+from typing import Tuple
+
+from app.ratelimit.buckets import TokenBuckets
+
+# Refills and takes from a bucket atomically, on the Redis server's clock so
+# that instances with drifting clocks share one view of every bucket.
+# KEYS[1] bucket; ARGV capacity, refill per second.
+# Returns {allowed, tokens left * 1000}.
+_TAKE_SCRIPT = """
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated_at')
+local tokens = tonumber(bucket[1]) or capacity
+local updated_at = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - updated_at) * rate)
+local allowed = 0
+if tokens >= 1 then
+    tokens = tokens - 1
+    allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated_at', tostring(now))
+redis.call('EXPIRE', KEYS[1], math.ceil(capacity / rate) + 1)
+return {allowed, math.floor(tokens * 1000)}
+"""
+
+
+class RedisTokenBuckets(TokenBuckets):
+    """
+    Buckets in Redis (Memorystore), shared by every instance of the service.
+    Idle buckets expire once they would be full again. Timeouts are short: a
+    slow store should not hold up the request.
+    """
+
+    def __init__(self, url: str, key_prefix: str = "megacare", timeout_seconds: float = 0.25, client=None):
+        if client is None:
+            import redis
+            client = redis.Redis.from_url(
+                url,
+                socket_timeout=timeout_seconds,
+                socket_connect_timeout=timeout_seconds,
+                decode_responses=True,
+            )
+        self.client = client
+        self.key_prefix = key_prefix
+        self._take = client.register_script(_TAKE_SCRIPT)
+
+    def take(self, key: str, capacity: int, refill_per_second: float) -> Tuple[bool, int]:
+        allowed, tokens_milli = self._take(keys=[f"{self.key_prefix}:ratelimit:{key}"], args=[capacity, refill_per_second])
+        if allowed:
+            return True, 0
+        return False, math.ceil((1 - int(tokens_milli) / 1000) / refill_per_second)
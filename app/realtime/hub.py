@@ -0,0 +1,95 @@
+# Location: app/realtime/hub.py
+
+"""
+Rooms of WebSocket connections (see app/realtime/session.py): who is in
+each room, and delivery of messages to the room's other connections. The
+two sides of a telehealth visit are rarely connected to the same Cloud Run
+instance, so with WEBSOCKET_BACKEND=redis rooms are kept in Redis and
+messages are relayed through its pub/sub; the in-memory hub only reaches
+the connections of this worker.
+"""
+
+import logging
+from abc import ABC, abstractmethod
+from dataclasses import dataclass
+from functools import lru_cache
+from typing import Any, Awaitable, Callable, Dict, List, Optional, Tuple
+
+from app.core.config import get_settings
+
+Deliver = Callable[[Dict[str, Any]], Awaitable[None]]
+
+
+@dataclass(frozen=True)
+class Member:
+    """A connection in a room, as the room's other members see it."""
+    connection_id: str
+    uid: str
+    role: str
+
+    def to_json(self) -> Dict[str, str]:
+        return {"connectionId": self.connection_id, "uid": self.uid, "role": self.role}
+
+
+class Hub(ABC):
+    """Rooms and their members, and delivery to the connections in a room."""
+
+    @abstractmethod
+    async def join(self, room: str, member: Member, deliver: Deliver) -> List[Member]:
+        """Adds the connection to the room, receiving its messages with `deliver`; returns the members, itself included."""
+
+    @abstractmethod
+    async def leave(self, room: str, connection_id: str) -> None:
+        """Removes the connection from the room."""
+
+    @abstractmethod
+    async def refresh(self, room: str, member: Member) -> None:
+        """Marks the member as still connected; hubs shared by instances drop members not refreshed."""
+
+    @abstractmethod
+    async def publish(self, room: str, message: Dict[str, Any], sender: Optional[str] = None, to: Optional[str] = None) -> None:
+        """Delivers the message to the room's members other than `sender`, or only to `to`."""
+
+
+async def safe_deliver(deliver: Deliver, connection_id: str, message: Dict[str, Any]) -> None:
+    try:
+        await deliver(message)
+    except Exception:
+        # The connection is closing; its session leaves its rooms.
+        logging.debug(f"Could not deliver a {message.get('type')} message to connection {connection_id}")
+
+
+class MemoryHub(Hub):
+    """Rooms of this worker's connections."""
+
+    def __init__(self):
+        self.rooms: Dict[str, Dict[str, Tuple[Member, Deliver]]] = {}
+
+    async def join(self, room: str, member: Member, deliver: Deliver) -> List[Member]:
+        members = self.rooms.setdefault(room, {})
+        members[member.connection_id] = (member, deliver)
+        return [m for m, _ in members.values()]
+
+    async def leave(self, room: str, connection_id: str) -> None:
+        members = self.rooms.get(room, {})
+        members.pop(connection_id, None)
+        if not members:
+            self.rooms.pop(room, None)
+
+    async def refresh(self, room: str, member: Member) -> None:
+        pass
+
+    async def publish(self, room: str, message: Dict[str, Any], sender: Optional[str] = None, to: Optional[str] = None) -> None:
+        for connection_id, (_, deliver) in list(self.rooms.get(room, {}).items()):
+            if connection_id != sender and (to is None or connection_id == to):
+                await safe_deliver(deliver, connection_id, message)
+
+
+@lru_cache
+def get_hub() -> Hub:
+    """Returns the process-wide hub: in Redis when WEBSOCKET_BACKEND=redis, otherwise in this process."""
+    settings = get_settings()
+    if settings.websocket_backend == "redis":
+        from app.realtime.redis_hub import RedisHub
+        return RedisHub(settings.redis_url, key_prefix=settings.cache_key_prefix, member_ttl_seconds=settings.websocket_idle_timeout_seconds * 2)
+    return MemoryHub()
@@ -0,0 +1,94 @@
+# Location: app/realtime/redis_hub.py
+
+import asyncio
+import json
+import logging
+import time
+from typing import Any, Dict, List, Optional
+
+from app.realtime.hub import Deliver, Hub, Member, safe_deliver
+
+
+class RedisHub(Hub):
+    """
+    Rooms in Redis (Memorystore), shared by every instance of the service.
+    A room's members are a hash of connection ID to member, each stamped
+    when last refreshed, so the members of an instance that stopped without
+    leaving drop out after `member_ttl_seconds`. Messages are published on
+    the room's channel; each worker subscribes to the channels of the rooms
+    its connections are in and delivers to them.
+    """
+
+    def __init__(self, url: str, key_prefix: str = "megacare", member_ttl_seconds: float = 120, client=None):
+        if client is None:
+            import redis.asyncio
+            client = redis.asyncio.Redis.from_url(url, decode_responses=True)
+        self.client = client
+        self.key_prefix = key_prefix
+        self.member_ttl_seconds = member_ttl_seconds
+        self.local: Dict[str, Dict[str, Deliver]] = {}
+        self.pubsub = None
+        self.listener: Optional[asyncio.Task] = None
+
+    def _members_key(self, room: str) -> str:
+        return f"{self.key_prefix}:ws:members:{room}"
+
+    def _channel(self, room: str) -> str:
+        return f"{self.key_prefix}:ws:room:{room}"
+
+    async def join(self, room: str, member: Member, deliver: Deliver) -> List[Member]:
+        await self.refresh(room, member)
+        connections = self.local.setdefault(room, {})
+        if not connections:
+            if self.pubsub is None:
+                self.pubsub = self.client.pubsub()
+            await self.pubsub.subscribe(self._channel(room))
+        connections[member.connection_id] = deliver
+        if self.listener is None or self.listener.done():
+            self.listener = asyncio.create_task(self._listen())
+        return await self._members(room)
+
+    async def leave(self, room: str, connection_id: str) -> None:
+        connections = self.local.get(room, {})
+        connections.pop(connection_id, None)
+        if not connections and self.local.pop(room, None) is not None:
+            await self.pubsub.unsubscribe(self._channel(room))
+        await self.client.hdel(self._members_key(room), connection_id)
+
+    async def refresh(self, room: str, member: Member) -> None:
+        key = self._members_key(room)
+        await self.client.hset(key, member.connection_id, json.dumps({**member.to_json(), "seenAt": time.time()}))
+        await self.client.expire(key, int(self.member_ttl_seconds) + 1)
+
+    async def publish(self, room: str, message: Dict[str, Any], sender: Optional[str] = None, to: Optional[str] = None) -> None:
+        await self.client.publish(self._channel(room), json.dumps({"message": message, "sender": sender, "to": to}))
+
+    async def _members(self, room: str) -> List[Member]:
+        key = self._members_key(room)
+        members, stale = [], []
+        for connection_id, value in (await self.client.hgetall(key)).items():
+            data = json.loads(value)
+            if data.get("seenAt", 0) < time.time() - self.member_ttl_seconds:
+                stale.append(connection_id)
+                continue
+            members.append(Member(connection_id=data["connectionId"], uid=data["uid"], role=data["role"]))
+        if stale:
+            await self.client.hdel(key, *stale)
+        return members
+
+    async def _listen(self) -> None:
+        """Delivers the messages of subscribed rooms to this worker's connections, until it is in none."""
+        prefix = self._channel("")
+        try:
+            async for raw in self.pubsub.listen():
+                if raw.get("type") != "message":
+                    continue
+                envelope = json.loads(raw["data"])
+                room = raw["channel"][len(prefix):]
+                for connection_id, deliver in list(self.local.get(room, {}).items()):
+                    if connection_id != envelope["sender"] and envelope["to"] in (None, connection_id):
+                        await safe_deliver(deliver, connection_id, envelope["message"])
+        except asyncio.CancelledError:
+            raise
+        except Exception:
+            logging.exception("WebSocket room listener failed", extra={"report_error": True})
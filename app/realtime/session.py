@@ -0,0 +1,409 @@
+# Location: app/realtime/session.py
+
+"""
+The protocol of /ws, the WebSocket the patient app and the clinician
+portal keep open during a telehealth visit. Messages are JSON objects
+with a `type`:
+
+- The client authenticates with an `Authorization: Bearer` header on the
+  upgrade request or, as browsers cannot set one, by sending
+  `{"type": "authenticate", "token": ...}` first, within
+  WEBSOCKET_AUTH_TIMEOUT_SECONDS. The server answers `welcome`, with the
+  connection's ID and the heartbeat interval.
+- `join` and `leave` a room, `{"room": "appointments/<appointmentId>"}`,
+  open to whoever may read the appointment. Joining answers `joined` with
+  the room's members; the others are sent `presence` events as members
+  join and leave.
+- `typing` (`{"room", "typing": true|false}`) and `signal` (`{"room",
+  "data", "to"?}`, WebRTC offers, answers and ICE candidates) are relayed
+  to the room's other members, or only to connection `to`, with the
+  sender as `from`.
+- The server sends `ping` every WEBSOCKET_HEARTBEAT_SECONDS and closes
+  connections it has heard nothing from (a `pong` will do) for
+  WEBSOCKET_IDLE_TIMEOUT_SECONDS. Sessions end after
+  WEBSOCKET_MAX_SESSION_SECONDS, within Cloud Run's request timeout, or
+  when the token expires; clients reconnect and join again.
+
+Mistakes in a message are answered with an `error` (`code`, `detail`);
+the connection stays open. The close codes below end it.
+"""
+
+import asyncio
+import json
+import logging
+import threading
+import time
+import uuid
+from collections import Counter
+from datetime import datetime, timezone
+from functools import lru_cache
+from typing import Any, Dict, Optional, Set
+
+from fastapi import HTTPException
+from starlette.concurrency import run_in_threadpool
+from starlette.datastructures import Headers
+from starlette.websockets import WebSocket, WebSocketDisconnect
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_appointment_repository, get_audit_event_repository
+from app.audit.events import purpose_of_use
+from app.auth.verifier import get_token_verifier
+from app.authz.mfa import check_mfa
+from app.authz.networks import client_address
+from app.authz.roles import PATIENT_ID_CLAIM, grants, has_permission, permission
+from app.core.config import get_settings
+from app.events.stream import own_patient
+from app.middleware.authentication import bearer_token
+from app.realtime.hub import Hub, Member
+from app.tenancy.context import acting_for, claimed_organization, is_platform_admin
+
+# --- Close Codes ---
+# 4000-4999 are the application's own (RFC 6455 section 7.4.2).
+CLOSE_SESSION_ENDED = 4000        # reconnect with a current token
+CLOSE_UNAUTHENTICATED = 4401
+CLOSE_FORBIDDEN = 4403
+CLOSE_IDLE = 4408
+CLOSE_TOO_MANY_CONNECTIONS = 4429  # the user's other connections use up their limit
+CLOSE_UNSUPPORTED_DATA = 1003      # binary messages
+CLOSE_MESSAGE_TOO_BIG = 1009
+CLOSE_INTERNAL_ERROR = 1011
+CLOSE_TRY_AGAIN_LATER = 1013       # this instance is full; reconnect to another
+
+ROOM_RESOURCES = ("appointments",)
+MAX_ROOMS_PER_CONNECTION = 4
+
+
+class ConnectionLimits:
+    """
+    Counts this worker's connections, in total and by user, against
+    WEBSOCKET_MAX_CONNECTIONS and WEBSOCKET_MAX_CONNECTIONS_PER_USER. Each
+    Cloud Run instance counts its own, so a user's limit is per instance.
+    """
+
+    def __init__(self, max_connections: int, max_per_user: int):
+        self.max_connections = max_connections
+        self.max_per_user = max_per_user
+        self.by_user: Counter = Counter()
+        self.lock = threading.Lock()
+
+    def acquire(self, uid: str) -> Optional[int]:
+        """Counts a connection of the user; returns the close code if it is over a limit."""
+        with self.lock:
+            if sum(self.by_user.values()) >= self.max_connections:
+                return CLOSE_TRY_AGAIN_LATER
+            if self.by_user[uid] >= self.max_per_user:
+                return CLOSE_TOO_MANY_CONNECTIONS
+            self.by_user[uid] += 1
+            return None
+
+    def release(self, uid: str) -> None:
+        with self.lock:
+            self.by_user[uid] -= 1
+            if self.by_user[uid] <= 0:
+                del self.by_user[uid]
+
+
+@lru_cache
+def get_connection_limits() -> ConnectionLimits:
+    settings = get_settings()
+    return ConnectionLimits(settings.websocket_max_connections, settings.websocket_max_connections_per_user)
+
+
+class SessionClosed(Exception):
+    def __init__(self, code: int, reason: str):
+        super().__init__(reason)
+        self.code = code
+        self.reason = reason
+
+
+class MessageError(Exception):
+    def __init__(self, code: str, detail: str, room: Optional[str] = None):
+        super().__init__(detail)
+        self.code = code
+        self.detail = detail
+        self.room = room
+
+
+def may_join(appointment: schemas.Appointment, principal: Dict) -> bool:
+    """Whether the caller may read the appointment, with their roles' permissions or as its patient."""
+    needed = permission("appointments", "GET")
+    full, own = grants(principal)
+    return has_permission(full, needed) or (needed in own and appointment.patient_id == principal.get(PATIENT_ID_CLAIM))
+
+
+class RealtimeSession:
+    """One WebSocket connection, from authentication until it closes."""
+
+    def __init__(self, websocket: WebSocket, hub: Hub, limits: ConnectionLimits, clock=time.monotonic):
+        settings = get_settings()
+        self.websocket = websocket
+        self.hub = hub
+        self.limits = limits
+        self.clock = clock
+        self.auth_timeout = settings.websocket_auth_timeout_seconds
+        self.heartbeat = settings.websocket_heartbeat_seconds
+        self.idle_timeout = settings.websocket_idle_timeout_seconds
+        self.max_session = settings.websocket_max_session_seconds
+        self.max_message_bytes = settings.websocket_max_message_bytes
+        self.tenancy_enabled = settings.tenancy_enabled
+        self.default_organization = settings.default_organization_id
+        self.connection_id = uuid.uuid4().hex
+        self.principal: Optional[Dict] = None
+        self.member: Optional[Member] = None
+        self.tenant: Optional[str] = None
+        self.rooms: Set[str] = set()
+        self.ends_at = 0.0
+        self.last_heard = clock()
+
+    async def run(self) -> None:
+        try:
+            self.principal = await self._authenticate()
+            self.tenant = self._tenant()
+            refused = self.limits.acquire(self.principal["uid"])
+            if refused:
+                raise SessionClosed(refused, "Too many connections")
+        except SessionClosed as closed:
+            await self._close(closed.code, closed.reason)
+            return
+        except WebSocketDisconnect:
+            return
+
+        uid = self.principal["uid"]
+        self.member = Member(self.connection_id, uid, "patient" if own_patient(self.principal) else "staff")
+        self.ends_at = self.clock() + self._remaining_seconds()
+        self.last_heard = self.clock()
+        logging.info(f"User {uid} opened WebSocket connection {self.connection_id}")
+        receiving = heartbeat = None
+        try:
+            await self._send({
+                "type": "welcome", "connectionId": self.connection_id,
+                "heartbeatSeconds": self.heartbeat, "idleTimeoutSeconds": self.idle_timeout,
+            })
+            receiving = asyncio.create_task(self._receive_loop())
+            heartbeat = asyncio.create_task(self._heartbeat_loop())
+            done, _ = await asyncio.wait({receiving, heartbeat}, return_when=asyncio.FIRST_COMPLETED)
+            for task in done:
+                task.result()
+        except SessionClosed as closed:
+            await self._close(closed.code, closed.reason)
+        except WebSocketDisconnect:
+            pass
+        except Exception:
+            logging.exception(f"WebSocket connection {self.connection_id} failed", extra={"report_error": True})
+            await self._close(CLOSE_INTERNAL_ERROR, "Internal error; reconnect")
+        finally:
+            for task in (receiving, heartbeat):
+                if task:
+                    task.cancel()
+            for room in list(self.rooms):
+                await self._leave(room)
+            self.limits.release(uid)
+            logging.info(f"User {uid} closed WebSocket connection {self.connection_id}")
+
+    # --- Authentication ---
+
+    async def _authenticate(self) -> Dict:
+        token = bearer_token(self.websocket.headers.get("authorization"))
+        if not token:
+            try:
+                text = await asyncio.wait_for(self._receive_text(), timeout=self.auth_timeout)
+            except asyncio.TimeoutError:
+                raise SessionClosed(CLOSE_UNAUTHENTICATED, "Authentication token not provided")
+            message = self._parse(text, limit=self.max_message_bytes) if text else None
+            if not isinstance(message, dict) or message.get("type") != "authenticate" or not isinstance(message.get("token"), str):
+                raise SessionClosed(CLOSE_UNAUTHENTICATED, "The first message must authenticate")
+            token = message["token"]
+        try:
+            claims = await run_in_threadpool(get_token_verifier().verify, token)
+        except Exception:
+            raise SessionClosed(CLOSE_UNAUTHENTICATED, "Invalid authentication credentials")
+        if not claims.get("uid"):
+            raise SessionClosed(CLOSE_UNAUTHENTICATED, "Invalid authentication credentials")
+        try:
+            check_mfa(claims)
+        except HTTPException as e:
+            raise SessionClosed(CLOSE_FORBIDDEN, e.detail)
+        return claims
+
+    def _tenant(self) -> Optional[str]:
+        """The organization the session acts for, as for HTTP requests (see app/middleware/tenancy.py)."""
+        if not self.tenancy_enabled:
+            return None
+        if is_platform_admin(self.principal):
+            return claimed_organization(self.principal)
+        tenant = claimed_organization(self.principal) or self.default_organization
+        if tenant is None:
+            raise SessionClosed(CLOSE_FORBIDDEN, "Your account is not assigned to an organization")
+        return tenant
+
+    def _remaining_seconds(self) -> float:
+        expires = self.principal.get("exp")
+        if isinstance(expires, (int, float)):
+            return max(0.0, min(self.max_session, expires - time.time()))
+        return self.max_session
+
+    # --- Receiving ---
+
+    async def _receive_loop(self) -> None:
+        while True:
+            text = await self._receive_text()
+            self.last_heard = self.clock()
+            message = self._parse(text, limit=self.max_message_bytes)
+            if not isinstance(message, dict) or not isinstance(message.get("type"), str):
+                await self._error(MessageError("invalid-message", "Messages are JSON objects with a `type`"))
+                continue
+            try:
+                await self._handle(message)
+            except MessageError as e:
+                await self._error(e)
+
+    async def _receive_text(self) -> str:
+        message = await self.websocket.receive()
+        if message["type"] == "websocket.disconnect":
+            raise WebSocketDisconnect(message.get("code", 1000))
+        if message.get("text") is None:
+            raise SessionClosed(CLOSE_UNSUPPORTED_DATA, "Messages must be text")
+        return message["text"]
+
+    @staticmethod
+    def _parse(text: str, limit: int) -> Any:
+        if len(text.encode()) > limit:
+            raise SessionClosed(CLOSE_MESSAGE_TOO_BIG, f"Messages are limited to {limit} bytes")
+        try:
+            return json.loads(text)
+        except ValueError:
+            return None
+
+    async def _handle(self, message: Dict[str, Any]) -> None:
+        kind = message["type"]
+        if kind == "ping":
+            await self._send({"type": "pong"})
+        elif kind == "pong":
+            pass
+        elif kind == "join":
+            await self._join(self._room(message))
+        elif kind == "leave":
+            room = self._joined_room(message)
+            await self._leave(room)
+            await self._send({"type": "left", "room": room})
+        elif kind == "typing":
+            room = self._joined_room(message)
+            await self.hub.publish(room, {
+                "type": "typing", "room": room, "from": self.member.to_json(), "typing": bool(message.get("typing")),
+            }, sender=self.connection_id)
+        elif kind == "signal":
+            room = self._joined_room(message)
+            to = message.get("to")
+            if not isinstance(message.get("data"), dict) or (to is not None and not isinstance(to, str)):
+                raise MessageError("invalid-message", "A signal has an object `data` and an optional connection ID `to`", room)
+            await self.hub.publish(room, {
+                "type": "signal", "room": room, "from": self.member.to_json(), "data": message["data"],
+            }, sender=self.connection_id, to=to)
+        else:
+            raise MessageError("unknown-type", f"Unknown message type '{kind}'")
+
+    def _room(self, message: Dict[str, Any]) -> str:
+        room = message.get("room")
+        resource, _, record_id = room.partition("/") if isinstance(room, str) else ("", "", "")
+        if resource not in ROOM_RESOURCES or not record_id or "/" in record_id:
+            raise MessageError("invalid-room", "Rooms are named appointments/<appointmentId>")
+        return room
+
+    def _joined_room(self, message: Dict[str, Any]) -> str:
+        room = self._room(message)
+        if room not in self.rooms:
+            raise MessageError("not-joined", "Join the room first", room)
+        return room
+
+    # --- Rooms ---
+
+    async def _join(self, room: str) -> None:
+        if room in self.rooms:
+            raise MessageError("already-joined", "This connection is already in the room", room)
+        if len(self.rooms) >= MAX_ROOMS_PER_CONNECTION:
+            raise MessageError("too-many-rooms", f"A connection may be in up to {MAX_ROOMS_PER_CONNECTION} rooms", room)
+        appointment = await run_in_threadpool(self._visit, room.partition("/")[2])
+        if appointment is None:
+            raise MessageError("not-found", "Appointment not found", room)
+        if not may_join(appointment, self.principal):
+            raise MessageError("forbidden", "You do not have permission to join this room", room)
+        if appointment.status == "cancelled":
+            raise MessageError("closed", "The appointment has been cancelled", room)
+
+        members = await self.hub.join(room, self.member, self._send)
+        self.rooms.add(room)
+        await self._send({"type": "joined", "room": room, "members": [m.to_json() for m in members]})
+        await self.hub.publish(room, {"type": "presence", "room": room, "event": "joined", "member": self.member.to_json()}, sender=self.connection_id)
+        logging.info(f"User {self.member.uid} joined {room} on connection {self.connection_id}")
+
+    def _visit(self, appointment_id: str) -> Optional[schemas.Appointment]:
+        """Reads the appointment, and records the access in the audit trail, as the session's organization."""
+        with acting_for(self.tenant):
+            appointment = get_appointment_repository().get(appointment_id)
+            allowed = appointment is not None and may_join(appointment, self.principal)
+            self._audit(appointment_id, appointment.patient_id if appointment else None, 200 if allowed else 403 if appointment else 404)
+        return appointment
+
+    def _audit(self, appointment_id: str, patient_id: Optional[str], status_code: int) -> None:
+        headers = Headers(scope=self.websocket.scope)
+        event = schemas.AuditEventCreate(
+            occurred_at=datetime.now(timezone.utc),
+            action="read",
+            outcome="success" if status_code < 400 else "failure",
+            status_code=status_code,
+            method="WS",
+            route="/ws",
+            path="/ws",
+            actor_uid=self.principal.get("uid"),
+            actor_roles=[str(role) for role in self.principal.get("roles") or []],
+            resource_type="appointments",
+            resource_id=appointment_id,
+            patient_id=patient_id,
+            purpose_of_use=purpose_of_use(headers),
+            source_ip=client_address(self.websocket.scope, get_settings().trusted_proxy_hops),
+            user_agent=headers.get("user-agent"),
+        )
+        try:
+            get_audit_event_repository().append(event)
+        except Exception:
+            logging.exception(f"Failed to write audit event for joining appointments/{appointment_id} by {event.actor_uid}", extra={"report_error": True})
+
+    async def _leave(self, room: str) -> None:
+        self.rooms.discard(room)
+        try:
+            await self.hub.leave(room, self.connection_id)
+            await self.hub.publish(room, {"type": "presence", "room": room, "event": "left", "member": self.member.to_json()}, sender=self.connection_id)
+        except Exception:
+            logging.exception(f"Failed to leave {room} for connection {self.connection_id}")
+
+    # --- Heartbeat ---
+
+    async def _heartbeat_loop(self) -> None:
+        while True:
+            await asyncio.sleep(self.heartbeat)
+            now = self.clock()
+            if now - self.last_heard >= self.idle_timeout:
+                raise SessionClosed(CLOSE_IDLE, "No messages received within the idle timeout")
+            if now >= self.ends_at:
+                raise SessionClosed(CLOSE_SESSION_ENDED, "Session ended; reconnect")
+            await self._send({"type": "ping"})
+            for room in list(self.rooms):
+                await self.hub.refresh(room, self.member)
+
+    # --- Sending ---
+
+    async def _send(self, message: Dict[str, Any]) -> None:
+        await self.websocket.send_text(json.dumps(message))
+
+    async def _error(self, error: MessageError) -> None:
+        message = {"type": "error", "code": error.code, "detail": error.detail}
+        if error.room:
+            message["room"] = error.room
+        await self._send(message)
+
+    async def _close(self, code: int, reason: str) -> None:
+        logging.info(f"Closing WebSocket connection {self.connection_id} ({code}): {reason}")
+        try:
+            await self.websocket.close(code=code, reason=reason)
+        except Exception:
+            pass
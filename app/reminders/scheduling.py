@@ -0,0 +1,149 @@
+# Location: app/reminders/scheduling.py
+
+"""
+Schedules the reminders of appointments and sends them when due. Each
+booked appointment gets a reminder per APPOINTMENT_REMINDER_HOURS offset,
+timed in the patient's timezone so none arrives at night: a reminder due
+within APPOINTMENT_REMINDER_QUIET_HOURS is brought forward to the evening
+before. Reminders are recorded, then queued as `appointment-reminder`
+jobs due at their time where the task queue can delay them that long; the
+`appointment-reminder-sweep` cron job sends any others once due. Either
+way a reminder is claimed before it is sent, so it is sent at most once.
+"""
+
+import logging
+from datetime import datetime, time, timedelta, timezone
+from typing import List, Optional, Tuple
+from zoneinfo import ZoneInfo
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_appointment_reminder_repository, get_appointment_repository, get_patient_repository, get_practitioner_repository
+from app.core.config import get_settings
+from app.notifications.notifier import Notifier
+from app.repositories.appointment_reminders import AppointmentReminderRepository
+from app.tasks.jobs import APPOINTMENT_REMINDER_TASK
+from app.tasks.queue import TaskQueue
+
+
+def reminder_task_id(reminder_id: str) -> str:
+    return f"appointment-reminder-{reminder_id}"
+
+
+def patient_timezone(patient: Optional[schemas.Patient]) -> ZoneInfo:
+    """The patient's timezone, or EMAIL_TIMEZONE for patients without one."""
+    return ZoneInfo((patient.timezone if patient else None) or get_settings().email_timezone)
+
+
+def _quiet(hour: int, quiet_hours: Tuple[int, int]) -> bool:
+    start, end = quiet_hours
+    return start <= hour < end if start < end else hour >= start or hour < end
+
+
+def _out_of_quiet_hours(due_at: datetime, tz: ZoneInfo, quiet_hours: Optional[Tuple[int, int]]) -> datetime:
+    """`due_at`, or the start of the quiet period it falls in."""
+    local = due_at.astimezone(tz)
+    if not quiet_hours or not _quiet(local.hour, quiet_hours):
+        return due_at
+    start = datetime.combine(local.date(), time(quiet_hours[0]), tzinfo=tz)
+    if start > local:
+        start = datetime.combine(local.date() - timedelta(days=1), time(quiet_hours[0]), tzinfo=tz)
+    return start.astimezone(timezone.utc)
+
+
+def reminder_times(
+    start: datetime, tz: ZoneInfo, offsets_hours: List[int], quiet_hours: Optional[Tuple[int, int]], now: datetime,
+) -> List[Tuple[int, datetime]]:
+    """
+    The (offset, due time) of each reminder of an appointment starting at
+    `start`, longest offset first. Reminders already past are dropped, as is
+    one that quiet hours move onto the time of a longer offset's.
+    """
+    times, seen = [], set()
+    for offset in sorted(set(offsets_hours), reverse=True):
+        due_at = _out_of_quiet_hours(start - timedelta(hours=offset), tz, quiet_hours)
+        if due_at <= now or due_at in seen:
+            continue
+        seen.add(due_at)
+        times.append((offset, due_at))
+    return times
+
+
+class ReminderScheduler:
+    """Records and queues the reminders of appointments, and cancels them."""
+
+    def __init__(
+        self, reminders: AppointmentReminderRepository, tasks: TaskQueue,
+        offsets_hours: List[int], quiet_hours: Optional[Tuple[int, int]],
+    ):
+        self.reminders = reminders
+        self.tasks = tasks
+        self.offsets_hours = offsets_hours
+        self.quiet_hours = quiet_hours
+
+    def schedule(self, appointment: schemas.Appointment, patient: Optional[schemas.Patient]) -> List[schemas.AppointmentReminder]:
+        """Schedules the reminders of a booked appointment; returns those recorded."""
+        if appointment.status != "booked":
+            return []
+        now = datetime.now(timezone.utc)
+        scheduled = []
+        for offset, due_at in reminder_times(appointment.start, patient_timezone(patient), self.offsets_hours, self.quiet_hours, now):
+            reminder = self.reminders.create(appointment, offset, due_at)
+            delay = int((due_at - now).total_seconds())
+            if delay <= self.tasks.max_delay_seconds:
+                self.tasks.enqueue(
+                    APPOINTMENT_REMINDER_TASK, {"reminderId": reminder.reminder_id},
+                    delay_seconds=delay, task_id=reminder_task_id(reminder.reminder_id),
+                )
+            scheduled.append(reminder)
+        return scheduled
+
+    def cancel(self, appointment_id: str, reason: str) -> int:
+        """Cancels the appointment's reminders not yet sent; returns how many."""
+        cancelled = 0
+        for reminder in self.reminders.list(appointment_id):
+            if reminder.status == "scheduled" and self.reminders.finish(reminder.reminder_id, "cancelled", reason):
+                self.tasks.cancel(reminder_task_id(reminder.reminder_id))
+                cancelled += 1
+        return cancelled
+
+    def reschedule(self, appointment: schemas.Appointment, patient: Optional[schemas.Patient]) -> List[schemas.AppointmentReminder]:
+        """Replaces the appointment's pending reminders with ones for its new start."""
+        self.cancel(appointment.appointment_id, "appointment rescheduled")
+        return self.schedule(appointment, patient)
+
+
+def send_reminder(reminder_id: str, notifier: Optional[Notifier]) -> Optional[schemas.AppointmentReminder]:
+    """
+    Sends a scheduled reminder to the patient, or skips it if the
+    appointment is no longer booked for the time it was scheduled for.
+    Returns the reminder as finished, or None if it was not scheduled (or
+    another worker has just claimed it).
+    """
+    reminders = get_appointment_reminder_repository()
+    reminder = reminders.get(reminder_id)
+    if not reminder or reminder.status != "scheduled":
+        return None
+    appointment = get_appointment_repository().get(reminder.appointment_id)
+    patient = get_patient_repository().get(reminder.patient_id)
+    if not appointment or appointment.status != "booked":
+        return reminders.finish(reminder_id, "skipped", "appointment is no longer booked")
+    if appointment.start != reminder.appointment_start:
+        return reminders.finish(reminder_id, "skipped", "appointment was moved")
+    if not patient:
+        return reminders.finish(reminder_id, "skipped", "patient not found")
+    if notifier is None:
+        return reminders.finish(reminder_id, "skipped", "no notification provider is configured")
+
+    # Claimed before the notification is queued: a worker that fails
+    # in between loses the reminder rather than sending it twice.
+    sent = reminders.finish(reminder_id, "sent")
+    if not sent:
+        return None
+    practitioner = get_practitioner_repository().get(appointment.practitioner_id)
+    notifier.notify("appointment-reminder", patient, {
+        "start": appointment.start.isoformat(),
+        "practitionerName": f"{practitioner.given_name} {practitioner.family_name}" if practitioner else None,
+        "location": appointment.location,
+    }, about=f"appointments/{appointment.appointment_id}")
+    logging.info(f"Sent the {reminder.offset_hours}h reminder of appointment {appointment.appointment_id}")
+    return sent
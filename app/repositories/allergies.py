@@ -0,0 +1,85 @@
+# Location: app/repositories/allergies.py
+
+from abc import ABC, abstractmethod
+from typing import List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+
+
+class AllergyRepository(ABC):
+    """Storage interface for a patient's allergies and intolerances."""
+
+    @abstractmethod
+    def create(self, patient_id: str, allergy_in: schemas.AllergyCreate, recorded_by: str) -> schemas.Allergy:
+        """Stores a new allergy record for the patient."""
+
+    @abstractmethod
+    def get(self, allergy_id: str) -> Optional[schemas.Allergy]:
+        """Returns the record, or None if it does not exist."""
+
+    @abstractmethod
+    def list(
+        self,
+        patient_id: str,
+        substance_code: Optional[str] = None,
+        substance_system: Optional[str] = None,
+        clinical_status: Optional[str] = None,
+        limit: int = 100,
+    ) -> List[schemas.Allergy]:
+        """Returns the patient's records, optionally only those for one substance code and/or clinical status."""
+
+    @abstractmethod
+    def update(self, allergy_id: str, allergy_in: schemas.AllergyUpdate) -> schemas.Allergy:
+        """Applies the fields set on `allergy_in`. Raises NotFoundError."""
+
+    @abstractmethod
+    def delete(self, allergy_id: str, deleted_by: str) -> None:
+        """Marks the record deleted. Raises NotFoundError if it does not exist or is already deleted."""
+
+    @abstractmethod
+    def restore(self, allergy_id: str) -> schemas.Allergy:
+        """Clears the record's deletion. Raises NotFoundError if it does not exist."""
+
+
+class FirestoreAllergyRepository(FirestoreRepository, AllergyRepository):
+    """Stores allergies in the top-level `allergies` collection, keyed to the patient by `patientId`."""
+
+    collection_name = "allergies"
+    model = schemas.Allergy
+    id_field = "allergyId"
+    soft_delete = True
+
+    def create(self, patient_id: str, allergy_in: schemas.AllergyCreate, recorded_by: str) -> schemas.Allergy:
+        return self._create({**allergy_in.model_dump(by_alias=True), "patientId": patient_id, "recordedBy": recorded_by})
+
+    def get(self, allergy_id: str) -> Optional[schemas.Allergy]:
+        return self._get(allergy_id)
+
+    def list(
+        self,
+        patient_id: str,
+        substance_code: Optional[str] = None,
+        substance_system: Optional[str] = None,
+        clinical_status: Optional[str] = None,
+        limit: int = 100,
+    ) -> List[schemas.Allergy]:
+        query = self._query().where(filter=FieldFilter("patientId", "==", patient_id))
+        if substance_code:
+            query = query.where(filter=FieldFilter("substance.code", "==", substance_code))
+        if substance_system:
+            query = query.where(filter=FieldFilter("substance.system", "==", substance_system))
+        if clinical_status:
+            query = query.where(filter=FieldFilter("clinicalStatus", "==", clinical_status))
+        return self._fetch(query, limit)
+
+    def update(self, allergy_id: str, allergy_in: schemas.AllergyUpdate) -> schemas.Allergy:
+        return self._update(allergy_id, allergy_in.model_dump(by_alias=True, exclude_unset=True))
+
+    def delete(self, allergy_id: str, deleted_by: str) -> None:
+        self._soft_delete(allergy_id, deleted_by)
+
+    def restore(self, allergy_id: str) -> schemas.Allergy:
+        return self._restore(allergy_id)
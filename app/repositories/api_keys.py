@@ -0,0 +1,63 @@
+# Location: app/repositories/api_keys.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime, timezone
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+
+
+class ApiKeyRepository(ABC):
+    """
+    Storage for integrator API keys, keyed by the key ID embedded in the key.
+    Records carry the secret's hash, never the secret itself.
+    """
+
+    @abstractmethod
+    def create(self, key_id: str, key_in: schemas.ApiKeyCreate, secret_hash: str, created_by: str) -> schemas.ApiKeyRecord:
+        """Stores a new, active key."""
+
+    @abstractmethod
+    def get(self, key_id: str) -> Optional[schemas.ApiKeyRecord]:
+        """Returns the key, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, limit: int = 100, after: Optional[str] = None) -> List[schemas.ApiKeyRecord]:
+        """Returns keys, oldest first."""
+
+    @abstractmethod
+    def set_secret_hash(self, key_id: str, secret_hash: str) -> schemas.ApiKeyRecord:
+        """Replaces the key's secret. Raises NotFoundError."""
+
+    @abstractmethod
+    def revoke(self, key_id: str, revoked_by: str) -> schemas.ApiKeyRecord:
+        """Marks the key revoked. Raises NotFoundError."""
+
+
+class ApiKeyRecordMixin:
+    """Everything but the listing query, on top of the storage base class helpers."""
+
+    def create(self, key_id: str, key_in: schemas.ApiKeyCreate, secret_hash: str, created_by: str) -> schemas.ApiKeyRecord:
+        data = {**key_in.model_dump(by_alias=True), "status": "active", "secretHash": secret_hash, "createdBy": created_by}
+        return self._create(data, record_id=key_id)
+
+    def get(self, key_id: str) -> Optional[schemas.ApiKeyRecord]:
+        return self._get(key_id)
+
+    def set_secret_hash(self, key_id: str, secret_hash: str) -> schemas.ApiKeyRecord:
+        return self._update(key_id, {"secretHash": secret_hash, "rotatedAt": datetime.now(timezone.utc)})
+
+    def revoke(self, key_id: str, revoked_by: str) -> schemas.ApiKeyRecord:
+        return self._update(key_id, {"status": "revoked", "revokedAt": datetime.now(timezone.utc), "revokedBy": revoked_by})
+
+
+class FirestoreApiKeyRepository(ApiKeyRecordMixin, FirestoreRepository, ApiKeyRepository):
+    """Stores keys in the top-level `apiKeys` collection."""
+
+    collection_name = "apiKeys"
+    model = schemas.ApiKeyRecord
+    id_field = "keyId"
+
+    def list(self, limit: int = 100, after: Optional[str] = None) -> List[schemas.ApiKeyRecord]:
+        return [self._to_model(doc) for doc in self._start_after(self._query().order_by("createdAt"), after).limit(limit).stream()]
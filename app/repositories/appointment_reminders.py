@@ -0,0 +1,87 @@
+# Location: app/repositories/appointment_reminders.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime, timezone
+from typing import Any, Callable, Dict, List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository, NotFoundError
+
+
+class ReminderNotScheduled(Exception):
+    """Raised inside `finish` for a reminder already sent, cancelled or skipped."""
+
+
+def finishing(status: str, reason: Optional[str]) -> Callable[[schemas.AppointmentReminder], Dict[str, Any]]:
+    """The mutation moving a scheduled reminder to `status`; raises ReminderNotScheduled for any other."""
+    def mutate(reminder: schemas.AppointmentReminder) -> Dict[str, Any]:
+        if reminder.status != "scheduled":
+            raise ReminderNotScheduled(reminder.reminder_id)
+        return {"status": status, "reason": reason, "finishedAt": datetime.now(timezone.utc)}
+    return mutate
+
+
+class AppointmentReminderRepository(ABC):
+    """Storage interface for the reminders scheduled for appointments."""
+
+    @abstractmethod
+    def create(self, appointment: schemas.Appointment, offset_hours: int, due_at: datetime) -> schemas.AppointmentReminder:
+        """Stores a scheduled reminder of the appointment's current start."""
+
+    @abstractmethod
+    def get(self, reminder_id: str) -> Optional[schemas.AppointmentReminder]:
+        """Returns the reminder, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, appointment_id: str) -> List[schemas.AppointmentReminder]:
+        """Returns the appointment's reminders, in no particular order."""
+
+    @abstractmethod
+    def list_due(self, due_by: datetime, limit: int = 100) -> List[schemas.AppointmentReminder]:
+        """Returns scheduled reminders due by `due_by`, earliest first."""
+
+    @abstractmethod
+    def finish(self, reminder_id: str, status: str, reason: Optional[str] = None) -> Optional[schemas.AppointmentReminder]:
+        """
+        Moves a scheduled reminder to sent, cancelled or skipped, atomically,
+        so that of two workers only one sends it. Returns None if it was no
+        longer scheduled (or does not exist).
+        """
+
+
+class FirestoreAppointmentReminderRepository(FirestoreRepository, AppointmentReminderRepository):
+    """Stores reminders in the top-level `appointmentReminders` collection."""
+
+    collection_name = "appointmentReminders"
+    model = schemas.AppointmentReminder
+    id_field = "reminderId"
+
+    def create(self, appointment: schemas.Appointment, offset_hours: int, due_at: datetime) -> schemas.AppointmentReminder:
+        return self._create({
+            "appointmentId": appointment.appointment_id, "patientId": appointment.patient_id, "offsetHours": offset_hours,
+            "dueAt": due_at, "appointmentStart": appointment.start, "status": "scheduled",
+        })
+
+    def get(self, reminder_id: str) -> Optional[schemas.AppointmentReminder]:
+        return self._get(reminder_id)
+
+    def list(self, appointment_id: str) -> List[schemas.AppointmentReminder]:
+        query = self._query().where(filter=FieldFilter("appointmentId", "==", appointment_id))
+        return self._fetch(query, 100)
+
+    def list_due(self, due_by: datetime, limit: int = 100) -> List[schemas.AppointmentReminder]:
+        # Note: Requires a composite index on (status, dueAt).
+        query = (
+            self._query().where(filter=FieldFilter("status", "==", "scheduled"))
+            .where(filter=FieldFilter("dueAt", "<=", due_by))
+            .order_by("dueAt")
+        )
+        return self._fetch(query, limit)
+
+    def finish(self, reminder_id: str, status: str, reason: Optional[str] = None) -> Optional[schemas.AppointmentReminder]:
+        try:
+            return self._transform(reminder_id, finishing(status, reason))
+        except (ReminderNotScheduled, NotFoundError):
+            return None
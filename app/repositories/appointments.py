@@ -0,0 +1,129 @@
+# Location: app/repositories/appointments.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime
+from typing import Dict, Iterator, List, Optional, get_args
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+from app.search.filters import Search, SearchField, SearchSpec
+from app.services.appointments import ACTIVE_STATUSES
+
+# What `GET /appointments` can be filtered and sorted by (see app/search),
+# besides its patient, practitioner and start range.
+APPOINTMENT_SEARCH = SearchSpec(
+    fields=(
+        SearchField("status", "token", choices=get_args(schemas.AppointmentStatus)),
+        SearchField("appointmentType", "token"),
+        SearchField("start", "datetime", sortable=True),
+        SearchField("end", "datetime", sortable=True),
+        SearchField("createdAt", "datetime", sortable=True),
+        SearchField("updatedAt", "datetime", sortable=True),
+    ),
+    default_sort="start",
+)
+
+
+class AppointmentRepository(ABC):
+    """Storage interface for appointments."""
+
+    @abstractmethod
+    def create(self, appointment_in: schemas.AppointmentCreate) -> schemas.Appointment:
+        """Stores a new appointment with status 'booked'."""
+
+    @abstractmethod
+    def get(self, appointment_id: str) -> Optional[schemas.Appointment]:
+        """Returns the appointment, or None if it does not exist."""
+
+    @abstractmethod
+    def list(
+        self,
+        patient_id: Optional[str] = None,
+        practitioner_id: Optional[str] = None,
+        start_from: Optional[datetime] = None,
+        start_to: Optional[datetime] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+        search: Optional[Search] = None,
+    ) -> List[schemas.Appointment]:
+        """
+        Returns appointments filtered by patient/practitioner, start range and
+        `search` (see APPOINTMENT_SEARCH), in its order; by start time by default.
+        """
+
+    @abstractmethod
+    def stream_starting_between(self, start_from: datetime, start_to: datetime) -> Iterator[schemas.Appointment]:
+        """Yields every appointment starting within [start_from, start_to), in no particular order."""
+
+    @abstractmethod
+    def find_overlapping(self, practitioner_id: str, start: datetime, end: datetime, exclude_id: Optional[str] = None) -> List[schemas.Appointment]:
+        """Returns the practitioner's active appointments that overlap [start, end)."""
+
+    @abstractmethod
+    def update(self, appointment_id: str, changes: Dict) -> schemas.Appointment:
+        """Applies raw field changes (by alias). Raises NotFoundError."""
+
+
+class FirestoreAppointmentRepository(FirestoreRepository, AppointmentRepository):
+    """Stores appointments in the top-level `appointments` collection."""
+
+    collection_name = "appointments"
+    model = schemas.Appointment
+    id_field = "appointmentId"
+
+    def create(self, appointment_in: schemas.AppointmentCreate) -> schemas.Appointment:
+        data = appointment_in.model_dump(by_alias=True)
+        data["status"] = "booked"
+        return self._create(data)
+
+    def get(self, appointment_id: str) -> Optional[schemas.Appointment]:
+        return self._get(appointment_id)
+
+    def list(
+        self,
+        patient_id: Optional[str] = None,
+        practitioner_id: Optional[str] = None,
+        start_from: Optional[datetime] = None,
+        start_to: Optional[datetime] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+        search: Optional[Search] = None,
+    ) -> List[schemas.Appointment]:
+        # Note: Filtering on an ID plus a start range requires composite indexes
+        # on (patientId, start) and (practitionerId, start), and each other
+        # filter and sort used needs its own.
+        query = self._query()
+        if patient_id:
+            query = query.where(filter=FieldFilter("patientId", "==", patient_id))
+        if practitioner_id:
+            query = query.where(filter=FieldFilter("practitionerId", "==", practitioner_id))
+        if start_from:
+            query = query.where(filter=FieldFilter("start", ">=", start_from))
+        if start_to:
+            query = query.where(filter=FieldFilter("start", "<", start_to))
+        query, keep = self._searched(query, search or APPOINTMENT_SEARCH.default())
+        return self._fetch(self._start_after(query, after), limit, keep)
+
+    def stream_starting_between(self, start_from: datetime, start_to: datetime) -> Iterator[schemas.Appointment]:
+        query = self._query().where(filter=FieldFilter("start", ">=", start_from)).where(filter=FieldFilter("start", "<", start_to))
+        for doc in query.stream():
+            yield self._to_model(doc)
+
+    def find_overlapping(self, practitioner_id: str, start: datetime, end: datetime, exclude_id: Optional[str] = None) -> List[schemas.Appointment]:
+        # Firestore only allows range filters on one field, so query by start
+        # and check the end bound in memory.
+        query = self._query().where(filter=FieldFilter("practitionerId", "==", practitioner_id)) \
+            .where(filter=FieldFilter("start", "<", end))
+        overlapping = []
+        for doc in query.stream():
+            appointment = self._to_model(doc)
+            if appointment.appointment_id == exclude_id or appointment.status not in ACTIVE_STATUSES:
+                continue
+            if appointment.end > start:
+                overlapping.append(appointment)
+        return overlapping
+
+    def update(self, appointment_id: str, changes: Dict) -> schemas.Appointment:
+        return self._update(appointment_id, changes)
@@ -0,0 +1,117 @@
+# Location: app/repositories/audit_events.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime
+from typing import List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+from firebase_admin import firestore
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository, to_firestore
+
+# What an anonymized audit event keeps: what was done to which kind of
+# resource, when and with what outcome, but not by or for whom.
+ANONYMIZED_AUDIT_EVENT = {
+    "path": "anonymized",
+    "actorUid": None,
+    "actorRoles": [],
+    "resourceId": None,
+    "patientId": None,
+    "sourceIp": None,
+    "userAgent": None,
+    "requestId": None,
+    "traceId": None,
+}
+
+
+class AuditEventRepository(ABC):
+    """
+    Append-only storage for the PHI access audit trail. There is deliberately
+    no update or delete: records are kept as written until their
+    organization's retention period ends, when only the retention job
+    (app/retention) deletes or anonymizes them.
+    """
+
+    @abstractmethod
+    def append(self, event: schemas.AuditEventCreate) -> str:
+        """Stores a new audit event and returns its ID."""
+
+    @abstractmethod
+    def get(self, event_id: str) -> Optional[schemas.AuditEvent]:
+        """Returns the audit event, or None if it does not exist."""
+
+    @abstractmethod
+    def list(
+        self,
+        actor_uid: Optional[str] = None,
+        patient_id: Optional[str] = None,
+        resource_type: Optional[str] = None,
+        occurred_from: Optional[datetime] = None,
+        occurred_to: Optional[datetime] = None,
+        limit: int = 100,
+        after: Optional[str] = None,
+    ) -> List[schemas.AuditEvent]:
+        """Returns matching events, most recent first."""
+
+    @abstractmethod
+    def purge_expired(self, before: datetime) -> int:
+        """Deletes the events that occurred before `before`. Returns the count."""
+
+    @abstractmethod
+    def anonymize_expired(self, since: Optional[datetime], before: datetime) -> int:
+        """Removes who acted and on whose record from the events that occurred within [since, before). Returns the count."""
+
+
+class FirestoreAuditEventRepository(FirestoreRepository, AuditEventRepository):
+    """
+    Stores audit events in the top-level `auditEvents` collection.
+    Service accounts other than the API's should have no write access to it,
+    and Firestore security rules deny all client reads and writes.
+    """
+
+    collection_name = "auditEvents"
+    model = schemas.AuditEvent
+    id_field = "eventId"
+
+    def append(self, event: schemas.AuditEventCreate) -> str:
+        # `create` (unlike `set`) fails if the document already exists, so an
+        # existing record can never be overwritten through this path.
+        event_ref = self.collection.document()
+        event_ref.create(to_firestore(self._stamped(event.model_dump(by_alias=True))))
+        return event_ref.id
+
+    def get(self, event_id: str) -> Optional[schemas.AuditEvent]:
+        return self._get(event_id)
+
+    def list(
+        self,
+        actor_uid: Optional[str] = None,
+        patient_id: Optional[str] = None,
+        resource_type: Optional[str] = None,
+        occurred_from: Optional[datetime] = None,
+        occurred_to: Optional[datetime] = None,
+        limit: int = 100,
+        after: Optional[str] = None,
+    ) -> List[schemas.AuditEvent]:
+        # Note: each equality filter combined with the occurredAt ordering
+        # requires a composite index.
+        query = self._query()
+        if actor_uid:
+            query = query.where(filter=FieldFilter("actorUid", "==", actor_uid))
+        if patient_id:
+            query = query.where(filter=FieldFilter("patientId", "==", patient_id))
+        if resource_type:
+            query = query.where(filter=FieldFilter("resourceType", "==", resource_type))
+        if occurred_from:
+            query = query.where(filter=FieldFilter("occurredAt", ">=", occurred_from))
+        if occurred_to:
+            query = query.where(filter=FieldFilter("occurredAt", "<=", occurred_to))
+        query = self._start_after(query.order_by("occurredAt", direction=firestore.Query.DESCENDING), after).limit(limit)
+        return [self._to_model(doc) for doc in query.stream()]
+
+    def purge_expired(self, before: datetime) -> int:
+        return self._purge(before, field="occurredAt")
+
+    def anonymize_expired(self, since: Optional[datetime], before: datetime) -> int:
+        return self._anonymize(ANONYMIZED_AUDIT_EVENT, before, since, field="occurredAt")
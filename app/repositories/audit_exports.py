@@ -0,0 +1,47 @@
+# Location: app/repositories/audit_exports.py
+
+from abc import ABC, abstractmethod
+from typing import Any, Dict, Optional
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+
+
+class AuditExportRepository(ABC):
+    """Storage interface for CSV exports of the audit trail."""
+
+    @abstractmethod
+    def create(self, export_in: schemas.AuditExportCreate, requested_by: str) -> schemas.AuditExport:
+        """Stores a new export with status 'accepted'."""
+
+    @abstractmethod
+    def get(self, export_id: str) -> Optional[schemas.AuditExport]:
+        """Returns the export, or None if it does not exist."""
+
+    @abstractmethod
+    def update(self, export_id: str, changes: Dict[str, Any]) -> schemas.AuditExport:
+        """Applies raw field changes (by alias). Raises NotFoundError."""
+
+
+class AuditExportRecordMixin:
+    """
+    The operations, on top of the storage base class helpers. Exports are
+    kept with the organization whose audit trail they cover.
+    """
+
+    def create(self, export_in: schemas.AuditExportCreate, requested_by: str) -> schemas.AuditExport:
+        return self._create({**export_in.model_dump(by_alias=True), "status": "accepted", "requestedBy": requested_by})
+
+    def get(self, export_id: str) -> Optional[schemas.AuditExport]:
+        return self._get(export_id)
+
+    def update(self, export_id: str, changes: Dict[str, Any]) -> schemas.AuditExport:
+        return self._update(export_id, changes)
+
+
+class FirestoreAuditExportRepository(AuditExportRecordMixin, FirestoreRepository, AuditExportRepository):
+    """Stores exports in the top-level `auditExports` collection."""
+
+    collection_name = "auditExports"
+    model = schemas.AuditExport
+    id_field = "exportId"
@@ -0,0 +1,512 @@
+# Location: app/repositories/base.py
+
+import logging
+from contextlib import contextmanager
+from contextvars import ContextVar
+from dataclasses import dataclass
+from datetime import date, datetime, timedelta, timezone
+from typing import Any, Callable, Dict, FrozenSet, Iterable, Iterator, List, Optional, Tuple, Type
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+from firebase_admin import firestore
+from pydantic import BaseModel
+
+from app.crypto.envelope import get_field_cipher
+from app.crypto.fields import Reseal, encrypted_paths, open_fields, seal_fields
+from app.search.filters import TEXT_OPERATORS, Search
+from app.tenancy.context import current_tenant
+
+
+class RepositoryError(Exception):
+    """Base class for errors raised by repository implementations."""
+
+
+class NotFoundError(RepositoryError):
+    """The requested record does not exist."""
+
+
+class ConflictError(RepositoryError):
+    """The write would violate a uniqueness constraint (e.g. a duplicate MRN)."""
+
+
+class StaleCursorError(RepositoryError):
+    """The record a page was to continue after has since been deleted."""
+
+
+class VersionConflictError(RepositoryError):
+    """The write expected a version of the record that is no longer the current one."""
+
+
+# --- Record Versions ---
+# Every record carries a `version`: 1 when created unless the data sets one
+# (consent documents number their own), incremented by each `_update` and
+# `_transform`. Records written before versions existed read as 0.
+# A request that must not overwrite changes it has not seen sets the version
+# it read with `expected_version`; the first write in it to one of the named
+# records then checks that version atomically with the write.
+VERSION_FIELD = "version"
+
+
+@dataclass
+class Precondition:
+    record_ids: FrozenSet[str]
+    version: int
+    checked: bool = False
+
+
+_precondition_var: ContextVar[Optional[Precondition]] = ContextVar("write_precondition", default=None)
+
+
+@contextmanager
+def expected_version(record_ids: Iterable[str], version: int) -> Iterator[Precondition]:
+    """Requires the first write in the block to any of `record_ids` to find the record at `version`."""
+    token = _precondition_var.set(Precondition(frozenset(record_ids), version))
+    try:
+        yield _precondition_var.get()
+    finally:
+        _precondition_var.reset(token)
+
+
+def _expected_version(record_id: str) -> Optional[int]:
+    """The version a write of the record must find, or None if the request set none for it."""
+    precondition = _precondition_var.get()
+    if precondition is None or precondition.checked or record_id not in precondition.record_ids:
+        return None
+    return precondition.version
+
+
+def check_version(model_name: str, record_id: str, current: int, expected: Optional[int]) -> None:
+    """
+    Checks the record's current version against the one the request expects
+    (from `_expected_version`, read before any transaction so a retried one
+    checks again). Once it passes, later writes of the record in the request
+    are its own and go unchecked. Raises VersionConflictError.
+    """
+    if expected is None:
+        return
+    if current != expected:
+        raise VersionConflictError(
+            f"{model_name} '{record_id}' is at version {current}, not {expected}; fetch it again before updating."
+        )
+    _precondition_var.get().checked = True
+
+
+# --- Tenancy ---
+# Records belong to the organization they were created for: repositories
+# stamp `tenantId` from the request's tenant (app/tenancy/context.py) on
+# create, and while a tenant is set their queries only match its records
+# and reads of another organization's record find nothing. Repositories of
+# platform-wide records (organizations, the outbox, job locks, ...) clear
+# `tenant_scoped`.
+TENANT_FIELD = "tenantId"
+
+
+class TenantScoping:
+    tenant_scoped = True
+
+    def _tenant(self) -> Optional[str]:
+        """The tenant this repository's reads and writes are scoped to, if any."""
+        return current_tenant() if self.tenant_scoped else None
+
+    def _stamped(self, data: Dict[str, Any]) -> Dict[str, Any]:
+        tenant = self._tenant()
+        return {**data, TENANT_FIELD: tenant} if tenant else data
+
+    def _foreign(self, data: Dict[str, Any]) -> bool:
+        """Whether a stored record (by alias) belongs to another organization than the current one."""
+        tenant = self._tenant()
+        return tenant is not None and data.get(TENANT_FIELD) != tenant
+
+
+# --- Soft Deletion ---
+# Clinical records are marked deleted rather than removed: repositories that
+# set `soft_delete` store `deletedAt`/`deletedBy` on delete, and their reads,
+# lists and updates then treat the record as gone. Inside `including_deleted()`
+# (an admin's `?includeDeleted=true`) deleted records are read like any
+# other, and `_restore` clears the mark.
+DELETED_FIELD = "deletedAt"
+
+_including_deleted_var: ContextVar[bool] = ContextVar("including_deleted", default=False)
+
+
+@contextmanager
+def including_deleted(enabled: bool = True) -> Iterator[None]:
+    """Makes reads in the block return deleted records too, if `enabled`."""
+    token = _including_deleted_var.set(enabled or _including_deleted_var.get())
+    try:
+        yield
+    finally:
+        _including_deleted_var.reset(token)
+
+
+def showing_deleted() -> bool:
+    """Whether reads currently include deleted records, e.g. to bypass caches of live ones."""
+    return _including_deleted_var.get()
+
+
+class SoftDeletion(TenantScoping):
+    """
+    The soft delete helpers of the storage base classes, written against
+    their `_get` and `_update`. Deleting a deleted record raises NotFoundError.
+    """
+    soft_delete = False
+
+    def _hides_deleted(self) -> bool:
+        return self.soft_delete and not showing_deleted()
+
+    def _hidden(self, data: Dict[str, Any]) -> bool:
+        """Whether a stored record (by alias) is deleted, or another organization's, and should be treated as gone."""
+        return self._foreign(data) or (self._hides_deleted() and data.get(DELETED_FIELD) is not None)
+
+    def _soft_delete(self, record_id: str, deleted_by: str):
+        return self._update(record_id, {DELETED_FIELD: datetime.now(timezone.utc), "deletedBy": deleted_by})
+
+    def _restore(self, record_id: str):
+        """Clears the deletion mark; a record that is not deleted is returned as it is. Raises NotFoundError."""
+        with including_deleted():
+            record = self._get(record_id)
+            if record is None:
+                raise NotFoundError(f"{self.model.__name__} '{record_id}' not found.")
+            if getattr(record, "deleted_at", None) is None:
+                return record
+            return self._update(record_id, {DELETED_FIELD: None, "deletedBy": None})
+
+
+# --- Field Encryption ---
+# Schema fields marked ENCRYPTED (a patient's `ssn`, an encounter's
+# `psychotherapyNotes`, ...) are sealed by app/crypto as records are written
+# and opened as they are read, so the stores only ever hold ciphertext. A
+# value read under an older KMS key version, or stored before
+# FIELD_ENCRYPTION_KEY was set, is sealed again and written back by
+# `_reseal`, only if it is still the stored value; neither the version nor
+# `updatedAt` moves, as the record itself has not changed. Reads inside a
+# transaction pass `reseal=False`.
+class FieldEncryption:
+    model: Type[BaseModel]
+
+    def _sealed(self, data: Dict[str, Any]) -> Dict[str, Any]:
+        return seal_fields(data, encrypted_paths(self.model), get_field_cipher())
+
+    def _opened(self, data: Dict[str, Any]) -> Tuple[Dict[str, Any], Dict[str, Reseal]]:
+        """The stored record (by alias) with its encrypted fields opened, and the values to re-seal by path."""
+        paths = encrypted_paths(self.model)
+        if not paths:
+            return data, {}
+        return open_fields(data, paths, get_field_cipher())
+
+
+def to_firestore(value: Any) -> Any:
+    """
+    Recursively converts values into types Firestore can store.
+    Firestore has no date-only type, so `date` values are stored as midnight
+    `datetime`s (the same convention the customer endpoints use for `dob`).
+    """
+    if isinstance(value, dict):
+        return {k: to_firestore(v) for k, v in value.items()}
+    if isinstance(value, list):
+        return [to_firestore(v) for v in value]
+    if isinstance(value, date) and not isinstance(value, datetime):
+        return datetime.combine(value, datetime.min.time())
+    return value
+
+
+class FirestoreRepository(SoftDeletion, FieldEncryption):
+    """
+    Shared plumbing for repositories backed by one top-level Firestore
+    collection. Subclasses set `collection_name`, the schema `model` they
+    return and `id_field`, the alias under which the document ID is exposed
+    (e.g. "patientId"), and `soft_delete` to keep deleted records. Queries
+    start from `_query()`, which only matches the current tenant's documents.
+    """
+    collection_name: str
+    model: Type[BaseModel]
+    id_field: str
+
+    def __init__(self, db):
+        self.db = db
+        self.collection = db.collection(self.collection_name)
+
+    def _to_model(self, doc, reseal: bool = True):
+        data, stale = self._opened(doc.to_dict())
+        if stale and reseal:
+            self._reseal(doc, stale)
+        data[self.id_field] = doc.id
+        return self.model.model_validate(data)
+
+    def _reseal(self, doc, stale: Dict[str, Reseal]) -> None:
+        # The write is conditional on the document not having changed since it was read.
+        try:
+            doc.reference.update(
+                {path: sealed for path, (_stored, sealed) in stale.items()},
+                option=self.db.write_option(last_update_time=doc.update_time),
+            )
+        except Exception as e:
+            logging.warning(f"Re-encrypting {self.model.__name__} '{doc.id}' failed, it is retried on the next read: {e}")
+
+    def _query(self):
+        tenant = self._tenant()
+        return self.collection.where(filter=FieldFilter(TENANT_FIELD, "==", tenant)) if tenant else self.collection
+
+    def _live(self, doc) -> bool:
+        return doc.exists and not self._hidden(doc.to_dict())
+
+    def _get(self, record_id: str):
+        doc = self.collection.document(record_id).get()
+        return self._to_model(doc) if self._live(doc) else None
+
+    def _get_many(self, record_ids: List[str]) -> Dict[str, Any]:
+        """Reads the documents in one batched call; returns those that exist, by ID."""
+        refs = [self.collection.document(record_id) for record_id in record_ids]
+        return {doc.id: self._to_model(doc) for doc in self.db.get_all(refs) if self._live(doc)}
+
+    def _fetch(self, query, limit: int, keep: Optional[Callable[[Dict[str, Any]], bool]] = None) -> List[Any]:
+        """
+        Runs `query` for up to `limit` records. Deleted records are skipped
+        as they are read rather than filtered in the query, which would miss
+        documents written before `deletedAt` existed; deletions are rare, so
+        reading on past them seldom takes a second batch. So are documents
+        `keep` (given the stored fields) rejects, which may take several.
+        """
+        records: List[Any] = []
+        while True:
+            docs = list(query.limit(limit).stream())
+            records += [
+                self._to_model(doc) for doc in docs
+                if not self._hidden(doc.to_dict()) and (keep is None or keep(doc.to_dict()))
+            ]
+            if len(docs) < limit or len(records) >= limit:
+                return records[:limit]
+            query = query.start_after(docs[-1])
+
+    def _create(self, data: Dict[str, Any], record_id: Optional[str] = None):
+        """Stores `data` with createdAt/updatedAt timestamps. Firestore generates the ID unless given."""
+        now = datetime.now(timezone.utc)
+        data = {VERSION_FIELD: 1, **to_firestore(self._sealed(self._stamped(data))), "createdAt": now, "updatedAt": now}
+        if record_id:
+            record_ref = self.collection.document(record_id)
+            record_ref.set(data)
+        else:
+            _update_time, record_ref = self.collection.add(data)
+        return self._to_model(record_ref.get())
+
+    def _update(self, record_id: str, changes: Dict[str, Any]):
+        if _expected_version(record_id) is not None:
+            return self._transform(record_id, lambda _record: changes)
+        record_ref = self.collection.document(record_id)
+        if not self._live(record_ref.get()):
+            raise NotFoundError(f"{self.model.__name__} '{record_id}' not found.")
+        record_ref.update({**to_firestore(self._sealed(changes)), VERSION_FIELD: firestore.Increment(1), "updatedAt": datetime.now(timezone.utc)})
+        return self._to_model(record_ref.get())
+
+    def _transform(self, record_id: str, mutate: Callable[[Any], Dict[str, Any]]):
+        """
+        Read-modify-write in a transaction, for changes computed from the
+        current document (e.g. editing one item of an array field).
+        `mutate` receives the stored record as a model and returns the raw
+        field changes (by alias). Firestore re-runs the transaction if the
+        document is written concurrently, so neither change is lost; an
+        exception raised by `mutate` aborts without writing. Raises NotFoundError,
+        or VersionConflictError if the request expected another version.
+        """
+        record_ref = self.collection.document(record_id)
+        expected = _expected_version(record_id)
+
+        @firestore.transactional
+        def apply(transaction):
+            snapshot = record_ref.get(transaction=transaction)
+            if not self._live(snapshot):
+                raise NotFoundError(f"{self.model.__name__} '{record_id}' not found.")
+            version = snapshot.to_dict().get(VERSION_FIELD, 0)
+            check_version(self.model.__name__, record_id, version, expected)
+            changes = mutate(self._to_model(snapshot, reseal=False))
+            transaction.update(record_ref, {**to_firestore(self._sealed(changes)), VERSION_FIELD: version + 1, "updatedAt": datetime.now(timezone.utc)})
+
+        apply(self.db.transaction())
+        return self._to_model(record_ref.get())
+
+    def _delete(self, record_id: str) -> None:
+        record_ref = self.collection.document(record_id)
+        expected = _expected_version(record_id)
+
+        @firestore.transactional
+        def apply(transaction):
+            snapshot = record_ref.get(transaction=transaction)
+            if not self._live(snapshot):
+                raise NotFoundError(f"{self.model.__name__} '{record_id}' not found.")
+            check_version(self.model.__name__, record_id, snapshot.to_dict().get(VERSION_FIELD, 0), expected)
+            transaction.delete(record_ref)
+
+        if expected is not None:
+            apply(self.db.transaction())
+            return
+        if not self._live(record_ref.get()):
+            raise NotFoundError(f"{self.model.__name__} '{record_id}' not found.")
+        record_ref.delete()
+
+    def _lease_due(self, limit: int, lease_seconds: float) -> List[Any]:
+        """
+        Claims up to `limit` documents with status "pending" whose
+        `nextAttemptAt` has passed, oldest due first, by moving `nextAttemptAt`
+        past the lease. Used by queues kept in the store (the event outbox,
+        webhook deliveries). Each claim re-checks the document in a
+        transaction, so two workers never lease the same one.
+        """
+        now = datetime.now(timezone.utc)
+        lease_until = now + timedelta(seconds=lease_seconds)
+        query = (
+            self._query().where(filter=FieldFilter("status", "==", "pending"))
+            .where(filter=FieldFilter("nextAttemptAt", "<=", now))
+            .order_by("nextAttemptAt")
+            .limit(limit)
+        )
+
+        @firestore.transactional
+        def lease(transaction, record_ref):
+            snapshot = record_ref.get(transaction=transaction)
+            data = snapshot.to_dict() if snapshot.exists else None
+            if not data or data.get("status") != "pending" or data["nextAttemptAt"] > now:
+                return None
+            transaction.update(record_ref, {"nextAttemptAt": lease_until, "updatedAt": now})
+            return self.model.model_validate({**data, "nextAttemptAt": lease_until, "updatedAt": now, self.id_field: snapshot.id})
+
+        claimed = []
+        for doc in query.stream():
+            record = lease(self.db.transaction(), doc.reference)
+            if record:
+                claimed.append(record)
+        return claimed
+
+    def _purge(self, before: datetime, match: Optional[Dict[str, Any]] = None, batch_size: int = 500, field: str = "updatedAt") -> int:
+        """
+        Deletes the documents whose `field` (last update by default) is before
+        `before` and whose fields equal `match`, in batched writes. Used by the
+        retention jobs for operational records such as relayed outbox entries,
+        and for records past their organization's retention period. Returns
+        how many were deleted.
+        """
+        query = self._query()
+        for name, value in (match or {}).items():
+            query = query.where(filter=FieldFilter(name, "==", value))
+        query = query.where(filter=FieldFilter(field, "<", before)).limit(batch_size)
+        deleted = 0
+        while True:
+            docs = list(query.stream())
+            if not docs:
+                return deleted
+            batch = self.db.batch()
+            for doc in docs:
+                batch.delete(doc.reference)
+            batch.commit()
+            deleted += len(docs)
+
+    def _anonymize(
+        self,
+        changes: Dict[str, Any],
+        before: datetime,
+        since: Optional[datetime] = None,
+        match: Optional[Dict[str, Any]] = None,
+        field: str = "updatedAt",
+        batch_size: int = 500,
+    ) -> int:
+        """
+        Applies `changes` (by dotted alias path, e.g. {"event.data": {}}) to
+        the documents whose `field` is in [since, before) and whose fields
+        equal `match`, in batched writes, for records past their retention
+        period that are kept without what identifies a patient. Returns how
+        many were changed.
+        """
+        query = self._query()
+        for name, value in (match or {}).items():
+            query = query.where(filter=FieldFilter(name, "==", value))
+        if since:
+            query = query.where(filter=FieldFilter(field, ">=", since))
+        query = query.where(filter=FieldFilter(field, "<", before))
+        update = {**to_firestore(changes), VERSION_FIELD: firestore.Increment(1), "updatedAt": datetime.now(timezone.utc)}
+        changed, batch, pending = 0, self.db.batch(), 0
+        for doc in query.stream():
+            batch.update(doc.reference, update)
+            pending += 1
+            if pending == batch_size:
+                batch.commit()
+                changed, batch, pending = changed + pending, self.db.batch(), 0
+        if pending:
+            batch.commit()
+        return changed + pending
+
+    def _searched(self, query, search: Search) -> Tuple[Any, Callable[[Dict[str, Any]], bool]]:
+        """
+        Adds the filters and sort of `search` (see app/search) to `query`.
+        Firestore cannot match text, so `contains` and `sw` filters are
+        returned as a check on each document instead, for `_fetch`. Filters
+        on fields other than the sort need composite indexes.
+        """
+        text = [condition for condition in search.filters if condition.op in TEXT_OPERATORS]
+        for condition in search.filters:
+            if condition.op not in TEXT_OPERATORS:
+                query = query.where(filter=FieldFilter(condition.paths[0], condition.op, to_firestore(condition.value)))
+        if search.sort:
+            direction = firestore.Query.DESCENDING if search.sort.descending else firestore.Query.ASCENDING
+            query = query.order_by(search.sort.path, direction=direction)
+        return query, lambda data: all(condition.matches_text(data) for condition in text)
+
+    def _start_after(self, query, record_id: Optional[str]):
+        """
+        Continues `query` after the document `record_id` in its order, for
+        keyset pagination (see app/pagination). Raises StaleCursorError if
+        the document no longer exists, or is another organization's.
+        """
+        if not record_id:
+            return query
+        snapshot = self.collection.document(record_id).get()
+        if not snapshot.exists or self._foreign(snapshot.to_dict()):
+            raise StaleCursorError(f"{self.model.__name__} '{record_id}' no longer exists.")
+        return query.start_after(snapshot)
+
+    def assign_tenant(self, tenant: str, batch_size: int = 500) -> int:
+        """
+        Stamps `tenant` on the documents that have none, i.e. those written
+        before tenancy was enabled (see app/tenancy/backfill.py), in batched
+        writes. Versions and `updatedAt` are left alone. Returns how many.
+        """
+        assigned, batch, pending = 0, self.db.batch(), 0
+        for doc in self.collection.stream():
+            if doc.to_dict().get(TENANT_FIELD):
+                continue
+            batch.update(doc.reference, {TENANT_FIELD: tenant})
+            pending += 1
+            if pending == batch_size:
+                batch.commit()
+                assigned, batch, pending = assigned + pending, self.db.batch(), 0
+        if pending:
+            batch.commit()
+        return assigned + pending
+
+    def reassign_patient(self, source_patient_id: str, target_patient_id: str, batch_size: int = 500) -> int:
+        """
+        Moves the current organization's documents of patient `source` to
+        patient `target`, deleted ones included, in batched writes, when
+        duplicate patients are merged (see app/services/patient_matching.py).
+        Only for collections whose records have a `patientId`. Returns how many.
+        """
+        with including_deleted():
+            query = self._query().where(filter=FieldFilter("patientId", "==", source_patient_id))
+        update = {"patientId": target_patient_id, VERSION_FIELD: firestore.Increment(1), "updatedAt": datetime.now(timezone.utc)}
+        moved, batch, pending = 0, self.db.batch(), 0
+        for doc in query.stream():
+            batch.update(doc.reference, update)
+            pending += 1
+            if pending == batch_size:
+                batch.commit()
+                moved, batch, pending = moved + pending, self.db.batch(), 0
+        if pending:
+            batch.commit()
+        return moved + pending
+
+    def _stream_updated_between(self, updated_since: Optional[datetime], updated_before: Optional[datetime]) -> Iterator:
+        """Streams the raw documents of the whole collection, optionally bounded by `updatedAt`."""
+        query = self._query()
+        if updated_since:
+            query = query.where(filter=FieldFilter("updatedAt", ">=", updated_since))
+        if updated_before:
+            query = query.where(filter=FieldFilter("updatedAt", "<", updated_before))
+        return (doc for doc in query.stream() if not self._hidden(doc.to_dict()))
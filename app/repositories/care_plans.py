@@ -0,0 +1,171 @@
+# Location: app/repositories/care_plans.py
+
+import uuid
+from abc import ABC, abstractmethod
+from datetime import datetime, timezone
+from typing import Dict, List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository, NotFoundError
+
+
+class CarePlanRepository(ABC):
+    """Storage interface for care plans and their nested goals and activities."""
+
+    @abstractmethod
+    def create(self, care_plan_in: schemas.CarePlanCreate, created_by: str) -> schemas.CarePlan:
+        """Stores a new plan in 'draft' status, assigning IDs to its goals and activities."""
+
+    @abstractmethod
+    def get(self, care_plan_id: str) -> Optional[schemas.CarePlan]:
+        """Returns the plan, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, patient_id: str, status: Optional[str] = None, limit: int = 30, after: Optional[str] = None) -> List[schemas.CarePlan]:
+        """Returns the patient's plans, optionally filtered by status."""
+
+    @abstractmethod
+    def update(self, care_plan_id: str, care_plan_in: schemas.CarePlanUpdate) -> schemas.CarePlan:
+        """Applies the fields set on `care_plan_in`. Raises NotFoundError."""
+
+    @abstractmethod
+    def set_status(self, care_plan_id: str, status: str, changed_by: str, reason: Optional[str] = None) -> schemas.CarePlan:
+        """Changes the plan's status and appends the change to its history. Raises NotFoundError."""
+
+    @abstractmethod
+    def add_goal(self, care_plan_id: str, goal_in: schemas.CarePlanGoalCreate) -> schemas.CarePlan:
+        """Appends a goal. Raises NotFoundError."""
+
+    @abstractmethod
+    def update_goal(self, care_plan_id: str, goal_id: str, goal_in: schemas.CarePlanGoalUpdate) -> schemas.CarePlan:
+        """Applies the fields set on `goal_in` to one goal. Raises NotFoundError."""
+
+    @abstractmethod
+    def remove_goal(self, care_plan_id: str, goal_id: str) -> schemas.CarePlan:
+        """Removes one goal. Raises NotFoundError."""
+
+    @abstractmethod
+    def add_activity(self, care_plan_id: str, activity_in: schemas.CarePlanActivityCreate) -> schemas.CarePlan:
+        """Appends an activity. Raises NotFoundError."""
+
+    @abstractmethod
+    def update_activity(self, care_plan_id: str, activity_id: str, activity_in: schemas.CarePlanActivityUpdate) -> schemas.CarePlan:
+        """Applies the fields set on `activity_in` to one activity. Raises NotFoundError."""
+
+    @abstractmethod
+    def remove_activity(self, care_plan_id: str, activity_id: str) -> schemas.CarePlan:
+        """Removes one activity. Raises NotFoundError."""
+
+    @abstractmethod
+    def delete(self, care_plan_id: str, deleted_by: str) -> None:
+        """Marks the plan deleted. Raises NotFoundError if it does not exist or is already deleted."""
+
+    @abstractmethod
+    def restore(self, care_plan_id: str) -> schemas.CarePlan:
+        """Clears the plan's deletion. Raises NotFoundError if it does not exist."""
+
+
+def _new_item_id() -> str:
+    return uuid.uuid4().hex
+
+
+class CarePlanRecordMixin:
+    """
+    Everything but the queries, written against the `_create`/`_get`/`_update`/
+    `_transform`/`_delete` helpers that every storage base class provides.
+    Goals and activities are embedded arrays on the plan record; each item
+    carries its own ID so it can be addressed individually.
+    """
+    soft_delete = True
+
+    def create(self, care_plan_in: schemas.CarePlanCreate, created_by: str) -> schemas.CarePlan:
+        data = care_plan_in.model_dump(by_alias=True)
+        data["goals"] = [{**goal, "goalId": _new_item_id()} for goal in data["goals"]]
+        data["activities"] = [{**activity, "activityId": _new_item_id()} for activity in data["activities"]]
+        data["status"] = "draft"
+        data["statusHistory"] = [{"status": "draft", "changedAt": datetime.now(timezone.utc), "changedBy": created_by, "reason": None}]
+        return self._create(data)
+
+    def get(self, care_plan_id: str) -> Optional[schemas.CarePlan]:
+        return self._get(care_plan_id)
+
+    def update(self, care_plan_id: str, care_plan_in: schemas.CarePlanUpdate) -> schemas.CarePlan:
+        return self._update(care_plan_id, care_plan_in.model_dump(by_alias=True, exclude_unset=True))
+
+    def set_status(self, care_plan_id: str, status: str, changed_by: str, reason: Optional[str] = None) -> schemas.CarePlan:
+        change = {"status": status, "changedAt": datetime.now(timezone.utc), "changedBy": changed_by, "reason": reason}
+
+        def mutate(care_plan: schemas.CarePlan):
+            history = [c.model_dump(by_alias=True) for c in care_plan.status_history] + [change]
+            return {"status": status, "statusHistory": history}
+        return self._transform(care_plan_id, mutate)
+
+    # --- Nested items ---
+    # Goals and activities are arrays on the plan document; each change is a
+    # transactional rewrite of the array so concurrent edits are not lost.
+
+    def _items(self, care_plan: schemas.CarePlan, field: str) -> List[Dict]:
+        return [item.model_dump(by_alias=True) for item in getattr(care_plan, field)]
+
+    def _add_item(self, care_plan_id: str, field: str, id_field: str, item: Dict) -> schemas.CarePlan:
+        item = {**item, id_field: _new_item_id()}
+        return self._transform(care_plan_id, lambda care_plan: {field: self._items(care_plan, field) + [item]})
+
+    def _update_item(self, care_plan_id: str, field: str, id_field: str, item_id: str, changes: Dict) -> schemas.CarePlan:
+        def mutate(care_plan: schemas.CarePlan):
+            items = self._items(care_plan, field)
+            item = next((i for i in items if i[id_field] == item_id), None)
+            if item is None:
+                raise NotFoundError(f"{id_field[:-2].capitalize()} '{item_id}' not found in this care plan.")
+            item.update(changes)
+            return {field: items}
+        return self._transform(care_plan_id, mutate)
+
+    def _remove_item(self, care_plan_id: str, field: str, id_field: str, item_id: str) -> schemas.CarePlan:
+        def mutate(care_plan: schemas.CarePlan):
+            items = self._items(care_plan, field)
+            remaining = [i for i in items if i[id_field] != item_id]
+            if len(remaining) == len(items):
+                raise NotFoundError(f"{id_field[:-2].capitalize()} '{item_id}' not found in this care plan.")
+            return {field: remaining}
+        return self._transform(care_plan_id, mutate)
+
+    def add_goal(self, care_plan_id: str, goal_in: schemas.CarePlanGoalCreate) -> schemas.CarePlan:
+        return self._add_item(care_plan_id, "goals", "goalId", goal_in.model_dump(by_alias=True))
+
+    def update_goal(self, care_plan_id: str, goal_id: str, goal_in: schemas.CarePlanGoalUpdate) -> schemas.CarePlan:
+        return self._update_item(care_plan_id, "goals", "goalId", goal_id, goal_in.model_dump(by_alias=True, exclude_unset=True))
+
+    def remove_goal(self, care_plan_id: str, goal_id: str) -> schemas.CarePlan:
+        return self._remove_item(care_plan_id, "goals", "goalId", goal_id)
+
+    def add_activity(self, care_plan_id: str, activity_in: schemas.CarePlanActivityCreate) -> schemas.CarePlan:
+        return self._add_item(care_plan_id, "activities", "activityId", activity_in.model_dump(by_alias=True))
+
+    def update_activity(self, care_plan_id: str, activity_id: str, activity_in: schemas.CarePlanActivityUpdate) -> schemas.CarePlan:
+        return self._update_item(care_plan_id, "activities", "activityId", activity_id, activity_in.model_dump(by_alias=True, exclude_unset=True))
+
+    def remove_activity(self, care_plan_id: str, activity_id: str) -> schemas.CarePlan:
+        return self._remove_item(care_plan_id, "activities", "activityId", activity_id)
+
+    def delete(self, care_plan_id: str, deleted_by: str) -> None:
+        self._soft_delete(care_plan_id, deleted_by)
+
+    def restore(self, care_plan_id: str) -> schemas.CarePlan:
+        return self._restore(care_plan_id)
+
+
+class FirestoreCarePlanRepository(CarePlanRecordMixin, FirestoreRepository, CarePlanRepository):
+    """Stores care plans in the top-level `carePlans` collection."""
+
+    collection_name = "carePlans"
+    model = schemas.CarePlan
+    id_field = "carePlanId"
+
+    def list(self, patient_id: str, status: Optional[str] = None, limit: int = 30, after: Optional[str] = None) -> List[schemas.CarePlan]:
+        query = self._query().where(filter=FieldFilter("patientId", "==", patient_id))
+        if status:
+            query = query.where(filter=FieldFilter("status", "==", status))
+        return self._fetch(self._start_after(query, after), limit)
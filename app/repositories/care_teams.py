@@ -0,0 +1,107 @@
+# Location: app/repositories/care_teams.py
+
+from abc import ABC, abstractmethod
+from typing import List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+
+from app.api.v1 import schemas
+from app.repositories.base import ConflictError, FirestoreRepository, NotFoundError
+
+
+class CareTeamRepository(ABC):
+    """Storage interface for care teams linking practitioners to a patient."""
+
+    @abstractmethod
+    def create(self, care_team_in: schemas.CareTeamCreate) -> schemas.CareTeam:
+        """Stores a new care team."""
+
+    @abstractmethod
+    def get(self, care_team_id: str) -> Optional[schemas.CareTeam]:
+        """Returns the care team, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, patient_id: Optional[str] = None, practitioner_id: Optional[str] = None, limit: int = 30, after: Optional[str] = None) -> List[schemas.CareTeam]:
+        """Returns care teams for a patient and/or containing a practitioner."""
+
+    @abstractmethod
+    def update(self, care_team_id: str, care_team_in: schemas.CareTeamUpdate) -> schemas.CareTeam:
+        """Applies the fields set on `care_team_in`. Raises NotFoundError."""
+
+    @abstractmethod
+    def add_member(self, care_team_id: str, member: schemas.CareTeamMember) -> schemas.CareTeam:
+        """Adds a practitioner to the team. Raises NotFoundError or ConflictError if already a member."""
+
+    @abstractmethod
+    def remove_member(self, care_team_id: str, practitioner_id: str) -> schemas.CareTeam:
+        """Removes a practitioner from the team. Raises NotFoundError."""
+
+    @abstractmethod
+    def delete(self, care_team_id: str, deleted_by: str) -> None:
+        """Marks the care team deleted. Raises NotFoundError if it does not exist or is already deleted."""
+
+    @abstractmethod
+    def restore(self, care_team_id: str) -> schemas.CareTeam:
+        """Clears the care team's deletion. Raises NotFoundError if it does not exist."""
+
+
+class CareTeamRecordMixin:
+    """
+    Everything but the queries, on top of the storage base class helpers.
+    A denormalised `memberIds` array is kept alongside `members` so teams can
+    be queried by practitioner.
+    """
+    soft_delete = True
+
+    @staticmethod
+    def _member_fields(members: List[dict]) -> dict:
+        return {"members": members, "memberIds": [m["practitionerId"] for m in members]}
+
+    def create(self, care_team_in: schemas.CareTeamCreate) -> schemas.CareTeam:
+        data = care_team_in.model_dump(by_alias=True)
+        data.update(self._member_fields(data["members"]))
+        return self._create(data)
+
+    def get(self, care_team_id: str) -> Optional[schemas.CareTeam]:
+        return self._get(care_team_id)
+
+    def update(self, care_team_id: str, care_team_in: schemas.CareTeamUpdate) -> schemas.CareTeam:
+        return self._update(care_team_id, care_team_in.model_dump(by_alias=True, exclude_unset=True))
+
+    def add_member(self, care_team_id: str, member: schemas.CareTeamMember) -> schemas.CareTeam:
+        def mutate(care_team: schemas.CareTeam):
+            if any(m.practitioner_id == member.practitioner_id for m in care_team.members):
+                raise ConflictError(f"Practitioner '{member.practitioner_id}' is already a member of this care team.")
+            members = [m.model_dump(by_alias=True) for m in care_team.members] + [member.model_dump(by_alias=True)]
+            return self._member_fields(members)
+        return self._transform(care_team_id, mutate)
+
+    def remove_member(self, care_team_id: str, practitioner_id: str) -> schemas.CareTeam:
+        def mutate(care_team: schemas.CareTeam):
+            members = [m.model_dump(by_alias=True) for m in care_team.members if m.practitioner_id != practitioner_id]
+            if len(members) == len(care_team.members):
+                raise NotFoundError(f"Practitioner '{practitioner_id}' is not a member of this care team.")
+            return self._member_fields(members)
+        return self._transform(care_team_id, mutate)
+
+    def delete(self, care_team_id: str, deleted_by: str) -> None:
+        self._soft_delete(care_team_id, deleted_by)
+
+    def restore(self, care_team_id: str) -> schemas.CareTeam:
+        return self._restore(care_team_id)
+
+
+class FirestoreCareTeamRepository(CareTeamRecordMixin, FirestoreRepository, CareTeamRepository):
+    """Stores care teams in the top-level `careTeams` collection, queried by practitioner with `array_contains`."""
+
+    collection_name = "careTeams"
+    model = schemas.CareTeam
+    id_field = "careTeamId"
+
+    def list(self, patient_id: Optional[str] = None, practitioner_id: Optional[str] = None, limit: int = 30, after: Optional[str] = None) -> List[schemas.CareTeam]:
+        query = self._query()
+        if patient_id:
+            query = query.where(filter=FieldFilter("patientId", "==", patient_id))
+        if practitioner_id:
+            query = query.where(filter=FieldFilter("memberIds", "array_contains", practitioner_id))
+        return self._fetch(self._start_after(query, after), limit)
@@ -0,0 +1,151 @@
+# Location: app/repositories/claims.py
+
+from abc import ABC, abstractmethod
+from datetime import date, datetime, timezone
+from typing import Any, Dict, List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+from firebase_admin import firestore
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+from app.services.claims import status_changes
+
+
+class ChargeItemRepository(ABC):
+    """Storage interface for the billable services recorded on encounters."""
+
+    @abstractmethod
+    def create(self, encounter: schemas.Encounter, charge_in: schemas.ChargeItemCreate, service_date: date, recorded_by: str) -> schemas.ChargeItem:
+        """Stores a new billable charge item of the encounter."""
+
+    @abstractmethod
+    def get(self, charge_item_id: str) -> Optional[schemas.ChargeItem]:
+        """Returns the charge item, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, encounter_id: str, status: Optional[str] = None) -> List[schemas.ChargeItem]:
+        """Returns the encounter's charge items by date of service, optionally only those with a status."""
+
+    @abstractmethod
+    def update(self, charge_item_id: str, changes: Dict[str, Any]) -> schemas.ChargeItem:
+        """Applies raw field changes (by alias). Raises NotFoundError."""
+
+
+class ClaimRepository(ABC):
+    """Storage interface for claims and what the payers made of them."""
+
+    @abstractmethod
+    def create(self, fields: Dict[str, Any], created_by: str) -> schemas.Claim:
+        """Stores a draft claim with the fields `build_claim` worked out (see app/services/claims.py)."""
+
+    @abstractmethod
+    def get(self, claim_id: str) -> Optional[schemas.Claim]:
+        """Returns the claim, or None if it does not exist."""
+
+    @abstractmethod
+    def list(
+        self,
+        patient_id: Optional[str] = None,
+        encounter_id: Optional[str] = None,
+        statuses: Optional[List[str]] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+    ) -> List[schemas.Claim]:
+        """Returns claims newest first, optionally of a patient or encounter and with one of `statuses`."""
+
+    @abstractmethod
+    def update(self, claim_id: str, changes: Dict[str, Any]) -> schemas.Claim:
+        """Applies raw field changes (by alias) that leave the status as it is. Raises NotFoundError."""
+
+    @abstractmethod
+    def change_status(self, claim_id: str, status: str, by: str, changes: Optional[Dict[str, Any]] = None, note: Optional[str] = None) -> schemas.Claim:
+        """
+        Moves the claim to `status`, applying `changes` and adding the move
+        to its history. Raises NotFoundError, or InvalidTransitionError if
+        the claim's current status does not lead there.
+        """
+
+
+def _by_service_date(charges: List[schemas.ChargeItem]) -> List[schemas.ChargeItem]:
+    return sorted(charges, key=lambda charge: (charge.service_date, charge.created_at))
+
+
+class ChargeItemRecordMixin:
+    """Everything but the listing query, on top of the storage base class helpers."""
+
+    def create(self, encounter: schemas.Encounter, charge_in: schemas.ChargeItemCreate, service_date: date, recorded_by: str) -> schemas.ChargeItem:
+        return self._create({
+            **charge_in.model_dump(by_alias=True),
+            "encounterId": encounter.encounter_id,
+            "patientId": encounter.patient_id,
+            "serviceDate": service_date,
+            "status": "billable",
+            "claimId": None,
+            "recordedBy": recorded_by,
+        })
+
+    def get(self, charge_item_id: str) -> Optional[schemas.ChargeItem]:
+        return self._get(charge_item_id)
+
+    def update(self, charge_item_id: str, changes: Dict[str, Any]) -> schemas.ChargeItem:
+        return self._update(charge_item_id, changes)
+
+
+class ClaimRecordMixin:
+    """Everything but the listing query, on top of the storage base class helpers."""
+
+    def create(self, fields: Dict[str, Any], created_by: str) -> schemas.Claim:
+        created = schemas.ClaimStatusChange(status="draft", at=datetime.now(timezone.utc), by=created_by)
+        return self._create({**fields, "status": "draft", "history": [created.model_dump(by_alias=True)], "createdBy": created_by})
+
+    def get(self, claim_id: str) -> Optional[schemas.Claim]:
+        return self._get(claim_id)
+
+    def update(self, claim_id: str, changes: Dict[str, Any]) -> schemas.Claim:
+        return self._update(claim_id, changes)
+
+    def change_status(self, claim_id: str, status: str, by: str, changes: Optional[Dict[str, Any]] = None, note: Optional[str] = None) -> schemas.Claim:
+        return self._transform(claim_id, lambda claim: status_changes(claim, status, by, changes, note))
+
+
+class FirestoreChargeItemRepository(ChargeItemRecordMixin, FirestoreRepository, ChargeItemRepository):
+    """Stores charge items in the top-level `chargeItems` collection, keyed to the encounter by `encounterId`."""
+
+    collection_name = "chargeItems"
+    model = schemas.ChargeItem
+    id_field = "chargeItemId"
+
+    def list(self, encounter_id: str, status: Optional[str] = None) -> List[schemas.ChargeItem]:
+        query = self._query().where(filter=FieldFilter("encounterId", "==", encounter_id))
+        if status:
+            query = query.where(filter=FieldFilter("status", "==", status))
+        return _by_service_date([self._to_model(doc) for doc in query.stream()])
+
+
+class FirestoreClaimRepository(ClaimRecordMixin, FirestoreRepository, ClaimRepository):
+    """Stores claims in the top-level `claims` collection."""
+
+    collection_name = "claims"
+    model = schemas.Claim
+    id_field = "claimId"
+
+    def list(
+        self,
+        patient_id: Optional[str] = None,
+        encounter_id: Optional[str] = None,
+        statuses: Optional[List[str]] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+    ) -> List[schemas.Claim]:
+        # Note: These queries require composite indexes on (patientId, createdAt desc),
+        # (encounterId, createdAt desc) and (status, createdAt desc).
+        query = self._query()
+        if patient_id:
+            query = query.where(filter=FieldFilter("patientId", "==", patient_id))
+        if encounter_id:
+            query = query.where(filter=FieldFilter("encounterId", "==", encounter_id))
+        if statuses:
+            query = query.where(filter=FieldFilter("status", "in", statuses))
+        query = self._start_after(query.order_by("createdAt", direction=firestore.Query.DESCENDING), after).limit(limit)
+        return [self._to_model(doc) for doc in query.stream()]
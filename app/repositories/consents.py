@@ -0,0 +1,133 @@
+# Location: app/repositories/consents.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+from firebase_admin import firestore
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository, to_firestore
+
+
+class ConsentDocumentRepository(ABC):
+    """Storage interface for the versioned consent texts patients agree to."""
+
+    @abstractmethod
+    def create(self, document_in: schemas.ConsentDocumentCreate, published_by: str) -> schemas.ConsentDocument:
+        """Publishes the next version of the document for its scope."""
+
+    @abstractmethod
+    def get(self, document_id: str) -> Optional[schemas.ConsentDocument]:
+        """Returns the document version, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, scope: str) -> List[schemas.ConsentDocument]:
+        """Returns every version published for the scope, newest first."""
+
+
+class ConsentRepository(ABC):
+    """Storage interface for patients' consent grants."""
+
+    @abstractmethod
+    def create(self, patient_id: str, grant: schemas.ConsentGrant, document: schemas.ConsentDocument, granted_by: str) -> schemas.Consent:
+        """Stores an active consent to `document`."""
+
+    @abstractmethod
+    def get(self, consent_id: str) -> Optional[schemas.Consent]:
+        """Returns the consent, or None if it does not exist."""
+
+    @abstractmethod
+    def list(
+        self,
+        patient_id: str,
+        scope: Optional[str] = None,
+        organization_id: Optional[str] = None,
+        status: Optional[str] = None,
+    ) -> List[schemas.Consent]:
+        """Returns the patient's consents newest first, optionally filtered."""
+
+    @abstractmethod
+    def update(self, consent_id: str, changes: Dict[str, Any]) -> schemas.Consent:
+        """Applies raw field changes (by alias). Raises NotFoundError."""
+
+
+class FirestoreConsentDocumentRepository(FirestoreRepository, ConsentDocumentRepository):
+    """Stores consent document versions in the top-level `consentDocuments` collection."""
+
+    collection_name = "consentDocuments"
+    model = schemas.ConsentDocument
+    id_field = "documentId"
+
+    def create(self, document_in: schemas.ConsentDocumentCreate, published_by: str) -> schemas.ConsentDocument:
+        doc_ref = self.collection.document()
+        now = datetime.now(timezone.utc)
+
+        # Assign the version in a transaction so concurrent publishes for the
+        # same scope cannot both become version N.
+        @firestore.transactional
+        def publish(transaction):
+            latest = self._query().where(filter=FieldFilter("scope", "==", document_in.scope)) \
+                .order_by("version", direction=firestore.Query.DESCENDING).limit(1)
+            versions = [doc.to_dict()["version"] for doc in latest.stream(transaction=transaction)]
+            data = {
+                **document_in.model_dump(by_alias=True),
+                "version": (versions[0] if versions else 0) + 1,
+                "publishedBy": published_by,
+                "createdAt": now,
+                "updatedAt": now,
+            }
+            transaction.set(doc_ref, to_firestore(self._stamped(data)))
+
+        publish(self.db.transaction())
+        return self._to_model(doc_ref.get())
+
+    def get(self, document_id: str) -> Optional[schemas.ConsentDocument]:
+        return self._get(document_id)
+
+    def list(self, scope: str) -> List[schemas.ConsentDocument]:
+        query = self._query().where(filter=FieldFilter("scope", "==", scope)) \
+            .order_by("version", direction=firestore.Query.DESCENDING)
+        return [self._to_model(doc) for doc in query.stream()]
+
+
+class FirestoreConsentRepository(FirestoreRepository, ConsentRepository):
+    """Stores consents in the top-level `consents` collection, keyed to the patient by `patientId`."""
+
+    collection_name = "consents"
+    model = schemas.Consent
+    id_field = "consentId"
+
+    def create(self, patient_id: str, grant: schemas.ConsentGrant, document: schemas.ConsentDocument, granted_by: str) -> schemas.Consent:
+        return self._create({
+            **grant.model_dump(by_alias=True),
+            "patientId": patient_id,
+            "documentVersion": document.version,
+            "status": "active",
+            "grantedAt": datetime.now(timezone.utc),
+            "grantedBy": granted_by,
+        })
+
+    def get(self, consent_id: str) -> Optional[schemas.Consent]:
+        return self._get(consent_id)
+
+    def list(
+        self,
+        patient_id: str,
+        scope: Optional[str] = None,
+        organization_id: Optional[str] = None,
+        status: Optional[str] = None,
+    ) -> List[schemas.Consent]:
+        query = self._query().where(filter=FieldFilter("patientId", "==", patient_id))
+        if scope:
+            query = query.where(filter=FieldFilter("scope", "==", scope))
+        if organization_id:
+            query = query.where(filter=FieldFilter("organizationId", "==", organization_id))
+        if status:
+            query = query.where(filter=FieldFilter("status", "==", status))
+        query = query.order_by("grantedAt", direction=firestore.Query.DESCENDING)
+        return [self._to_model(doc) for doc in query.stream()]
+
+    def update(self, consent_id: str, changes: Dict[str, Any]) -> schemas.Consent:
+        return self._update(consent_id, changes)
@@ -0,0 +1,117 @@
+# Location: app/repositories/coverage.py
+
+from abc import ABC, abstractmethod
+from datetime import date
+from typing import Any, Dict, List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+from firebase_admin import firestore
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+
+
+class CoverageRepository(ABC):
+    """Storage interface for a patient's health plans."""
+
+    @abstractmethod
+    def create(self, patient_id: str, coverage_in: schemas.CoverageCreate, recorded_by: str) -> schemas.Coverage:
+        """Stores a new coverage for the patient."""
+
+    @abstractmethod
+    def get(self, coverage_id: str) -> Optional[schemas.Coverage]:
+        """Returns the coverage, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, patient_id: str, status: Optional[str] = None) -> List[schemas.Coverage]:
+        """Returns the patient's coverages, primary first, optionally only those with a status."""
+
+    @abstractmethod
+    def update(self, coverage_id: str, coverage_in: schemas.CoverageUpdate) -> schemas.Coverage:
+        """Applies the fields set on `coverage_in`. Raises NotFoundError."""
+
+
+class EligibilityCheckRepository(ABC):
+    """Storage interface for eligibility checks and the payers' answers."""
+
+    @abstractmethod
+    def create(self, coverage: schemas.Coverage, check_in: schemas.EligibilityCheckCreate, service_date: date, requested_by: str) -> schemas.EligibilityCheck:
+        """Stores a new check of the coverage with status 'accepted'."""
+
+    @abstractmethod
+    def get(self, check_id: str) -> Optional[schemas.EligibilityCheck]:
+        """Returns the check, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, coverage_id: str, limit: int = 20) -> List[schemas.EligibilityCheck]:
+        """Returns the coverage's checks, most recent first."""
+
+    @abstractmethod
+    def update(self, check_id: str, changes: Dict[str, Any]) -> schemas.EligibilityCheck:
+        """Applies raw field changes (by alias). Raises NotFoundError."""
+
+
+PRIORITIES = ("primary", "secondary", "tertiary")
+
+
+def _by_priority(coverages: List[schemas.Coverage]) -> List[schemas.Coverage]:
+    return sorted(coverages, key=lambda coverage: (PRIORITIES.index(coverage.priority), coverage.created_at))
+
+
+class CoverageRecordMixin:
+    """Everything but the listing query, on top of the storage base class helpers."""
+
+    def create(self, patient_id: str, coverage_in: schemas.CoverageCreate, recorded_by: str) -> schemas.Coverage:
+        return self._create({**coverage_in.model_dump(by_alias=True), "patientId": patient_id, "status": "active", "recordedBy": recorded_by})
+
+    def get(self, coverage_id: str) -> Optional[schemas.Coverage]:
+        return self._get(coverage_id)
+
+    def update(self, coverage_id: str, coverage_in: schemas.CoverageUpdate) -> schemas.Coverage:
+        return self._update(coverage_id, coverage_in.model_dump(by_alias=True, exclude_unset=True))
+
+
+class EligibilityCheckRecordMixin:
+    """Everything but the listing query, on top of the storage base class helpers."""
+
+    def create(self, coverage: schemas.Coverage, check_in: schemas.EligibilityCheckCreate, service_date: date, requested_by: str) -> schemas.EligibilityCheck:
+        return self._create({
+            "coverageId": coverage.coverage_id,
+            "patientId": coverage.patient_id,
+            "status": "accepted",
+            "serviceDate": service_date,
+            "serviceTypes": check_in.service_types,
+            "requestedBy": requested_by,
+        })
+
+    def get(self, check_id: str) -> Optional[schemas.EligibilityCheck]:
+        return self._get(check_id)
+
+    def update(self, check_id: str, changes: Dict[str, Any]) -> schemas.EligibilityCheck:
+        return self._update(check_id, changes)
+
+
+class FirestoreCoverageRepository(CoverageRecordMixin, FirestoreRepository, CoverageRepository):
+    """Stores coverages in the top-level `coverage` collection, keyed to the patient by `patientId`."""
+
+    collection_name = "coverage"
+    model = schemas.Coverage
+    id_field = "coverageId"
+
+    def list(self, patient_id: str, status: Optional[str] = None) -> List[schemas.Coverage]:
+        query = self._query().where(filter=FieldFilter("patientId", "==", patient_id))
+        if status:
+            query = query.where(filter=FieldFilter("status", "==", status))
+        return _by_priority([self._to_model(doc) for doc in query.stream()])
+
+
+class FirestoreEligibilityCheckRepository(EligibilityCheckRecordMixin, FirestoreRepository, EligibilityCheckRepository):
+    """Stores checks in the top-level `eligibilityChecks` collection."""
+
+    collection_name = "eligibilityChecks"
+    model = schemas.EligibilityCheck
+    id_field = "checkId"
+
+    def list(self, coverage_id: str, limit: int = 20) -> List[schemas.EligibilityCheck]:
+        query = self._query().where(filter=FieldFilter("coverageId", "==", coverage_id))
+        return [self._to_model(doc) for doc in query.order_by("createdAt", direction=firestore.Query.DESCENDING).limit(limit).stream()]
@@ -0,0 +1,71 @@
+# Location: app/repositories/custom_roles.py
+
+from abc import ABC, abstractmethod
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.base import ConflictError, FirestoreRepository
+
+
+class CustomRoleRepository(ABC):
+    """
+    Storage for the roles organizations define through /admin/roles, keyed
+    by name within the organization.
+    """
+
+    @abstractmethod
+    def create(self, role_in: schemas.CustomRoleCreate, updated_by: str) -> schemas.CustomRole:
+        """Stores a new role. Raises ConflictError if the name is taken."""
+
+    @abstractmethod
+    def get(self, name: str) -> Optional[schemas.CustomRole]:
+        """Returns the role, or None if the organization has none by that name."""
+
+    @abstractmethod
+    def list(self) -> List[schemas.CustomRole]:
+        """Returns every role of the organization, by name."""
+
+    @abstractmethod
+    def update(self, name: str, role_in: schemas.CustomRoleUpdate, updated_by: str) -> schemas.CustomRole:
+        """Applies the fields set on `role_in`. Raises NotFoundError."""
+
+    @abstractmethod
+    def delete(self, name: str) -> None:
+        """Removes the role. Raises NotFoundError."""
+
+
+class CustomRoleRecordMixin:
+    """
+    Everything but the listing query, on top of the storage base class
+    helpers. Two organizations may define roles of the same name, so the
+    record ID is prefixed with the organization.
+    """
+
+    def _role_id(self, name: str) -> str:
+        return f"{self._tenant() or '-'}:{name}"
+
+    def create(self, role_in: schemas.CustomRoleCreate, updated_by: str) -> schemas.CustomRole:
+        role_id = self._role_id(role_in.name)
+        if self._get(role_id):
+            raise ConflictError(f"A role named '{role_in.name}' already exists.")
+        return self._create({**role_in.model_dump(by_alias=True), "updatedBy": updated_by}, record_id=role_id)
+
+    def get(self, name: str) -> Optional[schemas.CustomRole]:
+        return self._get(self._role_id(name))
+
+    def update(self, name: str, role_in: schemas.CustomRoleUpdate, updated_by: str) -> schemas.CustomRole:
+        return self._update(self._role_id(name), {**role_in.model_dump(by_alias=True, exclude_unset=True), "updatedBy": updated_by})
+
+    def delete(self, name: str) -> None:
+        self._delete(self._role_id(name))
+
+
+class FirestoreCustomRoleRepository(CustomRoleRecordMixin, FirestoreRepository, CustomRoleRepository):
+    """Stores roles in the top-level `customRoles` collection."""
+
+    collection_name = "customRoles"
+    model = schemas.CustomRole
+    id_field = "roleId"
+
+    def list(self) -> List[schemas.CustomRole]:
+        return sorted((self._to_model(doc) for doc in self._query().stream()), key=lambda role: role.name)
@@ -0,0 +1,62 @@
+# Location: app/repositories/devices.py
+
+from abc import ABC, abstractmethod
+from typing import Optional
+
+from firebase_admin import firestore
+
+from app.api.v1 import schemas
+
+
+class DeviceRepository(ABC):
+    """Storage interface for the devices registered to a customer profile."""
+
+    @abstractmethod
+    def get_for_owner(self, owner_uid: str, device_id: str) -> Optional[schemas.Device]:
+        """Returns the device if it is registered to `owner_uid`, otherwise None."""
+
+    @abstractmethod
+    def get_telemetry_sequence(self, owner_uid: str, device_id: str) -> Optional[int]:
+        """Returns the highest telemetry sequence number accepted for the device, if any."""
+
+    @abstractmethod
+    def advance_telemetry_sequence(self, owner_uid: str, device_id: str, sequence: int) -> int:
+        """Raises the device's high-water mark to `sequence` if it is higher. Returns the resulting value."""
+
+
+class FirestoreDeviceRepository(DeviceRepository):
+    """
+    Devices live in `customers/{uid}/devices/{deviceId}`; the telemetry
+    high-water mark is kept on the device document as `lastTelemetrySequence`.
+    """
+
+    def __init__(self, db):
+        self.db = db
+
+    def _device_ref(self, owner_uid: str, device_id: str):
+        return self.db.collection("customers").document(owner_uid).collection("devices").document(device_id)
+
+    def get_for_owner(self, owner_uid: str, device_id: str) -> Optional[schemas.Device]:
+        doc = self._device_ref(owner_uid, device_id).get()
+        if not doc.exists:
+            return None
+        return schemas.Device.model_validate({**doc.to_dict(), "deviceId": doc.id})
+
+    def get_telemetry_sequence(self, owner_uid: str, device_id: str) -> Optional[int]:
+        doc = self._device_ref(owner_uid, device_id).get()
+        return (doc.to_dict() or {}).get("lastTelemetrySequence") if doc.exists else None
+
+    def advance_telemetry_sequence(self, owner_uid: str, device_id: str, sequence: int) -> int:
+        device_ref = self._device_ref(owner_uid, device_id)
+
+        # Read and write in one transaction so concurrent batches can only move the mark forward.
+        @firestore.transactional
+        def advance(transaction) -> int:
+            snapshot = device_ref.get(transaction=transaction)
+            current = (snapshot.to_dict() or {}).get("lastTelemetrySequence")
+            if current is not None and current >= sequence:
+                return current
+            transaction.update(device_ref, {"lastTelemetrySequence": sequence})
+            return sequence
+
+        return advance(self.db.transaction())
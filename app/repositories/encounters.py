@@ -0,0 +1,147 @@
+# Location: app/repositories/encounters.py
+
+import uuid
+from abc import ABC, abstractmethod
+from datetime import datetime, timezone
+from typing import Dict, List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+from firebase_admin import firestore
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository, NotFoundError
+
+
+class EncounterRepository(ABC):
+    """Storage interface for patient visits and the records linked to them."""
+
+    @abstractmethod
+    def create(self, encounter_in: schemas.EncounterCreate) -> schemas.Encounter:
+        """Stores a new encounter."""
+
+    @abstractmethod
+    def get(self, encounter_id: str) -> Optional[schemas.Encounter]:
+        """Returns the encounter, or None if it does not exist."""
+
+    @abstractmethod
+    def list(
+        self,
+        patient_id: Optional[str] = None,
+        practitioner_id: Optional[str] = None,
+        start_from: Optional[datetime] = None,
+        start_to: Optional[datetime] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+    ) -> List[schemas.Encounter]:
+        """Returns encounters newest first, filtered by patient and/or participating practitioner and start range [start_from, start_to)."""
+
+    @abstractmethod
+    def update(self, encounter_id: str, changes: Dict) -> schemas.Encounter:
+        """Applies raw field changes (by alias). Raises NotFoundError."""
+
+    @abstractmethod
+    def link_observation(self, encounter_id: str, observation_id: str) -> schemas.Encounter:
+        """Adds the observation to the encounter; linking twice is a no-op. Raises NotFoundError."""
+
+    @abstractmethod
+    def unlink_observation(self, encounter_id: str, observation_id: str) -> schemas.Encounter:
+        """Removes the observation from the encounter. Raises NotFoundError."""
+
+    @abstractmethod
+    def add_document(self, encounter_id: str, document_in: schemas.EncounterDocument, added_by: str) -> schemas.Encounter:
+        """Attaches a document reference. Raises NotFoundError."""
+
+    @abstractmethod
+    def remove_document(self, encounter_id: str, document_id: str) -> schemas.Encounter:
+        """Detaches a document reference. Raises NotFoundError."""
+
+
+class EncounterRecordMixin:
+    """
+    Everything but the queries and observation links, on top of the storage
+    base class helpers. A denormalised `participantIds` array is kept
+    alongside `participants` so encounters can be queried by practitioner.
+    """
+
+    @staticmethod
+    def _participant_fields(participants: List[dict]) -> dict:
+        return {"participants": participants, "participantIds": [p["practitionerId"] for p in participants]}
+
+    def _require(self, encounter_id: str) -> schemas.Encounter:
+        encounter = self._get(encounter_id)
+        if not encounter:
+            raise NotFoundError(f"Encounter '{encounter_id}' not found.")
+        return encounter
+
+    def create(self, encounter_in: schemas.EncounterCreate) -> schemas.Encounter:
+        data = encounter_in.model_dump(by_alias=True)
+        data.update(self._participant_fields(data["participants"]))
+        data.update({"observationIds": [], "documents": []})
+        return self._create(data)
+
+    def get(self, encounter_id: str) -> Optional[schemas.Encounter]:
+        return self._get(encounter_id)
+
+    def update(self, encounter_id: str, changes: Dict) -> schemas.Encounter:
+        if "participants" in changes:
+            changes = {**changes, **self._participant_fields(changes["participants"])}
+        return self._update(encounter_id, changes)
+
+    def add_document(self, encounter_id: str, document_in: schemas.EncounterDocument, added_by: str) -> schemas.Encounter:
+        document = {
+            **document_in.model_dump(by_alias=True),
+            "documentId": uuid.uuid4().hex,
+            "addedAt": datetime.now(timezone.utc),
+            "addedBy": added_by,
+        }
+        return self._transform(encounter_id, lambda encounter: {
+            "documents": [d.model_dump(by_alias=True) for d in encounter.documents] + [document]
+        })
+
+    def remove_document(self, encounter_id: str, document_id: str) -> schemas.Encounter:
+        def mutate(encounter: schemas.Encounter):
+            documents = [d.model_dump(by_alias=True) for d in encounter.documents if d.document_id != document_id]
+            if len(documents) == len(encounter.documents):
+                raise NotFoundError(f"Document '{document_id}' is not attached to this encounter.")
+            return {"documents": documents}
+        return self._transform(encounter_id, mutate)
+
+
+class FirestoreEncounterRepository(EncounterRecordMixin, FirestoreRepository, EncounterRepository):
+    """Stores encounters in the top-level `encounters` collection, queried by practitioner with `array_contains`."""
+
+    collection_name = "encounters"
+    model = schemas.Encounter
+    id_field = "encounterId"
+
+    def list(
+        self,
+        patient_id: Optional[str] = None,
+        practitioner_id: Optional[str] = None,
+        start_from: Optional[datetime] = None,
+        start_to: Optional[datetime] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+    ) -> List[schemas.Encounter]:
+        # Note: These queries require composite indexes on (patientId, start desc)
+        # and (participantIds, start desc).
+        query = self._query()
+        if patient_id:
+            query = query.where(filter=FieldFilter("patientId", "==", patient_id))
+        if practitioner_id:
+            query = query.where(filter=FieldFilter("participantIds", "array_contains", practitioner_id))
+        if start_from:
+            query = query.where(filter=FieldFilter("start", ">=", start_from))
+        if start_to:
+            query = query.where(filter=FieldFilter("start", "<", start_to))
+        query = self._start_after(query.order_by("start", direction=firestore.Query.DESCENDING), after).limit(limit)
+        return [self._to_model(doc) for doc in query.stream()]
+
+    def link_observation(self, encounter_id: str, observation_id: str) -> schemas.Encounter:
+        return self._update(encounter_id, {"observationIds": firestore.ArrayUnion([observation_id])})
+
+    def unlink_observation(self, encounter_id: str, observation_id: str) -> schemas.Encounter:
+        encounter = self._require(encounter_id)
+        if observation_id not in encounter.observation_ids:
+            raise NotFoundError(f"Observation '{observation_id}' is not linked to this encounter.")
+        return self._update(encounter_id, {"observationIds": firestore.ArrayRemove([observation_id])})
@@ -0,0 +1,40 @@
+# Location: app/repositories/export_jobs.py
+
+from abc import ABC, abstractmethod
+from typing import Dict, Optional
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+
+
+class ExportJobRepository(ABC):
+    """Storage interface for FHIR bulk export jobs."""
+
+    @abstractmethod
+    def create(self, job_in: schemas.ExportJobCreate) -> schemas.ExportJob:
+        """Stores a new job with status 'accepted'."""
+
+    @abstractmethod
+    def get(self, job_id: str) -> Optional[schemas.ExportJob]:
+        """Returns the job, or None if it does not exist."""
+
+    @abstractmethod
+    def update(self, job_id: str, changes: Dict) -> schemas.ExportJob:
+        """Applies raw field changes (by alias). Raises NotFoundError."""
+
+
+class FirestoreExportJobRepository(FirestoreRepository, ExportJobRepository):
+    """Stores export jobs in the top-level `exportJobs` collection."""
+
+    collection_name = "exportJobs"
+    model = schemas.ExportJob
+    id_field = "jobId"
+
+    def create(self, job_in: schemas.ExportJobCreate) -> schemas.ExportJob:
+        return self._create({**job_in.model_dump(by_alias=True), "status": "accepted", "output": []})
+
+    def get(self, job_id: str) -> Optional[schemas.ExportJob]:
+        return self._get(job_id)
+
+    def update(self, job_id: str, changes: Dict) -> schemas.ExportJob:
+        return self._update(job_id, changes)
@@ -0,0 +1,67 @@
+# Location: app/repositories/feature_flags.py
+
+from abc import ABC, abstractmethod
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.base import ConflictError, FirestoreRepository
+
+
+class FeatureFlagRepository(ABC):
+    """
+    Storage for the feature flags managed through /admin/flags, keyed by the
+    flag key. Flags apply to the whole deployment, so reads are never scoped
+    to a tenant.
+    """
+
+    @abstractmethod
+    def create(self, flag_in: schemas.FeatureFlagCreate, updated_by: str) -> schemas.FeatureFlag:
+        """Stores a new flag. Raises ConflictError if the key is taken."""
+
+    @abstractmethod
+    def get(self, key: str) -> Optional[schemas.FeatureFlag]:
+        """Returns the flag, or None if it is not stored."""
+
+    @abstractmethod
+    def list(self) -> List[schemas.FeatureFlag]:
+        """Returns every stored flag, by key."""
+
+    @abstractmethod
+    def update(self, key: str, flag_in: schemas.FeatureFlagUpdate, updated_by: str) -> schemas.FeatureFlag:
+        """Applies the fields set on `flag_in`. Raises NotFoundError."""
+
+    @abstractmethod
+    def delete(self, key: str) -> None:
+        """Removes the flag. Raises NotFoundError."""
+
+
+class FeatureFlagRecordMixin:
+    """Everything but the listing query, on top of the storage base class helpers."""
+
+    tenant_scoped = False
+
+    def create(self, flag_in: schemas.FeatureFlagCreate, updated_by: str) -> schemas.FeatureFlag:
+        if self._get(flag_in.key):
+            raise ConflictError(f"A flag with key '{flag_in.key}' already exists.")
+        data = {**flag_in.model_dump(by_alias=True, exclude={"key"}), "updatedBy": updated_by}
+        return self._create(data, record_id=flag_in.key)
+
+    def get(self, key: str) -> Optional[schemas.FeatureFlag]:
+        return self._get(key)
+
+    def update(self, key: str, flag_in: schemas.FeatureFlagUpdate, updated_by: str) -> schemas.FeatureFlag:
+        return self._update(key, {**flag_in.model_dump(by_alias=True, exclude_unset=True), "updatedBy": updated_by})
+
+    def delete(self, key: str) -> None:
+        self._delete(key)
+
+
+class FirestoreFeatureFlagRepository(FeatureFlagRecordMixin, FirestoreRepository, FeatureFlagRepository):
+    """Stores flags in the top-level `featureFlags` collection, one document per key."""
+
+    collection_name = "featureFlags"
+    model = schemas.FeatureFlag
+    id_field = "key"
+
+    def list(self) -> List[schemas.FeatureFlag]:
+        return sorted((self._to_model(doc) for doc in self._query().stream()), key=lambda flag: flag.key)
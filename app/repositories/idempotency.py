@@ -0,0 +1,146 @@
+# Location: app/repositories/idempotency.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, Optional
+
+from firebase_admin import firestore
+
+from app.api.v1 import schemas
+from app.repositories.base import ConflictError, FirestoreRepository, NotFoundError, to_firestore
+
+
+class KeyInUseError(Exception):
+    """Raised inside a claim transaction to abort it without writing."""
+
+
+class IdempotencyRepository(ABC):
+    """
+    Outcomes of requests sent with an Idempotency-Key, one record per
+    caller, route and key. A record is claimed while its request runs and
+    holds the response once it completes, until it expires.
+    """
+
+    @abstractmethod
+    def claim(
+        self, record_id: str, principal: str, route: str, key: str, fingerprint: str, lease_seconds: float,
+    ) -> Optional[schemas.IdempotencyRecord]:
+        """
+        Claims the key for a request for `lease_seconds`. Returns None if the
+        request should run: the key is new, or its record has expired.
+        Otherwise returns the existing record, processing or completed.
+        """
+
+    @abstractmethod
+    def complete(self, record_id: str, status_code: int, headers: Dict[str, str], body: str, ttl_seconds: float) -> None:
+        """Stores the response (body as base64, sealed as it is written) to replay for `ttl_seconds`."""
+
+    @abstractmethod
+    def release(self, record_id: str) -> None:
+        """Forgets a claimed request that did not complete, so a retry runs it again."""
+
+    @abstractmethod
+    def purge(self, before: datetime) -> int:
+        """Deletes records last updated before `before`. Returns the count."""
+
+
+class IdempotencyRecordMixin:
+    """
+    Everything, on top of the storage base class helpers: a new key creates
+    the record (a duplicate insert means another request got there first),
+    an expired one is taken over in a `_transform`.
+    """
+
+    tenant_scoped = False
+
+    def claim(
+        self, record_id: str, principal: str, route: str, key: str, fingerprint: str, lease_seconds: float,
+    ) -> Optional[schemas.IdempotencyRecord]:
+        now = datetime.now(timezone.utc)
+        claim = {
+            "principal": principal, "route": route, "key": key, "fingerprint": fingerprint, "status": "processing",
+            "expiresAt": now + timedelta(seconds=lease_seconds),
+            "responseStatus": None, "responseHeaders": {}, "responseBody": None,
+        }
+        if self._get(record_id) is None:
+            try:
+                self._create(claim, record_id=record_id)
+                return None
+            except ConflictError:
+                pass
+
+        existing: Optional[schemas.IdempotencyRecord] = None
+
+        def take(record: schemas.IdempotencyRecord) -> Dict[str, Any]:
+            nonlocal existing
+            if record.expires_at > now:
+                existing = record
+                raise KeyInUseError(record_id)
+            return claim
+
+        try:
+            self._transform(record_id, take)
+        except KeyInUseError:
+            return existing
+        except NotFoundError:
+            # Released between the read and the transaction.
+            try:
+                self._create(claim, record_id=record_id)
+            except ConflictError:
+                return self._get(record_id)
+        return None
+
+    def complete(self, record_id: str, status_code: int, headers: Dict[str, str], body: str, ttl_seconds: float) -> None:
+        self._update(record_id, {
+            "status": "completed",
+            "expiresAt": datetime.now(timezone.utc) + timedelta(seconds=ttl_seconds),
+            "responseStatus": status_code,
+            "responseHeaders": headers,
+            "responseBody": body,
+        })
+
+    def release(self, record_id: str) -> None:
+        try:
+            self._delete(record_id)
+        except NotFoundError:
+            pass
+
+    def purge(self, before: datetime) -> int:
+        return self._purge(before)
+
+
+class FirestoreIdempotencyRepository(IdempotencyRecordMixin, FirestoreRepository, IdempotencyRepository):
+    """
+    Stores records in the top-level `idempotencyRecords` collection, keyed
+    by record ID. `_create` overwrites existing documents in Firestore, so
+    the claim reads and writes in one transaction, as job locks do.
+    """
+
+    collection_name = "idempotencyRecords"
+    model = schemas.IdempotencyRecord
+    id_field = "recordId"
+
+    def claim(
+        self, record_id: str, principal: str, route: str, key: str, fingerprint: str, lease_seconds: float,
+    ) -> Optional[schemas.IdempotencyRecord]:
+        now = datetime.now(timezone.utc)
+        record_ref = self.collection.document(record_id)
+        claim = {
+            "principal": principal, "route": route, "key": key, "fingerprint": fingerprint, "status": "processing",
+            "expiresAt": now + timedelta(seconds=lease_seconds),
+            "responseStatus": None, "responseHeaders": {}, "responseBody": None, "updatedAt": now,
+        }
+
+        @firestore.transactional
+        def take(transaction) -> Optional[schemas.IdempotencyRecord]:
+            snapshot = record_ref.get(transaction=transaction)
+            if not snapshot.exists:
+                transaction.create(record_ref, to_firestore({**claim, "createdAt": now}))
+                return None
+            record = self._to_model(snapshot, reseal=False)
+            if record.expires_at > now:
+                return record
+            transaction.update(record_ref, to_firestore(claim))
+            return None
+
+        return take(self.db.transaction())
@@ -0,0 +1,56 @@
+# Location: app/repositories/immunizations.py
+
+from abc import ABC, abstractmethod
+from typing import List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+from firebase_admin import firestore
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+
+
+class ImmunizationRepository(ABC):
+    """Storage interface for administered (or explicitly not given) vaccine doses."""
+
+    @abstractmethod
+    def create(self, patient_id: str, immunization_in: schemas.ImmunizationCreate, recorded_by: str) -> schemas.Immunization:
+        """Stores a new immunization record for the patient."""
+
+    @abstractmethod
+    def get(self, immunization_id: str) -> Optional[schemas.Immunization]:
+        """Returns the record, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, patient_id: str, vaccine_code: Optional[str] = None, limit: int = 100, after: Optional[str] = None) -> List[schemas.Immunization]:
+        """Returns the patient's records, most recent dose first, optionally for one CVX code."""
+
+    @abstractmethod
+    def update(self, immunization_id: str, immunization_in: schemas.ImmunizationUpdate) -> schemas.Immunization:
+        """Applies the fields set on `immunization_in`. Raises NotFoundError."""
+
+
+class FirestoreImmunizationRepository(FirestoreRepository, ImmunizationRepository):
+    """Stores immunizations in the top-level `immunizations` collection, keyed to the patient by `patientId`."""
+
+    collection_name = "immunizations"
+    model = schemas.Immunization
+    id_field = "immunizationId"
+
+    def create(self, patient_id: str, immunization_in: schemas.ImmunizationCreate, recorded_by: str) -> schemas.Immunization:
+        return self._create({**immunization_in.model_dump(by_alias=True), "patientId": patient_id, "recordedBy": recorded_by})
+
+    def get(self, immunization_id: str) -> Optional[schemas.Immunization]:
+        return self._get(immunization_id)
+
+    def list(self, patient_id: str, vaccine_code: Optional[str] = None, limit: int = 100, after: Optional[str] = None) -> List[schemas.Immunization]:
+        # Note: These queries require composite indexes on (patientId, occurrenceDate desc)
+        # and (patientId, vaccineCode.code, occurrenceDate desc).
+        query = self._query().where(filter=FieldFilter("patientId", "==", patient_id))
+        if vaccine_code:
+            query = query.where(filter=FieldFilter("vaccineCode.code", "==", vaccine_code))
+        query = self._start_after(query.order_by("occurrenceDate", direction=firestore.Query.DESCENDING), after).limit(limit)
+        return [self._to_model(doc) for doc in query.stream()]
+
+    def update(self, immunization_id: str, immunization_in: schemas.ImmunizationUpdate) -> schemas.Immunization:
+        return self._update(immunization_id, immunization_in.model_dump(by_alias=True, exclude_unset=True))
@@ -0,0 +1,40 @@
+# Location: app/repositories/import_jobs.py
+
+from abc import ABC, abstractmethod
+from typing import Dict, Optional
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+
+
+class ImportJobRepository(ABC):
+    """Storage interface for bulk NDJSON import jobs."""
+
+    @abstractmethod
+    def create(self, job_id: str, job_in: schemas.ImportJobCreate) -> schemas.ImportJob:
+        """Stores a new job with status 'accepted' under `job_id`, the ID its uploaded file is stored under."""
+
+    @abstractmethod
+    def get(self, job_id: str) -> Optional[schemas.ImportJob]:
+        """Returns the job, or None if it does not exist."""
+
+    @abstractmethod
+    def update(self, job_id: str, changes: Dict) -> schemas.ImportJob:
+        """Applies raw field changes (by alias). Raises NotFoundError."""
+
+
+class FirestoreImportJobRepository(FirestoreRepository, ImportJobRepository):
+    """Stores import jobs in the top-level `importJobs` collection."""
+
+    collection_name = "importJobs"
+    model = schemas.ImportJob
+    id_field = "jobId"
+
+    def create(self, job_id: str, job_in: schemas.ImportJobCreate) -> schemas.ImportJob:
+        return self._create({**job_in.model_dump(by_alias=True), "status": "accepted", "offset": 0, "linesRead": 0, "imported": {}, "skipped": 0, "failed": 0}, record_id=job_id)
+
+    def get(self, job_id: str) -> Optional[schemas.ImportJob]:
+        return self._get(job_id)
+
+    def update(self, job_id: str, changes: Dict) -> schemas.ImportJob:
+        return self._update(job_id, changes)
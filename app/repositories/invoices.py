@@ -0,0 +1,106 @@
+# Location: app/repositories/invoices.py
+
+import uuid
+from abc import ABC, abstractmethod
+from typing import Any, Dict, List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+from firebase_admin import firestore
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+from app.services.invoices import INVOICE_STATUSES, payment_changes, statement_number
+
+
+class InvoiceRepository(ABC):
+    """Storage interface for patient invoices and the payments taken against them."""
+
+    @abstractmethod
+    def create(self, fields: Dict[str, Any], created_by: str) -> schemas.Invoice:
+        """Stores a draft invoice with the fields `build_invoice` worked out (see app/services/invoices.py)."""
+
+    @abstractmethod
+    def get(self, invoice_id: str) -> Optional[schemas.Invoice]:
+        """Returns the invoice, or None if it does not exist."""
+
+    @abstractmethod
+    def list(
+        self,
+        patient_id: Optional[str] = None,
+        claim_id: Optional[str] = None,
+        statuses: Optional[List[str]] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+    ) -> List[schemas.Invoice]:
+        """Returns invoices newest first, optionally of a patient or claim and with one of `statuses`."""
+
+    @abstractmethod
+    def update(self, invoice_id: str, changes: Dict[str, Any]) -> schemas.Invoice:
+        """Applies raw field changes (by alias) that leave the status as it is. Raises NotFoundError."""
+
+    @abstractmethod
+    def change_status(self, invoice_id: str, status: str, changes: Optional[Dict[str, Any]] = None) -> schemas.Invoice:
+        """
+        Moves the invoice to `status`, applying `changes`. Raises
+        NotFoundError, or InvalidTransitionError if the invoice's current
+        status does not lead there.
+        """
+
+    @abstractmethod
+    def record_payment(self, invoice_id: str, payment_in: schemas.InvoicePaymentCreate, recorded_by: str, source: str = "manual") -> schemas.Invoice:
+        """
+        Adds the payment and takes it off the balance, in one write with the
+        check that it fits. Raises NotFoundError or InvalidInvoiceError.
+        """
+
+
+class InvoiceRecordMixin:
+    """Everything but the listing query, on top of the storage base class helpers."""
+
+    def create(self, fields: Dict[str, Any], created_by: str) -> schemas.Invoice:
+        invoice_id = uuid.uuid4().hex
+        data = {**fields, "statementNumber": statement_number(invoice_id), "status": "draft", "payments": [], "createdBy": created_by}
+        return self._create(data, record_id=invoice_id)
+
+    def get(self, invoice_id: str) -> Optional[schemas.Invoice]:
+        return self._get(invoice_id)
+
+    def update(self, invoice_id: str, changes: Dict[str, Any]) -> schemas.Invoice:
+        return self._update(invoice_id, changes)
+
+    def change_status(self, invoice_id: str, status: str, changes: Optional[Dict[str, Any]] = None) -> schemas.Invoice:
+        def mutate(invoice: schemas.Invoice) -> Dict[str, Any]:
+            INVOICE_STATUSES.ensure(invoice.status, status)
+            return {**(changes or {}), "status": status}
+        return self._transform(invoice_id, mutate)
+
+    def record_payment(self, invoice_id: str, payment_in: schemas.InvoicePaymentCreate, recorded_by: str, source: str = "manual") -> schemas.Invoice:
+        return self._transform(invoice_id, lambda invoice: payment_changes(invoice, payment_in, recorded_by, source))
+
+
+class FirestoreInvoiceRepository(InvoiceRecordMixin, FirestoreRepository, InvoiceRepository):
+    """Stores invoices in the top-level `invoices` collection."""
+
+    collection_name = "invoices"
+    model = schemas.Invoice
+    id_field = "invoiceId"
+
+    def list(
+        self,
+        patient_id: Optional[str] = None,
+        claim_id: Optional[str] = None,
+        statuses: Optional[List[str]] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+    ) -> List[schemas.Invoice]:
+        # Note: These queries require composite indexes on (patientId, createdAt desc),
+        # (claimId, createdAt desc) and (status, createdAt desc).
+        query = self._query()
+        if patient_id:
+            query = query.where(filter=FieldFilter("patientId", "==", patient_id))
+        if claim_id:
+            query = query.where(filter=FieldFilter("claimId", "==", claim_id))
+        if statuses:
+            query = query.where(filter=FieldFilter("status", "in", statuses))
+        query = self._start_after(query.order_by("createdAt", direction=firestore.Query.DESCENDING), after).limit(limit)
+        return [self._to_model(doc) for doc in query.stream()]
@@ -0,0 +1,86 @@
+# Location: app/repositories/lab_results.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime
+from typing import List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+from firebase_admin import firestore
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+from app.services.lab_results import is_abnormal
+
+
+class LabResultRepository(ABC):
+    """Storage interface for laboratory results (single tests and panels)."""
+
+    @abstractmethod
+    def create(self, patient_id: str, lab_in: schemas.LabResultCreate, recorded_by: str) -> schemas.LabResult:
+        """Stores a new, already interpreted lab result for the patient."""
+
+    @abstractmethod
+    def get(self, lab_result_id: str) -> Optional[schemas.LabResult]:
+        """Returns the result, or None if it does not exist."""
+
+    @abstractmethod
+    def list(
+        self,
+        patient_id: str,
+        code: Optional[str] = None,
+        start_from: Optional[datetime] = None,
+        start_to: Optional[datetime] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+    ) -> List[schemas.LabResult]:
+        """
+        Returns the patient's results newest first, optionally only those whose
+        panel or any analyte has `code`, collected within [start_from, start_to).
+        """
+
+
+class FirestoreLabResultRepository(FirestoreRepository, LabResultRepository):
+    """
+    Stores lab results in the top-level `labResults` collection.
+    A denormalised `codes` array holds the panel code and every analyte code
+    so results can be queried by either with `array_contains`.
+    """
+
+    collection_name = "labResults"
+    model = schemas.LabResult
+    id_field = "labResultId"
+
+    def create(self, patient_id: str, lab_in: schemas.LabResultCreate, recorded_by: str) -> schemas.LabResult:
+        data = lab_in.model_dump(by_alias=True)
+        codes = ([lab_in.panel_code] if lab_in.panel_code else []) + [a.code for a in lab_in.analytes]
+        data.update({
+            "patientId": patient_id,
+            "codes": codes,
+            "abnormal": is_abnormal(lab_in.analytes),
+            "recordedBy": recorded_by,
+        })
+        return self._create(data)
+
+    def get(self, lab_result_id: str) -> Optional[schemas.LabResult]:
+        return self._get(lab_result_id)
+
+    def list(
+        self,
+        patient_id: str,
+        code: Optional[str] = None,
+        start_from: Optional[datetime] = None,
+        start_to: Optional[datetime] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+    ) -> List[schemas.LabResult]:
+        # Note: These queries require composite indexes on (patientId, effectiveAt desc)
+        # and (patientId, codes, effectiveAt desc).
+        query = self._query().where(filter=FieldFilter("patientId", "==", patient_id))
+        if code:
+            query = query.where(filter=FieldFilter("codes", "array_contains", code))
+        if start_from:
+            query = query.where(filter=FieldFilter("effectiveAt", ">=", start_from))
+        if start_to:
+            query = query.where(filter=FieldFilter("effectiveAt", "<", start_to))
+        query = self._start_after(query.order_by("effectiveAt", direction=firestore.Query.DESCENDING), after).limit(limit)
+        return [self._to_model(doc) for doc in query.stream()]
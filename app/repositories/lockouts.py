@@ -0,0 +1,93 @@
+# Location: app/repositories/lockouts.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime
+from typing import Any, Callable, Dict, List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+from firebase_admin import firestore
+
+from app.api.v1 import schemas
+from app.repositories.base import ConflictError, FirestoreRepository
+
+
+class LockoutRepository(ABC):
+    """
+    Storage for failed sign-in attempts, one record per account or source
+    address, keyed by lockout ID ('account:<uid>', 'address:<ip>').
+    """
+
+    @abstractmethod
+    def get(self, lockout_id: str) -> Optional[schemas.Lockout]:
+        """Returns the record, or None if there were no recent failures."""
+
+    @abstractmethod
+    def record_failure(
+        self,
+        lockout_id: str,
+        subject_type: str,
+        subject: str,
+        apply: Callable[[schemas.Lockout], Dict[str, Any]],
+    ) -> schemas.Lockout:
+        """
+        Creates the record if needed, then applies the changes `apply` makes
+        of the stored record in one transaction, so concurrent failures are
+        all counted.
+        """
+
+    @abstractmethod
+    def list_locked(self, now: datetime, limit: int = 100) -> List[schemas.Lockout]:
+        """Returns the records locked out past `now`, longest lockout first."""
+
+    @abstractmethod
+    def clear(self, lockout_id: str) -> None:
+        """Forgets the failures and any lockout. Raises NotFoundError."""
+
+    @abstractmethod
+    def purge(self, before: datetime) -> int:
+        """Deletes records last changed before `before`; returns how many."""
+
+
+class LockoutRecordMixin:
+    """
+    The operations, on top of the storage base class helpers. Attempts are
+    refused before any organization is known, so records are not
+    tenant-scoped.
+    """
+
+    tenant_scoped = False
+
+    def get(self, lockout_id: str) -> Optional[schemas.Lockout]:
+        return self._get(lockout_id)
+
+    def record_failure(
+        self,
+        lockout_id: str,
+        subject_type: str,
+        subject: str,
+        apply: Callable[[schemas.Lockout], Dict[str, Any]],
+    ) -> schemas.Lockout:
+        if self._get(lockout_id) is None:
+            try:
+                self._create({"subjectType": subject_type, "subject": subject, "failures": 0}, record_id=lockout_id)
+            except ConflictError:
+                pass  # Created by a concurrent failure; count this one on top.
+        return self._transform(lockout_id, apply)
+
+    def clear(self, lockout_id: str) -> None:
+        self._delete(lockout_id)
+
+    def purge(self, before: datetime) -> int:
+        return self._purge(before)
+
+
+class FirestoreLockoutRepository(LockoutRecordMixin, FirestoreRepository, LockoutRepository):
+    """Stores records in the top-level `lockouts` collection."""
+
+    collection_name = "lockouts"
+    model = schemas.Lockout
+    id_field = "lockoutId"
+
+    def list_locked(self, now: datetime, limit: int = 100) -> List[schemas.Lockout]:
+        query = self._query().where(filter=FieldFilter("lockedUntil", ">", now))
+        return self._fetch(query.order_by("lockedUntil", direction=firestore.Query.DESCENDING), limit)
@@ -0,0 +1,67 @@
+# Location: app/repositories/magic_links.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime, timezone
+from typing import Any, Dict, Optional
+
+from app.api.v1 import schemas
+from app.repositories.base import ConflictError, FirestoreRepository
+
+
+class MagicLinkRepository(ABC):
+    """
+    Storage for emailed sign-in links, keyed by the link ID their tokens
+    carry. A record is what makes a link single-use: the token is only
+    accepted while its record is pending.
+    """
+
+    @abstractmethod
+    def create(self, link_id: str, uid: str, email: str, expires_at: datetime, requested_from: Optional[str] = None) -> schemas.MagicLinkRecord:
+        """Stores a new, pending link."""
+
+    @abstractmethod
+    def get(self, link_id: str) -> Optional[schemas.MagicLinkRecord]:
+        """Returns the link, or None if it does not exist."""
+
+    @abstractmethod
+    def redeem(self, link_id: str) -> schemas.MagicLinkRecord:
+        """Marks the link used. Raises ConflictError if it already was, and NotFoundError."""
+
+    @abstractmethod
+    def purge(self, before: datetime) -> int:
+        """Deletes links that expired before `before`; returns how many."""
+
+
+class MagicLinkRecordMixin:
+    """
+    The operations, on top of the storage base class helpers. Links are
+    redeemed before any organization is known, so they are not
+    tenant-scoped; they belong to their `uid`.
+    """
+
+    tenant_scoped = False
+
+    def create(self, link_id: str, uid: str, email: str, expires_at: datetime, requested_from: Optional[str] = None) -> schemas.MagicLinkRecord:
+        data = {"uid": uid, "email": email, "status": "pending", "expiresAt": expires_at, "requestedFrom": requested_from}
+        return self._create(data, record_id=link_id)
+
+    def get(self, link_id: str) -> Optional[schemas.MagicLinkRecord]:
+        return self._get(link_id)
+
+    def redeem(self, link_id: str) -> schemas.MagicLinkRecord:
+        def use(record: schemas.MagicLinkRecord) -> Dict[str, Any]:
+            if record.status != "pending":
+                raise ConflictError(f"Magic link '{link_id}' was already used.")
+            return {"status": "used", "usedAt": datetime.now(timezone.utc)}
+        return self._transform(link_id, use)
+
+    def purge(self, before: datetime) -> int:
+        return self._purge(before, field="expiresAt")
+
+
+class FirestoreMagicLinkRepository(MagicLinkRecordMixin, FirestoreRepository, MagicLinkRepository):
+    """Stores links in the top-level `magicLinks` collection."""
+
+    collection_name = "magicLinks"
+    model = schemas.MagicLinkRecord
+    id_field = "linkId"
@@ -0,0 +1,119 @@
+# Location: app/repositories/medications.py
+
+from abc import ABC, abstractmethod
+from typing import Any, Dict, Iterator, List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+from firebase_admin import firestore
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+
+
+class MedicationRepository(ABC):
+    """Storage interface for a patient's medication list."""
+
+    @abstractmethod
+    def create(self, patient_id: str, medication_in: schemas.MedicationCreate) -> schemas.Medication:
+        """Stores a new medication for the patient."""
+
+    @abstractmethod
+    def get(self, medication_id: str) -> Optional[schemas.Medication]:
+        """Returns the medication, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, patient_id: str, status: Optional[str] = None, limit: int = 100) -> List[schemas.Medication]:
+        """Returns the patient's medications, optionally filtered by status."""
+
+    @abstractmethod
+    def update(self, medication_id: str, medication_in: schemas.MedicationUpdate) -> schemas.Medication:
+        """Applies the fields set on `medication_in`. Raises NotFoundError."""
+
+    @abstractmethod
+    def record_refill(self, medication_id: str) -> schemas.Medication:
+        """Counts one more used refill against the medication. Raises NotFoundError."""
+
+
+class RefillRequestRepository(ABC):
+    """Storage interface for refill requests raised against a medication."""
+
+    @abstractmethod
+    def create(self, medication: schemas.Medication, request_in: schemas.RefillRequestCreate, requested_by: str) -> schemas.RefillRequest:
+        """Stores a new request in 'requested' status."""
+
+    @abstractmethod
+    def get(self, refill_request_id: str) -> Optional[schemas.RefillRequest]:
+        """Returns the request, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, medication_id: str, statuses: Optional[List[str]] = None, limit: int = 30, after: Optional[str] = None) -> List[schemas.RefillRequest]:
+        """Returns the medication's requests newest first, optionally only those in `statuses`."""
+
+    @abstractmethod
+    def stream_by_status(self, statuses: List[str]) -> Iterator[schemas.RefillRequest]:
+        """Yields every request in one of `statuses`, across medications, in no particular order."""
+
+    @abstractmethod
+    def update(self, refill_request_id: str, changes: Dict[str, Any]) -> schemas.RefillRequest:
+        """Applies a partial update (keys are field aliases). Raises NotFoundError."""
+
+
+class FirestoreMedicationRepository(FirestoreRepository, MedicationRepository):
+    """Stores medications in the top-level `medications` collection, keyed to the patient by `patientId`."""
+
+    collection_name = "medications"
+    model = schemas.Medication
+    id_field = "medicationId"
+
+    def create(self, patient_id: str, medication_in: schemas.MedicationCreate) -> schemas.Medication:
+        return self._create({**medication_in.model_dump(by_alias=True), "patientId": patient_id, "refillsUsed": 0})
+
+    def get(self, medication_id: str) -> Optional[schemas.Medication]:
+        return self._get(medication_id)
+
+    def list(self, patient_id: str, status: Optional[str] = None, limit: int = 100) -> List[schemas.Medication]:
+        query = self._query().where(filter=FieldFilter("patientId", "==", patient_id))
+        if status:
+            query = query.where(filter=FieldFilter("status", "==", status))
+        return [self._to_model(doc) for doc in query.limit(limit).stream()]
+
+    def update(self, medication_id: str, medication_in: schemas.MedicationUpdate) -> schemas.Medication:
+        return self._update(medication_id, medication_in.model_dump(by_alias=True, exclude_unset=True))
+
+    def record_refill(self, medication_id: str) -> schemas.Medication:
+        # Increment server-side so concurrent approvals are both counted.
+        return self._update(medication_id, {"refillsUsed": firestore.Increment(1)})
+
+
+class FirestoreRefillRequestRepository(FirestoreRepository, RefillRequestRepository):
+    """Stores refill requests in the top-level `refillRequests` collection."""
+
+    collection_name = "refillRequests"
+    model = schemas.RefillRequest
+    id_field = "refillRequestId"
+
+    def create(self, medication: schemas.Medication, request_in: schemas.RefillRequestCreate, requested_by: str) -> schemas.RefillRequest:
+        return self._create({
+            **request_in.model_dump(by_alias=True),
+            "patientId": medication.patient_id,
+            "medicationId": medication.medication_id,
+            "status": "requested",
+            "requestedBy": requested_by,
+        })
+
+    def get(self, refill_request_id: str) -> Optional[schemas.RefillRequest]:
+        return self._get(refill_request_id)
+
+    def list(self, medication_id: str, statuses: Optional[List[str]] = None, limit: int = 30, after: Optional[str] = None) -> List[schemas.RefillRequest]:
+        query = self._query().where(filter=FieldFilter("medicationId", "==", medication_id))
+        if statuses:
+            query = query.where(filter=FieldFilter("status", "in", list(statuses)))
+        query = self._start_after(query.order_by("createdAt", direction=firestore.Query.DESCENDING), after).limit(limit)
+        return [self._to_model(doc) for doc in query.stream()]
+
+    def stream_by_status(self, statuses: List[str]) -> Iterator[schemas.RefillRequest]:
+        for doc in self._query().where(filter=FieldFilter("status", "in", list(statuses))).stream():
+            yield self._to_model(doc)
+
+    def update(self, refill_request_id: str, changes: Dict[str, Any]) -> schemas.RefillRequest:
+        return self._update(refill_request_id, changes)
@@ -0,0 +1,602 @@
+# Location: app/repositories/memory.py
+
+import copy
+import threading
+import uuid
+from contextlib import contextmanager
+from datetime import datetime, timedelta, timezone
+from typing import Any, Callable, Dict, Iterator, List, Optional, Sequence, Tuple
+
+from app.api.v1 import schemas
+from app.repositories.base import (
+    DELETED_FIELD,
+    TENANT_FIELD,
+    VERSION_FIELD,
+    ConflictError,
+    NotFoundError,
+    Reseal,
+    StaleCursorError,
+    _expected_version,
+    check_version,
+    including_deleted,
+)
+from app.repositories.devices import DeviceRepository
+from app.repositories.postgres.allergies import PostgresAllergyRepository
+from app.repositories.postgres.api_keys import PostgresApiKeyRepository
+from app.repositories.postgres.appointment_reminders import PostgresAppointmentReminderRepository
+from app.repositories.postgres.appointments import PostgresAppointmentRepository
+from app.repositories.postgres.audit_events import PostgresAuditEventRepository
+from app.repositories.postgres.audit_exports import PostgresAuditExportRepository
+from app.repositories.postgres.base import PostgresRepository, to_json
+from app.repositories.postgres.care_plans import PostgresCarePlanRepository
+from app.repositories.postgres.care_teams import PostgresCareTeamRepository
+from app.repositories.postgres.claims import PostgresChargeItemRepository, PostgresClaimRepository
+from app.repositories.postgres.consents import PostgresConsentDocumentRepository, PostgresConsentRepository
+from app.repositories.postgres.coverage import PostgresCoverageRepository, PostgresEligibilityCheckRepository
+from app.repositories.postgres.custom_roles import PostgresCustomRoleRepository
+from app.repositories.postgres.encounters import PostgresEncounterRepository
+from app.repositories.postgres.export_jobs import PostgresExportJobRepository
+from app.repositories.postgres.feature_flags import PostgresFeatureFlagRepository
+from app.repositories.postgres.idempotency import PostgresIdempotencyRepository
+from app.repositories.postgres.immunizations import PostgresImmunizationRepository
+from app.repositories.postgres.import_jobs import PostgresImportJobRepository
+from app.repositories.postgres.invoices import PostgresInvoiceRepository
+from app.repositories.postgres.lab_results import PostgresLabResultRepository
+from app.repositories.postgres.lockouts import PostgresLockoutRepository
+from app.repositories.postgres.magic_links import PostgresMagicLinkRepository
+from app.repositories.postgres.medications import PostgresMedicationRepository, PostgresRefillRequestRepository
+from app.repositories.postgres.mfa import PostgresMfaEnrollmentRepository
+from app.repositories.postgres.notifications import PostgresNotificationRepository
+from app.repositories.postgres.observations import PostgresObservationRepository
+from app.repositories.postgres.organizations import PostgresOrganizationRepository
+from app.repositories.postgres.outbox import PostgresOutboxRepository
+from app.repositories.postgres.patient_documents import PostgresPatientDocumentRepository
+from app.repositories.postgres.patients import PostgresPatientRepository
+from app.repositories.postgres.practitioners import PostgresPractitionerRepository
+from app.repositories.postgres.push_tokens import PostgresPushTokenRepository
+from app.repositories.postgres.retention import PostgresPurgeManifestRepository, PostgresRetentionPolicyRepository
+from app.repositories.postgres.scheduler import PostgresJobLockRepository, PostgresJobRunRepository
+from app.repositories.postgres.sessions import PostgresSessionRepository
+from app.repositories.postgres.stream_events import PostgresStreamEventRepository
+from app.repositories.postgres.telehealth_sessions import PostgresTelehealthSessionRepository
+from app.repositories.postgres.webhooks import PostgresWebhookDeliveryRepository, PostgresWebhookSubscriptionRepository
+from app.repositories.postgres.wound_images import PostgresWoundImageRepository
+from app.search.filters import text_matches
+
+# The in-memory repositories (STORE=memory) are for local development and
+# tests: nothing is persisted and every worker process has its own data, so
+# run a single worker. They reuse the PostgreSQL repositories unchanged and
+# only replace the table access underneath, so rows have the same shape
+#   {"id": ..., "data": {...JSON...}, "created_at": datetime, "updated_at": datetime}
+# and filters, ordering and text comparison behave as they do in PostgreSQL.
+_COLUMNS = {"createdAt": "created_at", "updatedAt": "updated_at"}
+_MISSING = object()
+
+
+class MemoryStore:
+    """Holds the rows of every table, keyed by table name and row ID."""
+
+    def __init__(self):
+        self.tables: Dict[str, Dict[str, Dict[str, Any]]] = {}
+        self.lock = threading.RLock()
+
+    def table(self, name: str) -> Dict[str, Dict[str, Any]]:
+        return self.tables.setdefault(name, {})
+
+    def clear(self) -> None:
+        with self.lock:
+            self.tables.clear()
+
+    @contextmanager
+    def transaction(self):
+        """
+        Holds the lock for the enclosed block and restores every table if it
+        raises, the in-memory counterpart of `app.core.postgres.transaction`.
+        """
+        with self.lock:
+            snapshot = copy.deepcopy(self.tables)
+            try:
+                yield
+            except BaseException:
+                self.tables.clear()
+                self.tables.update(snapshot)
+                raise
+
+
+_store = MemoryStore()
+
+
+def get_memory_store() -> MemoryStore:
+    """Returns the process-wide store used by the STORE=memory dependencies."""
+    return _store
+
+
+def _value(row: Dict[str, Any], field: str) -> Any:
+    if field in _COLUMNS:
+        return row[_COLUMNS[field]]
+    value = row["data"]
+    for part in field.split("."):
+        if not isinstance(value, dict) or part not in value:
+            return _MISSING
+        value = value[part]
+    return value
+
+
+def _contains(data: Any, match: Any) -> bool:
+    """JSONB containment (`data @> match`) for the shapes `Query` produces."""
+    if isinstance(match, dict):
+        return isinstance(data, dict) and all(k in data and _contains(data[k], v) for k, v in match.items())
+    if isinstance(match, list):
+        return isinstance(data, list) and all(any(_contains(d, m) for d in data) for m in match)
+    return data == match
+
+
+def _compare(left: Any, op: str, right: Any) -> bool:
+    if left is _MISSING or left is None:
+        return False
+    if op == "!=":
+        return left != right
+    if op == "<":
+        return left < right
+    if op == "<=":
+        return left <= right
+    if op == ">":
+        return left > right
+    return left >= right
+
+
+class MemoryQuery:
+    """The `Query` builder's interface, evaluated over a `MemoryStore` table."""
+
+    def __init__(self, repo: "MemoryRepository"):
+        self.repo = repo
+        self._filters: List[Callable[[Dict[str, Any]], bool]] = []
+        self._order: Optional[Tuple[str, bool]] = None
+        self._limit: Optional[int] = None
+        self._after: Optional[str] = None
+
+    def where(self, field: str, op: str, value: Any) -> "MemoryQuery":
+        if op == "==":
+            encoded = to_json(value)
+            self._filters.append(lambda row: _contains(_value(row, field), encoded))
+        elif op == "array_contains":
+            encoded = to_json(value)
+            self._filters.append(lambda row: _contains(_value(row, field), [encoded]))
+        elif op == "in":
+            encoded = [to_json(v) for v in value]
+            self._filters.append(lambda row: _value(row, field) in encoded)
+        elif op in ("!=", "<", "<=", ">", ">="):
+            encoded = value if field in _COLUMNS else to_json(value)
+            self._filters.append(lambda row: _compare(_value(row, field), op, encoded))
+        else:
+            raise ValueError(f"Unsupported operator '{op}'")
+        return self
+
+    def where_text(self, fields: Sequence[str], op: str, text: str) -> "MemoryQuery":
+        if op not in ("contains", "starts_with"):
+            raise ValueError(f"Unsupported text operator '{op}'")
+        self._filters.append(lambda row: any(text_matches(_value(row, field), op, text) for field in fields))
+        return self
+
+    def order_by(self, field: str, descending: bool = False) -> "MemoryQuery":
+        self._order = (field, descending)
+        return self
+
+    def limit(self, count: int) -> "MemoryQuery":
+        self._limit = count
+        return self
+
+    def start_after(self, record_id: Optional[str]) -> "MemoryQuery":
+        self._after = record_id or None
+        return self
+
+    def _rows(self) -> List[Dict[str, Any]]:
+        with self.repo.store.lock:
+            rows = [copy.deepcopy(row) for row in self.repo.rows.values()]
+            anchor = copy.deepcopy(self.repo.rows.get(self._after)) if self._after else None
+        rows = [row for row in rows if all(f(row) for f in self._filters)]
+        if self._order:
+            field, descending = self._order
+            present = [row for row in rows if _value(row, field) not in (_MISSING, None)]
+            absent = [row for row in rows if _value(row, field) in (_MISSING, None)]
+            present.sort(key=lambda row: (_value(row, field), row["id"]), reverse=descending)
+            absent.sort(key=lambda row: row["id"], reverse=descending)
+            # PostgreSQL sorts NULLs last ascending and first descending.
+            rows = absent + present if descending else present + absent
+            if self._after:
+                if anchor is None or self.repo._foreign(anchor["data"]):
+                    raise StaleCursorError(f"{self.repo.model.__name__} '{self._after}' no longer exists.")
+                # Like the row comparison in SQL, rows without the field never follow the anchor.
+                key = (_value(anchor, field), anchor["id"])
+                rows = [
+                    row for row in present
+                    if key[0] not in (_MISSING, None)
+                    and ((_value(row, field), row["id"]) < key if descending else (_value(row, field), row["id"]) > key)
+                ]
+        elif self._limit is not None or self._after:
+            rows.sort(key=lambda row: row["id"])
+            if self._after:
+                rows = [row for row in rows if row["id"] > self._after]
+        return rows[:self._limit] if self._limit is not None else rows
+
+    def fetch(self) -> List[Any]:
+        return [self.repo._to_model(row) for row in self._rows()]
+
+    def stream(self) -> Iterator[Any]:
+        return iter(self.fetch())
+
+
+class MemoryRepository(PostgresRepository):
+    """
+    Replaces the table access of a `PostgresRepository` with a `MemoryStore`.
+    Subclasses list it before the PostgreSQL repository they reuse, e.g.
+    `class MemoryPatientRepository(MemoryRepository, PostgresPatientRepository)`.
+    """
+
+    def __init__(self, store: MemoryStore):
+        self.store = store
+
+    @property
+    def rows(self) -> Dict[str, Dict[str, Any]]:
+        return self.store.table(self.table)
+
+    def _query(self) -> MemoryQuery:
+        query = MemoryQuery(self)
+        tenant = self._tenant()
+        if tenant:
+            query.where(TENANT_FIELD, "==", tenant)
+        if self._hides_deleted():
+            query._filters.append(lambda row: _value(row, DELETED_FIELD) in (_MISSING, None))
+        return query
+
+    def _get(self, record_id: str):
+        with self.store.lock:
+            row = copy.deepcopy(self.rows.get(record_id))
+        return self._to_model(row) if row and not self._hidden(row["data"]) else None
+
+    def _get_many(self, record_ids: List[str]) -> Dict[str, Any]:
+        with self.store.lock:
+            rows = [copy.deepcopy(self.rows[record_id]) for record_id in record_ids if record_id in self.rows]
+        return {row["id"]: self._to_model(row) for row in rows if not self._hidden(row["data"])}
+
+    def _create(self, data: Dict[str, Any], record_id: Optional[str] = None):
+        now = datetime.now(timezone.utc)
+        record_id = record_id or uuid.uuid4().hex
+        with self.store.lock:
+            if record_id in self.rows:
+                raise ConflictError(f"{self.model.__name__} conflicts with an existing record: {self.table}_pkey")
+            row = {"id": record_id, "data": to_json({VERSION_FIELD: 1, **self._sealed(self._stamped(data))}), "created_at": now, "updated_at": now}
+            self.rows[record_id] = row
+            return self._to_model(copy.deepcopy(row))
+
+    def _update(self, record_id: str, changes: Dict[str, Any]):
+        return self._transform(record_id, lambda _record: changes)
+
+    def _transform(self, record_id: str, mutate: Callable[[Any], Dict[str, Any]]):
+        with self.store.lock:
+            row = self.rows.get(record_id)
+            if not row or self._hidden(row["data"]):
+                raise NotFoundError(f"{self.model.__name__} '{record_id}' not found.")
+            version = row["data"].get(VERSION_FIELD, 0)
+            check_version(self.model.__name__, record_id, version, _expected_version(record_id))
+            changes = mutate(self._to_model(copy.deepcopy(row), reseal=False))
+            row["data"] = {**row["data"], **to_json(self._sealed(changes)), VERSION_FIELD: version + 1}
+            row["updated_at"] = datetime.now(timezone.utc)
+            return self._to_model(copy.deepcopy(row))
+
+    def _reseal(self, record_id: str, stale: Dict[str, Reseal]) -> None:
+        with self.store.lock:
+            row = self.rows.get(record_id)
+            for path, (stored, sealed) in stale.items():
+                *parents, leaf = path.split(".")
+                parent = row["data"] if row else None
+                for part in parents:
+                    parent = parent.get(part) if isinstance(parent, dict) else None
+                if isinstance(parent, dict) and parent.get(leaf) == stored:
+                    parent[leaf] = sealed
+
+    def _delete(self, record_id: str) -> None:
+        with self.store.lock:
+            row = self.rows.get(record_id)
+            if not row or self._hidden(row["data"]):
+                raise NotFoundError(f"{self.model.__name__} '{record_id}' not found.")
+            check_version(self.model.__name__, record_id, row["data"].get(VERSION_FIELD, 0), _expected_version(record_id))
+            del self.rows[record_id]
+
+    def _lease_due(self, limit: int, lease_seconds: float) -> List[Any]:
+        now = datetime.now(timezone.utc)
+        lease = {"nextAttemptAt": now + timedelta(seconds=lease_seconds)}
+        with self.store.lock:
+            due = (
+                self._query().where("status", "==", "pending").where("nextAttemptAt", "<=", now)
+                .order_by("nextAttemptAt").limit(limit)._rows()
+            )
+            return [self._update(row["id"], lease) for row in due]
+
+    def assign_tenant(self, tenant: str) -> int:
+        with self.store.lock:
+            rows = [row for row in self.rows.values() if not row["data"].get(TENANT_FIELD)]
+            for row in rows:
+                row["data"] = {**row["data"], TENANT_FIELD: tenant}
+        return len(rows)
+
+    def reassign_patient(self, source_patient_id: str, target_patient_id: str) -> int:
+        now = datetime.now(timezone.utc)
+        with self.store.lock, including_deleted():
+            moved = self._query().where("patientId", "==", source_patient_id)._rows()
+            for row in moved:
+                data = self.rows[row["id"]]["data"]
+                self.rows[row["id"]]["data"] = {**data, "patientId": target_patient_id, VERSION_FIELD: data.get(VERSION_FIELD, 0) + 1}
+                self.rows[row["id"]]["updated_at"] = now
+        return len(moved)
+
+    def _purge(self, before: datetime, match: Optional[Dict[str, Any]] = None, field: str = "updatedAt") -> int:
+        with self.store.lock:
+            query = self._query().where(field, "<", before)
+            for name, value in (match or {}).items():
+                query = query.where(name, "==", value)
+            purged = query._rows()
+            for row in purged:
+                del self.rows[row["id"]]
+        return len(purged)
+
+    def _anonymize(
+        self,
+        changes: Dict[str, Any],
+        before: datetime,
+        since: Optional[datetime] = None,
+        match: Optional[Dict[str, Any]] = None,
+        field: str = "updatedAt",
+    ) -> int:
+        now = datetime.now(timezone.utc)
+        with self.store.lock:
+            query = self._query().where(field, "<", before)
+            if since:
+                query = query.where(field, ">=", since)
+            for name, value in (match or {}).items():
+                query = query.where(name, "==", value)
+            matched = query._rows()
+            for row in matched:
+                data = self.rows[row["id"]]["data"]
+                for path, value in to_json(changes).items():
+                    *parents, leaf = path.split(".")
+                    parent = data
+                    for part in parents:
+                        parent = parent.setdefault(part, {})
+                    parent[leaf] = value
+                data[VERSION_FIELD] = data.get(VERSION_FIELD, 0) + 1
+                self.rows[row["id"]]["updated_at"] = now
+        return len(matched)
+
+
+class MemoryPatientRepository(MemoryRepository, PostgresPatientRepository):
+    pass
+
+
+class MemoryPractitionerRepository(MemoryRepository, PostgresPractitionerRepository):
+    pass
+
+
+class MemoryCareTeamRepository(MemoryRepository, PostgresCareTeamRepository):
+    pass
+
+
+class MemoryCarePlanRepository(MemoryRepository, PostgresCarePlanRepository):
+    pass
+
+
+class MemoryAppointmentRepository(MemoryRepository, PostgresAppointmentRepository):
+    pass
+
+
+class MemoryAppointmentReminderRepository(MemoryRepository, PostgresAppointmentReminderRepository):
+    pass
+
+
+class MemoryEncounterRepository(MemoryRepository, PostgresEncounterRepository):
+    pass
+
+
+class MemoryObservationRepository(MemoryRepository, PostgresObservationRepository):
+    pass
+
+
+class MemoryLabResultRepository(MemoryRepository, PostgresLabResultRepository):
+    pass
+
+
+class MemoryMedicationRepository(MemoryRepository, PostgresMedicationRepository):
+    pass
+
+
+class MemoryRefillRequestRepository(MemoryRepository, PostgresRefillRequestRepository):
+    pass
+
+
+class MemoryAllergyRepository(MemoryRepository, PostgresAllergyRepository):
+    pass
+
+
+class MemoryImmunizationRepository(MemoryRepository, PostgresImmunizationRepository):
+    pass
+
+
+class MemoryPatientDocumentRepository(MemoryRepository, PostgresPatientDocumentRepository):
+    pass
+
+
+class MemoryWoundImageRepository(MemoryRepository, PostgresWoundImageRepository):
+    pass
+
+
+class MemoryNotificationRepository(MemoryRepository, PostgresNotificationRepository):
+    pass
+
+
+class MemoryPushTokenRepository(MemoryRepository, PostgresPushTokenRepository):
+    pass
+
+
+class MemoryTelehealthSessionRepository(MemoryRepository, PostgresTelehealthSessionRepository):
+    pass
+
+
+class MemoryCoverageRepository(MemoryRepository, PostgresCoverageRepository):
+    pass
+
+
+class MemoryEligibilityCheckRepository(MemoryRepository, PostgresEligibilityCheckRepository):
+    pass
+
+
+class MemoryChargeItemRepository(MemoryRepository, PostgresChargeItemRepository):
+    pass
+
+
+class MemoryClaimRepository(MemoryRepository, PostgresClaimRepository):
+    pass
+
+
+class MemoryInvoiceRepository(MemoryRepository, PostgresInvoiceRepository):
+    pass
+
+
+class MemoryConsentRepository(MemoryRepository, PostgresConsentRepository):
+    pass
+
+
+class MemoryConsentDocumentRepository(MemoryRepository, PostgresConsentDocumentRepository):
+    """Consent document versions; the PostgreSQL version queries are SQL, so they are redone here."""
+
+    def create(self, document_in: schemas.ConsentDocumentCreate, published_by: str) -> schemas.ConsentDocument:
+        with self.store.lock:
+            versions = [document.version for document in self._query().where("scope", "==", document_in.scope).fetch()]
+            data = {**document_in.model_dump(by_alias=True), "version": max(versions, default=0) + 1, "publishedBy": published_by}
+            return self._create(data)
+
+    def list(self, scope: str) -> List[schemas.ConsentDocument]:
+        documents = self._query().where("scope", "==", scope).fetch()
+        return sorted(documents, key=lambda document: document.version, reverse=True)
+
+
+class MemoryExportJobRepository(MemoryRepository, PostgresExportJobRepository):
+    pass
+
+
+class MemoryImportJobRepository(MemoryRepository, PostgresImportJobRepository):
+    pass
+
+
+class MemoryAuditEventRepository(MemoryRepository, PostgresAuditEventRepository):
+    pass
+
+
+class MemoryAuditExportRepository(MemoryRepository, PostgresAuditExportRepository):
+    pass
+
+
+class MemoryOutboxRepository(MemoryRepository, PostgresOutboxRepository):
+    pass
+
+
+class MemoryStreamEventRepository(MemoryRepository, PostgresStreamEventRepository):
+    pass
+
+
+class MemoryWebhookSubscriptionRepository(MemoryRepository, PostgresWebhookSubscriptionRepository):
+    pass
+
+
+class MemoryWebhookDeliveryRepository(MemoryRepository, PostgresWebhookDeliveryRepository):
+    pass
+
+
+class MemoryJobLockRepository(MemoryRepository, PostgresJobLockRepository):
+    pass
+
+
+class MemoryJobRunRepository(MemoryRepository, PostgresJobRunRepository):
+    pass
+
+
+class MemoryApiKeyRepository(MemoryRepository, PostgresApiKeyRepository):
+    pass
+
+
+class MemoryIdempotencyRepository(MemoryRepository, PostgresIdempotencyRepository):
+    pass
+
+
+class MemorySessionRepository(MemoryRepository, PostgresSessionRepository):
+    pass
+
+
+class MemoryMagicLinkRepository(MemoryRepository, PostgresMagicLinkRepository):
+    pass
+
+
+class MemoryMfaEnrollmentRepository(MemoryRepository, PostgresMfaEnrollmentRepository):
+    pass
+
+
+class MemoryLockoutRepository(MemoryRepository, PostgresLockoutRepository):
+    pass
+
+
+class MemoryOrganizationRepository(MemoryRepository, PostgresOrganizationRepository):
+    pass
+
+
+class MemoryFeatureFlagRepository(MemoryRepository, PostgresFeatureFlagRepository):
+    pass
+
+
+class MemoryCustomRoleRepository(MemoryRepository, PostgresCustomRoleRepository):
+    pass
+
+
+class MemoryRetentionPolicyRepository(MemoryRepository, PostgresRetentionPolicyRepository):
+    pass
+
+
+class MemoryPurgeManifestRepository(MemoryRepository, PostgresPurgeManifestRepository):
+    pass
+
+
+class MemoryDeviceRepository(DeviceRepository):
+    """
+    Devices keyed by (owner, device ID). Devices are registered through the
+    customer endpoints, which write to Firestore directly, so local setups
+    and tests add them with `put`.
+    """
+
+    def __init__(self, store: MemoryStore):
+        self.store = store
+
+    @property
+    def devices(self) -> Dict[str, Dict[str, Any]]:
+        return self.store.table("devices")
+
+    def put(self, owner_uid: str, device: schemas.Device) -> None:
+        with self.store.lock:
+            self.devices[f"{owner_uid}/{device.device_id}"] = device.model_dump(by_alias=True)
+
+    def get_for_owner(self, owner_uid: str, device_id: str) -> Optional[schemas.Device]:
+        with self.store.lock:
+            data = copy.deepcopy(self.devices.get(f"{owner_uid}/{device_id}"))
+        return schemas.Device.model_validate(data) if data else None
+
+    def get_telemetry_sequence(self, owner_uid: str, device_id: str) -> Optional[int]:
+        with self.store.lock:
+            return (self.devices.get(f"{owner_uid}/{device_id}") or {}).get("lastTelemetrySequence")
+
+    def advance_telemetry_sequence(self, owner_uid: str, device_id: str, sequence: int) -> int:
+        with self.store.lock:
+            device = self.devices.get(f"{owner_uid}/{device_id}")
+            if device is None:
+                raise NotFoundError(f"Device '{device_id}' not found.")
+            current = device.get("lastTelemetrySequence")
+            if current is not None and current >= sequence:
+                return current
+            device["lastTelemetrySequence"] = sequence
+            return sequence
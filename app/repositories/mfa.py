@@ -0,0 +1,98 @@
+# Location: app/repositories/mfa.py
+
+import hmac
+from abc import ABC, abstractmethod
+from datetime import datetime
+from typing import Any, Dict, Optional
+
+from app.api.v1 import schemas
+from app.repositories.base import ConflictError, FirestoreRepository, NotFoundError
+
+
+class MfaEnrollmentRepository(ABC):
+    """
+    Storage for users' second factors, one record per user keyed by uid.
+    The `use_*` methods accept each code once, even when two requests
+    present it at the same time.
+    """
+
+    @abstractmethod
+    def get(self, uid: str) -> Optional[schemas.MfaEnrollmentRecord]:
+        """Returns the user's enrollment, or None if they have none."""
+
+    @abstractmethod
+    def save(self, uid: str, changes: Dict[str, Any]) -> schemas.MfaEnrollmentRecord:
+        """Applies `changes` to the user's enrollment, creating it if needed."""
+
+    @abstractmethod
+    def use_totp_step(self, uid: str, step: int) -> schemas.MfaEnrollmentRecord:
+        """Records an accepted code's time step. Raises ConflictError if it is not after the last one."""
+
+    @abstractmethod
+    def use_sms_code(self, uid: str, code_hash: str, now: datetime) -> schemas.MfaEnrollmentRecord:
+        """Clears the pending text message code. Raises ConflictError unless it matches and has not expired."""
+
+    @abstractmethod
+    def use_recovery_code(self, uid: str, code_hash: str) -> schemas.MfaEnrollmentRecord:
+        """Removes the recovery code. Raises ConflictError if the user has no such code."""
+
+    @abstractmethod
+    def delete(self, uid: str) -> None:
+        """Removes every factor of the user. Does nothing if they have none."""
+
+
+class MfaEnrollmentRecordMixin:
+    """
+    The operations, on top of the storage base class helpers. Factors are
+    the user's wherever they sign in, so they are not tenant-scoped.
+    """
+
+    tenant_scoped = False
+
+    def get(self, uid: str) -> Optional[schemas.MfaEnrollmentRecord]:
+        return self._get(uid)
+
+    def save(self, uid: str, changes: Dict[str, Any]) -> schemas.MfaEnrollmentRecord:
+        if self._get(uid) is None:
+            try:
+                return self._create({"uid": uid, **changes}, record_id=uid)
+            except ConflictError:
+                pass  # Created concurrently; update it instead.
+        return self._update(uid, changes)
+
+    def use_totp_step(self, uid: str, step: int) -> schemas.MfaEnrollmentRecord:
+        def use(record: schemas.MfaEnrollmentRecord) -> Dict[str, Any]:
+            if record.last_totp_step is not None and step <= record.last_totp_step:
+                raise ConflictError("Authenticator code was already used.")
+            return {"lastTotpStep": step}
+        return self._transform(uid, use)
+
+    def use_sms_code(self, uid: str, code_hash: str, now: datetime) -> schemas.MfaEnrollmentRecord:
+        def use(record: schemas.MfaEnrollmentRecord) -> Dict[str, Any]:
+            if not record.sms_code_hash or not record.sms_code_expires_at or record.sms_code_expires_at <= now:
+                raise ConflictError("No text message code is pending.")
+            if not hmac.compare_digest(record.sms_code_hash, code_hash):
+                raise ConflictError("Text message code does not match.")
+            return {"smsCodeHash": None, "smsCodeExpiresAt": None}
+        return self._transform(uid, use)
+
+    def use_recovery_code(self, uid: str, code_hash: str) -> schemas.MfaEnrollmentRecord:
+        def use(record: schemas.MfaEnrollmentRecord) -> Dict[str, Any]:
+            if code_hash not in record.recovery_code_hashes:
+                raise ConflictError("Unknown or used recovery code.")
+            return {"recoveryCodeHashes": [h for h in record.recovery_code_hashes if h != code_hash]}
+        return self._transform(uid, use)
+
+    def delete(self, uid: str) -> None:
+        try:
+            self._delete(uid)
+        except NotFoundError:
+            pass
+
+
+class FirestoreMfaEnrollmentRepository(MfaEnrollmentRecordMixin, FirestoreRepository, MfaEnrollmentRepository):
+    """Stores enrollments in the top-level `mfaEnrollments` collection."""
+
+    collection_name = "mfaEnrollments"
+    model = schemas.MfaEnrollmentRecord
+    id_field = "uid"
@@ -0,0 +1,75 @@
+# Location: app/repositories/notifications.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime, timezone
+from typing import List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+from app.services.notifications import delivery_changes
+
+
+class NotificationRepository(ABC):
+    """Storage interface for messages sent to patients and their delivery."""
+
+    @abstractmethod
+    def create(self, notification_in: schemas.NotificationCreate, status: str = "queued") -> schemas.Notification:
+        """Stores a new notification, queued unless it is `throttled` and will not be sent."""
+
+    @abstractmethod
+    def get(self, notification_id: str) -> Optional[schemas.Notification]:
+        """Returns the notification, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, patient_id: str, status: Optional[str] = None, limit: int = 100) -> List[schemas.Notification]:
+        """Returns the notifications sent to a patient, optionally only those with one status."""
+
+    @abstractmethod
+    def mark_sent(self, notification_id: str, provider: str, sender: str, subject: Optional[str], provider_message_id: Optional[str]) -> schemas.Notification:
+        """Records that the provider accepted the message. Raises NotFoundError."""
+
+    @abstractmethod
+    def mark_failed(self, notification_id: str, error: str) -> schemas.Notification:
+        """Records that the message was refused, by the provider or because the patient opted out. Raises NotFoundError."""
+
+    @abstractmethod
+    def record_delivery(self, notification_id: str, event: schemas.DeliveryEvent) -> schemas.Notification:
+        """
+        Appends a delivery event reported by the provider and moves the
+        status accordingly (see app/services/notifications.py). An event
+        already recorded is not recorded twice. Raises NotFoundError.
+        """
+
+
+class FirestoreNotificationRepository(FirestoreRepository, NotificationRepository):
+    """Stores notifications in the top-level `notifications` collection, keyed to the patient by `patientId`."""
+
+    collection_name = "notifications"
+    model = schemas.Notification
+    id_field = "notificationId"
+
+    def create(self, notification_in: schemas.NotificationCreate, status: str = "queued") -> schemas.Notification:
+        return self._create({**notification_in.model_dump(by_alias=True), "status": status, "deliveryEvents": []})
+
+    def get(self, notification_id: str) -> Optional[schemas.Notification]:
+        return self._get(notification_id)
+
+    def list(self, patient_id: str, status: Optional[str] = None, limit: int = 100) -> List[schemas.Notification]:
+        query = self._query().where(filter=FieldFilter("patientId", "==", patient_id))
+        if status:
+            query = query.where(filter=FieldFilter("status", "==", status))
+        return self._fetch(query, limit)
+
+    def mark_sent(self, notification_id: str, provider: str, sender: str, subject: Optional[str], provider_message_id: Optional[str]) -> schemas.Notification:
+        return self._update(notification_id, {
+            "status": "sent", "provider": provider, "sender": sender, "subject": subject,
+            "providerMessageId": provider_message_id, "sentAt": datetime.now(timezone.utc),
+        })
+
+    def mark_failed(self, notification_id: str, error: str) -> schemas.Notification:
+        return self._update(notification_id, {"status": "failed", "error": error})
+
+    def record_delivery(self, notification_id: str, event: schemas.DeliveryEvent) -> schemas.Notification:
+        return self._transform(notification_id, lambda notification: delivery_changes(notification, event))
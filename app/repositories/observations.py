@@ -0,0 +1,136 @@
+# Location: app/repositories/observations.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime
+from typing import Iterator, List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+from app.search.filters import Search, SearchField, SearchSpec
+
+# What an anonymized observation keeps in place of its links to the patient
+# (see app/retention): the measurement stays usable for aggregate analysis.
+ANONYMIZED_PATIENT_ID = "anonymized"
+ANONYMIZED_OBSERVATION = {"patientId": ANONYMIZED_PATIENT_ID, "identifier": None}
+
+# What a patient's observations can be filtered and sorted by (see
+# app/search), besides the effective time range.
+OBSERVATION_SEARCH = SearchSpec(
+    fields=(
+        SearchField("code", "token", ("code.code",)),
+        SearchField("status", "token", choices=("registered", "preliminary", "final", "amended")),
+        SearchField("value", "number"),
+        SearchField("effectiveAt", "datetime", sortable=True),
+        SearchField("createdAt", "datetime", sortable=True),
+    ),
+    default_sort="-effectiveAt",
+)
+
+
+class ObservationRepository(ABC):
+    """Storage interface for clinical observations (vitals, measurements)."""
+
+    @abstractmethod
+    def create(self, observation_in: schemas.ObservationCreate) -> schemas.Observation:
+        """Stores a new observation."""
+
+    @abstractmethod
+    def get(self, observation_id: str) -> Optional[schemas.Observation]:
+        """Returns the observation, or None if it does not exist."""
+
+    @abstractmethod
+    def find_by_identifier(self, system: Optional[str], value: str) -> List[schemas.Observation]:
+        """Returns observations carrying the given external identifier. A None system matches any system."""
+
+    @abstractmethod
+    def list(
+        self,
+        patient_id: str,
+        code: Optional[str] = None,
+        start_from: Optional[datetime] = None,
+        start_to: Optional[datetime] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+        search: Optional[Search] = None,
+    ) -> List[schemas.Observation]:
+        """
+        Returns the patient's observations, optionally filtered by code,
+        effective time range [start_from, start_to) and `search` (see
+        OBSERVATION_SEARCH), in its order; newest first by default.
+        """
+
+    @abstractmethod
+    def stream(self, updated_since: Optional[datetime] = None, updated_before: Optional[datetime] = None) -> Iterator[schemas.Observation]:
+        """Yields every observation, optionally only those last updated within [updated_since, updated_before)."""
+
+    @abstractmethod
+    def stream_taken_between(self, start_from: datetime, start_to: datetime) -> Iterator[schemas.Observation]:
+        """Yields every observation taken within [start_from, start_to), in no particular order."""
+
+    @abstractmethod
+    def purge_expired(self, before: datetime) -> int:
+        """Deletes the observations taken before `before`. Returns the count."""
+
+    @abstractmethod
+    def anonymize_expired(self, since: Optional[datetime], before: datetime) -> int:
+        """Unlinks the observations taken within [since, before) from their patient. Returns the count."""
+
+
+class FirestoreObservationRepository(FirestoreRepository, ObservationRepository):
+    """Stores observations in the top-level `observations` collection."""
+
+    collection_name = "observations"
+    model = schemas.Observation
+    id_field = "observationId"
+
+    def create(self, observation_in: schemas.ObservationCreate) -> schemas.Observation:
+        return self._create(observation_in.model_dump(by_alias=True))
+
+    def get(self, observation_id: str) -> Optional[schemas.Observation]:
+        return self._get(observation_id)
+
+    def find_by_identifier(self, system: Optional[str], value: str) -> List[schemas.Observation]:
+        query = self._query().where(filter=FieldFilter("identifier.value", "==", value))
+        if system:
+            query = query.where(filter=FieldFilter("identifier.system", "==", system))
+        return [self._to_model(doc) for doc in query.stream()]
+
+    def list(
+        self,
+        patient_id: str,
+        code: Optional[str] = None,
+        start_from: Optional[datetime] = None,
+        start_to: Optional[datetime] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+        search: Optional[Search] = None,
+    ) -> List[schemas.Observation]:
+        # Note: These queries require composite indexes on (patientId, effectiveAt desc)
+        # and (patientId, code.code, effectiveAt desc), and each other filter
+        # and sort used needs its own.
+        query = self._query().where(filter=FieldFilter("patientId", "==", patient_id))
+        if code:
+            query = query.where(filter=FieldFilter("code.code", "==", code))
+        if start_from:
+            query = query.where(filter=FieldFilter("effectiveAt", ">=", start_from))
+        if start_to:
+            query = query.where(filter=FieldFilter("effectiveAt", "<", start_to))
+        query, keep = self._searched(query, search or OBSERVATION_SEARCH.default())
+        return self._fetch(self._start_after(query, after), limit, keep)
+
+    def stream(self, updated_since: Optional[datetime] = None, updated_before: Optional[datetime] = None) -> Iterator[schemas.Observation]:
+        for doc in self._stream_updated_between(updated_since, updated_before):
+            yield self._to_model(doc)
+
+    def stream_taken_between(self, start_from: datetime, start_to: datetime) -> Iterator[schemas.Observation]:
+        query = self._query().where(filter=FieldFilter("effectiveAt", ">=", start_from)).where(filter=FieldFilter("effectiveAt", "<", start_to))
+        for doc in query.stream():
+            yield self._to_model(doc)
+
+    def purge_expired(self, before: datetime) -> int:
+        return self._purge(before, field="effectiveAt")
+
+    def anonymize_expired(self, since: Optional[datetime], before: datetime) -> int:
+        return self._anonymize(ANONYMIZED_OBSERVATION, before, since, field="effectiveAt")
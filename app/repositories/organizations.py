@@ -0,0 +1,60 @@
+# Location: app/repositories/organizations.py
+
+from abc import ABC, abstractmethod
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.base import ConflictError, FirestoreRepository
+
+
+class OrganizationRepository(ABC):
+    """
+    Storage for the organizations (clinics) sharing the deployment, keyed by
+    the organization ID their users' tokens carry. Organizations are
+    platform records, so reads are never scoped to a tenant.
+    """
+
+    @abstractmethod
+    def create(self, organization_in: schemas.OrganizationCreate) -> schemas.Organization:
+        """Stores a new organization. Raises ConflictError if the ID is taken."""
+
+    @abstractmethod
+    def get(self, organization_id: str) -> Optional[schemas.Organization]:
+        """Returns the organization, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, limit: int = 100, after: Optional[str] = None) -> List[schemas.Organization]:
+        """Returns organizations by name."""
+
+    @abstractmethod
+    def update(self, organization_id: str, organization_in: schemas.OrganizationUpdate) -> schemas.Organization:
+        """Applies the fields set on `organization_in`. Raises NotFoundError."""
+
+
+class OrganizationRecordMixin:
+    """Everything but the listing query, on top of the storage base class helpers."""
+
+    tenant_scoped = False
+
+    def create(self, organization_in: schemas.OrganizationCreate) -> schemas.Organization:
+        if self._get(organization_in.organization_id):
+            raise ConflictError(f"An organization with ID '{organization_in.organization_id}' already exists.")
+        data = organization_in.model_dump(by_alias=True, exclude={"organization_id"})
+        return self._create(data, record_id=organization_in.organization_id)
+
+    def get(self, organization_id: str) -> Optional[schemas.Organization]:
+        return self._get(organization_id)
+
+    def update(self, organization_id: str, organization_in: schemas.OrganizationUpdate) -> schemas.Organization:
+        return self._update(organization_id, organization_in.model_dump(by_alias=True, exclude_unset=True))
+
+
+class FirestoreOrganizationRepository(OrganizationRecordMixin, FirestoreRepository, OrganizationRepository):
+    """Stores organizations in the top-level `organizations` collection."""
+
+    collection_name = "organizations"
+    model = schemas.Organization
+    id_field = "organizationId"
+
+    def list(self, limit: int = 100, after: Optional[str] = None) -> List[schemas.Organization]:
+        return [self._to_model(doc) for doc in self._start_after(self._query().order_by("name"), after).limit(limit).stream()]
@@ -0,0 +1,112 @@
+# Location: app/repositories/outbox.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime, timezone
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.events.envelope import Event
+from app.repositories.base import FirestoreRepository
+from app.tenancy.context import current_tenant
+
+
+def outbox_record(event: Event, now: datetime) -> dict:
+    """The stored fields of a new, immediately due outbox entry."""
+    return {
+        "event": event.model_dump(mode="json", by_alias=True),
+        "status": "pending",
+        "attempts": 0,
+        "nextAttemptAt": now,
+    }
+
+
+class OutboxRepository(ABC):
+    """
+    Storage for the transactional event outbox. Events are added in the
+    same transaction as the change they describe and relayed to Pub/Sub
+    afterwards by `app.events.relay.OutboxRelay`.
+    """
+
+    @abstractmethod
+    def add(self, event: Event) -> None:
+        """Stores the event as a pending entry, keyed by the event ID."""
+
+    @abstractmethod
+    def claim(self, limit: int, lease_seconds: float) -> List[schemas.OutboxEntry]:
+        """
+        Returns up to `limit` due pending entries, oldest first, and leases
+        them by moving `nextAttemptAt` forward, so concurrent relays do not
+        pick up the same entries.
+        """
+
+    @abstractmethod
+    def mark_published(self, event_id: str) -> None:
+        """Records that Pub/Sub accepted the event. Raises NotFoundError."""
+
+    @abstractmethod
+    def reschedule(self, event_id: str, attempts: int, next_attempt_at: datetime, error: str) -> None:
+        """Records a failed attempt and when to try again. Raises NotFoundError."""
+
+    @abstractmethod
+    def mark_failed(self, event_id: str, attempts: int, error: str) -> None:
+        """Gives up on the event after its last attempt. Raises NotFoundError."""
+
+    @abstractmethod
+    def purge_published(self, before: datetime) -> int:
+        """Deletes entries published before `before`; pending and failed entries are kept. Returns the count."""
+
+    @abstractmethod
+    def purge_expired(self, before: datetime) -> int:
+        """`purge_published`, for the current organization's events only. Returns the count."""
+
+    @abstractmethod
+    def anonymize_expired(self, since: Optional[datetime], before: datetime) -> int:
+        """Drops the payload of the current organization's events published within [since, before). Returns the count."""
+
+
+class OutboxRecordMixin:
+    """Everything, on top of the storage base class helpers; claiming uses `_lease_due`."""
+
+    tenant_scoped = False
+
+    def add(self, event: Event) -> None:
+        self._create(outbox_record(event, datetime.now(timezone.utc)), record_id=event.id)
+
+    def claim(self, limit: int, lease_seconds: float) -> List[schemas.OutboxEntry]:
+        return self._lease_due(limit, lease_seconds)
+
+    def mark_published(self, event_id: str) -> None:
+        self._update(event_id, {"status": "published", "publishedAt": datetime.now(timezone.utc)})
+
+    def reschedule(self, event_id: str, attempts: int, next_attempt_at: datetime, error: str) -> None:
+        self._update(event_id, {"attempts": attempts, "nextAttemptAt": next_attempt_at, "lastError": error})
+
+    def mark_failed(self, event_id: str, attempts: int, error: str) -> None:
+        self._update(event_id, {"status": "failed", "attempts": attempts, "lastError": error})
+
+    def purge_published(self, before: datetime) -> int:
+        return self._purge(before, {"status": "published"})
+
+    def _published_by_tenant(self) -> dict:
+        # Entries are not tenant-scoped; the event envelope records whose they are.
+        tenant = current_tenant()
+        return {"status": "published", **({"event.tenantId": tenant} if tenant else {})}
+
+    def purge_expired(self, before: datetime) -> int:
+        return self._purge(before, self._published_by_tenant())
+
+    def anonymize_expired(self, since: Optional[datetime], before: datetime) -> int:
+        return self._anonymize({"event.data": {}}, before, since, self._published_by_tenant())
+
+
+class FirestoreOutboxRepository(OutboxRecordMixin, FirestoreRepository, OutboxRepository):
+    """
+    Stores the outbox in the top-level `eventOutbox` collection. Firestore
+    repositories do not share transactions, so entries are written directly
+    after the change rather than atomically with it; the relay still retries
+    every entry until Pub/Sub accepts it.
+    """
+
+    collection_name = "eventOutbox"
+    model = schemas.OutboxEntry
+    id_field = "eventId"
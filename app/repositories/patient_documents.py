@@ -0,0 +1,82 @@
+# Location: app/repositories/patient_documents.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime, timezone
+from typing import List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+
+
+class PatientDocumentRepository(ABC):
+    """
+    Storage interface for the metadata of documents attached to a patient's
+    chart. The files themselves are in Cloud Storage, under `blobName`.
+    """
+
+    @abstractmethod
+    def create(
+        self, patient_id: str, document_id: str, document_in: schemas.PatientDocumentCreate, blob_name: str, uploaded_by: str,
+    ) -> schemas.PatientDocument:
+        """Stores a new, pending document record under `document_id`."""
+
+    @abstractmethod
+    def get(self, document_id: str) -> Optional[schemas.PatientDocument]:
+        """Returns the record, or None if it does not exist."""
+
+    @abstractmethod
+    def list(
+        self, patient_id: str, type: Optional[str] = None, status: Optional[str] = None, limit: int = 100,
+    ) -> List[schemas.PatientDocument]:
+        """Returns the patient's documents, optionally only those of one type and/or status."""
+
+    @abstractmethod
+    def mark_uploaded(self, document_id: str, status: str) -> schemas.PatientDocument:
+        """Records that the file was uploaded, moving the document to `status` ("available" or "pending-scan"). Raises NotFoundError."""
+
+    @abstractmethod
+    def record_scan(self, document_id: str, threat: Optional[str]) -> schemas.PatientDocument:
+        """Records the malware scan: available if `threat` is None, quarantined otherwise. Raises NotFoundError."""
+
+    @abstractmethod
+    def delete(self, document_id: str) -> None:
+        """Removes the record. Raises NotFoundError if it does not exist."""
+
+
+class FirestorePatientDocumentRepository(FirestoreRepository, PatientDocumentRepository):
+    """Stores document metadata in the top-level `patientDocuments` collection, keyed to the patient by `patientId`."""
+
+    collection_name = "patientDocuments"
+    model = schemas.PatientDocument
+    id_field = "documentId"
+
+    def create(
+        self, patient_id: str, document_id: str, document_in: schemas.PatientDocumentCreate, blob_name: str, uploaded_by: str,
+    ) -> schemas.PatientDocument:
+        data = {**document_in.model_dump(by_alias=True), "patientId": patient_id, "blobName": blob_name, "status": "pending", "uploadedBy": uploaded_by}
+        return self._create(data, record_id=document_id)
+
+    def get(self, document_id: str) -> Optional[schemas.PatientDocument]:
+        return self._get(document_id)
+
+    def list(
+        self, patient_id: str, type: Optional[str] = None, status: Optional[str] = None, limit: int = 100,
+    ) -> List[schemas.PatientDocument]:
+        query = self._query().where(filter=FieldFilter("patientId", "==", patient_id))
+        if type:
+            query = query.where(filter=FieldFilter("type", "==", type))
+        if status:
+            query = query.where(filter=FieldFilter("status", "==", status))
+        return self._fetch(query, limit)
+
+    def mark_uploaded(self, document_id: str, status: str) -> schemas.PatientDocument:
+        return self._update(document_id, {"status": status, "uploadedAt": datetime.now(timezone.utc)})
+
+    def record_scan(self, document_id: str, threat: Optional[str]) -> schemas.PatientDocument:
+        status = "quarantined" if threat else "available"
+        return self._update(document_id, {"status": status, "scannedAt": datetime.now(timezone.utc), "threat": threat})
+
+    def delete(self, document_id: str) -> None:
+        self._delete(document_id)
@@ -0,0 +1,194 @@
+# Location: app/repositories/patients.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime, timezone
+from typing import Any, Callable, Dict, Iterator, List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+
+from app.api.v1 import schemas
+from app.repositories.base import DELETED_FIELD, ConflictError, FirestoreRepository, including_deleted, to_firestore
+from app.search.filters import Search, SearchField, SearchSpec
+
+# What `GET /patients` can be filtered and sorted by (see app/search).
+PATIENT_SEARCH = SearchSpec(
+    fields=(
+        SearchField("name", "text", ("givenName", "familyName")),
+        SearchField("givenName", "text", sortable=True),
+        SearchField("familyName", "text", sortable=True),
+        SearchField("dob", "date", sortable=True),
+        SearchField("sex", "token", choices=("male", "female", "other", "unknown")),
+        SearchField("mrn", "token"),
+        SearchField("createdAt", "datetime", sortable=True),
+        SearchField("updatedAt", "datetime", sortable=True),
+    ),
+    default_sort="familyName",
+)
+
+
+def contact_preferences(patient: schemas.Patient, **changes: Any) -> Dict[str, Any]:
+    """
+    The change to a patient's contact preferences with `changes` (by field
+    name) applied, for `_transform`. The preferences are stored as one map,
+    so the SMS opt-out and the push categories are merged into the current
+    ones rather than written over each other.
+    """
+    preferences = patient.contact_preferences.model_copy(update=changes)
+    return {"contactPreferences": preferences.model_dump(by_alias=True)}
+
+
+def sms_preferences(opted_out: bool) -> Callable[[schemas.Patient], Dict[str, Any]]:
+    """The mutation recording a patient's text message opt-out or opt-in."""
+    changed_at = datetime.now(timezone.utc)
+    return lambda patient: contact_preferences(patient, sms_opt_out=opted_out, sms_opt_out_changed_at=changed_at)
+
+
+def push_preferences(preferences_in: schemas.PushPreferencesUpdate) -> Callable[[schemas.Patient], Dict[str, Any]]:
+    """The mutation turning the push categories set on `preferences_in` on or off."""
+    changes = preferences_in.model_dump(exclude_unset=True, exclude_none=True)
+    return lambda patient: contact_preferences(patient, push=patient.contact_preferences.push.model_copy(update=changes))
+
+
+def merged(merged_into: str, merged_by: str) -> Dict[str, Any]:
+    """The change deleting a duplicate patient merged into `merged_into`."""
+    return {DELETED_FIELD: datetime.now(timezone.utc), "deletedBy": merged_by, "mergedInto": merged_into}
+
+
+class PatientRepository(ABC):
+    """Storage interface for patient records."""
+
+    @abstractmethod
+    def create(self, patient_in: schemas.PatientCreate) -> schemas.Patient:
+        """Stores a new patient. Raises ConflictError if the MRN is already in use."""
+
+    @abstractmethod
+    def get(self, patient_id: str) -> Optional[schemas.Patient]:
+        """Returns the patient, or None if it does not exist or is deleted."""
+
+    @abstractmethod
+    def get_many(self, patient_ids: List[str]) -> Dict[str, schemas.Patient]:
+        """Returns the patients that exist among `patient_ids`, by ID, in one batched read."""
+
+    @abstractmethod
+    def get_by_mrn(self, mrn: str) -> Optional[schemas.Patient]:
+        """Returns the patient with the given medical record number, if any. Deleted patients keep their MRN."""
+
+    @abstractmethod
+    def list(self, limit: int = 30, after: Optional[str] = None, search: Optional[Search] = None) -> List[schemas.Patient]:
+        """Returns up to `limit` patients matching `search` (see PATIENT_SEARCH), in its order; by family name by default."""
+
+    @abstractmethod
+    def find_candidates(self, patient: schemas.Patient, limit: int = 50) -> List[schemas.Patient]:
+        """
+        Returns other live patients sharing `patient`'s date of birth, family
+        name or phone number, up to `limit` for each, the records duplicate
+        detection scores (see app/services/patient_matching.py).
+        """
+
+    @abstractmethod
+    def stream(self, updated_since: Optional[datetime] = None, updated_before: Optional[datetime] = None) -> Iterator[schemas.Patient]:
+        """Yields every patient, optionally only those last updated within [updated_since, updated_before)."""
+
+    @abstractmethod
+    def update(self, patient_id: str, patient_in: schemas.PatientUpdate) -> schemas.Patient:
+        """Applies the fields set on `patient_in`. Raises NotFoundError or ConflictError."""
+
+    @abstractmethod
+    def set_sms_opt_out(self, phone_number: str, opted_out: bool) -> List[schemas.Patient]:
+        """
+        Records on every patient with the E.164 `phone_number` whether they
+        opted out of text messages (a phone may be shared, e.g. by a family).
+        Returns the patients updated.
+        """
+
+    @abstractmethod
+    def update_push_preferences(self, patient_id: str, preferences_in: schemas.PushPreferencesUpdate) -> schemas.Patient:
+        """Turns the push notification categories set on `preferences_in` on or off. Raises NotFoundError."""
+
+    @abstractmethod
+    def delete(self, patient_id: str, deleted_by: str) -> None:
+        """Marks the patient deleted. Raises NotFoundError if it does not exist or is already deleted."""
+
+    @abstractmethod
+    def restore(self, patient_id: str) -> schemas.Patient:
+        """Clears the patient's deletion. Raises NotFoundError if it does not exist."""
+
+    @abstractmethod
+    def mark_merged(self, patient_id: str, merged_into: str, merged_by: str) -> schemas.Patient:
+        """Deletes a duplicate patient whose records were moved to `merged_into`, noting where. Raises NotFoundError."""
+
+
+class FirestorePatientRepository(FirestoreRepository, PatientRepository):
+    """Stores patients as documents in the top-level `patients` collection."""
+
+    collection_name = "patients"
+    model = schemas.Patient
+    id_field = "patientId"
+    soft_delete = True
+
+    def create(self, patient_in: schemas.PatientCreate) -> schemas.Patient:
+        with including_deleted():
+            taken = self.get_by_mrn(patient_in.mrn)
+        if taken:
+            raise ConflictError(f"A patient with MRN '{patient_in.mrn}' already exists.")
+        return self._create(patient_in.model_dump(by_alias=True))
+
+    def get(self, patient_id: str) -> Optional[schemas.Patient]:
+        return self._get(patient_id)
+
+    def get_many(self, patient_ids: List[str]) -> Dict[str, schemas.Patient]:
+        return self._get_many(patient_ids)
+
+    def get_by_mrn(self, mrn: str) -> Optional[schemas.Patient]:
+        # Note: This query requires a Firestore index on the 'mrn' field.
+        query = self._query().where(filter=FieldFilter("mrn", "==", mrn)).limit(1)
+        docs = list(query.stream())
+        return self._to_model(docs[0]) if docs and self._live(docs[0]) else None
+
+    def list(self, limit: int = 30, after: Optional[str] = None, search: Optional[Search] = None) -> List[schemas.Patient]:
+        query, keep = self._searched(self._query(), search or PATIENT_SEARCH.default())
+        return self._fetch(self._start_after(query, after), limit, keep)
+
+    def find_candidates(self, patient: schemas.Patient, limit: int = 50) -> List[schemas.Patient]:
+        # Note: With tenancy enabled, these queries require composite indexes
+        # on 'tenantId' + 'dob', 'familyName' and 'contact.phoneNumber'.
+        blocks = [("dob", to_firestore(patient.dob)), ("familyName", patient.family_name)]
+        if patient.contact and patient.contact.phone_number:
+            blocks.append(("contact.phoneNumber", patient.contact.phone_number))
+        candidates: Dict[str, schemas.Patient] = {}
+        for field, value in blocks:
+            for candidate in self._fetch(self._query().where(filter=FieldFilter(field, "==", value)), limit):
+                candidates.setdefault(candidate.patient_id, candidate)
+        candidates.pop(patient.patient_id, None)
+        return list(candidates.values())
+
+    def stream(self, updated_since: Optional[datetime] = None, updated_before: Optional[datetime] = None) -> Iterator[schemas.Patient]:
+        for doc in self._stream_updated_between(updated_since, updated_before):
+            yield self._to_model(doc)
+
+    def update(self, patient_id: str, patient_in: schemas.PatientUpdate) -> schemas.Patient:
+        changes = patient_in.model_dump(by_alias=True, exclude_unset=True)
+        if "mrn" in changes:
+            with including_deleted():
+                existing = self.get_by_mrn(changes["mrn"])
+            if existing and existing.patient_id != patient_id:
+                raise ConflictError(f"A patient with MRN '{changes['mrn']}' already exists.")
+        return self._update(patient_id, changes)
+
+    def set_sms_opt_out(self, phone_number: str, opted_out: bool) -> List[schemas.Patient]:
+        # Note: Firestore indexes the nested 'contact.phoneNumber' field automatically.
+        query = self._query().where(filter=FieldFilter("contact.phoneNumber", "==", phone_number))
+        preferences = sms_preferences(opted_out)
+        return [self._transform(patient.patient_id, preferences) for patient in self._fetch(query, 100)]
+
+    def update_push_preferences(self, patient_id: str, preferences_in: schemas.PushPreferencesUpdate) -> schemas.Patient:
+        return self._transform(patient_id, push_preferences(preferences_in))
+
+    def delete(self, patient_id: str, deleted_by: str) -> None:
+        self._soft_delete(patient_id, deleted_by)
+
+    def restore(self, patient_id: str) -> schemas.Patient:
+        return self._restore(patient_id)
+
+    def mark_merged(self, patient_id: str, merged_into: str, merged_by: str) -> schemas.Patient:
+        return self._update(patient_id, merged(merged_into, merged_by))
@@ -0,0 +1,48 @@
+# Location: app/repositories/postgres/allergies.py
+
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.allergies import AllergyRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresAllergyRepository(PostgresRepository, AllergyRepository):
+    """Stores allergies in the `allergies` table."""
+
+    table = "allergies"
+    model = schemas.Allergy
+    id_field = "allergyId"
+    soft_delete = True
+
+    def create(self, patient_id: str, allergy_in: schemas.AllergyCreate, recorded_by: str) -> schemas.Allergy:
+        return self._create({**allergy_in.model_dump(by_alias=True), "patientId": patient_id, "recordedBy": recorded_by})
+
+    def get(self, allergy_id: str) -> Optional[schemas.Allergy]:
+        return self._get(allergy_id)
+
+    def list(
+        self,
+        patient_id: str,
+        substance_code: Optional[str] = None,
+        substance_system: Optional[str] = None,
+        clinical_status: Optional[str] = None,
+        limit: int = 100,
+    ) -> List[schemas.Allergy]:
+        query = self._query().where("patientId", "==", patient_id)
+        if substance_code:
+            query = query.where("substance.code", "==", substance_code)
+        if substance_system:
+            query = query.where("substance.system", "==", substance_system)
+        if clinical_status:
+            query = query.where("clinicalStatus", "==", clinical_status)
+        return query.limit(limit).fetch()
+
+    def update(self, allergy_id: str, allergy_in: schemas.AllergyUpdate) -> schemas.Allergy:
+        return self._update(allergy_id, allergy_in.model_dump(by_alias=True, exclude_unset=True))
+
+    def delete(self, allergy_id: str, deleted_by: str) -> None:
+        self._soft_delete(allergy_id, deleted_by)
+
+    def restore(self, allergy_id: str) -> schemas.Allergy:
+        return self._restore(allergy_id)
@@ -0,0 +1,18 @@
+# Location: app/repositories/postgres/api_keys.py
+
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.api_keys import ApiKeyRecordMixin, ApiKeyRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresApiKeyRepository(ApiKeyRecordMixin, PostgresRepository, ApiKeyRepository):
+    """Stores keys in the `api_keys` table."""
+
+    table = "api_keys"
+    model = schemas.ApiKeyRecord
+    id_field = "keyId"
+
+    def list(self, limit: int = 100, after: Optional[str] = None) -> List[schemas.ApiKeyRecord]:
+        return self._query().order_by("createdAt").start_after(after).limit(limit).fetch()
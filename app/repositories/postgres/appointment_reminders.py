@@ -0,0 +1,38 @@
+# Location: app/repositories/postgres/appointment_reminders.py
+
+from datetime import datetime
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.appointment_reminders import AppointmentReminderRepository, ReminderNotScheduled, finishing
+from app.repositories.base import NotFoundError
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresAppointmentReminderRepository(PostgresRepository, AppointmentReminderRepository):
+    """Stores reminders in the `appointment_reminders` table."""
+
+    table = "appointment_reminders"
+    model = schemas.AppointmentReminder
+    id_field = "reminderId"
+
+    def create(self, appointment: schemas.Appointment, offset_hours: int, due_at: datetime) -> schemas.AppointmentReminder:
+        return self._create({
+            "appointmentId": appointment.appointment_id, "patientId": appointment.patient_id, "offsetHours": offset_hours,
+            "dueAt": due_at, "appointmentStart": appointment.start, "status": "scheduled",
+        })
+
+    def get(self, reminder_id: str) -> Optional[schemas.AppointmentReminder]:
+        return self._get(reminder_id)
+
+    def list(self, appointment_id: str) -> List[schemas.AppointmentReminder]:
+        return self._query().where("appointmentId", "==", appointment_id).limit(100).fetch()
+
+    def list_due(self, due_by: datetime, limit: int = 100) -> List[schemas.AppointmentReminder]:
+        return self._query().where("status", "==", "scheduled").where("dueAt", "<=", due_by).order_by("dueAt").limit(limit).fetch()
+
+    def finish(self, reminder_id: str, status: str, reason: Optional[str] = None) -> Optional[schemas.AppointmentReminder]:
+        try:
+            return self._transform(reminder_id, finishing(status, reason))
+        except (ReminderNotScheduled, NotFoundError):
+            return None
@@ -0,0 +1,61 @@
+# Location: app/repositories/postgres/appointments.py
+
+from datetime import datetime
+from typing import Dict, Iterator, List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.appointments import APPOINTMENT_SEARCH, AppointmentRepository
+from app.repositories.postgres.base import PostgresRepository
+from app.search.filters import Search, apply_search
+from app.services.appointments import ACTIVE_STATUSES
+
+
+class PostgresAppointmentRepository(PostgresRepository, AppointmentRepository):
+    """Stores appointments in the `appointments` table."""
+
+    table = "appointments"
+    model = schemas.Appointment
+    id_field = "appointmentId"
+
+    def create(self, appointment_in: schemas.AppointmentCreate) -> schemas.Appointment:
+        data = appointment_in.model_dump(by_alias=True)
+        data["status"] = "booked"
+        return self._create(data)
+
+    def get(self, appointment_id: str) -> Optional[schemas.Appointment]:
+        return self._get(appointment_id)
+
+    def list(
+        self,
+        patient_id: Optional[str] = None,
+        practitioner_id: Optional[str] = None,
+        start_from: Optional[datetime] = None,
+        start_to: Optional[datetime] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+        search: Optional[Search] = None,
+    ) -> List[schemas.Appointment]:
+        query = self._query()
+        if patient_id:
+            query = query.where("patientId", "==", patient_id)
+        if practitioner_id:
+            query = query.where("practitionerId", "==", practitioner_id)
+        if start_from:
+            query = query.where("start", ">=", start_from)
+        if start_to:
+            query = query.where("start", "<", start_to)
+        return apply_search(query, search or APPOINTMENT_SEARCH.default()).start_after(after).limit(limit).fetch()
+
+    def stream_starting_between(self, start_from: datetime, start_to: datetime) -> Iterator[schemas.Appointment]:
+        return self._query().where("start", ">=", start_from).where("start", "<", start_to).stream()
+
+    def find_overlapping(self, practitioner_id: str, start: datetime, end: datetime, exclude_id: Optional[str] = None) -> List[schemas.Appointment]:
+        query = self._query() \
+            .where("practitionerId", "==", practitioner_id) \
+            .where("status", "in", sorted(ACTIVE_STATUSES)) \
+            .where("start", "<", end) \
+            .where("end", ">", start)
+        return [a for a in query.fetch() if a.appointment_id != exclude_id]
+
+    def update(self, appointment_id: str, changes: Dict) -> schemas.Appointment:
+        return self._update(appointment_id, changes)
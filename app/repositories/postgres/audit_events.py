@@ -0,0 +1,55 @@
+# Location: app/repositories/postgres/audit_events.py
+
+from datetime import datetime
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.audit_events import ANONYMIZED_AUDIT_EVENT, AuditEventRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresAuditEventRepository(PostgresRepository, AuditEventRepository):
+    """
+    Stores audit events in the `audit_events` table. A trigger created by the
+    migrations rejects UPDATE and DELETE on it, so rows can only be appended,
+    except by the retention statements of `PostgresRepository`.
+    """
+
+    table = "audit_events"
+    model = schemas.AuditEvent
+    id_field = "eventId"
+
+    def append(self, event: schemas.AuditEventCreate) -> str:
+        return self._create(event.model_dump(by_alias=True)).event_id
+
+    def get(self, event_id: str) -> Optional[schemas.AuditEvent]:
+        return self._get(event_id)
+
+    def list(
+        self,
+        actor_uid: Optional[str] = None,
+        patient_id: Optional[str] = None,
+        resource_type: Optional[str] = None,
+        occurred_from: Optional[datetime] = None,
+        occurred_to: Optional[datetime] = None,
+        limit: int = 100,
+        after: Optional[str] = None,
+    ) -> List[schemas.AuditEvent]:
+        query = self._query()
+        if actor_uid:
+            query = query.where("actorUid", "==", actor_uid)
+        if patient_id:
+            query = query.where("patientId", "==", patient_id)
+        if resource_type:
+            query = query.where("resourceType", "==", resource_type)
+        if occurred_from:
+            query = query.where("occurredAt", ">=", occurred_from)
+        if occurred_to:
+            query = query.where("occurredAt", "<=", occurred_to)
+        return query.order_by("occurredAt", descending=True).start_after(after).limit(limit).fetch()
+
+    def purge_expired(self, before: datetime) -> int:
+        return self._purge(before, field="occurredAt")
+
+    def anonymize_expired(self, since: Optional[datetime], before: datetime) -> int:
+        return self._anonymize(ANONYMIZED_AUDIT_EVENT, before, since, field="occurredAt")
@@ -0,0 +1,13 @@
+# Location: app/repositories/postgres/audit_exports.py
+
+from app.api.v1 import schemas
+from app.repositories.audit_exports import AuditExportRecordMixin, AuditExportRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresAuditExportRepository(AuditExportRecordMixin, PostgresRepository, AuditExportRepository):
+    """Stores exports in the `audit_exports` table."""
+
+    table = "audit_exports"
+    model = schemas.AuditExport
+    id_field = "exportId"
@@ -0,0 +1,467 @@
+# Location: app/repositories/postgres/base.py
+
+import json
+import logging
+import re
+import uuid
+from datetime import date, datetime, timedelta, timezone
+from typing import Any, Callable, Dict, Iterator, List, Optional, Sequence, Tuple, Type
+
+from pydantic import BaseModel
+
+from app.core.postgres import connection
+from app.repositories.base import (
+    DELETED_FIELD,
+    TENANT_FIELD,
+    VERSION_FIELD,
+    ConflictError,
+    FieldEncryption,
+    NotFoundError,
+    Reseal,
+    SoftDeletion,
+    StaleCursorError,
+    _expected_version,
+    check_version,
+    including_deleted,
+)
+
+# Every resource table has the same shape (see app/db/migrations): the
+# document ID, the record as JSONB and the two bookkeeping timestamps.
+#   id TEXT PRIMARY KEY, data JSONB, created_at TIMESTAMPTZ, updated_at TIMESTAMPTZ
+_FIELD_RE = re.compile(r"^[A-Za-z][A-Za-z0-9]*(\.[A-Za-z][A-Za-z0-9]*)*$")
+_COLUMNS = {"createdAt": "created_at", "updatedAt": "updated_at"}
+
+# Lets the retention statements below past the trigger that keeps
+# `audit_events` append-only (see migration 000009) for the rest of their
+# transaction; other tables ignore the setting.
+RETENTION_PASS_SQL = "SET LOCAL megacare.retention_pass = 'on'"
+
+
+def to_json(value: Any) -> Any:
+    """
+    Recursively converts values into JSON types. Datetimes are written in UTC
+    with a fixed width (`2024-05-01T09:00:00.000000Z`) so that comparing and
+    sorting them as text gives chronological order; dates stay `YYYY-MM-DD`.
+    """
+    if isinstance(value, dict):
+        return {k: to_json(v) for k, v in value.items()}
+    if isinstance(value, (list, tuple)):
+        return [to_json(v) for v in value]
+    if isinstance(value, datetime):
+        if value.tzinfo is None:
+            value = value.replace(tzinfo=timezone.utc)
+        return value.astimezone(timezone.utc).strftime("%Y-%m-%dT%H:%M:%S.%fZ")
+    if isinstance(value, date):
+        return value.isoformat()
+    return value
+
+
+def _jsonb(value: Any):
+    from psycopg.types.json import Jsonb
+    return Jsonb(to_json(value), dumps=lambda obj: json.dumps(obj, ensure_ascii=False))
+
+
+def _nested(path: str, value: Any) -> Dict[str, Any]:
+    """Turns ("substance.code", "x") into {"substance": {"code": "x"}}."""
+    for part in reversed(path.split(".")):
+        value = {part: value}
+    return value
+
+
+def _merge(target: Dict[str, Any], source: Dict[str, Any]) -> None:
+    for key, value in source.items():
+        if isinstance(value, dict) and isinstance(target.get(key), dict):
+            _merge(target[key], value)
+        else:
+            target[key] = value
+
+
+def field_sql(field: str) -> str:
+    """
+    Returns the SQL expression for a field. Document fields are compared as
+    text in the "C" collation, which orders the fixed-width timestamps above
+    chronologically and strings by code point, like Firestore.
+    """
+    if field in _COLUMNS:
+        return _COLUMNS[field]
+    return f"{_text_sql(field)} COLLATE \"C\""
+
+
+def _text_sql(field: str) -> str:
+    """A document field as text in the database's collation, for matching text the way people read it."""
+    if not _FIELD_RE.match(field):
+        raise ValueError(f"Invalid field name '{field}'")
+    parts = field.split(".")
+    if len(parts) == 1:
+        return f"(data->>'{field}')"
+    return f"(data #>> '{{{','.join(parts)}}}')"
+
+
+def _like_pattern(op: str, text: str) -> str:
+    escaped = text.replace("\\", "\\\\").replace("%", "\\%").replace("_", "\\_")
+    return f"{escaped}%" if op == "starts_with" else f"%{escaped}%"
+
+
+class Query:
+    """
+    A small query builder over one resource table, shaped like the Firestore
+    queries it replaces:
+
+        repo._query().where("patientId", "==", patient_id).order_by("start", descending=True).limit(30)
+
+    Equality and `array_contains` filters become a single JSONB containment
+    test (`data @> ...`), which the table's GIN index serves; range filters
+    and ordering use the expression indexes created per table. Rows are
+    ordered by ID after the ordering field, and by ID alone when a limited
+    query has no ordering (like Firestore's document order), so that pages
+    continued with `start_after` neither skip nor repeat rows.
+    """
+
+    def __init__(self, repo: "PostgresRepository"):
+        self.repo = repo
+        self._match: Dict[str, Any] = {}
+        self._clauses: List[str] = []
+        self._params: List[Any] = []
+        self._order: Optional[Tuple[str, bool]] = None
+        self._limit: Optional[int] = None
+        self._after: Optional[str] = None
+        self._after_value: Any = None
+
+    def where(self, field: str, op: str, value: Any) -> "Query":
+        if op == "==":
+            _merge(self._match, _nested(field, to_json(value)))
+        elif op == "array_contains":
+            _merge(self._match, _nested(field, [to_json(value)]))
+        elif op == "in":
+            self._clauses.append(f"{field_sql(field)} = ANY(%s)")
+            self._params.append([to_json(v) for v in value])
+        elif op in ("!=", "<", "<=", ">", ">="):
+            if field in _COLUMNS:
+                self._clauses.append(f"{field_sql(field)} {op} %s")
+                self._params.append(value)
+            elif isinstance(value, (int, float)) and not isinstance(value, bool):
+                # Numbers compare as numbers, not as their text.
+                self._clauses.append(f"{_text_sql(field)}::numeric {op} %s")
+                self._params.append(value)
+            else:
+                self._clauses.append(f"{field_sql(field)} {op} %s")
+                self._params.append(to_json(value))
+        else:
+            raise ValueError(f"Unsupported operator '{op}'")
+        return self
+
+    def where_text(self, fields: Sequence[str], op: str, text: str) -> "Query":
+        """
+        Keeps rows where any of `fields` contains `text`, or starts with it
+        for `op` "starts_with", ignoring case (see app/search).
+        """
+        if op not in ("contains", "starts_with"):
+            raise ValueError(f"Unsupported text operator '{op}'")
+        self._clauses.append("(" + " OR ".join(f"{_text_sql(field)} ILIKE %s" for field in fields) + ")")
+        self._params.extend([_like_pattern(op, text)] * len(fields))
+        return self
+
+    def order_by(self, field: str, descending: bool = False) -> "Query":
+        self._order = (field, descending)
+        return self
+
+    def limit(self, count: int) -> "Query":
+        self._limit = count
+        return self
+
+    def start_after(self, record_id: Optional[str]) -> "Query":
+        """
+        Continues after the row `record_id` in this query's order, for keyset
+        pagination (see app/pagination). Call after `order_by`. Raises
+        StaleCursorError if the row has since been deleted, or is another
+        organization's.
+        """
+        if not record_id:
+            return self
+        if self._order:
+            with connection(self.repo.pool) as conn:
+                tenant = self.repo._tenant()
+                row = conn.execute(
+                    f"SELECT {field_sql(self._order[0])} AS value FROM {self.repo.table} WHERE id = %s{' AND data @> %s' if tenant else ''}",
+                    [record_id, _jsonb({TENANT_FIELD: tenant})] if tenant else [record_id],
+                ).fetchone()
+            if not row:
+                raise StaleCursorError(f"{self.repo.model.__name__} '{record_id}' no longer exists.")
+            self._after_value = row["value"]
+        self._after = record_id
+        return self
+
+    def _conditions(self) -> Tuple[List[str], List[Any]]:
+        clauses, params = list(self._clauses), list(self._params)
+        if self._match:
+            clauses.insert(0, "data @> %s")
+            params.insert(0, _jsonb(self._match))
+        return clauses, params
+
+    def where_sql(self) -> Tuple[str, List[Any]]:
+        """The filters alone, as a condition and its parameters, for DELETE and UPDATE statements over the same rows."""
+        clauses, params = self._conditions()
+        return " AND ".join(clauses) or "TRUE", params
+
+    def to_sql(self) -> Tuple[str, List[Any]]:
+        clauses, params = self._conditions()
+        order = None
+        if self._order:
+            field, descending = self._order
+            direction = "DESC" if descending else "ASC"
+            order = f"{field_sql(field)} {direction}, id {direction}"
+            if self._after:
+                clauses.append(f"({field_sql(field)}, id) {'<' if descending else '>'} (%s, %s)")
+                params.extend([self._after_value, self._after])
+        elif self._limit is not None or self._after:
+            order = "id ASC"
+            if self._after:
+                clauses.append("id > %s")
+                params.append(self._after)
+        statement = f"SELECT * FROM {self.repo.table}"
+        if clauses:
+            statement += " WHERE " + " AND ".join(clauses)
+        if order:
+            statement += f" ORDER BY {order}"
+        if self._limit is not None:
+            statement += " LIMIT %s"
+            params.append(self._limit)
+        return statement, params
+
+    def fetch(self) -> List[Any]:
+        statement, params = self.to_sql()
+        with connection(self.repo.pool) as conn:
+            rows = conn.execute(statement, params).fetchall()
+        return [self.repo._to_model(row) for row in rows]
+
+    def stream(self) -> Iterator[Any]:
+        """Yields records from a server-side cursor, for result sets too large to hold in memory."""
+        statement, params = self.to_sql()
+        with connection(self.repo.pool) as conn:
+            with conn.cursor(name=f"stream_{uuid.uuid4().hex}") as cursor:
+                cursor.execute(statement, params)
+                for row in cursor:
+                    yield self.repo._to_model(row)
+
+
+class PostgresRepository(SoftDeletion, FieldEncryption):
+    """
+    Shared plumbing for repositories backed by one PostgreSQL table, the
+    counterpart of `FirestoreRepository`. Subclasses set `table`, the schema
+    `model` they return and `id_field`, the alias under which the row ID is
+    exposed, and `soft_delete` to keep deleted records. Records are stored as JSONB in the same shape as the Firestore
+    documents, so both stores share the schema layer unchanged. Queries
+    from `_query()` and writes by ID only match the current tenant's rows.
+    """
+    table: str
+    model: Type[BaseModel]
+    id_field: str
+
+    def __init__(self, pool):
+        self.pool = pool
+
+    def _to_model(self, row: Dict[str, Any], reseal: bool = True):
+        data, stale = self._opened(dict(row["data"]))
+        if stale and reseal:
+            self._reseal(row["id"], stale)
+        data[self.id_field] = row["id"]
+        data["createdAt"] = row["created_at"]
+        data["updatedAt"] = row["updated_at"]
+        return self.model.model_validate(data)
+
+    def _reseal(self, record_id: str, stale: Dict[str, Reseal]) -> None:
+        try:
+            with connection(self.pool) as conn:
+                for path, (stored, sealed) in stale.items():
+                    parts = path.split(".")
+                    conn.execute(
+                        f"UPDATE {self.table} SET data = jsonb_set(data, %s, %s) WHERE id = %s AND data #> %s = %s",
+                        [parts, _jsonb(sealed), record_id, parts, _jsonb(stored)],
+                    )
+        except Exception as e:
+            logging.warning(f"Re-encrypting {self.model.__name__} '{record_id}' failed, it is retried on the next read: {e}")
+
+    def _query(self) -> Query:
+        query = Query(self)
+        tenant = self._tenant()
+        if tenant:
+            query.where(TENANT_FIELD, "==", tenant)
+        if self._hides_deleted():
+            query._clauses.append(f"{field_sql(DELETED_FIELD)} IS NULL")
+        return query
+
+    def _scope_sql(self) -> Tuple[str, List[Any]]:
+        """The condition, and its parameters, that limits a write by ID to the current tenant's live rows."""
+        tenant = self._tenant()
+        sql = " AND data @> %s" if tenant else ""
+        if self._hides_deleted():
+            sql += f" AND {field_sql(DELETED_FIELD)} IS NULL"
+        return sql, [_jsonb({TENANT_FIELD: tenant})] if tenant else []
+
+    def _get(self, record_id: str):
+        with connection(self.pool) as conn:
+            row = conn.execute(f"SELECT * FROM {self.table} WHERE id = %s", [record_id]).fetchone()
+        return self._to_model(row) if row and not self._hidden(row["data"]) else None
+
+    def _get_many(self, record_ids: List[str]) -> Dict[str, Any]:
+        """Reads the rows in one statement; returns those that exist, by ID."""
+        with connection(self.pool) as conn:
+            rows = conn.execute(f"SELECT * FROM {self.table} WHERE id = ANY(%s)", [list(record_ids)]).fetchall()
+        return {row["id"]: self._to_model(row) for row in rows if not self._hidden(row["data"])}
+
+    def _create(self, data: Dict[str, Any], record_id: Optional[str] = None):
+        """Inserts `data` with created_at/updated_at timestamps. A random hex ID is generated unless given."""
+        from psycopg import errors
+
+        now = datetime.now(timezone.utc)
+        try:
+            with connection(self.pool) as conn:
+                row = conn.execute(
+                    f"INSERT INTO {self.table} (id, data, created_at, updated_at) VALUES (%s, %s, %s, %s) RETURNING *",
+                    [record_id or uuid.uuid4().hex, _jsonb({VERSION_FIELD: 1, **self._sealed(self._stamped(data))}), now, now],
+                ).fetchone()
+        except errors.UniqueViolation as e:
+            raise ConflictError(f"{self.model.__name__} conflicts with an existing record: {e.diag.constraint_name}")
+        return self._to_model(row)
+
+    def _update(self, record_id: str, changes: Dict[str, Any]):
+        """Merges top-level fields into the stored record in one statement. Raises NotFoundError."""
+        if _expected_version(record_id) is not None:
+            return self._transform(record_id, lambda _record: changes)
+        scope, scope_params = self._scope_sql()
+        with connection(self.pool) as conn:
+            row = conn.execute(
+                f"UPDATE {self.table} SET data = data || %s || jsonb_build_object('{VERSION_FIELD}', "
+                f"COALESCE((data->>'{VERSION_FIELD}')::int, 0) + 1), updated_at = %s WHERE id = %s{scope} RETURNING *",
+                [_jsonb(self._sealed(changes)), datetime.now(timezone.utc), record_id, *scope_params],
+            ).fetchone()
+        if not row:
+            raise NotFoundError(f"{self.model.__name__} '{record_id}' not found.")
+        return self._to_model(row)
+
+    def _transform(self, record_id: str, mutate: Callable[[Any], Dict[str, Any]]):
+        """
+        Read-modify-write under a row lock (`SELECT ... FOR UPDATE`), with the
+        same contract as `FirestoreRepository._transform`: `mutate` receives the
+        stored record and returns the field changes; an exception raised by it
+        rolls the transaction back. Raises NotFoundError or VersionConflictError.
+        """
+        expected = _expected_version(record_id)
+        with connection(self.pool) as conn:
+            row = conn.execute(f"SELECT * FROM {self.table} WHERE id = %s FOR UPDATE", [record_id]).fetchone()
+            if not row or self._hidden(row["data"]):
+                raise NotFoundError(f"{self.model.__name__} '{record_id}' not found.")
+            version = row["data"].get(VERSION_FIELD, 0)
+            check_version(self.model.__name__, record_id, version, expected)
+            # Re-sealing from another connection would wait on this row's lock.
+            changes = mutate(self._to_model(row, reseal=False))
+            row = conn.execute(
+                f"UPDATE {self.table} SET data = data || %s, updated_at = %s WHERE id = %s RETURNING *",
+                [_jsonb({**self._sealed(changes), VERSION_FIELD: version + 1}), datetime.now(timezone.utc), record_id],
+            ).fetchone()
+        return self._to_model(row)
+
+    def _delete(self, record_id: str) -> None:
+        expected = _expected_version(record_id)
+        scope, scope_params = self._scope_sql()
+        with connection(self.pool) as conn:
+            if expected is not None:
+                row = conn.execute(f"SELECT data FROM {self.table} WHERE id = %s FOR UPDATE", [record_id]).fetchone()
+                if row and not self._hidden(row["data"]):
+                    check_version(self.model.__name__, record_id, row["data"].get(VERSION_FIELD, 0), expected)
+            row = conn.execute(f"DELETE FROM {self.table} WHERE id = %s{scope} RETURNING id", [record_id, *scope_params]).fetchone()
+        if not row:
+            raise NotFoundError(f"{self.model.__name__} '{record_id}' not found.")
+
+    def _lease_due(self, limit: int, lease_seconds: float) -> List[Any]:
+        """
+        Claims up to `limit` records with status "pending" whose `nextAttemptAt`
+        has passed, oldest due first, by moving `nextAttemptAt` past the lease.
+        Used by queues kept in the store (the event outbox, webhook deliveries);
+        SKIP LOCKED lets several workers claim disjoint batches concurrently.
+        """
+        now = datetime.now(timezone.utc)
+        due = field_sql("nextAttemptAt")
+        with connection(self.pool) as conn:
+            rows = conn.execute(
+                f"UPDATE {self.table} SET data = data || %s, updated_at = %s WHERE id IN ("
+                f"SELECT id FROM {self.table} WHERE data @> %s AND {due} <= %s "
+                f"ORDER BY {due} LIMIT %s FOR UPDATE SKIP LOCKED) RETURNING *",
+                [
+                    _jsonb({"nextAttemptAt": now + timedelta(seconds=lease_seconds)}),
+                    now,
+                    _jsonb({"status": "pending"}),
+                    to_json(now),
+                    limit,
+                ],
+            ).fetchall()
+        return sorted((self._to_model(row) for row in rows), key=lambda record: record.created_at)
+
+    def _purge(self, before: datetime, match: Optional[Dict[str, Any]] = None, field: str = "updatedAt") -> int:
+        """Deletes the rows from `_query()` whose `field` is before `before` and that contain `match`. Returns how many were deleted."""
+        query = self._query().where(field, "<", before)
+        for name, value in (match or {}).items():
+            query.where(name, "==", value)
+        condition, params = query.where_sql()
+        with connection(self.pool) as conn:
+            conn.execute(RETENTION_PASS_SQL)
+            cursor = conn.execute(f"DELETE FROM {self.table} WHERE {condition}", params)
+        return cursor.rowcount
+
+    def _anonymize(
+        self,
+        changes: Dict[str, Any],
+        before: datetime,
+        since: Optional[datetime] = None,
+        match: Optional[Dict[str, Any]] = None,
+        field: str = "updatedAt",
+    ) -> int:
+        """Applies `changes` (by dotted path) to the matching rows in one statement, like the Firestore version. Returns how many."""
+        query = self._query().where(field, "<", before)
+        if since:
+            query.where(field, ">=", since)
+        for name, value in (match or {}).items():
+            query.where(name, "==", value)
+        condition, params = query.where_sql()
+        data, values = "data", []
+        for path, value in changes.items():
+            data = f"jsonb_set({data}, %s, %s)"
+            values += [path.split("."), _jsonb(value)]
+        sql = (
+            f"UPDATE {self.table} SET data = {data} || jsonb_build_object('{VERSION_FIELD}', "
+            f"COALESCE((data->>'{VERSION_FIELD}')::int, 0) + 1), updated_at = %s WHERE {condition}"
+        )
+        with connection(self.pool) as conn:
+            conn.execute(RETENTION_PASS_SQL)
+            cursor = conn.execute(sql, [*values, datetime.now(timezone.utc), *params])
+        return cursor.rowcount
+
+    def assign_tenant(self, tenant: str) -> int:
+        """Stamps `tenant` on the rows that have none, in one statement. Returns how many."""
+        with connection(self.pool) as conn:
+            cursor = conn.execute(
+                f"UPDATE {self.table} SET data = data || %s WHERE COALESCE(data->>'{TENANT_FIELD}', '') = ''",
+                [_jsonb({TENANT_FIELD: tenant})],
+            )
+        return cursor.rowcount
+
+    def reassign_patient(self, source_patient_id: str, target_patient_id: str) -> int:
+        """Moves the current organization's rows of patient `source` to patient `target`, deleted ones included, in one statement. Returns how many."""
+        with including_deleted():
+            condition, params = self._query().where("patientId", "==", source_patient_id).where_sql()
+        sql = (
+            f"UPDATE {self.table} SET data = data || jsonb_build_object('patientId', %s::text, '{VERSION_FIELD}', "
+            f"COALESCE((data->>'{VERSION_FIELD}')::int, 0) + 1), updated_at = %s WHERE {condition}"
+        )
+        with connection(self.pool) as conn:
+            cursor = conn.execute(sql, [target_patient_id, datetime.now(timezone.utc), *params])
+        return cursor.rowcount
+
+    def _stream_updated_between(self, updated_since: Optional[datetime], updated_before: Optional[datetime]) -> Iterator:
+        """Streams the whole table as models, optionally bounded by `updatedAt`."""
+        query = self._query()
+        if updated_since:
+            query = query.where("updatedAt", ">=", updated_since)
+        if updated_before:
+            query = query.where("updatedAt", "<", updated_before)
+        return query.stream()
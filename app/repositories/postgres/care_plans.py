@@ -0,0 +1,21 @@
+# Location: app/repositories/postgres/care_plans.py
+
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.care_plans import CarePlanRecordMixin, CarePlanRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresCarePlanRepository(CarePlanRecordMixin, PostgresRepository, CarePlanRepository):
+    """Stores care plans in the `care_plans` table."""
+
+    table = "care_plans"
+    model = schemas.CarePlan
+    id_field = "carePlanId"
+
+    def list(self, patient_id: str, status: Optional[str] = None, limit: int = 30, after: Optional[str] = None) -> List[schemas.CarePlan]:
+        query = self._query().where("patientId", "==", patient_id)
+        if status:
+            query = query.where("status", "==", status)
+        return query.start_after(after).limit(limit).fetch()
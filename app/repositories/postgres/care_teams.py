@@ -0,0 +1,23 @@
+# Location: app/repositories/postgres/care_teams.py
+
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.care_teams import CareTeamRecordMixin, CareTeamRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresCareTeamRepository(CareTeamRecordMixin, PostgresRepository, CareTeamRepository):
+    """Stores care teams in the `care_teams` table."""
+
+    table = "care_teams"
+    model = schemas.CareTeam
+    id_field = "careTeamId"
+
+    def list(self, patient_id: Optional[str] = None, practitioner_id: Optional[str] = None, limit: int = 30, after: Optional[str] = None) -> List[schemas.CareTeam]:
+        query = self._query()
+        if patient_id:
+            query = query.where("patientId", "==", patient_id)
+        if practitioner_id:
+            query = query.where("memberIds", "array_contains", practitioner_id)
+        return query.start_after(after).limit(limit).fetch()
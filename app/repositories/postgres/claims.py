@@ -0,0 +1,52 @@
+# Location: app/repositories/postgres/claims.py
+
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.claims import (
+    ChargeItemRecordMixin,
+    ChargeItemRepository,
+    ClaimRecordMixin,
+    ClaimRepository,
+    _by_service_date,
+)
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresChargeItemRepository(ChargeItemRecordMixin, PostgresRepository, ChargeItemRepository):
+    """Stores charge items in the `charge_items` table."""
+
+    table = "charge_items"
+    model = schemas.ChargeItem
+    id_field = "chargeItemId"
+
+    def list(self, encounter_id: str, status: Optional[str] = None) -> List[schemas.ChargeItem]:
+        query = self._query().where("encounterId", "==", encounter_id)
+        if status:
+            query = query.where("status", "==", status)
+        return _by_service_date(query.fetch())
+
+
+class PostgresClaimRepository(ClaimRecordMixin, PostgresRepository, ClaimRepository):
+    """Stores claims in the `claims` table."""
+
+    table = "claims"
+    model = schemas.Claim
+    id_field = "claimId"
+
+    def list(
+        self,
+        patient_id: Optional[str] = None,
+        encounter_id: Optional[str] = None,
+        statuses: Optional[List[str]] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+    ) -> List[schemas.Claim]:
+        query = self._query()
+        if patient_id:
+            query = query.where("patientId", "==", patient_id)
+        if encounter_id:
+            query = query.where("encounterId", "==", encounter_id)
+        if statuses:
+            query = query.where("status", "in", statuses)
+        return query.order_by("createdAt", descending=True).start_after(after).limit(limit).fetch()
@@ -0,0 +1,91 @@
+# Location: app/repositories/postgres/consents.py
+
+import uuid
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional
+
+from app.api.v1 import schemas
+from app.core.postgres import connection
+from app.repositories.consents import ConsentDocumentRepository, ConsentRepository
+from app.repositories.base import TENANT_FIELD
+from app.repositories.postgres.base import PostgresRepository, _jsonb
+
+
+class PostgresConsentDocumentRepository(PostgresRepository, ConsentDocumentRepository):
+    """Stores consent document versions in the `consent_documents` table."""
+
+    table = "consent_documents"
+    model = schemas.ConsentDocument
+    id_field = "documentId"
+
+    def create(self, document_in: schemas.ConsentDocumentCreate, published_by: str) -> schemas.ConsentDocument:
+        now = datetime.now(timezone.utc)
+        tenant = self._tenant() or ""
+        with connection(self.pool) as conn:
+            # Serialise publishes per organization and scope so concurrent ones
+            # cannot both become version N; the unique index backs this up.
+            conn.execute("SELECT pg_advisory_xact_lock(hashtext(%s))", [f"consent_documents:{tenant}:{document_in.scope}"])
+            latest = conn.execute(
+                "SELECT COALESCE(MAX((data->>'version')::int), 0) AS version FROM consent_documents "
+                f"WHERE data->>'scope' = %s AND COALESCE(data->>'{TENANT_FIELD}', '') = %s",
+                [document_in.scope, tenant],
+            ).fetchone()
+            data = {**document_in.model_dump(by_alias=True), "version": latest["version"] + 1, "publishedBy": published_by}
+            row = conn.execute(
+                "INSERT INTO consent_documents (id, data, created_at, updated_at) VALUES (%s, %s, %s, %s) RETURNING *",
+                [uuid.uuid4().hex, _jsonb(self._stamped(data)), now, now],
+            ).fetchone()
+        return self._to_model(row)
+
+    def get(self, document_id: str) -> Optional[schemas.ConsentDocument]:
+        return self._get(document_id)
+
+    def list(self, scope: str) -> List[schemas.ConsentDocument]:
+        # Versions are numbers, so order them numerically rather than as text.
+        tenant = self._tenant()
+        with connection(self.pool) as conn:
+            rows = conn.execute(
+                "SELECT * FROM consent_documents WHERE data->>'scope' = %s AND data @> %s ORDER BY (data->>'version')::int DESC",
+                [scope, _jsonb({TENANT_FIELD: tenant} if tenant else {})],
+            ).fetchall()
+        return [self._to_model(row) for row in rows]
+
+
+class PostgresConsentRepository(PostgresRepository, ConsentRepository):
+    """Stores consents in the `consents` table."""
+
+    table = "consents"
+    model = schemas.Consent
+    id_field = "consentId"
+
+    def create(self, patient_id: str, grant: schemas.ConsentGrant, document: schemas.ConsentDocument, granted_by: str) -> schemas.Consent:
+        return self._create({
+            **grant.model_dump(by_alias=True),
+            "patientId": patient_id,
+            "documentVersion": document.version,
+            "status": "active",
+            "grantedAt": datetime.now(timezone.utc),
+            "grantedBy": granted_by,
+        })
+
+    def get(self, consent_id: str) -> Optional[schemas.Consent]:
+        return self._get(consent_id)
+
+    def list(
+        self,
+        patient_id: str,
+        scope: Optional[str] = None,
+        organization_id: Optional[str] = None,
+        status: Optional[str] = None,
+    ) -> List[schemas.Consent]:
+        query = self._query().where("patientId", "==", patient_id)
+        if scope:
+            query = query.where("scope", "==", scope)
+        if organization_id:
+            query = query.where("organizationId", "==", organization_id)
+        if status:
+            query = query.where("status", "==", status)
+        return query.order_by("grantedAt", descending=True).fetch()
+
+    def update(self, consent_id: str, changes: Dict[str, Any]) -> schemas.Consent:
+        return self._update(consent_id, changes)
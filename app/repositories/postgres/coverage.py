@@ -0,0 +1,38 @@
+# Location: app/repositories/postgres/coverage.py
+
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.coverage import (
+    CoverageRecordMixin,
+    CoverageRepository,
+    EligibilityCheckRecordMixin,
+    EligibilityCheckRepository,
+    _by_priority,
+)
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresCoverageRepository(CoverageRecordMixin, PostgresRepository, CoverageRepository):
+    """Stores coverages in the `coverage` table."""
+
+    table = "coverage"
+    model = schemas.Coverage
+    id_field = "coverageId"
+
+    def list(self, patient_id: str, status: Optional[str] = None) -> List[schemas.Coverage]:
+        query = self._query().where("patientId", "==", patient_id)
+        if status:
+            query = query.where("status", "==", status)
+        return _by_priority(query.fetch())
+
+
+class PostgresEligibilityCheckRepository(EligibilityCheckRecordMixin, PostgresRepository, EligibilityCheckRepository):
+    """Stores checks in the `eligibility_checks` table."""
+
+    table = "eligibility_checks"
+    model = schemas.EligibilityCheck
+    id_field = "checkId"
+
+    def list(self, coverage_id: str, limit: int = 20) -> List[schemas.EligibilityCheck]:
+        return self._query().where("coverageId", "==", coverage_id).order_by("createdAt", descending=True).limit(limit).fetch()
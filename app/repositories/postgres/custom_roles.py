@@ -0,0 +1,18 @@
+# Location: app/repositories/postgres/custom_roles.py
+
+from typing import List
+
+from app.api.v1 import schemas
+from app.repositories.custom_roles import CustomRoleRecordMixin, CustomRoleRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresCustomRoleRepository(CustomRoleRecordMixin, PostgresRepository, CustomRoleRepository):
+    """Stores roles in the `custom_roles` table."""
+
+    table = "custom_roles"
+    model = schemas.CustomRole
+    id_field = "roleId"
+
+    def list(self) -> List[schemas.CustomRole]:
+        return sorted(self._query().fetch(), key=lambda role: role.name)
@@ -0,0 +1,51 @@
+# Location: app/repositories/postgres/encounters.py
+
+from datetime import datetime
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.base import NotFoundError
+from app.repositories.encounters import EncounterRecordMixin, EncounterRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresEncounterRepository(EncounterRecordMixin, PostgresRepository, EncounterRepository):
+    """Stores encounters in the `encounters` table."""
+
+    table = "encounters"
+    model = schemas.Encounter
+    id_field = "encounterId"
+
+    def list(
+        self,
+        patient_id: Optional[str] = None,
+        practitioner_id: Optional[str] = None,
+        start_from: Optional[datetime] = None,
+        start_to: Optional[datetime] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+    ) -> List[schemas.Encounter]:
+        query = self._query()
+        if patient_id:
+            query = query.where("patientId", "==", patient_id)
+        if practitioner_id:
+            query = query.where("participantIds", "array_contains", practitioner_id)
+        if start_from:
+            query = query.where("start", ">=", start_from)
+        if start_to:
+            query = query.where("start", "<", start_to)
+        return query.order_by("start", descending=True).start_after(after).limit(limit).fetch()
+
+    def link_observation(self, encounter_id: str, observation_id: str) -> schemas.Encounter:
+        def mutate(encounter: schemas.Encounter):
+            if observation_id in encounter.observation_ids:
+                return {}
+            return {"observationIds": encounter.observation_ids + [observation_id]}
+        return self._transform(encounter_id, mutate)
+
+    def unlink_observation(self, encounter_id: str, observation_id: str) -> schemas.Encounter:
+        def mutate(encounter: schemas.Encounter):
+            if observation_id not in encounter.observation_ids:
+                raise NotFoundError(f"Observation '{observation_id}' is not linked to this encounter.")
+            return {"observationIds": [o for o in encounter.observation_ids if o != observation_id]}
+        return self._transform(encounter_id, mutate)
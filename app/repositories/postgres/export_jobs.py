@@ -0,0 +1,24 @@
+# Location: app/repositories/postgres/export_jobs.py
+
+from typing import Dict, Optional
+
+from app.api.v1 import schemas
+from app.repositories.export_jobs import ExportJobRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresExportJobRepository(PostgresRepository, ExportJobRepository):
+    """Stores export jobs in the `export_jobs` table."""
+
+    table = "export_jobs"
+    model = schemas.ExportJob
+    id_field = "jobId"
+
+    def create(self, job_in: schemas.ExportJobCreate) -> schemas.ExportJob:
+        return self._create({**job_in.model_dump(by_alias=True), "status": "accepted", "output": []})
+
+    def get(self, job_id: str) -> Optional[schemas.ExportJob]:
+        return self._get(job_id)
+
+    def update(self, job_id: str, changes: Dict) -> schemas.ExportJob:
+        return self._update(job_id, changes)
@@ -0,0 +1,18 @@
+# Location: app/repositories/postgres/feature_flags.py
+
+from typing import List
+
+from app.api.v1 import schemas
+from app.repositories.feature_flags import FeatureFlagRecordMixin, FeatureFlagRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresFeatureFlagRepository(FeatureFlagRecordMixin, PostgresRepository, FeatureFlagRepository):
+    """Stores flags in the `feature_flags` table."""
+
+    table = "feature_flags"
+    model = schemas.FeatureFlag
+    id_field = "key"
+
+    def list(self) -> List[schemas.FeatureFlag]:
+        return sorted(self._query().fetch(), key=lambda flag: flag.key)
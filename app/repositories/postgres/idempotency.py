@@ -0,0 +1,13 @@
+# Location: app/repositories/postgres/idempotency.py
+
+from app.api.v1 import schemas
+from app.repositories.idempotency import IdempotencyRecordMixin, IdempotencyRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresIdempotencyRepository(IdempotencyRecordMixin, PostgresRepository, IdempotencyRepository):
+    """Stores records in the `idempotency_records` table, keyed by record ID."""
+
+    table = "idempotency_records"
+    model = schemas.IdempotencyRecord
+    id_field = "recordId"
@@ -0,0 +1,30 @@
+# Location: app/repositories/postgres/immunizations.py
+
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.immunizations import ImmunizationRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresImmunizationRepository(PostgresRepository, ImmunizationRepository):
+    """Stores immunizations in the `immunizations` table."""
+
+    table = "immunizations"
+    model = schemas.Immunization
+    id_field = "immunizationId"
+
+    def create(self, patient_id: str, immunization_in: schemas.ImmunizationCreate, recorded_by: str) -> schemas.Immunization:
+        return self._create({**immunization_in.model_dump(by_alias=True), "patientId": patient_id, "recordedBy": recorded_by})
+
+    def get(self, immunization_id: str) -> Optional[schemas.Immunization]:
+        return self._get(immunization_id)
+
+    def list(self, patient_id: str, vaccine_code: Optional[str] = None, limit: int = 100, after: Optional[str] = None) -> List[schemas.Immunization]:
+        query = self._query().where("patientId", "==", patient_id)
+        if vaccine_code:
+            query = query.where("vaccineCode.code", "==", vaccine_code)
+        return query.order_by("occurrenceDate", descending=True).start_after(after).limit(limit).fetch()
+
+    def update(self, immunization_id: str, immunization_in: schemas.ImmunizationUpdate) -> schemas.Immunization:
+        return self._update(immunization_id, immunization_in.model_dump(by_alias=True, exclude_unset=True))
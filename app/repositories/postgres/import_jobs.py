@@ -0,0 +1,24 @@
+# Location: app/repositories/postgres/import_jobs.py
+
+from typing import Dict, Optional
+
+from app.api.v1 import schemas
+from app.repositories.import_jobs import ImportJobRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresImportJobRepository(PostgresRepository, ImportJobRepository):
+    """Stores import jobs in the `import_jobs` table."""
+
+    table = "import_jobs"
+    model = schemas.ImportJob
+    id_field = "jobId"
+
+    def create(self, job_id: str, job_in: schemas.ImportJobCreate) -> schemas.ImportJob:
+        return self._create({**job_in.model_dump(by_alias=True), "status": "accepted", "offset": 0, "linesRead": 0, "imported": {}, "skipped": 0, "failed": 0}, record_id=job_id)
+
+    def get(self, job_id: str) -> Optional[schemas.ImportJob]:
+        return self._get(job_id)
+
+    def update(self, job_id: str, changes: Dict) -> schemas.ImportJob:
+        return self._update(job_id, changes)
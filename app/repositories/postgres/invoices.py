@@ -0,0 +1,32 @@
+# Location: app/repositories/postgres/invoices.py
+
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.invoices import InvoiceRecordMixin, InvoiceRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresInvoiceRepository(InvoiceRecordMixin, PostgresRepository, InvoiceRepository):
+    """Stores invoices in the `invoices` table."""
+
+    table = "invoices"
+    model = schemas.Invoice
+    id_field = "invoiceId"
+
+    def list(
+        self,
+        patient_id: Optional[str] = None,
+        claim_id: Optional[str] = None,
+        statuses: Optional[List[str]] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+    ) -> List[schemas.Invoice]:
+        query = self._query()
+        if patient_id:
+            query = query.where("patientId", "==", patient_id)
+        if claim_id:
+            query = query.where("claimId", "==", claim_id)
+        if statuses:
+            query = query.where("status", "in", statuses)
+        return query.order_by("createdAt", descending=True).start_after(after).limit(limit).fetch()
@@ -0,0 +1,49 @@
+# Location: app/repositories/postgres/lab_results.py
+
+from datetime import datetime
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.lab_results import LabResultRepository
+from app.repositories.postgres.base import PostgresRepository
+from app.services.lab_results import is_abnormal
+
+
+class PostgresLabResultRepository(PostgresRepository, LabResultRepository):
+    """Stores lab results in the `lab_results` table, with the same denormalised `codes` array as Firestore."""
+
+    table = "lab_results"
+    model = schemas.LabResult
+    id_field = "labResultId"
+
+    def create(self, patient_id: str, lab_in: schemas.LabResultCreate, recorded_by: str) -> schemas.LabResult:
+        data = lab_in.model_dump(by_alias=True)
+        codes = ([lab_in.panel_code] if lab_in.panel_code else []) + [a.code for a in lab_in.analytes]
+        data.update({
+            "patientId": patient_id,
+            "codes": codes,
+            "abnormal": is_abnormal(lab_in.analytes),
+            "recordedBy": recorded_by,
+        })
+        return self._create(data)
+
+    def get(self, lab_result_id: str) -> Optional[schemas.LabResult]:
+        return self._get(lab_result_id)
+
+    def list(
+        self,
+        patient_id: str,
+        code: Optional[str] = None,
+        start_from: Optional[datetime] = None,
+        start_to: Optional[datetime] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+    ) -> List[schemas.LabResult]:
+        query = self._query().where("patientId", "==", patient_id)
+        if code:
+            query = query.where("codes", "array_contains", code)
+        if start_from:
+            query = query.where("effectiveAt", ">=", start_from)
+        if start_to:
+            query = query.where("effectiveAt", "<", start_to)
+        return query.order_by("effectiveAt", descending=True).start_after(after).limit(limit).fetch()
@@ -0,0 +1,19 @@
+# Location: app/repositories/postgres/lockouts.py
+
+from datetime import datetime
+from typing import List
+
+from app.api.v1 import schemas
+from app.repositories.lockouts import LockoutRecordMixin, LockoutRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresLockoutRepository(LockoutRecordMixin, PostgresRepository, LockoutRepository):
+    """Stores records in the `lockouts` table."""
+
+    table = "lockouts"
+    model = schemas.Lockout
+    id_field = "lockoutId"
+
+    def list_locked(self, now: datetime, limit: int = 100) -> List[schemas.Lockout]:
+        return self._query().where("lockedUntil", ">", now).order_by("lockedUntil", descending=True).limit(limit).fetch()
@@ -0,0 +1,13 @@
+# Location: app/repositories/postgres/magic_links.py
+
+from app.api.v1 import schemas
+from app.repositories.magic_links import MagicLinkRecordMixin, MagicLinkRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresMagicLinkRepository(MagicLinkRecordMixin, PostgresRepository, MagicLinkRepository):
+    """Stores links in the `magic_links` table."""
+
+    table = "magic_links"
+    model = schemas.MagicLinkRecord
+    id_field = "linkId"
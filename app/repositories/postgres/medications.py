@@ -0,0 +1,66 @@
+# Location: app/repositories/postgres/medications.py
+
+from typing import Any, Dict, Iterator, List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.medications import MedicationRepository, RefillRequestRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresMedicationRepository(PostgresRepository, MedicationRepository):
+    """Stores medications in the `medications` table."""
+
+    table = "medications"
+    model = schemas.Medication
+    id_field = "medicationId"
+
+    def create(self, patient_id: str, medication_in: schemas.MedicationCreate) -> schemas.Medication:
+        return self._create({**medication_in.model_dump(by_alias=True), "patientId": patient_id, "refillsUsed": 0})
+
+    def get(self, medication_id: str) -> Optional[schemas.Medication]:
+        return self._get(medication_id)
+
+    def list(self, patient_id: str, status: Optional[str] = None, limit: int = 100) -> List[schemas.Medication]:
+        query = self._query().where("patientId", "==", patient_id)
+        if status:
+            query = query.where("status", "==", status)
+        return query.limit(limit).fetch()
+
+    def update(self, medication_id: str, medication_in: schemas.MedicationUpdate) -> schemas.Medication:
+        return self._update(medication_id, medication_in.model_dump(by_alias=True, exclude_unset=True))
+
+    def record_refill(self, medication_id: str) -> schemas.Medication:
+        # Under the row lock, so concurrent approvals are both counted.
+        return self._transform(medication_id, lambda medication: {"refillsUsed": medication.refills_used + 1})
+
+
+class PostgresRefillRequestRepository(PostgresRepository, RefillRequestRepository):
+    """Stores refill requests in the `refill_requests` table."""
+
+    table = "refill_requests"
+    model = schemas.RefillRequest
+    id_field = "refillRequestId"
+
+    def create(self, medication: schemas.Medication, request_in: schemas.RefillRequestCreate, requested_by: str) -> schemas.RefillRequest:
+        return self._create({
+            **request_in.model_dump(by_alias=True),
+            "patientId": medication.patient_id,
+            "medicationId": medication.medication_id,
+            "status": "requested",
+            "requestedBy": requested_by,
+        })
+
+    def get(self, refill_request_id: str) -> Optional[schemas.RefillRequest]:
+        return self._get(refill_request_id)
+
+    def list(self, medication_id: str, statuses: Optional[List[str]] = None, limit: int = 30, after: Optional[str] = None) -> List[schemas.RefillRequest]:
+        query = self._query().where("medicationId", "==", medication_id)
+        if statuses:
+            query = query.where("status", "in", list(statuses))
+        return query.order_by("createdAt", descending=True).start_after(after).limit(limit).fetch()
+
+    def stream_by_status(self, statuses: List[str]) -> Iterator[schemas.RefillRequest]:
+        return self._query().where("status", "in", list(statuses)).stream()
+
+    def update(self, refill_request_id: str, changes: Dict[str, Any]) -> schemas.RefillRequest:
+        return self._update(refill_request_id, changes)
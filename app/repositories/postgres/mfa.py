@@ -0,0 +1,13 @@
+# Location: app/repositories/postgres/mfa.py
+
+from app.api.v1 import schemas
+from app.repositories.mfa import MfaEnrollmentRecordMixin, MfaEnrollmentRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresMfaEnrollmentRepository(MfaEnrollmentRecordMixin, PostgresRepository, MfaEnrollmentRepository):
+    """Stores enrollments in the `mfa_enrollments` table."""
+
+    table = "mfa_enrollments"
+    model = schemas.MfaEnrollmentRecord
+    id_field = "uid"
@@ -0,0 +1,41 @@
+# Location: app/repositories/postgres/notifications.py
+
+from datetime import datetime, timezone
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.notifications import NotificationRepository
+from app.repositories.postgres.base import PostgresRepository
+from app.services.notifications import delivery_changes
+
+
+class PostgresNotificationRepository(PostgresRepository, NotificationRepository):
+    """Stores notifications in the `notifications` table."""
+
+    table = "notifications"
+    model = schemas.Notification
+    id_field = "notificationId"
+
+    def create(self, notification_in: schemas.NotificationCreate, status: str = "queued") -> schemas.Notification:
+        return self._create({**notification_in.model_dump(by_alias=True), "status": status, "deliveryEvents": []})
+
+    def get(self, notification_id: str) -> Optional[schemas.Notification]:
+        return self._get(notification_id)
+
+    def list(self, patient_id: str, status: Optional[str] = None, limit: int = 100) -> List[schemas.Notification]:
+        query = self._query().where("patientId", "==", patient_id)
+        if status:
+            query = query.where("status", "==", status)
+        return query.limit(limit).fetch()
+
+    def mark_sent(self, notification_id: str, provider: str, sender: str, subject: Optional[str], provider_message_id: Optional[str]) -> schemas.Notification:
+        return self._update(notification_id, {
+            "status": "sent", "provider": provider, "sender": sender, "subject": subject,
+            "providerMessageId": provider_message_id, "sentAt": datetime.now(timezone.utc),
+        })
+
+    def mark_failed(self, notification_id: str, error: str) -> schemas.Notification:
+        return self._update(notification_id, {"status": "failed", "error": error})
+
+    def record_delivery(self, notification_id: str, event: schemas.DeliveryEvent) -> schemas.Notification:
+        return self._transform(notification_id, lambda notification: delivery_changes(notification, event))
@@ -0,0 +1,60 @@
+# Location: app/repositories/postgres/observations.py
+
+from datetime import datetime
+from typing import Iterator, List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.observations import ANONYMIZED_OBSERVATION, OBSERVATION_SEARCH, ObservationRepository
+from app.repositories.postgres.base import PostgresRepository
+from app.search.filters import Search, apply_search
+
+
+class PostgresObservationRepository(PostgresRepository, ObservationRepository):
+    """Stores observations in the `observations` table."""
+
+    table = "observations"
+    model = schemas.Observation
+    id_field = "observationId"
+
+    def create(self, observation_in: schemas.ObservationCreate) -> schemas.Observation:
+        return self._create(observation_in.model_dump(by_alias=True))
+
+    def get(self, observation_id: str) -> Optional[schemas.Observation]:
+        return self._get(observation_id)
+
+    def find_by_identifier(self, system: Optional[str], value: str) -> List[schemas.Observation]:
+        query = self._query().where("identifier.value", "==", value)
+        if system:
+            query = query.where("identifier.system", "==", system)
+        return query.fetch()
+
+    def list(
+        self,
+        patient_id: str,
+        code: Optional[str] = None,
+        start_from: Optional[datetime] = None,
+        start_to: Optional[datetime] = None,
+        limit: int = 30,
+        after: Optional[str] = None,
+        search: Optional[Search] = None,
+    ) -> List[schemas.Observation]:
+        query = self._query().where("patientId", "==", patient_id)
+        if code:
+            query = query.where("code.code", "==", code)
+        if start_from:
+            query = query.where("effectiveAt", ">=", start_from)
+        if start_to:
+            query = query.where("effectiveAt", "<", start_to)
+        return apply_search(query, search or OBSERVATION_SEARCH.default()).start_after(after).limit(limit).fetch()
+
+    def stream(self, updated_since: Optional[datetime] = None, updated_before: Optional[datetime] = None) -> Iterator[schemas.Observation]:
+        return self._stream_updated_between(updated_since, updated_before)
+
+    def stream_taken_between(self, start_from: datetime, start_to: datetime) -> Iterator[schemas.Observation]:
+        return self._query().where("effectiveAt", ">=", start_from).where("effectiveAt", "<", start_to).stream()
+
+    def purge_expired(self, before: datetime) -> int:
+        return self._purge(before, field="effectiveAt")
+
+    def anonymize_expired(self, since: Optional[datetime], before: datetime) -> int:
+        return self._anonymize(ANONYMIZED_OBSERVATION, before, since, field="effectiveAt")
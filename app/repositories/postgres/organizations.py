@@ -0,0 +1,18 @@
+# Location: app/repositories/postgres/organizations.py
+
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.organizations import OrganizationRecordMixin, OrganizationRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresOrganizationRepository(OrganizationRecordMixin, PostgresRepository, OrganizationRepository):
+    """Stores organizations in the `organizations` table."""
+
+    table = "organizations"
+    model = schemas.Organization
+    id_field = "organizationId"
+
+    def list(self, limit: int = 100, after: Optional[str] = None) -> List[schemas.Organization]:
+        return self._query().order_by("name").start_after(after).limit(limit).fetch()
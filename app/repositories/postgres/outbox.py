@@ -0,0 +1,17 @@
+# Location: app/repositories/postgres/outbox.py
+
+from app.api.v1 import schemas
+from app.repositories.outbox import OutboxRecordMixin, OutboxRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresOutboxRepository(OutboxRecordMixin, PostgresRepository, OutboxRepository):
+    """
+    Stores the outbox in the `event_outbox` table. `add` uses the connection
+    of the enclosing `transaction()`, so the entry commits or rolls back with
+    the change it describes.
+    """
+
+    table = "event_outbox"
+    model = schemas.OutboxEntry
+    id_field = "eventId"
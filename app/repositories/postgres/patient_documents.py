@@ -0,0 +1,45 @@
+# Location: app/repositories/postgres/patient_documents.py
+
+from datetime import datetime, timezone
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.patient_documents import PatientDocumentRepository
+from app.repositories.postgres.base import PostgresRepository
+
+
+class PostgresPatientDocumentRepository(PostgresRepository, PatientDocumentRepository):
+    """Stores document metadata in the `patient_documents` table."""
+
+    table = "patient_documents"
+    model = schemas.PatientDocument
+    id_field = "documentId"
+
+    def create(
+        self, patient_id: str, document_id: str, document_in: schemas.PatientDocumentCreate, blob_name: str, uploaded_by: str,
+    ) -> schemas.PatientDocument:
+        data = {**document_in.model_dump(by_alias=True), "patientId": patient_id, "blobName": blob_name, "status": "pending", "uploadedBy": uploaded_by}
+        return self._create(data, record_id=document_id)
+
+    def get(self, document_id: str) -> Optional[schemas.PatientDocument]:
+        return self._get(document_id)
+
+    def list(
+        self, patient_id: str, type: Optional[str] = None, status: Optional[str] = None, limit: int = 100,
+    ) -> List[schemas.PatientDocument]:
+        query = self._query().where("patientId", "==", patient_id)
+        if type:
+            query = query.where("type", "==", type)
+        if status:
+            query = query.where("status", "==", status)
+        return query.limit(limit).fetch()
+
+    def mark_uploaded(self, document_id: str, status: str) -> schemas.PatientDocument:
+        return self._update(document_id, {"status": status, "uploadedAt": datetime.now(timezone.utc)})
+
+    def record_scan(self, document_id: str, threat: Optional[str]) -> schemas.PatientDocument:
+        status = "quarantined" if threat else "available"
+        return self._update(document_id, {"status": status, "scannedAt": datetime.now(timezone.utc), "threat": threat})
+
+    def delete(self, document_id: str) -> None:
+        self._delete(document_id)
@@ -0,0 +1,79 @@
+# Location: app/repositories/postgres/patients.py
+
+from datetime import datetime
+from typing import Dict, Iterator, List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.base import ConflictError, including_deleted
+from app.repositories.patients import PATIENT_SEARCH, PatientRepository, merged, push_preferences, sms_preferences
+from app.repositories.postgres.base import PostgresRepository
+from app.search.filters import Search, apply_search
+
+
+class PostgresPatientRepository(PostgresRepository, PatientRepository):
+    """Stores patients in the `patients` table; MRNs are unique per organization through an index."""
+
+    table = "patients"
+    model = schemas.Patient
+    id_field = "patientId"
+    soft_delete = True
+
+    def create(self, patient_in: schemas.PatientCreate) -> schemas.Patient:
+        with including_deleted():
+            taken = self.get_by_mrn(patient_in.mrn)
+        if taken:
+            raise ConflictError(f"A patient with MRN '{patient_in.mrn}' already exists.")
+        return self._create(patient_in.model_dump(by_alias=True))
+
+    def get(self, patient_id: str) -> Optional[schemas.Patient]:
+        return self._get(patient_id)
+
+    def get_many(self, patient_ids: List[str]) -> Dict[str, schemas.Patient]:
+        return self._get_many(patient_ids)
+
+    def get_by_mrn(self, mrn: str) -> Optional[schemas.Patient]:
+        patients = self._query().where("mrn", "==", mrn).limit(1).fetch()
+        return patients[0] if patients else None
+
+    def list(self, limit: int = 30, after: Optional[str] = None, search: Optional[Search] = None) -> List[schemas.Patient]:
+        return apply_search(self._query(), search or PATIENT_SEARCH.default()).start_after(after).limit(limit).fetch()
+
+    def find_candidates(self, patient: schemas.Patient, limit: int = 50) -> List[schemas.Patient]:
+        blocks = [("dob", patient.dob), ("familyName", patient.family_name)]
+        if patient.contact and patient.contact.phone_number:
+            blocks.append(("contact.phoneNumber", patient.contact.phone_number))
+        candidates: Dict[str, schemas.Patient] = {}
+        for field, value in blocks:
+            for candidate in self._query().where(field, "==", value).limit(limit).fetch():
+                candidates.setdefault(candidate.patient_id, candidate)
+        candidates.pop(patient.patient_id, None)
+        return list(candidates.values())
+
+    def stream(self, updated_since: Optional[datetime] = None, updated_before: Optional[datetime] = None) -> Iterator[schemas.Patient]:
+        return self._stream_updated_between(updated_since, updated_before)
+
+    def update(self, patient_id: str, patient_in: schemas.PatientUpdate) -> schemas.Patient:
+        changes = patient_in.model_dump(by_alias=True, exclude_unset=True)
+        if "mrn" in changes:
+            with including_deleted():
+                existing = self.get_by_mrn(changes["mrn"])
+            if existing and existing.patient_id != patient_id:
+                raise ConflictError(f"A patient with MRN '{changes['mrn']}' already exists.")
+        return self._update(patient_id, changes)
+
+    def set_sms_opt_out(self, phone_number: str, opted_out: bool) -> List[schemas.Patient]:
+        preferences = sms_preferences(opted_out)
+        patients = self._query().where("contact.phoneNumber", "==", phone_number).limit(100).fetch()
+        return [self._transform(patient.patient_id, preferences) for patient in patients]
+
+    def update_push_preferences(self, patient_id: str, preferences_in: schemas.PushPreferencesUpdate) -> schemas.Patient:
+        return self._transform(patient_id, push_preferences(preferences_in))
+
+    def delete(self, patient_id: str, deleted_by: str) -> None:
+        self._soft_delete(patient_id, deleted_by)
+
+    def restore(self, patient_id: str) -> schemas.Patient:
+        return self._restore(patient_id)
+
+    def mark_merged(self, patient_id: str, merged_into: str, merged_by: str) -> schemas.Patient:
+        return self._update(patient_id, merged(merged_into, merged_by))
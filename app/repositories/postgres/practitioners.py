@@ -0,0 +1,46 @@
+# Location: app/repositories/postgres/practitioners.py
+
+from typing import Dict, List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.base import ConflictError, including_deleted
+from app.repositories.postgres.base import PostgresRepository
+from app.repositories.practitioners import PractitionerRepository
+
+
+class PostgresPractitionerRepository(PostgresRepository, PractitionerRepository):
+    """Stores practitioners in the `practitioners` table; NPIs are unique per organization through an index."""
+
+    table = "practitioners"
+    model = schemas.Practitioner
+    id_field = "practitionerId"
+    soft_delete = True
+
+    def create(self, practitioner_in: schemas.PractitionerCreate) -> schemas.Practitioner:
+        # Deleted practitioners keep their NPI, so the check includes them.
+        with including_deleted():
+            taken = self._query().where("npi", "==", practitioner_in.npi).limit(1).fetch()
+        if taken:
+            raise ConflictError(f"A practitioner with NPI '{practitioner_in.npi}' already exists.")
+        return self._create(practitioner_in.model_dump(by_alias=True))
+
+    def get(self, practitioner_id: str) -> Optional[schemas.Practitioner]:
+        return self._get(practitioner_id)
+
+    def get_many(self, practitioner_ids: List[str]) -> Dict[str, schemas.Practitioner]:
+        return self._get_many(practitioner_ids)
+
+    def list(self, limit: int = 30, specialty: Optional[str] = None, after: Optional[str] = None) -> List[schemas.Practitioner]:
+        query = self._query()
+        if specialty:
+            query = query.where("specialty", "==", specialty)
+        return query.order_by("familyName").start_after(after).limit(limit).fetch()
+
+    def update(self, practitioner_id: str, practitioner_in: schemas.PractitionerUpdate) -> schemas.Practitioner:
+        return self._update(practitioner_id, practitioner_in.model_dump(by_alias=True, exclude_unset=True))
+
+    def delete(self, practitioner_id: str, deleted_by: str) -> None:
+        self._soft_delete(practitioner_id, deleted_by)
+
+    def restore(self, practitioner_id: str) -> schemas.Practitioner:
+        return self._restore(practitioner_id)
@@ -0,0 +1,32 @@
+# Location: app/repositories/postgres/push_tokens.py
+
+from datetime import datetime, timezone
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.postgres.base import PostgresRepository
+from app.repositories.push_tokens import PushTokenRepository, push_token_id
+
+
+class PostgresPushTokenRepository(PostgresRepository, PushTokenRepository):
+    """Stores registrations in the `push_tokens` table."""
+
+    table = "push_tokens"
+    model = schemas.PushToken
+    id_field = "pushTokenId"
+
+    def register(self, patient_id: str, token_in: schemas.PushTokenCreate) -> schemas.PushToken:
+        record_id = push_token_id(token_in.token)
+        data = {**token_in.model_dump(by_alias=True), "patientId": patient_id, "registeredAt": datetime.now(timezone.utc)}
+        if self._get(record_id):
+            return self._update(record_id, data)
+        return self._create(data, record_id=record_id)
+
+    def get(self, push_token_id: str) -> Optional[schemas.PushToken]:
+        return self._get(push_token_id)
+
+    def list(self, patient_id: str, limit: int = 50) -> List[schemas.PushToken]:
+        return self._query().where("patientId", "==", patient_id).limit(limit).fetch()
+
+    def delete(self, push_token_id: str) -> None:
+        self._delete(push_token_id)
@@ -0,0 +1,36 @@
+# Location: app/repositories/postgres/retention.py
+
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.postgres.base import PostgresRepository
+from app.repositories.retention import PurgeManifestRepository, RetentionPolicyRecordMixin, RetentionPolicyRepository
+
+
+class PostgresRetentionPolicyRepository(RetentionPolicyRecordMixin, PostgresRepository, RetentionPolicyRepository):
+    """Stores overrides in the `retention_policies` table."""
+
+    table = "retention_policies"
+    model = schemas.RetentionPolicyRecord
+    id_field = "organizationId"
+
+
+class PostgresPurgeManifestRepository(PostgresRepository, PurgeManifestRepository):
+    """Stores manifests in the `purge_manifests` table."""
+
+    table = "purge_manifests"
+    model = schemas.PurgeManifest
+    id_field = "manifestId"
+
+    def create(self, manifest_in: schemas.PurgeManifestCreate) -> schemas.PurgeManifest:
+        return self._create(manifest_in.model_dump(by_alias=True))
+
+    def list(self, category: Optional[str] = None, limit: int = 50, after: Optional[str] = None) -> List[schemas.PurgeManifest]:
+        query = self._query()
+        if category:
+            query = query.where("category", "==", category)
+        return query.order_by("startedAt", descending=True).start_after(after).limit(limit).fetch()
+
+    def last_completed(self, category: str, action: str) -> Optional[schemas.PurgeManifest]:
+        query = self._query().where("category", "==", category).where("action", "==", action).where("status", "==", "completed")
+        return next(iter(query.order_by("cutoff", descending=True).limit(1).fetch()), None)
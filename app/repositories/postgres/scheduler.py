@@ -0,0 +1,29 @@
+# Location: app/repositories/postgres/scheduler.py
+
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.postgres.base import PostgresRepository
+from app.repositories.scheduler import JobLockRecordMixin, JobLockRepository, JobRunRecordMixin, JobRunRepository
+
+
+class PostgresJobLockRepository(JobLockRecordMixin, PostgresRepository, JobLockRepository):
+    """Stores locks in the `job_locks` table, keyed by job name."""
+
+    table = "job_locks"
+    model = schemas.JobLock
+    id_field = "job"
+
+
+class PostgresJobRunRepository(JobRunRecordMixin, PostgresRepository, JobRunRepository):
+    """Stores run history in the `job_runs` table."""
+
+    table = "job_runs"
+    model = schemas.JobRun
+    id_field = "runId"
+
+    def list(self, job: Optional[str] = None, limit: int = 50) -> List[schemas.JobRun]:
+        query = self._query()
+        if job:
+            query = query.where("job", "==", job)
+        return query.order_by("startedAt", descending=True).limit(limit).fetch()
@@ -0,0 +1,18 @@
+# Location: app/repositories/postgres/sessions.py
+
+from typing import List
+
+from app.api.v1 import schemas
+from app.repositories.postgres.base import PostgresRepository
+from app.repositories.sessions import SessionRecordMixin, SessionRepository
+
+
+class PostgresSessionRepository(SessionRecordMixin, PostgresRepository, SessionRepository):
+    """Stores sessions in the `sessions` table."""
+
+    table = "sessions"
+    model = schemas.SessionRecord
+    id_field = "sessionId"
+
+    def list_active(self, uid: str, limit: int = 100) -> List[schemas.SessionRecord]:
+        return self._query().where("uid", "==", uid).where("status", "==", "active").limit(limit).fetch()
@@ -0,0 +1,22 @@
+# Location: app/repositories/postgres/stream_events.py
+
+from datetime import datetime
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.postgres.base import PostgresRepository
+from app.repositories.stream_events import StreamEventRecordMixin, StreamEventRepository
+
+
+class PostgresStreamEventRepository(StreamEventRecordMixin, PostgresRepository, StreamEventRepository):
+    """Stores events in the `stream_events` table."""
+
+    table = "stream_events"
+    model = schemas.StreamEvent
+    id_field = "eventId"
+
+    def list_since(self, since: datetime, patient_id: Optional[str] = None, limit: int = 100) -> List[schemas.StreamEvent]:
+        query = self._query()
+        if patient_id:
+            query = query.where("patientId", "==", patient_id)
+        return query.where("occurredAt", ">=", since).order_by("occurredAt").limit(limit).fetch()
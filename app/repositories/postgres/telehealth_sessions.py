@@ -0,0 +1,38 @@
+# Location: app/repositories/postgres/telehealth_sessions.py
+
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.postgres.base import PostgresRepository
+from app.repositories.telehealth_sessions import TelehealthSessionRepository, session_record
+from app.services.telehealth import event_changes
+
+
+class PostgresTelehealthSessionRepository(PostgresRepository, TelehealthSessionRepository):
+    """Stores sessions in the `telehealth_sessions` table."""
+
+    table = "telehealth_sessions"
+    model = schemas.TelehealthSession
+    id_field = "telehealthSessionId"
+
+    def create(self, session_id: str, appointment: schemas.Appointment, provider: str, room_url: Optional[str]) -> schemas.TelehealthSession:
+        return self._create(session_record(appointment, provider, session_id, room_url), record_id=session_id)
+
+    def get(self, session_id: str) -> Optional[schemas.TelehealthSession]:
+        return self._get(session_id)
+
+    def list(
+        self, patient_id: Optional[str] = None, appointment_id: Optional[str] = None,
+        status: Optional[str] = None, limit: int = 100,
+    ) -> List[schemas.TelehealthSession]:
+        query = self._query()
+        if patient_id:
+            query = query.where("patientId", "==", patient_id)
+        if appointment_id:
+            query = query.where("appointmentId", "==", appointment_id)
+        if status:
+            query = query.where("status", "==", status)
+        return query.limit(limit).fetch()
+
+    def record_event(self, session_id: str, event: schemas.TelehealthSessionEvent, ended_by: Optional[str] = None) -> schemas.TelehealthSession:
+        return self._transform(session_id, lambda session: event_changes(session, event, ended_by))
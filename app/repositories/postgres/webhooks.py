@@ -0,0 +1,40 @@
+# Location: app/repositories/postgres/webhooks.py
+
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.postgres.base import PostgresRepository
+from app.repositories.webhooks import (
+    WebhookDeliveryRecordMixin,
+    WebhookDeliveryRepository,
+    WebhookSubscriptionRecordMixin,
+    WebhookSubscriptionRepository,
+)
+
+
+class PostgresWebhookSubscriptionRepository(WebhookSubscriptionRecordMixin, PostgresRepository, WebhookSubscriptionRepository):
+    """Stores subscriptions in the `webhook_subscriptions` table."""
+
+    table = "webhook_subscriptions"
+    model = schemas.WebhookSubscriptionWithSecret
+    id_field = "subscriptionId"
+
+    def list(self, limit: int = 100, after: Optional[str] = None) -> List[schemas.WebhookSubscriptionWithSecret]:
+        return self._query().order_by("createdAt").start_after(after).limit(limit).fetch()
+
+    def list_for_event_type(self, event_type: str) -> List[schemas.WebhookSubscriptionWithSecret]:
+        return self._query().where("active", "==", True).where("eventTypes", "array_contains", event_type).fetch()
+
+
+class PostgresWebhookDeliveryRepository(WebhookDeliveryRecordMixin, PostgresRepository, WebhookDeliveryRepository):
+    """Stores deliveries in the `webhook_deliveries` table."""
+
+    table = "webhook_deliveries"
+    model = schemas.WebhookDelivery
+    id_field = "deliveryId"
+
+    def list(self, subscription_id: str, status: Optional[str] = None, limit: int = 50, after: Optional[str] = None) -> List[schemas.WebhookDelivery]:
+        query = self._query().where("subscriptionId", "==", subscription_id)
+        if status:
+            query = query.where("status", "==", status)
+        return query.order_by("createdAt", descending=True).start_after(after).limit(limit).fetch()
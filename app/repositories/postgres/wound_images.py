@@ -0,0 +1,33 @@
+# Location: app/repositories/postgres/wound_images.py
+
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.postgres.base import PostgresRepository
+from app.repositories.wound_images import WoundImageRepository
+
+
+class PostgresWoundImageRepository(PostgresRepository, WoundImageRepository):
+    """Stores photo metadata in the `wound_images` table."""
+
+    table = "wound_images"
+    model = schemas.WoundImage
+    id_field = "imageId"
+
+    def create(
+        self, patient_id: str, image_id: str, image_in: schemas.WoundImageCreate, files: schemas.WoundImageFiles, uploaded_by: str,
+    ) -> schemas.WoundImage:
+        data = {**image_in.model_dump(by_alias=True), **files.model_dump(by_alias=True), "patientId": patient_id, "uploadedBy": uploaded_by}
+        return self._create(data, record_id=image_id)
+
+    def get(self, image_id: str) -> Optional[schemas.WoundImage]:
+        return self._get(image_id)
+
+    def list(self, patient_id: str, wound_id: Optional[str] = None, limit: int = 500) -> List[schemas.WoundImage]:
+        query = self._query().where("patientId", "==", patient_id)
+        if wound_id:
+            query = query.where("woundId", "==", wound_id)
+        return query.limit(limit).fetch()
+
+    def delete(self, image_id: str) -> None:
+        self._delete(image_id)
@@ -0,0 +1,78 @@
+# Location: app/repositories/practitioners.py
+
+from abc import ABC, abstractmethod
+from typing import Dict, List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+
+from app.api.v1 import schemas
+from app.repositories.base import ConflictError, FirestoreRepository
+
+
+class PractitionerRepository(ABC):
+    """Storage interface for practitioners (physicians, therapists, nurses, ...)."""
+
+    @abstractmethod
+    def create(self, practitioner_in: schemas.PractitionerCreate) -> schemas.Practitioner:
+        """Stores a new practitioner. Raises ConflictError if the NPI is already registered."""
+
+    @abstractmethod
+    def get(self, practitioner_id: str) -> Optional[schemas.Practitioner]:
+        """Returns the practitioner, or None if it does not exist."""
+
+    @abstractmethod
+    def get_many(self, practitioner_ids: List[str]) -> Dict[str, schemas.Practitioner]:
+        """Returns the practitioners that exist among `practitioner_ids`, by ID, in one batched read."""
+
+    @abstractmethod
+    def list(self, limit: int = 30, specialty: Optional[str] = None, after: Optional[str] = None) -> List[schemas.Practitioner]:
+        """Returns up to `limit` practitioners, optionally filtered by specialty."""
+
+    @abstractmethod
+    def update(self, practitioner_id: str, practitioner_in: schemas.PractitionerUpdate) -> schemas.Practitioner:
+        """Applies the fields set on `practitioner_in`. Raises NotFoundError."""
+
+    @abstractmethod
+    def delete(self, practitioner_id: str, deleted_by: str) -> None:
+        """Marks the practitioner deleted. Raises NotFoundError if it does not exist or is already deleted."""
+
+    @abstractmethod
+    def restore(self, practitioner_id: str) -> schemas.Practitioner:
+        """Clears the practitioner's deletion. Raises NotFoundError if it does not exist."""
+
+
+class FirestorePractitionerRepository(FirestoreRepository, PractitionerRepository):
+    """Stores practitioners in the top-level `practitioners` collection."""
+
+    collection_name = "practitioners"
+    model = schemas.Practitioner
+    id_field = "practitionerId"
+    soft_delete = True
+
+    def create(self, practitioner_in: schemas.PractitionerCreate) -> schemas.Practitioner:
+        # Note: This query requires a Firestore index on the 'npi' field.
+        query = self._query().where(filter=FieldFilter("npi", "==", practitioner_in.npi)).limit(1)
+        if list(query.stream()):
+            raise ConflictError(f"A practitioner with NPI '{practitioner_in.npi}' already exists.")
+        return self._create(practitioner_in.model_dump(by_alias=True))
+
+    def get(self, practitioner_id: str) -> Optional[schemas.Practitioner]:
+        return self._get(practitioner_id)
+
+    def get_many(self, practitioner_ids: List[str]) -> Dict[str, schemas.Practitioner]:
+        return self._get_many(practitioner_ids)
+
+    def list(self, limit: int = 30, specialty: Optional[str] = None, after: Optional[str] = None) -> List[schemas.Practitioner]:
+        query = self._query()
+        if specialty:
+            query = query.where(filter=FieldFilter("specialty", "==", specialty))
+        return self._fetch(self._start_after(query.order_by("familyName"), after), limit)
+
+    def update(self, practitioner_id: str, practitioner_in: schemas.PractitionerUpdate) -> schemas.Practitioner:
+        return self._update(practitioner_id, practitioner_in.model_dump(by_alias=True, exclude_unset=True))
+
+    def delete(self, practitioner_id: str, deleted_by: str) -> None:
+        self._soft_delete(practitioner_id, deleted_by)
+
+    def restore(self, practitioner_id: str) -> schemas.Practitioner:
+        return self._restore(practitioner_id)
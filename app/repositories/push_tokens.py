@@ -0,0 +1,68 @@
+# Location: app/repositories/push_tokens.py
+
+import hashlib
+from abc import ABC, abstractmethod
+from datetime import datetime, timezone
+from typing import List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+
+from app.api.v1 import schemas
+from app.repositories.base import ConflictError, FirestoreRepository
+
+
+def push_token_id(token: str) -> str:
+    """The record ID of a registration token; the token itself is too long, and a credential."""
+    return hashlib.sha256(token.encode()).hexdigest()
+
+
+class PushTokenRepository(ABC):
+    """
+    Storage interface for the patient app's push registration tokens. There
+    is one record per token, so an installation registered again, or by
+    another patient, replaces the earlier registration.
+    """
+
+    @abstractmethod
+    def register(self, patient_id: str, token_in: schemas.PushTokenCreate) -> schemas.PushToken:
+        """Stores the token for the patient, or moves it to them. Raises ConflictError if another organization holds it."""
+
+    @abstractmethod
+    def get(self, push_token_id: str) -> Optional[schemas.PushToken]:
+        """Returns the registration, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, patient_id: str, limit: int = 50) -> List[schemas.PushToken]:
+        """Returns the patient's registered tokens, in no particular order."""
+
+    @abstractmethod
+    def delete(self, push_token_id: str) -> None:
+        """Removes the registration. Raises NotFoundError if it does not exist."""
+
+
+class FirestorePushTokenRepository(FirestoreRepository, PushTokenRepository):
+    """Stores registrations in the top-level `pushTokens` collection, keyed to the patient by `patientId`."""
+
+    collection_name = "pushTokens"
+    model = schemas.PushToken
+    id_field = "pushTokenId"
+
+    def register(self, patient_id: str, token_in: schemas.PushTokenCreate) -> schemas.PushToken:
+        record_id = push_token_id(token_in.token)
+        data = {**token_in.model_dump(by_alias=True), "patientId": patient_id, "registeredAt": datetime.now(timezone.utc)}
+        if self._get(record_id):
+            return self._update(record_id, data)
+        if self.collection.document(record_id).get().exists:
+            # Outside the caller's organization; setting it would move it out.
+            raise ConflictError("The push token is registered in another organization.")
+        return self._create(data, record_id=record_id)
+
+    def get(self, push_token_id: str) -> Optional[schemas.PushToken]:
+        return self._get(push_token_id)
+
+    def list(self, patient_id: str, limit: int = 50) -> List[schemas.PushToken]:
+        query = self._query().where(filter=FieldFilter("patientId", "==", patient_id))
+        return self._fetch(query, limit)
+
+    def delete(self, push_token_id: str) -> None:
+        self._delete(push_token_id)
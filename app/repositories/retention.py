@@ -0,0 +1,98 @@
+# Location: app/repositories/retention.py
+
+from abc import ABC, abstractmethod
+from typing import List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+from firebase_admin import firestore
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository, NotFoundError
+
+
+class RetentionPolicyRepository(ABC):
+    """
+    Storage for organizations' retention overrides, keyed by organization ID.
+    Read by the retention job for each organization in turn, so reads are
+    never scoped to a tenant.
+    """
+
+    @abstractmethod
+    def get(self, organization_id: str) -> Optional[schemas.RetentionPolicyRecord]:
+        """Returns the organization's overrides, or None if it has set none."""
+
+    @abstractmethod
+    def update(self, organization_id: str, policy_in: schemas.RetentionPolicyUpdate, updated_by: str) -> schemas.RetentionPolicyRecord:
+        """Applies the categories set on `policy_in`, storing the record on first use."""
+
+
+class PurgeManifestRepository(ABC):
+    """
+    Storage for the manifests the retention job records, one per category
+    and run, in the organization the job acted for. Manifests are kept.
+    """
+
+    @abstractmethod
+    def create(self, manifest_in: schemas.PurgeManifestCreate) -> schemas.PurgeManifest:
+        """Stores a new manifest."""
+
+    @abstractmethod
+    def list(self, category: Optional[str] = None, limit: int = 50, after: Optional[str] = None) -> List[schemas.PurgeManifest]:
+        """Returns manifests, most recent first, optionally of one category."""
+
+    @abstractmethod
+    def last_completed(self, category: str, action: str) -> Optional[schemas.PurgeManifest]:
+        """Returns the completed manifest of `category` and `action` with the latest cutoff, if any."""
+
+
+class RetentionPolicyRecordMixin:
+    """Everything, on top of the storage base class helpers."""
+
+    tenant_scoped = False
+
+    def get(self, organization_id: str) -> Optional[schemas.RetentionPolicyRecord]:
+        return self._get(organization_id)
+
+    def update(self, organization_id: str, policy_in: schemas.RetentionPolicyUpdate, updated_by: str) -> schemas.RetentionPolicyRecord:
+        changes = {**policy_in.model_dump(by_alias=True, exclude_unset=True), "updatedBy": updated_by}
+        try:
+            return self._update(organization_id, changes)
+        except NotFoundError:
+            return self._create(changes, record_id=organization_id)
+
+
+class FirestoreRetentionPolicyRepository(RetentionPolicyRecordMixin, FirestoreRepository, RetentionPolicyRepository):
+    """Stores overrides in the top-level `retentionPolicies` collection, one document per organization."""
+
+    collection_name = "retentionPolicies"
+    model = schemas.RetentionPolicyRecord
+    id_field = "organizationId"
+
+
+class FirestorePurgeManifestRepository(FirestoreRepository, PurgeManifestRepository):
+    """Stores manifests in the top-level `purgeManifests` collection."""
+
+    collection_name = "purgeManifests"
+    model = schemas.PurgeManifest
+    id_field = "manifestId"
+
+    def create(self, manifest_in: schemas.PurgeManifestCreate) -> schemas.PurgeManifest:
+        return self._create(manifest_in.model_dump(by_alias=True))
+
+    def list(self, category: Optional[str] = None, limit: int = 50, after: Optional[str] = None) -> List[schemas.PurgeManifest]:
+        query = self._query()
+        if category:
+            query = query.where(filter=FieldFilter("category", "==", category))
+        query = self._start_after(query.order_by("startedAt", direction=firestore.Query.DESCENDING), after).limit(limit)
+        return [self._to_model(doc) for doc in query.stream()]
+
+    def last_completed(self, category: str, action: str) -> Optional[schemas.PurgeManifest]:
+        # Note: requires a composite index on (category, action, status, cutoff desc).
+        query = (
+            self._query().where(filter=FieldFilter("category", "==", category))
+            .where(filter=FieldFilter("action", "==", action))
+            .where(filter=FieldFilter("status", "==", "completed"))
+            .order_by("cutoff", direction=firestore.Query.DESCENDING)
+            .limit(1)
+        )
+        return next((self._to_model(doc) for doc in query.stream()), None)
@@ -0,0 +1,186 @@
+# Location: app/repositories/scheduler.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+from firebase_admin import firestore
+
+from app.api.v1 import schemas
+from app.repositories.base import ConflictError, FirestoreRepository, NotFoundError, to_firestore
+
+
+class LockHeldError(Exception):
+    """Raised inside a lock transaction to abort it without writing."""
+
+
+def lock_available(lock: schemas.JobLock, now: datetime, started_before: Optional[datetime]) -> bool:
+    """
+    Whether the job may start: nobody holds an unexpired lease and, when
+    `started_before` is given, the last run started no later than that.
+    """
+    if lock.locked_until and lock.locked_until > now:
+        return False
+    if started_before and lock.last_started_at and lock.last_started_at > started_before:
+        return False
+    return True
+
+
+class JobLockRepository(ABC):
+    """
+    One lock record per scheduled job. Locks are leases: a run that dies
+    without releasing its lock holds the job only until the lease expires.
+    """
+
+    @abstractmethod
+    def acquire(self, job: str, owner: str, lease_seconds: float, started_before: Optional[datetime] = None) -> bool:
+        """
+        Takes the job's lock for `lease_seconds` and records the start time.
+        Returns False if another owner holds it, or if `started_before` is
+        given and the job last started after it (i.e. it is not due yet).
+        """
+
+    @abstractmethod
+    def release(self, job: str, owner: str) -> None:
+        """Ends the lease early. Does nothing if `owner` no longer holds the lock."""
+
+    @abstractmethod
+    def get(self, job: str) -> Optional[schemas.JobLock]:
+        """Returns the job's lock record, or None if the job never ran."""
+
+
+class JobRunRepository(ABC):
+    """Run history of scheduled jobs."""
+
+    @abstractmethod
+    def start(self, job: str, trigger: str, owner: str) -> schemas.JobRun:
+        """Records a run that has just started."""
+
+    @abstractmethod
+    def finish(self, run_id: str, status: str, result: Optional[Dict[str, Any]] = None, error: Optional[str] = None) -> schemas.JobRun:
+        """Records the outcome of a run. Raises NotFoundError."""
+
+    @abstractmethod
+    def record_skipped(self, job: str, trigger: str, owner: str, reason: str) -> schemas.JobRun:
+        """Records a trigger that found the job locked."""
+
+    @abstractmethod
+    def list(self, job: Optional[str] = None, limit: int = 50) -> List[schemas.JobRun]:
+        """Returns runs, most recent first, optionally of one job."""
+
+    @abstractmethod
+    def purge(self, before: datetime) -> int:
+        """Deletes runs last updated before `before`. Returns the count."""
+
+
+class JobLockRecordMixin:
+    """
+    Everything, on top of the storage base class helpers: the first run
+    creates the record (a duplicate insert means another owner got there
+    first), later runs take the lock in a `_transform`.
+    """
+
+    tenant_scoped = False
+
+    def acquire(self, job: str, owner: str, lease_seconds: float, started_before: Optional[datetime] = None) -> bool:
+        now = datetime.now(timezone.utc)
+        claim = {"job": job, "lockedBy": owner, "lockedUntil": now + timedelta(seconds=lease_seconds), "lastStartedAt": now}
+        if self._get(job) is None:
+            try:
+                self._create(claim, record_id=job)
+                return True
+            except ConflictError:
+                pass
+
+        def take(lock: schemas.JobLock) -> Dict[str, Any]:
+            if not lock_available(lock, now, started_before):
+                raise LockHeldError(job)
+            return claim
+
+        try:
+            self._transform(job, take)
+        except LockHeldError:
+            return False
+        return True
+
+    def release(self, job: str, owner: str) -> None:
+        def free(lock: schemas.JobLock) -> Dict[str, Any]:
+            if lock.locked_by != owner:
+                raise LockHeldError(job)
+            return {"lockedBy": None, "lockedUntil": None}
+
+        try:
+            self._transform(job, free)
+        except (LockHeldError, NotFoundError):
+            pass
+
+    def get(self, job: str) -> Optional[schemas.JobLock]:
+        return self._get(job)
+
+
+class JobRunRecordMixin:
+    """Everything but the listing query."""
+
+    tenant_scoped = False
+
+    def start(self, job: str, trigger: str, owner: str) -> schemas.JobRun:
+        return self._create({"job": job, "trigger": trigger, "status": "running", "owner": owner, "startedAt": datetime.now(timezone.utc)})
+
+    def finish(self, run_id: str, status: str, result: Optional[Dict[str, Any]] = None, error: Optional[str] = None) -> schemas.JobRun:
+        return self._update(run_id, {"status": status, "result": result or {}, "error": error, "finishedAt": datetime.now(timezone.utc)})
+
+    def record_skipped(self, job: str, trigger: str, owner: str, reason: str) -> schemas.JobRun:
+        now = datetime.now(timezone.utc)
+        return self._create({
+            "job": job, "trigger": trigger, "status": "skipped", "owner": owner,
+            "startedAt": now, "finishedAt": now, "error": reason,
+        })
+
+    def purge(self, before: datetime) -> int:
+        return self._purge(before)
+
+
+class FirestoreJobLockRepository(JobLockRecordMixin, FirestoreRepository, JobLockRepository):
+    """
+    Stores locks in the top-level `jobLocks` collection, keyed by job name.
+    `_create` overwrites existing documents in Firestore, so the lock is
+    read and written in one transaction whether or not the document exists.
+    """
+
+    collection_name = "jobLocks"
+    model = schemas.JobLock
+    id_field = "job"
+
+    def acquire(self, job: str, owner: str, lease_seconds: float, started_before: Optional[datetime] = None) -> bool:
+        now = datetime.now(timezone.utc)
+        record_ref = self.collection.document(job)
+        claim = {"lockedBy": owner, "lockedUntil": now + timedelta(seconds=lease_seconds), "lastStartedAt": now, "updatedAt": now}
+
+        @firestore.transactional
+        def take(transaction) -> bool:
+            snapshot = record_ref.get(transaction=transaction)
+            if not snapshot.exists:
+                transaction.create(record_ref, to_firestore({**claim, "job": job, "createdAt": now}))
+                return True
+            if not lock_available(self._to_model(snapshot), now, started_before):
+                return False
+            transaction.update(record_ref, to_firestore(claim))
+            return True
+
+        return take(self.db.transaction())
+
+
+class FirestoreJobRunRepository(JobRunRecordMixin, FirestoreRepository, JobRunRepository):
+    """Stores run history in the top-level `jobRuns` collection."""
+
+    collection_name = "jobRuns"
+    model = schemas.JobRun
+    id_field = "runId"
+
+    def list(self, job: Optional[str] = None, limit: int = 50) -> List[schemas.JobRun]:
+        query = self._query()
+        if job:
+            query = query.where(filter=FieldFilter("job", "==", job))
+        query = query.order_by("startedAt", direction=firestore.Query.DESCENDING).limit(limit)
+        return [self._to_model(doc) for doc in query.stream()]
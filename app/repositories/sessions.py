@@ -0,0 +1,124 @@
+# Location: app/repositories/sessions.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+
+from app.api.v1 import schemas
+from app.repositories.base import ConflictError, FirestoreRepository
+
+
+class SessionRepository(ABC):
+    """
+    Storage for the patient portal's sign-in sessions, keyed by the session
+    ID embedded in their refresh tokens. Records carry hashes of the refresh
+    tokens, never the tokens themselves.
+    """
+
+    @abstractmethod
+    def create(
+        self,
+        session_id: str,
+        uid: str,
+        claims: Dict[str, Any],
+        refresh_hash: str,
+        authenticated_at: datetime,
+        expires_at: datetime,
+        device_label: Optional[str] = None,
+        user_agent: Optional[str] = None,
+        source_ip: Optional[str] = None,
+    ) -> schemas.SessionRecord:
+        """Stores a new, active session."""
+
+    @abstractmethod
+    def get(self, session_id: str) -> Optional[schemas.SessionRecord]:
+        """Returns the session, or None if it does not exist."""
+
+    @abstractmethod
+    def list_active(self, uid: str, limit: int = 100) -> List[schemas.SessionRecord]:
+        """Returns the user's active sessions, in no particular order."""
+
+    @abstractmethod
+    def rotate(self, session_id: str, refresh_hash: str, new_refresh_hash: str, expires_at: datetime) -> schemas.SessionRecord:
+        """
+        Replaces the refresh token whose hash is `refresh_hash`. Raises
+        ConflictError if the session is revoked or its token was already
+        replaced, and NotFoundError.
+        """
+
+    @abstractmethod
+    def revoke(self, session_id: str, reason: str) -> schemas.SessionRecord:
+        """Marks the session revoked; a revoked session is left as it is. Raises NotFoundError."""
+
+    @abstractmethod
+    def mark_mfa_verified(self, session_id: str, verified_at: datetime) -> schemas.SessionRecord:
+        """Records that the session's user proved a second factor. Raises ConflictError if the session is revoked, and NotFoundError."""
+
+
+class SessionRecordMixin:
+    """
+    Everything but the listing query, on top of the storage base class
+    helpers. Sessions are read by refresh token before any organization is
+    known, so they are not tenant-scoped; they belong to their `uid`.
+    """
+
+    tenant_scoped = False
+
+    def create(
+        self,
+        session_id: str,
+        uid: str,
+        claims: Dict[str, Any],
+        refresh_hash: str,
+        authenticated_at: datetime,
+        expires_at: datetime,
+        device_label: Optional[str] = None,
+        user_agent: Optional[str] = None,
+        source_ip: Optional[str] = None,
+    ) -> schemas.SessionRecord:
+        data = {
+            "uid": uid, "claims": claims, "status": "active", "refreshHash": refresh_hash,
+            "authenticatedAt": authenticated_at, "lastUsedAt": datetime.now(timezone.utc), "expiresAt": expires_at,
+            "deviceLabel": device_label, "userAgent": user_agent, "sourceIp": source_ip,
+        }
+        return self._create(data, record_id=session_id)
+
+    def get(self, session_id: str) -> Optional[schemas.SessionRecord]:
+        return self._get(session_id)
+
+    def rotate(self, session_id: str, refresh_hash: str, new_refresh_hash: str, expires_at: datetime) -> schemas.SessionRecord:
+        def replace(record: schemas.SessionRecord) -> Dict[str, Any]:
+            if record.status != "active" or record.refresh_hash != refresh_hash:
+                raise ConflictError(f"Session '{session_id}' is revoked or its refresh token was already used.")
+            return {
+                "refreshHash": new_refresh_hash, "previousRefreshHash": refresh_hash,
+                "lastUsedAt": datetime.now(timezone.utc), "expiresAt": expires_at,
+            }
+        return self._transform(session_id, replace)
+
+    def revoke(self, session_id: str, reason: str) -> schemas.SessionRecord:
+        record = self._get(session_id)
+        if record and record.status == "revoked":
+            return record
+        return self._update(session_id, {"status": "revoked", "revokedAt": datetime.now(timezone.utc), "revokedReason": reason})
+
+    def mark_mfa_verified(self, session_id: str, verified_at: datetime) -> schemas.SessionRecord:
+        def mark(record: schemas.SessionRecord) -> Dict[str, Any]:
+            if record.status != "active":
+                raise ConflictError(f"Session '{session_id}' is revoked.")
+            return {"mfaVerifiedAt": verified_at}
+        return self._transform(session_id, mark)
+
+
+class FirestoreSessionRepository(SessionRecordMixin, FirestoreRepository, SessionRepository):
+    """Stores sessions in the top-level `sessions` collection."""
+
+    collection_name = "sessions"
+    model = schemas.SessionRecord
+    id_field = "sessionId"
+
+    def list_active(self, uid: str, limit: int = 100) -> List[schemas.SessionRecord]:
+        query = self._query().where(filter=FieldFilter("uid", "==", uid)).where(filter=FieldFilter("status", "==", "active"))
+        return self._fetch(query, limit)
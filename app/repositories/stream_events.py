@@ -0,0 +1,70 @@
+# Location: app/repositories/stream_events.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime
+from typing import List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+
+from app.api.v1 import schemas
+from app.events.envelope import Event
+from app.repositories.base import FirestoreRepository
+
+
+def stream_record(event: Event) -> dict:
+    """The stored fields of an event on the stream: its type, what it is about and whose it is."""
+    segments = event.subject.split("/")
+    patient_id = segments[1] if segments[0] == "patients" and len(segments) > 1 else event.data.get("patientId")
+    return {
+        "type": event.type,
+        "subject": event.subject,
+        "resource": segments[0],
+        "patientId": patient_id,
+        "occurredAt": event.occurred_at,
+    }
+
+
+class StreamEventRepository(ABC):
+    """
+    Storage for the events sent on /events/stream, kept for
+    EVENT_STREAM_RETENTION_HOURS so that clients reconnecting with
+    Last-Event-ID receive what they missed.
+    """
+
+    @abstractmethod
+    def append(self, event: Event) -> None:
+        """Stores the event, keyed by the event ID."""
+
+    @abstractmethod
+    def list_since(self, since: datetime, patient_id: Optional[str] = None, limit: int = 100) -> List[schemas.StreamEvent]:
+        """Returns events that occurred at or after `since`, oldest first; only the patient's, if given."""
+
+    @abstractmethod
+    def purge(self, before: datetime) -> int:
+        """Deletes events that occurred before `before`. Returns the count."""
+
+
+class StreamEventRecordMixin:
+    """`append` and `purge`, on top of the storage base class helpers."""
+
+    def append(self, event: Event) -> None:
+        self._create(stream_record(event), record_id=event.id)
+
+    def purge(self, before: datetime) -> int:
+        return self._purge(before, field="occurredAt")
+
+
+class FirestoreStreamEventRepository(StreamEventRecordMixin, FirestoreRepository, StreamEventRepository):
+    """Stores events in the top-level `streamEvents` collection."""
+
+    collection_name = "streamEvents"
+    model = schemas.StreamEvent
+    id_field = "eventId"
+
+    def list_since(self, since: datetime, patient_id: Optional[str] = None, limit: int = 100) -> List[schemas.StreamEvent]:
+        # Note: Requires a composite index on (patientId, occurredAt).
+        query = self._query()
+        if patient_id:
+            query = query.where(filter=FieldFilter("patientId", "==", patient_id))
+        query = query.where(filter=FieldFilter("occurredAt", ">=", since)).order_by("occurredAt")
+        return self._fetch(query, limit)
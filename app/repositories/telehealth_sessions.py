@@ -0,0 +1,75 @@
+# Location: app/repositories/telehealth_sessions.py
+
+from abc import ABC, abstractmethod
+from typing import List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+from app.services.telehealth import event_changes, room_name
+
+
+def session_record(appointment: schemas.Appointment, provider: str, session_id: str, room_url: Optional[str]) -> dict:
+    return {
+        "appointmentId": appointment.appointment_id, "patientId": appointment.patient_id,
+        "practitionerId": appointment.practitioner_id, "provider": provider,
+        "roomName": room_name(session_id), "roomUrl": room_url, "status": "waiting", "events": [],
+    }
+
+
+class TelehealthSessionRepository(ABC):
+    """Storage interface for video visits and what happened in them."""
+
+    @abstractmethod
+    def create(self, session_id: str, appointment: schemas.Appointment, provider: str, room_url: Optional[str]) -> schemas.TelehealthSession:
+        """Stores a session for the appointment, in the room named after `session_id` (see app/services/telehealth.py)."""
+
+    @abstractmethod
+    def get(self, session_id: str) -> Optional[schemas.TelehealthSession]:
+        """Returns the session, or None if it does not exist."""
+
+    @abstractmethod
+    def list(
+        self, patient_id: Optional[str] = None, appointment_id: Optional[str] = None,
+        status: Optional[str] = None, limit: int = 100,
+    ) -> List[schemas.TelehealthSession]:
+        """Returns the sessions of a patient or an appointment, optionally only those with one status."""
+
+    @abstractmethod
+    def record_event(self, session_id: str, event: schemas.TelehealthSessionEvent, ended_by: Optional[str] = None) -> schemas.TelehealthSession:
+        """
+        Appends an event and works out the session's status, start and
+        duration again (see app/services/telehealth.py). An event already
+        recorded is not recorded twice. Raises NotFoundError.
+        """
+
+
+class FirestoreTelehealthSessionRepository(FirestoreRepository, TelehealthSessionRepository):
+    """Stores sessions in the top-level `telehealthSessions` collection."""
+
+    collection_name = "telehealthSessions"
+    model = schemas.TelehealthSession
+    id_field = "telehealthSessionId"
+
+    def create(self, session_id: str, appointment: schemas.Appointment, provider: str, room_url: Optional[str]) -> schemas.TelehealthSession:
+        return self._create(session_record(appointment, provider, session_id, room_url), record_id=session_id)
+
+    def get(self, session_id: str) -> Optional[schemas.TelehealthSession]:
+        return self._get(session_id)
+
+    def list(
+        self, patient_id: Optional[str] = None, appointment_id: Optional[str] = None,
+        status: Optional[str] = None, limit: int = 100,
+    ) -> List[schemas.TelehealthSession]:
+        query = self._query()
+        if patient_id:
+            query = query.where(filter=FieldFilter("patientId", "==", patient_id))
+        if appointment_id:
+            query = query.where(filter=FieldFilter("appointmentId", "==", appointment_id))
+        if status:
+            query = query.where(filter=FieldFilter("status", "==", status))
+        return self._fetch(query, limit)
+
+    def record_event(self, session_id: str, event: schemas.TelehealthSessionEvent, ended_by: Optional[str] = None) -> schemas.TelehealthSession:
+        return self._transform(session_id, lambda session: event_changes(session, event, ended_by))
@@ -0,0 +1,84 @@
+# Location: app/repositories/transactions.py
+
+import functools
+from contextlib import contextmanager, nullcontext
+from contextvars import ContextVar
+from typing import Callable, List, Optional
+
+from app.core.config import get_settings
+
+STORE = get_settings().store
+
+_active: ContextVar[bool] = ContextVar("unit_of_work_active", default=False)
+_after_commit: ContextVar[Optional[List[Callable[[], None]]]] = ContextVar("unit_of_work_after_commit", default=None)
+
+
+def in_transaction() -> bool:
+    """True inside a `unit_of_work()` whose writes commit or roll back together."""
+    return _active.get()
+
+
+def after_commit(callback: Callable[[], None]) -> None:
+    """
+    Runs `callback` once the enclosing `unit_of_work()` has committed, or
+    at once outside one; it is dropped if the work rolls back. For side
+    effects that cannot be undone and must not describe a change that was
+    never made, such as exporting analytics.
+    """
+    pending = _after_commit.get()
+    if pending is None:
+        callback()
+    else:
+        pending.append(callback)
+
+
+@contextmanager
+def unit_of_work():
+    """
+    Opens one store transaction for the enclosed block. With STORE=postgres
+    or STORE=memory every repository write inside it, and every event added
+    to the outbox, commits together. Firestore repositories write as they go,
+    so with STORE=firestore this is a no-op and `in_transaction()` stays False.
+    Callbacks registered with `after_commit` run when the outermost unit ends
+    without an exception.
+    """
+    if STORE == "postgres":
+        from app.core.postgres import get_pool, transaction
+        scope = transaction(get_pool())
+    elif STORE == "memory":
+        from app.repositories.memory import get_memory_store
+        scope = get_memory_store().transaction()
+    else:
+        scope = nullcontext()
+    outer = _after_commit.get()
+    callbacks = [] if outer is None else outer
+    pending_token = _after_commit.set(callbacks)
+    try:
+        with scope:
+            token = _active.set(STORE != "firestore")
+            try:
+                yield
+            finally:
+                _active.reset(token)
+    finally:
+        _after_commit.reset(pending_token)
+    if outer is None:
+        for callback in callbacks:
+            callback()
+
+
+def transactional(handler):
+    """
+    Runs a (sync) endpoint in a `unit_of_work()`, so the changes it makes
+    and the domain events it emits are stored atomically. An exception,
+    including an HTTPException raised after a write, rolls everything back.
+
+        @router.post("", ...)
+        @transactional
+        def create_patient(...):
+    """
+    @functools.wraps(handler)
+    def wrapper(*args, **kwargs):
+        with unit_of_work():
+            return handler(*args, **kwargs)
+    return wrapper
@@ -0,0 +1,199 @@
+# Location: app/repositories/webhooks.py
+
+from abc import ABC, abstractmethod
+from datetime import datetime, timezone
+from typing import List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+from firebase_admin import firestore
+
+from app.api.v1 import schemas
+from app.events.envelope import Event
+from app.repositories.base import FirestoreRepository
+
+
+class WebhookSubscriptionRepository(ABC):
+    """
+    Storage interface for partner webhook subscriptions. Records include the
+    signing secret; endpoints return `schemas.WebhookSubscription`, which
+    leaves it out, except on create and rotate.
+    """
+
+    @abstractmethod
+    def create(self, subscription_in: schemas.WebhookSubscriptionCreate, secret: str, created_by: str) -> schemas.WebhookSubscriptionWithSecret:
+        """Stores a new, active subscription."""
+
+    @abstractmethod
+    def get(self, subscription_id: str) -> Optional[schemas.WebhookSubscriptionWithSecret]:
+        """Returns the subscription, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, limit: int = 100, after: Optional[str] = None) -> List[schemas.WebhookSubscriptionWithSecret]:
+        """Returns subscriptions, oldest first."""
+
+    @abstractmethod
+    def list_for_event_type(self, event_type: str) -> List[schemas.WebhookSubscriptionWithSecret]:
+        """Returns the active subscriptions to an event type."""
+
+    @abstractmethod
+    def update(self, subscription_id: str, subscription_in: schemas.WebhookSubscriptionUpdate) -> schemas.WebhookSubscriptionWithSecret:
+        """Applies the fields set on `subscription_in`. Raises NotFoundError."""
+
+    @abstractmethod
+    def set_secret(self, subscription_id: str, secret: str) -> schemas.WebhookSubscriptionWithSecret:
+        """Replaces the signing secret. Raises NotFoundError."""
+
+    @abstractmethod
+    def delete(self, subscription_id: str) -> None:
+        """Removes the subscription. Raises NotFoundError if it does not exist."""
+
+
+class WebhookDeliveryRepository(ABC):
+    """
+    Storage for webhook deliveries: one record per event and subscription,
+    kept after the final attempt as the delivery log partners debug with.
+    """
+
+    @abstractmethod
+    def create(self, subscription_id: str, event: Event) -> schemas.WebhookDelivery:
+        """Stores a pending, immediately due delivery of the event."""
+
+    @abstractmethod
+    def get(self, delivery_id: str) -> Optional[schemas.WebhookDelivery]:
+        """Returns the delivery, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, subscription_id: str, status: Optional[str] = None, limit: int = 50, after: Optional[str] = None) -> List[schemas.WebhookDelivery]:
+        """Returns a subscription's deliveries, most recent first."""
+
+    @abstractmethod
+    def claim(self, limit: int, lease_seconds: float) -> List[schemas.WebhookDelivery]:
+        """Returns and leases up to `limit` due pending deliveries (see `OutboxRepository.claim`)."""
+
+    @abstractmethod
+    def mark_succeeded(self, delivery_id: str, attempts: int, response_status: int) -> None:
+        """Records a 2xx response. Raises NotFoundError."""
+
+    @abstractmethod
+    def reschedule(self, delivery_id: str, attempts: int, next_attempt_at: datetime, error: str, response_status: Optional[int]) -> None:
+        """Records a failed attempt and when to try again. Raises NotFoundError."""
+
+    @abstractmethod
+    def mark_failed(self, delivery_id: str, attempts: int, error: str, response_status: Optional[int]) -> None:
+        """Gives up on the delivery. Raises NotFoundError."""
+
+    @abstractmethod
+    def requeue(self, delivery_id: str) -> schemas.WebhookDelivery:
+        """Makes a delivery pending and due now, with a fresh set of attempts. Raises NotFoundError."""
+
+    @abstractmethod
+    def purge_finished(self, before: datetime) -> int:
+        """Deletes succeeded and failed deliveries last updated before `before`. Returns the count."""
+
+    @abstractmethod
+    def purge_expired(self, before: datetime) -> int:
+        """`purge_finished`, under the name the retention job uses for every store. Returns the count."""
+
+    @abstractmethod
+    def anonymize_expired(self, since: Optional[datetime], before: datetime) -> int:
+        """Drops the event payload of deliveries finished within [since, before). Returns the count."""
+
+
+class WebhookSubscriptionRecordMixin:
+    """Everything but the queries, on top of the storage base class helpers."""
+
+    def create(self, subscription_in: schemas.WebhookSubscriptionCreate, secret: str, created_by: str) -> schemas.WebhookSubscriptionWithSecret:
+        return self._create({**subscription_in.model_dump(by_alias=True), "active": True, "secret": secret, "createdBy": created_by})
+
+    def get(self, subscription_id: str) -> Optional[schemas.WebhookSubscriptionWithSecret]:
+        return self._get(subscription_id)
+
+    def update(self, subscription_id: str, subscription_in: schemas.WebhookSubscriptionUpdate) -> schemas.WebhookSubscriptionWithSecret:
+        return self._update(subscription_id, subscription_in.model_dump(by_alias=True, exclude_unset=True))
+
+    def set_secret(self, subscription_id: str, secret: str) -> schemas.WebhookSubscriptionWithSecret:
+        return self._update(subscription_id, {"secret": secret})
+
+    def delete(self, subscription_id: str) -> None:
+        self._delete(subscription_id)
+
+
+class WebhookDeliveryRecordMixin:
+    """Everything but the listing query; claiming uses `_lease_due`."""
+
+    def create(self, subscription_id: str, event: Event) -> schemas.WebhookDelivery:
+        return self._create({
+            "subscriptionId": subscription_id,
+            "eventId": event.id,
+            "eventType": event.type,
+            "event": event.model_dump(mode="json", by_alias=True),
+            "status": "pending",
+            "attempts": 0,
+            "nextAttemptAt": datetime.now(timezone.utc),
+        })
+
+    def get(self, delivery_id: str) -> Optional[schemas.WebhookDelivery]:
+        return self._get(delivery_id)
+
+    def claim(self, limit: int, lease_seconds: float) -> List[schemas.WebhookDelivery]:
+        return self._lease_due(limit, lease_seconds)
+
+    def mark_succeeded(self, delivery_id: str, attempts: int, response_status: int) -> None:
+        self._update(delivery_id, {
+            "status": "succeeded",
+            "attempts": attempts,
+            "responseStatus": response_status,
+            "lastError": None,
+            "deliveredAt": datetime.now(timezone.utc),
+        })
+
+    def reschedule(self, delivery_id: str, attempts: int, next_attempt_at: datetime, error: str, response_status: Optional[int]) -> None:
+        self._update(delivery_id, {"attempts": attempts, "nextAttemptAt": next_attempt_at, "lastError": error, "responseStatus": response_status})
+
+    def mark_failed(self, delivery_id: str, attempts: int, error: str, response_status: Optional[int]) -> None:
+        self._update(delivery_id, {"status": "failed", "attempts": attempts, "lastError": error, "responseStatus": response_status})
+
+    def requeue(self, delivery_id: str) -> schemas.WebhookDelivery:
+        return self._update(delivery_id, {"status": "pending", "attempts": 0, "nextAttemptAt": datetime.now(timezone.utc)})
+
+    def purge_finished(self, before: datetime) -> int:
+        return self._purge(before, {"status": "succeeded"}) + self._purge(before, {"status": "failed"})
+
+    def purge_expired(self, before: datetime) -> int:
+        return self.purge_finished(before)
+
+    def anonymize_expired(self, since: Optional[datetime], before: datetime) -> int:
+        return sum(self._anonymize({"event.data": {}}, before, since, {"status": status}) for status in ("succeeded", "failed"))
+
+
+class FirestoreWebhookSubscriptionRepository(WebhookSubscriptionRecordMixin, FirestoreRepository, WebhookSubscriptionRepository):
+    """Stores subscriptions in the top-level `webhookSubscriptions` collection."""
+
+    collection_name = "webhookSubscriptions"
+    model = schemas.WebhookSubscriptionWithSecret
+    id_field = "subscriptionId"
+
+    def list(self, limit: int = 100, after: Optional[str] = None) -> List[schemas.WebhookSubscriptionWithSecret]:
+        return [self._to_model(doc) for doc in self._start_after(self._query().order_by("createdAt"), after).limit(limit).stream()]
+
+    def list_for_event_type(self, event_type: str) -> List[schemas.WebhookSubscriptionWithSecret]:
+        query = (
+            self._query().where(filter=FieldFilter("active", "==", True))
+            .where(filter=FieldFilter("eventTypes", "array_contains", event_type))
+        )
+        return [self._to_model(doc) for doc in query.stream()]
+
+
+class FirestoreWebhookDeliveryRepository(WebhookDeliveryRecordMixin, FirestoreRepository, WebhookDeliveryRepository):
+    """Stores deliveries in the top-level `webhookDeliveries` collection."""
+
+    collection_name = "webhookDeliveries"
+    model = schemas.WebhookDelivery
+    id_field = "deliveryId"
+
+    def list(self, subscription_id: str, status: Optional[str] = None, limit: int = 50, after: Optional[str] = None) -> List[schemas.WebhookDelivery]:
+        query = self._query().where(filter=FieldFilter("subscriptionId", "==", subscription_id))
+        if status:
+            query = query.where(filter=FieldFilter("status", "==", status))
+        query = self._start_after(query.order_by("createdAt", direction=firestore.Query.DESCENDING), after).limit(limit)
+        return [self._to_model(doc) for doc in query.stream()]
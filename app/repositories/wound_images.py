@@ -0,0 +1,60 @@
+# Location: app/repositories/wound_images.py
+
+from abc import ABC, abstractmethod
+from typing import List, Optional
+
+from google.cloud.firestore_v1.base_query import FieldFilter
+
+from app.api.v1 import schemas
+from app.repositories.base import FirestoreRepository
+
+
+class WoundImageRepository(ABC):
+    """
+    Storage interface for the metadata of wound-care photos. The photos and
+    their thumbnails are in Cloud Storage, under the blob names recorded.
+    """
+
+    @abstractmethod
+    def create(
+        self, patient_id: str, image_id: str, image_in: schemas.WoundImageCreate, files: schemas.WoundImageFiles, uploaded_by: str,
+    ) -> schemas.WoundImage:
+        """Stores a new photo record under `image_id`."""
+
+    @abstractmethod
+    def get(self, image_id: str) -> Optional[schemas.WoundImage]:
+        """Returns the record, or None if it does not exist."""
+
+    @abstractmethod
+    def list(self, patient_id: str, wound_id: Optional[str] = None, limit: int = 500) -> List[schemas.WoundImage]:
+        """Returns the patient's photos, optionally only those of one wound, in no particular order."""
+
+    @abstractmethod
+    def delete(self, image_id: str) -> None:
+        """Removes the record. Raises NotFoundError if it does not exist."""
+
+
+class FirestoreWoundImageRepository(FirestoreRepository, WoundImageRepository):
+    """Stores photo metadata in the top-level `woundImages` collection, keyed to the patient by `patientId`."""
+
+    collection_name = "woundImages"
+    model = schemas.WoundImage
+    id_field = "imageId"
+
+    def create(
+        self, patient_id: str, image_id: str, image_in: schemas.WoundImageCreate, files: schemas.WoundImageFiles, uploaded_by: str,
+    ) -> schemas.WoundImage:
+        data = {**image_in.model_dump(by_alias=True), **files.model_dump(by_alias=True), "patientId": patient_id, "uploadedBy": uploaded_by}
+        return self._create(data, record_id=image_id)
+
+    def get(self, image_id: str) -> Optional[schemas.WoundImage]:
+        return self._get(image_id)
+
+    def list(self, patient_id: str, wound_id: Optional[str] = None, limit: int = 500) -> List[schemas.WoundImage]:
+        query = self._query().where(filter=FieldFilter("patientId", "==", patient_id))
+        if wound_id:
+            query = query.where(filter=FieldFilter("woundId", "==", wound_id))
+        return self._fetch(query, limit)
+
+    def delete(self, image_id: str) -> None:
+        self._delete(image_id)
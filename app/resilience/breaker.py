@@ -0,0 +1,116 @@
+# Location: app/resilience/breaker.py
+
+import logging
+import threading
+import time
+from contextlib import contextmanager
+from typing import Callable, Iterator, Tuple, Type
+
+from app.core.metrics import DEPENDENCY_CALLS_REJECTED_TOTAL, CIRCUIT_BREAKER_STATE
+
+CLOSED, OPEN, HALF_OPEN = "closed", "open", "half-open"
+# Reported by the circuit_breaker_state gauge.
+STATE_VALUES = {CLOSED: 0, HALF_OPEN: 1, OPEN: 2}
+
+
+class DependencyUnavailable(Exception):
+    """
+    Raised instead of calling a dependency that is failing or saturated.
+    `retry_after` is the number of seconds after which a call may succeed.
+    """
+
+    def __init__(self, dependency: str, message: str, retry_after: int = 1):
+        super().__init__(message)
+        self.dependency = dependency
+        self.retry_after = retry_after
+
+
+class CircuitOpenError(DependencyUnavailable):
+    """Raised while a dependency's circuit is open."""
+
+
+class CircuitBreaker:
+    """
+    Stops calling a dependency after `failure_threshold` consecutive calls
+    have failed with one of `failure_types` (the circuit opens), so requests
+    fail fast instead of each waiting for the dependency to time out. After
+    `reset_seconds` one trial call is let through (half-open): if it
+    succeeds the circuit closes, otherwise it opens again. Other exceptions,
+    such as NotFound, say nothing about the dependency's health and are
+    passed through without being counted.
+    """
+
+    def __init__(
+        self,
+        name: str,
+        failure_types: Tuple[Type[BaseException], ...],
+        failure_threshold: int = 5,
+        reset_seconds: float = 30.0,
+        clock: Callable[[], float] = time.monotonic,
+    ):
+        self.name = name
+        self.failure_types = failure_types
+        self.failure_threshold = failure_threshold
+        self.reset_seconds = reset_seconds
+        self.clock = clock
+        self.state = CLOSED
+        self.failures = 0
+        self._opened_at = 0.0
+        self._trial_running = False
+        self._lock = threading.Lock()
+        CIRCUIT_BREAKER_STATE.labels(dependency=name).set(STATE_VALUES[CLOSED])
+
+    def _set_state(self, state: str) -> None:
+        if state != self.state:
+            log = logging.warning if state == OPEN else logging.info
+            log(f"Circuit breaker '{self.name}' is now {state} (was {self.state}).")
+        self.state = state
+        CIRCUIT_BREAKER_STATE.labels(dependency=self.name).set(STATE_VALUES[state])
+
+    def _before_call(self) -> None:
+        with self._lock:
+            if self.state == OPEN and self.clock() - self._opened_at >= self.reset_seconds:
+                self._set_state(HALF_OPEN)
+            if self.state == CLOSED:
+                return
+            if self.state == HALF_OPEN and not self._trial_running:
+                self._trial_running = True
+                return
+            DEPENDENCY_CALLS_REJECTED_TOTAL.labels(dependency=self.name, reason="open").inc()
+            remaining = self.reset_seconds - (self.clock() - self._opened_at)
+            raise CircuitOpenError(self.name, f"{self.name} is unavailable (circuit open)", retry_after=max(1, round(remaining)))
+
+    def _after_call(self, failed: bool) -> None:
+        with self._lock:
+            trial, self._trial_running = self._trial_running, False
+            if not failed:
+                self.failures = 0
+                self._set_state(CLOSED)
+                return
+            self.failures += 1
+            if trial or self.failures >= self.failure_threshold:
+                self._opened_at = self.clock()
+                self._set_state(OPEN)
+
+    @contextmanager
+    def guard(self) -> Iterator[None]:
+        """Runs the enclosed call through the breaker; raises CircuitOpenError while it is open."""
+        self._before_call()
+        try:
+            yield
+        except self.failure_types:
+            self._after_call(failed=True)
+            raise
+        except BaseException:
+            self._after_call(failed=False)
+            raise
+        self._after_call(failed=False)
+
+    def call(self, fn: Callable, *args, **kwargs):
+        with self.guard():
+            return fn(*args, **kwargs)
+
+    def snapshot(self) -> dict:
+        """The breaker's state for /readyz."""
+        with self._lock:
+            return {"state": self.state, "consecutiveFailures": self.failures}
@@ -0,0 +1,37 @@
+# Location: app/resilience/bulkhead.py
+
+import threading
+from contextlib import contextmanager
+from typing import Iterator
+
+from app.core.metrics import DEPENDENCY_CALLS_REJECTED_TOTAL
+from app.resilience.breaker import DependencyUnavailable
+
+
+class BulkheadFullError(DependencyUnavailable):
+    """Raised when a dependency already has as many calls in flight as its bulkhead allows."""
+
+
+class Bulkhead:
+    """
+    Caps the calls to one dependency that a worker has in flight, waiting
+    up to `max_wait_seconds` for a free slot. Requests run their blocking
+    calls on a shared thread pool; without a cap, a dependency that hangs
+    would hold every thread and stall requests that never touch it.
+    """
+
+    def __init__(self, name: str, max_concurrent: int, max_wait_seconds: float = 0.5):
+        self.name = name
+        self.max_concurrent = max_concurrent
+        self.max_wait_seconds = max_wait_seconds
+        self._slots = threading.BoundedSemaphore(max_concurrent)
+
+    @contextmanager
+    def guard(self) -> Iterator[None]:
+        if not self._slots.acquire(timeout=self.max_wait_seconds):
+            DEPENDENCY_CALLS_REJECTED_TOTAL.labels(dependency=self.name, reason="bulkhead").inc()
+            raise BulkheadFullError(self.name, f"{self.name} has too many calls in flight")
+        try:
+            yield
+        finally:
+            self._slots.release()
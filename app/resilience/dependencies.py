@@ -0,0 +1,96 @@
+# Location: app/resilience/dependencies.py
+
+from contextlib import contextmanager
+from typing import Dict, Iterator, Optional, Tuple, Type
+
+from app.core.config import get_settings
+from app.resilience.breaker import CircuitBreaker
+from app.resilience.bulkhead import Bulkhead
+
+# --- Configuration ---
+settings = get_settings()
+FAILURE_THRESHOLD = settings.circuit_breaker_failure_threshold
+RESET_SECONDS = settings.circuit_breaker_reset_seconds
+BULKHEAD_MAX_CONCURRENT_CALLS = settings.bulkhead_max_concurrent_calls
+BULKHEAD_MAX_WAIT_SECONDS = settings.bulkhead_max_wait_seconds
+
+# --- Dependencies ---
+# Firestore serves nearly every request, so capping its calls would only
+# cap the API; it gets a breaker but no bulkhead.
+FIRESTORE = "firestore"
+PUBSUB = "pubsub"
+TWILIO = "twilio"
+BULKHEADED = {PUBSUB, TWILIO}
+
+_breakers: Dict[str, CircuitBreaker] = {}
+_bulkheads: Dict[str, Bulkhead] = {}
+
+
+def google_transient_errors() -> Tuple[Type[BaseException], ...]:
+    """Errors from Google Cloud clients that mean the service itself is unwell, rather than the request."""
+    from concurrent.futures import TimeoutError as FutureTimeoutError
+    from google.api_core import exceptions
+
+    return (
+        exceptions.ServiceUnavailable, exceptions.DeadlineExceeded, exceptions.InternalServerError,
+        exceptions.TooManyRequests, exceptions.ResourceExhausted, FutureTimeoutError,
+    )
+
+
+def get_breaker(name: str, failure_types: Optional[Tuple[Type[BaseException], ...]] = None) -> CircuitBreaker:
+    """
+    The process-wide breaker for a dependency, created on first use with
+    `failure_types` (by default the transient Google Cloud errors).
+    """
+    if name not in _breakers:
+        _breakers[name] = CircuitBreaker(name, failure_types or google_transient_errors(), FAILURE_THRESHOLD, RESET_SECONDS)
+    return _breakers[name]
+
+
+def get_bulkhead(name: str) -> Bulkhead:
+    if name not in _bulkheads:
+        _bulkheads[name] = Bulkhead(name, BULKHEAD_MAX_CONCURRENT_CALLS, BULKHEAD_MAX_WAIT_SECONDS)
+    return _bulkheads[name]
+
+
+@contextmanager
+def protect(name: str, failure_types: Optional[Tuple[Type[BaseException], ...]] = None) -> Iterator[None]:
+    """
+    Runs the enclosed call to dependency `name` through its breaker, and its
+    bulkhead if it has one. Raises DependencyUnavailable instead of calling
+    a dependency whose circuit is open or whose calls are all in flight.
+    """
+    breaker = get_breaker(name, failure_types)
+    if name not in BULKHEADED:
+        with breaker.guard():
+            yield
+        return
+    with get_bulkhead(name).guard(), breaker.guard():
+        yield
+
+
+def breaker_states() -> Dict[str, dict]:
+    """The state of every breaker that has been used, by dependency."""
+    return {name: breaker.snapshot() for name, breaker in sorted(_breakers.items())}
+
+
+class GuardedRepository:
+    """
+    Wraps a repository so that each of its method calls goes through the
+    dependency's breaker. Iterators a method returns are read outside it.
+    """
+
+    def __init__(self, repository, dependency: str):
+        self._repository = repository
+        self._dependency = dependency
+
+    def __getattr__(self, name: str):
+        attribute = getattr(self._repository, name)
+        if not callable(attribute) or name.startswith("_"):
+            return attribute
+
+        def guarded(*args, **kwargs):
+            with protect(self._dependency):
+                return attribute(*args, **kwargs)
+
+        return guarded
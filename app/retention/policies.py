@@ -0,0 +1,69 @@
+# Location: app/retention/policies.py
+
+"""
+Retention policies: for each category of data, how many days it is kept and
+whether it is then deleted or anonymized. Deployment defaults are set in
+RETENTION_DEFAULTS; an organization's administrators may override any
+category through /organizations/{organizationId}/retention:
+
+    RETENTION_DEFAULTS=telemetry=730,audit=2190:anonymize,messages=90
+
+A category with neither a default nor an override is kept indefinitely.
+"""
+
+import typing
+from functools import lru_cache
+from typing import Dict, Optional, Tuple
+
+from app.api.v1 import schemas
+from app.core.config import get_settings
+
+CATEGORIES: Tuple[str, ...] = typing.get_args(schemas.RetentionCategory)
+
+
+def parse_retention(spec: str) -> Dict[str, schemas.RetentionRule]:
+    """
+    Parses RETENTION_DEFAULTS, comma-separated `<category>=<days>[:delete|anonymize]`
+    entries such as "telemetry=730,audit=2190:anonymize". Raises ValueError
+    for a malformed entry or an unknown category.
+    """
+    rules = {}
+    for entry in (part.strip() for part in spec.split(",")):
+        if not entry:
+            continue
+        category, _, value = entry.partition("=")
+        days, _, action = value.partition(":")
+        category = category.strip()
+        if category not in CATEGORIES:
+            raise ValueError(f"unknown retention category '{category}', expected one of {', '.join(CATEGORIES)}")
+        try:
+            rules[category] = schemas.RetentionRule(days=int(days), action=action or "delete")
+        except ValueError:
+            raise ValueError(f"invalid retention rule '{entry}', expected <category>=<days>[:delete|anonymize]")
+    return rules
+
+
+@lru_cache
+def get_retention_defaults() -> Dict[str, schemas.RetentionRule]:
+    return parse_retention(get_settings().retention_defaults)
+
+
+def effective_policy(
+    organization_id: Optional[str],
+    stored: Optional[schemas.RetentionPolicyRecord],
+    defaults: Optional[Dict[str, schemas.RetentionRule]] = None,
+) -> schemas.RetentionPolicy:
+    """The rules in effect for an organization: its own where it set them, the defaults elsewhere."""
+    defaults = get_retention_defaults() if defaults is None else defaults
+    rules = {}
+    for category in CATEGORIES:
+        own = getattr(stored, category) if stored else None
+        rule, source = (own, "organization") if own else (defaults.get(category), "config")
+        rules[category] = schemas.EffectiveRetentionRule(**rule.model_dump(), source=source) if rule else None
+    return schemas.RetentionPolicy(
+        organization_id=organization_id,
+        **rules,
+        updated_by=stored.updated_by if stored else None,
+        version=stored.version if stored else 0,
+        updated_at=stored.updated_at if stored else None,
+    )
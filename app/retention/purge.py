@@ -0,0 +1,84 @@
+# Location: app/retention/purge.py
+
+"""
+Applies the retention policy of the organization the caller acts for (see
+`acting_for`), one category at a time, and records a purge manifest for
+each: the cutoff, what was done and how many records of each collection it
+touched. Deleting is repeated every run, since anything older than the
+cutoff is gone; anonymizing only covers data that expired since the last
+completed manifest of the category, whose cutoff it starts from, so
+records are not rewritten every day.
+"""
+
+import logging
+from datetime import datetime, timedelta, timezone
+from typing import Callable, Dict, List, Optional, Tuple
+
+from app.api.v1 import schemas
+from app.api.v1.deps import (
+    get_audit_event_repository,
+    get_observation_repository,
+    get_outbox_repository,
+    get_purge_manifest_repository,
+    get_retention_policy_repository,
+    get_webhook_delivery_repository,
+)
+from app.retention.policies import CATEGORIES, effective_policy
+from app.tenancy.context import current_tenant
+
+# The stores of each category, by the collection name manifests count them
+# under. Each offers `purge_expired(before)` and `anonymize_expired(since, before)`.
+CATEGORY_STORES: Dict[str, List[Tuple[str, Callable]]] = {
+    "telemetry": [("observations", get_observation_repository)],
+    "audit": [("auditEvents", get_audit_event_repository)],
+    "messages": [("eventOutbox", get_outbox_repository), ("webhookDeliveries", get_webhook_delivery_repository)],
+}
+
+
+def _expire(category: str, rule: schemas.RetentionRule, since: Optional[datetime], cutoff: datetime) -> Dict[str, int]:
+    counts = {}
+    for collection, repository in CATEGORY_STORES[category]:
+        store = repository()
+        counts[collection] = store.purge_expired(cutoff) if rule.action == "delete" else store.anonymize_expired(since, cutoff)
+    return counts
+
+
+def apply_retention(now: datetime) -> List[schemas.PurgeManifest]:
+    """
+    Expires the current organization's data past its retention periods.
+    A category that fails is recorded in a failed manifest and the others
+    still run; the next run repeats it. Returns the manifests recorded.
+    """
+    tenant = current_tenant()
+    policy = effective_policy(tenant, get_retention_policy_repository().get(tenant) if tenant else None)
+    manifests = get_purge_manifest_repository()
+    recorded = []
+    for category in CATEGORIES:
+        rule = getattr(policy, category)
+        if rule is None:
+            continue
+        cutoff = now - timedelta(days=rule.days)
+        since = None
+        if rule.action == "anonymize":
+            last = manifests.last_completed(category, "anonymize")
+            since = last.cutoff if last else None
+        started = datetime.now(timezone.utc)
+        try:
+            counts, status, error = _expire(category, rule, since, cutoff), "completed", None
+        except Exception as e:
+            logging.exception(f"Retention of {category} for organization {tenant or '-'} failed")
+            counts, status, error = {}, "failed", str(e)
+        recorded.append(manifests.create(schemas.PurgeManifestCreate(
+            category=category,
+            action=rule.action,
+            retention_days=rule.days,
+            cutoff=cutoff,
+            since=since,
+            counts=counts,
+            status=status,
+            error=error,
+            started_at=started,
+            finished_at=datetime.now(timezone.utc),
+        )))
+        logging.info(f"Retention {rule.action} of {category} for organization {tenant or '-'} before {cutoff.isoformat()}: {status} {counts}")
+    return recorded
@@ -0,0 +1,114 @@
+# Location: app/rpc/gateway.py
+
+import json
+import re
+from typing import Any, Iterator
+
+import grpc
+from fastapi import APIRouter, HTTPException, Request, status
+from fastapi.responses import JSONResponse, StreamingResponse
+from google.api import annotations_pb2
+from google.protobuf import json_format
+from google.protobuf.descriptor import MethodDescriptor
+from starlette.concurrency import run_in_threadpool
+
+from app.rpc.interceptors import HTTP_STATUS_CODES
+from app.rpc.server import servicers
+from app.rpc.stubs import load
+
+# REST routes transcoded from the `google.api.http` rules of the megacare/v1
+# protos, in the manner of grpc-gateway: the routes, request fields and
+# response bodies all come from the proto definitions, so the JSON surface
+# cannot drift from the gRPC one. Each `{field}` of a path template binds
+# one segment to a top-level request field; other fields are read from the
+# query string, and from a JSON body for rules with `body: "*"`. Responses
+# use the proto3 JSON mapping (lowerCamelCase names, RFC 3339 timestamps).
+# Server streams are sent as newline-delimited JSON, one `{"result": ...}`
+# per message.
+
+_UNSUPPORTED_FIELD_RE = re.compile(r"\{[^}]*[=.][^}]*\}")
+
+
+class RpcAborted(Exception):
+    """Raised by `HttpServicerContext.abort`, where grpc would end the call."""
+
+    def __init__(self, code: grpc.StatusCode, details: str):
+        super().__init__(details)
+        self.code = code
+        self.details = details
+
+
+class HttpServicerContext:
+    """The part of grpc.ServicerContext the servicers use, for calls that arrive over HTTP."""
+
+    def abort(self, code: grpc.StatusCode, details: str) -> None:
+        raise RpcAborted(code, details)
+
+
+def _http_error(e: RpcAborted) -> HTTPException:
+    return HTTPException(status_code=HTTP_STATUS_CODES.get(e.code, status.HTTP_500_INTERNAL_SERVER_ERROR), detail=e.details)
+
+
+async def _request_message(request_class: Any, body: str, request: Request) -> Any:
+    values = dict(request.query_params)
+    if body == "*":
+        raw = await request.body()
+        if raw:
+            try:
+                values.update(json.loads(raw))
+            except (ValueError, TypeError) as e:
+                raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail=f"Invalid JSON body: {e}")
+    values.update(request.path_params)
+    try:
+        return json_format.ParseDict(values, request_class())
+    except json_format.ParseError as e:
+        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail=f"Invalid request: {e}")
+
+
+def _lines(messages: Iterator[Any]) -> Iterator[str]:
+    try:
+        for message in messages:
+            yield json.dumps({"result": json_format.MessageToDict(message)}) + "\n"
+    except RpcAborted as e:
+        # The status line has gone out already, so the error ends the stream.
+        yield json.dumps({"error": {"code": e.code.value[0], "message": e.details}}) + "\n"
+
+
+def _add_route(router: APIRouter, pb: Any, servicer: Any, method: MethodDescriptor) -> None:
+    rule = method.GetOptions().Extensions[annotations_pb2.http]
+    pattern = rule.WhichOneof("pattern")
+    if pattern is None:
+        return  # gRPC only
+    if pattern == "custom":
+        http_method, path = rule.custom.kind.upper(), rule.custom.path
+    else:
+        http_method, path = pattern.upper(), getattr(rule, pattern)
+    if _UNSUPPORTED_FIELD_RE.search(path) or rule.body not in ("", "*") or rule.additional_bindings:
+        raise ValueError(f"{method.full_name}: the HTTP gateway only supports `{{field}}` paths, `body: \"*\"` and one binding per method")
+
+    request_class = getattr(pb, method.input_type.name)
+    behavior = getattr(servicer, method.name)
+
+    async def endpoint(request: Request):
+        message = await _request_message(request_class, rule.body, request)
+        context = HttpServicerContext()
+        if method.server_streaming:
+            return StreamingResponse(_lines(behavior(message, context)), media_type="application/x-ndjson")
+        try:
+            response = await run_in_threadpool(behavior, message, context)
+        except RpcAborted as e:
+            raise _http_error(e)
+        return JSONResponse(json_format.MessageToDict(response))
+
+    router.add_api_route(path, endpoint, methods=[http_method], name=method.name)
+
+
+def gateway_router() -> APIRouter:
+    """A router with a route for every RPC that has an HTTP rule."""
+    router = APIRouter()
+    for name, servicer in servicers().items():
+        pb, _ = load(name)
+        for service in pb.DESCRIPTOR.services_by_name.values():
+            for method in service.methods:
+                _add_route(router, pb, servicer, method)
+    return router
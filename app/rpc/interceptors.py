@@ -0,0 +1,165 @@
+# Location: app/rpc/interceptors.py
+
+import logging
+from datetime import datetime, timezone
+from typing import Callable, Dict, Iterable, Optional
+
+import grpc
+from starlette.datastructures import Headers
+
+from app.api.v1 import schemas
+from app.audit.context import AuditContext, audit_context_var, set_actor
+from app.audit.events import purpose_of_use
+from app.auth.google_tokens import verify_google_id_token
+from app.middleware.authentication import bearer_token
+from app.repositories.audit_events import AuditEventRepository
+
+# HTTP statuses of gRPC outcomes, as the audit trail records them and the
+# HTTP gateway returns them (see app/rpc/gateway.py). Other codes are 500s.
+HTTP_STATUS_CODES = {
+    grpc.StatusCode.INVALID_ARGUMENT: 400,
+    grpc.StatusCode.FAILED_PRECONDITION: 400,
+    grpc.StatusCode.OUT_OF_RANGE: 400,
+    grpc.StatusCode.UNAUTHENTICATED: 401,
+    grpc.StatusCode.PERMISSION_DENIED: 403,
+    grpc.StatusCode.NOT_FOUND: 404,
+    grpc.StatusCode.ALREADY_EXISTS: 409,
+    grpc.StatusCode.ABORTED: 409,
+    grpc.StatusCode.RESOURCE_EXHAUSTED: 429,
+    grpc.StatusCode.UNIMPLEMENTED: 501,
+    grpc.StatusCode.UNAVAILABLE: 503,
+    grpc.StatusCode.DEADLINE_EXCEEDED: 504,
+}
+
+
+def _wrap(handler: Optional[grpc.RpcMethodHandler], wrapper: Callable[[Callable], Callable]) -> Optional[grpc.RpcMethodHandler]:
+    """Returns `handler` with its behaviour wrapped. Every MegaCare RPC takes a single request message."""
+    if handler is None:
+        return None
+    serializers = {"request_deserializer": handler.request_deserializer, "response_serializer": handler.response_serializer}
+    if handler.unary_unary:
+        return grpc.unary_unary_rpc_method_handler(wrapper(handler.unary_unary), **serializers)
+    if handler.unary_stream:
+        return grpc.unary_stream_rpc_method_handler(wrapper(handler.unary_stream), **serializers)
+    return None
+
+
+class ServiceAuthInterceptor(grpc.ServerInterceptor):
+    """
+    Admits calls only with a Google-signed ID token for `audience` whose
+    email is one of `allowed_callers`, sent as `authorization: Bearer
+    <token>` metadata. These are the same callers, checked the same way, as
+    for /internal/services (see ServiceAuthenticationMiddleware).
+    """
+
+    def __init__(
+        self,
+        audience: Optional[str],
+        allowed_callers: Iterable[str],
+        verify: Callable[[str, str], Dict] = verify_google_id_token,
+    ):
+        self.audience = audience
+        self.allowed_callers = frozenset(allowed_callers)
+        self.verify = verify
+
+    def _authenticate(self, context: grpc.ServicerContext) -> None:
+        if not self.audience or not self.allowed_callers:
+            context.abort(grpc.StatusCode.PERMISSION_DENIED, "No internal service callers are configured")
+        token = bearer_token(dict(context.invocation_metadata()).get("authorization"))
+        if not token:
+            context.abort(grpc.StatusCode.UNAUTHENTICATED, "Service identity token not provided")
+        try:
+            claims = self.verify(token, self.audience)
+        except Exception as e:
+            logging.info(f"Rejected service token for gRPC call from {context.peer()}: {e}")
+            context.abort(grpc.StatusCode.UNAUTHENTICATED, f"Invalid service identity token: {e}")
+        if claims["email"] not in self.allowed_callers:
+            logging.warning(f"Refused gRPC call from service account {claims['email']}")
+            context.abort(grpc.StatusCode.PERMISSION_DENIED, "This service account may not call internal services")
+        set_actor({"uid": claims["email"]})
+
+    def intercept_service(self, continuation, handler_call_details):
+        def guarded(behavior: Callable) -> Callable:
+            # Checked inside the call, on the thread that runs the handler,
+            # so the verified actor reaches the audit context.
+            def call(request, context):
+                self._authenticate(context)
+                return behavior(request, context)
+            return call
+        return _wrap(continuation(handler_call_details), guarded)
+
+
+class AuditInterceptor(grpc.ServerInterceptor):
+    """
+    Writes an audit event for every call, as AuditMiddleware does for HTTP
+    requests: the route is the full method name, e.g.
+    `/megacare.v1.PatientService/GetPatient`, and the patient is taken from
+    the request's `patient_id` when it has one. Streams are recorded when
+    they start. Must come before ServiceAuthInterceptor, so refused calls
+    are recorded too.
+    """
+
+    def __init__(self, repository_factory: Callable[[], AuditEventRepository]):
+        self.repository_factory = repository_factory
+
+    def intercept_service(self, continuation, handler_call_details):
+        method = handler_call_details.method
+
+        def audited(behavior: Callable) -> Callable:
+            def call(request, context):
+                audit = AuditContext()
+                occurred_at = datetime.now(timezone.utc)
+                token = audit_context_var.set(audit)
+                status_code = 500
+                try:
+                    result = behavior(request, context)
+                    status_code = 200
+                    return result
+                except Exception:
+                    code = context.code() if hasattr(context, "code") else None
+                    status_code = HTTP_STATUS_CODES.get(code, 500)
+                    raise
+                finally:
+                    audit_context_var.reset(token)
+                    self._store(self._event(method, request, context, audit, status_code, occurred_at))
+            return call
+        return _wrap(continuation(handler_call_details), audited)
+
+    @staticmethod
+    def _event(method: str, request, context: grpc.ServicerContext, audit: AuditContext, status_code: int, occurred_at: datetime) -> schemas.AuditEventCreate:
+        # "/megacare.v1.PatientService/GetPatient" -> "patient"
+        resource = method.rpartition("/")[0].rsplit(".", 1)[-1].removesuffix("Service").lower()
+        request_fields = {field.name for field in request.DESCRIPTOR.fields}
+
+        def field(name: str) -> Optional[str]:
+            return (getattr(request, name) or None) if name in request_fields else None
+
+        metadata = dict(context.invocation_metadata())
+        # "ipv4:10.0.0.7:51234" or "ipv6:[::1]:51234"
+        peer = context.peer().split(":", 1)[-1].rsplit(":", 1)[0].strip("[]")
+        return schemas.AuditEventCreate(
+            occurred_at=occurred_at,
+            action="read",
+            outcome="success" if status_code < 400 else "failure",
+            status_code=status_code,
+            method="GRPC",
+            route=method,
+            path=method,
+            actor_uid=audit.actor_uid,
+            actor_roles=audit.actor_roles,
+            resource_type=audit.resource_type or f"{resource}s",
+            resource_id=audit.resource_id or field(f"{resource}_id"),
+            patient_id=audit.patient_id or field("patient_id"),
+            purpose_of_use=purpose_of_use(Headers(headers=metadata)),
+            source_ip=peer or None,
+            user_agent=metadata.get("user-agent"),
+        )
+
+    def _store(self, event: schemas.AuditEventCreate) -> None:
+        try:
+            self.repository_factory().append(event)
+        except Exception:
+            logging.exception(
+                f"Failed to write audit event for gRPC {event.route} by {event.actor_uid or 'anonymous'}",
+                extra={"report_error": True},
+            )
@@ -0,0 +1,92 @@
+# Location: app/rpc/messages.py
+
+from datetime import datetime, timezone
+from typing import Any, Optional
+
+from google.protobuf.timestamp_pb2 import Timestamp
+
+from app.api.v1 import schemas
+from app.rpc.stubs import load
+
+# Converts API models to the protobuf messages of app/rpc/protos. Unset
+# strings are sent as "" (the proto3 default); timestamps are omitted.
+
+
+def timestamp(value: Optional[datetime]) -> Optional[Timestamp]:
+    if value is None:
+        return None
+    message = Timestamp()
+    message.FromDatetime(value if value.tzinfo else value.replace(tzinfo=timezone.utc))
+    return message
+
+
+def from_timestamp(message: Any, field: str) -> Optional[datetime]:
+    """The datetime of a request's optional Timestamp field, or None when it is not set."""
+    if not message.HasField(field):
+        return None
+    return getattr(message, field).ToDatetime(tzinfo=timezone.utc)
+
+
+def patient_message(patient: schemas.Patient) -> Any:
+    pb, _ = load("patients")
+    contact = patient.contact
+    return pb.Patient(
+        patient_id=patient.patient_id,
+        mrn=patient.mrn,
+        given_name=patient.given_name,
+        family_name=patient.family_name,
+        birth_date=patient.dob.isoformat(),
+        sex=patient.sex,
+        nhs_number=patient.nhs_number or "",
+        contact=pb.ContactInfo(
+            phone_number=contact.phone_number or "",
+            email=contact.email or "",
+            address_line=contact.address_line or "",
+            city=contact.city or "",
+            postal_code=contact.postal_code or "",
+            country=contact.country or "",
+        ) if contact else None,
+        create_time=timestamp(patient.created_at),
+        update_time=timestamp(patient.updated_at),
+    )
+
+
+def appointment_message(appointment: schemas.Appointment) -> Any:
+    pb, _ = load("appointments")
+    return pb.Appointment(
+        appointment_id=appointment.appointment_id,
+        patient_id=appointment.patient_id,
+        practitioner_id=appointment.practitioner_id,
+        start_time=timestamp(appointment.start),
+        end_time=timestamp(appointment.end),
+        status=appointment.status,
+        appointment_type=appointment.appointment_type or "",
+        reason=appointment.reason or "",
+        location=appointment.location or "",
+        cancellation_reason=appointment.cancellation_reason or "",
+        create_time=timestamp(appointment.created_at),
+        update_time=timestamp(appointment.updated_at),
+    )
+
+
+def _coding(pb: Any, coding: schemas.Coding) -> Any:
+    return pb.Coding(system=coding.system, code=coding.code, display=coding.display or "")
+
+
+def observation_message(observation: schemas.Observation) -> Any:
+    pb, _ = load("observations")
+    return pb.Observation(
+        observation_id=observation.observation_id,
+        patient_id=observation.patient_id,
+        code=_coding(pb, observation.code),
+        status=observation.status,
+        value=observation.value,
+        unit=observation.unit or "",
+        components=[
+            pb.ObservationComponent(code=_coding(pb, component.code), value=component.value, unit=component.unit or "")
+            for component in observation.components
+        ],
+        effective_time=timestamp(observation.effective_at),
+        create_time=timestamp(observation.created_at),
+        update_time=timestamp(observation.updated_at),
+    )
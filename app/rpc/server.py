@@ -0,0 +1,105 @@
+# Location: app/rpc/server.py
+
+import logging
+import os
+import signal
+from concurrent import futures
+from typing import Any, Dict, Optional
+
+import firebase_admin
+import grpc
+from firebase_admin import credentials
+
+from app.api.v1.deps import (
+    get_appointment_repository,
+    get_audit_event_repository,
+    get_observation_repository,
+    get_patient_repository,
+)
+from app.core.config import get_settings
+from app.core.logging_config import setup_logging
+from app.rpc.interceptors import AuditInterceptor, ServiceAuthInterceptor
+from app.rpc.services import AppointmentService, ObservationService, PatientService
+from app.rpc.stubs import load
+
+
+def servicers() -> Dict[str, Any]:
+    """The servicer of each megacare/v1 proto, by proto name (see `load`)."""
+    return {
+        "patients": PatientService(get_patient_repository),
+        "appointments": AppointmentService(get_appointment_repository),
+        "observations": ObservationService(get_observation_repository),
+    }
+
+
+def create_server(max_workers: int) -> grpc.Server:
+    """
+    A gRPC server with the Patient, Appointment and Observation services,
+    not yet bound or started. Every call is audited and needs the identity
+    token of an allowed MegaCare service.
+    """
+    settings = get_settings()
+    interceptors = [
+        AuditInterceptor(get_audit_event_repository),
+        ServiceAuthInterceptor(settings.service_auth_audience, settings.service_auth_callers),
+    ]
+    server = grpc.server(futures.ThreadPoolExecutor(max_workers=max_workers), interceptors=interceptors)
+    services = servicers()
+    load("patients")[1].add_PatientServiceServicer_to_server(services["patients"], server)
+    load("appointments")[1].add_AppointmentServiceServicer_to_server(services["appointments"], server)
+    load("observations")[1].add_ObservationServiceServicer_to_server(services["observations"], server)
+    return server
+
+
+class RpcServer:
+    """
+    Serves gRPC on its own port next to the HTTP server, started and stopped
+    with the worker (see `lifespan` in app/main.py). The port is opened with
+    SO_REUSEPORT, so every worker of an instance can listen on it and the
+    kernel spreads connections across them.
+    """
+
+    def __init__(self, port: int, max_workers: int, grace_seconds: float = 5.0):
+        self.port = port
+        self.max_workers = max_workers
+        self.grace_seconds = grace_seconds
+        self._server: Optional[grpc.Server] = None
+
+    def start(self) -> None:
+        self._server = create_server(self.max_workers)
+        self._server.add_insecure_port(f"[::]:{self.port}")
+        self._server.start()
+        logging.info(f"gRPC server listening on port {self.port}.")
+
+    def wait(self) -> None:
+        """Blocks until the server has stopped."""
+        if self._server:
+            self._server.wait_for_termination()
+
+    def stop(self) -> None:
+        if self._server:
+            # Waits for in-flight calls, as gunicorn does for HTTP requests.
+            self._server.stop(self.grace_seconds).wait()
+            self._server = None
+
+
+def main() -> None:
+    """
+    Runs only the gRPC server, for platforms that route a single port per
+    container. On Cloud Run, deploy the image a second time with
+    `python -m app.rpc.server` as the command and HTTP/2 end-to-end enabled;
+    it listens on PORT.
+    """
+    setup_logging()
+    settings = get_settings()
+    if not firebase_admin._apps:
+        firebase_admin.initialize_app(credentials.ApplicationDefault(), {"projectId": settings.google_cloud_project})
+    server = RpcServer(int(os.getenv("PORT", settings.grpc_port)), settings.grpc_max_workers)
+    server.start()
+    # Cloud Run sends SIGTERM before stopping the container.
+    signal.signal(signal.SIGTERM, lambda *_: server.stop())
+    server.wait()
+
+
+if __name__ == "__main__":
+    main()
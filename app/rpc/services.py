@@ -0,0 +1,163 @@
+# Location: app/rpc/services.py
+
+import hashlib
+import logging
+from typing import Any, Callable, Iterator, List, Optional, Tuple, TypeVar
+
+import grpc
+
+from app.audit.context import annotate
+from app.pagination.cursors import InvalidCursorError, decode_cursor, encode_cursor
+from app.repositories.appointments import AppointmentRepository
+from app.repositories.base import StaleCursorError
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+from app.rpc.messages import appointment_message, from_timestamp, observation_message, patient_message
+from app.rpc.stubs import load
+
+T = TypeVar("T")
+
+DEFAULT_PAGE_SIZE = 30
+MAX_PAGE_SIZE = 100
+
+
+def _page(
+    context: grpc.ServicerContext,
+    request: Any,
+    fetch: Callable[[Optional[str], int], List[T]],
+    id_of: Callable[[T], str],
+) -> Tuple[List[T], str]:
+    """
+    Fetches the page of a List request, with the same signed cursors as the
+    REST list endpoints (see app/pagination/pages.py). A page token only
+    continues the request it was issued for: the other fields must match.
+    """
+    if request.page_size < 0:
+        context.abort(grpc.StatusCode.INVALID_ARGUMENT, "page_size must not be negative")
+    page_size = min(request.page_size or DEFAULT_PAGE_SIZE, MAX_PAGE_SIZE)
+    filters = type(request)()
+    filters.CopyFrom(request)
+    filters.ClearField("page_size")
+    filters.ClearField("page_token")
+    query = hashlib.sha256(type(request).DESCRIPTOR.full_name.encode() + filters.SerializeToString(deterministic=True)).hexdigest()[:16]
+
+    after = None
+    if request.page_token:
+        try:
+            state = decode_cursor(request.page_token)
+        except InvalidCursorError as e:
+            logging.info(f"Rejected gRPC page token: {e}")
+            context.abort(grpc.StatusCode.INVALID_ARGUMENT, "Invalid page_token")
+        if state.get("q") != query:
+            context.abort(grpc.StatusCode.INVALID_ARGUMENT, "The page_token belongs to a different request; keep the filters of the first page")
+        after = state.get("a")
+
+    try:
+        items = fetch(after, page_size + 1)
+    except StaleCursorError as e:
+        context.abort(grpc.StatusCode.FAILED_PRECONDITION, str(e))
+    if len(items) <= page_size:
+        return items, ""
+    items = items[:page_size]
+    return items, encode_cursor({"a": id_of(items[-1]), "q": query})
+
+
+def _found(context: grpc.ServicerContext, record: Optional[T], kind: str) -> T:
+    if record is None:
+        context.abort(grpc.StatusCode.NOT_FOUND, f"{kind} not found")
+    return record
+
+
+class PatientService:
+    """Implements megacare.v1.PatientService over the patient repository."""
+
+    def __init__(self, repo_factory: Callable[[], PatientRepository]):
+        self.repo_factory = repo_factory
+
+    def GetPatient(self, request, context):
+        return patient_message(_found(context, self.repo_factory().get(request.patient_id), "Patient"))
+
+    def GetPatientByMrn(self, request, context):
+        patient = _found(context, self.repo_factory().get_by_mrn(request.mrn), "Patient")
+        annotate(patient_id=patient.patient_id, resource_id=patient.patient_id)
+        return patient_message(patient)
+
+    def ListPatients(self, request, context):
+        pb, _ = load("patients")
+        repo = self.repo_factory()
+        patients, next_token = _page(context, request, lambda after, limit: repo.list(limit=limit, after=after), lambda p: p.patient_id)
+        return pb.ListPatientsResponse(patients=[patient_message(p) for p in patients], next_page_token=next_token)
+
+    def StreamPatients(self, request, context) -> Iterator[Any]:
+        since, before = from_timestamp(request, "updated_since"), from_timestamp(request, "updated_before")
+        for patient in self.repo_factory().stream(updated_since=since, updated_before=before):
+            yield patient_message(patient)
+
+
+class AppointmentService:
+    """Implements megacare.v1.AppointmentService over the appointment repository."""
+
+    def __init__(self, repo_factory: Callable[[], AppointmentRepository]):
+        self.repo_factory = repo_factory
+
+    def GetAppointment(self, request, context):
+        appointment = _found(context, self.repo_factory().get(request.appointment_id), "Appointment")
+        annotate(patient_id=appointment.patient_id)
+        return appointment_message(appointment)
+
+    def ListAppointments(self, request, context):
+        if not request.patient_id and not request.practitioner_id:
+            context.abort(grpc.StatusCode.INVALID_ARGUMENT, "Provide a patient_id or practitioner_id")
+        pb, _ = load("appointments")
+        repo = self.repo_factory()
+        appointments, next_token = _page(
+            context,
+            request,
+            lambda after, limit: repo.list(
+                patient_id=request.patient_id or None,
+                practitioner_id=request.practitioner_id or None,
+                start_from=from_timestamp(request, "start_from"),
+                start_to=from_timestamp(request, "start_to"),
+                limit=limit,
+                after=after,
+            ),
+            lambda a: a.appointment_id,
+        )
+        return pb.ListAppointmentsResponse(appointments=[appointment_message(a) for a in appointments], next_page_token=next_token)
+
+
+class ObservationService:
+    """Implements megacare.v1.ObservationService over the observation repository."""
+
+    def __init__(self, repo_factory: Callable[[], ObservationRepository]):
+        self.repo_factory = repo_factory
+
+    def GetObservation(self, request, context):
+        observation = _found(context, self.repo_factory().get(request.observation_id), "Observation")
+        annotate(patient_id=observation.patient_id)
+        return observation_message(observation)
+
+    def ListObservations(self, request, context):
+        if not request.patient_id:
+            context.abort(grpc.StatusCode.INVALID_ARGUMENT, "patient_id is required")
+        pb, _ = load("observations")
+        repo = self.repo_factory()
+        observations, next_token = _page(
+            context,
+            request,
+            lambda after, limit: repo.list(
+                request.patient_id,
+                code=request.code or None,
+                start_from=from_timestamp(request, "start_from"),
+                start_to=from_timestamp(request, "start_to"),
+                limit=limit,
+                after=after,
+            ),
+            lambda o: o.observation_id,
+        )
+        return pb.ListObservationsResponse(observations=[observation_message(o) for o in observations], next_page_token=next_token)
+
+    def StreamObservations(self, request, context) -> Iterator[Any]:
+        since, before = from_timestamp(request, "updated_since"), from_timestamp(request, "updated_before")
+        for observation in self.repo_factory().stream(updated_since=since, updated_before=before):
+            yield observation_message(observation)
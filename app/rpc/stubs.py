@@ -0,0 +1,25 @@
+# Location: app/rpc/stubs.py
+
+import os
+import sys
+from functools import lru_cache
+from types import ModuleType
+from typing import Tuple
+
+import grpc
+
+# The .proto files are the contract with other services, which generate
+# their clients from them. This service compiles them when first used
+# instead of checking in generated code that could drift from them.
+# google/api holds the HTTP rule annotations from googleapis, which the
+# HTTP gateway reads (see app/rpc/gateway.py).
+PROTO_DIR = os.path.join(os.path.dirname(__file__), "protos")
+
+
+@lru_cache
+def load(name: str) -> Tuple[ModuleType, ModuleType]:
+    """Returns the message and service modules of megacare/v1/<name>.proto, e.g. `load("patients")`."""
+    # Proto imports are resolved against sys.path.
+    if PROTO_DIR not in sys.path:
+        sys.path.append(PROTO_DIR)
+    return grpc.protos_and_services(f"megacare/v1/{name}.proto")
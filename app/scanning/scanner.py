@@ -0,0 +1,84 @@
+# Location: app/scanning/scanner.py
+
+import socket
+import struct
+from abc import ABC, abstractmethod
+from dataclasses import dataclass
+from functools import lru_cache
+from typing import Iterable, Optional
+
+from app.core.config import get_settings
+
+
+class ScanError(Exception):
+    """Raised when a file could not be scanned, e.g. the scanner is unreachable. Worth retrying."""
+
+
+@dataclass(frozen=True)
+class ScanResult:
+    clean: bool
+    threat: Optional[str] = None  # The scanner's name for what it found, e.g. "Win.Test.EICAR_HDB-1".
+
+
+class Scanner(ABC):
+    """Checks uploaded files for malware."""
+
+    @abstractmethod
+    def scan(self, chunks: Iterable[bytes]) -> ScanResult:
+        """Scans the file read as `chunks`. Raises ScanError if no verdict was reached."""
+
+
+class ClamdScanner(Scanner):
+    """
+    Streams files to a clamd daemon with its INSTREAM command, e.g. the
+    ClamAV sidecar container of the Cloud Run service. clamd refuses streams
+    over its StreamMaxLength (25 MB by default), which should be at least
+    PATIENT_DOCUMENT_MAX_BYTES.
+    """
+
+    def __init__(self, host: str, port: int, timeout: float):
+        self.host = host
+        self.port = port
+        self.timeout = timeout
+
+    def scan(self, chunks: Iterable[bytes]) -> ScanResult:
+        try:
+            with socket.create_connection((self.host, self.port), timeout=self.timeout) as conn:
+                conn.sendall(b"zINSTREAM\0")
+                for chunk in chunks:
+                    if chunk:
+                        conn.sendall(struct.pack("!L", len(chunk)) + chunk)
+                conn.sendall(struct.pack("!L", 0))
+                reply = self._read_reply(conn)
+        except OSError as e:
+            raise ScanError(f"clamd at {self.host}:{self.port} is unavailable: {e}") from e
+        return self._verdict(reply)
+
+    @staticmethod
+    def _read_reply(conn: socket.socket) -> str:
+        data = b""
+        while not data.endswith(b"\0"):
+            received = conn.recv(4096)
+            if not received:
+                break
+            data += received
+        return data.rstrip(b"\0").decode("utf-8", "replace").strip()
+
+    @staticmethod
+    def _verdict(reply: str) -> ScanResult:
+        """Reads clamd's "stream: OK" or "stream: <signature> FOUND" reply; anything else is an error."""
+        result = reply.split(":", 1)[-1].strip()
+        if result == "OK":
+            return ScanResult(clean=True)
+        if result.endswith(" FOUND"):
+            return ScanResult(clean=False, threat=result[: -len(" FOUND")].strip())
+        raise ScanError(f"clamd could not scan the file: {reply or 'no reply'}")
+
+
+@lru_cache
+def get_scanner() -> Optional[Scanner]:
+    """Returns the configured scanner, or None with DOCUMENT_SCANNER=none."""
+    settings = get_settings()
+    if settings.document_scanner == "clamav":
+        return ClamdScanner(settings.clamav_host, settings.clamav_port, settings.clamav_timeout_seconds)
+    return None
@@ -0,0 +1,222 @@
+# Location: app/scheduler/jobs.py
+
+import logging
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, Iterator, Optional
+
+from app.api.v1 import schemas
+from app.api.v1.deps import (
+    get_appointment_reminder_repository,
+    get_appointment_repository,
+    get_claim_repository,
+    get_coverage_repository,
+    get_event_publisher,
+    get_idempotency_repository,
+    get_job_run_repository,
+    get_job_task_queue,
+    get_lockout_repository,
+    get_magic_link_repository,
+    get_organization_repository,
+    get_outbox_repository,
+    get_patient_repository,
+    get_stream_event_repository,
+    get_webhook_delivery_repository,
+)
+from app.claims.clearinghouse import get_claims_clearinghouse
+from app.claims.submission import refresh_claim_status
+from app.core.config import get_settings
+from app.dependencies.notifications import get_notifier
+from app.eligibility.checks import billing_provider
+from app.eligibility.clearinghouse import ClearinghouseError
+from app.reminders.scheduling import send_reminder
+from app.repositories.appointments import AppointmentRepository
+from app.repositories.claims import ClaimRepository
+from app.repositories.transactions import unit_of_work
+from app.retention.purge import apply_retention
+from app.services.claims import AWAITING_PAYER
+from app.scheduler.registry import cron_job
+from app.tenancy.context import acting_for
+
+# --- Scheduled Jobs ---
+# Every recurring job, registered by name. Importing this module registers
+# them; the cron endpoint and the ticker do so at start-up.
+APPOINTMENT_REMINDERS_JOB = "appointment-reminders"
+APPOINTMENT_REMINDER_SWEEP_JOB = "appointment-reminder-sweep"
+RETENTION_PURGE_JOB = "retention-purge"
+DATA_RETENTION_JOB = "data-retention"
+CLAIM_STATUS_JOB = "claim-status"
+
+REMINDER_PAGE_SIZE = 200
+CLAIM_PAGE_SIZE = 100
+
+settings = get_settings()
+
+
+def _appointments_starting(appointments: AppointmentRepository, start_from: datetime, start_to: datetime) -> Iterator[schemas.Appointment]:
+    """Pages through appointments by start time; each page resumes at the last start seen."""
+    seen = set()
+    while True:
+        page = appointments.list(start_from=start_from, start_to=start_to, limit=REMINDER_PAGE_SIZE)
+        fresh = [a for a in page if a.appointment_id not in seen]
+        yield from fresh
+        if len(page) < REMINDER_PAGE_SIZE or not fresh:
+            return
+        seen.update(a.appointment_id for a in fresh)
+        start_from = page[-1].start
+
+
+def _claims_awaiting_payer(claims: ClaimRepository) -> Iterator[schemas.Claim]:
+    """Pages through the claims the payer has not settled, newest first."""
+    after = None
+    while True:
+        page = claims.list(statuses=list(AWAITING_PAYER), limit=CLAIM_PAGE_SIZE, after=after)
+        yield from page
+        if len(page) < CLAIM_PAGE_SIZE:
+            return
+        after = page[-1].claim_id
+
+
+def _tenants() -> Iterator[Optional[str]]:
+    """
+    The organizations a job works through one at a time, so that the records
+    it reads and the events it emits are each organization's; a single
+    unscoped pass when tenancy is off.
+    """
+    if not settings.tenancy_enabled:
+        yield None
+        return
+    organizations, after = get_organization_repository(), None
+    while True:
+        page = organizations.list(limit=100, after=after)
+        yield from (organization.organization_id for organization in page)
+        if len(page) < 100:
+            return
+        after = page[-1].organization_id
+
+
+@cron_job(APPOINTMENT_REMINDERS_JOB, every=timedelta(minutes=15))
+def send_appointment_reminders() -> Dict[str, Any]:
+    """
+    Emits `appointment.reminder_due` for every booked appointment starting
+    within APPOINTMENT_REMINDER_LEAD_HOURS and records `reminderSentAt` on
+    it, so each appointment is announced once. Patients are reminded by
+    the reminders scheduled at booking (see `appointment-reminder-sweep`).
+    """
+    now = datetime.now(timezone.utc)
+    appointments = get_appointment_repository()
+    reminders = 0
+    for tenant in _tenants():
+        with acting_for(tenant):
+            due = [
+                appointment
+                for appointment in _appointments_starting(appointments, now, now + timedelta(hours=settings.appointment_reminder_lead_hours))
+                if appointment.status == "booked" and appointment.reminder_sent_at is None
+            ]
+            for appointment in due:
+                with unit_of_work():
+                    updated = appointments.update(appointment.appointment_id, {"reminderSentAt": now})
+                    get_event_publisher().emit("appointment.reminder_due", f"appointments/{appointment.appointment_id}", updated)
+            reminders += len(due)
+    return {"remindersDue": reminders}
+
+
+@cron_job(APPOINTMENT_REMINDER_SWEEP_JOB, every=timedelta(minutes=5))
+def send_due_appointment_reminders() -> Dict[str, Any]:
+    """
+    Sends the appointment reminders that are due but were not queued for
+    their time: those due beyond the task queue's longest delay, all of
+    them without Cloud Tasks, and any whose job was lost.
+    """
+    now = datetime.now(timezone.utc)
+    reminders = get_appointment_reminder_repository()
+    notifier = get_notifier(get_job_task_queue())
+    sent = skipped = 0
+    for tenant in _tenants():
+        with acting_for(tenant):
+            while True:
+                due = reminders.list_due(now, limit=REMINDER_PAGE_SIZE)
+                finished = [send_reminder(reminder.reminder_id, notifier) for reminder in due]
+                sent += sum(1 for reminder in finished if reminder and reminder.status == "sent")
+                skipped += sum(1 for reminder in finished if reminder and reminder.status == "skipped")
+                if len(due) < REMINDER_PAGE_SIZE:
+                    break
+    return {"remindersSent": sent, "remindersSkipped": skipped}
+
+
+@cron_job(RETENTION_PURGE_JOB, every=timedelta(days=1), lease=timedelta(hours=1))
+def purge_operational_records() -> Dict[str, Any]:
+    """
+    Deletes relayed outbox entries, finished webhook deliveries and job runs
+    older than OPERATIONAL_RETENTION_DAYS, idempotency records past
+    IDEMPOTENCY_TTL_SECONDS, event stream records past
+    EVENT_STREAM_RETENTION_HOURS, and sign-in links that expired and
+    failed sign-in records quiet for OPERATIONAL_RETENTION_DAYS. Clinical
+    records are not touched.
+    """
+    now = datetime.now(timezone.utc)
+    cutoff = now - timedelta(days=settings.operational_retention_days)
+    return {
+        "outboxEntries": get_outbox_repository().purge_published(cutoff),
+        "webhookDeliveries": get_webhook_delivery_repository().purge_finished(cutoff),
+        "jobRuns": get_job_run_repository().purge(cutoff),
+        "idempotencyRecords": get_idempotency_repository().purge(now - timedelta(seconds=settings.idempotency_ttl_seconds)),
+        "streamEvents": get_stream_event_repository().purge(now - timedelta(hours=settings.event_stream_retention_hours)),
+        "magicLinks": get_magic_link_repository().purge(cutoff),
+        "lockouts": get_lockout_repository().purge(cutoff),
+    }
+
+
+@cron_job(DATA_RETENTION_JOB, every=timedelta(days=1), lease=timedelta(hours=2))
+def apply_retention_policies() -> Dict[str, Any]:
+    """
+    Deletes or anonymizes each organization's telemetry, audit events and
+    messages past its retention periods (see app/retention), recording a
+    purge manifest per category.
+    """
+    now = datetime.now(timezone.utc)
+    totals = {"manifests": 0, "deleted": 0, "anonymized": 0, "failed": 0}
+    for tenant in _tenants():
+        with acting_for(tenant):
+            for manifest in apply_retention(now):
+                totals["manifests"] += 1
+                totals["failed"] += manifest.status == "failed"
+                totals["deleted" if manifest.action == "delete" else "anonymized"] += sum(manifest.counts.values())
+    return totals
+
+
+@cron_job(CLAIM_STATUS_JOB, every=timedelta(hours=1), lease=timedelta(minutes=30))
+def check_claim_statuses() -> Dict[str, Any]:
+    """
+    Asks the payers, through CLAIMS_PROVIDER, where the claims they have not
+    settled stand: each claim once every CLAIM_STATUS_CHECK_HOURS after it
+    was submitted. Emits `claim.status_changed` for the claims that moved.
+    """
+    clearinghouse = get_claims_clearinghouse()
+    if clearinghouse is None:
+        return {"checked": 0, "changed": 0, "failed": 0}
+    now = datetime.now(timezone.utc)
+    checked_before = now - timedelta(hours=settings.claim_status_check_hours)
+    claims, coverages, patients = get_claim_repository(), get_coverage_repository(), get_patient_repository()
+    totals = {"checked": 0, "changed": 0, "failed": 0}
+    for tenant in _tenants():
+        with acting_for(tenant):
+            provider = billing_provider(get_organization_repository().get(tenant) if tenant else None)
+            # Collected before any is updated, so the pages are not read while they move.
+            due = [
+                claim for claim in _claims_awaiting_payer(claims)
+                if (claim.status_checked_at or claim.submitted_at or claim.created_at) <= checked_before
+            ]
+            for claim in due:
+                totals["checked"] += 1
+                with unit_of_work():
+                    # Caught inside, so the claim keeps the time it was asked about and waits its turn.
+                    try:
+                        updated = refresh_claim_status(claim, claims, coverages, patients, clearinghouse, provider, now)
+                    except ClearinghouseError as e:
+                        logging.warning(f"Could not ask about claim {claim.claim_id}: {e}")
+                        totals["failed"] += 1
+                        continue
+                    if updated:
+                        get_event_publisher().emit("claim.status_changed", f"claims/{claim.claim_id}", updated)
+                        totals["changed"] += 1
+    return totals
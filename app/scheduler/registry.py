@@ -0,0 +1,47 @@
+# Location: app/scheduler/registry.py
+
+from dataclasses import dataclass
+from datetime import timedelta
+from typing import Any, Callable, Dict, List, Optional
+
+CronHandler = Callable[[], Optional[Dict[str, Any]]]
+
+
+@dataclass(frozen=True)
+class CronJob:
+    name: str
+    every: timedelta
+    handler: CronHandler
+    lease: timedelta
+
+
+_JOBS: Dict[str, CronJob] = {}
+
+
+def cron_job(name: str, every: timedelta, lease: timedelta = timedelta(minutes=10)) -> Callable[[CronHandler], CronHandler]:
+    """
+    Registers a recurring job:
+
+        @cron_job("retention-purge", every=timedelta(days=1))
+        def purge_operational_records() -> Dict[str, Any]: ...
+
+    `every` is how often the in-process ticker runs the job; with Cloud
+    Scheduler, the scheduler job's cron expression decides. The dict the
+    handler returns is stored on the run record. Runs hold the job's lock
+    for at most `lease`, so a handler must finish well within it, and must
+    be safe to run again after a crash part-way through.
+    """
+    def register(handler: CronHandler) -> CronHandler:
+        if name in _JOBS:
+            raise ValueError(f"Scheduled job '{name}' is already registered.")
+        _JOBS[name] = CronJob(name=name, every=every, handler=handler, lease=lease)
+        return handler
+    return register
+
+
+def get_job(name: str) -> Optional[CronJob]:
+    return _JOBS.get(name)
+
+
+def all_jobs() -> List[CronJob]:
+    return list(_JOBS.values())
@@ -0,0 +1,64 @@
+# Location: app/scheduler/runner.py
+
+import logging
+import os
+import socket
+from datetime import datetime, timezone
+from typing import Optional
+
+from app.api.v1 import schemas
+from app.core.metrics import SCHEDULED_JOB_RUNS_TOTAL
+from app.repositories.scheduler import JobLockRepository, JobRunRepository
+from app.scheduler.registry import get_job
+
+# Errors are stored on the run record; keep them short.
+MAX_ERROR_LENGTH = 500
+
+
+def default_owner() -> str:
+    """Identifies this worker process in lock and run records."""
+    return f"{socket.gethostname()}:{os.getpid()}"
+
+
+class JobRunner:
+    """
+    Runs registered jobs under their lock and records every run, so a job
+    triggered by several instances (or by both Cloud Scheduler and the
+    ticker) runs once at a time.
+    """
+
+    def __init__(self, locks: JobLockRepository, runs: JobRunRepository, owner: Optional[str] = None):
+        self.locks = locks
+        self.runs = runs
+        self.owner = owner or default_owner()
+
+    def run(self, name: str, trigger: str, only_if_due: bool = False) -> Optional[schemas.JobRun]:
+        """
+        Runs the job and returns its run record. A job whose lock is held is
+        recorded as skipped. With `only_if_due` (the ticker) a job that is
+        locked or ran less than its interval ago returns None and leaves no
+        record. Raises LookupError for unknown jobs.
+        """
+        job = get_job(name)
+        if job is None:
+            raise LookupError(f"Unknown scheduled job '{name}'.")
+        started_before = datetime.now(timezone.utc) - job.every if only_if_due else None
+        if not self.locks.acquire(name, self.owner, job.lease.total_seconds(), started_before):
+            if only_if_due:
+                return None
+            logging.info(f"Scheduled job {name} skipped; another run holds its lock")
+            SCHEDULED_JOB_RUNS_TOTAL.labels(job=name, result="skipped").inc()
+            return self.runs.record_skipped(name, trigger, self.owner, "Another run holds the job's lock.")
+
+        run = self.runs.start(name, trigger, self.owner)
+        try:
+            result = job.handler() or {}
+        except Exception as e:
+            logging.exception(f"Scheduled job {name} failed", extra={"report_error": True})
+            SCHEDULED_JOB_RUNS_TOTAL.labels(job=name, result="failed").inc()
+            return self.runs.finish(run.run_id, "failed", error=f"{type(e).__name__}: {e}"[:MAX_ERROR_LENGTH])
+        finally:
+            self.locks.release(name, self.owner)
+        logging.info(f"Scheduled job {name} finished: {result}")
+        SCHEDULED_JOB_RUNS_TOTAL.labels(job=name, result="succeeded").inc()
+        return self.runs.finish(run.run_id, "succeeded", result=result)
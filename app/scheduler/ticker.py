@@ -0,0 +1,32 @@
+# Location: app/scheduler/ticker.py
+
+import sys
+
+from app.core.worker import PollingWorker
+from app.scheduler.registry import all_jobs
+from app.scheduler.runner import JobRunner
+
+
+class SchedulerTicker(PollingWorker):
+    """
+    Runs registered jobs when they are due, from a thread in each worker,
+    as the alternative to Cloud Scheduler. Only useful when the instance
+    keeps CPU outside requests (Cloud Run "CPU always allocated"); otherwise
+    the thread is throttled between requests and jobs run late. Workers
+    share the job locks, so each due run happens once.
+    """
+    name = "scheduler-ticker"
+    # There is never a backlog; every tick waits for the next one.
+    batch_size = sys.maxsize
+
+    def __init__(self, runner: JobRunner):
+        super().__init__()
+        self.runner = runner
+
+    def drain(self) -> int:
+        """Runs every job that is due. Returns how many ran."""
+        ran = 0
+        for job in all_jobs():
+            if self.runner.run(job.name, "ticker", only_if_due=True):
+                ran += 1
+        return ran
@@ -0,0 +1,166 @@
+# Location: app/search/documents.py
+
+"""
+What the full-text index holds: one `IndexedDocument` per encounter, care
+plan, allergy and uploaded patient document, built from the resource in
+its API (camelCase) form so that events and the reindex command produce
+the same entries. Only descriptive text is indexed. Encrypted fields are
+never indexed (events carry them as null), and a patient document's file
+is not read, only its metadata.
+"""
+
+from dataclasses import dataclass
+from datetime import datetime
+from typing import Any, Dict, FrozenSet, List, Optional, Tuple
+
+from app.authz.roles import PATIENT_ID_CLAIM, grants, has_permission, permission
+from app.events.envelope import Event
+
+# The kinds of record indexed, with the resource whose read permission
+# (see app/authz/roles.py) lets a caller find them.
+KIND_RESOURCES: Dict[str, str] = {
+    "encounter": "encounters",
+    "care-plan": "care-plans",
+    "allergy": "patients.allergies",
+    "patient-document": "patients.documents",
+}
+
+# Event types by the kind of record they are about, and those that take it
+# out of the index.
+_EVENT_KINDS = {"encounter": "encounter", "care_plan": "care-plan", "allergy": "allergy", "document": "patient-document"}
+_REMOVALS = {"care_plan.deleted", "allergy.deleted", "document.deleted", "document.quarantined"}
+
+
+@dataclass(frozen=True)
+class IndexedDocument:
+    """An entry of the index. `subject` is the record's path relative to /api/v1."""
+
+    kind: str
+    record_id: str
+    patient_id: str
+    tenant_id: Optional[str]
+    subject: str
+    title: str
+    text: str
+    recorded_at: datetime
+
+    @property
+    def id(self) -> str:
+        return document_id(self.kind, self.record_id)
+
+
+def document_id(kind: str, record_id: str) -> str:
+    return f"{kind}/{record_id}"
+
+
+@dataclass(frozen=True)
+class Visibility:
+    """
+    The records a caller may find: every record of `kinds`, and those of
+    `own_kinds` that belong to `own_patient_id` (a patient's own records),
+    within `tenant_id` when it is set.
+    """
+
+    kinds: FrozenSet[str]
+    own_kinds: FrozenSet[str] = frozenset()
+    own_patient_id: Optional[str] = None
+    tenant_id: Optional[str] = None
+
+    @property
+    def empty(self) -> bool:
+        return not self.kinds and not (self.own_kinds and self.own_patient_id)
+
+
+def visibility(principal: Dict[str, Any], tenant_id: Optional[str]) -> Visibility:
+    """What the caller may find, by the read permissions their roles or scopes grant."""
+    full, own = grants(principal)
+    needed = {kind: permission(resource, "GET") for kind, resource in KIND_RESOURCES.items()}
+    kinds = frozenset(kind for kind, p in needed.items() if has_permission(full, p))
+    own_kinds = frozenset(kind for kind, p in needed.items() if kind not in kinds and has_permission(own, p))
+    own_patient_id = principal.get(PATIENT_ID_CLAIM) if own_kinds else None
+    return Visibility(kinds, own_kinds if own_patient_id else frozenset(), own_patient_id, tenant_id)
+
+
+def _join(*parts: Optional[str]) -> str:
+    return "\n".join(part for part in parts if part)
+
+
+def _displays(codings: List[Dict[str, Any]]) -> List[str]:
+    return [coding.get("display") or coding.get("code") for coding in codings if coding]
+
+
+def encounter_document(data: Dict[str, Any], subject: str, tenant_id: Optional[str]) -> IndexedDocument:
+    documents = [document.get("title") for document in data.get("documents") or []]
+    return IndexedDocument(
+        kind="encounter", record_id=data["encounterId"], patient_id=data["patientId"], tenant_id=tenant_id, subject=subject,
+        title=data.get("reason") or f"{data['visitType']} visit",
+        text=_join(data.get("reason"), data.get("location"), *documents),
+        recorded_at=datetime.fromisoformat(data["start"]),
+    )
+
+
+def care_plan_document(data: Dict[str, Any], subject: str, tenant_id: Optional[str]) -> IndexedDocument:
+    goals = [goal.get("description") for goal in data.get("goals") or []]
+    activities = [activity.get("description") for activity in data.get("activities") or []]
+    reasons = [change.get("reason") for change in data.get("statusHistory") or []]
+    return IndexedDocument(
+        kind="care-plan", record_id=data["carePlanId"], patient_id=data["patientId"], tenant_id=tenant_id, subject=subject,
+        title=data["title"],
+        text=_join(data.get("description"), data.get("category"), *goals, *activities, *reasons),
+        recorded_at=datetime.fromisoformat(data["createdAt"]),
+    )
+
+
+def allergy_document(data: Dict[str, Any], subject: str, tenant_id: Optional[str]) -> IndexedDocument:
+    reactions = data.get("reactions") or []
+    manifestations = [display for reaction in reactions for display in _displays(reaction.get("manifestations") or [])]
+    substance = data["substance"]
+    return IndexedDocument(
+        kind="allergy", record_id=data["allergyId"], patient_id=data["patientId"], tenant_id=tenant_id, subject=subject,
+        title=substance.get("display") or substance["code"],
+        text=_join(data.get("note"), *manifestations, *(reaction.get("note") for reaction in reactions)),
+        recorded_at=datetime.fromisoformat(data["createdAt"]),
+    )
+
+
+def patient_document_document(data: Dict[str, Any], subject: str, tenant_id: Optional[str]) -> IndexedDocument:
+    return IndexedDocument(
+        kind="patient-document", record_id=data["documentId"], patient_id=data["patientId"], tenant_id=tenant_id, subject=subject,
+        title=data["title"],
+        text=_join(data["type"].replace("-", " "), data.get("contentType")),
+        recorded_at=datetime.fromisoformat(data.get("uploadedAt") or data["createdAt"]),
+    )
+
+
+_BUILDERS = {
+    "encounter": encounter_document,
+    "care-plan": care_plan_document,
+    "allergy": allergy_document,
+    "patient-document": patient_document_document,
+}
+
+
+def indexable(kind: str, data: Dict[str, Any]) -> bool:
+    """Whether a record belongs in the index: not deleted, and for documents, uploaded and clean."""
+    if data.get("deletedAt"):
+        return False
+    return kind != "patient-document" or data.get("status") == "available"
+
+
+def build_document(kind: str, data: Dict[str, Any], subject: str, tenant_id: Optional[str]) -> IndexedDocument:
+    return _BUILDERS[kind](data, subject, tenant_id)
+
+
+def from_event(event: Event) -> Tuple[Optional[str], Optional[IndexedDocument]]:
+    """
+    What an event changes in the index: the ID of the entry to remove, or
+    the entry to add or replace; (None, None) for events about records that
+    are not indexed.
+    """
+    kind = _EVENT_KINDS.get(event.type.split(".", 1)[0])
+    if not kind:
+        return None, None
+    record_id = event.subject.rsplit("/", 1)[-1]
+    if event.type in _REMOVALS or not indexable(kind, event.data):
+        return document_id(kind, record_id), None
+    return None, build_document(kind, event.data, event.subject, event.tenant_id)
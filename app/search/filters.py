@@ -0,0 +1,223 @@
+# Location: app/search/filters.py
+
+"""
+Filters and sort order for list endpoints, given as query parameters:
+
+    GET /api/v1/patients?dob=ge1980-01-01&name=contains:smith&sort=-createdAt
+
+Each searchable resource declares its fields in a `SearchSpec`, next to its
+repository. A parameter named after one of those fields filters by it;
+its value may start with an operator:
+
+* Dates, times and numbers take a FHIR-style prefix: `eq` (the default),
+  `ne`, `gt`, `ge`, `lt` or `le`, e.g. `dob=lt1990-01-01`. A date given
+  for a time field means midnight UTC, as does a time without an offset.
+* Text takes `eq:` (the default), `ne:`, `contains:` or `sw:` (starts
+  with). `contains` and `sw` ignore case.
+* Codes take `eq:` or `ne:`; `a,b` matches any of the values.
+
+Repeated parameters must all match, e.g. `dob=ge1980-01-01&dob=lt1990-01-01`.
+`sort` names one sortable field, with a leading `-` to sort descending.
+A parsed `Search` is applied to a store's query by the repositories (see
+`apply_search` below and `FirestoreRepository._searched`), so the filters
+run in the database. Firestore cannot match text, so `contains` and `sw`
+are checked as its documents are read.
+"""
+
+import re
+from dataclasses import dataclass, field
+from datetime import date, datetime, timezone
+from typing import Any, Callable, Dict, Iterable, List, Literal, Optional, Tuple
+
+from fastapi import HTTPException, Query, Request, status
+
+FieldKind = Literal["text", "token", "date", "datetime", "number"]
+
+# Operators of a `Filter`, as the repositories' `where` takes them, plus the
+# two text matches.
+TEXT_OPERATORS = ("contains", "starts_with")
+_PREFIXES = {"eq": "==", "ne": "!=", "gt": ">", "ge": ">=", "lt": "<", "le": "<="}
+_TEXT_PREFIXES = {"eq": "==", "ne": "!=", "contains": "contains", "sw": "starts_with"}
+_ORDERED = re.compile(r"^(eq|ne|gt|ge|lt|le):?(?=.)")
+_NAMED = re.compile(r"^(eq|ne|contains|sw):")
+
+# Firestore's limit on the values of an `in` filter.
+MAX_VALUES = 30
+
+
+class SearchError(ValueError):
+    """Raised for a filter or sort the resource cannot be searched by."""
+
+
+@dataclass(frozen=True)
+class SearchField:
+    """
+    A field a resource can be searched by: the query parameter `name`, the
+    kind of its values and the stored fields (by alias) it reads. A text
+    field may read several, e.g. `name` matches the given or family name.
+    """
+
+    name: str
+    kind: FieldKind
+    paths: Tuple[str, ...] = ()
+    sortable: bool = False
+    choices: Tuple[str, ...] = ()
+
+    @property
+    def path(self) -> str:
+        return self.paths[0] if self.paths else self.name
+
+
+@dataclass(frozen=True)
+class Filter:
+    """A condition on stored fields: `op` is one of `where`'s operators or TEXT_OPERATORS."""
+
+    paths: Tuple[str, ...]
+    op: str
+    value: Any
+
+    def matches_text(self, data: Dict[str, Any]) -> bool:
+        """Whether a stored record (by alias) satisfies this text match, for stores that cannot run it."""
+        return any(text_matches(_read(data, path), self.op, self.value) for path in self.paths)
+
+
+@dataclass(frozen=True)
+class Sort:
+    path: str
+    descending: bool = False
+
+
+@dataclass(frozen=True)
+class Search:
+    filters: Tuple[Filter, ...] = ()
+    sort: Optional[Sort] = None
+
+
+def text_matches(value: Any, op: str, text: str) -> bool:
+    if not isinstance(value, str):
+        return False
+    value, text = value.casefold(), text.casefold()
+    return value.startswith(text) if op == "starts_with" else text in value
+
+
+def _read(data: Dict[str, Any], path: str) -> Any:
+    value: Any = data
+    for part in path.split("."):
+        if not isinstance(value, dict):
+            return None
+        value = value.get(part)
+    return value
+
+
+def _typed(search_field: SearchField, raw: str) -> Any:
+    kind, name = search_field.kind, search_field.name
+    try:
+        if kind == "date":
+            return date.fromisoformat(raw)
+        if kind == "datetime":
+            moment = datetime.fromisoformat(raw.replace("Z", "+00:00"))
+            return moment if moment.tzinfo else moment.replace(tzinfo=timezone.utc)
+        if kind == "number":
+            return float(raw)
+    except ValueError:
+        expected = {"date": "a date (YYYY-MM-DD)", "datetime": "a date or an ISO 8601 time", "number": "a number"}[kind]
+        raise SearchError(f"'{name}' must be {expected}, not '{raw}'")
+    if search_field.choices and raw not in search_field.choices:
+        raise SearchError(f"'{name}' must be one of: {', '.join(search_field.choices)}")
+    return raw
+
+
+@dataclass(frozen=True)
+class SearchSpec:
+    """The fields a resource can be filtered and sorted by, and its order when no `sort` is given."""
+
+    fields: Tuple[SearchField, ...]
+    default_sort: str
+    _by_name: Dict[str, SearchField] = field(init=False, repr=False, compare=False)
+
+    def __post_init__(self):
+        object.__setattr__(self, "_by_name", {f.name: f for f in self.fields})
+
+    @property
+    def names(self) -> List[str]:
+        return [f.name for f in self.fields]
+
+    @property
+    def sortable(self) -> List[str]:
+        return [f.name for f in self.fields if f.sortable]
+
+    def default(self) -> Search:
+        return Search(sort=self.parse_sort(self.default_sort))
+
+    def parse_sort(self, value: str) -> Sort:
+        descending = value.startswith("-")
+        search_field = self._by_name.get(value.lstrip("-"))
+        if not search_field or not search_field.sortable:
+            raise SearchError(f"Cannot sort by '{value.lstrip('-')}'; sort by one of: {', '.join(self.sortable)}")
+        return Sort(search_field.path, descending)
+
+    def parse_filter(self, name: str, raw: str) -> Filter:
+        search_field = self._by_name[name]
+        paths = search_field.paths or (search_field.name,)
+        if search_field.kind in ("text", "token"):
+            match = _NAMED.match(raw)
+            op = _TEXT_PREFIXES[match.group(1)] if match else "=="
+            value = raw[match.end():] if match else raw
+            if search_field.kind == "token" and op in TEXT_OPERATORS:
+                raise SearchError(f"'{name}' is a code; it takes eq: or ne:, not {match.group(1)}:")
+            if len(paths) > 1 and op not in TEXT_OPERATORS:
+                raise SearchError(f"'{name}' takes contains: or sw:")
+            if not value:
+                raise SearchError(f"'{name}' needs a value")
+            if search_field.kind == "token" and "," in value:
+                if op != "==":
+                    raise SearchError(f"'{name}' takes several values only with eq:")
+                values = [_typed(search_field, v) for v in value.split(",") if v]
+                if len(values) > MAX_VALUES:
+                    raise SearchError(f"'{name}' takes at most {MAX_VALUES} values")
+                return Filter(paths, "in", values)
+            return Filter(paths, op, value if op in TEXT_OPERATORS else _typed(search_field, value))
+        match = _ORDERED.match(raw)
+        op = _PREFIXES[match.group(1)] if match else "=="
+        return Filter(paths, op, _typed(search_field, raw[match.end():] if match else raw))
+
+    def parse(self, params: Iterable[Tuple[str, str]], sort: Optional[str] = None) -> Search:
+        """
+        Reads the filters among `params` (query parameters as name, value
+        pairs; others are ignored) and the `sort`. Raises SearchError.
+        """
+        filters = tuple(self.parse_filter(name, value) for name, value in params if name in self._by_name)
+        return Search(filters=filters, sort=self.parse_sort(sort or self.default_sort))
+
+
+def apply_search(query, search: Search):
+    """
+    Adds the filters and sort of `search` to a PostgreSQL `Query` or a
+    `MemoryQuery`, which take the same operators. Call before `start_after`,
+    which seeks in the query's order.
+    """
+    for condition in search.filters:
+        if condition.op in TEXT_OPERATORS:
+            query = query.where_text(condition.paths, condition.op, condition.value)
+        else:
+            query = query.where(condition.paths[0], condition.op, condition.value)
+    if search.sort:
+        query = query.order_by(search.sort.path, descending=search.sort.descending)
+    return query
+
+
+def search_query(spec: SearchSpec) -> Callable[..., Search]:
+    """Dependency reading the filters and `sort` of a list request; answers 400 for ones `spec` does not allow."""
+
+    def search(
+        request: Request,
+        sort: Optional[str] = Query(
+            None, description=f"Field to sort by, descending with a leading '-': one of {', '.join(spec.sortable)}. Default '{spec.default_sort}'.",
+        ),
+    ) -> Search:
+        try:
+            return spec.parse(request.query_params.multi_items(), sort)
+        except SearchError as e:
+            raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail=str(e))
+
+    return search
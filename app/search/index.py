@@ -0,0 +1,296 @@
+# Location: app/search/index.py
+
+"""
+The full-text index behind /search. SEARCH_INDEX picks the implementation:
+
+* `embedded`: an SQLite FTS5 table at SEARCH_INDEX_PATH, shared by the
+  workers of an instance when that is a file, and held by each worker in
+  memory otherwise. Each instance has its own, so it suits a single
+  instance or development; fill it with `python -m app.search.reindex`.
+* `elasticsearch`: an Elasticsearch (or OpenSearch) index shared by every
+  instance, named ELASTICSEARCH_INDEX.
+
+Entries are kept up to date from domain events (see
+app/search/publisher.py). Both implementations match every word of the
+query, the last one as a prefix, rank by BM25 with the title weighted
+double, and filter by the caller's `Visibility` in the index, so totals and
+pages only ever count records the caller may read.
+"""
+
+import html
+import json
+import re
+import sqlite3
+import threading
+from abc import ABC, abstractmethod
+from dataclasses import dataclass
+from datetime import datetime
+from functools import lru_cache
+from typing import Any, Dict, FrozenSet, List, Optional, Tuple
+
+import httpx
+
+from app.api.v1 import schemas
+from app.core.config import get_settings
+from app.core.http_client import new_client
+from app.search.documents import IndexedDocument, Visibility
+
+_WORD = re.compile(r"\w+")
+# Marks the matched words in SQLite's highlights until they are escaped.
+_OPEN, _CLOSE = "\x02", "\x03"
+
+
+class SearchIndexError(Exception):
+    """Raised when the index could not be reached or refused a request."""
+
+
+@dataclass(frozen=True)
+class SearchQuery:
+    """A search: its text, what the caller may see, and optional narrowing by kind and patient."""
+
+    text: str
+    visibility: Visibility
+    kinds: Optional[FrozenSet[str]] = None
+    patient_id: Optional[str] = None
+    limit: int = 20
+    offset: int = 0
+
+    @property
+    def words(self) -> List[str]:
+        return _WORD.findall(self.text)
+
+
+def _marked(fragment: str) -> str:
+    return html.escape(fragment).replace(_OPEN, "<mark>").replace(_CLOSE, "</mark>")
+
+
+class SearchIndex(ABC):
+    @abstractmethod
+    def upsert(self, documents: List[IndexedDocument]) -> None:
+        """Adds the entries, replacing those with the same ID. Raises SearchIndexError."""
+
+    @abstractmethod
+    def delete(self, document_ids: List[str]) -> None:
+        """Removes the entries; IDs not in the index are ignored. Raises SearchIndexError."""
+
+    @abstractmethod
+    def clear(self) -> None:
+        """Removes every entry, before a full reindex. Raises SearchIndexError."""
+
+    @abstractmethod
+    def search(self, query: SearchQuery) -> schemas.SearchResults:
+        """The page of matches the caller may see, best first. Raises SearchIndexError."""
+
+
+class EmbeddedSearchIndex(SearchIndex):
+    """An SQLite FTS5 index, folding case and diacritics."""
+
+    _COLUMNS = ("title", "text", "id", "kind", "record_id", "patient_id", "tenant_id", "subject", "recorded_at")
+
+    def __init__(self, path: str = ":memory:"):
+        self.lock = threading.Lock()
+        self.conn = sqlite3.connect(path, check_same_thread=False)
+        unindexed = ", ".join(f"{column} UNINDEXED" for column in self._COLUMNS[2:])
+        self.conn.execute(
+            f"CREATE VIRTUAL TABLE IF NOT EXISTS search_documents USING fts5(title, text, {unindexed}, tokenize = 'unicode61 remove_diacritics 2')"
+        )
+
+    def upsert(self, documents: List[IndexedDocument]) -> None:
+        rows = [
+            (d.title, d.text, d.id, d.kind, d.record_id, d.patient_id, d.tenant_id, d.subject, d.recorded_at.isoformat())
+            for d in documents
+        ]
+        with self.lock, self.conn:
+            self.conn.executemany("DELETE FROM search_documents WHERE id = ?", [(d.id,) for d in documents])
+            self.conn.executemany(f"INSERT INTO search_documents ({', '.join(self._COLUMNS)}) VALUES ({', '.join('?' * len(self._COLUMNS))})", rows)
+
+    def delete(self, document_ids: List[str]) -> None:
+        with self.lock, self.conn:
+            self.conn.executemany("DELETE FROM search_documents WHERE id = ?", [(i,) for i in document_ids])
+
+    def clear(self) -> None:
+        with self.lock, self.conn:
+            self.conn.execute("DELETE FROM search_documents")
+
+    @staticmethod
+    def _where(query: SearchQuery) -> Tuple[str, List[Any]]:
+        words = query.words
+        match = " ".join(f'"{word}"' for word in words[:-1]) + f' "{words[-1]}"*'
+        visible, params = [], [match.strip()]
+        seen = query.visibility
+        if seen.kinds:
+            visible.append(f"kind IN ({', '.join('?' * len(seen.kinds))})")
+            params.extend(sorted(seen.kinds))
+        if seen.own_kinds and seen.own_patient_id:
+            visible.append(f"(kind IN ({', '.join('?' * len(seen.own_kinds))}) AND patient_id = ?)")
+            params.extend([*sorted(seen.own_kinds), seen.own_patient_id])
+        clauses = ["search_documents MATCH ?", f"({' OR '.join(visible)})"]
+        if seen.tenant_id:
+            clauses.append("tenant_id = ?")
+            params.append(seen.tenant_id)
+        if query.kinds is not None:
+            clauses.append(f"kind IN ({', '.join('?' * len(query.kinds)) or 'NULL'})")
+            params.extend(sorted(query.kinds))
+        if query.patient_id:
+            clauses.append("patient_id = ?")
+            params.append(query.patient_id)
+        return " AND ".join(clauses), params
+
+    def search(self, query: SearchQuery) -> schemas.SearchResults:
+        if not query.words or query.visibility.empty:
+            return schemas.SearchResults(total=0, offset=query.offset, hits=[])
+        where, params = self._where(query)
+        with self.lock:
+            total = self.conn.execute(f"SELECT count(*) FROM search_documents WHERE {where}", params).fetchone()[0]
+            rows = self.conn.execute(
+                "SELECT kind, record_id, patient_id, subject, title, recorded_at, bm25(search_documents, 2.0, 1.0) AS rank,"
+                " highlight(search_documents, 0, char(2), char(3)), snippet(search_documents, 1, char(2), char(3), '…', 16)"
+                f" FROM search_documents WHERE {where} ORDER BY rank LIMIT ? OFFSET ?",
+                [*params, query.limit, query.offset],
+            ).fetchall()
+        hits = []
+        for kind, record_id, patient_id, subject, title, recorded_at, rank, title_marks, text_marks in rows:
+            highlights = {field: [_marked(marks)] for field, marks in (("title", title_marks), ("text", text_marks)) if marks and _OPEN in marks}
+            hits.append(schemas.SearchHit(
+                kind=kind, id=record_id, patientId=patient_id, subject=subject, title=title,
+                recordedAt=datetime.fromisoformat(recorded_at), score=-rank, highlights=highlights,
+            ))
+        return schemas.SearchResults(total=total, offset=query.offset, hits=hits)
+
+
+class ElasticsearchIndex(SearchIndex):
+    """
+    An Elasticsearch index, created with its mapping on first use. Writes
+    are visible to searches after the index's refresh interval (1s by
+    default).
+    """
+
+    _MAPPING = {
+        "mappings": {
+            "dynamic": "strict",
+            "properties": {
+                "kind": {"type": "keyword"},
+                "recordId": {"type": "keyword"},
+                "patientId": {"type": "keyword"},
+                "tenantId": {"type": "keyword"},
+                "subject": {"type": "keyword", "index": False},
+                "title": {"type": "text"},
+                "text": {"type": "text"},
+                "recordedAt": {"type": "date"},
+            },
+        },
+    }
+
+    def __init__(self, url: str, index: str, api_key: Optional[str] = None, timeout: float = 5.0, client: Optional[httpx.Client] = None):
+        headers = {"Authorization": f"ApiKey {api_key}"} if api_key else {}
+        self.base_url = url.rstrip("/")
+        self.index = index
+        self.client = client or new_client("elasticsearch", timeout, headers=headers)
+        self._ready = False
+
+    def _request(self, method: str, path: str, **kwargs) -> Dict[str, Any]:
+        try:
+            response = self.client.request(method, f"{self.base_url}/{path}", **kwargs)
+        except httpx.HTTPError as e:
+            raise SearchIndexError(f"Elasticsearch unreachable: {e}") from e
+        if response.status_code >= 300:
+            raise SearchIndexError(f"Elasticsearch answered {response.status_code} to {method} /{path}")
+        return response.json()
+
+    def _ensure_index(self) -> None:
+        if self._ready:
+            return
+        try:
+            response = self.client.put(f"{self.base_url}/{self.index}", json=self._MAPPING)
+        except httpx.HTTPError as e:
+            raise SearchIndexError(f"Elasticsearch unreachable: {e}") from e
+        exists = response.status_code == 400 and "resource_already_exists_exception" in response.text
+        if response.status_code >= 300 and not exists:
+            raise SearchIndexError(f"Elasticsearch answered {response.status_code} when creating index {self.index}")
+        self._ready = True
+
+    def _bulk(self, lines: List[Dict[str, Any]]) -> None:
+        if not lines:
+            return
+        self._ensure_index()
+        body = "".join(json.dumps(line) + "\n" for line in lines)
+        result = self._request("POST", "_bulk", content=body, headers={"Content-Type": "application/x-ndjson"})
+        if result.get("errors"):
+            failed = next((item for entry in result.get("items", []) for item in entry.values() if item.get("error")), {})
+            raise SearchIndexError(f"Elasticsearch refused {failed.get('_id')}: {failed.get('error', {}).get('reason')}")
+
+    def upsert(self, documents: List[IndexedDocument]) -> None:
+        lines = []
+        for d in documents:
+            lines.append({"index": {"_index": self.index, "_id": d.id}})
+            lines.append({
+                "kind": d.kind, "recordId": d.record_id, "patientId": d.patient_id, "tenantId": d.tenant_id,
+                "subject": d.subject, "title": d.title, "text": d.text, "recordedAt": d.recorded_at.isoformat(),
+            })
+        self._bulk(lines)
+
+    def delete(self, document_ids: List[str]) -> None:
+        self._bulk([{"delete": {"_index": self.index, "_id": document_id}} for document_id in document_ids])
+
+    def clear(self) -> None:
+        self._ensure_index()
+        self._request("POST", f"{self.index}/_delete_by_query", json={"query": {"match_all": {}}})
+
+    @staticmethod
+    def _filters(query: SearchQuery) -> List[Dict[str, Any]]:
+        seen = query.visibility
+        visible: List[Dict[str, Any]] = []
+        if seen.kinds:
+            visible.append({"terms": {"kind": sorted(seen.kinds)}})
+        if seen.own_kinds and seen.own_patient_id:
+            visible.append({"bool": {"filter": [{"terms": {"kind": sorted(seen.own_kinds)}}, {"term": {"patientId": seen.own_patient_id}}]}})
+        filters: List[Dict[str, Any]] = [{"bool": {"should": visible, "minimum_should_match": 1}}]
+        if seen.tenant_id:
+            filters.append({"term": {"tenantId": seen.tenant_id}})
+        if query.kinds is not None:
+            filters.append({"terms": {"kind": sorted(query.kinds)}})
+        if query.patient_id:
+            filters.append({"term": {"patientId": query.patient_id}})
+        return filters
+
+    def search(self, query: SearchQuery) -> schemas.SearchResults:
+        if not query.words or query.visibility.empty:
+            return schemas.SearchResults(total=0, offset=query.offset, hits=[])
+        self._ensure_index()
+        body = {
+            "query": {"bool": {
+                "must": [{"multi_match": {"query": " ".join(query.words), "type": "bool_prefix", "operator": "and", "fields": ["title^2", "text"]}}],
+                "filter": self._filters(query),
+            }},
+            "from": query.offset,
+            "size": query.limit,
+            "track_total_hits": True,
+            "highlight": {
+                "encoder": "html", "pre_tags": ["<mark>"], "post_tags": ["</mark>"],
+                "fields": {"title": {"number_of_fragments": 0}, "text": {"fragment_size": 150, "number_of_fragments": 3}},
+            },
+        }
+        result = self._request("POST", f"{self.index}/_search", json=body)
+        hits = []
+        for hit in result["hits"]["hits"]:
+            source = hit["_source"]
+            hits.append(schemas.SearchHit(
+                kind=source["kind"], id=source["recordId"], patientId=source["patientId"], subject=source["subject"],
+                title=source["title"], recordedAt=source["recordedAt"], score=hit.get("_score") or 0.0,
+                highlights=hit.get("highlight") or {},
+            ))
+        return schemas.SearchResults(total=result["hits"]["total"]["value"], offset=query.offset, hits=hits)
+
+
+@lru_cache
+def get_search_index() -> Optional[SearchIndex]:
+    """Returns this worker's index, or None with SEARCH_INDEX=none."""
+    settings = get_settings()
+    if settings.search_index == "embedded":
+        return EmbeddedSearchIndex(settings.search_index_path)
+    if settings.search_index == "elasticsearch":
+        return ElasticsearchIndex(
+            settings.elasticsearch_url, settings.elasticsearch_index,
+            api_key=settings.elasticsearch_api_key, timeout=settings.search_timeout_seconds,
+        )
+    return None
@@ -0,0 +1,45 @@
+# Location: app/search/publisher.py
+
+import logging
+
+from app.events.envelope import Event
+from app.events.publisher import EventPublisher
+from app.repositories.transactions import after_commit
+from app.search.documents import from_event
+from app.search.index import SearchIndex, SearchIndexError
+
+
+def index_event(index: SearchIndex, event: Event) -> None:
+    """
+    Applies the event to the index. Indexing is best-effort: a failure is
+    logged, and the entry stays stale until the record changes again or
+    the index is rebuilt with `python -m app.search.reindex`.
+    """
+    removed, document = from_event(event)
+    try:
+        if document:
+            index.upsert([document])
+        elif removed:
+            index.delete([removed])
+    except SearchIndexError:
+        logging.exception(f"Failed to index {event.type} for {event.subject}")
+
+
+class SearchIndexEventPublisher(EventPublisher):
+    """
+    Updates the full-text index from the event once the change it describes
+    has committed, so rolled-back changes are never searchable, then hands
+    the event on to `inner`, failing as it does.
+    """
+
+    def __init__(self, inner: EventPublisher, index: SearchIndex):
+        self.inner = inner
+        self.index = index
+
+    @property
+    def transactional(self) -> bool:
+        return self.inner.transactional
+
+    def publish(self, event: Event) -> None:
+        after_commit(lambda: index_event(self.index, event))
+        self.inner.publish(event)
@@ -0,0 +1,104 @@
+# Location: app/search/reindex.py
+
+"""
+Rebuilds the full-text index from the records, for a new index, an
+embedded index after a restart, or to repair entries that missed their
+events (see app/search/publisher.py):
+
+    python -m app.search.reindex [--clear]
+
+Entries are replaced record by record, so search keeps working while it
+runs; `--clear` empties the index first, which also drops the entries of
+records deleted while indexing was failing. The records of deleted
+patients are not indexed. An embedded index must be at a file
+(SEARCH_INDEX_PATH) for the command to fill the API's index.
+"""
+
+import logging
+import sys
+from typing import Callable, Dict, Iterator, List, Optional, Tuple, TypeVar
+
+from pydantic import BaseModel
+
+from app.api.v1 import deps
+from app.core.config import get_settings
+from app.search.documents import build_document, indexable
+from app.search.index import SearchIndex, get_search_index
+from app.tenancy.context import acting_for
+
+PAGE_SIZE = 100
+# Allergies and documents are listed per patient without paging; no patient
+# has this many.
+MAX_PER_PATIENT = 1000
+
+T = TypeVar("T")
+
+
+def _pages(fetch: Callable[[Optional[str]], List[T]], id_of: Callable[[T], str]) -> Iterator[T]:
+    after = None
+    while True:
+        page = fetch(after)
+        yield from page
+        if len(page) < PAGE_SIZE:
+            return
+        after = id_of(page[-1])
+
+
+def _tenants() -> Iterator[Optional[str]]:
+    if not get_settings().tenancy_enabled:
+        yield None
+        return
+    organizations = deps.get_organization_repository()
+    for organization in _pages(lambda after: organizations.list(limit=PAGE_SIZE, after=after), lambda o: o.organization_id):
+        yield organization.organization_id
+
+
+def _patient_records(patient_id: str) -> Iterator[Tuple[str, str, BaseModel]]:
+    """The patient's indexable records, as (kind, subject, record)."""
+    encounters, care_plans = deps.get_encounter_repository(), deps.get_care_plan_repository()
+    for encounter in _pages(lambda after: encounters.list(patient_id=patient_id, limit=PAGE_SIZE, after=after), lambda e: e.encounter_id):
+        yield "encounter", f"encounters/{encounter.encounter_id}", encounter
+    for care_plan in _pages(lambda after: care_plans.list(patient_id, limit=PAGE_SIZE, after=after), lambda c: c.care_plan_id):
+        yield "care-plan", f"care-plans/{care_plan.care_plan_id}", care_plan
+    for allergy in deps.get_allergy_repository().list(patient_id, limit=MAX_PER_PATIENT):
+        yield "allergy", f"patients/{patient_id}/allergies/{allergy.allergy_id}", allergy
+    for document in deps.get_patient_document_repository().list(patient_id, status="available", limit=MAX_PER_PATIENT):
+        yield "patient-document", f"patients/{patient_id}/documents/{document.document_id}", document
+
+
+def reindex(index: SearchIndex, clear: bool = False) -> Dict[str, int]:
+    """Indexes every record, one patient at a time. Returns the counts by kind."""
+    if clear:
+        index.clear()
+    counts: Dict[str, int] = {}
+    patients = deps.get_patient_repository()
+    for tenant in _tenants():
+        with acting_for(tenant):
+            for patient in _pages(lambda after: patients.list(limit=PAGE_SIZE, after=after), lambda p: p.patient_id):
+                documents = []
+                for kind, subject, record in _patient_records(patient.patient_id):
+                    data = record.model_dump(mode="json", by_alias=True)
+                    if indexable(kind, data):
+                        documents.append(build_document(kind, data, subject, tenant))
+                        counts[kind] = counts.get(kind, 0) + 1
+                if documents:
+                    index.upsert(documents)
+    return counts
+
+
+def main(argv: List[str]) -> int:
+    if argv not in ([], ["--clear"]):
+        print("usage: python -m app.search.reindex [--clear]", file=sys.stderr)
+        return 2
+    index = get_search_index()
+    if index is None:
+        print("error: full-text search is off (SEARCH_INDEX=none)", file=sys.stderr)
+        return 1
+    counts = reindex(index, clear=argv == ["--clear"])
+    print(f"Indexed {sum(counts.values())} record(s): " + ", ".join(f"{n} {kind}" for kind, n in sorted(counts.items())))
+    return 0
+
+
+if __name__ == "__main__":
+    logging.basicConfig(level=logging.INFO)
+    sys.exit(main(sys.argv[1:]))
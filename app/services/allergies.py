@@ -0,0 +1,28 @@
+# Location: app/services/allergies.py
+
+from typing import Iterable, List
+
+from app.api.v1 import schemas
+from app.repositories.allergies import AllergyRepository
+
+# Records with these verification statuses were recorded by mistake or ruled
+# out, so they must not block treatment.
+DISREGARDED_VERIFICATION_STATUSES = {"refuted", "entered-in-error"}
+
+
+def is_relevant(allergy: schemas.Allergy) -> bool:
+    """True if the record describes a current, not-disproven reaction risk."""
+    return allergy.clinical_status == "active" and allergy.verification_status not in DISREGARDED_VERIFICATION_STATUSES
+
+
+def find_allergy_conflicts(repo: AllergyRepository, patient_id: str, substances: Iterable[schemas.Coding]) -> List[schemas.Allergy]:
+    """
+    Safety check for other modules (e.g. before adding a medication): returns
+    the patient's relevant allergies to any of `substances`, matched on both
+    code system and code, highest criticality first.
+    """
+    conflicts = []
+    for substance in substances:
+        matches = repo.list(patient_id, substance_code=substance.code, substance_system=substance.system, clinical_status="active")
+        conflicts.extend(a for a in matches if is_relevant(a))
+    return sorted(conflicts, key=lambda a: a.criticality != "high")
@@ -0,0 +1,26 @@
+# Location: app/services/appointments.py
+
+from app.services.state_machine import InvalidTransitionError, StateMachine
+
+# --- Appointment Status Transitions ---
+# booked -> arrived -> fulfilled, and either open state can be cancelled.
+# "fulfilled" and "cancelled" are terminal.
+APPOINTMENT_STATUSES = StateMachine("appointment", {
+    "booked": {"arrived", "cancelled"},
+    "arrived": {"fulfilled", "cancelled"},
+    "fulfilled": set(),
+    "cancelled": set(),
+})
+
+# States in which an appointment still occupies the practitioner's calendar.
+ACTIVE_STATUSES = {"booked", "arrived"}
+
+
+def ensure_transition(current: str, target: str) -> None:
+    """Raises InvalidTransitionError unless `current` -> `target` is a legal move."""
+    APPOINTMENT_STATUSES.ensure(current, target)
+
+
+def can_reschedule(status: str) -> bool:
+    """Only appointments that have not started yet can be moved."""
+    return status == "booked"
@@ -0,0 +1,267 @@
+# Location: app/services/bulk_import.py
+
+import json
+import logging
+from datetime import datetime, timezone
+from typing import Any, Dict, Iterable, Iterator, List, Optional, Tuple
+
+from pydantic import ValidationError
+
+from app.api.v1 import schemas
+from app.core.storage import delete_blob, read_blob, upload_blob
+from app.events.publisher import EventPublisher
+from app.repositories.base import ConflictError
+from app.repositories.import_jobs import ImportJobRepository
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.transactions import unit_of_work
+from app.services.vitals import VitalValidationError, build_vital_observation
+
+# --- Bulk Import ---
+# An import file has one JSON object per line, with a `resourceType` of
+# "Patient" (the fields of PatientCreate) or "Observation" (the fields of
+# VitalSignCreate, plus `patientId` or `patientMrn` and an optional source
+# `identifier`). The file is worked through a chunk of lines per task run;
+# lines that cannot be imported are written to the job's error report and
+# the rest of the file is still imported.
+NDJSON_CONTENT_TYPES = {"application/x-ndjson", "application/ndjson"}
+# Longer lines are refused without being parsed.
+MAX_LINE_BYTES = 1024 * 1024
+
+
+class ImportLineRejected(ValueError):
+    """Raised when a line of an import file cannot be imported."""
+
+
+def input_blob_name(job_id: str) -> str:
+    return f"imports/{job_id}/input.ndjson"
+
+
+def error_blob_prefix(job_id: str) -> str:
+    return f"imports/{job_id}/errors/"
+
+
+def error_blob_name(job_id: str, first_line: int) -> str:
+    """One report file per chunk, named so that listing them returns them in line order."""
+    return f"{error_blob_prefix(job_id)}{first_line:09d}.ndjson"
+
+
+def import_identifier_system(job_id: str) -> str:
+    """Identifies observations imported without a source identifier, together with their line number."""
+    return f"urn:megacare:import:{job_id}"
+
+
+def _validation_text(e: ValidationError) -> str:
+    # Only the field and the problem: the report must not repeat the values.
+    return "; ".join(f"{'.'.join(str(p) for p in err['loc'])}: {err['msg']}" for err in e.errors())
+
+
+def _lines(chunks: Iterable[bytes], start: int) -> Iterator[Tuple[int, Optional[bytes]]]:
+    """
+    Yields each line of the file read from byte `start`, with the offset just
+    past it. A line longer than MAX_LINE_BYTES is yielded as None, without
+    being held in memory.
+    """
+    offset, buffer, overlong = start, b"", False
+    for chunk in chunks:
+        buffer += chunk
+        while (end := buffer.find(b"\n")) >= 0:
+            line, buffer = buffer[:end], buffer[end + 1:]
+            offset += end + 1
+            yield offset, None if overlong or len(line) > MAX_LINE_BYTES else line
+            overlong = False
+        if len(buffer) > MAX_LINE_BYTES:
+            offset, buffer, overlong = offset + len(buffer), b"", True
+    if buffer or overlong:
+        yield offset + len(buffer), None if overlong else buffer
+
+
+class _Importer:
+    """
+    Imports the lines of one chunk. A chunk that is being run again after a
+    run that did not finish (`rerun_since`, when that run started) may find
+    its records already stored; those count as imported rather than failing.
+    """
+
+    def __init__(
+        self,
+        job_id: str,
+        patients: PatientRepository,
+        observations: ObservationRepository,
+        events: EventPublisher,
+        rerun_since: Optional[datetime],
+    ):
+        self.job_id = job_id
+        self.patients = patients
+        self.observations = observations
+        self.events = events
+        self.rerun_since = rerun_since
+        self.imported: Dict[str, int] = {}
+        self.skipped = 0
+        self.errors: List[schemas.ImportLineError] = []
+
+    def _stored_by_earlier_run(self, created_at: datetime) -> bool:
+        return self.rerun_since is not None and created_at >= self.rerun_since
+
+    def add(self, line_number: int, line: Optional[bytes]) -> None:
+        if line is None:
+            self.errors.append(schemas.ImportLineError(line=line_number, error=f"Line is longer than {MAX_LINE_BYTES} bytes"))
+            return
+        if not line.strip():
+            return
+        resource_type = None
+        try:
+            record = self._parse(line)
+            resource_type = record.pop("resourceType", None)
+            if resource_type == "Patient":
+                imported = self._import_patient(record)
+            elif resource_type == "Observation":
+                imported = self._import_observation(record, line_number)
+            else:
+                raise ImportLineRejected("resourceType must be 'Patient' or 'Observation'")
+        except ImportLineRejected as e:
+            self.errors.append(schemas.ImportLineError(line=line_number, resource_type=resource_type, error=str(e)))
+            return
+        if imported:
+            self.imported[resource_type] = self.imported.get(resource_type, 0) + 1
+        else:
+            self.skipped += 1
+
+    @staticmethod
+    def _parse(line: bytes) -> Dict[str, Any]:
+        try:
+            record = json.loads(line)
+        except UnicodeDecodeError:
+            raise ImportLineRejected("Line is not valid UTF-8")
+        except json.JSONDecodeError as e:
+            raise ImportLineRejected(f"Line is not valid JSON: {e.msg} at column {e.colno}")
+        if not isinstance(record, dict):
+            raise ImportLineRejected("Each line must be a JSON object")
+        return record
+
+    def _import_patient(self, record: Dict[str, Any]) -> bool:
+        try:
+            patient_in = schemas.PatientCreate.model_validate(record)
+        except ValidationError as e:
+            raise ImportLineRejected(_validation_text(e))
+        try:
+            with unit_of_work():
+                patient = self.patients.create(patient_in)
+                self.events.emit("patient.created", f"patients/{patient.patient_id}", patient)
+        except ConflictError as e:
+            existing = self.patients.get_by_mrn(patient_in.mrn)
+            if existing and self._stored_by_earlier_run(existing.created_at):
+                return True
+            raise ImportLineRejected(str(e))
+        return True
+
+    def _patient(self, record: Dict[str, Any]) -> schemas.Patient:
+        patient_id, mrn = record.pop("patientId", None), record.pop("patientMrn", None)
+        if bool(patient_id) == bool(mrn):
+            raise ImportLineRejected("Exactly one of patientId and patientMrn is required")
+        patient = self.patients.get(patient_id) if patient_id else self.patients.get_by_mrn(str(mrn))
+        if not patient:
+            raise ImportLineRejected("Patient not found")
+        return patient
+
+    def _import_observation(self, record: Dict[str, Any], line_number: int) -> bool:
+        patient = self._patient(record)
+        source_identifier = record.pop("identifier", None)
+        try:
+            vital_in = schemas.VitalSignCreate.model_validate(record)
+            identifier = (
+                schemas.Identifier.model_validate(source_identifier) if source_identifier is not None
+                else schemas.Identifier(system=import_identifier_system(self.job_id), value=str(line_number))
+            )
+        except ValidationError as e:
+            raise ImportLineRejected(_validation_text(e))
+        try:
+            observation_in = build_vital_observation(patient.patient_id, vital_in)
+        except VitalValidationError as e:
+            raise ImportLineRejected(str(e))
+
+        existing = self.observations.find_by_identifier(identifier.system, identifier.value)
+        if existing:
+            # Either this chunk stored it before being run again, or an earlier import did.
+            return any(self._stored_by_earlier_run(o.created_at) for o in existing)
+        with unit_of_work():
+            observation = self.observations.create(observation_in.model_copy(update={"identifier": identifier}))
+            self.events.emit("observation.recorded", f"patients/{patient.patient_id}/observations/{observation.observation_id}", observation)
+        return True
+
+
+def _error_report(errors: List[schemas.ImportLineError]) -> bytes:
+    return "".join(error.model_dump_json(by_alias=True, exclude_none=True) + "\n" for error in errors).encode()
+
+
+def import_chunk(
+    job_id: str,
+    offset: int,
+    jobs: ImportJobRepository,
+    patients: PatientRepository,
+    observations: ObservationRepository,
+    events: EventPublisher,
+    bucket_name: str,
+    chunk_lines: int,
+) -> Optional[int]:
+    """
+    Imports up to `chunk_lines` lines of the job's file from byte `offset`
+    and saves the job's progress. Returns the offset of the next chunk, or
+    None once the file is done (the uploaded file is then deleted), the job
+    has been cancelled (the file is deleted then too), or `offset` is not
+    where the job is up to (a task that has already run). Errors reading
+    the file or storing records are raised so that the chunk is run again.
+    """
+    job = jobs.get(job_id)
+    if job and job.status == "cancelled":
+        delete_blob(bucket_name, input_blob_name(job_id))
+    if not job or job.status not in ("accepted", "in-progress") or job.offset != offset:
+        return None
+    # chunkStartedAt is only still set if a run of this chunk did not finish.
+    rerun_since = job.chunk_started_at
+    jobs.update(job_id, {"status": "in-progress", "chunkStartedAt": rerun_since or datetime.now(timezone.utc)})
+
+    from google.api_core.exceptions import NotFound
+
+    importer = _Importer(job_id, patients, observations, events, rerun_since)
+    first_line = job.lines_read + 1
+    line_number, next_offset, done = job.lines_read, offset, True
+    try:
+        for next_offset, line in _lines(read_blob(bucket_name, input_blob_name(job_id), start=offset), offset):
+            line_number += 1
+            importer.add(line_number, line)
+            if line_number - job.lines_read >= chunk_lines:
+                done = next_offset >= job.size
+                break
+    except NotFound:
+        logging.error(f"Bulk import {job_id} stopped: its uploaded file is missing.", extra={"report_error": True})
+        jobs.update(job_id, {"status": "error", "error": "The uploaded file is missing", "chunkStartedAt": None})
+        return None
+    if importer.errors:
+        upload_blob(bucket_name, error_blob_name(job_id, first_line), _error_report(importer.errors), "application/x-ndjson")
+
+    imported = dict(job.imported)
+    for resource_type, count in importer.imported.items():
+        imported[resource_type] = imported.get(resource_type, 0) + count
+    changes = {
+        "offset": next_offset,
+        "linesRead": line_number,
+        "imported": imported,
+        "skipped": job.skipped + importer.skipped,
+        "failed": job.failed + len(importer.errors),
+        "chunkStartedAt": None,
+    }
+    cancelled = jobs.get(job_id).status == "cancelled"
+    if done and not cancelled:
+        changes.update({"status": "completed", "completedAt": datetime.now(timezone.utc)})
+    jobs.update(job_id, changes)
+
+    if cancelled:
+        delete_blob(bucket_name, input_blob_name(job_id))
+        logging.info(f"Bulk import {job_id} was cancelled after line {line_number}.")
+        return None
+    if done:
+        delete_blob(bucket_name, input_blob_name(job_id))
+        logging.info(f"Bulk import {job_id} completed: {sum(imported.values())} imported, {changes['skipped']} skipped, {changes['failed']} failed.")
+        return None
+    return next_offset
@@ -0,0 +1,14 @@
+# Location: app/services/care_plans.py
+
+from app.services.state_machine import StateMachine
+
+# --- Care Plan Status Transitions ---
+# A plan is drafted, put into effect, may be paused, and ends either
+# completed or revoked. "completed" and "revoked" are terminal.
+CARE_PLAN_STATUSES = StateMachine("care plan", {
+    "draft": {"active", "revoked"},
+    "active": {"on-hold", "completed", "revoked"},
+    "on-hold": {"active", "completed", "revoked"},
+    "completed": set(),
+    "revoked": set(),
+})
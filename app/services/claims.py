@@ -0,0 +1,124 @@
+# Location: app/services/claims.py
+
+import hashlib
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional
+
+from app.api.v1 import schemas
+from app.services.state_machine import StateMachine
+
+# --- Claim Status Transitions ---
+# A draft is queued to be sent, and is submitted once the clearinghouse has
+# taken it. The payer accepts it into adjudication, then pays or denies it;
+# payers that are quick may report the outcome first. A claim rejected by
+# the clearinghouse or payer is corrected and queued again, or voided, which
+# frees its charge items to be billed on another claim. "paid", "denied"
+# and "void" are terminal.
+CLAIM_STATUSES = StateMachine("claim", {
+    "draft": {"queued", "void"},
+    "queued": {"submitted", "rejected"},
+    "submitted": {"accepted", "rejected", "paid", "denied"},
+    "accepted": {"paid", "denied"},
+    "rejected": {"queued", "void"},
+    "paid": set(),
+    "denied": set(),
+    "void": set(),
+})
+
+# The claims the payer is asked about until they are settled.
+AWAITING_PAYER = ("submitted", "accepted")
+
+# CMS place of service codes by visit type: office, telehealth in the
+# patient's home, home, inpatient hospital and emergency room.
+PLACES_OF_SERVICE = {"ambulatory": "11", "virtual": "10", "home": "12", "inpatient": "21", "emergency": "23"}
+
+# An 837P carries up to 12 diagnoses, each line pointing at up to 4 of them.
+MAX_DIAGNOSES = 12
+
+
+class InvalidClaimError(ValueError):
+    """Raised when a claim cannot be built, or its lines adjudicated, as asked."""
+
+
+def patient_control_number(claim_id: str) -> str:
+    """The claim's reference with the payer (CLM01): 20 characters, the same on every submission."""
+    return hashlib.sha256(claim_id.encode()).hexdigest()[:20].upper()
+
+
+def build_claim(
+    encounter: schemas.Encounter,
+    coverage: schemas.Coverage,
+    charges: List[schemas.ChargeItem],
+    filing_code: str,
+) -> Dict[str, Any]:
+    """
+    The fields of a draft claim billing the charge items. The diagnoses of
+    all items are listed once, in the order they first appear, and each
+    line points at its own. Raises InvalidClaimError.
+    """
+    if not charges:
+        raise InvalidClaimError("There are no billable charge items to bill")
+    diagnoses: List[str] = []
+    for charge in charges:
+        diagnoses.extend(code for code in charge.diagnosis_codes if code not in diagnoses)
+    if len(diagnoses) > MAX_DIAGNOSES:
+        raise InvalidClaimError(f"A claim lists at most {MAX_DIAGNOSES} diagnoses; bill the charge items on more than one claim")
+    lines = [
+        schemas.ClaimLine(
+            charge_item_id=charge.charge_item_id,
+            procedure_code=charge.procedure_code,
+            modifiers=charge.modifiers,
+            diagnosis_pointers=[diagnoses.index(code) + 1 for code in charge.diagnosis_codes],
+            units=charge.units,
+            charge_cents=charge.charge_cents,
+            service_date=charge.service_date,
+            practitioner_id=charge.practitioner_id,
+        )
+        for charge in sorted(charges, key=lambda charge: (charge.service_date, charge.created_at))
+    ]
+    return {
+        "encounterId": encounter.encounter_id,
+        "patientId": encounter.patient_id,
+        "coverageId": coverage.coverage_id,
+        "payerId": coverage.payer_id,
+        "payerName": coverage.payer_name,
+        "filingCode": filing_code,
+        "placeOfService": PLACES_OF_SERVICE[encounter.visit_type],
+        "diagnosisCodes": diagnoses,
+        "lines": [line.model_dump(by_alias=True) for line in lines],
+        "totalChargeCents": sum(line.charge_cents for line in lines),
+    }
+
+
+def adjudicated_lines(claim: schemas.Claim, adjudications: List[schemas.ClaimLineAdjudication]) -> List[schemas.ClaimLine]:
+    """The claim's lines with what the payer paid and adjusted on each. Raises InvalidClaimError."""
+    by_item = {adjudication.charge_item_id: adjudication for adjudication in adjudications}
+    unknown = set(by_item) - {line.charge_item_id for line in claim.lines}
+    if unknown:
+        raise InvalidClaimError(f"Not lines of this claim: {', '.join(sorted(unknown))}")
+    return [
+        line.model_copy(update={"paid_cents": by_item[line.charge_item_id].paid_cents, "adjustments": by_item[line.charge_item_id].adjustments})
+        if line.charge_item_id in by_item else line
+        for line in claim.lines
+    ]
+
+
+def patient_responsibility(lines: List[schemas.ClaimLine]) -> Optional[int]:
+    """What the patient owes by the payer's PR adjustments, or None if no line was adjudicated."""
+    if all(line.paid_cents is None and not line.adjustments for line in lines):
+        return None
+    return sum(adjustment.amount_cents for line in lines for adjustment in line.adjustments if adjustment.group == "PR")
+
+
+def status_changes(claim: schemas.Claim, status: str, by: str, changes: Optional[Dict[str, Any]] = None, note: Optional[str] = None) -> Dict[str, Any]:
+    """
+    The changes moving the claim to `status`, with the move added to its
+    history. Raises InvalidTransitionError.
+    """
+    CLAIM_STATUSES.ensure(claim.status, status)
+    change = schemas.ClaimStatusChange(status=status, at=datetime.now(timezone.utc), by=by, note=note)
+    return {
+        **(changes or {}),
+        "status": status,
+        "history": [entry.model_dump(by_alias=True) for entry in claim.history] + [change.model_dump(by_alias=True)],
+    }
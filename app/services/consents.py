@@ -0,0 +1,65 @@
+# Location: app/services/consents.py
+
+from datetime import datetime, timezone
+from typing import List, Optional
+
+from app.api.v1 import schemas
+from app.repositories.consents import ConsentDocumentRepository, ConsentRepository
+
+
+class ConsentRequiredError(Exception):
+    """Raised by `require_consent` when the patient has not consented to the requested use."""
+
+    def __init__(self, decision: schemas.ConsentDecision):
+        self.decision = decision
+        super().__init__(f"Consent required ({decision.reason}).")
+
+
+def minimum_valid_version(documents: List[schemas.ConsentDocument]) -> int:
+    """The oldest document version a consent may refer to: the latest material change, or 1."""
+    return max((d.version for d in documents if d.material_change), default=1)
+
+
+def check_consent(
+    consents: ConsentRepository,
+    documents: ConsentDocumentRepository,
+    patient_id: str,
+    scope: str,
+    organization_id: Optional[str] = None,
+    now: Optional[datetime] = None,
+) -> schemas.ConsentDecision:
+    """
+    Decides whether the patient's data may be used for `scope` (and, for
+    data sharing, released to `organization_id`). A consent counts only if it
+    is active, not expired, and was given to a document version at or after
+    the last material change of the consent text.
+    """
+    now = now or datetime.now(timezone.utc)
+    active = consents.list(patient_id, scope=scope, organization_id=organization_id, status="active")
+    current = [c for c in active if not c.expires_at or c.expires_at > now]
+    if not current:
+        reason = "expired" if active else "no-consent"
+        return schemas.ConsentDecision(allowed=False, reason=reason)
+
+    required_version = minimum_valid_version(documents.list(scope))
+    valid = [c for c in current if c.document_version >= required_version]
+    if not valid:
+        return schemas.ConsentDecision(allowed=False, reason="reconsent-required", consent_id=current[0].consent_id)
+    return schemas.ConsentDecision(allowed=True, reason="consented", consent_id=valid[0].consent_id)
+
+
+def require_consent(
+    consents: ConsentRepository,
+    documents: ConsentDocumentRepository,
+    patient_id: str,
+    scope: str,
+    organization_id: Optional[str] = None,
+) -> schemas.ConsentDecision:
+    """
+    For handlers that release data: raises ConsentRequiredError unless
+    `check_consent` allows the release. Handlers usually map it to a 403.
+    """
+    decision = check_consent(consents, documents, patient_id, scope, organization_id)
+    if not decision.allowed:
+        raise ConsentRequiredError(decision)
+    return decision
@@ -0,0 +1,56 @@
+# Location: app/services/encounters.py
+
+from typing import List
+
+from app.api.v1 import schemas
+from app.services.state_machine import StateMachine
+
+# --- Encounter Status Transitions ---
+# "finished" and "cancelled" are terminal. Results that arrive after a visit
+# can still be linked to a finished encounter; cancelled ones accept nothing.
+ENCOUNTER_STATUSES = StateMachine("encounter", {
+    "planned": {"in-progress", "cancelled"},
+    "in-progress": {"finished", "cancelled"},
+    "finished": set(),
+    "cancelled": set(),
+})
+
+# --- Discharge Summaries ---
+DISCHARGE_SUMMARY_TITLE = "Discharge summary"
+
+
+def _observation_value(observation: schemas.Observation) -> str:
+    if observation.value is not None:
+        return f"{observation.value:g} {observation.unit or ''}".strip()
+    return ", ".join(
+        f"{component.code.display or component.code.code} {component.value:g} {component.unit or ''}".strip()
+        for component in observation.components if component.value is not None
+    ) or "no value"
+
+
+def discharge_summary(encounter: schemas.Encounter, patient: schemas.Patient, observations: List[schemas.Observation]) -> str:
+    """Renders the plain-text summary attached to an encounter when it is finished."""
+    end = encounter.end.isoformat() if encounter.end else "not recorded"
+    lines = [
+        DISCHARGE_SUMMARY_TITLE.upper(),
+        "",
+        f"Patient: {patient.given_name} {patient.family_name} (MRN {patient.mrn}, born {patient.dob.isoformat()})",
+        f"Encounter: {encounter.encounter_id} ({encounter.visit_type})",
+        f"Period: {encounter.start.isoformat()} to {end}",
+    ]
+    if encounter.location:
+        lines.append(f"Location: {encounter.location}")
+    if encounter.reason:
+        lines.append(f"Reason for visit: {encounter.reason}")
+    if encounter.participants:
+        lines.append("Participants: " + ", ".join(
+            f"{participant.practitioner_id} ({participant.role})" if participant.role else participant.practitioner_id
+            for participant in encounter.participants
+        ))
+    lines += ["", "Observations:"]
+    for observation in sorted(observations, key=lambda o: o.effective_at):
+        name = observation.code.display or observation.code.code
+        lines.append(f"- {observation.effective_at.isoformat()} {name}: {_observation_value(observation)}")
+    if not observations:
+        lines.append("- None recorded")
+    return "\n".join(lines) + "\n"
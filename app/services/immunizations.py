@@ -0,0 +1,115 @@
+# Location: app/services/immunizations.py
+
+import json
+from datetime import date, timedelta
+from functools import lru_cache
+from pathlib import Path
+from typing import Iterable, List, Optional
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.api.v1 import schemas
+from app.core.config import get_settings
+
+# --- Configuration ---
+# The bundled table covers routine adult vaccines; deployments can point
+# IMMUNIZATION_SCHEDULE_PATH at their own table in the same format.
+DEFAULT_SCHEDULE_PATH = Path(__file__).with_name("immunization_schedule.json")
+IMMUNIZATION_SCHEDULE_PATH = get_settings().immunization_schedule_path
+
+
+class ScheduledDose(BaseModel):
+    """One dose of a series. `interval_days` counts from the previous dose (from eligibility for the first)."""
+    interval_days: int = Field(0, ge=0, alias="intervalDays")
+    model_config = ConfigDict(populate_by_name=True)
+
+
+class ScheduleSeries(BaseModel):
+    """
+    A vaccine series: which CVX codes count towards it, who is eligible and
+    when each dose is due. Series with `repeat_every_days` (e.g. annual flu)
+    are due again that long after the last dose once the doses are complete.
+    """
+    id: str
+    name: str
+    cvx_codes: List[str] = Field(..., min_length=1, alias="cvxCodes")
+    min_age_years: int = Field(0, ge=0, alias="minAgeYears")
+    max_age_years: Optional[int] = Field(None, alias="maxAgeYears", description="Series not started by this age are no longer recommended.")
+    doses: List[ScheduledDose] = Field(..., min_length=1)
+    repeat_every_days: Optional[int] = Field(None, gt=0, alias="repeatEveryDays")
+    overdue_after_days: int = Field(30, ge=0, alias="overdueAfterDays", description="Grace period after the due date.")
+    model_config = ConfigDict(populate_by_name=True)
+
+
+class Schedule(BaseModel):
+    series: List[ScheduleSeries]
+
+
+def load_schedule(path: Path) -> Schedule:
+    """Reads and validates a schedule table. Raises ValueError if it is malformed."""
+    return Schedule.model_validate(json.loads(Path(path).read_text()))
+
+
+@lru_cache
+def get_schedule() -> Schedule:
+    return load_schedule(IMMUNIZATION_SCHEDULE_PATH or DEFAULT_SCHEDULE_PATH)
+
+
+def _add_years(day: date, years: int) -> date:
+    try:
+        return day.replace(year=day.year + years)
+    except ValueError:  # 29 February in a non-leap year
+        return day.replace(year=day.year + years, day=28)
+
+
+def _recommend(series: ScheduleSeries, birth_date: date, doses_given: List[date], as_of: date) -> Optional[schemas.ImmunizationRecommendation]:
+    eligible_from = _add_years(birth_date, series.min_age_years)
+    if not doses_given and series.max_age_years is not None and as_of >= _add_years(birth_date, series.max_age_years + 1):
+        return None
+
+    count = len(doses_given)
+    if count < len(series.doses):
+        interval = timedelta(days=series.doses[count].interval_days)
+        due = eligible_from if count == 0 else max(doses_given[-1] + interval, eligible_from)
+    elif series.repeat_every_days:
+        due = doses_given[-1] + timedelta(days=series.repeat_every_days)
+    else:
+        return schemas.ImmunizationRecommendation(
+            series_id=series.id, series_name=series.name, status="complete", doses_given=count,
+        )
+
+    overdue = due + timedelta(days=series.overdue_after_days)
+    if as_of >= overdue:
+        status = "overdue"
+    elif as_of >= due:
+        status = "due"
+    else:
+        status = "upcoming"
+    return schemas.ImmunizationRecommendation(
+        series_id=series.id, series_name=series.name, status=status, doses_given=count,
+        dose_number=count + 1, due_date=due, overdue_date=overdue,
+    )
+
+
+def forecast(
+    schedule: Schedule,
+    birth_date: date,
+    immunizations: Iterable[schemas.Immunization],
+    as_of: date,
+) -> List[schemas.ImmunizationRecommendation]:
+    """
+    Computes the next dose of every series the patient is eligible for as of
+    `as_of`. Only completed doses count; each counts towards every series
+    listing its CVX code. Doses given after `as_of` are ignored.
+    """
+    given = [
+        i for i in immunizations
+        if i.status == "completed" and i.vaccine_code.system == schemas.CVX_SYSTEM and i.occurrence_date <= as_of
+    ]
+    recommendations = []
+    for series in schedule.series:
+        dates = sorted(i.occurrence_date for i in given if i.vaccine_code.code in series.cvx_codes)
+        recommendation = _recommend(series, birth_date, dates, as_of)
+        if recommendation:
+            recommendations.append(recommendation)
+    return recommendations
@@ -0,0 +1,119 @@
+# Location: app/services/invoices.py
+
+import hashlib
+import uuid
+from datetime import datetime, timezone
+from typing import Any, Dict, Optional
+
+from app.api.v1 import schemas
+from app.services.state_machine import StateMachine
+
+# --- Invoice Status Transitions ---
+# A draft is reviewed, then issued to the patient. Payments move it to
+# "partially-paid" until the balance is settled. Only an invoice nothing
+# was paid on may be voided, e.g. one drafted from a claim later corrected;
+# refunds are made at the payment provider. "paid" and "void" are terminal.
+INVOICE_STATUSES = StateMachine("invoice", {
+    "draft": {"issued", "void"},
+    "issued": {"partially-paid", "paid", "void"},
+    "partially-paid": {"paid"},
+    "paid": set(),
+    "void": set(),
+})
+
+# The invoices payments are taken for.
+PAYABLE = ("issued", "partially-paid")
+
+# A claim is invoiced once the payer has settled it.
+INVOICEABLE_CLAIMS = ("paid", "denied")
+
+
+class InvalidInvoiceError(ValueError):
+    """Raised when an invoice cannot be drafted, or a payment recorded, as asked."""
+
+
+def statement_number(invoice_id: str) -> str:
+    """The invoice's reference on the statement: 10 characters, easier to read out than its ID."""
+    return hashlib.sha256(invoice_id.encode()).hexdigest()[:10].upper()
+
+
+def build_invoice(claim: schemas.Claim, charges: Dict[str, schemas.ChargeItem], message: Optional[str] = None) -> Dict[str, Any]:
+    """
+    The fields of a draft invoice for what the payer left the patient to
+    pay on the claim: each line's patient responsibility (PR) adjustments.
+    `charges` are the claim's charge items by ID, for their descriptions.
+    Raises InvalidInvoiceError.
+    """
+    if claim.status not in INVOICEABLE_CLAIMS:
+        raise InvalidInvoiceError(f"The claim is {claim.status}; invoice it once the payer has paid or denied it")
+    lines = []
+    for line in claim.lines:
+        charge = charges.get(line.charge_item_id)
+        lines.append(schemas.InvoiceLine(
+            charge_item_id=line.charge_item_id,
+            service_date=line.service_date,
+            procedure_code=line.procedure_code,
+            modifiers=line.modifiers,
+            description=charge.description if charge else None,
+            units=line.units,
+            charge_cents=line.charge_cents,
+            insurance_paid_cents=line.paid_cents or 0,
+            adjustments=line.adjustments,
+            patient_cents=sum(adjustment.amount_cents for adjustment in line.adjustments if adjustment.group == "PR"),
+        ))
+    amount_due = sum(line.patient_cents for line in lines)
+    if amount_due <= 0:
+        raise InvalidInvoiceError("The claim's lines leave the patient nothing to pay; record the payer's PR adjustments on them first")
+    other_adjustments = sum(adjustment.amount_cents for line in lines for adjustment in line.adjustments if adjustment.group != "PR")
+    return {
+        "claimId": claim.claim_id,
+        "encounterId": claim.encounter_id,
+        "patientId": claim.patient_id,
+        "payerName": claim.payer_name,
+        "lines": [line.model_dump(by_alias=True) for line in lines],
+        "totalChargeCents": sum(line.charge_cents for line in lines),
+        "insurancePaidCents": sum(line.insurance_paid_cents for line in lines),
+        "adjustmentCents": other_adjustments,
+        "amountDueCents": amount_due,
+        "amountPaidCents": 0,
+        "balanceCents": amount_due,
+        "message": message,
+    }
+
+
+def payment_changes(invoice: schemas.Invoice, payment_in: schemas.InvoicePaymentCreate, recorded_by: str, source: str = "manual") -> Dict[str, Any]:
+    """
+    The changes recording a payment on the invoice, settling it when the
+    balance is paid. Raises InvalidInvoiceError for an invoice not open for
+    payment, more than the balance, or a reference already recorded, and
+    InvalidTransitionError.
+    """
+    if invoice.status not in PAYABLE:
+        raise InvalidInvoiceError(f"The invoice is {invoice.status} and takes no payments")
+    if payment_in.reference and any(payment.reference == payment_in.reference for payment in invoice.payments):
+        raise InvalidInvoiceError(f"A payment with reference '{payment_in.reference}' is already recorded")
+    if payment_in.amount_cents > invoice.balance_cents:
+        raise InvalidInvoiceError(f"The payment is more than the balance of {invoice.balance_cents} cents")
+    now = datetime.now(timezone.utc)
+    payment = schemas.InvoicePayment(
+        payment_id=str(uuid.uuid4()),
+        amount_cents=payment_in.amount_cents,
+        method=payment_in.method,
+        reference=payment_in.reference,
+        received_at=payment_in.received_at or now,
+        source=source,
+        recorded_by=recorded_by,
+    )
+    balance = invoice.balance_cents - payment.amount_cents
+    status = "paid" if balance == 0 else "partially-paid"
+    if status != invoice.status:
+        INVOICE_STATUSES.ensure(invoice.status, status)
+    changes = {
+        "payments": [entry.model_dump(by_alias=True) for entry in invoice.payments] + [payment.model_dump(by_alias=True)],
+        "amountPaidCents": invoice.amount_paid_cents + payment.amount_cents,
+        "balanceCents": balance,
+        "status": status,
+    }
+    if status == "paid":
+        changes["paidAt"] = now
+    return changes
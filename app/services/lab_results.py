@@ -0,0 +1,92 @@
+# Location: app/services/lab_results.py
+
+from datetime import datetime, timezone
+from typing import Iterable, List, Optional
+
+from app.api.v1 import schemas
+from app.services.vitals import MAX_CLOCK_SKEW
+from app.terminology.codesystems import LOINC
+from app.terminology.service import CodeValidationError, Terminology, get_terminology
+
+
+class LabResultValidationError(ValueError):
+    """Raised when a lab result payload is not acceptable."""
+
+
+def interpret(value: Optional[float], reference_range: Optional[schemas.ReferenceRange]) -> Optional[str]:
+    """
+    Returns the HL7 abnormal flag for a numeric value against its reference
+    range, or None when there is nothing to compare against.
+    """
+    if value is None or reference_range is None:
+        return None
+    r = reference_range
+    if r.critical_low is not None and value < r.critical_low:
+        return "LL"
+    if r.critical_high is not None and value > r.critical_high:
+        return "HH"
+    if r.low is not None and value < r.low:
+        return "L"
+    if r.high is not None and value > r.high:
+        return "H"
+    if r.low is None and r.high is None:
+        return None
+    return "N"
+
+
+def is_abnormal(analytes: Iterable[schemas.LabAnalyte]) -> bool:
+    return any(a.flag and a.flag != "N" for a in analytes)
+
+
+def prepare_lab_result(
+    lab_in: schemas.LabResultCreate, now: Optional[datetime] = None, terminology: Optional[Terminology] = None,
+) -> schemas.LabResultCreate:
+    """
+    Validates a lab result, including its LOINC codes, and fills in each
+    analyte's abnormal flag from its reference range. Flags sent by the lab
+    are kept as-is.
+    Raises LabResultValidationError describing the first problem found.
+    """
+    now = now or datetime.now(timezone.utc)
+    terminology = terminology or get_terminology()
+    if lab_in.effective_at.tzinfo is None:
+        raise LabResultValidationError("effectiveAt must include a timezone")
+    if lab_in.effective_at > now + MAX_CLOCK_SKEW:
+        raise LabResultValidationError("effectiveAt cannot be in the future")
+    if lab_in.issued_at and lab_in.issued_at < lab_in.effective_at:
+        raise LabResultValidationError("issuedAt cannot be before the specimen was collected")
+    try:
+        if lab_in.panel_code:
+            terminology.check_code(LOINC, lab_in.panel_code, "panelCode")
+        for analyte in lab_in.analytes:
+            terminology.check_code(LOINC, analyte.code, f"Analyte {analyte.code}")
+    except CodeValidationError as e:
+        raise LabResultValidationError(str(e))
+
+    analytes = []
+    for analyte in lab_in.analytes:
+        if analyte.value is not None and analyte.reference_range and not analyte.unit:
+            raise LabResultValidationError(f"Analyte {analyte.code}: a unit is required to compare against the reference range")
+        flag = analyte.flag or interpret(analyte.value, analyte.reference_range)
+        analytes.append(analyte.model_copy(update={"flag": flag}))
+    return lab_in.model_copy(update={"analytes": analytes})
+
+
+def trend_points(results: Iterable[schemas.LabResult], code: str) -> List[schemas.LabTrendPoint]:
+    """Extracts one analyte's values from a patient's results, oldest first. Cancelled results are left out."""
+    points = []
+    for result in results:
+        if result.status == "cancelled":
+            continue
+        for analyte in result.analytes:
+            if analyte.code == code:
+                points.append(schemas.LabTrendPoint(
+                    lab_result_id=result.lab_result_id,
+                    effective_at=result.effective_at,
+                    value=analyte.value,
+                    value_string=analyte.value_string,
+                    unit=analyte.unit,
+                    flag=analyte.flag,
+                    reference_range=analyte.reference_range,
+                ))
+    return sorted(points, key=lambda p: p.effective_at)
@@ -0,0 +1,20 @@
+# Location: app/services/medications.py
+
+from app.services.state_machine import StateMachine
+
+# --- Refill Request Workflow ---
+# A request is reviewed by its assigned practitioner, who approves or denies
+# it. "approved" and "denied" are terminal.
+REFILL_REQUEST_STATUSES = StateMachine("refill request", {
+    "requested": {"under-review"},
+    "under-review": {"approved", "denied"},
+    "approved": set(),
+    "denied": set(),
+})
+
+# Requests that still await a decision; a medication may have at most one.
+OPEN_REFILL_STATUSES = {"requested", "under-review"}
+
+
+def refills_remaining(medication) -> int:
+    return max(medication.refills_allowed - medication.refills_used, 0)
@@ -0,0 +1,34 @@
+# Location: app/services/notifications.py
+
+from typing import Any, Dict
+
+from app.api.v1 import schemas
+
+# Providers report delivery events at least once and in no guaranteed order:
+# a deferral may arrive after the delivery it preceded. A report moves the
+# notification only to a status of the same or a higher rank, so a late
+# report never undoes a later one; a bounce after delivery (the receiving
+# server accepted the message and returned it later) and a spam complaint
+# still count.
+STATUS_RANKS = {
+    "queued": 0,
+    "throttled": 3,
+    "sent": 1,
+    "deferred": 2,
+    "delivered": 3,
+    "bounced": 3,
+    "dropped": 3,
+    "failed": 3,
+    "complained": 4,
+}
+
+
+def delivery_changes(notification: schemas.Notification, event: schemas.DeliveryEvent) -> Dict[str, Any]:
+    """The field changes recording `event` on `notification`; none for an event already recorded."""
+    if event.provider_event_id and any(e.provider_event_id == event.provider_event_id for e in notification.delivery_events):
+        return {}
+    events = [e.model_dump(by_alias=True) for e in notification.delivery_events] + [event.model_dump(by_alias=True)]
+    changes: Dict[str, Any] = {"deliveryEvents": events}
+    if STATUS_RANKS[event.status] >= STATUS_RANKS[notification.status]:
+        changes["status"] = event.status
+    return changes
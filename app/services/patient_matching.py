@@ -0,0 +1,213 @@
+# Location: app/services/patient_matching.py
+
+"""
+Duplicate patient detection and merging, the master patient index of the
+API. The same person is often registered twice: by two clinics before a
+referral, from an HL7v2 feed and by hand, with a typo in a name. Candidates
+are the records sharing a patient's date of birth, family name or phone
+number (`PatientRepository.find_candidates`); each is compared with the
+patient in two steps:
+
+* Deterministic rules: the same NHS number or SSN, or the same full name,
+  date of birth and phone number, make a `certain` match.
+* Probabilistic scoring, in the manner of Fellegi and Sunter: each field
+  adds a weight when it agrees, somewhat less when it nearly does (a typo
+  in a name, day and month swapped in a date of birth) and subtracts one
+  when it differs; a field missing on either side adds nothing. Totals of
+  PROBABLE_SCORE or more are `probable` matches, PROBABLE_SCORE down to
+  POSSIBLE_SCORE `possible` ones, for a person to review.
+
+Merging moves every clinical record of the duplicate to the patient kept
+and then deletes the duplicate, noting where it went (`mergedInto`).
+"""
+
+import re
+import unicodedata
+from datetime import date
+from typing import Callable, Dict, List, Optional, Tuple
+
+from app.api.v1 import schemas
+
+# Weights of a field's (agreement, partial agreement, disagreement). They
+# grow with how rarely two different people share the value by chance, so
+# a date of birth weighs more than a sex; a differing phone number or
+# address costs little, as people move and change numbers.
+WEIGHTS: Dict[str, Tuple[float, float, float]] = {
+    "givenName": (3.5, 2.0, -1.5),
+    "familyName": (4.0, 2.5, -2.0),
+    "dob": (5.0, 2.0, -3.0),
+    "sex": (0.5, 0.0, -1.0),
+    "phoneNumber": (4.0, 0.0, -0.5),
+    "address": (3.0, 1.0, -0.5),
+}
+PROBABLE_SCORE = 12.0
+POSSIBLE_SCORE = 7.0
+
+# Names at least this similar (Jaro-Winkler) partly agree, e.g. "Jon" and "John".
+SIMILAR_NAME = 0.9
+
+# Where a patient's records are: the repositories moved by a merge, by the
+# resource name reported in the result. Audit events and the event stream
+# are history and keep naming the duplicate.
+PATIENT_RECORDS = (
+    "appointments",
+    "appointmentReminders",
+    "encounters",
+    "observations",
+    "labResults",
+    "medications",
+    "refillRequests",
+    "allergies",
+    "immunizations",
+    "carePlans",
+    "careTeams",
+    "consents",
+    "patientDocuments",
+    "woundImages",
+    "notifications",
+    "pushTokens",
+    "telehealthSessions",
+    "coverage",
+    "eligibilityChecks",
+    "chargeItems",
+    "claims",
+    "invoices",
+)
+
+
+def normalize_name(name: Optional[str]) -> str:
+    """Lower case, without accents, spaces or punctuation, so "O'Brien" and "obrien" agree."""
+    decomposed = unicodedata.normalize("NFKD", name or "")
+    return re.sub(r"[^a-z]", "", "".join(c for c in decomposed if not unicodedata.combining(c)).lower())
+
+
+def jaro_winkler(left: str, right: str) -> float:
+    """The Jaro-Winkler similarity of two strings, from 0 (nothing in common) to 1 (equal)."""
+    if left == right:
+        return 1.0
+    if not left or not right:
+        return 0.0
+    window = max(max(len(left), len(right)) // 2 - 1, 0)
+    left_matched, right_matched = [False] * len(left), [False] * len(right)
+    matches = 0
+    for i, char in enumerate(left):
+        for j in range(max(0, i - window), min(len(right), i + window + 1)):
+            if not right_matched[j] and right[j] == char:
+                left_matched[i] = right_matched[j] = True
+                matches += 1
+                break
+    if not matches:
+        return 0.0
+    left_chars = [c for c, matched in zip(left, left_matched) if matched]
+    right_chars = [c for c, matched in zip(right, right_matched) if matched]
+    transpositions = sum(a != b for a, b in zip(left_chars, right_chars)) / 2
+    jaro = (matches / len(left) + matches / len(right) + (matches - transpositions) / matches) / 3
+    prefix = 0
+    for a, b in zip(left[:4], right[:4]):
+        if a != b:
+            break
+        prefix += 1
+    return jaro + prefix * 0.1 * (1 - jaro)
+
+
+def _compare_names(left: Optional[str], right: Optional[str]) -> schemas.FieldComparison:
+    left, right = normalize_name(left), normalize_name(right)
+    if not left or not right:
+        return "missing"
+    if left == right:
+        return "agree"
+    return "partial" if jaro_winkler(left, right) >= SIMILAR_NAME else "disagree"
+
+
+def _compare_dates(left: date, right: date) -> schemas.FieldComparison:
+    if left == right:
+        return "agree"
+    swapped = (left.year, left.month, left.day) == (right.year, right.day, right.month)
+    same_parts = sum(a == b for a, b in zip((left.year, left.month, left.day), (right.year, right.month, right.day)))
+    return "partial" if swapped or same_parts == 2 else "disagree"
+
+
+def _compare_values(left: Optional[str], right: Optional[str]) -> schemas.FieldComparison:
+    if not left or not right:
+        return "missing"
+    return "agree" if left == right else "disagree"
+
+
+def _phone(patient: schemas.Patient) -> Optional[str]:
+    return patient.contact.phone_number if patient.contact else None
+
+
+def _address(patient: schemas.Patient) -> Tuple[str, str]:
+    contact = patient.contact
+    if not contact:
+        return "", ""
+    postal_code = re.sub(r"\s", "", contact.postal_code or "").upper()
+    return re.sub(r"[^a-z0-9]", "", (contact.address_line or "").lower()), postal_code
+
+
+def _compare_addresses(left: schemas.Patient, right: schemas.Patient) -> schemas.FieldComparison:
+    (left_line, left_code), (right_line, right_code) = _address(left), _address(right)
+    if not (left_line or left_code) or not (right_line or right_code):
+        return "missing"
+    if left_code and left_code != right_code:
+        return "disagree"
+    if left_line and right_line and left_line != right_line:
+        return "partial" if left_code else "disagree"
+    return "agree"
+
+
+COMPARISONS: Dict[str, Callable[[schemas.Patient, schemas.Patient], schemas.FieldComparison]] = {
+    "givenName": lambda a, b: _compare_names(a.given_name, b.given_name),
+    "familyName": lambda a, b: _compare_names(a.family_name, b.family_name),
+    "dob": lambda a, b: _compare_dates(a.dob, b.dob),
+    "sex": lambda a, b: _compare_values(*(None if p.sex == "unknown" else p.sex for p in (a, b))),
+    "phoneNumber": lambda a, b: _compare_values(_phone(a), _phone(b)),
+    "address": _compare_addresses,
+}
+
+
+def _deterministic_rule(patient: schemas.Patient, candidate: schemas.Patient, fields: Dict[str, str]) -> Optional[str]:
+    if patient.nhs_number and patient.nhs_number == candidate.nhs_number:
+        return "nhs-number"
+    if patient.ssn and patient.ssn == candidate.ssn:
+        return "ssn"
+    if all(fields[name] == "agree" for name in ("givenName", "familyName", "dob", "phoneNumber")):
+        return "name-dob-phone"
+    return None
+
+
+def score(patient: schemas.Patient, candidate: schemas.Patient) -> Optional[schemas.PotentialDuplicate]:
+    """How likely `candidate` is the same person as `patient`; None below POSSIBLE_SCORE."""
+    comparisons = {name: compare(patient, candidate) for name, compare in COMPARISONS.items()}
+    fields = []
+    for name, comparison in comparisons.items():
+        agree, partial, disagree = WEIGHTS[name]
+        weight = {"agree": agree, "partial": partial, "disagree": disagree, "missing": 0.0}[comparison]
+        fields.append(schemas.MatchFieldScore(field=name, comparison=comparison, weight=weight))
+    total = round(sum(field.weight for field in fields), 2)
+    rule = _deterministic_rule(patient, candidate, comparisons)
+    if rule:
+        grade = "certain"
+    elif total >= PROBABLE_SCORE:
+        grade = "probable"
+    elif total >= POSSIBLE_SCORE:
+        grade = "possible"
+    else:
+        return None
+    return schemas.PotentialDuplicate(patient=candidate, grade=grade, score=total, rule=rule, fields=fields)
+
+
+def potential_duplicates(patient: schemas.Patient, candidates: List[schemas.Patient]) -> List[schemas.PotentialDuplicate]:
+    """The candidates that may be `patient`, certain matches first, then by score."""
+    matches = [match for match in (score(patient, candidate) for candidate in candidates) if match]
+    return sorted(matches, key=lambda match: (match.grade != "certain", -match.score, match.patient.patient_id))
+
+
+def merge_records(repositories: Dict[str, object], source_patient_id: str, target_patient_id: str) -> Dict[str, int]:
+    """
+    Moves the records of `source_patient_id` in each of `repositories` (by
+    resource name, see PATIENT_RECORDS) to `target_patient_id`. Moving is
+    idempotent, so a merge that failed part way is completed by running it
+    again. Returns how many records of each resource were moved.
+    """
+    return {resource: repositories[resource].reassign_patient(source_patient_id, target_patient_id) for resource in PATIENT_RECORDS}
@@ -0,0 +1,134 @@
+# Location: app/services/reports.py
+
+import csv
+import io
+from collections import defaultdict
+from datetime import date, datetime, time, timedelta, timezone
+from typing import Dict, Iterable, List, Optional, Tuple, Type
+from zoneinfo import ZoneInfo
+
+from pydantic import BaseModel
+
+from app.api.v1 import schemas
+
+# Patients are counted as adherent with readings on at least this share of
+# the days, as in the usual CPAP adherence measure (70% of nights).
+DEFAULT_ADHERENCE_THRESHOLD = 0.7
+
+
+def period_bounds(start: date, end: date, zone: ZoneInfo) -> Tuple[datetime, datetime]:
+    """The instants from the start of `start` to the end of `end` (inclusive days) in `zone`, in UTC."""
+    return (
+        datetime.combine(start, time.min, tzinfo=zone).astimezone(timezone.utc),
+        datetime.combine(end + timedelta(days=1), time.min, tzinfo=zone).astimezone(timezone.utc),
+    )
+
+
+def _local_day(at: datetime, zone: ZoneInfo) -> date:
+    if at.tzinfo is None:
+        at = at.replace(tzinfo=timezone.utc)
+    return at.astimezone(zone).date()
+
+
+def appointment_volume(
+    organization_id: Optional[str], appointments: Iterable[schemas.Appointment], zone: ZoneInfo,
+) -> List[schemas.AppointmentVolumeRow]:
+    """Appointments per day they start on, by status; days without appointments are left out."""
+    days: Dict[date, schemas.AppointmentVolumeRow] = {}
+    for appointment in appointments:
+        day = _local_day(appointment.start, zone)
+        row = days.setdefault(day, schemas.AppointmentVolumeRow(organization_id=organization_id, day=day))
+        row.total += 1
+        setattr(row, appointment.status, getattr(row, appointment.status) + 1)
+    return [days[day] for day in sorted(days)]
+
+
+def no_show_rates(
+    organization_id: Optional[str], appointments: Iterable[schemas.Appointment], now: datetime, by_practitioner: bool = False,
+) -> List[schemas.NoShowRateRow]:
+    """
+    The share of appointments whose patient never came. There is no
+    no-show status: an appointment that has ended while still booked (not
+    marked arrived, nor cancelled) is counted as one. Appointments that
+    have not ended yet are left out.
+    """
+    counts: Dict[Optional[str], List[int]] = defaultdict(lambda: [0, 0])
+    for appointment in appointments:
+        end = appointment.end if appointment.end.tzinfo else appointment.end.replace(tzinfo=timezone.utc)
+        if appointment.status == "cancelled" or end > now:
+            continue
+        count = counts[appointment.practitioner_id if by_practitioner else None]
+        count[0] += 1
+        count[1] += appointment.status == "booked"
+    if not counts and not by_practitioner:
+        counts[None] = [0, 0]
+    return [
+        schemas.NoShowRateRow(
+            organization_id=organization_id, practitioner_id=key, appointments=total, no_shows=missed,
+            no_show_rate=round(missed / total, 4) if total else None,
+        )
+        for key, (total, missed) in sorted(counts.items(), key=lambda item: item[0] or "")
+    ]
+
+
+def telemetry_adherence(
+    organization_id: Optional[str],
+    observations: Iterable[schemas.Observation],
+    start: date,
+    end: date,
+    zone: ZoneInfo,
+    code: Optional[str] = None,
+    threshold: float = DEFAULT_ADHERENCE_THRESHOLD,
+) -> schemas.TelemetryAdherenceRow:
+    """
+    How regularly patients' devices report: for each patient with a reading
+    in the period (of `code`, if given), the share of its days on which
+    they sent at least one, averaged over those patients. Patients who sent
+    nothing at all cannot be told apart from those not monitored, and are
+    not counted.
+    """
+    days_with_readings: Dict[str, set] = defaultdict(set)
+    for observation in observations:
+        if code and observation.code.code != code:
+            continue
+        days_with_readings[observation.patient_id].add(_local_day(observation.effective_at, zone))
+    period_days = (end - start).days + 1
+    shares = [len(days) / period_days for days in days_with_readings.values()]
+    return schemas.TelemetryAdherenceRow(
+        organization_id=organization_id,
+        code=code,
+        patients=len(shares),
+        adherent_patients=sum(share >= threshold for share in shares),
+        average_adherence=round(sum(shares) / len(shares), 4) if shares else None,
+        threshold=threshold,
+    )
+
+
+def open_refill_requests(
+    organization_id: Optional[str], requests: Iterable[schemas.RefillRequest], now: datetime, statuses: Iterable[str],
+) -> List[schemas.OpenRefillRequestRow]:
+    """Open requests by status, with the oldest and their average age; every open status has a row."""
+    by_status: Dict[str, List[datetime]] = {status: [] for status in sorted(statuses)}
+    for request in requests:
+        created = request.created_at if request.created_at.tzinfo else request.created_at.replace(tzinfo=timezone.utc)
+        by_status.setdefault(request.status, []).append(created)
+    rows = []
+    for status, created in by_status.items():
+        ages = [(now - at).total_seconds() / 3600 for at in created]
+        rows.append(schemas.OpenRefillRequestRow(
+            organization_id=organization_id, status=status, count=len(created),
+            oldest_requested_at=min(created) if created else None,
+            average_age_hours=round(sum(ages) / len(ages), 1) if ages else None,
+        ))
+    return rows
+
+
+def to_csv(row_model: Type[BaseModel], rows: Iterable[BaseModel]) -> str:
+    """The rows as CSV with a header line, columns named and ordered as the row's JSON fields."""
+    columns = list(row_model.model_fields)
+    out = io.StringIO()
+    writer = csv.DictWriter(out, fieldnames=columns, lineterminator="\r\n")
+    writer.writeheader()
+    for row in rows:
+        writer.writerow({name: "" if value is None else value for name, value in row.model_dump(mode="json").items()})
+    return out.getvalue()
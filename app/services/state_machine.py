@@ -0,0 +1,35 @@
+# Location: app/services/state_machine.py
+
+from typing import Dict, Set
+
+
+class InvalidTransitionError(Exception):
+    """Raised when a resource cannot move from its current status to the requested one."""
+
+    def __init__(self, resource: str, current: str, target: str):
+        self.resource = resource
+        self.current = current
+        self.target = target
+        super().__init__(f"Cannot change {resource} status from '{current}' to '{target}'.")
+
+
+class StateMachine:
+    """
+    The legal status changes of a resource, as a map from each status to the
+    statuses it may move to. Statuses that map to an empty set are terminal.
+    """
+
+    def __init__(self, resource: str, transitions: Dict[str, Set[str]]):
+        self.resource = resource
+        self.transitions = transitions
+
+    def allowed(self, current: str) -> Set[str]:
+        return self.transitions.get(current, set())
+
+    def is_terminal(self, status: str) -> bool:
+        return not self.allowed(status)
+
+    def ensure(self, current: str, target: str) -> None:
+        """Raises InvalidTransitionError unless `current` -> `target` is a legal move."""
+        if target not in self.allowed(current):
+            raise InvalidTransitionError(self.resource, current, target)
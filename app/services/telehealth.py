@@ -0,0 +1,80 @@
+# Location: app/services/telehealth.py
+
+from datetime import datetime
+from typing import Any, Dict, Optional, Set
+
+from app.api.v1 import schemas
+
+# Participants are named in their join tokens by role, so the provider's
+# reports of who joined say whether it was the patient or a clinician.
+PATIENT_IDENTITY_PREFIX = "patient-"
+STAFF_IDENTITY_PREFIX = "staff-"
+
+# Rooms are named after their session, so a provider report names it.
+ROOM_NAME_PREFIX = "megacare-"
+
+
+def room_name(session_id: str) -> str:
+    return f"{ROOM_NAME_PREFIX}{session_id}"
+
+
+def session_id_of_room(name: str) -> Optional[str]:
+    """The session a room is for, or None for rooms this API did not open."""
+    if not name.startswith(ROOM_NAME_PREFIX):
+        return None
+    return name[len(ROOM_NAME_PREFIX):] or None
+
+
+def participant_identity(role: str, subject: str) -> str:
+    return f"{PATIENT_IDENTITY_PREFIX if role == 'patient' else STAFF_IDENTITY_PREFIX}{subject}"
+
+
+def role_of_identity(identity: Optional[str]) -> Optional[str]:
+    if identity and identity.startswith(PATIENT_IDENTITY_PREFIX):
+        return "patient"
+    if identity and identity.startswith(STAFF_IDENTITY_PREFIX):
+        return "staff"
+    return None
+
+
+def _summary(events) -> Dict[str, Any]:
+    """
+    The status, start and end the events add up to. Providers report at
+    least once and not always in order, so the events are replayed in the
+    order they happened rather than in the order they were received.
+    """
+    present: Dict[str, Set[str]] = {"patient": set(), "staff": set()}
+    started_at: Optional[datetime] = None
+    for event in sorted(events, key=lambda e: e.at):
+        if event.type == "ended":
+            return {
+                "status": "ended", "startedAt": started_at, "endedAt": event.at,
+                "durationSeconds": int((event.at - started_at).total_seconds()) if started_at else None,
+            }
+        if event.role is None or event.identity is None:
+            continue
+        if event.type == "participant_joined":
+            present[event.role].add(event.identity)
+        elif event.type == "participant_left":
+            present[event.role].discard(event.identity)
+        if started_at is None and present["patient"] and present["staff"]:
+            started_at = event.at
+    return {"status": "active" if started_at else "waiting", "startedAt": started_at, "endedAt": None, "durationSeconds": None}
+
+
+def event_changes(session: schemas.TelehealthSession, event: schemas.TelehealthSessionEvent, ended_by: Optional[str] = None) -> Dict[str, Any]:
+    """
+    The field changes recording `event` on `session`; none for an event
+    already recorded, or for the end of a session already ended (the room
+    closing after a clinician ended the visit). Events that happened after
+    the end are kept but do not reopen it.
+    """
+    if event.provider_event_id and any(e.provider_event_id == event.provider_event_id for e in session.events):
+        return {}
+    if event.type == "ended" and session.status == "ended":
+        return {}
+    events = session.events + [event]
+    changes: Dict[str, Any] = {"events": [e.model_dump(by_alias=True) for e in events], **_summary(events)}
+    if event.type == "ended":
+        changes["endedBy"] = ended_by
+    return changes
@@ -0,0 +1,128 @@
+# Location: app/services/vitals.py
+
+from dataclasses import dataclass, field
+from datetime import datetime, timedelta, timezone
+from typing import Dict, Optional, Tuple
+
+from app.api.v1 import schemas
+
+LOINC_SYSTEM = "http://loinc.org"
+
+# Measurements from devices with slightly fast clocks are accepted up to this far in the future.
+MAX_CLOCK_SKEW = timedelta(minutes=5)
+
+
+@dataclass(frozen=True)
+class VitalSpec:
+    """
+    A supported vital sign: its LOINC code, accepted UCUM units (with the
+    factor converting each to the first unit) and the physiologically
+    plausible range in the first unit. Panels such as blood pressure carry
+    their values in `components` instead of a top-level value. Readings
+    outside the usual adult range (`normal`, where there is one) are
+    abnormal, and the patient is alerted to them.
+    """
+    code: str
+    display: str
+    units: Dict[str, float] = field(default_factory=dict)
+    minimum: Optional[float] = None
+    maximum: Optional[float] = None
+    components: Tuple["VitalSpec", ...] = ()
+    normal: Optional[Tuple[float, float]] = None
+
+
+SYSTOLIC = VitalSpec("8480-6", "Systolic blood pressure", {"mm[Hg]": 1.0}, 40, 300, normal=(90, 160))
+DIASTOLIC = VitalSpec("8462-4", "Diastolic blood pressure", {"mm[Hg]": 1.0}, 20, 200, normal=(50, 100))
+
+VITAL_SIGNS: Dict[str, VitalSpec] = {spec.code: spec for spec in (
+    VitalSpec("59408-5", "Oxygen saturation in Arterial blood by Pulse oximetry", {"%": 1.0}, 50, 100, normal=(92, 100)),
+    VitalSpec("8867-4", "Heart rate", {"/min": 1.0, "{beats}/min": 1.0}, 20, 300, normal=(50, 110)),
+    VitalSpec("85354-9", "Blood pressure panel with all children optional", components=(SYSTOLIC, DIASTOLIC)),
+    # Whether a weight is a concern depends on the patient, so none is abnormal.
+    VitalSpec("29463-7", "Body weight", {"kg": 1.0, "g": 0.001, "[lb_av]": 0.45359237}, 0.5, 500),
+)}
+
+
+class VitalValidationError(ValueError):
+    """Raised when a vital-sign payload does not match its LOINC definition."""
+
+
+def _check_value(spec: VitalSpec, value: Optional[float], unit: Optional[str], label: str) -> None:
+    if value is None:
+        raise VitalValidationError(f"{label}: a value is required")
+    if unit not in spec.units:
+        raise VitalValidationError(f"{label}: unit '{unit}' is not valid; expected one of {', '.join(spec.units)}")
+    normalised = value * spec.units[unit]
+    if not (spec.minimum <= normalised <= spec.maximum):
+        base_unit = next(iter(spec.units))
+        raise VitalValidationError(f"{label}: {value} {unit} is outside the plausible range {spec.minimum}-{spec.maximum} {base_unit}")
+
+
+def build_vital_observation(patient_id: str, vital_in: schemas.VitalSignCreate, now: Optional[datetime] = None) -> schemas.ObservationCreate:
+    """
+    Validates a vital-sign payload against its LOINC definition and returns
+    the observation to store, with the full LOINC coding filled in.
+    Raises VitalValidationError describing the first problem found.
+    """
+    spec = VITAL_SIGNS.get(vital_in.code)
+    if not spec:
+        raise VitalValidationError(f"Unsupported vital-sign code '{vital_in.code}'; supported codes are {', '.join(VITAL_SIGNS)}")
+
+    effective_at = vital_in.effective_at
+    if effective_at.tzinfo is None:
+        raise VitalValidationError("effectiveAt must include a timezone")
+    if effective_at > (now or datetime.now(timezone.utc)) + MAX_CLOCK_SKEW:
+        raise VitalValidationError("effectiveAt cannot be in the future")
+
+    components = []
+    if spec.components:
+        if vital_in.value is not None:
+            raise VitalValidationError(f"{spec.display} is recorded through components, not a top-level value")
+        given = {c.code: c for c in vital_in.components}
+        unknown = set(given) - {c.code for c in spec.components}
+        if unknown:
+            raise VitalValidationError(f"Unexpected component code(s) for {spec.display}: {', '.join(sorted(unknown))}")
+        for component_spec in spec.components:
+            component = given.get(component_spec.code)
+            if not component:
+                raise VitalValidationError(f"{spec.display} requires a {component_spec.display} ({component_spec.code}) component")
+            _check_value(component_spec, component.value, component.unit, component_spec.display)
+            components.append(schemas.ObservationComponent(
+                code=schemas.Coding(system=LOINC_SYSTEM, code=component_spec.code, display=component_spec.display),
+                value=component.value,
+                unit=component.unit,
+            ))
+    else:
+        if vital_in.components:
+            raise VitalValidationError(f"{spec.display} does not take components")
+        _check_value(spec, vital_in.value, vital_in.unit, spec.display)
+
+    return schemas.ObservationCreate(
+        patient_id=patient_id,
+        code=schemas.Coding(system=LOINC_SYSTEM, code=spec.code, display=spec.display),
+        status=vital_in.status,
+        value=vital_in.value,
+        unit=vital_in.unit if not spec.components else None,
+        components=components,
+        effective_at=effective_at,
+    )
+
+
+def _outside_normal(spec: VitalSpec, value: Optional[float], unit: Optional[str]) -> bool:
+    if spec.normal is None or value is None or unit not in spec.units:
+        return False
+    low, high = spec.normal
+    return not (low <= value * spec.units[unit] <= high)
+
+
+def is_abnormal_reading(observation: schemas.Observation) -> bool:
+    """Whether a vital sign, or any of its components, is outside its usual range."""
+    spec = VITAL_SIGNS.get(observation.code.code)
+    if not spec:
+        return False
+    if spec.components:
+        specs = {c.code: c for c in spec.components}
+        return any(
+            _outside_normal(specs[c.code.code], c.value, c.unit) for c in observation.components if c.code.code in specs
+        )
+    return _outside_normal(spec, observation.value, observation.unit)
@@ -0,0 +1,518 @@
+# Location: app/tasks/jobs.py
+
+import logging
+from datetime import datetime, timezone
+from typing import Any, Dict, Optional
+from zoneinfo import ZoneInfo
+
+from app.api.v1 import schemas
+from app.api.v1.deps import (
+    get_audit_event_repository,
+    get_audit_export_repository,
+    get_claim_repository,
+    get_coverage_repository,
+    get_eligibility_check_repository,
+    get_encounter_repository,
+    get_event_publisher,
+    get_export_job_repository,
+    get_import_job_repository,
+    get_invoice_repository,
+    get_job_task_queue,
+    get_magic_link_repository,
+    get_notification_repository,
+    get_observation_repository,
+    get_organization_repository,
+    get_patient_document_repository,
+    get_patient_repository,
+    get_practitioner_repository,
+    get_push_token_repository,
+)
+from app.audit.export import run_audit_export
+from app.auth.accounts import get_user_accounts
+from app.auth.magic_links import get_magic_links
+from app.claims.clearinghouse import get_claims_clearinghouse
+from app.claims.submission import run_claim_submission
+from app.core.config import get_settings
+from app.core.storage import get_storage_client, read_blob
+from app.eligibility.checks import billing_provider, run_eligibility_check
+from app.eligibility.clearinghouse import get_clearinghouse
+from app.fhir.bulk_export import run_export
+from app.invoices.statements import write_statement
+from app.notifications.push import OutgoingPush, PushDeliveryError, get_push_sender, push_allowed
+from app.notifications.senders import EmailRejected, OutgoingEmail, get_email_sender
+from app.notifications.sms import OutgoingSms, SmsRecipientOptedOut, SmsRejected, get_sms_sender
+from app.notifications.templates import PUSH_CATEGORIES, TemplateContext, render, render_push, render_sign_in_link, render_sms, render_staff_account_link
+from app.repositories.base import NotFoundError
+from app.repositories.push_tokens import push_token_id
+from app.repositories.transactions import unit_of_work
+from app.scanning.scanner import get_scanner
+from app.services.bulk_import import import_chunk
+from app.services.encounters import DISCHARGE_SUMMARY_TITLE, discharge_summary
+from app.tasks.registry import PermanentTaskError, task
+from app.tenancy.context import current_tenant
+
+# --- Deferred Jobs ---
+# Every job the service can defer, registered by name. Importing this module
+# registers them; the task handler endpoint does so at start-up.
+BULK_EXPORT_TASK = "fhir-bulk-export"
+AUDIT_EXPORT_TASK = "audit-export"
+BULK_IMPORT_TASK = "bulk-import"
+DISCHARGE_SUMMARY_TASK = "encounter-discharge-summary"
+DOCUMENT_SCAN_TASK = "patient-document-scan"
+EMAIL_NOTIFICATION_TASK = "notification-email"
+SMS_NOTIFICATION_TASK = "notification-sms"
+PUSH_NOTIFICATION_TASK = "notification-push"
+APPOINTMENT_REMINDER_TASK = "appointment-reminder"
+MAGIC_LINK_EMAIL_TASK = "magic-link-email"
+STAFF_ACCOUNT_EMAIL_TASK = "staff-account-email"
+ELIGIBILITY_CHECK_TASK = "eligibility-check"
+CLAIM_SUBMISSION_TASK = "claim-submission"
+INVOICE_STATEMENT_TASK = "invoice-statement"
+
+# Recorded as `addedBy` on documents that jobs attach.
+TASK_ACTOR = "system:tasks"
+
+settings = get_settings()
+
+
+@task(BULK_EXPORT_TASK)
+def bulk_export(payload: Dict[str, Any]) -> None:
+    """Runs a FHIR `$export` job. Jobs that are no longer `accepted` are skipped."""
+    run_export(
+        payload["jobId"],
+        get_export_job_repository(),
+        get_patient_repository(),
+        get_observation_repository(),
+        settings.fhir_export_bucket,
+    )
+
+
+@task(AUDIT_EXPORT_TASK)
+def audit_export(payload: Dict[str, Any]) -> None:
+    """Writes an audit trail export to AUDIT_EXPORT_BUCKET. Exports that are no longer `accepted` are skipped."""
+    bucket_name = settings.audit_export_bucket
+    if not bucket_name:
+        raise PermanentTaskError("AUDIT_EXPORT_BUCKET must be set to export the audit trail")
+    run_audit_export(payload["exportId"], get_audit_export_repository(), get_audit_event_repository(), bucket_name)
+
+
+def bulk_import_task_id(job_id: str, offset: int) -> str:
+    return f"bulk-import-{job_id}-{offset}"
+
+
+@task(BULK_IMPORT_TASK)
+def bulk_import(payload: Dict[str, Any]) -> None:
+    """
+    Imports the next chunk of an NDJSON import file and queues the chunk
+    after it. Without TASKS_QUEUE the whole file is imported in this run.
+    """
+    bucket_name = settings.import_bucket
+    if not bucket_name:
+        raise PermanentTaskError("IMPORT_BUCKET must be set to import files")
+    job_id, offset = payload["jobId"], payload.get("offset", 0)
+    while offset is not None:
+        offset = import_chunk(
+            job_id,
+            offset,
+            get_import_job_repository(),
+            get_patient_repository(),
+            get_observation_repository(),
+            get_event_publisher(),
+            bucket_name,
+            settings.import_chunk_lines,
+        )
+        if offset is not None and settings.tasks_queue:
+            get_job_task_queue().enqueue(BULK_IMPORT_TASK, {"jobId": job_id, "offset": offset}, task_id=bulk_import_task_id(job_id, offset))
+            return
+
+
+@task(DISCHARGE_SUMMARY_TASK)
+def write_discharge_summary(payload: Dict[str, Any]) -> None:
+    """
+    Writes the discharge summary of a finished encounter to
+    ENCOUNTER_DOCUMENTS_BUCKET and attaches it to the encounter. Does nothing
+    if a summary is already attached, so a repeated run adds no second copy.
+    """
+    encounter_id = payload["encounterId"]
+    bucket_name = settings.encounter_documents_bucket
+    if not bucket_name:
+        raise PermanentTaskError("ENCOUNTER_DOCUMENTS_BUCKET is not set")
+    encounters = get_encounter_repository()
+    encounter = encounters.get(encounter_id)
+    if not encounter:
+        raise PermanentTaskError(f"Encounter '{encounter_id}' not found")
+    if encounter.status != "finished":
+        # The job is queued before the status change commits, so it may run
+        # first; retry until the change is visible.
+        raise RuntimeError(f"Encounter '{encounter_id}' is not finished yet")
+    if any(document.title == DISCHARGE_SUMMARY_TITLE for document in encounter.documents):
+        return
+    patient = get_patient_repository().get(encounter.patient_id)
+    if not patient:
+        raise PermanentTaskError(f"Patient '{encounter.patient_id}' of encounter '{encounter_id}' not found")
+    observations = get_observation_repository()
+    linked = [o for o in (observations.get(observation_id) for observation_id in encounter.observation_ids) if o]
+
+    blob_name = f"encounters/{encounter_id}/discharge-summary.txt"
+    get_storage_client().bucket(bucket_name).blob(blob_name).upload_from_string(
+        discharge_summary(encounter, patient, linked), content_type="text/plain; charset=utf-8",
+    )
+    document = schemas.EncounterDocument(title=DISCHARGE_SUMMARY_TITLE, contentType="text/plain", url=f"gs://{bucket_name}/{blob_name}")
+    with unit_of_work():
+        updated = encounters.add_document(encounter_id, document, added_by=TASK_ACTOR)
+        get_event_publisher().emit("encounter.updated", f"encounters/{encounter_id}", updated)
+
+
+@task(ELIGIBILITY_CHECK_TASK)
+def eligibility_check(payload: Dict[str, Any]) -> None:
+    """
+    Asks the payer, through ELIGIBILITY_PROVIDER, whether a coverage pays
+    for the check's services, as the organization's billing provider.
+    Checks already answered are skipped; a clearinghouse that cannot be
+    reached is retried.
+    """
+    clearinghouse = get_clearinghouse()
+    if clearinghouse is None:
+        raise PermanentTaskError("ELIGIBILITY_PROVIDER must be set to check eligibility")
+    tenant = current_tenant()
+    organization = get_organization_repository().get(tenant) if tenant else None
+    check = run_eligibility_check(
+        payload["checkId"],
+        get_eligibility_check_repository(),
+        get_coverage_repository(),
+        get_patient_repository(),
+        clearinghouse,
+        billing_provider(organization),
+    )
+    if check and check.status == "completed":
+        subject = f"patients/{check.patient_id}/coverage/{check.coverage_id}/eligibility/{check.check_id}"
+        get_event_publisher().emit("eligibility_check.completed", subject, check)
+
+
+@task(CLAIM_SUBMISSION_TASK)
+def claim_submission(payload: Dict[str, Any]) -> None:
+    """
+    Sends a queued claim to the payer through CLAIMS_PROVIDER, billed by
+    the organization's billing provider. Claims no longer queued are
+    skipped; a clearinghouse that cannot be reached is retried.
+    """
+    clearinghouse = get_claims_clearinghouse()
+    if clearinghouse is None:
+        raise PermanentTaskError("CLAIMS_PROVIDER must be set to submit claims")
+    tenant = current_tenant()
+    organization = get_organization_repository().get(tenant) if tenant else None
+    claim = run_claim_submission(
+        payload["claimId"],
+        get_claim_repository(),
+        get_coverage_repository(),
+        get_patient_repository(),
+        get_practitioner_repository(),
+        clearinghouse,
+        billing_provider(organization),
+    )
+    if claim:
+        get_event_publisher().emit("claim.status_changed", f"claims/{claim.claim_id}", claim)
+
+
+@task(INVOICE_STATEMENT_TASK)
+def invoice_statement(payload: Dict[str, Any]) -> None:
+    """
+    Renders the PDF statement of an issued invoice to INVOICES_BUCKET, from
+    the organization's billing provider. Invoices with a statement already
+    are skipped.
+    """
+    bucket_name = settings.invoices_bucket
+    if not bucket_name:
+        raise PermanentTaskError("INVOICES_BUCKET must be set to render statements")
+    tenant = current_tenant()
+    organization = get_organization_repository().get(tenant) if tenant else None
+    invoice = write_statement(payload["invoiceId"], get_invoice_repository(), get_patient_repository(), billing_provider(organization), bucket_name)
+    if invoice:
+        logging.info(f"Rendered the statement of invoice {invoice.invoice_id}")
+
+
+@task(DOCUMENT_SCAN_TASK)
+def scan_document(payload: Dict[str, Any]) -> None:
+    """
+    Scans an uploaded patient document for malware with DOCUMENT_SCANNER,
+    then makes it available or quarantines it. A quarantined file is kept
+    for review until the document is deleted. Documents no longer awaiting
+    a scan are skipped; a scanner that cannot be reached is retried.
+    """
+    document_id = payload["documentId"]
+    scanner = get_scanner()
+    bucket_name = settings.patient_documents_bucket
+    if scanner is None or not bucket_name:
+        raise PermanentTaskError("DOCUMENT_SCANNER and PATIENT_DOCUMENTS_BUCKET must be set to scan documents")
+    documents = get_patient_document_repository()
+    document = documents.get(document_id)
+    if not document:
+        raise PermanentTaskError(f"Document '{document_id}' not found")
+    if document.status != "pending-scan":
+        return
+
+    result = scanner.scan(read_blob(bucket_name, document.blob_name))
+    subject = f"patients/{document.patient_id}/documents/{document_id}"
+    with unit_of_work():
+        scanned = documents.record_scan(document_id, None if result.clean else result.threat or "unknown")
+        get_event_publisher().emit("document.uploaded" if result.clean else "document.quarantined", subject, scanned)
+    if not result.clean:
+        logging.warning(f"Quarantined document {document_id} of patient {document.patient_id}: {result.threat}", extra={"report_error": True})
+
+
+def _queued_notification(notification_id: str):
+    """The notifications store and the notification, or None once it is no longer queued."""
+    notifications = get_notification_repository()
+    notification = notifications.get(notification_id)
+    if not notification:
+        raise PermanentTaskError(f"Notification '{notification_id}' not found")
+    return notifications, notification if notification.status == "queued" else None
+
+
+def _template_context(organization: Optional[schemas.Organization], context: Dict[str, Any]) -> TemplateContext:
+    return TemplateContext(
+        organization_name=organization.name if organization else settings.email_from_name,
+        portal_url=settings.patient_portal_url,
+        timezone=ZoneInfo(context.get("timezone") or settings.email_timezone),
+    )
+
+
+@task(EMAIL_NOTIFICATION_TASK)
+def send_email_notification(payload: Dict[str, Any]) -> None:
+    """
+    Renders a queued notification and hands it to EMAIL_PROVIDER, from the
+    sender identity of the organization it was queued for, or
+    EMAIL_FROM_ADDRESS. Notifications no longer queued are skipped. A
+    provider that cannot be reached is retried; one that refuses the
+    message fails the notification. If the provider accepted the message
+    but recording that failed, the retry sends it again.
+    """
+    notification_id = payload["notificationId"]
+    sender = get_email_sender()
+    if sender is None:
+        raise PermanentTaskError("EMAIL_PROVIDER must be set to send email notifications")
+    notifications, notification = _queued_notification(notification_id)
+    if not notification:
+        return
+
+    tenant = current_tenant()
+    organization = get_organization_repository().get(tenant) if tenant else None
+    common = _template_context(organization, notification.context)
+    identity = (organization.email_sender if organization else None) or schemas.EmailSenderIdentity(
+        address=settings.email_from_address, name=settings.email_from_name,
+    )
+    try:
+        rendered = render(notification.template, notification.context, common)
+    except (KeyError, ValueError) as e:
+        notifications.mark_failed(notification_id, f"Cannot render {notification.template}: {e}")
+        raise PermanentTaskError(f"Notification '{notification_id}' cannot be rendered: {e}")
+
+    message = OutgoingEmail(
+        notification_id=notification_id, to=notification.recipient, from_address=identity.address,
+        from_name=identity.name or common.organization_name, reply_to=identity.reply_to,
+        subject=rendered.subject, text=rendered.text, html=rendered.html,
+    )
+    try:
+        provider_message_id = sender.send(message)
+    except EmailRejected as e:
+        notifications.mark_failed(notification_id, str(e))
+        raise PermanentTaskError(f"Notification '{notification_id}' was refused: {e}")
+    notifications.mark_sent(notification_id, sender.name, identity.address, rendered.subject, provider_message_id)
+    logging.info(f"Sent {notification.template} notification {notification_id} to patient {notification.patient_id} via {sender.name}")
+
+
+@task(SMS_NOTIFICATION_TASK)
+def send_sms_notification(payload: Dict[str, Any]) -> None:
+    """
+    Renders a queued notification as a text message and hands it to
+    SMS_PROVIDER. A patient who opted out since it was queued is not texted,
+    and a recipient the provider reports as opted out is recorded as such
+    on every patient with that number. Otherwise like the email job:
+    outages are retried, refusals fail the notification.
+    """
+    notification_id = payload["notificationId"]
+    sender = get_sms_sender()
+    if sender is None:
+        raise PermanentTaskError("SMS_PROVIDER must be set to send text notifications")
+    notifications, notification = _queued_notification(notification_id)
+    if not notification:
+        return
+    patients = get_patient_repository()
+    patient = patients.get(notification.patient_id) if notification.patient_id else None
+    if patient and patient.contact_preferences.sms_opt_out:
+        notifications.mark_failed(notification_id, "The patient opted out of text messages")
+        return
+
+    tenant = current_tenant()
+    organization = get_organization_repository().get(tenant) if tenant else None
+    try:
+        body = render_sms(notification.template, notification.context, _template_context(organization, notification.context))
+    except (KeyError, ValueError) as e:
+        notifications.mark_failed(notification_id, f"Cannot render {notification.template}: {e}")
+        raise PermanentTaskError(f"Notification '{notification_id}' cannot be rendered: {e}")
+
+    try:
+        provider_message_id = sender.send(OutgoingSms(notification_id=notification_id, to=notification.recipient, body=body))
+    except SmsRecipientOptedOut as e:
+        patients.set_sms_opt_out(notification.recipient, True)
+        notifications.mark_failed(notification_id, str(e))
+        return
+    except SmsRejected as e:
+        notifications.mark_failed(notification_id, str(e))
+        raise PermanentTaskError(f"Notification '{notification_id}' was refused: {e}")
+    notifications.mark_sent(notification_id, sender.name, sender.sender, None, provider_message_id)
+    logging.info(f"Sent {notification.template} notification {notification_id} to patient {notification.patient_id} via {sender.name}")
+
+
+@task(PUSH_NOTIFICATION_TASK)
+def send_push_notification(payload: Dict[str, Any]) -> None:
+    """
+    Renders a queued notification as a push notification and sends it to
+    every device the patient registered, unless they turned its category
+    off since. Tokens FCM reports as no longer valid are removed. The
+    notification is sent once any device accepted it; if none did, it is
+    retried while FCM was unavailable for some, and fails otherwise.
+    """
+    notification_id = payload["notificationId"]
+    sender = get_push_sender()
+    if sender is None:
+        raise PermanentTaskError("PUSH_PROVIDER must be set to send push notifications")
+    notifications, notification = _queued_notification(notification_id)
+    if not notification:
+        return
+    patient = get_patient_repository().get(notification.patient_id) if notification.patient_id else None
+    if not patient:
+        notifications.mark_failed(notification_id, "The patient no longer exists")
+        return
+    if not push_allowed(patient, notification.template):
+        notifications.mark_failed(notification_id, f"The patient turned off {PUSH_CATEGORIES.get(notification.template)} push notifications")
+        return
+    push_tokens = get_push_token_repository()
+    tokens = push_tokens.list(patient.patient_id)
+    if not tokens:
+        notifications.mark_failed(notification_id, "The patient has no registered devices")
+        return
+
+    tenant = current_tenant()
+    organization = get_organization_repository().get(tenant) if tenant else None
+    try:
+        rendered = render_push(notification.template, notification.context, _template_context(organization, notification.context))
+    except (KeyError, ValueError) as e:
+        notifications.mark_failed(notification_id, f"Cannot render {notification.template}: {e}")
+        raise PermanentTaskError(f"Notification '{notification_id}' cannot be rendered: {e}")
+
+    data = {
+        "notificationId": notification_id, "template": notification.template,
+        "category": PUSH_CATEGORIES[notification.template], "about": notification.about, "link": rendered.link,
+    }
+    message = OutgoingPush(
+        notification_id=notification_id, tokens=tuple(t.token for t in tokens), title=rendered.title, body=rendered.body,
+        data={key: value for key, value in data.items() if value},
+    )
+    result = sender.send(message)
+    for token in result.invalid_tokens:
+        try:
+            push_tokens.delete(push_token_id(token))
+        except NotFoundError:
+            pass
+    if result.message_ids:
+        notifications.mark_sent(notification_id, sender.name, sender.sender, rendered.title, result.message_ids[0])
+        logging.info(
+            f"Sent {notification.template} notification {notification_id} to {len(result.message_ids)} device(s) "
+            f"of patient {notification.patient_id} via {sender.name}"
+        )
+    elif result.retryable:
+        raise PushDeliveryError(f"{sender.name} could not take notification {notification_id} for {result.retryable} device(s)")
+    else:
+        notifications.mark_failed(notification_id, "No registered device accepted the notification")
+
+
+@task(APPOINTMENT_REMINDER_TASK)
+def send_appointment_reminder(payload: Dict[str, Any]) -> None:
+    """
+    Sends an appointment reminder queued for its due time, unless it was
+    cancelled, already sent by the sweep, or the appointment has since
+    been cancelled or moved.
+    """
+    # Imported here: the notifier, and reminders through it, name the
+    # notification jobs above.
+    from app.dependencies.notifications import get_notifier
+    from app.reminders.scheduling import send_reminder
+
+    reminder = send_reminder(payload["reminderId"], get_notifier(get_job_task_queue()))
+    if reminder and reminder.status == "skipped":
+        logging.info(f"Skipped reminder {reminder.reminder_id} of appointment {reminder.appointment_id}: {reminder.reason}")
+
+
+@task(MAGIC_LINK_EMAIL_TASK)
+def send_magic_link(payload: Dict[str, Any]) -> None:
+    """
+    Emails a sign-in link from EMAIL_FROM_ADDRESS. Links used or expired
+    since they were asked for are skipped, and a refused address is not
+    retried; the user can ask for another link.
+    """
+    link_id = payload["linkId"]
+    sender = get_email_sender()
+    links = get_magic_links()
+    if sender is None or links is None:
+        raise PermanentTaskError("EMAIL_PROVIDER, SESSION_SIGNING_KEY and a Firebase user directory are needed to send sign-in links")
+    record = get_magic_link_repository().get(link_id)
+    if not record:
+        raise PermanentTaskError(f"Magic link '{link_id}' not found")
+    if record.status != "pending" or record.expires_at <= datetime.now(timezone.utc):
+        return
+
+    try:
+        url = links.url(record)
+    except ValueError as e:
+        raise PermanentTaskError(str(e))
+    common = _template_context(None, {})
+    rendered = render_sign_in_link(url, settings.magic_link_ttl_seconds // 60, common)
+    message = OutgoingEmail(
+        notification_id=f"magic-link-{link_id}", to=record.email, from_address=settings.email_from_address,
+        from_name=settings.email_from_name, reply_to=None,
+        subject=rendered.subject, text=rendered.text, html=rendered.html,
+    )
+    try:
+        sender.send(message)
+    except EmailRejected as e:
+        raise PermanentTaskError(f"Sign-in link {link_id} was refused: {e}")
+    logging.info(f"Sent sign-in link {link_id} to user {record.uid} via {sender.name}")
+
+
+@task(STAFF_ACCOUNT_EMAIL_TASK)
+def send_staff_account_link(payload: Dict[str, Any]) -> None:
+    """
+    Emails a staff member a link to set their password, from the sender
+    identity of their organization or EMAIL_FROM_ADDRESS: an invitation
+    (`invited`) or a credential reset. Deactivated accounts are skipped.
+    """
+    uid = payload["uid"]
+    sender = get_email_sender()
+    accounts = get_user_accounts()
+    if sender is None or accounts is None:
+        raise PermanentTaskError("EMAIL_PROVIDER and AUTH_PROVIDER=firebase are needed to email staff account links")
+    user = accounts.get(uid)
+    if not user or not user.email:
+        raise PermanentTaskError(f"User '{uid}' not found or has no email address")
+    if user.status == "deactivated":
+        return
+
+    tenant = current_tenant()
+    organization = get_organization_repository().get(tenant) if tenant else None
+    common = _template_context(organization, {})
+    identity = (organization.email_sender if organization else None) or schemas.EmailSenderIdentity(
+        address=settings.email_from_address, name=settings.email_from_name,
+    )
+    invited = bool(payload.get("invited"))
+    rendered = render_staff_account_link(accounts.password_link(user.email), invited, common)
+    message = OutgoingEmail(
+        notification_id=f"staff-account-{uid}", to=user.email, from_address=identity.address,
+        from_name=identity.name or common.organization_name, reply_to=identity.reply_to,
+        subject=rendered.subject, text=rendered.text, html=rendered.html,
+    )
+    try:
+        sender.send(message)
+    except EmailRejected as e:
+        raise PermanentTaskError(f"{'Invitation' if invited else 'Credential reset'} of user {uid} was refused: {e}")
+    logging.info(f"Sent {'an invitation' if invited else 'a credential reset'} to user {uid} via {sender.name}")
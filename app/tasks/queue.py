@@ -0,0 +1,144 @@
+# Location: app/tasks/queue.py
+
+import json
+import logging
+from abc import ABC, abstractmethod
+from datetime import datetime, timedelta, timezone
+from functools import lru_cache
+from typing import Any, Dict, Optional
+
+from fastapi import BackgroundTasks
+
+from app.core.config import get_settings
+from app.tasks.registry import run_task
+from app.tenancy.context import ORGANIZATION_HEADER, current_tenant
+
+# Cloud Tasks calls back at this path under TASKS_HANDLER_URL (see app/api/internal/endpoints/tasks.py).
+TASKS_PATH = "/internal/tasks"
+
+# --- Configuration ---
+settings = get_settings()
+GOOGLE_CLOUD_PROJECT = settings.google_cloud_project
+
+
+@lru_cache
+def get_tasks_client():
+    """Returns a process-wide Cloud Tasks client."""
+    from google.cloud import tasks_v2
+    return tasks_v2.CloudTasksClient()
+
+
+def queue_path(queue: str, location: Optional[str] = None, project: Optional[str] = None) -> str:
+    """Accepts a queue ID or a full `projects/{p}/locations/{l}/queues/{q}` path and returns the full path."""
+    if queue.startswith("projects/"):
+        return queue
+    project = project or GOOGLE_CLOUD_PROJECT
+    if not project or not location:
+        raise ValueError(f"Cannot resolve Cloud Tasks queue '{queue}' without GOOGLE_CLOUD_PROJECT and TASKS_LOCATION.")
+    return f"projects/{project}/locations/{location}/queues/{queue}"
+
+
+class TaskQueue(ABC):
+    """
+    Defers work to run outside the request that asks for it. Jobs are
+    referred to by the name their handler is registered under (see
+    `app.tasks.registry.task`) and take a JSON-serialisable payload.
+    """
+
+    # The longest `delay_seconds` the queue honours; 0 if it runs every job
+    # at once. Jobs due later have to be queued nearer the time.
+    max_delay_seconds = 0
+
+    @abstractmethod
+    def enqueue(self, name: str, payload: Dict[str, Any], delay_seconds: int = 0, task_id: Optional[str] = None) -> None:
+        """
+        Schedules the job. A `task_id` makes enqueueing idempotent: a second
+        job with the same ID is dropped while the first is known to the queue.
+        """
+
+    def cancel(self, task_id: str) -> None:
+        """
+        Drops a job queued under `task_id` that has not run yet, where the
+        queue can; jobs must still check that they are wanted when they run.
+        """
+
+
+class CloudTasksQueue(TaskQueue):
+    """
+    Creates an HTTP task per job that POSTs the payload back to this service
+    at `/internal/tasks/{name}`, authenticated with an OIDC token for
+    `service_account`. Cloud Tasks retries the call, with the queue's retry
+    policy, until the handler returns 2xx. A job queued for an organization
+    names it in X-Organization-Id, and runs scoped to it.
+    """
+
+    # Cloud Tasks refuses schedule times more than 30 days ahead.
+    max_delay_seconds = 30 * 24 * 3600 - 3600
+
+    def __init__(self, queue: str, handler_url: str, service_account: str, audience: Optional[str] = None):
+        self.queue = queue
+        self.handler_url = handler_url.rstrip("/")
+        self.service_account = service_account
+        self.audience = audience or self.handler_url
+
+    def enqueue(self, name: str, payload: Dict[str, Any], delay_seconds: int = 0, task_id: Optional[str] = None) -> None:
+        from google.api_core.exceptions import AlreadyExists
+
+        headers = {"Content-Type": "application/json"}
+        tenant = current_tenant()
+        if tenant:
+            headers[ORGANIZATION_HEADER] = tenant
+        task: Dict[str, Any] = {
+            "http_request": {
+                "http_method": "POST",
+                "url": f"{self.handler_url}{TASKS_PATH}/{name}",
+                "headers": headers,
+                "body": json.dumps(payload, default=str, separators=(",", ":")).encode("utf-8"),
+                "oidc_token": {"service_account_email": self.service_account, "audience": self.audience},
+            }
+        }
+        if task_id:
+            task["name"] = f"{self.queue}/tasks/{task_id}"
+        if delay_seconds:
+            task["schedule_time"] = datetime.now(timezone.utc) + timedelta(seconds=delay_seconds)
+        try:
+            created = get_tasks_client().create_task(parent=self.queue, task=task)
+        except AlreadyExists:
+            logging.info(f"Task {name} {task_id} is already queued.")
+            return
+        logging.info(f"Queued task {name} as {created.name}")
+
+    def cancel(self, task_id: str) -> None:
+        from google.api_core.exceptions import NotFound
+
+        try:
+            get_tasks_client().delete_task(name=f"{self.queue}/tasks/{task_id}")
+        except NotFound:
+            return
+        logging.info(f"Deleted task {task_id}")
+
+
+class BackgroundTaskQueue(TaskQueue):
+    """
+    Runs jobs in this worker after the response has been sent, for local
+    development and deployments without a queue. Delays are not honoured
+    and failed jobs are not retried. On Cloud Run the service needs CPU
+    allocated outside requests (`--no-cpu-throttling`) for jobs to progress.
+    """
+
+    def __init__(self, background_tasks: BackgroundTasks):
+        self.background_tasks = background_tasks
+
+    def enqueue(self, name: str, payload: Dict[str, Any], delay_seconds: int = 0, task_id: Optional[str] = None) -> None:
+        self.background_tasks.add_task(run_task, name, payload)
+
+
+class InlineTaskQueue(TaskQueue):
+    """
+    Runs jobs at once, in the caller's thread, for jobs queued outside a
+    request (e.g. by scheduled jobs) in deployments without a queue. Delays
+    are not honoured and failed jobs are not retried.
+    """
+
+    def enqueue(self, name: str, payload: Dict[str, Any], delay_seconds: int = 0, task_id: Optional[str] = None) -> None:
+        run_task(name, payload)
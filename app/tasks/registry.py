@@ -0,0 +1,57 @@
+# Location: app/tasks/registry.py
+
+import logging
+from typing import Any, Callable, Dict, Optional
+
+from app.core.metrics import TASK_RUNS_TOTAL
+
+TaskHandler = Callable[[Dict[str, Any]], None]
+
+_HANDLERS: Dict[str, TaskHandler] = {}
+
+
+class PermanentTaskError(Exception):
+    """Raised by a handler when retrying cannot help, e.g. the record it works on is gone."""
+
+
+def task(name: str) -> Callable[[TaskHandler], TaskHandler]:
+    """
+    Registers a handler for deferred jobs of the given name:
+
+        @task("encounter-discharge-summary")
+        def write_discharge_summary(payload: Dict[str, Any]) -> None: ...
+
+    Handlers may run more than once for the same job (queues deliver
+    at-least-once), so they must be idempotent.
+    """
+    def register(handler: TaskHandler) -> TaskHandler:
+        if name in _HANDLERS:
+            raise ValueError(f"Task '{name}' is already registered.")
+        _HANDLERS[name] = handler
+        return handler
+    return register
+
+
+def get_handler(name: str) -> Optional[TaskHandler]:
+    return _HANDLERS.get(name)
+
+
+def run_task(name: str, payload: Dict[str, Any]) -> bool:
+    """
+    Runs a registered job. Returns False if it failed permanently; any
+    other exception is raised so the queue retries the job.
+    """
+    handler = _HANDLERS.get(name)
+    if handler is None:
+        raise LookupError(f"Unknown task '{name}'.")
+    try:
+        handler(payload)
+    except PermanentTaskError as e:
+        logging.error(f"Task {name} failed permanently: {e}", extra={"report_error": True})
+        TASK_RUNS_TOTAL.labels(task=name, result="failed").inc()
+        return False
+    except Exception:
+        TASK_RUNS_TOTAL.labels(task=name, result="retried").inc()
+        raise
+    TASK_RUNS_TOTAL.labels(task=name, result="succeeded").inc()
+    return True
@@ -0,0 +1,218 @@
+# Location: app/telehealth/providers.py
+
+import time
+from abc import ABC, abstractmethod
+from datetime import datetime
+from functools import lru_cache
+from typing import Optional
+
+import httpx
+import jwt
+
+from app.core.config import get_settings
+from app.core.http_client import new_client
+
+# Where Twilio reports a room's participants joining and leaving, relative
+# to TWILIO_WEBHOOK_BASE_URL. Daily and LiveKit send their webhooks to the
+# URL set in their dashboards (see the README).
+TWILIO_STATUS_CALLBACK_PATH = "/integrations/telehealth/twilio/status"
+
+# Twilio's error for a room name already in use, e.g. a retried create.
+TWILIO_ROOM_EXISTS = 53113
+
+
+class VideoProviderError(Exception):
+    """Raised when the provider could not be reached or refused the request."""
+
+
+class VideoProvider(ABC):
+    """Opens and closes rooms at a video provider and lets participants in."""
+
+    name: str
+
+    @abstractmethod
+    def create_room(self, room_name: str, expires_at: datetime) -> Optional[str]:
+        """Opens the room, to close by `expires_at` at the latest; returns the URL clients join it at, if it has one."""
+
+    @abstractmethod
+    def join_token(self, room_name: str, identity: str, expires_at: datetime, moderator: bool) -> str:
+        """A token letting `identity` into the room until `expires_at`; moderators may remove others."""
+
+    @abstractmethod
+    def end_room(self, room_name: str) -> None:
+        """Closes the room, disconnecting everyone in it. A room already closed is not an error."""
+
+
+def _unix(at: datetime) -> int:
+    return int(at.timestamp())
+
+
+class TwilioVideoProvider(VideoProvider):
+    """
+    Twilio Video group rooms. Tokens are Access Tokens signed with an API
+    key (TWILIO_API_KEY_SID / TWILIO_API_KEY_SECRET); rooms are created
+    with a status callback, so participant events reach us.
+    """
+
+    name = "twilio"
+    API_URL = "https://video.twilio.com/v1/Rooms"
+
+    def __init__(
+        self, account_sid: str, api_key_sid: str, api_key_secret: str, webhook_base_url: Optional[str],
+        timeout: float, client: Optional[httpx.Client] = None,
+    ):
+        self.account_sid = account_sid
+        self.api_key_sid = api_key_sid
+        self.api_key_secret = api_key_secret
+        self.webhook_base_url = webhook_base_url.rstrip("/") if webhook_base_url else None
+        self.client = client or new_client("twilio-video", timeout)
+
+    def _post(self, url: str, data: dict) -> httpx.Response:
+        try:
+            response = self.client.post(url, data=data, auth=(self.api_key_sid, self.api_key_secret))
+        except httpx.HTTPError as e:
+            raise VideoProviderError(f"Twilio is unavailable: {e}") from e
+        if response.status_code >= 500 or response.status_code == 429:
+            raise VideoProviderError(f"Twilio answered {response.status_code}")
+        return response
+
+    def create_room(self, room_name: str, expires_at: datetime) -> Optional[str]:
+        # Twilio disconnects participants after MaxParticipantDuration (10 minutes to 24 hours).
+        data = {"UniqueName": room_name, "Type": "group", "MaxParticipantDuration": min(86400, max(600, _unix(expires_at) - int(time.time())))}
+        if self.webhook_base_url:
+            data.update({"StatusCallback": f"{self.webhook_base_url}{TWILIO_STATUS_CALLBACK_PATH}", "StatusCallbackMethod": "POST"})
+        response = self._post(self.API_URL, data)
+        if response.status_code >= 300 and self._error_code(response) != TWILIO_ROOM_EXISTS:
+            raise VideoProviderError(f"Twilio refused to create the room ({response.status_code}): {response.text}")
+        return None
+
+    @staticmethod
+    def _error_code(response: httpx.Response) -> Optional[int]:
+        try:
+            return response.json().get("code")
+        except ValueError:
+            return None
+
+    def join_token(self, room_name: str, identity: str, expires_at: datetime, moderator: bool) -> str:
+        now = int(time.time())
+        claims = {
+            "jti": f"{self.api_key_sid}-{now}",
+            "iss": self.api_key_sid,
+            "sub": self.account_sid,
+            "iat": now,
+            "exp": _unix(expires_at),
+            "grants": {"identity": identity, "video": {"room": room_name}},
+        }
+        return jwt.encode(claims, self.api_key_secret, algorithm="HS256", headers={"cty": "twilio-fpa;v=1"})
+
+    def end_room(self, room_name: str) -> None:
+        response = self._post(f"{self.API_URL}/{room_name}", {"Status": "completed"})
+        if response.status_code >= 300 and response.status_code not in (400, 404):
+            raise VideoProviderError(f"Twilio refused to end the room ({response.status_code}): {response.text}")
+
+
+class DailyVideoProvider(VideoProvider):
+    """
+    Daily private rooms, which expire at the end of the visit. Tokens are
+    meeting tokens from Daily's REST API; staff get owner tokens.
+    """
+
+    name = "daily"
+    API_URL = "https://api.daily.co/v1"
+
+    def __init__(self, api_key: str, timeout: float, client: Optional[httpx.Client] = None):
+        self.api_key = api_key
+        self.client = client or new_client("daily", timeout)
+
+    def _request(self, method: str, path: str, json: Optional[dict] = None) -> httpx.Response:
+        try:
+            response = self.client.request(method, f"{self.API_URL}{path}", json=json, headers={"Authorization": f"Bearer {self.api_key}"})
+        except httpx.HTTPError as e:
+            raise VideoProviderError(f"Daily is unavailable: {e}") from e
+        if response.status_code >= 500 or response.status_code == 429:
+            raise VideoProviderError(f"Daily answered {response.status_code}")
+        return response
+
+    def create_room(self, room_name: str, expires_at: datetime) -> Optional[str]:
+        response = self._request("POST", "/rooms", {
+            "name": room_name,
+            "privacy": "private",
+            "properties": {"exp": _unix(expires_at), "eject_at_room_exp": True},
+        })
+        if response.status_code >= 300:
+            # A retried create finds the room made the first time.
+            existing = self._request("GET", f"/rooms/{room_name}")
+            if existing.status_code != 200:
+                raise VideoProviderError(f"Daily refused to create the room ({response.status_code}): {response.text}")
+            response = existing
+        return response.json().get("url")
+
+    def join_token(self, room_name: str, identity: str, expires_at: datetime, moderator: bool) -> str:
+        properties = {"room_name": room_name, "user_id": identity, "exp": _unix(expires_at), "is_owner": moderator}
+        response = self._request("POST", "/meeting-tokens", {"properties": properties})
+        if response.status_code >= 300:
+            raise VideoProviderError(f"Daily refused the meeting token ({response.status_code}): {response.text}")
+        return response.json()["token"]
+
+    def end_room(self, room_name: str) -> None:
+        response = self._request("DELETE", f"/rooms/{room_name}")
+        if response.status_code >= 300 and response.status_code != 404:
+            raise VideoProviderError(f"Daily refused to delete the room ({response.status_code}): {response.text}")
+
+
+class LiveKitVideoProvider(VideoProvider):
+    """
+    A LiveKit server (LiveKit Cloud or our own). Rooms are created when the
+    first participant joins; tokens are signed with the API key and secret.
+    """
+
+    name = "livekit"
+
+    def __init__(self, url: str, api_key: str, api_secret: str, timeout: float, client: Optional[httpx.Client] = None):
+        self.url = url.rstrip("/")
+        self.api_key = api_key
+        self.api_secret = api_secret
+        self.client = client or new_client("livekit", timeout)
+
+    def _token(self, identity: Optional[str], video: dict, expires_at: int) -> str:
+        claims = {"iss": self.api_key, "nbf": int(time.time()), "exp": expires_at, "video": video}
+        if identity:
+            claims["sub"] = identity
+        return jwt.encode(claims, self.api_secret, algorithm="HS256")
+
+    def create_room(self, room_name: str, expires_at: datetime) -> Optional[str]:
+        return self.url
+
+    def join_token(self, room_name: str, identity: str, expires_at: datetime, moderator: bool) -> str:
+        video = {"room": room_name, "roomJoin": True, "canPublish": True, "canSubscribe": True, "roomAdmin": moderator}
+        return self._token(identity, video, _unix(expires_at))
+
+    def end_room(self, room_name: str) -> None:
+        # The server API is served over HTTPS at the address clients reach with WebSockets.
+        api_url = "https://" + self.url.split("://", 1)[-1]
+        token = self._token(None, {"roomCreate": True}, int(time.time()) + 60)
+        try:
+            response = self.client.post(
+                f"{api_url}/twirp/livekit.RoomService/DeleteRoom", json={"room": room_name},
+                headers={"Authorization": f"Bearer {token}"},
+            )
+        except httpx.HTTPError as e:
+            raise VideoProviderError(f"LiveKit is unavailable: {e}") from e
+        if response.status_code >= 300 and response.status_code != 404:
+            raise VideoProviderError(f"LiveKit refused to delete the room ({response.status_code}): {response.text}")
+
+
+@lru_cache
+def get_video_provider() -> Optional[VideoProvider]:
+    """Returns the configured provider, or None with TELEHEALTH_PROVIDER=none."""
+    settings = get_settings()
+    if settings.telehealth_provider == "twilio":
+        return TwilioVideoProvider(
+            settings.twilio_account_sid, settings.twilio_api_key_sid, settings.twilio_api_key_secret,
+            settings.twilio_webhook_base_url, settings.telehealth_timeout_seconds,
+        )
+    if settings.telehealth_provider == "daily":
+        return DailyVideoProvider(settings.daily_api_key, settings.telehealth_timeout_seconds)
+    if settings.telehealth_provider == "livekit":
+        return LiveKitVideoProvider(settings.livekit_url, settings.livekit_api_key, settings.livekit_api_secret, settings.telehealth_timeout_seconds)
+    return None
@@ -0,0 +1,116 @@
+# Location: app/telehealth/webhooks.py
+
+import base64
+import hashlib
+import hmac
+from datetime import datetime, timezone
+from typing import Any, Dict, Optional, Tuple
+
+import jwt
+
+from app.api.v1 import schemas
+from app.services.telehealth import role_of_identity
+
+# Each provider reports its rooms' participants joining and leaving, and
+# the room closing, as webhooks that are sent at least once and not always
+# in order. They are read here into session events, each with the
+# provider's ID so a report sent again is recorded once; rooms are named
+# after their session (see app/services/telehealth.py).
+RoomEvent = Tuple[str, schemas.TelehealthSessionEvent]
+
+# Twilio Video status callbacks, signed like every Twilio webhook (see
+# app/notifications/twilio_events.py).
+TWILIO_EVENTS = {
+    "participant-connected": "participant_joined",
+    "participant-disconnected": "participant_left",
+    "room-ended": "ended",
+}
+
+# Daily signs the timestamp and raw body with HMAC-SHA256, keyed with the
+# webhook's base64 secret.
+DAILY_SIGNATURE_HEADER = "X-Webhook-Signature"
+DAILY_TIMESTAMP_HEADER = "X-Webhook-Timestamp"
+DAILY_EVENTS = {
+    "participant.joined": "participant_joined",
+    "participant.left": "participant_left",
+    "meeting.ended": "ended",
+}
+
+# LiveKit sends a JWT signed with the API secret whose `sha256` claim is the
+# base64 SHA-256 of the body.
+LIVEKIT_EVENTS = {
+    "participant_joined": "participant_joined",
+    "participant_left": "participant_left",
+    "room_finished": "ended",
+}
+
+
+def _event(kind: str, at: datetime, identity: Optional[str], provider_event_id: Optional[str]) -> schemas.TelehealthSessionEvent:
+    if kind == "ended":
+        identity = None
+    return schemas.TelehealthSessionEvent(
+        type=kind, at=at, identity=identity, role=role_of_identity(identity), provider_event_id=provider_event_id,
+    )
+
+
+def _from_unix(value: Any) -> datetime:
+    try:
+        return datetime.fromtimestamp(float(value), tz=timezone.utc)
+    except (TypeError, ValueError):
+        return datetime.now(timezone.utc)
+
+
+def twilio_event(params: Dict[str, str]) -> Optional[RoomEvent]:
+    """The session event of a Twilio room status callback, or None for the events not recorded."""
+    kind = TWILIO_EVENTS.get(params.get("StatusCallbackEvent", ""))
+    if not kind or not params.get("RoomName"):
+        return None
+    try:
+        at = datetime.fromisoformat(params.get("Timestamp", "").replace("Z", "+00:00"))
+    except ValueError:
+        at = datetime.now(timezone.utc)
+    # A room's callbacks are numbered in the order Twilio sent them.
+    event_id = f"{params.get('RoomSid')}:{params['SequenceNumber']}" if params.get("SequenceNumber") else None
+    return params["RoomName"], _event(kind, at, params.get("ParticipantIdentity"), event_id)
+
+
+def verify_daily_signature(secret: str, payload: bytes, signature: str, timestamp: str) -> bool:
+    """Checks Daily's signature of a webhook body."""
+    try:
+        key = base64.b64decode(secret)
+    except ValueError:
+        return False
+    signed = timestamp.encode() + b"." + payload
+    expected = base64.b64encode(hmac.new(key, signed, hashlib.sha256).digest()).decode()
+    return hmac.compare_digest(expected, signature)
+
+
+def daily_event(body: Dict[str, Any]) -> Optional[RoomEvent]:
+    """The session event of a Daily webhook, or None for the events not recorded."""
+    kind = DAILY_EVENTS.get(body.get("type", ""))
+    payload = body.get("payload") or {}
+    if not kind or not isinstance(payload, dict) or not payload.get("room"):
+        return None
+    return payload["room"], _event(kind, _from_unix(body.get("event_ts")), payload.get("user_id"), body.get("id"))
+
+
+def verify_livekit_token(api_key: str, api_secret: str, payload: bytes, authorization: str) -> bool:
+    """Checks the token LiveKit sent with a webhook body."""
+    token = authorization.removeprefix("Bearer ").strip()
+    try:
+        claims = jwt.decode(token, api_secret, algorithms=["HS256"], issuer=api_key, options={"require": ["iss", "sha256"]})
+    except jwt.InvalidTokenError:
+        return False
+    expected = base64.b64encode(hashlib.sha256(payload).digest()).decode()
+    return hmac.compare_digest(expected, str(claims["sha256"]))
+
+
+def livekit_event(body: Dict[str, Any]) -> Optional[RoomEvent]:
+    """The session event of a LiveKit webhook, or None for the events not recorded."""
+    kind = LIVEKIT_EVENTS.get(body.get("event", ""))
+    room = body.get("room") or {}
+    if not kind or not isinstance(room, dict) or not room.get("name"):
+        return None
+    participant = body.get("participant") or {}
+    identity = participant.get("identity") if isinstance(participant, dict) else None
+    return room["name"], _event(kind, _from_unix(body.get("createdAt")), identity, body.get("id"))
@@ -0,0 +1,81 @@
+# Location: app/tenancy/backfill.py
+
+"""
+Assigns the records written before tenancy was enabled to an organization.
+With TENANCY_ENABLED=true, records without a `tenantId` are invisible to
+every organization, so a deployment that served a single clinic runs this
+once for that clinic, after registering it and before turning tenancy on:
+
+    python -m app.tenancy.backfill <organizationId>
+
+Records that already belong to an organization are left alone, so the
+command can be re-run, e.g. after a partial failure.
+"""
+
+import logging
+import sys
+from typing import Callable, Dict, List
+
+from app.api.v1 import deps
+from app.cache.patients import CachedPatientRepository
+
+# The repositories of tenant-scoped records; platform records (organizations,
+# feature flags, the outbox, job locks and runs, idempotency records) are
+# not assigned.
+SCOPED_REPOSITORIES: Dict[str, Callable] = {
+    "patients": deps.get_patient_repository,
+    "practitioners": deps.get_practitioner_repository,
+    "careTeams": deps.get_care_team_repository,
+    "carePlans": deps.get_care_plan_repository,
+    "appointments": deps.get_appointment_repository,
+    "appointmentReminders": deps.get_appointment_reminder_repository,
+    "encounters": deps.get_encounter_repository,
+    "observations": deps.get_observation_repository,
+    "labResults": deps.get_lab_result_repository,
+    "medications": deps.get_medication_repository,
+    "refillRequests": deps.get_refill_request_repository,
+    "allergies": deps.get_allergy_repository,
+    "immunizations": deps.get_immunization_repository,
+    "patientDocuments": deps.get_patient_document_repository,
+    "woundImages": deps.get_wound_image_repository,
+    "notifications": deps.get_notification_repository,
+    "pushTokens": deps.get_push_token_repository,
+    "consents": deps.get_consent_repository,
+    "consentDocuments": deps.get_consent_document_repository,
+    "exportJobs": deps.get_export_job_repository,
+    "importJobs": deps.get_import_job_repository,
+    "auditEvents": deps.get_audit_event_repository,
+    "webhookSubscriptions": deps.get_webhook_subscription_repository,
+    "webhookDeliveries": deps.get_webhook_delivery_repository,
+    "apiKeys": deps.get_api_key_repository,
+}
+
+
+def backfill(organization_id: str) -> Dict[str, int]:
+    """Stamps `organization_id` on every scoped record without a tenant. Returns the counts by resource."""
+    counts = {}
+    for resource, get_repository in SCOPED_REPOSITORIES.items():
+        repo = get_repository()
+        if isinstance(repo, CachedPatientRepository):
+            repo = repo.repo
+        counts[resource] = repo.assign_tenant(organization_id)
+        logging.info(f"Assigned {counts[resource]} {resource} record(s) to organization {organization_id}")
+    return counts
+
+
+def main(argv: List[str]) -> int:
+    usage = "usage: python -m app.tenancy.backfill <organizationId>"
+    if len(argv) != 1:
+        print(usage, file=sys.stderr)
+        return 2
+    if deps.get_organization_repository().get(argv[0]) is None:
+        print(f"error: organization '{argv[0]}' is not registered", file=sys.stderr)
+        return 1
+    counts = backfill(argv[0])
+    print(f"Assigned {sum(counts.values())} record(s) to {argv[0]}.")
+    return 0
+
+
+if __name__ == "__main__":
+    logging.basicConfig(level=logging.INFO)
+    sys.exit(main(sys.argv[1:]))
@@ -0,0 +1,43 @@
+# Location: app/tenancy/context.py
+
+from contextlib import contextmanager
+from contextvars import ContextVar
+from typing import Any, Dict, Iterator, Optional
+
+# --- Tenant ---
+# The organization (clinic) a request acts for, set by TenancyMiddleware from
+# the caller's `organizationId` claim. Repositories read it to scope every
+# query and to stamp new records (see app/repositories/base.py). None means
+# unscoped: tenancy is off, or the caller is a platform administrator or a
+# system job working across organizations.
+ORGANIZATION_ID_CLAIM = "organizationId"
+# Platform administrators may act for any organization by naming it here.
+ORGANIZATION_HEADER = "X-Organization-Id"
+PLATFORM_ADMIN = "platform-admin"
+
+tenant_var: ContextVar[Optional[str]] = ContextVar("tenant", default=None)
+
+
+def current_tenant() -> Optional[str]:
+    return tenant_var.get()
+
+
+@contextmanager
+def acting_for(organization_id: Optional[str]) -> Iterator[None]:
+    """Scopes repository access in the block to `organization_id` (None: unscoped)."""
+    token = tenant_var.set(organization_id)
+    try:
+        yield
+    finally:
+        tenant_var.reset(token)
+
+
+def claimed_organization(claims: Dict[str, Any]) -> Optional[str]:
+    """The organization a token or API key belongs to, if it names one."""
+    value = claims.get(ORGANIZATION_ID_CLAIM)
+    return str(value) if value else None
+
+
+def is_platform_admin(claims: Dict[str, Any]) -> bool:
+    roles = claims.get("roles")
+    return isinstance(roles, list) and PLATFORM_ADMIN in roles
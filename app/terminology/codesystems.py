@@ -0,0 +1,169 @@
+# Location: app/terminology/codesystems.py
+
+import re
+from typing import Callable, Dict, Iterator, List, Literal, Optional, Set
+
+from pydantic import BaseModel, ConfigDict, Field
+
+LOINC = "http://loinc.org"
+SNOMED_CT = "http://snomed.info/sct"
+ICD_10 = "http://hl7.org/fhir/sid/icd-10"
+
+# The Verhoeff tables SNOMED CT identifiers are checked with.
+_VERHOEFF_D = [
+    [0, 1, 2, 3, 4, 5, 6, 7, 8, 9], [1, 2, 3, 4, 0, 6, 7, 8, 9, 5], [2, 3, 4, 0, 1, 7, 8, 9, 5, 6],
+    [3, 4, 0, 1, 2, 8, 9, 5, 6, 7], [4, 0, 1, 2, 3, 9, 5, 6, 7, 8], [5, 9, 8, 7, 6, 0, 4, 3, 2, 1],
+    [6, 5, 9, 8, 7, 1, 0, 4, 3, 2], [7, 6, 5, 9, 8, 2, 1, 0, 4, 3], [8, 7, 6, 5, 9, 3, 2, 1, 0, 4],
+    [9, 8, 7, 6, 5, 4, 3, 2, 1, 0],
+]
+_VERHOEFF_P = [
+    [0, 1, 2, 3, 4, 5, 6, 7, 8, 9], [1, 5, 7, 6, 2, 8, 3, 0, 9, 4], [5, 8, 0, 3, 7, 9, 6, 1, 4, 2],
+    [8, 9, 1, 6, 0, 4, 3, 5, 2, 7], [9, 4, 5, 3, 1, 2, 6, 8, 7, 0], [4, 2, 8, 6, 5, 7, 3, 9, 0, 1],
+    [2, 7, 9, 3, 8, 0, 6, 4, 1, 5], [7, 0, 4, 6, 9, 1, 3, 2, 5, 8],
+]
+
+
+def _loinc_syntax(code: str) -> Optional[str]:
+    match = re.fullmatch(r"(\d{1,7})-(\d)", code)
+    if not match:
+        return "LOINC codes are 1-7 digits, a hyphen and a check digit, e.g. 59408-5"
+    # The mod 10 check digit: double every other digit from the right and add up the digits.
+    total = 0
+    for i, digit in enumerate(int(d) for d in reversed(match.group(1))):
+        doubled = digit * 2 if i % 2 == 0 else digit
+        total += doubled // 10 + doubled % 10
+    if (10 - total % 10) % 10 != int(match.group(2)):
+        return f"'{code}' has the wrong LOINC check digit"
+    return None
+
+
+def _snomed_syntax(code: str) -> Optional[str]:
+    if not re.fullmatch(r"[1-9]\d{5,17}", code):
+        return "SNOMED CT concept IDs are 6-18 digits"
+    # Partition 00 is a core concept, 10 an extension's; others are descriptions or relationships.
+    if code[-3:-1] not in ("00", "10"):
+        return f"'{code}' is not a SNOMED CT concept ID"
+    check = 0
+    for i, digit in enumerate(int(d) for d in reversed(code)):
+        check = _VERHOEFF_D[check][_VERHOEFF_P[i % 8][digit]]
+    if check != 0:
+        return f"'{code}' has the wrong SNOMED CT check digit"
+    return None
+
+
+def _icd10_syntax(code: str) -> Optional[str]:
+    if not re.fullmatch(r"[A-Z]\d[0-9A-Z](\.[0-9A-Z]{1,4})?", code):
+        return "ICD-10 codes are a letter, two characters and an optional dotted subdivision, e.g. G47.3"
+    return None
+
+
+# Checks of the form codes of a system take, returning what is wrong with a
+# code or None. They catch typos in codes a loaded fragment does not hold.
+SYNTAX_CHECKS: Dict[str, Callable[[str], Optional[str]]] = {
+    LOINC: _loinc_syntax,
+    SNOMED_CT: _snomed_syntax,
+    ICD_10: _icd10_syntax,
+}
+
+
+class ConceptProperty(BaseModel):
+    code: str
+    value_code: Optional[str] = Field(None, alias="valueCode")
+    value_boolean: Optional[bool] = Field(None, alias="valueBoolean")
+    value_string: Optional[str] = Field(None, alias="valueString")
+    model_config = ConfigDict(populate_by_name=True, extra="ignore")
+
+
+class Designation(BaseModel):
+    value: str
+    language: Optional[str] = None
+    model_config = ConfigDict(extra="ignore")
+
+
+class Concept(BaseModel):
+    """A concept of a FHIR CodeSystem; child concepts may be nested under it."""
+    code: str
+    display: Optional[str] = None
+    designation: List[Designation] = []
+    property: List[ConceptProperty] = []
+    concept: List["Concept"] = []
+    model_config = ConfigDict(extra="ignore")
+
+
+class CodeSystem(BaseModel):
+    """
+    A FHIR R4 CodeSystem resource. `content` says how much of the system it
+    holds: with `complete`, a code it lacks does not exist; bundled and other
+    partial copies are `fragment`s, whose missing codes may still be valid.
+    """
+    resource_type: Literal["CodeSystem"] = Field(..., alias="resourceType")
+    url: str
+    version: Optional[str] = None
+    name: Optional[str] = None
+    title: Optional[str] = None
+    content: Literal["not-present", "example", "fragment", "complete", "supplement"]
+    concept: List[Concept] = []
+    model_config = ConfigDict(populate_by_name=True, extra="ignore")
+
+
+class LoadedCodeSystem:
+    """A code system indexed by code, with its is-a hierarchy."""
+
+    def __init__(self, resource: CodeSystem):
+        self.url = resource.url
+        self.version = resource.version
+        self.name = resource.title or resource.name or resource.url
+        self.complete = resource.content == "complete"
+        self.concepts: Dict[str, Concept] = {}
+        self.parents: Dict[str, Set[str]] = {}
+        self.children: Dict[str, Set[str]] = {}
+        self._index(resource.concept, None)
+
+    def _index(self, concepts: List[Concept], parent: Optional[str]) -> None:
+        for concept in concepts:
+            self.concepts[concept.code] = concept
+            parents = {p.value_code for p in concept.property if p.code == "parent" and p.value_code}
+            if parent:
+                parents.add(parent)
+            for code in parents:
+                self.parents.setdefault(concept.code, set()).add(code)
+                self.children.setdefault(code, set()).add(concept.code)
+            self._index(concept.concept, concept.code)
+
+    def merge(self, other: "LoadedCodeSystem") -> None:
+        """Adds the concepts of another copy of the system, e.g. a deployment's own fragment."""
+        self.concepts.update(other.concepts)
+        for code, parents in other.parents.items():
+            self.parents.setdefault(code, set()).update(parents)
+        for code, children in other.children.items():
+            self.children.setdefault(code, set()).update(children)
+        self.complete = self.complete or other.complete
+        self.version = other.version or self.version
+
+    def get(self, code: str) -> Optional[Concept]:
+        return self.concepts.get(code)
+
+    def descendants(self, code: str) -> Iterator[str]:
+        """The codes below `code` in the hierarchy, each once."""
+        seen, pending = set(), list(self.children.get(code, ()))
+        while pending:
+            child = pending.pop()
+            if child not in seen:
+                seen.add(child)
+                yield child
+                pending.extend(self.children.get(child, ()))
+
+    @staticmethod
+    def is_inactive(concept: Concept) -> bool:
+        return any(
+            (p.code == "inactive" and p.value_boolean) or (p.code == "status" and p.value_code in ("retired", "inactive", "deprecated"))
+            for p in concept.property
+        )
+
+    @staticmethod
+    def matches_display(concept: Concept, display: str) -> bool:
+        expected = display.strip().casefold()
+        return any(
+            label and label.strip().casefold() == expected
+            for label in [concept.display, *(d.value for d in concept.designation)]
+        )
@@ -0,0 +1,61 @@
+# Location: app/terminology/remote.py
+
+from typing import Any, Dict, List, Optional
+
+import httpx
+
+from app.api.v1 import schemas
+from app.core.http_client import new_client
+
+
+class TerminologyServerError(Exception):
+    """Raised when the terminology server could not be reached or failed. The code is then unverified."""
+
+
+def _value(part: Dict[str, Any]) -> Any:
+    return next((v for k, v in part.items() if k.startswith("value")), None)
+
+
+class FhirTerminologyServer:
+    """
+    Looks codes up with `CodeSystem/$lookup` at a FHIR R4 terminology
+    server, such as Ontoserver or Snowstorm, for codes of systems whose full
+    release is not loaded here (SNOMED CT, LOINC and ICD-10 are licensed).
+    """
+
+    def __init__(self, base_url: str, timeout: float, client: Optional[httpx.Client] = None):
+        self.base_url = base_url.rstrip("/")
+        self.client = client or new_client("terminology", timeout, headers={"Accept": "application/fhir+json"})
+
+    def lookup(self, system: str, code: str) -> Optional[schemas.CodeLookup]:
+        """The concept, or None if the server does not know the code."""
+        try:
+            response = self.client.get(f"{self.base_url}/CodeSystem/$lookup", params={"system": system, "code": code})
+        except httpx.HTTPError as e:
+            raise TerminologyServerError(f"Terminology server unreachable: {e}") from e
+        # FHIR servers answer an unknown code (or system) with 404 or 400 and an OperationOutcome.
+        if response.status_code in (400, 404, 422):
+            return None
+        if response.status_code >= 300:
+            raise TerminologyServerError(f"Terminology server answered {response.status_code}")
+        try:
+            parameters = response.json().get("parameter") or []
+        except ValueError as e:
+            raise TerminologyServerError("Terminology server sent a malformed response") from e
+
+        display, version, inactive, parents = None, None, False, []
+        for parameter in parameters:
+            name = parameter.get("name")
+            if name == "display":
+                display = _value(parameter)
+            elif name == "version":
+                version = _value(parameter)
+            elif name == "property":
+                parts: List[Dict[str, Any]] = parameter.get("part") or []
+                prop = next((_value(p) for p in parts if p.get("name") == "code"), None)
+                value = next((_value(p) for p in parts if p.get("name") == "value"), None)
+                if prop == "inactive":
+                    inactive = value is True or value == "true"
+                elif prop == "parent" and value:
+                    parents.append(str(value))
+        return schemas.CodeLookup(system=system, code=code, display=display, version=version, inactive=inactive, parents=parents)
@@ -0,0 +1,223 @@
+# Location: app/terminology/service.py
+
+"""
+Code systems and value sets, so coded fields hold real codes rather than
+any string. Codes of LOINC, SNOMED CT and ICD-10 are checked in up to three
+ways, each more thorough than the last:
+
+* Their form: LOINC and SNOMED CT codes carry a check digit, ICD-10 codes
+  have a fixed shape. This catches most typos.
+* The loaded content: FHIR CodeSystem resources, the bundled fragments
+  under `data/` (the codes this API itself uses) and those found at
+  TERMINOLOGY_PATH. A deployment licensed for a full release can load it
+  there as a `complete` CodeSystem; codes missing from it are then refused.
+* TERMINOLOGY_SERVER_URL, a FHIR terminology server asked about codes of
+  those systems that the loaded content lacks. Its answers are cached.
+
+Value sets (FHIR ValueSet resources, loaded the same way) select codes for
+a purpose, e.g. the manifestations offered when recording an allergy.
+"""
+
+import json
+import logging
+from functools import lru_cache
+from pathlib import Path
+from typing import Dict, Iterable, List, Optional, Tuple
+
+from app.api.v1 import schemas
+from app.cache.base import Cache, NullCache, read_through
+from app.core.cache import get_cache
+from app.core.config import get_settings
+from app.terminology.codesystems import SYNTAX_CHECKS, CodeSystem, LoadedCodeSystem
+from app.terminology.remote import FhirTerminologyServer, TerminologyServerError
+from app.terminology.valuesets import ValueSet, check_value_set, expand
+
+# --- Configuration ---
+settings = get_settings()
+TERMINOLOGY_PATH = settings.terminology_path
+TERMINOLOGY_SERVER_URL = settings.terminology_server_url
+TERMINOLOGY_TIMEOUT_SECONDS = settings.terminology_timeout_seconds
+TERMINOLOGY_CACHE_SECONDS = settings.terminology_cache_seconds
+BUNDLED_PATH = Path(__file__).with_name("data")
+
+
+class CodeValidationError(ValueError):
+    """Raised when a coded value is known not to be valid."""
+
+
+def _resources(path: Path) -> Iterable[dict]:
+    files = sorted(path.glob("*.json")) if path.is_dir() else [path]
+    for file in files:
+        resource = json.loads(file.read_text())
+        if resource.get("resourceType") == "Bundle":
+            yield from (entry["resource"] for entry in resource.get("entry") or [] if "resource" in entry)
+        else:
+            yield resource
+
+
+def load_terminology(paths: Iterable[Path], **kwargs) -> "Terminology":
+    """
+    Reads the CodeSystem and ValueSet resources in `paths` (JSON files, or
+    directories of them, each a resource or a Bundle of them). Copies of the
+    same code system are merged. Raises ValueError if a resource is
+    malformed or a value set cannot be expanded.
+    """
+    code_systems: Dict[str, LoadedCodeSystem] = {}
+    value_sets: Dict[str, ValueSet] = {}
+    for path in paths:
+        for resource in _resources(Path(path)):
+            kind = resource.get("resourceType")
+            if kind == "CodeSystem":
+                loaded = LoadedCodeSystem(CodeSystem.model_validate(resource))
+                if loaded.url in code_systems:
+                    code_systems[loaded.url].merge(loaded)
+                else:
+                    code_systems[loaded.url] = loaded
+            elif kind == "ValueSet":
+                value_set = ValueSet.model_validate(resource)
+                value_sets[value_set.id] = value_set
+            else:
+                raise ValueError(f"{path}: expected CodeSystem or ValueSet resources, not {kind}")
+    for value_set in value_sets.values():
+        check_value_set(value_set, code_systems)
+    return Terminology(code_systems, value_sets, **kwargs)
+
+
+class Terminology:
+    def __init__(
+        self, code_systems: Dict[str, LoadedCodeSystem], value_sets: Dict[str, ValueSet],
+        server: Optional[FhirTerminologyServer] = None, cache: Optional[Cache] = None, cache_seconds: int = 86400,
+    ):
+        self.code_systems = code_systems
+        self.value_sets = value_sets
+        self.server = server
+        self.cache = cache or NullCache()
+        self.cache_seconds = cache_seconds
+        self._expansions: Dict[str, List[Tuple[str, str, Optional[str]]]] = {}
+
+    def knows(self, system: str) -> bool:
+        """Whether anything is known about the system's codes here."""
+        return system in self.code_systems or system in SYNTAX_CHECKS
+
+    def _local(self, system: str, code: str) -> Optional[schemas.CodeLookup]:
+        loaded = self.code_systems.get(system)
+        concept = loaded.get(code) if loaded else None
+        if not concept:
+            return None
+        return schemas.CodeLookup(
+            system=system, code=code, display=concept.display, version=loaded.version,
+            inactive=LoadedCodeSystem.is_inactive(concept), parents=sorted(loaded.parents.get(code, ())),
+        )
+
+    def lookup(self, system: str, code: str) -> Optional[schemas.CodeLookup]:
+        """
+        The concept `code` names in `system`, or None if it is not known.
+        Raises TerminologyServerError if the terminology server had to be
+        asked and failed.
+        """
+        found = self._local(system, code)
+        if found or not self.knows(system):
+            return found
+        check = SYNTAX_CHECKS.get(system)
+        loaded = self.code_systems.get(system)
+        if (check and check(code)) or (loaded and loaded.complete) or not self.server:
+            return None
+        return read_through(
+            self.cache, f"terminology:{system}|{code}", self.cache_seconds, schemas.CodeLookup,
+            lambda: self.server.lookup(system, code),
+        )
+
+    def validate(self, system: str, code: str, display: Optional[str] = None, value_set: Optional[ValueSet] = None) -> schemas.CodeValidation:
+        """Whether `code` is a valid code of `system` (and a member of `value_set`), and how far that was checked."""
+        def result(valid: bool, verification: str, message: Optional[str] = None, found: Optional[schemas.CodeLookup] = None):
+            return schemas.CodeValidation(
+                result=valid, verification=verification, system=system, code=code, message=message,
+                display=found.display if found else None, inactive=found.inactive if found else False,
+            )
+
+        if not self.knows(system):
+            validation = result(True, "none", f"Code system {system} is not known here; the code was not checked")
+        else:
+            check = SYNTAX_CHECKS.get(system)
+            error = check(code) if check else None
+            if error:
+                return result(False, "syntax", error)
+            loaded = self.code_systems.get(system)
+            name = loaded.name if loaded else system
+            try:
+                found = self.lookup(system, code)
+            except TerminologyServerError as e:
+                logging.warning(f"Could not look up {system}|{code}: {e}")
+                found, conclusive = None, False
+            else:
+                conclusive = bool((loaded and loaded.complete) or self.server)
+            if found:
+                if display and not self._matches_display(found, display):
+                    return result(False, "concept", f"Display '{display}' does not match '{found.display}'", found)
+                validation = result(True, "concept", f"{code} is inactive in {name}" if found.inactive else None, found)
+            elif conclusive:
+                return result(False, "concept", f"Code '{code}' was not found in {name}")
+            else:
+                checked = "only its form was checked" if check else "it was not checked"
+                validation = result(True, "syntax" if check else "none", f"Code '{code}' is not in the loaded content of {name}; {checked}")
+
+        if value_set and (system, code) not in {(s, c) for s, c, _ in self._expansion(value_set)}:
+            return result(False, validation.verification, f"Code '{code}' of {system} is not in value set '{value_set.id}'")
+        return validation
+
+    def _matches_display(self, found: schemas.CodeLookup, display: str) -> bool:
+        loaded = self.code_systems.get(found.system)
+        concept = loaded.get(found.code) if loaded else None
+        if concept:
+            return LoadedCodeSystem.matches_display(concept, display)
+        return not found.display or found.display.strip().casefold() == display.strip().casefold()
+
+    def check_code(self, system: str, code: str, label: Optional[str] = None) -> None:
+        """
+        Raises CodeValidationError if `code` is known not to be a code of
+        `system`. Codes that cannot be checked, such as those of unknown
+        systems or while the terminology server is down, are accepted.
+        """
+        validation = self.validate(system, code)
+        if not validation.result:
+            raise CodeValidationError(f"{label}: {validation.message}" if label else validation.message)
+
+    def check_codings(self, codings: Iterable[Optional[schemas.Coding]], label: str) -> None:
+        """`check_code` for each coding, naming the field as `label`."""
+        for coding in codings:
+            if coding:
+                self.check_code(coding.system, coding.code, label)
+
+    # --- Value sets ---
+
+    def get_value_set(self, value_set_id: str) -> Optional[ValueSet]:
+        """A value set by its ID or canonical URL."""
+        return self.value_sets.get(value_set_id) or next((v for v in self.value_sets.values() if v.url == value_set_id), None)
+
+    def _expansion(self, value_set: ValueSet) -> List[Tuple[str, str, Optional[str]]]:
+        # Loaded content does not change while the process runs.
+        if value_set.id not in self._expansions:
+            self._expansions[value_set.id] = expand(value_set, self.code_systems)
+        return self._expansions[value_set.id]
+
+    def expand(self, value_set: ValueSet, text: Optional[str] = None, offset: int = 0, count: int = 100) -> schemas.ValueSetExpansion:
+        """
+        A page of the value set's codes, optionally only those whose display
+        contains `text` or whose code starts with it (ignoring case).
+        """
+        entries = self._expansion(value_set)
+        if text:
+            needle = text.strip().casefold()
+            entries = [e for e in entries if e[1].casefold().startswith(needle) or needle in (e[2] or "").casefold()]
+        page = entries[offset:offset + count]
+        return schemas.ValueSetExpansion(
+            id=value_set.id, url=value_set.url, total=len(entries), offset=offset,
+            contains=[schemas.Coding(system=system, code=code, display=display) for system, code, display in page],
+        )
+
+
+@lru_cache
+def get_terminology() -> Terminology:
+    server = FhirTerminologyServer(TERMINOLOGY_SERVER_URL, TERMINOLOGY_TIMEOUT_SECONDS) if TERMINOLOGY_SERVER_URL else None
+    paths = [BUNDLED_PATH] + ([Path(TERMINOLOGY_PATH)] if TERMINOLOGY_PATH else [])
+    return load_terminology(paths, server=server, cache=get_cache(), cache_seconds=TERMINOLOGY_CACHE_SECONDS)
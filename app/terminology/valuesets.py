@@ -0,0 +1,106 @@
+# Location: app/terminology/valuesets.py
+
+from typing import Dict, Iterator, List, Literal, Optional, Tuple
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.terminology.codesystems import LoadedCodeSystem
+
+
+class ValueSetConcept(BaseModel):
+    code: str
+    display: Optional[str] = None
+    model_config = ConfigDict(extra="ignore")
+
+
+class ValueSetFilter(BaseModel):
+    property: str
+    op: str
+    value: str
+    model_config = ConfigDict(extra="ignore")
+
+
+class ValueSetInclude(BaseModel):
+    """
+    Codes of one system: those listed in `concept`, else those the filters
+    select, else the whole system. Only `concept is-a` and `concept
+    descendent-of` filters are supported.
+    """
+    system: str
+    version: Optional[str] = None
+    concept: List[ValueSetConcept] = []
+    filter: List[ValueSetFilter] = []
+    model_config = ConfigDict(extra="ignore")
+
+
+class ValueSetCompose(BaseModel):
+    include: List[ValueSetInclude] = Field(..., min_length=1)
+    exclude: List[ValueSetInclude] = []
+    model_config = ConfigDict(extra="ignore")
+
+
+class ValueSet(BaseModel):
+    """A FHIR R4 ValueSet resource defined by `compose`."""
+    resource_type: Literal["ValueSet"] = Field(..., alias="resourceType")
+    id: str
+    url: str
+    version: Optional[str] = None
+    name: Optional[str] = None
+    title: Optional[str] = None
+    description: Optional[str] = None
+    compose: ValueSetCompose
+    model_config = ConfigDict(populate_by_name=True, extra="ignore")
+
+
+SUPPORTED_FILTERS = ("is-a", "descendent-of")
+
+
+def check_value_set(value_set: ValueSet, code_systems: Dict[str, LoadedCodeSystem]) -> None:
+    """
+    Raises ValueError unless every part of the value set can be expanded:
+    filters and whole-system includes need the system loaded.
+    """
+    for part in value_set.compose.include + value_set.compose.exclude:
+        if part.concept:
+            continue
+        if part.system not in code_systems:
+            raise ValueError(f"ValueSet '{value_set.id}' selects codes of {part.system}, which is not loaded")
+        for f in part.filter:
+            if f.property != "concept" or f.op not in SUPPORTED_FILTERS:
+                raise ValueError(f"ValueSet '{value_set.id}': unsupported filter '{f.property} {f.op}'")
+
+
+def _select(part: ValueSetInclude, code_systems: Dict[str, LoadedCodeSystem]) -> Iterator[Tuple[str, Optional[str]]]:
+    system = code_systems.get(part.system)
+    if part.concept:
+        for concept in part.concept:
+            known = system.get(concept.code) if system else None
+            if not (known and LoadedCodeSystem.is_inactive(known)):
+                yield concept.code, concept.display or (known.display if known else None)
+        return
+    if part.filter:
+        selected = None
+        for f in part.filter:
+            codes = set(system.descendants(f.value))
+            if f.op == "is-a":
+                codes.add(f.value)
+            selected = codes if selected is None else selected & codes
+        codes = [code for code in system.concepts if code in selected]
+    else:
+        codes = list(system.concepts)
+    for code in codes:
+        concept = system.get(code)
+        if concept and not LoadedCodeSystem.is_inactive(concept):
+            yield code, concept.display
+
+
+def expand(value_set: ValueSet, code_systems: Dict[str, LoadedCodeSystem]) -> List[Tuple[str, str, Optional[str]]]:
+    """The active (system, code, display) entries of the value set, in the order of its definition."""
+    excluded = {(part.system, code) for part in value_set.compose.exclude for code, _ in _select(part, code_systems)}
+    entries, seen = [], set()
+    for part in value_set.compose.include:
+        for code, display in _select(part, code_systems):
+            if (part.system, code) not in excluded and (part.system, code) not in seen:
+                seen.add((part.system, code))
+                entries.append((part.system, code, display))
+    return entries
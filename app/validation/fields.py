@@ -0,0 +1,132 @@
+# Location: app/validation/fields.py
+
+import re
+from datetime import date, datetime, timezone
+from typing import Annotated
+from zoneinfo import ZoneInfo, ZoneInfoNotFoundError
+
+from pydantic import AfterValidator, BeforeValidator
+from pydantic_core import PydanticCustomError
+
+from app.core.config import get_settings
+
+# Reusable field types for request schemas, e.g. `mrn: MedicalRecordNumber`.
+# Each rule fails with its own error code, which is reported per field in
+# the `errors` of a 422 validation-error problem (see app/errors/handlers.py).
+
+MRN_PATTERN = re.compile(r"^[A-Za-z0-9][A-Za-z0-9-]{2,31}$")
+E164_PATTERN = re.compile(r"^\+[1-9]\d{6,14}$")
+# Area 000, 666 and 900-999, group 00 and serial 0000 are never issued.
+# Deliberately loose: the provider is the judge of deliverability.
+EMAIL_PATTERN = re.compile(r"^[^@\s]+@[^@\s]+\.[^@\s.]+$")
+SSN_PATTERN = re.compile(r"^(?!000|666|9\d\d)\d{3}-?(?!00)\d{2}-?(?!0000)\d{4}$")
+# Separators people type into phone numbers; they carry no meaning.
+_PHONE_SEPARATORS = re.compile(r"[\s().\-]")
+
+
+def is_valid_nhs_number(value: str) -> bool:
+    """Validates an NHS number's modulus 11 check digit."""
+    if len(value) != 10 or not value.isdigit():
+        return False
+    total = sum(int(digit) * weight for digit, weight in zip(value[:9], range(10, 1, -1)))
+    check = 11 - total % 11
+    if check == 11:
+        check = 0
+    return check != 10 and check == int(value[9])
+
+
+def is_valid_npi(npi: str) -> bool:
+    """Validates an NPI's check digit (Luhn algorithm over the '80840' prefixed number)."""
+    if len(npi) != 10 or not npi.isdigit():
+        return False
+    digits = [int(d) for d in "80840" + npi]
+    checksum = 0
+    for i, digit in enumerate(reversed(digits)):
+        if i % 2 == 1:
+            digit *= 2
+            if digit > 9:
+                digit -= 9
+        checksum += digit
+    return checksum % 10 == 0
+
+
+def _medical_record_number(value: str) -> str:
+    if not MRN_PATTERN.match(value):
+        raise PydanticCustomError("mrn_format", "MRN must be 3-32 letters, digits or hyphens, starting with a letter or digit")
+    return value
+
+
+def _nhs_number(value: str) -> str:
+    value = value.replace(" ", "")
+    if not is_valid_nhs_number(value):
+        raise PydanticCustomError("nhs_number", "NHS number must be 10 digits with a valid check digit")
+    return value
+
+
+def _social_security_number(value: str) -> str:
+    value = value.replace(" ", "")
+    if not SSN_PATTERN.match(value):
+        raise PydanticCustomError("ssn_format", "SSN must be 9 digits, as issued (e.g. 123-45-6789)")
+    digits = value.replace("-", "")
+    return f"{digits[:3]}-{digits[3:5]}-{digits[5:]}"
+
+
+def _npi(value: str) -> str:
+    if not is_valid_npi(value):
+        raise PydanticCustomError("npi", "npi must be a 10-digit number with a valid check digit")
+    return value
+
+
+def _phone_number(value: str) -> str:
+    """
+    Normalises to E.164. Numbers in national format (a single leading trunk
+    "0", as most local systems send them) get PHONE_DEFAULT_COUNTRY_CODE.
+    """
+    number = _PHONE_SEPARATORS.sub("", value) if isinstance(value, str) else value
+    if isinstance(number, str):
+        if number.startswith("00"):
+            number = "+" + number[2:]
+        elif number.startswith("0"):
+            number = "+" + get_settings().phone_default_country_code + number[1:]
+    if not isinstance(number, str) or not E164_PATTERN.match(number):
+        raise PydanticCustomError("phone_e164", "phone number must be in E.164 format, e.g. +66812345678")
+    return number
+
+
+def _email_address(value: str) -> str:
+    value = value.strip()
+    if not EMAIL_PATTERN.match(value):
+        raise PydanticCustomError("email_address", "must be an email address, e.g. clinic@example.com")
+    return value
+
+
+def _birth_date(value: date) -> date:
+    if value > datetime.now(timezone.utc).date():
+        raise PydanticCustomError("date_in_future", "date of birth must not be in the future")
+    return value
+
+
+def _future_instant(value: datetime) -> datetime:
+    # Instants sent without an offset are taken to be UTC.
+    if (value if value.tzinfo else value.replace(tzinfo=timezone.utc)) <= datetime.now(timezone.utc):
+        raise PydanticCustomError("date_not_in_future", "must be in the future")
+    return value
+
+
+def _iana_timezone(value: str) -> str:
+    try:
+        ZoneInfo(value)
+    except (ZoneInfoNotFoundError, ValueError):
+        raise PydanticCustomError("timezone", "must be an IANA timezone, e.g. Asia/Bangkok")
+    return value
+
+
+MedicalRecordNumber = Annotated[str, AfterValidator(_medical_record_number)]
+NhsNumber = Annotated[str, AfterValidator(_nhs_number)]
+SocialSecurityNumber = Annotated[str, AfterValidator(_social_security_number)]
+NationalProviderIdentifier = Annotated[str, AfterValidator(_npi)]
+PhoneNumber = Annotated[str, BeforeValidator(_phone_number)]
+EmailAddress = Annotated[str, AfterValidator(_email_address)]
+BirthDate = Annotated[date, AfterValidator(_birth_date)]
+FutureInstant = Annotated[datetime, AfterValidator(_future_instant)]
+IanaTimezone = Annotated[str, AfterValidator(_iana_timezone)]
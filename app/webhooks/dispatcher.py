@@ -0,0 +1,94 @@
+# Location: app/webhooks/dispatcher.py
+
+import json
+import logging
+from datetime import datetime, timezone
+from typing import Callable, Optional
+
+import httpx
+
+from app.api.v1 import schemas
+from app.core.http_client import new_client
+from app.core.metrics import WEBHOOK_DELIVERIES_TOTAL
+from app.core.worker import PollingWorker, retry_delay
+from app.repositories.webhooks import WebhookDeliveryRepository, WebhookSubscriptionRepository
+from app.webhooks.signing import SIGNATURE_HEADER, sign
+
+# Response bodies are only kept to help partners debug, so cap what is stored.
+MAX_ERROR_LENGTH = 500
+
+
+class WebhookDispatcher(PollingWorker):
+    """
+    POSTs due deliveries to their subscription's URL. Each request carries
+    the event envelope as JSON, signed with the subscription's secret (see
+    `app.webhooks.signing`). Any 2xx response is a success; anything else,
+    including timeouts, is retried with exponential backoff until
+    `max_attempts`. Like the outbox, delivery is at-least-once and receivers
+    deduplicate on X-MegaCare-Event-Id.
+    """
+    name = "webhook-dispatcher"
+
+    def __init__(
+        self,
+        subscriptions: WebhookSubscriptionRepository,
+        deliveries: WebhookDeliveryRepository,
+        client: Optional[httpx.Client] = None,
+        batch_size: int = 50,
+        max_attempts: int = 8,
+        timeout_seconds: float = 10.0,
+        clock: Callable[[], datetime] = lambda: datetime.now(timezone.utc),
+    ):
+        super().__init__()
+        self.subscriptions = subscriptions
+        self.deliveries = deliveries
+        self.client = client or new_client("webhooks", timeout_seconds)
+        self.batch_size = batch_size
+        self.max_attempts = max_attempts
+        # Leases outlast a request timing out, so a slow partner is not sent the same delivery twice.
+        self.lease_seconds = timeout_seconds * 3
+        self.clock = clock
+
+    def drain(self) -> int:
+        """Attempts one batch of due deliveries. Returns how many were attempted."""
+        deliveries = self.deliveries.claim(self.batch_size, self.lease_seconds)
+        for delivery in deliveries:
+            self.deliver(delivery)
+        return len(deliveries)
+
+    def deliver(self, delivery: schemas.WebhookDelivery) -> None:
+        attempts = delivery.attempts + 1
+        subscription = self.subscriptions.get(delivery.subscription_id)
+        if subscription is None or not subscription.active:
+            self.deliveries.mark_failed(delivery.delivery_id, delivery.attempts, "Subscription was deleted or deactivated.", None)
+            WEBHOOK_DELIVERIES_TOTAL.labels(result="failed").inc()
+            return
+
+        body = json.dumps(delivery.event, separators=(",", ":")).encode("utf-8")
+        headers = {
+            "Content-Type": "application/json",
+            SIGNATURE_HEADER: sign(subscription.secret, body),
+            "X-MegaCare-Event-Id": delivery.event_id,
+            "X-MegaCare-Event-Type": delivery.event_type,
+            "X-MegaCare-Delivery-Id": delivery.delivery_id,
+        }
+        try:
+            response = self.client.post(subscription.url, content=body, headers=headers)
+        except httpx.HTTPError as e:
+            self._record_failure(delivery, attempts, f"{type(e).__name__}: {e}", None)
+            return
+        if response.is_success:
+            self.deliveries.mark_succeeded(delivery.delivery_id, attempts, response.status_code)
+            WEBHOOK_DELIVERIES_TOTAL.labels(result="succeeded").inc()
+            return
+        self._record_failure(delivery, attempts, f"HTTP {response.status_code}: {response.text[:MAX_ERROR_LENGTH]}", response.status_code)
+
+    def _record_failure(self, delivery: schemas.WebhookDelivery, attempts: int, error: str, response_status: Optional[int]) -> None:
+        if attempts >= self.max_attempts:
+            logging.warning(f"Giving up on webhook delivery {delivery.delivery_id} after {attempts} attempts: {error}")
+            self.deliveries.mark_failed(delivery.delivery_id, attempts, error, response_status)
+            WEBHOOK_DELIVERIES_TOTAL.labels(result="failed").inc()
+            return
+        logging.info(f"Webhook delivery {delivery.delivery_id} failed (attempt {attempts}): {error}")
+        self.deliveries.reschedule(delivery.delivery_id, attempts, self.clock() + retry_delay(attempts), error, response_status)
+        WEBHOOK_DELIVERIES_TOTAL.labels(result="retried").inc()
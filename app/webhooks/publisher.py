@@ -0,0 +1,35 @@
+# Location: app/webhooks/publisher.py
+
+import logging
+
+from app.events.envelope import Event
+from app.events.publisher import EventPublisher
+from app.repositories.webhooks import WebhookDeliveryRepository, WebhookSubscriptionRepository
+
+
+class WebhookEventPublisher(EventPublisher):
+    """
+    Queues a delivery for every active subscription to the event's type, then
+    hands the event on to `inner`. Deliveries are stored like outbox entries,
+    inside the endpoint's transaction, and sent by `WebhookDispatcher`.
+    """
+
+    transactional = True
+
+    def __init__(self, inner: EventPublisher, subscriptions: WebhookSubscriptionRepository, deliveries: WebhookDeliveryRepository):
+        self.inner = inner
+        self.subscriptions = subscriptions
+        self.deliveries = deliveries
+
+    def publish(self, event: Event) -> None:
+        for subscription in self.subscriptions.list_for_event_type(event.type):
+            self.deliveries.create(subscription.subscription_id, event)
+        if self.inner.transactional:
+            self.inner.publish(event)
+            return
+        # A direct Pub/Sub publish cannot be rolled back, so its failure must
+        # not undo the change and the deliveries stored with it.
+        try:
+            self.inner.publish(event)
+        except Exception:
+            logging.exception(f"Failed to publish {event.type} event for {event.subject}", extra={"report_error": True})
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/megacare-dev/mega-care-api/client/megacare"
+)
+
+func runAppointmentsBook(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlagSet("appointments book", "")
+	patientID := flags.String("patient", "", "patient ID")
+	practitionerID := flags.String("practitioner", "", "practitioner ID")
+	start := flags.String("start", "", "start time, RFC 3339 with a timezone, e.g. 2026-03-01T09:30:00+01:00")
+	duration := flags.Duration("duration", 30*time.Minute, "length of the appointment")
+	appointmentType := flags.String("type", "", "appointment type, e.g. cpap-setup, follow-up or mask-fitting")
+	reason := flags.String("reason", "", "reason for the visit")
+	location := flags.String("location", "", "location")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if *patientID == "" || *practitionerID == "" || *start == "" {
+		return usageError(flags, "-patient, -practitioner and -start are required")
+	}
+	startAt, err := time.Parse(time.RFC3339, *start)
+	if err != nil {
+		return usageError(flags, "-start: %v", err)
+	}
+	if *duration <= 0 {
+		return usageError(flags, "-duration must be positive")
+	}
+
+	client, err := a.apiClient()
+	if err != nil {
+		return err
+	}
+	appointment, err := client.Appointments.Create(ctx, &megacare.AppointmentCreate{
+		PatientID: *patientID, PractitionerID: *practitionerID, Start: startAt, End: startAt.Add(*duration),
+		AppointmentType: *appointmentType, Reason: *reason, Location: *location,
+	})
+	if err != nil {
+		return err
+	}
+	return a.printJSON(appointment)
+}
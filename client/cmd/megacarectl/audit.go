@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/megacare-dev/mega-care-api/client/megacare"
+)
+
+func runAuditTail(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlagSet("audit tail", "")
+	patientID := flags.String("patient", "", "only events concerning this patient's records")
+	actor := flags.String("actor", "", "only events of this user ID")
+	resourceType := flags.String("resource-type", "", "only events on this resource type, e.g. patients or Observation")
+	since := flags.Duration("since", 15*time.Minute, "start with the events of this long ago")
+	interval := flags.Duration("interval", 5*time.Second, "how often to check for new events")
+	follow := flags.Bool("follow", true, "keep printing new events until interrupted")
+	asJSON := flags.Bool("json", false, "print each event as a line of JSON")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if *interval <= 0 {
+		return usageError(flags, "-interval must be positive")
+	}
+
+	client, err := a.apiClient()
+	if err != nil {
+		return err
+	}
+	filter := megacare.AuditEventListOptions{
+		ListOptions: megacare.ListOptions{Limit: 100},
+		PatientID:   *patientID, ActorUID: *actor, ResourceType: *resourceType,
+		From: time.Now().Add(-*since),
+	}
+	enc := json.NewEncoder(a.stdout)
+	return tailAudit(ctx, client, filter, *interval, *follow, func(event *megacare.AuditEvent) error {
+		if *asJSON {
+			return enc.Encode(event)
+		}
+		_, err := fmt.Fprintln(a.stdout, formatAuditEvent(event))
+		return err
+	})
+}
+
+// tailAudit emits the events matching filter oldest first, then polls for
+// newer ones until ctx is done. The API lists events at or after From, so
+// the events at the newest timestamp are remembered to emit them once.
+func tailAudit(ctx context.Context, client *megacare.Client, filter megacare.AuditEventListOptions, interval time.Duration, follow bool, emit func(*megacare.AuditEvent) error) error {
+	seen := map[string]bool{}
+	for {
+		var batch []megacare.AuditEvent
+		for event, err := range client.AuditEvents.List(&filter).All(ctx) {
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			if !seen[event.EventID] {
+				batch = append(batch, event)
+			}
+		}
+		// Pages come newest first.
+		for i := len(batch) - 1; i >= 0; i-- {
+			if err := emit(&batch[i]); err != nil {
+				return err
+			}
+			if at := batch[i].OccurredAt; at.After(filter.From) {
+				filter.From = at
+				clear(seen)
+			}
+			if batch[i].OccurredAt.Equal(filter.From) {
+				seen[batch[i].EventID] = true
+			}
+		}
+
+		if !follow {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+func formatAuditEvent(event *megacare.AuditEvent) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s  %-6s %-7s %d %s %s", event.OccurredAt.UTC().Format(time.RFC3339Nano), event.Action, event.Outcome, event.StatusCode, event.Method, event.Path)
+	for _, field := range [][2]string{{"actor", event.ActorUID}, {"patient", event.PatientID}, {"purpose", event.PurposeOfUse}, {"request", event.RequestID}} {
+		if field[1] != "" {
+			fmt.Fprintf(&b, " %s=%s", field[0], field[1])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/megacare-dev/mega-care-api/client/megacare"
+)
+
+// importTask creates one decoded record and returns its ID.
+type importTask func(ctx context.Context, client *megacare.Client) (string, error)
+
+// importKind decodes one line of an import file.
+type importKind func(line []byte) (importTask, error)
+
+// observationRecord is a line of an observations import: a vital sign and
+// the patient it belongs to.
+type observationRecord struct {
+	PatientID string `json:"patient_id"`
+	megacare.VitalSignCreate
+}
+
+var importKinds = map[string]importKind{
+	"patients": kind(func(ctx context.Context, c *megacare.Client, r *megacare.PatientCreate) (string, error) {
+		created, err := c.Patients.Create(ctx, r)
+		if err != nil {
+			return "", err
+		}
+		return created.PatientID, nil
+	}),
+	"practitioners": kind(func(ctx context.Context, c *megacare.Client, r *megacare.PractitionerCreate) (string, error) {
+		created, err := c.Practitioners.Create(ctx, r)
+		if err != nil {
+			return "", err
+		}
+		return created.PractitionerID, nil
+	}),
+	"appointments": kind(func(ctx context.Context, c *megacare.Client, r *megacare.AppointmentCreate) (string, error) {
+		created, err := c.Appointments.Create(ctx, r)
+		if err != nil {
+			return "", err
+		}
+		return created.AppointmentID, nil
+	}),
+	"observations": kind(func(ctx context.Context, c *megacare.Client, r *observationRecord) (string, error) {
+		if r.PatientID == "" {
+			return "", errors.New("patient_id is required")
+		}
+		created, err := c.Observations.Create(ctx, r.PatientID, &r.VitalSignCreate)
+		if err != nil {
+			return "", err
+		}
+		return created.ObservationID, nil
+	}),
+}
+
+// kind decodes lines into T, rejecting fields the API would not accept.
+func kind[T any](create func(ctx context.Context, c *megacare.Client, record *T) (string, error)) importKind {
+	return func(line []byte) (importTask, error) {
+		record := new(T)
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(record); err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, c *megacare.Client) (string, error) { return create(ctx, c, record) }, nil
+	}
+}
+
+type importLine struct {
+	number int
+	key    string // The Idempotency-Key of the line's request.
+	task   importTask
+}
+
+func runImport(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlagSet("import", "<file.ndjson>")
+	kindName := flags.String("type", "", "record type: "+strings.Join(importKindNames(), ", "))
+	dryRun := flags.Bool("dry-run", false, "check the file without creating anything")
+	workers := flags.Int("workers", 4, "records created at the same time")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	decode, ok := importKinds[*kindName]
+	if !ok {
+		return usageError(flags, "-type must be one of: %s", strings.Join(importKindNames(), ", "))
+	}
+	if flags.NArg() != 1 {
+		return usageError(flags, "expected one file (\"-\" for stdin)")
+	}
+	if *workers < 1 {
+		return usageError(flags, "-workers must be at least 1")
+	}
+
+	lines, err := readImportFile(flags.Arg(0), *kindName, decode)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Fprintf(a.stderr, "%d %s would be imported.\n", len(lines), *kindName)
+		return nil
+	}
+	client, err := a.apiClient()
+	if err != nil {
+		return err
+	}
+	return a.importLines(ctx, client, lines, *workers)
+}
+
+// readImportFile decodes every line before anything is sent, so that a
+// mistake late in a file does not leave it half imported.
+func readImportFile(name, kindName string, decode importKind) ([]importLine, error) {
+	in, err := openInput(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	var lines []importLine
+	var problems []string
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for number := 1; scanner.Scan(); number++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		task, err := decode(line)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: %v", number, err))
+			continue
+		}
+		lines = append(lines, importLine{number: number, key: importKey(kindName, line), task: task})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s has %d invalid lines; nothing was imported:\n  %s", name, len(problems), strings.Join(problems, "\n  "))
+	}
+	return lines, nil
+}
+
+// importKey derives a line's Idempotency-Key from its content, so that
+// running an interrupted import again repeats the same requests.
+func importKey(kindName string, line []byte) string {
+	sum := sha256.Sum256(append([]byte("megacarectl-import:"+kindName+":"), line...))
+	return hex.EncodeToString(sum[:16])
+}
+
+// importLines creates the records, writing "<line>\t<id>" to stdout for
+// each one created and the failures to stderr.
+func (a *app) importLines(ctx context.Context, client *megacare.Client, lines []importLine, workers int) error {
+	jobs := make(chan importLine)
+	var mu sync.Mutex
+	var failed []int
+	var wg sync.WaitGroup
+	for range min(workers, max(len(lines), 1)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for line := range jobs {
+				id, err := line.task(megacare.WithIdempotencyKey(ctx, line.key), client)
+				mu.Lock()
+				if err != nil {
+					failed = append(failed, line.number)
+					fmt.Fprintf(a.stderr, "line %d: %v\n", line.number, err)
+				} else {
+					fmt.Fprintf(a.stdout, "%d\t%s\n", line.number, id)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, line := range lines {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- line
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("import interrupted; lines are sent with the same idempotency keys when it is run again: %w", err)
+	}
+	if len(failed) > 0 {
+		sort.Ints(failed)
+		return fmt.Errorf("%d of %d records failed (lines %s)", len(failed), len(lines), joinInts(failed))
+	}
+	fmt.Fprintf(a.stderr, "Imported %d records.\n", len(lines))
+	return nil
+}
+
+func importKindNames() []string {
+	names := make([]string, 0, len(importKinds))
+	for name := range importKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, ", ")
+}
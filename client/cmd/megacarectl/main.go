@@ -0,0 +1,198 @@
+// Command megacarectl runs support tasks against a MegaCare environment:
+// creating patients, booking appointments, tailing the audit trail and
+// importing records from NDJSON files.
+//
+// Usage:
+//
+//	megacarectl [-env name] [-url url] <command> [flags] [args]
+//
+// Environments are named in config.json under the user's config directory
+// (e.g. ~/.config/megacarectl/config.json), or chosen with -url:
+//
+//	{
+//	  "default": "staging",
+//	  "environments": {
+//	    "staging": {
+//	      "url": "https://api.staging.megacare.example",
+//	      "oidc": {"issuer": "https://megacare.eu.auth0.com/", "client_id": "...", "audience": "https://api.megacare.example", "token": "access_token"}
+//	    }
+//	  }
+//	}
+//
+// Requests authenticate with the first of: the MEGACARE_API_KEY environment
+// variable, the MEGACARE_TOKEN environment variable (a bearer token, e.g.
+// from gcloud), or the OIDC session saved by "megacarectl login".
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/megacare-dev/mega-care-api/client/megacare"
+)
+
+// errUsage is returned after the usage of a command has been printed.
+var errUsage = errors.New("usage")
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, a *app, args []string) error
+}
+
+var commands = []command{
+	{"login", "sign in to the environment with its OIDC provider", runLogin},
+	{"logout", "forget the saved OIDC session", runLogout},
+	{"patients create", "register a patient", runPatientsCreate},
+	{"patients get", "print a patient record", runPatientsGet},
+	{"appointments book", "book an appointment", runAppointmentsBook},
+	{"audit tail", "print audit events as they are recorded", runAuditTail},
+	{"import", "create the records of an NDJSON file", runImport},
+}
+
+// app is the state shared by the commands of one invocation.
+type app struct {
+	stdout, stderr io.Writer
+	getenv         func(string) string
+	configDir      string
+	httpClient     *http.Client
+
+	envName string
+	env     Environment
+	client  *megacare.Client
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	configDir, err := os.UserConfigDir()
+	if err == nil {
+		configDir += string(os.PathSeparator) + "megacarectl"
+	}
+	a := &app{
+		stdout: os.Stdout, stderr: os.Stderr, getenv: os.Getenv, configDir: configDir,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	if err := a.run(ctx, os.Args[1:]); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, "megacarectl:", err)
+			os.Exit(1)
+		}
+		os.Exit(2)
+	}
+}
+
+func (a *app) run(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("megacarectl", flag.ContinueOnError)
+	flags.SetOutput(a.stderr)
+	envName := flags.String("env", a.getenv("MEGACARE_ENV"), "environment from the config file (default: its \"default\", or $MEGACARE_ENV)")
+	baseURL := flags.String("url", a.getenv("MEGACARE_URL"), "API URL, overriding the environment's (default $MEGACARE_URL)")
+	flags.Usage = func() {
+		fmt.Fprintln(a.stderr, "usage: megacarectl [-env name] [-url url] <command> [flags] [args]")
+		fmt.Fprintln(a.stderr, "\ncommands:")
+		for _, c := range commands {
+			fmt.Fprintf(a.stderr, "  %-18s %s\n", c.name, c.summary)
+		}
+		fmt.Fprintln(a.stderr, "\nflags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	cmd, rest := findCommand(flags.Args())
+	if cmd == nil {
+		flags.Usage()
+		return errUsage
+	}
+	if err := a.selectEnvironment(*envName, *baseURL); err != nil {
+		return err
+	}
+	return cmd.run(ctx, a, rest)
+}
+
+// findCommand matches the one- or two-word command at the start of args.
+func findCommand(args []string) (*command, []string) {
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == commands[i].name {
+			return &commands[i], args[len(words):]
+		}
+	}
+	return nil, nil
+}
+
+// newFlagSet returns the flag set of a command, printing its usage line on
+// -h and on bad flags.
+func (a *app) newFlagSet(name, args string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(a.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(a.stderr, "usage: megacarectl %s [flags] %s\n", name, args)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// usageError prints the problem and the usage of the command.
+func usageError(flags *flag.FlagSet, format string, args ...any) error {
+	fmt.Fprintf(flags.Output(), format+"\n", args...)
+	flags.Usage()
+	return errUsage
+}
+
+// apiClient returns the SDK client for the environment, authenticated with
+// the first credentials available.
+func (a *app) apiClient() (*megacare.Client, error) {
+	if a.client != nil {
+		return a.client, nil
+	}
+	if a.env.URL == "" {
+		return nil, errors.New("no environment selected: pass -env or -url, or set a default in " + a.configPath())
+	}
+	creds, err := a.credentials()
+	if err != nil {
+		return nil, err
+	}
+	a.client, err = megacare.NewClient(a.env.URL, creds, megacare.WithHTTPClient(a.httpClient), megacare.WithUserAgent("megacarectl"))
+	return a.client, err
+}
+
+func (a *app) credentials() (megacare.Credentials, error) {
+	if key := a.getenv("MEGACARE_API_KEY"); key != "" {
+		return megacare.APIKeyAuth(key), nil
+	}
+	if token := a.getenv("MEGACARE_TOKEN"); token != "" {
+		return megacare.BearerToken(token), nil
+	}
+	if a.env.OIDC == nil {
+		return nil, errors.New("no credentials: set MEGACARE_API_KEY or MEGACARE_TOKEN, or configure oidc for the environment and run megacarectl login")
+	}
+	return a.session().tokenSource(), nil
+}
+
+// printJSON writes v to stdout as indented JSON.
+func (a *app) printJSON(v any) error {
+	enc := json.NewEncoder(a.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// openInput opens a file named on the command line, or stdin for "-".
+func openInput(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(name)
+}
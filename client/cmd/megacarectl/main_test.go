@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// --- Test Setup ---
+
+// testApp returns an app whose config directory holds cfg and whose
+// environment variables are env.
+func testApp(t *testing.T, cfg *Config, env map[string]string) (*app, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+	dir := t.TempDir()
+	if cfg != nil {
+		data, _ := json.Marshal(cfg)
+		if err := os.WriteFile(filepath.Join(dir, "config.json"), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	a := &app{
+		stdout: &stdout, stderr: &stderr, configDir: dir, httpClient: http.DefaultClient,
+		getenv: func(name string) string { return env[name] },
+	}
+	return a, &stdout, &stderr
+}
+
+type apiRequest struct {
+	method, path, query, idempotencyKey, authorization string
+	body                                               map[string]any
+}
+
+// apiServer answers requests with the handler's responses and records them.
+func apiServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) (*httptest.Server, func() []apiRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []apiRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := apiRequest{
+			method: r.Method, path: r.URL.Path, query: r.URL.RawQuery,
+			idempotencyKey: r.Header.Get("Idempotency-Key"), authorization: r.Header.Get("Authorization"),
+		}
+		_ = json.NewDecoder(r.Body).Decode(&rec.body)
+		mu.Lock()
+		requests = append(requests, rec)
+		mu.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, func() []apiRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]apiRequest(nil), requests...)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "records.ndjson")
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// jwt returns an unsigned token with the exp claim, enough for the client,
+// which leaves verification to the API.
+func jwt(exp time.Time) string {
+	payload, _ := json.Marshal(map[string]any{"exp": exp.Unix()})
+	return "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+// --- Environment Test Cases ---
+
+func TestCredentialsPreferAnAPIKeyOverTokens(t *testing.T) {
+	ts, requests := apiServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"patient_id": "p-1"})
+	})
+	cfg := &Config{Default: "staging", Environments: map[string]Environment{"staging": {URL: ts.URL}}}
+	a, _, _ := testApp(t, cfg, map[string]string{"MEGACARE_API_KEY": "mk_1", "MEGACARE_TOKEN": "tok"})
+
+	if err := a.run(context.Background(), []string{"patients", "get", "p-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := requests()[0]
+	if got.path != "/api/v1/patients/p-1" || got.authorization != "" {
+		t.Errorf("request = %s with Authorization %q, want /api/v1/patients/p-1 with the API key only", got.path, got.authorization)
+	}
+}
+
+func TestUnknownEnvironmentNamesTheConfiguredOnes(t *testing.T) {
+	cfg := &Config{Environments: map[string]Environment{"prod": {URL: "https://a"}, "dev": {URL: "https://b"}}}
+	a, _, _ := testApp(t, cfg, nil)
+
+	err := a.run(context.Background(), []string{"-env", "qa", "patients", "get", "p-1"})
+
+	if err == nil || !strings.Contains(err.Error(), "dev, prod") {
+		t.Errorf("err = %v, want one naming dev, prod", err)
+	}
+}
+
+func TestMissingRequiredFlagsPrintTheUsage(t *testing.T) {
+	a, _, stderr := testApp(t, nil, nil)
+
+	err := a.run(context.Background(), []string{"-url", "https://api.example", "appointments", "book", "-patient", "p-1"})
+
+	if err != errUsage || !strings.Contains(stderr.String(), "usage: megacarectl appointments book") {
+		t.Errorf("err = %v, stderr = %q; want the usage", err, stderr.String())
+	}
+}
+
+// --- OIDC Test Cases ---
+
+// oidcServer is an OIDC provider whose device flow is approved on the second
+// poll.
+func oidcServer(t *testing.T, idToken string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	polls := 0
+	mux := http.NewServeMux()
+	var ts *httptest.Server
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"token_endpoint": ts.URL + "/token", "device_authorization_endpoint": ts.URL + "/device"})
+	})
+	mux.HandleFunc("POST /device", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.Form.Get("client_id") != "cli" || !strings.Contains(r.Form.Get("scope"), "offline_access") {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_request"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"device_code": "dc", "user_code": "ABCD-EFGH", "verification_uri": "https://idp.example/activate", "expires_in": 60})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Form.Get("grant_type") {
+		case "refresh_token":
+			writeJSON(w, http.StatusOK, map[string]any{"id_token": idToken, "expires_in": 3600})
+		case "urn:ietf:params:oauth:grant-type:device_code":
+			if polls++; polls == 1 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "authorization_pending"})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"id_token": idToken, "refresh_token": "rt", "expires_in": 3600})
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "unsupported_grant_type"})
+		}
+	})
+	ts = httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestLoginSavesTheSessionUsedByLaterCommands(t *testing.T) {
+	defer func(interval time.Duration) { devicePollInterval = interval }(devicePollInterval)
+	devicePollInterval = time.Millisecond
+	token := jwt(time.Now().Add(time.Hour))
+	idp := oidcServer(t, token)
+	ts, requests := apiServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"patient_id": "p-1"})
+	})
+	cfg := &Config{Default: "staging", Environments: map[string]Environment{
+		"staging": {URL: ts.URL, OIDC: &OIDCConfig{Issuer: idp.URL + "/", ClientID: "cli"}},
+	}}
+	a, _, stderr := testApp(t, cfg, nil)
+
+	if err := a.run(context.Background(), []string{"login"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr.String(), "ABCD-EFGH") {
+		t.Errorf("stderr = %q, want the user code", stderr.String())
+	}
+	info, err := os.Stat(filepath.Join(a.configDir, "sessions", "staging.json"))
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("session file: %v, %v; want mode 0600", info, err)
+	}
+
+	if err := a.run(context.Background(), []string{"patients", "get", "p-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := requests()[0].authorization; got != "Bearer "+token {
+		t.Errorf("Authorization = %q, want the ID token from the login", got)
+	}
+}
+
+func TestExpiredSessionIsRefreshed(t *testing.T) {
+	fresh := jwt(time.Now().Add(time.Hour))
+	idp := oidcServer(t, fresh)
+	ts, requests := apiServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"patient_id": "p-1"})
+	})
+	cfg := &Config{Default: "staging", Environments: map[string]Environment{
+		"staging": {URL: ts.URL, OIDC: &OIDCConfig{Issuer: idp.URL, ClientID: "cli"}},
+	}}
+	a, _, _ := testApp(t, cfg, nil)
+	if err := a.selectEnvironment("", ""); err != nil {
+		t.Fatal(err)
+	}
+	expired := &savedTokens{IDToken: jwt(time.Now().Add(-time.Minute)), RefreshToken: "rt", Expiry: time.Now().Add(-time.Minute)}
+	if err := a.session().save(expired); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.run(context.Background(), []string{"patients", "get", "p-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := requests()[0].authorization; got != "Bearer "+fresh {
+		t.Errorf("Authorization = %q, want the refreshed token", got)
+	}
+	saved, err := a.session().load()
+	if err != nil || saved.IDToken != fresh || saved.RefreshToken != "rt" {
+		t.Errorf("saved session = %+v, %v; want the new token and the kept refresh token", saved, err)
+	}
+}
+
+// --- Import Test Cases ---
+
+func TestImportCreatesEachLineWithAStableIdempotencyKey(t *testing.T) {
+	var mu sync.Mutex
+	n := 0
+	ts, requests := apiServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		n++
+		id := fmt.Sprintf("p-%d", n)
+		mu.Unlock()
+		writeJSON(w, http.StatusCreated, map[string]any{"patient_id": id})
+	})
+	a, stdout, _ := testApp(t, nil, map[string]string{"MEGACARE_API_KEY": "mk_1"})
+	file := writeFile(t, `{"given_name":"Ann","family_name":"Lee","dob":"1980-01-31","mrn":"M1"}
+
+{"given_name":"Bo","family_name":"Ng","dob":"1975-06-01","mrn":"M2"}
+`)
+
+	args := []string{"-url", ts.URL, "import", "-type", "patients", "-workers", "1", file}
+	if err := a.run(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	if got := stdout.String(); got != "1\tp-1\n3\tp-2\n" {
+		t.Errorf("stdout = %q, want the line numbers and IDs", got)
+	}
+	if err := a.run(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+
+	got := requests()
+	if len(got) != 4 || got[0].path != "/api/v1/patients" || got[0].body["mrn"] != "M1" {
+		t.Fatalf("requests = %+v, want two POST /api/v1/patients per run", got)
+	}
+	if got[0].idempotencyKey == got[1].idempotencyKey || got[0].idempotencyKey != got[2].idempotencyKey {
+		t.Errorf("keys = %q %q %q, want one key per line, the same on every run", got[0].idempotencyKey, got[1].idempotencyKey, got[2].idempotencyKey)
+	}
+}
+
+func TestImportSendsNothingWhenALineIsInvalid(t *testing.T) {
+	ts, requests := apiServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusCreated, map[string]any{"observation_id": "o-1"})
+	})
+	a, _, _ := testApp(t, nil, map[string]string{"MEGACARE_API_KEY": "mk_1"})
+	file := writeFile(t, `{"patient_id":"p-1","code":"8867-4","value":61,"unit":"/min","effective_at":"2026-01-02T03:04:05Z"}
+{"patient_id":"p-1","code":"8867-4","valu":61,"effective_at":"2026-01-02T03:04:05Z"}
+`)
+
+	err := a.run(context.Background(), []string{"-url", ts.URL, "import", "-type", "observations", file})
+
+	if err == nil || !strings.Contains(err.Error(), `line 2: json: unknown field "valu"`) {
+		t.Errorf("err = %v, want line 2's unknown field", err)
+	}
+	if got := requests(); len(got) != 0 {
+		t.Errorf("sent %d requests, want none", len(got))
+	}
+}
+
+func TestImportReportsFailedLines(t *testing.T) {
+	ts, _ := apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"type":"urn:megacare:problem:conflict","title":"Conflict","status":409,"detail":"MRN already in use"}`))
+	})
+	a, _, stderr := testApp(t, nil, map[string]string{"MEGACARE_API_KEY": "mk_1"})
+	file := writeFile(t, `{"given_name":"Ann","family_name":"Lee","dob":"1980-01-31","mrn":"M1"}`)
+
+	err := a.run(context.Background(), []string{"-url", ts.URL, "import", "-type", "patients", file})
+
+	if err == nil || !strings.Contains(err.Error(), "1 of 1 records failed (lines 1)") {
+		t.Errorf("err = %v, want the failed line", err)
+	}
+	if !strings.Contains(stderr.String(), "MRN already in use") {
+		t.Errorf("stderr = %q, want the problem detail", stderr.String())
+	}
+}
+
+// --- Audit Tail Test Cases ---
+
+func TestAuditTailPrintsEachEventOnceOldestFirst(t *testing.T) {
+	base := time.Now().UTC().Truncate(time.Second).Add(-time.Minute)
+	at := func(s int) string { return base.Add(time.Duration(s) * time.Second).Format(time.RFC3339) }
+	event := func(id string, s int) map[string]any {
+		return map[string]any{"event_id": id, "occurred_at": at(s), "action": "read", "outcome": "success", "status_code": 200, "method": "GET", "path": "/api/v1/patients/p-1"}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	polls := 0
+	ts, requests := apiServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		polls++
+		switch polls {
+		case 1: // Newest first.
+			writeJSON(w, http.StatusOK, map[string]any{"items": []any{event("e2", 2), event("e1", 1)}})
+		case 2: // e2 again, as events at From are listed too.
+			writeJSON(w, http.StatusOK, map[string]any{"items": []any{event("e3", 3), event("e2", 2)}})
+		default:
+			cancel()
+			writeJSON(w, http.StatusOK, map[string]any{"items": []any{}})
+		}
+	})
+	a, stdout, _ := testApp(t, nil, map[string]string{"MEGACARE_API_KEY": "mk_1"})
+
+	err := a.run(ctx, []string{"-url", ts.URL, "audit", "tail", "-patient", "p-1", "-interval", "1ms", "-json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		var e struct {
+			EventID string `json:"event_id"`
+		}
+		_ = json.Unmarshal([]byte(line), &e)
+		ids = append(ids, e.EventID)
+	}
+	if strings.Join(ids, ",") != "e1,e2,e3" {
+		t.Errorf("printed %v, want e1,e2,e3", ids)
+	}
+	if q := requests()[1].query; !strings.Contains(q, "from="+url.QueryEscape(at(2))) || !strings.Contains(q, "patientId=p-1") {
+		t.Errorf("second poll query = %q, want from the newest event", q)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/megacare-dev/mega-care-api/client/megacare"
+)
+
+// devicePollInterval is how often the token endpoint is polled during login
+// when the provider does not say.
+var devicePollInterval = 5 * time.Second
+
+// expiryMargin renews tokens this long before they expire, so that a token
+// does not run out while a request is in flight.
+const expiryMargin = time.Minute
+
+// session is the OIDC session of one environment, saved as
+// sessions/<env>.json in the config directory.
+type session struct {
+	a   *app
+	cfg *OIDCConfig
+}
+
+type savedTokens struct {
+	IDToken      string    `json:"id_token,omitempty"`
+	AccessToken  string    `json:"access_token,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry"` // When the token sent to the API expires.
+}
+
+type providerMetadata struct {
+	TokenEndpoint               string `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+}
+
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	IDToken      string `json:"id_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// oauthError is an error response of an OAuth endpoint (RFC 6749 §5.2).
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *oauthError) Error() string {
+	if e.Description != "" {
+		return e.Code + ": " + e.Description
+	}
+	return e.Code
+}
+
+func (a *app) session() *session {
+	return &session{a: a, cfg: a.env.OIDC}
+}
+
+func (s *session) path() string {
+	return filepath.Join(s.a.configDir, "sessions", s.a.envName+".json")
+}
+
+func (s *session) load() (*savedTokens, error) {
+	data, err := os.ReadFile(s.path())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("not signed in to %s; run megacarectl -env %s login", s.a.envName, s.a.envName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tokens := &savedTokens{}
+	if err := json.Unmarshal(data, tokens); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path(), err)
+	}
+	return tokens, nil
+}
+
+// save writes the tokens readable by the user only.
+func (s *session) save(tokens *savedTokens) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path()), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path())
+}
+
+// tokenSource returns the saved session's token, refreshing it when it is
+// about to expire.
+func (s *session) tokenSource() megacare.TokenSource {
+	var mu sync.Mutex
+	var tokens *savedTokens
+	return func(ctx context.Context) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if tokens == nil {
+			loaded, err := s.load()
+			if err != nil {
+				return "", err
+			}
+			tokens = loaded
+		}
+		if time.Now().Add(expiryMargin).Before(tokens.Expiry) {
+			return s.apiToken(tokens), nil
+		}
+		if tokens.RefreshToken == "" {
+			return "", fmt.Errorf("the session for %s has expired; run megacarectl -env %s login", s.a.envName, s.a.envName)
+		}
+		refreshed, err := s.refresh(ctx, tokens)
+		if err != nil {
+			return "", fmt.Errorf("refreshing the session for %s (run megacarectl -env %s login to sign in again): %w", s.a.envName, s.a.envName, err)
+		}
+		tokens = refreshed
+		return s.apiToken(tokens), nil
+	}
+}
+
+// apiToken is the token the API verifies.
+func (s *session) apiToken(tokens *savedTokens) string {
+	if s.cfg.Token == "access_token" {
+		return tokens.AccessToken
+	}
+	return tokens.IDToken
+}
+
+func (s *session) refresh(ctx context.Context, previous *savedTokens) (*savedTokens, error) {
+	meta, err := s.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {previous.RefreshToken}, "client_id": {s.cfg.ClientID}}
+	resp := &tokenResponse{}
+	if err := s.postForm(ctx, meta.TokenEndpoint, form, resp); err != nil {
+		return nil, err
+	}
+	if resp.RefreshToken == "" {
+		resp.RefreshToken = previous.RefreshToken
+	}
+	return s.store(resp)
+}
+
+// store saves the tokens of a token response.
+func (s *session) store(resp *tokenResponse) (*savedTokens, error) {
+	tokens := &savedTokens{IDToken: resp.IDToken, AccessToken: resp.AccessToken, RefreshToken: resp.RefreshToken}
+	token := s.apiToken(tokens)
+	if token == "" {
+		return nil, fmt.Errorf("the provider issued no %s; check the token setting of environment %q", orDefault(s.cfg.Token, "id_token"), s.a.envName)
+	}
+	expiry, ok := jwtExpiry(token)
+	if !ok {
+		expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	tokens.Expiry = expiry
+	return tokens, s.save(tokens)
+}
+
+// deviceLogin signs in with the device authorization grant (RFC 8628): the
+// user approves the sign-in in a browser, on any device.
+func (s *session) deviceLogin(ctx context.Context) (*savedTokens, error) {
+	meta, err := s.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if meta.DeviceAuthorizationEndpoint == "" {
+		return nil, fmt.Errorf("%s does not support the device authorization grant", s.cfg.Issuer)
+	}
+	scopes := s.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "offline_access"}
+	}
+	form := url.Values{"client_id": {s.cfg.ClientID}, "scope": {strings.Join(scopes, " ")}}
+	if s.cfg.Audience != "" {
+		form.Set("audience", s.cfg.Audience)
+	}
+	auth := &deviceAuthorization{}
+	if err := s.postForm(ctx, meta.DeviceAuthorizationEndpoint, form, auth); err != nil {
+		return nil, err
+	}
+	if auth.VerificationURIComplete != "" {
+		fmt.Fprintf(s.a.stderr, "To sign in, open %s and check that it shows the code %s.\n", auth.VerificationURIComplete, auth.UserCode)
+	} else {
+		fmt.Fprintf(s.a.stderr, "To sign in, open %s and enter the code %s.\n", auth.VerificationURI, auth.UserCode)
+	}
+
+	interval := devicePollInterval
+	if auth.Interval > 0 {
+		interval = time.Duration(auth.Interval) * time.Second
+	}
+	if auth.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(auth.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+	form = url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:device_code"}, "device_code": {auth.DeviceCode}, "client_id": {s.cfg.ClientID}}
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, errors.New("the sign-in code expired before it was used")
+			}
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		resp := &tokenResponse{}
+		err := s.postForm(ctx, meta.TokenEndpoint, form, resp)
+		var oe *oauthError
+		switch {
+		case err == nil:
+			return s.store(resp)
+		case errors.As(err, &oe) && oe.Code == "authorization_pending":
+		case errors.As(err, &oe) && oe.Code == "slow_down":
+			interval += 5 * time.Second
+		default:
+			return nil, err
+		}
+	}
+}
+
+func (s *session) discover(ctx context.Context) (*providerMetadata, error) {
+	endpoint := strings.TrimSuffix(s.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	meta := &providerMetadata{}
+	if err := s.send(req, meta); err != nil {
+		return nil, err
+	}
+	if meta.TokenEndpoint == "" {
+		return nil, fmt.Errorf("%s names no token_endpoint", endpoint)
+	}
+	return meta, nil
+}
+
+func (s *session) postForm(ctx context.Context, endpoint string, form url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return s.send(req, out)
+}
+
+// send decodes a JSON response into out, or returns the OAuth error of a
+// failed one.
+func (s *session) send(req *http.Request, out any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		oe := &oauthError{}
+		if json.Unmarshal(body, oe) == nil && oe.Code != "" {
+			return oe
+		}
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL.Redacted(), resp.Status)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%s %s: %w", req.Method, req.URL.Redacted(), err)
+	}
+	return nil
+}
+
+// jwtExpiry reads the exp claim of a JWT without verifying it; the API
+// does the verifying.
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
+
+func orDefault(value, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
+
+func runLogin(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlagSet("login", "")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if a.env.OIDC == nil {
+		return fmt.Errorf("environment %q has no oidc settings in %s", a.envName, a.configPath())
+	}
+	s := a.session()
+	if _, err := s.deviceLogin(ctx); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stderr, "Signed in to %s.\n", a.envName)
+	return nil
+}
+
+func runLogout(_ context.Context, a *app, args []string) error {
+	flags := a.newFlagSet("logout", "")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if a.envName == "" {
+		return errors.New("no environment selected: pass -env")
+	}
+	err := os.Remove(a.session().path())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/megacare-dev/mega-care-api/client/megacare"
+)
+
+func runPatientsCreate(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlagSet("patients create", "")
+	file := flags.String("f", "", "read the patient from a JSON file in the API's format (\"-\" for stdin) instead of the flags")
+	givenName := flags.String("given-name", "", "given name")
+	familyName := flags.String("family-name", "", "family name")
+	dob := flags.String("dob", "", "date of birth, YYYY-MM-DD")
+	sex := flags.String("sex", "", "sex, e.g. female")
+	mrn := flags.String("mrn", "", "medical record number; must not be in use")
+	nhsNumber := flags.String("nhs-number", "", "10-digit NHS number")
+	phone := flags.String("phone", "", "phone number")
+	email := flags.String("email", "", "email address")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	patient := &megacare.PatientCreate{}
+	if *file != "" {
+		if err := readJSONFile(*file, patient); err != nil {
+			return err
+		}
+	} else {
+		if *givenName == "" || *familyName == "" || *dob == "" || *mrn == "" {
+			return usageError(flags, "-given-name, -family-name, -dob and -mrn are required, or -f")
+		}
+		date, err := megacare.ParseDate(*dob)
+		if err != nil {
+			return usageError(flags, "-dob: %v", err)
+		}
+		patient = &megacare.PatientCreate{GivenName: *givenName, FamilyName: *familyName, DOB: date, Sex: *sex, MRN: *mrn, NHSNumber: *nhsNumber}
+		if *phone != "" || *email != "" {
+			patient.Contact = &megacare.ContactInfo{PhoneNumber: *phone, Email: *email}
+		}
+	}
+
+	client, err := a.apiClient()
+	if err != nil {
+		return err
+	}
+	created, err := client.Patients.Create(ctx, patient)
+	if err != nil {
+		return err
+	}
+	return a.printJSON(created)
+}
+
+func runPatientsGet(ctx context.Context, a *app, args []string) error {
+	flags := a.newFlagSet("patients get", "<patient-id>")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() != 1 {
+		return usageError(flags, "expected one patient ID")
+	}
+
+	client, err := a.apiClient()
+	if err != nil {
+		return err
+	}
+	patient, err := client.Patients.Get(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return a.printJSON(patient)
+}
+
+// readJSONFile decodes a file, rejecting fields the API would not accept.
+func readJSONFile(name string, v any) error {
+	in, err := openInput(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	dec := json.NewDecoder(in)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,3 @@
+module github.com/megacare-dev/mega-care-api/client
+
+go 1.23
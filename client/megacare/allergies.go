@@ -0,0 +1,133 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Allergy is an allergy or intolerance of a patient.
+type Allergy struct {
+	Substance          Coding                    `json:"substance"` // The substance the patient reacts to, e.g. an RxNorm or SNOMED CT code.
+	Type               string                    `json:"type,omitempty"`
+	Category           string                    `json:"category,omitempty"`
+	Criticality        AllergyCriticality        `json:"criticality,omitempty"`
+	ClinicalStatus     AllergyClinicalStatus     `json:"clinical_status,omitempty"`
+	VerificationStatus AllergyVerificationStatus `json:"verification_status,omitempty"`
+	Reactions          []AllergyReaction         `json:"reactions,omitempty"`
+	OnsetDate          *Date                     `json:"onset_date,omitempty"`
+	Note               string                    `json:"note,omitempty"`
+	AllergyID          string                    `json:"allergy_id"`
+	PatientID          string                    `json:"patient_id"`
+	RecordedBy         string                    `json:"recorded_by"`
+	DeletedAt          *time.Time                `json:"deleted_at,omitempty"` // Set while the record is deleted; see Restore.
+	DeletedBy          string                    `json:"deleted_by,omitempty"`
+	Version            int                       `json:"version"`
+	CreatedAt          time.Time                 `json:"created_at"`
+	UpdatedAt          time.Time                 `json:"updated_at"`
+}
+
+// AllergyCreate is the body of AllergiesService.Create.
+type AllergyCreate struct {
+	Substance          Coding                    `json:"substance"` // The substance the patient reacts to, e.g. an RxNorm or SNOMED CT code.
+	Type               string                    `json:"type,omitempty"`
+	Category           string                    `json:"category,omitempty"`
+	Criticality        AllergyCriticality        `json:"criticality,omitempty"`
+	ClinicalStatus     AllergyClinicalStatus     `json:"clinical_status,omitempty"`
+	VerificationStatus AllergyVerificationStatus `json:"verification_status,omitempty"`
+	Reactions          []AllergyReaction         `json:"reactions,omitempty"`
+	OnsetDate          *Date                     `json:"onset_date,omitempty"`
+	Note               string                    `json:"note,omitempty"`
+}
+
+// AllergyUpdate is the body of AllergiesService.Update.
+type AllergyUpdate struct {
+	Category           *string                    `json:"category,omitempty"`
+	Criticality        *AllergyCriticality        `json:"criticality,omitempty"`
+	ClinicalStatus     *AllergyClinicalStatus     `json:"clinical_status,omitempty"`
+	VerificationStatus *AllergyVerificationStatus `json:"verification_status,omitempty"`
+	Reactions          []AllergyReaction          `json:"reactions,omitempty"`
+	OnsetDate          *Date                      `json:"onset_date,omitempty"`
+	Note               *string                    `json:"note,omitempty"`
+}
+
+// AllergyReaction is a reaction the patient had to the substance.
+type AllergyReaction struct {
+	Manifestations []Coding   `json:"manifestations"` // Clinical signs, e.g. a SNOMED CT code for urticaria.
+	Severity       string     `json:"severity,omitempty"`
+	Onset          *time.Time `json:"onset,omitempty"`
+	Note           string     `json:"note,omitempty"`
+}
+
+// AllergyCriticality is the potential harm of a future reaction.
+type AllergyCriticality string
+
+const (
+	AllergyCriticalityLow            AllergyCriticality = "low"
+	AllergyCriticalityHigh           AllergyCriticality = "high"
+	AllergyCriticalityUnableToAssess AllergyCriticality = "unable-to-assess"
+)
+
+// AllergyClinicalStatus is whether an allergy is current.
+type AllergyClinicalStatus string
+
+const (
+	AllergyClinicalStatusActive   AllergyClinicalStatus = "active"
+	AllergyClinicalStatusInactive AllergyClinicalStatus = "inactive"
+	AllergyClinicalStatusResolved AllergyClinicalStatus = "resolved"
+)
+
+// AllergyVerificationStatus is how certain an allergy is.
+type AllergyVerificationStatus string
+
+const (
+	AllergyVerificationStatusUnconfirmed    AllergyVerificationStatus = "unconfirmed"
+	AllergyVerificationStatusConfirmed      AllergyVerificationStatus = "confirmed"
+	AllergyVerificationStatusRefuted        AllergyVerificationStatus = "refuted"
+	AllergyVerificationStatusEnteredInError AllergyVerificationStatus = "entered-in-error"
+)
+
+// AllergiesService records allergies under /patients/{patientId}/allergies.
+type AllergiesService service
+
+// AllergyListOptions filter AllergiesService.List.
+type AllergyListOptions struct {
+	Substance      string // Only records for this substance code.
+	System         string // Code system of Substance; any system if empty.
+	ClinicalStatus AllergyClinicalStatus
+	IncludeDeleted bool // Also list deleted records; administrators only.
+}
+
+// Create records an allergy for a patient.
+func (s *AllergiesService) Create(ctx context.Context, patientID string, allergy *AllergyCreate) (*Allergy, error) {
+	return call[Allergy](ctx, s.client, http.MethodPost, path("patients", patientID, "allergies"), nil, allergy)
+}
+
+// List returns a patient's allergies; opts may be nil.
+func (s *AllergiesService) List(ctx context.Context, patientID string, opts *AllergyListOptions) ([]Allergy, error) {
+	o := deref(opts)
+	q := query{}.setStr("substance", o.Substance).setStr("system", o.System).setStr("clinicalStatus", string(o.ClinicalStatus)).setBool("includeDeleted", o.IncludeDeleted)
+	return list[Allergy](ctx, s.client, path("patients", patientID, "allergies"), url.Values(q))
+}
+
+// Get returns an allergy of a patient by ID.
+func (s *AllergiesService) Get(ctx context.Context, patientID, allergyID string) (*Allergy, error) {
+	return call[Allergy](ctx, s.client, http.MethodGet, path("patients", patientID, "allergies", allergyID), nil, nil)
+}
+
+// Update changes the fields of an allergy that are set in update.
+func (s *AllergiesService) Update(ctx context.Context, patientID, allergyID string, update *AllergyUpdate) (*Allergy, error) {
+	return call[Allergy](ctx, s.client, http.MethodPatch, path("patients", patientID, "allergies", allergyID), nil, update)
+}
+
+// Delete deletes an allergy recorded in error; it can be restored.
+func (s *AllergiesService) Delete(ctx context.Context, patientID, allergyID string) error {
+	return s.client.do(ctx, http.MethodDelete, path("patients", patientID, "allergies", allergyID), nil, nil, nil)
+}
+
+// Restore undoes the deletion of an allergy. Administrators only; the API
+// answers 409 if the substance has been recorded again since.
+func (s *AllergiesService) Restore(ctx context.Context, patientID, allergyID string) (*Allergy, error) {
+	return call[Allergy](ctx, s.client, http.MethodPost, path("patients", patientID, "allergies", allergyID, "restore"), nil, nil)
+}
@@ -0,0 +1,79 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// APIKey is an API key issued to an integrator. The key itself is only
+// returned when it is created or rotated.
+type APIKey struct {
+	KeyID              string       `json:"key_id"`
+	Name               string       `json:"name"`
+	Scopes             []string     `json:"scopes"`
+	RateLimitPerMinute *int         `json:"rate_limit_per_minute,omitempty"`
+	Status             APIKeyStatus `json:"status,omitempty"`
+	CreatedBy          string       `json:"created_by"`
+	RotatedAt          *time.Time   `json:"rotated_at,omitempty"`
+	RevokedAt          *time.Time   `json:"revoked_at,omitempty"`
+	RevokedBy          string       `json:"revoked_by,omitempty"`
+	TenantID           string       `json:"tenant_id,omitempty"` // The organization the key acts for, its creator's.
+	Version            int          `json:"version"`
+	CreatedAt          time.Time    `json:"created_at"`
+	UpdatedAt          time.Time    `json:"updated_at"`
+}
+
+// APIKeyWithSecret is an API key with its secret, as returned when it is
+// created or rotated.
+type APIKeyWithSecret struct {
+	APIKey
+	Key string `json:"key"` // Send as the X-Api-Key header. Store it securely; it is not shown again.
+}
+
+// APIKeyCreate is the body of APIKeysService.Create.
+type APIKeyCreate struct {
+	Name               string   `json:"name"` // Identifies the integrator, e.g. "Acme Sleep Lab backend".
+	Scopes             []string `json:"scopes"`
+	RateLimitPerMinute *int     `json:"rate_limit_per_minute,omitempty"` // Requests per minute; the default limit when unset.
+}
+
+// APIKeyStatus is whether an API key can be used.
+type APIKeyStatus string
+
+const (
+	APIKeyStatusActive  APIKeyStatus = "active"
+	APIKeyStatusRevoked APIKeyStatus = "revoked"
+)
+
+// APIKeysService issues API keys under /admin/api-keys. It needs the
+// integration-admin role.
+type APIKeysService service
+
+// Create issues an API key. The response includes the key, which is not
+// returned again.
+func (s *APIKeysService) Create(ctx context.Context, key *APIKeyCreate) (*APIKeyWithSecret, error) {
+	return call[APIKeyWithSecret](ctx, s.client, http.MethodPost, path("admin", "api-keys"), nil, key)
+}
+
+// List pages through API keys, oldest first, including revoked ones; opts
+// may be nil.
+func (s *APIKeysService) List(opts *ListOptions) *Pager[APIKey] {
+	return newPager[APIKey](s.client, path("admin", "api-keys"), nil, deref(opts))
+}
+
+// Get returns an API key by ID.
+func (s *APIKeysService) Get(ctx context.Context, keyID string) (*APIKey, error) {
+	return call[APIKey](ctx, s.client, http.MethodGet, path("admin", "api-keys", keyID), nil, nil)
+}
+
+// Rotate replaces the secret of a key, keeping its ID, scopes and limit. The
+// old secret stops working shortly after.
+func (s *APIKeysService) Rotate(ctx context.Context, keyID string) (*APIKeyWithSecret, error) {
+	return call[APIKeyWithSecret](ctx, s.client, http.MethodPost, path("admin", "api-keys", keyID, "rotate"), nil, nil)
+}
+
+// Revoke revokes a key for good.
+func (s *APIKeysService) Revoke(ctx context.Context, keyID string) (*APIKey, error) {
+	return call[APIKey](ctx, s.client, http.MethodPost, path("admin", "api-keys", keyID, "revoke"), nil, nil)
+}
@@ -0,0 +1,144 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Appointment is a booked visit with a practitioner.
+type Appointment struct {
+	PatientID          string            `json:"patient_id"`
+	PractitionerID     string            `json:"practitioner_id"`
+	Start              time.Time         `json:"start"`
+	End                time.Time         `json:"end"`
+	AppointmentType    string            `json:"appointment_type,omitempty"` // e.g. "cpap-setup", "follow-up", "mask-fitting".
+	Reason             string            `json:"reason,omitempty"`
+	Location           string            `json:"location,omitempty"`
+	AppointmentID      string            `json:"appointment_id"`
+	Status             AppointmentStatus `json:"status,omitempty"`
+	CancellationReason string            `json:"cancellation_reason,omitempty"`
+	ReminderSentAt     *time.Time        `json:"reminder_sent_at,omitempty"` // When the reminder job announced the upcoming appointment.
+	Version            int               `json:"version"`
+	CreatedAt          time.Time         `json:"created_at"`
+	UpdatedAt          time.Time         `json:"updated_at"`
+}
+
+// AppointmentCreate is the body of AppointmentsService.Create.
+type AppointmentCreate struct {
+	PatientID       string    `json:"patient_id"`
+	PractitionerID  string    `json:"practitioner_id"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	AppointmentType string    `json:"appointment_type,omitempty"` // e.g. "cpap-setup", "follow-up", "mask-fitting".
+	Reason          string    `json:"reason,omitempty"`
+	Location        string    `json:"location,omitempty"`
+}
+
+// AppointmentReschedule is the body of AppointmentsService.Reschedule.
+type AppointmentReschedule struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// AppointmentCancel is the body of AppointmentsService.Cancel.
+type AppointmentCancel struct {
+	CancellationReason string `json:"cancellation_reason,omitempty"`
+}
+
+// AppointmentStatusUpdate is the body of AppointmentsService.SetStatus.
+type AppointmentStatusUpdate struct {
+	Status AppointmentStatus `json:"status"`
+}
+
+// AppointmentStatus is the status of an appointment.
+type AppointmentStatus string
+
+const (
+	AppointmentStatusBooked    AppointmentStatus = "booked"
+	AppointmentStatusArrived   AppointmentStatus = "arrived"
+	AppointmentStatusFulfilled AppointmentStatus = "fulfilled"
+	AppointmentStatusCancelled AppointmentStatus = "cancelled"
+)
+
+// AppointmentReminder is a reminder of an appointment sent to its patient.
+type AppointmentReminder struct {
+	ReminderID       string         `json:"reminder_id"`
+	AppointmentID    string         `json:"appointment_id"`
+	PatientID        string         `json:"patient_id"`
+	OffsetHours      int            `json:"offset_hours"` // How long before the appointment the reminder was meant for.
+	DueAt            time.Time      `json:"due_at"`       // Earlier than the offset if it fell in the patient's quiet hours.
+	AppointmentStart time.Time      `json:"appointment_start"`
+	Status           ReminderStatus `json:"status"`
+	Reason           string         `json:"reason,omitempty"` // Why it was cancelled or skipped.
+	FinishedAt       *time.Time     `json:"finished_at,omitempty"`
+	Version          int            `json:"version"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+// ReminderStatus is the status of an appointment reminder.
+type ReminderStatus string
+
+const (
+	ReminderStatusScheduled ReminderStatus = "scheduled"
+	ReminderStatusSent      ReminderStatus = "sent"
+	ReminderStatusCancelled ReminderStatus = "cancelled"
+	ReminderStatusSkipped   ReminderStatus = "skipped" // The appointment was no longer booked for its time when it was due.
+)
+
+// AppointmentsService books and manages appointments under /appointments.
+type AppointmentsService service
+
+// AppointmentListOptions filter AppointmentsService.List.
+type AppointmentListOptions struct {
+	ListOptions
+	PatientID      string
+	PractitionerID string
+	From           time.Time // Only appointments starting at or after From.
+	To             time.Time // Only appointments starting before To.
+	Search
+}
+
+// Create books an appointment. The API answers 409 if the practitioner is
+// already booked for an overlapping time.
+func (s *AppointmentsService) Create(ctx context.Context, appointment *AppointmentCreate) (*Appointment, error) {
+	return call[Appointment](ctx, s.client, http.MethodPost, "appointments", nil, appointment)
+}
+
+// List pages through appointments ordered by start time unless sorted
+// otherwise; opts may be nil. They can be filtered by status,
+// appointmentType, start, end, createdAt and updatedAt, and sorted by any
+// of the last four.
+func (s *AppointmentsService) List(opts *AppointmentListOptions) *Pager[Appointment] {
+	o := deref(opts)
+	q := query{}.setStr("patientId", o.PatientID).setStr("practitionerId", o.PractitionerID).setTime("from", o.From).setTime("to", o.To).setSearch(o.Search)
+	return newPager[Appointment](s.client, "appointments", url.Values(q), o.ListOptions)
+}
+
+// Get returns an appointment by ID.
+func (s *AppointmentsService) Get(ctx context.Context, appointmentID string) (*Appointment, error) {
+	return call[Appointment](ctx, s.client, http.MethodGet, path("appointments", appointmentID), nil, nil)
+}
+
+// Reschedule moves a booked appointment to a new time.
+func (s *AppointmentsService) Reschedule(ctx context.Context, appointmentID string, reschedule *AppointmentReschedule) (*Appointment, error) {
+	return call[Appointment](ctx, s.client, http.MethodPost, path("appointments", appointmentID, "reschedule"), nil, reschedule)
+}
+
+// Cancel cancels an appointment.
+func (s *AppointmentsService) Cancel(ctx context.Context, appointmentID string, cancel *AppointmentCancel) (*Appointment, error) {
+	return call[Appointment](ctx, s.client, http.MethodPost, path("appointments", appointmentID, "cancel"), nil, cancel)
+}
+
+// SetStatus moves an appointment to a new status, e.g. arrived or fulfilled.
+func (s *AppointmentsService) SetStatus(ctx context.Context, appointmentID string, update *AppointmentStatusUpdate) (*Appointment, error) {
+	return call[Appointment](ctx, s.client, http.MethodPost, path("appointments", appointmentID, "status"), nil, update)
+}
+
+// Reminders returns the reminders scheduled for an appointment, earliest due
+// first, including those already sent or cancelled.
+func (s *AppointmentsService) Reminders(ctx context.Context, appointmentID string) ([]AppointmentReminder, error) {
+	return list[AppointmentReminder](ctx, s.client, path("appointments", appointmentID, "reminders"), nil)
+}
@@ -0,0 +1,129 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// AuditEvent records one request to the API: who did what to which record.
+type AuditEvent struct {
+	OccurredAt    time.Time    `json:"occurred_at"`
+	Action        AuditAction  `json:"action"`
+	Outcome       AuditOutcome `json:"outcome"`
+	StatusCode    int          `json:"status_code"`
+	Method        string       `json:"method"`
+	Route         string       `json:"route"`               // Route template, e.g. "/api/v1/patients/{patientId}".
+	Path          string       `json:"path"`                // Request path without the query string.
+	ActorUID      string       `json:"actor_uid,omitempty"` // Unset when the request was not authenticated.
+	ActorRoles    []string     `json:"actor_roles,omitempty"`
+	ResourceType  string       `json:"resource_type,omitempty"`
+	ResourceID    string       `json:"resource_id,omitempty"`
+	PatientID     string       `json:"patient_id,omitempty"`
+	PurposeOfUse  string       `json:"purpose_of_use"` // HL7 PurposeOfUse code, e.g. "TREAT", or "UNSPECIFIED".
+	SourceIP      string       `json:"source_ip,omitempty"`
+	UserAgent     string       `json:"user_agent,omitempty"`
+	RequestID     string       `json:"request_id,omitempty"`
+	TraceID       string       `json:"trace_id,omitempty"`
+	SecurityEvent string       `json:"security_event,omitempty"` // Set on failed and refused sign-in attempts, lockouts and unlocks, e.g. "locked-out".
+	EventID       string       `json:"event_id"`
+}
+
+// AuditAction is the kind of access an audit event records.
+type AuditAction string
+
+const (
+	AuditActionRead   AuditAction = "read"
+	AuditActionCreate AuditAction = "create"
+	AuditActionUpdate AuditAction = "update"
+	AuditActionDelete AuditAction = "delete"
+)
+
+// AuditOutcome is whether the audited request succeeded.
+type AuditOutcome string
+
+const (
+	AuditOutcomeSuccess AuditOutcome = "success"
+	AuditOutcomeFailure AuditOutcome = "failure"
+)
+
+// AuditEventsService reads the audit trail under /audit-events. It needs the
+// compliance-officer or privacy-officer role.
+type AuditEventsService service
+
+// AuditEventListOptions filter AuditEventsService.List.
+type AuditEventListOptions struct {
+	ListOptions
+	ActorUID     string
+	PatientID    string    // Only events concerning this patient's records.
+	ResourceType string    // e.g. "patients", "encounters" or "Observation".
+	From         time.Time // Only events at or after From.
+	To           time.Time // Only events at or before To.
+}
+
+// List pages through audit events, newest first; opts may be nil.
+func (s *AuditEventsService) List(opts *AuditEventListOptions) *Pager[AuditEvent] {
+	o := deref(opts)
+	q := query{}.setStr("actorUid", o.ActorUID).setStr("patientId", o.PatientID).setStr("resourceType", o.ResourceType).setTime("from", o.From).setTime("to", o.To)
+	return newPager[AuditEvent](s.client, "audit-events", url.Values(q), o.ListOptions)
+}
+
+// Get returns an audit event by ID.
+func (s *AuditEventsService) Get(ctx context.Context, eventID string) (*AuditEvent, error) {
+	return call[AuditEvent](ctx, s.client, http.MethodGet, path("audit-events", eventID), nil, nil)
+}
+
+// Audit export statuses.
+const (
+	AuditExportAccepted   = "accepted"
+	AuditExportInProgress = "in-progress"
+	AuditExportCompleted  = "completed"
+	AuditExportError      = "error"
+)
+
+// AuditExportCreate is the body of AuditEventsService.Export: the filters
+// of AuditEventListOptions, all optional.
+type AuditExportCreate struct {
+	ActorUID     string     `json:"actor_uid,omitempty"`
+	PatientID    string     `json:"patient_id,omitempty"`
+	ResourceType string     `json:"resource_type,omitempty"`
+	From         *time.Time `json:"occurred_from,omitempty"`
+	To           *time.Time `json:"occurred_to,omitempty"`
+}
+
+// AuditExport is an export of audit events to a CSV file and how far it has got.
+type AuditExport struct {
+	AuditExportCreate
+	ExportID    string     `json:"export_id"`
+	Status      string     `json:"status"`
+	RequestedBy string     `json:"requested_by"`
+	BlobName    string     `json:"blob_name,omitempty"`
+	RowCount    int        `json:"row_count"`
+	Error       string     `json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"` // Set once completed; signed and short-lived, so fetch the export again for a fresh one.
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Done reports whether the export has stopped, successfully or not.
+func (e *AuditExport) Done() bool {
+	return e.Status != AuditExportAccepted && e.Status != AuditExportInProgress
+}
+
+// Export starts writing the matching audit events to a CSV file in the
+// background; export may be nil for the whole trail. It needs a recent
+// second-factor verification. Poll the export with GetExport until it is
+// Done, then download the file from its DownloadURL.
+func (s *AuditEventsService) Export(ctx context.Context, export *AuditExportCreate) (*AuditExport, error) {
+	if export == nil {
+		export = &AuditExportCreate{}
+	}
+	return call[AuditExport](ctx, s.client, http.MethodPost, path("audit-events", "exports"), nil, export)
+}
+
+// GetExport returns an audit export by ID.
+func (s *AuditEventsService) GetExport(ctx context.Context, exportID string) (*AuditExport, error) {
+	return call[AuditExport](ctx, s.client, http.MethodGet, path("audit-events", "exports", exportID), nil, nil)
+}
@@ -0,0 +1,250 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CarePlan is a patient's plan of care, with its goals and activities.
+type CarePlan struct {
+	PatientID     string                 `json:"patient_id"`
+	Title         string                 `json:"title"`
+	Description   string                 `json:"description,omitempty"`
+	Category      string                 `json:"category,omitempty"` // The program the plan belongs to, e.g. "sleep-apnea" or "copd".
+	CareTeamID    string                 `json:"care_team_id,omitempty"`
+	PeriodStart   Date                   `json:"period_start"`
+	PeriodEnd     *Date                  `json:"period_end,omitempty"`
+	CarePlanID    string                 `json:"care_plan_id"`
+	Status        CarePlanStatus         `json:"status,omitempty"`
+	Goals         []CarePlanGoal         `json:"goals,omitempty"`
+	Activities    []CarePlanActivity     `json:"activities,omitempty"`
+	StatusHistory []CarePlanStatusChange `json:"status_history,omitempty"`
+	DeletedAt     *time.Time             `json:"deleted_at,omitempty"` // Set while the record is deleted; see Restore.
+	DeletedBy     string                 `json:"deleted_by,omitempty"`
+	Version       int                    `json:"version"`
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+}
+
+// CarePlanCreate is the body of CarePlansService.Create.
+type CarePlanCreate struct {
+	PatientID   string                   `json:"patient_id"`
+	Title       string                   `json:"title"`
+	Description string                   `json:"description,omitempty"`
+	Category    string                   `json:"category,omitempty"` // The program the plan belongs to, e.g. "sleep-apnea" or "copd".
+	CareTeamID  string                   `json:"care_team_id,omitempty"`
+	PeriodStart Date                     `json:"period_start"`
+	PeriodEnd   *Date                    `json:"period_end,omitempty"`
+	Goals       []CarePlanGoalCreate     `json:"goals,omitempty"`
+	Activities  []CarePlanActivityCreate `json:"activities,omitempty"`
+}
+
+// CarePlanUpdate changes a plan's details. Status is changed with
+// CarePlansService.SetStatus.
+type CarePlanUpdate struct {
+	Title       *string `json:"title,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Category    *string `json:"category,omitempty"`
+	CareTeamID  *string `json:"care_team_id,omitempty"`
+	PeriodStart *Date   `json:"period_start,omitempty"`
+	PeriodEnd   *Date   `json:"period_end,omitempty"`
+}
+
+// CarePlanStatusUpdate is the body of CarePlansService.SetStatus.
+type CarePlanStatusUpdate struct {
+	Status CarePlanStatus `json:"status"`
+	Reason string         `json:"reason,omitempty"`
+}
+
+// CarePlanStatusChange is one entry of a plan's status history.
+type CarePlanStatusChange struct {
+	Status    CarePlanStatus `json:"status"`
+	ChangedAt time.Time      `json:"changed_at"`
+	ChangedBy string         `json:"changed_by"`
+	Reason    string         `json:"reason,omitempty"`
+}
+
+// CarePlanGoal is a goal of a care plan.
+type CarePlanGoal struct {
+	Description string     `json:"description"`
+	Measure     *Coding    `json:"measure,omitempty"` // What is measured to track the goal, e.g. the LOINC code for AHI.
+	TargetValue *float64   `json:"target_value,omitempty"`
+	TargetUnit  string     `json:"target_unit,omitempty"`
+	DueDate     *Date      `json:"due_date,omitempty"`
+	Status      GoalStatus `json:"status,omitempty"`
+	GoalID      string     `json:"goal_id"`
+}
+
+// CarePlanGoalCreate is the body of CarePlansService.AddGoal.
+type CarePlanGoalCreate struct {
+	Description string     `json:"description"`
+	Measure     *Coding    `json:"measure,omitempty"` // What is measured to track the goal, e.g. the LOINC code for AHI.
+	TargetValue *float64   `json:"target_value,omitempty"`
+	TargetUnit  string     `json:"target_unit,omitempty"`
+	DueDate     *Date      `json:"due_date,omitempty"`
+	Status      GoalStatus `json:"status,omitempty"`
+}
+
+// CarePlanGoalUpdate is the body of CarePlansService.UpdateGoal.
+type CarePlanGoalUpdate struct {
+	Description *string     `json:"description,omitempty"`
+	Measure     *Coding     `json:"measure,omitempty"`
+	TargetValue *float64    `json:"target_value,omitempty"`
+	TargetUnit  *string     `json:"target_unit,omitempty"`
+	DueDate     *Date       `json:"due_date,omitempty"`
+	Status      *GoalStatus `json:"status,omitempty"`
+}
+
+// CarePlanActivity is a planned activity of a care plan.
+type CarePlanActivity struct {
+	Description    string            `json:"description"`
+	Kind           string            `json:"kind,omitempty"` // e.g. "appointment", "education", "measurement", "device-check".
+	Schedule       *ActivitySchedule `json:"schedule,omitempty"`
+	PractitionerID string            `json:"practitioner_id,omitempty"` // Practitioner responsible for the activity.
+	Status         ActivityStatus    `json:"status,omitempty"`
+	ActivityID     string            `json:"activity_id"`
+}
+
+// CarePlanActivityCreate is the body of CarePlansService.AddActivity.
+type CarePlanActivityCreate struct {
+	Description    string            `json:"description"`
+	Kind           string            `json:"kind,omitempty"` // e.g. "appointment", "education", "measurement", "device-check".
+	Schedule       *ActivitySchedule `json:"schedule,omitempty"`
+	PractitionerID string            `json:"practitioner_id,omitempty"` // Practitioner responsible for the activity.
+	Status         ActivityStatus    `json:"status,omitempty"`
+}
+
+// CarePlanActivityUpdate is the body of CarePlansService.UpdateActivity.
+type CarePlanActivityUpdate struct {
+	Description    *string           `json:"description,omitempty"`
+	Kind           *string           `json:"kind,omitempty"`
+	Schedule       *ActivitySchedule `json:"schedule,omitempty"`
+	PractitionerID *string           `json:"practitioner_id,omitempty"`
+	Status         *ActivityStatus   `json:"status,omitempty"`
+}
+
+// ActivitySchedule is a repeating schedule, e.g. 3 times per week from
+// StartDate until EndDate.
+type ActivitySchedule struct {
+	Frequency int    `json:"frequency"`
+	Period    string `json:"period"`
+	StartDate Date   `json:"start_date"`
+	EndDate   *Date  `json:"end_date,omitempty"`
+}
+
+// CarePlanStatus is the status of a care plan.
+type CarePlanStatus string
+
+const (
+	CarePlanStatusDraft     CarePlanStatus = "draft"
+	CarePlanStatusActive    CarePlanStatus = "active"
+	CarePlanStatusOnHold    CarePlanStatus = "on-hold"
+	CarePlanStatusCompleted CarePlanStatus = "completed"
+	CarePlanStatusRevoked   CarePlanStatus = "revoked"
+)
+
+// GoalStatus is the status of a care plan goal.
+type GoalStatus string
+
+const (
+	GoalStatusProposed    GoalStatus = "proposed"
+	GoalStatusInProgress  GoalStatus = "in-progress"
+	GoalStatusAchieved    GoalStatus = "achieved"
+	GoalStatusNotAchieved GoalStatus = "not-achieved"
+	GoalStatusCancelled   GoalStatus = "cancelled"
+)
+
+// ActivityStatus is the status of a care plan activity.
+type ActivityStatus string
+
+const (
+	ActivityStatusNotStarted ActivityStatus = "not-started"
+	ActivityStatusScheduled  ActivityStatus = "scheduled"
+	ActivityStatusInProgress ActivityStatus = "in-progress"
+	ActivityStatusOnHold     ActivityStatus = "on-hold"
+	ActivityStatusCompleted  ActivityStatus = "completed"
+	ActivityStatusCancelled  ActivityStatus = "cancelled"
+)
+
+// CarePlansService manages care plans under /care-plans.
+type CarePlansService service
+
+// CarePlanListOptions filter CarePlansService.List.
+type CarePlanListOptions struct {
+	ListOptions
+	PatientID      string
+	Status         CarePlanStatus
+	IncludeDeleted bool // Also list deleted records; administrators only.
+}
+
+// Create creates a care plan, with any initial goals and activities.
+func (s *CarePlansService) Create(ctx context.Context, plan *CarePlanCreate) (*CarePlan, error) {
+	return call[CarePlan](ctx, s.client, http.MethodPost, "care-plans", nil, plan)
+}
+
+// List pages through care plans; opts may be nil.
+func (s *CarePlansService) List(opts *CarePlanListOptions) *Pager[CarePlan] {
+	o := deref(opts)
+	q := query{}.setStr("patientId", o.PatientID).setStr("status", string(o.Status)).setBool("includeDeleted", o.IncludeDeleted)
+	return newPager[CarePlan](s.client, "care-plans", url.Values(q), o.ListOptions)
+}
+
+// Get returns a care plan by ID.
+func (s *CarePlansService) Get(ctx context.Context, carePlanID string) (*CarePlan, error) {
+	return call[CarePlan](ctx, s.client, http.MethodGet, path("care-plans", carePlanID), nil, nil)
+}
+
+// Update changes the details of a care plan.
+func (s *CarePlansService) Update(ctx context.Context, carePlanID string, update *CarePlanUpdate) (*CarePlan, error) {
+	return call[CarePlan](ctx, s.client, http.MethodPatch, path("care-plans", carePlanID), nil, update)
+}
+
+// SetStatus moves a care plan to a new status; the API rejects transitions
+// its workflow does not allow with a 409.
+func (s *CarePlansService) SetStatus(ctx context.Context, carePlanID string, update *CarePlanStatusUpdate) (*CarePlan, error) {
+	return call[CarePlan](ctx, s.client, http.MethodPost, path("care-plans", carePlanID, "status"), nil, update)
+}
+
+// Delete deletes a draft care plan; it can be restored.
+func (s *CarePlansService) Delete(ctx context.Context, carePlanID string) error {
+	return s.client.do(ctx, http.MethodDelete, path("care-plans", carePlanID), nil, nil, nil)
+}
+
+// Restore undoes the deletion of a care plan. Administrators only.
+func (s *CarePlansService) Restore(ctx context.Context, carePlanID string) (*CarePlan, error) {
+	return call[CarePlan](ctx, s.client, http.MethodPost, path("care-plans", carePlanID, "restore"), nil, nil)
+}
+
+// AddGoal adds a goal to a care plan and returns the updated plan.
+func (s *CarePlansService) AddGoal(ctx context.Context, carePlanID string, goal *CarePlanGoalCreate) (*CarePlan, error) {
+	return call[CarePlan](ctx, s.client, http.MethodPost, path("care-plans", carePlanID, "goals"), nil, goal)
+}
+
+// UpdateGoal changes a goal of a care plan and returns the updated plan.
+func (s *CarePlansService) UpdateGoal(ctx context.Context, carePlanID, goalID string, update *CarePlanGoalUpdate) (*CarePlan, error) {
+	return call[CarePlan](ctx, s.client, http.MethodPatch, path("care-plans", carePlanID, "goals", goalID), nil, update)
+}
+
+// RemoveGoal removes a goal from a care plan and returns the updated plan.
+func (s *CarePlansService) RemoveGoal(ctx context.Context, carePlanID, goalID string) (*CarePlan, error) {
+	return call[CarePlan](ctx, s.client, http.MethodDelete, path("care-plans", carePlanID, "goals", goalID), nil, nil)
+}
+
+// AddActivity adds an activity to a care plan and returns the updated plan.
+func (s *CarePlansService) AddActivity(ctx context.Context, carePlanID string, activity *CarePlanActivityCreate) (*CarePlan, error) {
+	return call[CarePlan](ctx, s.client, http.MethodPost, path("care-plans", carePlanID, "activities"), nil, activity)
+}
+
+// UpdateActivity changes an activity of a care plan and returns the updated
+// plan.
+func (s *CarePlansService) UpdateActivity(ctx context.Context, carePlanID, activityID string, update *CarePlanActivityUpdate) (*CarePlan, error) {
+	return call[CarePlan](ctx, s.client, http.MethodPatch, path("care-plans", carePlanID, "activities", activityID), nil, update)
+}
+
+// RemoveActivity removes an activity from a care plan and returns the
+// updated plan.
+func (s *CarePlansService) RemoveActivity(ctx context.Context, carePlanID, activityID string) (*CarePlan, error) {
+	return call[CarePlan](ctx, s.client, http.MethodDelete, path("care-plans", carePlanID, "activities", activityID), nil, nil)
+}
@@ -0,0 +1,95 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CareTeam is the practitioners looking after a patient.
+type CareTeam struct {
+	PatientID  string           `json:"patient_id"`
+	Name       string           `json:"name"`
+	Status     string           `json:"status,omitempty"`
+	Members    []CareTeamMember `json:"members,omitempty"`
+	CareTeamID string           `json:"care_team_id"`
+	DeletedAt  *time.Time       `json:"deleted_at,omitempty"` // Set while the record is deleted; see Restore.
+	DeletedBy  string           `json:"deleted_by,omitempty"`
+	Version    int              `json:"version"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
+// CareTeamCreate is the body of CareTeamsService.Create.
+type CareTeamCreate struct {
+	PatientID string           `json:"patient_id"`
+	Name      string           `json:"name"`
+	Status    string           `json:"status,omitempty"`
+	Members   []CareTeamMember `json:"members,omitempty"`
+}
+
+// CareTeamUpdate is the body of CareTeamsService.Update.
+type CareTeamUpdate struct {
+	Name   *string `json:"name,omitempty"`
+	Status *string `json:"status,omitempty"`
+}
+
+// CareTeamMember is a practitioner on a care team and their role.
+type CareTeamMember struct {
+	PractitionerID string `json:"practitioner_id"`
+	Role           string `json:"role"` // The member's role on the team, e.g. "primary-physician" or "respiratory-therapist".
+}
+
+// CareTeamsService manages care teams under /care-teams.
+type CareTeamsService service
+
+// CareTeamListOptions filter CareTeamsService.List.
+type CareTeamListOptions struct {
+	ListOptions
+	PatientID      string
+	PractitionerID string // Only teams this practitioner is a member of.
+	IncludeDeleted bool   // Also list deleted records; administrators only.
+}
+
+// Create creates a care team for a patient.
+func (s *CareTeamsService) Create(ctx context.Context, team *CareTeamCreate) (*CareTeam, error) {
+	return call[CareTeam](ctx, s.client, http.MethodPost, "care-teams", nil, team)
+}
+
+// List pages through care teams; opts may be nil.
+func (s *CareTeamsService) List(opts *CareTeamListOptions) *Pager[CareTeam] {
+	o := deref(opts)
+	q := query{}.setStr("patientId", o.PatientID).setStr("practitionerId", o.PractitionerID).setBool("includeDeleted", o.IncludeDeleted)
+	return newPager[CareTeam](s.client, "care-teams", url.Values(q), o.ListOptions)
+}
+
+// Get returns a care team by ID.
+func (s *CareTeamsService) Get(ctx context.Context, careTeamID string) (*CareTeam, error) {
+	return call[CareTeam](ctx, s.client, http.MethodGet, path("care-teams", careTeamID), nil, nil)
+}
+
+// Update changes the name or status of a care team.
+func (s *CareTeamsService) Update(ctx context.Context, careTeamID string, update *CareTeamUpdate) (*CareTeam, error) {
+	return call[CareTeam](ctx, s.client, http.MethodPatch, path("care-teams", careTeamID), nil, update)
+}
+
+// AddMember adds a practitioner to a care team.
+func (s *CareTeamsService) AddMember(ctx context.Context, careTeamID string, member *CareTeamMember) (*CareTeam, error) {
+	return call[CareTeam](ctx, s.client, http.MethodPost, path("care-teams", careTeamID, "members"), nil, member)
+}
+
+// RemoveMember removes a practitioner from a care team.
+func (s *CareTeamsService) RemoveMember(ctx context.Context, careTeamID, practitionerID string) (*CareTeam, error) {
+	return call[CareTeam](ctx, s.client, http.MethodDelete, path("care-teams", careTeamID, "members", practitionerID), nil, nil)
+}
+
+// Delete deletes a care team; it can be restored.
+func (s *CareTeamsService) Delete(ctx context.Context, careTeamID string) error {
+	return s.client.do(ctx, http.MethodDelete, path("care-teams", careTeamID), nil, nil, nil)
+}
+
+// Restore undoes the deletion of a care team. Administrators only.
+func (s *CareTeamsService) Restore(ctx context.Context, careTeamID string) (*CareTeam, error) {
+	return call[CareTeam](ctx, s.client, http.MethodPost, path("care-teams", careTeamID, "restore"), nil, nil)
+}
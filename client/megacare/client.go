@@ -0,0 +1,398 @@
+package megacare
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxRetries  = 3
+	defaultMinBackoff  = 250 * time.Millisecond
+	defaultMaxBackoff  = 10 * time.Second
+	defaultUserAgent   = "megacare-go"
+	idempotencyHeader  = "Idempotency-Key"
+	ifMatchHeader      = "If-Match"
+	organizationHeader = "X-Organization-Id"
+	apiPath            = "/api/v1"
+	maxRetryAfterDelay = time.Minute
+)
+
+// Client talks to one MegaCare deployment. Use NewClient to create one.
+type Client struct {
+	baseURL    *url.URL
+	creds      Credentials
+	httpClient *http.Client
+	userAgent  string
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	common service
+
+	Patients         *PatientsService
+	Practitioners    *PractitionersService
+	CareTeams        *CareTeamsService
+	CarePlans        *CarePlansService
+	Appointments     *AppointmentsService
+	Encounters       *EncountersService
+	Observations     *ObservationsService
+	LabResults       *LabResultsService
+	Medications      *MedicationsService
+	Allergies        *AllergiesService
+	Immunizations    *ImmunizationsService
+	Documents        *DocumentsService
+	WoundImages      *WoundImagesService
+	Consents         *ConsentsService
+	ConsentDocuments *ConsentDocumentsService
+	Devices          *DevicesService
+	Notifications    *NotificationsService
+	Push             *PushService
+	Telehealth       *TelehealthService
+	Reports          *ReportsService
+	Terminology      *TerminologyService
+	TextSearch       *TextSearchService
+	Imports          *ImportsService
+	AuditEvents      *AuditEventsService
+	Webhooks         *WebhooksService
+	APIKeys          *APIKeysService
+	Organizations    *OrganizationsService
+	FeatureFlags     *FeatureFlagsService
+}
+
+type service struct {
+	client *Client
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client requests are sent with, e.g. one with
+// a timeout or a custom transport. The default is http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithMaxRetries sets how many times a failed request is retried; 0
+// disables retries. The default is 3.
+func WithMaxRetries(n int) Option {
+	return func(c *Client) { c.maxRetries = max(n, 0) }
+}
+
+// WithBackoff sets the delay before the first retry and the most any retry
+// waits, unless a Retry-After header asks for longer.
+func WithBackoff(initial, limit time.Duration) Option {
+	return func(c *Client) { c.minBackoff, c.maxBackoff = initial, limit }
+}
+
+// WithUserAgent prefixes the User-Agent header, e.g. with the name and
+// version of the calling service.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent + " " + defaultUserAgent }
+}
+
+// NewClient returns a client for the deployment at baseURL, e.g.
+// "https://api.megacare.example"; the /api/v1 prefix is added, so it may be
+// left out.
+func NewClient(baseURL string, creds Credentials, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("megacare: invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("megacare: base URL %q must be an absolute http or https URL", baseURL)
+	}
+	if creds == nil {
+		return nil, errors.New("megacare: credentials are required")
+	}
+	if !strings.HasSuffix(u.Path, apiPath) {
+		u.Path += apiPath
+	}
+	c := &Client{
+		baseURL:    u,
+		creds:      creds,
+		httpClient: http.DefaultClient,
+		userAgent:  defaultUserAgent,
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.common.client = c
+	c.Patients = (*PatientsService)(&c.common)
+	c.Practitioners = (*PractitionersService)(&c.common)
+	c.CareTeams = (*CareTeamsService)(&c.common)
+	c.CarePlans = (*CarePlansService)(&c.common)
+	c.Appointments = (*AppointmentsService)(&c.common)
+	c.Encounters = (*EncountersService)(&c.common)
+	c.Observations = (*ObservationsService)(&c.common)
+	c.LabResults = (*LabResultsService)(&c.common)
+	c.Medications = (*MedicationsService)(&c.common)
+	c.Allergies = (*AllergiesService)(&c.common)
+	c.Immunizations = (*ImmunizationsService)(&c.common)
+	c.Documents = (*DocumentsService)(&c.common)
+	c.WoundImages = (*WoundImagesService)(&c.common)
+	c.Consents = (*ConsentsService)(&c.common)
+	c.ConsentDocuments = (*ConsentDocumentsService)(&c.common)
+	c.Devices = (*DevicesService)(&c.common)
+	c.Notifications = (*NotificationsService)(&c.common)
+	c.Push = (*PushService)(&c.common)
+	c.Telehealth = (*TelehealthService)(&c.common)
+	c.Reports = (*ReportsService)(&c.common)
+	c.Terminology = (*TerminologyService)(&c.common)
+	c.TextSearch = (*TextSearchService)(&c.common)
+	c.Imports = (*ImportsService)(&c.common)
+	c.AuditEvents = (*AuditEventsService)(&c.common)
+	c.Webhooks = (*WebhooksService)(&c.common)
+	c.APIKeys = (*APIKeysService)(&c.common)
+	c.Organizations = (*OrganizationsService)(&c.common)
+	c.FeatureFlags = (*FeatureFlagsService)(&c.common)
+	return c, nil
+}
+
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey returns a context whose POST requests send key as their
+// Idempotency-Key instead of a generated one. Use a new key for every
+// distinct operation.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+func idempotencyKey(ctx context.Context) string {
+	if key, ok := ctx.Value(idempotencyKeyContextKey{}).(string); ok && key != "" {
+		return key
+	}
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type versionContextKey struct{}
+
+// WithVersion returns a context whose PUT, PATCH and DELETE requests send
+// If-Match with version, the Version of the resource the change is based
+// on. Updates of a resource that has since changed fail with a 409 Error
+// (see IsVersionConflict) instead of overwriting the other change.
+func WithVersion(ctx context.Context, version int) context.Context {
+	return context.WithValue(ctx, versionContextKey{}, version)
+}
+
+func ifMatch(ctx context.Context, method string) string {
+	version, ok := ctx.Value(versionContextKey{}).(int)
+	if !ok || (method != http.MethodPut && method != http.MethodPatch && method != http.MethodDelete) {
+		return ""
+	}
+	return fmt.Sprintf(`W/"%d"`, version)
+}
+
+type organizationContextKey struct{}
+
+// WithOrganization returns a context whose requests act for the
+// organization organizationID. Only platform administrators may choose an
+// organization; other callers act for their own, and naming another one
+// fails with a 403 Error of type ProblemTypeCrossTenant.
+func WithOrganization(ctx context.Context, organizationID string) context.Context {
+	return context.WithValue(ctx, organizationContextKey{}, organizationID)
+}
+
+// path joins escaped path segments, e.g. path("patients", id, "allergies").
+func path(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, s := range segments {
+		escaped[i] = url.PathEscape(s)
+	}
+	return strings.Join(escaped, "/")
+}
+
+// call sends a request and returns its decoded JSON response.
+func call[T any](ctx context.Context, c *Client, method, p string, query url.Values, body any) (*T, error) {
+	out := new(T)
+	if err := c.do(ctx, method, p, query, body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// list sends a request to an endpoint that returns a plain JSON array.
+func list[T any](ctx context.Context, c *Client, p string, query url.Values) ([]T, error) {
+	var out []T
+	if err := c.do(ctx, http.MethodGet, p, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func deref[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
+
+// rawBody is a request body sent as it is rather than encoded as JSON.
+type rawBody struct {
+	contentType string
+	data        []byte
+}
+
+// do sends a request to the API path p (relative to /api/v1), retrying
+// transient failures, and decodes the JSON response into out unless it is
+// nil. A rawBody is sent as it is, and a *[]byte out receives the response
+// body undecoded.
+func (c *Client) do(ctx context.Context, method, p string, query url.Values, body, out any) error {
+	target := c.baseURL.String() + "/" + p
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var payload []byte
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case rawBody:
+		payload, contentType = b.data, b.contentType
+	default:
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("megacare: encoding request body: %w", err)
+		}
+	}
+	key := ""
+	if method == http.MethodPost {
+		key = idempotencyKey(ctx)
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := c.newRequest(ctx, method, target, payload, contentType, key)
+		if err != nil {
+			return err
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil || attempt >= c.maxRetries {
+				return err
+			}
+			if waitErr := c.wait(ctx, attempt, nil); waitErr != nil {
+				return waitErr
+			}
+			continue
+		}
+		if resp.StatusCode < 300 {
+			defer resp.Body.Close()
+			if out == nil || resp.StatusCode == http.StatusNoContent {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				return nil
+			}
+			if raw, ok := out.(*[]byte); ok {
+				if *raw, err = io.ReadAll(resp.Body); err != nil {
+					return fmt.Errorf("megacare: reading %s /%s response: %w", method, p, err)
+				}
+				return nil
+			}
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return fmt.Errorf("megacare: decoding %s /%s response: %w", method, p, err)
+			}
+			return nil
+		}
+		if !retryable(resp.StatusCode) || attempt >= c.maxRetries {
+			defer resp.Body.Close()
+			return errorFromResponse(resp)
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		if err := c.wait(ctx, attempt, resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) newRequest(ctx context.Context, method, target string, payload []byte, contentType, key string) (*http.Request, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("megacare: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if payload != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if key != "" {
+		req.Header.Set(idempotencyHeader, key)
+	}
+	if tag := ifMatch(ctx, method); tag != "" {
+		req.Header.Set(ifMatchHeader, tag)
+	}
+	if organizationID, ok := ctx.Value(organizationContextKey{}).(string); ok && organizationID != "" {
+		req.Header.Set(organizationHeader, organizationID)
+	}
+	if err := c.creds.Authorize(ctx, req); err != nil {
+		return nil, fmt.Errorf("megacare: authorizing request: %w", err)
+	}
+	return req, nil
+}
+
+// retryable reports whether a response status means the request may succeed
+// if sent again: rate limited, or a gateway or overloaded server.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// wait sleeps before retry number attempt+1: exponential backoff with full
+// jitter, or longer if the response's Retry-After asks for it.
+func (c *Client) wait(ctx context.Context, attempt int, resp *http.Response) error {
+	delay := c.minBackoff << attempt
+	if delay <= 0 || delay > c.maxBackoff {
+		delay = c.maxBackoff
+	}
+	delay = time.Duration(mathrand.Int64N(int64(delay) + 1))
+	if resp != nil {
+		if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok && after > delay {
+			delay = min(after, maxRetryAfterDelay)
+		}
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,534 @@
+package megacare
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// --- Test Setup ---
+
+type recorded struct {
+	method, path, query, idempotencyKey, ifMatch, apiKey, authorization, organization string
+	body                                                                              map[string]any
+}
+
+// server answers requests with the handler's responses and records them.
+func server(t *testing.T, handler func(n int, w http.ResponseWriter, r *http.Request)) (*Client, *[]recorded) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []recorded
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		rec := recorded{
+			method: r.Method, path: r.URL.EscapedPath(), query: r.URL.RawQuery,
+			idempotencyKey: r.Header.Get("Idempotency-Key"), ifMatch: r.Header.Get("If-Match"), apiKey: r.Header.Get("X-Api-Key"), authorization: r.Header.Get("Authorization"),
+			organization: r.Header.Get("X-Organization-Id"),
+		}
+		_ = json.NewDecoder(r.Body).Decode(&rec.body)
+		requests = append(requests, rec)
+		n := len(requests)
+		mu.Unlock()
+		handler(n, w, r)
+	}))
+	t.Cleanup(ts.Close)
+	client, err := NewClient(ts.URL, APIKeyAuth("mk_test"), WithBackoff(time.Millisecond, 5*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, &requests
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+var patientJSON = map[string]any{
+	"patient_id": "p-1", "given_name": "Ann", "family_name": "Lee", "dob": "1980-01-31", "mrn": "MRN-1",
+	"version": 3, "created_at": "2026-01-02T03:04:05Z", "updated_at": "2026-01-02T03:04:05Z",
+}
+
+// --- Request Test Cases ---
+
+func TestRequestsUseTheAPIPathAndCredentials(t *testing.T) {
+	client, requests := server(t, func(_ int, w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, patientJSON)
+	})
+
+	patient, err := client.Patients.Get(context.Background(), "p/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := (*requests)[0]
+	if got.method != http.MethodGet || got.path != "/api/v1/patients/p%2F1" {
+		t.Errorf("request = %s %s, want GET /api/v1/patients/p%%2F1", got.method, got.path)
+	}
+	if got.apiKey != "mk_test" {
+		t.Errorf("X-Api-Key = %q, want mk_test", got.apiKey)
+	}
+	if got.idempotencyKey != "" {
+		t.Errorf("GET sent Idempotency-Key %q", got.idempotencyKey)
+	}
+	if patient.MRN != "MRN-1" || patient.DOB != (Date{1980, time.January, 31}) {
+		t.Errorf("patient = %+v", patient)
+	}
+}
+
+func TestBodiesAndFiltersUseTheAPINames(t *testing.T) {
+	client, requests := server(t, func(_ int, w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusCreated, patientJSON)
+	})
+	ctx := context.Background()
+
+	_, err := client.Patients.Create(ctx, &PatientCreate{GivenName: "Ann", FamilyName: "Lee", DOB: Date{1980, time.January, 31}, MRN: "MRN-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	_, _ = client.Appointments.List(&AppointmentListOptions{PatientID: "p-1", From: from, ListOptions: ListOptions{Limit: 5}}).Next(ctx)
+
+	body := (*requests)[0].body
+	if body["given_name"] != "Ann" || body["dob"] != "1980-01-31" {
+		t.Errorf("body = %v", body)
+	}
+	if _, sent := body["nhs_number"]; sent {
+		t.Errorf("unset optional field was sent: %v", body)
+	}
+	if got, want := (*requests)[1].query, "from=2026-03-01T09%3A00%3A00Z&limit=5&patientId=p-1"; got != want {
+		t.Errorf("query = %q, want %q", got, want)
+	}
+}
+
+func TestBearerTokensAreFetchedPerRequest(t *testing.T) {
+	client, requests := server(t, func(_ int, w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, patientJSON)
+	})
+	calls := 0
+	client.creds = TokenSource(func(context.Context) (string, error) {
+		calls++
+		return fmt.Sprintf("token-%d", calls), nil
+	})
+
+	for range 2 {
+		if _, err := client.Patients.Get(context.Background(), "p-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if (*requests)[1].authorization != "Bearer token-2" {
+		t.Errorf("Authorization = %q, want Bearer token-2", (*requests)[1].authorization)
+	}
+}
+
+// --- Retry Test Cases ---
+
+func TestPostsAreRetriedWithTheSameIdempotencyKey(t *testing.T) {
+	client, requests := server(t, func(n int, w http.ResponseWriter, _ *http.Request) {
+		if n < 3 {
+			w.Header().Set("Retry-After", "0")
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"type": "about:blank", "title": "Service Unavailable", "status": 503})
+			return
+		}
+		writeJSON(w, http.StatusCreated, patientJSON)
+	})
+
+	if _, err := client.Patients.Create(context.Background(), &PatientCreate{MRN: "MRN-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*requests) != 3 {
+		t.Fatalf("sent %d requests, want 3", len(*requests))
+	}
+	key := (*requests)[0].idempotencyKey
+	if key == "" {
+		t.Fatal("POST sent no Idempotency-Key")
+	}
+	for _, r := range *requests {
+		if r.idempotencyKey != key {
+			t.Errorf("retry sent Idempotency-Key %q, want %q", r.idempotencyKey, key)
+		}
+	}
+}
+
+func TestIdempotencyKeyCanBeSupplied(t *testing.T) {
+	client, requests := server(t, func(_ int, w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusCreated, patientJSON)
+	})
+
+	ctx := WithIdempotencyKey(context.Background(), "import-42-row-7")
+	if _, err := client.Patients.Create(ctx, &PatientCreate{MRN: "MRN-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := (*requests)[0].idempotencyKey; got != "import-42-row-7" {
+		t.Errorf("Idempotency-Key = %q, want import-42-row-7", got)
+	}
+}
+
+func TestUpdatesSendTheVersionTheyAreBasedOn(t *testing.T) {
+	client, requests := server(t, func(n int, w http.ResponseWriter, _ *http.Request) {
+		if n == 3 {
+			writeJSON(w, http.StatusConflict, map[string]any{"type": ProblemTypeVersionConflict, "title": "Stale Version", "status": 409})
+			return
+		}
+		writeJSON(w, http.StatusOK, patientJSON)
+	})
+
+	patient, err := client.Patients.Get(context.Background(), "p-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithVersion(context.Background(), patient.Version)
+	if _, err := client.Patients.Update(ctx, "p-1", &PatientUpdate{}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Patients.Update(ctx, "p-1", &PatientUpdate{})
+
+	if got := (*requests)[1].ifMatch; got != `W/"3"` {
+		t.Errorf("If-Match = %q, want W/\"3\"", got)
+	}
+	if got := (*requests)[0].ifMatch; got != "" {
+		t.Errorf("GET sent If-Match %q", got)
+	}
+	if !IsVersionConflict(err) || !IsConflict(err) {
+		t.Errorf("err = %v, want a version conflict", err)
+	}
+}
+
+func TestSearchFiltersAreSentAsQueryParameters(t *testing.T) {
+	client, requests := server(t, func(_ int, w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"items": []any{}})
+	})
+
+	search := Search{Filters: []Filter{Ge("dob", Date{1980, time.January, 1}), Contains("name", "smith"), AnyOf("sex", "female", "other")}, Sort: "-createdAt"}
+	_, err := client.Patients.List(&PatientListOptions{Search: search}).Next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := (*requests)[0].query, "dob=ge%3A1980-01-01&name=contains%3Asmith&sex=eq%3Afemale%2Cother&sort=-createdAt"; got != want {
+		t.Errorf("query = %q, want %q", got, want)
+	}
+}
+
+func TestDeletedRecordsCanBeListedAndRestored(t *testing.T) {
+	client, requests := server(t, func(n int, w http.ResponseWriter, _ *http.Request) {
+		if n == 1 {
+			deleted := map[string]any{"deleted_at": "2026-01-03T00:00:00Z", "deleted_by": "admin-1"}
+			for name, value := range patientJSON {
+				deleted[name] = value
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"items": []any{deleted}})
+			return
+		}
+		writeJSON(w, http.StatusOK, patientJSON)
+	})
+
+	page, err := client.Patients.List(&PatientListOptions{IncludeDeleted: true}).Next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := client.Patients.Restore(context.Background(), "p-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := (*requests)[0].query; got != "includeDeleted=true" {
+		t.Errorf("list query = %q, want includeDeleted=true", got)
+	}
+	if deleted := page.Items[0]; deleted.DeletedAt == nil || deleted.DeletedBy != "admin-1" {
+		t.Errorf("deleted patient = %+v", deleted)
+	}
+	if got := (*requests)[1]; got.method != http.MethodPost || got.path != "/api/v1/patients/p-1/restore" {
+		t.Errorf("restore request = %s %s", got.method, got.path)
+	}
+	if restored.DeletedAt != nil {
+		t.Errorf("restored patient is still deleted: %v", restored.DeletedAt)
+	}
+}
+
+func TestRequestsActForTheChosenOrganization(t *testing.T) {
+	client, requests := server(t, func(n int, w http.ResponseWriter, _ *http.Request) {
+		if n == 2 {
+			writeJSON(w, http.StatusForbidden, map[string]any{"type": ProblemTypeCrossTenant, "title": "Forbidden", "status": 403})
+			return
+		}
+		writeJSON(w, http.StatusOK, patientJSON)
+	})
+
+	ctx := WithOrganization(context.Background(), "clinic-a")
+	if _, err := client.Patients.Get(ctx, "p-1"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.Organizations.Get(context.Background(), "clinic-b")
+
+	if got := (*requests)[0].organization; got != "clinic-a" {
+		t.Errorf("X-Organization-Id = %q, want clinic-a", got)
+	}
+	if got := (*requests)[1]; got.organization != "" || got.path != "/api/v1/organizations/clinic-b" {
+		t.Errorf("organization request = %s with X-Organization-Id %q", got.path, got.organization)
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Type != ProblemTypeCrossTenant {
+		t.Errorf("err = %v, want a cross-tenant problem", err)
+	}
+}
+
+func TestFeatureFlagsAreListedAndChanged(t *testing.T) {
+	client, requests := server(t, func(n int, w http.ResponseWriter, _ *http.Request) {
+		flag := map[string]any{"key": "telehealth-visits", "enabled": true, "percentage": 25, "source": "store", "version": 2}
+		if n == 1 {
+			writeJSON(w, http.StatusOK, []any{map[string]any{"key": "new-reports", "enabled": true, "percentage": 100, "source": "config"}, flag})
+			return
+		}
+		writeJSON(w, http.StatusOK, flag)
+	})
+
+	flags, err := client.FeatureFlags.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	percentage := 25
+	updated, err := client.FeatureFlags.Update(WithVersion(context.Background(), 1), "telehealth-visits", &FeatureFlagUpdate{Percentage: &percentage})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(flags) != 2 || flags[0].Source != "config" || flags[0].CreatedAt != nil {
+		t.Errorf("flags = %+v", flags)
+	}
+	if got := (*requests)[1]; got.method != http.MethodPatch || got.path != "/api/v1/admin/flags/telehealth-visits" || got.body["percentage"] != float64(25) {
+		t.Errorf("update request = %s %s %v", got.method, got.path, got.body)
+	}
+	if updated.Percentage != 25 || updated.Version != 2 {
+		t.Errorf("updated flag = %+v", updated)
+	}
+}
+
+func TestWoundPhotosAreSentAndReturnedAsTheyAre(t *testing.T) {
+	photo := []byte("\xff\xd8\xff\xe0 not really a JPEG")
+	var contentType, rawQuery string
+	var received []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = w.Write(photo)
+			return
+		}
+		contentType, rawQuery = r.Header.Get("Content-Type"), r.URL.RawQuery
+		received, _ = io.ReadAll(r.Body)
+		writeJSON(w, http.StatusCreated, map[string]any{"image_id": "img-1", "wound_id": "left-heel", "length_mm": 12.5})
+	}))
+	t.Cleanup(ts.Close)
+	client, err := NewClient(ts.URL, APIKeyAuth("mk_test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	length := 12.5
+	image, err := client.WoundImages.Upload(context.Background(), "p-1", &WoundImageUpload{WoundID: "left-heel", ContentType: "image/jpeg", LengthMm: &length}, photo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := client.WoundImages.Original(context.Background(), "p-1", "img-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if contentType != "image/jpeg" || string(received) != string(photo) || rawQuery != "lengthMm=12.5&woundId=left-heel" {
+		t.Errorf("upload = %q %q %q", contentType, rawQuery, received)
+	}
+	if image.ImageID != "img-1" || *image.LengthMm != 12.5 {
+		t.Errorf("image = %+v", image)
+	}
+	if string(original) != string(photo) {
+		t.Errorf("original = %q", original)
+	}
+}
+
+func TestClientErrorsAreNotRetried(t *testing.T) {
+	client, requests := server(t, func(_ int, w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"type": "about:blank", "title": "Internal Server Error", "status": 500})
+	})
+
+	_, err := client.Patients.Get(context.Background(), "p-1")
+
+	if err == nil || len(*requests) != 1 {
+		t.Errorf("err = %v after %d requests, want an error after 1", err, len(*requests))
+	}
+}
+
+func TestRetriesStopAfterMaxRetries(t *testing.T) {
+	client, requests := server(t, func(_ int, w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]any{"type": "about:blank", "title": "Too Many Requests", "status": 429})
+	})
+	client.maxRetries = 2
+
+	_, err := client.Patients.Get(context.Background(), "p-1")
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("err = %v, want a 429 *Error", err)
+	}
+	if len(*requests) != 3 {
+		t.Errorf("sent %d requests, want 3", len(*requests))
+	}
+}
+
+func TestRetryAfterIsParsed(t *testing.T) {
+	for value, want := range map[string]time.Duration{"0": 0, "7": 7 * time.Second} {
+		if got, ok := retryAfter(value); !ok || got != want {
+			t.Errorf("retryAfter(%q) = %v, %v; want %v", value, got, ok, want)
+		}
+	}
+	if _, ok := retryAfter("soon"); ok {
+		t.Error("retryAfter accepted an invalid value")
+	}
+}
+
+// --- Error Test Cases ---
+
+func TestProblemDetailsAreDecoded(t *testing.T) {
+	client, _ := server(t, func(_ int, w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"type":"urn:megacare:problem:validation-error","title":"Invalid Request","status":422,
+			"errors":[{"field":"contact.phoneNumber","message":"not a valid phone number","code":"phone_e164"}],"requestId":"req-9"}`))
+	})
+
+	_, err := client.Patients.Create(context.Background(), &PatientCreate{})
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *Error", err)
+	}
+	if apiErr.Type != ProblemTypeValidationError || apiErr.RequestID != "req-9" || apiErr.Errors[0].Code != "phone_e164" {
+		t.Errorf("error = %+v", apiErr)
+	}
+	if !strings.Contains(err.Error(), "contact.phoneNumber: not a valid phone number") {
+		t.Errorf("message %q does not name the invalid field", err.Error())
+	}
+}
+
+func TestNonProblemErrorsKeepTheirStatus(t *testing.T) {
+	client, _ := server(t, func(_ int, w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "upstream connect error", http.StatusNotFound)
+	})
+
+	_, err := client.Patients.Get(context.Background(), "p-1")
+
+	if !IsNotFound(err) || !strings.Contains(err.Error(), "upstream connect error") {
+		t.Errorf("err = %v, want a 404 with the body as detail", err)
+	}
+}
+
+// --- Pagination Test Cases ---
+
+func TestAllFollowsCursors(t *testing.T) {
+	client, requests := server(t, func(n int, w http.ResponseWriter, _ *http.Request) {
+		items := []map[string]any{{"patient_id": fmt.Sprintf("p-%d", n), "dob": "1980-01-01"}}
+		if n < 3 {
+			writeJSON(w, http.StatusOK, map[string]any{"items": items, "next_cursor": fmt.Sprintf("c%d", n)})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items, "total": 3})
+	})
+
+	var ids []string
+	for patient, err := range client.Patients.List(&PatientListOptions{ListOptions: ListOptions{Limit: 1}}).All(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, patient.PatientID)
+	}
+
+	if strings.Join(ids, ",") != "p-1,p-2,p-3" {
+		t.Errorf("ids = %v", ids)
+	}
+	if got := (*requests)[2].query; got != "cursor=c2&limit=1" {
+		t.Errorf("third page query = %q, want cursor=c2&limit=1", got)
+	}
+}
+
+func TestNextStopsAfterTheLastPage(t *testing.T) {
+	client, _ := server(t, func(_ int, w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"items": []any{}, "total": 0})
+	})
+	pager := client.Webhooks.List(nil)
+
+	page, err := pager.Next(context.Background())
+	if err != nil || page.Total == nil || *page.Total != 0 {
+		t.Fatalf("page = %+v, err = %v", page, err)
+	}
+	if _, err := pager.Next(context.Background()); !errors.Is(err, ErrNoMorePages) {
+		t.Errorf("err = %v, want ErrNoMorePages", err)
+	}
+}
+
+// --- Client Test Cases ---
+
+func TestNewClientChecksItsArguments(t *testing.T) {
+	for _, baseURL := range []string{"api.megacare.example", "ftp://api.megacare.example", "https://"} {
+		if _, err := NewClient(baseURL, APIKeyAuth("k")); err == nil {
+			t.Errorf("NewClient(%q) accepted an invalid base URL", baseURL)
+		}
+	}
+	if _, err := NewClient("https://api.megacare.example", nil); err == nil {
+		t.Error("NewClient accepted nil credentials")
+	}
+	client, err := NewClient("https://api.megacare.example/api/v1/", APIKeyAuth("k"))
+	if err != nil || client.baseURL.String() != "https://api.megacare.example/api/v1" {
+		t.Errorf("base URL = %v, err = %v", client.baseURL, err)
+	}
+}
+
+func TestTextSearchSendsKindsAndDecodesHighlights(t *testing.T) {
+	client, requests := server(t, func(_ int, w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"total": 1, "offset": 0, "hits": []any{map[string]any{
+			"kind": "encounter", "id": "enc-1", "patient_id": "p-1", "subject": "encounters/enc-1", "title": "CPAP mask leak",
+			"recorded_at": "2026-10-01T09:00:00Z", "score": 2.5, "highlights": map[string]any{"title": []string{"CPAP <mark>mask</mark> leak"}},
+		}}})
+	})
+
+	results, err := client.TextSearch.Search(context.Background(), "mask", &TextSearchOptions{Kinds: []SearchKind{SearchKindEncounter, SearchKindCarePlan}, Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := (*requests)[0].query, "kind=encounter&kind=care-plan&limit=5&q=mask"; got != want {
+		t.Errorf("query = %q, want %q", got, want)
+	}
+	if hit := results.Hits[0]; hit.PatientID != "p-1" || hit.Highlights["title"][0] != "CPAP <mark>mask</mark> leak" {
+		t.Errorf("hit = %+v", hit)
+	}
+}
+
+func TestImportErrorReportIsReadLineByLine(t *testing.T) {
+	client, requests := server(t, func(_ int, w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = io.WriteString(w, "{\"line\":1,\"error\":\"Line is not valid JSON\"}\n{\"line\":4,\"resource_type\":\"Patient\",\"error\":\"dob: Input should be a valid date\"}\n")
+	})
+
+	report, err := client.Imports.Errors(context.Background(), "job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := (*requests)[0].path; got != "/api/v1/import/job-1/errors" {
+		t.Errorf("path = %q", got)
+	}
+	if len(report) != 2 || report[1].Line != 4 || report[1].ResourceType != "Patient" {
+		t.Errorf("report = %+v", report)
+	}
+}
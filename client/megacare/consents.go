@@ -0,0 +1,149 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Consent is a patient's consent to one scope of data use.
+type Consent struct {
+	ConsentID        string        `json:"consent_id"`
+	PatientID        string        `json:"patient_id"`
+	Scope            ConsentScope  `json:"scope"`
+	OrganizationID   string        `json:"organization_id,omitempty"`
+	DocumentID       string        `json:"document_id"`
+	DocumentVersion  int           `json:"document_version"`
+	Status           ConsentStatus `json:"status"`
+	GrantedAt        time.Time     `json:"granted_at"`
+	GrantedBy        string        `json:"granted_by"`
+	ExpiresAt        *time.Time    `json:"expires_at,omitempty"`
+	RevokedAt        *time.Time    `json:"revoked_at,omitempty"`
+	RevokedBy        string        `json:"revoked_by,omitempty"`
+	RevocationReason string        `json:"revocation_reason,omitempty"`
+	Version          int           `json:"version"`
+	CreatedAt        time.Time     `json:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+}
+
+// ConsentGrant is the body of ConsentsService.Grant.
+type ConsentGrant struct {
+	Scope          ConsentScope `json:"scope"`
+	DocumentID     string       `json:"document_id"`               // The consent document the patient agreed to; must be the latest version for the scope.
+	OrganizationID string       `json:"organization_id,omitempty"` // Recipient organisation; required for "data-sharing".
+	ExpiresAt      *time.Time   `json:"expires_at,omitempty"`
+}
+
+// ConsentRevoke is the body of ConsentsService.Revoke.
+type ConsentRevoke struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// ConsentDecision is the answer of ConsentsService.Check.
+type ConsentDecision struct {
+	Allowed   bool   `json:"allowed"`
+	Reason    string `json:"reason"` // Why access is allowed or denied, e.g. "no-consent" or "reconsent-required".
+	ConsentID string `json:"consent_id,omitempty"`
+}
+
+// ConsentDocument is one version of the consent text for a scope.
+type ConsentDocument struct {
+	Scope          ConsentScope `json:"scope"`
+	Title          string       `json:"title"`
+	Body           string       `json:"body"`                      // The consent text shown to the patient.
+	Language       string       `json:"language,omitempty"`        // BCP 47 language tag, e.g. "th" or "en".
+	MaterialChange bool         `json:"material_change,omitempty"` // If true, consents given to earlier versions are no longer valid.
+	DocumentID     string       `json:"document_id"`
+	Version        int          `json:"version"` // Assigned sequentially per scope.
+	PublishedBy    string       `json:"published_by"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// ConsentDocumentCreate is the body of ConsentDocumentsService.Publish.
+type ConsentDocumentCreate struct {
+	Scope          ConsentScope `json:"scope"`
+	Title          string       `json:"title"`
+	Body           string       `json:"body"`                      // The consent text shown to the patient.
+	Language       string       `json:"language,omitempty"`        // BCP 47 language tag, e.g. "th" or "en".
+	MaterialChange *bool        `json:"material_change,omitempty"` // If true, consents given to earlier versions are no longer valid.
+}
+
+// ConsentScope is a kind of data use a patient consents to.
+type ConsentScope string
+
+const (
+	ConsentScopeDataSharing ConsentScope = "data-sharing"
+	ConsentScopeResearch    ConsentScope = "research"
+	ConsentScopeMarketing   ConsentScope = "marketing"
+)
+
+// ConsentStatus is the status of a consent.
+type ConsentStatus string
+
+const (
+	ConsentStatusActive     ConsentStatus = "active"
+	ConsentStatusRevoked    ConsentStatus = "revoked"
+	ConsentStatusSuperseded ConsentStatus = "superseded"
+)
+
+// ConsentsService records patient consents under /patients/{patientId}/consents.
+type ConsentsService service
+
+// ConsentListOptions filter ConsentsService.List.
+type ConsentListOptions struct {
+	Scope  ConsentScope
+	Status ConsentStatus
+}
+
+// Grant records a patient's consent to the latest consent document of a
+// scope. It supersedes an active consent to an earlier version of the same
+// scope and organisation.
+func (s *ConsentsService) Grant(ctx context.Context, patientID string, grant *ConsentGrant) (*Consent, error) {
+	return call[Consent](ctx, s.client, http.MethodPost, path("patients", patientID, "consents"), nil, grant)
+}
+
+// List returns a patient's consents; opts may be nil.
+func (s *ConsentsService) List(ctx context.Context, patientID string, opts *ConsentListOptions) ([]Consent, error) {
+	o := deref(opts)
+	q := query{}.setStr("scope", string(o.Scope)).setStr("status", string(o.Status))
+	return list[Consent](ctx, s.client, path("patients", patientID, "consents"), url.Values(q))
+}
+
+// Check reports whether a patient's data may be used for scope, by
+// organizationID for data-sharing; organizationID may be empty otherwise.
+func (s *ConsentsService) Check(ctx context.Context, patientID string, scope ConsentScope, organizationID string) (*ConsentDecision, error) {
+	q := query{}.setStr("scope", string(scope)).setStr("organizationId", organizationID)
+	return call[ConsentDecision](ctx, s.client, http.MethodGet, path("patients", patientID, "consents", "check"), url.Values(q), nil)
+}
+
+// Get returns a consent of a patient by ID.
+func (s *ConsentsService) Get(ctx context.Context, patientID, consentID string) (*Consent, error) {
+	return call[Consent](ctx, s.client, http.MethodGet, path("patients", patientID, "consents", consentID), nil, nil)
+}
+
+// Revoke revokes a consent.
+func (s *ConsentsService) Revoke(ctx context.Context, patientID, consentID string, revoke *ConsentRevoke) (*Consent, error) {
+	return call[Consent](ctx, s.client, http.MethodPost, path("patients", patientID, "consents", consentID, "revoke"), nil, revoke)
+}
+
+// ConsentDocumentsService publishes consent texts under /consent-documents.
+type ConsentDocumentsService service
+
+// Publish publishes a new version of the consent document of a scope.
+func (s *ConsentDocumentsService) Publish(ctx context.Context, document *ConsentDocumentCreate) (*ConsentDocument, error) {
+	return call[ConsentDocument](ctx, s.client, http.MethodPost, "consent-documents", nil, document)
+}
+
+// List returns every published version of the consent document of a scope,
+// newest first.
+func (s *ConsentDocumentsService) List(ctx context.Context, scope ConsentScope) ([]ConsentDocument, error) {
+	q := query{}.setStr("scope", string(scope))
+	return list[ConsentDocument](ctx, s.client, "consent-documents", url.Values(q))
+}
+
+// Get returns a consent document by ID.
+func (s *ConsentDocumentsService) Get(ctx context.Context, documentID string) (*ConsentDocument, error) {
+	return call[ConsentDocument](ctx, s.client, http.MethodGet, path("consent-documents", documentID), nil, nil)
+}
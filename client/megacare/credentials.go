@@ -0,0 +1,50 @@
+package megacare
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Credentials authorize the requests of a Client.
+type Credentials interface {
+	// Authorize adds the credentials to an outgoing request. It is called
+	// for every attempt, so tokens that expire between retries are renewed.
+	Authorize(ctx context.Context, req *http.Request) error
+}
+
+type apiKey string
+
+func (k apiKey) Authorize(_ context.Context, req *http.Request) error {
+	req.Header.Set("X-Api-Key", string(k))
+	return nil
+}
+
+// APIKeyAuth authenticates with an API key issued under /api/v1/admin/api-keys,
+// sent as the X-Api-Key header.
+func APIKeyAuth(key string) Credentials {
+	return apiKey(key)
+}
+
+// BearerToken authenticates with a fixed ID token, e.g. for a short-lived
+// command-line session. Use TokenSource for tokens that must be refreshed.
+func BearerToken(token string) Credentials {
+	return TokenSource(func(context.Context) (string, error) { return token, nil })
+}
+
+// TokenSource is a Credentials that sends the token returned by the
+// function as a bearer token. The function is called for every request and
+// should cache the token until it is close to its expiry.
+type TokenSource func(ctx context.Context) (string, error)
+
+func (f TokenSource) Authorize(ctx context.Context, req *http.Request) error {
+	token, err := f(ctx)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return errors.New("megacare: token source returned an empty token")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
@@ -0,0 +1,37 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// TelemetryReading is one reading sent by a device.
+type TelemetryReading struct {
+	Sequence   int            `json:"sequence"` // Per-device counter that increases with every reading; used to drop resent readings.
+	RecordedAt time.Time      `json:"recorded_at"`
+	Metrics    map[string]any `json:"metrics"` // Measured values keyed by name, e.g. {"spo2": 96, "pulseRate": 72} or {"pressure": 10.4, "leak": 12}.
+}
+
+// TelemetryBatch is the body of DevicesService.SendTelemetry.
+type TelemetryBatch struct {
+	Readings []TelemetryReading `json:"readings"`
+}
+
+// TelemetryBatchResult reports what the API did with a TelemetryBatch.
+type TelemetryBatchResult struct {
+	Accepted     int    `json:"accepted"`
+	Duplicates   int    `json:"duplicates"`
+	LastSequence *int   `json:"last_sequence,omitempty"` // Highest sequence number accepted so far for this device.
+	MessageID    string `json:"message_id,omitempty"`    // Pub/Sub message ID of the published batch, if anything was published.
+}
+
+// DevicesService sends device data under /devices.
+type DevicesService service
+
+// SendTelemetry sends a batch of readings from a device. Readings with a
+// sequence number the API has already accepted are counted as duplicates
+// and dropped, so a batch may safely be sent again.
+func (s *DevicesService) SendTelemetry(ctx context.Context, deviceID string, batch *TelemetryBatch) (*TelemetryBatchResult, error) {
+	return call[TelemetryBatchResult](ctx, s.client, http.MethodPost, path("devices", deviceID, "telemetry"), nil, batch)
+}
@@ -0,0 +1,58 @@
+// Package megacare is the Go client for the MegaCare REST API (/api/v1).
+//
+// Create one Client per deployment and share it; it is safe for concurrent
+// use:
+//
+//	client, err := megacare.NewClient("https://api.megacare.example", megacare.APIKeyAuth(os.Getenv("MEGACARE_API_KEY")))
+//	patient, err := client.Patients.Get(ctx, "p-123")
+//
+// Each resource has a service on the Client (client.Patients,
+// client.Appointments, ...) with one method per endpoint. Request and
+// response types mirror the API schemas, with the JSON field names the API
+// uses.
+//
+// # Retries
+//
+// Requests that fail with 429, 502, 503 or 504, or that do not get a
+// response at all, are retried with exponential backoff and jitter,
+// waiting at least as long as a Retry-After header asks. Every POST carries
+// an Idempotency-Key header that stays the same across its retries, so the
+// server can recognise a repeated request; use WithIdempotencyKey to supply
+// your own key, e.g. one stored with a job so that a restarted job reuses it.
+//
+// # Concurrent updates
+//
+// Resources carry a Version that the API increments on every change.
+// Updates must say which version they are based on: pass the context from
+// WithVersion, and an update racing another one fails with IsVersionConflict
+// instead of silently overwriting it:
+//
+//	patient, err := client.Patients.Get(ctx, "p-123")
+//	updated, err := client.Patients.Update(megacare.WithVersion(ctx, patient.Version), patient.PatientID, changes)
+//
+// # Organizations
+//
+// On deployments shared by several clinics, requests act for the caller's
+// organization. Platform administrators choose the organization a request
+// acts for with WithOrganization:
+//
+//	patients := client.Patients.List(nil).All(megacare.WithOrganization(ctx, "chiang-mai-sleep-clinic"))
+//
+// # Errors
+//
+// Responses other than 2xx are returned as *Error, decoded from the
+// problem details (RFC 9457) body. Use errors.As to read the status, the
+// problem type and, for 422 responses, the invalid fields.
+//
+// # Pagination
+//
+// List endpoints that page their results return a *Pager. Call Next for one
+// page at a time, or range over All to read every item:
+//
+//	for patient, err := range client.Patients.List(nil).All(ctx) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(patient.MRN)
+//	}
+package megacare
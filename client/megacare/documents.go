@@ -0,0 +1,123 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Document is the metadata of a file attached to a patient's chart.
+type Document struct {
+	Type        DocumentType   `json:"type"`
+	Title       string         `json:"title"`
+	ContentType string         `json:"content_type"`
+	Size        int64          `json:"size"`     // Size of the file in bytes.
+	Checksum    string         `json:"checksum"` // Base64 MD5 of the file.
+	DocumentID  string         `json:"document_id"`
+	PatientID   string         `json:"patient_id"`
+	BlobName    string         `json:"blob_name"`
+	Status      DocumentStatus `json:"status"`
+	UploadedBy  string         `json:"uploaded_by"`
+	UploadedAt  *time.Time     `json:"uploaded_at,omitempty"` // When the upload was confirmed.
+	ScannedAt   *time.Time     `json:"scanned_at,omitempty"`  // When the file was scanned for malware, if it was.
+	Threat      string         `json:"threat,omitempty"`      // What the malware scan found in a quarantined file.
+	Version     int            `json:"version"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// DocumentCreate is the body of DocumentsService.Create.
+type DocumentCreate struct {
+	Type        DocumentType `json:"type"`
+	Title       string       `json:"title"`
+	ContentType string       `json:"content_type"` // application/pdf, image/jpeg, image/png, image/tiff or text/plain.
+	Size        int64        `json:"size"`         // Size of the file in bytes.
+	Checksum    string       `json:"checksum"`     // Base64 MD5 of the file, sent again as the upload's Content-MD5 header.
+}
+
+// DocumentUpload is where to PUT a document's file.
+type DocumentUpload struct {
+	Document      Document          `json:"document"`
+	UploadURL     string            `json:"upload_url"`
+	UploadHeaders map[string]string `json:"upload_headers"` // Headers the PUT must be sent with.
+	ExpiresAt     time.Time         `json:"expires_at"`
+}
+
+// DocumentDownload is a signed URL to download a document's file from.
+type DocumentDownload struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// DocumentType is what kind of document a file is.
+type DocumentType string
+
+const (
+	DocumentTypeDischargeSummary DocumentType = "discharge-summary"
+	DocumentTypeReferralLetter   DocumentType = "referral-letter"
+	DocumentTypeLabReport        DocumentType = "lab-report"
+	DocumentTypeImagingReport    DocumentType = "imaging-report"
+	DocumentTypeConsentForm      DocumentType = "consent-form"
+	DocumentTypeOther            DocumentType = "other"
+)
+
+// DocumentStatus is where a document is in its upload. Only available
+// documents can be downloaded.
+type DocumentStatus string
+
+const (
+	DocumentStatusPending     DocumentStatus = "pending"      // Awaiting the upload.
+	DocumentStatusPendingScan DocumentStatus = "pending-scan" // Uploaded, awaiting the malware scan.
+	DocumentStatusAvailable   DocumentStatus = "available"
+	DocumentStatusQuarantined DocumentStatus = "quarantined" // The scan found malware; see Threat.
+)
+
+// DocumentsService attaches files to charts under
+// /patients/{patientId}/documents. Files are not sent through the API:
+// Create returns a signed Cloud Storage URL to PUT the file to, with the
+// headers in UploadHeaders (and no Authorization header), and Complete
+// confirms the upload.
+type DocumentsService service
+
+// DocumentListOptions filter DocumentsService.List.
+type DocumentListOptions struct {
+	Type   DocumentType
+	Status DocumentStatus
+}
+
+// Create records a pending document and returns where to upload its file.
+func (s *DocumentsService) Create(ctx context.Context, patientID string, document *DocumentCreate) (*DocumentUpload, error) {
+	return call[DocumentUpload](ctx, s.client, http.MethodPost, path("patients", patientID, "documents"), nil, document)
+}
+
+// Complete confirms that a document's file was uploaded. The document is
+// available, or pending-scan if the API scans uploads for malware. The API
+// answers 409 if nothing was uploaded yet, and 422 (discarding the file) if
+// it does not match the size, checksum and content type given to Create.
+func (s *DocumentsService) Complete(ctx context.Context, patientID, documentID string) (*Document, error) {
+	return call[Document](ctx, s.client, http.MethodPost, path("patients", patientID, "documents", documentID, "complete"), nil, nil)
+}
+
+// List returns the documents attached to a patient's chart; opts may be nil.
+func (s *DocumentsService) List(ctx context.Context, patientID string, opts *DocumentListOptions) ([]Document, error) {
+	o := deref(opts)
+	q := query{}.setStr("type", string(o.Type)).setStr("status", string(o.Status))
+	return list[Document](ctx, s.client, path("patients", patientID, "documents"), url.Values(q))
+}
+
+// Get returns a document's metadata by ID.
+func (s *DocumentsService) Get(ctx context.Context, patientID, documentID string) (*Document, error) {
+	return call[Document](ctx, s.client, http.MethodGet, path("patients", patientID, "documents", documentID), nil, nil)
+}
+
+// Download returns a short-lived signed URL to download a document's file.
+// The API answers 409 unless the document is available.
+func (s *DocumentsService) Download(ctx context.Context, patientID, documentID string) (*DocumentDownload, error) {
+	return call[DocumentDownload](ctx, s.client, http.MethodGet, path("patients", patientID, "documents", documentID, "download"), nil, nil)
+}
+
+// Delete removes a document and its file. This cannot be undone.
+func (s *DocumentsService) Delete(ctx context.Context, patientID, documentID string) error {
+	return s.client.do(ctx, http.MethodDelete, path("patients", patientID, "documents", documentID), nil, nil, nil)
+}
@@ -0,0 +1,176 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Encounter is a visit: what happened, who took part, and the observations
+// and documents it produced.
+type Encounter struct {
+	PatientID          string                  `json:"patient_id"`
+	VisitType          EncounterVisitType      `json:"visit_type"`
+	Reason             string                  `json:"reason,omitempty"`
+	Location           string                  `json:"location,omitempty"`
+	Start              time.Time               `json:"start"`
+	End                *time.Time              `json:"end,omitempty"`
+	Participants       []EncounterParticipant  `json:"participants,omitempty"`
+	AppointmentID      string                  `json:"appointment_id,omitempty"`      // The appointment this visit fulfils, if any.
+	PsychotherapyNotes string                  `json:"psychotherapy_notes,omitempty"` // The therapist's session notes, kept apart from the rest of the record. Encrypted at rest.
+	EncounterID        string                  `json:"encounter_id"`
+	Status             EncounterStatus         `json:"status"`
+	ObservationIDs     []string                `json:"observation_ids,omitempty"`
+	Documents          []EncounterDocumentLink `json:"documents,omitempty"`
+	Version            int                     `json:"version"`
+	CreatedAt          time.Time               `json:"created_at"`
+	UpdatedAt          time.Time               `json:"updated_at"`
+}
+
+// EncounterCreate is the body of EncountersService.Create.
+type EncounterCreate struct {
+	PatientID          string                 `json:"patient_id"`
+	VisitType          EncounterVisitType     `json:"visit_type"`
+	Reason             string                 `json:"reason,omitempty"`
+	Location           string                 `json:"location,omitempty"`
+	Start              time.Time              `json:"start"`
+	End                *time.Time             `json:"end,omitempty"`
+	Participants       []EncounterParticipant `json:"participants,omitempty"`
+	AppointmentID      string                 `json:"appointment_id,omitempty"`      // The appointment this visit fulfils, if any.
+	PsychotherapyNotes string                 `json:"psychotherapy_notes,omitempty"` // The therapist's session notes, kept apart from the rest of the record. Encrypted at rest.
+	Status             string                 `json:"status,omitempty"`
+}
+
+// EncounterUpdate changes a visit's details. Status is changed with
+// EncountersService.SetStatus.
+type EncounterUpdate struct {
+	VisitType          *EncounterVisitType    `json:"visit_type,omitempty"`
+	Reason             *string                `json:"reason,omitempty"`
+	Location           *string                `json:"location,omitempty"`
+	Start              *time.Time             `json:"start,omitempty"`
+	End                *time.Time             `json:"end,omitempty"`
+	Participants       []EncounterParticipant `json:"participants,omitempty"`
+	PsychotherapyNotes *string                `json:"psychotherapy_notes,omitempty"`
+}
+
+// EncounterStatusUpdate is the body of EncountersService.SetStatus.
+type EncounterStatusUpdate struct {
+	Status EncounterStatus `json:"status"`
+}
+
+// EncounterParticipant is a practitioner who took part in a visit.
+type EncounterParticipant struct {
+	PractitionerID string `json:"practitioner_id"`
+	Role           string `json:"role,omitempty"` // e.g. "attender", "consultant", "nurse".
+}
+
+// EncounterDocument is a document produced during a visit (visit note, sleep
+// study report, ...), stored elsewhere.
+type EncounterDocument struct {
+	Title       string `json:"title"`
+	ContentType string `json:"content_type,omitempty"`
+	URL         string `json:"url"` // Location of the document, e.g. a gs:// URI or document service URL.
+}
+
+// EncounterDocumentLink is a document attached to an encounter.
+type EncounterDocumentLink struct {
+	Title       string    `json:"title"`
+	ContentType string    `json:"content_type,omitempty"`
+	URL         string    `json:"url"` // Location of the document, e.g. a gs:// URI or document service URL.
+	DocumentID  string    `json:"document_id"`
+	AddedAt     time.Time `json:"added_at"`
+	AddedBy     string    `json:"added_by"`
+}
+
+// EncounterObservationLink is the body of EncountersService.LinkObservation.
+type EncounterObservationLink struct {
+	ObservationID string `json:"observation_id"`
+}
+
+// EncounterVisitType is the kind of visit.
+type EncounterVisitType string
+
+const (
+	EncounterVisitTypeAmbulatory EncounterVisitType = "ambulatory"
+	EncounterVisitTypeVirtual    EncounterVisitType = "virtual"
+	EncounterVisitTypeHome       EncounterVisitType = "home"
+	EncounterVisitTypeInpatient  EncounterVisitType = "inpatient"
+	EncounterVisitTypeEmergency  EncounterVisitType = "emergency"
+)
+
+// EncounterStatus is the status of an encounter.
+type EncounterStatus string
+
+const (
+	EncounterStatusPlanned    EncounterStatus = "planned"
+	EncounterStatusInProgress EncounterStatus = "in-progress"
+	EncounterStatusFinished   EncounterStatus = "finished"
+	EncounterStatusCancelled  EncounterStatus = "cancelled"
+)
+
+// EncountersService records visits under /encounters.
+type EncountersService service
+
+// EncounterListOptions filter EncountersService.List.
+type EncounterListOptions struct {
+	ListOptions
+	PatientID      string
+	PractitionerID string    // Only visits this practitioner took part in.
+	From           time.Time // Only visits starting at or after From.
+	To             time.Time // Only visits starting before To.
+}
+
+// Create opens an encounter.
+func (s *EncountersService) Create(ctx context.Context, encounter *EncounterCreate) (*Encounter, error) {
+	return call[Encounter](ctx, s.client, http.MethodPost, "encounters", nil, encounter)
+}
+
+// List pages through encounters; opts may be nil.
+func (s *EncountersService) List(opts *EncounterListOptions) *Pager[Encounter] {
+	o := deref(opts)
+	q := query{}.setStr("patientId", o.PatientID).setStr("practitionerId", o.PractitionerID).setTime("from", o.From).setTime("to", o.To)
+	return newPager[Encounter](s.client, "encounters", url.Values(q), o.ListOptions)
+}
+
+// Get returns an encounter by ID.
+func (s *EncountersService) Get(ctx context.Context, encounterID string) (*Encounter, error) {
+	return call[Encounter](ctx, s.client, http.MethodGet, path("encounters", encounterID), nil, nil)
+}
+
+// Update changes the details of an encounter.
+func (s *EncountersService) Update(ctx context.Context, encounterID string, update *EncounterUpdate) (*Encounter, error) {
+	return call[Encounter](ctx, s.client, http.MethodPatch, path("encounters", encounterID), nil, update)
+}
+
+// SetStatus moves an encounter to a new status, e.g. in-progress or
+// finished.
+func (s *EncountersService) SetStatus(ctx context.Context, encounterID string, update *EncounterStatusUpdate) (*Encounter, error) {
+	return call[Encounter](ctx, s.client, http.MethodPost, path("encounters", encounterID, "status"), nil, update)
+}
+
+// Observations returns the observations linked to an encounter.
+func (s *EncountersService) Observations(ctx context.Context, encounterID string) ([]Observation, error) {
+	return list[Observation](ctx, s.client, path("encounters", encounterID, "observations"), nil)
+}
+
+// LinkObservation links an observation of the patient to an encounter.
+func (s *EncountersService) LinkObservation(ctx context.Context, encounterID, observationID string) (*Encounter, error) {
+	link := &EncounterObservationLink{ObservationID: observationID}
+	return call[Encounter](ctx, s.client, http.MethodPost, path("encounters", encounterID, "observations"), nil, link)
+}
+
+// UnlinkObservation removes an observation from an encounter.
+func (s *EncountersService) UnlinkObservation(ctx context.Context, encounterID, observationID string) (*Encounter, error) {
+	return call[Encounter](ctx, s.client, http.MethodDelete, path("encounters", encounterID, "observations", observationID), nil, nil)
+}
+
+// AddDocument attaches a document to an encounter.
+func (s *EncountersService) AddDocument(ctx context.Context, encounterID string, document *EncounterDocument) (*Encounter, error) {
+	return call[Encounter](ctx, s.client, http.MethodPost, path("encounters", encounterID, "documents"), nil, document)
+}
+
+// RemoveDocument removes a document from an encounter.
+func (s *EncountersService) RemoveDocument(ctx context.Context, encounterID, documentID string) (*Encounter, error) {
+	return call[Encounter](ctx, s.client, http.MethodDelete, path("encounters", encounterID, "documents", documentID), nil, nil)
+}
@@ -0,0 +1,94 @@
+package megacare
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Problem types the API sets on errors that clients may want to branch on.
+const (
+	ProblemTypeNotFound          = "urn:megacare:problem:not-found"
+	ProblemTypeConflict          = "urn:megacare:problem:conflict"
+	ProblemTypeInvalidTransition = "urn:megacare:problem:invalid-transition"
+	ProblemTypeStaleCursor       = "urn:megacare:problem:stale-cursor"
+	ProblemTypeValidationError   = "urn:megacare:problem:validation-error"
+	ProblemTypeVersionConflict   = "urn:megacare:problem:version-conflict"
+	ProblemTypeCrossTenant       = "urn:megacare:problem:cross-tenant"
+)
+
+// FieldError is one invalid field of a request rejected with 422.
+type FieldError struct {
+	Field   string `json:"field"` // Path of the field, e.g. "contact.phoneNumber".
+	Message string `json:"message"`
+	Code    string `json:"code"` // The failed rule, e.g. "missing" or "phone_e164".
+}
+
+// Error is an API response other than 2xx, decoded from its problem
+// details body.
+type Error struct {
+	StatusCode int          `json:"-"`
+	Type       string       `json:"type"`
+	Title      string       `json:"title"`
+	Detail     string       `json:"detail,omitempty"`
+	Instance   string       `json:"instance,omitempty"`
+	RequestID  string       `json:"requestId,omitempty"` // Quote it when reporting a problem.
+	TraceID    string       `json:"traceId,omitempty"`
+	Errors     []FieldError `json:"errors,omitempty"`
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "megacare: %d %s", e.StatusCode, e.Title)
+	if e.Detail != "" {
+		fmt.Fprintf(&b, ": %s", e.Detail)
+	}
+	for _, f := range e.Errors {
+		fmt.Fprintf(&b, "; %s: %s", f.Field, f.Message)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " (request %s)", e.RequestID)
+	}
+	return b.String()
+}
+
+// IsNotFound reports whether err is an *Error for a 404 response.
+func IsNotFound(err error) bool {
+	return statusOf(err) == http.StatusNotFound
+}
+
+// IsConflict reports whether err is an *Error for a 409 response.
+func IsConflict(err error) bool {
+	return statusOf(err) == http.StatusConflict
+}
+
+// IsVersionConflict reports whether err is an *Error for an update refused
+// because the resource changed since the version it was based on.
+func IsVersionConflict(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Type == ProblemTypeVersionConflict
+}
+
+func statusOf(err error) int {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// errorFromResponse reads the problem details of a failed response. Bodies
+// that are not problem details (e.g. from a proxy) keep their status and
+// the start of the body as the detail.
+func errorFromResponse(resp *http.Response) *Error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	e := &Error{}
+	if err := json.Unmarshal(body, e); err != nil || e.Title == "" {
+		e = &Error{Type: "about:blank", Title: http.StatusText(resp.StatusCode), Detail: strings.TrimSpace(string(body[:min(len(body), 512)]))}
+	}
+	e.StatusCode = resp.StatusCode
+	return e
+}
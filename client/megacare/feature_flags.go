@@ -0,0 +1,68 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// FeatureFlag switches a feature on for some callers. An enabled flag is on
+// for the organizations it lists and for Percentage percent of the rest.
+type FeatureFlag struct {
+	Key           string     `json:"key"`
+	Description   string     `json:"description,omitempty"`
+	Enabled       bool       `json:"enabled"`
+	Percentage    int        `json:"percentage"`
+	Organizations []string   `json:"organizations,omitempty"`
+	Source        string     `json:"source"` // "config" for flags set with FEATURE_FLAGS and not stored, else "store".
+	UpdatedBy     string     `json:"updated_by,omitempty"`
+	Version       int        `json:"version"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"` // Unset for configured flags.
+	UpdatedAt     *time.Time `json:"updated_at,omitempty"`
+}
+
+// FeatureFlagCreate is the body of FeatureFlagsService.Create.
+type FeatureFlagCreate struct {
+	Key           string   `json:"key"` // Lowercase letters, digits and hyphens, e.g. "telehealth-visits".
+	Description   string   `json:"description,omitempty"`
+	Enabled       bool     `json:"enabled"`
+	Percentage    int      `json:"percentage"` // 0-100; set 100 to enable the flag for everyone.
+	Organizations []string `json:"organizations,omitempty"`
+}
+
+// FeatureFlagUpdate is the body of FeatureFlagsService.Update.
+type FeatureFlagUpdate struct {
+	Description   *string  `json:"description,omitempty"`
+	Enabled       *bool    `json:"enabled,omitempty"`
+	Percentage    *int     `json:"percentage,omitempty"`
+	Organizations []string `json:"organizations,omitempty"`
+}
+
+// FeatureFlagsService manages feature flags under /admin/flags. It needs the
+// platform-admin role.
+type FeatureFlagsService service
+
+// Create stores a flag, overriding a configured flag with the same key.
+func (s *FeatureFlagsService) Create(ctx context.Context, flag *FeatureFlagCreate) (*FeatureFlag, error) {
+	return call[FeatureFlag](ctx, s.client, http.MethodPost, path("admin", "flags"), nil, flag)
+}
+
+// List returns every flag in effect, stored or configured, by key.
+func (s *FeatureFlagsService) List(ctx context.Context) ([]FeatureFlag, error) {
+	return list[FeatureFlag](ctx, s.client, path("admin", "flags"), nil)
+}
+
+// Get returns a flag by key.
+func (s *FeatureFlagsService) Get(ctx context.Context, key string) (*FeatureFlag, error) {
+	return call[FeatureFlag](ctx, s.client, http.MethodGet, path("admin", "flags", key), nil, nil)
+}
+
+// Update changes a stored flag, e.g. to raise its percentage.
+func (s *FeatureFlagsService) Update(ctx context.Context, key string, update *FeatureFlagUpdate) (*FeatureFlag, error) {
+	return call[FeatureFlag](ctx, s.client, http.MethodPatch, path("admin", "flags", key), nil, update)
+}
+
+// Delete removes a stored flag; a configured flag with the same key applies again.
+func (s *FeatureFlagsService) Delete(ctx context.Context, key string) error {
+	return s.client.do(ctx, http.MethodDelete, path("admin", "flags", key), nil, nil, nil)
+}
@@ -0,0 +1,129 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Immunization is a vaccine dose given to, or not given to, a patient.
+type Immunization struct {
+	VaccineCode    Coding             `json:"vaccine_code"` // CVX vaccine code (system "http://hl7.org/fhir/sid/cvx").
+	Status         ImmunizationStatus `json:"status,omitempty"`
+	StatusReason   string             `json:"status_reason,omitempty"` // Why a dose was not given, for "not-done".
+	OccurrenceDate Date               `json:"occurrence_date"`         // Date the vaccine was administered.
+	LotNumber      string             `json:"lot_number,omitempty"`
+	ExpirationDate *Date              `json:"expiration_date,omitempty"` // Expiry of the lot.
+	Manufacturer   string             `json:"manufacturer,omitempty"`
+	Site           string             `json:"site,omitempty"` // Body site, e.g. "left deltoid".
+	Route          string             `json:"route,omitempty"`
+	PerformerID    string             `json:"performer_id,omitempty"` // Practitioner who administered the dose.
+	Series         string             `json:"series,omitempty"`       // Name of the series the dose belongs to, e.g. "Hepatitis B 3-dose".
+	DoseNumber     *int               `json:"dose_number,omitempty"`
+	SeriesDoses    *int               `json:"series_doses,omitempty"`
+	ImmunizationID string             `json:"immunization_id"`
+	PatientID      string             `json:"patient_id"`
+	RecordedBy     string             `json:"recorded_by"`
+	Version        int                `json:"version"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+// ImmunizationCreate is the body of ImmunizationsService.Create.
+type ImmunizationCreate struct {
+	VaccineCode    Coding             `json:"vaccine_code"` // CVX vaccine code (system "http://hl7.org/fhir/sid/cvx").
+	Status         ImmunizationStatus `json:"status,omitempty"`
+	StatusReason   string             `json:"status_reason,omitempty"` // Why a dose was not given, for "not-done".
+	OccurrenceDate Date               `json:"occurrence_date"`         // Date the vaccine was administered.
+	LotNumber      string             `json:"lot_number,omitempty"`
+	ExpirationDate *Date              `json:"expiration_date,omitempty"` // Expiry of the lot.
+	Manufacturer   string             `json:"manufacturer,omitempty"`
+	Site           string             `json:"site,omitempty"` // Body site, e.g. "left deltoid".
+	Route          string             `json:"route,omitempty"`
+	PerformerID    string             `json:"performer_id,omitempty"` // Practitioner who administered the dose.
+	Series         string             `json:"series,omitempty"`       // Name of the series the dose belongs to, e.g. "Hepatitis B 3-dose".
+	DoseNumber     *int               `json:"dose_number,omitempty"`
+	SeriesDoses    *int               `json:"series_doses,omitempty"`
+}
+
+// ImmunizationUpdate corrects a recorded dose; the vaccine and date cannot be
+// changed.
+type ImmunizationUpdate struct {
+	Status         *ImmunizationStatus `json:"status,omitempty"`
+	StatusReason   *string             `json:"status_reason,omitempty"`
+	LotNumber      *string             `json:"lot_number,omitempty"`
+	ExpirationDate *Date               `json:"expiration_date,omitempty"`
+	Manufacturer   *string             `json:"manufacturer,omitempty"`
+	Site           *string             `json:"site,omitempty"`
+	Route          *string             `json:"route,omitempty"`
+	Series         *string             `json:"series,omitempty"`
+	DoseNumber     *int                `json:"dose_number,omitempty"`
+	SeriesDoses    *int                `json:"series_doses,omitempty"`
+}
+
+// ImmunizationForecast is the doses a patient is due for.
+type ImmunizationForecast struct {
+	PatientID       string                       `json:"patient_id"`
+	AsOf            Date                         `json:"as_of"`
+	Recommendations []ImmunizationRecommendation `json:"recommendations"`
+}
+
+// ImmunizationRecommendation is the next dose of one vaccine series.
+type ImmunizationRecommendation struct {
+	SeriesID    string `json:"series_id"`
+	SeriesName  string `json:"series_name"`
+	Status      string `json:"status"`
+	DosesGiven  int    `json:"doses_given"`
+	DoseNumber  *int   `json:"dose_number,omitempty"` // The next dose; unset once the series is complete.
+	DueDate     *Date  `json:"due_date,omitempty"`
+	OverdueDate *Date  `json:"overdue_date,omitempty"`
+}
+
+// ImmunizationStatus is the status of an immunization.
+type ImmunizationStatus string
+
+const (
+	ImmunizationStatusCompleted      ImmunizationStatus = "completed"
+	ImmunizationStatusNotDone        ImmunizationStatus = "not-done"
+	ImmunizationStatusEnteredInError ImmunizationStatus = "entered-in-error"
+)
+
+// ImmunizationsService records vaccine doses under
+// /patients/{patientId}/immunizations.
+type ImmunizationsService service
+
+// ImmunizationListOptions filter ImmunizationsService.List.
+type ImmunizationListOptions struct {
+	ListOptions
+	VaccineCode string // Only doses with this CVX code.
+}
+
+// Create records a vaccine dose for a patient.
+func (s *ImmunizationsService) Create(ctx context.Context, patientID string, immunization *ImmunizationCreate) (*Immunization, error) {
+	return call[Immunization](ctx, s.client, http.MethodPost, path("patients", patientID, "immunizations"), nil, immunization)
+}
+
+// List pages through a patient's immunizations; opts may be nil.
+func (s *ImmunizationsService) List(patientID string, opts *ImmunizationListOptions) *Pager[Immunization] {
+	o := deref(opts)
+	q := query{}.setStr("vaccineCode", o.VaccineCode)
+	return newPager[Immunization](s.client, path("patients", patientID, "immunizations"), url.Values(q), o.ListOptions)
+}
+
+// Forecast returns the doses a patient is due for on asOf, or today when
+// asOf is nil.
+func (s *ImmunizationsService) Forecast(ctx context.Context, patientID string, asOf *Date) (*ImmunizationForecast, error) {
+	q := query{}.setDate("asOf", asOf)
+	return call[ImmunizationForecast](ctx, s.client, http.MethodGet, path("patients", patientID, "immunizations", "forecast"), url.Values(q), nil)
+}
+
+// Get returns an immunization of a patient by ID.
+func (s *ImmunizationsService) Get(ctx context.Context, patientID, immunizationID string) (*Immunization, error) {
+	return call[Immunization](ctx, s.client, http.MethodGet, path("patients", patientID, "immunizations", immunizationID), nil, nil)
+}
+
+// Update corrects the fields of a recorded dose that are set in update.
+func (s *ImmunizationsService) Update(ctx context.Context, patientID, immunizationID string, update *ImmunizationUpdate) (*Immunization, error) {
+	return call[Immunization](ctx, s.client, http.MethodPatch, path("patients", patientID, "immunizations", immunizationID), nil, update)
+}
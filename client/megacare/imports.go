@@ -0,0 +1,96 @@
+package megacare
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Import job statuses.
+const (
+	ImportAccepted   = "accepted"
+	ImportInProgress = "in-progress"
+	ImportCompleted  = "completed"
+	ImportError      = "error"
+	ImportCancelled  = "cancelled"
+)
+
+// ImportJob is a bulk import of an NDJSON file and how far it has got.
+type ImportJob struct {
+	JobID       string         `json:"job_id"`
+	Status      string         `json:"status"`
+	BlobName    string         `json:"blob_name"`
+	Size        int64          `json:"size"` // Size of the file in bytes.
+	RequestedBy string         `json:"requested_by"`
+	Offset      int64          `json:"offset"` // Bytes of the file read so far.
+	LinesRead   int            `json:"lines_read"`
+	Imported    map[string]int `json:"imported"` // Records created, by resource type.
+	Skipped     int            `json:"skipped"`  // Observations already imported with the same identifier.
+	Failed      int            `json:"failed"`   // Lines in the error report.
+	Error       string         `json:"error,omitempty"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// Done reports whether the job has stopped, successfully or not.
+func (j *ImportJob) Done() bool {
+	return j.Status != ImportAccepted && j.Status != ImportInProgress
+}
+
+// ImportLineError is a line of an import file that was not imported. The
+// line's values are not repeated.
+type ImportLineError struct {
+	Line         int    `json:"line"` // From 1.
+	ResourceType string `json:"resource_type,omitempty"`
+	Error        string `json:"error"`
+}
+
+// ImportsService imports patients and vital-sign observations in bulk
+// under /import.
+type ImportsService service
+
+// Start uploads an NDJSON file and starts importing it in the background.
+// Each line is a JSON object with a "resourceType" of "Patient" (the fields
+// of a PatientCreate) or "Observation" (the fields of a VitalSignCreate,
+// plus "patientId" or "patientMrn" and optionally the source system's
+// "identifier"). Poll the job with Get until it is Done.
+func (s *ImportsService) Start(ctx context.Context, ndjson []byte) (*ImportJob, error) {
+	body := rawBody{contentType: "application/x-ndjson", data: ndjson}
+	return call[ImportJob](ctx, s.client, http.MethodPost, path("import"), nil, body)
+}
+
+// Get returns an import job by ID.
+func (s *ImportsService) Get(ctx context.Context, jobID string) (*ImportJob, error) {
+	return call[ImportJob](ctx, s.client, http.MethodGet, path("import", jobID), nil, nil)
+}
+
+// Errors returns the lines that were not imported so far, in line order.
+func (s *ImportsService) Errors(ctx context.Context, jobID string) ([]ImportLineError, error) {
+	var report []byte
+	if err := s.client.do(ctx, http.MethodGet, path("import", jobID, "errors"), nil, nil, &report); err != nil {
+		return nil, err
+	}
+	lines := []ImportLineError{}
+	scanner := bufio.NewScanner(bytes.NewReader(report))
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var line ImportLineError
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// Cancel stops an import after the chunk of lines being imported. Records
+// already imported are kept.
+func (s *ImportsService) Cancel(ctx context.Context, jobID string) (*ImportJob, error) {
+	return call[ImportJob](ctx, s.client, http.MethodDelete, path("import", jobID), nil, nil)
+}
@@ -0,0 +1,144 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// LabResult is a laboratory report: one test, or a panel of analytes.
+type LabResult struct {
+	PanelCode    string          `json:"panel_code,omitempty"` // LOINC panel code, e.g. "24323-8" (metabolic panel); omit for a single test.
+	PanelDisplay string          `json:"panel_display,omitempty"`
+	Status       LabResultStatus `json:"status,omitempty"`
+	EffectiveAt  time.Time       `json:"effective_at"`         // Specimen collection time; must include a timezone.
+	IssuedAt     *time.Time      `json:"issued_at,omitempty"`  // When the lab released the result.
+	Performer    string          `json:"performer,omitempty"`  // Name of the performing laboratory.
+	OrderedBy    string          `json:"ordered_by,omitempty"` // Ordering practitioner ID.
+	EncounterID  string          `json:"encounter_id,omitempty"`
+	Analytes     []LabAnalyte    `json:"analytes"`
+	LabResultID  string          `json:"lab_result_id"`
+	PatientID    string          `json:"patient_id"`
+	Abnormal     bool            `json:"abnormal,omitempty"` // True if any analyte is flagged other than "N".
+	RecordedBy   string          `json:"recorded_by"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+// LabResultCreate is the body of LabResultsService.Create.
+type LabResultCreate struct {
+	PanelCode    string          `json:"panel_code,omitempty"` // LOINC panel code, e.g. "24323-8" (metabolic panel); omit for a single test.
+	PanelDisplay string          `json:"panel_display,omitempty"`
+	Status       LabResultStatus `json:"status,omitempty"`
+	EffectiveAt  time.Time       `json:"effective_at"`         // Specimen collection time; must include a timezone.
+	IssuedAt     *time.Time      `json:"issued_at,omitempty"`  // When the lab released the result.
+	Performer    string          `json:"performer,omitempty"`  // Name of the performing laboratory.
+	OrderedBy    string          `json:"ordered_by,omitempty"` // Ordering practitioner ID.
+	EncounterID  string          `json:"encounter_id,omitempty"`
+	Analytes     []LabAnalyte    `json:"analytes"`
+}
+
+// LabAnalyte is one measured analyte of a lab result.
+type LabAnalyte struct {
+	Code           string          `json:"code"` // LOINC code of the analyte, e.g. "2345-7" (glucose).
+	Display        string          `json:"display,omitempty"`
+	Value          *float64        `json:"value,omitempty"`
+	ValueString    string          `json:"value_string,omitempty"` // Result for non-numeric tests, e.g. "positive".
+	Unit           string          `json:"unit,omitempty"`         // UCUM unit of `value`.
+	ReferenceRange *ReferenceRange `json:"reference_range,omitempty"`
+	Flag           AbnormalFlag    `json:"flag,omitempty"` // Computed from the reference range when not sent by the lab.
+	Note           string          `json:"note,omitempty"`
+}
+
+// ReferenceRange is the normal range of an analyte.
+type ReferenceRange struct {
+	Low          *float64 `json:"low,omitempty"`
+	High         *float64 `json:"high,omitempty"`
+	CriticalLow  *float64 `json:"critical_low,omitempty"`
+	CriticalHigh *float64 `json:"critical_high,omitempty"`
+	Text         string   `json:"text,omitempty"` // Range as printed by the lab, e.g. "<200 mg/dL" or "negative".
+}
+
+// LabTrend is the results of one analyte over time.
+type LabTrend struct {
+	Code   string          `json:"code"`
+	Points []LabTrendPoint `json:"points"` // Oldest first.
+}
+
+// LabTrendPoint is one result in a LabTrend.
+type LabTrendPoint struct {
+	LabResultID    string          `json:"lab_result_id"`
+	EffectiveAt    time.Time       `json:"effective_at"`
+	Value          *float64        `json:"value,omitempty"`
+	ValueString    string          `json:"value_string,omitempty"`
+	Unit           string          `json:"unit,omitempty"`
+	Flag           AbnormalFlag    `json:"flag,omitempty"`
+	ReferenceRange *ReferenceRange `json:"reference_range,omitempty"`
+}
+
+// LabResultStatus is the status of a lab result.
+type LabResultStatus string
+
+const (
+	LabResultStatusPreliminary LabResultStatus = "preliminary"
+	LabResultStatusFinal       LabResultStatus = "final"
+	LabResultStatusCorrected   LabResultStatus = "corrected"
+	LabResultStatusCancelled   LabResultStatus = "cancelled"
+)
+
+// AbnormalFlag is the HL7 interpretation of an analyte against its reference
+// range.
+type AbnormalFlag string
+
+const (
+	AbnormalFlagN  AbnormalFlag = "N"
+	AbnormalFlagL  AbnormalFlag = "L"
+	AbnormalFlagH  AbnormalFlag = "H"
+	AbnormalFlagLL AbnormalFlag = "LL"
+	AbnormalFlagHH AbnormalFlag = "HH"
+	AbnormalFlagA  AbnormalFlag = "A"
+)
+
+// LabResultsService records lab results under /patients/{patientId}/lab-results.
+type LabResultsService service
+
+// LabResultListOptions filter LabResultsService.List.
+type LabResultListOptions struct {
+	ListOptions
+	Code string    // Only results whose panel or any analyte has this LOINC code.
+	From time.Time // Only results collected at or after From.
+	To   time.Time // Only results collected before To.
+}
+
+// LabTrendOptions select the results of LabResultsService.Trend.
+type LabTrendOptions struct {
+	From  time.Time
+	To    time.Time
+	Limit int // Maximum number of results to read; 100 when 0.
+}
+
+// Create records a lab result for a patient.
+func (s *LabResultsService) Create(ctx context.Context, patientID string, result *LabResultCreate) (*LabResult, error) {
+	return call[LabResult](ctx, s.client, http.MethodPost, path("patients", patientID, "lab-results"), nil, result)
+}
+
+// List pages through a patient's lab results; opts may be nil.
+func (s *LabResultsService) List(patientID string, opts *LabResultListOptions) *Pager[LabResult] {
+	o := deref(opts)
+	q := query{}.setStr("code", o.Code).setTime("from", o.From).setTime("to", o.To)
+	return newPager[LabResult](s.client, path("patients", patientID, "lab-results"), url.Values(q), o.ListOptions)
+}
+
+// Trend returns the results of one analyte over time, oldest first; opts may
+// be nil.
+func (s *LabResultsService) Trend(ctx context.Context, patientID, code string, opts *LabTrendOptions) (*LabTrend, error) {
+	o := deref(opts)
+	q := query{}.setStr("code", code).setTime("from", o.From).setTime("to", o.To).setInt("limit", o.Limit)
+	return call[LabTrend](ctx, s.client, http.MethodGet, path("patients", patientID, "lab-results", "trend"), url.Values(q), nil)
+}
+
+// Get returns a lab result of a patient by ID.
+func (s *LabResultsService) Get(ctx context.Context, patientID, labResultID string) (*LabResult, error) {
+	return call[LabResult](ctx, s.client, http.MethodGet, path("patients", patientID, "lab-results", labResultID), nil, nil)
+}
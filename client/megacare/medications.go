@@ -0,0 +1,168 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Medication is a prescribed medication on the medication list.
+type Medication struct {
+	Code           *Coding          `json:"code,omitempty"` // Coded medication, e.g. an RxNorm concept.
+	Name           string           `json:"name"`
+	Dosage         string           `json:"dosage,omitempty"` // Free-text dosage instructions, e.g. "1 tablet twice daily".
+	Route          string           `json:"route,omitempty"`
+	Quantity       *int             `json:"quantity,omitempty"` // Units dispensed per fill.
+	RefillsAllowed int              `json:"refills_allowed,omitempty"`
+	PrescriberID   string           `json:"prescriber_id,omitempty"`
+	StartDate      *Date            `json:"start_date,omitempty"`
+	EndDate        *Date            `json:"end_date,omitempty"`
+	MedicationID   string           `json:"medication_id"`
+	PatientID      string           `json:"patient_id"`
+	Status         MedicationStatus `json:"status"`
+	RefillsUsed    int              `json:"refills_used,omitempty"`
+	Version        int              `json:"version"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+// MedicationCreate is the body of MedicationsService.Create.
+type MedicationCreate struct {
+	Code           *Coding          `json:"code,omitempty"` // Coded medication, e.g. an RxNorm concept.
+	Name           string           `json:"name"`
+	Dosage         string           `json:"dosage,omitempty"` // Free-text dosage instructions, e.g. "1 tablet twice daily".
+	Route          string           `json:"route,omitempty"`
+	Quantity       *int             `json:"quantity,omitempty"` // Units dispensed per fill.
+	RefillsAllowed *int             `json:"refills_allowed,omitempty"`
+	PrescriberID   string           `json:"prescriber_id,omitempty"`
+	StartDate      *Date            `json:"start_date,omitempty"`
+	EndDate        *Date            `json:"end_date,omitempty"`
+	Status         MedicationStatus `json:"status,omitempty"`
+}
+
+// MedicationUpdate is the body of MedicationsService.Update.
+type MedicationUpdate struct {
+	Name           *string           `json:"name,omitempty"`
+	Dosage         *string           `json:"dosage,omitempty"`
+	Route          *string           `json:"route,omitempty"`
+	Quantity       *int              `json:"quantity,omitempty"`
+	RefillsAllowed *int              `json:"refills_allowed,omitempty"`
+	PrescriberID   *string           `json:"prescriber_id,omitempty"`
+	EndDate        *Date             `json:"end_date,omitempty"`
+	Status         *MedicationStatus `json:"status,omitempty"`
+}
+
+// MedicationStatus is the status of a medication.
+type MedicationStatus string
+
+const (
+	MedicationStatusActive    MedicationStatus = "active"
+	MedicationStatusOnHold    MedicationStatus = "on-hold"
+	MedicationStatusStopped   MedicationStatus = "stopped"
+	MedicationStatusCompleted MedicationStatus = "completed"
+)
+
+// RefillRequest is a request to refill a medication, and its review.
+type RefillRequest struct {
+	RefillRequestID        string              `json:"refill_request_id"`
+	PatientID              string              `json:"patient_id"`
+	MedicationID           string              `json:"medication_id"`
+	Status                 RefillRequestStatus `json:"status"`
+	Note                   string              `json:"note,omitempty"`
+	RequestedBy            string              `json:"requested_by"`
+	AssignedPractitionerID string              `json:"assigned_practitioner_id,omitempty"`
+	DecisionNote           string              `json:"decision_note,omitempty"`
+	DecidedBy              string              `json:"decided_by,omitempty"`
+	DecidedAt              *time.Time          `json:"decided_at,omitempty"`
+	Version                int                 `json:"version"`
+	CreatedAt              time.Time           `json:"created_at"`
+	UpdatedAt              time.Time           `json:"updated_at"`
+}
+
+// RefillRequestCreate is the body of MedicationsService.RequestRefill.
+type RefillRequestCreate struct {
+	Note string `json:"note,omitempty"` // Message from the patient to the reviewing practitioner.
+}
+
+// RefillRequestAssign is the body of MedicationsService.AssignRefillRequest.
+type RefillRequestAssign struct {
+	PractitionerID string `json:"practitioner_id"`
+}
+
+// RefillRequestStatusUpdate is the body of
+// MedicationsService.SetRefillRequestStatus.
+type RefillRequestStatusUpdate struct {
+	Status       RefillRequestStatus `json:"status"`
+	DecisionNote string              `json:"decision_note,omitempty"` // Shown to the patient, e.g. why a refill was denied.
+}
+
+// RefillRequestStatus is the review status of a refill request.
+type RefillRequestStatus string
+
+const (
+	RefillRequestStatusRequested   RefillRequestStatus = "requested"
+	RefillRequestStatusUnderReview RefillRequestStatus = "under-review"
+	RefillRequestStatusApproved    RefillRequestStatus = "approved"
+	RefillRequestStatusDenied      RefillRequestStatus = "denied"
+)
+
+// MedicationsService manages the medication list and refill requests under
+// /patients/{patientId}/medications.
+type MedicationsService service
+
+// Create prescribes a medication for a patient.
+func (s *MedicationsService) Create(ctx context.Context, patientID string, medication *MedicationCreate) (*Medication, error) {
+	return call[Medication](ctx, s.client, http.MethodPost, path("patients", patientID, "medications"), nil, medication)
+}
+
+// List returns a patient's medications, optionally only those with a given
+// status; status may be empty.
+func (s *MedicationsService) List(ctx context.Context, patientID string, status MedicationStatus) ([]Medication, error) {
+	q := query{}.setStr("status", string(status))
+	return list[Medication](ctx, s.client, path("patients", patientID, "medications"), url.Values(q))
+}
+
+// Get returns a medication of a patient by ID.
+func (s *MedicationsService) Get(ctx context.Context, patientID, medicationID string) (*Medication, error) {
+	return call[Medication](ctx, s.client, http.MethodGet, path("patients", patientID, "medications", medicationID), nil, nil)
+}
+
+// Update changes the fields of a medication that are set in update.
+func (s *MedicationsService) Update(ctx context.Context, patientID, medicationID string, update *MedicationUpdate) (*Medication, error) {
+	return call[Medication](ctx, s.client, http.MethodPatch, path("patients", patientID, "medications", medicationID), nil, update)
+}
+
+// RequestRefill requests a refill of an active medication. Only one request
+// per medication can be open at a time.
+func (s *MedicationsService) RequestRefill(ctx context.Context, patientID, medicationID string, refill *RefillRequestCreate) (*RefillRequest, error) {
+	return call[RefillRequest](ctx, s.client, http.MethodPost, refillRequestsPath(patientID, medicationID), nil, refill)
+}
+
+// RefillRequests pages through the refill requests of a medication; opts may
+// be nil.
+func (s *MedicationsService) RefillRequests(patientID, medicationID string, opts *ListOptions) *Pager[RefillRequest] {
+	return newPager[RefillRequest](s.client, refillRequestsPath(patientID, medicationID), nil, deref(opts))
+}
+
+// GetRefillRequest returns a refill request by ID.
+func (s *MedicationsService) GetRefillRequest(ctx context.Context, patientID, medicationID, refillRequestID string) (*RefillRequest, error) {
+	return call[RefillRequest](ctx, s.client, http.MethodGet, refillRequestsPath(patientID, medicationID, refillRequestID), nil, nil)
+}
+
+// AssignRefillRequest assigns an open refill request to the practitioner who
+// will review it.
+func (s *MedicationsService) AssignRefillRequest(ctx context.Context, patientID, medicationID, refillRequestID, practitionerID string) (*RefillRequest, error) {
+	assign := &RefillRequestAssign{PractitionerID: practitionerID}
+	return call[RefillRequest](ctx, s.client, http.MethodPost, refillRequestsPath(patientID, medicationID, refillRequestID, "assign"), nil, assign)
+}
+
+// SetRefillRequestStatus moves a refill request through review. Approving a
+// request counts one refill against the medication.
+func (s *MedicationsService) SetRefillRequestStatus(ctx context.Context, patientID, medicationID, refillRequestID string, update *RefillRequestStatusUpdate) (*RefillRequest, error) {
+	return call[RefillRequest](ctx, s.client, http.MethodPost, refillRequestsPath(patientID, medicationID, refillRequestID, "status"), nil, update)
+}
+
+func refillRequestsPath(patientID, medicationID string, rest ...string) string {
+	return path(append([]string{"patients", patientID, "medications", medicationID, "refill-requests"}, rest...)...)
+}
@@ -0,0 +1,81 @@
+package megacare
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Date is a calendar date without a time of day, sent as YYYY-MM-DD.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// DateOf returns the date of t in t's location.
+func DateOf(t time.Time) Date {
+	year, month, day := t.Date()
+	return Date{Year: year, Month: month, Day: day}
+}
+
+// ParseDate parses a YYYY-MM-DD date.
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return Date{}, fmt.Errorf("megacare: invalid date %q: %w", s, err)
+	}
+	return DateOf(t), nil
+}
+
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Coding is a code from a code system, e.g. LOINC, SNOMED CT or RxNorm.
+type Coding struct {
+	System  string `json:"system"` // Code system URI, e.g. "http://loinc.org".
+	Code    string `json:"code"`
+	Display string `json:"display,omitempty"`
+}
+
+// ContactInfo holds the contact details of a patient or practitioner. Phone
+// numbers are normalised to E.164 by the API.
+type ContactInfo struct {
+	PhoneNumber string `json:"phone_number,omitempty"`
+	Email       string `json:"email,omitempty"`
+	AddressLine string `json:"address_line,omitempty"`
+	City        string `json:"city,omitempty"`
+	PostalCode  string `json:"postal_code,omitempty"`
+	Country     string `json:"country,omitempty"`
+}
+
+// InsuranceCoverage is the health plan that covers a patient.
+type InsuranceCoverage struct {
+	Payer       string `json:"payer"`     // The insurer or plan, e.g. "Blue Cross".
+	MemberID    string `json:"member_id"` // The patient's ID with the payer. Encrypted at rest.
+	GroupNumber string `json:"group_number,omitempty"`
+}
+
+// Identifier is an identifier assigned by another system.
+type Identifier struct {
+	System string `json:"system"`
+	Value  string `json:"value"`
+}
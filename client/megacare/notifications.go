@@ -0,0 +1,96 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Notification is a transactional message sent to a patient, e.g. an
+// appointment confirmation, and its delivery as reported by the provider.
+type Notification struct {
+	NotificationID    string               `json:"notification_id"`
+	Channel           NotificationChannel  `json:"channel"`
+	Template          NotificationTemplate `json:"template"`
+	Recipient         string               `json:"recipient"` // Email address, E.164 phone number, or the patient's devices for push.
+	PatientID         string               `json:"patient_id,omitempty"`
+	About             string               `json:"about,omitempty"` // The record the message is about, e.g. "appointments/<id>".
+	Context           map[string]any       `json:"context,omitempty"`
+	Status            NotificationStatus   `json:"status"`
+	Provider          string               `json:"provider,omitempty"` // "sendgrid", "smtp", "twilio" or "fcm", once sent.
+	Sender            string               `json:"sender,omitempty"`   // Address, number or messaging service the message was sent from.
+	Subject           string               `json:"subject,omitempty"`  // Of emails, or the title of a push; texts have none.
+	ProviderMessageID string               `json:"provider_message_id,omitempty"`
+	SentAt            *time.Time           `json:"sent_at,omitempty"`
+	Error             string               `json:"error,omitempty"` // Why the provider refused the message, if it did.
+	DeliveryEvents    []DeliveryEvent      `json:"delivery_events"`
+	Version           int                  `json:"version"`
+	CreatedAt         time.Time            `json:"created_at"`
+	UpdatedAt         time.Time            `json:"updated_at"`
+}
+
+// DeliveryEvent is a delivery report of the provider about a notification.
+type DeliveryEvent struct {
+	Status          NotificationStatus `json:"status"`
+	At              time.Time          `json:"at"`
+	Reason          string             `json:"reason,omitempty"` // E.g. the bounce reply of the receiving server.
+	ProviderEventID string             `json:"provider_event_id,omitempty"`
+}
+
+// NotificationChannel is how a notification is sent.
+type NotificationChannel string
+
+const (
+	NotificationChannelEmail NotificationChannel = "email"
+	NotificationChannelSMS   NotificationChannel = "sms"
+	NotificationChannelPush  NotificationChannel = "push"
+)
+
+// NotificationTemplate is which message a notification is.
+type NotificationTemplate string
+
+const (
+	NotificationTemplateAppointmentConfirmation NotificationTemplate = "appointment-confirmation"
+	NotificationTemplateAppointmentReminder     NotificationTemplate = "appointment-reminder" // Push only.
+	NotificationTemplateResultReady             NotificationTemplate = "result-ready"
+	NotificationTemplateAbnormalReading         NotificationTemplate = "abnormal-reading" // Push only.
+)
+
+// NotificationStatus is where a notification is in its delivery. A late
+// report never moves it back: a deferral received after the delivery is
+// recorded among DeliveryEvents only.
+type NotificationStatus string
+
+const (
+	NotificationStatusQueued     NotificationStatus = "queued"
+	NotificationStatusThrottled  NotificationStatus = "throttled" // Over the API's SMS caps; never sent.
+	NotificationStatusSent       NotificationStatus = "sent"      // Accepted by the provider.
+	NotificationStatusDeferred   NotificationStatus = "deferred"
+	NotificationStatusDelivered  NotificationStatus = "delivered"
+	NotificationStatusBounced    NotificationStatus = "bounced"
+	NotificationStatusDropped    NotificationStatus = "dropped"
+	NotificationStatusComplained NotificationStatus = "complained" // The patient reported it as spam.
+	NotificationStatusFailed     NotificationStatus = "failed"     // Refused, or the patient opted out; see Error.
+)
+
+// NotificationsService reads the notifications sent to patients under
+// /patients/{patientId}/notifications. The API sends them itself.
+type NotificationsService service
+
+// NotificationListOptions filter NotificationsService.List.
+type NotificationListOptions struct {
+	Status NotificationStatus
+}
+
+// List returns the notifications sent to a patient, newest first; opts may be nil.
+func (s *NotificationsService) List(ctx context.Context, patientID string, opts *NotificationListOptions) ([]Notification, error) {
+	o := deref(opts)
+	q := query{}.setStr("status", string(o.Status))
+	return list[Notification](ctx, s.client, path("patients", patientID, "notifications"), url.Values(q))
+}
+
+// Get returns a notification by ID.
+func (s *NotificationsService) Get(ctx context.Context, patientID, notificationID string) (*Notification, error) {
+	return call[Notification](ctx, s.client, http.MethodGet, path("patients", patientID, "notifications", notificationID), nil, nil)
+}
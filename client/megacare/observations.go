@@ -0,0 +1,80 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Observation is a measurement, e.g. a vital sign.
+type Observation struct {
+	PatientID     string                 `json:"patient_id"`
+	Code          Coding                 `json:"code"`
+	Status        string                 `json:"status,omitempty"`
+	Value         *float64               `json:"value,omitempty"`
+	Unit          string                 `json:"unit,omitempty"` // UCUM unit of `value`, e.g. "%" or "kg".
+	Components    []ObservationComponent `json:"components,omitempty"`
+	EffectiveAt   time.Time              `json:"effective_at"`
+	Identifier    *Identifier            `json:"identifier,omitempty"` // Identifier assigned by the sending system, if any.
+	ObservationID string                 `json:"observation_id"`
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+}
+
+// ObservationComponent is one part of a multi-part observation, e.g. the
+// systolic value of a blood pressure.
+type ObservationComponent struct {
+	Code  Coding   `json:"code"`
+	Value *float64 `json:"value,omitempty"`
+	Unit  string   `json:"unit,omitempty"`
+}
+
+// VitalSignCreate is the body of ObservationsService.Create. Code must be one
+// of the supported LOINC vital-sign codes.
+type VitalSignCreate struct {
+	Code        string                     `json:"code"` // LOINC code, e.g. "59408-5" (SpO2), "8867-4" (heart rate), "85354-9" (blood pressure), "29463-7" (weight).
+	Value       *float64                   `json:"value,omitempty"`
+	Unit        string                     `json:"unit,omitempty"` // UCUM unit; must be valid for the code.
+	Components  []VitalSignComponentCreate `json:"components,omitempty"`
+	EffectiveAt time.Time                  `json:"effective_at"` // When the measurement was taken; must include a timezone.
+	Status      string                     `json:"status,omitempty"`
+}
+
+// VitalSignComponentCreate is one component of a multi-part vital sign.
+type VitalSignComponentCreate struct {
+	Code  string  `json:"code"` // LOINC code of the component, e.g. "8480-6" for systolic pressure.
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// ObservationsService records vital signs under /patients/{patientId}/observations.
+type ObservationsService service
+
+// ObservationListOptions filter ObservationsService.List.
+type ObservationListOptions struct {
+	ListOptions
+	Code string    // Only observations with this LOINC code.
+	From time.Time // Only observations taken at or after From.
+	To   time.Time // Only observations taken before To.
+	Search
+}
+
+// Create records a vital sign for a patient.
+func (s *ObservationsService) Create(ctx context.Context, patientID string, vital *VitalSignCreate) (*Observation, error) {
+	return call[Observation](ctx, s.client, http.MethodPost, path("patients", patientID, "observations"), nil, vital)
+}
+
+// List pages through a patient's observations, newest first unless sorted
+// otherwise; opts may be nil. They can be filtered by code, status, value,
+// effectiveAt and createdAt, and sorted by effectiveAt or createdAt.
+func (s *ObservationsService) List(patientID string, opts *ObservationListOptions) *Pager[Observation] {
+	o := deref(opts)
+	q := query{}.setStr("code", o.Code).setTime("from", o.From).setTime("to", o.To).setSearch(o.Search)
+	return newPager[Observation](s.client, path("patients", patientID, "observations"), url.Values(q), o.ListOptions)
+}
+
+// Get returns an observation of a patient by ID.
+func (s *ObservationsService) Get(ctx context.Context, patientID, observationID string) (*Observation, error) {
+	return call[Observation](ctx, s.client, http.MethodGet, path("patients", patientID, "observations", observationID), nil, nil)
+}
@@ -0,0 +1,182 @@
+package megacare
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Organization is a clinic sharing the deployment. Its users' tokens carry
+// its ID as the organizationId claim.
+type Organization struct {
+	OrganizationID string       `json:"organization_id"`
+	Name           string       `json:"name"`
+	Contact        *ContactInfo `json:"contact,omitempty"`
+	EmailSender    *EmailSender `json:"email_sender,omitempty"` // Sender of the organization's emails; the API's default if nil.
+	// AllowedSourceRanges are the CIDR ranges the organization's admin and
+	// integration requests must come from; the deployment default if empty.
+	AllowedSourceRanges []string  `json:"allowed_source_ranges,omitempty"`
+	Version             int       `json:"version"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// OrganizationCreate is the body of OrganizationsService.Create.
+type OrganizationCreate struct {
+	OrganizationID      string       `json:"organization_id"` // Lowercase letters, digits and hyphens, e.g. "chiang-mai-sleep-clinic".
+	Name                string       `json:"name"`
+	Contact             *ContactInfo `json:"contact,omitempty"`
+	EmailSender         *EmailSender `json:"email_sender,omitempty"`
+	AllowedSourceRanges []string     `json:"allowed_source_ranges,omitempty"`
+}
+
+// OrganizationUpdate is the body of OrganizationsService.Update.
+type OrganizationUpdate struct {
+	Name        *string      `json:"name,omitempty"`
+	Contact     *ContactInfo `json:"contact,omitempty"`
+	EmailSender *EmailSender `json:"email_sender,omitempty"`
+	// AllowedSourceRanges replaces the organization's ranges when non-nil;
+	// point it at an empty slice to fall back to the deployment default.
+	AllowedSourceRanges *[]string `json:"allowed_source_ranges,omitempty"`
+}
+
+// EmailSender is who an organization's emails come from. With SendGrid the
+// address must be a verified sender or on an authenticated domain.
+type EmailSender struct {
+	Address string `json:"address"`
+	Name    string `json:"name,omitempty"`
+	ReplyTo string `json:"reply_to,omitempty"`
+}
+
+// OrganizationsService manages organizations under /organizations. Creating
+// and listing them needs the platform-admin role.
+type OrganizationsService service
+
+// Create registers an organization.
+func (s *OrganizationsService) Create(ctx context.Context, organization *OrganizationCreate) (*Organization, error) {
+	return call[Organization](ctx, s.client, http.MethodPost, "organizations", nil, organization)
+}
+
+// List pages through organizations by name; opts may be nil.
+func (s *OrganizationsService) List(opts *ListOptions) *Pager[Organization] {
+	return newPager[Organization](s.client, "organizations", nil, deref(opts))
+}
+
+// Get returns an organization by ID. Members may get their own.
+func (s *OrganizationsService) Get(ctx context.Context, organizationID string) (*Organization, error) {
+	return call[Organization](ctx, s.client, http.MethodGet, path("organizations", organizationID), nil, nil)
+}
+
+// Update changes an organization's name, contact details or allowed
+// source ranges. Its administrators may update their own, but not to
+// ranges that exclude the address they call from.
+func (s *OrganizationsService) Update(ctx context.Context, organizationID string, update *OrganizationUpdate) (*Organization, error) {
+	return call[Organization](ctx, s.client, http.MethodPatch, path("organizations", organizationID), nil, update)
+}
+
+// RetentionCategory is a kind of data with its own retention period.
+type RetentionCategory string
+
+const (
+	RetentionCategoryTelemetry RetentionCategory = "telemetry" // Observations, by when they were taken.
+	RetentionCategoryAudit     RetentionCategory = "audit"     // PHI access audit events, by when they occurred.
+	RetentionCategoryMessages  RetentionCategory = "messages"  // Relayed domain events and finished webhook deliveries.
+)
+
+// RetentionAction is what happens to data past its retention period.
+type RetentionAction string
+
+const (
+	RetentionActionDelete    RetentionAction = "delete"
+	RetentionActionAnonymize RetentionAction = "anonymize" // Kept without patient, user and request identifiers.
+)
+
+// RetentionRule is how long one category of data is kept.
+type RetentionRule struct {
+	Days   int             `json:"days"`
+	Action RetentionAction `json:"action,omitempty"` // Defaults to RetentionActionDelete.
+	Source string          `json:"source,omitempty"` // In responses: "config" for a deployment default, else "organization".
+}
+
+// RetentionPolicy is the rules in effect for an organization. A nil
+// category is kept indefinitely.
+type RetentionPolicy struct {
+	OrganizationID string         `json:"organization_id"`
+	Telemetry      *RetentionRule `json:"telemetry"`
+	Audit          *RetentionRule `json:"audit"`
+	Messages       *RetentionRule `json:"messages"`
+	UpdatedBy      string         `json:"updated_by,omitempty"`
+	Version        int            `json:"version"`
+	UpdatedAt      *time.Time     `json:"updated_at,omitempty"`
+}
+
+// RetentionPolicyUpdate is the body of OrganizationsService.UpdateRetention.
+// Unset categories are left as they are; those in Reset follow the
+// deployment default again.
+type RetentionPolicyUpdate struct {
+	Telemetry *RetentionRule
+	Audit     *RetentionRule
+	Messages  *RetentionRule
+	Reset     []RetentionCategory
+}
+
+// MarshalJSON sends the categories in Reset as null.
+func (u RetentionPolicyUpdate) MarshalJSON() ([]byte, error) {
+	body := map[string]*RetentionRule{}
+	for _, category := range u.Reset {
+		body[string(category)] = nil
+	}
+	for category, rule := range map[RetentionCategory]*RetentionRule{RetentionCategoryTelemetry: u.Telemetry, RetentionCategoryAudit: u.Audit, RetentionCategoryMessages: u.Messages} {
+		if rule != nil {
+			body[string(category)] = &RetentionRule{Days: rule.Days, Action: rule.Action}
+		}
+	}
+	return json.Marshal(body)
+}
+
+// PurgeManifest records what one run of the retention job did to one
+// category of an organization's data.
+type PurgeManifest struct {
+	ManifestID    string            `json:"manifest_id"`
+	TenantID      string            `json:"tenant_id,omitempty"`
+	Category      RetentionCategory `json:"category"`
+	Action        RetentionAction   `json:"action"`
+	RetentionDays int               `json:"retention_days"`
+	Cutoff        time.Time         `json:"cutoff"`          // Data older than this expired.
+	Since         *time.Time        `json:"since,omitempty"` // For anonymizing, the previous run's cutoff.
+	Counts        map[string]int    `json:"counts"`          // Records deleted or anonymized, by collection.
+	Status        string            `json:"status"`          // "completed" or "failed".
+	Error         string            `json:"error,omitempty"`
+	StartedAt     time.Time         `json:"started_at"`
+	FinishedAt    time.Time         `json:"finished_at"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// PurgeManifestListOptions filter OrganizationsService.ListPurges.
+type PurgeManifestListOptions struct {
+	ListOptions
+	Category RetentionCategory
+}
+
+// Retention returns the organization's retention policy. It needs the
+// admin role in the organization, or platform-admin.
+func (s *OrganizationsService) Retention(ctx context.Context, organizationID string) (*RetentionPolicy, error) {
+	return call[RetentionPolicy](ctx, s.client, http.MethodGet, path("organizations", organizationID, "retention"), nil, nil)
+}
+
+// UpdateRetention sets the organization's own retention rules. Pass the
+// policy's Version with WithVersion; it is 0 before any rule is set.
+func (s *OrganizationsService) UpdateRetention(ctx context.Context, organizationID string, update *RetentionPolicyUpdate) (*RetentionPolicy, error) {
+	return call[RetentionPolicy](ctx, s.client, http.MethodPatch, path("organizations", organizationID, "retention"), nil, update)
+}
+
+// ListPurges pages through the organization's purge manifests, newest
+// first; opts may be nil.
+func (s *OrganizationsService) ListPurges(organizationID string, opts *PurgeManifestListOptions) *Pager[PurgeManifest] {
+	o := deref(opts)
+	q := query{}.setStr("category", string(o.Category))
+	return newPager[PurgeManifest](s.client, path("organizations", organizationID, "retention", "purges"), url.Values(q), o.ListOptions)
+}
@@ -0,0 +1,140 @@
+package megacare
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ErrNoMorePages is returned by Pager.Next after the last page.
+var ErrNoMorePages = errors.New("megacare: no more pages")
+
+// Page is one page of a list endpoint.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"` // Set when more items follow.
+	Total      *int   `json:"total,omitempty"`       // The number of items across all pages; only set on the last page.
+}
+
+// ListOptions select the page of a list endpoint. The filters of a list
+// must stay the same from page to page; the API rejects a cursor used with
+// different ones.
+type ListOptions struct {
+	Limit  int    // Items per page; the endpoint's default when 0.
+	Cursor string // The NextCursor of an earlier page, to resume from there.
+}
+
+// Pager reads the pages of one list request in order. It is not safe for
+// concurrent use.
+type Pager[T any] struct {
+	client *Client
+	path   string
+	query  url.Values
+	cursor string
+	done   bool
+}
+
+func newPager[T any](c *Client, p string, query url.Values, opts ListOptions) *Pager[T] {
+	if query == nil {
+		query = url.Values{}
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	return &Pager[T]{client: c, path: p, query: query, cursor: opts.Cursor}
+}
+
+// More reports whether Next has another page to fetch.
+func (p *Pager[T]) More() bool {
+	return !p.done
+}
+
+// Next fetches the next page, or returns ErrNoMorePages after the last one.
+func (p *Pager[T]) Next(ctx context.Context) (*Page[T], error) {
+	if p.done {
+		return nil, ErrNoMorePages
+	}
+	query := url.Values{}
+	for name, values := range p.query {
+		query[name] = values
+	}
+	if p.cursor != "" {
+		query.Set("cursor", p.cursor)
+	}
+	page := &Page[T]{}
+	if err := p.client.do(ctx, http.MethodGet, p.path, query, nil, page); err != nil {
+		return nil, err
+	}
+	p.cursor = page.NextCursor
+	p.done = page.NextCursor == ""
+	return page, nil
+}
+
+// All returns an iterator over the items of every remaining page. It stops
+// after the first error, which it yields with the zero value of T.
+func (p *Pager[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for p.More() {
+			page, err := p.Next(ctx)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range page.Items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// query builds the filters of a list request, leaving out unset values.
+type query url.Values
+
+func (q query) setStr(name, value string) query {
+	if value != "" {
+		url.Values(q).Set(name, value)
+	}
+	return q
+}
+
+func (q query) setTime(name string, value time.Time) query {
+	if !value.IsZero() {
+		url.Values(q).Set(name, value.Format(time.RFC3339Nano))
+	}
+	return q
+}
+
+func (q query) setDate(name string, value *Date) query {
+	if value != nil {
+		url.Values(q).Set(name, value.String())
+	}
+	return q
+}
+
+func (q query) setBool(name string, value bool) query {
+	if value {
+		url.Values(q).Set(name, "true")
+	}
+	return q
+}
+
+func (q query) setFloat(name string, value *float64) query {
+	if value != nil {
+		url.Values(q).Set(name, strconv.FormatFloat(*value, 'f', -1, 64))
+	}
+	return q
+}
+
+func (q query) setInt(name string, value int) query {
+	if value != 0 {
+		url.Values(q).Set(name, strconv.Itoa(value))
+	}
+	return q
+}
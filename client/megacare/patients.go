@@ -0,0 +1,150 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Patient is a patient record.
+type Patient struct {
+	GivenName          string             `json:"given_name"`
+	FamilyName         string             `json:"family_name"`
+	DOB                Date               `json:"dob"`
+	Sex                string             `json:"sex,omitempty"`
+	MRN                string             `json:"mrn"`                  // Medical record number, unique per patient.
+	NHSNumber          string             `json:"nhs_number,omitempty"` // 10-digit NHS number, for patients registered with the NHS.
+	SSN                string             `json:"ssn,omitempty"`        // US Social Security number. Encrypted at rest.
+	Contact            *ContactInfo       `json:"contact,omitempty"`
+	Insurance          *InsuranceCoverage `json:"insurance,omitempty"`
+	Timezone           string             `json:"timezone,omitempty"` // IANA timezone, e.g. "Asia/Bangkok", for reminder times.
+	PatientID          string             `json:"patient_id"`
+	ContactPreferences ContactPreferences `json:"contact_preferences"`  // See PushService for the push categories.
+	DeletedAt          *time.Time         `json:"deleted_at,omitempty"` // Set while the record is deleted; see Restore.
+	DeletedBy          string             `json:"deleted_by,omitempty"`
+	MergedInto         string             `json:"merged_into,omitempty"` // Set on a duplicate merged into that patient; see Merge.
+	Version            int                `json:"version"`
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+}
+
+// ContactPreferences is how a patient agreed to be contacted.
+type ContactPreferences struct {
+	SMSOptOut          bool            `json:"sms_opt_out"` // The patient texted STOP; no texts are sent until they text START.
+	SMSOptOutChangedAt *time.Time      `json:"sms_opt_out_changed_at,omitempty"`
+	Push               PushPreferences `json:"push"`
+}
+
+// PatientCreate is the body of PatientsService.Create.
+type PatientCreate struct {
+	GivenName  string             `json:"given_name"`
+	FamilyName string             `json:"family_name"`
+	DOB        Date               `json:"dob"`
+	Sex        string             `json:"sex,omitempty"`
+	MRN        string             `json:"mrn"`                  // Medical record number, unique per patient.
+	NHSNumber  string             `json:"nhs_number,omitempty"` // 10-digit NHS number, for patients registered with the NHS.
+	SSN        string             `json:"ssn,omitempty"`        // US Social Security number. Encrypted at rest.
+	Contact    *ContactInfo       `json:"contact,omitempty"`
+	Insurance  *InsuranceCoverage `json:"insurance,omitempty"`
+	Timezone   string             `json:"timezone,omitempty"` // IANA timezone, e.g. "Asia/Bangkok".
+}
+
+// PatientUpdate is the body of PatientsService.Update; only fields that are set
+// are changed.
+type PatientUpdate struct {
+	GivenName  *string            `json:"given_name,omitempty"`
+	FamilyName *string            `json:"family_name,omitempty"`
+	DOB        *Date              `json:"dob,omitempty"`
+	Sex        *string            `json:"sex,omitempty"`
+	MRN        *string            `json:"mrn,omitempty"`
+	NHSNumber  *string            `json:"nhs_number,omitempty"`
+	SSN        *string            `json:"ssn,omitempty"`
+	Contact    *ContactInfo       `json:"contact,omitempty"`
+	Insurance  *InsuranceCoverage `json:"insurance,omitempty"`
+	Timezone   *string            `json:"timezone,omitempty"`
+}
+
+// PotentialDuplicate is another patient who may be the same person.
+type PotentialDuplicate struct {
+	Patient Patient           `json:"patient"`
+	Grade   string            `json:"grade"`          // "certain", "probable" or "possible".
+	Score   float64           `json:"score"`          // Sum of the field weights; higher is likelier the same person.
+	Rule    string            `json:"rule,omitempty"` // "nhs-number", "ssn" or "name-dob-phone" for certain matches.
+	Fields  []MatchFieldScore `json:"fields"`
+}
+
+// MatchFieldScore is how one field of a PotentialDuplicate compared.
+type MatchFieldScore struct {
+	Field      string  `json:"field"`      // e.g. "dob" or "phoneNumber".
+	Comparison string  `json:"comparison"` // "agree", "partial", "disagree" or "missing".
+	Weight     float64 `json:"weight"`
+}
+
+// PatientMergeResult is the patient kept by PatientsService.Merge and how many
+// records of each resource moved to it.
+type PatientMergeResult struct {
+	Patient         Patient        `json:"patient"`
+	SourcePatientID string         `json:"source_patient_id"`
+	Moved           map[string]int `json:"moved"` // e.g. {"observations": 212}.
+}
+
+// PatientsService manages patient records under /patients.
+type PatientsService service
+
+// PatientListOptions filter PatientsService.List.
+type PatientListOptions struct {
+	ListOptions
+	Search
+	IncludeDeleted bool // Also list deleted records; administrators only.
+}
+
+// Create registers a patient. The MRN must not be in use.
+func (s *PatientsService) Create(ctx context.Context, patient *PatientCreate) (*Patient, error) {
+	return call[Patient](ctx, s.client, http.MethodPost, "patients", nil, patient)
+}
+
+// List pages through all patients, by family name unless sorted otherwise;
+// opts may be nil. They can be filtered by name (matching either given or
+// family name, with Contains or StartsWith), givenName, familyName, dob,
+// sex, mrn, createdAt and updatedAt, and sorted by givenName, familyName,
+// dob, createdAt or updatedAt.
+func (s *PatientsService) List(opts *PatientListOptions) *Pager[Patient] {
+	o := deref(opts)
+	q := query{}.setBool("includeDeleted", o.IncludeDeleted).setSearch(o.Search)
+	return newPager[Patient](s.client, "patients", url.Values(q), o.ListOptions)
+}
+
+// Get returns a patient by ID.
+func (s *PatientsService) Get(ctx context.Context, patientID string) (*Patient, error) {
+	return call[Patient](ctx, s.client, http.MethodGet, path("patients", patientID), nil, nil)
+}
+
+// Update changes the fields of a patient that are set in update.
+func (s *PatientsService) Update(ctx context.Context, patientID string, update *PatientUpdate) (*Patient, error) {
+	return call[Patient](ctx, s.client, http.MethodPatch, path("patients", patientID), nil, update)
+}
+
+// Delete deletes a patient. The record is kept, marked deleted, so it can be
+// restored; its MRN stays in use.
+func (s *PatientsService) Delete(ctx context.Context, patientID string) error {
+	return s.client.do(ctx, http.MethodDelete, path("patients", patientID), nil, nil, nil)
+}
+
+// Restore undoes the deletion of a patient. Administrators only.
+func (s *PatientsService) Restore(ctx context.Context, patientID string) (*Patient, error) {
+	return call[Patient](ctx, s.client, http.MethodPost, path("patients", patientID, "restore"), nil, nil)
+}
+
+// PotentialDuplicates returns the patients who may be the same person as
+// patientID, certain matches first, then by score.
+func (s *PatientsService) PotentialDuplicates(ctx context.Context, patientID string) ([]PotentialDuplicate, error) {
+	return list[PotentialDuplicate](ctx, s.client, path("patients", patientID, "potential-duplicates"), nil)
+}
+
+// Merge moves every clinical record of the duplicate sourcePatientID to
+// patientID and deletes the duplicate. Administrators only.
+func (s *PatientsService) Merge(ctx context.Context, patientID, sourcePatientID string) (*PatientMergeResult, error) {
+	body := map[string]string{"source_patient_id": sourcePatientID}
+	return call[PatientMergeResult](ctx, s.client, http.MethodPost, path("patients", patientID, "merge"), nil, body)
+}
@@ -0,0 +1,87 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Practitioner is a clinician who sees patients.
+type Practitioner struct {
+	GivenName      string       `json:"given_name"`
+	FamilyName     string       `json:"family_name"`
+	NPI            string       `json:"npi"` // 10-digit National Provider Identifier.
+	Specialty      string       `json:"specialty,omitempty"`
+	Contact        *ContactInfo `json:"contact,omitempty"`
+	Active         bool         `json:"active,omitempty"`
+	PractitionerID string       `json:"practitioner_id"`
+	DeletedAt      *time.Time   `json:"deleted_at,omitempty"` // Set while the record is deleted; see Restore.
+	DeletedBy      string       `json:"deleted_by,omitempty"`
+	Version        int          `json:"version"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// PractitionerCreate is the body of PractitionersService.Create.
+type PractitionerCreate struct {
+	GivenName  string       `json:"given_name"`
+	FamilyName string       `json:"family_name"`
+	NPI        string       `json:"npi"` // 10-digit National Provider Identifier.
+	Specialty  string       `json:"specialty,omitempty"`
+	Contact    *ContactInfo `json:"contact,omitempty"`
+	Active     *bool        `json:"active,omitempty"`
+}
+
+// PractitionerUpdate is the body of PractitionersService.Update; only fields
+// that are set are changed.
+type PractitionerUpdate struct {
+	GivenName  *string      `json:"given_name,omitempty"`
+	FamilyName *string      `json:"family_name,omitempty"`
+	Specialty  *string      `json:"specialty,omitempty"`
+	Contact    *ContactInfo `json:"contact,omitempty"`
+	Active     *bool        `json:"active,omitempty"`
+}
+
+// PractitionersService manages practitioners under /practitioners.
+type PractitionersService service
+
+// PractitionerListOptions filter PractitionersService.List.
+type PractitionerListOptions struct {
+	ListOptions
+	Specialty      string
+	IncludeDeleted bool // Also list deleted records; administrators only.
+}
+
+// Create registers a practitioner. The NPI must not be in use.
+func (s *PractitionersService) Create(ctx context.Context, practitioner *PractitionerCreate) (*Practitioner, error) {
+	return call[Practitioner](ctx, s.client, http.MethodPost, "practitioners", nil, practitioner)
+}
+
+// List pages through practitioners; opts may be nil.
+func (s *PractitionersService) List(opts *PractitionerListOptions) *Pager[Practitioner] {
+	o := deref(opts)
+	q := query{}.setStr("specialty", o.Specialty).setBool("includeDeleted", o.IncludeDeleted)
+	return newPager[Practitioner](s.client, "practitioners", url.Values(q), o.ListOptions)
+}
+
+// Get returns a practitioner by ID.
+func (s *PractitionersService) Get(ctx context.Context, practitionerID string) (*Practitioner, error) {
+	return call[Practitioner](ctx, s.client, http.MethodGet, path("practitioners", practitionerID), nil, nil)
+}
+
+// Update changes the fields of a practitioner that are set in update.
+func (s *PractitionersService) Update(ctx context.Context, practitionerID string, update *PractitionerUpdate) (*Practitioner, error) {
+	return call[Practitioner](ctx, s.client, http.MethodPatch, path("practitioners", practitionerID), nil, update)
+}
+
+// Delete deletes a practitioner. The record is kept, marked deleted, so it can
+// be restored; its NPI stays in use.
+func (s *PractitionersService) Delete(ctx context.Context, practitionerID string) error {
+	return s.client.do(ctx, http.MethodDelete, path("practitioners", practitionerID), nil, nil, nil)
+}
+
+// Restore undoes the deletion of a practitioner. Administrators only.
+func (s *PractitionersService) Restore(ctx context.Context, practitionerID string) (*Practitioner, error) {
+	return call[Practitioner](ctx, s.client, http.MethodPost, path("practitioners", practitionerID, "restore"), nil, nil)
+}
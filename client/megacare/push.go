@@ -0,0 +1,82 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// PushToken is a device registered for a patient's push notifications. The
+// FCM token itself is never returned.
+type PushToken struct {
+	PushTokenID  string       `json:"push_token_id"` // Derived from the token; registering it again updates this record.
+	PatientID    string       `json:"patient_id"`
+	Platform     PushPlatform `json:"platform"`
+	AppVersion   string       `json:"app_version,omitempty"`
+	RegisteredAt time.Time    `json:"registered_at"` // When the app last registered the token.
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+// PushTokenCreate is the body of PushService.RegisterToken.
+type PushTokenCreate struct {
+	Token      string       `json:"token"` // The FCM registration token of the app installation.
+	Platform   PushPlatform `json:"platform"`
+	AppVersion string       `json:"app_version,omitempty"`
+}
+
+// PushPlatform is the kind of device a push token belongs to.
+type PushPlatform string
+
+const (
+	PushPlatformIOS     PushPlatform = "ios"
+	PushPlatformAndroid PushPlatform = "android"
+	PushPlatformWeb     PushPlatform = "web"
+)
+
+// PushPreferences are the categories of push notification a patient
+// receives; all are on until turned off.
+type PushPreferences struct {
+	Messages         bool `json:"messages"`          // E.g. a result being ready to view.
+	Appointments     bool `json:"appointments"`      // Confirmations and reminders.
+	AbnormalReadings bool `json:"abnormal_readings"` // Vital signs recorded outside their usual range.
+}
+
+// PushPreferencesUpdate is the body of PushService.UpdatePreferences; nil
+// fields are left unchanged.
+type PushPreferencesUpdate struct {
+	Messages         *bool `json:"messages,omitempty"`
+	Appointments     *bool `json:"appointments,omitempty"`
+	AbnormalReadings *bool `json:"abnormal_readings,omitempty"`
+}
+
+// PushService registers the patient app's devices for push notifications
+// and sets the categories a patient receives, under
+// /patients/{patientId}/push-tokens and /patients/{patientId}/push-preferences.
+type PushService service
+
+// RegisterToken registers an app installation's FCM token for the patient,
+// moving it from whoever registered it before.
+func (s *PushService) RegisterToken(ctx context.Context, patientID string, in *PushTokenCreate) (*PushToken, error) {
+	return call[PushToken](ctx, s.client, http.MethodPost, path("patients", patientID, "push-tokens"), nil, in)
+}
+
+// ListTokens returns the patient's registered devices, most recently registered first.
+func (s *PushService) ListTokens(ctx context.Context, patientID string) ([]PushToken, error) {
+	return list[PushToken](ctx, s.client, path("patients", patientID, "push-tokens"), nil)
+}
+
+// DeleteToken unregisters a device, e.g. when the patient signs out on it.
+func (s *PushService) DeleteToken(ctx context.Context, patientID, pushTokenID string) error {
+	return s.client.do(ctx, http.MethodDelete, path("patients", patientID, "push-tokens", pushTokenID), nil, nil, nil)
+}
+
+// Preferences returns the categories of push notification the patient receives.
+func (s *PushService) Preferences(ctx context.Context, patientID string) (*PushPreferences, error) {
+	return call[PushPreferences](ctx, s.client, http.MethodGet, path("patients", patientID, "push-preferences"), nil, nil)
+}
+
+// UpdatePreferences turns the categories set on in on or off.
+func (s *PushService) UpdatePreferences(ctx context.Context, patientID string, in *PushPreferencesUpdate) (*PushPreferences, error) {
+	return call[PushPreferences](ctx, s.client, http.MethodPatch, path("patients", patientID, "push-preferences"), nil, in)
+}
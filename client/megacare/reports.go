@@ -0,0 +1,137 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ReportOptions pick the period and organization of a report. From and To
+// are inclusive days in Timezone (the server's REPORTS_TIMEZONE when
+// empty); the last 30 days when unset. OrganizationID is for platform
+// administrators; without it they get rows for every organization.
+type ReportOptions struct {
+	From           *Date
+	To             *Date
+	Timezone       string
+	OrganizationID string
+}
+
+func (o ReportOptions) query() query {
+	return query{}.setDate("from", o.From).setDate("to", o.To).
+		setStr("timezone", o.Timezone).setStr("organizationId", o.OrganizationID)
+}
+
+// ReportPeriod is the period a report covers and when it was made.
+type ReportPeriod struct {
+	PeriodStart Date      `json:"period_start"`
+	PeriodEnd   Date      `json:"period_end"`
+	Timezone    string    `json:"timezone"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// AppointmentVolumeReport counts appointments per organization per day.
+type AppointmentVolumeReport struct {
+	ReportPeriod
+	Rows []AppointmentVolumeRow `json:"rows"`
+}
+
+// AppointmentVolumeRow counts the appointments starting on a day, by the
+// status they are in now. Days without appointments have no row.
+type AppointmentVolumeRow struct {
+	OrganizationID string `json:"organization_id,omitempty"`
+	Day            Date   `json:"day"`
+	Total          int    `json:"total"`
+	Booked         int    `json:"booked"`
+	Arrived        int    `json:"arrived"`
+	Fulfilled      int    `json:"fulfilled"`
+	Cancelled      int    `json:"cancelled"`
+}
+
+// NoShowRateReport is the share of ended appointments whose patient never came.
+type NoShowRateReport struct {
+	ReportPeriod
+	Rows []NoShowRateRow `json:"rows"`
+}
+
+// NoShowRateRow covers an organization, or one of its practitioners.
+type NoShowRateRow struct {
+	OrganizationID string   `json:"organization_id,omitempty"`
+	PractitionerID string   `json:"practitioner_id,omitempty"` // Set when grouped by practitioner.
+	Appointments   int      `json:"appointments"`              // Ended and not cancelled.
+	NoShows        int      `json:"no_shows"`                  // Still booked once ended.
+	NoShowRate     *float64 `json:"no_show_rate,omitempty"`    // Unset without appointments.
+}
+
+// TelemetryAdherenceReport is how regularly patients' devices send readings.
+type TelemetryAdherenceReport struct {
+	ReportPeriod
+	Rows []TelemetryAdherenceRow `json:"rows"`
+}
+
+// TelemetryAdherenceRow covers the patients of an organization who sent a
+// reading in the period.
+type TelemetryAdherenceRow struct {
+	OrganizationID   string   `json:"organization_id,omitempty"`
+	Code             string   `json:"code,omitempty"`
+	Patients         int      `json:"patients"`
+	AdherentPatients int      `json:"adherent_patients"`           // With readings on at least Threshold of the days.
+	AverageAdherence *float64 `json:"average_adherence,omitempty"` // Share of days with readings, averaged over Patients.
+	Threshold        float64  `json:"threshold"`
+}
+
+// TelemetryAdherenceOptions add the reading code and threshold to ReportOptions.
+type TelemetryAdherenceOptions struct {
+	ReportOptions
+	Code      string   // LOINC code; all readings when empty.
+	Threshold *float64 // 0.7 when unset.
+}
+
+// OpenRefillRequestReport counts the period's refill requests still waiting for a decision.
+type OpenRefillRequestReport struct {
+	ReportPeriod
+	Rows []OpenRefillRequestRow `json:"rows"`
+}
+
+// OpenRefillRequestRow covers an organization's open requests of one status.
+type OpenRefillRequestRow struct {
+	OrganizationID    string              `json:"organization_id,omitempty"`
+	Status            RefillRequestStatus `json:"status"`
+	Count             int                 `json:"count"`
+	OldestRequestedAt *time.Time          `json:"oldest_requested_at,omitempty"`
+	AverageAgeHours   *float64            `json:"average_age_hours,omitempty"`
+}
+
+// ReportsService reads the operational reports under /reports. The server
+// also renders them as CSV, which this client does not request.
+type ReportsService service
+
+// AppointmentVolume counts appointments per organization per day they start on.
+func (s *ReportsService) AppointmentVolume(ctx context.Context, opts *ReportOptions) (*AppointmentVolumeReport, error) {
+	q := deref(opts).query()
+	return call[AppointmentVolumeReport](ctx, s.client, http.MethodGet, path("reports", "appointment-volume"), url.Values(q), nil)
+}
+
+// NoShowRates returns the no-show rate per organization, or per
+// practitioner when byPractitioner is set.
+func (s *ReportsService) NoShowRates(ctx context.Context, opts *ReportOptions, byPractitioner bool) (*NoShowRateReport, error) {
+	q := deref(opts).query()
+	if byPractitioner {
+		q = q.setStr("groupBy", "practitioner")
+	}
+	return call[NoShowRateReport](ctx, s.client, http.MethodGet, path("reports", "no-show-rates"), url.Values(q), nil)
+}
+
+// TelemetryAdherence returns the average telemetry adherence per organization.
+func (s *ReportsService) TelemetryAdherence(ctx context.Context, opts *TelemetryAdherenceOptions) (*TelemetryAdherenceReport, error) {
+	o := deref(opts)
+	q := o.ReportOptions.query().setStr("code", o.Code).setFloat("threshold", o.Threshold)
+	return call[TelemetryAdherenceReport](ctx, s.client, http.MethodGet, path("reports", "telemetry-adherence"), url.Values(q), nil)
+}
+
+// OpenRefillRequests counts the period's open refill requests per organization and status.
+func (s *ReportsService) OpenRefillRequests(ctx context.Context, opts *ReportOptions) (*OpenRefillRequestReport, error) {
+	q := deref(opts).query()
+	return call[OpenRefillRequestReport](ctx, s.client, http.MethodGet, path("reports", "open-refill-requests"), url.Values(q), nil)
+}
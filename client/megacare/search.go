@@ -0,0 +1,81 @@
+package megacare
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter is one condition of a Search, built with Eq, Ne, Gt, Ge, Lt, Le,
+// Contains, StartsWith or AnyOf.
+type Filter struct {
+	Field string
+	Value string // The operator and value as sent, e.g. "ge:1980-01-01".
+}
+
+// Search filters and sorts the patients, appointments and observations
+// listed; the List method of each names the fields it takes. Every filter
+// must match.
+type Search struct {
+	Filters []Filter
+	Sort    string // A sortable field, descending with a leading "-", e.g. "-createdAt".
+}
+
+// Eq matches records whose field equals value.
+func Eq(field string, value any) Filter { return filter(field, "eq", value) }
+
+// Ne matches records whose field is set to something other than value.
+func Ne(field string, value any) Filter { return filter(field, "ne", value) }
+
+// Gt matches records whose date, time or number field is after value.
+func Gt(field string, value any) Filter { return filter(field, "gt", value) }
+
+// Ge matches records whose date, time or number field is value or after.
+func Ge(field string, value any) Filter { return filter(field, "ge", value) }
+
+// Lt matches records whose date, time or number field is before value.
+func Lt(field string, value any) Filter { return filter(field, "lt", value) }
+
+// Le matches records whose date, time or number field is value or before.
+func Le(field string, value any) Filter { return filter(field, "le", value) }
+
+// Contains matches records whose text field contains text, ignoring case.
+func Contains(field, text string) Filter { return filter(field, "contains", text) }
+
+// StartsWith matches records whose text field starts with text, ignoring case.
+func StartsWith(field, text string) Filter { return filter(field, "sw", text) }
+
+// AnyOf matches records whose code field is one of codes.
+func AnyOf(field string, codes ...string) Filter {
+	return Filter{Field: field, Value: "eq:" + strings.Join(codes, ",")}
+}
+
+func filter(field, op string, value any) Filter {
+	return Filter{Field: field, Value: op + ":" + filterValue(value)}
+}
+
+func filterValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case Date:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (q query) setSearch(s Search) query {
+	for _, f := range s.Filters {
+		url.Values(q).Add(f.Field, f.Value)
+	}
+	return q.setStr("sort", s.Sort)
+}
@@ -0,0 +1,101 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TelehealthSession is the video visit of an appointment, and what happened
+// in it as reported by the video provider.
+type TelehealthSession struct {
+	TelehealthSessionID string                   `json:"telehealth_session_id"`
+	AppointmentID       string                   `json:"appointment_id"`
+	PatientID           string                   `json:"patient_id"`
+	PractitionerID      string                   `json:"practitioner_id"`
+	Provider            string                   `json:"provider"` // "twilio", "daily" or "livekit".
+	RoomName            string                   `json:"room_name"`
+	RoomURL             string                   `json:"room_url,omitempty"`
+	Status              TelehealthSessionStatus  `json:"status"`
+	StartedAt           *time.Time               `json:"started_at,omitempty"` // When the patient and a clinician were first both in the room.
+	EndedAt             *time.Time               `json:"ended_at,omitempty"`
+	DurationSeconds     *int                     `json:"duration_seconds,omitempty"` // From StartedAt to EndedAt; the billed duration.
+	EndedBy             string                   `json:"ended_by,omitempty"`         // The user who ended it, or "provider".
+	Events              []TelehealthSessionEvent `json:"events"`
+	Version             int                      `json:"version"`
+	CreatedAt           time.Time                `json:"created_at"`
+	UpdatedAt           time.Time                `json:"updated_at"`
+}
+
+// TelehealthSessionEvent is a token issued for a visit, a participant
+// joining or leaving it, or its end.
+type TelehealthSessionEvent struct {
+	Type            string    `json:"type"` // "token_issued", "participant_joined", "participant_left" or "ended".
+	At              time.Time `json:"at"`
+	Identity        string    `json:"identity,omitempty"` // "patient-<patientId>" or "staff-<uid>".
+	Role            string    `json:"role,omitempty"`     // "patient" or "staff".
+	ProviderEventID string    `json:"provider_event_id,omitempty"`
+}
+
+// TelehealthSessionStatus is where a visit is.
+type TelehealthSessionStatus string
+
+const (
+	TelehealthSessionStatusWaiting TelehealthSessionStatus = "waiting" // Until the patient and a clinician have both joined.
+	TelehealthSessionStatusActive  TelehealthSessionStatus = "active"
+	TelehealthSessionStatusEnded   TelehealthSessionStatus = "ended"
+)
+
+// TelehealthJoinToken lets the caller into a visit's room with the video
+// provider's client SDK until ExpiresAt.
+type TelehealthJoinToken struct {
+	Token     string    `json:"token"`
+	Provider  string    `json:"provider"`
+	RoomName  string    `json:"room_name"`
+	RoomURL   string    `json:"room_url,omitempty"`
+	Identity  string    `json:"identity"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TelehealthSessionListOptions filter TelehealthService.List; PatientID or
+// AppointmentID is required.
+type TelehealthSessionListOptions struct {
+	PatientID     string
+	AppointmentID string
+	Status        TelehealthSessionStatus
+}
+
+// TelehealthService opens video visits for appointments and issues the
+// tokens to join them, under /telehealth/sessions.
+type TelehealthService service
+
+// Create opens the video visit of a booked appointment.
+func (s *TelehealthService) Create(ctx context.Context, appointmentID string) (*TelehealthSession, error) {
+	body := map[string]string{"appointment_id": appointmentID}
+	return call[TelehealthSession](ctx, s.client, http.MethodPost, path("telehealth", "sessions"), nil, body)
+}
+
+// List returns the visits of a patient or an appointment, newest first.
+func (s *TelehealthService) List(ctx context.Context, opts *TelehealthSessionListOptions) ([]TelehealthSession, error) {
+	o := deref(opts)
+	q := query{}.setStr("patientId", o.PatientID).setStr("appointmentId", o.AppointmentID).setStr("status", string(o.Status))
+	return list[TelehealthSession](ctx, s.client, path("telehealth", "sessions"), url.Values(q))
+}
+
+// Get returns a visit by ID.
+func (s *TelehealthService) Get(ctx context.Context, sessionID string) (*TelehealthSession, error) {
+	return call[TelehealthSession](ctx, s.client, http.MethodGet, path("telehealth", "sessions", sessionID), nil, nil)
+}
+
+// JoinToken issues the caller a short-lived token to join the visit, as the
+// patient or as staff depending on who the caller is.
+func (s *TelehealthService) JoinToken(ctx context.Context, sessionID string) (*TelehealthJoinToken, error) {
+	return call[TelehealthJoinToken](ctx, s.client, http.MethodPost, path("telehealth", "sessions", sessionID, "tokens"), nil, nil)
+}
+
+// End closes the visit's room and fixes its duration. Only clinicians may end a visit.
+func (s *TelehealthService) End(ctx context.Context, sessionID string) (*TelehealthSession, error) {
+	return call[TelehealthSession](ctx, s.client, http.MethodPost, path("telehealth", "sessions", sessionID, "end"), nil, nil)
+}
@@ -0,0 +1,106 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Code systems the server checks codes of.
+const (
+	SystemLOINC    = "http://loinc.org"
+	SystemSNOMEDCT = "http://snomed.info/sct"
+	SystemICD10    = "http://hl7.org/fhir/sid/icd-10"
+)
+
+// CodeLookup is a concept of a code system.
+type CodeLookup struct {
+	System   string   `json:"system"`
+	Code     string   `json:"code"`
+	Display  string   `json:"display,omitempty"`
+	Version  string   `json:"version,omitempty"`
+	Inactive bool     `json:"inactive"`
+	Parents  []string `json:"parents,omitempty"` // Codes the concept is a kind of, as far as the server knows.
+}
+
+// CodeVerification says how far a code could be checked.
+type CodeVerification string
+
+const (
+	CodeVerificationConcept CodeVerification = "concept" // The code was found.
+	CodeVerificationSyntax  CodeVerification = "syntax"  // Only its form (e.g. check digit) was checked.
+	CodeVerificationNone    CodeVerification = "none"    // Nothing is known about the code system.
+)
+
+// CodeValidation is the answer of TerminologyService.ValidateCode. Result is
+// false only when the code is known not to be valid.
+type CodeValidation struct {
+	Result       bool             `json:"result"`
+	Verification CodeVerification `json:"verification"`
+	System       string           `json:"system"`
+	Code         string           `json:"code"`
+	Display      string           `json:"display,omitempty"` // The code's preferred display, when it was found.
+	Inactive     bool             `json:"inactive"`
+	Message      string           `json:"message,omitempty"`
+}
+
+// ValidateCodeOptions add checks to TerminologyService.ValidateCode.
+type ValidateCodeOptions struct {
+	Display  string // Also check that this is a display of the code.
+	ValueSet string // Also check that the code is in this value set (ID or canonical URL).
+}
+
+// ValueSet describes a value set the server holds.
+type ValueSet struct {
+	ID          string `json:"id"`
+	URL         string `json:"url"`
+	Name        string `json:"name,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// ValueSetExpansion is a page of a value set's active codes.
+type ValueSetExpansion struct {
+	ID       string   `json:"id"`
+	URL      string   `json:"url"`
+	Total    int      `json:"total"` // Codes matching the filter, across every page.
+	Offset   int      `json:"offset"`
+	Contains []Coding `json:"contains"`
+}
+
+// ExpandOptions filter and page TerminologyService.Expand.
+type ExpandOptions struct {
+	Filter string // Codes starting with, or displays containing, this text.
+	Offset int
+	Count  int // 100 when zero; at most 1000.
+}
+
+// TerminologyService looks up and validates codes under /terminology.
+type TerminologyService service
+
+// Lookup returns the concept a code names. IsNotFound reports codes the
+// server does not know.
+func (s *TerminologyService) Lookup(ctx context.Context, system, code string) (*CodeLookup, error) {
+	q := query{}.setStr("system", system).setStr("code", code)
+	return call[CodeLookup](ctx, s.client, http.MethodGet, path("terminology", "lookup"), url.Values(q), nil)
+}
+
+// ValidateCode checks a code the way the server checks coded fields on writes.
+func (s *TerminologyService) ValidateCode(ctx context.Context, system, code string, opts *ValidateCodeOptions) (*CodeValidation, error) {
+	o := deref(opts)
+	q := query{}.setStr("system", system).setStr("code", code).setStr("display", o.Display).setStr("valueSet", o.ValueSet)
+	return call[CodeValidation](ctx, s.client, http.MethodGet, path("terminology", "validate-code"), url.Values(q), nil)
+}
+
+// ValueSets returns the value sets the server holds.
+func (s *TerminologyService) ValueSets(ctx context.Context) ([]ValueSet, error) {
+	return list[ValueSet](ctx, s.client, path("terminology", "value-sets"), nil)
+}
+
+// Expand returns a page of a value set's active codes.
+func (s *TerminologyService) Expand(ctx context.Context, valueSetID string, opts *ExpandOptions) (*ValueSetExpansion, error) {
+	o := deref(opts)
+	q := query{}.setStr("filter", o.Filter).setInt("offset", o.Offset).setInt("count", o.Count)
+	return call[ValueSetExpansion](ctx, s.client, http.MethodGet, path("terminology", "value-sets", valueSetID, "expand"), url.Values(q), nil)
+}
@@ -0,0 +1,61 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SearchKind is a kind of record full-text search finds.
+type SearchKind string
+
+const (
+	SearchKindEncounter       SearchKind = "encounter"
+	SearchKindCarePlan        SearchKind = "care-plan"
+	SearchKindAllergy         SearchKind = "allergy"
+	SearchKindPatientDocument SearchKind = "patient-document"
+)
+
+// SearchHit is a record matching a full-text search.
+type SearchHit struct {
+	Kind       SearchKind          `json:"kind"`
+	ID         string              `json:"id"`
+	PatientID  string              `json:"patient_id"`
+	Subject    string              `json:"subject"` // The record's path relative to /api/v1, e.g. "encounters/4f2a...".
+	Title      string              `json:"title"`
+	RecordedAt time.Time           `json:"recorded_at"`
+	Score      float64             `json:"score"`      // Comparable only within one response.
+	Highlights map[string][]string `json:"highlights"` // HTML-escaped fragments with the matched words in <mark> tags, by field ("title", "text").
+}
+
+// SearchResults is a page of full-text search matches, best first.
+type SearchResults struct {
+	Total  int         `json:"total"` // Matches across every page.
+	Offset int         `json:"offset"`
+	Hits   []SearchHit `json:"hits"`
+}
+
+// TextSearchOptions narrow and page TextSearchService.Search.
+type TextSearchOptions struct {
+	Kinds     []SearchKind // Only these kinds of record.
+	PatientID string       // Only this patient's records.
+	Limit     int          // 20 when zero; at most 100.
+	Offset    int
+}
+
+// TextSearchService searches the text of clinical records under /search.
+// The server must have a search index (SEARCH_INDEX); it answers 503 otherwise.
+type TextSearchService service
+
+// Search finds the encounters, care plans, allergies and patient documents
+// the caller may read whose text matches every word of text, the last one
+// as a prefix.
+func (s *TextSearchService) Search(ctx context.Context, text string, opts *TextSearchOptions) (*SearchResults, error) {
+	o := deref(opts)
+	q := query{}.setStr("q", text).setStr("patientId", o.PatientID).setInt("limit", o.Limit).setInt("offset", o.Offset)
+	for _, kind := range o.Kinds {
+		url.Values(q).Add("kind", string(kind))
+	}
+	return call[SearchResults](ctx, s.client, http.MethodGet, path("search"), url.Values(q), nil)
+}
@@ -0,0 +1,175 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WebhookSubscription sends events to an HTTPS endpoint.
+type WebhookSubscription struct {
+	SubscriptionID string      `json:"subscription_id"`
+	URL            string      `json:"url"`
+	EventTypes     []EventType `json:"event_types"`
+	Description    string      `json:"description,omitempty"`
+	Active         bool        `json:"active,omitempty"`
+	CreatedBy      string      `json:"created_by"`
+	Version        int         `json:"version"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+// WebhookSubscriptionWithSecret is a subscription with its signing secret, as
+// returned when it is created or its secret rotated.
+type WebhookSubscriptionWithSecret struct {
+	WebhookSubscription
+	Secret string `json:"secret"` // Key for verifying the X-MegaCare-Signature header. Store it securely; it is not shown again.
+}
+
+// WebhookSubscriptionCreate is the body of WebhooksService.Create.
+type WebhookSubscriptionCreate struct {
+	URL         string      `json:"url"` // HTTPS endpoint that receives a POST per event.
+	EventTypes  []EventType `json:"event_types"`
+	Description string      `json:"description,omitempty"`
+}
+
+// WebhookSubscriptionUpdate is the body of WebhooksService.Update.
+type WebhookSubscriptionUpdate struct {
+	URL         *string     `json:"url,omitempty"`
+	EventTypes  []EventType `json:"event_types,omitempty"`
+	Description *string     `json:"description,omitempty"`
+	Active      *bool       `json:"active,omitempty"` // Paused subscriptions receive no new deliveries.
+}
+
+// WebhookDelivery is the delivery of one event to one subscription.
+type WebhookDelivery struct {
+	DeliveryID     string                `json:"delivery_id"`
+	SubscriptionID string                `json:"subscription_id"`
+	EventID        string                `json:"event_id"`
+	EventType      EventType             `json:"event_type"`
+	Event          map[string]any        `json:"event"` // The event envelope, sent as the request body.
+	Status         WebhookDeliveryStatus `json:"status,omitempty"`
+	Attempts       int                   `json:"attempts,omitempty"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at"`
+	ResponseStatus *int                  `json:"response_status,omitempty"` // HTTP status of the last attempt, if a response was received.
+	LastError      string                `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+// WebhookDeliveryStatus is the status of a webhook delivery.
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "failed"
+)
+
+// EventType is the type of a webhook event, e.g. "patient.created".
+type EventType string
+
+const (
+	EventTypePatientCreated             EventType = "patient.created"
+	EventTypePatientUpdated             EventType = "patient.updated"
+	EventTypePatientDeleted             EventType = "patient.deleted"
+	EventTypePatientRestored            EventType = "patient.restored"
+	EventTypePatientMerged              EventType = "patient.merged"
+	EventTypePractitionerCreated        EventType = "practitioner.created"
+	EventTypePractitionerUpdated        EventType = "practitioner.updated"
+	EventTypePractitionerDeleted        EventType = "practitioner.deleted"
+	EventTypePractitionerRestored       EventType = "practitioner.restored"
+	EventTypeCareTeamCreated            EventType = "care_team.created"
+	EventTypeCareTeamUpdated            EventType = "care_team.updated"
+	EventTypeCareTeamDeleted            EventType = "care_team.deleted"
+	EventTypeCareTeamRestored           EventType = "care_team.restored"
+	EventTypeAppointmentBooked          EventType = "appointment.booked"
+	EventTypeAppointmentRescheduled     EventType = "appointment.rescheduled"
+	EventTypeAppointmentCancelled       EventType = "appointment.cancelled"
+	EventTypeAppointmentStatusChanged   EventType = "appointment.status_changed"
+	EventTypeAppointmentReminderDue     EventType = "appointment.reminder_due"
+	EventTypeEncounterOpened            EventType = "encounter.opened"
+	EventTypeEncounterUpdated           EventType = "encounter.updated"
+	EventTypeEncounterStatusChanged     EventType = "encounter.status_changed"
+	EventTypeObservationRecorded        EventType = "observation.recorded"
+	EventTypeLabResultRecorded          EventType = "lab_result.recorded"
+	EventTypeCarePlanCreated            EventType = "care_plan.created"
+	EventTypeCarePlanUpdated            EventType = "care_plan.updated"
+	EventTypeCarePlanStatusChanged      EventType = "care_plan.status_changed"
+	EventTypeCarePlanDeleted            EventType = "care_plan.deleted"
+	EventTypeCarePlanRestored           EventType = "care_plan.restored"
+	EventTypeMedicationPrescribed       EventType = "medication.prescribed"
+	EventTypeMedicationUpdated          EventType = "medication.updated"
+	EventTypeRefillRequestCreated       EventType = "refill_request.created"
+	EventTypeRefillRequestStatusChanged EventType = "refill_request.status_changed"
+	EventTypeAllergyRecorded            EventType = "allergy.recorded"
+	EventTypeAllergyUpdated             EventType = "allergy.updated"
+	EventTypeAllergyDeleted             EventType = "allergy.deleted"
+	EventTypeAllergyRestored            EventType = "allergy.restored"
+	EventTypeImmunizationRecorded       EventType = "immunization.recorded"
+	EventTypeImmunizationUpdated        EventType = "immunization.updated"
+	EventTypeDocumentUploaded           EventType = "document.uploaded"
+	EventTypeDocumentQuarantined        EventType = "document.quarantined"
+	EventTypeDocumentDeleted            EventType = "document.deleted"
+	EventTypeWoundImageRecorded         EventType = "wound_image.recorded"
+	EventTypeWoundImageDeleted          EventType = "wound_image.deleted"
+	EventTypeConsentGranted             EventType = "consent.granted"
+	EventTypeConsentRevoked             EventType = "consent.revoked"
+	EventTypeConsentDocumentPublished   EventType = "consent_document.published"
+)
+
+// WebhooksService manages webhook subscriptions under /webhooks. It needs the
+// integration-admin role.
+type WebhooksService service
+
+// WebhookDeliveryListOptions filter WebhooksService.Deliveries.
+type WebhookDeliveryListOptions struct {
+	ListOptions
+	Status WebhookDeliveryStatus
+}
+
+// Create subscribes an HTTPS endpoint to one or more event types. The
+// response includes the signing secret, which is not returned again.
+func (s *WebhooksService) Create(ctx context.Context, subscription *WebhookSubscriptionCreate) (*WebhookSubscriptionWithSecret, error) {
+	return call[WebhookSubscriptionWithSecret](ctx, s.client, http.MethodPost, "webhooks", nil, subscription)
+}
+
+// List pages through webhook subscriptions; opts may be nil.
+func (s *WebhooksService) List(opts *ListOptions) *Pager[WebhookSubscription] {
+	return newPager[WebhookSubscription](s.client, "webhooks", nil, deref(opts))
+}
+
+// Get returns a webhook subscription by ID.
+func (s *WebhooksService) Get(ctx context.Context, subscriptionID string) (*WebhookSubscription, error) {
+	return call[WebhookSubscription](ctx, s.client, http.MethodGet, path("webhooks", subscriptionID), nil, nil)
+}
+
+// Update changes a webhook subscription, e.g. to pause it.
+func (s *WebhooksService) Update(ctx context.Context, subscriptionID string, update *WebhookSubscriptionUpdate) (*WebhookSubscription, error) {
+	return call[WebhookSubscription](ctx, s.client, http.MethodPatch, path("webhooks", subscriptionID), nil, update)
+}
+
+// Delete deletes a webhook subscription.
+func (s *WebhooksService) Delete(ctx context.Context, subscriptionID string) error {
+	return s.client.do(ctx, http.MethodDelete, path("webhooks", subscriptionID), nil, nil, nil)
+}
+
+// RotateSecret replaces the signing secret of a subscription and returns the
+// new one.
+func (s *WebhooksService) RotateSecret(ctx context.Context, subscriptionID string) (*WebhookSubscriptionWithSecret, error) {
+	return call[WebhookSubscriptionWithSecret](ctx, s.client, http.MethodPost, path("webhooks", subscriptionID, "rotate-secret"), nil, nil)
+}
+
+// Deliveries pages through the deliveries of a subscription; opts may be nil.
+func (s *WebhooksService) Deliveries(subscriptionID string, opts *WebhookDeliveryListOptions) *Pager[WebhookDelivery] {
+	o := deref(opts)
+	q := query{}.setStr("status", string(o.Status))
+	return newPager[WebhookDelivery](s.client, path("webhooks", subscriptionID, "deliveries"), url.Values(q), o.ListOptions)
+}
+
+// RetryDelivery schedules a failed delivery to be sent again.
+func (s *WebhooksService) RetryDelivery(ctx context.Context, subscriptionID, deliveryID string) (*WebhookDelivery, error) {
+	return call[WebhookDelivery](ctx, s.client, http.MethodPost, path("webhooks", subscriptionID, "deliveries", deliveryID, "retry"), nil, nil)
+}
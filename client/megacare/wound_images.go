@@ -0,0 +1,109 @@
+package megacare
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WoundImage is a wound-care photo's details. The photo itself is
+// downloaded with WoundImagesService.Original.
+type WoundImage struct {
+	ImageID           string    `json:"image_id"`
+	PatientID         string    `json:"patient_id"`
+	WoundID           string    `json:"wound_id"`
+	BodySite          string    `json:"body_site,omitempty"`
+	TakenAt           time.Time `json:"taken_at"`
+	LengthMm          *float64  `json:"length_mm,omitempty"`
+	WidthMm           *float64  `json:"width_mm,omitempty"`
+	Note              string    `json:"note,omitempty"`
+	ContentType       string    `json:"content_type"` // image/jpeg or image/png.
+	Width             int       `json:"width"`
+	Height            int       `json:"height"`
+	Size              int64     `json:"size"` // Size of the stored photo in bytes.
+	OriginalBlobName  string    `json:"original_blob_name"`
+	ThumbnailBlobName string    `json:"thumbnail_blob_name"`
+	Encrypted         bool      `json:"encrypted"` // Whether the stored photo is encrypted.
+	UploadedBy        string    `json:"uploaded_by"`
+	Version           int       `json:"version"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// WoundImageView is a photo in a wound's series, with a signed URL of its
+// thumbnail.
+type WoundImageView struct {
+	WoundImage
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// WoundImageSeries is a wound's photos, oldest first, to compare its healing
+// over time.
+type WoundImageSeries struct {
+	PatientID             string           `json:"patient_id"`
+	WoundID               string           `json:"wound_id"`
+	BodySite              string           `json:"body_site,omitempty"`
+	Images                []WoundImageView `json:"images"`
+	FirstTakenAt          time.Time        `json:"first_taken_at"`
+	LastTakenAt           time.Time        `json:"last_taken_at"`
+	AreaChangePercent     *float64         `json:"area_change_percent,omitempty"` // Change of length x width from the first to the latest measured photo.
+	ThumbnailURLsExpireAt time.Time        `json:"thumbnail_urls_expire_at"`
+}
+
+// WoundImageUpload describes a photo sent to WoundImagesService.Upload.
+type WoundImageUpload struct {
+	WoundID     string    // Required; the wound's photos form its series.
+	ContentType string    // image/jpeg or image/png.
+	TakenAt     time.Time // Now if zero.
+	BodySite    string
+	LengthMm    *float64
+	WidthMm     *float64
+	Note        string
+}
+
+// WoundImagesService uploads wound-care photos under
+// /patients/{patientId}/wound-images. The API removes their EXIF metadata,
+// including any GPS position, before storing them.
+type WoundImagesService service
+
+// Upload sends a JPEG or PNG photo.
+func (s *WoundImagesService) Upload(ctx context.Context, patientID string, upload *WoundImageUpload, photo []byte) (*WoundImage, error) {
+	u := deref(upload)
+	q := query{}.setStr("woundId", u.WoundID).setTime("takenAt", u.TakenAt).setStr("bodySite", u.BodySite).
+		setFloat("lengthMm", u.LengthMm).setFloat("widthMm", u.WidthMm).setStr("note", u.Note)
+	body := rawBody{contentType: u.ContentType, data: photo}
+	return call[WoundImage](ctx, s.client, http.MethodPost, path("patients", patientID, "wound-images"), url.Values(q), body)
+}
+
+// List returns a patient's wound photos, oldest first; woundID may be empty
+// for the photos of every wound.
+func (s *WoundImagesService) List(ctx context.Context, patientID, woundID string) ([]WoundImage, error) {
+	q := query{}.setStr("woundId", woundID)
+	return list[WoundImage](ctx, s.client, path("patients", patientID, "wound-images"), url.Values(q))
+}
+
+// Get returns a photo's details by ID.
+func (s *WoundImagesService) Get(ctx context.Context, patientID, imageID string) (*WoundImage, error) {
+	return call[WoundImage](ctx, s.client, http.MethodGet, path("patients", patientID, "wound-images", imageID), nil, nil)
+}
+
+// Original downloads the full-size photo, in the format of
+// WoundImage.ContentType.
+func (s *WoundImagesService) Original(ctx context.Context, patientID, imageID string) ([]byte, error) {
+	var photo []byte
+	if err := s.client.do(ctx, http.MethodGet, path("patients", patientID, "wound-images", imageID, "original"), nil, nil, &photo); err != nil {
+		return nil, err
+	}
+	return photo, nil
+}
+
+// Series returns a wound's photos with short-lived thumbnail URLs.
+func (s *WoundImagesService) Series(ctx context.Context, patientID, woundID string) (*WoundImageSeries, error) {
+	return call[WoundImageSeries](ctx, s.client, http.MethodGet, path("patients", patientID, "wounds", woundID, "series"), nil, nil)
+}
+
+// Delete removes a photo and its thumbnail. This cannot be undone.
+func (s *WoundImagesService) Delete(ctx context.Context, patientID, imageID string) error {
+	return s.client.do(ctx, http.MethodDelete, path("patients", patientID, "wound-images", imageID), nil, nil, nil)
+}
@@ -0,0 +1,55 @@
+# Gunicorn configuration for running the API on Cloud Run.
+# Loaded via `gunicorn -c gunicorn.conf.py app.main:app` (see Dockerfile).
+import os
+
+# --- Binding & Workers ---
+# Cloud Run injects the port to listen on through the PORT environment variable.
+bind = f"0.0.0.0:{os.getenv('PORT', '8080')}"
+workers = int(os.getenv("WEB_CONCURRENCY", "4"))
+# Header size and concurrency limits are applied by the custom worker class.
+worker_class = "app.core.server.MegaCareUvicornWorker"
+
+# --- Timeouts ---
+# A worker that does not report back to the master within `timeout` seconds
+# (e.g. blocked on a hung downstream call) is killed and restarted.
+timeout = int(os.getenv("SERVER_WORKER_TIMEOUT_SECONDS", "60"))
+# Seconds an idle keep-alive connection is held open. Cloud Run's front end
+# reuses connections, so this only needs to cover short gaps between requests.
+keepalive = int(os.getenv("SERVER_KEEPALIVE_SECONDS", "5"))
+# Recycle workers periodically to bound memory growth; jitter avoids all
+# workers restarting at once.
+max_requests = int(os.getenv("SERVER_MAX_REQUESTS", "0"))
+max_requests_jitter = int(os.getenv("SERVER_MAX_REQUESTS_JITTER", "0"))
+
+# --- Graceful Shutdown ---
+# When an instance is scaled down, Cloud Run sends SIGTERM and waits 10 seconds
+# before sending SIGKILL. Gunicorn forwards SIGTERM to the workers, which stop
+# accepting new connections and let in-flight requests finish for up to
+# `graceful_timeout` seconds. Keep this below Cloud Run's 10 second window so
+# workers exit cleanly instead of being killed mid-request.
+graceful_timeout = int(os.getenv("SHUTDOWN_TIMEOUT_SECONDS", "8"))
+
+
+def on_starting(server):
+    server.log.info(f"Starting Gunicorn with {workers} workers on {bind}.")
+    # Start every container with an empty Prometheus multiprocess directory.
+    metrics_dir = os.getenv("PROMETHEUS_MULTIPROC_DIR")
+    if metrics_dir:
+        os.makedirs(metrics_dir, exist_ok=True)
+        for name in os.listdir(metrics_dir):
+            os.remove(os.path.join(metrics_dir, name))
+
+
+def worker_exit(server, worker):
+    server.log.info(f"Worker {worker.pid} exited after draining in-flight requests.")
+
+
+def child_exit(server, worker):
+    # Drop the exited worker's live gauges from the shared Prometheus directory.
+    if os.getenv("PROMETHEUS_MULTIPROC_DIR"):
+        from prometheus_client import multiprocess
+        multiprocess.mark_process_dead(worker.pid)
+
+
+def on_exit(server):
+    server.log.info("Gunicorn master has shut down.")
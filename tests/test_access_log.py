@@ -0,0 +1,117 @@
+import asyncio
+import logging
+import pytest
+
+from app.core.access_log import ACCESS_LOGGER, SampleRule, http_request_entry, parse_sample_rules, sample_rate
+from app.middleware import access_log
+from app.middleware.access_log import AccessLogMiddleware
+
+# --- Test Setup ---
+
+class RecordingHandler(logging.Handler):
+    def __init__(self):
+        super().__init__()
+        self.records = []
+
+    def emit(self, record):
+        self.records.append(record)
+
+
+@pytest.fixture
+def records():
+    """Captures what is written to the access logger."""
+    handler = RecordingHandler()
+    logger = logging.getLogger(ACCESS_LOGGER)
+    logger.addHandler(handler)
+    logger.setLevel(logging.INFO)
+    yield handler.records
+    logger.removeHandler(handler)
+
+
+def make_app(status=200, body=b'{"ok": true}'):
+    async def app(scope, receive, send):
+        await receive()
+        await send({"type": "http.response.start", "status": status, "headers": []})
+        await send({"type": "http.response.body", "body": body})
+    return app
+
+
+def call(middleware, path="/api/v1/patients/p-1", state=None, headers=None):
+    scope = {
+        "type": "http", "method": "POST", "path": path, "http_version": "1.1", "client": ("10.0.0.9", 5000),
+        "headers": [(k.encode(), v.encode()) for k, v in (headers or {}).items()], "state": state or {},
+    }
+    messages = iter([{"type": "http.request", "body": b'{"a": 1}', "more_body": False}])
+
+    async def receive():
+        return next(messages)
+
+    async def send(message):
+        pass
+
+    asyncio.run(middleware(scope, receive, send))
+
+# --- Sample Rule Test Cases ---
+
+def test_parse_sample_rules_orders_longest_prefix_first():
+    """Tests that rules are parsed and matched by their longest prefix, with unmatched routes logged in full."""
+    rules = parse_sample_rules("/api/v1=0.5, /api/v1/devices/=0.05,/healthz=0")
+
+    assert rules[0] == SampleRule("/api/v1/devices", 0.05)
+    assert sample_rate(rules, "/api/v1/devices/d-1/telemetry") == 0.05
+    assert sample_rate(rules, "/api/v1/patients") == 0.5
+    assert sample_rate(rules, "/healthz") == 0
+    assert sample_rate(rules, "/fhir/Patient") == 1.0
+
+def test_parse_sample_rules_rejects_malformed_entries():
+    """Tests that rates outside 0 to 1 and prefixes without a slash are refused."""
+    for spec in ("/api=2", "api=0.5", "/api=often"):
+        with pytest.raises(ValueError):
+            parse_sample_rules(spec)
+
+# --- Middleware Test Cases ---
+
+def test_request_is_logged_once_with_http_request(records, monkeypatch):
+    """Tests that a request produces one entry with its httpRequest, route, caller and sizes."""
+    monkeypatch.setattr(access_log, "route_template", lambda scope: "/api/v1/patients/{patientId}")
+
+    call(
+        AccessLogMiddleware(make_app(status=201), rules=[]),
+        path="/api/v1/patients/p-1",
+        state={"principal": {"uid": "clinician-1"}},
+        headers={"user-agent": "ehr-engine/2.1", "x-forwarded-for": "198.51.100.9, 203.0.113.7"},
+    )
+
+    [record] = records
+    assert record.getMessage().startswith("POST /api/v1/patients/{patientId} 201 ")
+    assert record.json_fields == {"route": "/api/v1/patients/{patientId}", "principal": "clinician-1"}
+    assert record.labels == {"log": "access"}
+    request = record.http_request
+    assert (request["requestMethod"], request["requestUrl"], request["status"]) == ("POST", "/api/v1/patients/p-1", 201)
+    assert (request["requestSize"], request["responseSize"]) == ("8", "12")
+    assert (request["remoteIp"], request["userAgent"], request["protocol"]) == ("203.0.113.7", "ehr-engine/2.1", "HTTP/1.1")
+    assert request["latency"].endswith("s")
+
+def test_sampled_routes_still_log_errors(records):
+    """Tests that a route sampled at 0 logs nothing for successes but still logs failures."""
+    rules = parse_sample_rules("/healthz=0")
+
+    call(AccessLogMiddleware(make_app(), rules=rules), path="/healthz")
+    call(AccessLogMiddleware(make_app(status=503), rules=rules), path="/healthz")
+
+    assert [r.http_request["status"] for r in records] == [503]
+
+def test_calling_service_is_the_principal_of_internal_requests(records):
+    """Tests that requests from internal services are logged with the service account."""
+    call(AccessLogMiddleware(make_app(), rules=[]), path="/internal/services/v1/patients", state={"service_caller": {"email": "reports@megacare.iam.gserviceaccount.com"}})
+
+    assert records[0].json_fields["principal"] == "reports@megacare.iam.gserviceaccount.com"
+
+def test_http_request_entry_leaves_out_missing_fields():
+    """Tests that optional HttpRequest fields are omitted rather than sent empty."""
+    entry = http_request_entry("GET", "/fhir/metadata", 200, 0.0125, 0, 512)
+
+    assert entry == {
+        "requestMethod": "GET", "requestUrl": "/fhir/metadata", "status": 200,
+        "requestSize": "0", "responseSize": "512", "latency": "0.012500s",
+    }
@@ -0,0 +1,148 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_allergy_repository, get_patient_repository
+from app.api.v1.endpoints import allergies
+from app.dependencies.auth import get_current_user
+from app.repositories.allergies import AllergyRepository, FirestoreAllergyRepository
+from app.repositories.patients import PatientRepository
+from app.services.allergies import find_allergy_conflicts
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(allergies.router, prefix="/api/v1/patients", tags=["Allergies"])
+app.dependency_overrides[get_current_user] = lambda: {"uid": "clinician-uid-123"}
+client = TestClient(app)
+
+FAKE_PATIENT_ID = "patient-1"
+FAKE_ALLERGY_ID = "allergy-1"
+RXNORM = "http://www.nlm.nih.gov/research/umls/rxnorm"
+PENICILLIN = {"system": RXNORM, "code": "7980", "display": "Penicillin G"}
+
+def make_allergy(allergy_id=FAKE_ALLERGY_ID, criticality="low", clinical_status="active", verification_status="confirmed"):
+    return schemas.Allergy.model_validate({
+        "allergyId": allergy_id,
+        "patientId": FAKE_PATIENT_ID,
+        "substance": PENICILLIN,
+        "category": "medication",
+        "criticality": criticality,
+        "clinicalStatus": clinical_status,
+        "verificationStatus": verification_status,
+        "recordedBy": "clinician-uid-123",
+        "createdAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+    })
+
+@pytest.fixture
+def repos():
+    """Overrides the allergy and patient repositories with mocks."""
+    mocks = {
+        "allergies": MagicMock(spec=AllergyRepository),
+        "patients": MagicMock(spec=PatientRepository),
+    }
+    app.dependency_overrides[get_allergy_repository] = lambda: mocks["allergies"]
+    app.dependency_overrides[get_patient_repository] = lambda: mocks["patients"]
+    yield mocks
+    for dep in (get_allergy_repository, get_patient_repository):
+        app.dependency_overrides.pop(dep, None)
+
+# --- Endpoint Test Cases ---
+
+def test_create_allergy_success(repos):
+    """Tests recording an allergy with a reaction."""
+    repos["allergies"].list.return_value = []
+    repos["allergies"].create.return_value = make_allergy()
+
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/allergies", json={
+        "substance": PENICILLIN,
+        "criticality": "high",
+        "reactions": [{"manifestations": [{"system": "http://snomed.info/sct", "code": "126485001"}], "severity": "moderate"}],
+    })
+
+    assert response.status_code == 201
+    assert response.json()["allergy_id"] == FAKE_ALLERGY_ID
+    assert repos["allergies"].create.call_args.kwargs["recorded_by"] == "clinician-uid-123"
+
+def test_create_allergy_duplicate_substance(repos):
+    """Tests 409 when the substance is already recorded for the patient."""
+    repos["allergies"].list.return_value = [make_allergy()]
+
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/allergies", json={"substance": PENICILLIN})
+
+    assert response.status_code == 409
+    repos["allergies"].create.assert_not_called()
+
+def test_create_allergy_rejects_invalid_criticality(repos):
+    """Tests 422 for values outside the criticality value set."""
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/allergies", json={"substance": PENICILLIN, "criticality": "extreme"})
+
+    assert response.status_code == 422
+
+def test_list_allergies_by_substance(repos):
+    """Tests that the substance filter is passed through to the repository."""
+    repos["allergies"].list.return_value = [make_allergy()]
+
+    response = client.get(f"/api/v1/patients/{FAKE_PATIENT_ID}/allergies?substance=7980&system={RXNORM}")
+
+    assert response.status_code == 200
+    repos["allergies"].list.assert_called_once_with(FAKE_PATIENT_ID, substance_code="7980", substance_system=RXNORM, clinical_status=None)
+
+def test_list_allergies_system_requires_substance(repos):
+    """Tests 400 when a code system is given without a code."""
+    response = client.get(f"/api/v1/patients/{FAKE_PATIENT_ID}/allergies?system={RXNORM}")
+
+    assert response.status_code == 400
+
+def test_get_allergy_of_other_patient_returns_404(repos):
+    """Tests that a record is only visible under its own patient."""
+    repos["allergies"].get.return_value = make_allergy()
+
+    response = client.get(f"/api/v1/patients/someone-else/allergies/{FAKE_ALLERGY_ID}")
+
+    assert response.status_code == 404
+
+def test_delete_allergy(repos):
+    """Tests deleting an allergy record."""
+    repos["allergies"].get.return_value = make_allergy()
+
+    response = client.delete(f"/api/v1/patients/{FAKE_PATIENT_ID}/allergies/{FAKE_ALLERGY_ID}")
+
+    assert response.status_code == 204
+    repos["allergies"].delete.assert_called_once_with(FAKE_ALLERGY_ID, deleted_by="clinician-uid-123")
+
+# --- Safety Check Test Cases ---
+
+def test_find_allergy_conflicts_ignores_refuted_and_orders_by_criticality():
+    """Tests that only relevant records are returned, high criticality first."""
+    repo = MagicMock(spec=AllergyRepository)
+    repo.list.return_value = [
+        make_allergy("a-1", criticality="low"),
+        make_allergy("a-2", verification_status="refuted"),
+        make_allergy("a-3", criticality="high"),
+    ]
+
+    conflicts = find_allergy_conflicts(repo, FAKE_PATIENT_ID, [schemas.Coding(**PENICILLIN)])
+
+    assert [a.allergy_id for a in conflicts] == ["a-3", "a-1"]
+    repo.list.assert_called_once_with(FAKE_PATIENT_ID, substance_code="7980", substance_system=RXNORM, clinical_status="active")
+
+# --- Firestore Repository Test Cases ---
+
+def test_firestore_list_filters_by_substance():
+    """Tests that substance lookups query the nested substance code and system."""
+    mock_db = MagicMock()
+    query = mock_db.collection.return_value.where.return_value
+    query.where.return_value = query
+    query.limit.return_value.stream.return_value = []
+
+    repo = FirestoreAllergyRepository(mock_db)
+    repo.list(FAKE_PATIENT_ID, substance_code="7980", substance_system=RXNORM)
+
+    fields = [c.kwargs["filter"].field_path for c in query.where.call_args_list]
+    assert fields == ["substance.code", "substance.system"]
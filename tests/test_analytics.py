@@ -0,0 +1,188 @@
+import json
+from datetime import datetime, timezone
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.analytics.export import AnalyticsExporter, AnalyticsRecorder, AnalyticsSink, AnalyticsSinkError
+from app.analytics.publisher import AnalyticsEventPublisher
+from app.analytics.rows import event_row, pseudonym, request_row
+from app.analytics.schema import SCHEMA_VERSION, TABLE_SCHEMA
+from app.audit.context import set_actor
+from app.events.envelope import Event
+from app.events.publisher import EventPublisher
+from app.middleware.analytics import UsageAnalyticsMiddleware
+from app.middleware.audit import AuditMiddleware
+from app.repositories.transactions import unit_of_work
+
+# --- Test Setup ---
+
+KEY = "test-pseudonym-key"
+NOW = datetime(2026, 10, 1, 9, 0, tzinfo=timezone.utc)
+
+class RecordingPublisher(EventPublisher):
+    def __init__(self):
+        self.events = []
+
+    def publish(self, event: Event) -> None:
+        self.events.append(event)
+
+class FlakySink(AnalyticsSink):
+    def __init__(self, failures: int = 0):
+        self.failures = failures
+        self.batches = []
+
+    def write(self, rows):
+        if self.failures:
+            self.failures -= 1
+            raise AnalyticsSinkError("unavailable")
+        self.batches.append(rows)
+
+class NullAuditRepository:
+    def append(self, event):
+        pass
+
+def booked(**data):
+    appointment = {
+        "appointmentId": "a-1", "patientId": "p-1", "practitionerId": "pr-1", "appointmentType": "follow-up",
+        "status": "booked", "reason": "Mask leaks at night", "start": "2026-10-02T09:00:00Z", "end": "2026-10-02T09:30:00Z",
+    }
+    appointment.update(data)
+    return Event(type="appointment.booked", subject="appointments/a-1", data=appointment, occurredAt=NOW)
+
+# --- Row Test Cases ---
+
+def test_event_rows_keep_only_listed_fields_and_pseudonymise_ids():
+    """Tests that a domain event's row carries pseudonyms instead of IDs, the listed fields and durations, and no free text or dates."""
+    row = event_row(KEY, booked())
+
+    assert row["schema_version"] == SCHEMA_VERSION
+    assert row["category"] == "clinical_operations" and row["event_type"] == "appointment.booked"
+    assert row["resource"] == "appointment"
+    assert row["patient_key"] == pseudonym(KEY, "p-1") != "p-1"
+    assert row["resource_key"] == pseudonym(KEY, "a-1")
+    assert json.loads(row["attributes"]) == {"appointmentType": "follow-up", "status": "booked", "durationMinutes": 30}
+    assert "p-1" not in json.dumps(row) and "Mask leaks" not in json.dumps(row)
+
+def test_pseudonyms_depend_on_the_key():
+    """Tests that the same ID has one pseudonym per key, so rows join but cannot be traced back without it."""
+    assert pseudonym(KEY, "p-1") == pseudonym(KEY, "p-1")
+    assert pseudonym(KEY, "p-1") != pseudonym("another-key", "p-1")
+    assert pseudonym(KEY, None) is None
+
+def test_nested_codes_are_read_and_objects_dropped():
+    """Tests that dotted attributes read into codings, and that non-scalar values are never exported."""
+    event = Event(
+        type="observation.recorded", subject="patients/p-1/observations/o-1", occurredAt=NOW,
+        data={"patientId": "p-1", "code": {"system": "http://loinc.org", "code": "59408-5", "display": "SpO2"}, "status": "final", "value": 91, "unit": "%"},
+    )
+
+    attributes = json.loads(event_row(KEY, event)["attributes"])
+
+    assert attributes == {"code.system": "http://loinc.org", "code.code": "59408-5", "status": "final", "unit": "%"}
+
+def test_request_rows_name_the_route_not_the_path():
+    """Tests that a usage row carries the route template, outcome and the caller by pseudonym and roles."""
+    row = request_row(KEY, NOW, "/api/v1/patients/{patientId}", "GET", 200, 12.34567, "uid-1", ["clinician"], "org-1")
+
+    assert row["category"] == "usage" and row["event_type"] == "api.request"
+    assert row["route"] == "/api/v1/patients/{patientId}" and row["status_code"] == 200
+    assert row["duration_ms"] == 12.346
+    assert row["actor_key"] == pseudonym(KEY, "uid-1") and row["actor_roles"] == ["clinician"]
+    assert row["organization_id"] == "org-1"
+
+def test_rows_match_the_table_schema():
+    """Tests that every row column is in the table schema, and every required column is set."""
+    columns = {field["name"] for field in TABLE_SCHEMA}
+    required = {field["name"] for field in TABLE_SCHEMA if field["mode"] == "REQUIRED"}
+    for row in (event_row(KEY, booked()), request_row(KEY, NOW, "/", "GET", 200, 1.0, None, [], None)):
+        assert set(row) <= columns
+        assert all(row[name] is not None for name in required)
+
+# --- Publisher Test Cases ---
+
+def test_rows_are_queued_once_the_change_commits():
+    """Tests that an event's row is queued when its unit of work ends, and the event is still passed on."""
+    inner, recorder = RecordingPublisher(), AnalyticsRecorder(10)
+    publisher = AnalyticsEventPublisher(inner, recorder, KEY)
+
+    with unit_of_work():
+        publisher.emit("appointment.booked", "appointments/a-1", patientId="p-1")
+        assert recorder.take(10) == []
+
+    assert [row["event_type"] for row in recorder.take(10)] == ["appointment.booked"]
+    assert [event.type for event in inner.events] == ["appointment.booked"]
+
+def test_rows_of_rolled_back_changes_are_not_queued():
+    """Tests that a unit of work that fails drops the rows of the events emitted in it."""
+    recorder = AnalyticsRecorder(10)
+    publisher = AnalyticsEventPublisher(RecordingPublisher(), recorder, KEY)
+
+    with pytest.raises(RuntimeError):
+        with unit_of_work():
+            publisher.emit("appointment.booked", "appointments/a-1", patientId="p-1")
+            raise RuntimeError("write failed")
+
+    assert recorder.take(10) == []
+
+def test_a_full_queue_drops_rows_instead_of_blocking():
+    """Tests that rows recorded beyond the queue size are dropped."""
+    recorder = AnalyticsRecorder(2)
+    for _ in range(3):
+        recorder.record(event_row(KEY, booked()))
+
+    assert len(recorder.take(10)) == 2
+
+# --- Exporter Test Cases ---
+
+def test_refused_batches_are_tried_again_then_dropped():
+    """Tests that the exporter retries a batch the sink refused, and gives it up after the last attempt."""
+    recorder, sink = AnalyticsRecorder(10), FlakySink(failures=1)
+    exporter = AnalyticsExporter(recorder, sink, batch_size=10, max_attempts=2)
+    recorder.record(event_row(KEY, booked()))
+
+    assert exporter.drain() == 0
+    assert exporter.drain() == 1
+    assert len(sink.batches) == 1
+
+    sink.failures = 2
+    recorder.record(event_row(KEY, booked()))
+    exporter.drain()
+    exporter.drain()
+    assert exporter.drain() == 0 and len(sink.batches) == 1
+
+def test_flush_exports_what_is_left():
+    """Tests that flushing on shutdown writes every queued row in batches."""
+    recorder, sink = AnalyticsRecorder(10), FlakySink()
+    for _ in range(3):
+        recorder.record(event_row(KEY, booked()))
+
+    AnalyticsExporter(recorder, sink, batch_size=2).flush()
+
+    assert [len(batch) for batch in sink.batches] == [2, 1]
+
+# --- Middleware Test Cases ---
+
+usage_app = FastAPI()
+usage_recorder = AnalyticsRecorder(100)
+
+@usage_app.get("/api/v1/patients/{patientId}")
+def read_patient(patientId: str):
+    set_actor({"uid": "uid-1", "roles": ["clinician"]})
+    return {"patientId": patientId}
+
+usage_app.add_middleware(UsageAnalyticsMiddleware, recorder_factory=lambda: usage_recorder, pseudonym_key=KEY)
+usage_app.add_middleware(AuditMiddleware, repository_factory=NullAuditRepository)
+
+def test_requests_are_recorded_by_route():
+    """Tests that a request is recorded with its route template and the caller named by the audit context."""
+    usage_recorder.take(100)
+    TestClient(usage_app).get("/api/v1/patients/p-1")
+    TestClient(usage_app).get("/nowhere")
+
+    rows = usage_recorder.take(100)
+
+    assert [row["route"] for row in rows] == ["/api/v1/patients/{patientId}"]
+    assert rows[0]["status_code"] == 200
+    assert rows[0]["actor_key"] == pseudonym(KEY, "uid-1") and rows[0]["actor_roles"] == ["clinician"]
@@ -0,0 +1,226 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+from fastapi import Depends, FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_api_key_repository
+from app.api.v1.endpoints import api_keys
+from app.auth.api_keys import (
+    API_KEY_HEADER,
+    ApiKeyVerifier,
+    KeyRateLimiter,
+    format_key,
+    hash_secret,
+    new_key_id,
+    new_secret,
+    parse_key,
+    scope_for,
+)
+from app.auth.verifier import InvalidTokenError
+from app.dependencies.auth import get_current_user
+from app.middleware.authentication import AuthenticationMiddleware
+from app.repositories.api_keys import ApiKeyRepository
+from app.repositories.memory import MemoryApiKeyRepository, MemoryStore
+
+# --- Test Setup ---
+
+ADMIN_USER = {"uid": "admin-uid", "roles": ["integration-admin"]}
+NOW = datetime(2024, 5, 1, 9, 0, tzinfo=timezone.utc)
+KEY_ID = "0123456789abcdef"
+SECRET = "s" * 43
+
+def make_key_in(**overrides):
+    data = {"name": "Acme Sleep Lab backend", "scopes": ["patients:read"]}
+    data.update(overrides)
+    return schemas.ApiKeyCreate.model_validate(data)
+
+def make_record(status="active", secret=SECRET, **overrides):
+    data = {
+        "keyId": KEY_ID,
+        "name": "Acme Sleep Lab backend",
+        "scopes": ["patients:read"],
+        "status": status,
+        "secretHash": hash_secret(secret),
+        "createdBy": "admin-uid",
+        "createdAt": NOW,
+        "updatedAt": NOW,
+    }
+    data.update(overrides)
+    return schemas.ApiKeyRecord.model_validate(data)
+
+def make_verifier(repo, clock=lambda: 0.0):
+    return ApiKeyVerifier(lambda: repo, cache_seconds=60, clock=clock)
+
+# --- Key Format Test Cases ---
+
+def test_generated_keys_round_trip():
+    """Tests that a formatted key parses back into its ID and secret, and malformed keys do not parse."""
+    key_id, secret = new_key_id(), new_secret()
+
+    assert parse_key(format_key(key_id, secret)) == (key_id, secret)
+    assert parse_key("mck_short_secret") is None
+    assert parse_key(f"sk_{key_id}_{secret}") is None
+
+def test_scopes_are_validated_and_normalised():
+    """Tests that scopes must name a resource and an access, and duplicates are dropped."""
+    assert make_key_in(scopes=["patients:read", "appointments:write", "patients:read"]).scopes == ["appointments:write", "patients:read"]
+    for scopes in [[], ["patients"], ["patients:delete"], ["Patients:read"]]:
+        with pytest.raises(ValueError):
+            make_key_in(scopes=scopes)
+
+def test_scope_for_maps_method_and_first_path_segment():
+    """Tests that reads need the resource's read scope, other methods its write scope, and subresources the parent's."""
+    assert scope_for("GET", "/api/v1/patients") == "patients:read"
+    assert scope_for("HEAD", "/api/v1/patients/p-1") == "patients:read"
+    assert scope_for("GET", "/api/v1/patients/p-1/observations") == "patients:read"
+    assert scope_for("PATCH", "/api/v1/care-plans/cp-1") == "care-plans:write"
+    assert scope_for("GET", "/api/v1") is None
+
+# --- Verifier Test Cases ---
+
+def test_verifier_accepts_matching_secret_only():
+    """Tests that a key verifies with its secret and yields scope-bearing claims without roles."""
+    store = MemoryStore()
+    repo = MemoryApiKeyRepository(store)
+    repo.create(KEY_ID, make_key_in(), hash_secret(SECRET), "admin-uid")
+    verifier = make_verifier(repo)
+
+    record, claims = verifier.verify(format_key(KEY_ID, SECRET))
+
+    assert record.key_id == KEY_ID
+    assert claims["uid"] == f"api-key:{KEY_ID}"
+    assert claims["roles"] == []
+    assert claims["scopes"] == ["patients:read"]
+    for raw in [format_key(KEY_ID, "x" * 43), format_key("fedcba9876543210", SECRET), "not-a-key"]:
+        with pytest.raises(InvalidTokenError):
+            verifier.verify(raw)
+
+def test_verifier_refuses_revoked_key_once_cache_expires():
+    """Tests that a revoked key keeps working only until its cached record expires."""
+    repo = MagicMock(spec=ApiKeyRepository)
+    repo.get.return_value = make_record()
+    now = [0.0]
+    verifier = make_verifier(repo, clock=lambda: now[0])
+    verifier.verify(format_key(KEY_ID, SECRET))
+
+    repo.get.return_value = make_record(status="revoked")
+    verifier.verify(format_key(KEY_ID, SECRET))
+    now[0] += 61
+
+    with pytest.raises(InvalidTokenError):
+        verifier.verify(format_key(KEY_ID, SECRET))
+
+def test_verifier_accepts_rotated_key_immediately():
+    """Tests that a key that does not match its cached record is looked up again."""
+    repo = MagicMock(spec=ApiKeyRepository)
+    repo.get.return_value = make_record()
+    verifier = make_verifier(repo)
+    verifier.verify(format_key(KEY_ID, SECRET))
+
+    rotated = "r" * 43
+    repo.get.return_value = make_record(secret=rotated)
+
+    record, _ = verifier.verify(format_key(KEY_ID, rotated))
+    assert record.secret_hash == hash_secret(rotated)
+    assert repo.get.call_count == 2
+
+# --- Rate Limiter Test Cases ---
+
+def test_rate_limiter_counts_per_key_and_minute():
+    """Tests that requests over the limit are refused until the next minute, independently per key."""
+    now = [120.0]
+    limiter = KeyRateLimiter(clock=lambda: now[0])
+
+    assert limiter.allow("key-1", 2) == (True, 60)
+    assert limiter.allow("key-1", 2)[0]
+    now[0] += 15
+    assert limiter.allow("key-1", 2) == (False, 45)
+    assert limiter.allow("key-2", 2)[0]
+    now[0] += 45
+    assert limiter.allow("key-1", 2)[0]
+
+# --- Middleware Test Cases ---
+
+store = MemoryStore()
+middleware_repo = MemoryApiKeyRepository(store)
+middleware_repo.create(KEY_ID, make_key_in(rateLimitPerMinute=2), hash_secret(SECRET), "admin-uid")
+
+middleware_app = FastAPI()
+middleware_app.add_middleware(AuthenticationMiddleware, api_key_verifier_factory=lambda: make_verifier(middleware_repo))
+
+@middleware_app.get("/api/v1/patients")
+def list_patients(current_user=Depends(get_current_user)):
+    return {"uid": current_user["uid"]}
+
+@middleware_app.post("/api/v1/patients")
+def create_patient(current_user=Depends(get_current_user)):
+    return {"uid": current_user["uid"]}
+
+middleware_client = TestClient(middleware_app)
+
+def test_middleware_enforces_key_scopes_and_rate_limit():
+    """Tests that a key reaches handlers within its scopes, gets 403 outside them and 429 over its limit."""
+    headers = {API_KEY_HEADER: format_key(KEY_ID, SECRET)}
+
+    allowed = middleware_client.get("/api/v1/patients", headers=headers)
+    out_of_scope = middleware_client.post("/api/v1/patients", headers=headers)
+    middleware_client.get("/api/v1/patients", headers=headers)
+    limited = middleware_client.get("/api/v1/patients", headers=headers)
+    forged = middleware_client.get("/api/v1/patients", headers={API_KEY_HEADER: format_key(KEY_ID, "x" * 43)})
+
+    assert allowed.status_code == 200
+    assert allowed.json() == {"uid": f"api-key:{KEY_ID}"}
+    assert out_of_scope.status_code == 403
+    assert limited.status_code == 429
+    assert "Retry-After" in limited.headers
+    assert forged.status_code == 401
+
+# --- Endpoint Test Cases ---
+
+app = FastAPI()
+app.include_router(api_keys.router, prefix="/api/v1/admin/api-keys", tags=["API Keys"])
+client = TestClient(app)
+
+@pytest.fixture
+def repo():
+    """Overrides the API key repository with an in-memory one and signs in an integration admin."""
+    repo = MemoryApiKeyRepository(MemoryStore())
+    app.dependency_overrides[get_api_key_repository] = lambda: repo
+    app.dependency_overrides[get_current_user] = lambda: ADMIN_USER
+    yield repo
+    app.dependency_overrides.pop(get_api_key_repository, None)
+    app.dependency_overrides.pop(get_current_user, None)
+
+def test_create_returns_key_once_and_stores_hash(repo):
+    """Tests that the key is returned on create, verifies against the stored hash, and is not shown on read."""
+    created = client.post("/api/v1/admin/api-keys", json={"name": "Acme", "scopes": ["patients:read"]})
+    key_id = created.json()["key_id"]
+    fetched = client.get(f"/api/v1/admin/api-keys/{key_id}")
+
+    assert created.status_code == 201
+    assert make_verifier(repo).verify(created.json()["key"])[0].key_id == key_id
+    assert "key" not in fetched.json()
+    assert "secret_hash" not in fetched.json()
+
+def test_rotate_and_revoke(repo):
+    """Tests that rotation issues a new key for the same ID, and revoked keys cannot be revoked or rotated again."""
+    key_id = client.post("/api/v1/admin/api-keys", json={"name": "Acme", "scopes": ["patients:read"]}).json()["key_id"]
+
+    rotated = client.post(f"/api/v1/admin/api-keys/{key_id}/rotate")
+    revoked = client.post(f"/api/v1/admin/api-keys/{key_id}/revoke")
+
+    assert rotated.status_code == 200
+    assert parse_key(rotated.json()["key"])[0] == key_id
+    assert revoked.json()["status"] == "revoked"
+    assert revoked.json()["revoked_by"] == "admin-uid"
+    assert client.post(f"/api/v1/admin/api-keys/{key_id}/revoke").status_code == 409
+    assert client.post(f"/api/v1/admin/api-keys/{key_id}/rotate").status_code == 409
+    assert client.post("/api/v1/admin/api-keys/missing/revoke").status_code == 404
+
+def test_api_keys_require_integration_admin(repo):
+    """Tests that users without the integration-admin role are refused."""
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "clinician-uid", "roles": ["clinician"]}
+
+    assert client.get("/api/v1/admin/api-keys").status_code == 403
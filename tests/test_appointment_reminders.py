@@ -0,0 +1,174 @@
+from datetime import datetime, timedelta, timezone
+from unittest.mock import MagicMock
+from zoneinfo import ZoneInfo
+
+from app.api.v1 import schemas
+from app.reminders import scheduling
+from app.reminders.scheduling import ReminderScheduler, reminder_times, send_reminder
+from app.repositories.memory import (
+    MemoryAppointmentReminderRepository,
+    MemoryAppointmentRepository,
+    MemoryPatientRepository,
+    MemoryPractitionerRepository,
+    MemoryStore,
+)
+from app.scheduler import jobs as cron_jobs
+from app.tasks import jobs
+
+# --- Test Setup ---
+
+BANGKOK = ZoneInfo("Asia/Bangkok")
+QUIET = (21, 8)
+
+def local(tz, *args):
+    return datetime(*args, tzinfo=tz).astimezone(timezone.utc)
+
+def use_store(monkeypatch, store):
+    """Points the reminder functions at memory repositories on `store`."""
+    monkeypatch.setattr(scheduling, "get_appointment_reminder_repository", lambda: MemoryAppointmentReminderRepository(store))
+    monkeypatch.setattr(scheduling, "get_appointment_repository", lambda: MemoryAppointmentRepository(store))
+    monkeypatch.setattr(scheduling, "get_patient_repository", lambda: MemoryPatientRepository(store))
+    monkeypatch.setattr(scheduling, "get_practitioner_repository", lambda: MemoryPractitionerRepository(store))
+
+def book(store, start, timezone_name="Asia/Bangkok"):
+    patient = MemoryPatientRepository(store).create(schemas.PatientCreate(
+        givenName="Ann", familyName="Lee", dob="1980-01-01", mrn="MRN-1", timezone=timezone_name,
+    ))
+    appointment = MemoryAppointmentRepository(store).create(schemas.AppointmentCreate(
+        patientId=patient.patient_id, practitionerId="prac-1", start=start, end=start + timedelta(minutes=30),
+    ))
+    return patient, appointment
+
+def make_scheduler(store, max_delay_seconds=30 * 24 * 3600):
+    tasks = MagicMock()
+    tasks.max_delay_seconds = max_delay_seconds
+    return ReminderScheduler(MemoryAppointmentReminderRepository(store), tasks, [48, 2], QUIET), tasks
+
+# --- Reminder Time Test Cases ---
+
+def test_reminders_are_due_their_offset_before_the_appointment():
+    """Tests that each offset gives a reminder at that many hours before the start, longest first."""
+    start = local(BANGKOK, 2026, 10, 20, 14, 0)
+    now = start - timedelta(days=7)
+
+    assert reminder_times(start, BANGKOK, [2, 48], QUIET, now) == [
+        (48, start - timedelta(hours=48)),
+        (2, start - timedelta(hours=2)),
+    ]
+
+def test_reminders_due_at_night_move_to_the_evening_before():
+    """Tests that a reminder due in the patient's quiet hours is sent when they begin instead."""
+    start = local(BANGKOK, 2026, 10, 20, 9, 0)
+    now = start - timedelta(days=7)
+
+    [(_, day_before), (_, early)] = reminder_times(start, BANGKOK, [48, 2], QUIET, now)
+
+    assert day_before == local(BANGKOK, 2026, 10, 18, 9, 0)
+    assert early == local(BANGKOK, 2026, 10, 19, 21, 0)
+
+def test_quiet_hours_are_the_patients_local_night():
+    """Tests that the same instant is moved for a patient whose night it is, and not for one whose day it is."""
+    start = datetime(2026, 10, 20, 5, 0, tzinfo=timezone.utc)
+    now = start - timedelta(days=7)
+
+    assert reminder_times(start, BANGKOK, [2], QUIET, now) == [(2, start - timedelta(hours=2))]
+    new_york = ZoneInfo("America/New_York")
+    assert reminder_times(start, new_york, [2], QUIET, now) == [(2, local(new_york, 2026, 10, 19, 21, 0))]
+
+def test_past_and_duplicate_reminders_are_dropped():
+    """Tests that reminders already due are not scheduled, nor one quiet hours move onto another's time."""
+    start = local(BANGKOK, 2026, 10, 20, 7, 0)
+
+    assert reminder_times(start, BANGKOK, [3, 2], QUIET, start - timedelta(days=1)) == [(3, local(BANGKOK, 2026, 10, 19, 21, 0))]
+    assert reminder_times(start, BANGKOK, [48, 2], None, start - timedelta(hours=3)) == [(2, start - timedelta(hours=2))]
+
+# --- Scheduling Test Cases ---
+
+def test_booking_records_and_queues_each_reminder():
+    """Tests that reminders are recorded and queued for their time under a stable task ID."""
+    store = MemoryStore()
+    patient, appointment = book(store, datetime.now(timezone.utc).replace(microsecond=0) + timedelta(days=5))
+    scheduler, tasks = make_scheduler(store)
+
+    reminders = scheduler.schedule(appointment, patient)
+
+    assert [r.offset_hours for r in reminders] == [48, 2]
+    assert all(r.status == "scheduled" and r.appointment_start == appointment.start for r in reminders)
+    first = tasks.enqueue.call_args_list[0]
+    assert first.args == (jobs.APPOINTMENT_REMINDER_TASK, {"reminderId": reminders[0].reminder_id})
+    assert first.kwargs["task_id"] == f"appointment-reminder-{reminders[0].reminder_id}"
+    assert 0 < first.kwargs["delay_seconds"] <= 3 * 24 * 3600
+
+def test_reminders_beyond_the_queue_delay_are_left_to_the_sweep():
+    """Tests that reminders the queue cannot delay that long are recorded but not queued."""
+    store = MemoryStore()
+    patient, appointment = book(store, datetime.now(timezone.utc) + timedelta(days=5))
+    scheduler, tasks = make_scheduler(store, max_delay_seconds=0)
+
+    assert len(scheduler.schedule(appointment, patient)) == 2
+    tasks.enqueue.assert_not_called()
+
+def test_rescheduling_cancels_pending_reminders():
+    """Tests that a reschedule cancels the old reminders, and their tasks, and schedules the new time."""
+    store = MemoryStore()
+    patient, appointment = book(store, datetime.now(timezone.utc) + timedelta(days=5))
+    scheduler, tasks = make_scheduler(store)
+    old = scheduler.schedule(appointment, patient)
+
+    moved = MemoryAppointmentRepository(store).update(appointment.appointment_id, {"start": appointment.start + timedelta(days=1), "end": appointment.end + timedelta(days=1)})
+    new = scheduler.reschedule(moved, patient)
+
+    reminders = {r.reminder_id: r for r in MemoryAppointmentReminderRepository(store).list(appointment.appointment_id)}
+    assert [reminders[r.reminder_id].status for r in old] == ["cancelled", "cancelled"]
+    assert reminders[old[0].reminder_id].reason == "appointment rescheduled"
+    assert {c.args[0] for c in tasks.cancel.call_args_list} == {f"appointment-reminder-{r.reminder_id}" for r in old}
+    assert all(r.appointment_start == moved.start for r in new)
+    assert scheduler.cancel(appointment.appointment_id, "appointment cancelled") == 2
+
+# --- Sending Test Cases ---
+
+def test_a_due_reminder_is_sent_once(monkeypatch):
+    """Tests that a reminder is claimed and sent, and a second attempt (task or sweep) sends nothing."""
+    store = MemoryStore()
+    use_store(monkeypatch, store)
+    patient, appointment = book(store, datetime.now(timezone.utc) + timedelta(days=5))
+    [reminder, _] = make_scheduler(store)[0].schedule(appointment, patient)
+    notifier = MagicMock()
+
+    sent = send_reminder(reminder.reminder_id, notifier)
+
+    assert sent.status == "sent" and sent.finished_at is not None
+    template, to, context = notifier.notify.call_args.args
+    assert (template, to.patient_id, context["start"]) == ("appointment-reminder", patient.patient_id, appointment.start.isoformat())
+    assert send_reminder(reminder.reminder_id, notifier) is None
+    assert notifier.notify.call_count == 1
+
+def test_reminders_of_moved_or_cancelled_appointments_are_skipped(monkeypatch):
+    """Tests that a reminder whose appointment has changed since it was scheduled is skipped, not sent."""
+    store = MemoryStore()
+    use_store(monkeypatch, store)
+    patient, appointment = book(store, datetime.now(timezone.utc) + timedelta(days=5))
+    [day_before, same_day] = make_scheduler(store)[0].schedule(appointment, patient)
+    appointments = MemoryAppointmentRepository(store)
+    notifier = MagicMock()
+
+    appointments.update(appointment.appointment_id, {"start": appointment.start + timedelta(hours=1), "end": appointment.end + timedelta(hours=1)})
+    assert send_reminder(day_before.reminder_id, notifier).reason == "appointment was moved"
+    appointments.update(appointment.appointment_id, {"status": "cancelled"})
+    assert send_reminder(same_day.reminder_id, notifier).status == "skipped"
+    notifier.notify.assert_not_called()
+
+def test_sweep_sends_reminders_that_are_due(monkeypatch):
+    """Tests that the sweep job sends due reminders and leaves later ones scheduled."""
+    store = MemoryStore()
+    use_store(monkeypatch, store)
+    reminders = MemoryAppointmentReminderRepository(store)
+    patient, appointment = book(store, datetime.now(timezone.utc) + timedelta(hours=1))
+    due = reminders.create(appointment, 2, datetime.now(timezone.utc) - timedelta(minutes=1))
+    later = reminders.create(appointment, 1, datetime.now(timezone.utc) + timedelta(minutes=30))
+    monkeypatch.setattr(cron_jobs, "get_appointment_reminder_repository", lambda: reminders)
+    monkeypatch.setattr(cron_jobs, "get_notifier", lambda tasks: MagicMock())
+
+    assert cron_jobs.send_due_appointment_reminders() == {"remindersSent": 1, "remindersSkipped": 0}
+    assert reminders.get(due.reminder_id).status == "sent"
+    assert reminders.get(later.reminder_id).status == "scheduled"
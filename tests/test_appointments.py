@@ -0,0 +1,217 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_appointment_repository, get_patient_repository, get_practitioner_repository
+from app.api.v1.endpoints import appointments
+from app.dependencies.auth import get_current_user
+from app.repositories.appointments import AppointmentRepository, FirestoreAppointmentRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.practitioners import PractitionerRepository
+from app.services.appointments import InvalidTransitionError, ensure_transition
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(appointments.router, prefix="/api/v1/appointments", tags=["Appointments"])
+app.dependency_overrides[get_current_user] = lambda: {"uid": "staff-uid-123"}
+client = TestClient(app)
+
+FAKE_APPOINTMENT_ID = "appt-1"
+FAKE_PATIENT_ID = "patient-1"
+FAKE_PRACTITIONER_ID = "pr-1"
+
+def make_appointment(status="booked", start=datetime(2024, 5, 1, 9, 0, tzinfo=timezone.utc), end=datetime(2024, 5, 1, 9, 30, tzinfo=timezone.utc)):
+    return schemas.Appointment.model_validate({
+        "appointmentId": FAKE_APPOINTMENT_ID,
+        "patientId": FAKE_PATIENT_ID,
+        "practitionerId": FAKE_PRACTITIONER_ID,
+        "start": start,
+        "end": end,
+        "appointmentType": "follow-up",
+        "status": status,
+        "createdAt": datetime(2024, 4, 1, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 4, 1, tzinfo=timezone.utc),
+    })
+
+NEW_APPOINTMENT = {
+    "patient_id": FAKE_PATIENT_ID,
+    "practitioner_id": FAKE_PRACTITIONER_ID,
+    "start": "2024-05-01T09:00:00Z",
+    "end": "2024-05-01T09:30:00Z",
+    "appointment_type": "follow-up",
+}
+
+@pytest.fixture
+def repos():
+    """Overrides the appointment, patient and practitioner repositories with mocks."""
+    mocks = {
+        "appointments": MagicMock(spec=AppointmentRepository),
+        "patients": MagicMock(spec=PatientRepository),
+        "practitioners": MagicMock(spec=PractitionerRepository),
+    }
+    mocks["appointments"].find_overlapping.return_value = []
+    app.dependency_overrides[get_appointment_repository] = lambda: mocks["appointments"]
+    app.dependency_overrides[get_patient_repository] = lambda: mocks["patients"]
+    app.dependency_overrides[get_practitioner_repository] = lambda: mocks["practitioners"]
+    yield mocks
+    for dep in (get_appointment_repository, get_patient_repository, get_practitioner_repository):
+        app.dependency_overrides.pop(dep, None)
+
+# --- Status Transition Test Cases ---
+
+def test_allowed_transitions():
+    """Tests the legal path booked -> arrived -> fulfilled and cancellation of open appointments."""
+    for current, target in [("booked", "arrived"), ("arrived", "fulfilled"), ("booked", "cancelled"), ("arrived", "cancelled")]:
+        ensure_transition(current, target)
+
+def test_illegal_transitions():
+    """Tests that skipping states, going backwards or leaving a terminal state is rejected."""
+    for current, target in [("booked", "fulfilled"), ("arrived", "booked"), ("fulfilled", "cancelled"), ("cancelled", "booked")]:
+        with pytest.raises(InvalidTransitionError):
+            ensure_transition(current, target)
+
+# --- Endpoint Test Cases ---
+
+def test_create_appointment_success(repos):
+    """Tests booking an appointment in a free slot."""
+    repos["appointments"].create.return_value = make_appointment()
+
+    response = client.post("/api/v1/appointments", json=NEW_APPOINTMENT)
+
+    assert response.status_code == 201
+    data = response.json()
+    assert data["appointment_id"] == FAKE_APPOINTMENT_ID
+    assert data["status"] == "booked"
+
+def test_create_appointment_end_before_start(repos):
+    """Tests 422 when the period is inverted."""
+    response = client.post("/api/v1/appointments", json={**NEW_APPOINTMENT, "end": "2024-05-01T08:00:00Z"})
+
+    assert response.status_code == 422
+    repos["appointments"].create.assert_not_called()
+
+def test_create_appointment_double_booked(repos):
+    """Tests 409 when the practitioner already has an overlapping appointment."""
+    repos["appointments"].find_overlapping.return_value = [make_appointment()]
+
+    response = client.post("/api/v1/appointments", json=NEW_APPOINTMENT)
+
+    assert response.status_code == 409
+    repos["appointments"].create.assert_not_called()
+
+def test_create_appointment_unknown_practitioner(repos):
+    """Tests 422 when the practitioner does not exist."""
+    repos["practitioners"].get.return_value = None
+
+    response = client.post("/api/v1/appointments", json=NEW_APPOINTMENT)
+
+    assert response.status_code == 422
+
+def test_list_appointments_requires_filter(repos):
+    """Tests 400 when neither patientId nor practitionerId is given."""
+    response = client.get("/api/v1/appointments")
+
+    assert response.status_code == 400
+
+def test_list_appointments_by_practitioner(repos):
+    """Tests listing a practitioner's appointments within a date range."""
+    repos["appointments"].list.return_value = [make_appointment()]
+
+    response = client.get("/api/v1/appointments?practitionerId=pr-1&from=2024-05-01T00:00:00Z")
+
+    assert response.status_code == 200
+    kwargs = repos["appointments"].list.call_args.kwargs
+    assert kwargs["practitioner_id"] == "pr-1"
+    assert kwargs["start_from"] == datetime(2024, 5, 1, tzinfo=timezone.utc)
+
+def test_reschedule_appointment_success(repos):
+    """Tests moving a booked appointment, excluding itself from the overlap check."""
+    repos["appointments"].get.return_value = make_appointment()
+    repos["appointments"].update.return_value = make_appointment(
+        start=datetime(2024, 5, 2, 9, 0, tzinfo=timezone.utc), end=datetime(2024, 5, 2, 9, 30, tzinfo=timezone.utc)
+    )
+
+    response = client.post(f"/api/v1/appointments/{FAKE_APPOINTMENT_ID}/reschedule", json={
+        "start": "2024-05-02T09:00:00Z", "end": "2024-05-02T09:30:00Z",
+    })
+
+    assert response.status_code == 200
+    assert repos["appointments"].find_overlapping.call_args.kwargs["exclude_id"] == FAKE_APPOINTMENT_ID
+
+def test_reschedule_fulfilled_appointment(repos):
+    """Tests 409 when rescheduling an appointment that has already happened."""
+    repos["appointments"].get.return_value = make_appointment(status="fulfilled")
+
+    response = client.post(f"/api/v1/appointments/{FAKE_APPOINTMENT_ID}/reschedule", json={
+        "start": "2024-05-02T09:00:00Z", "end": "2024-05-02T09:30:00Z",
+    })
+
+    assert response.status_code == 409
+    repos["appointments"].update.assert_not_called()
+
+def test_cancel_appointment_records_reason(repos):
+    """Tests cancelling a booked appointment with a reason."""
+    repos["appointments"].get.return_value = make_appointment()
+    repos["appointments"].update.return_value = make_appointment(status="cancelled")
+
+    response = client.post(f"/api/v1/appointments/{FAKE_APPOINTMENT_ID}/cancel", json={"cancellation_reason": "Patient unwell"})
+
+    assert response.status_code == 200
+    repos["appointments"].update.assert_called_once_with(
+        FAKE_APPOINTMENT_ID, {"status": "cancelled", "cancellationReason": "Patient unwell"}
+    )
+
+def test_update_status_illegal_transition(repos):
+    """Tests 409 when marking a booked appointment fulfilled without arrival."""
+    repos["appointments"].get.return_value = make_appointment()
+
+    response = client.post(f"/api/v1/appointments/{FAKE_APPOINTMENT_ID}/status", json={"status": "fulfilled"})
+
+    assert response.status_code == 409
+    repos["appointments"].update.assert_not_called()
+
+def test_get_appointment_not_found(repos):
+    """Tests 404 for an unknown appointment."""
+    repos["appointments"].get.return_value = None
+
+    response = client.get("/api/v1/appointments/missing")
+
+    assert response.status_code == 404
+
+# --- Firestore Repository Test Cases ---
+
+def test_firestore_find_overlapping_ignores_cancelled_and_adjacent():
+    """Tests that only active appointments ending after the requested start are returned."""
+    mock_db = MagicMock()
+    query = mock_db.collection.return_value.where.return_value.where.return_value
+
+    def snapshot(appt_id, status, start_hour, end_hour):
+        appointment = make_appointment(
+            status=status,
+            start=datetime(2024, 5, 1, start_hour, 0, tzinfo=timezone.utc),
+            end=datetime(2024, 5, 1, end_hour, 0, tzinfo=timezone.utc),
+        )
+        doc = MagicMock()
+        doc.id = appt_id
+        doc.to_dict.return_value = appointment.model_dump(by_alias=True, exclude={"appointment_id"})
+        return doc
+
+    query.stream.return_value = [
+        snapshot("a", "booked", 8, 9),      # ends exactly at the requested start
+        snapshot("b", "cancelled", 9, 10),  # cancelled, frees the slot
+        snapshot("c", "arrived", 9, 10),    # genuine overlap
+    ]
+
+    repo = FirestoreAppointmentRepository(mock_db)
+    overlapping = repo.find_overlapping(
+        FAKE_PRACTITIONER_ID,
+        datetime(2024, 5, 1, 9, 0, tzinfo=timezone.utc),
+        datetime(2024, 5, 1, 10, 0, tzinfo=timezone.utc),
+    )
+
+    assert [a.appointment_id for a in overlapping] == ["c"]
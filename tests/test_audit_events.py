@@ -0,0 +1,354 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import Depends, FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_audit_event_repository, get_audit_export_repository, get_task_queue
+from app.api.v1.endpoints import audit_events
+from app.audit import export as audit_export
+from app.audit.context import annotate, set_actor
+from app.audit.events import resource_for
+from app.core.config import get_settings
+from app.dependencies.auth import get_current_user
+from app.middleware.audit import AuditMiddleware
+from app.repositories.audit_events import AuditEventRepository, FirestoreAuditEventRepository
+from app.repositories.audit_exports import AuditExportRepository
+from app.tasks.jobs import AUDIT_EXPORT_TASK
+from app.tasks.queue import TaskQueue
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(audit_events.router, prefix="/api/v1/audit-events", tags=["Audit"])
+client = TestClient(app)
+
+COMPLIANCE_USER = {"uid": "compliance-uid", "roles": ["compliance-officer"]}
+CLINICIAN_USER = {"uid": "clinician-uid", "roles": ["clinician"]}
+FAKE_EVENT_ID = "event-1"
+FAKE_PATIENT_ID = "patient-1"
+
+def make_event(event_id=FAKE_EVENT_ID, **overrides):
+    data = {
+        "eventId": event_id,
+        "occurredAt": datetime(2024, 5, 1, 9, 0, tzinfo=timezone.utc),
+        "action": "read",
+        "outcome": "success",
+        "statusCode": 200,
+        "method": "GET",
+        "route": "/api/v1/patients/{patientId}",
+        "path": f"/api/v1/patients/{FAKE_PATIENT_ID}",
+        "actorUid": "clinician-uid",
+        "actorRoles": ["clinician"],
+        "resourceType": "patients",
+        "resourceId": FAKE_PATIENT_ID,
+        "patientId": FAKE_PATIENT_ID,
+        "purposeOfUse": "TREAT",
+        "sourceIp": "203.0.113.7",
+        "requestId": "req-1",
+    }
+    data.update(overrides)
+    return schemas.AuditEvent.model_validate(data)
+
+@pytest.fixture
+def repo():
+    """Overrides the audit event repository with a mock and signs in a compliance officer."""
+    mock_repo = MagicMock(spec=AuditEventRepository)
+    app.dependency_overrides[get_audit_event_repository] = lambda: mock_repo
+    app.dependency_overrides[get_current_user] = lambda: COMPLIANCE_USER
+    yield mock_repo
+    app.dependency_overrides.pop(get_audit_event_repository, None)
+    app.dependency_overrides.pop(get_current_user, None)
+
+# --- Endpoint Test Cases ---
+
+def test_list_audit_events_passes_filters(repo):
+    """Tests that search filters are passed through to the repository."""
+    repo.list.return_value = [make_event()]
+
+    response = client.get("/api/v1/audit-events", params={
+        "patientId": FAKE_PATIENT_ID,
+        "actorUid": "clinician-uid",
+        "from": "2024-05-01T00:00:00Z",
+        "limit": 50,
+    })
+
+    assert response.status_code == 200
+    assert response.json()["items"][0]["event_id"] == FAKE_EVENT_ID
+    kwargs = repo.list.call_args.kwargs
+    assert kwargs["patient_id"] == FAKE_PATIENT_ID
+    assert kwargs["actor_uid"] == "clinician-uid"
+    assert kwargs["occurred_from"] == datetime(2024, 5, 1, tzinfo=timezone.utc)
+    assert kwargs["limit"] == 51
+
+def test_list_audit_events_rejects_inverted_range(repo):
+    """Tests that 'from' after 'to' is rejected."""
+    response = client.get("/api/v1/audit-events", params={"from": "2024-05-02T00:00:00Z", "to": "2024-05-01T00:00:00Z"})
+
+    assert response.status_code == 400
+    repo.list.assert_not_called()
+
+def test_audit_events_require_compliance_role(repo):
+    """Tests that users without a compliance role cannot read the audit trail."""
+    app.dependency_overrides[get_current_user] = lambda: CLINICIAN_USER
+
+    list_response = client.get("/api/v1/audit-events")
+    get_response = client.get(f"/api/v1/audit-events/{FAKE_EVENT_ID}")
+
+    assert list_response.status_code == 403
+    assert get_response.status_code == 403
+    repo.list.assert_not_called()
+    repo.get.assert_not_called()
+
+def test_privacy_officer_can_read_audit_trail(repo):
+    """Tests that the privacy officer role is also admitted."""
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "privacy-uid", "roles": ["privacy-officer"]}
+    repo.list.return_value = []
+
+    response = client.get("/api/v1/audit-events")
+
+    assert response.status_code == 200
+
+def test_get_audit_event_not_found(repo):
+    """Tests that an unknown event ID returns 404."""
+    repo.get.return_value = None
+
+    response = client.get("/api/v1/audit-events/unknown")
+
+    assert response.status_code == 404
+
+# --- Export Test Cases ---
+
+FAKE_EXPORT_ID = "export-1"
+
+def make_export(**overrides):
+    data = {
+        "exportId": FAKE_EXPORT_ID,
+        "status": "accepted",
+        "requestedBy": COMPLIANCE_USER["uid"],
+        "patientId": FAKE_PATIENT_ID,
+        "createdAt": datetime(2024, 6, 1, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 6, 1, tzinfo=timezone.utc),
+    }
+    data.update(overrides)
+    return schemas.AuditExport.model_validate(data)
+
+@pytest.fixture
+def exports(monkeypatch):
+    """Overrides the export repository and the task queue with mocks and configures a bucket."""
+    monkeypatch.setattr(get_settings(), "audit_export_bucket", "audit-bucket")
+    mocks = {"exports": MagicMock(spec=AuditExportRepository), "tasks": MagicMock(spec=TaskQueue)}
+    app.dependency_overrides[get_audit_export_repository] = lambda: mocks["exports"]
+    app.dependency_overrides[get_task_queue] = lambda: mocks["tasks"]
+    app.dependency_overrides[get_current_user] = lambda: COMPLIANCE_USER
+    yield mocks
+    for dep in (get_audit_export_repository, get_task_queue, get_current_user):
+        app.dependency_overrides.pop(dep, None)
+
+def test_start_audit_export_queues_it(exports):
+    """Tests that starting an export records its filters, queues it and points to its status."""
+    exports["exports"].create.return_value = make_export()
+
+    response = client.post("/api/v1/audit-events/exports", json={"patientId": FAKE_PATIENT_ID, "from": "2024-01-01T00:00:00Z"})
+
+    assert response.status_code == 202
+    assert response.headers["content-location"] == f"/api/v1/audit-events/exports/{FAKE_EXPORT_ID}"
+    export_in, requested_by = exports["exports"].create.call_args[0]
+    assert export_in.patient_id == FAKE_PATIENT_ID
+    assert export_in.occurred_from == datetime(2024, 1, 1, tzinfo=timezone.utc)
+    assert requested_by == COMPLIANCE_USER["uid"]
+    exports["tasks"].enqueue.assert_called_once_with(AUDIT_EXPORT_TASK, {"exportId": FAKE_EXPORT_ID}, task_id=f"audit-export-{FAKE_EXPORT_ID}")
+
+def test_start_audit_export_needs_a_bucket_and_a_compliance_role(exports, monkeypatch):
+    """Tests that exports are refused without a bucket or to users without a compliance role."""
+    monkeypatch.setattr(get_settings(), "audit_export_bucket", None)
+    assert client.post("/api/v1/audit-events/exports", json={}).status_code == 503
+
+    monkeypatch.setattr(get_settings(), "audit_export_bucket", "audit-bucket")
+    app.dependency_overrides[get_current_user] = lambda: CLINICIAN_USER
+    assert client.post("/api/v1/audit-events/exports", json={}).status_code == 403
+    exports["exports"].create.assert_not_called()
+
+def test_completed_audit_export_links_to_the_file(exports, monkeypatch):
+    """Tests that a completed export is returned with a signed download URL."""
+    exports["exports"].get.return_value = make_export(status="completed", blobName="audit-exports/export-1.csv", rowCount=2)
+    monkeypatch.setattr(audit_events, "signed_download_url", lambda bucket, blob, ttl: f"https://signed/{bucket}/{blob}")
+
+    response = client.get(f"/api/v1/audit-events/exports/{FAKE_EXPORT_ID}")
+
+    assert response.status_code == 200
+    assert response.json()["download_url"] == "https://signed/audit-bucket/audit-exports/export-1.csv"
+    exports["exports"].get.return_value = None
+    assert client.get("/api/v1/audit-events/exports/unknown").status_code == 404
+
+def test_run_audit_export_writes_csv_in_batches(monkeypatch):
+    """Tests that matching events are written as CSV batch by batch and counted on the export."""
+    written = []
+    blob = MagicMock()
+    blob.name = "audit-exports/export-1.csv"
+    blob.open.return_value.__enter__.return_value.write.side_effect = written.append
+    monkeypatch.setattr(audit_export, "get_storage_client", lambda: MagicMock(bucket=MagicMock(return_value=MagicMock(blob=MagicMock(return_value=blob)))))
+    exports = MagicMock(spec=AuditExportRepository)
+    exports.get.return_value = make_export()
+    events = MagicMock(spec=AuditEventRepository)
+    events.list.side_effect = [[make_event("e1"), make_event("e2")], [make_event("e3", actorRoles=["clinician", "admin"])]]
+
+    audit_export.run_audit_export(FAKE_EXPORT_ID, exports, events, "audit-bucket", batch_size=2)
+
+    assert events.list.call_args_list[0].kwargs["patient_id"] == FAKE_PATIENT_ID
+    assert events.list.call_args_list[1].kwargs["after"] == "e2"
+    lines = "".join(written).splitlines()
+    assert lines[0].split(",")[:3] == ["eventId", "occurredAt", "actorUid"]
+    assert [line.split(",")[0] for line in lines[1:]] == ["e1", "e2", "e3"]
+    assert "clinician admin" in lines[3]
+    final = exports.update.call_args_list[-1][0][1]
+    assert final["status"] == "completed"
+    assert final["rowCount"] == 3
+
+def test_run_audit_export_escapes_formulas(monkeypatch):
+    """Tests that caller-supplied values a spreadsheet would run as formulas are written as text."""
+    written = []
+    blob = MagicMock()
+    blob.open.return_value.__enter__.return_value.write.side_effect = written.append
+    monkeypatch.setattr(audit_export, "get_storage_client", lambda: MagicMock(bucket=MagicMock(return_value=MagicMock(blob=MagicMock(return_value=blob)))))
+    exports = MagicMock(spec=AuditExportRepository)
+    exports.get.return_value = make_export()
+    events = MagicMock(spec=AuditEventRepository)
+    events.list.side_effect = [[make_event("e1", userAgent='=HYPERLINK("https://evil.example","x")', path="@SUM(1)")], []]
+
+    audit_export.run_audit_export(FAKE_EXPORT_ID, exports, events, "audit-bucket")
+
+    row = "".join(written).splitlines()[1]
+    assert "'=HYPERLINK" in row and "'@SUM(1)" in row
+    assert ",200," in row
+
+def test_run_audit_export_records_failure_and_skips_finished_exports(monkeypatch):
+    """Tests that a failure marks the export as errored and that finished exports are not rerun."""
+    monkeypatch.setattr(audit_export, "get_storage_client", MagicMock(side_effect=RuntimeError("no credentials")))
+    exports = MagicMock(spec=AuditExportRepository)
+    exports.get.return_value = make_export()
+
+    audit_export.run_audit_export(FAKE_EXPORT_ID, exports, MagicMock(spec=AuditEventRepository), "audit-bucket")
+
+    assert exports.update.call_args_list[-1][0][1] == {"status": "error", "error": "no credentials"}
+    exports.reset_mock()
+    exports.get.return_value = make_export(status="completed")
+    audit_export.run_audit_export(FAKE_EXPORT_ID, exports, MagicMock(spec=AuditEventRepository), "audit-bucket")
+    exports.update.assert_not_called()
+
+# --- Audit Middleware Test Cases ---
+
+audit_repo = MagicMock(spec=AuditEventRepository)
+
+def fake_current_user():
+    claims = {"uid": "clinician-uid", "roles": ["clinician"]}
+    set_actor(claims)
+    return claims
+
+audited_app = FastAPI()
+audited_app.add_middleware(AuditMiddleware, repository_factory=lambda: audit_repo)
+
+@audited_app.get("/api/v1/patients/{patientId}/allergies/{allergyId}")
+def read_allergy(patientId: str, allergyId: str, current_user=Depends(fake_current_user)):
+    return {"allergyId": allergyId}
+
+@audited_app.get("/api/v1/encounters/{encounterId}")
+def read_encounter(encounterId: str, current_user=Depends(fake_current_user)):
+    annotate(patient_id=FAKE_PATIENT_ID)
+    return {"encounterId": encounterId}
+
+@audited_app.get("/healthz")
+def healthz():
+    return {"status": "ok"}
+
+audited_client = TestClient(audited_app)
+
+def recorded_event() -> schemas.AuditEventCreate:
+    return audit_repo.append.call_args[0][0]
+
+def test_middleware_records_actor_resource_and_purpose():
+    """Tests that a PHI read is recorded with who, what, where from and why."""
+    audit_repo.reset_mock()
+    audit_repo.append.side_effect = None
+
+    response = audited_client.get(
+        f"/api/v1/patients/{FAKE_PATIENT_ID}/allergies/allergy-1",
+        headers={"X-Purpose-Of-Use": "treat", "X-Forwarded-For": "198.51.100.9, 203.0.113.7"},
+    )
+
+    assert response.status_code == 200
+    event = recorded_event()
+    assert event.actor_uid == "clinician-uid"
+    assert event.actor_roles == ["clinician"]
+    assert event.action == "read"
+    assert event.outcome == "success"
+    assert event.route == "/api/v1/patients/{patientId}/allergies/{allergyId}"
+    assert event.resource_type == "allergies"
+    assert event.resource_id == "allergy-1"
+    assert event.patient_id == FAKE_PATIENT_ID
+    assert event.purpose_of_use == "TREAT"
+    assert event.source_ip == "203.0.113.7"
+
+def test_middleware_uses_patient_annotated_by_handler():
+    """Tests that handlers can attach the patient for routes not nested under one."""
+    audit_repo.reset_mock()
+    audit_repo.append.side_effect = None
+
+    audited_client.get("/api/v1/encounters/enc-1")
+
+    event = recorded_event()
+    assert event.resource_type == "encounters"
+    assert event.resource_id == "enc-1"
+    assert event.patient_id == FAKE_PATIENT_ID
+    assert event.purpose_of_use == "UNSPECIFIED"
+
+def test_middleware_skips_probes_and_unmatched_routes():
+    """Tests that health probes and 404s for unknown paths are not audited."""
+    audit_repo.reset_mock()
+    audit_repo.append.side_effect = None
+
+    audited_client.get("/healthz")
+    audited_client.get("/does-not-exist")
+
+    audit_repo.append.assert_not_called()
+
+def test_middleware_write_failure_does_not_fail_request():
+    """Tests that an audit storage error is logged rather than breaking the response."""
+    audit_repo.reset_mock()
+    audit_repo.append.side_effect = Exception("firestore unavailable")
+
+    response = audited_client.get(f"/api/v1/patients/{FAKE_PATIENT_ID}/allergies/allergy-1")
+
+    assert response.status_code == 200
+    audit_repo.append.assert_called_once()
+    audit_repo.append.side_effect = None
+
+def test_resource_for_skips_operation_segments():
+    """Tests that trailing operations are attributed to the resource they act on."""
+    cases = [
+        ("/api/v1/encounters/{encounterId}/status", {"encounterId": "enc-1"}, ("encounters", "enc-1")),
+        ("/api/v1/patients/{patientId}/lab-results/trend", {"patientId": "p-1"}, ("lab-results", None)),
+        ("/fhir/Patient/{patientId}", {"patientId": "p-1"}, ("Patient", "p-1")),
+    ]
+    for route, params, expected in cases:
+        assert resource_for(route, params) == expected
+
+# --- Firestore Repository Test Cases ---
+
+def test_firestore_append_never_overwrites():
+    """Tests that events are written with create() on a new document rather than set()."""
+    mock_db = MagicMock()
+    event_ref = MagicMock()
+    event_ref.id = FAKE_EVENT_ID
+    mock_db.collection.return_value.document.return_value = event_ref
+
+    repo = FirestoreAuditEventRepository(mock_db)
+    event_id = repo.append(schemas.AuditEventCreate.model_validate(make_event().model_dump(by_alias=True, exclude={"event_id"})))
+
+    assert event_id == FAKE_EVENT_ID
+    mock_db.collection.return_value.document.assert_called_once_with()
+    event_ref.create.assert_called_once()
+    event_ref.set.assert_not_called()
+    assert event_ref.create.call_args[0][0]["patientId"] == FAKE_PATIENT_ID
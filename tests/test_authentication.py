@@ -0,0 +1,168 @@
+import pytest
+import json
+import time
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+
+import httpx
+import jwt
+from cryptography.hazmat.primitives.asymmetric import rsa
+from fastapi import Depends, FastAPI
+from app.auth.context import principal_var
+from app.auth.jwks import JwksCache, discover_jwks_url
+from app.auth.verifier import InvalidTokenError, OidcTokenVerifier, TokenVerifier
+from app.dependencies import auth
+from app.dependencies.auth import get_current_user
+from app.middleware.authentication import AuthenticationMiddleware, bearer_token
+
+# --- Test Setup ---
+
+ISSUER = "https://securetoken.google.com/mega-care"
+AUDIENCE = "mega-care"
+JWKS_URL = "https://www.googleapis.com/service_accounts/v1/jwk/securetoken@system.gserviceaccount.com"
+
+PRIVATE_KEY = rsa.generate_private_key(public_exponent=65537, key_size=2048)
+
+def make_jwk(kid="key-1"):
+    jwk = json.loads(jwt.algorithms.RSAAlgorithm.to_jwk(PRIVATE_KEY.public_key()))
+    return {**jwk, "kid": kid, "use": "sig", "alg": "RS256"}
+
+def make_token(kid="key-1", **overrides):
+    now = int(time.time())
+    claims = {"iss": ISSUER, "aud": AUDIENCE, "sub": "user-123", "iat": now, "exp": now + 300, "roles": ["clinician"]}
+    claims.update(overrides)
+    return jwt.encode(claims, PRIVATE_KEY, algorithm="RS256", headers={"kid": kid})
+
+def make_keys(jwks=None, clock=time.monotonic):
+    fetches = []
+
+    def handler(request):
+        fetches.append(str(request.url))
+        return httpx.Response(200, json={"keys": jwks if jwks is not None else [make_jwk()]})
+
+    keys = JwksCache(lambda: JWKS_URL, ttl_seconds=3600, client=httpx.Client(transport=httpx.MockTransport(handler)), clock=clock)
+    return keys, fetches
+
+def make_verifier(**kwargs):
+    keys, _ = make_keys()
+    return OidcTokenVerifier(ISSUER, AUDIENCE, keys, **kwargs)
+
+# --- Verifier Test Cases ---
+
+def test_oidc_verifier_returns_subject_and_roles():
+    """Tests that a token signed with the issuer's key yields its subject as `uid` and its roles."""
+    claims = make_verifier().verify(make_token())
+
+    assert claims["uid"] == "user-123"
+    assert claims["roles"] == ["clinician"]
+
+def test_oidc_verifier_reads_configured_roles_claim():
+    """Tests that roles can come from a namespaced claim, as Auth0 issues them."""
+    token = make_token(**{"https://megacare.example/roles": ["integration-admin"]})
+
+    claims = make_verifier(roles_claim="https://megacare.example/roles").verify(token)
+
+    assert claims["roles"] == ["integration-admin"]
+
+def test_oidc_verifier_rejects_foreign_expired_or_unsigned_tokens():
+    """Tests that wrong audience or issuer, expired tokens, unknown keys and unsigned tokens are refused."""
+    verifier = make_verifier()
+    now = int(time.time())
+    for token in [
+        make_token(aud="another-project"),
+        make_token(iss="https://accounts.example.com"),
+        make_token(iat=now - 7200, exp=now - 3600),
+        make_token(kid="unknown-key"),
+        jwt.encode({"iss": ISSUER, "aud": AUDIENCE, "sub": "user-123", "iat": now, "exp": now + 300}, None, algorithm="none"),
+    ]:
+        with pytest.raises(InvalidTokenError):
+            verifier.verify(token)
+
+# --- Key Cache Test Cases ---
+
+def test_jwks_cache_fetches_once_and_refreshes_for_new_key_ids():
+    """Tests that keys are reused, and an unknown key ID refetches the set at most once a minute."""
+    now = [1000.0]
+    keys, fetches = make_keys(jwks=[make_jwk("key-1")], clock=lambda: now[0])
+
+    keys.get("key-1")
+    keys.get("key-1")
+    with pytest.raises(LookupError):
+        keys.get("key-2")
+    assert len(fetches) == 1
+
+    now[0] += 61
+    with pytest.raises(LookupError):
+        keys.get("key-2")
+    assert len(fetches) == 2
+
+def test_discovery_reads_jwks_uri():
+    """Tests that the JWKS URL is taken from the issuer's OpenID configuration."""
+    def handler(request):
+        assert str(request.url) == f"{ISSUER}/.well-known/openid-configuration"
+        return httpx.Response(200, json={"issuer": ISSUER, "jwks_uri": JWKS_URL})
+
+    assert discover_jwks_url(ISSUER, httpx.Client(transport=httpx.MockTransport(handler))) == JWKS_URL
+
+# --- Dependency Test Cases ---
+
+def test_bearer_token_parsing():
+    """Tests that only well-formed Bearer headers yield a token."""
+    assert bearer_token("Bearer abc.def") == "abc.def"
+    assert bearer_token("bearer abc.def") == "abc.def"
+    assert bearer_token("Basic dXNlcg==") is None
+    assert bearer_token("Bearer ") is None
+    assert bearer_token(None) is None
+
+def test_current_user_reuses_claims_verified_by_middleware(monkeypatch):
+    """Tests that the dependency returns the principal from the request context without verifying again."""
+    verifier = MagicMock(spec=TokenVerifier)
+    monkeypatch.setattr(auth, "get_token_verifier", lambda: verifier)
+    token = principal_var.set({"uid": "user-123", "roles": []})
+    try:
+        user = get_current_user(MagicMock(credentials="token"))
+    finally:
+        principal_var.reset(token)
+
+    assert user["uid"] == "user-123"
+    verifier.verify.assert_not_called()
+
+# --- Middleware Test Cases ---
+
+app = FastAPI()
+app.add_middleware(AuthenticationMiddleware, verifier_factory=lambda: make_verifier())
+
+@app.get("/api/v1/patients")
+def list_patients(current_user=Depends(get_current_user)):
+    return {"uid": current_user["uid"]}
+
+@app.post("/api/v1/auth/line")
+def line_login():
+    return {"ok": True}
+
+@app.get("/healthz")
+def healthz():
+    return {"status": "ok"}
+
+client = TestClient(app)
+
+def test_middleware_rejects_requests_without_valid_token():
+    """Tests that /api/v1 requests without a token, or with a forged one, get 401 before the handler runs."""
+    missing = client.get("/api/v1/patients")
+    forged = client.get("/api/v1/patients", headers={"Authorization": f"Bearer {make_token(aud='elsewhere')}"})
+
+    assert missing.status_code == 401
+    assert missing.headers["WWW-Authenticate"] == "Bearer"
+    assert forged.status_code == 401
+
+def test_middleware_passes_verified_caller_to_handlers():
+    """Tests that a valid token reaches the handler with its claims as the current user."""
+    response = client.get("/api/v1/patients", headers={"Authorization": f"Bearer {make_token()}"})
+
+    assert response.status_code == 200
+    assert response.json() == {"uid": "user-123"}
+
+def test_middleware_leaves_login_and_probes_open():
+    """Tests that the login endpoints and routes outside /api/v1 need no token."""
+    assert client.post("/api/v1/auth/line").status_code == 200
+    assert client.get("/healthz").status_code == 200
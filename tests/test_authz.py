@@ -0,0 +1,174 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timedelta, timezone
+
+from fastapi import APIRouter, Depends, FastAPI
+from app.api.v1 import schemas
+from app.authz import ownership
+from app.authz.ownership import get_owner_lookup, owner_lookup
+from app.authz.roles import ADMIN, CLINICIAN, COORDINATOR, PATIENT, grants, has_permission, permission
+from app.authz.policy import authorize
+from app.dependencies.auth import get_current_user
+from app.repositories.appointments import AppointmentRepository
+
+# --- Test Setup ---
+
+NOW = datetime(2024, 5, 1, 9, 0, tzinfo=timezone.utc)
+
+def make_user(*roles, **claims):
+    return {"uid": "user-1", "roles": list(roles), **claims}
+
+def make_appointment(appointment_id="appt-1", patient_id="patient-1"):
+    return schemas.Appointment.model_validate({
+        "appointmentId": appointment_id,
+        "patientId": patient_id,
+        "practitionerId": "prac-1",
+        "start": NOW,
+        "end": NOW + timedelta(minutes=30),
+        "status": "booked",
+        "createdAt": NOW,
+        "updatedAt": NOW,
+    })
+
+patients_router = APIRouter()
+
+@patients_router.get("")
+def list_patients():
+    return []
+
+@patients_router.get("/{patientId}")
+def get_patient(patientId: str):
+    return {"patientId": patientId}
+
+@patients_router.delete("/{patientId}")
+def delete_patient(patientId: str):
+    return {"deleted": patientId}
+
+appointments_router = APIRouter()
+
+@appointments_router.get("")
+def list_appointments(patientId: str = None):
+    return []
+
+@appointments_router.post("")
+def create_appointment(body: dict):
+    return body
+
+@appointments_router.get("/{appointmentId}")
+def get_appointment(appointmentId: str):
+    return {"appointmentId": appointmentId}
+
+app = FastAPI()
+app.include_router(patients_router, prefix="/api/v1/patients", dependencies=[Depends(authorize("patients"))])
+app.include_router(appointments_router, prefix="/api/v1/appointments", dependencies=[Depends(authorize("appointments"))])
+client = TestClient(app)
+
+@pytest.fixture
+def appointments(monkeypatch):
+    """Signs in a patient linked to patient-1 and serves appointments from a mock repository."""
+    repo = MagicMock(spec=AppointmentRepository)
+    repo.get.side_effect = lambda appointment_id: {
+        "appt-1": make_appointment("appt-1", "patient-1"),
+        "appt-2": make_appointment("appt-2", "patient-2"),
+    }.get(appointment_id)
+    monkeypatch.setattr(ownership, "get_appointment_repository", lambda: repo)
+    app.dependency_overrides[get_current_user] = lambda: make_user(PATIENT, patientId="patient-1")
+    yield repo
+    app.dependency_overrides.pop(get_current_user, None)
+
+# --- Role Test Cases ---
+
+def test_permission_maps_methods_to_read_and_write():
+    """Tests that safe methods need read access and all others write access."""
+    assert permission("patients", "GET") == "patients:read"
+    assert permission("patients", "HEAD") == "patients:read"
+    assert permission("patients", "DELETE") == "patients:write"
+
+def test_roles_grant_their_permissions():
+    """Tests that each role's permissions are combined, and that unknown roles grant nothing."""
+    clinician, _ = grants(make_user(CLINICIAN))
+    coordinator, _ = grants(make_user(COORDINATOR))
+    admin, _ = grants(make_user(ADMIN))
+    both, _ = grants(make_user(CLINICIAN, COORDINATOR))
+
+    assert has_permission(clinician, "encounters:write")
+    assert not has_permission(coordinator, "encounters:write")
+    assert has_permission(admin, "anything:write")
+    assert has_permission(both, "care-teams:write") and has_permission(both, "encounters:write")
+    assert grants(make_user("compliance-officer")) == (frozenset(), frozenset())
+
+def test_patient_permissions_are_limited_to_own_records():
+    """Tests that a patient's clinical permissions only extend to their own records."""
+    full, own = grants(make_user(PATIENT))
+
+    assert not has_permission(full, "patients:read")
+    assert "patients:read" in own
+    assert "encounters:write" not in own
+
+def test_api_key_scopes_are_its_permissions():
+    """Tests that an API key's scopes grant unrestricted access and its lack of roles grants nothing else."""
+    full, own = grants({"uid": "api-key:k-1", "roles": [], "scopes": ["patients:read"], "authMethod": "api_key"})
+
+    assert full == frozenset({"patients:read"})
+    assert own == frozenset()
+
+def test_owner_lookup_rejects_duplicate_registration():
+    """Tests that each path parameter has a single owner lookup."""
+    assert get_owner_lookup("appointmentId") is ownership.appointment_owner
+    with pytest.raises(ValueError):
+        owner_lookup("appointmentId")(lambda record_id: None)
+
+# --- Policy Test Cases ---
+
+def test_roles_without_permission_are_refused():
+    """Tests that a coordinator may read patients but not delete them, and role-less users may do neither."""
+    app.dependency_overrides[get_current_user] = lambda: make_user(COORDINATOR)
+    assert client.get("/api/v1/patients/patient-1").status_code == 200
+    assert client.delete("/api/v1/patients/patient-1").status_code == 403
+
+    app.dependency_overrides[get_current_user] = lambda: make_user()
+    assert client.get("/api/v1/patients/patient-1").status_code == 403
+    app.dependency_overrides.pop(get_current_user, None)
+
+def test_patient_reaches_only_own_patient_record(appointments):
+    """Tests that a patient may open their own record but not another's, nor the patient list."""
+    assert client.get("/api/v1/patients/patient-1").status_code == 200
+    assert client.get("/api/v1/patients/patient-2").status_code == 403
+    assert client.get("/api/v1/patients").status_code == 403
+
+def test_patient_without_linked_record_is_refused(appointments):
+    """Tests that a patient user with no `patientId` claim reaches no patient record."""
+    app.dependency_overrides[get_current_user] = lambda: make_user(PATIENT)
+
+    assert client.get("/api/v1/patients/patient-1").status_code == 403
+
+def test_patient_reaches_own_appointments_by_lookup_filter_or_body(appointments):
+    """Tests that ownership is established from the record named in the path, the patientId filter or the body."""
+    assert client.get("/api/v1/appointments/appt-1").status_code == 200
+    assert client.get("/api/v1/appointments/appt-2").status_code == 403
+    assert client.get("/api/v1/appointments/missing").status_code == 200
+    assert client.get("/api/v1/appointments", params={"patientId": "patient-1"}).status_code == 200
+    assert client.get("/api/v1/appointments", params={"practitionerId": "prac-1"}).status_code == 403
+    assert client.post("/api/v1/appointments", json={"patientId": "patient-1"}).status_code == 200
+    assert client.post("/api/v1/appointments", json={"patientId": "patient-2"}).status_code == 403
+
+def test_patient_cannot_vouch_with_the_query_for_another_patient_in_the_body(appointments):
+    """Tests that the body's patient is the one checked, and a query naming another is refused."""
+    own, other = {"patientId": "patient-1"}, {"patientId": "patient-2"}
+
+    assert client.post("/api/v1/appointments", params=own, json=other).status_code == 400
+    assert client.post("/api/v1/appointments", params=other, json=own).status_code == 400
+    assert client.post("/api/v1/appointments", params=own, json=own).status_code == 200
+    untyped = client.post("/api/v1/appointments", params=own, content=b'{"patientId": "patient-2"}', headers={"Content-Type": ""})
+    assert untyped.status_code == 400
+    assert client.post("/api/v1/appointments", params=own, json={"patient_id": "patient-2"}).status_code == 400
+    assert client.post("/api/v1/appointments", json={"patient_id": "patient-2"}).status_code == 403
+    assert client.post("/api/v1/appointments", json={"patientId": "patient-1", "patient_id": "patient-2"}).status_code == 400
+
+def test_staff_roles_skip_ownership_lookups(appointments):
+    """Tests that unrestricted permissions are granted without loading the record."""
+    app.dependency_overrides[get_current_user] = lambda: make_user(CLINICIAN)
+
+    assert client.get("/api/v1/appointments/appt-2").status_code == 200
+    appointments.get.assert_not_called()
@@ -0,0 +1,242 @@
+import json
+from datetime import datetime, timedelta, timezone
+
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_import_job_repository, get_task_queue
+from app.api.v1.endpoints import imports as imports_endpoint
+from app.dependencies.auth import get_current_user
+from app.events.publisher import EventPublisher
+from app.repositories.memory import MemoryImportJobRepository, MemoryObservationRepository, MemoryPatientRepository, MemoryStore
+from app.services import bulk_import
+from app.services.bulk_import import MAX_LINE_BYTES, error_blob_name, import_chunk, import_identifier_system, input_blob_name
+from app.services.vitals import build_vital_observation
+
+# --- Test Setup ---
+
+BUCKET = "imports-bucket"
+TAKEN_AT = (datetime.now(timezone.utc) - timedelta(days=1)).replace(microsecond=0).isoformat()
+
+
+class RecordingPublisher(EventPublisher):
+    def __init__(self):
+        self.events = []
+
+    def publish(self, event) -> None:
+        self.events.append(event)
+
+
+class FakeBucket:
+    """Stands in for the storage helpers the importer uses, keeping objects in a dict."""
+
+    def __init__(self, monkeypatch):
+        self.blobs = {}
+        monkeypatch.setattr(bulk_import, "read_blob", self.read)
+        monkeypatch.setattr(bulk_import, "upload_blob", self.upload)
+        monkeypatch.setattr(bulk_import, "delete_blob", lambda bucket, name: self.blobs.pop(name, None))
+
+    def read(self, bucket, name, chunk_size=16, start=0):
+        from google.api_core.exceptions import NotFound
+
+        if name not in self.blobs:
+            raise NotFound(name)
+        data = self.blobs[name][start:]
+        # Small chunks so that lines are split across them.
+        return (data[i:i + chunk_size] for i in range(0, len(data), chunk_size))
+
+    def upload(self, bucket, name, data, content_type):
+        self.blobs[name] = data
+
+
+def line(resource_type, **fields):
+    return json.dumps({"resourceType": resource_type, **fields})
+
+
+def patient_line(mrn="MRN-1", **fields):
+    return line("Patient", **{"givenName": "Ann", "familyName": "Smith", "dob": "1975-03-02", "mrn": mrn, **fields})
+
+
+def heart_rate_line(value=72, **fields):
+    return line("Observation", code="8867-4", value=value, unit="/min", effectiveAt=TAKEN_AT, **fields)
+
+
+def setup(monkeypatch, lines, job_id="job-1"):
+    store = MemoryStore()
+    bucket = FakeBucket(monkeypatch)
+    content = ("\n".join(lines) + "\n").encode()
+    bucket.blobs[input_blob_name(job_id)] = content
+    jobs = MemoryImportJobRepository(store)
+    jobs.create(job_id, schemas.ImportJobCreate(blob_name=input_blob_name(job_id), size=len(content), requested_by="admin-1"))
+    return {
+        "jobs": jobs, "patients": MemoryPatientRepository(store), "observations": MemoryObservationRepository(store),
+        "events": RecordingPublisher(), "bucket": bucket,
+    }
+
+
+def run(ctx, job_id="job-1", chunk_lines=500):
+    """Runs chunks until the job stops, as the task does without a queue."""
+    offset, runs = 0, 0
+    while offset is not None:
+        offset = import_chunk(job_id, offset, ctx["jobs"], ctx["patients"], ctx["observations"], ctx["events"], BUCKET, chunk_lines)
+        runs += 1
+    return runs
+
+
+def report(ctx, job_id="job-1"):
+    lines = b"".join(data for name, data in sorted(ctx["bucket"].blobs.items()) if name.startswith(f"imports/{job_id}/errors/"))
+    return [json.loads(l) for l in lines.splitlines()]
+
+# --- Import Test Cases ---
+
+def test_import_creates_patients_and_their_observations(monkeypatch):
+    """Tests that patients are created, observations reference them by MRN, and events are emitted."""
+    ctx = setup(monkeypatch, [patient_line(), heart_rate_line(patientMrn="MRN-1")])
+
+    run(ctx)
+
+    job = ctx["jobs"].get("job-1")
+    assert job.status == "completed" and job.completed_at is not None
+    assert job.imported == {"Patient": 1, "Observation": 1}
+    assert (job.lines_read, job.failed, job.offset) == (2, 0, job.size)
+    patient = ctx["patients"].get_by_mrn("MRN-1")
+    [observation] = ctx["observations"].list(patient.patient_id)
+    assert observation.code.display == "Heart rate"
+    assert observation.identifier.system == import_identifier_system("job-1") and observation.identifier.value == "2"
+    assert [e.type for e in ctx["events"].events] == ["patient.created", "observation.recorded"]
+    assert input_blob_name("job-1") not in ctx["bucket"].blobs
+
+def test_invalid_lines_are_reported_without_their_values(monkeypatch):
+    """Tests that bad lines go to the error report by line number, without their values, and the rest is imported."""
+    ctx = setup(monkeypatch, [
+        "not json",
+        json.dumps([1, 2]),
+        line("Encounter"),
+        patient_line(mrn="MRN-1", dob="not-a-date"),
+        "",
+        patient_line(mrn="MRN-2"),
+        heart_rate_line(value=900, patientMrn="MRN-2"),
+        heart_rate_line(patientMrn="MRN-404"),
+        heart_rate_line(),
+    ])
+
+    run(ctx)
+
+    job = ctx["jobs"].get("job-1")
+    assert job.imported == {"Patient": 1}
+    assert (job.failed, job.lines_read) == (7, 9)
+    errors = report(ctx)
+    assert [e["line"] for e in errors] == [1, 2, 3, 4, 7, 8, 9]
+    assert errors[0]["error"].startswith("Line is not valid JSON")
+    assert errors[1]["error"] == "Each line must be a JSON object"
+    assert errors[3]["resourceType"] == "Patient" and errors[3]["error"].startswith("dob:")
+    assert "not-a-date" not in json.dumps(errors)
+    assert errors[5]["error"] == "Patient not found"
+    assert errors[6]["error"] == "Exactly one of patientId and patientMrn is required"
+
+def test_overlong_lines_are_refused(monkeypatch):
+    """Tests that a line over MAX_LINE_BYTES is reported and the following lines still imported."""
+    ctx = setup(monkeypatch, [patient_line(mrn="MRN-1", note="x" * (MAX_LINE_BYTES + 10)), patient_line(mrn="MRN-2")])
+
+    run(ctx)
+
+    job = ctx["jobs"].get("job-1")
+    assert job.imported == {"Patient": 1}
+    assert report(ctx) == [{"line": 1, "error": f"Line is longer than {MAX_LINE_BYTES} bytes"}]
+    assert ctx["patients"].get_by_mrn("MRN-2")
+
+def test_import_runs_in_chunks_with_a_report_per_chunk(monkeypatch):
+    """Tests that progress is saved after each chunk and each chunk writes its own report file."""
+    ctx = setup(monkeypatch, [patient_line(mrn=f"MRN-{i}") for i in range(5)] + ["oops"])
+
+    runs = run(ctx, chunk_lines=2)
+
+    job = ctx["jobs"].get("job-1")
+    assert runs == 3
+    assert (job.status, job.imported, job.failed) == ("completed", {"Patient": 5}, 1)
+    assert error_blob_name("job-1", 5) in ctx["bucket"].blobs
+
+def test_stale_chunk_task_is_ignored(monkeypatch):
+    """Tests that a task for an offset the job has moved past does nothing."""
+    ctx = setup(monkeypatch, [patient_line(mrn="MRN-1"), patient_line(mrn="MRN-2")])
+    next_offset = import_chunk("job-1", 0, ctx["jobs"], ctx["patients"], ctx["observations"], ctx["events"], BUCKET, 1)
+
+    assert import_chunk("job-1", 0, ctx["jobs"], ctx["patients"], ctx["observations"], ctx["events"], BUCKET, 1) is None
+    assert ctx["jobs"].get("job-1").offset == next_offset
+    assert ctx["jobs"].get("job-1").imported == {"Patient": 1}
+
+def test_rerun_of_an_unfinished_chunk_does_not_duplicate(monkeypatch):
+    """Tests that records stored by a run that did not finish count as imported when the chunk runs again."""
+    ctx = setup(monkeypatch, [patient_line(mrn="MRN-1"), heart_rate_line(patientMrn="MRN-1")])
+    ctx["jobs"].update("job-1", {"status": "in-progress", "chunkStartedAt": datetime.now(timezone.utc) - timedelta(minutes=1)})
+    patient = ctx["patients"].create(schemas.PatientCreate(givenName="Ann", familyName="Smith", dob="1975-03-02", mrn="MRN-1"))
+    reading = build_vital_observation(patient.patient_id, schemas.VitalSignCreate(code="8867-4", value=72, unit="/min", effectiveAt=TAKEN_AT))
+    ctx["observations"].create(reading.model_copy(update={"identifier": schemas.Identifier(system=import_identifier_system("job-1"), value="2")}))
+
+    run(ctx)
+
+    job = ctx["jobs"].get("job-1")
+    assert (job.imported, job.failed, job.chunk_started_at) == ({"Patient": 1, "Observation": 1}, 0, None)
+    assert len(ctx["observations"].list(patient.patient_id)) == 1
+
+def test_existing_mrn_is_a_conflict(monkeypatch):
+    """Tests that a patient whose MRN was already in use before the import is reported."""
+    ctx = setup(monkeypatch, [patient_line(mrn="MRN-1")])
+    ctx["patients"].create(schemas.PatientCreate(givenName="Ann", familyName="Smith", dob="1975-03-02", mrn="MRN-1"))
+
+    run(ctx)
+
+    assert ctx["jobs"].get("job-1").failed == 1
+    assert "already exists" in report(ctx)[0]["error"]
+
+def test_source_identifiers_skip_readings_already_imported(monkeypatch):
+    """Tests that an observation with a source identifier that is already stored is skipped."""
+    identifier = {"system": "urn:oid:1.2.3", "value": "reading-77"}
+    ctx = setup(monkeypatch, [patient_line(), heart_rate_line(patientMrn="MRN-1", identifier=identifier)])
+    run(ctx)
+    second = setup(monkeypatch, [heart_rate_line(patientMrn="MRN-1", identifier=identifier)], job_id="job-2")
+    second.update(patients=ctx["patients"], observations=ctx["observations"])
+
+    run(second, job_id="job-2")
+
+    job = second["jobs"].get("job-2")
+    assert (job.imported, job.skipped) == ({}, 1)
+
+def test_cancelled_import_stops_and_deletes_its_file(monkeypatch):
+    """Tests that a cancelled job imports nothing more and its uploaded file is removed."""
+    ctx = setup(monkeypatch, [patient_line(mrn="MRN-1")])
+    ctx["jobs"].update("job-1", {"status": "cancelled"})
+
+    assert import_chunk("job-1", 0, ctx["jobs"], ctx["patients"], ctx["observations"], ctx["events"], BUCKET, 500) is None
+    assert ctx["patients"].get_by_mrn("MRN-1") is None
+    assert input_blob_name("job-1") not in ctx["bucket"].blobs
+
+def test_missing_file_fails_the_job(monkeypatch):
+    """Tests that a job whose uploaded file has gone is marked as failed rather than retried."""
+    ctx = setup(monkeypatch, [patient_line()])
+    del ctx["bucket"].blobs[input_blob_name("job-1")]
+
+    run(ctx)
+
+    job = ctx["jobs"].get("job-1")
+    assert (job.status, job.error) == ("error", "The uploaded file is missing")
+
+# --- Endpoint Test Cases ---
+
+def test_start_import_requires_ndjson_and_a_bucket(monkeypatch):
+    """Tests that imports need IMPORT_BUCKET and an NDJSON content type."""
+    app = FastAPI()
+    app.include_router(imports_endpoint.router, prefix="/api/v1/import")
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "admin-1", "roles": ["admin"]}
+    app.dependency_overrides[get_import_job_repository] = lambda: MemoryImportJobRepository(MemoryStore())
+    app.dependency_overrides[get_task_queue] = lambda: None
+    client = TestClient(app)
+
+    monkeypatch.setattr(imports_endpoint, "IMPORT_BUCKET", None)
+    disabled = client.post("/api/v1/import", content=patient_line(), headers={"Content-Type": "application/x-ndjson"})
+    monkeypatch.setattr(imports_endpoint, "IMPORT_BUCKET", BUCKET)
+    wrong_type = client.post("/api/v1/import", content=patient_line(), headers={"Content-Type": "application/json"})
+
+    assert disabled.status_code == 503
+    assert wrong_type.status_code == 415
@@ -0,0 +1,121 @@
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+from app.api.v1 import schemas
+from app.cache.base import Cache, MemoryCache, read_through
+from app.cache.patients import CachedPatientRepository, mrn_key, patient_key
+from app.cache.redis_cache import RedisCache
+from app.repositories.patients import PatientRepository
+
+# --- Test Setup ---
+
+FAKE_PATIENT_ID = "patient-1"
+NOW = datetime(2024, 5, 1, 9, 0, tzinfo=timezone.utc)
+
+def make_patient(mrn="MRN-1", given_name="Ann"):
+    return schemas.Patient.model_validate({
+        "patientId": FAKE_PATIENT_ID,
+        "givenName": given_name,
+        "familyName": "Lee",
+        "dob": "1980-01-01",
+        "mrn": mrn,
+        "createdAt": NOW,
+        "updatedAt": NOW,
+    })
+
+class FakeClock:
+    def __init__(self):
+        self.now = 0.0
+
+    def __call__(self):
+        return self.now
+
+# --- Cache Test Cases ---
+
+def test_memory_cache_expires_entries():
+    """Tests that entries are served until their TTL and not after."""
+    clock = FakeClock()
+    cache = MemoryCache(clock=clock)
+    cache.set("patient:id:1", "value", ttl_seconds=10)
+
+    clock.now = 9.9
+    assert cache.get("patient:id:1") == "value"
+    clock.now = 10.0
+    assert cache.get("patient:id:1") is None
+
+def test_read_through_loads_once_and_skips_misses():
+    """Tests that a hit skips the loader and that None results are not cached."""
+    cache = MemoryCache()
+    load = MagicMock(return_value=make_patient())
+
+    first = read_through(cache, "patient:id:1", 60, schemas.Patient, load)
+    second = read_through(cache, "patient:id:1", 60, schemas.Patient, load)
+    missing = MagicMock(return_value=None)
+    read_through(cache, "patient:id:2", 60, schemas.Patient, missing)
+    read_through(cache, "patient:id:2", 60, schemas.Patient, missing)
+
+    assert first == second
+    load.assert_called_once()
+    assert missing.call_count == 2
+
+def test_read_through_falls_back_when_cache_is_down():
+    """Tests that cache errors are treated as misses rather than failing the request."""
+    cache = MagicMock(spec=Cache)
+    cache.get.side_effect = ConnectionError("redis unavailable")
+    cache.set.side_effect = ConnectionError("redis unavailable")
+
+    patient = read_through(cache, "patient:id:1", 60, schemas.Patient, lambda: make_patient())
+
+    assert patient.mrn == "MRN-1"
+
+def test_redis_cache_prefixes_keys_and_sets_ttl():
+    """Tests the key prefix and that TTLs are passed to Redis."""
+    client = MagicMock()
+    cache = RedisCache("redis://localhost:6379/0", key_prefix="megacare-dev", client=client)
+
+    cache.set("patient:id:1", "value", ttl_seconds=30)
+    cache.delete("patient:id:1", "patient:mrn:MRN-1")
+
+    client.set.assert_called_once_with("megacare-dev:patient:id:1", "value", ex=30)
+    client.delete.assert_called_once_with("megacare-dev:patient:id:1", "megacare-dev:patient:mrn:MRN-1")
+
+# --- Cached Repository Test Cases ---
+
+def test_cached_patient_lookup_by_mrn_hits_store_once():
+    """Tests that repeated MRN lookups are served from the cache."""
+    repo = MagicMock(spec=PatientRepository)
+    repo.get_by_mrn.return_value = make_patient()
+    cached = CachedPatientRepository(repo, MemoryCache(), ttl_seconds=60)
+
+    cached.get_by_mrn("MRN-1")
+    patient = cached.get_by_mrn("MRN-1")
+
+    assert patient.patient_id == FAKE_PATIENT_ID
+    repo.get_by_mrn.assert_called_once_with("MRN-1")
+
+def test_update_invalidates_id_and_old_and_new_mrn():
+    """Tests that changing an MRN drops the entries under both MRNs and the ID."""
+    repo = MagicMock(spec=PatientRepository)
+    cache = MemoryCache()
+    for key in (patient_key(FAKE_PATIENT_ID), mrn_key("MRN-1"), mrn_key("MRN-2")):
+        cache.set(key, make_patient().model_dump_json(by_alias=True), 60)
+    repo.get.return_value = make_patient(mrn="MRN-1")
+    repo.update.return_value = make_patient(mrn="MRN-2")
+
+    CachedPatientRepository(repo, cache, ttl_seconds=60).update(FAKE_PATIENT_ID, schemas.PatientUpdate(mrn="MRN-2"))
+
+    assert cache.get(patient_key(FAKE_PATIENT_ID)) is None
+    assert cache.get(mrn_key("MRN-1")) is None
+    assert cache.get(mrn_key("MRN-2")) is None
+
+def test_delete_invalidates_cached_patient():
+    """Tests that a deleted patient is no longer served from the cache."""
+    repo = MagicMock(spec=PatientRepository)
+    repo.get.return_value = make_patient()
+    cached = CachedPatientRepository(repo, MemoryCache(), ttl_seconds=60)
+    cached.get(FAKE_PATIENT_ID)
+
+    cached.delete(FAKE_PATIENT_ID, deleted_by="staff-1")
+    repo.get.return_value = None
+
+    assert cached.get(FAKE_PATIENT_ID) is None
@@ -0,0 +1,234 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_care_plan_repository, get_care_team_repository, get_patient_repository, get_practitioner_repository
+from app.api.v1.endpoints import care_plans
+from app.dependencies.auth import get_current_user
+from app.repositories.base import NotFoundError
+from app.repositories.care_plans import CarePlanRepository, FirestoreCarePlanRepository
+from app.repositories.care_teams import CareTeamRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.practitioners import PractitionerRepository
+from app.services.care_plans import CARE_PLAN_STATUSES
+from app.services.state_machine import InvalidTransitionError
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(care_plans.router, prefix="/api/v1/care-plans", tags=["Care Plans"])
+app.dependency_overrides[get_current_user] = lambda: {"uid": "coordinator-uid-123"}
+client = TestClient(app)
+
+FAKE_CARE_PLAN_ID = "plan-1"
+FAKE_PATIENT_ID = "patient-1"
+
+def make_care_plan(status="draft", goals=None, activities=None):
+    return schemas.CarePlan.model_validate({
+        "carePlanId": FAKE_CARE_PLAN_ID,
+        "patientId": FAKE_PATIENT_ID,
+        "title": "CPAP adherence program",
+        "periodStart": "2024-01-01",
+        "status": status,
+        "goals": goals or [],
+        "activities": activities or [],
+        "createdAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+    })
+
+@pytest.fixture
+def repos():
+    """Overrides the care plan, care team, patient and practitioner repositories with mocks."""
+    mocks = {
+        "care_plans": MagicMock(spec=CarePlanRepository),
+        "care_teams": MagicMock(spec=CareTeamRepository),
+        "patients": MagicMock(spec=PatientRepository),
+        "practitioners": MagicMock(spec=PractitionerRepository),
+    }
+    app.dependency_overrides[get_care_plan_repository] = lambda: mocks["care_plans"]
+    app.dependency_overrides[get_care_team_repository] = lambda: mocks["care_teams"]
+    app.dependency_overrides[get_patient_repository] = lambda: mocks["patients"]
+    app.dependency_overrides[get_practitioner_repository] = lambda: mocks["practitioners"]
+    yield mocks
+    for dep in (get_care_plan_repository, get_care_team_repository, get_patient_repository, get_practitioner_repository):
+        app.dependency_overrides.pop(dep, None)
+
+# --- Endpoint Test Cases ---
+
+def test_create_care_plan_success(repos):
+    """Tests creating a draft care plan with a goal and a scheduled activity."""
+    repos["care_plans"].create.return_value = make_care_plan(
+        goals=[{"goalId": "g-1", "description": "Use CPAP 4h/night"}],
+        activities=[{"activityId": "a-1", "description": "Weekly usage review", "practitionerId": "pr-1"}],
+    )
+
+    response = client.post("/api/v1/care-plans", json={
+        "patient_id": FAKE_PATIENT_ID,
+        "title": "CPAP adherence program",
+        "period_start": "2024-01-01",
+        "goals": [{"description": "Use CPAP 4h/night", "target_value": 4, "target_unit": "h"}],
+        "activities": [{
+            "description": "Weekly usage review",
+            "practitioner_id": "pr-1",
+            "schedule": {"frequency": 1, "period": "week", "start_date": "2024-01-01"},
+        }],
+    })
+
+    assert response.status_code == 201
+    assert response.json()["status"] == "draft"
+    assert response.json()["goals"][0]["goal_id"] == "g-1"
+    repos["practitioners"].get.assert_called_once_with("pr-1")
+    assert repos["care_plans"].create.call_args.kwargs["created_by"] == "coordinator-uid-123"
+
+def test_create_care_plan_unknown_patient(repos):
+    """Tests 422 when the patient does not exist."""
+    repos["patients"].get.return_value = None
+
+    response = client.post("/api/v1/care-plans", json={"patient_id": "missing", "title": "Plan", "period_start": "2024-01-01"})
+
+    assert response.status_code == 422
+    repos["care_plans"].create.assert_not_called()
+
+def test_create_care_plan_rejects_care_team_of_other_patient(repos):
+    """Tests 422 when the referenced care team belongs to a different patient."""
+    repos["care_teams"].get.return_value = MagicMock(patient_id="someone-else")
+
+    response = client.post("/api/v1/care-plans", json={
+        "patient_id": FAKE_PATIENT_ID, "title": "Plan", "period_start": "2024-01-01", "care_team_id": "team-1",
+    })
+
+    assert response.status_code == 422
+    repos["care_plans"].create.assert_not_called()
+
+def test_list_care_plans_requires_patient(repos):
+    """Tests 400 when no patientId filter is given."""
+    response = client.get("/api/v1/care-plans")
+
+    assert response.status_code == 400
+
+def test_list_care_plans_by_status(repos):
+    """Tests that the status filter is passed through to the repository."""
+    repos["care_plans"].list.return_value = [make_care_plan(status="active")]
+
+    response = client.get(f"/api/v1/care-plans?patientId={FAKE_PATIENT_ID}&status=active")
+
+    assert response.status_code == 200
+    repos["care_plans"].list.assert_called_once_with(patient_id=FAKE_PATIENT_ID, status="active", limit=31, after=None)
+
+def test_activate_care_plan(repos):
+    """Tests a legal status change, recorded with the acting user."""
+    repos["care_plans"].get.return_value = make_care_plan(status="draft")
+    repos["care_plans"].set_status.return_value = make_care_plan(status="active")
+
+    response = client.post(f"/api/v1/care-plans/{FAKE_CARE_PLAN_ID}/status", json={"status": "active"})
+
+    assert response.status_code == 200
+    repos["care_plans"].set_status.assert_called_once_with(FAKE_CARE_PLAN_ID, "active", changed_by="coordinator-uid-123", reason=None)
+
+def test_illegal_status_change_returns_409(repos):
+    """Tests 409 when a completed plan is reactivated."""
+    repos["care_plans"].get.return_value = make_care_plan(status="completed")
+
+    response = client.post(f"/api/v1/care-plans/{FAKE_CARE_PLAN_ID}/status", json={"status": "active"})
+
+    assert response.status_code == 409
+    repos["care_plans"].set_status.assert_not_called()
+
+def test_add_goal_to_revoked_plan_returns_409(repos):
+    """Tests that goals cannot be added once a plan is terminal."""
+    repos["care_plans"].get.return_value = make_care_plan(status="revoked")
+
+    response = client.post(f"/api/v1/care-plans/{FAKE_CARE_PLAN_ID}/goals", json={"description": "New goal"})
+
+    assert response.status_code == 409
+    repos["care_plans"].add_goal.assert_not_called()
+
+def test_update_care_plan_rejects_inverted_period(repos):
+    """Tests 422 when the new periodEnd falls before the stored periodStart."""
+    repos["care_plans"].get.return_value = make_care_plan(status="active")
+
+    response = client.patch(f"/api/v1/care-plans/{FAKE_CARE_PLAN_ID}", json={"period_end": "2023-06-01"})
+
+    assert response.status_code == 422
+    repos["care_plans"].update.assert_not_called()
+
+def test_delete_active_care_plan_returns_409(repos):
+    """Tests that only draft plans can be deleted."""
+    repos["care_plans"].get.return_value = make_care_plan(status="active")
+
+    response = client.delete(f"/api/v1/care-plans/{FAKE_CARE_PLAN_ID}")
+
+    assert response.status_code == 409
+    repos["care_plans"].delete.assert_not_called()
+
+# --- Status Transition Test Cases ---
+
+def test_care_plan_transitions():
+    """Tests the care plan lifecycle, including the terminal statuses."""
+    CARE_PLAN_STATUSES.ensure("draft", "active")
+    CARE_PLAN_STATUSES.ensure("on-hold", "active")
+    assert CARE_PLAN_STATUSES.is_terminal("completed")
+    for current, target in (("draft", "on-hold"), ("completed", "active"), ("revoked", "draft")):
+        with pytest.raises(InvalidTransitionError):
+            CARE_PLAN_STATUSES.ensure(current, target)
+
+# --- Firestore Repository Test Cases ---
+
+def _mock_plan_ref(mock_db, care_plan):
+    plan_ref = mock_db.collection.return_value.document.return_value
+    snapshot = MagicMock()
+    snapshot.exists = True
+    snapshot.id = FAKE_CARE_PLAN_ID
+    snapshot.to_dict.return_value = care_plan.model_dump(by_alias=True, exclude={"care_plan_id"})
+    plan_ref.get.return_value = snapshot
+    return plan_ref
+
+def test_firestore_create_assigns_item_ids_and_history():
+    """Tests that new plans start in draft with IDs on every goal and activity."""
+    mock_db = MagicMock()
+    plan_ref = _mock_plan_ref(mock_db, make_care_plan())
+    mock_db.collection.return_value.add.return_value = (None, plan_ref)
+
+    repo = FirestoreCarePlanRepository(mock_db)
+    repo.create(schemas.CarePlanCreate(
+        patient_id=FAKE_PATIENT_ID, title="Plan", period_start="2024-01-01",
+        goals=[schemas.CarePlanGoalCreate(description="Goal")],
+        activities=[schemas.CarePlanActivityCreate(description="Activity")],
+    ), created_by="coordinator-uid-123")
+
+    data = mock_db.collection.return_value.add.call_args[0][0]
+    assert data["status"] == "draft"
+    assert data["goals"][0]["goalId"]
+    assert data["activities"][0]["activityId"]
+    assert data["statusHistory"][0]["changedBy"] == "coordinator-uid-123"
+    assert isinstance(data["periodStart"], datetime)
+
+def test_firestore_update_goal_changes_only_that_goal():
+    """Tests that a goal update rewrites the goals array with just that item changed."""
+    mock_db = MagicMock()
+    plan_ref = _mock_plan_ref(mock_db, make_care_plan(goals=[
+        {"goalId": "g-1", "description": "First"},
+        {"goalId": "g-2", "description": "Second"},
+    ]))
+
+    repo = FirestoreCarePlanRepository(mock_db)
+    repo.update_goal(FAKE_CARE_PLAN_ID, "g-2", schemas.CarePlanGoalUpdate(status="achieved"))
+
+    goals = mock_db.transaction.return_value.update.call_args[0][1]["goals"]
+    assert [g["status"] for g in goals] == ["proposed", "achieved"]
+    assert goals[1]["description"] == "Second"
+
+def test_firestore_update_unknown_goal_writes_nothing():
+    """Tests that a missing goal aborts the transaction without a write."""
+    mock_db = MagicMock()
+    _mock_plan_ref(mock_db, make_care_plan(goals=[{"goalId": "g-1", "description": "First"}]))
+
+    repo = FirestoreCarePlanRepository(mock_db)
+    with pytest.raises(NotFoundError):
+        repo.update_goal(FAKE_CARE_PLAN_ID, "missing", schemas.CarePlanGoalUpdate(status="achieved"))
+
+    mock_db.transaction.return_value.update.assert_not_called()
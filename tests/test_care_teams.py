@@ -0,0 +1,134 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_care_team_repository, get_patient_repository, get_practitioner_repository
+from app.api.v1.endpoints import care_teams
+from app.dependencies.auth import get_current_user
+from app.repositories.base import ConflictError
+from app.repositories.care_teams import CareTeamRepository, FirestoreCareTeamRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.practitioners import PractitionerRepository
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(care_teams.router, prefix="/api/v1/care-teams", tags=["Care Teams"])
+app.dependency_overrides[get_current_user] = lambda: {"uid": "staff-uid-123"}
+client = TestClient(app)
+
+FAKE_CARE_TEAM_ID = "team-1"
+FAKE_PATIENT_ID = "patient-1"
+
+def make_care_team(members=None):
+    return schemas.CareTeam.model_validate({
+        "careTeamId": FAKE_CARE_TEAM_ID,
+        "patientId": FAKE_PATIENT_ID,
+        "name": "CPAP Program",
+        "members": members or [],
+        "createdAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+    })
+
+@pytest.fixture
+def repos():
+    """Overrides the care team, patient and practitioner repositories with mocks."""
+    mocks = {
+        "care_teams": MagicMock(spec=CareTeamRepository),
+        "patients": MagicMock(spec=PatientRepository),
+        "practitioners": MagicMock(spec=PractitionerRepository),
+    }
+    app.dependency_overrides[get_care_team_repository] = lambda: mocks["care_teams"]
+    app.dependency_overrides[get_patient_repository] = lambda: mocks["patients"]
+    app.dependency_overrides[get_practitioner_repository] = lambda: mocks["practitioners"]
+    yield mocks
+    for dep in (get_care_team_repository, get_patient_repository, get_practitioner_repository):
+        app.dependency_overrides.pop(dep, None)
+
+# --- Endpoint Test Cases ---
+
+def test_create_care_team_success(repos):
+    """Tests creating a care team for an existing patient and practitioner."""
+    members = [{"practitionerId": "pr-1", "role": "primary-physician"}]
+    repos["care_teams"].create.return_value = make_care_team(members)
+
+    response = client.post("/api/v1/care-teams", json={
+        "patient_id": FAKE_PATIENT_ID,
+        "name": "CPAP Program",
+        "members": [{"practitioner_id": "pr-1", "role": "primary-physician"}],
+    })
+
+    assert response.status_code == 201
+    assert response.json()["members"][0]["practitioner_id"] == "pr-1"
+    repos["patients"].get.assert_called_once_with(FAKE_PATIENT_ID)
+    repos["practitioners"].get.assert_called_once_with("pr-1")
+
+def test_create_care_team_unknown_patient(repos):
+    """Tests 422 when the patient does not exist."""
+    repos["patients"].get.return_value = None
+
+    response = client.post("/api/v1/care-teams", json={"patient_id": "missing", "name": "Team"})
+
+    assert response.status_code == 422
+    repos["care_teams"].create.assert_not_called()
+
+def test_create_care_team_unknown_practitioner(repos):
+    """Tests 422 naming the practitioner that does not exist."""
+    repos["practitioners"].get.return_value = None
+
+    response = client.post("/api/v1/care-teams", json={
+        "patient_id": FAKE_PATIENT_ID, "name": "Team",
+        "members": [{"practitioner_id": "ghost", "role": "nurse"}],
+    })
+
+    assert response.status_code == 422
+    assert "ghost" in response.json()["detail"]
+
+def test_list_care_teams_requires_filter(repos):
+    """Tests 400 when neither patientId nor practitionerId is given."""
+    response = client.get("/api/v1/care-teams")
+
+    assert response.status_code == 400
+
+def test_list_care_teams_by_practitioner(repos):
+    """Tests listing the care teams a practitioner belongs to."""
+    repos["care_teams"].list.return_value = [make_care_team()]
+
+    response = client.get("/api/v1/care-teams?practitionerId=pr-1")
+
+    assert response.status_code == 200
+    repos["care_teams"].list.assert_called_once_with(patient_id=None, practitioner_id="pr-1", limit=31, after=None)
+
+def test_add_member_conflict(repos):
+    """Tests 409 when the practitioner is already on the team."""
+    repos["care_teams"].add_member.side_effect = ConflictError("Practitioner 'pr-1' is already a member of this care team.")
+
+    response = client.post(f"/api/v1/care-teams/{FAKE_CARE_TEAM_ID}/members", json={"practitioner_id": "pr-1", "role": "nurse"})
+
+    assert response.status_code == 409
+
+# --- Firestore Repository Test Cases ---
+
+def test_firestore_add_member_keeps_member_ids_in_sync():
+    """Tests that the denormalised memberIds array is updated with the members list."""
+    mock_db = MagicMock()
+    team_ref = mock_db.collection.return_value.document.return_value
+    existing = make_care_team([{"practitionerId": "pr-1", "role": "primary-physician"}])
+    snapshot = MagicMock()
+    snapshot.exists = True
+    snapshot.id = FAKE_CARE_TEAM_ID
+    snapshot.to_dict.return_value = existing.model_dump(by_alias=True, exclude={"care_team_id"})
+    team_ref.get.return_value = snapshot
+
+    repo = FirestoreCareTeamRepository(mock_db)
+    repo.add_member(FAKE_CARE_TEAM_ID, schemas.CareTeamMember(practitioner_id="pr-2", role="nurse"))
+
+    update_ref, changes = mock_db.transaction.return_value.update.call_args[0]
+    assert update_ref is team_ref
+    assert changes["memberIds"] == ["pr-1", "pr-2"]
+    assert [m["practitionerId"] for m in changes["members"]] == ["pr-1", "pr-2"]
+    assert "updatedAt" in changes
@@ -0,0 +1,318 @@
+import json
+import pytest
+from datetime import date, datetime, timedelta, timezone
+from unittest.mock import MagicMock
+
+import httpx
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api.v1 import schemas
+from app.api.v1.deps import (
+    get_charge_item_repository,
+    get_claim_repository,
+    get_coverage_repository,
+    get_encounter_repository,
+    get_event_publisher,
+    get_practitioner_repository,
+    get_task_queue,
+)
+from app.api.v1.endpoints import charges as charge_endpoints
+from app.api.v1.endpoints import claims as claim_endpoints
+from app.authz.roles import COORDINATOR, grants, has_permission
+from app.claims.clearinghouse import ChangeHealthcareClaims, ClaimsClearinghouse, ClaimStatusReport, ClaimSubmission, SubmissionReceipt
+from app.claims.submission import refresh_claim_status, run_claim_submission
+from app.dependencies.auth import get_current_user
+from app.eligibility.clearinghouse import ClearinghouseError
+from app.repositories.memory import (
+    MemoryChargeItemRepository,
+    MemoryClaimRepository,
+    MemoryCoverageRepository,
+    MemoryEncounterRepository,
+    MemoryPatientRepository,
+    MemoryPractitionerRepository,
+    MemoryStore,
+)
+from app.services.claims import InvalidClaimError, build_claim, patient_control_number
+from app.tasks.jobs import CLAIM_SUBMISSION_TASK
+from app.tasks.queue import TaskQueue
+
+# --- Test Setup ---
+
+ADDRESS = schemas.BillingAddress(line="1 Main St", city="Austin", state="TX", postalCode="787010001")
+PROVIDER = schemas.BillingProvider(npi="1234567893", name="MegaCare Sleep Clinic", taxId="123456789", address=ADDRESS)
+
+def add_visit(store, status="finished", mrn="MRN-1"):
+    """A patient with primary coverage and an encounter of theirs."""
+    patient = MemoryPatientRepository(store).create(schemas.PatientCreate(givenName="Ann", familyName="Lee", dob="1980-01-01", sex="female", mrn=mrn))
+    coverage = MemoryCoverageRepository(store).create(
+        patient.patient_id, schemas.CoverageCreate(payerId="60054", payerName="Aetna", memberId="W123456789"), recorded_by="desk-1",
+    )
+    encounters = MemoryEncounterRepository(store)
+    encounter = encounters.create(schemas.EncounterCreate(patientId=patient.patient_id, visitType="ambulatory", start=datetime(2024, 5, 1, 9, tzinfo=timezone.utc)))
+    return patient, coverage, encounters.update(encounter.encounter_id, {"status": status})
+
+def add_charge(store, encounter, code="95810", diagnoses=("G47.33",), cents=150000):
+    charge_in = schemas.ChargeItemCreate(procedureCode=code, diagnosisCodes=list(diagnoses), chargeCents=cents)
+    return MemoryChargeItemRepository(store).create(encounter, charge_in, date(2024, 5, 1), recorded_by="desk-1")
+
+def add_claim(store, status="draft", mrn="MRN-2"):
+    _patient, coverage, encounter = add_visit(store, mrn=mrn)
+    charges = [add_charge(store, encounter), add_charge(store, encounter, "94660", ("G47.33", "E66.01"), 25000)]
+    claims = MemoryClaimRepository(store)
+    claim = claims.create(build_claim(encounter, coverage, charges, "CI"), created_by="desk-1")
+    for step in {"draft": [], "queued": ["queued"], "submitted": ["queued", "submitted"]}[status]:
+        claim = claims.change_status(claim.claim_id, step, "desk-1")
+    return claim
+
+class FakeClaimsClearinghouse(ClaimsClearinghouse):
+    name = "fake"
+
+    def __init__(self, receipt=None, report=None, error=None):
+        self.receipt = receipt or SubmissionReceipt(accepted=True, reference="CH-1")
+        self.report = report or ClaimStatusReport(status=None)
+        self.error = error
+        self.submissions = []
+
+    def submit(self, submission):
+        self.submissions.append(submission)
+        if self.error:
+            raise self.error
+        return self.receipt
+
+    def status(self, submission):
+        self.submissions.append(submission)
+        if self.error:
+            raise self.error
+        return self.report
+
+def repos(store):
+    return MemoryClaimRepository(store), MemoryCoverageRepository(store), MemoryPatientRepository(store)
+
+# --- Claim Building Test Cases ---
+
+def test_build_claim_lists_diagnoses_once_and_points_lines_at_them():
+    """Tests that shared diagnoses are listed once and each line points at its own."""
+    store = MemoryStore()
+    _patient, coverage, encounter = add_visit(store)
+    charges = [add_charge(store, encounter, diagnoses=("G47.33",)), add_charge(store, encounter, "94660", ("E6601", "G47.33"), 25000)]
+
+    fields = build_claim(encounter, coverage, charges, "CI")
+
+    assert fields["diagnosisCodes"] == ["G47.33", "E66.01"]
+    assert [line["diagnosisPointers"] for line in fields["lines"]] == [[1], [2, 1]]
+    assert (fields["placeOfService"], fields["totalChargeCents"], fields["payerId"]) == ("11", 175000, "60054")
+
+def test_build_claim_refuses_nothing_to_bill_and_too_many_diagnoses():
+    """Tests that a claim needs charge items and at most 12 diagnoses."""
+    store = MemoryStore()
+    _patient, coverage, encounter = add_visit(store)
+    charges = [add_charge(store, encounter, diagnoses=[f"G47.3{i}", f"G47.4{i}", f"G47.5{i}"]) for i in range(5)]
+
+    with pytest.raises(InvalidClaimError):
+        build_claim(encounter, coverage, [], "CI")
+    with pytest.raises(InvalidClaimError):
+        build_claim(encounter, coverage, charges, "CI")
+    assert len(build_claim(encounter, coverage, charges[:4], "CI")["diagnosisCodes"]) == 12
+
+# --- Clearinghouse Test Cases ---
+
+def change_healthcare(handler):
+    def with_token(request):
+        if request.url.path.endswith("/token"):
+            return httpx.Response(200, json={"access_token": "t-1", "expires_in": 3600})
+        return handler(request)
+    return ChangeHealthcareClaims("https://sandbox.example", "id", "secret", 10, client=httpx.Client(transport=httpx.MockTransport(with_token)))
+
+def submission_of(store, claim):
+    _claims, coverages, patients = repos(store)
+    return ClaimSubmission(
+        control_number="000000001", patient_control_number=patient_control_number(claim.claim_id), provider=PROVIDER,
+        claim=claim, coverage=coverages.get(claim.coverage_id), patient=patients.get(claim.patient_id),
+    )
+
+def test_change_healthcare_sends_the_837p():
+    """Tests that the claim is sent with its billing provider, diagnoses and service lines."""
+    store = MemoryStore()
+    claim = add_claim(store, "queued")
+    requests = []
+
+    def handler(request):
+        requests.append(request)
+        return httpx.Response(200, json={"status": "SUCCESS", "claimReference": {"correlationId": "C-42"}})
+
+    receipt = change_healthcare(handler).submit(submission_of(store, claim))
+
+    assert receipt.accepted and receipt.reference == "C-42"
+    assert requests[0].url.path == "/medicalnetwork/professionalclaims/v3/submission"
+    body = json.loads(requests[0].content)
+    assert body["billing"]["employerId"] == "123456789" and body["billing"]["address"]["postalCode"] == "787010001"
+    assert body["subscriber"]["paymentResponsibilityLevelCode"] == "P" and body["subscriber"]["memberId"] == "W123456789"
+    info = body["claimInformation"]
+    assert info["patientControlNumber"] == patient_control_number(claim.claim_id)
+    assert info["claimChargeAmount"] == "1750.00"
+    assert info["healthCareCodeInformation"] == [{"diagnosisTypeCode": "ABK", "diagnosisCode": "G4733"}, {"diagnosisTypeCode": "ABF", "diagnosisCode": "E6601"}]
+    assert [line["professionalService"]["compositeDiagnosisCodePointers"]["diagnosisCodePointers"] for line in info["serviceLines"]] == [["1"], ["1", "2"]]
+
+def test_change_healthcare_edit_failures_and_outages():
+    """Tests that failed edits are a rejection, while an outage raises to be retried."""
+    store = MemoryStore()
+    submission = submission_of(store, add_claim(store, "queued"))
+
+    def rejecting(request):
+        return httpx.Response(400, json={"errors": [{"field": "subscriber.memberId", "description": "Invalid value", "followupAction": "Correct and resubmit"}]})
+
+    receipt = change_healthcare(rejecting).submit(submission)
+    assert not receipt.accepted
+    assert [(r.description, r.follow_up) for r in receipt.rejections] == [("subscriber.memberId: Invalid value", "Correct and resubmit")]
+
+    with pytest.raises(ClearinghouseError) as raised:
+        change_healthcare(lambda request: httpx.Response(502)).submit(submission)
+    assert raised.value.retryable
+
+def test_change_healthcare_reads_the_277_for_our_claim():
+    """Tests that the status of the claim with our control number is read, with what was paid."""
+    store = MemoryStore()
+    claim = add_claim(store, "submitted")
+    ours = {"patientAccountNumber": patient_control_number(claim.claim_id), "statusCategoryCode": "F1", "statusCategoryCodeValue": "Finalized/Payment", "amountPaid": "1200.5", "tradingPartnerClaimNumber": "P-9"}
+    other = {"patientAccountNumber": "OTHER", "statusCategoryCode": "F2"}
+
+    def handler(request):
+        return httpx.Response(200, json={"claims": [{"claimStatus": other}, {"claimStatus": ours}]})
+
+    report = change_healthcare(handler).status(submission_of(store, claim))
+
+    assert (report.status, report.paid_cents, report.payer_claim_number) == ("paid", 120050, "P-9")
+
+# --- Submission Test Cases ---
+
+def test_submission_runs_once():
+    """Tests that a queued claim is submitted with its control number and not sent again."""
+    store = MemoryStore()
+    claim = add_claim(store, "queued")
+    clearinghouse = FakeClaimsClearinghouse()
+    claims, coverages, patients = repos(store)
+    practitioners = MemoryPractitionerRepository(store)
+
+    done = run_claim_submission(claim.claim_id, claims, coverages, patients, practitioners, clearinghouse, PROVIDER)
+
+    assert done.status == "submitted" and done.clearinghouse_reference == "CH-1"
+    assert done.patient_control_number == patient_control_number(claim.claim_id)
+    assert [change.by for change in done.history][-1] == "clearinghouse"
+    assert run_claim_submission(claim.claim_id, claims, coverages, patients, practitioners, clearinghouse, PROVIDER) is None
+    assert len(clearinghouse.submissions) == 1
+
+def test_submission_retries_outages_and_rejects_unfit_claims():
+    """Tests that an outage leaves the claim queued, and one without a complete billing provider is rejected."""
+    store = MemoryStore()
+    claims, coverages, patients = repos(store)
+    practitioners = MemoryPractitionerRepository(store)
+    claim = add_claim(store, "queued")
+
+    with pytest.raises(ClearinghouseError):
+        run_claim_submission(claim.claim_id, claims, coverages, patients, practitioners, FakeClaimsClearinghouse(error=ClearinghouseError("down")), PROVIDER)
+    assert claims.get(claim.claim_id).status == "queued" and claims.get(claim.claim_id).error == "down"
+
+    no_address = schemas.BillingProvider(npi="1234567893", name="MegaCare Sleep Clinic")
+    rejected = run_claim_submission(claim.claim_id, claims, coverages, patients, practitioners, FakeClaimsClearinghouse(), no_address)
+    assert rejected.status == "rejected" and "tax ID" in rejected.error
+
+def test_status_refresh_moves_claims_the_payer_settled():
+    """Tests that a payment is recorded, and that an answer saying nothing new only stamps the check."""
+    store = MemoryStore()
+    claims, coverages, patients = repos(store)
+    claim = add_claim(store, "submitted")
+    now = datetime.now(timezone.utc)
+
+    assert refresh_claim_status(claim, claims, coverages, patients, FakeClaimsClearinghouse(), PROVIDER, now) is None
+    assert claims.get(claim.claim_id).status_checked_at == now
+
+    paid = ClaimStatusReport(status="paid", category="F1", paid_cents=120050, payer_claim_number="P-9")
+    later = now + timedelta(days=1)
+    updated = refresh_claim_status(claims.get(claim.claim_id), claims, coverages, patients, FakeClaimsClearinghouse(report=paid), PROVIDER, later)
+    assert (updated.status, updated.paid_cents, updated.payer_claim_number, updated.adjudicated_at) == ("paid", 120050, "P-9", later)
+
+def test_billing_coordinators_manage_claims_and_charges():
+    """Tests that coordinators may record charges and manage claims but not edit encounters."""
+    full, _own = grants({"roles": [COORDINATOR]})
+
+    assert has_permission(full, "claims:write") and has_permission(full, "encounters.charges:write")
+    assert not has_permission(full, "encounters:write")
+
+# --- Endpoint Test Cases ---
+
+app = FastAPI()
+app.include_router(charge_endpoints.router, prefix="/api/v1/encounters")
+app.include_router(claim_endpoints.router, prefix="/api/v1/claims")
+client = TestClient(app)
+
+@pytest.fixture
+def env(monkeypatch):
+    """A finished visit in a memory store, a clearinghouse and a task queue, with a coordinator signed in."""
+    store = MemoryStore()
+    tasks = MagicMock(spec=TaskQueue)
+    monkeypatch.setattr(claim_endpoints, "get_claims_clearinghouse", lambda: FakeClaimsClearinghouse())
+    app.dependency_overrides[get_charge_item_repository] = lambda: MemoryChargeItemRepository(store)
+    app.dependency_overrides[get_claim_repository] = lambda: MemoryClaimRepository(store)
+    app.dependency_overrides[get_coverage_repository] = lambda: MemoryCoverageRepository(store)
+    app.dependency_overrides[get_encounter_repository] = lambda: MemoryEncounterRepository(store)
+    app.dependency_overrides[get_practitioner_repository] = lambda: MemoryPractitionerRepository(store)
+    app.dependency_overrides[get_event_publisher] = lambda: MagicMock()
+    app.dependency_overrides[get_task_queue] = lambda: tasks
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "desk-1", "roles": [COORDINATOR]}
+    _patient, coverage, encounter = add_visit(store)
+    yield {"store": store, "tasks": tasks, "encounter": encounter, "coverage": coverage}
+    app.dependency_overrides.clear()
+
+def test_charges_are_claimed_and_the_claim_submitted(env):
+    """Tests that a claim bills the encounter's charges and that submitting it queues the job."""
+    encounter_id = env["encounter"].encounter_id
+    charges_url = f"/api/v1/encounters/{encounter_id}/charges"
+    charge = client.post(charges_url, json={"procedureCode": "95810", "diagnosisCodes": ["g4733"], "chargeCents": 150000})
+    assert charge.status_code == 201
+    assert (charge.json()["service_date"], charge.json()["diagnosis_codes"]) == ("2024-05-01", ["G47.33"])
+
+    response = client.post("/api/v1/claims", json={"encounterId": encounter_id})
+    assert response.status_code == 201
+    claim = response.json()
+    assert (claim["status"], claim["coverage_id"], claim["total_charge_cents"]) == ("draft", env["coverage"].coverage_id, 150000)
+    assert [c["status"] for c in client.get(charges_url).json()] == ["billed"]
+    assert client.post("/api/v1/claims", json={"encounterId": encounter_id}).status_code == 409
+    assert client.delete(f"{charges_url}/{charge.json()['charge_item_id']}").status_code == 409
+
+    submitted = client.post(f"/api/v1/claims/{claim['claim_id']}/submit")
+    assert submitted.status_code == 202 and submitted.json()["status"] == "queued"
+    env["tasks"].enqueue.assert_called_once_with(CLAIM_SUBMISSION_TASK, {"claimId": claim["claim_id"]}, task_id=f"claim-submission-{claim['claim_id']}-2")
+    assert client.post(f"/api/v1/claims/{claim['claim_id']}/submit").status_code == 409
+    assert [c["claim_id"] for c in client.get("/api/v1/claims", params={"status": "queued"}).json()["items"]] == [claim["claim_id"]]
+
+def test_remittances_are_recorded_and_voids_free_the_charges(env):
+    """Tests that a paper remittance sets what the patient owes, and that voiding releases the charge items."""
+    store = env["store"]
+    claim = add_claim(store, "submitted")
+    line = claim.lines[0]
+    remittance = {
+        "status": "paid",
+        "lines": [{"chargeItemId": line.charge_item_id, "paidCents": 100000, "adjustments": [{"group": "CO", "reasonCode": "45", "amountCents": 30000}, {"group": "PR", "reasonCode": "2", "amountCents": 20000}]}],
+    }
+    paid = client.post(f"/api/v1/claims/{claim.claim_id}/status", json=remittance)
+    assert paid.status_code == 200
+    assert (paid.json()["paid_cents"], paid.json()["patient_responsibility_cents"]) == (100000, 20000)
+    assert client.post(f"/api/v1/claims/{claim.claim_id}/status", json={"status": "void"}).status_code == 409
+
+    draft = add_claim(store, mrn="MRN-3")
+    assert client.post(f"/api/v1/claims/{draft.claim_id}/status", json={"status": "void"}).json()["status"] == "void"
+    charges = MemoryChargeItemRepository(store)
+    assert {(charges.get(l.charge_item_id).status, charges.get(l.charge_item_id).claim_id) for l in draft.lines} == {("billable", None)}
+
+def test_claims_need_a_finished_encounter_and_a_clearinghouse(env, monkeypatch):
+    """Tests that unfinished visits cannot be billed and that claims are not submitted without a clearinghouse."""
+    store = env["store"]
+    _patient, _coverage, open_visit = add_visit(store, status="in-progress", mrn="MRN-3")
+    add_charge(store, open_visit)
+    assert client.post("/api/v1/claims", json={"encounterId": open_visit.encounter_id}).status_code == 409
+
+    claim = add_claim(store, mrn="MRN-4")
+    monkeypatch.setattr(claim_endpoints, "get_claims_clearinghouse", lambda: None)
+    assert client.post(f"/api/v1/claims/{claim.claim_id}/submit").status_code == 503
+    env["tasks"].enqueue.assert_not_called()
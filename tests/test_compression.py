@@ -0,0 +1,86 @@
+import gzip
+import json
+from fastapi.testclient import TestClient
+
+from fastapi import FastAPI
+from fastapi.responses import PlainTextResponse, Response, StreamingResponse
+from app.middleware.compression import CompressionMiddleware, compressible, negotiate_encoding
+
+# --- Test Setup ---
+
+ITEMS = [{"patientId": f"patient-{i}", "givenName": "Somchai", "familyName": "Jaidee"} for i in range(100)]
+
+app = FastAPI()
+
+@app.get("/patients")
+def list_patients():
+    return ITEMS
+
+@app.get("/patients/patient-1")
+def read_patient():
+    return ITEMS[0]
+
+@app.get("/export")
+def export():
+    lines = (json.dumps(item) + "\n" for item in ITEMS)
+    return StreamingResponse(lines, media_type="application/fhir+ndjson")
+
+@app.get("/document")
+def document():
+    return Response(b"%PDF-1.7" + b"\0" * 4096, media_type="application/pdf")
+
+@app.get("/events")
+def events():
+    return PlainTextResponse("data: {}\n\n" * 200, media_type="text/event-stream")
+
+client = TestClient(CompressionMiddleware(app, minimum_size=500, gzip_level=6, brotli=False))
+
+# --- Negotiation Test Cases ---
+
+def test_negotiate_encoding_respects_preference_and_quality():
+    """Tests that the most preferred accepted encoding is chosen and q=0 refuses one."""
+    assert negotiate_encoding("gzip, deflate, br", ("br", "gzip")) == "br"
+    assert negotiate_encoding("br;q=0, gzip;q=0.5", ("br", "gzip")) == "gzip"
+    assert negotiate_encoding("*", ("br", "gzip")) == "br"
+    assert negotiate_encoding("identity", ("br", "gzip")) is None
+    assert negotiate_encoding(None, ("gzip",)) is None
+
+def test_compressible_covers_json_flavours_and_text():
+    """Tests that JSON, FHIR, NDJSON and text are compressed, but not binaries or event streams."""
+    for content_type in ["application/json", "application/fhir+json; charset=utf-8", "application/problem+json", "application/x-ndjson", "text/csv"]:
+        assert compressible(content_type)
+    for content_type in ["application/pdf", "image/png", "text/event-stream", None]:
+        assert not compressible(content_type)
+
+# --- Middleware Test Cases ---
+
+def test_large_json_is_gzipped():
+    """Tests that a JSON body above the threshold is sent gzip-encoded with Vary."""
+    response = client.get("/patients", headers={"Accept-Encoding": "gzip"})
+
+    assert response.headers["Content-Encoding"] == "gzip"
+    assert "Accept-Encoding" in response.headers["Vary"]
+    assert response.json() == ITEMS
+
+def test_small_or_unaccepted_responses_are_sent_as_is():
+    """Tests that small bodies and clients without gzip get uncompressed responses."""
+    small = client.get("/patients/patient-1", headers={"Accept-Encoding": "gzip"})
+    identity = client.get("/patients", headers={"Accept-Encoding": "identity"})
+
+    assert "Content-Encoding" not in small.headers
+    assert "Content-Encoding" not in identity.headers
+    assert identity.json() == ITEMS
+
+def test_streamed_ndjson_is_compressed_as_it_is_sent():
+    """Tests that a streamed export is compressed without a Content-Length."""
+    with client.stream("GET", "/export", headers={"Accept-Encoding": "gzip"}) as response:
+        raw = b"".join(response.iter_raw())
+
+    assert response.headers["Content-Encoding"] == "gzip"
+    assert "Content-Length" not in response.headers
+    assert [json.loads(line) for line in gzip.decompress(raw).splitlines()] == ITEMS
+
+def test_binary_and_event_stream_responses_are_not_compressed():
+    """Tests that content types outside JSON and text are left alone."""
+    assert "Content-Encoding" not in client.get("/document", headers={"Accept-Encoding": "gzip"}).headers
+    assert "Content-Encoding" not in client.get("/events", headers={"Accept-Encoding": "gzip"}).headers
@@ -0,0 +1,208 @@
+import itertools
+
+from fastapi.testclient import TestClient
+
+from fastapi import FastAPI, HTTPException
+from fastapi.responses import JSONResponse
+from app.api.v1 import schemas
+from app.errors.handlers import register_exception_handlers
+from app.middleware.conditional import ConditionalRequestMiddleware, any_match, body_etag, body_version, none_match, parse_etags, tag_version
+from app.repositories.memory import MemoryPatientRepository, MemoryStore
+
+# --- Test Setup ---
+
+care_plans = {"cp-1": {"carePlanId": "cp-1", "title": "CPAP adherence", "status": "active"}}
+writes = []
+
+app = FastAPI()
+
+@app.get("/care-plans/{carePlanId}")
+def read_care_plan(carePlanId: str):
+    if carePlanId not in care_plans:
+        raise HTTPException(status_code=404, detail="Care plan not found")
+    return care_plans[carePlanId]
+
+@app.patch("/care-plans/{carePlanId}")
+def update_care_plan(carePlanId: str, changes: dict):
+    writes.append(carePlanId)
+    care_plans[carePlanId] = {**care_plans[carePlanId], **changes}
+    return care_plans[carePlanId]
+
+@app.get("/versioned")
+def versioned():
+    return JSONResponse({"id": "v"}, headers={"ETag": 'W/"7"'})
+
+@app.get("/large")
+def large():
+    return {"data": "x" * 200}
+
+client = TestClient(ConditionalRequestMiddleware(app))
+small_client = TestClient(ConditionalRequestMiddleware(app, max_body_bytes=100))
+
+patients = MemoryPatientRepository(MemoryStore())
+mrns = itertools.count(1)
+
+versioned_app = FastAPI()
+register_exception_handlers(versioned_app)
+
+@versioned_app.get("/patients/{patientId}", response_model=schemas.Patient, response_model_by_alias=False)
+def read_patient(patientId: str):
+    return patients.get(patientId)
+
+@versioned_app.patch("/patients/{patientId}", response_model=schemas.Patient, response_model_by_alias=False)
+def update_patient(patientId: str, patient_in: schemas.PatientUpdate):
+    return patients.update(patientId, patient_in)
+
+@versioned_app.delete("/patients/{patientId}", status_code=204)
+def delete_patient(patientId: str):
+    patients.delete(patientId, deleted_by="staff-1")
+
+versioned_app.add_middleware(ConditionalRequestMiddleware)
+versioned_client = TestClient(versioned_app)
+
+def make_patient() -> str:
+    return patients.create(schemas.PatientCreate(givenName="Ann", familyName="Lee", dob="1980-01-01", mrn=f"MRN-{next(mrns)}")).patient_id
+
+def reset():
+    care_plans["cp-1"] = {"carePlanId": "cp-1", "title": "CPAP adherence", "status": "active"}
+    writes.clear()
+
+# --- Comparison Test Cases ---
+
+def test_etags_are_strong_and_stable():
+    """Tests that the same body always gets the same quoted tag and different bodies differ."""
+    assert body_etag(b"{}") == body_etag(b"{}")
+    assert body_etag(b"{}") != body_etag(b"[]")
+    assert body_etag(b"{}").startswith('"')
+
+def test_if_none_match_is_weak_and_if_match_is_strong():
+    """Tests the comparison functions of RFC 9110: weak for If-None-Match, strong for If-Match."""
+    assert parse_etags('"a", W/"b"') == ['"a"', 'W/"b"']
+    assert none_match(['W/"a"'], '"a"')
+    assert none_match(["*"], '"a"')
+    assert any_match(['"a"'], '"a"')
+    assert not any_match(['W/"a"'], 'W/"a"')
+    assert not any_match(["*"], None)
+
+def test_versions_are_read_from_tags_and_bodies():
+    """Tests that only a single W/"<n>" If-Match names a version, and a body's integer `version` does."""
+    assert tag_version(['W/"3"']) == 3
+    assert tag_version(['"3"']) is None
+    assert tag_version(['W/"3"', 'W/"4"']) is None
+    assert tag_version(["*"]) is None
+    assert body_version(b'{"version": 2, "given_name": "Ann"}') == 2
+    assert body_version(b'{"version": true}') is None
+    assert body_version(b'[{"version": 2}]') is None
+    assert body_version(b"not json") is None
+
+# --- Read Test Cases ---
+
+def test_get_returns_etag_and_304_when_unchanged():
+    """Tests that a GET carries an ETag and a matching If-None-Match gets 304 without a body."""
+    reset()
+    first = client.get("/care-plans/cp-1")
+    etag = first.headers["ETag"]
+
+    revalidated = client.get("/care-plans/cp-1", headers={"If-None-Match": etag})
+
+    assert revalidated.status_code == 304
+    assert revalidated.content == b""
+    assert revalidated.headers["ETag"] == etag
+    assert client.get("/care-plans/cp-1", headers={"If-None-Match": '"stale"'}).status_code == 200
+
+def test_own_etags_and_large_bodies_are_left_alone():
+    """Tests that handlers' own ETags are kept and bodies over the limit are not tagged."""
+    assert client.get("/versioned").headers["ETag"] == 'W/"7"'
+    assert client.get("/versioned", headers={"If-None-Match": 'W/"7"'}).status_code == 200
+
+    response = small_client.get("/large")
+    assert response.status_code == 200
+    assert "ETag" not in response.headers
+    assert response.json() == {"data": "x" * 200}
+
+def test_error_responses_have_no_etag():
+    """Tests that only successful reads are tagged."""
+    assert "ETag" not in client.get("/care-plans/missing").headers
+
+# --- Write Test Cases ---
+
+def test_write_with_current_etag_is_applied():
+    """Tests that If-Match with the current ETag lets the update through."""
+    reset()
+    etag = client.get("/care-plans/cp-1").headers["ETag"]
+
+    response = client.patch("/care-plans/cp-1", json={"status": "completed"}, headers={"If-Match": etag})
+
+    assert response.status_code == 200
+    assert writes == ["cp-1"]
+
+def test_lost_update_is_refused_with_412():
+    """Tests that a write based on an outdated read is refused and not applied."""
+    reset()
+    etag = client.get("/care-plans/cp-1").headers["ETag"]
+    client.patch("/care-plans/cp-1", json={"title": "Mask refit"}, headers={"If-Match": etag})
+
+    stale = client.patch("/care-plans/cp-1", json={"status": "revoked"}, headers={"If-Match": etag})
+
+    assert stale.status_code == 412
+    assert stale.headers["ETag"] == client.get("/care-plans/cp-1").headers["ETag"]
+    assert care_plans["cp-1"]["status"] == "active"
+    assert writes == ["cp-1"]
+
+def test_if_match_on_missing_resource_is_refused():
+    """Tests that If-Match fails for a resource that does not exist, even with `*`."""
+    reset()
+
+    assert client.patch("/care-plans/missing", json={}, headers={"If-Match": "*"}).status_code == 412
+    assert writes == []
+
+def test_write_without_if_match_is_unconditional():
+    """Tests that clients not sending If-Match keep today's behaviour."""
+    reset()
+
+    assert client.patch("/care-plans/cp-1", json={"status": "completed"}).status_code == 200
+
+# --- Version Test Cases ---
+
+def test_versioned_resources_are_tagged_with_their_version():
+    """Tests that a resource with a version gets W/"<version>" as its ETag, revalidated as usual."""
+    patient_id = make_patient()
+
+    response = versioned_client.get(f"/patients/{patient_id}")
+
+    assert response.headers["ETag"] == 'W/"1"'
+    assert versioned_client.get(f"/patients/{patient_id}", headers={"If-None-Match": 'W/"1"'}).status_code == 304
+
+def test_updates_naming_the_current_version_are_applied():
+    """Tests that If-Match or a body `version` with the current version lets the update through."""
+    patient_id = make_patient()
+
+    by_header = versioned_client.patch(f"/patients/{patient_id}", json={"given_name": "Anna"}, headers={"If-Match": 'W/"1"'})
+    by_body = versioned_client.patch(f"/patients/{patient_id}", json={"given_name": "Anne", "version": 2})
+
+    assert by_header.status_code == by_body.status_code == 200
+    assert by_body.json()["version"] == 3
+    assert patients.get(patient_id).given_name == "Anne"
+
+def test_stale_versions_are_refused_with_409():
+    """Tests that a write based on an old version is refused without being applied."""
+    patient_id = make_patient()
+    versioned_client.patch(f"/patients/{patient_id}", json={"given_name": "Anna"}, headers={"If-Match": 'W/"1"'})
+
+    stale = versioned_client.patch(f"/patients/{patient_id}", json={"given_name": "Stale"}, headers={"If-Match": 'W/"1"'})
+    gone = versioned_client.delete(f"/patients/{patient_id}", headers={"If-Match": 'W/"1"'})
+
+    assert stale.status_code == gone.status_code == 409
+    assert stale.json()["type"] == "urn:megacare:problem:version-conflict"
+    assert patients.get(patient_id).given_name == "Anna"
+
+def test_updates_without_a_version_are_refused_with_428():
+    """Tests that updating a versioned resource must name a version, while deleting need not."""
+    patient_id = make_patient()
+
+    response = versioned_client.patch(f"/patients/{patient_id}", json={"given_name": "Anna"})
+
+    assert response.status_code == 428
+    assert response.json()["type"] == "urn:megacare:problem:precondition-required"
+    assert patients.get(patient_id).given_name == "Ann"
+    assert versioned_client.delete(f"/patients/{patient_id}").status_code == 204
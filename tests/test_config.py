@@ -0,0 +1,253 @@
+import pytest
+from unittest.mock import MagicMock
+from pydantic import ValidationError
+
+from app.core.config import Settings, resolve_secret_references
+
+# --- Helpers ---
+
+def fake_secret_client(payloads):
+    """Returns a Secret Manager client mock that serves `payloads` keyed by version name."""
+    client = MagicMock()
+    def access_secret_version(request):
+        response = MagicMock()
+        response.payload.data = payloads[request["name"]].encode("utf-8")
+        return response
+    client.access_secret_version.side_effect = access_secret_version
+    return client
+
+# --- Test Cases ---
+
+def test_defaults_for_local_development(monkeypatch):
+    """Tests text logs and disabled tracing when not running on Cloud Run."""
+    monkeypatch.delenv("K_SERVICE", raising=False)
+
+    settings = Settings(_env_file=None)
+
+    assert settings.log_level == "INFO"
+    assert settings.log_format == "text"
+    assert settings.tracing_enabled is False
+    assert settings.service_name == "mega-care-api"
+
+def test_cloud_run_defaults(monkeypatch):
+    """Tests that JSON logs and tracing are enabled when K_SERVICE is set."""
+    monkeypatch.setenv("K_SERVICE", "mega-care-api")
+    monkeypatch.setenv("LOG_LEVEL", "debug")
+
+    settings = Settings(_env_file=None)
+
+    assert settings.log_level == "DEBUG"
+    assert settings.log_format == "json"
+    assert settings.tracing_enabled is True
+
+def test_invalid_values_fail_fast(monkeypatch):
+    """Tests that out-of-range values are rejected at load time."""
+    monkeypatch.setenv("TRACING_SAMPLE_RATIO", "1.5")
+
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+def test_resolves_secret_manager_references(monkeypatch):
+    """Tests that sm:// references are replaced with the secret payload."""
+    monkeypatch.setenv("GOOGLE_CLOUD_PROJECT", "mega-care-dev")
+    monkeypatch.setenv("LINE_CHANNEL_ID", "1234567890")
+    monkeypatch.setenv("LINE_CHANNEL_SECRET", "sm://line-channel-secret")
+    monkeypatch.setenv("HEALTH_SECRET_NAME", "sm://projects/other/secrets/probe/versions/3")
+    client = fake_secret_client({
+        "projects/mega-care-dev/secrets/line-channel-secret/versions/latest": "s3cret",
+        "projects/other/secrets/probe/versions/3": "probe-value",
+    })
+
+    settings = resolve_secret_references(Settings(_env_file=None), client=client)
+
+    assert settings.line_channel_id == "1234567890"
+    assert settings.line_channel_secret == "s3cret"
+    assert settings.health_secret_name == "probe-value"
+
+def test_no_secret_lookup_without_references(monkeypatch):
+    """Tests that Secret Manager is never called when no references are configured."""
+    monkeypatch.setenv("LINE_CHANNEL_SECRET", "plain-value")
+    client = MagicMock()
+
+    settings = resolve_secret_references(Settings(_env_file=None), client=client)
+
+    assert settings.line_channel_secret == "plain-value"
+    client.access_secret_version.assert_not_called()
+
+def test_invalid_hl7v2_timezone_fails_fast(monkeypatch):
+    """Tests that an unknown HL7V2_DEFAULT_TIMEZONE is rejected at start-up."""
+    monkeypatch.setenv("HL7V2_DEFAULT_TIMEZONE", "Mars/Olympus")
+
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+def test_missing_immunization_schedule_fails_fast(monkeypatch, tmp_path):
+    """Tests that IMMUNIZATION_SCHEDULE_PATH must point at an existing file."""
+    monkeypatch.setenv("IMMUNIZATION_SCHEDULE_PATH", str(tmp_path / "missing.json"))
+
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+def test_postgres_store_requires_database_url(monkeypatch):
+    """Tests that STORE=postgres without DATABASE_URL is rejected at start-up."""
+    monkeypatch.setenv("STORE", "postgres")
+    monkeypatch.delenv("DATABASE_URL", raising=False)
+
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+def test_cors_lists_are_read_from_comma_separated_values(monkeypatch):
+    """Tests that CORS origins, methods and headers are split into lists."""
+    monkeypatch.setenv("CORS_ALLOW_ORIGINS", "https://portal.megacare.example, https://admin.megacare.example")
+    monkeypatch.setenv("CORS_ALLOW_METHODS", "GET,POST")
+
+    settings = Settings(_env_file=None)
+
+    assert settings.cors_origins == ["https://portal.megacare.example", "https://admin.megacare.example"]
+    assert settings.cors_methods == ["GET", "POST"]
+    assert settings.cors_headers == ["*"]
+
+def test_cors_credentials_require_explicit_origins(monkeypatch):
+    """Tests that credentialed CORS with a wildcard origin is rejected at start-up."""
+    monkeypatch.setenv("CORS_ALLOW_CREDENTIALS", "true")
+    monkeypatch.delenv("CORS_ALLOW_ORIGINS", raising=False)
+
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+def test_default_api_version_must_be_served(monkeypatch):
+    """Tests that API_DEFAULT_VERSION is normalised, and an unserved version is rejected at start-up."""
+    monkeypatch.setenv("API_DEFAULT_VERSION", "1")
+    assert Settings(_env_file=None).api_default_version == "v1"
+
+    monkeypatch.setenv("API_DEFAULT_VERSION", "v9")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+def test_malformed_feature_flags_fail_fast(monkeypatch):
+    """Tests that FEATURE_FLAGS is checked at start-up."""
+    monkeypatch.setenv("FEATURE_FLAGS", "telehealth-visits=off:clinic-a,new-reports=25")
+    assert Settings(_env_file=None).feature_flags == "telehealth-visits=off:clinic-a,new-reports=25"
+
+    monkeypatch.setenv("FEATURE_FLAGS", "new-reports=half")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+def test_field_encryption_key_must_name_a_kms_key(monkeypatch):
+    """Tests that FIELD_ENCRYPTION_KEY is a CryptoKey name, not one of its versions."""
+    key = "projects/megacare/locations/asia-southeast1/keyRings/api/cryptoKeys/fields"
+    monkeypatch.setenv("FIELD_ENCRYPTION_KEY", key)
+    assert Settings(_env_file=None).field_encryption_key == key
+
+    monkeypatch.setenv("FIELD_ENCRYPTION_KEY", f"{key}/cryptoKeyVersions/1")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+def test_malformed_retention_defaults_fail_fast(monkeypatch):
+    """Tests that RETENTION_DEFAULTS is checked at start-up."""
+    monkeypatch.setenv("RETENTION_DEFAULTS", "telemetry=730,audit=2190:anonymize")
+    assert Settings(_env_file=None).retention_defaults == "telemetry=730,audit=2190:anonymize"
+
+    monkeypatch.setenv("RETENTION_DEFAULTS", "billing=30")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+def test_email_provider_needs_its_settings(monkeypatch):
+    """Tests that an email provider without a sender address or its credentials fails at start-up."""
+    monkeypatch.setenv("EMAIL_PROVIDER", "sendgrid")
+    monkeypatch.setenv("SENDGRID_API_KEY", "SG.key")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+    monkeypatch.setenv("EMAIL_FROM_ADDRESS", "no-reply@megacare.example")
+    assert Settings(_env_file=None).email_provider == "sendgrid"
+
+    monkeypatch.setenv("EMAIL_PROVIDER", "smtp")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+def test_twilio_needs_credentials_and_a_sender(monkeypatch):
+    """Tests that SMS_PROVIDER=twilio needs the account credentials and a number or messaging service."""
+    monkeypatch.setenv("SMS_PROVIDER", "twilio")
+    monkeypatch.setenv("TWILIO_ACCOUNT_SID", "AC123")
+    monkeypatch.setenv("TWILIO_AUTH_TOKEN", "token")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+    monkeypatch.setenv("TWILIO_MESSAGING_SERVICE_SID", "MG123")
+    assert Settings(_env_file=None).sms_provider == "twilio"
+
+def test_reminder_hours_and_quiet_hours_are_parsed(monkeypatch):
+    """Tests that reminder offsets come longest first and quiet hours parse, and bad values fail at start-up."""
+    monkeypatch.setenv("APPOINTMENT_REMINDER_HOURS", "2, 48,2")
+    monkeypatch.setenv("APPOINTMENT_REMINDER_QUIET_HOURS", "22-7")
+    settings = Settings(_env_file=None)
+    assert settings.reminder_offsets_hours == [48, 2]
+    assert settings.reminder_quiet_hours == (22, 7)
+
+    monkeypatch.setenv("APPOINTMENT_REMINDER_QUIET_HOURS", "")
+    assert Settings(_env_file=None).reminder_quiet_hours is None
+
+    monkeypatch.setenv("APPOINTMENT_REMINDER_HOURS", "0")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+    monkeypatch.setenv("APPOINTMENT_REMINDER_HOURS", "48")
+    monkeypatch.setenv("APPOINTMENT_REMINDER_QUIET_HOURS", "9pm-8am")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+def test_websocket_settings_are_checked(monkeypatch):
+    """Tests that the Redis backend needs REDIS_URL and the idle timeout must outlast a heartbeat."""
+    monkeypatch.setenv("WEBSOCKET_BACKEND", "redis")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+    monkeypatch.setenv("REDIS_URL", "redis://localhost:6379/0")
+    assert Settings(_env_file=None).websocket_backend == "redis"
+
+    monkeypatch.setenv("WEBSOCKET_IDLE_TIMEOUT_SECONDS", "20")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+def test_telehealth_provider_needs_its_credentials(monkeypatch):
+    """Tests that each video provider requires its API credentials."""
+    monkeypatch.setenv("TELEHEALTH_PROVIDER", "livekit")
+    monkeypatch.setenv("LIVEKIT_URL", "wss://megacare.livekit.cloud")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+    monkeypatch.setenv("LIVEKIT_API_KEY", "APIkey")
+    monkeypatch.setenv("LIVEKIT_API_SECRET", "secret")
+    assert Settings(_env_file=None).telehealth_provider == "livekit"
+
+    monkeypatch.setenv("TELEHEALTH_PROVIDER", "daily")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+def test_analytics_sink_needs_a_destination_and_pseudonym_key(monkeypatch):
+    """Tests that an analytics sink requires its table or topic and the pseudonym key."""
+    monkeypatch.setenv("ANALYTICS_SINK", "bigquery")
+    monkeypatch.setenv("ANALYTICS_TABLE", "mega-care-dev.analytics.events")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+    monkeypatch.setenv("ANALYTICS_PSEUDONYM_KEY", "secret")
+    assert Settings(_env_file=None).analytics_sink == "bigquery"
+
+    monkeypatch.setenv("ANALYTICS_SINK", "pubsub")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+def test_elasticsearch_index_needs_a_url(monkeypatch):
+    """Tests that SEARCH_INDEX=elasticsearch requires ELASTICSEARCH_URL, and the embedded index nothing."""
+    monkeypatch.setenv("SEARCH_INDEX", "elasticsearch")
+    with pytest.raises(ValidationError):
+        Settings(_env_file=None)
+
+    monkeypatch.setenv("ELASTICSEARCH_URL", "https://search.example:9200")
+    assert Settings(_env_file=None).search_index == "elasticsearch"
+
+    monkeypatch.delenv("ELASTICSEARCH_URL")
+    monkeypatch.setenv("SEARCH_INDEX", "embedded")
+    assert Settings(_env_file=None).search_index_path == ":memory:"
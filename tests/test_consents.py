@@ -0,0 +1,219 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_consent_document_repository, get_consent_repository, get_patient_repository
+from app.api.v1.endpoints import consent_documents, consents
+from app.dependencies.auth import get_current_user
+from app.repositories.consents import ConsentDocumentRepository, ConsentRepository, FirestoreConsentRepository
+from app.repositories.patients import PatientRepository
+from app.services.consents import ConsentRequiredError, check_consent, require_consent
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(consents.router, prefix="/api/v1/patients", tags=["Consents"])
+app.include_router(consent_documents.router, prefix="/api/v1/consent-documents", tags=["Consents"])
+app.dependency_overrides[get_current_user] = lambda: {"uid": "patient-uid-123"}
+client = TestClient(app)
+
+FAKE_PATIENT_ID = "patient-1"
+FAKE_CONSENT_ID = "consent-1"
+CONSENTS_URL = f"/api/v1/patients/{FAKE_PATIENT_ID}/consents"
+
+def make_document(version=2, scope="research", material_change=False):
+    return schemas.ConsentDocument.model_validate({
+        "documentId": f"doc-v{version}",
+        "scope": scope,
+        "title": "Research use of sleep data",
+        "body": "...",
+        "materialChange": material_change,
+        "version": version,
+        "publishedBy": "admin-uid",
+        "createdAt": datetime(2024, 1, version, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 1, version, tzinfo=timezone.utc),
+    })
+
+def make_consent(consent_id=FAKE_CONSENT_ID, version=2, status="active", scope="research", organization_id=None, expires_at=None):
+    return schemas.Consent.model_validate({
+        "consentId": consent_id,
+        "patientId": FAKE_PATIENT_ID,
+        "scope": scope,
+        "organizationId": organization_id,
+        "documentId": f"doc-v{version}",
+        "documentVersion": version,
+        "status": status,
+        "grantedAt": datetime(2024, 2, 1, tzinfo=timezone.utc),
+        "grantedBy": "patient-uid-123",
+        "expiresAt": expires_at,
+        "createdAt": datetime(2024, 2, 1, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 2, 1, tzinfo=timezone.utc),
+    })
+
+@pytest.fixture
+def repos():
+    """Overrides the consent, consent document and patient repositories with mocks."""
+    mocks = {
+        "consents": MagicMock(spec=ConsentRepository),
+        "documents": MagicMock(spec=ConsentDocumentRepository),
+        "patients": MagicMock(spec=PatientRepository),
+    }
+    mocks["documents"].get.return_value = make_document()
+    mocks["documents"].list.return_value = [make_document(2), make_document(1)]
+    mocks["consents"].list.return_value = []
+    app.dependency_overrides[get_consent_repository] = lambda: mocks["consents"]
+    app.dependency_overrides[get_consent_document_repository] = lambda: mocks["documents"]
+    app.dependency_overrides[get_patient_repository] = lambda: mocks["patients"]
+    yield mocks
+    for dep in (get_consent_repository, get_consent_document_repository, get_patient_repository):
+        app.dependency_overrides.pop(dep, None)
+
+# --- Endpoint Test Cases ---
+
+def test_grant_consent_success(repos):
+    """Tests granting consent to the latest document of a scope."""
+    repos["consents"].create.return_value = make_consent()
+
+    response = client.post(CONSENTS_URL, json={"scope": "research", "document_id": "doc-v2"})
+
+    assert response.status_code == 201
+    assert response.json()["document_version"] == 2
+    assert repos["consents"].create.call_args.kwargs["granted_by"] == "patient-uid-123"
+
+def test_grant_consent_to_outdated_document_returns_409(repos):
+    """Tests that consent can only be given to the latest version."""
+    repos["documents"].get.return_value = make_document(1)
+
+    response = client.post(CONSENTS_URL, json={"scope": "research", "document_id": "doc-v1"})
+
+    assert response.status_code == 409
+    repos["consents"].create.assert_not_called()
+
+def test_grant_consent_supersedes_previous_version(repos):
+    """Tests that re-consenting to a new version supersedes the old consent."""
+    repos["consents"].list.return_value = [make_consent("consent-old", version=1)]
+    repos["consents"].create.return_value = make_consent()
+
+    response = client.post(CONSENTS_URL, json={"scope": "research", "document_id": "doc-v2"})
+
+    assert response.status_code == 201
+    repos["consents"].update.assert_called_once_with("consent-old", {"status": "superseded"})
+
+def test_grant_data_sharing_requires_organization(repos):
+    """Tests 422 when data-sharing consent does not name the recipient."""
+    response = client.post(CONSENTS_URL, json={"scope": "data-sharing", "document_id": "doc-v2"})
+
+    assert response.status_code == 422
+
+def test_grant_consent_document_of_other_scope_returns_422(repos):
+    """Tests that the document must belong to the scope being consented to."""
+    repos["documents"].get.return_value = make_document(scope="marketing")
+
+    response = client.post(CONSENTS_URL, json={"scope": "research", "document_id": "doc-v2"})
+
+    assert response.status_code == 422
+
+def test_revoke_consent(repos):
+    """Tests revoking an active consent keeps the record with revocation details."""
+    repos["consents"].get.return_value = make_consent()
+    repos["consents"].update.return_value = make_consent(status="revoked")
+
+    response = client.post(f"{CONSENTS_URL}/{FAKE_CONSENT_ID}/revoke", json={"reason": "No longer interested"})
+
+    assert response.status_code == 200
+    changes = repos["consents"].update.call_args[0][1]
+    assert changes["status"] == "revoked"
+    assert changes["revokedBy"] == "patient-uid-123"
+
+def test_revoke_revoked_consent_returns_409(repos):
+    """Tests that only active consents can be revoked."""
+    repos["consents"].get.return_value = make_consent(status="revoked")
+
+    response = client.post(f"{CONSENTS_URL}/{FAKE_CONSENT_ID}/revoke", json={})
+
+    assert response.status_code == 409
+
+def test_check_consent_endpoint(repos):
+    """Tests the consent check for a scope."""
+    repos["consents"].list.return_value = [make_consent()]
+
+    response = client.get(f"{CONSENTS_URL}/check?scope=research")
+
+    assert response.status_code == 200
+    assert response.json() == {"allowed": True, "reason": "consented", "consent_id": FAKE_CONSENT_ID}
+
+def test_check_data_sharing_requires_organization(repos):
+    """Tests 400 when a data-sharing check does not name the recipient."""
+    response = client.get(f"{CONSENTS_URL}/check?scope=data-sharing")
+
+    assert response.status_code == 400
+
+def test_publish_consent_document(repos):
+    """Tests publishing a new consent document version."""
+    repos["documents"].create.return_value = make_document(3)
+
+    response = client.post("/api/v1/consent-documents", json={"scope": "research", "title": "Research use", "body": "...", "material_change": True})
+
+    assert response.status_code == 201
+    assert response.json()["version"] == 3
+
+# --- Consent Check Test Cases ---
+
+def test_check_consent_requires_reconsent_after_material_change():
+    """Tests that consents to versions before a material change no longer count."""
+    consents_repo = MagicMock(spec=ConsentRepository)
+    documents_repo = MagicMock(spec=ConsentDocumentRepository)
+    consents_repo.list.return_value = [make_consent(version=1)]
+    documents_repo.list.return_value = [make_document(2, material_change=True), make_document(1)]
+
+    decision = check_consent(consents_repo, documents_repo, FAKE_PATIENT_ID, "research")
+
+    assert not decision.allowed
+    assert decision.reason == "reconsent-required"
+
+def test_check_consent_ignores_expired_consents():
+    """Tests that expired consents do not allow access."""
+    consents_repo = MagicMock(spec=ConsentRepository)
+    documents_repo = MagicMock(spec=ConsentDocumentRepository)
+    consents_repo.list.return_value = [make_consent(expires_at=datetime(2024, 3, 1, tzinfo=timezone.utc))]
+    documents_repo.list.return_value = [make_document(2)]
+
+    decision = check_consent(consents_repo, documents_repo, FAKE_PATIENT_ID, "research", now=datetime(2024, 4, 1, tzinfo=timezone.utc))
+
+    assert decision.reason == "expired"
+
+def test_require_consent_raises_without_consent():
+    """Tests the helper other handlers call before releasing data."""
+    consents_repo = MagicMock(spec=ConsentRepository)
+    documents_repo = MagicMock(spec=ConsentDocumentRepository)
+    consents_repo.list.return_value = []
+
+    with pytest.raises(ConsentRequiredError) as exc_info:
+        require_consent(consents_repo, documents_repo, FAKE_PATIENT_ID, "data-sharing", organization_id="org-1")
+
+    assert exc_info.value.decision.reason == "no-consent"
+    consents_repo.list.assert_called_once_with(FAKE_PATIENT_ID, scope="data-sharing", organization_id="org-1", status="active")
+
+# --- Firestore Repository Test Cases ---
+
+def test_firestore_create_consent_records_document_version():
+    """Tests that a new consent stores the version of the document agreed to."""
+    mock_db = MagicMock()
+    consent_ref = MagicMock()
+    snapshot = MagicMock()
+    snapshot.id = FAKE_CONSENT_ID
+    snapshot.to_dict.return_value = make_consent().model_dump(by_alias=True, exclude={"consent_id"})
+    consent_ref.get.return_value = snapshot
+    mock_db.collection.return_value.add.return_value = (None, consent_ref)
+
+    repo = FirestoreConsentRepository(mock_db)
+    repo.create(FAKE_PATIENT_ID, schemas.ConsentGrant(scope="research", document_id="doc-v2"), make_document(2), granted_by="patient-uid-123")
+
+    data = mock_db.collection.return_value.add.call_args[0][0]
+    assert data["documentVersion"] == 2
+    assert data["status"] == "active"
+    assert data["patientId"] == FAKE_PATIENT_ID
@@ -0,0 +1,259 @@
+import json
+import pytest
+from datetime import date, datetime, timedelta, timezone
+from unittest.mock import MagicMock
+
+import httpx
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_coverage_repository, get_eligibility_check_repository, get_event_publisher, get_patient_repository, get_task_queue
+from app.api.v1.endpoints import coverage as coverage_endpoints
+from app.authz.roles import COORDINATOR, grants, has_permission
+from app.core.config import get_settings
+from app.dependencies.auth import get_current_user
+from app.eligibility.checks import control_number, reusable_check, run_eligibility_check
+from app.eligibility.clearinghouse import (
+    ChangeHealthcareClearinghouse,
+    Clearinghouse,
+    ClearinghouseError,
+    EligibilityAnswer,
+    EligibilityInquiry,
+    summarize_benefits,
+)
+from app.repositories.memory import MemoryCoverageRepository, MemoryEligibilityCheckRepository, MemoryPatientRepository, MemoryStore
+from app.tasks.jobs import ELIGIBILITY_CHECK_TASK
+from app.tasks.queue import TaskQueue
+
+# --- Test Setup ---
+
+PROVIDER = schemas.BillingProvider(npi="1234567893", name="MegaCare Sleep Clinic")
+
+def add_patient(store):
+    return MemoryPatientRepository(store).create(schemas.PatientCreate(givenName="Ann", familyName="Lee", dob="1980-01-01", sex="female", mrn="MRN-1"))
+
+def add_coverage(store, patient_id, **fields):
+    data = {"payerId": "60054", "payerName": "Aetna", "memberId": "W123456789", "groupNumber": "G-1", **fields}
+    return MemoryCoverageRepository(store).create(patient_id, schemas.CoverageCreate.model_validate(data), recorded_by="desk-1")
+
+def start_check(store, coverage, service_types=("30",)):
+    check_in = schemas.EligibilityCheckCreate(serviceTypes=list(service_types))
+    return MemoryEligibilityCheckRepository(store).create(coverage, check_in, date(2024, 5, 1), requested_by="desk-1")
+
+class FakeClearinghouse(Clearinghouse):
+    name = "fake"
+
+    def __init__(self, answer=None, error=None):
+        self.answer = answer or EligibilityAnswer(eligible=True, plan_status="Active Coverage")
+        self.error = error
+        self.inquiries = []
+
+    def check(self, inquiry):
+        self.inquiries.append(inquiry)
+        if self.error:
+            raise self.error
+        return self.answer
+
+# A 271 as Change Healthcare's API gives it, cut down to what is summarized.
+RESPONSE_271 = {
+    "controlNumber": "123456789",
+    "planStatus": [{"statusCode": "1", "status": "Active Coverage", "serviceTypeCodes": ["30"]}],
+    "benefitsInformation": [
+        {"code": "1", "name": "Active Coverage", "serviceTypeCodes": ["30"], "serviceTypes": ["Health Benefit Plan Coverage"], "planCoverage": "Open Access Plus"},
+        {"code": "C", "coverageLevelCode": "IND", "serviceTypeCodes": ["30"], "timeQualifierCode": "23", "benefitAmount": "1500", "inPlanNetworkIndicatorCode": "Y"},
+        {"code": "C", "coverageLevelCode": "IND", "serviceTypeCodes": ["30"], "timeQualifierCode": "29", "benefitAmount": "412.5", "inPlanNetworkIndicatorCode": "Y"},
+        {"code": "B", "serviceTypeCodes": ["98"], "benefitAmount": "25", "inPlanNetworkIndicatorCode": "Y", "authOrCertIndicator": "N"},
+        {"code": "A", "serviceTypeCodes": ["98"], "benefitPercent": "0.2", "inPlanNetworkIndicatorCode": "N", "additionalInformation": [{"description": "After deductible"}]},
+    ],
+}
+
+# --- Benefit Summary Test Cases ---
+
+def test_benefits_are_summarized_per_service_type_network_and_level():
+    """Tests that copays, coinsurance and deductibles are grouped and converted to cents and percent."""
+    summaries = {(s.service_type, s.network, s.coverage_level): s for s in summarize_benefits(RESPONSE_271["benefitsInformation"], ["30"])}
+
+    deductible = summaries[("30", "in-network", "individual")]
+    assert (deductible.deductible_cents, deductible.deductible_remaining_cents) == (150000, 41250)
+    assert summaries[("30", "any", None)].active is True
+    office_visit = summaries[("98", "in-network", None)]
+    assert office_visit.copay_cents == 2500 and office_visit.authorization_required is False
+    out_of_network = summaries[("98", "out-of-network", None)]
+    assert out_of_network.coinsurance_percent == 20.0 and out_of_network.notes == ["After deductible"]
+
+# --- Clearinghouse Test Cases ---
+
+def change_healthcare(handler):
+    return ChangeHealthcareClearinghouse("https://sandbox.example", "id", "secret", 10, client=httpx.Client(transport=httpx.MockTransport(handler)))
+
+def inquiry(store, **coverage_fields):
+    patient = add_patient(store)
+    return EligibilityInquiry(
+        control_number="000000001", provider=PROVIDER, coverage=add_coverage(store, patient.patient_id, **coverage_fields),
+        patient=patient, service_date=date(2024, 5, 1), service_types=["30"],
+    )
+
+def test_change_healthcare_sends_the_270_and_reads_the_271():
+    """Tests that the inquiry names the dependant and policyholder, and that the token is reused."""
+    requests = []
+
+    def handler(request):
+        requests.append(request)
+        if request.url.path.endswith("/token"):
+            return httpx.Response(200, json={"access_token": "t-1", "expires_in": 3600})
+        return httpx.Response(200, json=RESPONSE_271)
+
+    clearinghouse = change_healthcare(handler)
+    subscriber = {"givenName": "Bob", "familyName": "Lee", "dob": "1978-02-02", "sex": "male"}
+    answer = clearinghouse.check(inquiry(MemoryStore(), relationship="spouse", subscriber=subscriber))
+    clearinghouse.check(inquiry(MemoryStore()))
+
+    assert [r.url.path for r in requests] == ["/apip/auth/v2/token", "/medicalnetwork/eligibility/v3", "/medicalnetwork/eligibility/v3"]
+    body = json.loads(requests[1].content)
+    assert body["tradingPartnerServiceId"] == "60054" and body["provider"]["npi"] == "1234567893"
+    assert body["subscriber"] == {"firstName": "Bob", "lastName": "Lee", "dateOfBirth": "19780202", "gender": "M", "memberId": "W123456789", "groupNumber": "G-1"}
+    assert body["dependents"][0]["individualRelationshipCode"] == "01"
+    assert body["encounter"] == {"dateOfService": "20240501", "serviceTypeCodes": ["30"]}
+    assert requests[2].headers["Authorization"] == "Bearer t-1"
+    assert (answer.eligible, answer.plan_status, answer.plan_name) == (True, "Active Coverage", "Open Access Plus")
+
+def test_change_healthcare_rejections_and_outages():
+    """Tests that a payer's rejection is an answer, while an outage raises to be retried."""
+    def rejecting(request):
+        if request.url.path.endswith("/token"):
+            return httpx.Response(200, json={"access_token": "t-1"})
+        return httpx.Response(200, json={"errors": [{"code": "72", "description": "Invalid/Missing Subscriber/Insured ID", "followupAction": "Please Correct and Resubmit"}]})
+
+    answer = change_healthcare(rejecting).check(inquiry(MemoryStore()))
+    assert answer.eligible is None
+    assert [(r.code, r.follow_up) for r in answer.rejections] == [("72", "Please Correct and Resubmit")]
+
+    with pytest.raises(ClearinghouseError) as raised:
+        change_healthcare(lambda request: httpx.Response(503)).check(inquiry(MemoryStore()))
+    assert raised.value.retryable
+
+# --- Check Runner Test Cases ---
+
+def test_run_records_the_answer_once():
+    """Tests that a check is completed with the payer's answer and not asked again."""
+    store = MemoryStore()
+    patient = add_patient(store)
+    check = start_check(store, add_coverage(store, patient.patient_id))
+    checks = MemoryEligibilityCheckRepository(store)
+    clearinghouse = FakeClearinghouse(EligibilityAnswer(eligible=True, plan_status="Active Coverage", benefits=[schemas.BenefitSummary(serviceType="30", copayCents=2500)]))
+
+    done = run_eligibility_check(check.check_id, checks, MemoryCoverageRepository(store), MemoryPatientRepository(store), clearinghouse, PROVIDER)
+
+    assert done.status == "completed" and done.eligible is True
+    assert done.benefits[0].copay_cents == 2500
+    assert done.trace_number == control_number(check.check_id) == clearinghouse.inquiries[0].control_number
+    assert run_eligibility_check(check.check_id, checks, MemoryCoverageRepository(store), MemoryPatientRepository(store), clearinghouse, PROVIDER) is None
+    assert len(clearinghouse.inquiries) == 1
+
+def test_run_retries_outages_and_fails_without_a_provider():
+    """Tests that an unreachable clearinghouse leaves the check to be retried, and a missing NPI fails it."""
+    store = MemoryStore()
+    patient = add_patient(store)
+    coverage = add_coverage(store, patient.patient_id)
+    checks = MemoryEligibilityCheckRepository(store)
+    repos = (checks, MemoryCoverageRepository(store), MemoryPatientRepository(store))
+
+    check = start_check(store, coverage)
+    with pytest.raises(ClearinghouseError):
+        run_eligibility_check(check.check_id, *repos, FakeClearinghouse(error=ClearinghouseError("down")), PROVIDER)
+    assert checks.get(check.check_id).status == "in-progress"
+    assert run_eligibility_check(check.check_id, *repos, FakeClearinghouse(), PROVIDER).status == "completed"
+
+    failed = run_eligibility_check(start_check(store, coverage).check_id, *repos, FakeClearinghouse(), None)
+    assert failed.status == "error" and "BILLING_PROVIDER_NPI" in failed.error
+
+def test_answers_are_reused_until_stale_or_the_coverage_changes():
+    """Tests that a recent answer for the same question is reused, but not after the coverage is edited."""
+    store = MemoryStore()
+    patient = add_patient(store)
+    coverage = add_coverage(store, patient.patient_id)
+    checks = MemoryEligibilityCheckRepository(store)
+    check = start_check(store, coverage)
+    run_eligibility_check(check.check_id, checks, MemoryCoverageRepository(store), MemoryPatientRepository(store), FakeClearinghouse(), PROVIDER)
+    now = datetime.now(timezone.utc)
+
+    assert reusable_check(checks, coverage, patient, date(2024, 5, 1), ["30"], 3600, now).check_id == check.check_id
+    assert reusable_check(checks, coverage, patient, date(2024, 5, 2), ["30"], 3600, now) is None
+    assert reusable_check(checks, coverage, patient, date(2024, 5, 1), ["30", "98"], 3600, now) is None
+    assert reusable_check(checks, coverage, patient, date(2024, 5, 1), ["30"], 3600, now + timedelta(hours=2)) is None
+
+    edited = MemoryCoverageRepository(store).update(coverage.coverage_id, schemas.CoverageUpdate(memberId="W987654321"))
+    assert reusable_check(checks, edited, patient, date(2024, 5, 1), ["30"], 3600, now) is None
+
+def test_front_desk_coordinators_manage_coverage_only():
+    """Tests that coordinators may change coverage but no other patient records."""
+    full, _own = grants({"roles": [COORDINATOR]})
+
+    assert has_permission(full, "patients.coverage:write")
+    assert not has_permission(full, "patients.allergies:write")
+
+# --- Endpoint Test Cases ---
+
+app = FastAPI()
+app.include_router(coverage_endpoints.router, prefix="/api/v1/patients")
+client = TestClient(app)
+
+@pytest.fixture
+def env(monkeypatch):
+    """A patient in a memory store, a clearinghouse and a task queue, with a coordinator signed in."""
+    store = MemoryStore()
+    tasks = MagicMock(spec=TaskQueue)
+    monkeypatch.setattr(coverage_endpoints, "get_clearinghouse", lambda: FakeClearinghouse())
+    monkeypatch.setattr(get_settings(), "eligibility_cache_seconds", 3600)
+    app.dependency_overrides[get_coverage_repository] = lambda: MemoryCoverageRepository(store)
+    app.dependency_overrides[get_eligibility_check_repository] = lambda: MemoryEligibilityCheckRepository(store)
+    app.dependency_overrides[get_patient_repository] = lambda: MemoryPatientRepository(store)
+    app.dependency_overrides[get_event_publisher] = lambda: MagicMock()
+    app.dependency_overrides[get_task_queue] = lambda: tasks
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "desk-1", "roles": [COORDINATOR]}
+    yield {"store": store, "tasks": tasks, "patient": add_patient(store)}
+    app.dependency_overrides.clear()
+
+def test_record_and_list_coverage(env):
+    """Tests that coverage is recorded for the patient and listed primary first."""
+    patient_id = env["patient"].patient_id
+    secondary = client.post(f"/api/v1/patients/{patient_id}/coverage", json={"payerId": "87726", "payerName": "UnitedHealthcare", "memberId": "U1", "priority": "secondary"})
+    primary = client.post(f"/api/v1/patients/{patient_id}/coverage", json={"payerId": "60054", "payerName": "Aetna", "memberId": "W1"})
+
+    assert secondary.status_code == 201 and primary.json()["status"] == "active"
+    assert [c["payer_name"] for c in client.get(f"/api/v1/patients/{patient_id}/coverage").json()] == ["Aetna", "UnitedHealthcare"]
+    assert client.post(f"/api/v1/patients/{patient_id}/coverage", json={"payerId": "60054", "payerName": "Aetna", "memberId": "W2", "relationship": "child"}).status_code == 422
+    assert client.get("/api/v1/patients/unknown/coverage").status_code == 404
+
+def test_eligibility_check_runs_in_the_background(env):
+    """Tests that a check is accepted and queued, and that a completed answer is then reused."""
+    patient_id = env["patient"].patient_id
+    coverage = add_coverage(env["store"], patient_id)
+    url = f"/api/v1/patients/{patient_id}/coverage/{coverage.coverage_id}/eligibility"
+
+    response = client.post(url, json={"serviceDate": "2024-05-01"})
+    assert response.status_code == 202
+    check = response.json()
+    assert response.headers["Content-Location"] == f"{url}/{check['check_id']}"
+    env["tasks"].enqueue.assert_called_once_with(ELIGIBILITY_CHECK_TASK, {"checkId": check["check_id"]}, task_id=f"eligibility-check-{check['check_id']}")
+
+    store = env["store"]
+    run_eligibility_check(check["check_id"], MemoryEligibilityCheckRepository(store), MemoryCoverageRepository(store), MemoryPatientRepository(store), FakeClearinghouse(), PROVIDER)
+    cached = client.post(url, json={"serviceDate": "2024-05-01"})
+    assert cached.status_code == 200
+    assert cached.json()["check_id"] == check["check_id"] and cached.json()["cached"] is True
+    assert client.post(url, json={"serviceDate": "2024-05-01", "refresh": True}).status_code == 202
+    assert client.get(f"{url}/{check['check_id']}").json()["eligible"] is True
+
+def test_eligibility_checks_need_a_clearinghouse_and_active_coverage(env, monkeypatch):
+    """Tests that checks are refused without a clearinghouse or for ended coverage."""
+    patient_id = env["patient"].patient_id
+    coverage = add_coverage(env["store"], patient_id)
+    url = f"/api/v1/patients/{patient_id}/coverage/{coverage.coverage_id}/eligibility"
+
+    assert client.patch(f"/api/v1/patients/{patient_id}/coverage/{coverage.coverage_id}", json={"status": "inactive"}).status_code == 200
+    assert client.post(url, json={}).status_code == 409
+    monkeypatch.setattr(coverage_endpoints, "get_clearinghouse", lambda: None)
+    assert client.post(url, json={}).status_code == 503
+    env["tasks"].enqueue.assert_not_called()
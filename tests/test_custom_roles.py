@@ -0,0 +1,163 @@
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_custom_role_repository
+from app.api.v1.endpoints import roles
+from app.audit.context import AuditContext, audit_context_var
+from app.authz import custom_roles
+from app.authz.custom_roles import CustomRoleCache
+from app.authz.policy import subresource
+from app.authz.roles import grants, has_permission
+from app.dependencies.auth import get_current_user
+from app.repositories.memory import MemoryCustomRoleRepository, MemoryStore
+from app.tenancy.context import acting_for
+
+# --- Test Setup ---
+
+class ActAsMiddleware:
+    """Stands in for TenancyMiddleware and AuditMiddleware: acts for org-1 and keeps each request's audit context."""
+
+    def __init__(self, app):
+        self.app = app
+
+    async def __call__(self, scope, receive, send):
+        token = audit_context_var.set(AuditContext())
+        try:
+            with acting_for("org-1"):
+                await self.app(scope, receive, send)
+        finally:
+            audit_context_var.reset(token)
+
+class Clock:
+    def __init__(self):
+        self.now = 0.0
+
+    def __call__(self):
+        return self.now
+
+LAB_TECHNICIAN = {
+    "name": "lab-technician",
+    "permissions": ["patients:read", "patients.lab-results:write"],
+    "denied": ["patients.documents:read"],
+}
+
+def define(store, organization_id, role=LAB_TECHNICIAN):
+    with acting_for(organization_id):
+        return MemoryCustomRoleRepository(store).create(schemas.CustomRoleCreate.model_validate(role), "admin-1")
+
+ADMIN_USER = {"uid": "admin-1", "roles": ["admin"], "organizationId": "org-1"}
+
+app = FastAPI()
+app.include_router(roles.router, prefix="/api/v1/admin/roles")
+app.add_middleware(ActAsMiddleware)
+client = TestClient(app)
+
+@pytest.fixture
+def store(monkeypatch):
+    """A memory store behind the endpoints and a fresh role cache reading it, with an administrator of org-1 signed in."""
+    store = MemoryStore()
+    cache = CustomRoleCache(lambda: MemoryCustomRoleRepository(store), ttl_seconds=30)
+    monkeypatch.setattr(custom_roles, "get_custom_role_cache", lambda: cache)
+    monkeypatch.setattr(roles, "get_custom_role_cache", lambda: cache)
+    app.dependency_overrides[get_custom_role_repository] = lambda: MemoryCustomRoleRepository(store)
+    app.dependency_overrides[get_current_user] = lambda: ADMIN_USER
+    yield store
+    app.dependency_overrides.clear()
+
+# --- Permission Test Cases ---
+
+def test_subresource_permissions_are_covered_by_the_resource():
+    """Tests that a resource's permission covers its subresources and that denials win."""
+    assert has_permission(frozenset({"patients:read"}), "patients.lab-results:read")
+    assert not has_permission(frozenset({"patients.lab-results:read"}), "patients:read")
+    assert not has_permission(frozenset({"patients:read", "!patients.documents:read"}), "patients.documents:read")
+    assert not has_permission(frozenset({"*", "!patients:write"}), "patients.lab-results:write")
+
+def test_subresource_is_the_segment_after_the_resource_id():
+    """Tests that the kind of record a route reaches is read from its template."""
+    assert subresource("/api/v1/patients/{patientId}/lab-results/{labResultId}", "patients") == "lab-results"
+    assert subresource("/api/v1/patients/{patientId}", "patients") is None
+    assert subresource("/api/v1/patients", "patients") is None
+    assert subresource("/api/v1/encounters/{encounterId}/status", "encounters") is None
+
+# --- Cache Test Cases ---
+
+def test_cache_keeps_each_organizations_roles_apart():
+    """Tests that roles of the same name in two organizations grant their own permissions."""
+    store = MemoryStore()
+    define(store, "org-1")
+    define(store, "org-2", {"name": "lab-technician", "permissions": ["appointments:read"]})
+    cache = CustomRoleCache(lambda: MemoryCustomRoleRepository(store))
+
+    assert "patients:read" in cache.permissions("org-1", ["lab-technician"])
+    assert "!patients.documents:read" in cache.permissions("org-1", ["lab-technician"])
+    assert cache.permissions("org-2", ["lab-technician"]) == frozenset({"appointments:read"})
+    assert cache.permissions("org-1", ["unknown"]) == frozenset()
+
+def test_cache_expires_and_invalidates():
+    """Tests that roles are reread after the TTL or an invalidation, and that store failures grant nothing."""
+    store = MemoryStore()
+    clock = Clock()
+    cache = CustomRoleCache(lambda: MemoryCustomRoleRepository(store), ttl_seconds=30, clock=clock)
+    assert cache.roles("org-1") == {}
+
+    define(store, "org-1")
+    assert cache.roles("org-1") == {}
+    clock.now = 31
+    assert "lab-technician" in cache.roles("org-1")
+
+    with acting_for("org-1"):
+        MemoryCustomRoleRepository(store).delete("lab-technician")
+    cache.invalidate("org-1")
+    assert cache.roles("org-1") == {}
+
+    def unavailable():
+        raise RuntimeError("store unavailable")
+
+    assert CustomRoleCache(unavailable).roles("org-1") == {}
+
+def test_grants_include_the_organizations_custom_roles(store):
+    """Tests that a custom role held by the caller grants its permissions and denials."""
+    define(store, "org-1")
+
+    with acting_for("org-1"):
+        full, own = grants({"uid": "tech-1", "roles": ["lab-technician"]})
+
+    assert has_permission(full, "patients.lab-results:write")
+    assert not has_permission(full, "patients.documents:read")
+    assert own == frozenset()
+
+# --- Endpoint Test Cases ---
+
+def test_define_change_and_remove_a_role(store):
+    """Tests the lifecycle of a custom role and that changes apply to holders at once."""
+    response = client.post("/api/v1/admin/roles", json=LAB_TECHNICIAN)
+    assert response.status_code == 201
+    assert response.json()["denied"] == ["patients.documents:read"]
+    assert [role["name"] for role in client.get("/api/v1/admin/roles").json()] == ["lab-technician"]
+    assert client.post("/api/v1/admin/roles", json=LAB_TECHNICIAN).status_code == 409
+
+    with acting_for("org-1"):
+        assert has_permission(grants({"roles": ["lab-technician"]})[0], "patients:read")
+    response = client.patch("/api/v1/admin/roles/lab-technician", json={"permissions": ["appointments:read"]})
+    assert response.json()["permissions"] == ["appointments:read"]
+    with acting_for("org-1"):
+        assert not has_permission(grants({"roles": ["lab-technician"]})[0], "patients:read")
+
+    assert client.delete("/api/v1/admin/roles/lab-technician").status_code == 204
+    assert client.get("/api/v1/admin/roles/lab-technician").status_code == 404
+
+def test_roles_are_validated(store):
+    """Tests that built-in names and malformed permissions are refused."""
+    assert client.post("/api/v1/admin/roles", json={"name": "clinician", "permissions": ["patients:read"]}).status_code == 409
+    assert client.post("/api/v1/admin/roles", json={"name": "compliance-officer"}).status_code == 409
+    assert client.post("/api/v1/admin/roles", json={"name": "reader", "permissions": ["patients:delete"]}).status_code == 422
+
+def test_only_administrators_define_roles(store):
+    """Tests that other staff cannot manage roles."""
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "c1", "roles": ["clinician"], "organizationId": "org-1"}
+
+    assert client.get("/api/v1/admin/roles").status_code == 403
+    assert client.post("/api/v1/admin/roles", json=LAB_TECHNICIAN).status_code == 403
@@ -0,0 +1,151 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_device_repository
+from app.api.v1.endpoints import devices
+from app.core import pubsub
+from app.dependencies.auth import get_current_user
+from app.repositories.devices import DeviceRepository, FirestoreDeviceRepository
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(devices.router, prefix="/api/v1/devices", tags=["Devices"])
+app.dependency_overrides[get_current_user] = lambda: {"uid": "test-user-uid-123"}
+client = TestClient(app)
+
+FAKE_DEVICE_ID = "device-1"
+
+def make_device(status="Active"):
+    return schemas.Device.model_validate({
+        "deviceId": FAKE_DEVICE_ID,
+        "deviceName": "AirSense 11",
+        "serialNumber": "SN-123",
+        "deviceNumber": "001",
+        "status": status,
+        "addedDate": datetime(2024, 1, 1, tzinfo=timezone.utc),
+    })
+
+def readings(*sequences):
+    return {"readings": [
+        {"sequence": s, "recorded_at": f"2024-05-01T00:0{s % 10}:00Z", "metrics": {"spo2": 96, "pulseRate": 70}}
+        for s in sequences
+    ]}
+
+@pytest.fixture
+def repo(monkeypatch):
+    """Overrides the device repository with a mock and captures published messages."""
+    monkeypatch.setattr(devices, "TELEMETRY_TOPIC", "device-telemetry")
+    published = []
+    def fake_publish(topic, payload, **attributes):
+        published.append((topic, payload, attributes))
+        return "msg-1"
+    monkeypatch.setattr(devices, "publish_json", fake_publish)
+
+    mock = MagicMock(spec=DeviceRepository)
+    mock.get_for_owner.return_value = make_device()
+    mock.get_telemetry_sequence.return_value = None
+    mock.advance_telemetry_sequence.side_effect = lambda uid, device_id, sequence: sequence
+    mock.published = published
+    app.dependency_overrides[get_device_repository] = lambda: mock
+    yield mock
+    app.dependency_overrides.pop(get_device_repository, None)
+
+# --- Endpoint Test Cases ---
+
+def test_ingest_telemetry_publishes_batch(repo):
+    """Tests that a new batch is published once and the high-water mark is advanced."""
+    response = client.post(f"/api/v1/devices/{FAKE_DEVICE_ID}/telemetry", json=readings(2, 1, 3))
+
+    assert response.status_code == 202
+    assert response.json() == {"accepted": 3, "duplicates": 0, "last_sequence": 3, "message_id": "msg-1"}
+    topic, payload, attributes = repo.published[0]
+    assert topic == "device-telemetry"
+    assert [r["sequence"] for r in payload["readings"]] == [1, 2, 3]
+    assert payload["serialNumber"] == "SN-123"
+    assert attributes == {"deviceId": FAKE_DEVICE_ID, "schemaVersion": "1"}
+    repo.advance_telemetry_sequence.assert_called_once_with("test-user-uid-123", FAKE_DEVICE_ID, 3)
+
+def test_ingest_telemetry_drops_resent_readings(repo):
+    """Tests that readings at or below the high-water mark, and repeats within a batch, are dropped."""
+    repo.get_telemetry_sequence.return_value = 2
+
+    response = client.post(f"/api/v1/devices/{FAKE_DEVICE_ID}/telemetry", json=readings(1, 2, 3, 3, 4))
+
+    assert response.json()["accepted"] == 2
+    assert response.json()["duplicates"] == 3
+    assert [r["sequence"] for r in repo.published[0][1]["readings"]] == [3, 4]
+
+def test_ingest_telemetry_fully_duplicate_batch(repo):
+    """Tests that a resent batch is acknowledged without publishing."""
+    repo.get_telemetry_sequence.return_value = 5
+
+    response = client.post(f"/api/v1/devices/{FAKE_DEVICE_ID}/telemetry", json=readings(4, 5))
+
+    assert response.status_code == 202
+    assert response.json() == {"accepted": 0, "duplicates": 2, "last_sequence": 5, "message_id": None}
+    assert repo.published == []
+    repo.advance_telemetry_sequence.assert_not_called()
+
+def test_ingest_telemetry_unregistered_device(repo):
+    """Tests 404 for a device that is not registered to the caller."""
+    repo.get_for_owner.return_value = None
+
+    response = client.post("/api/v1/devices/someone-elses/telemetry", json=readings(1))
+
+    assert response.status_code == 404
+
+def test_ingest_telemetry_inactive_device(repo):
+    """Tests 409 for a device that has been deactivated."""
+    repo.get_for_owner.return_value = make_device(status="Inactive")
+
+    response = client.post(f"/api/v1/devices/{FAKE_DEVICE_ID}/telemetry", json=readings(1))
+
+    assert response.status_code == 409
+
+def test_ingest_telemetry_publish_failure_keeps_mark(repo, monkeypatch):
+    """Tests that a failed publish returns 503 and does not advance the high-water mark."""
+    monkeypatch.setattr(devices, "publish_json", MagicMock(side_effect=TimeoutError()))
+
+    response = client.post(f"/api/v1/devices/{FAKE_DEVICE_ID}/telemetry", json=readings(1))
+
+    assert response.status_code == 503
+    repo.advance_telemetry_sequence.assert_not_called()
+
+def test_ingest_telemetry_rejects_empty_batch(repo):
+    """Tests 422 for a batch without readings."""
+    response = client.post(f"/api/v1/devices/{FAKE_DEVICE_ID}/telemetry", json={"readings": []})
+
+    assert response.status_code == 422
+
+# --- Pub/Sub Helper Test Cases ---
+
+def test_topic_path_resolves_topic_ids(monkeypatch):
+    """Tests that bare topic IDs are qualified with the project."""
+    monkeypatch.setattr(pubsub, "GOOGLE_CLOUD_PROJECT", "mega-care-dev")
+
+    assert pubsub.topic_path("device-telemetry") == "projects/mega-care-dev/topics/device-telemetry"
+    assert pubsub.topic_path("projects/other/topics/t") == "projects/other/topics/t"
+
+# --- Firestore Repository Test Cases ---
+
+def test_firestore_device_lookup_is_scoped_to_owner():
+    """Tests that devices are read from the owner's devices sub-collection."""
+    mock_db = MagicMock()
+    device_ref = mock_db.collection.return_value.document.return_value.collection.return_value.document.return_value
+    snapshot = MagicMock()
+    snapshot.exists = True
+    snapshot.id = FAKE_DEVICE_ID
+    snapshot.to_dict.return_value = make_device().model_dump(by_alias=True, exclude={"device_id"})
+    device_ref.get.return_value = snapshot
+
+    device = FirestoreDeviceRepository(mock_db).get_for_owner("owner-uid", FAKE_DEVICE_ID)
+
+    assert device.device_id == FAKE_DEVICE_ID
+    mock_db.collection.assert_called_once_with("customers")
+    mock_db.collection.return_value.document.assert_called_once_with("owner-uid")
@@ -0,0 +1,78 @@
+import threading
+import time
+import tracemalloc
+
+import pytest
+
+from app.core.diagnostics import heap_top, runtime_vars, sample_profile, stack_dump
+
+# --- Test Setup ---
+
+def spin_until(stop):
+    while not stop.is_set():
+        sum(range(1000))
+
+
+def busy_thread():
+    stop = threading.Event()
+    thread = threading.Thread(target=spin_until, args=(stop,), name="busy-worker", daemon=True)
+    thread.start()
+    return thread, stop
+
+# --- Diagnostics Test Cases ---
+
+def test_stack_dump_lists_every_thread():
+    """Tests that the dump names each thread and shows where it is."""
+    thread, stop = busy_thread()
+    try:
+        dump = stack_dump()
+    finally:
+        stop.set()
+        thread.join()
+
+    assert "Thread busy-worker" in dump
+    assert "spin_until" in dump
+    assert "test_stack_dump_lists_every_thread" in dump
+
+def test_sample_profile_counts_folded_stacks():
+    """Tests that the profile has one folded line per stack, with the sampled thread's functions outermost first."""
+    thread, stop = busy_thread()
+    try:
+        profile = sample_profile(0.2, interval=0.005)
+    finally:
+        stop.set()
+        thread.join()
+
+    lines = profile.splitlines()
+    busy = [line for line in lines if line.startswith("busy-worker;")]
+    assert busy and all(line.rsplit(" ", 1)[1].isdigit() for line in lines)
+    assert "spin_until (test_diagnostics.py)" in busy[0]
+    assert "sample_profile" not in profile
+
+def test_runtime_vars_report_the_process():
+    """Tests that the counters describe this interpreter and process."""
+    values = runtime_vars()
+
+    assert values["threads"] >= 1
+    assert values["uptimeSeconds"] >= 0
+    assert len(values["gc"]["collections"]) == 3
+    assert values["tracemalloc"]["tracing"] == tracemalloc.is_tracing()
+
+def test_heap_top_needs_tracemalloc():
+    """Tests that the heap view is refused without tracing and lists the largest allocations with it."""
+    was_tracing = tracemalloc.is_tracing()
+    tracemalloc.stop()
+    with pytest.raises(RuntimeError):
+        heap_top(10)
+
+    tracemalloc.start()
+    try:
+        kept = [bytearray(1024) for _ in range(200)]
+        heap = heap_top(5)
+    finally:
+        if not was_tracing:
+            tracemalloc.stop()
+
+    assert len(heap["allocations"]) <= 5
+    assert heap["tracedBytes"] > 0 and kept
+    assert any("test_diagnostics.py" in a["location"] for a in heap["allocations"])
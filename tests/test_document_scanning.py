@@ -0,0 +1,138 @@
+import pytest
+import struct
+from unittest.mock import MagicMock
+
+from app.api.v1 import schemas
+from app.repositories.memory import MemoryPatientDocumentRepository, MemoryStore
+from app.scanning import scanner as scanning
+from app.scanning.scanner import ClamdScanner, ScanError, ScanResult
+from app.tasks import jobs
+from app.tasks.registry import run_task
+
+# --- Test Setup ---
+
+class FakeClamd:
+    """A socket standing in for clamd: records what is sent and answers `reply`."""
+
+    def __init__(self, reply: bytes):
+        self.reply = reply
+        self.sent = b""
+
+    def sendall(self, data):
+        self.sent += data
+
+    def recv(self, size):
+        reply, self.reply = self.reply, b""
+        return reply
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, *exc):
+        return False
+
+def use_clamd(monkeypatch, reply: bytes) -> FakeClamd:
+    conn = FakeClamd(reply)
+    monkeypatch.setattr(scanning.socket, "create_connection", lambda address, timeout: conn)
+    return conn
+
+def pending_scan_document(repo):
+    repo.create("patient-1", "doc-1", schemas.PatientDocumentCreate(
+        type="referral-letter", title="Referral to sleep clinic", content_type="application/pdf",
+        size=68, checksum="1B2M2Y8AsgTpgAmY7PhCfg==",
+    ), "patients/patient-1/documents/doc-1", uploaded_by="clinician-uid-123")
+    return repo.mark_uploaded("doc-1", status="pending-scan")
+
+def use_scan_job(monkeypatch, repo, result):
+    """Points the scan job at `repo`, a bucket, and a scanner returning `result`."""
+    events = MagicMock()
+    scanner = MagicMock()
+    scanner.scan.return_value = result
+    monkeypatch.setattr(jobs.settings, "patient_documents_bucket", "docs")
+    monkeypatch.setattr(jobs, "get_scanner", lambda: scanner)
+    monkeypatch.setattr(jobs, "get_patient_document_repository", lambda: repo)
+    monkeypatch.setattr(jobs, "get_event_publisher", lambda: events)
+    monkeypatch.setattr(jobs, "read_blob", lambda bucket, blob_name: iter([b"%PDF-1.7"]))
+    return scanner, events
+
+# --- Scanner Test Cases ---
+
+def test_clamd_scanner_streams_chunks_and_reads_clean_verdict(monkeypatch):
+    """Tests that chunks are sent length-prefixed after zINSTREAM, ended by a zero length."""
+    conn = use_clamd(monkeypatch, b"stream: OK\0")
+
+    result = ClamdScanner("localhost", 3310, 5).scan([b"%PDF", b"", b"-1.7"])
+
+    assert result == ScanResult(clean=True)
+    assert conn.sent == b"zINSTREAM\0" + struct.pack("!L", 4) + b"%PDF" + struct.pack("!L", 4) + b"-1.7" + struct.pack("!L", 0)
+
+def test_clamd_scanner_reports_the_signature_found(monkeypatch):
+    """Tests that a FOUND reply is an unclean verdict naming the signature."""
+    use_clamd(monkeypatch, b"stream: Win.Test.EICAR_HDB-1 FOUND\0")
+
+    result = ClamdScanner("localhost", 3310, 5).scan([b"X5O!P%@AP"])
+
+    assert result == ScanResult(clean=False, threat="Win.Test.EICAR_HDB-1")
+
+def test_clamd_errors_are_not_verdicts(monkeypatch):
+    """Tests that clamd errors and an unreachable daemon raise ScanError instead of passing the file."""
+    use_clamd(monkeypatch, b"INSTREAM size limit exceeded. ERROR\0")
+    with pytest.raises(ScanError):
+        ClamdScanner("localhost", 3310, 5).scan([b"data"])
+
+    def refused(address, timeout):
+        raise ConnectionRefusedError("connection refused")
+    monkeypatch.setattr(scanning.socket, "create_connection", refused)
+    with pytest.raises(ScanError):
+        ClamdScanner("localhost", 3310, 5).scan([b"data"])
+
+# --- Scan Job Test Cases ---
+
+def test_clean_document_becomes_available(monkeypatch):
+    """Tests that a clean scan makes the document available and announces the upload."""
+    repo = MemoryPatientDocumentRepository(MemoryStore())
+    pending_scan_document(repo)
+    _scanner, events = use_scan_job(monkeypatch, repo, ScanResult(clean=True))
+
+    assert run_task(jobs.DOCUMENT_SCAN_TASK, {"documentId": "doc-1"}) is True
+
+    document = repo.get("doc-1")
+    assert (document.status, document.threat) == ("available", None)
+    assert document.scanned_at is not None
+    assert events.emit.call_args.args[0] == "document.uploaded"
+
+def test_infected_document_is_quarantined(monkeypatch):
+    """Tests that a document with malware is quarantined with what was found."""
+    repo = MemoryPatientDocumentRepository(MemoryStore())
+    pending_scan_document(repo)
+    _scanner, events = use_scan_job(monkeypatch, repo, ScanResult(clean=False, threat="Win.Test.EICAR_HDB-1"))
+
+    run_task(jobs.DOCUMENT_SCAN_TASK, {"documentId": "doc-1"})
+
+    document = repo.get("doc-1")
+    assert (document.status, document.threat) == ("quarantined", "Win.Test.EICAR_HDB-1")
+    assert events.emit.call_args.args[0] == "document.quarantined"
+
+def test_scan_job_skips_documents_already_scanned(monkeypatch):
+    """Tests that a repeated job leaves a scanned document alone."""
+    repo = MemoryPatientDocumentRepository(MemoryStore())
+    pending_scan_document(repo)
+    repo.record_scan("doc-1", threat=None)
+    scanner, _events = use_scan_job(monkeypatch, repo, ScanResult(clean=False, threat="Win.Test.EICAR_HDB-1"))
+
+    run_task(jobs.DOCUMENT_SCAN_TASK, {"documentId": "doc-1"})
+
+    scanner.scan.assert_not_called()
+    assert repo.get("doc-1").status == "available"
+
+def test_unreachable_scanner_is_retried(monkeypatch):
+    """Tests that a scan error leaves the document pending and is raised for the queue to retry."""
+    repo = MemoryPatientDocumentRepository(MemoryStore())
+    pending_scan_document(repo)
+    scanner, _events = use_scan_job(monkeypatch, repo, None)
+    scanner.scan.side_effect = ScanError("clamd at localhost:3310 is unavailable")
+
+    with pytest.raises(ScanError):
+        run_task(jobs.DOCUMENT_SCAN_TASK, {"documentId": "doc-1"})
+
+    assert repo.get("doc-1").status == "pending-scan"
@@ -0,0 +1,210 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from firebase_admin import firestore
+from app.api.v1 import schemas
+from app.api.v1.deps import (
+    get_appointment_repository,
+    get_encounter_repository,
+    get_observation_repository,
+    get_patient_repository,
+    get_practitioner_repository,
+    get_task_queue,
+)
+from app.api.v1.endpoints import encounters
+from app.dependencies.auth import get_current_user
+from app.repositories.appointments import AppointmentRepository
+from app.repositories.encounters import EncounterRepository, FirestoreEncounterRepository
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.practitioners import PractitionerRepository
+from app.tasks.jobs import DISCHARGE_SUMMARY_TASK
+from app.tasks.queue import TaskQueue
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(encounters.router, prefix="/api/v1/encounters", tags=["Encounters"])
+app.dependency_overrides[get_current_user] = lambda: {"uid": "staff-uid-123"}
+client = TestClient(app)
+
+FAKE_ENCOUNTER_ID = "enc-1"
+FAKE_PATIENT_ID = "patient-1"
+DEPENDENCIES = (get_encounter_repository, get_patient_repository, get_practitioner_repository, get_appointment_repository, get_observation_repository)
+
+def make_encounter(status="in-progress", end=None, observation_ids=None, participants=None):
+    return schemas.Encounter.model_validate({
+        "encounterId": FAKE_ENCOUNTER_ID,
+        "patientId": FAKE_PATIENT_ID,
+        "visitType": "ambulatory",
+        "start": datetime(2024, 3, 1, 9, 0, tzinfo=timezone.utc),
+        "end": end,
+        "status": status,
+        "participants": participants or [],
+        "observationIds": observation_ids or [],
+        "createdAt": datetime(2024, 3, 1, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 3, 1, tzinfo=timezone.utc),
+    })
+
+@pytest.fixture
+def repos():
+    """Overrides every repository the encounter endpoints use with mocks."""
+    mocks = {
+        "encounters": MagicMock(spec=EncounterRepository),
+        "patients": MagicMock(spec=PatientRepository),
+        "practitioners": MagicMock(spec=PractitionerRepository),
+        "appointments": MagicMock(spec=AppointmentRepository),
+        "observations": MagicMock(spec=ObservationRepository),
+    }
+    app.dependency_overrides[get_encounter_repository] = lambda: mocks["encounters"]
+    app.dependency_overrides[get_patient_repository] = lambda: mocks["patients"]
+    app.dependency_overrides[get_practitioner_repository] = lambda: mocks["practitioners"]
+    app.dependency_overrides[get_appointment_repository] = lambda: mocks["appointments"]
+    app.dependency_overrides[get_observation_repository] = lambda: mocks["observations"]
+    yield mocks
+    for dep in DEPENDENCIES:
+        app.dependency_overrides.pop(dep, None)
+
+# --- Endpoint Test Cases ---
+
+def test_create_encounter_success(repos):
+    """Tests opening an encounter with a participating practitioner."""
+    repos["encounters"].create.return_value = make_encounter(participants=[{"practitionerId": "pr-1", "role": "attender"}])
+
+    response = client.post("/api/v1/encounters", json={
+        "patient_id": FAKE_PATIENT_ID,
+        "visit_type": "ambulatory",
+        "location": "Sleep Clinic, Room 2",
+        "start": "2024-03-01T09:00:00Z",
+        "participants": [{"practitioner_id": "pr-1", "role": "attender"}],
+    })
+
+    assert response.status_code == 201
+    assert response.json()["status"] == "in-progress"
+    repos["practitioners"].get.assert_called_once_with("pr-1")
+
+def test_create_encounter_rejects_appointment_of_other_patient(repos):
+    """Tests 422 when the referenced appointment belongs to someone else."""
+    repos["appointments"].get.return_value = MagicMock(patient_id="someone-else")
+
+    response = client.post("/api/v1/encounters", json={
+        "patient_id": FAKE_PATIENT_ID, "visit_type": "virtual", "start": "2024-03-01T09:00:00Z", "appointment_id": "appt-1",
+    })
+
+    assert response.status_code == 422
+    repos["encounters"].create.assert_not_called()
+
+def test_create_encounter_rejects_inverted_period(repos):
+    """Tests 422 when the encounter ends before it starts."""
+    response = client.post("/api/v1/encounters", json={
+        "patient_id": FAKE_PATIENT_ID, "visit_type": "home", "start": "2024-03-01T09:00:00Z", "end": "2024-03-01T08:00:00Z",
+    })
+
+    assert response.status_code == 422
+
+def test_list_encounters_requires_filter(repos):
+    """Tests 400 when neither patientId nor practitionerId is given."""
+    response = client.get("/api/v1/encounters")
+
+    assert response.status_code == 400
+
+def test_finish_encounter_sets_end(repos):
+    """Tests that finishing an open-ended encounter closes its period."""
+    repos["encounters"].get.return_value = make_encounter()
+    repos["encounters"].update.return_value = make_encounter(status="finished", end=datetime(2024, 3, 1, 10, tzinfo=timezone.utc))
+
+    response = client.post(f"/api/v1/encounters/{FAKE_ENCOUNTER_ID}/status", json={"status": "finished"})
+
+    assert response.status_code == 200
+    changes = repos["encounters"].update.call_args[0][1]
+    assert changes["status"] == "finished"
+    assert "end" in changes
+
+def test_finish_encounter_queues_discharge_summary(repos, monkeypatch):
+    """Tests that finishing an encounter queues its discharge summary once a documents bucket is configured."""
+    monkeypatch.setattr(encounters, "ENCOUNTER_DOCUMENTS_BUCKET", "encounter-docs")
+    tasks = MagicMock(spec=TaskQueue)
+    app.dependency_overrides[get_task_queue] = lambda: tasks
+    repos["encounters"].get.return_value = make_encounter()
+    repos["encounters"].update.return_value = make_encounter(status="finished", end=datetime(2024, 3, 1, 10, tzinfo=timezone.utc))
+
+    response = client.post(f"/api/v1/encounters/{FAKE_ENCOUNTER_ID}/status", json={"status": "finished"})
+
+    assert response.status_code == 200
+    tasks.enqueue.assert_called_once_with(
+        DISCHARGE_SUMMARY_TASK, {"encounterId": FAKE_ENCOUNTER_ID}, task_id=f"discharge-summary-{FAKE_ENCOUNTER_ID}",
+    )
+    app.dependency_overrides.pop(get_task_queue, None)
+
+def test_reopen_finished_encounter_returns_409(repos):
+    """Tests that finished encounters cannot be reopened."""
+    repos["encounters"].get.return_value = make_encounter(status="finished")
+
+    response = client.post(f"/api/v1/encounters/{FAKE_ENCOUNTER_ID}/status", json={"status": "in-progress"})
+
+    assert response.status_code == 409
+
+def test_link_observation_of_same_patient(repos):
+    """Tests linking an observation to a finished encounter, e.g. a late result."""
+    repos["encounters"].get.return_value = make_encounter(status="finished")
+    repos["observations"].get.return_value = MagicMock(patient_id=FAKE_PATIENT_ID)
+    repos["encounters"].link_observation.return_value = make_encounter(status="finished", observation_ids=["obs-1"])
+
+    response = client.post(f"/api/v1/encounters/{FAKE_ENCOUNTER_ID}/observations", json={"observation_id": "obs-1"})
+
+    assert response.status_code == 200
+    assert response.json()["observation_ids"] == ["obs-1"]
+
+def test_link_observation_of_other_patient_returns_422(repos):
+    """Tests that observations of another patient cannot be linked."""
+    repos["encounters"].get.return_value = make_encounter()
+    repos["observations"].get.return_value = MagicMock(patient_id="someone-else")
+
+    response = client.post(f"/api/v1/encounters/{FAKE_ENCOUNTER_ID}/observations", json={"observation_id": "obs-1"})
+
+    assert response.status_code == 422
+    repos["encounters"].link_observation.assert_not_called()
+
+def test_add_document_to_cancelled_encounter_returns_409(repos):
+    """Tests that cancelled encounters accept no new records."""
+    repos["encounters"].get.return_value = make_encounter(status="cancelled")
+
+    response = client.post(f"/api/v1/encounters/{FAKE_ENCOUNTER_ID}/documents", json={"title": "Visit note", "url": "gs://notes/1.pdf"})
+
+    assert response.status_code == 409
+    repos["encounters"].add_document.assert_not_called()
+
+# --- Firestore Repository Test Cases ---
+
+def _mock_encounter_ref(mock_db, encounter):
+    encounter_ref = mock_db.collection.return_value.document.return_value
+    snapshot = MagicMock()
+    snapshot.exists = True
+    snapshot.id = FAKE_ENCOUNTER_ID
+    snapshot.to_dict.return_value = encounter.model_dump(by_alias=True, exclude={"encounter_id"})
+    encounter_ref.get.return_value = snapshot
+    return encounter_ref
+
+def test_firestore_update_participants_keeps_ids_in_sync():
+    """Tests that the denormalised participantIds array follows the participants list."""
+    mock_db = MagicMock()
+    encounter_ref = _mock_encounter_ref(mock_db, make_encounter())
+
+    repo = FirestoreEncounterRepository(mock_db)
+    repo.update(FAKE_ENCOUNTER_ID, {"participants": [{"practitionerId": "pr-2", "role": "nurse"}]})
+
+    assert encounter_ref.update.call_args[0][0]["participantIds"] == ["pr-2"]
+
+def test_firestore_link_observation_uses_array_union():
+    """Tests that linking is an idempotent server-side array union."""
+    mock_db = MagicMock()
+    encounter_ref = _mock_encounter_ref(mock_db, make_encounter())
+
+    repo = FirestoreEncounterRepository(mock_db)
+    repo.link_observation(FAKE_ENCOUNTER_ID, "obs-1")
+
+    assert isinstance(encounter_ref.update.call_args[0][0]["observationIds"], firestore.ArrayUnion)
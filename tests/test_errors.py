@@ -0,0 +1,111 @@
+from fastapi.testclient import TestClient
+from pydantic import BaseModel
+from typing import List
+
+from fastapi import FastAPI, HTTPException
+from app.core.request_context import request_id_var, trace_id_var
+from app.errors.handlers import register_exception_handlers
+from app.errors.problems import PROBLEM_JSON, problem_body, problem_type
+from app.repositories.base import ConflictError, NotFoundError
+from app.services.state_machine import InvalidTransitionError
+
+# --- Test Setup ---
+
+class Participant(BaseModel):
+    practitionerId: str
+
+class EncounterIn(BaseModel):
+    start: str
+    participants: List[Participant]
+
+app = FastAPI()
+register_exception_handlers(app)
+
+@app.get("/api/v1/patients/{patientId}")
+def read_patient(patientId: str):
+    raise HTTPException(status_code=404, detail="Patient not found")
+
+@app.get("/api/v1/secure")
+def secure():
+    raise HTTPException(status_code=401, detail="Missing bearer token", headers={"WWW-Authenticate": "Bearer"})
+
+@app.post("/api/v1/encounters")
+def create_encounter(encounter_in: EncounterIn):
+    return encounter_in
+
+@app.put("/api/v1/patients/{patientId}")
+def update_patient(patientId: str):
+    raise ConflictError("A patient with MRN 'MRN-1' already exists.")
+
+@app.post("/api/v1/appointments/{appointmentId}/status")
+def change_status(appointmentId: str):
+    raise InvalidTransitionError("Cannot move an appointment from 'fulfilled' to 'booked'")
+
+@app.get("/fhir/Patient/{patientId}")
+def read_fhir_patient(patientId: str):
+    raise NotFoundError(f"Patient '{patientId}' not found.")
+
+client = TestClient(app)
+
+# --- Problem Body Test Cases ---
+
+def test_problem_body_carries_status_phrase_and_request_ids():
+    """Tests that a problem defaults to about:blank with the status phrase and the current request and trace IDs."""
+    request_token, trace_token = request_id_var.set("req-1"), trace_id_var.set("trace-1")
+    try:
+        body = problem_body(404, "Patient not found", instance="/api/v1/patients/p-1")
+    finally:
+        request_id_var.reset(request_token)
+        trace_id_var.reset(trace_token)
+
+    assert body == {
+        "type": "about:blank",
+        "title": "Not Found",
+        "status": 404,
+        "detail": "Patient not found",
+        "instance": "/api/v1/patients/p-1",
+        "requestId": "req-1",
+        "traceId": "trace-1",
+    }
+
+# --- Handler Test Cases ---
+
+def test_http_exceptions_become_problems_and_keep_headers():
+    """Tests that HTTPExceptions are served as problem+json with their detail and headers."""
+    not_found = client.get("/api/v1/patients/p-1")
+    unauthorized = client.get("/api/v1/secure")
+
+    assert not_found.status_code == 404
+    assert not_found.headers["content-type"] == PROBLEM_JSON
+    assert not_found.json()["detail"] == "Patient not found"
+    assert not_found.json()["title"] == "Not Found"
+    assert unauthorized.headers["WWW-Authenticate"] == "Bearer"
+
+def test_validation_errors_list_each_field():
+    """Tests that a 422 names every invalid field by its path in the body."""
+    response = client.post("/api/v1/encounters", json={"participants": [{"practitionerId": "pr-1"}, {}]})
+
+    body = response.json()
+    assert response.status_code == 422
+    assert body["type"] == problem_type("validation-error")
+    assert sorted(error["field"] for error in body["errors"]) == ["participants[1].practitionerId", "start"]
+    assert {error["code"] for error in body["errors"]} == {"missing"}
+
+def test_domain_errors_map_to_typed_problems():
+    """Tests that repository and state machine errors escaping a handler get their own problem types."""
+    conflict = client.put("/api/v1/patients/p-1")
+    transition = client.post("/api/v1/appointments/a-1/status")
+
+    assert conflict.status_code == 409
+    assert conflict.json()["type"] == problem_type("conflict")
+    assert "MRN-1" in conflict.json()["detail"]
+    assert transition.status_code == 409
+    assert transition.json()["type"] == problem_type("invalid-transition")
+
+def test_fhir_errors_stay_operation_outcomes():
+    """Tests that errors under /fhir are answered with an OperationOutcome instead."""
+    response = client.get("/fhir/Patient/p-9")
+
+    assert response.status_code == 404
+    assert response.headers["content-type"] == "application/fhir+json"
+    assert response.json()["issue"][0]["code"] == "not-found"
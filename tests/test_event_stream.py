@@ -0,0 +1,122 @@
+import asyncio
+from datetime import datetime, timedelta, timezone
+
+import pytest
+
+from app.events.envelope import Event
+from app.events.publisher import EventPublisher
+from app.events.stream import EventStream, StreamEventPublisher, format_event_id, parse_event_id, sse_messages, visible_to
+from app.repositories.memory import MemoryStore, MemoryStreamEventRepository
+
+# --- Test Setup ---
+
+NOW = datetime(2026, 10, 1, 9, 0, tzinfo=timezone.utc)
+STAFF = {"uid": "staff-1", "roles": ["clinician"]}
+PATIENT = {"uid": "line-1", "roles": ["patient"], "patientId": "p-1"}
+
+class RecordingPublisher(EventPublisher):
+    def __init__(self):
+        self.events = []
+
+    def publish(self, event: Event) -> None:
+        self.events.append(event)
+
+def emit(repo, event_type, subject, at, **data):
+    event = Event(type=event_type, subject=subject, data=data, occurredAt=at)
+    repo.append(event)
+    return event
+
+def open_stream(repo, principal, after=None, types=None, now=NOW):
+    return EventStream(repo, principal, None, types=types, after=after, clock=lambda: now)
+
+# --- Visibility Test Cases ---
+
+def test_patients_see_only_their_own_records():
+    """Tests that a patient's stream holds their own appointments and results, and staff see every patient's."""
+    repo = MemoryStreamEventRepository(MemoryStore())
+    emit(repo, "appointment.booked", "appointments/a-1", NOW + timedelta(seconds=1), patientId="p-1")
+    emit(repo, "appointment.booked", "appointments/a-2", NOW + timedelta(seconds=2), patientId="p-2")
+    emit(repo, "lab_result.recorded", "patients/p-1/lab-results/l-1", NOW + timedelta(seconds=3))
+
+    mine = open_stream(repo, PATIENT).read()
+    everyone = open_stream(repo, STAFF).read()
+
+    assert [e.subject for e in mine] == ["appointments/a-1", "patients/p-1/lab-results/l-1"]
+    assert len(everyone) == 3
+
+def test_events_of_unreadable_resources_are_not_sent():
+    """Tests that an event is only visible with the read permission of its resource, or of the caller's own records."""
+    repo = MemoryStreamEventRepository(MemoryStore())
+    emit(repo, "care_plan.created", "care-plans/c-1", NOW + timedelta(seconds=1), patientId="p-1")
+    emit(repo, "care_plan.created", "care-plans/c-2", NOW + timedelta(seconds=2), patientId="p-2")
+    mine, theirs = repo.list_since(NOW)
+    api_key = {"uid": "key-1", "authMethod": "api_key", "scopes": ["appointments:read"]}
+
+    assert visible_to(mine, PATIENT) and not visible_to(theirs, PATIENT)
+    assert not visible_to(mine, api_key)
+    assert visible_to(theirs, STAFF)
+
+# --- Reading Test Cases ---
+
+def test_each_event_is_read_once():
+    """Tests that later reads return only new events, including ones stamped inside the settle window."""
+    repo = MemoryStreamEventRepository(MemoryStore())
+    stream = open_stream(repo, STAFF)
+    emit(repo, "appointment.booked", "appointments/a-1", NOW + timedelta(seconds=10), patientId="p-1")
+    assert [e.subject for e in stream.read()] == ["appointments/a-1"]
+
+    emit(repo, "appointment.cancelled", "appointments/a-2", NOW + timedelta(seconds=8), patientId="p-1")
+
+    assert [e.subject for e in stream.read()] == ["appointments/a-2"]
+    assert stream.read() == []
+
+def test_reads_resume_after_the_last_event_id():
+    """Tests that a stream opened with a Last-Event-ID starts after that event, and filters by type."""
+    repo = MemoryStreamEventRepository(MemoryStore())
+    first = emit(repo, "appointment.booked", "appointments/a-1", NOW, patientId="p-1")
+    emit(repo, "appointment.rescheduled", "appointments/a-1", NOW + timedelta(seconds=1), patientId="p-1")
+    emit(repo, "appointment.cancelled", "appointments/a-1", NOW + timedelta(seconds=2), patientId="p-1")
+    [sent] = repo.list_since(NOW, limit=1)
+
+    after = parse_event_id(format_event_id(sent))
+    stream = open_stream(repo, STAFF, after=after, types=frozenset({"appointment.cancelled"}), now=NOW + timedelta(minutes=1))
+
+    assert after == (first.occurred_at, first.id)
+    assert [e.type for e in stream.read()] == ["appointment.cancelled"]
+
+def test_invalid_last_event_ids_are_rejected():
+    """Tests that an ID the stream did not send raises ValueError."""
+    for value in ("", "abc", "123", "12x-e-1"):
+        with pytest.raises(ValueError):
+            parse_event_id(value)
+
+# --- Publishing Test Cases ---
+
+def test_publisher_records_events_and_hands_them_on():
+    """Tests that the stream publisher stores each event and passes it to the next publisher."""
+    repo = MemoryStreamEventRepository(MemoryStore())
+    inner = RecordingPublisher()
+    event = Event(type="patient.created", subject="patients/p-1", data={"patientId": "p-1"})
+
+    StreamEventPublisher(inner, repo).publish(event)
+
+    assert inner.events == [event]
+    [stored] = repo.list_since(event.occurred_at)
+    assert (stored.event_id, stored.resource, stored.patient_id) == (event.id, "patients", "p-1")
+
+def test_stream_text_ends_after_max_seconds():
+    """Tests that the response sends the retry interval, a reset when asked, the events, and then ends."""
+    repo = MemoryStreamEventRepository(MemoryStore())
+    stream = open_stream(repo, STAFF, now=datetime.now(timezone.utc) - timedelta(seconds=1))
+    event = emit(repo, "appointment.booked", "appointments/a-1", datetime.now(timezone.utc), patientId="p-1")
+
+    async def collect():
+        async def connected():
+            return False
+        return [m async for m in sse_messages(stream, connected, poll_seconds=0.01, heartbeat_seconds=60, max_seconds=0.05, reset=True)]
+
+    messages = asyncio.run(collect())
+
+    assert messages[:2] == ["retry: 1010\n\n", "event: reset\ndata: {}\n\n"]
+    assert messages[2].startswith(f"id: {format_event_id(repo.list_since(event.occurred_at)[0])}\nevent: appointment.booked\n")
+    assert len(messages) == 3
@@ -0,0 +1,125 @@
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock, patch
+from datetime import date, datetime, timezone
+from zoneinfo import ZoneInfo
+
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_event_publisher, get_patient_repository
+from app.api.v1.endpoints import patients
+from app.dependencies.auth import get_current_user
+from app.events.envelope import Event, resource_event
+from app.events.publisher import EventPublisher, PubSubEventPublisher
+from app.hl7v2.parser import parse_message
+from app.hl7v2.processing import process_adt
+from app.repositories.patients import PatientRepository
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(patients.router, prefix="/api/v1/patients", tags=["Patients"])
+app.dependency_overrides[get_current_user] = lambda: {"uid": "staff-uid-123"}
+client = TestClient(app)
+
+FAKE_PATIENT_ID = "patient-1"
+NOW = datetime(2024, 5, 1, 9, 0, tzinfo=timezone.utc)
+
+def make_patient(**overrides):
+    data = {
+        "patientId": FAKE_PATIENT_ID,
+        "givenName": "Somchai",
+        "familyName": "Jaidee",
+        "dob": date(1980, 4, 1),
+        "mrn": "MRN-0001",
+        "createdAt": NOW,
+        "updatedAt": NOW,
+    }
+    data.update(overrides)
+    return schemas.Patient.model_validate(data)
+
+class RecordingPublisher(EventPublisher):
+    def __init__(self, error=None):
+        self.events = []
+        self.error = error
+
+    def publish(self, event: Event) -> None:
+        if self.error:
+            raise self.error
+        self.events.append(event)
+
+# --- Envelope Test Cases ---
+
+def test_resource_event_carries_resource_in_api_form():
+    """Tests that the envelope holds the resource with camelCase keys and JSON values."""
+    event = resource_event("patient.created", f"patients/{FAKE_PATIENT_ID}", make_patient())
+
+    body = event.model_dump(mode="json", by_alias=True)
+    assert body["type"] == "patient.created"
+    assert body["subject"] == f"patients/{FAKE_PATIENT_ID}"
+    assert body["data"]["patientId"] == FAKE_PATIENT_ID
+    assert body["data"]["dob"] == "1980-04-01"
+    assert "occurredAt" in body and len(body["id"]) == 32
+
+def test_pubsub_publisher_sets_filter_attributes():
+    """Tests that the event ID, type and subject are also sent as message attributes."""
+    event = Event(type="patient.deleted", subject=f"patients/{FAKE_PATIENT_ID}", data={"patientId": FAKE_PATIENT_ID})
+
+    with patch("app.events.publisher.publish_json") as publish_json:
+        PubSubEventPublisher("domain-events").publish(event)
+
+    topic, payload = publish_json.call_args[0]
+    assert topic == "domain-events"
+    assert payload["data"] == {"patientId": FAKE_PATIENT_ID}
+    assert publish_json.call_args.kwargs == {"eventId": event.id, "type": "patient.deleted", "subject": f"patients/{FAKE_PATIENT_ID}"}
+
+# --- Publisher Test Cases ---
+
+def test_emit_builds_deletion_event_from_keywords():
+    """Tests that events without a resource carry the keyword data."""
+    publisher = RecordingPublisher()
+
+    publisher.emit("patient.deleted", f"patients/{FAKE_PATIENT_ID}", patientId=FAKE_PATIENT_ID)
+
+    assert publisher.events[0].data == {"patientId": FAKE_PATIENT_ID}
+
+def test_emit_swallows_publish_errors():
+    """Tests that a publish failure is logged rather than raised into the request."""
+    publisher = RecordingPublisher(error=RuntimeError("pubsub unavailable"))
+
+    publisher.emit("patient.created", f"patients/{FAKE_PATIENT_ID}", make_patient())
+
+    assert publisher.events == []
+
+# --- Endpoint Test Cases ---
+
+def test_create_patient_emits_event():
+    """Tests that registering a patient publishes patient.created with the stored record."""
+    repo = MagicMock(spec=PatientRepository)
+    repo.create.return_value = make_patient()
+    events = MagicMock(spec=EventPublisher)
+    app.dependency_overrides[get_patient_repository] = lambda: repo
+    app.dependency_overrides[get_event_publisher] = lambda: events
+
+    response = client.post("/api/v1/patients", json={
+        "given_name": "Somchai", "family_name": "Jaidee", "dob": "1980-04-01", "mrn": "MRN-0001",
+    })
+
+    app.dependency_overrides.pop(get_patient_repository, None)
+    app.dependency_overrides.pop(get_event_publisher, None)
+    assert response.status_code == 201
+    events.emit.assert_called_once_with("patient.created", f"patients/{FAKE_PATIENT_ID}", repo.create.return_value)
+
+def test_hl7v2_adt_emits_patient_event():
+    """Tests that an ADT message that creates a patient publishes patient.created."""
+    repo = MagicMock(spec=PatientRepository)
+    repo.get_by_mrn.return_value = None
+    repo.create.return_value = make_patient()
+    events = MagicMock(spec=EventPublisher)
+    message = parse_message(
+        "MSH|^~\\&|EPIC|HOSP|MEGACARE|MC|20240501090000||ADT^A04|MSG001|P|2.5\r"
+        "PID|1||MRN-0001^^^HOSP^MR||Jaidee^Somchai||19800401|M\r"
+    )
+
+    process_adt(message, repo, ZoneInfo("Asia/Bangkok"), events)
+
+    events.emit.assert_called_once_with("patient.created", f"patients/{FAKE_PATIENT_ID}", repo.create.return_value)
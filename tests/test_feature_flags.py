@@ -0,0 +1,153 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+
+from fastapi import Depends, FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_feature_flag_repository
+from app.api.v1.endpoints import feature_flags
+from app.dependencies import flags as flag_dependencies
+from app.dependencies.auth import get_current_user
+from app.dependencies.flags import require_flag
+from app.flags.flags import FeatureFlags, bucket, evaluate, parse_flags
+from app.repositories.feature_flags import FeatureFlagRepository
+from app.repositories.memory import MemoryFeatureFlagRepository, MemoryStore
+from app.tenancy.context import acting_for
+
+# --- Test Setup ---
+
+PLATFORM_ADMIN_USER = {"uid": "ops-1", "roles": ["platform-admin"]}
+
+def make_flag(key="telehealth-visits", **overrides):
+    data = {"key": key, "enabled": True, "percentage": 100}
+    data.update(overrides)
+    return schemas.FeatureFlag.model_validate(data)
+
+class FakeClock:
+    def __init__(self):
+        self.now = 0.0
+    def __call__(self):
+        return self.now
+
+def make_client(monkeypatch, repo, flags, user=PLATFORM_ADMIN_USER):
+    app = FastAPI()
+    app.include_router(feature_flags.router, prefix="/api/v1/admin/flags")
+    app.dependency_overrides[get_current_user] = lambda: user
+    app.dependency_overrides[get_feature_flag_repository] = lambda: repo
+    monkeypatch.setattr(feature_flags, "get_feature_flags", lambda: flags)
+    return TestClient(app)
+
+# --- Evaluation Test Cases ---
+
+def test_configured_flags_are_parsed():
+    """Tests on/off/percentage entries, organizations, and that malformed entries are rejected."""
+    flags = parse_flags("telehealth-visits=off:clinic-a+clinic-b,new-reports=25, , legacy=off,bulk-import=on")
+
+    assert flags["telehealth-visits"].organizations == ["clinic-a", "clinic-b"]
+    assert (flags["telehealth-visits"].enabled, flags["telehealth-visits"].percentage) == (True, 0)
+    assert (flags["new-reports"].enabled, flags["new-reports"].percentage) == (True, 25)
+    assert flags["legacy"].enabled is False
+    assert flags["bulk-import"].percentage == 100
+    assert {flag.source for flag in flags.values()} == {"config"}
+    for spec in ("new-reports=101", "new-reports=maybe", "New Reports=on", "=on"):
+        with pytest.raises(ValueError):
+            parse_flags(spec)
+
+def test_flags_are_on_for_listed_organizations_and_a_stable_share_of_the_rest():
+    """Tests kill switch, per-organization overrides and that raising the percentage only adds callers."""
+    organizations = [f"clinic-{n}" for n in range(400)]
+    ten = make_flag(percentage=10)
+    fifty = make_flag(percentage=50)
+    on_at_ten = {o for o in organizations if evaluate(ten, o, None)}
+    on_at_fifty = {o for o in organizations if evaluate(fifty, o, None)}
+
+    assert on_at_ten < on_at_fifty
+    assert 10 <= len(on_at_ten) <= 80
+    assert evaluate(make_flag(percentage=0, organizations=["clinic-a"]), "clinic-a", None)
+    assert not evaluate(make_flag(enabled=False, organizations=["clinic-a"]), "clinic-a", None)
+    assert evaluate(ten, None, "nurse-1") == (bucket(ten.key, "nurse-1") < 10)
+    assert not evaluate(ten, None, None)
+    assert evaluate(make_flag(), None, None)
+
+def test_stored_flags_override_configured_ones_and_are_cached():
+    """Tests precedence, the cache lifetime, invalidation, and falling back when the store fails."""
+    flags_repo = MemoryFeatureFlagRepository(MemoryStore())
+    clock = FakeClock()
+    repository_factory = MagicMock(return_value=flags_repo)
+    flags = FeatureFlags(parse_flags("telehealth-visits=off,new-reports=on"), repository_factory, cache_seconds=30, clock=clock)
+    assert not flags.is_enabled("telehealth-visits")
+
+    flags_repo.create(schemas.FeatureFlagCreate(key="telehealth-visits", enabled=True), updated_by="ops-1")
+    assert not flags.is_enabled("telehealth-visits")
+    clock.now = 31
+    assert flags.is_enabled("telehealth-visits")
+    assert [(flag.key, flag.source) for flag in flags.all()] == [("new-reports", "config"), ("telehealth-visits", "store")]
+    assert not flags.is_enabled("unknown")
+
+    flags_repo.update("telehealth-visits", schemas.FeatureFlagUpdate(enabled=False), updated_by="ops-1")
+    flags.invalidate()
+    assert not flags.is_enabled("telehealth-visits")
+
+    failing = MagicMock(spec=FeatureFlagRepository)
+    failing.list.side_effect = RuntimeError("store unavailable")
+    repository_factory.return_value = failing
+    clock.now = 62
+    assert flags.get("telehealth-visits").enabled is False
+    assert flags.is_enabled("new-reports")
+
+def test_flags_follow_the_current_organization():
+    """Tests that evaluation defaults to the organization the request acts for."""
+    flags_repo = MemoryFeatureFlagRepository(MemoryStore())
+    flags = FeatureFlags({"telehealth-visits": make_flag(percentage=0, organizations=["clinic-a"])}, lambda: flags_repo)
+
+    with acting_for("clinic-a"):
+        assert flags.is_enabled("telehealth-visits")
+    with acting_for("clinic-b"):
+        assert not flags.is_enabled("telehealth-visits")
+
+def test_dark_launched_routes_answer_404_until_their_flag_is_on(monkeypatch):
+    """Tests the require_flag dependency."""
+    flags_repo = MemoryFeatureFlagRepository(MemoryStore())
+    flags = FeatureFlags(parse_flags("telehealth-visits=off"), lambda: flags_repo)
+    monkeypatch.setattr(flag_dependencies, "get_feature_flags", lambda: flags)
+    app = FastAPI()
+
+    @app.get("/api/v1/telehealth", dependencies=[Depends(require_flag("telehealth-visits"))])
+    def telehealth():
+        return {"ok": True}
+
+    client = TestClient(app)
+    assert client.get("/api/v1/telehealth").status_code == 404
+    flags.configured = parse_flags("telehealth-visits=on")
+    assert client.get("/api/v1/telehealth").json() == {"ok": True}
+
+# --- Admin Endpoint Test Cases ---
+
+def test_platform_admins_manage_flags(monkeypatch):
+    """Tests create, list with configured flags, update, duplicate keys and delete."""
+    flags_repo = MemoryFeatureFlagRepository(MemoryStore())
+    flags = FeatureFlags(parse_flags("new-reports=25"), lambda: flags_repo)
+    client = make_client(monkeypatch, flags_repo, flags)
+
+    created = client.post("/api/v1/admin/flags", json={"key": "telehealth-visits", "enabled": True, "percentage": 10})
+    duplicate = client.post("/api/v1/admin/flags", json={"key": "telehealth-visits"})
+    listed = client.get("/api/v1/admin/flags")
+    updated = client.patch("/api/v1/admin/flags/telehealth-visits", json={"percentage": 50})
+
+    assert created.status_code == 201
+    assert created.json()["updated_by"] == "ops-1"
+    assert duplicate.status_code == 409
+    assert [(f["key"], f["source"]) for f in listed.json()] == [("new-reports", "config"), ("telehealth-visits", "store")]
+    assert updated.json()["percentage"] == 50
+    assert flags.get("telehealth-visits").percentage == 50
+    assert client.delete("/api/v1/admin/flags/telehealth-visits").status_code == 204
+    assert client.get("/api/v1/admin/flags/telehealth-visits").status_code == 404
+    assert client.patch("/api/v1/admin/flags/new-reports", json={"percentage": 50}).status_code == 404
+
+def test_flags_are_managed_by_platform_admins_only(monkeypatch):
+    """Tests that clinic administrators cannot change flags."""
+    flags_repo = MemoryFeatureFlagRepository(MemoryStore())
+    client = make_client(monkeypatch, flags_repo, FeatureFlags({}, lambda: flags_repo), user={"uid": "admin-a", "roles": ["admin"]})
+
+    assert client.post("/api/v1/admin/flags", json={"key": "telehealth-visits"}).status_code == 403
+    assert client.get("/api/v1/admin/flags").status_code == 403
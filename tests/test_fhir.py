@@ -0,0 +1,254 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import date, datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from app.api.fhir.router import fhir_router
+from app.api.v1 import schemas
+from app.api.v1.deps import get_observation_repository, get_patient_repository
+from app.dependencies.auth import get_current_user
+from app.fhir.mappers import (
+    MRN_SYSTEM, NHS_NUMBER_SYSTEM, FHIRMappingError, observation_from_fhir, observation_to_fhir,
+    parse_reference, parse_token, patient_from_fhir, patient_to_fhir,
+)
+from app.repositories.base import ConflictError
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(fhir_router, prefix="/fhir")
+app.dependency_overrides[get_current_user] = lambda: {"uid": "integration-uid-123", "roles": ["clinician"]}
+client = TestClient(app)
+
+FAKE_PATIENT_ID = "patient-1"
+FAKE_OBSERVATION_ID = "obs-1"
+SPO2_CODING = {"system": "http://loinc.org", "code": "59408-5", "display": "Oxygen saturation by pulse oximetry"}
+
+def make_patient():
+    return schemas.Patient.model_validate({
+        "patientId": FAKE_PATIENT_ID,
+        "givenName": "Somchai",
+        "familyName": "Jaidee",
+        "dob": date(1965, 3, 14),
+        "sex": "male",
+        "mrn": "MRN-001",
+        "contact": {"phoneNumber": "+66811111111", "city": "Bangkok"},
+        "createdAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 1, 2, tzinfo=timezone.utc),
+    })
+
+def make_observation():
+    return schemas.Observation.model_validate({
+        "observationId": FAKE_OBSERVATION_ID,
+        "patientId": FAKE_PATIENT_ID,
+        "code": SPO2_CODING,
+        "value": 96,
+        "unit": "%",
+        "effectiveAt": datetime(2024, 5, 1, 7, 30, tzinfo=timezone.utc),
+        "identifier": {"system": "urn:ehr:obs", "value": "A-1"},
+        "createdAt": datetime(2024, 5, 1, 7, 31, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 5, 1, 7, 31, tzinfo=timezone.utc),
+    })
+
+FHIR_PATIENT = {
+    "resourceType": "Patient",
+    "identifier": [{"system": "urn:hospital:mrn", "value": "H-9"}, {"system": MRN_SYSTEM, "value": "MRN-001"}],
+    "name": [{"use": "official", "family": "Jaidee", "given": ["Somchai"]}],
+    "gender": "male",
+    "birthDate": "1965-03-14",
+    "telecom": [{"system": "phone", "value": "+66811111111"}],
+}
+
+FHIR_OBSERVATION = {
+    "resourceType": "Observation",
+    "status": "final",
+    "code": {"coding": [SPO2_CODING]},
+    "subject": {"reference": f"Patient/{FAKE_PATIENT_ID}"},
+    "effectiveDateTime": "2024-05-01T07:30:00Z",
+    "valueQuantity": {"value": 96, "unit": "%", "system": "http://unitsofmeasure.org", "code": "%"},
+}
+
+@pytest.fixture
+def repos():
+    """Overrides the patient and observation repositories with mocks."""
+    mocks = {
+        "patients": MagicMock(spec=PatientRepository),
+        "observations": MagicMock(spec=ObservationRepository),
+    }
+    app.dependency_overrides[get_patient_repository] = lambda: mocks["patients"]
+    app.dependency_overrides[get_observation_repository] = lambda: mocks["observations"]
+    yield mocks
+    for dep in (get_patient_repository, get_observation_repository):
+        app.dependency_overrides.pop(dep, None)
+
+# --- Mapper Test Cases ---
+
+def test_patient_to_fhir():
+    """Tests that a patient is serialised with its MRN identifier, name and telecom."""
+    resource = patient_to_fhir(make_patient())
+
+    assert resource["resourceType"] == "Patient"
+    assert resource["id"] == FAKE_PATIENT_ID
+    assert resource["identifier"][0] == {"use": "usual", "system": MRN_SYSTEM, "value": "MRN-001"}
+    assert resource["name"][0]["family"] == "Jaidee"
+    assert resource["birthDate"] == "1965-03-14"
+    assert resource["telecom"] == [{"system": "phone", "value": "+66811111111"}]
+    assert resource["address"] == [{"city": "Bangkok"}]
+
+def test_patient_from_fhir_picks_megacare_mrn():
+    """Tests that only the identifier with the MegaCare MRN system becomes the MRN."""
+    patient_in = patient_from_fhir(FHIR_PATIENT)
+
+    assert patient_in.mrn == "MRN-001"
+    assert patient_in.given_name == "Somchai"
+    assert patient_in.dob == date(1965, 3, 14)
+    assert patient_in.contact.phone_number == "+66811111111"
+
+def test_nhs_number_round_trips_as_identifier():
+    """Tests that an NHS number is exposed as an NHS identifier and read back from one."""
+    resource = patient_to_fhir(make_patient().model_copy(update={"nhs_number": "9434765919"}))
+
+    assert resource["identifier"][1] == {"use": "official", "system": NHS_NUMBER_SYSTEM, "value": "9434765919"}
+    assert patient_from_fhir({**FHIR_PATIENT, "identifier": resource["identifier"]}).nhs_number == "9434765919"
+
+def test_patient_from_fhir_requires_mrn():
+    """Tests that a Patient without a MegaCare MRN is rejected."""
+    with pytest.raises(FHIRMappingError):
+        patient_from_fhir({**FHIR_PATIENT, "identifier": [{"system": "urn:hospital:mrn", "value": "H-9"}]})
+
+def test_observation_round_trip():
+    """Tests that an observation survives conversion to FHIR and back."""
+    resource = observation_to_fhir(make_observation())
+
+    assert resource["subject"] == {"reference": f"Patient/{FAKE_PATIENT_ID}"}
+    assert resource["valueQuantity"]["code"] == "%"
+
+    observation_in = observation_from_fhir(resource)
+    assert observation_in.patient_id == FAKE_PATIENT_ID
+    assert observation_in.code.code == "59408-5"
+    assert observation_in.value == 96
+    assert observation_in.identifier.value == "A-1"
+
+def test_observation_components_round_trip():
+    """Tests that blood pressure components are mapped to and from FHIR."""
+    observation = make_observation().model_copy(update={
+        "value": None, "unit": None,
+        "code": schemas.Coding(system="http://loinc.org", code="85354-9"),
+        "components": [schemas.ObservationComponent(code=schemas.Coding(system="http://loinc.org", code="8480-6"), value=120, unit="mm[Hg]")],
+    })
+
+    resource = observation_to_fhir(observation)
+
+    assert "valueQuantity" not in resource
+    assert resource["component"][0]["valueQuantity"]["value"] == 120
+    assert observation_from_fhir(resource).components[0].code.code == "8480-6"
+
+def test_observation_from_fhir_rejects_non_quantity_values():
+    """Tests that value types other than valueQuantity are reported rather than dropped."""
+    resource = {k: v for k, v in FHIR_OBSERVATION.items() if k != "valueQuantity"}
+    with pytest.raises(FHIRMappingError):
+        observation_from_fhir({**resource, "valueString": "normal"})
+
+def test_parse_token_and_reference():
+    """Tests FHIR token and reference parsing."""
+    assert parse_token("urn:x|123") == ("urn:x", "123")
+    assert parse_token("|123") == (None, "123")
+    assert parse_token("123") == (None, "123")
+    assert parse_reference("Patient/p1", "Patient") == "p1"
+    assert parse_reference("https://ehr.example/fhir/Patient/p1", "Patient") == "p1"
+    assert parse_reference("Group/g1", "Patient") is None
+
+# --- Endpoint Test Cases ---
+
+def test_read_patient_success(repos):
+    """Tests reading a Patient returns FHIR JSON with the FHIR media type."""
+    repos["patients"].get.return_value = make_patient()
+
+    response = client.get(f"/fhir/Patient/{FAKE_PATIENT_ID}")
+
+    assert response.status_code == 200
+    assert response.headers["content-type"].startswith("application/fhir+json")
+    assert response.json()["resourceType"] == "Patient"
+
+def test_read_patient_not_found(repos):
+    """Tests that a missing Patient returns an OperationOutcome."""
+    repos["patients"].get.return_value = None
+
+    response = client.get("/fhir/Patient/missing")
+
+    assert response.status_code == 404
+    assert response.json()["resourceType"] == "OperationOutcome"
+    assert response.json()["issue"][0]["code"] == "not-found"
+
+def test_search_patient_by_identifier(repos):
+    """Tests searching by MRN token returns a searchset Bundle."""
+    repos["patients"].get_by_mrn.return_value = make_patient()
+
+    response = client.get("/fhir/Patient", params={"identifier": f"{MRN_SYSTEM}|MRN-001"})
+
+    assert response.status_code == 200
+    bundle = response.json()
+    assert bundle["type"] == "searchset"
+    assert bundle["total"] == 1
+    assert bundle["entry"][0]["fullUrl"].endswith(f"/fhir/Patient/{FAKE_PATIENT_ID}")
+    repos["patients"].get_by_mrn.assert_called_once_with("MRN-001")
+
+def test_search_patient_foreign_system_is_empty(repos):
+    """Tests that identifiers from other systems do not match MegaCare MRNs."""
+    response = client.get("/fhir/Patient", params={"identifier": "urn:hospital:mrn|MRN-001"})
+
+    assert response.status_code == 200
+    assert response.json()["total"] == 0
+    repos["patients"].get_by_mrn.assert_not_called()
+
+def test_create_patient_success(repos):
+    """Tests creating a Patient from FHIR JSON sent as application/fhir+json."""
+    repos["patients"].create.return_value = make_patient()
+
+    response = client.post(
+        "/fhir/Patient", json=FHIR_PATIENT, headers={"Content-Type": "application/fhir+json"}
+    )
+
+    assert response.status_code == 201
+    assert response.headers["location"].endswith(f"/fhir/Patient/{FAKE_PATIENT_ID}")
+    assert repos["patients"].create.call_args[0][0].mrn == "MRN-001"
+
+def test_create_patient_duplicate_mrn(repos):
+    """Tests that a duplicate MRN returns a 409 OperationOutcome."""
+    repos["patients"].create.side_effect = ConflictError("A patient with MRN 'MRN-001' already exists.")
+
+    response = client.post("/fhir/Patient", json=FHIR_PATIENT)
+
+    assert response.status_code == 409
+    assert response.json()["issue"][0]["code"] == "duplicate"
+
+def test_create_observation_unknown_patient(repos):
+    """Tests that an Observation for an unknown Patient is rejected."""
+    repos["patients"].get.return_value = None
+
+    response = client.post("/fhir/Observation", json=FHIR_OBSERVATION)
+
+    assert response.status_code == 422
+    repos["observations"].create.assert_not_called()
+
+def test_search_observations_by_patient(repos):
+    """Tests searching observations by patient reference."""
+    repos["observations"].list.return_value = [make_observation()]
+
+    response = client.get("/fhir/Observation", params={"patient": f"Patient/{FAKE_PATIENT_ID}", "_count": 10})
+
+    assert response.status_code == 200
+    assert response.json()["entry"][0]["resource"]["id"] == FAKE_OBSERVATION_ID
+    repos["observations"].list.assert_called_once_with(FAKE_PATIENT_ID, limit=10)
+
+def test_capability_statement():
+    """Tests that /metadata advertises the supported resources."""
+    response = client.get("/fhir/metadata")
+
+    assert response.status_code == 200
+    types = [r["type"] for r in response.json()["rest"][0]["resource"]]
+    assert types == ["Patient", "Observation"]
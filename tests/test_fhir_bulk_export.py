@@ -0,0 +1,218 @@
+import json
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import date, datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from app.api.fhir.endpoints import bulk_export as bulk_export_endpoints
+from app.api.fhir.router import fhir_router
+from app.api.v1 import schemas
+from app.api.v1.deps import get_export_job_repository, get_task_queue
+from app.dependencies.auth import get_current_user
+from app.fhir import bulk_export
+from app.repositories.export_jobs import ExportJobRepository
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+from app.tasks.jobs import BULK_EXPORT_TASK
+from app.tasks.queue import TaskQueue
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(fhir_router, prefix="/fhir")
+app.dependency_overrides[get_current_user] = lambda: {"uid": "analytics-uid-123", "roles": ["clinician"]}
+client = TestClient(app)
+
+FAKE_JOB_ID = "job-1"
+TRANSACTION_TIME = datetime(2024, 6, 1, 12, 0, tzinfo=timezone.utc)
+ASYNC = {"Prefer": "respond-async", "Accept": "application/fhir+json"}
+
+def make_job(status="accepted", **overrides):
+    return schemas.ExportJob.model_validate({
+        "jobId": FAKE_JOB_ID,
+        "types": ["Patient", "Observation"],
+        "requestUrl": "http://testserver/fhir/$export",
+        "requestedBy": "analytics-uid-123",
+        "transactionTime": TRANSACTION_TIME,
+        "status": status,
+        "createdAt": TRANSACTION_TIME,
+        "updatedAt": TRANSACTION_TIME,
+        **overrides,
+    })
+
+def make_patient():
+    return schemas.Patient.model_validate({
+        "patientId": "patient-1", "givenName": "Somchai", "familyName": "Jaidee",
+        "dob": date(1965, 3, 14), "mrn": "MRN-001",
+        "createdAt": TRANSACTION_TIME, "updatedAt": TRANSACTION_TIME,
+    })
+
+@pytest.fixture
+def repos(monkeypatch):
+    """Overrides the export job repository and the task queue with mocks and configures a bucket."""
+    monkeypatch.setattr(bulk_export_endpoints, "FHIR_EXPORT_BUCKET", "exports-bucket")
+    mocks = {
+        "jobs": MagicMock(spec=ExportJobRepository),
+        "tasks": MagicMock(spec=TaskQueue),
+    }
+    app.dependency_overrides[get_export_job_repository] = lambda: mocks["jobs"]
+    app.dependency_overrides[get_task_queue] = lambda: mocks["tasks"]
+    yield mocks
+    for dep in (get_export_job_repository, get_task_queue):
+        app.dependency_overrides.pop(dep, None)
+
+# --- Kick-off Test Cases ---
+
+def test_kickoff_returns_status_location(repos):
+    """Tests that kick-off creates a job, queues it and points to the status URL."""
+    repos["jobs"].create.return_value = make_job()
+
+    response = client.post("/fhir/$export?_type=Patient&_since=2024-01-01T00:00:00Z", headers=ASYNC)
+
+    assert response.status_code == 202
+    assert response.headers["content-location"] == f"http://testserver/fhir/bulkstatus/{FAKE_JOB_ID}"
+    job_in = repos["jobs"].create.call_args[0][0]
+    assert job_in.types == ["Patient"]
+    assert job_in.since == datetime(2024, 1, 1, tzinfo=timezone.utc)
+    repos["tasks"].enqueue.assert_called_once_with(BULK_EXPORT_TASK, {"jobId": FAKE_JOB_ID}, task_id=f"bulk-export-{FAKE_JOB_ID}")
+
+def test_kickoff_reads_parameters_body(repos):
+    """Tests that POST kick-off accepts a FHIR Parameters resource."""
+    repos["jobs"].create.return_value = make_job()
+
+    response = client.post("/fhir/$export", headers=ASYNC, json={
+        "resourceType": "Parameters",
+        "parameter": [{"name": "_type", "valueString": "Observation"}],
+    })
+
+    assert response.status_code == 202
+    assert repos["jobs"].create.call_args[0][0].types == ["Observation"]
+
+def test_kickoff_requires_respond_async(repos):
+    """Tests that the Prefer: respond-async header is mandatory."""
+    response = client.post("/fhir/$export")
+
+    assert response.status_code == 400
+    repos["jobs"].create.assert_not_called()
+
+def test_kickoff_rejects_unsupported_type(repos):
+    """Tests that unknown resource types are reported in an OperationOutcome."""
+    response = client.post("/fhir/$export?_type=Patient,Claim", headers=ASYNC)
+
+    assert response.status_code == 400
+    assert "Claim" in response.json()["issue"][0]["diagnostics"]
+
+def test_kickoff_without_bucket(repos, monkeypatch):
+    """Tests that bulk export is unavailable when no bucket is configured."""
+    monkeypatch.setattr(bulk_export_endpoints, "FHIR_EXPORT_BUCKET", None)
+
+    response = client.post("/fhir/$export", headers=ASYNC)
+
+    assert response.status_code == 503
+
+# --- Status Test Cases ---
+
+def test_status_in_progress(repos):
+    """Tests 202 with X-Progress while the job is running."""
+    repos["jobs"].get.return_value = make_job(status="in-progress", progress="Exporting Patient (1/2)")
+
+    response = client.get(f"/fhir/bulkstatus/{FAKE_JOB_ID}")
+
+    assert response.status_code == 202
+    assert response.headers["x-progress"] == "Exporting Patient (1/2)"
+    assert "retry-after" in response.headers
+
+def test_status_complete_manifest(repos, monkeypatch):
+    """Tests that a completed job returns a manifest with signed URLs."""
+    monkeypatch.setattr(bulk_export_endpoints, "signed_download_url", lambda bucket, blob, ttl: f"https://signed/{bucket}/{blob}")
+    repos["jobs"].get.return_value = make_job(status="completed", output=[
+        {"type": "Patient", "blobName": "bulk-export/job-1/Patient.ndjson", "count": 2},
+    ])
+
+    response = client.get(f"/fhir/bulkstatus/{FAKE_JOB_ID}")
+
+    assert response.status_code == 200
+    manifest = response.json()
+    assert manifest["transactionTime"] == TRANSACTION_TIME.isoformat()
+    assert manifest["requiresAccessToken"] is False
+    assert manifest["output"] == [{"type": "Patient", "url": "https://signed/exports-bucket/bulk-export/job-1/Patient.ndjson", "count": 2}]
+    assert "expires" in response.headers
+
+def test_status_other_users_job_is_hidden(repos):
+    """Tests that a job cannot be polled by a user who did not start it."""
+    repos["jobs"].get.return_value = make_job(requestedBy="someone-else")
+
+    response = client.get(f"/fhir/bulkstatus/{FAKE_JOB_ID}")
+
+    assert response.status_code == 404
+
+def test_cancel_export(repos):
+    """Tests that DELETE on the status URL cancels the job."""
+    repos["jobs"].get.return_value = make_job(status="in-progress")
+
+    response = client.delete(f"/fhir/bulkstatus/{FAKE_JOB_ID}")
+
+    assert response.status_code == 202
+    repos["jobs"].update.assert_called_once_with(FAKE_JOB_ID, {"status": "cancelled", "progress": None})
+
+# --- Exporter Test Cases ---
+
+def fake_bucket():
+    """Returns a bucket mock whose blobs capture what is written to them."""
+    bucket = MagicMock()
+    written = {}
+    def blob(name):
+        b = MagicMock()
+        b.name = name
+        buffer = []
+        written[name] = buffer
+        b.open.return_value.__enter__.return_value.write.side_effect = buffer.append
+        return b
+    bucket.blob.side_effect = blob
+    return bucket, written
+
+def test_run_export_writes_ndjson(monkeypatch):
+    """Tests that each type is written as NDJSON within the export window and recorded on the job."""
+    bucket, written = fake_bucket()
+    monkeypatch.setattr(bulk_export, "get_storage_client", lambda: MagicMock(bucket=MagicMock(return_value=bucket)))
+    jobs = MagicMock(spec=ExportJobRepository)
+    jobs.get.return_value = make_job()
+    patients = MagicMock(spec=PatientRepository)
+    patients.stream.return_value = [make_patient()]
+    observations = MagicMock(spec=ObservationRepository)
+    observations.stream.return_value = []
+
+    bulk_export.run_export(FAKE_JOB_ID, jobs, patients, observations, "exports-bucket")
+
+    patients.stream.assert_called_once_with(updated_since=None, updated_before=TRANSACTION_TIME)
+    lines = "".join(written["bulk-export/job-1/Patient.ndjson"]).splitlines()
+    assert [json.loads(line)["id"] for line in lines] == ["patient-1"]
+    final = jobs.update.call_args_list[-1][0][1]
+    assert final["status"] == "completed"
+    # Types without resources produce no output file.
+    assert final["output"] == [{"type": "Patient", "blobName": "bulk-export/job-1/Patient.ndjson", "count": 1}]
+
+def test_run_export_stops_when_cancelled(monkeypatch):
+    """Tests that the exporter stops before the next type once the job is cancelled."""
+    bucket, _written = fake_bucket()
+    monkeypatch.setattr(bulk_export, "get_storage_client", lambda: MagicMock(bucket=MagicMock(return_value=bucket)))
+    jobs = MagicMock(spec=ExportJobRepository)
+    jobs.get.side_effect = [make_job(), make_job(status="cancelled")]
+    patients = MagicMock(spec=PatientRepository)
+
+    bulk_export.run_export(FAKE_JOB_ID, jobs, patients, MagicMock(spec=ObservationRepository), "exports-bucket")
+
+    patients.stream.assert_not_called()
+    assert all(c[0][1].get("status") != "completed" for c in jobs.update.call_args_list)
+
+def test_run_export_records_failure(monkeypatch):
+    """Tests that an exporter failure marks the job as errored."""
+    monkeypatch.setattr(bulk_export, "get_storage_client", MagicMock(side_effect=RuntimeError("no credentials")))
+    jobs = MagicMock(spec=ExportJobRepository)
+    jobs.get.return_value = make_job()
+
+    bulk_export.run_export(FAKE_JOB_ID, jobs, MagicMock(spec=PatientRepository), MagicMock(spec=ObservationRepository), "exports-bucket")
+
+    assert jobs.update.call_args_list[-1][0][1] == {"status": "error", "progress": None, "error": "no credentials"}
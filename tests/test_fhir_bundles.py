@@ -0,0 +1,159 @@
+import asyncio
+import pytest
+
+from app.events.publisher import EventPublisher
+from app.fhir.bundles import BundleError, EntryFailed, process_bundle
+from app.fhir.mappers import MRN_SYSTEM
+from app.repositories import transactions
+from app.repositories.memory import MemoryObservationRepository, MemoryPatientRepository, get_memory_store
+
+# --- Test Setup ---
+
+BASE_URL = "https://api.megacare.example/fhir"
+CLINICIAN = {"uid": "integration-uid-123", "roles": ["clinician"]}
+SPO2_CODING = {"system": "http://loinc.org", "code": "59408-5", "display": "Oxygen saturation by pulse oximetry"}
+
+
+class RecordingPublisher(EventPublisher):
+    def __init__(self):
+        self.events = []
+
+    def publish(self, event) -> None:
+        self.events.append(event)
+
+
+def fhir_patient(mrn="MRN-001"):
+    return {
+        "resourceType": "Patient",
+        "identifier": [{"system": MRN_SYSTEM, "value": mrn}],
+        "name": [{"family": "Jaidee", "given": ["Somchai"]}],
+        "birthDate": "1965-03-14",
+    }
+
+
+def fhir_observation(subject):
+    return {
+        "resourceType": "Observation",
+        "status": "final",
+        "code": {"coding": [SPO2_CODING]},
+        "subject": {"reference": subject},
+        "effectiveDateTime": "2024-05-01T07:30:00Z",
+        "valueQuantity": {"value": 96, "unit": "%", "code": "%"},
+    }
+
+
+def post(resource, full_url=None):
+    entry = {"resource": resource, "request": {"method": "POST", "url": resource["resourceType"]}}
+    if full_url:
+        entry["fullUrl"] = full_url
+    return entry
+
+
+def get(url):
+    return {"request": {"method": "GET", "url": url}}
+
+
+def bundle(bundle_type, *entries):
+    return {"resourceType": "Bundle", "type": bundle_type, "entry": list(entries)}
+
+
+@pytest.fixture
+def store(monkeypatch):
+    """Runs bundles against the in-memory store, whose units of work roll back."""
+    monkeypatch.setattr(transactions, "STORE", "memory")
+    memory = get_memory_store()
+    memory.clear()
+    yield memory
+    memory.clear()
+
+
+def run(store, posted, principal=CLINICIAN, events=None):
+    return asyncio.run(process_bundle(
+        posted, principal, BASE_URL, MemoryPatientRepository(store), MemoryObservationRepository(store),
+        events or RecordingPublisher(),
+    ))
+
+# --- Transaction Test Cases ---
+
+def test_transaction_resolves_references_to_new_resources(store):
+    """Tests that an Observation can reference a Patient created in the same transaction by its fullUrl."""
+    events = RecordingPublisher()
+    response = run(store, bundle(
+        "transaction",
+        get(f"Patient?identifier={MRN_SYSTEM}|MRN-001"),
+        post(fhir_observation("urn:uuid:61ebe359-bfdc-4613-8bf2-c5e300945f0a")),
+        post(fhir_patient(), full_url="urn:uuid:61ebe359-bfdc-4613-8bf2-c5e300945f0a"),
+    ), events=events)
+
+    assert response["type"] == "transaction-response"
+    search, observation, patient = response["entry"]
+    assert patient["response"]["status"] == "201 Created"
+    patient_id = patient["resource"]["id"]
+    assert patient["response"]["location"] == f"Patient/{patient_id}"
+    assert observation["resource"]["subject"]["reference"] == f"Patient/{patient_id}"
+    # Reads run after the writes, so the search finds the new patient.
+    assert search["response"]["status"] == "200 OK"
+    assert search["resource"]["entry"][0]["resource"]["id"] == patient_id
+    assert [e.type for e in events.events] == ["patient.created", "observation.recorded"]
+
+def test_failed_transaction_stores_nothing(store):
+    """Tests that a transaction whose last entry fails rolls back the entries before it."""
+    with pytest.raises(EntryFailed) as failure:
+        run(store, bundle("transaction", post(fhir_patient()), get("Patient/missing")))
+
+    assert failure.value.status_code == 404
+    assert str(failure.value) == "Bundle.entry[1]: Patient/missing not found"
+    assert store.table("patients") == {}
+
+def test_transaction_is_checked_before_anything_is_written(store):
+    """Tests that a duplicate MRN or an unknown patient refuses the whole transaction up front."""
+    with pytest.raises(EntryFailed) as duplicate:
+        run(store, bundle("transaction", post(fhir_patient()), post(fhir_patient())))
+    with pytest.raises(EntryFailed) as unknown:
+        run(store, bundle("transaction", post(fhir_patient()), post(fhir_observation("Patient/nobody"))))
+
+    assert (duplicate.value.status_code, duplicate.value.code) == (409, "duplicate")
+    assert str(duplicate.value).startswith("Bundle.entry[1]:")
+    assert (unknown.value.status_code, unknown.value.code) == (422, "processing")
+    assert store.table("patients") == {}
+
+# --- Batch Test Cases ---
+
+def test_batch_entries_succeed_or_fail_on_their_own(store):
+    """Tests that each batch entry gets its own status and a failed one does not undo the others."""
+    response = run(store, bundle(
+        "batch",
+        post(fhir_patient()),
+        post({"resourceType": "Patient", "name": [{"family": "NoMrn"}]}),
+        get("Patient/missing"),
+        {"request": {"method": "DELETE", "url": "Patient/p-1"}},
+        {"request": {"method": "POST", "url": "Patient", "ifNoneExist": "identifier=MRN-001"}, "resource": fhir_patient()},
+    ))
+
+    assert response["type"] == "batch-response"
+    statuses = [e["response"]["status"] for e in response["entry"]]
+    assert statuses == ["201 Created", "400 Bad Request", "404 Not Found", "400 Bad Request", "400 Bad Request"]
+    assert response["entry"][2]["response"]["outcome"]["issue"][0]["code"] == "not-found"
+    assert response["entry"][4]["response"]["outcome"]["issue"][0]["code"] == "not-supported"
+    assert len(store.table("patients")) == 1
+
+def test_batch_entries_are_authorized_one_by_one(store):
+    """Tests that a patient-scoped token reaches its launch patient's record but not another's."""
+    patients = MemoryPatientRepository(store)
+    own = run(store, bundle("batch", post(fhir_patient("MRN-1"))))["entry"][0]["resource"]["id"]
+    other = run(store, bundle("batch", post(fhir_patient("MRN-2"))))["entry"][0]["resource"]["id"]
+    token = {"uid": "app-user", "roles": [], "scope": "patient/*.read", "patient": own}
+
+    response = run(store, bundle("batch", get(f"Patient/{own}"), get(f"{BASE_URL}/Patient/{other}")), principal=token)
+
+    assert [e["response"]["status"] for e in response["entry"]] == ["200 OK", "403 Forbidden"]
+    assert patients.get(other) is not None
+
+def test_only_batch_and_transaction_bundles_are_accepted(store):
+    """Tests that other resources and Bundle types are refused as a whole."""
+    with pytest.raises(BundleError) as not_bundle:
+        run(store, fhir_patient())
+    with pytest.raises(BundleError) as history:
+        run(store, bundle("history"))
+
+    assert (not_bundle.value.status_code, history.value.code) == (400, "not-supported")
@@ -0,0 +1,163 @@
+import pytest
+from datetime import datetime, timezone
+
+from app.api.v1 import schemas
+from app.cache import base as cache_base
+from app.cache.base import MemoryCache, read_through
+from app.crypto.envelope import FieldCipher, FieldEncryptionError, is_sealed
+from app.crypto.fields import encrypted_paths, open_fields
+from app.crypto.kms import KeyManager
+from app.events.envelope import resource_event
+from app.repositories import base
+from app.repositories.memory import MemoryEncounterRepository, MemoryPatientRepository, MemoryStore
+
+# --- Test Setup ---
+
+KEY_NAME = "projects/megacare/locations/asia-southeast1/keyRings/api/cryptoKeys/fields"
+
+class FakeKeyManager(KeyManager):
+    """Stands in for Cloud KMS: 'wraps' data keys by reversing them, under a version that can be rotated."""
+    def __init__(self):
+        self.version = 1
+        self.wraps = []
+        self.unwraps = []
+    def wrap(self, data_key):
+        self.wraps.append(data_key)
+        return data_key[::-1], f"{KEY_NAME}/cryptoKeyVersions/{self.version}"
+    def unwrap(self, wrapped, key_version):
+        self.unwraps.append(key_version)
+        return wrapped[::-1]
+
+class FakeClock:
+    def __init__(self):
+        self.now = 0.0
+    def __call__(self):
+        return self.now
+
+def use_cipher(monkeypatch, cipher):
+    monkeypatch.setattr(base, "get_field_cipher", lambda: cipher)
+    monkeypatch.setattr(cache_base, "get_field_cipher", lambda: cipher)
+
+def make_patient_in(**overrides):
+    data = {
+        "givenName": "Ann", "familyName": "Lee", "dob": "1980-01-01", "mrn": "MRN-1",
+        "ssn": "123-45-6789", "insurance": {"payer": "Blue Cross", "memberId": "XZ-0042"},
+    }
+    data.update(overrides)
+    return schemas.PatientCreate.model_validate(data)
+
+# --- Cipher Test Cases ---
+
+def test_values_are_sealed_under_a_wrapped_data_key():
+    """Tests the round trip, that KMS wraps one data key per lifetime, and that a value cannot move to another field."""
+    keys, clock = FakeKeyManager(), FakeClock()
+    cipher = FieldCipher(keys, data_key_seconds=60, clock=clock)
+
+    first, second = cipher.seal("123-45-6789", "ssn"), cipher.seal("123-45-6789", "ssn")
+    assert first != second and "6789" not in first
+    assert first.startswith(f"enc:v1:{KEY_NAME}/cryptoKeyVersions/1:")
+    assert cipher.open(first, "ssn") == "123-45-6789"
+    assert len(keys.wraps) == 1 and keys.unwraps == []
+
+    with pytest.raises(FieldEncryptionError):
+        cipher.open(first, "insurance.memberId")
+    with pytest.raises(FieldEncryptionError):
+        cipher.open(first[:-4] + "AAAA", "ssn")
+
+    clock.now = 61
+    cipher.seal("x", "ssn")
+    assert len(keys.wraps) == 2
+    # Another worker opens the value by unwrapping its data key once.
+    other = FieldCipher(keys)
+    assert [other.open(first, "ssn"), other.open(second, "ssn")] == ["123-45-6789"] * 2
+    assert len(keys.unwraps) == 1
+
+def test_files_are_sealed_with_raw_ciphertext():
+    """Tests the round trip of a file, and that it opens only for the context it was sealed for."""
+    cipher = FieldCipher(FakeKeyManager())
+    photo = b"\xff\xd8\xff\xe0" + bytes(range(256))
+
+    sealed = cipher.seal_bytes(photo, "woundImages/image-1")
+
+    assert sealed.startswith(f"enc:v1:{KEY_NAME}/cryptoKeyVersions/1:".encode())
+    assert photo not in sealed
+    assert FieldCipher(FakeKeyManager()).open_bytes(sealed, "woundImages/image-1") == photo
+    with pytest.raises(FieldEncryptionError):
+        cipher.open_bytes(sealed, "woundImages/image-2")
+    with pytest.raises(FieldEncryptionError):
+        cipher.open_bytes(photo, "woundImages/image-1")
+
+def test_encrypted_fields_are_found_in_nested_models():
+    """Tests the paths derived from the ENCRYPTED markers of the schemas."""
+    assert encrypted_paths(schemas.Patient) == ("ssn", "insurance.memberId")
+    assert encrypted_paths(schemas.Encounter) == ("psychotherapyNotes",)
+    assert encrypted_paths(schemas.IdempotencyRecord) == ("responseBody",)
+    assert encrypted_paths(schemas.Practitioner) == ()
+
+def test_sealed_values_need_a_key():
+    """Tests that a sealed value is never returned as it is when FIELD_ENCRYPTION_KEY is missing."""
+    sealed = FieldCipher(FakeKeyManager()).seal("123-45-6789", "ssn")
+
+    with pytest.raises(FieldEncryptionError):
+        open_fields({"ssn": sealed}, ("ssn",), None)
+    assert open_fields({"ssn": "123-45-6789"}, ("ssn",), None) == ({"ssn": "123-45-6789"}, {})
+
+# --- Repository Test Cases ---
+
+def test_repositories_store_only_ciphertext(monkeypatch):
+    """Tests create and update of patients and encounters, and that reads return the plaintext."""
+    use_cipher(monkeypatch, FieldCipher(FakeKeyManager()))
+    store = MemoryStore()
+    patients = MemoryPatientRepository(store)
+    encounters = MemoryEncounterRepository(store)
+
+    patient = patients.create(make_patient_in())
+    patients.update(patient.patient_id, schemas.PatientUpdate(insurance={"payer": "Aetna", "memberId": "AE-7"}))
+    encounter = encounters.create(schemas.EncounterCreate(
+        patientId=patient.patient_id, visitType="ambulatory", start=datetime(2024, 5, 1, tzinfo=timezone.utc),
+        psychotherapyNotes="Discussed sleep anxiety.",
+    ))
+
+    stored = store.table("patients")[patient.patient_id]["data"]
+    assert is_sealed(stored["ssn"]) and is_sealed(stored["insurance"]["memberId"])
+    assert stored["insurance"]["payer"] == "Aetna"
+    assert is_sealed(store.table("encounters")[encounter.encounter_id]["data"]["psychotherapyNotes"])
+    assert patient.ssn == "123-45-6789"
+    assert patients.get(patient.patient_id).insurance.member_id == "AE-7"
+    assert encounters.get(encounter.encounter_id).psychotherapy_notes == "Discussed sleep anxiety."
+
+def test_values_are_resealed_under_the_current_key_as_they_are_read(monkeypatch):
+    """Tests lazy re-encryption after a KMS rotation and of values stored before encryption was on."""
+    keys, clock = FakeKeyManager(), FakeClock()
+    use_cipher(monkeypatch, FieldCipher(keys, data_key_seconds=60, clock=clock))
+    store = MemoryStore()
+    patients = MemoryPatientRepository(store)
+    patient = patients.create(make_patient_in())
+    row = store.table("patients")[patient.patient_id]
+    row["data"]["insurance"]["memberId"] = "XZ-0042"
+
+    keys.version = 2
+    clock.now = 61
+    assert patients.get(patient.patient_id).ssn == "123-45-6789"
+
+    stored = store.table("patients")[patient.patient_id]
+    assert "cryptoKeyVersions/2:" in stored["data"]["ssn"]
+    assert "cryptoKeyVersions/2:" in stored["data"]["insurance"]["memberId"]
+    assert (stored["data"]["version"], stored["updated_at"]) == (1, patient.updated_at)
+    assert patients.get(patient.patient_id).insurance.member_id == "XZ-0042"
+
+# --- Event and Cache Test Cases ---
+
+def test_encrypted_fields_leave_the_service_only_sealed(monkeypatch):
+    """Tests that domain events drop encrypted fields and cache entries keep them sealed."""
+    use_cipher(monkeypatch, FieldCipher(FakeKeyManager()))
+    patient = MemoryPatientRepository(MemoryStore()).create(make_patient_in())
+    cache = MemoryCache()
+
+    event = resource_event("patient.created", f"patients/{patient.patient_id}", patient)
+    assert event.data["ssn"] is None and event.data["insurance"] == {"payer": "Blue Cross", "memberId": None, "groupNumber": None}
+    assert event.data["mrn"] == "MRN-1"
+
+    read_through(cache, "patient:id:1", 60, schemas.Patient, lambda: patient)
+    assert "123-45-6789" not in cache.get("patient:id:1")
+    assert read_through(cache, "patient:id:1", 60, schemas.Patient, lambda: None).ssn == "123-45-6789"
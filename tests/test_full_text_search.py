@@ -0,0 +1,234 @@
+import json
+from datetime import datetime, timezone
+from unittest.mock import MagicMock
+
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api.v1 import schemas
+from app.api.v1.endpoints import search as search_endpoint
+from app.dependencies.auth import get_current_user
+from app.events.envelope import Event, resource_event
+from app.events.publisher import NullEventPublisher
+from app.repositories.memory import (
+    MemoryAllergyRepository,
+    MemoryCarePlanRepository,
+    MemoryEncounterRepository,
+    MemoryPatientDocumentRepository,
+    MemoryPatientRepository,
+    MemoryStore,
+)
+from app.search import reindex
+from app.search.documents import IndexedDocument, Visibility, from_event, visibility
+from app.search.index import ElasticsearchIndex, EmbeddedSearchIndex, SearchIndexError, SearchQuery, get_search_index
+from app.search.publisher import SearchIndexEventPublisher
+
+# --- Test Setup ---
+
+NOW = datetime(2026, 10, 1, 9, 0, tzinfo=timezone.utc)
+EVERYTHING = Visibility(frozenset({"encounter", "care-plan", "allergy", "patient-document"}))
+
+def entry(kind, record_id, patient_id, title, text, tenant_id=None):
+    return IndexedDocument(
+        kind=kind, record_id=record_id, patient_id=patient_id, tenant_id=tenant_id,
+        subject=f"things/{record_id}", title=title, text=text, recorded_at=NOW,
+    )
+
+def filled_index():
+    index = EmbeddedSearchIndex()
+    index.upsert([
+        entry("encounter", "enc-1", "p-1", "CPAP mask leak", "Follow-up on nasal mask leak at night\nSleep clinic"),
+        entry("care-plan", "cp-1", "p-1", "Sleep apnea program", "Nightly CPAP use; mask refit"),
+        entry("allergy", "al-1", "p-2", "Penicillin", "Urticaria after <b>amoxicillin</b>"),
+        entry("care-plan", "cp-2", "p-3", "Sleep hygiene", "Café visits before bed", tenant_id="org-2"),
+    ])
+    return index
+
+def allergy(**changes):
+    return schemas.Allergy.model_validate({
+        "allergyId": "al-1", "patientId": "p-1", "recordedBy": "doc-1", "createdAt": NOW, "updatedAt": NOW,
+        "substance": {"system": "http://www.nlm.nih.gov/research/umls/rxnorm", "code": "7980", "display": "Penicillin G"},
+        "reactions": [{"manifestations": [{"system": "http://snomed.info/sct", "code": "126485001", "display": "Urticaria"}], "note": "Within an hour"}],
+        "note": "Reported by the patient's mother",
+        **changes,
+    })
+
+# --- Embedded Index Test Cases ---
+
+def test_every_word_must_match_and_the_last_as_a_prefix():
+    """Tests that words are ANDed, the last one matched as a prefix, ignoring case and diacritics."""
+    index = filled_index()
+
+    both = index.search(SearchQuery("mask LEA", EVERYTHING))
+    prefix = index.search(SearchQuery("slee", EVERYTHING))
+    accents = index.search(SearchQuery("cafe", EVERYTHING))
+
+    assert [hit.id for hit in both.hits] == ["enc-1"]
+    assert {hit.id for hit in prefix.hits} == {"enc-1", "cp-1", "cp-2"} and prefix.total == 3
+    assert [hit.id for hit in accents.hits] == ["cp-2"]
+
+def test_matches_are_highlighted_and_escaped():
+    """Tests that matched words are wrapped in <mark> and the rest of the text is HTML-escaped."""
+    hit = filled_index().search(SearchQuery("amoxicillin", EVERYTHING)).hits[0]
+
+    assert hit.highlights == {"text": ["Urticaria after &lt;b&gt;<mark>amoxicillin</mark>&lt;/b&gt;"]}
+    assert hit.subject == "things/al-1" and hit.patient_id == "p-2" and hit.score > 0
+
+def test_results_are_limited_to_what_the_caller_may_see():
+    """Tests that kinds without permission, other patients' own records and other tenants are never counted."""
+    index = filled_index()
+    patient = Visibility(frozenset(), own_kinds=frozenset({"encounter", "care-plan", "allergy"}), own_patient_id="p-1")
+    scoped = Visibility(EVERYTHING.kinds, tenant_id="org-2")
+
+    assert {hit.id for hit in index.search(SearchQuery("sleep", patient)).hits} == {"enc-1", "cp-1"}
+    assert index.search(SearchQuery("penicillin", patient)).total == 0
+    assert [hit.id for hit in index.search(SearchQuery("sleep", scoped)).hits] == ["cp-2"]
+    assert [hit.id for hit in index.search(SearchQuery("sleep", Visibility(frozenset({"encounter"})))).hits] == ["enc-1"]
+    assert [hit.id for hit in index.search(SearchQuery("sleep", EVERYTHING, kinds=frozenset({"care-plan"}), patient_id="p-1")).hits] == ["cp-1"]
+
+def test_upserts_replace_and_deletes_remove_entries():
+    """Tests that an entry is replaced by ID and removed from search once deleted."""
+    index = filled_index()
+    index.upsert([entry("encounter", "enc-1", "p-1", "Annual review", "Weight and blood pressure")])
+
+    assert index.search(SearchQuery("leak", EVERYTHING)).total == 0
+    assert index.search(SearchQuery("annual", EVERYTHING)).total == 1
+    index.delete(["encounter/enc-1", "encounter/unknown"])
+    assert index.search(SearchQuery("annual", EVERYTHING)).total == 0
+
+# --- Permission Test Cases ---
+
+def test_visibility_follows_role_and_scope_permissions():
+    """Tests the kinds callers may find by role, for a patient's own records, and for API key scopes."""
+    coordinator = visibility({"uid": "u-1", "roles": ["coordinator"]}, "org-1")
+    patient = visibility({"uid": "u-2", "roles": ["patient"], "patientId": "p-1"}, "org-1")
+    unlinked = visibility({"uid": "u-3", "roles": ["patient"]}, None)
+    api_key = visibility({"uid": "key-1", "authMethod": "api_key", "scopes": ["encounters:read", "care-plans:write"]}, None)
+
+    assert coordinator == Visibility(EVERYTHING.kinds, tenant_id="org-1")
+    assert patient.kinds == frozenset() and patient.own_patient_id == "p-1"
+    assert patient.own_kinds == EVERYTHING.kinds
+    assert unlinked.empty
+    assert api_key.kinds == frozenset({"encounter"}) and api_key.own_kinds == frozenset()
+
+# --- Indexing Test Cases ---
+
+def test_events_become_index_entries_or_removals():
+    """Tests the entries built from events, and that deletions and unscanned documents are removed."""
+    recorded = from_event(resource_event("allergy.recorded", "patients/p-1/allergies/al-1", allergy()))
+    removed, nothing = from_event(Event(type="allergy.deleted", subject="patients/p-1/allergies/al-1", data={"patientId": "p-1", "allergyId": "al-1"}))
+    pending_scan = schemas.PatientDocument.model_validate({
+        "documentId": "doc-1", "patientId": "p-1", "blobName": "b", "status": "pending-scan", "uploadedBy": "u-1",
+        "type": "lab-report", "title": "Sleep study", "contentType": "application/pdf", "size": 10, "checksum": "A" * 22 + "==",
+        "createdAt": NOW, "updatedAt": NOW,
+    })
+
+    assert recorded[0] is None
+    document = recorded[1]
+    assert (document.id, document.title, document.subject) == ("allergy/al-1", "Penicillin G", "patients/p-1/allergies/al-1")
+    assert document.text == "Reported by the patient's mother\nUrticaria\nWithin an hour"
+    assert (removed, nothing) == ("allergy/al-1", None)
+    assert from_event(resource_event("document.uploaded", "patients/p-1/documents/doc-1", pending_scan)) == ("patient-document/doc-1", None)
+    assert from_event(resource_event("observation.recorded", "patients/p-1/observations/o-1", allergy())) == (None, None)
+
+def test_psychotherapy_notes_are_never_indexed():
+    """Tests that an encounter's encrypted notes stay out of its entry."""
+    encounter = schemas.Encounter.model_validate({
+        "encounterId": "enc-1", "patientId": "p-1", "visitType": "ambulatory", "status": "in-progress", "start": NOW,
+        "reason": "Insomnia", "psychotherapyNotes": "Discussed grief", "createdAt": NOW, "updatedAt": NOW,
+    })
+
+    _, document = from_event(resource_event("encounter.opened", "encounters/enc-1", encounter))
+
+    assert "grief" not in document.text and document.title == "Insomnia"
+
+def test_publisher_indexes_and_survives_index_failures():
+    """Tests that published events update the index, and that an index failure does not fail the publish."""
+    index = EmbeddedSearchIndex()
+    SearchIndexEventPublisher(NullEventPublisher(), index).emit("allergy.recorded", "patients/p-1/allergies/al-1", allergy())
+    broken = MagicMock()
+    broken.upsert.side_effect = SearchIndexError("down")
+
+    SearchIndexEventPublisher(NullEventPublisher(), broken).publish(resource_event("allergy.recorded", "patients/p-1/allergies/al-1", allergy()))
+
+    assert index.search(SearchQuery("urticaria", EVERYTHING)).total == 1
+    broken.upsert.assert_called_once()
+
+# --- Elasticsearch Test Cases ---
+
+def test_elasticsearch_queries_filter_by_visibility():
+    """Tests the index mapping, bulk body and the permission filters and highlighting of a search."""
+    client = MagicMock()
+    client.put.return_value = MagicMock(status_code=200, text="{}")
+    client.request.return_value = MagicMock(status_code=200, json=lambda: {
+        "errors": False,
+        "hits": {"total": {"value": 1}, "hits": [{"_score": 3.5, "_source": {
+            "kind": "encounter", "recordId": "enc-1", "patientId": "p-1", "tenantId": "org-1", "subject": "encounters/enc-1",
+            "title": "CPAP mask leak", "text": "", "recordedAt": NOW.isoformat(),
+        }, "highlight": {"title": ["CPAP <mark>mask</mark> leak"]}}]},
+    })
+    index = ElasticsearchIndex("https://es.example:9200/", "clinical", client=client)
+    patient = Visibility(frozenset(), own_kinds=frozenset({"encounter"}), own_patient_id="p-1", tenant_id="org-1")
+
+    index.upsert([entry("encounter", "enc-1", "p-1", "CPAP mask leak", "")])
+    results = index.search(SearchQuery("mask", patient, limit=5))
+
+    assert client.put.call_args[0][0] == "https://es.example:9200/clinical"
+    bulk = client.request.call_args_list[0]
+    assert bulk[0] == ("POST", "https://es.example:9200/_bulk")
+    assert json.loads(bulk[1]["content"].splitlines()[0]) == {"index": {"_index": "clinical", "_id": "encounter/enc-1"}}
+    body = client.request.call_args_list[1][1]["json"]
+    assert body["query"]["bool"]["filter"] == [
+        {"bool": {"should": [{"bool": {"filter": [{"terms": {"kind": ["encounter"]}}, {"term": {"patientId": "p-1"}}]}}], "minimum_should_match": 1}},
+        {"term": {"tenantId": "org-1"}},
+    ]
+    assert body["size"] == 5 and body["highlight"]["encoder"] == "html"
+    assert results.total == 1 and results.hits[0].highlights == {"title": ["CPAP <mark>mask</mark> leak"]}
+
+# --- Endpoint Test Cases ---
+
+app = FastAPI()
+app.include_router(search_endpoint.router, prefix="/api/v1/search")
+client = TestClient(app)
+
+def test_search_endpoint():
+    """Tests a search, 403 for callers who may read none of the records, and 503 with search off."""
+    index = filled_index()
+    app.dependency_overrides[get_search_index] = lambda: index
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "doc-1", "roles": ["clinician"]}
+    try:
+        found = client.get("/api/v1/search", params={"q": "mask", "kind": "encounter"})
+        app.dependency_overrides[get_current_user] = lambda: {"uid": "u-3", "roles": ["patient"]}
+        forbidden = client.get("/api/v1/search", params={"q": "mask"})
+        app.dependency_overrides[get_search_index] = lambda: None
+        disabled = client.get("/api/v1/search", params={"q": "mask"})
+    finally:
+        app.dependency_overrides.clear()
+
+    assert found.status_code == 200
+    assert [hit["id"] for hit in found.json()["hits"]] == ["enc-1"]
+    assert forbidden.status_code == 403
+    assert disabled.status_code == 503
+
+# --- Reindex Test Cases ---
+
+def test_reindex_indexes_every_patients_records(monkeypatch):
+    """Tests that the reindex command builds entries from the stored records, skipping deleted ones."""
+    store = MemoryStore()
+    patients, allergies, care_plans = MemoryPatientRepository(store), MemoryAllergyRepository(store), MemoryCarePlanRepository(store)
+    for name, getter in [
+        ("get_patient_repository", patients), ("get_allergy_repository", allergies), ("get_care_plan_repository", care_plans),
+        ("get_encounter_repository", MemoryEncounterRepository(store)), ("get_patient_document_repository", MemoryPatientDocumentRepository(store)),
+    ]:
+        monkeypatch.setattr(reindex.deps, name, lambda repo=getter: repo)
+    patient = patients.create(schemas.PatientCreate(givenName="Ann", familyName="Smith", dob="1975-03-02", mrn="MRN-1"))
+    kept = allergies.create(patient.patient_id, schemas.AllergyCreate.model_validate(allergy().model_dump(by_alias=True)), recorded_by="doc-1")
+    dropped = allergies.create(patient.patient_id, schemas.AllergyCreate(substance={"system": "http://snomed.info/sct", "code": "91935009", "display": "Peanut"}), recorded_by="doc-1")
+    allergies.delete(dropped.allergy_id, deleted_by="doc-1")
+    index = EmbeddedSearchIndex()
+
+    counts = reindex.reindex(index)
+
+    assert counts == {"allergy": 1}
+    assert [hit.id for hit in index.search(SearchQuery("urticaria", EVERYTHING)).hits] == [kept.allergy_id]
+    assert index.search(SearchQuery("peanut", EVERYTHING)).total == 0
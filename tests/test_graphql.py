@@ -0,0 +1,157 @@
+import pytest
+from fastapi.testclient import TestClient
+from datetime import datetime, timedelta, timezone
+
+from fastapi import FastAPI
+from app.api.graphql.router import graphql_router
+from app.api.v1 import schemas
+from app.api.v1.deps import (
+    get_appointment_repository,
+    get_medication_repository,
+    get_observation_repository,
+    get_patient_repository,
+    get_practitioner_repository,
+)
+from app.core.config import get_settings
+from app.dependencies.auth import get_current_user
+from app.repositories.memory import (
+    MemoryAppointmentRepository,
+    MemoryMedicationRepository,
+    MemoryObservationRepository,
+    MemoryPatientRepository,
+    MemoryPractitionerRepository,
+    MemoryStore,
+)
+
+# --- Test Setup ---
+
+DASHBOARD = """
+query {
+  me {
+    givenName
+    appointments { start practitioner { familyName } }
+    medications(status: "active") { name prescriber { familyName } }
+    observations(first: 5) { code { code } value unit }
+  }
+}
+"""
+
+class CountingPractitionerRepository(MemoryPractitionerRepository):
+    def __init__(self, store):
+        super().__init__(store)
+        self.batches = []
+
+    def get_many(self, practitioner_ids):
+        self.batches.append(sorted(practitioner_ids))
+        return super().get_many(practitioner_ids)
+
+@pytest.fixture
+def portal():
+    """A portal patient with two upcoming appointments with different practitioners, a medication and an observation."""
+    store = MemoryStore()
+    patients = MemoryPatientRepository(store)
+    practitioners = CountingPractitionerRepository(store)
+    appointments = MemoryAppointmentRepository(store)
+    medications = MemoryMedicationRepository(store)
+    observations = MemoryObservationRepository(store)
+
+    patient = patients.create(schemas.PatientCreate(givenName="Ann", familyName="Lee", dob="1980-01-01", mrn="MRN-1"))
+    other = patients.create(schemas.PatientCreate(givenName="Bob", familyName="Ray", dob="1975-01-01", mrn="MRN-2"))
+    doctors = [
+        practitioners.create(schemas.PractitionerCreate(givenName="Sam", familyName=name, npi=npi))
+        for name, npi in [("Okafor", "1234567893"), ("Chen", "1245319599")]
+    ]
+    start = datetime.now(timezone.utc) + timedelta(days=1)
+    for i, doctor in enumerate(doctors):
+        appointments.create(schemas.AppointmentCreate(
+            patientId=patient.patient_id, practitionerId=doctor.practitioner_id,
+            start=start + timedelta(days=i), end=start + timedelta(days=i, hours=1),
+        ))
+    medications.create(patient.patient_id, schemas.MedicationCreate(name="Acetazolamide 250mg", prescriberId=doctors[0].practitioner_id))
+    observations.create(schemas.ObservationCreate(
+        patientId=patient.patient_id, code={"system": "http://loinc.org", "code": "59408-5"}, value=96, unit="%", effectiveAt=start,
+    ))
+
+    app = FastAPI()
+    app.include_router(graphql_router, prefix="/graphql")
+    app.dependency_overrides[get_patient_repository] = lambda: patients
+    app.dependency_overrides[get_practitioner_repository] = lambda: practitioners
+    app.dependency_overrides[get_appointment_repository] = lambda: appointments
+    app.dependency_overrides[get_medication_repository] = lambda: medications
+    app.dependency_overrides[get_observation_repository] = lambda: observations
+
+    def sign_in(claims):
+        app.dependency_overrides[get_current_user] = lambda: claims
+    yield TestClient(app), sign_in, patient, other, practitioners
+
+# --- Query Test Cases ---
+
+def test_dashboard_in_one_query(portal):
+    """Tests that a portal patient gets their profile, appointments, medications and observations in one request."""
+    client, sign_in, patient, _, _ = portal
+    sign_in({"uid": "ann", "roles": ["patient"], "patientId": patient.patient_id})
+
+    response = client.post("/graphql", json={"query": DASHBOARD})
+
+    assert response.status_code == 200
+    body = response.json()
+    assert "errors" not in body
+    me = body["data"]["me"]
+    assert me["givenName"] == "Ann"
+    assert [a["practitioner"]["familyName"] for a in me["appointments"]] == ["Okafor", "Chen"]
+    assert me["medications"][0]["prescriber"]["familyName"] == "Okafor"
+    assert me["observations"][0] == {"code": {"code": "59408-5"}, "value": 96.0, "unit": "%"}
+
+def test_practitioners_are_loaded_in_one_batch(portal):
+    """Tests that the practitioners of all of a patient's appointments are read with one batched call."""
+    client, sign_in, patient, _, practitioners = portal
+    sign_in({"uid": "ann", "roles": ["patient"], "patientId": patient.patient_id})
+
+    client.post("/graphql", json={"query": "query { me { appointments { practitioner { familyName } } } }"})
+
+    assert len(practitioners.batches) == 1
+    assert len(practitioners.batches[0]) == 2
+
+def test_patients_may_not_read_other_records(portal):
+    """Tests that a patient asking for someone else's record gets an error for that field and no data."""
+    client, sign_in, patient, other, _ = portal
+    sign_in({"uid": "ann", "roles": ["patient"], "patientId": patient.patient_id})
+
+    body = client.post("/graphql", json={"query": f'query {{ patient(id: "{other.patient_id}") {{ mrn }} }}'}).json()
+
+    assert body["data"]["patient"] is None
+    assert body["errors"][0]["message"] == "You may only access your own records"
+
+def test_clinicians_read_several_patients(portal):
+    """Tests that a clinician can fetch several patients at once, with unknown IDs left out."""
+    client, sign_in, patient, other, _ = portal
+    sign_in({"uid": "dr", "roles": ["clinician"]})
+    query = f'query {{ patients(ids: ["{patient.patient_id}", "{other.patient_id}", "missing"]) {{ mrn }} }}'
+
+    body = client.post("/graphql", json={"query": query}).json()
+
+    assert sorted(p["mrn"] for p in body["data"]["patients"]) == ["MRN-1", "MRN-2"]
+
+def test_staff_need_a_second_factor(portal, monkeypatch):
+    """Tests that a clinician who has not proved a second factor is refused once MFA is required."""
+    client, sign_in, patient, _, _ = portal
+    monkeypatch.setattr(get_settings(), "session_signing_key", "s" * 32)
+    monkeypatch.setattr(get_settings(), "mfa_required_roles", "clinician")
+    sign_in({"uid": "dr", "roles": ["clinician"]})
+
+    response = client.post("/graphql", json={"query": f'query {{ patient(id: "{patient.patient_id}") {{ mrn }} }}'})
+
+    assert response.status_code == 403
+    assert "insufficient_user_authentication" in response.headers["WWW-Authenticate"]
+
+def test_denied_kinds_of_record_are_withheld(portal):
+    """Tests that a denial of a kind of record under patients withholds that field, as on the REST routes."""
+    client, sign_in, patient, _, _ = portal
+    sign_in({"uid": "api-key:k1", "authMethod": "api_key", "apiKeyId": "k1", "scopes": ["patients:read", "!patients.medications:read"]})
+    query = f'query {{ patient(id: "{patient.patient_id}") {{ mrn medications {{ name }} observations {{ value }} }} }}'
+
+    body = client.post("/graphql", json={"query": query}).json()
+
+    assert body["data"]["patient"] is None
+    assert body["errors"][0]["path"] == ["patient", "medications"]
+    assert body["errors"][0]["message"] == "You do not have permission to access this resource"
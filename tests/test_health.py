@@ -0,0 +1,73 @@
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+from unittest.mock import patch
+
+from app.api import health as health_endpoints
+from app.core import health
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(health_endpoints.router)
+client = TestClient(app)
+
+@pytest.fixture
+def checks():
+    """Replaces the global check registry with an empty one for each test."""
+    with patch.object(health, "_checks", {}) as registry:
+        yield registry
+
+def failing_check():
+    raise ConnectionError("connection refused")
+
+# --- Test Cases ---
+
+def test_liveness_does_not_run_checks(checks):
+    """Tests that /healthz succeeds even when a dependency is down."""
+    health.register_check("firestore", failing_check)
+
+    response = client.get("/healthz")
+
+    assert response.status_code == 200
+    assert response.json() == {"status": "ok"}
+
+def test_readiness_all_checks_pass(checks):
+    """Tests a 200 response with per-dependency status when all checks pass."""
+    health.register_check("firestore", lambda: None)
+    health.register_check("pubsub", lambda: None)
+
+    response = client.get("/readyz")
+
+    assert response.status_code == 200
+    body = response.json()
+    assert body["status"] == "ok"
+    assert body["checks"]["firestore"]["status"] == "ok"
+    assert body["checks"]["pubsub"]["status"] == "ok"
+    assert "latencyMs" in body["checks"]["firestore"]
+
+def test_readiness_reports_failing_dependency(checks):
+    """Tests a 503 response naming the failed dependency without its error."""
+    health.register_check("firestore", lambda: None)
+    health.register_check("secretmanager", failing_check)
+
+    response = client.get("/readyz")
+
+    assert response.status_code == 503
+    body = response.json()
+    assert body["status"] == "unavailable"
+    assert body["checks"]["firestore"]["status"] == "ok"
+    assert body["checks"]["secretmanager"] == {
+        "status": "error",
+        "latencyMs": body["checks"]["secretmanager"]["latencyMs"],
+    }
+    assert "connection refused" not in response.text
+
+@patch('app.core.health.firestore.client')
+def test_firestore_check_reads_one_document(mock_firestore_client):
+    """Tests that the built-in Firestore check performs a minimal read."""
+    health.check_firestore()
+
+    mock_db = mock_firestore_client.return_value
+    mock_db.collection.assert_called_once_with("customers")
+    mock_db.collection.return_value.limit.assert_called_once_with(1)
@@ -0,0 +1,215 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import date, datetime, timedelta, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from app.api.integrations.router import integrations_router
+from app.api.v1 import schemas
+from app.api.v1.deps import get_observation_repository, get_patient_repository
+from app.dependencies.auth import get_current_user
+from app.hl7v2.ack import build_ack
+from app.hl7v2.parser import HL7ParseError, Encoding, escape, parse_message, parse_timestamp, unescape
+from app.hl7v2.processing import HL7ProcessingError, process_message
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(integrations_router, prefix="/integrations")
+app.dependency_overrides[get_current_user] = lambda: {"uid": "interface-engine-uid"}
+client = TestClient(app)
+
+FAKE_PATIENT_ID = "patient-1"
+
+ADT_A01 = "\r".join([
+    "MSH|^~\\&|HIS|BKK_HOSP|MEGACARE|MEGACARE|20240501083000+0700||ADT^A01^ADT_A01|MSG0001|P|2.5",
+    "EVN|A01|20240501083000+0700",
+    "PID|1||H-9^^^BKK_HOSP^PI~MRN-001^^^BKK_HOSP^MR||Jaidee^Somchai^K||19650314|M|||99 Sukhumvit Rd^^Bangkok^^10110^TH||0811111111",
+    "PV1|1|I",
+])
+
+ORU_R01 = "\r".join([
+    "MSH|^~\\&|LIS|BKK_HOSP|MEGACARE|MEGACARE|20240501090000||ORU^R01|MSG0002|P|2.5",
+    "PID|1||MRN-001^^^BKK_HOSP^MR||Jaidee^Somchai",
+    "OBR|1||LAB-55|24331-1^Lipid panel^LN|||20240501080000",
+    "OBX|1|NM|59408-5^Oxygen saturation^LN||96|%|95-100||||F|||20240501073000",
+    "OBX|2|NM|8867-4^Heart rate^LN||72|/min|||||F",
+    "OBX|3|TX|8251-1^Comment^LN||Patient resting||||||F",
+])
+
+def make_patient():
+    return schemas.Patient.model_validate({
+        "patientId": FAKE_PATIENT_ID, "givenName": "Somchai", "familyName": "Jaidee",
+        "dob": date(1965, 3, 14), "mrn": "MRN-001",
+        "createdAt": datetime(2024, 1, 1, tzinfo=timezone.utc), "updatedAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+    })
+
+@pytest.fixture
+def repos():
+    """Overrides the patient and observation repositories with mocks."""
+    mocks = {
+        "patients": MagicMock(spec=PatientRepository),
+        "observations": MagicMock(spec=ObservationRepository),
+    }
+    app.dependency_overrides[get_patient_repository] = lambda: mocks["patients"]
+    app.dependency_overrides[get_observation_repository] = lambda: mocks["observations"]
+    yield mocks
+    for dep in (get_patient_repository, get_observation_repository):
+        app.dependency_overrides.pop(dep, None)
+
+def msa(ack: str):
+    """Returns the MSA segment of an ACK as a list of fields."""
+    return next(line for line in ack.split("\r") if line.startswith("MSA")).split("|")
+
+# --- Parser Test Cases ---
+
+def test_parse_message_fields_and_components():
+    """Tests HL7 1-based field numbering, components and repetitions."""
+    message = parse_message(ADT_A01)
+
+    assert message.message_type == ("ADT", "A01")
+    assert message.control_id == "MSG0001"
+    assert message.msh.field(1) == "|"
+    assert message.msh.field(3) == "HIS"
+    pid = message.segment("PID")
+    assert pid.component(3, 1, repetition=1) == "MRN-001"
+    assert pid.component(5, 2) == "Somchai"
+    assert len(message.all("PV1")) == 1
+
+def test_parse_message_accepts_lf_separators():
+    """Tests that LF-separated segments are accepted."""
+    message = parse_message(ADT_A01.replace("\r", "\n"))
+
+    assert [s.name for s in message.segments] == ["MSH", "EVN", "PID", "PV1"]
+
+def test_parse_message_rejects_missing_msh():
+    """Tests that a message without an MSH header is rejected."""
+    with pytest.raises(HL7ParseError):
+        parse_message("PID|1||MRN-001")
+
+def test_escape_round_trip():
+    """Tests that delimiter characters survive escaping."""
+    enc = Encoding()
+    text = "BP 120/80 | cuff ^ left & right ~ seated \\ ok"
+
+    assert unescape(escape(text, enc), enc) == text
+
+def test_parse_timestamp_offsets():
+    """Tests timestamp precision and offsets."""
+    assert parse_timestamp("20240501073000+0700") == datetime(2024, 5, 1, 7, 30, tzinfo=timezone(timedelta(hours=7)))
+    assert parse_timestamp("19650314") == datetime(1965, 3, 14, tzinfo=timezone.utc)
+    assert parse_timestamp("not-a-date") is None
+
+# --- ACK Test Cases ---
+
+def test_build_ack_swaps_sender_and_receiver():
+    """Tests that the ACK echoes the control ID and addresses the original sender."""
+    message = parse_message(ADT_A01)
+
+    ack = build_ack(message, "AA", "Created patient patient-1")
+
+    msh = ack.split("\r")[0].split("|")
+    assert msh[4:6] == ["HIS", "BKK_HOSP"]
+    assert msh[8] == "ACK^A01^ACK"
+    assert msa(ack)[:3] == ["MSA", "AA", "MSG0001"]
+
+# --- Processing Test Cases ---
+
+def test_process_adt_creates_patient():
+    """Tests that ADT^A01 for an unknown MRN registers the patient from PID."""
+    patients = MagicMock(spec=PatientRepository)
+    patients.get_by_mrn.return_value = None
+    patients.create.return_value = make_patient()
+
+    result = process_message(parse_message(ADT_A01), patients, MagicMock(spec=ObservationRepository), timezone.utc)
+
+    patient_in = patients.create.call_args[0][0]
+    assert patient_in.mrn == "MRN-001"
+    assert patient_in.given_name == "Somchai K"
+    assert patient_in.dob == date(1965, 3, 14)
+    assert patient_in.sex == "male"
+    assert patient_in.contact.city == "Bangkok"
+    assert result == f"Created patient {FAKE_PATIENT_ID}"
+
+def test_process_adt_updates_existing_patient():
+    """Tests that ADT^A08 for a known MRN only changes the fields present in PID."""
+    patients = MagicMock(spec=PatientRepository)
+    patients.get_by_mrn.return_value = make_patient()
+    patients.update.return_value = make_patient()
+    message = parse_message(ADT_A01.replace("ADT^A01^ADT_A01", "ADT^A08^ADT_A01"))
+
+    process_message(message, patients, MagicMock(spec=ObservationRepository), timezone.utc)
+
+    patient_id, changes = patients.update.call_args[0]
+    assert patient_id == FAKE_PATIENT_ID
+    assert "mrn" not in changes.model_dump(exclude_unset=True)
+    patients.create.assert_not_called()
+
+def test_process_oru_stores_numeric_results_once():
+    """Tests that numeric OBX become observations and already-stored OBX are skipped."""
+    patients = MagicMock(spec=PatientRepository)
+    patients.get_by_mrn.return_value = make_patient()
+    observations = MagicMock(spec=ObservationRepository)
+    # OBX 2 was stored by an earlier delivery of the same message.
+    observations.find_by_identifier.side_effect = lambda system, value: [MagicMock()] if value == "MSG0002-2" else []
+
+    result = process_message(parse_message(ORU_R01), patients, observations, timezone.utc)
+
+    assert observations.create.call_count == 1
+    stored = observations.create.call_args[0][0]
+    assert stored.code.system == "http://loinc.org"
+    assert stored.value == 96
+    assert stored.effective_at == datetime(2024, 5, 1, 7, 30, tzinfo=timezone.utc)
+    assert stored.identifier.system == "urn:hl7v2:BKK_HOSP:obx"
+    assert "skipped 1 non-numeric OBX" in result
+
+def test_process_oru_unknown_patient():
+    """Tests that results for an unknown MRN are an application error."""
+    patients = MagicMock(spec=PatientRepository)
+    patients.get_by_mrn.return_value = None
+
+    with pytest.raises(HL7ProcessingError) as exc_info:
+        process_message(parse_message(ORU_R01), patients, MagicMock(spec=ObservationRepository), timezone.utc)
+
+    assert exc_info.value.code == "AE"
+
+def test_process_unsupported_message_type():
+    """Tests that message types we do not handle are rejected."""
+    message = parse_message(ADT_A01.replace("ADT^A01^ADT_A01", "SIU^S12"))
+
+    with pytest.raises(HL7ProcessingError) as exc_info:
+        process_message(message, MagicMock(spec=PatientRepository), MagicMock(spec=ObservationRepository), timezone.utc)
+
+    assert exc_info.value.code == "AR"
+
+# --- Endpoint Test Cases ---
+
+def test_ingest_adt_returns_aa(repos):
+    """Tests that an applied message is acknowledged with AA."""
+    repos["patients"].get_by_mrn.return_value = None
+    repos["patients"].create.return_value = make_patient()
+
+    response = client.post("/integrations/hl7v2", content=ADT_A01, headers={"Content-Type": "x-application/hl7-v2+er7"})
+
+    assert response.status_code == 200
+    assert response.headers["content-type"].startswith("x-application/hl7-v2+er7")
+    assert msa(response.text)[:3] == ["MSA", "AA", "MSG0001"]
+
+def test_ingest_unparseable_returns_ar(repos):
+    """Tests that garbage is rejected with AR rather than an HTTP error."""
+    response = client.post("/integrations/hl7v2", content="hello", headers={"Content-Type": "x-application/hl7-v2+er7"})
+
+    assert response.status_code == 200
+    assert msa(response.text)[1] == "AR"
+    assert "ERR|" in response.text
+
+def test_ingest_unexpected_error_returns_ae(repos):
+    """Tests that storage failures produce AE so the engine can retry."""
+    repos["patients"].get_by_mrn.side_effect = RuntimeError("Firestore unavailable")
+
+    response = client.post("/integrations/hl7v2", content=ORU_R01, headers={"Content-Type": "x-application/hl7-v2+er7"})
+
+    assert msa(response.text)[1] == "AE"
@@ -0,0 +1,144 @@
+from datetime import datetime, timezone
+
+import httpx
+import pytest
+
+from app.core.http_client import RetryTransport, new_client, retry_after_seconds
+from app.core.request_context import REQUEST_ID_HEADER, request_id_var, span_id_var, trace_id_var
+
+# --- Test Setup ---
+
+TRACE_ID = "4bf92f3577b34da6a3ce929d0e0e4736"
+SPAN_ID = "00f067aa0ba902b7"
+
+def make_client(handler, max_attempts=3):
+    """A client whose transport answers with `handler` and records the delays it would have slept."""
+    delays = []
+    transport = RetryTransport(
+        "test", httpx.MockTransport(handler), max_attempts=max_attempts,
+        backoff_base_seconds=0.2, backoff_max_seconds=5.0, sleep=delays.append, jitter=lambda: 1.0,
+    )
+    return httpx.Client(transport=transport), delays
+
+def answers(*results):
+    """A handler giving each result in turn; exceptions are raised."""
+    seen = []
+    def handler(request):
+        seen.append(request)
+        result = results[len(seen) - 1]
+        if isinstance(result, Exception):
+            raise result
+        return result
+    return handler, seen
+
+# --- Retry Test Cases ---
+
+def test_idempotent_request_is_retried_on_busy_answers():
+    """Tests that a GET answered 503 is retried with doubling backoff until it succeeds."""
+    handler, seen = answers(httpx.Response(503), httpx.Response(502), httpx.Response(200, json={"ok": True}))
+    client, delays = make_client(handler)
+
+    response = client.get("https://provider.example/resource")
+
+    assert response.status_code == 200
+    assert len(seen) == 3
+    assert delays == [0.2, 0.4]
+
+def test_retry_after_is_honoured_up_to_the_maximum():
+    """Tests that Retry-After sets the delay, capped at the backoff maximum."""
+    handler, _ = answers(httpx.Response(429, headers={"Retry-After": "2"}), httpx.Response(429, headers={"Retry-After": "120"}), httpx.Response(204))
+    client, delays = make_client(handler)
+
+    assert client.get("https://provider.example/resource").status_code == 204
+    assert delays == [2.0, 5.0]
+
+def test_last_answer_is_returned_when_attempts_run_out():
+    """Tests that the final busy answer is returned rather than raised."""
+    handler, seen = answers(httpx.Response(503), httpx.Response(503))
+    client, _ = make_client(handler, max_attempts=2)
+
+    assert client.get("https://provider.example/resource").status_code == 503
+    assert len(seen) == 2
+
+def test_post_is_not_retried_after_it_was_sent():
+    """Tests that a POST is not repeated on a busy answer or a read timeout, which could send it twice."""
+    handler, seen = answers(httpx.Response(503))
+    client, _ = make_client(handler)
+    assert client.post("https://provider.example/messages", data={"To": "+66800000000"}).status_code == 503
+    assert len(seen) == 1
+
+    handler, seen = answers(httpx.ReadTimeout("slow"))
+    client, _ = make_client(handler)
+    with pytest.raises(httpx.ReadTimeout):
+        client.post("https://provider.example/messages", data={"To": "+66800000000"})
+    assert len(seen) == 1
+
+def test_post_is_retried_when_the_connection_failed():
+    """Tests that any request is retried when nothing was sent."""
+    handler, seen = answers(httpx.ConnectError("refused"), httpx.Response(201))
+    client, _ = make_client(handler)
+
+    assert client.post("https://provider.example/messages", json={"body": "hi"}).status_code == 201
+    assert len(seen) == 2
+    assert seen[1].content == seen[0].content
+
+def test_client_errors_are_not_retried():
+    """Tests that a 4xx other than 429 is returned at once."""
+    handler, seen = answers(httpx.Response(404))
+    client, _ = make_client(handler)
+
+    assert client.get("https://provider.example/missing").status_code == 404
+    assert len(seen) == 1
+
+def test_backoff_is_jittered_and_capped():
+    """Tests full jitter: the delay is a random share of the capped exponential delay."""
+    transport = RetryTransport("test", httpx.MockTransport(lambda r: httpx.Response(200)), backoff_base_seconds=1.0, backoff_max_seconds=5.0, jitter=lambda: 0.5)
+
+    assert transport.backoff(1) == 0.5
+    assert transport.backoff(3) == 2.0
+    assert transport.backoff(10) == 2.5
+
+def test_retry_after_accepts_seconds_and_dates():
+    now = lambda: datetime(2026, 1, 1, 12, 0, 0, tzinfo=timezone.utc)
+
+    assert retry_after_seconds("7") == 7.0
+    assert retry_after_seconds("Thu, 01 Jan 2026 12:00:30 GMT", now) == 30.0
+    assert retry_after_seconds("soon") is None
+    assert retry_after_seconds(None) is None
+
+# --- Propagation Test Cases ---
+
+def test_request_id_and_trace_context_are_passed_on():
+    """Tests that calls made while serving a request carry its request ID and trace."""
+    seen = []
+    client = new_client("test", 1.0, transport=httpx.MockTransport(lambda request: seen.append(request) or httpx.Response(200)))
+    tokens = [request_id_var.set("req-123"), trace_id_var.set(TRACE_ID), span_id_var.set(SPAN_ID)]
+    try:
+        client.get("https://provider.example/resource")
+    finally:
+        span_id_var.reset(tokens[2])
+        trace_id_var.reset(tokens[1])
+        request_id_var.reset(tokens[0])
+
+    assert seen[0].headers[REQUEST_ID_HEADER] == "req-123"
+    assert seen[0].headers["traceparent"] == f"00-{TRACE_ID}-{SPAN_ID}-00"
+
+def test_calls_outside_a_request_carry_no_context():
+    seen = []
+    client = new_client("test", 1.0, transport=httpx.MockTransport(lambda request: seen.append(request) or httpx.Response(200)))
+
+    client.get("https://provider.example/resource")
+
+    assert REQUEST_ID_HEADER not in seen[0].headers
+    assert "traceparent" not in seen[0].headers
+
+def test_new_client_does_not_follow_redirects():
+    """Tests that credentials are not sent on to wherever a provider redirects."""
+    seen = []
+    def handler(request):
+        seen.append(request)
+        return httpx.Response(302, headers={"Location": "https://elsewhere.example/"})
+    client = new_client("test", 1.0, transport=httpx.MockTransport(handler))
+
+    assert client.get("https://provider.example/resource").status_code == 302
+    assert len(seen) == 1
@@ -0,0 +1,196 @@
+import hashlib
+import json
+import pytest
+from datetime import datetime, timedelta, timezone
+
+from fastapi.testclient import TestClient
+
+from fastapi import APIRouter, FastAPI, HTTPException
+from app.crypto.envelope import FieldCipher, is_sealed
+from app.crypto.kms import KeyManager
+from app.middleware import idempotency
+from app.middleware.idempotency import IdempotencyMiddleware, idempotency_record_id
+from app.repositories import base
+from app.repositories.memory import MemoryIdempotencyRepository, MemoryStore
+
+# --- Test Setup ---
+
+bookings = []
+
+router = APIRouter()
+
+@router.post("/api/v1/appointments", status_code=201)
+def book_appointment(appointment: dict):
+    if appointment.get("slot") == "taken":
+        raise HTTPException(status_code=409, detail="Slot already booked")
+    if appointment.get("slot") == "broken":
+        raise HTTPException(status_code=503, detail="Scheduling unavailable")
+    bookings.append(appointment)
+    return {"appointment_id": f"a-{len(bookings)}", **appointment}
+
+@router.post("/api/v1/patients")
+def create_patient(patient: dict):
+    bookings.append(patient)
+    return patient
+
+@router.post("/api/v1/auth/sessions", status_code=201)
+def start_session(credentials: dict):
+    bookings.append(credentials)
+    return {"accessToken": f"token-{len(bookings)}"}
+
+class ReversingKeyManager(KeyManager):
+    """Stands in for Cloud KMS: 'wraps' data keys by reversing them."""
+    def wrap(self, data_key):
+        return data_key[::-1], "projects/megacare/locations/asia-southeast1/keyRings/api/cryptoKeys/fields/cryptoKeyVersions/1"
+    def unwrap(self, wrapped, key_version):
+        return wrapped[::-1]
+
+@pytest.fixture(autouse=True)
+def field_encryption(monkeypatch):
+    cipher = FieldCipher(ReversingKeyManager())
+    monkeypatch.setattr(idempotency, "get_field_cipher", lambda: cipher)
+    monkeypatch.setattr(base, "get_field_cipher", lambda: cipher)
+
+def with_principal(app):
+    """Stands in for AuthenticationMiddleware: the X-Test-User header becomes the verified caller."""
+    async def asgi(scope, receive, send):
+        user = dict(scope.get("headers", [])).get(b"x-test-user")
+        if user:
+            scope.setdefault("state", {})["principal"] = {"uid": user.decode()}
+        await app(scope, receive, send)
+    return asgi
+
+def make_client():
+    bookings.clear()
+    repository = MemoryIdempotencyRepository(MemoryStore())
+    app = FastAPI()
+    app.include_router(router)
+    app.add_middleware(IdempotencyMiddleware, repository_factory=lambda: repository, ttl_seconds=3600, prefixes=("/api/v1",))
+    app.add_middleware(with_principal)
+    return TestClient(app), repository
+
+def book(client, key, slot="09:00", user="u-1"):
+    return client.post("/api/v1/appointments", json={"slot": slot}, headers={"Idempotency-Key": key, "X-Test-User": user})
+
+# --- Repository Test Cases ---
+
+def test_claims_are_exclusive_until_they_expire():
+    """Tests that a key is claimed once, returns its record while held, and can be taken over once expired."""
+    store = MemoryStore()
+    repository = MemoryIdempotencyRepository(store)
+
+    assert repository.claim("r-1", "user:u-1", "POST /a", "k-1", "f-1", lease_seconds=60) is None
+    assert repository.claim("r-1", "user:u-1", "POST /a", "k-1", "f-1", lease_seconds=60).status == "processing"
+    store.table("idempotency_records")["r-1"]["data"]["expiresAt"] = (datetime.now(timezone.utc) - timedelta(seconds=1)).isoformat()
+    assert repository.claim("r-1", "user:u-1", "POST /a", "k-1", "f-2", lease_seconds=60) is None
+    assert repository.claim("r-1", "user:u-1", "POST /a", "k-1", "f-2", lease_seconds=60).fingerprint == "f-2"
+
+def test_completed_records_hold_the_response_and_released_ones_are_gone():
+    """Tests that completing stores the response and releasing frees the key."""
+    repository = MemoryIdempotencyRepository(MemoryStore())
+    repository.claim("r-1", "user:u-1", "POST /a", "k-1", "f", lease_seconds=60)
+    repository.complete("r-1", 201, {"content-type": "application/json"}, "e30=", ttl_seconds=3600)
+
+    record = repository.claim("r-1", "user:u-1", "POST /a", "k-1", "f", lease_seconds=60)
+    assert (record.status, record.response_status, record.response_body) == ("completed", 201, "e30=")
+
+    repository.release("r-1")
+    repository.release("r-1")
+    assert repository.claim("r-1", "user:u-1", "POST /a", "k-1", "f", lease_seconds=60) is None
+
+def test_record_ids_are_scoped_to_caller_and_route():
+    """Tests that the same key names different records for different callers or routes."""
+    assert idempotency_record_id("user:u-1", "POST /a", "k") == idempotency_record_id("user:u-1", "POST /a", "k")
+    assert idempotency_record_id("user:u-1", "POST /a", "k") != idempotency_record_id("user:u-2", "POST /a", "k")
+    assert idempotency_record_id("user:u-1", "POST /a", "k") != idempotency_record_id("user:u-1", "POST /b", "k")
+
+# --- Middleware Test Cases ---
+
+def test_retries_replay_the_first_response():
+    """Tests that a retried POST is answered from the store without booking twice."""
+    client, _ = make_client()
+    first = book(client, "k-1")
+    retry = book(client, "k-1")
+
+    assert first.status_code == retry.status_code == 201
+    assert retry.json() == first.json()
+    assert retry.headers["Idempotent-Replayed"] == "true"
+    assert "Idempotent-Replayed" not in first.headers
+    assert len(bookings) == 1
+
+def test_keys_are_per_caller_and_route():
+    """Tests that another caller, or another route, with the same key runs its own request."""
+    client, _ = make_client()
+    book(client, "k-1")
+    book(client, "k-1", user="u-2")
+    client.post("/api/v1/patients", json={"slot": "09:00"}, headers={"Idempotency-Key": "k-1", "X-Test-User": "u-1"})
+
+    assert len(bookings) == 3
+
+def test_reused_keys_with_a_different_body_are_refused():
+    """Tests that a key sent again with another body is a 422 problem."""
+    client, _ = make_client()
+    book(client, "k-1")
+    response = book(client, "k-1", slot="10:00")
+
+    assert response.status_code == 422
+    assert response.json()["type"] == "urn:megacare:problem:idempotency-key-reused"
+    assert len(bookings) == 1
+
+def test_requests_in_progress_are_conflicts():
+    """Tests that a retry racing the first request is told to retry later."""
+    client, repository = make_client()
+    body = json.dumps({"slot": "09:00"}).encode()
+    route = "POST /api/v1/appointments"
+    record_id = idempotency_record_id("user:u-1", route, "k-1")
+    repository.claim(record_id, "user:u-1", route, "k-1", hashlib.sha256(body).hexdigest(), lease_seconds=60)
+    response = client.post("/api/v1/appointments", content=body, headers={"Idempotency-Key": "k-1", "X-Test-User": "u-1", "Content-Type": "application/json"})
+
+    assert response.status_code == 409
+    assert response.headers["Retry-After"] == "1"
+    assert not bookings
+
+def test_client_errors_are_replayed_and_server_errors_are_not():
+    """Tests that a 4xx is kept for retries, while a 5xx frees the key so the retry runs."""
+    client, _ = make_client()
+    assert book(client, "k-1", slot="taken").status_code == 409
+    assert book(client, "k-1", slot="taken").headers["Idempotent-Replayed"] == "true"
+
+    assert book(client, "k-2", slot="broken").status_code == 503
+    assert "Idempotent-Replayed" not in book(client, "k-2", slot="broken").headers
+
+def test_requests_without_a_key_or_with_a_bad_one():
+    """Tests that keyless POSTs run every time, and over-long keys are refused."""
+    client, _ = make_client()
+    client.post("/api/v1/appointments", json={"slot": "09:00"})
+    client.post("/api/v1/appointments", json={"slot": "09:00"})
+    assert len(bookings) == 2
+
+    response = book(client, "k" * 256)
+    assert response.status_code == 400
+    assert response.json()["type"] == "urn:megacare:problem:invalid-idempotency-key"
+
+def test_stored_responses_are_sealed(monkeypatch):
+    """Tests that the stored body is ciphertext, and that without field encryption responses are not kept."""
+    client, repository = make_client()
+    book(client, "k-1")
+
+    [row] = repository.store.table("idempotency_records").values()
+    assert is_sealed(row["data"]["responseBody"])
+    assert book(client, "k-1").headers["Idempotent-Replayed"] == "true"
+
+    monkeypatch.setattr(idempotency, "get_field_cipher", lambda: None)
+    book(client, "k-2")
+    assert "Idempotent-Replayed" not in book(client, "k-2").headers
+    assert len(bookings) == 3
+
+def test_credentials_are_never_stored():
+    """Tests that keys on routes issuing credentials are ignored, so no token is kept for replay."""
+    client, repository = make_client()
+    headers = {"Idempotency-Key": "k-1", "X-Test-User": "u-1"}
+    first = client.post("/api/v1/auth/sessions", json={"idToken": "t"}, headers=headers)
+    retry = client.post("/api/v1/auth/sessions", json={"idToken": "t"}, headers=headers)
+
+    assert (first.json(), retry.json()) == ({"accessToken": "token-1"}, {"accessToken": "token-2"})
+    assert "Idempotent-Replayed" not in retry.headers
+    assert not repository.store.table("idempotency_records")
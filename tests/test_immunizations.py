@@ -0,0 +1,191 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import date, datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_immunization_repository, get_patient_repository, get_practitioner_repository
+from app.api.v1.endpoints import immunizations
+from app.dependencies.auth import get_current_user
+from app.repositories.immunizations import ImmunizationRepository
+from app.repositories.patients import PatientRepository
+from app.repositories.practitioners import PractitionerRepository
+from app.services.immunizations import DEFAULT_SCHEDULE_PATH, Schedule, forecast, load_schedule
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(immunizations.router, prefix="/api/v1/patients", tags=["Immunizations"])
+app.dependency_overrides[get_current_user] = lambda: {"uid": "nurse-uid-123"}
+client = TestClient(app)
+
+FAKE_PATIENT_ID = "patient-1"
+FAKE_IMMUNIZATION_ID = "imm-1"
+CVX = schemas.CVX_SYSTEM
+
+def make_patient(dob=date(1960, 5, 1)):
+    return schemas.Patient.model_validate({
+        "patientId": FAKE_PATIENT_ID,
+        "givenName": "Somchai",
+        "familyName": "Jaidee",
+        "dob": dob,
+        "mrn": "MRN-001",
+        "createdAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+    })
+
+def make_immunization(code="187", occurrence_date=date(2024, 1, 10), status="completed", immunization_id=FAKE_IMMUNIZATION_ID):
+    return schemas.Immunization.model_validate({
+        "immunizationId": immunization_id,
+        "patientId": FAKE_PATIENT_ID,
+        "vaccineCode": {"system": CVX, "code": code},
+        "status": status,
+        "occurrenceDate": occurrence_date,
+        "lotNumber": "LOT-42",
+        "recordedBy": "nurse-uid-123",
+        "createdAt": datetime(2024, 1, 10, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 1, 10, tzinfo=timezone.utc),
+    })
+
+def make_schedule(**series):
+    return Schedule.model_validate({"series": [{
+        "id": "zoster", "name": "Zoster", "cvxCodes": ["187"], "minAgeYears": 50,
+        "doses": [{"intervalDays": 0}, {"intervalDays": 60}], "overdueAfterDays": 30,
+        **series,
+    }]})
+
+@pytest.fixture
+def repos():
+    """Overrides the immunization, patient and practitioner repositories with mocks."""
+    mocks = {
+        "immunizations": MagicMock(spec=ImmunizationRepository),
+        "patients": MagicMock(spec=PatientRepository),
+        "practitioners": MagicMock(spec=PractitionerRepository),
+    }
+    mocks["patients"].get.return_value = make_patient()
+    app.dependency_overrides[get_immunization_repository] = lambda: mocks["immunizations"]
+    app.dependency_overrides[get_patient_repository] = lambda: mocks["patients"]
+    app.dependency_overrides[get_practitioner_repository] = lambda: mocks["practitioners"]
+    yield mocks
+    for dep in (get_immunization_repository, get_patient_repository, get_practitioner_repository):
+        app.dependency_overrides.pop(dep, None)
+
+# --- Endpoint Test Cases ---
+
+def test_create_immunization_success(repos):
+    """Tests recording a dose with CVX coding, lot number and series position."""
+    repos["immunizations"].create.return_value = make_immunization()
+
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/immunizations", json={
+        "vaccine_code": {"system": CVX, "code": "187"},
+        "occurrence_date": "2024-01-10",
+        "lot_number": "LOT-42",
+        "dose_number": 1,
+        "series_doses": 2,
+    })
+
+    assert response.status_code == 201
+    assert response.json()["lot_number"] == "LOT-42"
+    assert repos["immunizations"].create.call_args.kwargs["recorded_by"] == "nurse-uid-123"
+
+def test_create_immunization_rejects_non_cvx_code(repos):
+    """Tests 422 when the vaccine is not coded with CVX."""
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/immunizations", json={
+        "vaccine_code": {"system": "http://snomed.info/sct", "code": "871751006"},
+        "occurrence_date": "2024-01-10",
+    })
+
+    assert response.status_code == 422
+    repos["immunizations"].create.assert_not_called()
+
+def test_create_immunization_rejects_dose_beyond_series(repos):
+    """Tests 422 when doseNumber exceeds seriesDoses."""
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/immunizations", json={
+        "vaccine_code": {"system": CVX, "code": "187"}, "occurrence_date": "2024-01-10", "dose_number": 3, "series_doses": 2,
+    })
+
+    assert response.status_code == 422
+
+def test_create_immunization_rejects_date_before_birth(repos):
+    """Tests 422 when the dose would predate the patient's birth."""
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/immunizations", json={
+        "vaccine_code": {"system": CVX, "code": "187"}, "occurrence_date": "1950-01-01",
+    })
+
+    assert response.status_code == 422
+    repos["immunizations"].create.assert_not_called()
+
+def test_get_forecast(repos):
+    """Tests that the forecast endpoint evaluates the schedule against the patient's history."""
+    repos["immunizations"].list.return_value = [make_immunization(code="187", occurrence_date=date(2024, 1, 10))]
+
+    response = client.get(f"/api/v1/patients/{FAKE_PATIENT_ID}/immunizations/forecast?asOf=2024-08-01")
+
+    assert response.status_code == 200
+    data = response.json()
+    assert data["as_of"] == "2024-08-01"
+    zoster = next(r for r in data["recommendations"] if r["series_id"] == "zoster")
+    assert zoster["dose_number"] == 2
+    assert zoster["status"] == "overdue"
+
+def test_get_immunization_of_other_patient_returns_404(repos):
+    """Tests that a record is only visible under its own patient."""
+    repos["immunizations"].get.return_value = make_immunization()
+
+    response = client.get(f"/api/v1/patients/someone-else/immunizations/{FAKE_IMMUNIZATION_ID}")
+
+    assert response.status_code == 404
+
+# --- Forecast Test Cases ---
+
+def test_forecast_first_dose_due_at_eligibility():
+    """Tests that the first dose falls due on the birthday that makes the patient eligible."""
+    [recommendation] = forecast(make_schedule(), date(1974, 3, 15), [], as_of=date(2024, 3, 20))
+
+    assert recommendation.dose_number == 1
+    assert recommendation.due_date == date(2024, 3, 15)
+    assert recommendation.overdue_date == date(2024, 4, 14)
+    assert recommendation.status == "due"
+
+def test_forecast_statuses_follow_interval_and_grace_period():
+    """Tests upcoming, due and overdue for the second dose of a series."""
+    history = [make_immunization(occurrence_date=date(2024, 1, 1))]
+    for as_of, expected in ((date(2024, 2, 1), "upcoming"), (date(2024, 3, 1), "due"), (date(2024, 4, 1), "overdue")):
+        [recommendation] = forecast(make_schedule(), date(1960, 1, 1), history, as_of=as_of)
+        assert recommendation.status == expected
+        assert recommendation.due_date == date(2024, 3, 1)
+
+def test_forecast_complete_and_repeating_series():
+    """Tests that finished series are complete unless they repeat, e.g. annually."""
+    history = [make_immunization(occurrence_date=date(2023, 1, 1)), make_immunization(occurrence_date=date(2023, 3, 1))]
+
+    [complete] = forecast(make_schedule(), date(1960, 1, 1), history, as_of=date(2024, 1, 1))
+    [repeating] = forecast(make_schedule(repeatEveryDays=365), date(1960, 1, 1), history, as_of=date(2024, 1, 1))
+
+    assert complete.status == "complete"
+    assert complete.due_date is None
+    assert repeating.dose_number == 3
+    assert repeating.due_date == date(2024, 2, 29)
+
+def test_forecast_ignores_doses_not_given():
+    """Tests that not-done and entered-in-error records do not count towards a series."""
+    history = [make_immunization(status="not-done"), make_immunization(status="entered-in-error")]
+
+    [recommendation] = forecast(make_schedule(), date(1960, 1, 1), history, as_of=date(2024, 6, 1))
+
+    assert recommendation.doses_given == 0
+
+def test_forecast_skips_series_past_maximum_age():
+    """Tests that series not started before the maximum age are no longer recommended."""
+    schedule = make_schedule(minAgeYears=18, maxAgeYears=59)
+
+    assert forecast(schedule, date(1950, 1, 1), [], as_of=date(2024, 1, 1)) == []
+    assert len(forecast(schedule, date(1970, 1, 1), [], as_of=date(2024, 1, 1))) == 1
+
+def test_bundled_schedule_is_valid():
+    """Tests that the schedule shipped with the service parses."""
+    schedule = load_schedule(DEFAULT_SCHEDULE_PATH)
+
+    assert {"influenza", "zoster", "hepatitis-b"} <= {s.id for s in schedule.series}
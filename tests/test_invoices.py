@@ -0,0 +1,312 @@
+import hashlib
+import hmac
+import json
+import time
+import pytest
+from datetime import date, datetime, timezone
+from unittest.mock import MagicMock
+
+import httpx
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api.integrations.endpoints import payment_events
+from app.api.v1 import schemas
+from app.api.v1.deps import (
+    get_charge_item_repository,
+    get_claim_repository,
+    get_event_publisher,
+    get_invoice_repository,
+    get_task_queue,
+)
+from app.api.v1.endpoints import invoices as invoice_endpoints
+from app.authz.roles import COORDINATOR, OWN_RECORD_PERMISSIONS, PATIENT, grants, has_permission
+from app.dependencies.auth import get_current_user
+from app.invoices import statements
+from app.invoices.payments import StripePayments, stripe_payment, verify_stripe_signature
+from app.repositories.memory import (
+    MemoryChargeItemRepository,
+    MemoryClaimRepository,
+    MemoryCoverageRepository,
+    MemoryEncounterRepository,
+    MemoryInvoiceRepository,
+    MemoryPatientRepository,
+    MemoryStore,
+)
+from app.services.claims import build_claim
+from app.services.invoices import InvalidInvoiceError, build_invoice, payment_changes
+from app.tasks.jobs import INVOICE_STATEMENT_TASK
+from app.tasks.queue import TaskQueue
+
+# --- Test Setup ---
+
+PROVIDER = schemas.BillingProvider(
+    npi="1234567893", name="MegaCare Sleep Clinic", taxId="123456789",
+    address=schemas.BillingAddress(line="1 Main St", city="Austin", state="TX", postalCode="787010001"),
+)
+
+def add_claim(store, mrn="MRN-1", status="paid", patient_cents=(20000, 5000)):
+    """A claim of two lines the payer settled with `status`, leaving the patient `patient_cents` of each."""
+    patient = MemoryPatientRepository(store).create(schemas.PatientCreate(givenName="Ann", familyName="Lee", dob="1980-01-01", sex="female", mrn=mrn))
+    coverage = MemoryCoverageRepository(store).create(
+        patient.patient_id, schemas.CoverageCreate(payerId="60054", payerName="Aetna", memberId="W123456789"), recorded_by="desk-1",
+    )
+    encounters = MemoryEncounterRepository(store)
+    encounter = encounters.create(schemas.EncounterCreate(patientId=patient.patient_id, visitType="ambulatory", start=datetime(2024, 5, 1, 9, tzinfo=timezone.utc)))
+    encounter = encounters.update(encounter.encounter_id, {"status": "finished"})
+    charge_items = MemoryChargeItemRepository(store)
+    charges = [
+        charge_items.create(encounter, schemas.ChargeItemCreate(procedureCode=code, diagnosisCodes=["G47.33"], chargeCents=cents), date(2024, 5, 1), recorded_by="desk-1")
+        for code, cents in (("95810", 150000), ("94660", 25000))
+    ]
+    claims = MemoryClaimRepository(store)
+    claim = claims.create(build_claim(encounter, coverage, charges, "CI"), created_by="desk-1")
+    for step in ("queued", "submitted"):
+        claim = claims.change_status(claim.claim_id, step, "desk-1")
+    if status == "submitted":
+        return claim
+    lines = [
+        {
+            **line.model_dump(by_alias=True),
+            "paidCents": 0 if status == "denied" else line.charge_cents // 2,
+            "adjustments": [
+                {"group": "CO", "reasonCode": "45", "amountCents": line.charge_cents // 2 - owed},
+                {"group": "PR", "reasonCode": "2", "amountCents": owed},
+            ],
+        }
+        for line, owed in zip(claim.lines, patient_cents)
+    ]
+    return claims.change_status(claim.claim_id, status, "payer", changes={"lines": lines})
+
+def add_invoice(store, status="issued", mrn="MRN-1"):
+    claim = add_claim(store, mrn=mrn)
+    invoices = MemoryInvoiceRepository(store)
+    invoice = invoices.create(build_invoice(claim, {}), created_by="desk-1")
+    if status == "issued":
+        invoice = invoices.change_status(invoice.invoice_id, "issued", {"issuedAt": datetime.now(timezone.utc), "dueDate": date(2024, 7, 1)})
+    return invoice
+
+def stripe_signature(secret, payload, at=None):
+    at = int(at if at is not None else time.time())
+    digest = hmac.new(secret.encode(), f"{at}.".encode() + payload, hashlib.sha256).hexdigest()
+    return f"t={at},v1={digest}"
+
+def checkout_completed(invoice_id, amount_cents, reference="pi_1", tenant_id=None):
+    metadata = {"invoiceId": invoice_id, **({"tenantId": tenant_id} if tenant_id else {})}
+    return {
+        "type": "checkout.session.completed",
+        "data": {"object": {"id": "cs_1", "payment_status": "paid", "amount_total": amount_cents, "payment_intent": reference, "metadata": metadata}},
+    }
+
+# --- Invoice Building Test Cases ---
+
+def test_build_invoice_bills_the_patient_responsibility():
+    """Tests that each line bills its PR adjustments and the totals add up."""
+    store = MemoryStore()
+    claim = add_claim(store)
+    charges = MemoryChargeItemRepository(store)
+
+    fields = build_invoice(claim, {line.charge_item_id: charges.get(line.charge_item_id) for line in claim.lines}, "Thank you")
+
+    assert [line["patientCents"] for line in fields["lines"]] == [20000, 5000]
+    assert (fields["totalChargeCents"], fields["insurancePaidCents"], fields["adjustmentCents"]) == (175000, 87500, 62500)
+    assert (fields["amountDueCents"], fields["balanceCents"], fields["message"]) == (25000, 25000, "Thank you")
+
+def test_build_invoice_refuses_unsettled_claims_and_nothing_owed():
+    """Tests that only adjudicated claims leaving the patient something to pay are invoiced."""
+    store = MemoryStore()
+
+    with pytest.raises(InvalidInvoiceError):
+        build_invoice(add_claim(store, status="submitted"), {})
+    with pytest.raises(InvalidInvoiceError):
+        build_invoice(add_claim(store, mrn="MRN-2", patient_cents=(0, 0)), {})
+    assert build_invoice(add_claim(store, mrn="MRN-3", status="denied"), {})["amountDueCents"] == 25000
+
+def test_payments_settle_the_balance():
+    """Tests that a part payment leaves the invoice partially paid and the rest pays it."""
+    store = MemoryStore()
+    invoices = MemoryInvoiceRepository(store)
+    invoice = add_invoice(store)
+
+    part = invoices.record_payment(invoice.invoice_id, schemas.InvoicePaymentCreate(amountCents=10000, method="cash"), "desk-1")
+    assert (part.status, part.amount_paid_cents, part.balance_cents, part.paid_at) == ("partially-paid", 10000, 15000, None)
+
+    rest = invoices.record_payment(invoice.invoice_id, schemas.InvoicePaymentCreate(amountCents=15000, method="check", reference="CHK-101"), "desk-1")
+    assert (rest.status, rest.balance_cents, [p.method for p in rest.payments]) == ("paid", 0, ["cash", "check"])
+    assert rest.paid_at is not None
+
+def test_payments_over_the_balance_or_repeated_are_refused():
+    """Tests that overpayments, repeated references and payments on drafts are refused."""
+    store = MemoryStore()
+    invoice = add_invoice(store)
+    paid = payment_changes(invoice, schemas.InvoicePaymentCreate(amountCents=100, method="card", reference="pi_1"), "desk-1")
+    invoice = invoice.model_copy(update={"payments": [schemas.InvoicePayment.model_validate(p) for p in paid["payments"]], "balance_cents": paid["balanceCents"]})
+
+    with pytest.raises(InvalidInvoiceError):
+        payment_changes(invoice, schemas.InvoicePaymentCreate(amountCents=100, method="card", reference="pi_1"), "desk-1")
+    with pytest.raises(InvalidInvoiceError):
+        payment_changes(invoice, schemas.InvoicePaymentCreate(amountCents=invoice.balance_cents + 1, method="cash"), "desk-1")
+    with pytest.raises(InvalidInvoiceError):
+        payment_changes(add_invoice(store, "draft", mrn="MRN-2"), schemas.InvoicePaymentCreate(amountCents=100, method="cash"), "desk-1")
+
+# --- Statement Test Cases ---
+
+def test_statement_is_rendered_once(monkeypatch):
+    """Tests that an issued invoice's statement is a PDF quoting its number, written once."""
+    store = MemoryStore()
+    invoices = MemoryInvoiceRepository(store)
+    invoice = add_invoice(store)
+    uploads = []
+    monkeypatch.setattr(statements, "upload_blob", lambda bucket, name, data, content_type: uploads.append((bucket, name, data)))
+
+    written = statements.write_statement(invoice.invoice_id, invoices, MemoryPatientRepository(store), PROVIDER, "statements")
+
+    assert written.statement.blob_name == f"invoices/{invoice.invoice_id}/statement.pdf"
+    bucket, name, data = uploads[0]
+    assert (bucket, name) == ("statements", written.statement.blob_name)
+    assert data.startswith(b"%PDF-1.4") and data.rstrip().endswith(b"%%EOF")
+    assert f"Statement number: {invoice.statement_number}".encode() in data
+    assert b"78701-0001" in data and b"$250.00" in data
+    assert statements.write_statement(invoice.invoice_id, invoices, MemoryPatientRepository(store), PROVIDER, "statements") is None
+    assert len(uploads) == 1
+
+# --- Stripe Test Cases ---
+
+def test_stripe_signatures_are_verified():
+    """Tests that only recent signatures of the exact body with the secret are accepted."""
+    payload = b'{"type": "checkout.session.completed"}'
+    now = time.time()
+
+    assert verify_stripe_signature("whsec", payload, stripe_signature("whsec", payload, now), now)
+    assert not verify_stripe_signature("whsec", payload + b" ", stripe_signature("whsec", payload, now), now)
+    assert not verify_stripe_signature("other", payload, stripe_signature("whsec", payload, now), now)
+    assert not verify_stripe_signature("whsec", payload, stripe_signature("whsec", payload, now - 600), now)
+    assert not verify_stripe_signature("whsec", payload, "garbage", now)
+
+def test_stripe_payment_reads_completed_checkouts():
+    """Tests that paid checkouts name the invoice and amount, and other events are ignored."""
+    payment = stripe_payment(checkout_completed("inv-1", 25000, tenant_id="org-1"))
+
+    assert (payment.invoice_id, payment.tenant_id, payment.amount_cents, payment.reference) == ("inv-1", "org-1", 25000, "pi_1")
+    assert stripe_payment({"type": "checkout.session.expired", "data": {"object": {}}}) is None
+    unpaid = checkout_completed("inv-1", 25000)
+    unpaid["data"]["object"]["payment_status"] = "unpaid"
+    assert stripe_payment(unpaid) is None
+
+def test_stripe_checkout_bills_the_balance():
+    """Tests that the checkout session charges the balance and carries the invoice and organization."""
+    store = MemoryStore()
+    invoice = add_invoice(store)
+    requests = []
+
+    def handler(request):
+        requests.append(request)
+        return httpx.Response(200, json={"id": "cs_1", "url": "https://checkout.stripe.com/c/cs_1", "expires_at": 1767225600})
+
+    stripe = StripePayments("sk_test", 10, client=httpx.Client(transport=httpx.MockTransport(handler)))
+    checkout = stripe.checkout(invoice, "org-1", "https://portal.example/billing")
+
+    assert (checkout.url, checkout.reference) == ("https://checkout.stripe.com/c/cs_1", "cs_1")
+    assert checkout.expires_at == datetime(2026, 1, 1, tzinfo=timezone.utc)
+    sent = dict(httpx.QueryParams(requests[0].content.decode()))
+    assert requests[0].headers["Authorization"] == "Bearer sk_test"
+    assert sent["line_items[0][price_data][unit_amount]"] == "25000"
+    assert (sent["metadata[invoiceId]"], sent["metadata[tenantId]"]) == (invoice.invoice_id, "org-1")
+
+def test_patients_read_and_pay_their_own_invoices():
+    """Tests that coordinators manage invoices and patients may only read and pay their own."""
+    full, _own = grants({"roles": [COORDINATOR]})
+    own = OWN_RECORD_PERMISSIONS[PATIENT]
+
+    assert has_permission(full, "invoices:write")
+    assert has_permission(own, "invoices:read") and has_permission(own, "invoices.checkout:write")
+    assert not has_permission(own, "invoices:write") and not has_permission(own, "invoices.payments:write")
+
+# --- Endpoint Test Cases ---
+
+app = FastAPI()
+app.include_router(invoice_endpoints.router, prefix="/api/v1/invoices")
+app.include_router(payment_events.router, prefix="/integrations")
+client = TestClient(app)
+
+@pytest.fixture
+def env(monkeypatch):
+    """A paid claim in a memory store and a task queue, with a coordinator signed in."""
+    store = MemoryStore()
+    tasks = MagicMock(spec=TaskQueue)
+    monkeypatch.setattr(invoice_endpoints.settings, "invoices_bucket", "statements")
+    monkeypatch.setattr(payment_events, "STRIPE_WEBHOOK_SECRET", "whsec")
+    app.dependency_overrides[get_charge_item_repository] = lambda: MemoryChargeItemRepository(store)
+    app.dependency_overrides[get_claim_repository] = lambda: MemoryClaimRepository(store)
+    app.dependency_overrides[get_invoice_repository] = lambda: MemoryInvoiceRepository(store)
+    app.dependency_overrides[get_event_publisher] = lambda: MagicMock()
+    app.dependency_overrides[get_task_queue] = lambda: tasks
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "desk-1", "roles": [COORDINATOR]}
+    yield {"store": store, "tasks": tasks, "claim": add_claim(store)}
+    app.dependency_overrides.clear()
+
+def post_stripe_event(event, secret="whsec"):
+    payload = json.dumps(event).encode()
+    headers = {"Stripe-Signature": stripe_signature(secret, payload), "Content-Type": "application/json"}
+    return client.post("/integrations/payments/stripe/events", content=payload, headers=headers)
+
+def test_invoices_are_issued_and_paid(env):
+    """Tests drafting an invoice for a claim, issuing it to render the statement, and paying it off."""
+    claim = env["claim"]
+    response = client.post("/api/v1/invoices", json={"claimId": claim.claim_id})
+    assert response.status_code == 201
+    invoice = response.json()
+    assert (invoice["status"], invoice["amount_due_cents"], invoice["patient_id"]) == ("draft", 25000, claim.patient_id)
+    assert client.post("/api/v1/invoices", json={"claimId": claim.claim_id}).status_code == 409
+    assert client.post(f"/api/v1/invoices/{invoice['invoice_id']}/payments", json={"amountCents": 100, "method": "cash"}).status_code == 409
+
+    issued = client.post(f"/api/v1/invoices/{invoice['invoice_id']}/status", json={"status": "issued"})
+    assert issued.status_code == 200
+    assert issued.json()["status"] == "issued" and issued.json()["due_date"] is not None
+    env["tasks"].enqueue.assert_called_once_with(INVOICE_STATEMENT_TASK, {"invoiceId": invoice["invoice_id"]}, task_id=f"invoice-statement-{invoice['invoice_id']}")
+    assert client.get(f"/api/v1/invoices/{invoice['invoice_id']}/statement").status_code == 409
+
+    payments_url = f"/api/v1/invoices/{invoice['invoice_id']}/payments"
+    assert client.post(payments_url, json={"amountCents": 30000, "method": "cash"}).status_code == 409
+    assert client.post(payments_url, json={"amountCents": 5000, "method": "check", "reference": "CHK-1"}).json()["status"] == "partially-paid"
+    paid = client.post(payments_url, json={"amountCents": 20000, "method": "card"})
+    assert paid.status_code == 201 and (paid.json()["status"], paid.json()["balance_cents"]) == ("paid", 0)
+    assert [i["invoice_id"] for i in client.get("/api/v1/invoices", params={"status": "paid"}).json()["items"]] == [invoice["invoice_id"]]
+
+def test_voided_invoices_free_the_claim(env):
+    """Tests that voiding an invoice lets the claim be invoiced again, and unsettled claims are refused."""
+    claim = env["claim"]
+    first = client.post("/api/v1/invoices", json={"claimId": claim.claim_id}).json()
+    voided = client.post(f"/api/v1/invoices/{first['invoice_id']}/status", json={"status": "void", "note": "Claim corrected"})
+    assert (voided.json()["status"], voided.json()["void_note"]) == ("void", "Claim corrected")
+    assert client.post(f"/api/v1/invoices/{first['invoice_id']}/status", json={"status": "issued"}).status_code == 409
+    assert client.post("/api/v1/invoices", json={"claimId": claim.claim_id}).status_code == 201
+
+    unsettled = add_claim(env["store"], mrn="MRN-2", status="submitted")
+    assert client.post("/api/v1/invoices", json={"claimId": unsettled.claim_id}).status_code == 409
+    assert client.post("/api/v1/invoices", json={"claimId": "unknown"}).status_code == 422
+
+def test_checkout_needs_a_payment_provider(env, monkeypatch):
+    """Tests that online payment is unavailable without a provider and for invoices not open for payment."""
+    invoice = add_invoice(env["store"], mrn="MRN-2")
+    monkeypatch.setattr(invoice_endpoints, "get_payment_provider", lambda: None)
+    assert client.post(f"/api/v1/invoices/{invoice.invoice_id}/checkout").status_code == 503
+
+    provider = MagicMock()
+    provider.checkout.return_value = MagicMock(url="https://checkout.stripe.com/c/cs_1", expires_at=datetime(2026, 1, 1, tzinfo=timezone.utc), reference="cs_1")
+    monkeypatch.setattr(invoice_endpoints, "get_payment_provider", lambda: provider)
+    assert client.post(f"/api/v1/invoices/{invoice.invoice_id}/checkout").json()["url"] == "https://checkout.stripe.com/c/cs_1"
+    draft = add_invoice(env["store"], "draft", mrn="MRN-3")
+    assert client.post(f"/api/v1/invoices/{draft.invoice_id}/checkout").status_code == 409
+
+def test_stripe_reports_payments_once(env):
+    """Tests that a signed checkout event pays the invoice once, however often Stripe sends it."""
+    invoice = add_invoice(env["store"], mrn="MRN-2")
+    event = checkout_completed(invoice.invoice_id, 25000)
+
+    assert post_stripe_event(event, secret="wrong").status_code == 403
+    assert post_stripe_event(event).status_code == 200
+    assert post_stripe_event(event).status_code == 200
+    paid = MemoryInvoiceRepository(env["store"]).get(invoice.invoice_id)
+    assert (paid.status, [(p.source, p.reference) for p in paid.payments]) == ("paid", [("stripe", "pi_1")])
+    assert post_stripe_event(checkout_completed("unknown", 100, reference="pi_2")).status_code == 200
@@ -0,0 +1,160 @@
+import ipaddress
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api import versioning
+from app.api.versioning import ApiVersion
+from app.authz.networks import address_allowed, client_address, parse_ranges
+from app.middleware.ip_allowlist import SourceAddressAllowlistMiddleware, SourceRangeCache
+from app.tenancy.context import acting_for
+
+# --- Test Setup ---
+
+RANGES = {"clinic-a": ["203.0.113.0/24"], "clinic-b": []}
+
+class FakeClock:
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+class ActAsMiddleware:
+    """Stands in for TenancyMiddleware: acts for the organization named in X-Test-Organization."""
+
+    def __init__(self, app):
+        self.app = app
+
+    async def __call__(self, scope, receive, send):
+        organization = dict(scope.get("headers") or []).get(b"x-test-organization")
+        with acting_for(organization.decode() if organization else None):
+            await self.app(scope, receive, send)
+
+def make_client(default_ranges=(), trusted_proxy_hops=1):
+    app = FastAPI()
+
+    @app.get("/api/v1/admin/api-keys")
+    def list_keys():
+        return []
+
+    @app.get("/api/v2/admin/api-keys")
+    def list_keys_v2():
+        return []
+
+    @app.get("/api/v1/patients")
+    def list_patients():
+        return []
+
+    @app.post("/integrations/hl7v2")
+    def receive_hl7v2():
+        return {}
+
+    @app.post("/integrations/payments/stripe/events")
+    def receive_stripe_event():
+        return {}
+
+    cache = SourceRangeCache(lambda organization_id: RANGES.get(organization_id), ttl_seconds=60)
+    app.add_middleware(
+        SourceAddressAllowlistMiddleware,
+        prefixes=["{api}/admin", "/integrations/hl7v2"],
+        default_ranges=list(default_ranges),
+        trusted_proxy_hops=trusted_proxy_hops,
+        cache=cache,
+    )
+    app.add_middleware(ActAsMiddleware)
+    return TestClient(app)
+
+def from_address(address, organization=None):
+    headers = {"X-Forwarded-For": address}
+    if organization:
+        headers["X-Test-Organization"] = organization
+    return headers
+
+# --- Address Test Cases ---
+
+def test_client_address_is_the_hop_appended_by_the_trusted_proxy():
+    """Tests that entries a client put in X-Forwarded-For itself are skipped."""
+    scope = {"headers": [(b"x-forwarded-for", b"10.9.9.9, 198.51.100.4")], "client": ("169.254.1.1", 443)}
+
+    assert client_address(scope, trusted_proxy_hops=1) == "198.51.100.4"
+    assert client_address(scope, trusted_proxy_hops=2) == "10.9.9.9"
+    assert client_address(scope, trusted_proxy_hops=3) is None
+    assert client_address(scope, trusted_proxy_hops=0) == "169.254.1.1"
+    assert client_address({"headers": [], "client": ("192.0.2.1", 443)}) == "192.0.2.1"
+
+def test_address_allowed():
+    ranges = parse_ranges(["203.0.113.0/24", "2001:db8::/32"])
+
+    assert address_allowed("203.0.113.200", ranges)
+    assert address_allowed("::ffff:203.0.113.5", ranges)
+    assert address_allowed("2001:db8::1", ranges)
+    assert not address_allowed("198.51.100.1", ranges)
+    assert not address_allowed("not-an-address", ranges)
+    assert not address_allowed(None, ranges)
+
+def test_parse_ranges_rejects_malformed_ranges():
+    assert parse_ranges(["192.0.2.7"]) == [ipaddress.ip_network("192.0.2.7/32")]
+    with pytest.raises(ValueError):
+        parse_ranges(["203.0.113.5/24"])
+    with pytest.raises(ValueError):
+        parse_ranges(["example.com"])
+
+# --- Middleware Test Cases ---
+
+def test_organization_ranges_apply_to_admin_routes():
+    client = make_client()
+
+    assert client.get("/api/v1/admin/api-keys", headers=from_address("203.0.113.9", "clinic-a")).status_code == 200
+    refused = client.get("/api/v1/admin/api-keys", headers=from_address("198.51.100.4", "clinic-a"))
+    assert refused.status_code == 403
+    assert refused.json()["type"].endswith("/source-address-forbidden")
+
+def test_spoofed_forwarded_entries_do_not_pass():
+    """Tests that a client cannot claim an allowed address by sending X-Forwarded-For itself."""
+    client = make_client()
+
+    response = client.get("/api/v1/admin/api-keys", headers=from_address("203.0.113.9, 198.51.100.4", "clinic-a"))
+
+    assert response.status_code == 403
+
+def test_other_routes_are_not_restricted():
+    client = make_client()
+
+    assert client.get("/api/v1/patients", headers=from_address("198.51.100.4", "clinic-a")).status_code == 200
+
+def test_partner_routes_of_every_version_are_restricted_but_not_provider_callbacks(monkeypatch):
+    """Tests that the admin API of each served version and the HL7 v2 interface are restricted, signed callbacks not."""
+    monkeypatch.setitem(versioning.API_VERSIONS, "v2", ApiVersion("v2"))
+    client = make_client(default_ranges=["192.0.2.0/24"])
+    outside = from_address("198.51.100.4")
+
+    assert client.get("/api/v2/admin/api-keys", headers=outside).status_code == 403
+    assert client.post("/integrations/hl7v2", headers=outside).status_code == 403
+    assert client.post("/integrations/payments/stripe/events", headers=outside).status_code == 200
+
+def test_default_ranges_apply_without_organization_ranges():
+    client = make_client(default_ranges=["192.0.2.0/24"])
+
+    assert client.get("/api/v1/admin/api-keys", headers=from_address("192.0.2.10", "clinic-b")).status_code == 200
+    assert client.get("/api/v1/admin/api-keys", headers=from_address("198.51.100.4", "clinic-b")).status_code == 403
+    assert client.get("/api/v1/admin/api-keys", headers=from_address("198.51.100.4")).status_code == 403
+
+def test_no_ranges_allow_any_address():
+    client = make_client()
+
+    assert client.get("/api/v1/admin/api-keys", headers=from_address("198.51.100.4", "clinic-b")).status_code == 200
+
+def test_ranges_are_cached():
+    """Tests that an organization's ranges are read once per TTL."""
+    lookups = []
+    clock = FakeClock()
+    cache = SourceRangeCache(lambda organization_id: lookups.append(organization_id) or ["203.0.113.0/24"], ttl_seconds=60, clock=clock)
+
+    cache.get("clinic-a")
+    cache.get("clinic-a")
+    clock.now += 60
+    cache.get("clinic-a")
+
+    assert lookups == ["clinic-a", "clinic-a"]
@@ -0,0 +1,192 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_lab_result_repository, get_patient_repository
+from app.api.v1.endpoints import lab_results
+from app.dependencies.auth import get_current_user
+from app.repositories.lab_results import FirestoreLabResultRepository, LabResultRepository
+from app.repositories.patients import PatientRepository
+from app.services.lab_results import LabResultValidationError, interpret, prepare_lab_result, trend_points
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(lab_results.router, prefix="/api/v1/patients", tags=["Lab Results"])
+app.dependency_overrides[get_current_user] = lambda: {"uid": "clinician-uid-123"}
+client = TestClient(app)
+
+FAKE_PATIENT_ID = "patient-1"
+FAKE_LAB_RESULT_ID = "lab-1"
+GLUCOSE = "2345-7"
+HBA1C = "4548-4"
+GLUCOSE_RANGE = {"low": 70, "high": 99, "criticalLow": 40, "criticalHigh": 400}
+
+def make_lab_result(lab_result_id=FAKE_LAB_RESULT_ID, glucose=110.0, effective_at=datetime(2024, 2, 1, 8, tzinfo=timezone.utc), status="final"):
+    return schemas.LabResult.model_validate({
+        "labResultId": lab_result_id,
+        "patientId": FAKE_PATIENT_ID,
+        "panelCode": "24323-8",
+        "status": status,
+        "effectiveAt": effective_at,
+        "analytes": [
+            {"code": GLUCOSE, "value": glucose, "unit": "mg/dL", "referenceRange": GLUCOSE_RANGE, "flag": "H"},
+            {"code": HBA1C, "value": 6.1, "unit": "%"},
+        ],
+        "abnormal": True,
+        "recordedBy": "clinician-uid-123",
+        "createdAt": datetime(2024, 2, 1, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 2, 1, tzinfo=timezone.utc),
+    })
+
+@pytest.fixture
+def repos():
+    """Overrides the lab result and patient repositories with mocks."""
+    mocks = {
+        "lab_results": MagicMock(spec=LabResultRepository),
+        "patients": MagicMock(spec=PatientRepository),
+    }
+    app.dependency_overrides[get_lab_result_repository] = lambda: mocks["lab_results"]
+    app.dependency_overrides[get_patient_repository] = lambda: mocks["patients"]
+    yield mocks
+    for dep in (get_lab_result_repository, get_patient_repository):
+        app.dependency_overrides.pop(dep, None)
+
+# --- Endpoint Test Cases ---
+
+def test_create_lab_result_flags_analytes(repos):
+    """Tests that analytes are flagged from their reference ranges before storage."""
+    repos["lab_results"].create.return_value = make_lab_result()
+
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/lab-results", json={
+        "panel_code": "24323-8",
+        "effective_at": "2024-02-01T08:00:00Z",
+        "analytes": [
+            {"code": GLUCOSE, "value": 110, "unit": "mg/dL", "reference_range": GLUCOSE_RANGE},
+            {"code": HBA1C, "value": 6.1, "unit": "%"},
+        ],
+    })
+
+    assert response.status_code == 201
+    stored = repos["lab_results"].create.call_args[0][1]
+    assert [a.flag for a in stored.analytes] == ["H", None]
+    assert repos["lab_results"].create.call_args.kwargs["recorded_by"] == "clinician-uid-123"
+
+def test_create_lab_result_rejects_invalid_loinc(repos):
+    """Tests 422 for analyte codes that are not LOINC-shaped."""
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/lab-results", json={
+        "effective_at": "2024-02-01T08:00:00Z",
+        "analytes": [{"code": "GLU", "value": 110, "unit": "mg/dL"}],
+    })
+
+    assert response.status_code == 422
+
+def test_create_lab_result_requires_panel_for_several_analytes(repos):
+    """Tests 422 when several analytes are sent without a panel code."""
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/lab-results", json={
+        "effective_at": "2024-02-01T08:00:00Z",
+        "analytes": [{"code": GLUCOSE, "value": 110, "unit": "mg/dL"}, {"code": HBA1C, "value": 6.1, "unit": "%"}],
+    })
+
+    assert response.status_code == 422
+
+def test_create_lab_result_unknown_patient(repos):
+    """Tests 404 when the patient does not exist."""
+    repos["patients"].get.return_value = None
+
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/lab-results", json={
+        "effective_at": "2024-02-01T08:00:00Z", "analytes": [{"code": GLUCOSE, "value": 110, "unit": "mg/dL"}],
+    })
+
+    assert response.status_code == 404
+    repos["lab_results"].create.assert_not_called()
+
+def test_list_lab_results_by_code_and_range(repos):
+    """Tests that code and date range filters are passed to the repository."""
+    repos["lab_results"].list.return_value = [make_lab_result()]
+
+    response = client.get(f"/api/v1/patients/{FAKE_PATIENT_ID}/lab-results?code={GLUCOSE}&from=2024-01-01T00:00:00Z&to=2024-03-01T00:00:00Z")
+
+    assert response.status_code == 200
+    kwargs = repos["lab_results"].list.call_args.kwargs
+    assert kwargs["code"] == GLUCOSE
+    assert kwargs["start_from"] == datetime(2024, 1, 1, tzinfo=timezone.utc)
+
+def test_list_lab_results_rejects_inverted_range(repos):
+    """Tests 400 when 'from' is not before 'to'."""
+    response = client.get(f"/api/v1/patients/{FAKE_PATIENT_ID}/lab-results?from=2024-03-01T00:00:00Z&to=2024-01-01T00:00:00Z")
+
+    assert response.status_code == 400
+
+def test_get_lab_trend(repos):
+    """Tests that the trend endpoint returns one analyte's values oldest first."""
+    repos["lab_results"].list.return_value = [
+        make_lab_result("lab-2", glucose=130, effective_at=datetime(2024, 3, 1, tzinfo=timezone.utc)),
+        make_lab_result("lab-1", glucose=110, effective_at=datetime(2024, 2, 1, tzinfo=timezone.utc)),
+    ]
+
+    response = client.get(f"/api/v1/patients/{FAKE_PATIENT_ID}/lab-results/trend?code={GLUCOSE}")
+
+    assert response.status_code == 200
+    assert [p["value"] for p in response.json()["points"]] == [110, 130]
+
+# --- Interpretation Test Cases ---
+
+def test_interpret_flags():
+    """Tests normal, high/low and critical flags against a reference range."""
+    reference_range = schemas.ReferenceRange.model_validate(GLUCOSE_RANGE)
+    for value, expected in ((85, "N"), (65, "L"), (120, "H"), (30, "LL"), (450, "HH")):
+        assert interpret(value, reference_range) == expected
+    assert interpret(85, None) is None
+    assert interpret(85, schemas.ReferenceRange(text="see report")) is None
+
+def test_prepare_keeps_flags_sent_by_lab():
+    """Tests that a flag provided by the lab is not overwritten."""
+    lab_in = schemas.LabResultCreate.model_validate({
+        "effectiveAt": "2024-02-01T08:00:00Z",
+        "analytes": [{"code": "5196-1", "valueString": "positive", "flag": "A"}],
+    })
+
+    prepared = prepare_lab_result(lab_in, now=datetime(2024, 2, 2, tzinfo=timezone.utc))
+
+    assert prepared.analytes[0].flag == "A"
+
+def test_prepare_rejects_future_collection_time():
+    """Tests that results cannot be collected in the future."""
+    lab_in = schemas.LabResultCreate.model_validate({
+        "effectiveAt": "2024-02-01T08:00:00Z", "analytes": [{"code": GLUCOSE, "value": 85, "unit": "mg/dL"}],
+    })
+
+    with pytest.raises(LabResultValidationError):
+        prepare_lab_result(lab_in, now=datetime(2024, 1, 1, tzinfo=timezone.utc))
+
+def test_trend_points_skip_cancelled_results():
+    """Tests that cancelled results do not appear in trends."""
+    results = [make_lab_result("lab-1"), make_lab_result("lab-2", status="cancelled")]
+
+    assert [p.lab_result_id for p in trend_points(results, GLUCOSE)] == ["lab-1"]
+
+# --- Firestore Repository Test Cases ---
+
+def test_firestore_create_denormalises_codes_and_abnormal():
+    """Tests that the panel and analyte codes are indexed and the abnormal flag is derived."""
+    mock_db = MagicMock()
+    result_ref = MagicMock()
+    snapshot = MagicMock()
+    snapshot.id = FAKE_LAB_RESULT_ID
+    snapshot.to_dict.return_value = make_lab_result().model_dump(by_alias=True, exclude={"lab_result_id"})
+    result_ref.get.return_value = snapshot
+    mock_db.collection.return_value.add.return_value = (None, result_ref)
+    lab_in = schemas.LabResultCreate.model_validate(make_lab_result().model_dump(by_alias=True))
+
+    repo = FirestoreLabResultRepository(mock_db)
+    repo.create(FAKE_PATIENT_ID, lab_in, recorded_by="clinician-uid-123")
+
+    data = mock_db.collection.return_value.add.call_args[0][0]
+    assert data["codes"] == ["24323-8", GLUCOSE, HBA1C]
+    assert data["abnormal"] is True
+    assert data["patientId"] == FAKE_PATIENT_ID
@@ -0,0 +1,132 @@
+import asyncio
+
+from app.middleware.load_shedding import LoadSheddingMiddleware
+from app.ratelimit.concurrency import AdaptiveConcurrencyLimiter, p99
+
+# --- Test Setup ---
+
+class FakeClock:
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+def make_limiter(clock, min_limit=2, max_limit=10):
+    return AdaptiveConcurrencyLimiter(min_limit, max_limit, latency_target_seconds=0.5, window_seconds=1.0, backoff=0.5, min_samples=3, clock=clock)
+
+def finish_window(limiter, clock, latency, count=3):
+    for _ in range(count):
+        assert limiter.try_acquire()
+        limiter.release(latency)
+    clock.now += 1.0
+    limiter.try_acquire()
+    limiter.release(0.0)
+
+def call(app, path="/api/v1/patients", method="GET"):
+    """Sends one request through the ASGI app and returns its status and headers."""
+    sent = []
+
+    async def receive():
+        return {"type": "http.request", "body": b"", "more_body": False}
+
+    async def send(message):
+        sent.append(message)
+
+    scope = {"type": "http", "method": method, "path": path, "headers": [], "query_string": b""}
+    asyncio.run(app(scope, receive, send))
+    start = sent[0]
+    return start["status"], {k.decode(): v.decode() for k, v in start["headers"]}
+
+async def ok(scope, receive, send):
+    await send({"type": "http.response.start", "status": 200, "headers": []})
+    await send({"type": "http.response.body", "body": b"{}"})
+
+# --- Limiter Test Cases ---
+
+def test_limiter_refuses_requests_over_its_limit():
+    """Tests that no more than the limit are admitted at once and a released slot is reused."""
+    limiter = make_limiter(FakeClock(), max_limit=2)
+
+    assert limiter.try_acquire() and limiter.try_acquire()
+    assert not limiter.try_acquire()
+    limiter.release(0.1)
+    assert limiter.try_acquire()
+    assert limiter.in_flight == 2
+
+def test_limiter_backs_off_while_latency_is_over_target():
+    """Tests that a window with a slow p99 cuts the limit, down to its minimum."""
+    clock = FakeClock()
+    limiter = make_limiter(clock)
+
+    finish_window(limiter, clock, latency=2.0)
+    assert limiter.limit == 5
+    finish_window(limiter, clock, latency=2.0)
+    finish_window(limiter, clock, latency=2.0)
+    assert limiter.limit == 2
+
+def test_limiter_recovers_one_at_a_time():
+    """Tests that fast windows grow the limit by one, up to its maximum."""
+    clock = FakeClock()
+    limiter = make_limiter(clock)
+    finish_window(limiter, clock, latency=2.0)
+
+    finish_window(limiter, clock, latency=0.1)
+    assert limiter.limit == 6
+    for _ in range(10):
+        finish_window(limiter, clock, latency=0.1)
+    assert limiter.limit == 10
+
+def test_limiter_ignores_windows_with_few_samples():
+    """Tests that a couple of slow requests in a quiet window do not cut the limit."""
+    clock = FakeClock()
+    limiter = make_limiter(clock)
+
+    finish_window(limiter, clock, latency=5.0, count=1)
+
+    assert limiter.limit == 10
+
+def test_p99_is_the_nearest_rank():
+    """Tests that the 99th percentile of 100 samples is the 99th, and of a few the largest."""
+    assert p99([float(i) for i in range(1, 101)]) == 99.0
+    assert p99([0.1, 0.2, 0.3]) == 0.3
+
+# --- Middleware Test Cases ---
+
+def test_requests_over_the_limit_get_503_with_retry_after():
+    """Tests that a request arriving while the worker is at its limit is refused as a problem."""
+    limiter = make_limiter(FakeClock(), max_limit=1)
+    app = LoadSheddingMiddleware(ok, limiter=limiter, retry_after_seconds=3)
+    assert limiter.try_acquire()
+
+    status, headers = call(app)
+
+    assert status == 503
+    assert headers["retry-after"] == "3"
+    assert headers["content-type"].startswith("application/problem+json")
+    assert limiter.in_flight == 1
+
+def test_admitted_requests_release_their_slot():
+    """Tests that a request that was served, or raised, frees its slot."""
+    limiter = make_limiter(FakeClock(), max_limit=1)
+
+    async def failing(scope, receive, send):
+        raise RuntimeError("boom")
+
+    assert call(LoadSheddingMiddleware(ok, limiter=limiter))[0] == 200
+    try:
+        call(LoadSheddingMiddleware(failing, limiter=limiter))
+    except RuntimeError:
+        pass
+    assert limiter.in_flight == 0
+
+def test_probes_and_preflights_are_never_refused():
+    """Tests that health checks, metrics and OPTIONS requests pass while the worker is at its limit."""
+    limiter = make_limiter(FakeClock(), max_limit=1)
+    app = LoadSheddingMiddleware(ok, limiter=limiter)
+    assert limiter.try_acquire()
+
+    assert call(app, "/healthz")[0] == 200
+    assert call(app, "/metrics")[0] == 200
+    assert call(app, "/api/v1/patients", method="OPTIONS")[0] == 200
+    assert call(app, "/healthzz")[0] == 503
@@ -0,0 +1,202 @@
+import pytest
+import time
+from fastapi import Depends, FastAPI
+from fastapi.testclient import TestClient
+
+from app.api.v1.deps import get_lockout_repository
+from app.api.v1.endpoints import lockouts, sessions
+from app.audit.context import AuditContext, audit_context_var
+from app.auth.lockout import LockedOut, LoginGuard, account, address
+from app.auth.verifier import InvalidTokenError, TokenVerifier
+from app.dependencies.auth import get_current_user
+from app.middleware.authentication import AuthenticationMiddleware
+from app.repositories.memory import MemoryLockoutRepository, MemoryStore
+
+# --- Test Setup ---
+
+def make_guard(repo, now, **limits):
+    return LoginGuard(repository_factory=lambda: repo, clock=lambda: now[0], **{"account_threshold": 5, "address_threshold": 8, **limits})
+
+@pytest.fixture
+def audit_context():
+    context = AuditContext()
+    token = audit_context_var.set(context)
+    yield context
+    audit_context_var.reset(token)
+
+# --- Guard Test Cases ---
+
+def test_failures_past_the_free_attempts_delay_the_next_one_progressively():
+    repo = MemoryLockoutRepository(MemoryStore())
+    now = [1_700_000_000.0]
+    guard = make_guard(repo, now, free_attempts=2, base_delay_seconds=2, max_delay_seconds=5)
+
+    delays = []
+    for _ in range(4):
+        guard.check(account("u1"))
+        guard.failed(account("u1"))
+        record = repo.get("account:u1")
+        delays.append((record.retry_at.timestamp() - now[0]) if record.retry_at else 0)
+        now[0] += 10
+
+    assert delays == [0, 0, 2, 4]
+    guard.failed(account("u1"))
+    with pytest.raises(LockedOut) as refused:
+        guard.check(account("u1"))
+    assert refused.value.locked is True
+    assert refused.value.retry_after == 900
+
+def test_lockout_ends_and_failures_are_counted_afresh():
+    repo = MemoryLockoutRepository(MemoryStore())
+    now = [1_700_000_000.0]
+    guard = make_guard(repo, now, free_attempts=10)
+    for _ in range(5):
+        guard.failed(account("u1"))
+    with pytest.raises(LockedOut):
+        guard.check(account("u1"))
+
+    now[0] += 901
+    guard.check(account("u1"))
+    guard.failed(account("u1"))
+
+    record = repo.get("account:u1")
+    assert record.failures == 1
+    assert record.lockouts == 1
+    assert record.locked_until is None
+
+def test_failures_outside_the_window_do_not_add_up():
+    repo = MemoryLockoutRepository(MemoryStore())
+    now = [1_700_000_000.0]
+    guard = make_guard(repo, now, free_attempts=10)
+
+    for _ in range(6):
+        guard.failed(account("u1"))
+        now[0] += 300
+
+    assert repo.get("account:u1").failures == 3
+    guard.check(account("u1"))
+
+def test_signing_in_clears_the_account_but_not_the_address():
+    repo = MemoryLockoutRepository(MemoryStore())
+    now = [time.time()]
+    guard = make_guard(repo, now)
+    guard.failed(account("u1"), address("203.0.113.7"))
+
+    guard.succeeded(account("u1"))
+    guard.succeeded(address("203.0.113.7"))
+
+    assert repo.get("account:u1") is None
+    assert repo.get("address:203.0.113.7").failures == 1
+
+def test_addresses_have_their_own_threshold():
+    repo = MemoryLockoutRepository(MemoryStore())
+    now = [time.time()]
+    guard = make_guard(repo, now, free_attempts=10)
+    for _ in range(5):
+        guard.failed(address("203.0.113.7"))
+
+    guard.check(address("203.0.113.7"), address(None))
+    for _ in range(3):
+        guard.failed(address("203.0.113.7"))
+    with pytest.raises(LockedOut):
+        guard.check(account("u1"), address("203.0.113.7"))
+
+def test_failures_and_lockouts_mark_the_audit_event(audit_context):
+    repo = MemoryLockoutRepository(MemoryStore())
+    now = [time.time()]
+    guard = make_guard(repo, now, free_attempts=1)
+
+    guard.failed(account("u1"))
+    assert audit_context.security_event == "sign-in-failed"
+    guard.failed(account("u1"))
+    assert audit_context.security_event == "sign-in-delayed"
+    for _ in range(3):
+        guard.failed(account("u1"))
+    assert audit_context.security_event == "locked-out"
+    with pytest.raises(LockedOut):
+        guard.check(account("u1"))
+    assert audit_context.security_event == "lockout-refused"
+    guard.unlock("account:u1")
+    assert audit_context.security_event == "unlocked"
+
+# --- Middleware Test Cases ---
+
+class RejectingVerifier(TokenVerifier):
+    def verify(self, token):
+        if token != "good":
+            raise InvalidTokenError("Unknown token")
+        return {"uid": "u1", "roles": ["admin"]}
+
+def test_rejected_credentials_lock_out_the_address_but_valid_ones_still_work():
+    repo = MemoryLockoutRepository(MemoryStore())
+    guard = make_guard(repo, [time.time()], free_attempts=100, address_threshold=3)
+    protected = FastAPI()
+    protected.add_middleware(AuthenticationMiddleware, verifier_factory=RejectingVerifier, login_guard_factory=lambda: guard)
+
+    @protected.get("/api/v1/patients")
+    def list_patients(current_user=Depends(get_current_user)):
+        return {"uid": current_user["uid"]}
+
+    client = TestClient(protected)
+    statuses = [client.get("/api/v1/patients", headers={"Authorization": "Bearer bad"}).status_code for _ in range(4)]
+
+    assert statuses == [401, 401, 401, 429]
+    assert repo.get("address:testclient").locked_until is not None
+    assert client.get("/api/v1/patients", headers={"Authorization": "Bearer good"}).status_code == 200
+
+class UnavailableVerifier(TokenVerifier):
+    def verify(self, token):
+        raise ConnectionError("JWKS fetch timed out")
+
+def test_verifier_outages_are_not_counted_as_rejected_credentials():
+    """Tests that credentials which could not be checked get 503 and do not lock out the address."""
+    repo = MemoryLockoutRepository(MemoryStore())
+    guard = make_guard(repo, [time.time()], free_attempts=100, address_threshold=1)
+    protected = FastAPI()
+    protected.add_middleware(AuthenticationMiddleware, verifier_factory=UnavailableVerifier, login_guard_factory=lambda: guard)
+
+    @protected.get("/api/v1/patients")
+    def list_patients():
+        return []
+
+    client = TestClient(protected)
+    statuses = [client.get("/api/v1/patients", headers={"Authorization": "Bearer good"}).status_code for _ in range(3)]
+
+    assert statuses == [503, 503, 503]
+    assert repo.get("address:testclient") is None
+
+# --- Endpoint Test Cases ---
+
+lockout_repo = MemoryLockoutRepository(MemoryStore())
+endpoint_guard = LoginGuard(repository_factory=lambda: lockout_repo, account_threshold=2)
+current_user = {"uid": "admin-1", "roles": ["admin"]}
+
+app = FastAPI()
+app.include_router(lockouts.router, prefix="/api/v1/admin/lockouts")
+app.dependency_overrides[get_lockout_repository] = lambda: lockout_repo
+app.dependency_overrides[sessions.login_guard] = lambda: endpoint_guard
+app.dependency_overrides[get_current_user] = lambda: current_user
+
+client = TestClient(app)
+
+def test_administrators_list_and_lift_lockouts():
+    endpoint_guard.failed(account("u7"))
+    endpoint_guard.failed(account("u7"))
+    endpoint_guard.failed(account("u8"))
+
+    listed = client.get("/api/v1/admin/lockouts").json()
+    assert [lockout["lockout_id"] for lockout in listed] == ["account:u7"]
+    assert client.get("/api/v1/admin/lockouts/account:u8").json()["failures"] == 1
+
+    assert client.delete("/api/v1/admin/lockouts/account:u7").status_code == 204
+    endpoint_guard.check(account("u7"))
+    assert client.delete("/api/v1/admin/lockouts/account:u7").status_code == 404
+
+def test_only_administrators_manage_lockouts():
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "c1", "roles": ["clinician"]}
+    try:
+        response = client.delete("/api/v1/admin/lockouts/account:u8")
+    finally:
+        app.dependency_overrides[get_current_user] = lambda: current_user
+
+    assert response.status_code == 403
@@ -0,0 +1,64 @@
+import json
+import logging
+
+from app.core import logging_config
+from app.core.logging_config import CloudLoggingFormatter
+
+# --- Helpers ---
+
+def make_record(msg="hello", level=logging.INFO, **extra):
+    record = logging.LogRecord("test.logger", level, __file__, 42, msg, None, None, func="fn")
+    for key, value in extra.items():
+        setattr(record, key, value)
+    return record
+
+# --- Test Cases ---
+
+def test_formats_basic_record_as_json():
+    """Tests that a plain record is emitted with Cloud Logging's core fields."""
+    entry = json.loads(CloudLoggingFormatter().format(make_record(level=logging.WARNING)))
+
+    assert entry["severity"] == "WARNING"
+    assert entry["message"] == "hello"
+    assert entry["logger"] == "test.logger"
+    assert entry["logging.googleapis.com/sourceLocation"]["line"] == 42
+    assert "time" in entry
+
+def test_includes_trace_http_request_and_labels(monkeypatch):
+    """Tests that optional `extra` fields are mapped to Cloud Logging's special keys."""
+    monkeypatch.setattr(logging_config, "GOOGLE_CLOUD_PROJECT", "mega-care-dev")
+    record = make_record(
+        trace="abc123",
+        span_id="0000000000000001",
+        http_request={"requestMethod": "GET", "status": 200},
+        labels={"component": "auth"},
+    )
+
+    entry = json.loads(CloudLoggingFormatter().format(record))
+
+    assert entry["logging.googleapis.com/trace"] == "projects/mega-care-dev/traces/abc123"
+    assert entry["logging.googleapis.com/spanId"] == "0000000000000001"
+    assert entry["httpRequest"] == {"requestMethod": "GET", "status": 200}
+    assert entry["logging.googleapis.com/labels"]["component"] == "auth"
+
+def test_appends_exception_to_message():
+    """Tests that exception tracebacks are included in the message for Error Reporting."""
+    try:
+        raise ValueError("boom")
+    except ValueError:
+        import sys
+        record = make_record(msg="failed", level=logging.ERROR)
+        record.exc_info = sys.exc_info()
+
+    entry = json.loads(CloudLoggingFormatter().format(record))
+
+    assert entry["severity"] == "ERROR"
+    assert entry["message"].startswith("failed\nTraceback")
+    assert "ValueError: boom" in entry["message"]
+
+def test_json_fields_are_added_to_the_payload():
+    """Tests that `json_fields` become top-level payload fields without replacing the standard ones."""
+    entry = json.loads(CloudLoggingFormatter().format(make_record(json_fields={"route": "/api/v1/patients", "message": "ignored"})))
+
+    assert entry["route"] == "/api/v1/patients"
+    assert entry["message"] == "hello"
@@ -0,0 +1,156 @@
+import jwt
+import pytest
+from datetime import datetime, timedelta, timezone
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api.v1.deps import get_task_queue
+from app.api.v1.endpoints import magic_links, sessions
+from app.auth.directory import UserDirectory
+from app.auth.lockout import LoginGuard
+from app.auth.magic_links import MagicLinkError, MagicLinks, MagicLinkThrottled
+from app.auth.sessions import SessionManager
+from app.auth.verifier import InvalidTokenError, TokenVerifier
+from app.ratelimit.buckets import MemoryTokenBuckets
+from app.repositories.memory import MemoryLockoutRepository, MemoryMagicLinkRepository, MemorySessionRepository, MemoryStore
+from app.tasks.jobs import MAGIC_LINK_EMAIL_TASK
+
+# --- Test Setup ---
+
+SIGNING_KEY = "k" * 32
+
+class FakeDirectory(UserDirectory):
+    def __init__(self):
+        self.users = {"u1": {"uid": "u1", "email": "Ada@Example.com", "roles": ["patient"], "patientId": "patient-u1"}}
+
+    def find_by_email(self, email):
+        return next((user for user in self.users.values() if user["email"].lower() == email.lower()), None)
+
+    def get(self, uid):
+        return self.users.get(uid)
+
+def make_links(repo, directory=None, now=None, per_hour=5):
+    now = now or [datetime.now(timezone.utc)]
+    return MagicLinks(
+        SIGNING_KEY, directory or FakeDirectory(), MemoryTokenBuckets(), repository_factory=lambda: repo,
+        ttl_seconds=900, per_address_per_hour=per_hour, link_url="https://care.example.com/sign-in/link", clock=lambda: now[0],
+    )
+
+# --- Magic Link Test Cases ---
+
+def test_links_are_only_recorded_for_known_addresses():
+    repo = MemoryMagicLinkRepository(MemoryStore())
+    links = make_links(repo)
+
+    record = links.request("ada@example.com", source_ip="203.0.113.7")
+
+    assert record.uid == "u1"
+    assert record.email == "Ada@Example.com"
+    assert record.requested_from == "203.0.113.7"
+    assert links.url(record).startswith("https://care.example.com/sign-in/link#token=")
+    assert links.request("nobody@example.com") is None
+
+def test_links_work_once():
+    repo = MemoryMagicLinkRepository(MemoryStore())
+    links = make_links(repo)
+    record = links.request("ada@example.com")
+    token = links.token(record)
+
+    assert links.redeem(token)["uid"] == "u1"
+    assert repo.get(record.link_id).status == "used"
+    with pytest.raises(MagicLinkError):
+        links.redeem(token)
+
+def test_expired_and_forged_links_are_refused():
+    repo = MemoryMagicLinkRepository(MemoryStore())
+    now = [datetime.now(timezone.utc) - timedelta(minutes=20)]
+    links = make_links(repo, now=now)
+    expired = links.token(links.request("ada@example.com"))
+    now[0] = datetime.now(timezone.utc)
+    forged = jwt.encode({**jwt.decode(links.token(links.request("ada@example.com")), options={"verify_signature": False})}, "o" * 32, algorithm="HS256")
+
+    for token in (expired, forged, "not-a-jwt"):
+        with pytest.raises(MagicLinkError):
+            links.redeem(token)
+
+def test_links_of_disabled_accounts_are_refused():
+    repo = MemoryMagicLinkRepository(MemoryStore())
+    directory = FakeDirectory()
+    links = make_links(repo, directory=directory)
+    token = links.token(links.request("ada@example.com"))
+
+    del directory.users["u1"]
+
+    with pytest.raises(MagicLinkError):
+        links.redeem(token)
+
+def test_addresses_are_throttled_whether_or_not_they_have_an_account():
+    """Tests that the limit is per address, and applies before the lookup so it reveals nothing."""
+    links = make_links(MemoryMagicLinkRepository(MemoryStore()), per_hour=2)
+    for email in ("ada@example.com", "ADA@example.com", "nobody@example.com", "nobody@example.com"):
+        links.request(email)
+
+    with pytest.raises(MagicLinkThrottled) as raised:
+        links.request("Ada@Example.com")
+    with pytest.raises(MagicLinkThrottled):
+        links.request("nobody@example.com")
+    assert raised.value.retry_after > 0
+
+# --- Endpoint Test Cases ---
+
+class NoIdentityVerifier(TokenVerifier):
+    def verify(self, token):
+        raise InvalidTokenError("No ID tokens here")
+
+class RecordingQueue:
+    def __init__(self):
+        self.enqueued = []
+
+    def enqueue(self, name, payload, delay_seconds=0, task_id=None):
+        self.enqueued.append((name, payload))
+
+link_repo = MemoryMagicLinkRepository(MemoryStore())
+session_repo = MemorySessionRepository(MemoryStore())
+endpoint_links = make_links(link_repo, per_hour=100)
+endpoint_manager = SessionManager(NoIdentityVerifier(), SIGNING_KEY, repository_factory=lambda: session_repo)
+queue = RecordingQueue()
+
+lockout_repo = MemoryLockoutRepository(MemoryStore())
+
+app = FastAPI()
+app.include_router(magic_links.router, prefix="/api/v1/auth/magic-link")
+app.dependency_overrides[magic_links.magic_links] = lambda: endpoint_links
+app.dependency_overrides[sessions.session_manager] = lambda: endpoint_manager
+app.dependency_overrides[get_task_queue] = lambda: queue
+app.dependency_overrides[sessions.login_guard] = lambda: LoginGuard(repository_factory=lambda: lockout_repo)
+
+client = TestClient(app)
+
+def test_sign_in_by_link_over_http():
+    requested = client.post("/api/v1/auth/magic-link", json={"email": "ada@example.com"})
+    unknown = client.post("/api/v1/auth/magic-link", json={"email": "nobody@example.com"})
+    assert requested.status_code == unknown.status_code == 202
+    assert requested.content == unknown.content
+    name, payload = queue.enqueued[-1]
+    assert name == MAGIC_LINK_EMAIL_TASK
+    token = endpoint_links.token(link_repo.get(payload["linkId"]))
+
+    exchanged = client.post("/api/v1/auth/magic-link/exchange", json={"token": token, "deviceLabel": "Tablet"})
+    assert exchanged.status_code == 201
+    body = exchanged.json()
+    assert body["session"]["device_label"] == "Tablet"
+    assert session_repo.get(body["session"]["session_id"]).claims == {"roles": ["patient"], "patientId": "patient-u1"}
+
+    assert client.post("/api/v1/auth/magic-link/exchange", json={"token": token}).status_code == 401
+
+def test_throttled_requests_say_when_to_retry():
+    throttled_links = make_links(MemoryMagicLinkRepository(MemoryStore()), per_hour=1)
+    app.dependency_overrides[magic_links.magic_links] = lambda: throttled_links
+    try:
+        client.post("/api/v1/auth/magic-link", json={"email": "ada@example.com"})
+        response = client.post("/api/v1/auth/magic-link", json={"email": "ada@example.com"})
+    finally:
+        app.dependency_overrides[magic_links.magic_links] = lambda: endpoint_links
+
+    assert response.status_code == 429
+    assert int(response.headers["Retry-After"]) > 0
@@ -0,0 +1,230 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from firebase_admin import firestore
+from app.api.v1 import schemas
+from app.api.v1.deps import get_medication_repository, get_patient_repository, get_practitioner_repository, get_refill_request_repository
+from app.api.v1.endpoints import medications
+from app.dependencies.auth import get_current_user
+from app.repositories.medications import (
+    FirestoreMedicationRepository,
+    FirestoreRefillRequestRepository,
+    MedicationRepository,
+    RefillRequestRepository,
+)
+from app.repositories.patients import PatientRepository
+from app.repositories.practitioners import PractitionerRepository
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(medications.router, prefix="/api/v1/patients", tags=["Medications"])
+app.dependency_overrides[get_current_user] = lambda: {"uid": "user-uid-123"}
+client = TestClient(app)
+
+FAKE_PATIENT_ID = "patient-1"
+FAKE_MEDICATION_ID = "med-1"
+FAKE_REFILL_REQUEST_ID = "refill-1"
+MEDICATION_URL = f"/api/v1/patients/{FAKE_PATIENT_ID}/medications/{FAKE_MEDICATION_ID}"
+REFILL_URL = f"{MEDICATION_URL}/refill-requests/{FAKE_REFILL_REQUEST_ID}"
+
+def make_medication(status="active", refills_allowed=3, refills_used=0):
+    return schemas.Medication.model_validate({
+        "medicationId": FAKE_MEDICATION_ID,
+        "patientId": FAKE_PATIENT_ID,
+        "name": "Acetazolamide 250mg",
+        "status": status,
+        "refillsAllowed": refills_allowed,
+        "refillsUsed": refills_used,
+        "createdAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+    })
+
+def make_refill_request(status="requested", assigned_practitioner_id=None):
+    return schemas.RefillRequest.model_validate({
+        "refillRequestId": FAKE_REFILL_REQUEST_ID,
+        "patientId": FAKE_PATIENT_ID,
+        "medicationId": FAKE_MEDICATION_ID,
+        "status": status,
+        "requestedBy": "patient-uid",
+        "assignedPractitionerId": assigned_practitioner_id,
+        "createdAt": datetime(2024, 1, 2, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 1, 2, tzinfo=timezone.utc),
+    })
+
+@pytest.fixture
+def repos():
+    """Overrides the medication, refill request, patient and practitioner repositories with mocks."""
+    mocks = {
+        "medications": MagicMock(spec=MedicationRepository),
+        "refill_requests": MagicMock(spec=RefillRequestRepository),
+        "patients": MagicMock(spec=PatientRepository),
+        "practitioners": MagicMock(spec=PractitionerRepository),
+    }
+    mocks["medications"].get.return_value = make_medication()
+    app.dependency_overrides[get_medication_repository] = lambda: mocks["medications"]
+    app.dependency_overrides[get_refill_request_repository] = lambda: mocks["refill_requests"]
+    app.dependency_overrides[get_patient_repository] = lambda: mocks["patients"]
+    app.dependency_overrides[get_practitioner_repository] = lambda: mocks["practitioners"]
+    yield mocks
+    for dep in (get_medication_repository, get_refill_request_repository, get_patient_repository, get_practitioner_repository):
+        app.dependency_overrides.pop(dep, None)
+
+# --- Endpoint Test Cases ---
+
+def test_create_medication_success(repos):
+    """Tests adding a medication to an existing patient's list."""
+    repos["medications"].create.return_value = make_medication()
+
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/medications", json={
+        "name": "Acetazolamide 250mg", "dosage": "1 tablet twice daily", "refills_allowed": 3,
+    })
+
+    assert response.status_code == 201
+    assert response.json()["medication_id"] == FAKE_MEDICATION_ID
+    assert repos["medications"].create.call_args[0][0] == FAKE_PATIENT_ID
+
+def test_create_medication_unknown_patient(repos):
+    """Tests 404 when the patient does not exist."""
+    repos["patients"].get.return_value = None
+
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/medications", json={"name": "Acetazolamide"})
+
+    assert response.status_code == 404
+    repos["medications"].create.assert_not_called()
+
+def test_get_medication_of_other_patient_returns_404(repos):
+    """Tests that a medication is only visible under its own patient."""
+    response = client.get(f"/api/v1/patients/someone-else/medications/{FAKE_MEDICATION_ID}")
+
+    assert response.status_code == 404
+
+def test_create_refill_request_success(repos):
+    """Tests requesting a refill of an active medication with refills remaining."""
+    repos["refill_requests"].list.return_value = []
+    repos["refill_requests"].create.return_value = make_refill_request()
+
+    response = client.post(f"{MEDICATION_URL}/refill-requests", json={"note": "Running low"})
+
+    assert response.status_code == 201
+    assert response.json()["status"] == "requested"
+    assert repos["refill_requests"].create.call_args.kwargs["requested_by"] == "user-uid-123"
+
+def test_create_refill_request_rejected_when_open_request_exists(repos):
+    """Tests 409 when the medication already has a request awaiting a decision."""
+    repos["refill_requests"].list.return_value = [make_refill_request(status="under-review")]
+
+    response = client.post(f"{MEDICATION_URL}/refill-requests", json={})
+
+    assert response.status_code == 409
+    repos["refill_requests"].create.assert_not_called()
+
+def test_create_refill_request_rejected_without_refills(repos):
+    """Tests 409 when every allowed refill has been used."""
+    repos["medications"].get.return_value = make_medication(refills_allowed=2, refills_used=2)
+
+    response = client.post(f"{MEDICATION_URL}/refill-requests", json={})
+
+    assert response.status_code == 409
+    repos["refill_requests"].create.assert_not_called()
+
+def test_create_refill_request_rejected_for_stopped_medication(repos):
+    """Tests 409 when the medication is no longer active."""
+    repos["medications"].get.return_value = make_medication(status="stopped")
+
+    response = client.post(f"{MEDICATION_URL}/refill-requests", json={})
+
+    assert response.status_code == 409
+
+def test_assign_refill_request(repos):
+    """Tests assigning a reviewer to an open request."""
+    repos["refill_requests"].get.return_value = make_refill_request()
+    repos["refill_requests"].update.return_value = make_refill_request(assigned_practitioner_id="pr-1")
+
+    response = client.post(f"{REFILL_URL}/assign", json={"practitioner_id": "pr-1"})
+
+    assert response.status_code == 200
+    repos["refill_requests"].update.assert_called_once_with(FAKE_REFILL_REQUEST_ID, {"assignedPractitionerId": "pr-1"})
+
+def test_review_requires_assigned_practitioner(repos):
+    """Tests 409 when review starts before a practitioner is assigned."""
+    repos["refill_requests"].get.return_value = make_refill_request()
+
+    response = client.post(f"{REFILL_URL}/status", json={"status": "under-review"})
+
+    assert response.status_code == 409
+    repos["refill_requests"].update.assert_not_called()
+
+def test_approve_refill_request_records_refill(repos):
+    """Tests that approval records the decision and counts a refill against the medication."""
+    repos["refill_requests"].get.return_value = make_refill_request(status="under-review", assigned_practitioner_id="pr-1")
+    repos["refill_requests"].update.return_value = make_refill_request(status="approved", assigned_practitioner_id="pr-1")
+
+    response = client.post(f"{REFILL_URL}/status", json={"status": "approved"})
+
+    assert response.status_code == 200
+    changes = repos["refill_requests"].update.call_args[0][1]
+    assert changes["status"] == "approved"
+    assert changes["decidedBy"] == "user-uid-123"
+    repos["medications"].record_refill.assert_called_once_with(FAKE_MEDICATION_ID)
+
+def test_deny_refill_request_does_not_record_refill(repos):
+    """Tests that a denial keeps the medication's refill count unchanged."""
+    repos["refill_requests"].get.return_value = make_refill_request(status="under-review", assigned_practitioner_id="pr-1")
+    repos["refill_requests"].update.return_value = make_refill_request(status="denied", assigned_practitioner_id="pr-1")
+
+    response = client.post(f"{REFILL_URL}/status", json={"status": "denied", "decision_note": "Please book a review"})
+
+    assert response.status_code == 200
+    assert repos["refill_requests"].update.call_args[0][1]["decisionNote"] == "Please book a review"
+    repos["medications"].record_refill.assert_not_called()
+
+def test_approve_without_review_returns_409(repos):
+    """Tests that requests must go through review before a decision."""
+    repos["refill_requests"].get.return_value = make_refill_request(assigned_practitioner_id="pr-1")
+
+    response = client.post(f"{REFILL_URL}/status", json={"status": "approved"})
+
+    assert response.status_code == 409
+    repos["medications"].record_refill.assert_not_called()
+
+# --- Firestore Repository Test Cases ---
+
+def test_firestore_create_refill_request_links_medication():
+    """Tests that new requests are stored against the medication and its patient."""
+    mock_db = MagicMock()
+    request_ref = MagicMock()
+    snapshot = MagicMock()
+    snapshot.id = FAKE_REFILL_REQUEST_ID
+    snapshot.to_dict.return_value = make_refill_request().model_dump(by_alias=True, exclude={"refill_request_id"})
+    request_ref.get.return_value = snapshot
+    mock_db.collection.return_value.add.return_value = (None, request_ref)
+
+    repo = FirestoreRefillRequestRepository(mock_db)
+    repo.create(make_medication(), schemas.RefillRequestCreate(note="Running low"), requested_by="patient-uid")
+
+    data = mock_db.collection.return_value.add.call_args[0][0]
+    assert data["medicationId"] == FAKE_MEDICATION_ID
+    assert data["patientId"] == FAKE_PATIENT_ID
+    assert data["status"] == "requested"
+    assert data["requestedBy"] == "patient-uid"
+
+def test_firestore_record_refill_increments_server_side():
+    """Tests that used refills are incremented atomically rather than read-modify-write."""
+    mock_db = MagicMock()
+    medication_ref = mock_db.collection.return_value.document.return_value
+    snapshot = MagicMock()
+    snapshot.exists = True
+    snapshot.id = FAKE_MEDICATION_ID
+    snapshot.to_dict.return_value = make_medication().model_dump(by_alias=True, exclude={"medication_id"})
+    medication_ref.get.return_value = snapshot
+
+    repo = FirestoreMedicationRepository(mock_db)
+    repo.record_refill(FAKE_MEDICATION_ID)
+
+    changes = medication_ref.update.call_args[0][0]
+    assert isinstance(changes["refillsUsed"], firestore.Increment)
@@ -0,0 +1,242 @@
+import pytest
+from fastapi.testclient import TestClient
+from datetime import datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import (
+    get_appointment_repository,
+    get_encounter_repository,
+    get_observation_repository,
+    get_patient_repository,
+    get_practitioner_repository,
+)
+from app.api.v1.endpoints import encounters
+from app.dependencies.auth import get_current_user
+from app.repositories.base import ConflictError, NotFoundError, StaleCursorError, VersionConflictError, expected_version, including_deleted
+from app.repositories.memory import (
+    MemoryAppointmentRepository,
+    MemoryConsentDocumentRepository,
+    MemoryDeviceRepository,
+    MemoryEncounterRepository,
+    MemoryObservationRepository,
+    MemoryPatientRepository,
+    MemoryPractitionerRepository,
+    MemoryStore,
+)
+from app.tenancy.context import acting_for
+
+# --- Test Setup ---
+
+def make_patient_in(mrn="MRN-1", family_name="Lee"):
+    return schemas.PatientCreate(givenName="Ann", familyName=family_name, dob="1980-01-01", mrn=mrn)
+
+def make_encounter_in(patient_id, start, participants=()):
+    return schemas.EncounterCreate(
+        patientId=patient_id,
+        visitType="ambulatory",
+        start=start,
+        participants=[{"practitionerId": p} for p in participants],
+    )
+
+# --- Repository Test Cases ---
+
+def test_patient_round_trip_and_unique_mrn():
+    """Tests create/get/update and that duplicate MRNs are rejected."""
+    repo = MemoryPatientRepository(MemoryStore())
+
+    patient = repo.create(make_patient_in())
+    updated = repo.update(patient.patient_id, schemas.PatientUpdate(givenName="Anna"))
+
+    assert repo.get(patient.patient_id).mrn == "MRN-1"
+    assert updated.given_name == "Anna"
+    assert updated.updated_at >= patient.created_at
+    with pytest.raises(ConflictError):
+        repo.create(make_patient_in())
+
+def test_records_are_isolated_from_callers():
+    """Tests that mutating a returned model does not change the stored record."""
+    repo = MemoryPatientRepository(MemoryStore())
+    patient = repo.create(make_patient_in())
+
+    patient.family_name = "Changed"
+
+    assert repo.get(patient.patient_id).family_name == "Lee"
+
+def test_get_many_returns_existing_records_by_id():
+    """Tests that a batched read returns the records that exist, keyed by ID."""
+    repo = MemoryPatientRepository(MemoryStore())
+    first, second = repo.create(make_patient_in("MRN-1")), repo.create(make_patient_in("MRN-2"))
+
+    found = repo.get_many([second.patient_id, "missing", first.patient_id])
+
+    assert set(found) == {first.patient_id, second.patient_id}
+    assert found[second.patient_id].mrn == "MRN-2"
+
+def test_queries_filter_order_and_limit_like_postgres():
+    """Tests array-contains and range filters with descending time order."""
+    repo = MemoryEncounterRepository(MemoryStore())
+    for day in (1, 3, 2):
+        repo.create(make_encounter_in("p-1", datetime(2024, 5, day, 9, 0, tzinfo=timezone.utc), participants=["prac-1"]))
+    repo.create(make_encounter_in("p-2", datetime(2024, 5, 4, 9, 0, tzinfo=timezone.utc)))
+
+    results = repo.list(practitioner_id="prac-1", start_from=datetime(2024, 5, 2, tzinfo=timezone.utc), limit=5)
+
+    assert [e.start.day for e in results] == [3, 2]
+
+def test_list_continues_after_a_record_without_gaps_or_repeats():
+    """Tests keyset continuation across records sharing the ordering value, past deleted anchors, and stale ones."""
+    repo = MemoryPatientRepository(MemoryStore())
+    for index, family_name in enumerate(["Lee", "Kim", "Lee", "Ng", "Lee"]):
+        repo.create(make_patient_in(mrn=f"MRN-{index}", family_name=family_name))
+
+    pages, after = [], None
+    while True:
+        page = repo.list(limit=2, after=after)
+        if not page:
+            break
+        pages.append(page)
+        after = page[-1].patient_id
+
+    names = [p.family_name for page in pages for p in page]
+    assert names == ["Kim", "Lee", "Lee", "Lee", "Ng"]
+    assert len({p.patient_id for page in pages for p in page}) == 5
+    anchor = pages[0][-1].patient_id
+    repo.delete(anchor, deleted_by="staff-1")
+    assert [p.family_name for p in repo.list(limit=2, after=anchor)] == ["Lee", "Lee"]
+    del repo.store.table("patients")[anchor]
+    with pytest.raises(StaleCursorError):
+        repo.list(limit=2, after=anchor)
+
+def test_transform_operations_and_not_found():
+    """Tests observation link/unlink and NotFoundError for unknown records."""
+    repo = MemoryEncounterRepository(MemoryStore())
+    encounter = repo.create(make_encounter_in("p-1", datetime(2024, 5, 1, 9, 0, tzinfo=timezone.utc)))
+
+    repo.link_observation(encounter.encounter_id, "obs-1")
+    repo.link_observation(encounter.encounter_id, "obs-1")
+    assert repo.get(encounter.encounter_id).observation_ids == ["obs-1"]
+
+    with pytest.raises(NotFoundError):
+        repo.unlink_observation(encounter.encounter_id, "obs-2")
+    with pytest.raises(NotFoundError):
+        repo.update("missing", schemas.EncounterUpdate(reason="Follow-up"))
+
+def test_writes_increment_versions_and_stale_ones_are_refused():
+    """Tests that versions count changes, and a write expecting an old version changes nothing."""
+    repo = MemoryPatientRepository(MemoryStore())
+    patient = repo.create(make_patient_in())
+    assert patient.version == 1
+    assert repo.update(patient.patient_id, schemas.PatientUpdate(givenName="Anna")).version == 2
+
+    with pytest.raises(VersionConflictError):
+        with expected_version({patient.patient_id}, 1):
+            repo.update(patient.patient_id, schemas.PatientUpdate(givenName="Stale"))
+    assert repo.get(patient.patient_id).given_name == "Anna"
+
+    with expected_version({patient.patient_id}, 2):
+        repo.update(patient.patient_id, schemas.PatientUpdate(givenName="Ann"))
+        assert repo.update(patient.patient_id, schemas.PatientUpdate(familyName="Lim")).version == 4
+    with pytest.raises(VersionConflictError):
+        with expected_version({patient.patient_id}, 2):
+            repo.delete(patient.patient_id, deleted_by="staff-1")
+    assert repo.get(patient.patient_id) is not None
+
+def test_deleted_records_are_hidden_until_restored():
+    """Tests that deleted patients disappear from reads and lists, keep their MRN, and come back when restored."""
+    repo = MemoryPatientRepository(MemoryStore())
+    patient = repo.create(make_patient_in())
+    repo.create(make_patient_in(mrn="MRN-2", family_name="Ng"))
+    repo.delete(patient.patient_id, deleted_by="staff-1")
+
+    assert repo.get(patient.patient_id) is None
+    assert repo.get_by_mrn("MRN-1") is None
+    assert [p.mrn for p in repo.list()] == ["MRN-2"]
+    with pytest.raises(NotFoundError):
+        repo.delete(patient.patient_id, deleted_by="staff-1")
+    with pytest.raises(NotFoundError):
+        repo.update(patient.patient_id, schemas.PatientUpdate(givenName="Anna"))
+    with pytest.raises(ConflictError):
+        repo.create(make_patient_in())
+
+    with including_deleted():
+        deleted = repo.get(patient.patient_id)
+        assert [p.mrn for p in repo.list()] == ["MRN-1", "MRN-2"]
+    assert (deleted.deleted_by, deleted.deleted_at is not None) == ("staff-1", True)
+
+    restored = repo.restore(patient.patient_id)
+    assert (restored.deleted_at, restored.deleted_by) == (None, None)
+    assert repo.get(patient.patient_id).mrn == "MRN-1"
+    with pytest.raises(NotFoundError):
+        repo.restore("missing")
+
+def test_records_are_scoped_to_the_current_organization():
+    """Tests that records written for one organization are invisible to another, which may reuse the MRN."""
+    repo = MemoryPatientRepository(MemoryStore())
+    legacy = repo.create(make_patient_in(mrn="MRN-0"))
+    with acting_for("clinic-a"):
+        patient = repo.create(make_patient_in())
+
+    with acting_for("clinic-b"):
+        assert repo.get(patient.patient_id) is None
+        assert repo.list() == []
+        with pytest.raises(NotFoundError):
+            repo.update(patient.patient_id, schemas.PatientUpdate(givenName="Anna"))
+        with pytest.raises(StaleCursorError):
+            repo.list(after=patient.patient_id)
+        repo.create(make_patient_in())
+    assert len(repo.list()) == 3
+
+    assert repo.assign_tenant("clinic-a") == 1
+    with acting_for("clinic-a"):
+        assert {p.mrn for p in repo.list()} == {"MRN-0", "MRN-1"}
+        assert repo.get(legacy.patient_id) is not None
+
+def test_consent_document_versions_increment_per_scope():
+    """Tests that each scope has its own version sequence, listed newest first."""
+    repo = MemoryConsentDocumentRepository(MemoryStore())
+    for scope in ("data-sharing", "data-sharing", "research"):
+        repo.create(schemas.ConsentDocumentCreate(scope=scope, title="Consent", body="I agree."), published_by="admin")
+
+    assert [d.version for d in repo.list("data-sharing")] == [2, 1]
+    assert [d.version for d in repo.list("research")] == [1]
+
+def test_device_telemetry_sequence_only_moves_forward():
+    """Tests the device high-water mark for a device added with put()."""
+    repo = MemoryDeviceRepository(MemoryStore())
+    repo.put("owner-1", schemas.Device.model_validate({
+        "deviceId": "dev-1", "serialNumber": "SN1", "deviceNumber": "001", "deviceName": "AirSense 10",
+        "addedDate": datetime(2024, 5, 1, tzinfo=timezone.utc),
+    }))
+
+    assert repo.advance_telemetry_sequence("owner-1", "dev-1", 5) == 5
+    assert repo.advance_telemetry_sequence("owner-1", "dev-1", 3) == 5
+    assert repo.get_telemetry_sequence("owner-1", "dev-1") == 5
+    with pytest.raises(NotFoundError):
+        repo.advance_telemetry_sequence("owner-1", "unknown", 1)
+
+# --- Endpoint Test Cases ---
+
+def test_handlers_run_against_memory_repositories():
+    """Tests an endpoint end to end with memory repositories instead of mocks."""
+    store = MemoryStore()
+    patients = MemoryPatientRepository(store)
+    app = FastAPI()
+    app.include_router(encounters.router, prefix="/api/v1/encounters")
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "staff-uid-123"}
+    app.dependency_overrides[get_patient_repository] = lambda: patients
+    app.dependency_overrides[get_encounter_repository] = lambda: MemoryEncounterRepository(store)
+    app.dependency_overrides[get_practitioner_repository] = lambda: MemoryPractitionerRepository(store)
+    app.dependency_overrides[get_observation_repository] = lambda: MemoryObservationRepository(store)
+    app.dependency_overrides[get_appointment_repository] = lambda: MemoryAppointmentRepository(store)
+    client = TestClient(app)
+    patient = patients.create(make_patient_in())
+
+    created = client.post("/api/v1/encounters", json={
+        "patientId": patient.patient_id, "visitType": "virtual", "start": "2024-05-01T09:00:00Z",
+    })
+    listed = client.get("/api/v1/encounters", params={"patientId": patient.patient_id})
+
+    assert created.status_code == 201
+    assert [e["encounter_id"] for e in listed.json()["items"]] == [created.json()["encounter_id"]]
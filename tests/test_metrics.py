@@ -0,0 +1,52 @@
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api import metrics as metrics_endpoints
+from app.core.metrics import HTTP_REQUESTS_TOTAL
+from app.middleware.metrics import MetricsMiddleware
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.add_middleware(MetricsMiddleware)
+app.include_router(metrics_endpoints.router)
+
+@app.get("/patients/{patientId}")
+def get_patient(patientId: str):
+    return {"patientId": patientId}
+
+client = TestClient(app)
+
+def requests_total(method, route, status):
+    return HTTP_REQUESTS_TOTAL.labels(method=method, route=route, status=status)._value.get()
+
+# --- Test Cases ---
+
+def test_requests_are_counted_by_route_template():
+    """Tests that requests to different IDs are aggregated under one route label."""
+    before = requests_total("GET", "/patients/{patientId}", "200")
+
+    client.get("/patients/a")
+    client.get("/patients/b")
+
+    assert requests_total("GET", "/patients/{patientId}", "200") == before + 2
+
+def test_unmatched_routes_share_one_label():
+    """Tests that 404s for arbitrary paths do not create new label values."""
+    before = requests_total("GET", "<unmatched>", "404")
+
+    client.get("/does-not-exist/123")
+
+    assert requests_total("GET", "<unmatched>", "404") == before + 1
+
+def test_metrics_endpoint_exposes_prometheus_text():
+    """Tests that /metrics renders the core HTTP metrics."""
+    client.get("/patients/a")
+
+    response = client.get("/metrics")
+
+    assert response.status_code == 200
+    assert response.headers["content-type"].startswith("text/plain")
+    assert "http_requests_total" in response.text
+    assert "http_request_duration_seconds_bucket" in response.text
+    assert "http_requests_in_flight" in response.text
@@ -0,0 +1,237 @@
+import jwt
+import pytest
+import time
+from fastapi import FastAPI, HTTPException
+from fastapi.testclient import TestClient
+
+from app.api.v1.deps import get_session_repository
+from app.api.v1.endpoints import mfa, sessions
+from app.auth.lockout import LoginGuard
+from app.auth.mfa import MfaError, MfaManager, MfaStateError, MfaThrottled, MfaUnavailable, totp
+from app.auth.sessions import SessionManager, SessionTokenVerifier
+from app.auth.verifier import InvalidTokenError, TokenVerifier
+from app.authz.mfa import MFA_CLAIM, check_mfa, check_step_up, mfa_verified_at
+from app.core.config import get_settings
+from app.notifications.sms import SmsSender
+from app.ratelimit.buckets import MemoryTokenBuckets
+from app.repositories.memory import MemoryLockoutRepository, MemoryMfaEnrollmentRepository, MemorySessionRepository, MemoryStore
+
+# --- Test Setup ---
+
+SIGNING_KEY = "k" * 32
+# RFC 6238's SHA-1 test secret ("12345678901234567890") in base32.
+RFC_SECRET = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+class RecordingSmsSender(SmsSender):
+    name = "fake"
+    sender = "+15550000000"
+
+    def __init__(self):
+        self.sent = []
+
+    def send(self, message):
+        self.sent.append(message)
+        return "SM1"
+
+    def last_code(self):
+        return self.sent[-1].body.split("code is ")[1][:6]
+
+def make_manager(repo, sms=None, now=None, max_attempts=5):
+    now = now or [time.time()]
+    return MfaManager(MemoryTokenBuckets(), sms, repository_factory=lambda: repo, max_attempts=max_attempts, clock=lambda: now[0])
+
+@pytest.fixture
+def mfa_enforced(monkeypatch):
+    monkeypatch.setattr(get_settings(), "session_signing_key", SIGNING_KEY)
+    monkeypatch.setattr(get_settings(), "mfa_required_roles", "clinician,admin")
+
+# --- TOTP Test Cases ---
+
+def test_totp_matches_the_rfc_6238_test_vectors():
+    assert totp(RFC_SECRET, 59 // 30) == "287082"
+    assert totp(RFC_SECRET, 1111111109 // 30) == "081804"
+    assert totp(RFC_SECRET, 1234567890 // 30) == "005924"
+
+def test_authenticator_app_enrollment_and_verification():
+    repo = MemoryMfaEnrollmentRepository(MemoryStore())
+    now = [1_700_000_000.0]
+    manager = make_manager(repo, now=now)
+
+    enrollment = manager.begin_totp("u1", "ada@example.com")
+    assert enrollment.otpauth_uri.startswith("otpauth://totp/MegaCare%3Aada%40example.com?secret=")
+    recovery_codes = manager.confirm_totp("u1", totp(enrollment.secret, int(now[0]) // 30))
+
+    assert len(recovery_codes) == 10
+    assert repo.get("u1").totp_confirmed_at is not None
+    now[0] += 30
+    manager.verify("u1", "totp", totp(enrollment.secret, int(now[0]) // 30))
+    with pytest.raises(MfaStateError):
+        manager.begin_totp("u1", "ada@example.com")
+
+def test_authenticator_codes_work_once_and_tolerate_drift():
+    repo = MemoryMfaEnrollmentRepository(MemoryStore())
+    now = [1_700_000_000.0]
+    manager = make_manager(repo, now=now)
+    secret = manager.begin_totp("u1", "u1").secret
+    step = int(now[0]) // 30
+    manager.confirm_totp("u1", totp(secret, step))
+
+    with pytest.raises(MfaError):
+        manager.verify("u1", "totp", totp(secret, step))
+    manager.verify("u1", "totp", totp(secret, step + 1))
+    with pytest.raises(MfaError):
+        manager.verify("u1", "totp", totp(secret, step + 3))
+
+def test_recovery_codes_work_once():
+    repo = MemoryMfaEnrollmentRepository(MemoryStore())
+    now = [1_700_000_000.0]
+    manager = make_manager(repo, now=now)
+    secret = manager.begin_totp("u1", "u1").secret
+    codes = manager.confirm_totp("u1", totp(secret, int(now[0]) // 30))
+
+    manager.verify("u1", "recovery", codes[0].upper())
+
+    with pytest.raises(MfaError):
+        manager.verify("u1", "recovery", codes[0])
+    assert len(repo.get("u1").recovery_code_hashes) == 9
+
+def test_text_message_enrollment_and_challenge():
+    repo = MemoryMfaEnrollmentRepository(MemoryStore())
+    sms = RecordingSmsSender()
+    manager = make_manager(repo, sms=sms)
+
+    manager.begin_sms("u1", "+15551234567")
+    assert sms.sent[-1].to == "+15551234567"
+    assert manager.confirm_sms("u1", sms.last_code())
+
+    manager.challenge("u1")
+    code = sms.last_code()
+    manager.verify("u1", "sms", code)
+    with pytest.raises(MfaError):
+        manager.verify("u1", "sms", code)
+
+def test_text_message_codes_expire():
+    repo = MemoryMfaEnrollmentRepository(MemoryStore())
+    sms = RecordingSmsSender()
+    now = [time.time()]
+    manager = make_manager(repo, sms=sms, now=now)
+    manager.begin_sms("u1", "+15551234567")
+
+    now[0] += 301
+
+    with pytest.raises(MfaError):
+        manager.confirm_sms("u1", sms.last_code())
+
+def test_text_messages_need_a_provider():
+    manager = make_manager(MemoryMfaEnrollmentRepository(MemoryStore()))
+
+    with pytest.raises(MfaUnavailable):
+        manager.begin_sms("u1", "+15551234567")
+
+def test_code_guessing_is_throttled():
+    repo = MemoryMfaEnrollmentRepository(MemoryStore())
+    manager = make_manager(repo, max_attempts=3)
+    manager.begin_totp("u1", "u1")
+
+    for _ in range(3):
+        with pytest.raises(MfaError):
+            manager.confirm_totp("u1", "000000")
+    with pytest.raises(MfaThrottled):
+        manager.confirm_totp("u1", "000000")
+
+# --- Authz Test Cases ---
+
+def test_clinicians_need_a_second_factor_once_sessions_are_on(monkeypatch):
+    clinician = {"uid": "c1", "roles": ["clinician"]}
+
+    check_mfa(clinician)
+
+    monkeypatch.setattr(get_settings(), "session_signing_key", SIGNING_KEY)
+    with pytest.raises(HTTPException) as refused:
+        check_mfa(clinician)
+    assert refused.value.status_code == 403
+    assert 'error="insufficient_user_authentication"' in refused.value.headers["WWW-Authenticate"]
+    check_mfa({**clinician, MFA_CLAIM: int(time.time())})
+    check_mfa({"uid": "p1", "roles": ["patient"]})
+    check_mfa({"uid": "key-1", "authMethod": "api_key", "scopes": ["*"], "roles": ["admin"]})
+
+def test_provider_mfa_counts_as_of_sign_in():
+    assert mfa_verified_at({"auth_time": 100, "firebase": {"sign_in_second_factor": "phone"}}) == 100
+    assert mfa_verified_at({"auth_time": 100, "amr": ["pwd", "mfa"]}) == 100
+    assert mfa_verified_at({"auth_time": 100, "firebase": {"sign_in_provider": "password"}}) is None
+
+def test_step_up_needs_a_recent_verification(mfa_enforced):
+    admin = {"uid": "a1", "roles": ["admin"]}
+
+    check_step_up({**admin, MFA_CLAIM: int(time.time()) - 10}, "start a bulk export")
+    with pytest.raises(HTTPException):
+        check_step_up({**admin, MFA_CLAIM: int(time.time()) - 3600}, "start a bulk export")
+    check_step_up({"uid": "p1", "roles": ["patient"]}, "start a bulk export")
+    with pytest.raises(HTTPException):
+        check_step_up({"uid": "p1", "roles": ["patient"]}, "remove your second factors", everyone=True)
+
+# --- Endpoint Test Cases ---
+
+class FakeIdentityVerifier(TokenVerifier):
+    def verify(self, token):
+        if not token.startswith("id-token-"):
+            raise InvalidTokenError("Not an ID token")
+        return {"uid": token[len("id-token-"):], "roles": ["clinician"]}
+
+mfa_repo = MemoryMfaEnrollmentRepository(MemoryStore())
+session_repo = MemorySessionRepository(MemoryStore())
+endpoint_mfa = MfaManager(MemoryTokenBuckets(), None, repository_factory=lambda: mfa_repo)
+endpoint_sessions = SessionManager(FakeIdentityVerifier(), SIGNING_KEY, repository_factory=lambda: session_repo)
+endpoint_verifier = SessionTokenVerifier(FakeIdentityVerifier(), SIGNING_KEY, repository_factory=lambda: session_repo)
+
+lockout_repo = MemoryLockoutRepository(MemoryStore())
+
+app = FastAPI()
+app.include_router(sessions.router, prefix="/api/v1/auth/sessions")
+app.include_router(mfa.router, prefix="/api/v1/auth/mfa")
+app.dependency_overrides[sessions.session_manager] = lambda: endpoint_sessions
+app.dependency_overrides[mfa.mfa_manager] = lambda: endpoint_mfa
+app.dependency_overrides[get_session_repository] = lambda: session_repo
+app.dependency_overrides[sessions.login_guard] = lambda: LoginGuard(repository_factory=lambda: lockout_repo)
+
+client = TestClient(app)
+
+@pytest.fixture(autouse=True)
+def session_tokens(monkeypatch):
+    from app.dependencies import auth
+    monkeypatch.setattr(auth, "get_token_verifier", lambda: endpoint_verifier)
+
+def test_staff_enroll_and_verify_over_http(mfa_enforced):
+    started = client.post("/api/v1/auth/sessions", json={"idToken": "id-token-c9"}).json()
+    headers = {"Authorization": f"Bearer {started['access_token']}"}
+    assert client.get("/api/v1/auth/mfa", headers=headers).json()["required"] is True
+
+    secret = client.post("/api/v1/auth/mfa/totp", headers=headers).json()["secret"]
+    confirmed = client.post("/api/v1/auth/mfa/totp/confirm", json={"code": totp(secret, int(time.time()) // 30)}, headers=headers)
+    assert confirmed.status_code == 200
+    assert len(confirmed.json()["recovery_codes"]) == 10
+
+    verified = client.post("/api/v1/auth/mfa/verify", json={"method": "recovery", "code": confirmed.json()["recovery_codes"][0]}, headers=headers)
+    assert verified.status_code == 200
+    claims = jwt.decode(verified.json()["access_token"], options={"verify_signature": False})
+    assert abs(claims[MFA_CLAIM] - time.time()) < 60
+    refreshed = client.post("/api/v1/auth/sessions/refresh", json={"refreshToken": started["refresh_token"]}).json()
+    assert MFA_CLAIM in jwt.decode(refreshed["access_token"], options={"verify_signature": False})
+
+def test_adding_a_factor_takes_a_recent_verification(mfa_enforced):
+    started = client.post("/api/v1/auth/sessions", json={"idToken": "id-token-c8"}).json()
+    headers = {"Authorization": f"Bearer {started['access_token']}"}
+    secret = client.post("/api/v1/auth/mfa/totp", headers=headers).json()["secret"]
+    client.post("/api/v1/auth/mfa/totp/confirm", json={"code": totp(secret, int(time.time()) // 30)}, headers=headers)
+
+    response = client.post("/api/v1/auth/mfa/sms", json={"phoneNumber": "+15551234567"}, headers=headers)
+
+    assert response.status_code == 403
+
+def test_verification_needs_a_session(monkeypatch):
+    from app.dependencies import auth
+    monkeypatch.setattr(auth, "get_token_verifier", lambda: FakeIdentityVerifier())
+
+    response = client.post("/api/v1/auth/mfa/verify", json={"method": "totp", "code": "123456"}, headers={"Authorization": "Bearer id-token-c7"})
+
+    assert response.status_code == 400
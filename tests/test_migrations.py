@@ -0,0 +1,83 @@
+import pytest
+from unittest.mock import MagicMock
+
+from app.db.migrate import Migration, MigrationError, load_migrations, migrate_down, migrate_up
+
+# --- Test Setup ---
+
+class FakeConnection:
+    """Records executed SQL and keeps the schema_migrations row in memory."""
+
+    def __init__(self, version=None, dirty=False):
+        self.row = {"version": version, "dirty": dirty} if version is not None else None
+        self.statements = []
+        self.fail_on = None
+        self.autocommit = False
+
+    def execute(self, sql, params=None):
+        self.statements.append(sql)
+        if self.fail_on and sql == self.fail_on:
+            raise RuntimeError("syntax error")
+        result = MagicMock()
+        if sql.startswith("SELECT version, dirty"):
+            result.fetchone.return_value = self.row
+        elif sql == "DELETE FROM schema_migrations":
+            self.row = None
+        elif sql.startswith("INSERT INTO schema_migrations"):
+            self.row = {"version": params[0], "dirty": params[1]}
+        return result
+
+def fake_pool(conn):
+    pool = MagicMock()
+    pool.connection.return_value.__enter__.return_value = conn
+    return pool
+
+MIGRATIONS = [
+    Migration(1, "create_patients", up="CREATE TABLE patients", down="DROP TABLE patients"),
+    Migration(2, "create_encounters", up="CREATE TABLE encounters", down="DROP TABLE encounters"),
+]
+
+# --- Test Cases ---
+
+def test_bundled_migrations_are_paired_and_ordered():
+    """Tests that every bundled migration has up and down files, numbered from 1."""
+    migrations = load_migrations()
+
+    assert [m.version for m in migrations] == list(range(1, len(migrations) + 1))
+    assert all(m.down for m in migrations)
+    assert "CREATE TABLE audit_events" in migrations[0].up
+
+def test_migrate_up_applies_only_pending_migrations():
+    """Tests that migrations at or below the current version are skipped."""
+    conn = FakeConnection(version=1)
+
+    applied = migrate_up(fake_pool(conn), MIGRATIONS)
+
+    assert applied == [2]
+    assert "CREATE TABLE encounters" in conn.statements
+    assert "CREATE TABLE patients" not in conn.statements
+    assert conn.row == {"version": 2, "dirty": False}
+
+def test_failed_migration_leaves_schema_dirty():
+    """Tests that a failure is recorded, and that later runs refuse to continue."""
+    conn = FakeConnection()
+    conn.fail_on = "CREATE TABLE encounters"
+
+    with pytest.raises(RuntimeError):
+        migrate_up(fake_pool(conn), MIGRATIONS)
+    assert conn.row == {"version": 2, "dirty": True}
+
+    conn.fail_on = None
+    with pytest.raises(MigrationError):
+        migrate_up(fake_pool(conn), MIGRATIONS)
+
+def test_migrate_down_steps_back_to_previous_version():
+    """Tests rolling back one step, and back to an empty schema."""
+    conn = FakeConnection(version=2)
+
+    assert migrate_down(fake_pool(conn), 1, MIGRATIONS) == [2]
+    assert conn.row == {"version": 1, "dirty": False}
+
+    assert migrate_down(fake_pool(conn), 5, MIGRATIONS) == [1]
+    assert conn.row is None
+    assert "DROP TABLE patients" in conn.statements
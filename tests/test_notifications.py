@@ -0,0 +1,316 @@
+import base64
+import json
+import pytest
+from datetime import datetime, timezone
+from unittest.mock import MagicMock
+from zoneinfo import ZoneInfo
+
+import httpx
+from cryptography.hazmat.primitives import hashes, serialization
+from cryptography.hazmat.primitives.asymmetric import ec
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api.integrations.endpoints import email_events
+from app.api.v1 import schemas
+from app.api.v1.deps import get_notification_repository
+from app.notifications import senders
+from app.notifications.notifier import Notifier
+from app.notifications.senders import EmailDeliveryError, EmailRejected, OutgoingEmail, SendGridSender
+from app.notifications.sendgrid_events import SIGNATURE_HEADER, TIMESTAMP_HEADER, delivery_events, verify_signature
+from app.notifications.templates import TemplateContext, render
+from app.repositories.memory import MemoryNotificationRepository, MemoryOrganizationRepository, MemoryStore
+from app.tasks import jobs
+from app.tasks.registry import run_task
+from app.tenancy.context import acting_for
+
+# --- Test Setup ---
+
+COMMON = TemplateContext(organization_name="Chiang Mai Sleep Clinic", portal_url="https://care.example.com", timezone=ZoneInfo("Asia/Bangkok"))
+AT = datetime(2024, 3, 2, 9, 30, tzinfo=timezone.utc)
+
+def queued_notification(repo, template="result-ready", context=None):
+    return repo.create(schemas.NotificationCreate(
+        template=template, recipient="ann.lee@example.com", patient_id="patient-1",
+        about="patients/patient-1/lab-results/lab-1", context=context or {"patientName": "Ann"},
+    ))
+
+def delivery(status, event_id, at=AT):
+    return schemas.DeliveryEvent(status=status, at=at, provider_event_id=event_id)
+
+def outgoing_email(**overrides):
+    fields = dict(
+        notification_id="notification-1", to="ann.lee@example.com", from_address="care@sleepclinic.example",
+        from_name="Chiang Mai Sleep Clinic", reply_to=None, subject="A new result is ready to view",
+        text="Hello,\n", html="<p>Hello,</p>",
+    )
+    fields.update(overrides)
+    return OutgoingEmail(**fields)
+
+def sendgrid_answering(status_code, headers=None, body=None):
+    http = MagicMock()
+    http.post.return_value = httpx.Response(status_code, headers=headers or {}, json=body)
+    return SendGridSender("SG.key", 10, client=http), http
+
+def use_send_job(monkeypatch, notifications, organizations=None):
+    """Points the send job at the given stores and a mock sender named sendgrid."""
+    sender = MagicMock()
+    sender.name = "sendgrid"
+    sender.send.return_value = "sg-message-1"
+    monkeypatch.setattr(jobs.settings, "email_from_address", "no-reply@megacare.example")
+    monkeypatch.setattr(jobs.settings, "email_from_name", "MegaCare")
+    monkeypatch.setattr(jobs.settings, "patient_portal_url", "https://care.example.com")
+    monkeypatch.setattr(jobs, "get_email_sender", lambda: sender)
+    monkeypatch.setattr(jobs, "get_notification_repository", lambda: notifications)
+    monkeypatch.setattr(jobs, "get_organization_repository", lambda: organizations or MemoryOrganizationRepository(MemoryStore()))
+    return sender
+
+def signing_key():
+    """Returns an Event Webhook key pair: a signing function and the base64 verification key."""
+    private_key = ec.generate_private_key(ec.SECP256R1())
+    public_key = private_key.public_key().public_bytes(
+        serialization.Encoding.DER, serialization.PublicFormat.SubjectPublicKeyInfo,
+    )
+    def sign(timestamp: str, payload: bytes) -> str:
+        return base64.b64encode(private_key.sign(timestamp.encode() + payload, ec.ECDSA(hashes.SHA256()))).decode()
+    return sign, base64.b64encode(public_key).decode()
+
+# --- Template Test Cases ---
+
+def test_appointment_confirmation_is_written_in_local_time():
+    """Tests that the appointment time is shown in EMAIL_TIMEZONE with the practitioner and place."""
+    rendered = render("appointment-confirmation", {
+        "patientName": "Ann", "start": "2024-03-02T02:30:00+00:00", "appointmentType": "follow-up",
+        "practitionerName": "Dr. Somchai Jaidee", "location": "Room 4",
+    }, COMMON)
+
+    assert rendered.subject == "Your appointment with Chiang Mai Sleep Clinic is confirmed"
+    assert "Your follow up appointment with Dr. Somchai Jaidee is booked for Saturday 2 March 2024 at 09:30 (Asia/Bangkok) at Room 4." in rendered.text
+    assert "View your appointments: https://care.example.com" in rendered.text
+
+def test_result_ready_names_no_result():
+    """Tests that a result notice carries no test or value, only a link to sign in."""
+    rendered = render("result-ready", {"patientName": "Ann", "testName": "HbA1c"}, COMMON)
+
+    assert "HbA1c" not in rendered.text + rendered.html
+    assert rendered.text.startswith("Dear Ann,")
+
+def test_context_values_are_escaped_in_html():
+    """Tests that values from the notification's context cannot inject markup."""
+    rendered = render("result-ready", {"patientName": "<script>alert(1)</script>"}, COMMON)
+
+    assert "<script>" not in rendered.html
+    assert "&lt;script&gt;" in rendered.html
+
+# --- Sender Test Cases ---
+
+def test_sendgrid_sender_tags_the_message_and_disables_tracking():
+    """Tests the Mail Send body: the notification ID as a custom arg, both parts, no open or click tracking."""
+    sender, http = sendgrid_answering(202, headers={"X-Message-Id": "sg-message-1"})
+
+    assert sender.send(outgoing_email(reply_to="front-desk@sleepclinic.example")) == "sg-message-1"
+
+    body = http.post.call_args.kwargs["json"]
+    assert body["personalizations"] == [{"to": [{"email": "ann.lee@example.com"}], "custom_args": {"notificationId": "notification-1"}}]
+    assert body["from"] == {"email": "care@sleepclinic.example", "name": "Chiang Mai Sleep Clinic"}
+    assert body["reply_to"] == {"email": "front-desk@sleepclinic.example"}
+    assert [part["type"] for part in body["content"]] == ["text/plain", "text/html"]
+    assert body["tracking_settings"] == {"click_tracking": {"enable": False}, "open_tracking": {"enable": False}}
+    assert http.post.call_args.kwargs["headers"] == {"Authorization": "Bearer SG.key"}
+
+def test_sendgrid_refusals_are_permanent_and_outages_are_not():
+    """Tests that 4xx answers raise EmailRejected, and 429 and 5xx raise EmailDeliveryError."""
+    sender, _http = sendgrid_answering(400, body={"errors": [{"message": "The from address does not match a verified Sender Identity."}]})
+    with pytest.raises(EmailRejected, match="verified Sender Identity"):
+        sender.send(outgoing_email())
+
+    for status_code in (429, 503):
+        sender, _http = sendgrid_answering(status_code)
+        with pytest.raises(EmailDeliveryError):
+            sender.send(outgoing_email())
+
+def test_no_sender_without_a_provider(monkeypatch):
+    """Tests that EMAIL_PROVIDER=none configures no sender."""
+    monkeypatch.setattr(senders.get_settings(), "email_provider", "none")
+    senders.get_email_sender.cache_clear()
+    try:
+        assert senders.get_email_sender() is None
+    finally:
+        senders.get_email_sender.cache_clear()
+
+# --- Notifier Test Cases ---
+
+def test_notifier_records_and_queues_the_message():
+    """Tests that a notification is recorded as queued and its send job enqueued."""
+    repo = MemoryNotificationRepository(MemoryStore())
+    tasks = MagicMock()
+    patient = schemas.Patient(
+        patientId="patient-1", givenName="Ann", familyName="Lee", dob="1980-01-01", mrn="MRN-1",
+        contact={"email": "ann.lee@example.com"}, createdAt=AT, updatedAt=AT,
+    )
+
+    [notification] = Notifier(repo, tasks, email=True).notify("result-ready", patient, {}, about="patients/patient-1/lab-results/lab-1")
+
+    assert (notification.channel, notification.status, notification.recipient, notification.context) == (
+        "email", "queued", "ann.lee@example.com", {"patientName": "Ann"},
+    )
+    tasks.enqueue.assert_called_once_with(
+        jobs.EMAIL_NOTIFICATION_TASK, {"notificationId": notification.notification_id}, task_id=f"notification-{notification.notification_id}",
+    )
+
+def test_notifier_skips_patients_without_email():
+    """Tests that patients without an email address are not notified."""
+    repo = MemoryNotificationRepository(MemoryStore())
+    tasks = MagicMock()
+    patient = schemas.Patient(patientId="patient-1", givenName="Ann", familyName="Lee", dob="1980-01-01", mrn="MRN-1", createdAt=AT, updatedAt=AT)
+
+    assert Notifier(repo, tasks, email=True).notify("result-ready", patient, {}) == []
+    tasks.enqueue.assert_not_called()
+
+# --- Send Job Test Cases ---
+
+def test_send_job_uses_the_organization_sender_identity(monkeypatch):
+    """Tests that a notification queued for an organization is sent from its emailSender and marked sent."""
+    store = MemoryStore()
+    notifications = MemoryNotificationRepository(store)
+    organizations = MemoryOrganizationRepository(store)
+    organizations.create(schemas.OrganizationCreate(
+        organization_id="sleep-clinic", name="Chiang Mai Sleep Clinic",
+        email_sender={"address": "care@sleepclinic.example", "replyTo": "front-desk@sleepclinic.example"},
+    ))
+    with acting_for("sleep-clinic"):
+        notification = queued_notification(notifications)
+        sender = use_send_job(monkeypatch, notifications, organizations)
+
+        assert run_task(jobs.EMAIL_NOTIFICATION_TASK, {"notificationId": notification.notification_id}) is True
+
+        message = sender.send.call_args.args[0]
+        assert (message.from_address, message.from_name, message.reply_to) == (
+            "care@sleepclinic.example", "Chiang Mai Sleep Clinic", "front-desk@sleepclinic.example",
+        )
+        sent = notifications.get(notification.notification_id)
+    assert (sent.status, sent.provider, sent.provider_message_id, sent.sender) == ("sent", "sendgrid", "sg-message-1", "care@sleepclinic.example")
+    assert sent.sent_at is not None
+
+def test_send_job_falls_back_to_the_default_sender(monkeypatch):
+    """Tests that without an organization the message comes from EMAIL_FROM_ADDRESS."""
+    notifications = MemoryNotificationRepository(MemoryStore())
+    notification = queued_notification(notifications)
+    sender = use_send_job(monkeypatch, notifications)
+
+    run_task(jobs.EMAIL_NOTIFICATION_TASK, {"notificationId": notification.notification_id})
+
+    message = sender.send.call_args.args[0]
+    assert (message.from_address, message.from_name) == ("no-reply@megacare.example", "MegaCare")
+
+def test_refused_message_fails_the_notification(monkeypatch):
+    """Tests that a refusal marks the notification failed and is not retried."""
+    notifications = MemoryNotificationRepository(MemoryStore())
+    notification = queued_notification(notifications)
+    sender = use_send_job(monkeypatch, notifications)
+    sender.send.side_effect = EmailRejected("SendGrid refused the message (403): unverified sender")
+
+    assert run_task(jobs.EMAIL_NOTIFICATION_TASK, {"notificationId": notification.notification_id}) is False
+
+    failed = notifications.get(notification.notification_id)
+    assert (failed.status, failed.error) == ("failed", "SendGrid refused the message (403): unverified sender")
+
+def test_unreachable_provider_is_retried_and_sent_once(monkeypatch):
+    """Tests that an outage leaves the notification queued for the retry, and a sent one is not sent again."""
+    notifications = MemoryNotificationRepository(MemoryStore())
+    notification = queued_notification(notifications)
+    sender = use_send_job(monkeypatch, notifications)
+    sender.send.side_effect = EmailDeliveryError("SendGrid answered 503")
+
+    with pytest.raises(EmailDeliveryError):
+        run_task(jobs.EMAIL_NOTIFICATION_TASK, {"notificationId": notification.notification_id})
+    assert notifications.get(notification.notification_id).status == "queued"
+
+    sender.send.side_effect = None
+    run_task(jobs.EMAIL_NOTIFICATION_TASK, {"notificationId": notification.notification_id})
+    run_task(jobs.EMAIL_NOTIFICATION_TASK, {"notificationId": notification.notification_id})
+    assert sender.send.call_count == 2
+
+# --- Delivery Tracking Test Cases ---
+
+def test_delivery_events_are_recorded_once_and_never_move_back():
+    """Tests that a repeated event is ignored and a late deferral does not undo the delivery."""
+    repo = MemoryNotificationRepository(MemoryStore())
+    notification = queued_notification(repo)
+    repo.mark_sent(notification.notification_id, "sendgrid", "care@sleepclinic.example", "A new result is ready to view", "sg-message-1")
+
+    repo.record_delivery(notification.notification_id, delivery("delivered", "event-2"))
+    repo.record_delivery(notification.notification_id, delivery("delivered", "event-2"))
+    recorded = repo.record_delivery(notification.notification_id, delivery("deferred", "event-1"))
+
+    assert recorded.status == "delivered"
+    assert [e.provider_event_id for e in recorded.delivery_events] == ["event-2", "event-1"]
+
+    assert repo.record_delivery(notification.notification_id, delivery("complained", "event-3")).status == "complained"
+
+def test_webhook_batch_is_matched_to_notifications():
+    """Tests that only status events carrying a notification ID are taken from a batch."""
+    found = delivery_events([
+        {"event": "processed", "notificationId": "notification-1", "sg_event_id": "e0", "timestamp": 1709371800},
+        {"event": "bounce", "notificationId": "notification-1", "sg_event_id": "e1", "timestamp": 1709371800, "reason": "550 5.1.1 User unknown"},
+        {"event": "delivered", "sg_event_id": "e2", "timestamp": 1709371800},
+    ])
+
+    assert found == [("notification-1", schemas.DeliveryEvent(
+        status="bounced", at=datetime.fromtimestamp(1709371800, timezone.utc), reason="550 5.1.1 User unknown", provider_event_id="e1",
+    ))]
+
+def test_webhook_signature_covers_timestamp_and_body():
+    """Tests that a valid signature verifies, and a changed body or a malformed signature does not."""
+    sign, public_key = signing_key()
+    payload = b'[{"event":"delivered"}]'
+    signature = sign("1709371800", payload)
+
+    assert verify_signature(public_key, payload, signature, "1709371800")
+    assert not verify_signature(public_key, b'[{"event":"bounce"}]', signature, "1709371800")
+    assert not verify_signature(public_key, payload, "not base64!", "1709371800")
+
+# --- Webhook Endpoint Test Cases ---
+
+app = FastAPI()
+app.include_router(email_events.router, prefix="/integrations")
+client = TestClient(app)
+
+@pytest.fixture
+def webhook(monkeypatch):
+    """Configures a verification key and an in-memory notification store; returns the signer and the store."""
+    sign, public_key = signing_key()
+    repo = MemoryNotificationRepository(MemoryStore())
+    monkeypatch.setattr(email_events, "SENDGRID_WEBHOOK_PUBLIC_KEY", public_key)
+    app.dependency_overrides[get_notification_repository] = lambda: repo
+    yield sign, repo
+    app.dependency_overrides.pop(get_notification_repository, None)
+
+def test_signed_events_are_recorded(webhook):
+    """Tests that a signed batch records its delivery events and unknown notifications are skipped."""
+    sign, repo = webhook
+    notification = queued_notification(repo)
+    payload = json.dumps([
+        {"event": "delivered", "notificationId": notification.notification_id, "sg_event_id": "e1", "timestamp": 1709371800},
+        {"event": "delivered", "notificationId": "deleted-notification", "sg_event_id": "e2", "timestamp": 1709371800},
+    ]).encode()
+
+    response = client.post("/integrations/email/sendgrid/events", content=payload, headers={
+        SIGNATURE_HEADER: sign("1709371800", payload), TIMESTAMP_HEADER: "1709371800",
+    })
+
+    assert response.status_code == 204
+    assert repo.get(notification.notification_id).status == "delivered"
+
+def test_unsigned_events_are_refused(webhook):
+    """Tests that a batch with a bad signature is refused and records nothing."""
+    _sign, repo = webhook
+    notification = queued_notification(repo)
+    payload = json.dumps([{"event": "delivered", "notificationId": notification.notification_id}]).encode()
+
+    response = client.post("/integrations/email/sendgrid/events", content=payload, headers={
+        SIGNATURE_HEADER: base64.b64encode(b"forged").decode(), TIMESTAMP_HEADER: "1709371800",
+    })
+
+    assert response.status_code == 403
+    assert repo.get(notification.notification_id).status == "queued"
@@ -0,0 +1,169 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import date, datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_observation_repository, get_patient_repository
+from app.api.v1.endpoints import observations
+from app.dependencies.auth import get_current_user
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+from app.services.vitals import LOINC_SYSTEM, VitalValidationError, build_vital_observation
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(observations.router, prefix="/api/v1/patients", tags=["Observations"])
+app.dependency_overrides[get_current_user] = lambda: {"uid": "nurse-uid-123"}
+client = TestClient(app)
+
+FAKE_PATIENT_ID = "patient-1"
+FAKE_OBSERVATION_ID = "obs-1"
+NOW = datetime(2024, 5, 1, 12, 0, tzinfo=timezone.utc)
+
+def vital(**payload):
+    return schemas.VitalSignCreate.model_validate({"effectiveAt": "2024-05-01T07:30:00Z", **payload})
+
+def make_observation(patient_id=FAKE_PATIENT_ID):
+    return schemas.Observation.model_validate({
+        "observationId": FAKE_OBSERVATION_ID,
+        "patientId": patient_id,
+        "code": {"system": LOINC_SYSTEM, "code": "8867-4", "display": "Heart rate"},
+        "value": 72,
+        "unit": "/min",
+        "effectiveAt": datetime(2024, 5, 1, 7, 30, tzinfo=timezone.utc),
+        "createdAt": NOW,
+        "updatedAt": NOW,
+    })
+
+@pytest.fixture
+def repos():
+    """Overrides the observation and patient repositories with mocks."""
+    mocks = {
+        "observations": MagicMock(spec=ObservationRepository),
+        "patients": MagicMock(spec=PatientRepository),
+    }
+    app.dependency_overrides[get_observation_repository] = lambda: mocks["observations"]
+    app.dependency_overrides[get_patient_repository] = lambda: mocks["patients"]
+    yield mocks
+    for dep in (get_observation_repository, get_patient_repository):
+        app.dependency_overrides.pop(dep, None)
+
+# --- Vital Sign Validation Test Cases ---
+
+def test_build_spo2_fills_loinc_coding():
+    """Tests that a valid SpO2 reading gets the full LOINC coding."""
+    observation_in = build_vital_observation(FAKE_PATIENT_ID, vital(code="59408-5", value=96, unit="%"), now=NOW)
+
+    assert observation_in.code.system == LOINC_SYSTEM
+    assert observation_in.code.display.startswith("Oxygen saturation")
+    assert observation_in.value == 96
+
+def test_build_blood_pressure_components():
+    """Tests that blood pressure is stored as systolic and diastolic components."""
+    observation_in = build_vital_observation(FAKE_PATIENT_ID, vital(code="85354-9", components=[
+        {"code": "8480-6", "value": 120, "unit": "mm[Hg]"},
+        {"code": "8462-4", "value": 80, "unit": "mm[Hg]"},
+    ]), now=NOW)
+
+    assert observation_in.value is None
+    assert [(c.code.code, c.value) for c in observation_in.components] == [("8480-6", 120), ("8462-4", 80)]
+
+def test_build_weight_accepts_pounds_within_range():
+    """Tests that alternative units are range-checked after conversion."""
+    build_vital_observation(FAKE_PATIENT_ID, vital(code="29463-7", value=180, unit="[lb_av]"), now=NOW)
+
+    with pytest.raises(VitalValidationError):
+        build_vital_observation(FAKE_PATIENT_ID, vital(code="29463-7", value=1200, unit="[lb_av]"), now=NOW)
+
+def test_build_vital_rejects_invalid():
+    """Tests the validation messages for unsupported codes, units, ranges and panels."""
+    cases = [
+        ({"code": "1234-5", "value": 1, "unit": "%"}, "Unsupported vital-sign code"),
+        ({"code": "59408-5", "value": 96, "unit": "mmol/L"}, "unit 'mmol/L' is not valid"),
+        ({"code": "8867-4", "value": 900, "unit": "/min"}, "outside the plausible range"),
+        ({"code": "85354-9", "components": [{"code": "8480-6", "value": 120, "unit": "mm[Hg]"}]}, "requires a Diastolic"),
+        ({"code": "85354-9", "value": 120, "unit": "mm[Hg]"}, "recorded through components"),
+    ]
+    for payload, message in cases:
+        with pytest.raises(VitalValidationError) as exc_info:
+            build_vital_observation(FAKE_PATIENT_ID, vital(**payload), now=NOW)
+        assert message in str(exc_info.value)
+
+def test_build_vital_rejects_future_and_naive_timestamps():
+    """Tests that effectiveAt must be timezone-aware and not in the future."""
+    with pytest.raises(VitalValidationError):
+        build_vital_observation(FAKE_PATIENT_ID, vital(code="8867-4", value=72, unit="/min", effectiveAt="2024-05-02T07:30:00Z"), now=NOW)
+    with pytest.raises(VitalValidationError):
+        build_vital_observation(FAKE_PATIENT_ID, vital(code="8867-4", value=72, unit="/min", effectiveAt="2024-05-01T07:30:00"), now=NOW)
+
+# --- Endpoint Test Cases ---
+
+def test_create_observation_success(repos):
+    """Tests recording a heart rate for an existing patient."""
+    repos["observations"].create.return_value = make_observation()
+
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/observations", json={
+        "code": "8867-4", "value": 72, "unit": "/min", "effective_at": "2024-05-01T07:30:00Z",
+    })
+
+    assert response.status_code == 201
+    assert response.json()["observation_id"] == FAKE_OBSERVATION_ID
+    assert repos["observations"].create.call_args[0][0].code.display == "Heart rate"
+
+def test_create_observation_invalid_unit(repos):
+    """Tests 422 with a descriptive message for a unit that does not match the code."""
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/observations", json={
+        "code": "8867-4", "value": 72, "unit": "%", "effective_at": "2024-05-01T07:30:00Z",
+    })
+
+    assert response.status_code == 422
+    assert "unit '%' is not valid" in response.json()["detail"]
+    repos["observations"].create.assert_not_called()
+
+def test_create_observation_unknown_patient(repos):
+    """Tests 404 when the patient does not exist."""
+    repos["patients"].get.return_value = None
+
+    response = client.post("/api/v1/patients/missing/observations", json={
+        "code": "8867-4", "value": 72, "unit": "/min", "effective_at": "2024-05-01T07:30:00Z",
+    })
+
+    assert response.status_code == 404
+
+def test_list_observations_time_range(repos):
+    """Tests that code and time-range filters are passed to the repository."""
+    repos["observations"].list.return_value = [make_observation()]
+
+    response = client.get(f"/api/v1/patients/{FAKE_PATIENT_ID}/observations", params={
+        "code": "8867-4", "from": "2024-05-01T00:00:00Z", "to": "2024-05-02T00:00:00Z",
+    })
+
+    assert response.status_code == 200
+    repos["observations"].list.assert_called_once_with(
+        FAKE_PATIENT_ID,
+        code="8867-4",
+        start_from=datetime(2024, 5, 1, tzinfo=timezone.utc),
+        start_to=datetime(2024, 5, 2, tzinfo=timezone.utc),
+        limit=31,
+        after=None,
+    )
+
+def test_list_observations_inverted_range(repos):
+    """Tests 400 when 'from' is not before 'to'."""
+    response = client.get(f"/api/v1/patients/{FAKE_PATIENT_ID}/observations", params={
+        "from": "2024-05-02T00:00:00Z", "to": "2024-05-01T00:00:00Z",
+    })
+
+    assert response.status_code == 400
+
+def test_get_observation_of_other_patient(repos):
+    """Tests that an observation is not returned under a different patient's path."""
+    repos["observations"].get.return_value = make_observation(patient_id="patient-2")
+
+    response = client.get(f"/api/v1/patients/{FAKE_PATIENT_ID}/observations/{FAKE_OBSERVATION_ID}")
+
+    assert response.status_code == 404
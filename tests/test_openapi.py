@@ -0,0 +1,80 @@
+from fastapi.testclient import TestClient
+from pydantic import BaseModel
+
+from fastapi import APIRouter, FastAPI
+from app.api.openapi import TAGS_METADATA, install_openapi, operation_id
+from app.errors.problems import PROBLEM_JSON
+
+# --- Test Setup ---
+
+class PatientIn(BaseModel):
+    mrn: str
+
+v1 = APIRouter()
+fhir = APIRouter()
+
+@v1.post("/patients")
+def create_patient(patient_in: PatientIn):
+    return patient_in
+
+@v1.get("/patients/{patientId}")
+def get_patient(patientId: str):
+    return {}
+
+def create_patient_resource(resource: dict):
+    return resource
+
+# Named like the /api/v1 handler, as FHIR handlers of the same resource are.
+fhir.add_api_route("/Patient", create_patient_resource, methods=["POST"], name="create_patient")
+
+app = FastAPI(title="Test API", version="1", openapi_tags=TAGS_METADATA, generate_unique_id_function=operation_id)
+app.include_router(v1, prefix="/api/v1", tags=["Patients"])
+app.include_router(fhir, prefix="/fhir", tags=["FHIR R4"])
+install_openapi(app)
+
+client = TestClient(app)
+
+def operations(document):
+    return {(path, method): operation for path, methods in document["paths"].items() for method, operation in methods.items()}
+
+# --- OpenAPI Test Cases ---
+
+def test_openapi_document_is_served_with_stable_operation_ids():
+    """Tests that /openapi.json names operations after their handlers, keeping FHIR names apart."""
+    document = client.get("/openapi.json").json()
+    ids = [operation["operationId"] for operation in operations(document).values()]
+
+    assert document["openapi"].startswith("3.")
+    assert "create_patient" in ids and "fhir_create_patient" in ids
+    assert len(ids) == len(set(ids))
+    assert [tag["name"] for tag in document["tags"]][:2] == ["Authentication", "Customers"]
+
+def test_errors_are_documented_as_problems_outside_fhir():
+    """Tests that 422s reference the validation problem and every operation has a problem default response."""
+    document = client.get("/openapi.json").json()
+    ops = operations(document)
+    create = ops[("/api/v1/patients", "post")]["responses"]
+
+    assert create["422"]["content"][PROBLEM_JSON]["schema"] == {"$ref": "#/components/schemas/ValidationProblem"}
+    assert ops[("/api/v1/patients/{patientId}", "get")]["responses"]["default"]["content"][PROBLEM_JSON]
+    assert "application/fhir+json" in ops[("/fhir/Patient", "post")]["responses"]["default"]["content"]
+    assert "HTTPValidationError" not in document["components"]["schemas"]
+    assert "errors" in document["components"]["schemas"]["ValidationProblem"]["properties"]
+
+def test_idempotency_key_is_documented_on_api_posts():
+    """Tests that /api POSTs list the optional Idempotency-Key header, and other operations do not."""
+    ops = operations(client.get("/openapi.json").json())
+
+    def headers(operation):
+        return [p["name"] for p in operation.get("parameters", []) if p["in"] == "header"]
+
+    assert "Idempotency-Key" in headers(ops[("/api/v1/patients", "post")])
+    assert "Idempotency-Key" not in headers(ops[("/api/v1/patients/{patientId}", "get")])
+    assert "Idempotency-Key" not in headers(ops[("/fhir/Patient", "post")])
+
+def test_swagger_ui_is_served():
+    """Tests that the embedded Swagger UI loads the generated document."""
+    response = client.get("/docs")
+
+    assert response.status_code == 200
+    assert "/openapi.json" in response.text
@@ -0,0 +1,177 @@
+import pytest
+from unittest.mock import MagicMock
+from datetime import datetime, timedelta, timezone
+
+from app.api.v1 import schemas
+from app.core.postgres import connection, transaction
+from app.core.worker import retry_delay
+from app.events.envelope import Event
+from app.events.publisher import EventPublisher, OutboxEventPublisher
+from app.events.relay import OutboxRelay
+from app.repositories import transactions
+from app.repositories.memory import MemoryOutboxRepository, MemoryPatientRepository, MemoryStore, get_memory_store
+from app.repositories.outbox import OutboxRepository
+from app.repositories.postgres.outbox import PostgresOutboxRepository
+from app.repositories.transactions import in_transaction, transactional
+
+# --- Test Setup ---
+
+NOW = datetime(2024, 5, 1, 9, 0, tzinfo=timezone.utc)
+
+def make_event(subject="patients/p-1"):
+    return Event(type="patient.created", subject=subject, data={"patientId": "p-1"})
+
+def make_patient_in(mrn="MRN-1"):
+    return schemas.PatientCreate(givenName="Ann", familyName="Lee", dob="1980-01-01", mrn=mrn)
+
+def make_entry(event, attempts=0):
+    return schemas.OutboxEntry(
+        eventId=event.id,
+        event=event.model_dump(mode="json", by_alias=True),
+        attempts=attempts,
+        nextAttemptAt=NOW,
+        createdAt=NOW,
+        updatedAt=NOW,
+    )
+
+# --- Transaction Test Cases ---
+
+def test_memory_transaction_rolls_back_change_and_event():
+    """Tests that a failure after the write discards both the record and its outbox entry."""
+    store = MemoryStore()
+    patients, outbox = MemoryPatientRepository(store), MemoryOutboxRepository(store)
+
+    with pytest.raises(RuntimeError):
+        with store.transaction():
+            patients.create(make_patient_in())
+            outbox.add(make_event())
+            raise RuntimeError("handler failed")
+
+    assert store.table("patients") == {}
+    assert store.table("event_outbox") == {}
+
+def test_transactional_endpoint_commits_together(monkeypatch):
+    """Tests that a decorated handler's change and event are both stored, inside one unit of work."""
+    monkeypatch.setattr(transactions, "STORE", "memory")
+    store = get_memory_store()
+    store.clear()
+    events = OutboxEventPublisher(MemoryOutboxRepository(store))
+
+    @transactional
+    def create_patient():
+        assert in_transaction()
+        patient = MemoryPatientRepository(store).create(make_patient_in())
+        events.emit("patient.created", f"patients/{patient.patient_id}", patient)
+        return patient
+
+    patient = create_patient()
+
+    assert not in_transaction()
+    [entry] = store.table("event_outbox").values()
+    assert entry["data"]["event"]["subject"] == f"patients/{patient.patient_id}"
+    store.clear()
+
+def test_outbox_publisher_raises_inside_transaction(monkeypatch):
+    """Tests that an outbox write failure propagates (rolling back) rather than being swallowed."""
+    monkeypatch.setattr(transactions, "STORE", "memory")
+    outbox = MagicMock(spec=OutboxRepository)
+    outbox.add.side_effect = RuntimeError("database unavailable")
+    events = OutboxEventPublisher(outbox)
+
+    with pytest.raises(RuntimeError):
+        with transactions.unit_of_work():
+            events.emit("patient.deleted", "patients/p-1", patientId="p-1")
+
+    events.emit("patient.deleted", "patients/p-1", patientId="p-1")
+    assert outbox.add.call_count == 2
+
+def test_postgres_connection_shares_transaction_with_savepoints():
+    """Tests that repository calls inside `transaction()` run on its connection, each in a savepoint."""
+    pool = MagicMock()
+    conn = MagicMock()
+    pool.connection.return_value.__enter__.return_value = conn
+
+    with transaction(pool):
+        with connection(pool) as first:
+            pass
+        with connection(pool) as second:
+            pass
+
+    assert first is conn and second is conn
+    pool.connection.assert_called_once_with()
+    assert conn.transaction.call_count == 3
+
+# --- Outbox Repository Test Cases ---
+
+def test_memory_outbox_claim_leases_entries():
+    """Tests that claimed entries are not handed out again until their lease expires."""
+    outbox = MemoryOutboxRepository(MemoryStore())
+    outbox.add(make_event())
+
+    first = outbox.claim(limit=10, lease_seconds=60)
+    second = outbox.claim(limit=10, lease_seconds=60)
+
+    assert len(first) == 1
+    assert second == []
+    assert first[0].next_attempt_at > datetime.now(timezone.utc)
+
+def test_postgres_outbox_claim_skips_locked_rows():
+    """Tests that the claim query leases pending, due rows with SKIP LOCKED."""
+    pool = MagicMock()
+    conn = MagicMock()
+    pool.connection.return_value.__enter__.return_value = conn
+    conn.execute.return_value.fetchall.return_value = []
+
+    PostgresOutboxRepository(pool).claim(limit=50, lease_seconds=60)
+
+    sql, params = conn.execute.call_args[0]
+    assert sql.startswith("UPDATE event_outbox SET data = data || %s")
+    assert "FOR UPDATE SKIP LOCKED" in sql
+    assert params[2].obj == {"status": "pending"}
+    assert params[-1] == 50
+
+# --- Relay Test Cases ---
+
+def test_relay_publishes_and_marks_entries():
+    """Tests that published entries are marked so they are not sent again."""
+    event = make_event()
+    outbox = MagicMock(spec=OutboxRepository)
+    outbox.claim.return_value = [make_entry(event)]
+    publisher = MagicMock(spec=EventPublisher)
+
+    published = OutboxRelay(outbox, publisher).drain()
+
+    assert published == 1
+    assert publisher.publish.call_args[0][0].id == event.id
+    outbox.mark_published.assert_called_once_with(event.id)
+
+def test_relay_reschedules_with_backoff():
+    """Tests that a failed publish is retried later, backing off per attempt."""
+    event = make_event()
+    outbox = MagicMock(spec=OutboxRepository)
+    outbox.claim.return_value = [make_entry(event, attempts=2)]
+    publisher = MagicMock(spec=EventPublisher)
+    publisher.publish.side_effect = RuntimeError("deadline exceeded")
+
+    OutboxRelay(outbox, publisher, clock=lambda: NOW).drain()
+
+    outbox.mark_published.assert_not_called()
+    event_id, attempts, next_attempt_at, error = outbox.reschedule.call_args[0]
+    assert (event_id, attempts) == (event.id, 3)
+    assert next_attempt_at == NOW + timedelta(seconds=4)
+    assert "deadline exceeded" in error
+    assert retry_delay(20) == timedelta(minutes=5)
+
+def test_relay_gives_up_after_max_attempts():
+    """Tests that an entry is marked failed on its last allowed attempt."""
+    event = make_event()
+    outbox = MagicMock(spec=OutboxRepository)
+    outbox.claim.return_value = [make_entry(event, attempts=2)]
+    publisher = MagicMock(spec=EventPublisher)
+    publisher.publish.side_effect = RuntimeError("permission denied")
+
+    OutboxRelay(outbox, publisher, max_attempts=3).drain()
+
+    outbox.reschedule.assert_not_called()
+    outbox.mark_failed.assert_called_once()
+    assert outbox.mark_failed.call_args[0][:2] == (event.id, 3)
@@ -0,0 +1,109 @@
+import pytest
+from fastapi.testclient import TestClient
+
+from fastapi import Depends, FastAPI, HTTPException
+from app.pagination.cursors import InvalidCursorError, decode_cursor, encode_cursor
+from app.pagination.pages import Page, PageRequest, paginate, pagination
+from app.repositories.base import StaleCursorError
+
+# --- Test Setup ---
+
+KEY = b"test-cursor-key"
+RECORDS = [f"patient-{i:02d}" for i in range(7)]
+
+def fetch_records(after, limit):
+    """Stands in for a repository `list(limit=..., after=...)` over RECORDS."""
+    start = RECORDS.index(after) + 1 if after else 0
+    return RECORDS[start:start + limit]
+
+def first_page(limit=3, query="q1"):
+    return PageRequest(limit=limit, cursor=None, query=query)
+
+app = FastAPI()
+
+@app.get("/patients", response_model=Page[str])
+def list_patients(page: PageRequest = Depends(pagination(default_limit=3, max_limit=5))):
+    return paginate(page, fetch_records, lambda record: record)
+
+client = TestClient(app)
+
+# --- Cursor Test Cases ---
+
+def test_cursor_round_trips_and_is_url_safe():
+    """Tests that the state packed into a cursor is read back unchanged."""
+    token = encode_cursor({"a": "patient-02", "q": "q1", "n": 3}, key=KEY)
+
+    assert decode_cursor(token, key=KEY) == {"a": "patient-02", "q": "q1", "n": 3}
+    assert all(c.isalnum() or c in "-_." for c in token)
+
+def test_tampered_or_foreign_cursors_are_rejected():
+    """Tests that altered, garbled or differently signed cursors are refused."""
+    token = encode_cursor({"a": "patient-02", "q": "q1", "n": 3}, key=KEY)
+    body, signature = token.split(".")
+    forged = encode_cursor({"a": "patient-05", "q": "q1", "n": 3}, key=b"guessed").split(".")[0] + "." + signature
+
+    for bad in [forged, body, "not-a-cursor", body + ".!!", encode_cursor({"a": "x"}, key=b"other-key")]:
+        with pytest.raises(InvalidCursorError):
+            decode_cursor(bad, key=KEY)
+
+# --- Paginate Test Cases ---
+
+def test_pages_cover_every_record_once_and_total_on_last_page():
+    """Tests that following next_cursor visits each record once and only the last page has a total."""
+    seen, page = [], paginate(first_page(), fetch_records, lambda record: record)
+    while True:
+        seen.extend(page.items)
+        if not page.next_cursor:
+            break
+        assert page.total is None
+        page = paginate(PageRequest(limit=3, cursor=page.next_cursor, query="q1"), fetch_records, lambda record: record)
+
+    assert seen == RECORDS
+    assert page.total == len(RECORDS)
+
+def test_exact_final_page_has_no_next_cursor():
+    """Tests that a list ending exactly on a page boundary does not promise an empty page."""
+    page = paginate(first_page(limit=7), fetch_records, lambda record: record)
+
+    assert page.next_cursor is None
+    assert page.total == 7
+
+def test_cursor_of_other_query_is_rejected():
+    """Tests that a cursor cannot be replayed against different filters."""
+    page = paginate(first_page(query="q1"), fetch_records, lambda record: record)
+
+    with pytest.raises(HTTPException) as excinfo:
+        paginate(PageRequest(limit=3, cursor=page.next_cursor, query="q2"), fetch_records, lambda record: record)
+
+    assert excinfo.value.status_code == 400
+
+def test_deleted_anchor_is_left_to_the_stale_cursor_handler():
+    """Tests that a cursor pointing at a since-deleted record raises StaleCursorError for the problem handler."""
+    def fetch_stale(after, limit):
+        if after:
+            raise StaleCursorError("gone")
+        return fetch_records(after, limit)
+    page = paginate(first_page(), fetch_stale, lambda record: record)
+
+    with pytest.raises(StaleCursorError):
+        paginate(PageRequest(limit=3, cursor=page.next_cursor, query="q1"), fetch_stale, lambda record: record)
+
+# --- Endpoint Test Cases ---
+
+def test_endpoint_returns_envelope_and_follows_cursor():
+    """Tests the {items, next_cursor, total} envelope over HTTP and that limit may change between pages."""
+    first = client.get("/patients", params={"sort": "name"}).json()
+    second = client.get("/patients", params={"sort": "name", "cursor": first["next_cursor"], "limit": 5}).json()
+
+    assert first["items"] == RECORDS[:3]
+    assert first["total"] is None
+    assert second["items"] == RECORDS[3:]
+    assert second["next_cursor"] is None
+    assert second["total"] == 7
+
+def test_endpoint_rejects_cursor_with_changed_filters_and_large_limits():
+    """Tests 400 for a cursor reused with other filters and 422 above the maximum limit."""
+    first = client.get("/patients", params={"sort": "name"}).json()
+
+    assert client.get("/patients", params={"sort": "dob", "cursor": first["next_cursor"]}).status_code == 400
+    assert client.get("/patients", params={"limit": 6}).status_code == 422
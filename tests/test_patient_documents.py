@@ -0,0 +1,213 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_event_publisher, get_patient_document_repository, get_patient_repository, get_task_queue
+from app.api.v1.endpoints import patient_documents
+from app.dependencies.auth import get_current_user
+from app.repositories.memory import MemoryPatientDocumentRepository, MemoryStore
+from app.repositories.patients import PatientRepository
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(patient_documents.router, prefix="/api/v1/patients", tags=["Documents"])
+app.dependency_overrides[get_current_user] = lambda: {"uid": "clinician-uid-123"}
+client = TestClient(app)
+
+FAKE_PATIENT_ID = "patient-1"
+CHECKSUM = "1B2M2Y8AsgTpgAmY7PhCfg=="
+DISCHARGE_SUMMARY = {
+    "type": "discharge-summary",
+    "title": "Discharge summary 2024-03-02",
+    "contentType": "application/pdf",
+    "size": 48213,
+    "checksum": CHECKSUM,
+}
+
+def stored_blob(size=48213, md5_hash=CHECKSUM, content_type="application/pdf"):
+    blob = MagicMock()
+    blob.size, blob.md5_hash, blob.content_type = size, md5_hash, content_type
+    return blob
+
+@pytest.fixture
+def storage(monkeypatch):
+    """Points the endpoints at a fake bucket and Cloud Storage calls at mocks."""
+    fake = MagicMock()
+    fake.signed_upload_url.return_value = "https://storage.googleapis.com/docs/upload?X-Goog-Signature=abc"
+    fake.signed_download_url.return_value = "https://storage.googleapis.com/docs/download?X-Goog-Signature=def"
+    fake.get_blob.return_value = stored_blob()
+    monkeypatch.setattr(patient_documents, "PATIENT_DOCUMENTS_BUCKET", "docs")
+    monkeypatch.setattr(patient_documents, "get_scanner", lambda: MagicMock())
+    for name in ("signed_upload_url", "signed_download_url", "get_blob", "delete_blob"):
+        monkeypatch.setattr(patient_documents, name, getattr(fake, name))
+    return fake
+
+@pytest.fixture
+def repos():
+    """Overrides the document repository with an in-memory one, and the patient repository with a mock."""
+    mocks = {
+        "documents": MemoryPatientDocumentRepository(MemoryStore()),
+        "patients": MagicMock(spec=PatientRepository),
+        "events": MagicMock(),
+        "tasks": MagicMock(),
+    }
+    app.dependency_overrides[get_patient_document_repository] = lambda: mocks["documents"]
+    app.dependency_overrides[get_patient_repository] = lambda: mocks["patients"]
+    app.dependency_overrides[get_event_publisher] = lambda: mocks["events"]
+    app.dependency_overrides[get_task_queue] = lambda: mocks["tasks"]
+    yield mocks
+    for dep in (get_patient_document_repository, get_patient_repository, get_event_publisher, get_task_queue):
+        app.dependency_overrides.pop(dep, None)
+
+def add_document(repo, document_id="doc-1"):
+    return repo.create(
+        FAKE_PATIENT_ID, document_id, schemas.PatientDocumentCreate.model_validate(DISCHARGE_SUMMARY),
+        f"patients/{FAKE_PATIENT_ID}/documents/{document_id}", uploaded_by="clinician-uid-123",
+    )
+
+# --- Repository Test Cases ---
+
+def test_memory_repository_marks_documents_uploaded():
+    """Tests that a document starts pending and is listed by status once uploaded."""
+    repo = MemoryPatientDocumentRepository(MemoryStore())
+    pending = add_document(repo, "doc-1")
+    add_document(repo, "doc-2")
+
+    uploaded = repo.mark_uploaded("doc-1", status="available")
+
+    assert pending.status == "pending" and pending.uploaded_at is None
+    assert uploaded.status == "available" and uploaded.uploaded_at is not None
+    assert [d.document_id for d in repo.list(FAKE_PATIENT_ID, status="available")] == ["doc-1"]
+    assert len(repo.list(FAKE_PATIENT_ID, type="discharge-summary")) == 2
+    assert repo.list("patient-2") == []
+
+# --- Endpoint Test Cases ---
+
+def test_create_document_issues_signed_upload_url(repos, storage):
+    """Tests that announcing a document records it as pending and returns a signed PUT URL with its headers."""
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/documents", json=DISCHARGE_SUMMARY)
+
+    assert response.status_code == 201
+    body = response.json()
+    assert body["upload_url"].startswith("https://storage.googleapis.com/")
+    assert body["upload_headers"] == {"Content-Type": "application/pdf", "Content-MD5": CHECKSUM}
+    assert body["document"]["status"] == "pending"
+    assert body["document"]["uploaded_by"] == "clinician-uid-123"
+    document_id = body["document"]["document_id"]
+    storage.signed_upload_url.assert_called_once_with(
+        "docs", f"patients/{FAKE_PATIENT_ID}/documents/{document_id}", 900, "application/pdf", CHECKSUM,
+    )
+
+def test_create_document_rejects_oversized_files(repos, storage, monkeypatch):
+    """Tests that files over PATIENT_DOCUMENT_MAX_BYTES are refused before a URL is issued."""
+    monkeypatch.setattr(patient_documents, "MAX_BYTES", 1024)
+
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/documents", json=DISCHARGE_SUMMARY)
+
+    assert response.status_code == 413
+    storage.signed_upload_url.assert_not_called()
+
+def test_create_document_without_bucket(repos, monkeypatch):
+    """Tests that uploads answer 503 when no bucket is configured."""
+    monkeypatch.setattr(patient_documents, "PATIENT_DOCUMENTS_BUCKET", None)
+
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/documents", json=DISCHARGE_SUMMARY)
+
+    assert response.status_code == 503
+
+def test_create_document_without_scanner(repos, storage, monkeypatch):
+    """Tests that uploads answer 503 when no malware scanner is configured, as unscanned files are never served."""
+    monkeypatch.setattr(patient_documents, "get_scanner", lambda: None)
+
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/documents", json=DISCHARGE_SUMMARY)
+
+    assert response.status_code == 503
+    storage.signed_upload_url.assert_not_called()
+
+def test_complete_upload_waits_for_the_malware_scan(repos, storage):
+    """Tests that a stored file matching the announced size and checksum awaits its scan, which is queued."""
+    add_document(repos["documents"])
+
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/documents/doc-1/complete")
+
+    assert response.status_code == 200
+    assert response.json()["status"] == "pending-scan"
+    repos["tasks"].enqueue.assert_called_once_with("patient-document-scan", {"documentId": "doc-1"}, task_id="document-scan-doc-1")
+    repos["events"].emit.assert_not_called()
+
+def test_complete_upload_without_scanner_stays_unavailable(repos, storage, monkeypatch):
+    """Tests that an upload completed after the scanner was turned off is not made available unscanned."""
+    monkeypatch.setattr(patient_documents, "get_scanner", lambda: None)
+    add_document(repos["documents"])
+
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/documents/doc-1/complete")
+
+    assert response.json()["status"] == "pending-scan"
+    repos["tasks"].enqueue.assert_not_called()
+
+def test_complete_upload_discards_mismatched_files(repos, storage):
+    """Tests that a stored file with a different checksum is deleted and the document stays pending."""
+    add_document(repos["documents"])
+    storage.get_blob.return_value = stored_blob(md5_hash="XUFAKrxLKna5cZ2REBfFkg==")
+
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/documents/doc-1/complete")
+
+    assert response.status_code == 422
+    storage.delete_blob.assert_called_once_with("docs", f"patients/{FAKE_PATIENT_ID}/documents/doc-1")
+    assert repos["documents"].get("doc-1").status == "pending"
+
+def test_complete_upload_before_the_file_arrives(repos, storage):
+    """Tests that completing an upload with nothing stored answers 409."""
+    add_document(repos["documents"])
+    storage.get_blob.return_value = None
+
+    response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/documents/doc-1/complete")
+
+    assert response.status_code == 409
+
+def test_download_requires_a_completed_upload(repos, storage):
+    """Tests that downloads answer 409 while pending, then return a signed GET URL."""
+    add_document(repos["documents"])
+    url = f"/api/v1/patients/{FAKE_PATIENT_ID}/documents/doc-1/download"
+
+    assert client.get(url).status_code == 409
+    repos["documents"].mark_uploaded("doc-1", status="available")
+    response = client.get(url)
+
+    assert response.status_code == 200
+    assert response.json()["url"].startswith("https://storage.googleapis.com/")
+    storage.signed_download_url.assert_called_once_with("docs", f"patients/{FAKE_PATIENT_ID}/documents/doc-1", 300)
+
+def test_unscanned_and_quarantined_documents_cannot_be_downloaded(repos, storage):
+    """Tests that downloads answer 409 while the scan is pending and after it found malware."""
+    repo = repos["documents"]
+    add_document(repo)
+    url = f"/api/v1/patients/{FAKE_PATIENT_ID}/documents/doc-1/download"
+
+    repo.mark_uploaded("doc-1", status="pending-scan")
+    assert client.get(url).status_code == 409
+    repo.record_scan("doc-1", threat="Win.Test.EICAR_HDB-1")
+    assert client.get(url).status_code == 409
+    storage.signed_download_url.assert_not_called()
+
+def test_document_of_another_patient_is_not_found(repos, storage):
+    """Tests that a document is only reachable under the patient it is attached to."""
+    add_document(repos["documents"])
+
+    response = client.get("/api/v1/patients/patient-2/documents/doc-1")
+
+    assert response.status_code == 404
+
+def test_delete_document_removes_the_file(repos, storage):
+    """Tests that deleting a document removes both the record and the stored file."""
+    add_document(repos["documents"])
+
+    response = client.delete(f"/api/v1/patients/{FAKE_PATIENT_ID}/documents/doc-1")
+
+    assert response.status_code == 204
+    assert repos["documents"].get("doc-1") is None
+    storage.delete_blob.assert_called_once_with("docs", f"patients/{FAKE_PATIENT_ID}/documents/doc-1")
@@ -0,0 +1,195 @@
+from datetime import date, datetime, timedelta, timezone
+
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_event_publisher, get_patient_record_repositories, get_patient_repository
+from app.api.v1.endpoints import patients
+from app.dependencies.auth import get_current_user
+from app.repositories.base import including_deleted
+from app.repositories.memory import (
+    MemoryAllergyRepository,
+    MemoryAppointmentRepository,
+    MemoryObservationRepository,
+    MemoryPatientRepository,
+    MemoryStore,
+)
+from app.services.patient_matching import PATIENT_RECORDS, jaro_winkler, merge_records, normalize_name, potential_duplicates, score
+
+# --- Test Setup ---
+
+NOW = datetime(2026, 10, 1, 9, 0, tzinfo=timezone.utc)
+
+def make_patient(patient_id="p-1", **overrides):
+    data = {
+        "patientId": patient_id, "givenName": "Somchai", "familyName": "Jaidee", "dob": date(1980, 4, 1), "sex": "male",
+        "mrn": f"MRN-{patient_id}", "contact": {"phoneNumber": "+66812345678", "addressLine": "12 Sukhumvit Rd", "postalCode": "10110"},
+        "createdAt": NOW, "updatedAt": NOW,
+    }
+    data.update(overrides)
+    return schemas.Patient.model_validate(data)
+
+def register(store, mrn, **overrides):
+    data = {"givenName": "Somchai", "familyName": "Jaidee", "dob": date(1980, 4, 1), "mrn": mrn, "contact": {"phoneNumber": "+66812345678"}}
+    data.update(overrides)
+    return MemoryPatientRepository(store).create(schemas.PatientCreate.model_validate(data))
+
+class RecordingPublisher:
+    def __init__(self):
+        self.events = []
+
+    def emit(self, event_type, subject, resource=None, **data):
+        self.events.append((event_type, subject, data))
+
+class CountingRepository:
+    def __init__(self):
+        self.calls = []
+
+    def reassign_patient(self, source_patient_id, target_patient_id):
+        self.calls.append((source_patient_id, target_patient_id))
+        return 1
+
+# --- Comparison Test Cases ---
+
+def test_names_are_compared_without_case_accents_or_punctuation():
+    """Tests that names are normalised before comparing, and that close spellings are similar."""
+    assert normalize_name("O'Brien-Smith") == normalize_name("obrien smith") == "obriensmith"
+    assert normalize_name("José") == "jose"
+    assert jaro_winkler("martha", "marhta") > 0.95
+    assert jaro_winkler("somchai", "somchai") == 1.0
+    assert jaro_winkler("somchai", "wichai") < 0.9
+
+def test_identifiers_make_a_certain_match():
+    """Tests that the same NHS number is a certain match, whatever the other fields say."""
+    match = score(make_patient(nhsNumber="9434765919"), make_patient("p-2", givenName="Sam", dob=date(1990, 1, 1), nhsNumber="9434765919"))
+
+    assert match.grade == "certain" and match.rule == "nhs-number"
+
+def test_name_date_of_birth_and_phone_make_a_certain_match():
+    """Tests the deterministic rule on full name, date of birth and phone number."""
+    match = score(make_patient(), make_patient("p-2", contact={"phoneNumber": "+66812345678"}))
+
+    assert match.grade == "certain" and match.rule == "name-dob-phone"
+
+def test_near_matches_are_scored_by_field():
+    """Tests that a typo in a name and a swapped day and month still score as a probable match."""
+    match = score(make_patient(), make_patient("p-2", givenName="Somchay", dob=date(1980, 1, 4)))
+
+    by_field = {field.field: field.comparison for field in match.fields}
+    assert by_field["givenName"] == "partial" and by_field["dob"] == "partial"
+    assert by_field["phoneNumber"] == "agree" and by_field["address"] == "agree"
+    assert match.grade == "probable" and match.rule is None
+
+def test_different_people_are_not_matched():
+    """Tests that patients who only share a family name are not reported."""
+    other = make_patient("p-2", givenName="Malee", sex="female", dob=date(1985, 7, 12), contact={"phoneNumber": "+66898765432", "postalCode": "50200"})
+
+    assert score(make_patient(), other) is None
+
+def test_missing_fields_add_nothing():
+    """Tests that a field missing on either side neither raises nor lowers the score."""
+    match = score(make_patient(), make_patient("p-2", givenName="Somchay", contact=None))
+
+    by_field = {field.field: field for field in match.fields}
+    assert by_field["phoneNumber"].comparison == "missing" and by_field["phoneNumber"].weight == 0
+    assert match.score == 11.5 and match.grade == "possible"
+
+def test_duplicates_are_ordered_certain_first():
+    """Tests that certain matches come before higher-scoring probabilistic ones."""
+    patient = make_patient(ssn="123-45-6789")
+    certain = make_patient("p-2", givenName="Sam", contact=None, ssn="123-45-6789")
+    probable = make_patient("p-3", givenName="Somchay")
+
+    assert [match.patient.patient_id for match in potential_duplicates(patient, [probable, certain])] == ["p-2", "p-3"]
+
+# --- Repository Test Cases ---
+
+def test_candidates_share_a_date_of_birth_family_name_or_phone():
+    """Tests that candidates are found by each blocking field, once each, without the patient itself."""
+    store = MemoryStore()
+    patient = register(store, "MRN-1")
+    register(store, "MRN-2", familyName="Jaidi", contact=None)
+    register(store, "MRN-3", dob=date(1970, 1, 1), contact={"phoneNumber": "+66898765432"})
+    register(store, "MRN-4", dob=date(1970, 1, 1), familyName="Srisuk")
+    register(store, "MRN-5", dob=date(1970, 1, 1), familyName="Srisuk", contact=None)
+
+    candidates = MemoryPatientRepository(store).find_candidates(patient)
+
+    assert sorted(candidate.mrn for candidate in candidates) == ["MRN-2", "MRN-3", "MRN-4"]
+
+def test_records_are_moved_to_the_kept_patient():
+    """Tests that reassigning moves a patient's records, deleted ones included, and bumps their versions."""
+    store = MemoryStore()
+    observations = MemoryObservationRepository(store)
+    for _ in range(2):
+        observations.create(schemas.ObservationCreate(patientId="p-2", code=schemas.Coding(system="http://loinc.org", code="59408-5"), value=95, effectiveAt=NOW))
+    observations.create(schemas.ObservationCreate(patientId="p-3", code=schemas.Coding(system="http://loinc.org", code="59408-5"), value=97, effectiveAt=NOW))
+    allergies = MemoryAllergyRepository(store)
+    allergy = allergies.create("p-2", schemas.AllergyCreate(substance=schemas.Coding(system="http://snomed.info/sct", code="372687004")), recorded_by="doc-1")
+    allergies.delete(allergy.allergy_id, deleted_by="doc-1")
+
+    assert observations.reassign_patient("p-2", "p-1") == 2
+    assert allergies.reassign_patient("p-2", "p-1") == 1
+    assert sorted(o.patient_id for o in observations.stream_taken_between(NOW - timedelta(days=1), NOW + timedelta(days=1))) == ["p-1", "p-1", "p-3"]
+    with including_deleted():
+        assert allergies.get(allergy.allergy_id).patient_id == "p-1"
+    assert observations.reassign_patient("p-2", "p-1") == 0
+
+def test_merge_moves_every_patient_resource():
+    """Tests that a merge reassigns the records of every patient resource."""
+    repositories = {resource: CountingRepository() for resource in PATIENT_RECORDS}
+
+    moved = merge_records(repositories, "p-2", "p-1")
+
+    assert set(moved) == set(PATIENT_RECORDS) and set(moved.values()) == {1}
+    assert all(repo.calls == [("p-2", "p-1")] for repo in repositories.values())
+
+# --- Endpoint Test Cases ---
+
+app = FastAPI()
+app.include_router(patients.router, prefix="/api/v1/patients")
+client = TestClient(app)
+
+def serve(store, publisher, roles):
+    app.dependency_overrides[get_patient_repository] = lambda: MemoryPatientRepository(store)
+    app.dependency_overrides[get_patient_record_repositories] = lambda: {
+        resource: MemoryAppointmentRepository(store) if resource == "appointments" else CountingRepository() for resource in PATIENT_RECORDS
+    }
+    app.dependency_overrides[get_event_publisher] = lambda: publisher
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "admin-1", "roles": roles}
+
+def test_merge_deletes_the_duplicate():
+    """Tests that merging moves the duplicate's appointments, deletes it with `mergedInto`, and refuses to restore it."""
+    store, publisher = MemoryStore(), RecordingPublisher()
+    kept, duplicate = register(store, "MRN-1"), register(store, "MRN-2", givenName="Somchay")
+    MemoryAppointmentRepository(store).create(schemas.AppointmentCreate(patientId=duplicate.patient_id, practitionerId="doc-1", start=NOW, end=NOW + timedelta(minutes=30)))
+    serve(store, publisher, ["admin"])
+    try:
+        found = client.get(f"/api/v1/patients/{kept.patient_id}/potential-duplicates")
+        merged = client.post(f"/api/v1/patients/{kept.patient_id}/merge", json={"sourcePatientId": duplicate.patient_id})
+        restored = client.post(f"/api/v1/patients/{duplicate.patient_id}/restore")
+    finally:
+        app.dependency_overrides.clear()
+
+    assert [match["patient"]["patient_id"] for match in found.json()] == [duplicate.patient_id]
+    assert merged.status_code == 200 and merged.json()["moved"]["appointments"] == 1
+    assert len(MemoryAppointmentRepository(store).list(patient_id=kept.patient_id)) == 1
+    with including_deleted():
+        assert MemoryPatientRepository(store).get(duplicate.patient_id).merged_into == kept.patient_id
+    assert restored.status_code == 409
+    assert publisher.events[-1][0] == "patient.merged"
+
+def test_merge_is_for_administrators():
+    """Tests that clinicians cannot merge patients, and no patient can be merged into itself."""
+    store = MemoryStore()
+    kept = register(store, "MRN-1")
+    serve(store, RecordingPublisher(), ["clinician"])
+    try:
+        refused = client.post(f"/api/v1/patients/{kept.patient_id}/merge", json={"sourcePatientId": "p-2"})
+        app.dependency_overrides[get_current_user] = lambda: {"uid": "admin-1", "roles": ["admin"]}
+        itself = client.post(f"/api/v1/patients/{kept.patient_id}/merge", json={"sourcePatientId": kept.patient_id})
+    finally:
+        app.dependency_overrides.clear()
+
+    assert refused.status_code == 403 and itself.status_code == 400
@@ -0,0 +1,243 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, date, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_patient_repository
+from app.api.v1.endpoints import patients
+from app.dependencies.auth import get_current_user
+from app.repositories.base import ConflictError, NotFoundError
+from app.repositories.patients import FirestorePatientRepository, PatientRepository
+
+# --- Test Setup ---
+
+# Create a minimal FastAPI app for testing purposes
+app = FastAPI()
+app.include_router(patients.router, prefix="/api/v1/patients", tags=["Patients"])
+
+FAKE_USER = {"uid": "staff-uid-123", "email": "staff@example.com"}
+FAKE_PATIENT_ID = "patient-abc-123"
+
+def override_get_current_user():
+    return FAKE_USER
+
+app.dependency_overrides[get_current_user] = override_get_current_user
+
+client = TestClient(app)
+
+def make_patient(**overrides):
+    data = {
+        "patientId": FAKE_PATIENT_ID,
+        "givenName": "Somchai",
+        "familyName": "Jaidee",
+        "dob": date(1980, 4, 1),
+        "sex": "male",
+        "mrn": "MRN-0001",
+        "contact": {"phoneNumber": "+66812345678"},
+        "createdAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+    }
+    data.update(overrides)
+    return schemas.Patient.model_validate(data)
+
+@pytest.fixture
+def mock_repo():
+    """Overrides the patient repository dependency with a mock for each test."""
+    repo = MagicMock(spec=PatientRepository)
+    app.dependency_overrides[get_patient_repository] = lambda: repo
+    yield repo
+    app.dependency_overrides.pop(get_patient_repository, None)
+
+# --- Endpoint Test Cases ---
+
+def test_create_patient_success(mock_repo):
+    """Tests successful creation of a patient."""
+    mock_repo.create.return_value = make_patient()
+    request_payload = {
+        "given_name": "Somchai",
+        "family_name": "Jaidee",
+        "dob": "1980-04-01",
+        "sex": "male",
+        "mrn": "MRN-0001",
+        "contact": {"phone_number": "+66812345678"},
+    }
+
+    response = client.post("/api/v1/patients", json=request_payload)
+
+    assert response.status_code == 201
+    patient_in = mock_repo.create.call_args[0][0]
+    assert isinstance(patient_in, schemas.PatientCreate)
+    assert patient_in.dob == date(1980, 4, 1)
+    response_data = response.json()
+    assert response_data["patient_id"] == FAKE_PATIENT_ID
+    assert response_data["dob"] == "1980-04-01"
+    assert response_data["contact"]["phone_number"] == "+66812345678"
+
+def test_create_patient_duplicate_mrn(mock_repo):
+    """Tests that a duplicate MRN returns 409 Conflict."""
+    mock_repo.create.side_effect = ConflictError("A patient with MRN 'MRN-0001' already exists.")
+
+    response = client.post("/api/v1/patients", json={
+        "given_name": "A", "family_name": "B", "dob": "1980-04-01", "mrn": "MRN-0001"
+    })
+
+    assert response.status_code == 409
+    assert "already exists" in response.json()["detail"]
+
+def test_create_patient_invalid_sex(mock_repo):
+    """Tests that an unknown value for sex is rejected with 422."""
+    response = client.post("/api/v1/patients", json={
+        "given_name": "A", "family_name": "B", "dob": "1980-04-01", "mrn": "MRN-1", "sex": "x"
+    })
+
+    assert response.status_code == 422
+    mock_repo.create.assert_not_called()
+
+def test_list_patients(mock_repo):
+    """Tests listing patients honours the limit parameter, reading one more to detect a next page."""
+    mock_repo.list.return_value = [make_patient(), make_patient(patientId="patient-2", mrn="MRN-0002")]
+
+    response = client.get("/api/v1/patients?limit=2")
+
+    assert response.status_code == 200
+    assert [p["patient_id"] for p in response.json()["items"]] == [FAKE_PATIENT_ID, "patient-2"]
+    mock_repo.list.assert_called_once_with(limit=3, after=None)
+
+def test_get_patient_not_found(mock_repo):
+    """Tests 404 when the patient does not exist."""
+    mock_repo.get.return_value = None
+
+    response = client.get("/api/v1/patients/missing")
+
+    assert response.status_code == 404
+    assert response.json()["detail"] == "Patient not found"
+
+def test_update_patient_only_sends_set_fields(mock_repo):
+    """Tests that a partial update only includes the fields sent by the client."""
+    mock_repo.update.return_value = make_patient(familyName="Rakdee")
+
+    response = client.patch(f"/api/v1/patients/{FAKE_PATIENT_ID}", json={"family_name": "Rakdee"})
+
+    assert response.status_code == 200
+    assert response.json()["family_name"] == "Rakdee"
+    patient_id, patient_in = mock_repo.update.call_args[0]
+    assert patient_id == FAKE_PATIENT_ID
+    assert patient_in.model_dump(exclude_unset=True) == {"family_name": "Rakdee"}
+
+def test_update_patient_rejects_null_required_fields(mock_repo):
+    """Tests that fields every patient has cannot be cleared with an explicit null."""
+    for field in ("givenName", "familyName", "dob", "sex", "mrn"):
+        response = client.patch(f"/api/v1/patients/{FAKE_PATIENT_ID}", json={field: None})
+
+        assert response.status_code == 422, field
+    mock_repo.update.assert_not_called()
+
+def test_delete_patient_not_found(mock_repo):
+    """Tests 404 when deleting a patient that does not exist."""
+    mock_repo.delete.side_effect = NotFoundError("Patient 'missing' not found.")
+
+    response = client.delete("/api/v1/patients/missing")
+
+    assert response.status_code == 404
+
+def test_delete_patient_success(mock_repo):
+    """Tests successful deletion returns 204 with no body."""
+    response = client.delete(f"/api/v1/patients/{FAKE_PATIENT_ID}")
+
+    assert response.status_code == 204
+    assert response.content == b""
+    mock_repo.delete.assert_called_once_with(FAKE_PATIENT_ID, deleted_by=FAKE_USER["uid"])
+
+def test_include_deleted_is_for_admins(mock_repo):
+    """Tests that only administrators may list deleted patients."""
+    mock_repo.list.return_value = [make_patient(deletedAt=datetime(2024, 2, 1, tzinfo=timezone.utc), deletedBy="admin-uid")]
+
+    assert client.get("/api/v1/patients?includeDeleted=true").status_code == 403
+    mock_repo.list.assert_not_called()
+
+    app.dependency_overrides[get_current_user] = lambda: {**FAKE_USER, "roles": ["admin"]}
+    try:
+        response = client.get("/api/v1/patients?includeDeleted=true")
+    finally:
+        app.dependency_overrides[get_current_user] = override_get_current_user
+
+    assert response.status_code == 200
+    assert response.json()["items"][0]["deleted_by"] == "admin-uid"
+
+def test_restore_patient(mock_repo):
+    """Tests that administrators can restore a deleted patient, and others cannot."""
+    mock_repo.restore.return_value = make_patient()
+
+    assert client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/restore").status_code == 403
+
+    app.dependency_overrides[get_current_user] = lambda: {**FAKE_USER, "roles": ["admin"]}
+    try:
+        response = client.post(f"/api/v1/patients/{FAKE_PATIENT_ID}/restore")
+        mock_repo.restore.side_effect = NotFoundError("Patient 'missing' not found.")
+        missing = client.post("/api/v1/patients/missing/restore")
+    finally:
+        app.dependency_overrides[get_current_user] = override_get_current_user
+
+    assert response.status_code == 200
+    assert response.json()["deleted_at"] is None
+    mock_repo.restore.assert_any_call(FAKE_PATIENT_ID)
+    assert missing.status_code == 404
+
+# --- Firestore Repository Test Cases ---
+
+def test_firestore_create_converts_dob_and_sets_timestamps():
+    """Tests that dob is stored as a datetime and audit timestamps are added."""
+    mock_db = MagicMock()
+    mock_collection = mock_db.collection.return_value
+    mock_collection.where.return_value.limit.return_value.stream.return_value = []  # MRN is free
+    mock_patient_ref = MagicMock()
+    mock_collection.add.return_value = (datetime.now(timezone.utc), mock_patient_ref)
+    stored = make_patient().model_dump(by_alias=True, exclude={"patient_id"})
+    mock_patient_ref.get.return_value.id = FAKE_PATIENT_ID
+    mock_patient_ref.get.return_value.to_dict.return_value = stored
+
+    repo = FirestorePatientRepository(mock_db)
+    patient = repo.create(schemas.PatientCreate(given_name="Somchai", family_name="Jaidee", dob=date(1980, 4, 1), mrn="MRN-0001"))
+
+    mock_db.collection.assert_called_once_with("patients")
+    data_sent_to_firestore = mock_collection.add.call_args[0][0]
+    assert data_sent_to_firestore["dob"] == datetime(1980, 4, 1, 0, 0)
+    assert isinstance(data_sent_to_firestore["createdAt"], datetime)
+    assert data_sent_to_firestore["createdAt"] == data_sent_to_firestore["updatedAt"]
+    assert patient.patient_id == FAKE_PATIENT_ID
+
+def test_firestore_create_rejects_duplicate_mrn():
+    """Tests that creating a patient with an existing MRN raises ConflictError."""
+    mock_db = MagicMock()
+    existing_doc = MagicMock()
+    existing_doc.id = "other-patient"
+    existing_doc.to_dict.return_value = make_patient().model_dump(by_alias=True, exclude={"patient_id"})
+    mock_db.collection.return_value.where.return_value.limit.return_value.stream.return_value = [existing_doc]
+
+    repo = FirestorePatientRepository(mock_db)
+
+    with pytest.raises(ConflictError):
+        repo.create(schemas.PatientCreate(given_name="A", family_name="B", dob=date(1980, 4, 1), mrn="MRN-0001"))
+    mock_db.collection.return_value.add.assert_not_called()
+
+def test_firestore_list_reads_past_deleted_patients():
+    """Tests that deleted documents are skipped and the list reads on until it has `limit` patients."""
+    def doc(patient_id, **overrides):
+        snapshot = MagicMock()
+        snapshot.id = patient_id
+        snapshot.to_dict.return_value = make_patient(**overrides).model_dump(by_alias=True, exclude={"patient_id"})
+        return snapshot
+
+    mock_db = MagicMock()
+    query = mock_db.collection.return_value.order_by.return_value
+    deleted = doc("p-2", deletedAt=datetime(2024, 2, 1, tzinfo=timezone.utc))
+    query.limit.return_value.stream.return_value = [doc("p-1"), deleted]
+    query.start_after.return_value.limit.return_value.stream.return_value = [doc("p-3")]
+
+    patients_listed = FirestorePatientRepository(mock_db).list(limit=2)
+
+    assert [p.patient_id for p in patients_listed] == ["p-1", "p-3"]
+    query.start_after.assert_called_once_with(deleted)
@@ -0,0 +1,139 @@
+import pytest
+from unittest.mock import MagicMock
+from datetime import datetime, timedelta, timezone
+
+from app.api.v1 import schemas
+from app.repositories.base import ConflictError, NotFoundError, StaleCursorError
+from app.repositories.postgres.base import field_sql, to_json
+from app.repositories.postgres.care_teams import PostgresCareTeamRepository
+from app.repositories.postgres.encounters import PostgresEncounterRepository
+from app.repositories.postgres.patients import PostgresPatientRepository
+
+# --- Test Setup ---
+
+NOW = datetime(2024, 5, 1, 9, 0, tzinfo=timezone.utc)
+
+def fake_pool():
+    """Returns (pool, conn) where every `pool.connection()` block yields `conn`."""
+    pool = MagicMock()
+    conn = MagicMock()
+    pool.connection.return_value.__enter__.return_value = conn
+    return pool, conn
+
+def make_row(record_id, data):
+    return {"id": record_id, "data": data, "created_at": NOW, "updated_at": NOW}
+
+def executed(conn, index=-1):
+    """Returns (sql, params) of an executed statement."""
+    args = conn.execute.call_args_list[index][0]
+    return args[0], args[1]
+
+# --- Encoding Test Cases ---
+
+def test_to_json_timestamps_sort_chronologically_as_text():
+    """Tests that encoded datetimes compare as text in time order, across offsets."""
+    bangkok = timezone(timedelta(hours=7))
+    earlier = to_json(datetime(2024, 5, 1, 15, 0, tzinfo=bangkok))  # 08:00 UTC
+    later = to_json(datetime(2024, 5, 1, 9, 0, 0, 5, tzinfo=timezone.utc))
+
+    assert earlier == "2024-05-01T08:00:00.000000Z"
+    assert earlier < later
+
+def test_field_sql_maps_columns_and_nested_fields():
+    """Tests column, top-level and dotted field expressions, and rejects injection."""
+    assert field_sql("updatedAt") == "updated_at"
+    assert field_sql("start") == "(data->>'start') COLLATE \"C\""
+    assert field_sql("substance.code") == "(data #>> '{substance,code}') COLLATE \"C\""
+    with pytest.raises(ValueError):
+        field_sql("start'; DROP TABLE patients; --")
+
+# --- Query Test Cases ---
+
+def test_query_combines_containment_range_and_ordering():
+    """Tests that equality and array filters share one JSONB containment test."""
+    pool, conn = fake_pool()
+    conn.execute.return_value.fetchall.return_value = []
+
+    PostgresEncounterRepository(pool).list(patient_id="p-1", practitioner_id="prac-1", start_from=NOW, limit=5)
+
+    sql, params = executed(conn)
+    assert sql == (
+        "SELECT * FROM encounters WHERE data @> %s AND (data->>'start') COLLATE \"C\" >= %s"
+        " ORDER BY (data->>'start') COLLATE \"C\" DESC, id DESC LIMIT %s"
+    )
+    assert params[0].obj == {"patientId": "p-1", "participantIds": ["prac-1"]}
+    assert params[1:] == ["2024-05-01T09:00:00.000000Z", 5]
+
+def test_start_after_seeks_past_the_anchor_row():
+    """Tests that a continued list compares (order field, id) with the anchor row instead of using OFFSET."""
+    pool, conn = fake_pool()
+    conn.execute.return_value.fetchone.return_value = {"value": "2024-05-01T09:00:00.000000Z"}
+    conn.execute.return_value.fetchall.return_value = []
+
+    PostgresEncounterRepository(pool).list(patient_id="p-1", limit=5, after="enc-9")
+
+    anchor_sql, anchor_params = executed(conn, 0)
+    sql, params = executed(conn, 1)
+    assert anchor_sql == "SELECT (data->>'start') COLLATE \"C\" AS value FROM encounters WHERE id = %s"
+    assert anchor_params == ["enc-9"]
+    assert "AND ((data->>'start') COLLATE \"C\", id) < (%s, %s) ORDER BY" in sql
+    assert params[1:] == ["2024-05-01T09:00:00.000000Z", "enc-9", 5]
+    assert "OFFSET" not in sql
+
+def test_start_after_deleted_row_raises_stale_cursor():
+    """Tests that continuing after a row that no longer exists is reported rather than restarting."""
+    pool, conn = fake_pool()
+    conn.execute.return_value.fetchone.return_value = None
+
+    with pytest.raises(StaleCursorError):
+        PostgresEncounterRepository(pool).list(patient_id="p-1", after="deleted")
+
+def test_list_returns_models_with_row_id():
+    """Tests that rows are converted back into schema models."""
+    pool, conn = fake_pool()
+    conn.execute.return_value.fetchall.return_value = [
+        make_row("team-1", {"patientId": "p-1", "name": "Sleep clinic", "members": [], "memberIds": []}),
+    ]
+
+    teams = PostgresCareTeamRepository(pool).list(patient_id="p-1")
+
+    assert teams[0].care_team_id == "team-1"
+    assert teams[0].created_at == NOW
+
+# --- Write Test Cases ---
+
+def test_create_maps_unique_violation_to_conflict():
+    """Tests that a unique index violation (e.g. a concurrent duplicate MRN) becomes a ConflictError."""
+    from psycopg import errors
+
+    pool, conn = fake_pool()
+    conn.execute.return_value.fetchall.return_value = []
+    violation = errors.UniqueViolation("duplicate key value violates unique constraint")
+    conn.execute.side_effect = [conn.execute.return_value, violation]
+    patient_in = schemas.PatientCreate(mrn="MRN-1", givenName="Ann", familyName="Lee", dob="1980-01-01")
+
+    with pytest.raises(ConflictError):
+        PostgresPatientRepository(pool).create(patient_in)
+
+def test_update_unknown_record_raises_not_found():
+    """Tests that an UPDATE matching no row raises NotFoundError."""
+    pool, conn = fake_pool()
+    conn.execute.return_value.fetchone.return_value = None
+
+    with pytest.raises(NotFoundError):
+        PostgresPatientRepository(pool).update("missing", schemas.PatientUpdate(givenName="Ann"))
+
+def test_unlink_observation_locks_row_and_removes_link():
+    """Tests that unlinking reads the encounter FOR UPDATE and writes the remaining IDs."""
+    pool, conn = fake_pool()
+    data = {"patientId": "p-1", "visitType": "ambulatory", "status": "finished", "start": "2024-05-01T09:00:00.000000Z",
+            "participants": [], "participantIds": [], "observationIds": ["obs-1", "obs-2"], "documents": []}
+    conn.execute.return_value.fetchone.return_value = make_row("enc-1", data)
+
+    PostgresEncounterRepository(pool).unlink_observation("enc-1", "obs-1")
+
+    select_sql, _ = executed(conn, 0)
+    update_sql, params = executed(conn, 1)
+    assert select_sql.endswith("FOR UPDATE")
+    assert update_sql.startswith("UPDATE encounters SET data = data || %s")
+    assert params[0].obj == {"observationIds": ["obs-2"], "version": 1}
@@ -0,0 +1,94 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+# To test the router, we need a FastAPI app instance
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_practitioner_repository
+from app.api.v1.endpoints import practitioners
+from app.dependencies.auth import get_current_user
+from app.repositories.base import ConflictError
+from app.repositories.practitioners import PractitionerRepository
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(practitioners.router, prefix="/api/v1/practitioners", tags=["Practitioners"])
+app.dependency_overrides[get_current_user] = lambda: {"uid": "staff-uid-123"}
+client = TestClient(app)
+
+VALID_NPI = "1234567893"
+FAKE_PRACTITIONER_ID = "practitioner-abc"
+
+def make_practitioner(**overrides):
+    data = {
+        "practitionerId": FAKE_PRACTITIONER_ID,
+        "givenName": "Malee",
+        "familyName": "Sukjai",
+        "npi": VALID_NPI,
+        "specialty": "sleep-medicine",
+        "createdAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+    }
+    data.update(overrides)
+    return schemas.Practitioner.model_validate(data)
+
+@pytest.fixture
+def mock_repo():
+    repo = MagicMock(spec=PractitionerRepository)
+    app.dependency_overrides[get_practitioner_repository] = lambda: repo
+    yield repo
+    app.dependency_overrides.pop(get_practitioner_repository, None)
+
+# --- Test Cases ---
+
+def test_create_practitioner_success(mock_repo):
+    """Tests successful registration of a practitioner."""
+    mock_repo.create.return_value = make_practitioner()
+
+    response = client.post("/api/v1/practitioners", json={
+        "given_name": "Malee", "family_name": "Sukjai", "npi": VALID_NPI, "specialty": "sleep-medicine"
+    })
+
+    assert response.status_code == 201
+    assert response.json()["practitioner_id"] == FAKE_PRACTITIONER_ID
+    assert response.json()["active"] is True
+
+def test_create_practitioner_invalid_npi(mock_repo):
+    """Tests that an NPI with a bad check digit is rejected with 422."""
+    response = client.post("/api/v1/practitioners", json={
+        "given_name": "Malee", "family_name": "Sukjai", "npi": "1234567890"
+    })
+
+    assert response.status_code == 422
+    mock_repo.create.assert_not_called()
+
+def test_create_practitioner_duplicate_npi(mock_repo):
+    """Tests 409 when the NPI is already registered."""
+    mock_repo.create.side_effect = ConflictError("A practitioner with NPI '1234567893' already exists.")
+
+    response = client.post("/api/v1/practitioners", json={
+        "given_name": "Malee", "family_name": "Sukjai", "npi": VALID_NPI
+    })
+
+    assert response.status_code == 409
+
+def test_list_practitioners_by_specialty(mock_repo):
+    """Tests that the specialty filter is passed to the repository."""
+    mock_repo.list.return_value = [make_practitioner()]
+
+    response = client.get("/api/v1/practitioners?specialty=sleep-medicine")
+
+    assert response.status_code == 200
+    assert len(response.json()["items"]) == 1
+    mock_repo.list.assert_called_once_with(limit=31, specialty="sleep-medicine", after=None)
+
+def test_get_practitioner_not_found(mock_repo):
+    """Tests 404 when the practitioner does not exist."""
+    mock_repo.get.return_value = None
+
+    response = client.get("/api/v1/practitioners/missing")
+
+    assert response.status_code == 404
@@ -0,0 +1,294 @@
+import pytest
+from datetime import datetime, timezone
+from types import SimpleNamespace
+from unittest.mock import MagicMock
+from zoneinfo import ZoneInfo
+
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+from firebase_admin import exceptions, messaging
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_patient_repository, get_push_token_repository
+from app.api.v1.endpoints import push_tokens
+from app.dependencies.auth import get_current_user
+from app.notifications import push
+from app.notifications.notifier import Notifier
+from app.notifications.push import FcmSender, OutgoingPush, PushDeliveryError, PushResult
+from app.notifications.templates import TemplateContext, render_push
+from app.repositories.memory import (
+    MemoryNotificationRepository,
+    MemoryOrganizationRepository,
+    MemoryPatientRepository,
+    MemoryPushTokenRepository,
+    MemoryStore,
+)
+from app.services.vitals import is_abnormal_reading
+from app.tasks import jobs
+from app.tasks.registry import run_task
+
+# --- Test Setup ---
+
+COMMON = TemplateContext(organization_name="Chiang Mai Sleep Clinic", portal_url="https://care.example.com", timezone=ZoneInfo("Asia/Bangkok"))
+TOKEN = "fcm-registration-token-" + "a" * 40
+
+def add_patient(patients, mrn="MRN-1"):
+    return patients.create(schemas.PatientCreate(givenName="Ann", familyName="Lee", dob="1980-01-01", mrn=mrn))
+
+def register(push_tokens_repo, patient, token=TOKEN, platform="android"):
+    return push_tokens_repo.register(patient.patient_id, schemas.PushTokenCreate(token=token, platform=platform))
+
+def queued_push(notifications, patient, template="result-ready"):
+    return notifications.create(schemas.NotificationCreate(
+        channel="push", template=template, recipient=f"patients/{patient.patient_id}/push-tokens",
+        patient_id=patient.patient_id, context={"patientName": "Ann"}, about="patients/p/lab-results/l",
+    ))
+
+def use_push_job(monkeypatch, store, result=None):
+    """Points the push job at `store` and a mock FCM sender returning `result`."""
+    sender = MagicMock()
+    sender.name, sender.sender = "fcm", "megacare-dev"
+    sender.send.return_value = result or PushResult(message_ids=["projects/megacare-dev/messages/1"])
+    monkeypatch.setattr(jobs, "get_push_sender", lambda: sender)
+    monkeypatch.setattr(jobs, "get_notification_repository", lambda: MemoryNotificationRepository(store))
+    monkeypatch.setattr(jobs, "get_patient_repository", lambda: MemoryPatientRepository(store))
+    monkeypatch.setattr(jobs, "get_push_token_repository", lambda: MemoryPushTokenRepository(store))
+    monkeypatch.setattr(jobs, "get_organization_repository", lambda: MemoryOrganizationRepository(store))
+    return sender
+
+def observation(code, value=None, unit=None, components=()):
+    now = datetime.now(timezone.utc)
+    return schemas.Observation(
+        observation_id="o-1", patient_id="p-1", code=schemas.Coding(system="http://loinc.org", code=code),
+        status="final", value=value, unit=unit, effective_at=now, created_at=now, updated_at=now,
+        components=[schemas.ObservationComponent(code=schemas.Coding(system="http://loinc.org", code=c), value=v, unit="mm[Hg]") for c, v in components],
+    )
+
+# --- Message Test Cases ---
+
+def test_push_is_the_subject_over_the_first_paragraph():
+    """Tests the title and body of a push notification, and the link the app opens."""
+    rendered = render_push("result-ready", {"patientName": "Ann"}, COMMON)
+
+    assert (rendered.title, rendered.body, rendered.link) == (
+        "A new result is ready to view", "A new result is ready for you to view.", "https://care.example.com",
+    )
+
+def test_reading_alert_names_no_value():
+    """Tests that the abnormal-reading alert, shown on a locked phone, says nothing about the reading."""
+    rendered = render_push("abnormal-reading", {"patientName": "Ann", "value": 180}, COMMON)
+
+    assert "180" not in rendered.body and "pressure" not in rendered.body.lower()
+
+def test_readings_outside_the_usual_range_are_abnormal():
+    """Tests the usual ranges, including blood pressure components, and that weight is never abnormal."""
+    assert is_abnormal_reading(observation("59408-5", 88, "%"))
+    assert not is_abnormal_reading(observation("59408-5", 97, "%"))
+    assert is_abnormal_reading(observation("85354-9", components=[("8480-6", 172), ("8462-4", 85)]))
+    assert not is_abnormal_reading(observation("85354-9", components=[("8480-6", 120), ("8462-4", 80)]))
+    assert not is_abnormal_reading(observation("29463-7", 250, "kg"))
+
+# --- Sender Test Cases ---
+
+def fcm_answering(monkeypatch, *responses):
+    calls = []
+    def send_each(messages, app=None):
+        calls.append(messages)
+        return SimpleNamespace(responses=list(responses))
+    monkeypatch.setattr(messaging, "send_each", send_each)
+    return calls
+
+def test_fcm_sender_sorts_results_by_device(monkeypatch):
+    """Tests that accepted, unregistered and unavailable devices are told apart."""
+    calls = fcm_answering(
+        monkeypatch,
+        SimpleNamespace(success=True, message_id="m-1", exception=None),
+        SimpleNamespace(success=False, message_id=None, exception=messaging.UnregisteredError("gone")),
+        SimpleNamespace(success=False, message_id=None, exception=exceptions.UnavailableError("later")),
+    )
+    message = OutgoingPush(notification_id="n-1", tokens=("t-1", "t-2", "t-3"), title="Title", body="Body", data={"notificationId": "n-1"})
+
+    result = FcmSender("megacare-dev", 3600).send(message)
+
+    assert (result.message_ids, result.invalid_tokens, result.retryable) == (["m-1"], ["t-2"], 1)
+    assert [m.token for m in calls[0]] == ["t-1", "t-2", "t-3"]
+    assert calls[0][0].data == {"notificationId": "n-1"}
+
+def test_fcm_outage_is_retryable(monkeypatch):
+    """Tests that FCM failing the whole batch raises PushDeliveryError."""
+    def send_each(messages, app=None):
+        raise exceptions.UnavailableError("down")
+    monkeypatch.setattr(messaging, "send_each", send_each)
+
+    with pytest.raises(PushDeliveryError):
+        FcmSender("megacare-dev", 3600).send(OutgoingPush(notification_id="n-1", tokens=("t-1",), title="T", body="B", data={}))
+
+# --- Notifier Test Cases ---
+
+def test_notifier_pushes_to_registered_patients_only():
+    """Tests that a push is queued for a patient with a device, and none for one without."""
+    store = MemoryStore()
+    patients = MemoryPatientRepository(store)
+    tokens = MemoryPushTokenRepository(store)
+    tasks = MagicMock()
+    notifier = Notifier(MemoryNotificationRepository(store), tasks, email=False, push_tokens=tokens)
+    patient = add_patient(patients)
+
+    assert notifier.notify("result-ready", patient, {}) == []
+
+    register(tokens, patient)
+    [pushed] = notifier.notify("result-ready", patient, {})
+    assert (pushed.channel, pushed.recipient) == ("push", f"patients/{patient.patient_id}/push-tokens")
+    assert tasks.enqueue.call_args.args[0] == jobs.PUSH_NOTIFICATION_TASK
+
+def test_categories_turned_off_are_not_pushed():
+    """Tests per-category preferences: appointments off still leaves messages on."""
+    store = MemoryStore()
+    patients = MemoryPatientRepository(store)
+    tokens = MemoryPushTokenRepository(store)
+    notifier = Notifier(MemoryNotificationRepository(store), MagicMock(), email=False, push_tokens=tokens)
+    patient = add_patient(patients)
+    register(tokens, patient)
+
+    patient = patients.update_push_preferences(patient.patient_id, schemas.PushPreferencesUpdate(appointments=False))
+
+    assert notifier.notify("appointment-reminder", patient, {"start": "2026-10-15T09:00:00+00:00"}) == []
+    assert len(notifier.notify("result-ready", patient, {})) == 1
+
+def test_reminders_are_not_emailed():
+    """Tests that push-only templates skip email even when it is configured."""
+    store = MemoryStore()
+    patients = MemoryPatientRepository(store)
+    patient = patients.create(schemas.PatientCreate(
+        givenName="Ann", familyName="Lee", dob="1980-01-01", mrn="MRN-1", contact={"email": "ann.lee@example.com"},
+    ))
+    notifier = Notifier(MemoryNotificationRepository(store), MagicMock(), email=True, push_tokens=MemoryPushTokenRepository(store))
+
+    assert notifier.notify("abnormal-reading", patient, {}) == []
+    assert [n.channel for n in notifier.notify("result-ready", patient, {})] == ["email"]
+
+def test_push_preferences_keep_the_sms_opt_out():
+    """Tests that changing push categories and the SMS opt-out do not overwrite each other."""
+    patients = MemoryPatientRepository(MemoryStore())
+    patient = patients.create(schemas.PatientCreate(
+        givenName="Ann", familyName="Lee", dob="1980-01-01", mrn="MRN-1", contact={"phoneNumber": "+66812345678"},
+    ))
+
+    patients.set_sms_opt_out("+66812345678", True)
+    patients.update_push_preferences(patient.patient_id, schemas.PushPreferencesUpdate(abnormalReadings=False))
+    patients.set_sms_opt_out("+66812345678", True)
+
+    preferences = patients.get(patient.patient_id).contact_preferences
+    assert preferences.sms_opt_out is True
+    assert (preferences.push.abnormal_readings, preferences.push.messages) == (False, True)
+
+# --- Token Registration Test Cases ---
+
+def test_registering_a_token_again_moves_it():
+    """Tests that one installation has one registration, held by the patient who registered it last."""
+    store = MemoryStore()
+    patients = MemoryPatientRepository(store)
+    tokens = MemoryPushTokenRepository(store)
+    first, second = add_patient(patients), add_patient(patients, mrn="MRN-2")
+
+    before = register(tokens, first)
+    after = register(tokens, second, platform="ios")
+
+    assert after.push_token_id == before.push_token_id
+    assert tokens.list(first.patient_id) == []
+    assert [t.platform for t in tokens.list(second.patient_id)] == ["ios"]
+
+# --- Send Job Test Cases ---
+
+def test_push_job_sends_to_every_device(monkeypatch):
+    """Tests that a queued push goes to the patient's devices and is marked sent from the project."""
+    store = MemoryStore()
+    patient = add_patient(MemoryPatientRepository(store))
+    register(MemoryPushTokenRepository(store), patient)
+    register(MemoryPushTokenRepository(store), patient, token=TOKEN + "b", platform="ios")
+    pushed = queued_push(MemoryNotificationRepository(store), patient)
+    sender = use_push_job(monkeypatch, store)
+
+    assert run_task(jobs.PUSH_NOTIFICATION_TASK, {"notificationId": pushed.notification_id}) is True
+
+    message = sender.send.call_args.args[0]
+    assert sorted(message.tokens) == sorted([TOKEN, TOKEN + "b"])
+    assert message.data == {
+        "notificationId": pushed.notification_id, "template": "result-ready", "category": "messages",
+        "about": "patients/p/lab-results/l",
+    }
+    sent = MemoryNotificationRepository(store).get(pushed.notification_id)
+    assert (sent.status, sent.provider, sent.sender, sent.subject) == ("sent", "fcm", "megacare-dev", "A new result is ready to view")
+
+def test_unregistered_tokens_are_removed(monkeypatch):
+    """Tests that tokens FCM reports as unregistered are deleted, and a push no device took fails."""
+    store = MemoryStore()
+    patient = add_patient(MemoryPatientRepository(store))
+    register(MemoryPushTokenRepository(store), patient)
+    pushed = queued_push(MemoryNotificationRepository(store), patient)
+    use_push_job(monkeypatch, store, result=PushResult(invalid_tokens=[TOKEN]))
+
+    run_task(jobs.PUSH_NOTIFICATION_TASK, {"notificationId": pushed.notification_id})
+
+    assert MemoryPushTokenRepository(store).list(patient.patient_id) == []
+    assert MemoryNotificationRepository(store).get(pushed.notification_id).status == "failed"
+
+def test_push_job_retries_while_fcm_is_unavailable(monkeypatch):
+    """Tests that a push no device could take for now is left queued for a retry."""
+    store = MemoryStore()
+    patient = add_patient(MemoryPatientRepository(store))
+    register(MemoryPushTokenRepository(store), patient)
+    pushed = queued_push(MemoryNotificationRepository(store), patient)
+    use_push_job(monkeypatch, store, result=PushResult(retryable=1))
+
+    with pytest.raises(PushDeliveryError):
+        run_task(jobs.PUSH_NOTIFICATION_TASK, {"notificationId": pushed.notification_id})
+
+    assert MemoryNotificationRepository(store).get(pushed.notification_id).status == "queued"
+
+# --- Endpoint Test Cases ---
+
+app = FastAPI()
+app.include_router(push_tokens.router, prefix="/patients")
+client = TestClient(app)
+
+@pytest.fixture
+def store():
+    store = MemoryStore()
+    app.dependency_overrides[get_patient_repository] = lambda: MemoryPatientRepository(store)
+    app.dependency_overrides[get_push_token_repository] = lambda: MemoryPushTokenRepository(store)
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "patient-user"}
+    yield store
+    app.dependency_overrides.clear()
+
+def test_registered_tokens_are_never_returned(store):
+    """Tests registration and listing, without the token in either response."""
+    patient = add_patient(MemoryPatientRepository(store))
+
+    response = client.post(f"/patients/{patient.patient_id}/push-tokens", json={"token": TOKEN, "platform": "android", "appVersion": "2.4.0"})
+    assert response.status_code == 201
+    assert "token" not in response.json()
+
+    [registered] = client.get(f"/patients/{patient.patient_id}/push-tokens").json()
+    assert (registered["platform"], registered["app_version"]) == ("android", "2.4.0")
+    assert "token" not in registered
+
+    assert client.delete(f"/patients/{patient.patient_id}/push-tokens/{registered['push_token_id']}").status_code == 204
+    assert client.get(f"/patients/{patient.patient_id}/push-tokens").json() == []
+
+def test_preferences_are_patched_by_category(store):
+    """Tests that categories left out of a PATCH are unchanged."""
+    patient = add_patient(MemoryPatientRepository(store))
+
+    response = client.patch(f"/patients/{patient.patient_id}/push-preferences", json={"abnormalReadings": False})
+
+    assert response.status_code == 200
+    assert response.json() == {"messages": True, "appointments": True, "abnormal_readings": False}
+    assert client.get(f"/patients/{patient.patient_id}/push-preferences").json()["abnormal_readings"] is False
+
+def test_push_allowed_reads_the_template_category():
+    """Tests that templates without a push category are never pushed."""
+    patient = add_patient(MemoryPatientRepository(MemoryStore()))
+
+    assert push.push_allowed(patient, "result-ready")
+    assert not push.push_allowed(patient, "unknown-template")
@@ -0,0 +1,145 @@
+import pytest
+from fastapi.testclient import TestClient
+
+from fastapi import FastAPI
+from app.middleware.rate_limit import RateLimitMiddleware, caller_key
+from app.ratelimit.buckets import FallbackTokenBuckets, MemoryTokenBuckets, RateLimitRule, match_rule, parse_rules
+from app.ratelimit.redis_buckets import RedisTokenBuckets
+
+# --- Test Setup ---
+
+class FakeClock:
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+class BrokenBuckets(MemoryTokenBuckets):
+    def take(self, key, capacity, refill_per_second):
+        raise ConnectionError("redis unavailable")
+
+app = FastAPI()
+
+@app.get("/api/v1/patients")
+def list_patients():
+    return []
+
+@app.get("/health")
+def health():
+    return {"status": "ok"}
+
+def with_principal(inner):
+    """Stands in for AuthenticationMiddleware: the X-Test-User header becomes the verified caller."""
+    async def asgi(scope, receive, send):
+        user = dict(scope.get("headers", [])).get(b"x-test-user")
+        if user:
+            scope.setdefault("state", {})["principal"] = {"uid": user.decode()}
+        await inner(scope, receive, send)
+    return asgi
+
+def make_client(per_minute=60, burst=2):
+    buckets = MemoryTokenBuckets(clock=FakeClock())
+    limited = RateLimitMiddleware(app, rules=[RateLimitRule("/api/v1", per_minute, burst)], buckets_factory=lambda: buckets)
+    return TestClient(with_principal(limited))
+
+# --- Rule Test Cases ---
+
+def test_parse_rules_reads_rates_and_bursts():
+    """Tests that rules are parsed with the burst defaulting to the rate, longest prefix first."""
+    rules = parse_rules("/api/v1=600:120, /api/v1/auth/=20:5,/fhir=300")
+
+    assert rules == [
+        RateLimitRule("/api/v1/auth", 20, 5),
+        RateLimitRule("/api/v1", 600, 120),
+        RateLimitRule("/fhir", 300, 300),
+    ]
+
+def test_parse_rules_rejects_malformed_entries():
+    """Tests that entries without a prefix or numeric limits are refused."""
+    for spec in ["api/v1=10", "/api/v1=ten", "/api/v1=10:x", "/api/v1"]:
+        with pytest.raises(ValueError):
+            parse_rules(spec)
+
+def test_match_rule_prefers_longest_prefix_on_segment_boundaries():
+    """Tests that the most specific group applies and prefixes only match whole segments."""
+    rules = parse_rules("/api/v1=600,/api/v1/auth=20")
+
+    assert match_rule(rules, "/api/v1/auth/line").per_minute == 20
+    assert match_rule(rules, "/api/v1/patients").per_minute == 600
+    assert match_rule(rules, "/api/v1x") is None
+    assert match_rule(rules, "/health") is None
+
+# --- Bucket Test Cases ---
+
+def test_memory_buckets_allow_burst_then_refill():
+    """Tests that a bucket allows its burst, refuses with the wait for the next token, then refills."""
+    clock = FakeClock()
+    buckets = MemoryTokenBuckets(clock=clock)
+
+    assert buckets.take("user:a", 2, 0.5) == (True, 0)
+    assert buckets.take("user:a", 2, 0.5) == (True, 0)
+    assert buckets.take("user:a", 2, 0.5) == (False, 2)
+    assert buckets.take("user:b", 2, 0.5) == (True, 0)
+
+    clock.now += 2
+    assert buckets.take("user:a", 2, 0.5) == (True, 0)
+
+def test_fallback_buckets_limit_locally_when_store_fails():
+    """Tests that a failing shared store degrades to per-process limits instead of errors."""
+    buckets = FallbackTokenBuckets(BrokenBuckets(), MemoryTokenBuckets(clock=FakeClock()))
+
+    assert buckets.take("user:a", 1, 1.0) == (True, 0)
+    assert buckets.take("user:a", 1, 1.0) == (False, 1)
+
+def test_redis_buckets_run_script_on_prefixed_key():
+    """Tests that Redis buckets run the take script on a namespaced key and derive the wait."""
+    calls = []
+    results = iter([[1, 1500], [0, 250]])
+    class FakeRedis:
+        def register_script(self, script):
+            def run(keys, args):
+                calls.append((keys, args))
+                return next(results)
+            return run
+    buckets = RedisTokenBuckets("redis://unused", key_prefix="megacare", client=FakeRedis())
+
+    assert buckets.take("/api/v1|user:a", 10, 0.25) == (True, 0)
+    assert buckets.take("/api/v1|user:a", 10, 0.25) == (False, 3)
+    assert calls[0] == (["megacare:ratelimit:/api/v1|user:a"], [10, 0.25])
+
+# --- Middleware Test Cases ---
+
+def test_caller_key_prefers_api_key_then_user_then_address():
+    """Tests that requests are counted against the key, user, service or client address, ignoring hops the client added to X-Forwarded-For."""
+    assert caller_key({"state": {"principal": {"uid": "api-key:k1", "apiKeyId": "k1"}}}) == "key:k1"
+    assert caller_key({"state": {"principal": {"uid": "user-1"}}}) == "user:user-1"
+    assert caller_key({"state": {"service_caller": {"email": "billing@example.com"}}}) == "service:billing@example.com"
+    assert caller_key({"headers": [(b"x-forwarded-for", b"203.0.113.9, 198.51.100.4")], "client": ("10.0.0.1", 443)}) == "ip:198.51.100.4"
+    assert caller_key({"headers": [], "client": ("198.51.100.4", 443)}) == "ip:198.51.100.4"
+
+def test_middleware_refuses_over_limit_with_retry_after():
+    """Tests that requests beyond the burst get 429 with Retry-After."""
+    client = make_client(per_minute=60, burst=2)
+    headers = {"X-Test-User": "user-1"}
+
+    assert client.get("/api/v1/patients", headers=headers).status_code == 200
+    assert client.get("/api/v1/patients", headers=headers).status_code == 200
+    limited = client.get("/api/v1/patients", headers=headers)
+
+    assert limited.status_code == 429
+    assert limited.headers["Retry-After"] == "1"
+
+def test_middleware_keeps_separate_quotas_per_caller():
+    """Tests that one caller exhausting its quota does not limit another."""
+    client = make_client(burst=1)
+
+    assert client.get("/api/v1/patients", headers={"X-Test-User": "user-1"}).status_code == 200
+    assert client.get("/api/v1/patients", headers={"X-Test-User": "user-1"}).status_code == 429
+    assert client.get("/api/v1/patients", headers={"X-Test-User": "user-2"}).status_code == 200
+
+def test_middleware_leaves_unmatched_routes_alone():
+    """Tests that routes outside every rule, such as probes, are never limited."""
+    client = make_client(burst=1)
+
+    assert all(client.get("/health").status_code == 200 for _ in range(3))
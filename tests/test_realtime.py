@@ -0,0 +1,276 @@
+import asyncio
+import json
+from datetime import datetime, timedelta, timezone
+
+from app.api.v1 import schemas
+from app.authz.mfa import MFA_CLAIM
+from app.core.config import get_settings
+from app.realtime import session as session_module
+from app.realtime.hub import MemoryHub, Member
+from app.realtime.redis_hub import RedisHub
+from app.realtime.session import (
+    CLOSE_FORBIDDEN,
+    CLOSE_IDLE,
+    CLOSE_TOO_MANY_CONNECTIONS,
+    CLOSE_TRY_AGAIN_LATER,
+    CLOSE_UNAUTHENTICATED,
+    ConnectionLimits,
+    RealtimeSession,
+)
+from app.repositories.memory import MemoryAppointmentRepository, MemoryAuditEventRepository, MemoryStore
+
+# --- Test Setup ---
+
+TOKENS = {
+    "patient-token": {"uid": "line-1", "roles": ["patient"], "patientId": "p-1"},
+    "other-patient-token": {"uid": "line-2", "roles": ["patient"], "patientId": "p-2"},
+    "clinician-token": {"uid": "doc-1", "roles": ["clinician"]},
+    "verified-clinician-token": {"uid": "doc-2", "roles": ["clinician"], MFA_CLAIM: 1},
+}
+
+class FakeVerifier:
+    def verify(self, token):
+        if token not in TOKENS:
+            raise ValueError("unknown token")
+        return TOKENS[token]
+
+class FakeWebSocket:
+    """Stands in for a Starlette WebSocket: messages put on `incoming` are received, sent ones collected."""
+
+    def __init__(self, authorization=None):
+        headers = [(b"authorization", f"Bearer {authorization}".encode())] if authorization else []
+        self.scope = {"type": "websocket", "headers": headers, "client": ("10.0.0.7", 51234)}
+        self.headers = {"authorization": f"Bearer {authorization}"} if authorization else {}
+        self.incoming = asyncio.Queue()
+        self.sent = []
+        self.closed = None
+
+    def put(self, **message):
+        self.incoming.put_nowait({"type": "websocket.receive", "text": json.dumps(message)})
+
+    def disconnect(self):
+        self.incoming.put_nowait({"type": "websocket.disconnect", "code": 1000})
+
+    async def receive(self):
+        return await self.incoming.get()
+
+    async def send_text(self, text):
+        self.sent.append(json.loads(text))
+
+    async def close(self, code=1000, reason=None):
+        self.closed = code
+        self.disconnect()
+
+    def of_type(self, kind):
+        return [m for m in self.sent if m["type"] == kind]
+
+class FakeRedis:
+    """The hash and pub/sub commands RedisHub uses, shared by hubs as one Redis is by instances."""
+
+    def __init__(self):
+        self.hashes = {}
+        self.subscribers = []
+
+    async def hset(self, key, field, value):
+        self.hashes.setdefault(key, {})[field] = value
+
+    async def expire(self, key, seconds):
+        pass
+
+    async def hgetall(self, key):
+        return dict(self.hashes.get(key, {}))
+
+    async def hdel(self, key, *fields):
+        for field in fields:
+            self.hashes.get(key, {}).pop(field, None)
+
+    async def publish(self, channel, data):
+        for pubsub in self.subscribers:
+            if channel in pubsub.channels:
+                pubsub.queue.put_nowait({"type": "message", "channel": channel, "data": data})
+
+    def pubsub(self):
+        pubsub = FakePubSub()
+        self.subscribers.append(pubsub)
+        return pubsub
+
+class FakePubSub:
+    def __init__(self):
+        self.channels = set()
+        self.queue = asyncio.Queue()
+
+    async def subscribe(self, channel):
+        self.channels.add(channel)
+
+    async def unsubscribe(self, channel):
+        self.channels.discard(channel)
+
+    async def listen(self):
+        while self.channels:
+            yield await self.queue.get()
+
+def setup(monkeypatch):
+    store = MemoryStore()
+    appointments = MemoryAppointmentRepository(store)
+    audit = MemoryAuditEventRepository(store)
+    monkeypatch.setattr(session_module, "get_token_verifier", lambda: FakeVerifier())
+    monkeypatch.setattr(session_module, "get_appointment_repository", lambda: appointments)
+    monkeypatch.setattr(session_module, "get_audit_event_repository", lambda: audit)
+    start = datetime.now(timezone.utc) + timedelta(hours=1)
+    visit = appointments.create(schemas.AppointmentCreate(patientId="p-1", practitionerId="prac-1", start=start, end=start + timedelta(minutes=30)))
+    return visit, audit
+
+def open_session(hub, limits, websocket, heartbeat=60.0, idle_timeout=120.0):
+    session = RealtimeSession(websocket, hub, limits)
+    session.heartbeat, session.idle_timeout = heartbeat, idle_timeout
+    return session
+
+async def settle():
+    for _ in range(20):
+        await asyncio.sleep(0)
+
+# --- Session Test Cases ---
+
+def test_visit_participants_see_each_other_and_exchange_signals(monkeypatch):
+    """Tests that patient and clinician join the visit's room, get presence, and relay typing and signals."""
+    visit, audit = setup(monkeypatch)
+    room = f"appointments/{visit.appointment_id}"
+
+    async def scenario():
+        hub, limits = MemoryHub(), ConnectionLimits(10, 5)
+        patient, clinician = FakeWebSocket(), FakeWebSocket(authorization="clinician-token")
+        patient.put(type="authenticate", token="patient-token")
+        tasks = [asyncio.create_task(open_session(hub, limits, ws).run()) for ws in (patient, clinician)]
+        patient.put(type="join", room=room)
+        await settle()
+        clinician.put(type="join", room=room)
+        await settle()
+        doctor = clinician.of_type("welcome")[0]["connectionId"]
+        patient.put(type="typing", room=room, typing=True)
+        patient.put(type="signal", room=room, to=doctor, data={"sdp": "offer"})
+        clinician.put(type="signal", room=room, data={"candidate": "c-1"})
+        await settle()
+        clinician.disconnect()
+        await settle()
+        patient.disconnect()
+        await asyncio.gather(*tasks)
+        return patient, clinician, doctor
+
+    patient, clinician, doctor = asyncio.run(scenario())
+
+    assert [m["uid"] for m in clinician.of_type("joined")[0]["members"]] == ["line-1", "doc-1"]
+    assert [(p["event"], p["member"]["role"]) for p in patient.of_type("presence")] == [("joined", "staff"), ("left", "staff")]
+    [typing] = clinician.of_type("typing")
+    assert typing["typing"] is True and typing["from"]["role"] == "patient"
+    assert clinician.of_type("signal")[0]["data"] == {"sdp": "offer"}
+    assert patient.of_type("signal")[0]["from"]["connectionId"] == doctor
+    assert {(e.actor_uid, e.method, e.patient_id) for e in audit.list(resource_type="appointments")} == {("line-1", "WS", "p-1"), ("doc-1", "WS", "p-1")}
+
+def test_patients_cannot_join_other_patients_visits(monkeypatch):
+    """Tests that joining an appointment the caller may not read is answered with an error and the connection stays open."""
+    visit, _ = setup(monkeypatch)
+
+    async def scenario():
+        websocket = FakeWebSocket(authorization="other-patient-token")
+        task = asyncio.create_task(open_session(MemoryHub(), ConnectionLimits(10, 5), websocket).run())
+        websocket.put(type="join", room=f"appointments/{visit.appointment_id}")
+        websocket.put(type="join", room="patients/p-1")
+        websocket.put(type="typing", room=f"appointments/{visit.appointment_id}")
+        websocket.put(type="ping")
+        await settle()
+        websocket.disconnect()
+        await task
+        return websocket
+
+    websocket = asyncio.run(scenario())
+
+    assert [e["code"] for e in websocket.of_type("error")] == ["forbidden", "invalid-room", "not-joined"]
+    assert websocket.of_type("pong") and websocket.closed is None
+
+def test_unauthenticated_connections_are_closed(monkeypatch):
+    """Tests that a connection whose first message is not a valid authenticate message is closed with 4401."""
+    setup(monkeypatch)
+
+    async def scenario(message):
+        websocket = FakeWebSocket()
+        websocket.put(**message)
+        await open_session(MemoryHub(), ConnectionLimits(10, 5), websocket).run()
+        return websocket
+
+    assert asyncio.run(scenario({"type": "join", "room": "appointments/a-1"})).closed == CLOSE_UNAUTHENTICATED
+    assert asyncio.run(scenario({"type": "authenticate", "token": "forged"})).closed == CLOSE_UNAUTHENTICATED
+
+def test_staff_without_a_second_factor_are_closed(monkeypatch):
+    """Tests that a clinician who has not proved a second factor is closed with 4403 once MFA is required."""
+    setup(monkeypatch)
+    monkeypatch.setattr(get_settings(), "session_signing_key", "s" * 32)
+    monkeypatch.setattr(get_settings(), "mfa_required_roles", "clinician")
+
+    async def scenario(token):
+        websocket = FakeWebSocket(authorization=token)
+        websocket.disconnect()
+        await open_session(MemoryHub(), ConnectionLimits(10, 5), websocket).run()
+        return websocket
+
+    assert asyncio.run(scenario("clinician-token")).closed == CLOSE_FORBIDDEN
+    assert asyncio.run(scenario("verified-clinician-token")).closed is None
+    assert asyncio.run(scenario("patient-token")).closed is None
+
+def test_connections_over_the_limits_are_refused(monkeypatch):
+    """Tests that a user's connections beyond the per-user limit close with 4429, and a full worker with 1013."""
+    setup(monkeypatch)
+    limits = ConnectionLimits(2, 1)
+
+    async def scenario(token):
+        websocket = FakeWebSocket(authorization=token)
+        websocket.disconnect()
+        await open_session(MemoryHub(), limits, websocket).run()
+        return websocket
+
+    assert limits.acquire("line-1") is None
+    assert asyncio.run(scenario("patient-token")).closed == CLOSE_TOO_MANY_CONNECTIONS
+    assert asyncio.run(scenario("clinician-token")).closed is None
+    assert limits.acquire("doc-1") is None
+    assert asyncio.run(scenario("other-patient-token")).closed == CLOSE_TRY_AGAIN_LATER
+    limits.release("line-1")
+    limits.release("doc-1")
+    assert limits.by_user == {}
+
+def test_idle_connections_are_closed(monkeypatch):
+    """Tests that the server pings and closes a connection it has not heard from within the idle timeout."""
+    setup(monkeypatch)
+
+    async def scenario():
+        websocket = FakeWebSocket(authorization="patient-token")
+        await asyncio.wait_for(open_session(MemoryHub(), ConnectionLimits(10, 5), websocket, heartbeat=0.01, idle_timeout=0.03).run(), timeout=2)
+        return websocket
+
+    websocket = asyncio.run(scenario())
+
+    assert websocket.of_type("ping")
+    assert websocket.closed == CLOSE_IDLE
+
+# --- Redis Hub Test Cases ---
+
+def test_redis_rooms_span_instances():
+    """Tests that members joined on two hubs sharing Redis see each other and receive each other's messages."""
+    redis = FakeRedis()
+    first, second = RedisHub("redis://unused", client=redis), RedisHub("redis://unused", client=redis)
+    patient, doctor = Member("c-1", "line-1", "patient"), Member("c-2", "doc-1", "staff")
+
+    async def scenario():
+        received = []
+        async def deliver(message):
+            received.append(message)
+        await first.join("appointments/a-1", patient, deliver)
+        members = await second.join("appointments/a-1", doctor, deliver)
+        await second.publish("appointments/a-1", {"type": "signal"}, sender="c-2")
+        await settle()
+        await first.leave("appointments/a-1", "c-1")
+        return members, received, await second._members("appointments/a-1")
+
+    members, received, remaining = asyncio.run(scenario())
+
+    assert {m.uid for m in members} == {"line-1", "doc-1"}
+    assert received == [{"type": "signal"}]
+    assert remaining == [doctor]
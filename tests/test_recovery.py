@@ -0,0 +1,48 @@
+from fastapi import FastAPI, HTTPException
+from fastapi.testclient import TestClient
+
+from app.middleware.recovery import RecoveryMiddleware
+from app.middleware.request_context import RequestContextMiddleware
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.add_middleware(RecoveryMiddleware)
+app.add_middleware(RequestContextMiddleware)
+
+@app.get("/boom")
+def boom():
+    raise RuntimeError("database password is hunter2")
+
+@app.get("/not-found")
+def not_found():
+    raise HTTPException(status_code=404, detail="Nothing here")
+
+client = TestClient(app)
+
+# --- Test Cases ---
+
+def test_unhandled_exception_returns_sanitized_problem(caplog):
+    """Tests that an unhandled exception becomes a generic problem+json 500."""
+    response = client.get("/boom")
+
+    assert response.status_code == 500
+    assert response.headers["content-type"] == "application/problem+json"
+    body = response.json()
+    assert body["status"] == 500
+    assert body["title"] == "Internal Server Error"
+    assert body["requestId"] == response.headers["X-Request-ID"]
+    # The internal error message must never be leaked to the client.
+    assert "hunter2" not in response.text
+
+    # ...but it must be logged with the stack trace and flagged for Error Reporting.
+    error_records = [r for r in caplog.records if getattr(r, "report_error", False)]
+    assert len(error_records) == 1
+    assert error_records[0].exc_info is not None
+
+def test_http_exceptions_are_not_intercepted():
+    """Tests that regular HTTPExceptions keep FastAPI's default handling."""
+    response = client.get("/not-found")
+
+    assert response.status_code == 404
+    assert response.json() == {"detail": "Nothing here"}
@@ -0,0 +1,81 @@
+import json
+import logging
+import sys
+
+from app.core.logging_config import CloudLoggingFormatter
+from app.core.redaction import MASK, RedactionLogFilter, redact_fields, redact_text
+
+# --- Helpers ---
+
+def make_record(msg, *args, **extra):
+    record = logging.LogRecord("test.logger", logging.ERROR, __file__, 42, msg, args, None, func="fn")
+    for key, value in extra.items():
+        setattr(record, key, value)
+    return record
+
+# --- Free Text Test Cases ---
+
+def test_values_of_phi_fields_are_masked():
+    """Tests masking after PHI field names in messages, reprs, JSON and pydantic errors, keeping IDs."""
+    assert redact_text("A patient with MRN 'MRN-1' already exists.") == f"A patient with MRN {MASK} already exists."
+    assert redact_text("{'given_name': 'Ann', 'patient_id': 'p-1'}") == f"{{'given_name': {MASK}, 'patient_id': 'p-1'}}"
+    assert redact_text('{"familyName": "Lee", "reason": "chest pain, at night"}') == f'{{"familyName": {MASK}, "reason": {MASK}}}'
+
+    error = "Field required [type=missing, input_value={'familyName': 'Lee', 'dob': '1980-01-31'}, input_type=dict]"
+    assert "Lee" not in redact_text(error) and "1980" not in redact_text(error)
+
+def test_dates_of_birth_contact_details_and_query_values_are_masked():
+    """Tests masking by shape, sparing timestamps, service accounts and safe query parameters."""
+    text = redact_text(
+        "Born 1980-01-31 (31/01/1980), +66812345678, 081 234 5678, ann.lee@example.com; "
+        "GET /api/v1/patients?familyName=Lee&limit=20 by tasks@mega-care.iam.gserviceaccount.com "
+        "at 2024-05-01T09:00:00Z"
+    )
+
+    for phi in ("1980", "+66812345678", "081 234 5678", "ann.lee@example.com", "familyName=Lee"):
+        assert phi not in text
+    for kept in ("limit=20", "tasks@mega-care.iam.gserviceaccount.com", "2024-05-01T09:00:00Z"):
+        assert kept in text
+
+# --- Structured Field Test Cases ---
+
+def test_structured_fields_are_masked_unless_allowlisted():
+    """Tests that only SAFE_FIELDS and IDs keep their values, and that safe strings are still scrubbed."""
+    redacted = redact_fields({
+        "requestMethod": "POST",
+        "requestUrl": "/api/v1/patients?mrn=MRN-1",
+        "status": 409,
+        "patientId": "p-1",
+        "patient": {"givenName": "Ann", "organizationId": "clinic-a"},
+        "tags": ["Ann", "Lee"],
+    })
+
+    assert redacted == {
+        "requestMethod": "POST",
+        "requestUrl": f"/api/v1/patients?mrn={MASK}",
+        "status": 409,
+        "patientId": "p-1",
+        "patient": {"givenName": MASK, "organizationId": "clinic-a"},
+        "tags": [MASK, MASK],
+    }
+
+# --- Log Filter Test Cases ---
+
+def test_log_records_are_redacted_before_they_are_formatted():
+    """Tests the message arguments, the traceback and the extras of an Error Reporting entry."""
+    try:
+        raise ValueError("A patient with MRN 'MRN-1' already exists.")
+    except ValueError:
+        record = make_record(
+            "Creating %s failed", "patient with dob=1980-01-31",
+            report_error=True,
+            http_request={"requestMethod": "POST", "requestUrl": "/api/v1/patients?mrn=MRN-1", "body": "Ann"},
+        )
+        record.exc_info = sys.exc_info()
+
+    assert RedactionLogFilter().filter(record)
+    entry = json.loads(CloudLoggingFormatter().format(record))
+
+    assert entry["message"].startswith(f"Creating patient with dob={MASK} failed\nTraceback")
+    assert "MRN-1" not in json.dumps(entry)
+    assert entry["context"]["httpRequest"] == {"requestMethod": "POST", "requestUrl": f"/api/v1/patients?mrn={MASK}", "body": MASK}
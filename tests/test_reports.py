@@ -0,0 +1,161 @@
+from datetime import date, datetime, timedelta, timezone
+from zoneinfo import ZoneInfo
+
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_appointment_repository, get_organization_repository
+from app.api.v1.endpoints import reports
+from app.dependencies.auth import get_current_user
+from app.repositories.memory import MemoryAppointmentRepository, MemoryOrganizationRepository, MemoryStore
+from app.services.reports import (
+    appointment_volume,
+    no_show_rates,
+    open_refill_requests,
+    period_bounds,
+    telemetry_adherence,
+    to_csv,
+)
+
+# --- Test Setup ---
+
+BANGKOK = ZoneInfo("Asia/Bangkok")
+NOW = datetime(2026, 10, 10, 12, 0, tzinfo=timezone.utc)
+
+def appointment(appointment_id, start, status="booked", practitioner_id="prac-1"):
+    return schemas.Appointment(
+        appointmentId=appointment_id, patientId="p-1", practitionerId=practitioner_id, start=start,
+        end=start + timedelta(minutes=30), status=status, createdAt=NOW, updatedAt=NOW,
+    )
+
+def reading(patient_id, effective_at, code="59408-5"):
+    return schemas.Observation(
+        observationId=f"o-{patient_id}-{effective_at.isoformat()}", patientId=patient_id,
+        code=schemas.Coding(system="http://loinc.org", code=code), value=95, effectiveAt=effective_at,
+        createdAt=NOW, updatedAt=NOW,
+    )
+
+def refill(refill_request_id, status, created_at):
+    return schemas.RefillRequest(
+        refillRequestId=refill_request_id, patientId="p-1", medicationId="m-1", status=status, requestedBy="p-1",
+        createdAt=created_at, updatedAt=created_at,
+    )
+
+# --- Period Test Cases ---
+
+def test_period_covers_whole_local_days():
+    """Tests that a report period runs from local midnight of its first day to local midnight after its last."""
+    start, end = period_bounds(date(2026, 10, 1), date(2026, 10, 2), BANGKOK)
+
+    assert start == datetime(2026, 9, 30, 17, 0, tzinfo=timezone.utc)
+    assert end == datetime(2026, 10, 2, 17, 0, tzinfo=timezone.utc)
+
+# --- Report Test Cases ---
+
+def test_appointment_volume_is_counted_by_local_day_and_status():
+    """Tests that appointments are counted on the day they start in the report's timezone, by status."""
+    rows = appointment_volume("org-1", [
+        appointment("a-1", datetime(2026, 10, 1, 2, 0, tzinfo=timezone.utc)),
+        appointment("a-2", datetime(2026, 9, 30, 20, 0, tzinfo=timezone.utc), status="cancelled"),
+        appointment("a-3", datetime(2026, 10, 2, 3, 0, tzinfo=timezone.utc), status="fulfilled"),
+    ], BANGKOK)
+
+    assert [(row.day, row.total, row.booked, row.cancelled, row.fulfilled) for row in rows] == [
+        (date(2026, 10, 1), 2, 1, 1, 0),
+        (date(2026, 10, 2), 1, 0, 0, 1),
+    ]
+    assert all(row.organization_id == "org-1" for row in rows)
+
+def test_no_shows_are_ended_appointments_still_booked():
+    """Tests that ended appointments never marked arrived count as no-shows, and cancelled or upcoming ones are left out."""
+    appointments = [
+        appointment("a-1", NOW - timedelta(days=2)),
+        appointment("a-2", NOW - timedelta(days=2), status="fulfilled"),
+        appointment("a-3", NOW - timedelta(days=1), status="arrived", practitioner_id="prac-2"),
+        appointment("a-4", NOW - timedelta(days=1), status="cancelled"),
+        appointment("a-5", NOW + timedelta(hours=1)),
+    ]
+
+    [clinic] = no_show_rates("org-1", appointments, NOW)
+    by_practitioner = no_show_rates("org-1", appointments, NOW, by_practitioner=True)
+
+    assert (clinic.appointments, clinic.no_shows, clinic.no_show_rate) == (3, 1, 0.3333)
+    assert [(row.practitioner_id, row.no_shows, row.no_show_rate) for row in by_practitioner] == [("prac-1", 1, 0.5), ("prac-2", 0, 0.0)]
+
+def test_no_show_rate_is_empty_without_appointments():
+    """Tests that a clinic without ended appointments has a row with no rate rather than none."""
+    [row] = no_show_rates("org-1", [], NOW)
+
+    assert (row.appointments, row.no_show_rate) == (0, None)
+
+def test_adherence_is_the_share_of_days_with_readings():
+    """Tests that each patient's adherence is their days with a reading over the period's days, averaged over patients with readings."""
+    start = datetime(2026, 10, 1, 1, 0, tzinfo=timezone.utc)
+    observations = [reading("p-1", start + timedelta(days=day)) for day in range(4)]
+    observations += [reading("p-1", start + timedelta(hours=2))]
+    observations += [reading("p-2", start), reading("p-2", start + timedelta(days=1), code="8867-4")]
+
+    row = telemetry_adherence("org-1", observations, date(2026, 10, 1), date(2026, 10, 5), BANGKOK, code="59408-5")
+
+    assert (row.patients, row.adherent_patients, row.average_adherence) == (2, 1, 0.5)
+    assert row.code == "59408-5" and row.threshold == 0.7
+
+def test_open_refill_requests_are_grouped_by_status():
+    """Tests that every open status has a row, with the oldest request and the average age in hours."""
+    rows = open_refill_requests("org-1", [
+        refill("r-1", "requested", NOW - timedelta(hours=10)),
+        refill("r-2", "requested", NOW - timedelta(hours=30)),
+    ], NOW, {"requested", "under-review"})
+
+    assert [(row.status, row.count, row.average_age_hours) for row in rows] == [("requested", 2, 20.0), ("under-review", 0, None)]
+    assert rows[0].oldest_requested_at == NOW - timedelta(hours=30)
+
+def test_csv_has_a_header_and_blank_missing_values():
+    """Tests that rows render as CSV with the JSON field names as the header and empty cells for missing values."""
+    rows = [schemas.NoShowRateRow(organization_id=None, practitioner_id=None, appointments=0, no_shows=0, no_show_rate=None)]
+
+    assert to_csv(schemas.NoShowRateRow, rows) == "organization_id,practitioner_id,appointments,no_shows,no_show_rate\r\n,,0,0,\r\n"
+
+# --- Endpoint Test Cases ---
+
+app = FastAPI()
+app.include_router(reports.router, prefix="/api/v1/reports")
+client = TestClient(app)
+
+def serve(store):
+    app.dependency_overrides[get_appointment_repository] = lambda: MemoryAppointmentRepository(store)
+    app.dependency_overrides[get_organization_repository] = lambda: MemoryOrganizationRepository(store)
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "coordinator-1", "roles": ["coordinator"]}
+
+def test_reports_render_as_csv_on_request():
+    """Tests that a report is returned as a CSV attachment with `format=csv` or `Accept: text/csv`."""
+    store = MemoryStore()
+    MemoryAppointmentRepository(store).create(schemas.AppointmentCreate(
+        patientId="p-1", practitionerId="prac-1", start=datetime(2026, 10, 1, 9, 0, tzinfo=timezone.utc), end=datetime(2026, 10, 1, 9, 30, tzinfo=timezone.utc),
+    ))
+    serve(store)
+    try:
+        params = {"from": "2026-10-01", "to": "2026-10-07"}
+        as_json = client.get("/api/v1/reports/appointment-volume", params=params)
+        as_csv = client.get("/api/v1/reports/appointment-volume", params=params, headers={"Accept": "text/csv"})
+    finally:
+        app.dependency_overrides.clear()
+
+    assert as_json.status_code == 200
+    assert as_json.json()["period_start"] == "2026-10-01" and as_json.json()["rows"][0]["total"] == 1
+    assert as_csv.headers["content-type"].startswith("text/csv")
+    assert 'filename="appointment-volume_2026-10-01_2026-10-07.csv"' in as_csv.headers["content-disposition"]
+    assert as_csv.text.splitlines()[1] == ",2026-10-01,1,1,0,0,0"
+
+def test_report_periods_are_checked():
+    """Tests that a reversed or overlong period and an unknown timezone are rejected."""
+    serve(MemoryStore())
+    try:
+        reversed_period = client.get("/api/v1/reports/no-show-rates", params={"from": "2026-10-07", "to": "2026-10-01"})
+        overlong = client.get("/api/v1/reports/no-show-rates", params={"from": "2024-01-01", "to": "2026-10-01"})
+        unknown_zone = client.get("/api/v1/reports/no-show-rates", params={"timezone": "Mars/Olympus"})
+    finally:
+        app.dependency_overrides.clear()
+
+    assert [reversed_period.status_code, overlong.status_code, unknown_zone.status_code] == [400, 400, 400]
@@ -0,0 +1,194 @@
+import json
+from datetime import date, datetime, timezone
+from typing import List, Optional
+
+from fastapi.testclient import TestClient
+
+from fastapi import FastAPI, HTTPException
+from app.api.representations import CSV, FHIR_NDJSON, JSON, NDJSON, Representable, csv_columns, csv_lines, negotiate, representable, select_columns, to_fhir
+from app.api.v1 import schemas
+from app.fhir.responses import FHIR_JSON
+from app.middleware.representation import RepresentationMiddleware
+from app.pagination.pages import Page
+
+# --- Test Setup ---
+
+def make_patient(patient_id: str) -> dict:
+    return schemas.Patient.model_validate({
+        "patientId": patient_id,
+        "givenName": "Somchai",
+        "familyName": "Jaidee",
+        "dob": date(1965, 3, 14),
+        "sex": "male",
+        "mrn": f"MRN-{patient_id}",
+        "createdAt": datetime(2024, 1, 1, tzinfo=timezone.utc),
+        "updatedAt": datetime(2024, 1, 2, tzinfo=timezone.utc),
+    }).model_dump(mode="json")
+
+patients = [make_patient(f"p-{n}") for n in range(5)]
+
+app = FastAPI()
+
+@app.get("/api/v1/patients", response_model=Page[schemas.Patient], response_model_by_alias=False)
+def list_patients(limit: int = 2, cursor: Optional[str] = None):
+    start = int(cursor or 0)
+    if start > len(patients):
+        raise HTTPException(status_code=400, detail="Stale cursor")
+    end = start + limit
+    more = end < len(patients)
+    return {"items": patients[start:end], "next_cursor": str(end) if more else None, "total": None if more else len(patients)}
+
+@app.get("/api/v1/patients/{patientId}", response_model=schemas.Patient, response_model_by_alias=False)
+def read_patient(patientId: str):
+    for patient in patients:
+        if patient["patient_id"] == patientId:
+            return patient
+    raise HTTPException(status_code=404, detail="Patient not found")
+
+@app.get("/api/v1/tags", response_model=List[schemas.Coding], response_model_by_alias=False)
+def list_tags():
+    return [{"system": "urn:tags", "code": "a"}, {"system": "urn:tags", "code": "b"}]
+
+app.add_middleware(RepresentationMiddleware, prefixes=("/api/v1",))
+
+client = TestClient(app)
+
+def lines(response) -> List[dict]:
+    return [json.loads(line) for line in response.text.splitlines()]
+
+# --- Negotiation Test Cases ---
+
+def test_negotiation_picks_the_highest_rated_offered_type():
+    """Tests that the most specific matching range sets a type's quality, with ties going to the first offered."""
+    offered = [JSON, FHIR_JSON, NDJSON]
+    assert negotiate(None, offered) == JSON
+    assert negotiate("application/fhir+json", offered) == FHIR_JSON
+    assert negotiate("application/*", offered) == JSON
+    assert negotiate("application/*;q=0.5, application/x-ndjson", offered) == NDJSON
+    assert negotiate("application/json;q=0, */*", offered) == FHIR_JSON
+    assert negotiate("application/ndjson", offered) == NDJSON
+    assert negotiate("text/html", offered) is None
+
+def test_representations_are_read_from_response_models():
+    """Tests that pages, lists and single models are recognised, and FHIR offered only where mapped."""
+    assert representable(Page[schemas.Patient]) == Representable(schemas.Patient, "page")
+    assert representable(List[schemas.Coding]) == Representable(schemas.Coding, "list")
+    assert representable(schemas.Patient).media_types() == [JSON, FHIR_JSON]
+    assert representable(Page[schemas.Patient]).media_types() == [JSON, FHIR_JSON, NDJSON, FHIR_NDJSON, CSV]
+    assert representable(List[schemas.Coding]).media_types() == [JSON, NDJSON, CSV]
+    assert representable(None) is None
+    assert representable(dict) is None
+
+def test_items_are_mapped_to_fhir_resources():
+    """Tests that a JSON item is rendered with the model's FHIR mapper."""
+    resource = to_fhir(schemas.Patient, patients[0])
+    assert resource["resourceType"] == "Patient"
+    assert resource["id"] == "p-0"
+
+# --- CSV Test Cases ---
+
+def test_csv_columns_flatten_nested_models():
+    """Tests that nested models become dotted columns and lists stay in one column."""
+    columns = csv_columns(schemas.Patient)
+    assert columns[:3] == ["given_name", "family_name", "dob"]
+    assert "contact.phone_number" in columns and "contact" not in columns
+    assert "components" in csv_columns(schemas.Observation)
+
+def test_fields_select_and_order_columns():
+    """Tests that `fields` picks columns in its order, expands nested models and names unknown ones."""
+    assert select_columns(schemas.Patient, "mrn, family_name") == ["mrn", "family_name"]
+    assert select_columns(schemas.Patient, "contact")[0] == "contact.phone_number"
+    assert select_columns(schemas.Patient, None) == csv_columns(schemas.Patient)
+    try:
+        select_columns(schemas.Patient, "mrn,shoe_size")
+    except ValueError as e:
+        assert "shoe_size" in str(e)
+    else:
+        raise AssertionError("expected a ValueError")
+
+def test_csv_cells_are_quoted_and_formulas_defused():
+    """Tests that values are quoted as needed, nested values read, and formula-like text prefixed."""
+    item = {**patients[0], "family_name": "=HYPERLINK(\"x\")", "given_name": "Ann, Jr.", "contact": {"phone_number": "+66812345678"}}
+    rows = csv_lines(schemas.Patient, ["given_name", "family_name", "contact.phone_number", "contact.email", "dob"], [item], header=True)
+    assert rows.decode().split("\r\n") == [
+        "given_name,family_name,contact.phone_number,contact.email,dob",
+        "\"Ann, Jr.\",\"'=HYPERLINK(\"\"x\"\")\",'+66812345678,,1965-03-14",
+        "",
+    ]
+
+def test_csv_reads_aliased_keys():
+    """Tests that items sent by alias fill the same columns."""
+    rows = csv_lines(schemas.Patient, ["given_name", "contact.phone_number"], [{"givenName": "Ann", "contact": {"phoneNumber": "0812345678"}}])
+    assert rows == b"Ann,0812345678\r\n"
+
+# --- Middleware Test Cases ---
+
+def test_json_is_served_unchanged_and_varies_by_accept():
+    """Tests that JSON requests, and requests accepting nothing offered, get the handler's JSON."""
+    for accept in (None, "application/json", "text/html"):
+        response = client.get("/api/v1/patients/p-1", headers={"Accept": accept} if accept else {})
+        assert response.headers["content-type"] == JSON
+        assert response.json()["patient_id"] == "p-1"
+        assert "Accept" in response.headers["Vary"]
+
+def test_single_items_are_rendered_as_fhir_resources():
+    """Tests that a mapped model is sent as its FHIR resource."""
+    response = client.get("/api/v1/patients/p-1", headers={"Accept": FHIR_JSON})
+    assert response.status_code == 200
+    assert response.headers["content-type"] == FHIR_JSON
+    assert response.json()["resourceType"] == "Patient"
+    assert int(response.headers["content-length"]) == len(response.content)
+
+def test_pages_are_rendered_as_searchset_bundles():
+    """Tests that a page is a Bundle with a next link, and a total only on the last page."""
+    bundle = client.get("/api/v1/patients", headers={"Accept": FHIR_JSON}).json()
+    assert bundle["type"] == "searchset"
+    assert [entry["resource"]["id"] for entry in bundle["entry"]] == ["p-0", "p-1"]
+    assert "total" not in bundle
+    assert "cursor=2" in next(link["url"] for link in bundle["link"] if link["relation"] == "next")
+    last = client.get("/api/v1/patients?cursor=4", headers={"Accept": FHIR_JSON}).json()
+    assert last["total"] == 5
+    assert [link["relation"] for link in last["link"]] == ["self"]
+
+def test_paged_lists_are_streamed_as_ndjson_across_every_page():
+    """Tests that NDJSON follows the cursors, one item per line, at the client's page size."""
+    response = client.get("/api/v1/patients?limit=2", headers={"Accept": NDJSON})
+    assert response.status_code == 200
+    assert response.headers["content-type"] == NDJSON
+    assert [item["patient_id"] for item in lines(response)] == [f"p-{n}" for n in range(5)]
+
+def test_fhir_ndjson_streams_resources():
+    """Tests that FHIR NDJSON has a resource per line."""
+    response = client.get("/api/v1/patients", headers={"Accept": FHIR_NDJSON})
+    assert response.headers["content-type"] == FHIR_NDJSON
+    assert {item["resourceType"] for item in lines(response)} == {"Patient"}
+    assert len(lines(response)) == 5
+
+def test_plain_lists_stream_without_fhir():
+    """Tests that unmapped lists can be streamed, and fall back to JSON when FHIR is asked for."""
+    assert [item["code"] for item in lines(client.get("/api/v1/tags", headers={"Accept": NDJSON}))] == ["a", "b"]
+    response = client.get("/api/v1/tags", headers={"Accept": FHIR_JSON})
+    assert response.headers["content-type"] == JSON
+
+def test_paged_lists_are_streamed_as_csv_across_every_page():
+    """Tests that CSV has one header, a row per item across pages, the chosen columns and a filename."""
+    response = client.get("/api/v1/patients?limit=2&format=csv&fields=patient_id,mrn")
+    assert response.status_code == 200
+    assert response.headers["content-type"] == "text/csv; charset=utf-8"
+    assert response.headers["content-disposition"] == 'attachment; filename="patients.csv"'
+    assert response.text.splitlines() == ["patient_id,mrn"] + [f"p-{n},MRN-p-{n}" for n in range(5)]
+    assert client.get("/api/v1/tags", headers={"Accept": "text/csv"}).text.splitlines() == ["system,code,display", "urn:tags,a,", "urn:tags,b,"]
+
+def test_csv_needs_a_list_and_known_fields():
+    """Tests that single items ignore format=csv and unknown columns are refused."""
+    assert client.get("/api/v1/patients/p-1?format=csv").headers["content-type"] == JSON
+    response = client.get("/api/v1/patients?format=csv&fields=shoe_size")
+    assert response.status_code == 400
+    assert "shoe_size" in response.json()["detail"]
+
+def test_errors_are_never_converted():
+    """Tests that error responses are forwarded as the handler sent them."""
+    response = client.get("/api/v1/patients/p-9", headers={"Accept": FHIR_JSON})
+    assert response.status_code == 404
+    assert response.headers["content-type"] != FHIR_JSON
+    assert client.get("/api/v1/patients?cursor=9", headers={"Accept": NDJSON}).status_code == 400
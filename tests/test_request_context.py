@@ -0,0 +1,61 @@
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.core.request_context import get_request_id, get_trace_id, parse_cloud_trace_context, parse_traceparent
+from app.middleware.request_context import RequestContextMiddleware
+
+# --- Test Setup ---
+
+# Create a minimal FastAPI app that echoes the request context seen by a handler
+app = FastAPI()
+app.add_middleware(RequestContextMiddleware)
+
+@app.get("/echo")
+def echo():
+    return {"request_id": get_request_id(), "trace_id": get_trace_id()}
+
+client = TestClient(app)
+
+FAKE_TRACE_ID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+# --- Test Cases ---
+
+def test_generates_request_id_when_absent():
+    """Tests that a request ID is generated and echoed in the response header."""
+    response = client.get("/echo")
+
+    assert response.status_code == 200
+    request_id = response.headers["X-Request-ID"]
+    assert request_id
+    assert response.json()["request_id"] == request_id
+    assert "X-Trace-ID" not in response.headers
+
+def test_preserves_client_request_id_and_traceparent():
+    """Tests that a client-supplied request ID and W3C trace context are propagated."""
+    response = client.get("/echo", headers={
+        "X-Request-ID": "client-req-1",
+        "traceparent": f"00-{FAKE_TRACE_ID}-00f067aa0ba902b7-01",
+    })
+
+    assert response.headers["X-Request-ID"] == "client-req-1"
+    assert response.headers["X-Trace-ID"] == FAKE_TRACE_ID
+    assert response.json() == {"request_id": "client-req-1", "trace_id": FAKE_TRACE_ID}
+
+def test_falls_back_to_cloud_trace_context():
+    """Tests that X-Cloud-Trace-Context is used when traceparent is missing."""
+    response = client.get("/echo", headers={"X-Cloud-Trace-Context": f"{FAKE_TRACE_ID}/1;o=1"})
+
+    assert response.json()["trace_id"] == FAKE_TRACE_ID
+
+def test_rejects_malformed_request_id():
+    """Tests that an unsafe client request ID is replaced with a generated one."""
+    response = client.get("/echo", headers={"X-Request-ID": "bad id <script>"})
+
+    assert response.headers["X-Request-ID"] != "bad id <script>"
+
+def test_parse_trace_headers():
+    """Tests parsing of both supported trace header formats."""
+    assert parse_traceparent(f"00-{FAKE_TRACE_ID}-00f067aa0ba902b7-01") == (FAKE_TRACE_ID, "00f067aa0ba902b7")
+    assert parse_traceparent("00-" + "0" * 32 + "-00f067aa0ba902b7-01") == (None, None)
+    assert parse_cloud_trace_context(f"{FAKE_TRACE_ID}/1;o=1") == (FAKE_TRACE_ID, "0000000000000001")
+    assert parse_cloud_trace_context("garbage") == (None, None)
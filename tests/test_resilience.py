@@ -0,0 +1,125 @@
+import threading
+
+import pytest
+
+from app.resilience.breaker import CLOSED, HALF_OPEN, OPEN, CircuitBreaker, CircuitOpenError
+from app.resilience.bulkhead import Bulkhead, BulkheadFullError
+from app.resilience.dependencies import GuardedRepository, get_breaker
+
+# --- Test Setup ---
+
+class FakeClock:
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+class Unavailable(Exception):
+    pass
+
+def make_breaker(clock, threshold=3):
+    return CircuitBreaker("downstream", (Unavailable,), failure_threshold=threshold, reset_seconds=30, clock=clock)
+
+def fail(breaker, error=Unavailable):
+    def call():
+        raise error("down")
+    with pytest.raises(error):
+        breaker.call(call)
+
+# --- Circuit Breaker Test Cases ---
+
+def test_breaker_opens_after_consecutive_failures():
+    """Tests that the circuit opens on the threshold-th failure in a row and then refuses calls."""
+    breaker = make_breaker(FakeClock())
+    fail(breaker)
+    fail(breaker)
+    assert breaker.call(lambda: "ok") == "ok"
+    for _ in range(3):
+        fail(breaker)
+
+    assert breaker.state == OPEN
+    with pytest.raises(CircuitOpenError) as refused:
+        breaker.call(lambda: "never called")
+    assert refused.value.retry_after == 30
+
+def test_other_errors_do_not_count_as_failures():
+    """Tests that errors outside failure_types pass through without opening the circuit."""
+    breaker = make_breaker(FakeClock(), threshold=1)
+
+    fail(breaker, KeyError)
+
+    assert breaker.state == CLOSED
+
+def test_breaker_lets_one_trial_through_after_the_reset_time():
+    """Tests that a half-open circuit admits a single call, closing on success."""
+    clock = FakeClock()
+    breaker = make_breaker(clock, threshold=1)
+    fail(breaker)
+    clock.now += 30
+
+    with breaker.guard():
+        assert breaker.state == HALF_OPEN
+        with pytest.raises(CircuitOpenError):
+            breaker.call(lambda: "second call")
+
+    assert breaker.state == CLOSED
+
+def test_failed_trial_opens_the_circuit_again():
+    """Tests that a failing trial call reopens the circuit for another reset period."""
+    clock = FakeClock()
+    breaker = make_breaker(clock)
+    for _ in range(3):
+        fail(breaker)
+    clock.now += 31
+
+    fail(breaker)
+
+    assert breaker.state == OPEN
+    assert breaker.snapshot() == {"state": OPEN, "consecutiveFailures": 4}
+
+# --- Bulkhead Test Cases ---
+
+def test_bulkhead_refuses_calls_over_its_limit():
+    """Tests that a call is refused while the bulkhead's slots are all taken, and admitted once one is free."""
+    bulkhead = Bulkhead("downstream", max_concurrent=1, max_wait_seconds=0)
+    started, finish = threading.Event(), threading.Event()
+
+    def slow():
+        with bulkhead.guard():
+            started.set()
+            finish.wait(5)
+
+    worker = threading.Thread(target=slow)
+    worker.start()
+    started.wait(5)
+    with pytest.raises(BulkheadFullError):
+        with bulkhead.guard():
+            pass
+    finish.set()
+    worker.join(5)
+
+    with bulkhead.guard():
+        pass
+
+# --- Guarded Repository Test Cases ---
+
+def test_guarded_repository_calls_go_through_the_breaker():
+    """Tests that the wrapper passes calls and attributes through and counts the repository's failures."""
+    class Repository:
+        collection = "patients"
+
+        def get(self, patient_id):
+            return {"id": patient_id}
+
+        def list(self):
+            raise Unavailable("store down")
+
+    breaker = get_breaker("test-store", (Unavailable,))
+    guarded = GuardedRepository(Repository(), "test-store")
+
+    assert guarded.get("p-1") == {"id": "p-1"}
+    assert guarded.collection == "patients"
+    with pytest.raises(Unavailable):
+        guarded.list()
+    assert breaker.failures == 1
@@ -0,0 +1,187 @@
+import pytest
+from fastapi.testclient import TestClient
+from datetime import datetime, timedelta, timezone
+
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_organization_repository, get_purge_manifest_repository, get_retention_policy_repository
+from app.api.v1.endpoints import organizations
+from app.dependencies.auth import get_current_user
+from app.events.envelope import Event
+from app.repositories.memory import (
+    MemoryAuditEventRepository,
+    MemoryObservationRepository,
+    MemoryOrganizationRepository,
+    MemoryOutboxRepository,
+    MemoryPurgeManifestRepository,
+    MemoryRetentionPolicyRepository,
+    MemoryStore,
+    MemoryWebhookDeliveryRepository,
+)
+from app.retention import policies, purge
+from app.retention.policies import effective_policy, parse_retention
+from app.tenancy.context import acting_for
+
+# --- Test Setup ---
+
+NOW = datetime(2026, 6, 1, 9, 0, tzinfo=timezone.utc)
+ADMIN_USER = {"uid": "admin-1", "roles": ["admin"], "organizationId": "clinic-a"}
+
+def use_store(monkeypatch, store, defaults=""):
+    """Points the retention pass at `store`, with `defaults` as RETENTION_DEFAULTS."""
+    monkeypatch.setattr(purge, "CATEGORY_STORES", {
+        "telemetry": [("observations", lambda: MemoryObservationRepository(store))],
+        "audit": [("auditEvents", lambda: MemoryAuditEventRepository(store))],
+        "messages": [("eventOutbox", lambda: MemoryOutboxRepository(store)), ("webhookDeliveries", lambda: MemoryWebhookDeliveryRepository(store))],
+    })
+    monkeypatch.setattr(purge, "get_retention_policy_repository", lambda: MemoryRetentionPolicyRepository(store))
+    monkeypatch.setattr(purge, "get_purge_manifest_repository", lambda: MemoryPurgeManifestRepository(store))
+    monkeypatch.setattr(policies, "get_retention_defaults", lambda: parse_retention(defaults))
+
+def add_observation(store, effective_at):
+    return MemoryObservationRepository(store).create(schemas.ObservationCreate(
+        patientId="patient-1",
+        code={"system": "http://loinc.org", "code": "59408-5"},
+        value=96,
+        unit="%",
+        effectiveAt=effective_at,
+        identifier={"system": "urn:device", "value": "reading-1"},
+    ))
+
+def add_audit_event(store, occurred_at):
+    return MemoryAuditEventRepository(store).append(schemas.AuditEventCreate(
+        occurredAt=occurred_at, action="read", outcome="success", statusCode=200, method="GET",
+        route="/api/v1/patients/{patientId}", path="/api/v1/patients/patient-1", actorUid="clin-1",
+        actorRoles=["Clinician"], resourceType="Patient", resourceId="patient-1", patientId="patient-1",
+        purposeOfUse="TREAT", sourceIp="203.0.113.5",
+    ))
+
+# --- Policy Test Cases ---
+
+def test_parse_retention_reads_days_and_action():
+    """Tests that RETENTION_DEFAULTS entries default to deleting, and bad entries are refused."""
+    rules = parse_retention("telemetry=730, audit=2190:anonymize")
+
+    assert rules["telemetry"] == schemas.RetentionRule(days=730, action="delete")
+    assert rules["audit"] == schemas.RetentionRule(days=2190, action="anonymize")
+    assert "messages" not in rules
+    for spec in ("billing=30", "audit=forever", "audit=30:archive", "telemetry=0"):
+        with pytest.raises(ValueError):
+            parse_retention(spec)
+
+def test_effective_policy_prefers_the_organizations_rules():
+    """Tests that an organization's rule replaces the default for its category only."""
+    stored = schemas.RetentionPolicyRecord(
+        organization_id="clinic-a", telemetry={"days": 90}, version=2, created_at=NOW, updated_at=NOW,
+    )
+
+    policy = effective_policy("clinic-a", stored, parse_retention("telemetry=730,audit=2190:anonymize"))
+
+    assert (policy.telemetry.days, policy.telemetry.source) == (90, "organization")
+    assert (policy.audit.days, policy.audit.action, policy.audit.source) == (2190, "anonymize", "config")
+    assert policy.messages is None
+    assert policy.version == 2
+
+# --- Purge Test Cases ---
+
+def test_expired_telemetry_is_deleted_and_recorded(monkeypatch):
+    """Tests that observations taken before the cutoff are deleted and the manifest counts them."""
+    store = MemoryStore()
+    use_store(monkeypatch, store, "telemetry=30")
+    with acting_for("clinic-a"):
+        old = add_observation(store, NOW - timedelta(days=31))
+        recent = add_observation(store, NOW - timedelta(days=1))
+    with acting_for("clinic-b"):
+        other = add_observation(store, NOW - timedelta(days=31))
+
+    with acting_for("clinic-a"):
+        [manifest] = purge.apply_retention(NOW)
+
+    assert set(store.table("observations")) == {recent.observation_id, other.observation_id}
+    assert old.observation_id not in store.table("observations")
+    assert (manifest.category, manifest.action, manifest.status) == ("telemetry", "delete", "completed")
+    assert manifest.counts == {"observations": 1}
+    assert manifest.cutoff == NOW - timedelta(days=30)
+    assert manifest.tenant_id == "clinic-a"
+
+def test_anonymizing_audit_events_starts_from_the_last_cutoff(monkeypatch):
+    """Tests that anonymized audit events lose their identifiers, and a later run only touches newly expired ones."""
+    store = MemoryStore()
+    use_store(monkeypatch, store, "audit=365:anonymize")
+    with acting_for("clinic-a"):
+        expired = add_audit_event(store, NOW - timedelta(days=400))
+        kept = add_audit_event(store, NOW - timedelta(days=10))
+        first = purge.apply_retention(NOW)[0]
+        second = purge.apply_retention(NOW + timedelta(days=1))[0]
+        event = MemoryAuditEventRepository(store).get(expired)
+
+    assert first.counts == {"auditEvents": 1}
+    assert event.actor_uid is None and event.patient_id is None and event.source_ip is None
+    assert event.path == "anonymized"
+    assert (event.action, event.resource_type, event.route) == ("read", "Patient", "/api/v1/patients/{patientId}")
+    assert MemoryAuditEventRepository(store).get(kept).patient_id == "patient-1"
+    assert second.since == first.cutoff
+    assert second.counts == {"auditEvents": 0}
+
+def test_messages_are_purged_for_the_current_organization_only(monkeypatch):
+    """Tests that published outbox entries of other organizations and pending ones are kept."""
+    store = MemoryStore()
+    use_store(monkeypatch, store, "messages=7")
+    outbox = MemoryOutboxRepository(store)
+    with acting_for("clinic-a"):
+        mine, pending = (Event(type="patient.created", subject=f"patients/p-{i}", data={}) for i in range(2))
+    with acting_for("clinic-b"):
+        theirs = Event(type="patient.created", subject="patients/p-9", data={})
+    for event in (mine, pending, theirs):
+        outbox.add(event)
+    outbox.mark_published(mine.id)
+    outbox.mark_published(theirs.id)
+    for event_id in (mine.id, pending.id, theirs.id):
+        store.table("event_outbox")[event_id]["updated_at"] = NOW - timedelta(days=8)
+
+    with acting_for("clinic-a"):
+        [manifest] = purge.apply_retention(NOW)
+
+    assert set(store.table("event_outbox")) == {pending.id, theirs.id}
+    assert manifest.counts == {"eventOutbox": 1, "webhookDeliveries": 0}
+
+def test_failed_category_is_recorded_and_others_still_run(monkeypatch):
+    """Tests that a store error yields a failed manifest without stopping the other categories."""
+    store = MemoryStore()
+    use_store(monkeypatch, store, "telemetry=30,audit=30")
+    broken = MemoryAuditEventRepository(store)
+    def unavailable(before):
+        raise RuntimeError("store unavailable")
+    broken.purge_expired = unavailable
+    stores = dict(purge.CATEGORY_STORES, audit=[("auditEvents", lambda: broken)])
+    monkeypatch.setattr(purge, "CATEGORY_STORES", stores)
+
+    with acting_for("clinic-a"):
+        manifests = purge.apply_retention(NOW)
+
+    assert [(m.category, m.status) for m in manifests] == [("telemetry", "completed"), ("audit", "failed")]
+    assert manifests[1].error == "store unavailable"
+
+# --- Endpoint Test Cases ---
+
+def test_organization_admin_overrides_and_reads_retention(monkeypatch):
+    """Tests that an organization's administrator sets a rule and sees it with the defaults."""
+    store = MemoryStore()
+    use_store(monkeypatch, store, "audit=2190:anonymize")
+    orgs = MemoryOrganizationRepository(store)
+    orgs.create(schemas.OrganizationCreate(organizationId="clinic-a", name="Clinic A"))
+    app = FastAPI()
+    app.include_router(organizations.router, prefix="/api/v1/organizations")
+    app.dependency_overrides[get_current_user] = lambda: ADMIN_USER
+    app.dependency_overrides[get_organization_repository] = lambda: orgs
+    app.dependency_overrides[get_retention_policy_repository] = lambda: MemoryRetentionPolicyRepository(store)
+    app.dependency_overrides[get_purge_manifest_repository] = lambda: MemoryPurgeManifestRepository(store)
+    client = TestClient(app)
+
+    response = client.patch("/api/v1/organizations/clinic-a/retention", json={"telemetry": {"days": 90}})
+    assert response.status_code == 200
+    assert response.json()["telemetry"] == {"days": 90, "action": "delete", "source": "organization"}
+    assert response.json()["audit"]["source"] == "config"
+
+    assert client.get("/api/v1/organizations/clinic-b/retention").status_code == 403
+    assert client.get("/api/v1/organizations/clinic-a/retention/purges").json()["items"] == []
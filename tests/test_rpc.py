@@ -0,0 +1,151 @@
+import pytest
+import grpc
+
+from app.api.v1 import schemas
+from app.repositories.memory import MemoryAuditEventRepository, MemoryPatientRepository, MemoryStore
+from app.rpc.interceptors import AuditInterceptor, ServiceAuthInterceptor
+from app.rpc.services import PatientService
+from app.rpc.stubs import load
+
+# --- Test Setup ---
+
+AUDIENCE = "https://mega-care-api.example.run.app"
+BILLING_ACCOUNT = "billing@mega-care.iam.gserviceaccount.com"
+
+class Aborted(Exception):
+    pass
+
+class FakeContext:
+    """Stands in for grpc.ServicerContext: abort() raises, as it does in grpc."""
+
+    def __init__(self, metadata=()):
+        self.metadata = tuple(metadata)
+        self._code = None
+
+    def abort(self, code, details):
+        self._code = code
+        raise Aborted(details)
+
+    def code(self):
+        return self._code
+
+    def invocation_metadata(self):
+        return self.metadata
+
+    def peer(self):
+        return "ipv4:10.0.0.7:51234"
+
+class FakeHandlerCallDetails:
+    def __init__(self, method):
+        self.method = method
+
+def fake_verify(token, audience):
+    if token != "good-token" or audience != AUDIENCE:
+        raise ValueError("Token has wrong audience")
+    return {"email": BILLING_ACCOUNT}
+
+def make_patients(count):
+    repo = MemoryPatientRepository(MemoryStore())
+    for i in range(count):
+        repo.create(schemas.PatientCreate(givenName="Ann", familyName=f"Lee{i}", dob="1980-01-01", mrn=f"MRN-{i}"))
+    return repo
+
+def intercept(interceptor, behavior, method="/megacare.v1.PatientService/GetPatient"):
+    """Runs `behavior` as a unary call through `interceptor` and returns the wrapped behaviour."""
+    handler = grpc.unary_unary_rpc_method_handler(behavior)
+    return interceptor.intercept_service(lambda details: handler, FakeHandlerCallDetails(method)).unary_unary
+
+# --- Service Test Cases ---
+
+def test_get_patient_returns_message():
+    """Tests that GetPatient converts the stored patient into its protobuf message."""
+    pb, _ = load("patients")
+    repo = make_patients(1)
+    patient = repo.list()[0]
+
+    message = PatientService(lambda: repo).GetPatient(pb.GetPatientRequest(patient_id=patient.patient_id), FakeContext())
+
+    assert message.mrn == "MRN-0"
+    assert message.birth_date == "1980-01-01"
+    assert message.nhs_number == ""
+    assert message.HasField("create_time")
+
+def test_get_patient_not_found():
+    """Tests that an unknown patient aborts the call with NOT_FOUND."""
+    pb, _ = load("patients")
+    context = FakeContext()
+
+    with pytest.raises(Aborted):
+        PatientService(lambda: make_patients(0)).GetPatient(pb.GetPatientRequest(patient_id="missing"), context)
+    assert context.code() == grpc.StatusCode.NOT_FOUND
+
+def test_list_patients_pages_with_tokens():
+    """Tests that ListPatients returns every patient once across pages, and no token on the last page."""
+    pb, _ = load("patients")
+    repo = make_patients(5)
+    service = PatientService(lambda: repo)
+
+    seen, token = [], ""
+    for _ in range(3):
+        response = service.ListPatients(pb.ListPatientsRequest(page_size=2, page_token=token), FakeContext())
+        seen += [p.mrn for p in response.patients]
+        token = response.next_page_token
+    assert sorted(seen) == [f"MRN-{i}" for i in range(5)]
+    assert token == ""
+
+def test_list_patients_rejects_bad_page_tokens():
+    """Tests that tampered page tokens and negative page sizes are INVALID_ARGUMENT."""
+    pb, _ = load("patients")
+    service = PatientService(lambda: make_patients(1))
+    for request in [pb.ListPatientsRequest(page_token="not-a-token"), pb.ListPatientsRequest(page_size=-1)]:
+        context = FakeContext()
+
+        with pytest.raises(Aborted):
+            service.ListPatients(request, context)
+        assert context.code() == grpc.StatusCode.INVALID_ARGUMENT
+
+# --- Interceptor Test Cases ---
+
+def test_service_auth_admits_allowed_callers():
+    """Tests that a valid token of an allowed service account reaches the servicer."""
+    interceptor = ServiceAuthInterceptor(AUDIENCE, [BILLING_ACCOUNT], verify=fake_verify)
+    call = intercept(interceptor, lambda request, context: "ok")
+
+    assert call(None, FakeContext([("authorization", "Bearer good-token")])) == "ok"
+
+def test_service_auth_refuses_other_calls():
+    """Tests that missing and invalid tokens are UNAUTHENTICATED, and an unconfigured audience refuses all calls."""
+    cases = [
+        (ServiceAuthInterceptor(AUDIENCE, [BILLING_ACCOUNT], verify=fake_verify), [], grpc.StatusCode.UNAUTHENTICATED),
+        (ServiceAuthInterceptor(AUDIENCE, [BILLING_ACCOUNT], verify=fake_verify), [("authorization", "Bearer bad-token")], grpc.StatusCode.UNAUTHENTICATED),
+        (ServiceAuthInterceptor(None, [BILLING_ACCOUNT], verify=fake_verify), [("authorization", "Bearer good-token")], grpc.StatusCode.PERMISSION_DENIED),
+        (ServiceAuthInterceptor(AUDIENCE, ["other@mega-care.iam.gserviceaccount.com"], verify=fake_verify), [("authorization", "Bearer good-token")], grpc.StatusCode.PERMISSION_DENIED),
+    ]
+    for interceptor, metadata, expected in cases:
+        context = FakeContext(metadata)
+        call = intercept(interceptor, lambda request, context: "ok")
+
+        with pytest.raises(Aborted):
+            call(None, context)
+        assert context.code() == expected
+
+def test_audit_interceptor_records_calls():
+    """Tests that successful and refused calls are both written to the audit trail with the patient of the request."""
+    pb, _ = load("patients")
+    audit_repo = MemoryAuditEventRepository(MemoryStore())
+    audited = AuditInterceptor(lambda: audit_repo)
+    auth = ServiceAuthInterceptor(AUDIENCE, [BILLING_ACCOUNT], verify=fake_verify)
+    request = pb.GetPatientRequest(patient_id="patient-1")
+
+    ok = intercept(audited, intercept(auth, lambda request, context: "ok"))
+    ok(request, FakeContext([("authorization", "Bearer good-token")]))
+    with pytest.raises(Aborted):
+        ok(request, FakeContext())
+
+    events = sorted(audit_repo.list(), key=lambda e: e.status_code)
+    assert [e.status_code for e in events] == [200, 401]
+    assert events[0].actor_uid == BILLING_ACCOUNT
+    assert events[0].route == "/megacare.v1.PatientService/GetPatient"
+    assert events[0].resource_type == "patients"
+    assert {e.patient_id for e in events} == {"patient-1"}
+    assert events[0].source_ip == "10.0.0.7"
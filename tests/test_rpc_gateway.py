@@ -0,0 +1,73 @@
+import json
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api.v1 import schemas
+from app.errors.handlers import register_exception_handlers
+from app.repositories.memory import MemoryObservationRepository, MemoryPatientRepository, MemoryStore
+from app.rpc import server
+from app.rpc.gateway import gateway_router
+
+# --- Test Setup ---
+
+@pytest.fixture
+def gateway(monkeypatch):
+    """A client for the gateway routes, over in-memory repositories with three patients."""
+    store = MemoryStore()
+    patients = MemoryPatientRepository(store)
+    for i in range(3):
+        patients.create(schemas.PatientCreate(givenName="Ann", familyName=f"Lee{i}", dob="1980-01-01", mrn=f"MRN-{i}"))
+    monkeypatch.setattr(server, "get_patient_repository", lambda: patients)
+    monkeypatch.setattr(server, "get_observation_repository", lambda: MemoryObservationRepository(store))
+
+    app = FastAPI()
+    register_exception_handlers(app)
+    app.include_router(gateway_router(), prefix="/internal/services")
+    yield TestClient(app), patients
+
+# --- Gateway Test Cases ---
+
+def test_get_is_transcoded_from_http_rule(gateway):
+    """Tests that GET /v1/patients/{patient_id} calls GetPatient and returns the message as proto3 JSON."""
+    client, patients = gateway
+    patient = patients.get_by_mrn("MRN-1")
+
+    response = client.get(f"/internal/services/v1/patients/{patient.patient_id}")
+
+    assert response.status_code == 200
+    body = response.json()
+    assert body["patientId"] == patient.patient_id
+    assert body["birthDate"] == "1980-01-01"
+    assert body["updateTime"].endswith("Z")
+
+def test_query_parameters_fill_request_fields(gateway):
+    """Tests that fields outside the path are read from the query string, by JSON or proto name."""
+    client, _ = gateway
+    first = client.get("/internal/services/v1/patients", params={"pageSize": 2}).json()
+    rest = client.get("/internal/services/v1/patients", params={"page_size": 2, "pageToken": first["nextPageToken"]}).json()
+
+    assert len(first["patients"]) == 2
+    assert len(rest["patients"]) == 1
+    assert "nextPageToken" not in rest
+
+def test_errors_are_problem_details(gateway):
+    """Tests that aborted calls map to their HTTP status and unknown fields are rejected."""
+    client, _ = gateway
+    missing = client.get("/internal/services/v1/patients/missing")
+    unknown = client.get("/internal/services/v1/patients", params={"colour": "blue"})
+
+    assert missing.status_code == 404
+    assert missing.headers["content-type"].startswith("application/problem+json")
+    assert missing.json()["detail"] == "Patient not found"
+    assert unknown.status_code == 400
+
+def test_server_streams_are_newline_delimited(gateway):
+    """Tests that a server-streaming RPC is sent as one JSON result object per line."""
+    client, _ = gateway
+    response = client.get("/internal/services/v1/patients:stream")
+
+    assert response.headers["content-type"].startswith("application/x-ndjson")
+    lines = [json.loads(line) for line in response.text.splitlines()]
+    assert sorted(line["result"]["mrn"] for line in lines) == ["MRN-0", "MRN-1", "MRN-2"]
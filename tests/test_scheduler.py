@@ -0,0 +1,238 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timedelta, timezone
+
+from fastapi import FastAPI
+from app.api.internal.endpoints import cron as cron_endpoints
+from app.api.internal.router import internal_router
+from app.api.v1 import schemas
+from app.api.v1.deps import get_job_lock_repository, get_job_run_repository
+from app.events.envelope import Event
+from app.repositories.appointments import AppointmentRepository
+from app.repositories.memory import (
+    MemoryIdempotencyRepository,
+    MemoryJobLockRepository,
+    MemoryJobRunRepository,
+    MemoryLockoutRepository,
+    MemoryMagicLinkRepository,
+    MemoryOutboxRepository,
+    MemoryStore,
+    MemoryStreamEventRepository,
+    MemoryWebhookDeliveryRepository,
+)
+from app.scheduler import jobs
+from app.scheduler import ticker as ticker_module
+from app.scheduler.registry import cron_job, get_job
+from app.scheduler.runner import JobRunner
+from app.scheduler.ticker import SchedulerTicker
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(internal_router, prefix="/internal")
+client = TestClient(app)
+
+SCHEDULER_ACCOUNT = "cloud-scheduler@mega-care.iam.gserviceaccount.com"
+NOW = datetime.now(timezone.utc)
+
+calls = []
+
+@cron_job("test-count", every=timedelta(hours=1))
+def count():
+    calls.append("count")
+    return {"counted": len(calls)}
+
+@cron_job("test-broken", every=timedelta(hours=1))
+def broken():
+    raise RuntimeError("store unavailable")
+
+def make_runner(owner="worker-1", store=None):
+    store = store or MemoryStore()
+    return JobRunner(MemoryJobLockRepository(store), MemoryJobRunRepository(store), owner=owner), store
+
+def make_appointment(appointment_id, start, **overrides):
+    return schemas.Appointment.model_validate({
+        "appointmentId": appointment_id,
+        "patientId": "patient-1",
+        "practitionerId": "prac-1",
+        "start": start,
+        "end": start + timedelta(minutes=30),
+        "status": "booked",
+        "createdAt": NOW,
+        "updatedAt": NOW,
+        **overrides,
+    })
+
+# --- Lock Test Cases ---
+
+def test_lock_is_exclusive_until_released_or_expired():
+    """Tests that a held lock refuses other owners, and is free again after release or once the lease ends."""
+    locks = MemoryJobLockRepository(MemoryStore())
+
+    assert locks.acquire("job", "worker-1", lease_seconds=60)
+    assert not locks.acquire("job", "worker-2", lease_seconds=60)
+    locks.release("job", "worker-2")
+    assert not locks.acquire("job", "worker-2", lease_seconds=60)
+    locks.release("job", "worker-1")
+    assert locks.acquire("job", "worker-2", lease_seconds=0)
+    assert locks.acquire("job", "worker-1", lease_seconds=60)
+
+def test_lock_respects_last_start_when_due_check_requested():
+    """Tests that `started_before` refuses a job that started more recently."""
+    locks = MemoryJobLockRepository(MemoryStore())
+    locks.acquire("job", "worker-1", lease_seconds=0)
+
+    assert not locks.acquire("job", "worker-1", lease_seconds=0, started_before=NOW - timedelta(hours=1))
+    assert locks.acquire("job", "worker-1", lease_seconds=0, started_before=datetime.now(timezone.utc) + timedelta(seconds=1))
+
+# --- Runner Test Cases ---
+
+def test_runner_records_successful_run_and_releases_lock():
+    """Tests that a run is recorded with the handler's counts and the job can run again right after."""
+    runner, store = make_runner()
+    calls.clear()
+
+    run = runner.run("test-count", "cron")
+
+    assert run.status == "succeeded"
+    assert run.result == {"counted": 1}
+    assert run.finished_at is not None
+    assert runner.run("test-count", "cron").status == "succeeded"
+    assert [r.status for r in MemoryJobRunRepository(store).list(job="test-count")] == ["succeeded", "succeeded"]
+
+def test_runner_records_failure():
+    """Tests that a failing handler is recorded as failed with its error, and frees the lock."""
+    runner, _ = make_runner()
+
+    run = runner.run("test-broken", "cron")
+
+    assert run.status == "failed"
+    assert run.error == "RuntimeError: store unavailable"
+    assert runner.locks.get("test-broken").locked_by is None
+
+def test_runner_skips_locked_job():
+    """Tests that a trigger while another owner holds the lock is recorded as skipped without running."""
+    runner, store = make_runner()
+    runner.locks.acquire("test-count", "worker-2", lease_seconds=60)
+    calls.clear()
+
+    run = runner.run("test-count", "cron")
+
+    assert run.status == "skipped"
+    assert calls == []
+
+def test_runner_rejects_unknown_job():
+    """Tests that running an unregistered job raises LookupError."""
+    runner, _ = make_runner()
+    with pytest.raises(LookupError):
+        runner.run("no-such-job", "cron")
+
+def test_ticker_runs_jobs_only_when_due(monkeypatch):
+    """Tests that a second tick within the interval runs nothing and leaves no run records."""
+    runner, store = make_runner()
+    ticker = SchedulerTicker(runner)
+    monkeypatch.setattr(ticker_module, "all_jobs", lambda: [get_job("test-count")])
+    calls.clear()
+
+    ticker.drain()
+    first = len(MemoryJobRunRepository(store).list(job="test-count"))
+    ticker.drain()
+
+    assert calls == ["count"]
+    assert first == 1
+    assert len(MemoryJobRunRepository(store).list(job="test-count")) == 1
+
+# --- Job Test Cases ---
+
+def test_reminders_announce_each_booked_appointment_once(monkeypatch):
+    """Tests that due booked appointments get a reminder event and `reminderSentAt`, and others are left alone."""
+    appointments = MagicMock(spec=AppointmentRepository)
+    appointments.list.return_value = [
+        make_appointment("appt-1", NOW + timedelta(hours=2)),
+        make_appointment("appt-2", NOW + timedelta(hours=3), reminderSentAt=NOW - timedelta(hours=1)),
+        make_appointment("appt-3", NOW + timedelta(hours=4), status="cancelled"),
+    ]
+    appointments.update.side_effect = lambda appointment_id, changes: make_appointment(appointment_id, NOW + timedelta(hours=2), **changes)
+    events = MagicMock()
+    monkeypatch.setattr(jobs, "get_appointment_repository", lambda: appointments)
+    monkeypatch.setattr(jobs, "get_event_publisher", lambda: events)
+
+    result = jobs.send_appointment_reminders()
+
+    assert result == {"remindersDue": 1}
+    assert appointments.update.call_args.args[0] == "appt-1"
+    assert "reminderSentAt" in appointments.update.call_args.args[1]
+    events.emit.assert_called_once()
+    assert events.emit.call_args.args[:2] == ("appointment.reminder_due", "appointments/appt-1")
+    kwargs = appointments.list.call_args.kwargs
+    assert kwargs["start_to"] - kwargs["start_from"] == timedelta(hours=jobs.settings.appointment_reminder_lead_hours)
+
+def test_retention_purge_keeps_pending_and_recent_records(monkeypatch):
+    """Tests that only finished records older than the retention period are deleted."""
+    store = MemoryStore()
+    outbox, deliveries = MemoryOutboxRepository(store), MemoryWebhookDeliveryRepository(store)
+    runs, keys = MemoryJobRunRepository(store), MemoryIdempotencyRepository(store)
+    streamed, links = MemoryStreamEventRepository(store), MemoryMagicLinkRepository(store)
+    lockouts = MemoryLockoutRepository(store)
+    old, pending, recent = (Event(type="patient.created", subject=f"patients/p-{i}", data={}) for i in range(3))
+    for event in (old, pending, recent):
+        outbox.add(event)
+    outbox.mark_published(old.id)
+    outbox.mark_published(recent.id)
+    delivered = deliveries.create("sub-1", old)
+    deliveries.mark_succeeded(delivered.delivery_id, 1, 200)
+    keys.claim("r-1", "user:u-1", "POST /api/v1/appointments", "k-1", "f", lease_seconds=60)
+    keys.complete("r-1", 201, {}, "", ttl_seconds=jobs.settings.idempotency_ttl_seconds)
+    keys.claim("r-2", "user:u-1", "POST /api/v1/appointments", "k-2", "f", lease_seconds=60)
+    streamed.append(old.model_copy(update={"occurred_at": datetime.now(timezone.utc) - timedelta(hours=jobs.settings.event_stream_retention_hours + 1)}))
+    streamed.append(recent)
+    aged = datetime.now(timezone.utc) - timedelta(days=jobs.settings.operational_retention_days + 1)
+    links.create("link-old", "u-1", "a@example.com", aged)
+    links.create("link-new", "u-1", "a@example.com", datetime.now(timezone.utc))
+    for lockout_id in ("account:u-1", "account:u-2"):
+        lockouts.record_failure(lockout_id, "account", lockout_id[len("account:"):], lambda record: {"failures": 1})
+    store.table("lockouts")["account:u-1"]["updated_at"] = aged
+    for table, record_id in (("event_outbox", old.id), ("event_outbox", pending.id), ("webhook_deliveries", delivered.delivery_id), ("idempotency_records", "r-1")):
+        store.table(table)[record_id]["updated_at"] = aged
+    monkeypatch.setattr(jobs, "get_outbox_repository", lambda: outbox)
+    monkeypatch.setattr(jobs, "get_webhook_delivery_repository", lambda: deliveries)
+    monkeypatch.setattr(jobs, "get_job_run_repository", lambda: runs)
+    monkeypatch.setattr(jobs, "get_idempotency_repository", lambda: keys)
+    monkeypatch.setattr(jobs, "get_stream_event_repository", lambda: streamed)
+    monkeypatch.setattr(jobs, "get_magic_link_repository", lambda: links)
+    monkeypatch.setattr(jobs, "get_lockout_repository", lambda: lockouts)
+
+    result = jobs.purge_operational_records()
+
+    assert result == {"outboxEntries": 1, "webhookDeliveries": 1, "jobRuns": 0, "idempotencyRecords": 1, "streamEvents": 1, "magicLinks": 1, "lockouts": 1}
+    assert set(store.table("magic_links")) == {"link-new"}
+    assert set(store.table("lockouts")) == {"account:u-2"}
+    assert set(store.table("event_outbox")) == {pending.id, recent.id}
+    assert set(store.table("stream_events")) == {recent.id}
+
+# --- Endpoint Test Cases ---
+
+def test_cron_endpoint_runs_job():
+    """Tests that an authenticated Cloud Scheduler call runs the job and unknown jobs return 404."""
+    store = MemoryStore()
+    app.dependency_overrides[cron_endpoints.require_cloud_scheduler] = lambda: {"email": SCHEDULER_ACCOUNT}
+    app.dependency_overrides[get_job_lock_repository] = lambda: MemoryJobLockRepository(store)
+    app.dependency_overrides[get_job_run_repository] = lambda: MemoryJobRunRepository(store)
+
+    response = client.post("/internal/cron/test-count")
+    failed = client.post("/internal/cron/test-broken")
+    missing = client.post("/internal/cron/no-such-job")
+
+    assert response.status_code == 200
+    assert response.json()["status"] == "succeeded"
+    assert response.json()["trigger"] == "cron"
+    assert failed.status_code == 500
+    assert missing.status_code == 404
+    app.dependency_overrides.pop(cron_endpoints.require_cloud_scheduler, None)
+    app.dependency_overrides.pop(get_job_lock_repository, None)
+    app.dependency_overrides.pop(get_job_run_repository, None)
+
+def test_cron_endpoint_requires_token():
+    """Tests that calls without a bearer token are refused."""
+    assert client.post("/internal/cron/test-count").status_code in (401, 403)
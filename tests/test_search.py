@@ -0,0 +1,132 @@
+import pytest
+from unittest.mock import MagicMock
+from datetime import date, datetime, timedelta, timezone
+
+from app.api.v1 import schemas
+from app.repositories.appointments import APPOINTMENT_SEARCH
+from app.repositories.memory import MemoryAppointmentRepository, MemoryObservationRepository, MemoryPatientRepository, MemoryStore
+from app.repositories.observations import OBSERVATION_SEARCH
+from app.repositories.patients import PATIENT_SEARCH
+from app.repositories.postgres.patients import PostgresPatientRepository
+from app.search.filters import Filter, SearchError, Sort
+
+# --- Test Setup ---
+
+START = datetime(2026, 10, 1, 9, 0, tzinfo=timezone.utc)
+
+def search(spec, query, sort=None):
+    return spec.parse([tuple(part.split("=", 1)) for part in query.split("&")], sort)
+
+def add_patients(repo):
+    for given, family, dob in [("Ann", "Smith", "1975-03-02"), ("Bob", "Goldsmith", "1984-07-19"), ("Cara", "Lee", "1990-11-30"), ("Dan", "Smithers", "1982-01-05")]:
+        repo.create(schemas.PatientCreate(givenName=given, familyName=family, dob=dob, mrn=f"MRN-{given}"))
+
+# --- Parsing Test Cases ---
+
+def test_prefixes_and_modifiers_become_typed_filters():
+    """Tests that FHIR-style prefixes and text modifiers parse into operators on typed values."""
+    parsed = search(PATIENT_SEARCH, "dob=ge1980-01-01&dob=lt1990-01-01&name=contains:smith&sex=female", sort="-createdAt")
+
+    assert parsed.filters == (
+        Filter(("dob",), ">=", date(1980, 1, 1)),
+        Filter(("dob",), "<", date(1990, 1, 1)),
+        Filter(("givenName", "familyName"), "contains", "smith"),
+        Filter(("sex",), "==", "female"),
+    )
+    assert parsed.sort == Sort("createdAt", descending=True)
+
+def test_values_default_to_equality_and_lists_to_any_of():
+    """Tests that a bare value means equality, a comma list any of the codes, and that times without an offset are UTC."""
+    parsed = search(OBSERVATION_SEARCH, "code=8867-4,59408-5&value=72&effectiveAt=gt2026-10-01")
+
+    assert parsed.filters == (
+        Filter(("code.code",), "in", ["8867-4", "59408-5"]),
+        Filter(("value",), "==", 72.0),
+        Filter(("effectiveAt",), ">", datetime(2026, 10, 1, tzinfo=timezone.utc)),
+    )
+    assert parsed.sort == Sort("effectiveAt", descending=True)
+
+def test_other_parameters_are_left_alone():
+    """Tests that parameters that are not search fields, such as paging ones, are ignored."""
+    assert search(PATIENT_SEARCH, "limit=5&cursor=abc&includeDeleted=true").filters == ()
+
+def test_invalid_filters_are_refused():
+    """Tests that malformed values, disallowed operators and unsortable fields raise SearchError."""
+    cases = [
+        (PATIENT_SEARCH, "dob=ge1980-13-01", None, "'dob' must be a date"),
+        (PATIENT_SEARCH, "sex=ne:robot", None, "'sex' must be one of"),
+        (PATIENT_SEARCH, "name=eq:Smith", None, "'name' takes contains: or sw:"),
+        (PATIENT_SEARCH, "mrn=contains:12", None, "'mrn' is a code"),
+        (PATIENT_SEARCH, "familyName=", None, "'familyName' needs a value"),
+        (PATIENT_SEARCH, "limit=5", "mrn", "Cannot sort by 'mrn'"),
+        (APPOINTMENT_SEARCH, "status=ne:booked,cancelled", None, "several values only with eq:"),
+    ]
+    for spec, query, sort, message in cases:
+        with pytest.raises(SearchError) as exc_info:
+            search(spec, query, sort)
+        assert message in str(exc_info.value)
+
+# --- Repository Test Cases ---
+
+def test_patients_are_filtered_and_sorted():
+    """Tests that text, date and sort filters are applied together, ignoring case."""
+    repo = MemoryPatientRepository(MemoryStore())
+    add_patients(repo)
+
+    found = repo.list(search=search(PATIENT_SEARCH, "name=contains:SMITH&dob=ge1980-01-01", sort="-dob"))
+    starting = repo.list(search=search(PATIENT_SEARCH, "familyName=sw:smith"))
+
+    assert [p.family_name for p in found] == ["Goldsmith", "Smithers"]
+    assert [p.family_name for p in starting] == ["Smith", "Smithers"]
+    assert [p.family_name for p in repo.list()] == ["Goldsmith", "Lee", "Smith", "Smithers"]
+
+def test_pages_continue_in_the_search_order():
+    """Tests that `after` seeks within the requested sort order."""
+    repo = MemoryPatientRepository(MemoryStore())
+    add_patients(repo)
+    by_dob = search(PATIENT_SEARCH, "sex=unknown", sort="dob")
+
+    first = repo.list(limit=2, search=by_dob)
+    rest = repo.list(limit=2, after=first[-1].patient_id, search=by_dob)
+
+    assert [p.given_name for p in first + rest] == ["Ann", "Dan", "Bob", "Cara"]
+
+def test_appointments_and_observations_take_search_filters():
+    """Tests the not-equal, any-of and numeric comparisons on appointments and observations."""
+    store = MemoryStore()
+    appointments = MemoryAppointmentRepository(store)
+    observations = MemoryObservationRepository(store)
+    for hours in range(3):
+        appointment = appointments.create(schemas.AppointmentCreate(
+            patientId="p-1", practitionerId="prac-1", start=START + timedelta(hours=hours), end=START + timedelta(hours=hours, minutes=30),
+        ))
+        if hours == 1:
+            appointments.update(appointment.appointment_id, {"status": "cancelled"})
+    for code, value in [("8867-4", 72), ("8867-4", 118), ("59408-5", 88), ("29463-7", 81)]:
+        observations.create(schemas.ObservationCreate.model_validate({
+            "patientId": "p-1", "code": {"system": "http://loinc.org", "code": code}, "value": value, "unit": "x", "effectiveAt": START,
+        }))
+
+    active = appointments.list(patient_id="p-1", search=search(APPOINTMENT_SEARCH, "status=ne:cancelled", sort="-start"))
+    low = observations.list("p-1", search=search(OBSERVATION_SEARCH, "code=8867-4,59408-5&value=lt100"))
+
+    assert [a.start.hour for a in active] == [11, 9]
+    assert sorted(o.value for o in low) == [72, 88]
+
+def test_postgres_translates_text_and_numeric_filters():
+    """Tests that text matches become ILIKE patterns across fields, with wildcards escaped."""
+    pool = MagicMock()
+    conn = MagicMock()
+    pool.connection.return_value.__enter__.return_value = conn
+    conn.execute.return_value.fetchall.return_value = []
+
+    PostgresPatientRepository(pool).list(limit=5, search=search(PATIENT_SEARCH, "name=sw:o_brien&dob=lt1990-01-01", sort="-dob"))
+
+    sql, params = conn.execute.call_args_list[-1][0]
+    assert sql == (
+        "SELECT * FROM patients WHERE (data->>'deletedAt') COLLATE \"C\" IS NULL"
+        " AND ((data->>'givenName') ILIKE %s OR (data->>'familyName') ILIKE %s)"
+        " AND (data->>'dob') COLLATE \"C\" < %s"
+        " ORDER BY (data->>'dob') COLLATE \"C\" DESC, id DESC LIMIT %s"
+    )
+    assert params == ["o\\_brien%", "o\\_brien%", "1990-01-01", 5]
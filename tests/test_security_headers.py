@@ -0,0 +1,79 @@
+from fastapi import FastAPI
+from fastapi.responses import HTMLResponse, Response
+from fastapi.testclient import TestClient
+
+from app.middleware.security_headers import API_CONTENT_SECURITY_POLICY, DOCS_CONTENT_SECURITY_POLICY, SecurityHeadersMiddleware
+
+# --- Test Setup ---
+
+app = FastAPI(docs_url=None, redoc_url=None)
+
+@app.get("/api/v1/patients/patient-1")
+def read_patient():
+    return {"patientId": "patient-1"}
+
+@app.get("/api/v1/wound-images/image-1")
+def read_image():
+    return Response(b"\xff\xd8", media_type="image/jpeg", headers={"Cache-Control": "private, max-age=60"})
+
+@app.get("/docs")
+def docs():
+    return HTMLResponse("<html></html>")
+
+@app.get("/healthz")
+def healthz():
+    return {"status": "ok"}
+
+@app.get("/framed")
+def framed():
+    return Response("", headers={"Content-Security-Policy": "frame-ancestors 'self'"})
+
+def make_client(**kwargs):
+    options = {"no_store_prefixes": ["/api"], "docs_prefixes": ["/docs"], "hsts_max_age_seconds": 31536000, "referrer_policy": "no-referrer"}
+    return TestClient(SecurityHeadersMiddleware(app, **{**options, **kwargs}))
+
+# --- Test Cases ---
+
+def test_api_responses_get_every_header():
+    response = make_client().get("/api/v1/patients/patient-1")
+
+    assert response.headers["Strict-Transport-Security"] == "max-age=31536000; includeSubDomains"
+    assert response.headers["X-Content-Type-Options"] == "nosniff"
+    assert response.headers["Content-Security-Policy"] == API_CONTENT_SECURITY_POLICY
+    assert response.headers["Referrer-Policy"] == "no-referrer"
+    assert response.headers["Cache-Control"] == "no-store"
+
+def test_no_store_replaces_caching_set_by_the_route():
+    """Tests that patient data is never cacheable, whatever the route asked for."""
+    response = make_client().get("/api/v1/wound-images/image-1")
+
+    assert response.headers["Cache-Control"] == "no-store"
+
+def test_routes_outside_the_groups_may_be_cached():
+    response = make_client().get("/healthz")
+
+    assert "Cache-Control" not in response.headers
+    assert response.headers["Content-Security-Policy"] == API_CONTENT_SECURITY_POLICY
+
+def test_docs_get_their_own_policy():
+    response = make_client().get("/docs")
+
+    assert response.headers["Content-Security-Policy"] == DOCS_CONTENT_SECURITY_POLICY
+    assert "https://cdn.jsdelivr.net" in DOCS_CONTENT_SECURITY_POLICY
+
+def test_headers_set_by_the_route_are_kept():
+    response = make_client().get("/framed")
+
+    assert response.headers["Content-Security-Policy"] == "frame-ancestors 'self'"
+
+def test_hsts_can_be_turned_off():
+    response = make_client(hsts_max_age_seconds=0).get("/healthz")
+
+    assert "Strict-Transport-Security" not in response.headers
+
+def test_error_responses_get_the_headers():
+    response = make_client().get("/api/v1/missing")
+
+    assert response.status_code == 404
+    assert response.headers["Cache-Control"] == "no-store"
+    assert response.headers["X-Content-Type-Options"] == "nosniff"
@@ -0,0 +1,157 @@
+import pytest
+import httpx
+import jwt
+from fastapi.testclient import TestClient
+
+from fastapi import FastAPI, Request
+from app.auth.google_tokens import GoogleIdTokenAuth, IdTokenProvider, REFRESH_MARGIN_SECONDS, service_client, verify_google_id_token
+from app.middleware.service_authentication import ServiceAuthenticationMiddleware
+
+# --- Test Setup ---
+
+AUDIENCE = "https://mega-care-api.example.run.app"
+BILLING_ACCOUNT = "billing@mega-care.iam.gserviceaccount.com"
+NOW = 1_714_550_400.0
+
+def make_claims(**overrides):
+    return {"iss": "https://accounts.google.com", "aud": AUDIENCE, "email": BILLING_ACCOUNT, "email_verified": True, **overrides}
+
+def make_id_token(expires_at):
+    return jwt.encode({"aud": AUDIENCE, "exp": int(expires_at)}, "metadata-server", algorithm="HS256")
+
+def fake_verify(token, audience):
+    if token != "good-token" or audience != AUDIENCE:
+        raise ValueError("Token has wrong audience")
+    return make_claims()
+
+app = FastAPI()
+
+@app.get("/internal/services/whoami")
+def whoami(request: Request):
+    return {"email": request.state.service_caller["email"]}
+
+@app.get("/api/v1/health")
+def unrelated():
+    return {"status": "ok"}
+
+client = TestClient(ServiceAuthenticationMiddleware(app, audience=AUDIENCE, allowed_callers=[BILLING_ACCOUNT], verify=fake_verify))
+
+class FakeClock:
+    def __init__(self):
+        self.now = NOW
+
+    def __call__(self):
+        return self.now
+
+@pytest.fixture
+def metadata_server():
+    """A metadata server that mints a one-hour token per request and records the requests."""
+    requests = []
+
+    def handler(request):
+        requests.append(request)
+        return httpx.Response(200, text=make_id_token(NOW + 3600 * len(requests)))
+    yield requests, httpx.Client(transport=httpx.MockTransport(handler))
+
+# --- Token Verification Test Cases ---
+
+def test_verify_google_id_token_returns_google_claims(monkeypatch):
+    """Tests that a verified Google token's claims are returned for the expected audience."""
+    from google.oauth2 import id_token
+    seen = {}
+    def verify_oauth2_token(token, request, audience):
+        seen["audience"] = audience
+        return make_claims()
+    monkeypatch.setattr(id_token, "verify_oauth2_token", verify_oauth2_token)
+
+    assert verify_google_id_token("token", AUDIENCE)["email"] == BILLING_ACCOUNT
+    assert seen["audience"] == AUDIENCE
+
+def test_verify_google_id_token_rejects_unusable_claims(monkeypatch):
+    """Tests that tokens of other issuers or without a verified email are rejected."""
+    from google.oauth2 import id_token
+    for claims in [make_claims(email_verified=False), make_claims(email=None), make_claims(iss="https://issuer.example")]:
+        monkeypatch.setattr(id_token, "verify_oauth2_token", lambda token, request, audience: claims)
+
+        with pytest.raises(ValueError):
+            verify_google_id_token("token", AUDIENCE)
+
+# --- Middleware Test Cases ---
+
+def test_allowed_service_account_reaches_internal_route():
+    """Tests that a valid token of an allowed account is admitted and its claims exposed."""
+    response = client.get("/internal/services/whoami", headers={"Authorization": "Bearer good-token"})
+
+    assert response.status_code == 200
+    assert response.json() == {"email": BILLING_ACCOUNT}
+
+def test_missing_or_invalid_token_is_unauthorized():
+    """Tests that calls without a valid token get 401 with a bearer challenge."""
+    missing = client.get("/internal/services/whoami")
+    invalid = client.get("/internal/services/whoami", headers={"Authorization": "Bearer forged"})
+
+    assert missing.status_code == 401
+    assert invalid.status_code == 401
+    assert invalid.headers["WWW-Authenticate"] == "Bearer"
+
+def test_other_service_accounts_are_forbidden():
+    """Tests that a valid token of an account not in the allowlist gets 403."""
+    strict = TestClient(ServiceAuthenticationMiddleware(app, audience=AUDIENCE, allowed_callers=["reports@mega-care.iam.gserviceaccount.com"], verify=fake_verify))
+
+    assert strict.get("/internal/services/whoami", headers={"Authorization": "Bearer good-token"}).status_code == 403
+
+def test_unconfigured_middleware_refuses_every_call():
+    """Tests that without allowed callers no token is accepted."""
+    closed = TestClient(ServiceAuthenticationMiddleware(app, audience=AUDIENCE, allowed_callers=[], verify=fake_verify))
+
+    assert closed.get("/internal/services/whoami", headers={"Authorization": "Bearer good-token"}).status_code == 403
+
+def test_routes_outside_prefix_are_untouched():
+    """Tests that other routes are left to their own authentication."""
+    assert client.get("/api/v1/health").status_code == 200
+
+# --- Outbound Token Test Cases ---
+
+def test_provider_requests_full_token_for_audience(metadata_server):
+    """Tests that tokens are requested from the metadata server with the audience and header it needs."""
+    requests, metadata_client = metadata_server
+
+    IdTokenProvider(metadata_client, clock=FakeClock()).token(AUDIENCE)
+
+    [request] = requests
+    assert request.headers["Metadata-Flavor"] == "Google"
+    assert request.url.params["audience"] == AUDIENCE
+    assert request.url.params["format"] == "full"
+
+def test_provider_reuses_token_until_near_expiry(metadata_server):
+    """Tests that a token is cached per audience and replaced shortly before it expires."""
+    requests, metadata_client = metadata_server
+    clock = FakeClock()
+    provider = IdTokenProvider(metadata_client, clock=clock)
+
+    first = provider.token(AUDIENCE)
+    clock.now += 3600 - REFRESH_MARGIN_SECONDS - 1
+    assert provider.token(AUDIENCE) == first
+    assert len(requests) == 1
+
+    clock.now += 2
+    assert provider.token(AUDIENCE) != first
+    provider.token("https://billing.example.run.app")
+    assert len(requests) == 3
+
+def test_service_client_sends_id_token_for_target(metadata_server):
+    """Tests that the client authenticates each call with a token minted for the target URL."""
+    minted, metadata_client = metadata_server
+    provider = IdTokenProvider(metadata_client, clock=FakeClock())
+    sent = []
+    def target(request):
+        sent.append(request)
+        return httpx.Response(200, json={})
+
+    with service_client(AUDIENCE + "/", provider=provider, transport=httpx.MockTransport(target)) as billing:
+        billing.get("/internal/services/whoami")
+
+    [request] = sent
+    assert request.headers["Authorization"] == f"Bearer {provider.token(AUDIENCE)}"
+    assert [mint.url.params["audience"] for mint in minted] == [AUDIENCE]
+    assert isinstance(billing.auth, GoogleIdTokenAuth)
@@ -0,0 +1,270 @@
+import jwt
+import pytest
+from datetime import datetime, timedelta, timezone
+from fastapi.testclient import TestClient
+
+from fastapi import FastAPI
+from app.api.v1.deps import get_session_repository
+from app.api.v1.endpoints import sessions
+from app.auth.lockout import LoginGuard
+from app.auth.sessions import (
+    SESSION_ISSUER,
+    SessionError,
+    SessionManager,
+    SessionTokenVerifier,
+    format_refresh_token,
+    new_session_id,
+    parse_refresh_token,
+)
+from app.auth.verifier import InvalidTokenError, TokenVerifier
+from app.repositories.memory import MemoryLockoutRepository, MemorySessionRepository, MemoryStore
+
+# --- Test Setup ---
+
+SIGNING_KEY = "k" * 32
+NOW = datetime(2024, 5, 1, 9, 0, tzinfo=timezone.utc)
+SIGNED_IN = int((NOW - timedelta(minutes=1)).timestamp())
+
+class FakeIdentityVerifier(TokenVerifier):
+    """Accepts "id-token-<uid>" as an ID token of <uid>."""
+
+    def verify(self, token):
+        if not token.startswith("id-token-"):
+            raise InvalidTokenError("Not an ID token")
+        uid = token[len("id-token-"):]
+        return {"uid": uid, "roles": ["patient"], "patientId": f"patient-{uid}", "auth_time": SIGNED_IN, "firebase": {"sign_in_provider": "password"}}
+
+def make_manager(repo, now=None, credentials_valid_after=lambda uid: None):
+    now = now or [NOW]
+    return SessionManager(
+        FakeIdentityVerifier(), SIGNING_KEY, repository_factory=lambda: repo,
+        idle_seconds=3600, max_age_seconds=3 * 3600, credentials_valid_after=credentials_valid_after, clock=lambda: now[0],
+    )
+
+def make_verifier(repo, clock=lambda: 0.0):
+    return SessionTokenVerifier(FakeIdentityVerifier(), SIGNING_KEY, repository_factory=lambda: repo, cache_seconds=30, clock=clock)
+
+# --- Refresh Token Test Cases ---
+
+def test_refresh_tokens_round_trip():
+    session_id, secret = new_session_id(), "s" * 43
+
+    assert parse_refresh_token(format_refresh_token(session_id, secret)) == (session_id, secret)
+    assert parse_refresh_token("mcr_short_secret") is None
+    assert parse_refresh_token(f"mck_{session_id}_{secret}") is None
+
+def test_session_starts_from_an_id_token():
+    """Tests that the session keeps the identity claims access tokens need, but not the rest of the ID token."""
+    repo = MemorySessionRepository(MemoryStore())
+    _, tokens = make_manager(repo).start("id-token-u1", device_label="Kitchen iPad", user_agent="Safari", source_ip="203.0.113.7")
+
+    record = repo.get(tokens.session.session_id)
+    assert record.uid == "u1"
+    assert record.device_label == "Kitchen iPad"
+    assert record.claims == {"roles": ["patient"], "patientId": "patient-u1"}
+    assert record.authenticated_at == datetime.fromtimestamp(SIGNED_IN, tz=timezone.utc)
+    assert parse_refresh_token(tokens.refresh_token)[0] == record.session_id
+    with pytest.raises(InvalidTokenError):
+        make_manager(repo).start("forged")
+
+def test_refresh_rotates_the_refresh_token():
+    """Tests that a refresh returns a new pair and the refresh token it replaced no longer works."""
+    repo = MemorySessionRepository(MemoryStore())
+    manager = make_manager(repo)
+    _, first = manager.start("id-token-u1")
+
+    second = manager.refresh(first.refresh_token)
+    third = manager.refresh(second.refresh_token)
+
+    assert len({first.refresh_token, second.refresh_token, third.refresh_token}) == 3
+    assert third.session.session_id == first.session.session_id
+
+def test_reused_refresh_token_revokes_the_session():
+    """Tests that presenting a replaced refresh token again signs the session out, including its newest token."""
+    repo = MemorySessionRepository(MemoryStore())
+    manager = make_manager(repo)
+    _, first = manager.start("id-token-u1")
+    second = manager.refresh(first.refresh_token)
+
+    with pytest.raises(SessionError):
+        manager.refresh(first.refresh_token)
+
+    assert repo.get(first.session.session_id).revoked_reason == "refresh-token-reused"
+    with pytest.raises(SessionError):
+        manager.refresh(second.refresh_token)
+
+def test_forged_refresh_token_leaves_the_session_alone():
+    """Tests that a wrong secret for a known session ID is refused without signing the user out."""
+    repo = MemorySessionRepository(MemoryStore())
+    manager = make_manager(repo)
+    _, tokens = manager.start("id-token-u1")
+
+    with pytest.raises(SessionError):
+        manager.refresh(format_refresh_token(tokens.session.session_id, "x" * 43))
+
+    assert repo.get(tokens.session.session_id).status == "active"
+
+def test_idle_and_old_sessions_expire():
+    """Tests that a session ends when not refreshed within the idle timeout, and at its maximum age regardless."""
+    repo = MemorySessionRepository(MemoryStore())
+    now = [NOW]
+    manager = make_manager(repo, now=now)
+    _, tokens = manager.start("id-token-u1")
+
+    now[0] += timedelta(minutes=50)
+    tokens = manager.refresh(tokens.refresh_token)
+    now[0] += timedelta(minutes=50)
+    tokens = manager.refresh(tokens.refresh_token)
+    now[0] += timedelta(minutes=61)
+    with pytest.raises(SessionError):
+        manager.refresh(tokens.refresh_token)
+
+    now[0] = NOW
+    _, tokens = manager.start("id-token-u2")
+    for _ in range(3):
+        now[0] += timedelta(minutes=59)
+        tokens = manager.refresh(tokens.refresh_token)
+    # Three hours after sign-in, however recently it was refreshed.
+    assert tokens.session.expires_at == datetime.fromtimestamp(SIGNED_IN, tz=timezone.utc) + timedelta(hours=3)
+    now[0] += timedelta(minutes=3)
+    with pytest.raises(SessionError):
+        manager.refresh(tokens.refresh_token)
+
+def test_password_change_revokes_sessions_signed_in_before_it():
+    """Tests that a session cannot be refreshed once the user's credentials changed after they signed in."""
+    repo = MemorySessionRepository(MemoryStore())
+    changed = {}
+    manager = make_manager(repo, credentials_valid_after=lambda uid: changed.get(uid))
+    _, tokens = manager.start("id-token-u1")
+    tokens = manager.refresh(tokens.refresh_token)
+
+    changed["u1"] = NOW
+    with pytest.raises(SessionError):
+        manager.refresh(tokens.refresh_token)
+
+    assert repo.get(tokens.session.session_id).revoked_reason == "credentials-changed"
+
+def test_revoke_all_keeps_the_current_session():
+    repo = MemorySessionRepository(MemoryStore())
+    manager = make_manager(repo)
+    _, phone = manager.start("id-token-u1")
+    _, tablet = manager.start("id-token-u1")
+    _, other_user = manager.start("id-token-u2")
+
+    assert manager.revoke_all("u1", "signed-out-elsewhere", keep=phone.session.session_id) == 1
+
+    assert [record.session_id for record in repo.list_active("u1")] == [phone.session.session_id]
+    assert repo.get(tablet.session.session_id).status == "revoked"
+    assert repo.get(other_user.session.session_id).status == "active"
+
+# --- Access Token Test Cases ---
+
+def test_access_tokens_verify_as_the_session_user():
+    repo = MemorySessionRepository(MemoryStore())
+    _, tokens = make_manager(repo, now=[datetime.now(timezone.utc)]).start("id-token-u1")
+
+    claims = make_verifier(repo).verify(tokens.access_token)
+
+    assert claims["uid"] == "u1"
+    assert claims["roles"] == ["patient"]
+    assert claims["patientId"] == "patient-u1"
+    assert claims["sid"] == tokens.session.session_id
+    assert claims["iss"] == SESSION_ISSUER
+    assert claims["authMethod"] == "session"
+
+def test_other_tokens_go_to_the_identity_verifier():
+    """Tests that ID tokens are still verified by the AUTH_PROVIDER verifier, and garbage by neither."""
+    seen = []
+    class RecordingVerifier(TokenVerifier):
+        def verify(self, token):
+            seen.append(token)
+            return {"uid": "u1", "roles": []}
+    verifier = SessionTokenVerifier(RecordingVerifier(), SIGNING_KEY, repository_factory=lambda: MemorySessionRepository(MemoryStore()))
+    id_token = jwt.encode({"iss": "https://securetoken.google.com/megacare", "sub": "u1"}, "firebase-key-" * 3)
+
+    assert verifier.verify(id_token)["uid"] == "u1"
+    assert seen == [id_token]
+    with pytest.raises(InvalidTokenError):
+        verifier.verify("not-a-jwt")
+
+def test_access_tokens_of_a_revoked_session_stop_working_once_cache_expires():
+    repo = MemorySessionRepository(MemoryStore())
+    manager = make_manager(repo, now=[datetime.now(timezone.utc)])
+    _, tokens = manager.start("id-token-u1")
+    now = [0.0]
+    verifier = make_verifier(repo, clock=lambda: now[0])
+    verifier.verify(tokens.access_token)
+
+    manager.end(tokens.refresh_token)
+    verifier.verify(tokens.access_token)
+    now[0] += 31
+
+    with pytest.raises(InvalidTokenError):
+        verifier.verify(tokens.access_token)
+
+def test_access_tokens_signed_with_another_key_are_refused():
+    repo = MemorySessionRepository(MemoryStore())
+    manager = make_manager(repo, now=[datetime.now(timezone.utc)])
+    manager.signing_key = "o" * 32
+    _, tokens = manager.start("id-token-u1")
+
+    with pytest.raises(InvalidTokenError):
+        make_verifier(repo).verify(tokens.access_token)
+
+# --- Endpoint Test Cases ---
+
+endpoint_repo = MemorySessionRepository(MemoryStore())
+endpoint_manager = make_manager(endpoint_repo, now=[datetime.now(timezone.utc)])
+endpoint_verifier = make_verifier(endpoint_repo)
+
+lockout_repo = MemoryLockoutRepository(MemoryStore())
+
+app = FastAPI()
+app.include_router(sessions.router, prefix="/api/v1/auth/sessions")
+app.dependency_overrides[sessions.session_manager] = lambda: endpoint_manager
+app.dependency_overrides[get_session_repository] = lambda: endpoint_repo
+app.dependency_overrides[sessions.login_guard] = lambda: LoginGuard(repository_factory=lambda: lockout_repo)
+
+client = TestClient(app)
+
+@pytest.fixture(autouse=True)
+def session_tokens(monkeypatch):
+    from app.dependencies import auth
+    monkeypatch.setattr(auth, "get_token_verifier", lambda: endpoint_verifier)
+
+def test_sign_in_refresh_and_log_out_over_http():
+    started = client.post("/api/v1/auth/sessions", json={"idToken": "id-token-u9", "deviceLabel": "Phone"}, headers={"User-Agent": "PortalApp/2.1"})
+    assert started.status_code == 201
+    body = started.json()
+    assert body["token_type"] == "Bearer"
+    assert body["session"]["device_label"] == "Phone"
+    assert body["session"]["user_agent"] == "PortalApp/2.1"
+
+    refreshed = client.post("/api/v1/auth/sessions/refresh", json={"refreshToken": body["refresh_token"]})
+    replayed = client.post("/api/v1/auth/sessions/refresh", json={"refreshToken": body["refresh_token"]})
+    assert refreshed.status_code == 200
+    assert replayed.status_code == 401
+
+    assert client.post("/api/v1/auth/sessions", json={"idToken": "forged"}).status_code == 401
+
+def test_users_list_and_sign_out_their_other_devices():
+    phone = client.post("/api/v1/auth/sessions", json={"idToken": "id-token-u7", "deviceLabel": "Phone"}).json()
+    laptop = client.post("/api/v1/auth/sessions", json={"idToken": "id-token-u7", "deviceLabel": "Laptop"}).json()
+    headers = {"Authorization": f"Bearer {phone['access_token']}"}
+
+    listed = client.get("/api/v1/auth/sessions", headers=headers).json()
+    assert {(s["device_label"], s["current"]) for s in listed} == {("Phone", True), ("Laptop", False)}
+
+    revoked = client.post("/api/v1/auth/sessions/revoke-others", headers=headers)
+    assert revoked.json() == {"revoked": 1}
+    assert client.post("/api/v1/auth/sessions/refresh", json={"refreshToken": laptop["refresh_token"]}).status_code == 401
+    assert [s["device_label"] for s in client.get("/api/v1/auth/sessions", headers=headers).json()] == ["Phone"]
+
+def test_users_cannot_sign_out_others_sessions():
+    mine = client.post("/api/v1/auth/sessions", json={"idToken": "id-token-u5"}).json()
+    theirs = client.post("/api/v1/auth/sessions", json={"idToken": "id-token-u6"}).json()
+
+    response = client.delete(f"/api/v1/auth/sessions/{theirs['session']['session_id']}", headers={"Authorization": f"Bearer {mine['access_token']}"})
+
+    assert response.status_code == 404
+    assert endpoint_repo.get(theirs["session"]["session_id"]).status == "active"
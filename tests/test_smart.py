@@ -0,0 +1,202 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+from fastapi import APIRouter, Depends, FastAPI
+from app.api.fhir.endpoints import metadata
+from app.api.v1 import schemas
+from app.authz import ownership
+from app.authz.smart import authorize_fhir
+from app.dependencies.auth import get_current_user
+from app.fhir import smart
+from app.fhir.smart import SmartScope, get_smart_configuration, granted_scopes, launch_context, parse_scope
+from app.repositories.observations import ObservationRepository
+
+# --- Test Setup ---
+
+NOW = datetime(2024, 5, 1, 9, 0, tzinfo=timezone.utc)
+AUTHORIZE_URL = "https://auth.megacare.example/authorize"
+TOKEN_URL = "https://auth.megacare.example/oauth/token"
+
+def make_token_claims(scope, **claims):
+    return {"uid": "app-user", "roles": [], "scope": scope, **claims}
+
+def make_observation(observation_id="obs-1", patient_id="patient-1"):
+    return schemas.Observation.model_validate({
+        "observationId": observation_id, "patientId": patient_id,
+        "code": {"system": "http://loinc.org", "code": "59408-5", "display": "SpO2"},
+        "value": 96, "unit": "%", "effectiveAt": NOW, "createdAt": NOW, "updatedAt": NOW,
+    })
+
+patient_router = APIRouter()
+
+@patient_router.get("/{patientId}")
+def read_patient(patientId: str):
+    return {"id": patientId}
+
+observation_router = APIRouter()
+
+@observation_router.get("/{observationId}")
+def read_observation(observationId: str):
+    return {"id": observationId}
+
+@observation_router.get("")
+def search_observations(patient: str = None):
+    return {"entry": []}
+
+@observation_router.post("")
+def create_observation(resource: dict):
+    return resource
+
+export_router = APIRouter()
+
+@export_router.post("/$export")
+def export():
+    return {}
+
+app = FastAPI()
+app.include_router(patient_router, prefix="/fhir/Patient", dependencies=[Depends(authorize_fhir("Patient"))])
+app.include_router(observation_router, prefix="/fhir/Observation", dependencies=[Depends(authorize_fhir("Observation"))])
+app.include_router(export_router, prefix="/fhir", dependencies=[Depends(authorize_fhir("*", action="r"))])
+app.include_router(metadata.router, prefix="/fhir")
+client = TestClient(app)
+
+@pytest.fixture
+def observations(monkeypatch):
+    """Serves observations of patient-1 and patient-2 from a mock repository."""
+    repo = MagicMock(spec=ObservationRepository)
+    repo.get.side_effect = lambda observation_id: {
+        "obs-1": make_observation("obs-1", "patient-1"),
+        "obs-2": make_observation("obs-2", "patient-2"),
+    }.get(observation_id)
+    monkeypatch.setattr(ownership, "get_observation_repository", lambda: repo)
+    yield repo
+    app.dependency_overrides.pop(get_current_user, None)
+
+# --- Scope Test Cases ---
+
+def test_parse_scope_reads_v1_and_v2_syntax():
+    """Tests that v1 `read`/`write` and v2 action letters become the same actions."""
+    assert parse_scope("patient/*.read") == SmartScope("patient", "*", frozenset("rs"))
+    assert parse_scope("user/Observation.write") == SmartScope("user", "Observation", frozenset("cud"))
+    assert parse_scope("system/*.*") == SmartScope("system", "*", frozenset("cruds"))
+    assert parse_scope("patient/Observation.rs") == SmartScope("patient", "Observation", frozenset("rs"))
+
+def test_parse_scope_ignores_non_resource_scopes():
+    """Tests that OpenID, launch and malformed scopes are not resource scopes."""
+    for scope in ["openid", "fhirUser", "launch/patient", "patient/Observation.", "patient/observation.read", "group/*.read"]:
+        assert parse_scope(scope) is None
+
+def test_granted_scopes_accepts_string_or_list_claims():
+    """Tests that scopes are read from a space-separated `scope` claim or an `scp` list."""
+    assert len(granted_scopes({"scope": "openid launch/patient patient/*.read"})) == 1
+    assert len(granted_scopes({"scp": ["patient/Patient.read", "user/Observation.write"]})) == 2
+    assert granted_scopes({"uid": "user-1"}) == []
+
+def test_scope_matches_type_and_action():
+    """Tests that a scope allows its own resource type, or all types for `*`, and only its actions."""
+    scope = parse_scope("patient/Observation.read")
+
+    assert scope.allows("Observation", "s")
+    assert not scope.allows("Observation", "c")
+    assert not scope.allows("Patient", "r")
+    assert parse_scope("patient/*.read").allows("Patient", "r")
+
+def test_launch_context_reads_token_claims():
+    """Tests that the launch patient, encounter and user come from the token."""
+    context = launch_context({"patient": "patient-1", "encounter": "enc-1", "fhirUser": "Practitioner/prac-1"})
+
+    assert context == {"patient": "patient-1", "encounter": "enc-1", "fhirUser": "Practitioner/prac-1"}
+
+# --- Authorization Test Cases ---
+
+def test_patient_scope_reaches_only_launch_patient(observations):
+    """Tests that patient-level scopes reach the launch patient's records by path, lookup, search and body only."""
+    app.dependency_overrides[get_current_user] = lambda: make_token_claims("launch/patient patient/*.read patient/Observation.write", patient="patient-1")
+
+    assert client.get("/fhir/Patient/patient-1").status_code == 200
+    assert client.get("/fhir/Patient/patient-2").status_code == 403
+    assert client.get("/fhir/Observation/obs-1").status_code == 200
+    assert client.get("/fhir/Observation/obs-2").status_code == 403
+    assert client.get("/fhir/Observation", params={"patient": "Patient/patient-1"}).status_code == 200
+    assert client.get("/fhir/Observation").status_code == 403
+    assert client.post("/fhir/Observation", json={"subject": {"reference": "Patient/patient-1"}}).status_code == 200
+    assert client.post("/fhir/Observation", json={"subject": {"reference": "Patient/patient-2"}}).status_code == 403
+    mismatched = client.post("/fhir/Observation", params={"patient": "patient-1"}, json={"subject": {"reference": "Patient/patient-2"}})
+    assert mismatched.status_code == 400
+
+def test_missing_scope_is_refused_with_insufficient_scope(observations):
+    """Tests that an interaction outside the token's scopes gets 403 with an OAuth error."""
+    app.dependency_overrides[get_current_user] = lambda: make_token_claims("patient/Patient.read", patient="patient-1")
+
+    response = client.get("/fhir/Observation/obs-1")
+
+    assert response.status_code == 403
+    assert response.headers["WWW-Authenticate"] == 'Bearer error="insufficient_scope"'
+
+def test_user_scope_still_needs_user_roles(observations):
+    """Tests that user-level scopes only reach what the user's roles allow."""
+    app.dependency_overrides[get_current_user] = lambda: make_token_claims("user/Observation.read")
+    assert client.get("/fhir/Observation/obs-2").status_code == 403
+
+    app.dependency_overrides[get_current_user] = lambda: {**make_token_claims("user/Observation.read"), "roles": ["clinician"]}
+    assert client.get("/fhir/Observation/obs-2").status_code == 200
+
+def test_bulk_export_needs_system_or_user_scope_for_all_types(observations):
+    """Tests that bulk export is open to system/*.read tokens but not patient-level ones."""
+    app.dependency_overrides[get_current_user] = lambda: make_token_claims("system/*.read")
+    assert client.post("/fhir/$export").status_code == 200
+
+    app.dependency_overrides[get_current_user] = lambda: make_token_claims("patient/*.read", patient="patient-1")
+    assert client.post("/fhir/$export").status_code == 403
+
+def test_tokens_without_smart_scopes_are_checked_by_role(observations):
+    """Tests that ordinary tokens keep the role-based rules of /api/v1/patients."""
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "user-1", "roles": ["coordinator"]}
+
+    assert client.get("/fhir/Observation/obs-1").status_code == 200
+    assert client.post("/fhir/Observation", json={"subject": {"reference": "Patient/patient-1"}}).status_code == 403
+
+# --- Configuration Test Cases ---
+
+def test_smart_configuration_discovers_endpoints_from_issuer(monkeypatch):
+    """Tests that unset endpoints are taken from the OIDC issuer's discovery document."""
+    settings = MagicMock(
+        smart_authorization_endpoint=None, smart_token_endpoint=None, oidc_jwks_url=None,
+        oidc_issuer="https://auth.megacare.example/",
+    )
+    monkeypatch.setattr(smart, "get_settings", lambda: settings)
+    monkeypatch.setattr(smart, "fetch_openid_configuration", lambda issuer: {
+        "authorization_endpoint": AUTHORIZE_URL, "token_endpoint": TOKEN_URL, "jwks_uri": "https://auth.megacare.example/jwks",
+    })
+    get_smart_configuration.cache_clear()
+    try:
+        configuration = get_smart_configuration()
+    finally:
+        get_smart_configuration.cache_clear()
+
+    assert configuration["authorization_endpoint"] == AUTHORIZE_URL
+    assert configuration["token_endpoint"] == TOKEN_URL
+    assert configuration["jwks_uri"] == "https://auth.megacare.example/jwks"
+    assert "launch-ehr" in configuration["capabilities"]
+    assert "S256" in configuration["code_challenge_methods_supported"]
+
+def test_smart_configuration_endpoint_advertises_oauth_uris(monkeypatch):
+    """Tests that the well-known document and the CapabilityStatement name the configured endpoints."""
+    monkeypatch.setattr(metadata, "get_smart_configuration", lambda: smart.smart_configuration(AUTHORIZE_URL, TOKEN_URL))
+
+    document = client.get("/fhir/.well-known/smart-configuration")
+    capability = client.get("/fhir/metadata").json()
+
+    assert document.status_code == 200
+    assert document.json()["token_endpoint"] == TOKEN_URL
+    [uris] = capability["rest"][0]["security"]["extension"]
+    assert {"url": "authorize", "valueUri": AUTHORIZE_URL} in uris["extension"]
+
+def test_smart_configuration_endpoint_without_configuration(monkeypatch):
+    """Tests that the well-known document is 404 when no authorization server is configured."""
+    monkeypatch.setattr(metadata, "get_smart_configuration", lambda: None)
+
+    assert client.get("/fhir/.well-known/smart-configuration").status_code == 404
+    assert "security" not in client.get("/fhir/metadata").json()["rest"][0]
@@ -0,0 +1,268 @@
+import base64
+import hashlib
+import hmac
+import pytest
+from datetime import datetime, timezone
+from unittest.mock import MagicMock
+from urllib.parse import urlencode
+from zoneinfo import ZoneInfo
+
+import httpx
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api.integrations.endpoints import sms_events
+from app.api.v1 import schemas
+from app.api.v1.deps import get_notification_repository, get_patient_repository
+from app.notifications.notifier import Notifier, SmsThrottle
+from app.notifications.sms import OutgoingSms, SmsDeliveryError, SmsRecipientOptedOut, SmsRejected, TwilioSender
+from app.notifications.templates import TemplateContext, render_sms
+from app.notifications.twilio_events import SIGNATURE_HEADER, delivery_event, opt_out_keyword, verify_signature
+from app.ratelimit.buckets import MemoryTokenBuckets
+from app.repositories.memory import MemoryNotificationRepository, MemoryOrganizationRepository, MemoryPatientRepository, MemoryStore
+from app.tasks import jobs
+from app.tasks.registry import run_task
+
+# --- Test Setup ---
+
+COMMON = TemplateContext(organization_name="Chiang Mai Sleep Clinic", portal_url="https://care.example.com", timezone=ZoneInfo("Asia/Bangkok"))
+PHONE = "+66812345678"
+
+def add_patient(patients, mrn="MRN-1", phone_number=PHONE):
+    return patients.create(schemas.PatientCreate(
+        givenName="Ann", familyName="Lee", dob="1980-01-01", mrn=mrn,
+        contact={"phoneNumber": phone_number, "email": "ann.lee@example.com"},
+    ))
+
+def queued_text(notifications, patient):
+    return notifications.create(schemas.NotificationCreate(
+        channel="sms", template="result-ready", recipient=PHONE, patient_id=patient.patient_id, context={"patientName": "Ann"},
+    ))
+
+def twilio_answering(status_code, body=None, messaging_service_sid=None):
+    http = MagicMock()
+    http.post.return_value = httpx.Response(status_code, json=body or {})
+    sender = TwilioSender("AC123", "token", "+15005550006", messaging_service_sid, "https://api.megacare.example/", 10, client=http)
+    return sender, http
+
+def use_sms_job(monkeypatch, store):
+    """Points the SMS job at `store` and a mock sender named twilio."""
+    sender = MagicMock()
+    sender.name, sender.sender = "twilio", "+15005550006"
+    sender.send.return_value = "SM123"
+    monkeypatch.setattr(jobs, "get_sms_sender", lambda: sender)
+    monkeypatch.setattr(jobs, "get_notification_repository", lambda: MemoryNotificationRepository(store))
+    monkeypatch.setattr(jobs, "get_patient_repository", lambda: MemoryPatientRepository(store))
+    monkeypatch.setattr(jobs, "get_organization_repository", lambda: MemoryOrganizationRepository(store))
+    return sender
+
+def twilio_signature(url, params):
+    signed = url + "".join(name + params[name] for name in sorted(params))
+    return base64.b64encode(hmac.new(b"token", signed.encode(), hashlib.sha1).digest()).decode()
+
+# --- Message Test Cases ---
+
+def test_text_names_the_sender_and_how_to_opt_out():
+    """Tests that a text is the template's first paragraph, the link, and the STOP footer."""
+    body = render_sms("result-ready", {"patientName": "Ann"}, COMMON)
+
+    assert body == "Chiang Mai Sleep Clinic: A new result is ready for you to view. https://care.example.com Reply STOP to opt out."
+
+def test_twilio_sender_posts_the_message_with_a_status_callback():
+    """Tests the Messages API call: the number, the body and a callback naming the notification."""
+    sender, http = twilio_answering(201, body={"sid": "SM123"})
+
+    assert sender.send(OutgoingSms(notification_id="n-1", to=PHONE, body="Hello")) == "SM123"
+
+    assert http.post.call_args.args[0] == "https://api.twilio.com/2010-04-01/Accounts/AC123/Messages.json"
+    assert http.post.call_args.kwargs["auth"] == ("AC123", "token")
+    assert http.post.call_args.kwargs["data"] == {
+        "To": PHONE, "Body": "Hello", "From": "+15005550006",
+        "StatusCallback": "https://api.megacare.example/integrations/sms/twilio/status?notificationId=n-1",
+    }
+
+def test_twilio_sender_prefers_the_messaging_service():
+    """Tests that a Messaging Service is sent from instead of the number."""
+    sender, http = twilio_answering(201, body={"sid": "SM123"}, messaging_service_sid="MG123")
+
+    sender.send(OutgoingSms(notification_id="n-1", to=PHONE, body="Hello"))
+
+    data = http.post.call_args.kwargs["data"]
+    assert data["MessagingServiceSid"] == "MG123" and "From" not in data
+    assert sender.sender == "MG123"
+
+def test_twilio_errors():
+    """Tests that an unsubscribed recipient, other refusals and outages raise their own errors."""
+    sender, _http = twilio_answering(400, body={"code": 21610, "message": "Attempt to send to unsubscribed recipient"})
+    with pytest.raises(SmsRecipientOptedOut):
+        sender.send(OutgoingSms(notification_id="n-1", to=PHONE, body="Hello"))
+
+    sender, _http = twilio_answering(400, body={"code": 21211, "message": "Invalid 'To' Phone Number"})
+    with pytest.raises(SmsRejected, match="21211"):
+        sender.send(OutgoingSms(notification_id="n-1", to=PHONE, body="Hello"))
+
+    sender, _http = twilio_answering(503)
+    with pytest.raises(SmsDeliveryError):
+        sender.send(OutgoingSms(notification_id="n-1", to=PHONE, body="Hello"))
+
+# --- Webhook Parsing Test Cases ---
+
+def test_signature_matches_twilio_example():
+    """Tests the signature against the example in Twilio's webhook security documentation."""
+    params = {"CallSid": "CA1234567890ABCDE", "Caller": "+12349013030", "Digits": "1234", "From": "+12349013030", "To": "+18005551212"}
+    url = "https://mycompany.com/myapp.php?foo=1&bar=2"
+
+    assert verify_signature("12345", url, params, "0/KCTR6DLpKmkAf8muzZqo1nDgQ=")
+    assert not verify_signature("12345", url, {**params, "Digits": "9999"}, "0/KCTR6DLpKmkAf8muzZqo1nDgQ=")
+
+def test_only_whole_keywords_change_preferences():
+    """Tests STOP and START keywords in any case, and that other replies are ignored."""
+    assert opt_out_keyword(" stop ") is True
+    assert opt_out_keyword("Unsubscribe") is True
+    assert opt_out_keyword("START") is False
+    assert opt_out_keyword("please stop by at 3") is None
+
+def test_final_statuses_become_delivery_events():
+    """Tests that delivered, undelivered and failed are recorded, once per message and status."""
+    event = delivery_event({"MessageSid": "SM123", "MessageStatus": "undelivered", "ErrorCode": "30003"})
+
+    assert (event.status, event.reason, event.provider_event_id) == ("bounced", "Twilio error 30003", "SM123:undelivered")
+    assert delivery_event({"MessageSid": "SM123", "MessageStatus": "sent"}) is None
+
+# --- Notifier Test Cases ---
+
+def test_notifier_texts_unless_opted_out():
+    """Tests that a text is queued to the patient's number, and none once they opted out."""
+    store = MemoryStore()
+    patients = MemoryPatientRepository(store)
+    notifications = MemoryNotificationRepository(store)
+    tasks = MagicMock()
+    notifier = Notifier(notifications, tasks, email=False, sms_throttle=SmsThrottle(MemoryTokenBuckets(), 100, 5))
+    patient = add_patient(patients)
+
+    [text] = notifier.notify("result-ready", patient, {})
+    assert (text.channel, text.recipient, text.status) == ("sms", PHONE, "queued")
+    assert tasks.enqueue.call_args.args[0] == jobs.SMS_NOTIFICATION_TASK
+
+    [opted_out] = patients.set_sms_opt_out(PHONE, True)
+    assert notifier.notify("result-ready", opted_out, {}) == []
+
+def test_texts_over_the_caps_are_throttled():
+    """Tests that texts beyond the organization's hourly cap are recorded as throttled and not queued."""
+    store = MemoryStore()
+    patients = MemoryPatientRepository(store)
+    tasks = MagicMock()
+    notifier = Notifier(MemoryNotificationRepository(store), tasks, email=False, sms_throttle=SmsThrottle(MemoryTokenBuckets(clock=lambda: 0.0), 3, 5))
+
+    statuses = [notifier.notify("result-ready", add_patient(patients, mrn=f"MRN-{i}"), {})[0].status for i in range(5)]
+
+    assert statuses == ["queued", "queued", "queued", "throttled", "throttled"]
+    assert tasks.enqueue.call_count == 3
+
+def test_one_patient_is_not_texted_over_and_over():
+    """Tests the per-patient daily cap."""
+    patient = add_patient(MemoryPatientRepository(MemoryStore()))
+    notifier = Notifier(MemoryNotificationRepository(MemoryStore()), MagicMock(), email=False, sms_throttle=SmsThrottle(MemoryTokenBuckets(clock=lambda: 0.0), 100, 2))
+
+    statuses = [notifier.notify("result-ready", patient, {})[0].status for _ in range(3)]
+
+    assert statuses == ["queued", "queued", "throttled"]
+
+# --- Send Job Test Cases ---
+
+def test_sms_job_sends_and_marks_sent(monkeypatch):
+    """Tests that a queued text is rendered, sent and marked sent from the sender's number."""
+    store = MemoryStore()
+    text = queued_text(MemoryNotificationRepository(store), add_patient(MemoryPatientRepository(store)))
+    sender = use_sms_job(monkeypatch, store)
+
+    assert run_task(jobs.SMS_NOTIFICATION_TASK, {"notificationId": text.notification_id}) is True
+
+    assert sender.send.call_args.args[0].to == PHONE
+    sent = MemoryNotificationRepository(store).get(text.notification_id)
+    assert (sent.status, sent.provider, sent.sender, sent.provider_message_id) == ("sent", "twilio", "+15005550006", "SM123")
+
+def test_sms_job_respects_an_opt_out_since_queued(monkeypatch):
+    """Tests that a patient who texted STOP after the text was queued is not texted."""
+    store = MemoryStore()
+    patients = MemoryPatientRepository(store)
+    text = queued_text(MemoryNotificationRepository(store), add_patient(patients))
+    patients.set_sms_opt_out(PHONE, True)
+    sender = use_sms_job(monkeypatch, store)
+
+    run_task(jobs.SMS_NOTIFICATION_TASK, {"notificationId": text.notification_id})
+
+    sender.send.assert_not_called()
+    assert MemoryNotificationRepository(store).get(text.notification_id).status == "failed"
+
+def test_provider_opt_out_is_recorded_on_the_patients(monkeypatch):
+    """Tests that Twilio's unsubscribed-recipient error opts every patient with the number out."""
+    store = MemoryStore()
+    patients = MemoryPatientRepository(store)
+    text = queued_text(MemoryNotificationRepository(store), add_patient(patients))
+    sibling = add_patient(patients, mrn="MRN-2")
+    sender = use_sms_job(monkeypatch, store)
+    sender.send.side_effect = SmsRecipientOptedOut("The recipient opted out with Twilio")
+
+    assert run_task(jobs.SMS_NOTIFICATION_TASK, {"notificationId": text.notification_id}) is True
+
+    assert patients.get(sibling.patient_id).contact_preferences.sms_opt_out is True
+    assert MemoryNotificationRepository(store).get(text.notification_id).status == "failed"
+
+# --- Webhook Endpoint Test Cases ---
+
+app = FastAPI()
+app.include_router(sms_events.router, prefix="/integrations")
+client = TestClient(app)
+
+@pytest.fixture
+def webhook(monkeypatch):
+    """Configures Twilio's auth token and base URL, and in-memory stores; returns the store."""
+    store = MemoryStore()
+    monkeypatch.setattr(sms_events, "TWILIO_AUTH_TOKEN", "token")
+    monkeypatch.setattr(sms_events, "TWILIO_WEBHOOK_BASE_URL", "https://api.megacare.example")
+    app.dependency_overrides[get_notification_repository] = lambda: MemoryNotificationRepository(store)
+    app.dependency_overrides[get_patient_repository] = lambda: MemoryPatientRepository(store)
+    yield store
+    app.dependency_overrides.clear()
+
+def post_signed(path, params):
+    signature = twilio_signature(f"https://api.megacare.example{path}", params)
+    return client.post(path, content=urlencode(params), headers={
+        "Content-Type": "application/x-www-form-urlencoded", SIGNATURE_HEADER: signature,
+    })
+
+def test_stop_and_start_replies_update_preferences(webhook):
+    """Tests that STOP opts the sender's patients out and START opts them back in."""
+    patients = MemoryPatientRepository(webhook)
+    patient = add_patient(patients)
+
+    response = post_signed("/integrations/sms/twilio/inbound", {"From": PHONE, "Body": "STOP", "MessageSid": "SM1"})
+    assert response.status_code == 200
+    assert response.headers["content-type"].startswith("application/xml")
+    assert patients.get(patient.patient_id).contact_preferences.sms_opt_out is True
+
+    post_signed("/integrations/sms/twilio/inbound", {"From": PHONE, "Body": "start", "MessageSid": "SM2"})
+    assert patients.get(patient.patient_id).contact_preferences.sms_opt_out is False
+
+def test_status_callback_records_delivery(webhook):
+    """Tests that a signed status callback is recorded against the notification in its URL."""
+    notifications = MemoryNotificationRepository(webhook)
+    text = queued_text(notifications, add_patient(MemoryPatientRepository(webhook)))
+
+    response = post_signed(f"/integrations/sms/twilio/status?notificationId={text.notification_id}", {"MessageSid": "SM123", "MessageStatus": "delivered"})
+
+    assert response.status_code == 204
+    assert notifications.get(text.notification_id).status == "delivered"
+
+def test_unsigned_replies_are_refused(webhook):
+    """Tests that a reply without a valid signature changes nothing."""
+    patients = MemoryPatientRepository(webhook)
+    patient = add_patient(patients)
+
+    response = client.post("/integrations/sms/twilio/inbound", content=urlencode({"From": PHONE, "Body": "STOP"}), headers={
+        "Content-Type": "application/x-www-form-urlencoded", SIGNATURE_HEADER: "forged",
+    })
+
+    assert response.status_code == 403
+    assert patients.get(patient.patient_id).contact_preferences.sms_opt_out is False
@@ -0,0 +1,187 @@
+import pytest
+import json
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import date, datetime, timezone
+
+from fastapi import BackgroundTasks, FastAPI
+from app.api.internal.endpoints import tasks as tasks_endpoints
+from app.api.internal.router import internal_router
+from app.api.v1 import schemas
+from app.dependencies import auth
+from app.repositories.encounters import EncounterRepository
+from app.repositories.observations import ObservationRepository
+from app.repositories.patients import PatientRepository
+from app.services.encounters import DISCHARGE_SUMMARY_TITLE, discharge_summary
+from app.tasks import jobs, queue
+from app.tasks.queue import BackgroundTaskQueue, CloudTasksQueue, queue_path
+from app.tasks.registry import PermanentTaskError, run_task, task
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(internal_router, prefix="/internal")
+client = TestClient(app)
+
+QUEUE = "projects/mega-care/locations/asia-southeast1/queues/default"
+HANDLER_URL = "https://api.example.run.app"
+TASKS_ACCOUNT = "cloud-tasks@mega-care.iam.gserviceaccount.com"
+NOW = datetime(2024, 5, 1, 9, 0, tzinfo=timezone.utc)
+
+calls = []
+
+@task("test-echo")
+def echo(payload):
+    calls.append(payload)
+
+@task("test-gone")
+def gone(payload):
+    raise PermanentTaskError("record deleted")
+
+def make_encounter(**overrides):
+    return schemas.Encounter.model_validate({
+        "encounterId": "enc-1",
+        "patientId": "patient-1",
+        "visitType": "ambulatory",
+        "reason": "CPAP titration follow-up",
+        "status": "finished",
+        "start": NOW,
+        "end": datetime(2024, 5, 1, 10, 0, tzinfo=timezone.utc),
+        "observationIds": ["obs-1"],
+        "createdAt": NOW,
+        "updatedAt": NOW,
+        **overrides,
+    })
+
+def make_patient():
+    return schemas.Patient.model_validate({
+        "patientId": "patient-1", "givenName": "Somchai", "familyName": "Jaidee",
+        "dob": date(1965, 3, 14), "mrn": "MRN-001", "createdAt": NOW, "updatedAt": NOW,
+    })
+
+def make_observation():
+    return schemas.Observation.model_validate({
+        "observationId": "obs-1", "patientId": "patient-1",
+        "code": {"system": "http://loinc.org", "code": "59408-5", "display": "SpO2"},
+        "value": 96, "unit": "%", "effectiveAt": NOW, "createdAt": NOW, "updatedAt": NOW,
+    })
+
+# --- Queue Test Cases ---
+
+def test_cloud_tasks_queue_creates_authenticated_http_task(monkeypatch):
+    """Tests that a job becomes a POST to the handler URL carrying an OIDC token for the tasks account."""
+    tasks_client = MagicMock()
+    monkeypatch.setattr(queue, "get_tasks_client", lambda: tasks_client)
+
+    CloudTasksQueue(QUEUE, HANDLER_URL + "/", TASKS_ACCOUNT).enqueue("test-echo", {"jobId": "job-1"}, task_id="echo-job-1")
+
+    kwargs = tasks_client.create_task.call_args.kwargs
+    request = kwargs["task"]["http_request"]
+    assert kwargs["parent"] == QUEUE
+    assert kwargs["task"]["name"] == f"{QUEUE}/tasks/echo-job-1"
+    assert request["url"] == f"{HANDLER_URL}/internal/tasks/test-echo"
+    assert json.loads(request["body"]) == {"jobId": "job-1"}
+    assert request["oidc_token"] == {"service_account_email": TASKS_ACCOUNT, "audience": HANDLER_URL}
+
+def test_cloud_tasks_queue_ignores_duplicate_task_id(monkeypatch):
+    """Tests that enqueueing a job whose ID is already queued is not an error."""
+    from google.api_core.exceptions import AlreadyExists
+    tasks_client = MagicMock()
+    tasks_client.create_task.side_effect = AlreadyExists("exists")
+    monkeypatch.setattr(queue, "get_tasks_client", lambda: tasks_client)
+
+    CloudTasksQueue(QUEUE, HANDLER_URL, TASKS_ACCOUNT).enqueue("test-echo", {}, task_id="echo-1")
+
+def test_queue_path_resolves_queue_ids():
+    """Tests that queue IDs are expanded and full paths are kept."""
+    assert queue_path("default", "asia-southeast1", project="mega-care") == QUEUE
+    assert queue_path(QUEUE) == QUEUE
+    with pytest.raises(ValueError):
+        queue_path("default", None, project="mega-care")
+
+def test_background_queue_runs_job_after_response():
+    """Tests that without Cloud Tasks the job is added to the response's background tasks."""
+    background_tasks = BackgroundTasks()
+
+    BackgroundTaskQueue(background_tasks).enqueue("test-echo", {"n": 1})
+
+    [scheduled] = background_tasks.tasks
+    assert scheduled.func is run_task
+    assert scheduled.args == ("test-echo", {"n": 1})
+
+# --- Registry Test Cases ---
+
+def test_run_task_reports_permanent_failure():
+    """Tests that a permanent failure is acknowledged rather than raised, and unknown tasks are refused."""
+    assert run_task("test-gone", {}) is False
+    with pytest.raises(LookupError):
+        run_task("no-such-task", {})
+
+# --- Handler Test Cases ---
+
+def test_service_account_token_must_match_account(monkeypatch):
+    """Tests that a valid Google token of another service account is rejected."""
+    from google.oauth2 import id_token
+    monkeypatch.setattr(id_token, "verify_oauth2_token", lambda token, request, audience: {
+        "email": "someone-else@mega-care.iam.gserviceaccount.com", "email_verified": True, "aud": audience,
+    })
+
+    with pytest.raises(ValueError):
+        auth.verify_service_account_token("token", HANDLER_URL, TASKS_ACCOUNT)
+
+def test_task_endpoint_runs_registered_job():
+    """Tests that an authenticated Cloud Tasks call runs the job and unknown tasks return 404."""
+    app.dependency_overrides[tasks_endpoints.require_cloud_tasks] = lambda: {"email": TASKS_ACCOUNT}
+    calls.clear()
+
+    response = client.post("/internal/tasks/test-echo", json={"n": 2}, headers={"X-CloudTasks-TaskName": "t-1"})
+    missing = client.post("/internal/tasks/no-such-task", json={})
+
+    assert response.status_code == 204
+    assert calls == [{"n": 2}]
+    assert missing.status_code == 404
+    app.dependency_overrides.pop(tasks_endpoints.require_cloud_tasks, None)
+
+def test_task_endpoint_requires_token():
+    """Tests that calls without a bearer token are refused."""
+    assert client.post("/internal/tasks/test-echo", json={}).status_code in (401, 403)
+
+# --- Discharge Summary Test Cases ---
+
+def test_discharge_summary_lists_visit_and_observations():
+    """Tests that the summary names the patient, the visit and each linked observation."""
+    text = discharge_summary(make_encounter(), make_patient(), [make_observation()])
+
+    assert "Somchai Jaidee (MRN MRN-001, born 1965-03-14)" in text
+    assert "Reason for visit: CPAP titration follow-up" in text
+    assert "- 2024-05-01T09:00:00+00:00 SpO2: 96 %" in text
+
+def test_discharge_summary_job_uploads_and_attaches(monkeypatch):
+    """Tests that the job writes the summary to the bucket and attaches it to the encounter once."""
+    encounters = MagicMock(spec=EncounterRepository)
+    encounters.get.return_value = make_encounter()
+    patients = MagicMock(spec=PatientRepository)
+    patients.get.return_value = make_patient()
+    observations = MagicMock(spec=ObservationRepository)
+    observations.get.return_value = make_observation()
+    bucket = MagicMock()
+    monkeypatch.setattr(jobs.settings, "encounter_documents_bucket", "encounter-docs")
+    monkeypatch.setattr(jobs, "get_encounter_repository", lambda: encounters)
+    monkeypatch.setattr(jobs, "get_patient_repository", lambda: patients)
+    monkeypatch.setattr(jobs, "get_observation_repository", lambda: observations)
+    monkeypatch.setattr(jobs, "get_event_publisher", lambda: MagicMock())
+    monkeypatch.setattr(jobs, "get_storage_client", lambda: MagicMock(bucket=MagicMock(return_value=bucket)))
+
+    jobs.write_discharge_summary({"encounterId": "enc-1"})
+
+    bucket.blob.assert_called_once_with("encounters/enc-1/discharge-summary.txt")
+    document = encounters.add_document.call_args.args[1]
+    assert document.title == DISCHARGE_SUMMARY_TITLE
+    assert document.url == "gs://encounter-docs/encounters/enc-1/discharge-summary.txt"
+
+    attached = schemas.EncounterDocumentLink(
+        documentId="doc-1", title=DISCHARGE_SUMMARY_TITLE, url=document.url, addedAt=NOW, addedBy=jobs.TASK_ACTOR,
+    )
+    encounters.get.return_value = make_encounter(documents=[attached.model_dump(by_alias=True)])
+    jobs.write_discharge_summary({"encounterId": "enc-1"})
+    encounters.add_document.assert_called_once()
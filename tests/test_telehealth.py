@@ -0,0 +1,311 @@
+import base64
+import hashlib
+import hmac
+import json
+import pytest
+from datetime import datetime, timedelta, timezone
+from unittest.mock import MagicMock
+
+import httpx
+import jwt
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api.integrations.endpoints import telehealth_events
+from app.api.v1 import schemas
+from app.api.v1.deps import get_appointment_repository, get_event_publisher, get_telehealth_session_repository
+from app.api.v1.endpoints import telehealth
+from app.dependencies.auth import get_current_user
+from app.repositories.memory import MemoryAppointmentRepository, MemoryStore, MemoryTelehealthSessionRepository
+from app.telehealth.providers import DailyVideoProvider, LiveKitVideoProvider, TwilioVideoProvider, VideoProviderError
+from app.telehealth.webhooks import daily_event, livekit_event, twilio_event, verify_daily_signature, verify_livekit_token
+
+# --- Test Setup ---
+
+NOW = datetime.now(timezone.utc)
+
+def at(minutes):
+    return NOW + timedelta(minutes=minutes)
+
+def book(appointments, start=None, status=None):
+    start = start or at(5)
+    appointment = appointments.create(schemas.AppointmentCreate(patientId="p-1", practitionerId="prac-1", start=start, end=start + timedelta(minutes=30)))
+    return appointments.update(appointment.appointment_id, {"status": status}) if status else appointment
+
+def event(kind, minutes, identity=None, event_id=None):
+    role = "patient" if identity and identity.startswith("patient-") else "staff" if identity else None
+    return schemas.TelehealthSessionEvent(type=kind, at=at(minutes), identity=identity, role=role, provider_event_id=event_id)
+
+def open_session(store, provider="livekit"):
+    appointment = book(MemoryAppointmentRepository(store))
+    return MemoryTelehealthSessionRepository(store).create("s-1", appointment, provider, None)
+
+class RecordingPublisher:
+    def __init__(self):
+        self.events = []
+
+    def emit(self, event_type, subject, data):
+        self.events.append((event_type, subject))
+
+# --- Session Lifecycle Test Cases ---
+
+def test_visit_starts_when_patient_and_clinician_are_both_in():
+    """Tests that a visit is active from when both sides were in the room, and its duration runs to the end."""
+    store = MemoryStore()
+    sessions = MemoryTelehealthSessionRepository(store)
+    session = open_session(store)
+
+    sessions.record_event("s-1", event("participant_joined", 0, "staff-doc-1", "e-1"))
+    assert sessions.get("s-1").status == "waiting"
+    sessions.record_event("s-1", event("participant_joined", 2, "patient-p-1", "e-2"))
+    sessions.record_event("s-1", event("participant_left", 20, "patient-p-1", "e-3"))
+    ended = sessions.record_event("s-1", event("ended", 22), ended_by="doc-1")
+
+    assert session.room_name == "megacare-s-1"
+    assert (ended.status, ended.started_at, ended.ended_at) == ("ended", at(2), at(22))
+    assert ended.duration_seconds == 20 * 60 and ended.ended_by == "doc-1"
+    assert [e.type for e in ended.events] == ["participant_joined", "participant_joined", "participant_left", "ended"]
+
+def test_repeated_and_late_reports_change_nothing():
+    """Tests that a report sent twice is recorded once, an earlier event arriving late still counts, and an end after the end is ignored."""
+    store = MemoryStore()
+    sessions = MemoryTelehealthSessionRepository(store)
+    open_session(store)
+
+    sessions.record_event("s-1", event("participant_joined", 3, "patient-p-1", "e-2"))
+    sessions.record_event("s-1", event("participant_joined", 3, "patient-p-1", "e-2"))
+    sessions.record_event("s-1", event("participant_joined", 1, "staff-doc-1", "e-1"))
+    sessions.record_event("s-1", event("ended", 10), ended_by="doc-1")
+    ended = sessions.record_event("s-1", event("ended", 12, event_id="room-finished"), ended_by="provider")
+
+    assert len(ended.events) == 3
+    assert (ended.started_at, ended.ended_at, ended.ended_by) == (at(3), at(10), "doc-1")
+
+def test_visit_ended_before_both_joined_has_no_duration():
+    """Tests that a visit the patient never joined ends without a start or duration."""
+    store = MemoryStore()
+    sessions = MemoryTelehealthSessionRepository(store)
+    open_session(store)
+
+    sessions.record_event("s-1", event("participant_joined", 0, "staff-doc-1"))
+    ended = sessions.record_event("s-1", event("ended", 15), ended_by="doc-1")
+
+    assert (ended.status, ended.started_at, ended.duration_seconds) == ("ended", None, None)
+
+# --- Provider Test Cases ---
+
+def test_twilio_tokens_are_access_tokens_for_the_room():
+    """Tests the Twilio Video access token: signed with the API key, carrying the identity and room grant."""
+    provider = TwilioVideoProvider("AC123", "SK123", "secret", "https://api.megacare.example", 10, client=MagicMock())
+
+    token = provider.join_token("megacare-s-1", "patient-p-1", at(10), moderator=False)
+
+    claims = jwt.decode(token, "secret", algorithms=["HS256"])
+    assert (claims["iss"], claims["sub"]) == ("SK123", "AC123")
+    assert claims["grants"] == {"identity": "patient-p-1", "video": {"room": "megacare-s-1"}}
+    assert jwt.get_unverified_header(token)["cty"] == "twilio-fpa;v=1"
+
+def test_twilio_rooms_report_to_the_status_callback():
+    """Tests that rooms are created with our status callback, and that a room already created is not an error."""
+    http = MagicMock()
+    http.post.return_value = httpx.Response(400, json={"code": 53113, "message": "Room exists"})
+    provider = TwilioVideoProvider("AC123", "SK123", "secret", "https://api.megacare.example/", 10, client=http)
+
+    assert provider.create_room("megacare-s-1", at(90)) is None
+
+    data = http.post.call_args.kwargs["data"]
+    assert data["StatusCallback"] == "https://api.megacare.example/integrations/telehealth/twilio/status"
+    assert (data["UniqueName"], data["Type"]) == ("megacare-s-1", "group")
+
+def test_daily_rooms_are_private_and_found_again_on_retry():
+    """Tests that a Daily room is created private and expiring, and a retried create returns the existing room's URL."""
+    http = MagicMock()
+    http.request.side_effect = [
+        httpx.Response(400, json={"error": "invalid-request-error", "info": "a room named megacare-s-1 already exists"}),
+        httpx.Response(200, json={"name": "megacare-s-1", "url": "https://megacare.daily.co/megacare-s-1"}),
+    ]
+    provider = DailyVideoProvider("key", 10, client=http)
+
+    assert provider.create_room("megacare-s-1", at(90)) == "https://megacare.daily.co/megacare-s-1"
+
+    body = http.request.call_args_list[0].kwargs["json"]
+    assert body["privacy"] == "private" and body["properties"]["exp"] == int(at(90).timestamp())
+
+def test_livekit_tokens_grant_the_room_only():
+    """Tests the LiveKit token: the identity as subject and a join grant for one room, admin for staff."""
+    provider = LiveKitVideoProvider("wss://megacare.livekit.cloud", "APIkey", "secret", 10, client=MagicMock())
+
+    claims = jwt.decode(provider.join_token("megacare-s-1", "staff-doc-1", at(10), moderator=True), "secret", algorithms=["HS256"])
+
+    assert (claims["iss"], claims["sub"]) == ("APIkey", "staff-doc-1")
+    assert claims["video"]["room"] == "megacare-s-1" and claims["video"]["roomJoin"] and claims["video"]["roomAdmin"]
+
+def test_provider_outages_raise():
+    """Tests that an unreachable provider raises VideoProviderError."""
+    http = MagicMock()
+    http.post.side_effect = httpx.ConnectError("down")
+
+    with pytest.raises(VideoProviderError):
+        LiveKitVideoProvider("wss://megacare.livekit.cloud", "APIkey", "secret", 10, client=http).end_room("megacare-s-1")
+
+# --- Webhook Parsing Test Cases ---
+
+def test_daily_signature():
+    """Tests Daily's HMAC-SHA256 signature over the timestamp and body."""
+    secret = base64.b64encode(b"webhook-secret").decode()
+    payload = b'{"type":"meeting.ended"}'
+    signature = base64.b64encode(hmac.new(b"webhook-secret", b"1700000000." + payload, hashlib.sha256).digest()).decode()
+
+    assert verify_daily_signature(secret, payload, signature, "1700000000")
+    assert not verify_daily_signature(secret, payload + b" ", signature, "1700000000")
+
+def test_livekit_token_must_sign_the_body():
+    """Tests that LiveKit's webhook token must be ours and carry the body's hash."""
+    payload = b'{"event":"room_finished"}'
+    token = jwt.encode({"iss": "APIkey", "sha256": base64.b64encode(hashlib.sha256(payload).digest()).decode()}, "secret", algorithm="HS256")
+
+    assert verify_livekit_token("APIkey", "secret", payload, token)
+    assert not verify_livekit_token("APIkey", "secret", b'{"event":"participant_left"}', token)
+    assert not verify_livekit_token("APIkey", "other-secret", payload, token)
+
+def test_provider_reports_become_session_events():
+    """Tests that each provider's join, leave and end reports name the room, identity and role."""
+    room, joined = twilio_event({
+        "StatusCallbackEvent": "participant-connected", "RoomName": "megacare-s-1", "RoomSid": "RM1",
+        "ParticipantIdentity": "patient-p-1", "SequenceNumber": "3", "Timestamp": "2024-05-01T09:02:00.000Z",
+    })
+    assert (room, joined.type, joined.role, joined.provider_event_id) == ("megacare-s-1", "participant_joined", "patient", "RM1:3")
+    assert joined.at == datetime(2024, 5, 1, 9, 2, tzinfo=timezone.utc)
+
+    _room, left = daily_event({"type": "participant.left", "id": "d-1", "event_ts": 1714554000, "payload": {"room": "megacare-s-1", "user_id": "staff-doc-1"}})
+    assert (left.type, left.role, left.provider_event_id) == ("participant_left", "staff", "d-1")
+
+    _room, ended = livekit_event({"event": "room_finished", "id": "EV_1", "room": {"name": "megacare-s-1"}, "createdAt": "1714554000"})
+    assert (ended.type, ended.identity) == ("ended", None)
+    assert twilio_event({"StatusCallbackEvent": "track-added", "RoomName": "megacare-s-1"}) is None
+
+# --- Endpoint Test Cases ---
+
+app = FastAPI()
+app.include_router(telehealth.router, prefix="/api/v1/telehealth")
+app.include_router(telehealth_events.router, prefix="/integrations")
+client = TestClient(app)
+
+STAFF = {"uid": "doc-1", "roles": ["clinician"]}
+PATIENT = {"uid": "line-1", "roles": ["patient"], "patientId": "p-1"}
+
+@pytest.fixture
+def api(monkeypatch):
+    """In-memory stores, a mock LiveKit provider and a recording publisher; returns them with the store."""
+    store = MemoryStore()
+    provider = MagicMock()
+    provider.name = "livekit"
+    provider.create_room.return_value = "wss://megacare.livekit.cloud"
+    provider.join_token.return_value = "join-token"
+    publisher = RecordingPublisher()
+    monkeypatch.setattr(telehealth, "get_video_provider", lambda: provider)
+    monkeypatch.setattr(telehealth_events, "LIVEKIT_API_KEY", "APIkey")
+    monkeypatch.setattr(telehealth_events, "LIVEKIT_API_SECRET", "secret")
+    app.dependency_overrides[get_appointment_repository] = lambda: MemoryAppointmentRepository(store)
+    app.dependency_overrides[get_telehealth_session_repository] = lambda: MemoryTelehealthSessionRepository(store)
+    app.dependency_overrides[get_event_publisher] = lambda: publisher
+    app.dependency_overrides[get_current_user] = lambda: STAFF
+    yield store, provider, publisher
+    app.dependency_overrides.clear()
+
+def act_as(principal):
+    app.dependency_overrides[get_current_user] = lambda: principal
+
+def test_clinician_opens_a_visit_and_both_get_tokens(api):
+    """Tests opening a visit for a booked appointment and tokens naming the patient and the clinician."""
+    store, provider, publisher = api
+    appointment = book(MemoryAppointmentRepository(store))
+
+    response = client.post("/api/v1/telehealth/sessions", json={"appointmentId": appointment.appointment_id})
+    assert response.status_code == 201
+    session = response.json()
+    assert (session["status"], session["provider"], session["room_url"]) == ("waiting", "livekit", "wss://megacare.livekit.cloud")
+
+    staff_token = client.post(f"/api/v1/telehealth/sessions/{session['telehealth_session_id']}/tokens").json()
+    act_as(PATIENT)
+    patient_token = client.post(f"/api/v1/telehealth/sessions/{session['telehealth_session_id']}/tokens").json()
+
+    assert (staff_token["identity"], staff_token["role"]) == ("staff-doc-1", "staff")
+    assert (patient_token["identity"], patient_token["role"]) == ("patient-p-1", "patient")
+    assert provider.join_token.call_args_list[0].kwargs["moderator"] is True
+    assert datetime.fromisoformat(patient_token["expires_at"]) <= datetime.now(timezone.utc) + timedelta(minutes=10, seconds=5)
+    assert publisher.events == [("telehealth_session.created", f"telehealth/sessions/{session['telehealth_session_id']}")]
+    recorded = MemoryTelehealthSessionRepository(store).get(session["telehealth_session_id"])
+    assert [e.type for e in recorded.events] == ["token_issued", "token_issued"]
+
+def test_visits_need_an_open_appointment(api):
+    """Tests that a cancelled appointment, or one with an open visit, cannot get another."""
+    store, _provider, _publisher = api
+    cancelled = book(MemoryAppointmentRepository(store), status="cancelled")
+    booked = book(MemoryAppointmentRepository(store))
+
+    assert client.post("/api/v1/telehealth/sessions", json={"appointmentId": cancelled.appointment_id}).status_code == 409
+    assert client.post("/api/v1/telehealth/sessions", json={"appointmentId": booked.appointment_id}).status_code == 201
+    assert client.post("/api/v1/telehealth/sessions", json={"appointmentId": booked.appointment_id}).status_code == 409
+    assert client.post("/api/v1/telehealth/sessions", json={"appointmentId": "missing"}).status_code == 422
+
+def test_tokens_only_in_the_join_window(api):
+    """Tests that tokens are refused well before the appointment and to other patients."""
+    store, _provider, _publisher = api
+    later = book(MemoryAppointmentRepository(store), start=at(24 * 60))
+    session = MemoryTelehealthSessionRepository(store).create("s-1", later, "livekit", None)
+
+    assert client.post(f"/api/v1/telehealth/sessions/{session.telehealth_session_id}/tokens").status_code == 409
+    act_as({"uid": "line-2", "roles": ["patient"], "patientId": "p-2"})
+    assert client.post(f"/api/v1/telehealth/sessions/{session.telehealth_session_id}/tokens").status_code == 403
+
+def test_clinician_ends_the_visit(api):
+    """Tests that ending closes the room and emits telehealth_session.ended, and that patients cannot end it."""
+    store, provider, publisher = api
+    session = open_session(store)
+
+    act_as(PATIENT)
+    assert client.post("/api/v1/telehealth/sessions/s-1/end").status_code == 403
+    act_as(STAFF)
+    response = client.post("/api/v1/telehealth/sessions/s-1/end")
+
+    assert response.status_code == 200
+    assert (response.json()["status"], response.json()["ended_by"]) == ("ended", "doc-1")
+    provider.end_room.assert_called_once_with(session.room_name)
+    assert publisher.events == [("telehealth_session.ended", "telehealth/sessions/s-1")]
+    assert client.post("/api/v1/telehealth/sessions/s-1/tokens").status_code == 409
+
+def test_no_provider_is_503(api, monkeypatch):
+    """Tests that visits cannot be opened with TELEHEALTH_PROVIDER=none."""
+    store, _provider, _publisher = api
+    monkeypatch.setattr(telehealth, "get_video_provider", lambda: None)
+
+    assert client.post("/api/v1/telehealth/sessions", json={"appointmentId": book(MemoryAppointmentRepository(store)).appointment_id}).status_code == 503
+
+def post_livekit(body):
+    payload = json.dumps(body).encode()
+    token = jwt.encode({"iss": "APIkey", "sha256": base64.b64encode(hashlib.sha256(payload).digest()).decode()}, "secret", algorithm="HS256")
+    return client.post("/integrations/telehealth/livekit/events", content=payload, headers={"Content-Type": "application/webhook+json", "Authorization": token})
+
+def test_livekit_webhook_starts_and_ends_the_visit(api):
+    """Tests that signed participant and room events are recorded and announce the start and end."""
+    store, _provider, publisher = api
+    open_session(store)
+
+    for identity in ("staff-doc-1", "patient-p-1"):
+        assert post_livekit({"event": "participant_joined", "id": f"EV_{identity}", "room": {"name": "megacare-s-1"}, "participant": {"identity": identity}}).status_code == 204
+    post_livekit({"event": "room_finished", "id": "EV_end", "room": {"name": "megacare-s-1"}})
+
+    session = MemoryTelehealthSessionRepository(store).get("s-1")
+    assert (session.status, session.ended_by) == ("ended", "provider")
+    assert [e for e, _subject in publisher.events] == ["telehealth_session.started", "telehealth_session.ended"]
+
+def test_unsigned_webhooks_are_refused(api):
+    """Tests that a LiveKit event without a valid token changes nothing."""
+    store, _provider, _publisher = api
+    open_session(store)
+
+    response = client.post("/integrations/telehealth/livekit/events", content=b'{"event":"room_finished","room":{"name":"megacare-s-1"}}', headers={"Authorization": "forged"})
+
+    assert response.status_code == 403
+    assert MemoryTelehealthSessionRepository(store).get("s-1").status == "waiting"
@@ -0,0 +1,124 @@
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_organization_repository
+from app.api.v1.endpoints import organizations
+from app.auth.verifier import InvalidTokenError, TokenVerifier
+from app.middleware import tenancy
+from app.middleware.authentication import AuthenticationMiddleware
+from app.middleware.tenancy import TenancyMiddleware
+from app.repositories.memory import MemoryOrganizationRepository, MemoryStore
+from app.tenancy.context import current_tenant
+
+# --- Test Setup ---
+
+TOKENS = {
+    "clinic-a": {"uid": "nurse-a", "roles": ["clinician"], "organizationId": "clinic-a"},
+    "unassigned": {"uid": "nurse-x", "roles": ["clinician"]},
+    "platform": {"uid": "ops-1", "roles": ["platform-admin"]},
+}
+
+def make_verifier():
+    verifier = MagicMock(spec=TokenVerifier)
+
+    def verify(token):
+        if token not in TOKENS:
+            raise InvalidTokenError("Unknown token")
+        return TOKENS[token]
+    verifier.verify.side_effect = verify
+    return verifier
+
+def make_client(default_organization=None):
+    app = FastAPI()
+    app.include_router(organizations.router, prefix="/api/v1/organizations")
+    app.add_middleware(TenancyMiddleware, verifier_factory=make_verifier, default_organization=default_organization)
+    app.add_middleware(AuthenticationMiddleware, verifier_factory=make_verifier)
+
+    @app.get("/api/v1/patients")
+    def list_patients():
+        return {"tenant": current_tenant()}
+
+    @app.get("/fhir/r4/Patient")
+    def search_patients():
+        return {"tenant": current_tenant()}
+
+    return app, TestClient(app)
+
+def bearer(token):
+    return {"Authorization": f"Bearer {token}"}
+
+@pytest.fixture
+def organizations_repo():
+    return MemoryOrganizationRepository(MemoryStore())
+
+# --- Middleware Test Cases ---
+
+def test_requests_act_for_the_callers_organization():
+    """Tests that a member's requests are scoped to the organization in their token, on FHIR routes too."""
+    _, client = make_client()
+
+    assert client.get("/api/v1/patients", headers=bearer("clinic-a")).json() == {"tenant": "clinic-a"}
+    assert client.get("/fhir/r4/Patient", headers=bearer("clinic-a")).json() == {"tenant": "clinic-a"}
+    assert client.get("/fhir/r4/Patient").json() == {"tenant": None}
+
+def test_cross_tenant_requests_are_refused():
+    """Tests that naming another organization in the header or the path is a 403 cross-tenant problem."""
+    _, client = make_client()
+
+    by_header = client.get("/api/v1/patients", headers={**bearer("clinic-a"), "X-Organization-Id": "clinic-b"})
+    by_path = client.get("/api/v1/organizations/clinic-b", headers=bearer("clinic-a"))
+
+    for response in (by_header, by_path):
+        assert response.status_code == 403
+        assert response.json()["type"].endswith("/cross-tenant")
+    assert client.get("/api/v1/patients", headers={**bearer("clinic-a"), "X-Organization-Id": "clinic-a"}).status_code == 200
+
+def test_callers_without_an_organization_use_the_default_or_are_refused():
+    """Tests DEFAULT_ORGANIZATION_ID for tokens without the claim, and 403 without either."""
+    _, client = make_client()
+    response = client.get("/api/v1/patients", headers=bearer("unassigned"))
+    assert response.status_code == 403
+    assert response.json()["type"].endswith("/no-organization")
+
+    _, client = make_client(default_organization="clinic-a")
+    assert client.get("/api/v1/patients", headers=bearer("unassigned")).json() == {"tenant": "clinic-a"}
+
+def test_platform_admins_choose_the_organization(monkeypatch):
+    """Tests that platform administrators act for the organization they name, and across all without one, marked in the audit trail."""
+    marked = []
+    monkeypatch.setattr(tenancy, "security_event", marked.append)
+    _, client = make_client()
+
+    assert client.get("/api/v1/patients", headers={**bearer("platform"), "X-Organization-Id": "clinic-b"}).json() == {"tenant": "clinic-b"}
+    assert client.get("/api/v1/patients", headers=bearer("platform")).json() == {"tenant": None}
+    assert marked == ["cross-tenant-access", "cross-tenant-access"]
+
+    client.get("/api/v1/patients", headers=bearer("clinic-a"))
+    assert len(marked) == 2
+
+# --- Organization Endpoint Test Cases ---
+
+def test_platform_admins_register_organizations_and_members_read_their_own(organizations_repo):
+    """Tests registering an organization, duplicate IDs, and member access to it."""
+    app, client = make_client()
+    app.dependency_overrides[get_organization_repository] = lambda: organizations_repo
+    payload = {"organizationId": "clinic-a", "name": "Chiang Mai Sleep Clinic"}
+
+    created = client.post("/api/v1/organizations", json=payload, headers=bearer("platform"))
+    duplicate = client.post("/api/v1/organizations", json=payload, headers=bearer("platform"))
+    by_member = client.post("/api/v1/organizations", json={**payload, "organizationId": "clinic-c"}, headers=bearer("clinic-a"))
+
+    assert created.status_code == 201
+    assert created.json()["organization_id"] == "clinic-a"
+    assert duplicate.status_code == 409
+    assert by_member.status_code == 403
+    assert client.get("/api/v1/organizations/clinic-a", headers=bearer("clinic-a")).json()["name"] == "Chiang Mai Sleep Clinic"
+    assert client.get("/api/v1/organizations", headers=bearer("clinic-a")).status_code == 403
+
+def test_organization_ids_are_validated():
+    """Tests that organization IDs must be lowercase slugs."""
+    with pytest.raises(ValueError):
+        schemas.OrganizationCreate(organizationId="Clinic A", name="Clinic A")
@@ -0,0 +1,215 @@
+import json
+from datetime import datetime, timezone
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from app.api.v1 import schemas
+from app.api.v1.endpoints import terminology
+from app.cache.base import MemoryCache
+from app.dependencies.auth import get_current_user
+from app.services.lab_results import LabResultValidationError, prepare_lab_result
+from app.terminology.codesystems import ICD_10, LOINC, SNOMED_CT, SYNTAX_CHECKS
+from app.terminology.remote import FhirTerminologyServer, TerminologyServerError
+from app.terminology.service import BUNDLED_PATH, CodeValidationError, get_terminology, load_terminology
+
+# --- Test Setup ---
+
+RXNORM = "http://www.nlm.nih.gov/research/umls/rxnorm"
+
+class FakeResponse:
+    def __init__(self, status_code, body=None):
+        self.status_code = status_code
+        self.body = body
+
+    def json(self):
+        return self.body
+
+class FakeClient:
+    """Answers `$lookup` from a dict of code to parameters, counting the requests."""
+    def __init__(self, concepts, fail=False):
+        self.concepts = concepts
+        self.fail = fail
+        self.requests = []
+
+    def get(self, url, params=None):
+        self.requests.append(params)
+        if self.fail:
+            return FakeResponse(503)
+        parameters = self.concepts.get(params["code"])
+        return FakeResponse(200, {"resourceType": "Parameters", "parameter": parameters}) if parameters else FakeResponse(404)
+
+def with_server(concepts, fail=False):
+    client = FakeClient(concepts, fail=fail)
+    server = FhirTerminologyServer("https://tx.example/fhir", 5, client=client)
+    return load_terminology([BUNDLED_PATH], server=server, cache=MemoryCache()), client
+
+def write_release(tmp_path, content="complete", codes=("59408-5",)):
+    release = {"resourceType": "CodeSystem", "url": LOINC, "version": "2.78", "content": content, "concept": [{"code": code} for code in codes]}
+    (tmp_path / "loinc-release.json").write_text(json.dumps(release))
+    return tmp_path
+
+# --- Syntax Test Cases ---
+
+def test_check_digits_catch_typos():
+    """Tests that LOINC and SNOMED CT check digits and the shape of ICD-10 codes are checked."""
+    assert SYNTAX_CHECKS[LOINC]("59408-5") is None and SYNTAX_CHECKS[LOINC]("2339-0") is None
+    assert "check digit" in SYNTAX_CHECKS[LOINC]("59408-4")
+    assert SYNTAX_CHECKS[SNOMED_CT]("372687004") is None
+    assert "check digit" in SYNTAX_CHECKS[SNOMED_CT]("372687005")
+    assert "not a SNOMED CT concept ID" in SYNTAX_CHECKS[SNOMED_CT]("372687014")
+    assert SYNTAX_CHECKS[ICD_10]("G47.3") is None and SYNTAX_CHECKS[ICD_10]("I10") is None
+    assert SYNTAX_CHECKS[ICD_10]("g47.3") is not None
+
+# --- Lookup and Validation Test Cases ---
+
+def test_bundled_content_loads():
+    """Tests that the code systems and value sets shipped with the service load and hold the codes the API uses."""
+    loaded = get_terminology()
+
+    assert {LOINC, SNOMED_CT, ICD_10} <= set(loaded.code_systems)
+    assert not any(system.complete for system in loaded.code_systems.values())
+    assert {"vital-signs", "sleep-disorders", "allergy-reaction-manifestations", "allergy-substances"} <= set(loaded.value_sets)
+
+def test_lookup_gives_display_and_parents():
+    """Tests that a bundled concept is found with its display and the codes above it."""
+    found = get_terminology().lookup(ICD_10, "G47.3")
+
+    assert found.display == "Sleep apnoea" and found.parents == ["G47"]
+    assert get_terminology().lookup(ICD_10, "Z99.9") is None
+
+def test_codes_outside_the_fragment_are_only_checked_for_form():
+    """Tests that a well-formed code missing from a bundled fragment is accepted, but marked as checked by syntax only."""
+    loaded = get_terminology()
+
+    found = loaded.validate(LOINC, "59408-5")
+    missing = loaded.validate(LOINC, "2339-0")
+    mistyped = loaded.validate(LOINC, "2339-1")
+    unknown_system = loaded.validate(RXNORM, "7980")
+
+    assert (found.result, found.verification, found.display) == (True, "concept", "Oxygen saturation in Arterial blood by Pulse oximetry")
+    assert (missing.result, missing.verification) == (True, "syntax")
+    assert (mistyped.result, mistyped.verification) == (False, "syntax")
+    assert (unknown_system.result, unknown_system.verification) == (True, "none")
+
+def test_display_is_checked_when_given():
+    """Tests that a display must be one of the code's, ignoring case."""
+    loaded = get_terminology()
+
+    assert loaded.validate(ICD_10, "G47.3", display="sleep apnoea").result
+    assert not loaded.validate(ICD_10, "G47.3", display="Narcolepsy").result
+
+def test_complete_release_refuses_missing_codes(tmp_path):
+    """Tests that a code missing from a loaded complete release is refused, and that it merges with the bundled fragment."""
+    loaded = load_terminology([BUNDLED_PATH, write_release(tmp_path)])
+
+    assert loaded.code_systems[LOINC].complete and loaded.code_systems[LOINC].version == "2.78"
+    assert not loaded.validate(LOINC, "2339-0").result
+    assert loaded.validate(LOINC, "8867-4").verification == "concept"
+    with pytest.raises(CodeValidationError, match="not found in LOINC"):
+        loaded.check_code(LOINC, "2339-0", "Analyte 2339-0")
+
+def test_malformed_value_set_is_rejected(tmp_path):
+    """Tests that loading fails for a value set selecting codes of a system that is not loaded."""
+    (tmp_path / "broken.json").write_text(json.dumps({
+        "resourceType": "ValueSet", "id": "broken", "url": "urn:broken",
+        "compose": {"include": [{"system": RXNORM, "filter": [{"property": "concept", "op": "is-a", "value": "1"}]}]},
+    }))
+
+    with pytest.raises(ValueError, match="not loaded"):
+        load_terminology([tmp_path])
+
+# --- Terminology Server Test Cases ---
+
+def test_server_answers_are_cached():
+    """Tests that codes missing from the loaded content are looked up at the server once, and its answers are used."""
+    loaded, client = with_server({"2339-0": [
+        {"name": "display", "valueString": "Glucose [Mass/volume] in Blood"},
+        {"name": "property", "part": [{"name": "code", "valueCode": "inactive"}, {"name": "value", "valueBoolean": False}]},
+    ]})
+
+    first = loaded.validate(LOINC, "2339-0")
+    second = loaded.validate(LOINC, "2339-0")
+
+    assert (first.result, first.verification, first.display) == (True, "concept", "Glucose [Mass/volume] in Blood")
+    assert second == first and len(client.requests) == 1
+    assert not loaded.validate(LOINC, "2345-7").inactive and len(client.requests) == 1
+
+def test_server_makes_missing_codes_invalid():
+    """Tests that a well-formed code the server does not know is refused, and that bad syntax never reaches it."""
+    loaded, client = with_server({})
+
+    assert not loaded.validate(LOINC, "2339-0").result
+    assert not loaded.validate(LOINC, "2339-1").result
+    assert [params["code"] for params in client.requests] == ["2339-0"]
+
+def test_server_outage_leaves_codes_unverified():
+    """Tests that writes are not blocked while the server is down, but lookups report the failure."""
+    loaded, _ = with_server({}, fail=True)
+
+    assert loaded.validate(LOINC, "2339-0").verification == "syntax"
+    loaded.check_code(LOINC, "2339-0")
+    with pytest.raises(TerminologyServerError):
+        loaded.lookup(LOINC, "2339-0")
+
+# --- Value Set Test Cases ---
+
+def test_expansion_follows_the_hierarchy():
+    """Tests that `descendent-of` selects the codes below a concept but not the concept itself."""
+    loaded = get_terminology()
+    sleep_disorders = loaded.get_value_set("sleep-disorders")
+
+    expansion = loaded.expand(sleep_disorders)
+
+    codes = [coding.code for coding in expansion.contains]
+    assert "G47" not in codes and {"G47.3", "G47.4", "E66.2"} <= set(codes) and expansion.total == len(codes)
+    assert not loaded.validate(ICD_10, "G47", value_set=sleep_disorders).result
+    assert loaded.validate(ICD_10, "G47.3", value_set=sleep_disorders).result
+
+def test_expansion_filters_and_pages():
+    """Tests that an expansion is filtered by code prefix or display text, and paged."""
+    loaded = get_terminology()
+    value_set = loaded.get_value_set("https://api.megacare.example/fhir/ValueSet/sleep-disorders")
+
+    by_text = loaded.expand(value_set, text="APNOEA")
+    page = loaded.expand(value_set, text="G47", offset=2, count=2)
+
+    assert [coding.code for coding in by_text.contains] == ["G47.3"]
+    assert page.total == 7 and [coding.code for coding in page.contains] == ["G47.2", "G47.3"]
+
+# --- Coded Field Test Cases ---
+
+def test_lab_results_with_invalid_loinc_codes_are_refused():
+    """Tests that a lab result is refused when an analyte's LOINC code has the wrong check digit."""
+    lab_in = schemas.LabResultCreate.model_validate({
+        "effectiveAt": datetime(2026, 10, 1, 8, 0, tzinfo=timezone.utc),
+        "analytes": [{"code": "2345-8", "value": 5.4, "unit": "mmol/L"}],
+    })
+
+    with pytest.raises(LabResultValidationError, match="Analyte 2345-8"):
+        prepare_lab_result(lab_in)
+
+# --- Endpoint Test Cases ---
+
+app = FastAPI()
+app.include_router(terminology.router, prefix="/api/v1/terminology")
+client = TestClient(app)
+
+def test_lookup_and_validate_code_endpoints():
+    """Tests the lookup, validate-code and expand routes, and 404 for unknown codes and value sets."""
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "clinician-1", "roles": ["clinician"]}
+    try:
+        found = client.get("/api/v1/terminology/lookup", params={"system": SNOMED_CT, "code": "78275009"})
+        missing = client.get("/api/v1/terminology/lookup", params={"system": SNOMED_CT, "code": "372687005"})
+        validation = client.get("/api/v1/terminology/validate-code", params={"system": SNOMED_CT, "code": "39579001", "valueSet": "allergy-reaction-manifestations"})
+        expansion = client.get("/api/v1/terminology/value-sets/vital-signs/expand", params={"filter": "heart"})
+        unknown = client.get("/api/v1/terminology/value-sets/nothing/expand")
+    finally:
+        app.dependency_overrides.clear()
+
+    assert found.status_code == 200 and found.json()["parents"] == ["73430006"]
+    assert missing.status_code == 404
+    assert validation.json()["result"] is True and validation.json()["display"] == "Anaphylaxis"
+    assert [coding["code"] for coding in expansion.json()["contains"]] == ["8867-4"]
+    assert unknown.status_code == 404
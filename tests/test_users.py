@@ -0,0 +1,184 @@
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_custom_role_repository, get_task_queue
+from app.api.v1.endpoints import mfa, users
+from app.audit.context import AuditContext, audit_context_var
+from app.auth.accounts import AccountExistsError, UserAccounts
+from app.auth.mfa import MfaManager
+from app.auth.sessions import SessionManager
+from app.dependencies.auth import get_current_user
+from app.repositories.memory import MemoryCustomRoleRepository, MemoryStore
+from app.tasks.jobs import STAFF_ACCOUNT_EMAIL_TASK
+from app.tasks.queue import TaskQueue
+from app.tenancy.context import acting_for
+
+# --- Test Setup ---
+
+class MemoryUserAccounts(UserAccounts):
+    """Accounts kept in a dict, with the sign-ins ended per user counted."""
+
+    def __init__(self):
+        self.users = {}
+        self.revoked = []
+
+    def add(self, uid, roles, organization_id="org-1", status="active", email=None):
+        self.users[uid] = schemas.StaffUser(uid=uid, email=email or f"{uid}@example.com", roles=roles, organization_id=organization_id, status=status)
+
+    def list(self, organization_id):
+        return sorted(
+            (user for user in self.users.values() if organization_id is None or user.organization_id == organization_id),
+            key=lambda user: user.uid,
+        )
+
+    def get(self, uid):
+        return self.users.get(uid)
+
+    def create(self, email, display_name, roles, organization_id):
+        if any(user.email == email for user in self.users.values()):
+            raise AccountExistsError("An account with the address already exists")
+        uid = f"u{len(self.users) + 1}"
+        self.users[uid] = schemas.StaffUser(uid=uid, email=email, display_name=display_name, roles=roles, organization_id=organization_id, status="invited")
+        return self.users[uid]
+
+    def set_roles(self, uid, roles):
+        self.users[uid] = self.users[uid].model_copy(update={"roles": roles})
+        return self.users[uid]
+
+    def set_disabled(self, uid, disabled):
+        self.users[uid] = self.users[uid].model_copy(update={"status": "deactivated" if disabled else "active"})
+        return self.users[uid]
+
+    def revoke_sign_ins(self, uid):
+        self.revoked.append(uid)
+
+    def password_link(self, email):
+        return f"https://example.com/reset?email={email}"
+
+class ActAsMiddleware:
+    """Stands in for TenancyMiddleware and AuditMiddleware: acts for org-1 and keeps each request's audit context."""
+
+    contexts = []
+
+    def __init__(self, app):
+        self.app = app
+
+    async def __call__(self, scope, receive, send):
+        context = AuditContext()
+        self.contexts.append(context)
+        token = audit_context_var.set(context)
+        try:
+            with acting_for("org-1"):
+                await self.app(scope, receive, send)
+        finally:
+            audit_context_var.reset(token)
+
+def last_security_event():
+    return ActAsMiddleware.contexts[-1].security_event
+
+ADMIN_USER = {"uid": "admin-1", "roles": ["admin"], "organizationId": "org-1"}
+
+app = FastAPI()
+app.include_router(users.router, prefix="/api/v1/admin/users")
+app.add_middleware(ActAsMiddleware)
+client = TestClient(app)
+
+@pytest.fixture
+def env():
+    """Signs in an administrator of org-1 and overrides the accounts, custom roles, sessions, second factors and task queue."""
+    accounts = MemoryUserAccounts()
+    accounts.add("admin-1", ["admin"])
+    accounts.add("c1", ["clinician"])
+    accounts.add("c2", ["clinician"], organization_id="org-2")
+    accounts.add("p1", ["patient"])
+    mocks = {
+        "accounts": accounts,
+        "sessions": MagicMock(spec=SessionManager),
+        "mfa": MagicMock(spec=MfaManager),
+        "tasks": MagicMock(spec=TaskQueue),
+        "store": MemoryStore(),
+    }
+    app.dependency_overrides[users.user_accounts] = lambda: accounts
+    app.dependency_overrides[get_custom_role_repository] = lambda: MemoryCustomRoleRepository(mocks["store"])
+    app.dependency_overrides[users.portal_sessions] = lambda: mocks["sessions"]
+    app.dependency_overrides[mfa.mfa_manager] = lambda: mocks["mfa"]
+    app.dependency_overrides[get_task_queue] = lambda: mocks["tasks"]
+    app.dependency_overrides[get_current_user] = lambda: ADMIN_USER
+    yield mocks
+    app.dependency_overrides.clear()
+
+# --- Endpoint Test Cases ---
+
+def test_administrators_see_only_their_organizations_staff(env):
+    """Tests that the list and lookups are limited to the caller's organization and to staff."""
+    listed = client.get("/api/v1/admin/users").json()
+
+    assert [user["uid"] for user in listed["items"]] == ["admin-1", "c1"]
+    assert client.get("/api/v1/admin/users/c2").status_code == 404
+    assert client.get("/api/v1/admin/users/p1").status_code == 404
+
+def test_invite_creates_the_account_and_emails_a_link(env):
+    """Tests that an invitation creates an account in the caller's organization and queues the email."""
+    response = client.post("/api/v1/admin/users", json={"email": "new@example.com", "displayName": "New Nurse", "roles": ["clinician", "coordinator"]})
+
+    assert response.status_code == 201
+    invited = response.json()
+    assert invited["status"] == "invited"
+    assert invited["organization_id"] == "org-1"
+    env["tasks"].enqueue.assert_called_once_with(STAFF_ACCOUNT_EMAIL_TASK, {"uid": invited["uid"], "invited": True}, task_id=f"staff-invitation-{invited['uid']}")
+    assert last_security_event() == "user-invited"
+    assert client.post("/api/v1/admin/users", json={"email": "new@example.com", "roles": ["clinician"]}).status_code == 409
+
+def test_invites_cannot_grant_patient_or_platform_roles(env):
+    """Tests that tenant administrators cannot make patients or platform administrators."""
+    assert client.post("/api/v1/admin/users", json={"email": "a@example.com", "roles": ["patient"]}).status_code == 400
+    assert client.post("/api/v1/admin/users", json={"email": "b@example.com", "roles": ["platform-admin"]}).status_code == 403
+    env["tasks"].enqueue.assert_not_called()
+
+def test_only_known_roles_are_assigned(env):
+    """Tests that roles must be built in or defined by the organization."""
+    with acting_for("org-1"):
+        MemoryCustomRoleRepository(env["store"]).create(schemas.CustomRoleCreate(name="lab-technician", permissions=["patients:read"]), "admin-1")
+
+    assert client.put("/api/v1/admin/users/c1/roles", json={"roles": ["lab-tech"]}).status_code == 400
+    assert client.put("/api/v1/admin/users/c1/roles", json={"roles": ["lab-technician"]}).json()["roles"] == ["lab-technician"]
+
+def test_changing_roles_ends_sign_ins(env):
+    """Tests that new roles replace the old ones and the user's sign-ins are ended."""
+    response = client.put("/api/v1/admin/users/c1/roles", json={"roles": ["coordinator"]})
+
+    assert response.status_code == 200
+    assert response.json()["roles"] == ["coordinator"]
+    assert env["accounts"].revoked == ["c1"]
+    env["sessions"].revoke_all.assert_called_once_with("c1", "roles-changed")
+    assert last_security_event() == "roles-changed"
+
+def test_deactivate_and_reactivate(env):
+    """Tests that deactivating signs the user out and that administrators cannot deactivate themselves."""
+    assert client.post("/api/v1/admin/users/c1/deactivate").json()["status"] == "deactivated"
+    assert env["accounts"].revoked == ["c1"]
+    assert client.post("/api/v1/admin/users/c1/credential-reset", json={}).status_code == 409
+
+    assert client.post("/api/v1/admin/users/c1/reactivate").json()["status"] == "active"
+    assert client.post("/api/v1/admin/users/admin-1/deactivate").status_code == 400
+    assert client.post("/api/v1/admin/users/c2/deactivate").status_code == 404
+
+def test_credential_reset_signs_out_and_can_remove_second_factors(env):
+    """Tests that a reset ends sign-ins, optionally removes second factors and emails a link."""
+    response = client.post("/api/v1/admin/users/c1/credential-reset", json={"resetMfa": True})
+
+    assert response.status_code == 202
+    env["sessions"].revoke_all.assert_called_once_with("c1", "credentials-reset")
+    env["mfa"].reset.assert_called_once_with("c1")
+    env["tasks"].enqueue.assert_called_once_with(STAFF_ACCOUNT_EMAIL_TASK, {"uid": "c1", "invited": False})
+    assert last_security_event() == "credentials-reset"
+
+def test_only_administrators_manage_users(env):
+    """Tests that other staff cannot manage accounts."""
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "c1", "roles": ["clinician"], "organizationId": "org-1"}
+
+    assert client.get("/api/v1/admin/users").status_code == 403
+    assert client.post("/api/v1/admin/users/c1/credential-reset", json={}).status_code == 403
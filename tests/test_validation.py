@@ -0,0 +1,120 @@
+import pytest
+from datetime import date, datetime, timedelta, timezone
+from pydantic import TypeAdapter, ValidationError
+
+from app.api.v1 import schemas
+from app.validation.fields import (
+    BirthDate,
+    FutureInstant,
+    MedicalRecordNumber,
+    NhsNumber,
+    PhoneNumber,
+    SocialSecurityNumber,
+    is_valid_nhs_number,
+)
+
+# --- Test Setup ---
+
+def error_codes(adapter, value):
+    with pytest.raises(ValidationError) as excinfo:
+        adapter.validate_python(value)
+    return [error["type"] for error in excinfo.value.errors()]
+
+def patient_payload(**overrides):
+    payload = {"givenName": "Somchai", "familyName": "Jaidee", "dob": "1980-04-01", "mrn": "MRN-0001"}
+    payload.update(overrides)
+    return payload
+
+# --- Field Rule Test Cases ---
+
+def test_nhs_number_check_digit():
+    """Tests the modulus 11 check, including numbers whose check digit would be 10."""
+    assert is_valid_nhs_number("9434765919")
+    assert TypeAdapter(NhsNumber).validate_python("943 476 5919") == "9434765919"
+    for invalid in ["9434765918", "943476591", "94347659190", "abcdefghij", "1000000010"]:
+        assert not is_valid_nhs_number(invalid), invalid
+
+def test_mrn_format():
+    """Tests that MRNs are letters, digits and hyphens of a sensible length."""
+    adapter = TypeAdapter(MedicalRecordNumber)
+
+    assert adapter.validate_python("MRN-0001") == "MRN-0001"
+    for invalid in ["M1", "-MRN-1", "MRN 0001", "MRN_0001", "M" * 33]:
+        assert error_codes(adapter, invalid) == ["mrn_format"], invalid
+
+def test_ssns_are_normalised_and_unissued_numbers_refused():
+    """Tests the 123-45-6789 form, and areas, groups and serials the SSA never issues."""
+    adapter = TypeAdapter(SocialSecurityNumber)
+
+    assert adapter.validate_python("123456789") == "123-45-6789"
+    assert adapter.validate_python("123 45 6789") == "123-45-6789"
+    for invalid in ["000-12-3456", "666-12-3456", "912-34-5678", "123-00-4567", "123-45-0000", "12-345-6789", "12345678"]:
+        assert error_codes(adapter, invalid) == ["ssn_format"], invalid
+
+def test_phone_numbers_are_normalised_to_e164():
+    """Tests that separators are dropped and national or 00-prefixed numbers become E.164."""
+    adapter = TypeAdapter(PhoneNumber)
+
+    assert adapter.validate_python("+66812345678") == "+66812345678"
+    assert adapter.validate_python("081-234 5678") == "+66812345678"
+    assert adapter.validate_python("0066 (81) 234.5678") == "+66812345678"
+    for invalid in ["12345", "+0812345678", "+66 81 ABC 5678", "+6681234567890123"]:
+        assert error_codes(adapter, invalid) == ["phone_e164"], invalid
+
+def test_birth_date_may_be_today_but_not_in_the_future():
+    """Tests that newborns can be registered but dates after today are refused."""
+    adapter = TypeAdapter(BirthDate)
+    today = datetime.now(timezone.utc).date()
+
+    assert adapter.validate_python(today) == today
+    assert error_codes(adapter, today + timedelta(days=1)) == ["date_in_future"]
+
+def test_future_instant_treats_naive_values_as_utc():
+    """Tests that past instants are refused with or without an offset."""
+    adapter = TypeAdapter(FutureInstant)
+    past = datetime.now(timezone.utc) - timedelta(minutes=1)
+
+    assert adapter.validate_python(past + timedelta(days=1)) > past
+    assert error_codes(adapter, past) == ["date_not_in_future"]
+    assert error_codes(adapter, past.replace(tzinfo=None)) == ["date_not_in_future"]
+
+# --- Schema Test Cases ---
+
+def test_patient_create_reports_every_invalid_field():
+    """Tests that one validation error lists each broken rule of a patient payload."""
+    payload = patient_payload(mrn="#1", dob="2999-01-01", nhsNumber="9434765918", contact={"phoneNumber": "12"})
+
+    with pytest.raises(ValidationError) as excinfo:
+        schemas.PatientCreate.model_validate(payload)
+
+    errors = {tuple(error["loc"]): error["type"] for error in excinfo.value.errors()}
+    assert errors == {
+        ("dob",): "date_in_future",
+        ("mrn",): "mrn_format",
+        ("nhsNumber",): "nhs_number",
+        ("contact", "phoneNumber"): "phone_e164",
+    }
+
+def test_rules_apply_to_payloads_not_stored_records():
+    """Tests that a stored patient predating the rules still loads as it is."""
+    patient = schemas.Patient.model_validate(patient_payload(
+        patientId="p-1",
+        mrn="legacy mrn",
+        contact={"phoneNumber": "0812345678"},
+        createdAt=datetime(2024, 1, 1, tzinfo=timezone.utc),
+        updatedAt=datetime(2024, 1, 1, tzinfo=timezone.utc),
+    ))
+
+    assert patient.mrn == "legacy mrn"
+    assert patient.contact.phone_number == "0812345678"
+    assert schemas.PatientCreate.model_validate(patient_payload(contact={"phoneNumber": "0812345678"})).contact.phone_number == "+66812345678"
+    assert schemas.PatientUpdate.model_validate({"dob": date(1980, 4, 1)}).dob == date(1980, 4, 1)
+
+def test_consent_expiry_must_be_in_the_future():
+    """Tests that a consent cannot be granted with an expiry that has already passed."""
+    grant = {"scope": "research", "documentId": "doc-1", "expiresAt": "2020-01-01T00:00:00Z"}
+
+    with pytest.raises(ValidationError) as excinfo:
+        schemas.ConsentGrant.model_validate(grant)
+
+    assert excinfo.value.errors()[0]["loc"] == ("expiresAt",)
@@ -0,0 +1,118 @@
+from datetime import date
+
+from fastapi.testclient import TestClient
+from starlette.datastructures import Headers
+
+from fastapi import FastAPI
+from app.api import versioning
+from app.api.versioning import ApiVersion, RouteDeprecation, deprecation_headers, normalize_version, path_version, requested_version, strip_version
+from app.auth.api_keys import scope_for
+from app.middleware.versioning import ApiVersionMiddleware
+
+# --- Test Setup ---
+
+app = FastAPI()
+
+@app.get("/api/v1/patients/{patientId}")
+def read_patient_v1(patientId: str):
+    return {"patient_id": patientId, "version": 1}
+
+@app.get("/api/v2/patients/{patientId}")
+def read_patient_v2(patientId: str):
+    return {"patientId": patientId, "version": 2}
+
+@app.get("/fhir/metadata")
+def metadata():
+    return {"resourceType": "CapabilityStatement"}
+
+app.add_middleware(ApiVersionMiddleware, default_version="v1")
+
+client = TestClient(app)
+
+def serve_v2(monkeypatch):
+    monkeypatch.setitem(versioning.API_VERSIONS, "v2", ApiVersion("v2"))
+
+# --- Parsing Test Cases ---
+
+def test_versions_are_read_from_paths_and_headers():
+    """Tests that versions are found in paths, and that requested versions are normalised."""
+    assert path_version("/api/v1/patients") == "v1"
+    assert path_version("/api/v12") == "v12"
+    assert path_version("/api/patients") is None
+    assert path_version("/api/v1patients") is None
+    assert strip_version("/api/v1/patients/p-1") == "/patients/p-1"
+    assert normalize_version("2") == normalize_version("V2") == "v2"
+    assert normalize_version("latest") is None
+
+def test_requested_version_prefers_the_header_over_the_accept_parameter():
+    """Tests that Api-Version wins over a version parameter of Accept."""
+    assert requested_version(Headers({"accept": "application/json; version=2"})) == "2"
+    assert requested_version(Headers({"api-version": "v1", "accept": "application/json; version=2"})) == "v1"
+    assert requested_version(Headers({"accept": "application/json"})) is None
+
+def test_deprecation_headers_follow_the_rfcs():
+    """Tests the Deprecation (RFC 9745), Sunset (RFC 8594) and successor Link formats."""
+    headers = dict(deprecation_headers(date(2026, 1, 1), date(2026, 7, 1), "/api/v2/patients"))
+    assert headers["Deprecation"] == "@1767225600"
+    assert headers["Sunset"] == "Wed, 01 Jul 2026 00:00:00 GMT"
+    assert headers["Link"] == '</api/v2/patients>; rel="successor-version"'
+    assert deprecation_headers(None, None) == []
+
+def test_api_key_scopes_are_the_same_in_every_version():
+    """Tests that a key scope names the resource whatever the version."""
+    assert scope_for("GET", "/api/v1/patients/p-1") == scope_for("GET", "/api/v2/patients/p-1") == "patients:read"
+
+# --- Middleware Test Cases ---
+
+def test_versioned_paths_are_served_and_labelled():
+    """Tests that a versioned path is served as is, with its Api-Version."""
+    response = client.get("/api/v1/patients/p-1", headers={"Api-Version": "2"})
+    assert response.status_code == 200
+    assert response.json()["version"] == 1
+    assert response.headers["Api-Version"] == "v1"
+    assert "Deprecation" not in response.headers
+
+def test_unversioned_paths_use_the_negotiated_version(monkeypatch):
+    """Tests that /api paths are rewritten to the requested version, or the default one."""
+    serve_v2(monkeypatch)
+    assert client.get("/api/patients/p-1").json()["version"] == 1
+    response = client.get("/api/patients/p-1", headers={"Api-Version": "2"})
+    assert response.json() == {"patientId": "p-1", "version": 2}
+    assert response.headers["Api-Version"] == "v2"
+    assert "Api-Version" in response.headers["Vary"]
+    assert client.get("/api/patients/p-1", headers={"Accept": "application/json; version=2"}).json()["version"] == 2
+
+def test_unknown_versions_are_unsupported_version_problems():
+    """Tests that versions that are not served are refused with a problem naming the served ones."""
+    response = client.get("/api/patients/p-1", headers={"Api-Version": "7"})
+    assert response.status_code == 400
+    assert response.headers["content-type"] == "application/problem+json"
+    assert response.json()["type"] == "urn:megacare:problem:unsupported-version"
+    assert "v1" in response.json()["detail"]
+    assert client.get("/api/v7/patients/p-1").status_code == 404
+
+def test_deprecated_versions_announce_their_sunset(monkeypatch):
+    """Tests that every response of a deprecated version carries Deprecation and Sunset."""
+    serve_v2(monkeypatch)
+    monkeypatch.setitem(versioning.API_VERSIONS, "v1", ApiVersion("v1", deprecated_on=date(2026, 1, 1), sunset_on=date(2026, 7, 1)))
+    response = client.get("/api/v1/patients/p-1")
+    assert response.headers["Deprecation"] == "@1767225600"
+    assert response.headers["Sunset"] == "Wed, 01 Jul 2026 00:00:00 GMT"
+    assert "Deprecation" not in client.get("/api/v2/patients/p-1").headers
+
+def test_deprecated_routes_link_their_successor(monkeypatch):
+    """Tests that a retired route is announced by its template, and other routes are not."""
+    serve_v2(monkeypatch)
+    monkeypatch.setattr(versioning, "DEPRECATED_ROUTES", (
+        RouteDeprecation("GET", "/api/v1/patients/{patientId}", date(2026, 1, 1), successor="/api/v2/patients/{patientId}"),
+    ))
+    response = client.get("/api/v1/patients/p-1")
+    assert response.headers["Deprecation"] == "@1767225600"
+    assert response.headers["Link"] == '</api/v2/patients/{patientId}>; rel="successor-version"'
+    assert "Deprecation" not in client.get("/api/v2/patients/p-1").headers
+
+def test_other_paths_are_left_alone():
+    """Tests that routes outside /api get no version header."""
+    response = client.get("/fhir/metadata", headers={"Api-Version": "7"})
+    assert response.status_code == 200
+    assert "Api-Version" not in response.headers
@@ -0,0 +1,53 @@
+import threading
+
+import pytest
+
+from app.core import warmup
+
+# --- Test Setup ---
+
+@pytest.fixture(autouse=True)
+def no_hooks(monkeypatch):
+    monkeypatch.setattr(warmup, "_hooks", {})
+
+# --- Test Cases ---
+
+def test_every_hook_runs():
+    """Tests that each registered hook runs once and is reported ok."""
+    calls = []
+    warmup.register_hook("pool", lambda: calls.append("pool"))
+    warmup.register_hook("keys", lambda: calls.append("keys"))
+
+    results = warmup.run_warmup(timeout=5)
+
+    assert sorted(calls) == ["keys", "pool"]
+    assert {name: result["status"] for name, result in results.items()} == {"pool": "ok", "keys": "ok"}
+
+def test_failed_hook_does_not_stop_the_others():
+    """Tests that a failing hook is reported and the rest still run."""
+    def broken():
+        raise ConnectionError("issuer unreachable")
+    calls = []
+    warmup.register_hook("keys", broken)
+    warmup.register_hook("terminology", lambda: calls.append("terminology"))
+
+    results = warmup.run_warmup(timeout=5)
+
+    assert results["keys"]["status"] == "error"
+    assert results["keys"]["error"] == "issuer unreachable"
+    assert results["terminology"]["status"] == "ok"
+    assert calls == ["terminology"]
+
+def test_slow_hook_is_left_behind_after_the_timeout():
+    """Tests that startup is not held up past the timeout by a hook that hangs."""
+    release = threading.Event()
+    warmup.register_hook("hung", lambda: release.wait(5))
+
+    results = warmup.run_warmup(timeout=0.05)
+    release.set()
+
+    assert results == {"hung": {"status": "timeout"}}
+
+def test_templates_render():
+    """Tests that the template hook renders every notification template."""
+    warmup.warm_templates()
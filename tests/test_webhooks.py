@@ -0,0 +1,249 @@
+import pytest
+import json
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timedelta, timezone
+
+import httpx
+from fastapi import FastAPI
+from app.api.v1 import schemas
+from app.api.v1.deps import get_webhook_delivery_repository, get_webhook_subscription_repository
+from app.api.v1.endpoints import webhooks
+from app.dependencies.auth import get_current_user
+from app.events.envelope import Event
+from app.events.publisher import EventPublisher
+from app.repositories.memory import MemoryStore, MemoryWebhookDeliveryRepository, MemoryWebhookSubscriptionRepository
+from app.repositories.webhooks import WebhookDeliveryRepository, WebhookSubscriptionRepository
+from app.webhooks.dispatcher import WebhookDispatcher
+from app.webhooks.publisher import WebhookEventPublisher
+from app.webhooks.signing import SIGNATURE_HEADER, sign, verify
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(webhooks.router, prefix="/api/v1/webhooks", tags=["Webhooks"])
+client = TestClient(app)
+
+ADMIN_USER = {"uid": "admin-uid", "roles": ["integration-admin"]}
+NOW = datetime(2024, 5, 1, 9, 0, tzinfo=timezone.utc)
+SECRET = "whsec_test"
+
+def make_event():
+    return Event(type="patient.created", subject="patients/p-1", data={"patientId": "p-1"})
+
+def make_subscription_in(**overrides):
+    data = {"url": "https://partner.example.com/hooks", "eventTypes": ["patient.created"]}
+    data.update(overrides)
+    return schemas.WebhookSubscriptionCreate.model_validate(data)
+
+def make_subscription(active=True):
+    return schemas.WebhookSubscriptionWithSecret(
+        subscriptionId="sub-1",
+        url="https://partner.example.com/hooks",
+        eventTypes=["patient.created"],
+        active=active,
+        secret=SECRET,
+        createdBy="admin-uid",
+        createdAt=NOW,
+        updatedAt=NOW,
+    )
+
+def make_delivery(event, attempts=0, status="pending"):
+    return schemas.WebhookDelivery(
+        deliveryId="del-1",
+        subscriptionId="sub-1",
+        eventId=event.id,
+        eventType=event.type,
+        event=event.model_dump(mode="json", by_alias=True),
+        status=status,
+        attempts=attempts,
+        nextAttemptAt=NOW,
+        createdAt=NOW,
+        updatedAt=NOW,
+    )
+
+def make_dispatcher(deliveries, subscription, handler, max_attempts=3):
+    subscriptions = MagicMock(spec=WebhookSubscriptionRepository)
+    subscriptions.get.return_value = subscription
+    repo = MagicMock(spec=WebhookDeliveryRepository)
+    repo.claim.return_value = deliveries
+    dispatcher = WebhookDispatcher(
+        subscriptions,
+        repo,
+        client=httpx.Client(transport=httpx.MockTransport(handler)),
+        max_attempts=max_attempts,
+        clock=lambda: NOW,
+    )
+    return dispatcher, repo
+
+# --- Signing Test Cases ---
+
+def test_signature_verifies_and_rejects_tampering():
+    """Tests that a signature verifies for the signed body only, and not after the tolerance."""
+    header = sign(SECRET, b'{"id":"1"}', timestamp=1_700_000_000)
+
+    assert verify(SECRET, b'{"id":"1"}', header, now=1_700_000_060)
+    assert not verify(SECRET, b'{"id":"2"}', header, now=1_700_000_060)
+    assert not verify("whsec_other", b'{"id":"1"}', header, now=1_700_000_060)
+    assert not verify(SECRET, b'{"id":"1"}', header, now=1_700_000_000 + 301)
+
+# --- Schema Test Cases ---
+
+def test_subscription_rejects_non_public_urls():
+    """Tests that plain HTTP and internal callback URLs are refused."""
+    for url in [
+        "http://partner.example.com/hooks",
+        "https://localhost/hooks",
+        "https://10.0.0.5/hooks",
+        "https://169.254.169.254/computeMetadata",
+        "https://metadata.google.internal/",
+    ]:
+        with pytest.raises(ValueError):
+            make_subscription_in(url=url)
+
+# --- Fan-out Test Cases ---
+
+def test_publisher_queues_delivery_per_matching_subscription():
+    """Tests that each active subscription to the event type gets a delivery and the inner publisher still runs."""
+    store = MemoryStore()
+    subscriptions, deliveries = MemoryWebhookSubscriptionRepository(store), MemoryWebhookDeliveryRepository(store)
+    matching = subscriptions.create(make_subscription_in(), SECRET, "admin-uid")
+    subscriptions.create(make_subscription_in(eventTypes=["appointment.booked"]), SECRET, "admin-uid")
+    paused = subscriptions.create(make_subscription_in(), SECRET, "admin-uid")
+    subscriptions.update(paused.subscription_id, schemas.WebhookSubscriptionUpdate(active=False))
+    inner = MagicMock(spec=EventPublisher)
+    inner.transactional = False
+    event = make_event()
+
+    WebhookEventPublisher(inner, subscriptions, deliveries).publish(event)
+
+    [delivery] = deliveries.list(matching.subscription_id)
+    assert delivery.event_id == event.id
+    assert delivery.status == "pending"
+    assert deliveries.list(paused.subscription_id) == []
+    inner.publish.assert_called_once_with(event)
+
+def test_publisher_swallows_direct_publish_failure():
+    """Tests that a failed non-transactional inner publish does not undo the queued deliveries."""
+    subscriptions = MagicMock(spec=WebhookSubscriptionRepository)
+    subscriptions.list_for_event_type.return_value = [make_subscription()]
+    deliveries = MagicMock(spec=WebhookDeliveryRepository)
+    inner = MagicMock(spec=EventPublisher)
+    inner.transactional = False
+    inner.publish.side_effect = RuntimeError("Pub/Sub unavailable")
+
+    WebhookEventPublisher(inner, subscriptions, deliveries).publish(make_event())
+
+    deliveries.create.assert_called_once()
+
+# --- Dispatcher Test Cases ---
+
+def test_dispatcher_posts_signed_event_and_marks_success():
+    """Tests that the envelope is POSTed with a verifiable signature and a 2xx marks the delivery succeeded."""
+    event = make_event()
+    requests = []
+
+    def handler(request):
+        requests.append(request)
+        return httpx.Response(204)
+
+    dispatcher, repo = make_dispatcher([make_delivery(event)], make_subscription(), handler)
+    assert dispatcher.drain() == 1
+
+    [request] = requests
+    assert str(request.url) == "https://partner.example.com/hooks"
+    assert json.loads(request.content)["id"] == event.id
+    assert verify(SECRET, request.content, request.headers[SIGNATURE_HEADER])
+    assert request.headers["X-MegaCare-Event-Id"] == event.id
+    repo.mark_succeeded.assert_called_once_with("del-1", 1, 204)
+
+def test_dispatcher_reschedules_with_backoff_on_error_response():
+    """Tests that a non-2xx response is recorded and retried after the backoff delay."""
+    dispatcher, repo = make_dispatcher(
+        [make_delivery(make_event(), attempts=1)], make_subscription(), lambda request: httpx.Response(503, text="busy"),
+    )
+
+    dispatcher.drain()
+
+    repo.reschedule.assert_called_once_with("del-1", 2, NOW + timedelta(seconds=2), "HTTP 503: busy", 503)
+    repo.mark_succeeded.assert_not_called()
+
+def test_dispatcher_gives_up_after_max_attempts():
+    """Tests that the last failed attempt marks the delivery failed."""
+    def handler(request):
+        raise httpx.ConnectTimeout("timed out", request=request)
+
+    dispatcher, repo = make_dispatcher([make_delivery(make_event(), attempts=2)], make_subscription(), handler, max_attempts=3)
+
+    dispatcher.drain()
+
+    repo.mark_failed.assert_called_once_with("del-1", 3, "ConnectTimeout: timed out", None)
+    repo.reschedule.assert_not_called()
+
+def test_dispatcher_fails_deliveries_of_paused_subscriptions():
+    """Tests that nothing is sent for an inactive subscription."""
+    handler = MagicMock()
+    dispatcher, repo = make_dispatcher([make_delivery(make_event())], make_subscription(active=False), handler)
+
+    dispatcher.drain()
+
+    handler.assert_not_called()
+    repo.mark_failed.assert_called_once()
+
+def test_memory_delivery_claim_leases_due_deliveries():
+    """Tests that claimed deliveries are not claimed again until their lease expires."""
+    repo = MemoryWebhookDeliveryRepository(MemoryStore())
+    delivery = repo.create("sub-1", make_event())
+
+    assert [d.delivery_id for d in repo.claim(10, lease_seconds=60)] == [delivery.delivery_id]
+    assert repo.claim(10, lease_seconds=60) == []
+
+# --- Endpoint Test Cases ---
+
+@pytest.fixture
+def repos():
+    """Overrides the webhook repositories with mocks and signs in an integration admin."""
+    subscriptions = MagicMock(spec=WebhookSubscriptionRepository)
+    deliveries = MagicMock(spec=WebhookDeliveryRepository)
+    app.dependency_overrides[get_webhook_subscription_repository] = lambda: subscriptions
+    app.dependency_overrides[get_webhook_delivery_repository] = lambda: deliveries
+    app.dependency_overrides[get_current_user] = lambda: ADMIN_USER
+    yield subscriptions, deliveries
+    app.dependency_overrides.pop(get_webhook_subscription_repository, None)
+    app.dependency_overrides.pop(get_webhook_delivery_repository, None)
+    app.dependency_overrides.pop(get_current_user, None)
+
+def test_create_subscription_returns_secret_once(repos):
+    """Tests that the secret is returned on create but not when the subscription is read."""
+    subscriptions, _ = repos
+    subscriptions.create.return_value = make_subscription()
+    subscriptions.get.return_value = make_subscription()
+
+    created = client.post("/api/v1/webhooks", json={"url": "https://partner.example.com/hooks", "eventTypes": ["patient.created"]})
+    fetched = client.get("/api/v1/webhooks/sub-1")
+
+    assert created.status_code == 201
+    assert created.json()["secret"] == SECRET
+    assert subscriptions.create.call_args.args[1].startswith("whsec_")
+    assert "secret" not in fetched.json()
+
+def test_retry_requeues_failed_delivery(repos):
+    """Tests that a failed delivery can be re-sent, and that pending ones are refused."""
+    _, deliveries = repos
+    event = make_event()
+    deliveries.get.return_value = make_delivery(event, attempts=3, status="failed")
+    deliveries.requeue.return_value = make_delivery(event)
+
+    response = client.post("/api/v1/webhooks/sub-1/deliveries/del-1/retry")
+
+    assert response.status_code == 200
+    deliveries.requeue.assert_called_once_with("del-1")
+
+    deliveries.get.return_value = make_delivery(event)
+    assert client.post("/api/v1/webhooks/sub-1/deliveries/del-1/retry").status_code == 409
+
+def test_webhooks_require_integration_admin(repos):
+    """Tests that users without the integration-admin role are refused."""
+    app.dependency_overrides[get_current_user] = lambda: {"uid": "clinician-uid", "roles": ["clinician"]}
+
+    assert client.get("/api/v1/webhooks").status_code == 403
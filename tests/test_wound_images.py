@@ -0,0 +1,202 @@
+import io
+import pytest
+from fastapi.testclient import TestClient
+from unittest.mock import MagicMock
+from datetime import datetime, timezone
+
+from fastapi import FastAPI
+from PIL import Image
+
+from app.api.v1 import schemas
+from app.api.v1.deps import get_event_publisher, get_patient_repository, get_wound_image_repository
+from app.api.v1.endpoints import wound_images
+from app.crypto.envelope import FieldCipher
+from app.crypto.kms import KeyManager
+from app.dependencies.auth import get_current_user
+from app.images.processing import ImageRejected, process_photo
+from app.repositories.memory import MemoryStore, MemoryWoundImageRepository
+from app.repositories.patients import PatientRepository
+
+# --- Test Setup ---
+
+app = FastAPI()
+app.include_router(wound_images.router, prefix="/api/v1/patients", tags=["Wound Images"])
+app.dependency_overrides[get_current_user] = lambda: {"uid": "clinician-uid-123"}
+client = TestClient(app)
+
+FAKE_PATIENT_ID = "patient-1"
+ORIENTATION, MAKE, GPS_INFO = 0x0112, 0x010F, 0x8825
+
+class FakeKeyManager(KeyManager):
+    """Stands in for Cloud KMS by 'wrapping' data keys reversed."""
+    def wrap(self, data_key):
+        return data_key[::-1], "projects/megacare/locations/asia-southeast1/keyRings/api/cryptoKeys/fields/cryptoKeyVersions/1"
+    def unwrap(self, wrapped, key_version):
+        return wrapped[::-1]
+
+def photo(size=(40, 20), image_format="JPEG", orientation=None, gps=False) -> bytes:
+    """A photo as a phone would take it: with the camera's make, and optionally a rotation and position."""
+    exif = Image.Exif()
+    exif[MAKE] = "Acme Phone"
+    if orientation:
+        exif[ORIENTATION] = orientation
+    if gps:
+        exif[GPS_INFO] = {1: "N", 2: (13.0, 45.0, 0.0), 3: "E", 4: (100.0, 31.0, 0.0)}
+    out = io.BytesIO()
+    Image.new("RGB", size, (180, 60, 60)).save(out, image_format, exif=exif.tobytes())
+    return out.getvalue()
+
+@pytest.fixture
+def storage(monkeypatch):
+    """Points the endpoints at a fake bucket and Cloud Storage calls at an in-memory dict of blobs."""
+    blobs = {}
+    fake = MagicMock()
+    fake.upload_blob.side_effect = lambda bucket, name, data, content_type: blobs.__setitem__(name, data)
+    fake.download_blob.side_effect = lambda bucket, name: blobs[name]
+    fake.signed_download_url.side_effect = lambda bucket, name, ttl: f"https://storage.googleapis.com/{bucket}/{name}?X-Goog-Signature=abc"
+    fake.blobs = blobs
+    monkeypatch.setattr(wound_images, "WOUND_IMAGES_BUCKET", "wounds")
+    monkeypatch.setattr(wound_images, "get_field_cipher", lambda: None)
+    for name in ("upload_blob", "download_blob", "signed_download_url", "delete_blob"):
+        monkeypatch.setattr(wound_images, name, getattr(fake, name))
+    return fake
+
+@pytest.fixture
+def repos():
+    """Overrides the wound image repository with an in-memory one, and the patient repository with a mock."""
+    mocks = {
+        "images": MemoryWoundImageRepository(MemoryStore()),
+        "patients": MagicMock(spec=PatientRepository),
+        "events": MagicMock(),
+    }
+    app.dependency_overrides[get_wound_image_repository] = lambda: mocks["images"]
+    app.dependency_overrides[get_patient_repository] = lambda: mocks["patients"]
+    app.dependency_overrides[get_event_publisher] = lambda: mocks["events"]
+    yield mocks
+    for dep in (get_wound_image_repository, get_patient_repository, get_event_publisher):
+        app.dependency_overrides.pop(dep, None)
+
+def upload(params, body=None, content_type="image/jpeg"):
+    return client.post(
+        f"/api/v1/patients/{FAKE_PATIENT_ID}/wound-images", params=params,
+        content=photo() if body is None else body, headers={"Content-Type": content_type},
+    )
+
+def add_image(repo, image_id, taken_at, length_mm=None, width_mm=None, wound_id="left-heel"):
+    files = schemas.WoundImageFiles(
+        content_type="image/jpeg", width=40, height=20, size=1024,
+        original_blob_name=f"patients/{FAKE_PATIENT_ID}/wound-images/{image_id}/original",
+        thumbnail_blob_name=f"patients/{FAKE_PATIENT_ID}/wound-images/{image_id}/thumbnail.jpg",
+    )
+    image_in = schemas.WoundImageCreate(wound_id=wound_id, taken_at=taken_at, length_mm=length_mm, width_mm=width_mm)
+    return repo.create(FAKE_PATIENT_ID, image_id, image_in, files, uploaded_by="clinician-uid-123")
+
+# --- Processing Test Cases ---
+
+def test_metadata_is_stripped_and_orientation_applied():
+    """Tests that EXIF, including the GPS position, is gone and the photo was turned upright first."""
+    processed = process_photo(photo(orientation=6, gps=True), max_pixels=10_000, thumbnail_pixels=32)
+
+    stored = Image.open(io.BytesIO(processed.original))
+    assert len(stored.getexif()) == 0
+    assert b"Acme Phone" not in processed.original
+    assert (processed.width, processed.height) == (20, 40) == stored.size
+    assert processed.content_type == "image/jpeg"
+
+def test_thumbnail_fits_within_the_configured_size():
+    """Tests that PNG photos keep their format while their thumbnail is a scaled-down JPEG."""
+    processed = process_photo(photo(size=(400, 200), image_format="PNG"), max_pixels=100_000, thumbnail_pixels=100)
+
+    thumbnail = Image.open(io.BytesIO(processed.thumbnail))
+    assert processed.content_type == "image/png"
+    assert (thumbnail.format, thumbnail.size) == ("JPEG", (100, 50))
+
+def test_other_files_are_rejected():
+    """Tests that non-images, other formats and photos over the pixel limit are refused."""
+    gif = io.BytesIO()
+    Image.new("RGB", (10, 10)).save(gif, "GIF")
+
+    for data, max_pixels in ((b"%PDF-1.7", 10_000), (gif.getvalue(), 10_000), (photo(), 100)):
+        with pytest.raises(ImageRejected):
+            process_photo(data, max_pixels=max_pixels, thumbnail_pixels=32)
+
+# --- Endpoint Test Cases ---
+
+def test_upload_stores_the_stripped_photo_and_thumbnail(repos, storage):
+    """Tests that the stored photo is the one without metadata, recorded with its details and announced."""
+    response = upload({"woundId": "left-heel", "takenAt": "2024-05-01T09:00:00Z", "lengthMm": 30, "widthMm": 20})
+
+    assert response.status_code == 201
+    body = response.json()
+    assert (body["wound_id"], body["length_mm"], body["encrypted"]) == ("left-heel", 30, False)
+    original = storage.blobs[body["original_blob_name"]]
+    assert b"Acme Phone" not in original
+    assert Image.open(io.BytesIO(storage.blobs[body["thumbnail_blob_name"]])).format == "JPEG"
+    assert repos["events"].emit.call_args.args[0] == "wound_image.recorded"
+
+def test_upload_encrypts_the_photo_when_a_key_is_configured(repos, storage, monkeypatch):
+    """Tests that only ciphertext is stored for the photo, and that the original route decrypts it."""
+    cipher = FieldCipher(FakeKeyManager())
+    monkeypatch.setattr(wound_images, "get_field_cipher", lambda: cipher)
+
+    body = upload({"woundId": "left-heel"}).json()
+    response = client.get(f"/api/v1/patients/{FAKE_PATIENT_ID}/wound-images/{body['image_id']}/original")
+
+    assert body["encrypted"] is True
+    assert storage.blobs[body["original_blob_name"]].startswith(b"enc:v1:")
+    assert response.status_code == 200
+    assert response.headers["content-type"] == "image/jpeg"
+    assert Image.open(io.BytesIO(response.content)).size == (40, 20)
+
+def test_upload_rejects_files_that_are_not_photos(repos, storage):
+    """Tests that non-images are refused with 422 and nothing is stored."""
+    response = upload({"woundId": "left-heel"}, body=b"%PDF-1.7")
+
+    assert response.status_code == 422
+    storage.upload_blob.assert_not_called()
+
+def test_upload_rejects_oversized_files(repos, storage, monkeypatch):
+    """Tests that photos over WOUND_IMAGE_MAX_BYTES answer 413."""
+    monkeypatch.setattr(wound_images, "MAX_BYTES", 100)
+
+    assert upload({"woundId": "left-heel"}).status_code == 413
+
+def test_uploads_need_a_bucket(repos, monkeypatch):
+    """Tests that uploads answer 503 when WOUND_IMAGES_BUCKET is unset."""
+    monkeypatch.setattr(wound_images, "WOUND_IMAGES_BUCKET", None)
+
+    assert upload({"woundId": "left-heel"}).status_code == 503
+
+def test_series_orders_photos_and_measures_healing(repos, storage):
+    """Tests that a wound's photos come oldest first with thumbnail URLs, and the area change between measured ones."""
+    repo = repos["images"]
+    add_image(repo, "img-3", datetime(2024, 5, 15, tzinfo=timezone.utc), length_mm=20, width_mm=15)
+    add_image(repo, "img-1", datetime(2024, 5, 1, tzinfo=timezone.utc), length_mm=30, width_mm=20)
+    add_image(repo, "img-2", datetime(2024, 5, 8, tzinfo=timezone.utc))
+    add_image(repo, "img-4", datetime(2024, 5, 2, tzinfo=timezone.utc), wound_id="sacrum")
+
+    response = client.get(f"/api/v1/patients/{FAKE_PATIENT_ID}/wounds/left-heel/series")
+
+    assert response.status_code == 200
+    body = response.json()
+    assert [image["image_id"] for image in body["images"]] == ["img-1", "img-2", "img-3"]
+    assert body["area_change_percent"] == -50.0
+    assert body["images"][0]["thumbnail_url"].startswith("https://storage.googleapis.com/wounds/")
+    assert client.get(f"/api/v1/patients/{FAKE_PATIENT_ID}/wounds/right-foot/series").status_code == 404
+
+def test_image_of_another_patient_is_not_found(repos, storage):
+    """Tests that a photo is only reachable under the patient it was taken of."""
+    add_image(repos["images"], "img-1", datetime(2024, 5, 1, tzinfo=timezone.utc))
+
+    assert client.get("/api/v1/patients/patient-2/wound-images/img-1").status_code == 404
+    assert client.get("/api/v1/patients/patient-2/wound-images/img-1/original").status_code == 404
+
+def test_delete_removes_the_photo_and_thumbnail(repos, storage):
+    """Tests that deleting a photo removes the record and both stored files."""
+    image = add_image(repos["images"], "img-1", datetime(2024, 5, 1, tzinfo=timezone.utc))
+
+    response = client.delete(f"/api/v1/patients/{FAKE_PATIENT_ID}/wound-images/img-1")
+
+    assert response.status_code == 204
+    assert repos["images"].get("img-1") is None
+    assert [call.args[1] for call in storage.delete_blob.call_args_list] == [image.original_blob_name, image.thumbnail_blob_name]